@@ -0,0 +1,186 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+type accessLabels struct {
+	Caller    string // full name of the calling component
+	Component string // full name of the called component
+	Method    string // called method
+}
+
+var accessDeniedCalls = metrics.NewCounterMap[accessLabels](
+	"serviceweaver_component_denied_call_count",
+	"Count of Service Weaver component method calls rejected by the access policy of the calling component",
+)
+
+// An accessPolicy enforces the access policies of the calling components on
+// the remote calls to a method. Access policies are configured per calling
+// component:
+//
+//	[serviceweaver.access."github.com/my/project/plugins/Recommender".allow]
+//	"github.com/my/project/catalogservice/T" = ["ListProducts", "GetProduct"]
+//
+// See runtime.AccessConfig for details. Rejected calls fail with
+// ErrPermissionDenied, and are logged by the weavelet hosting the called
+// component. Only remote calls are checked; calls to colocated components are
+// regular function calls.
+//
+// Callers identify themselves by sending their name along with their calls
+// (see callerStub). The weavelets of an application trust each other, so
+// policies guard against components that call more than they should, not
+// against compromised processes.
+type accessPolicy struct {
+	component string          // called component
+	method    string          // called method
+	allowed   map[string]bool // whether a caller with a policy may call method
+	logger    *slog.Logger    // logs rejected calls
+}
+
+// accessPolicy returns the access policy of the calls to the provided method
+// of the provided component, or nil if no component has an access policy.
+func (w *weavelet) accessPolicy(component, method string) *accessPolicy {
+	if len(w.accessConfigs) == 0 {
+		return nil
+	}
+	logger := slog.New(&logging.LogHandler{
+		Opts: logging.Options{
+			App:        w.info.App,
+			Deployment: w.info.DeploymentId,
+			Component:  component,
+			Weavelet:   w.info.Id,
+		},
+		Write: w.createLogSaver(),
+	})
+	return newAccessPolicy(component, method, w.accessConfigs, logger)
+}
+
+// newAccessPolicy returns a new access policy that enforces the provided
+// policies, keyed by calling component, on the calls to the provided method
+// of the provided component.
+func newAccessPolicy(component, method string, configs map[string]runtime.AccessConfig, logger *slog.Logger) *accessPolicy {
+	allowed := map[string]bool{}
+	for caller, config := range configs {
+		methods := config.Allow[component]
+		allowed[caller] = slices.Contains(methods, method) || slices.Contains(methods, "*")
+	}
+	return &accessPolicy{
+		component: component,
+		method:    method,
+		allowed:   allowed,
+		logger:    logger,
+	}
+}
+
+// check returns a PermissionDenied error if the provided caller isn't allowed
+// to make the call, or nil otherwise. Callers without a policy, and calls
+// whose caller is unknown, are allowed. A nil accessPolicy allows every call.
+func (a *accessPolicy) check(ctx context.Context, caller string) error {
+	if a == nil {
+		return nil
+	}
+	if allowed, restricted := a.allowed[caller]; !restricted || allowed {
+		return nil
+	}
+	accessDeniedCalls.Get(accessLabels{Caller: caller, Component: a.component, Method: a.method}).Add(1)
+	trace.SpanFromContext(ctx).AddEvent("call denied",
+		trace.WithAttributes(attribute.String("caller", caller)))
+	a.logger.Warn("Call denied by access policy", "caller", caller, "method", a.method)
+	return fmt.Errorf("%w: component %s may not call %s.%s", call.PermissionDenied, caller, a.component, a.method)
+}
+
+// callerKey is the context key for the calling component stored by
+// callerStub.
+type callerKey struct{}
+
+// callerFromContext returns the name of the calling component stored in ctx by
+// a callerStub, or the empty string if there is none.
+func callerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// callerStub is a codegen.Stub that attaches the name of the calling
+// component to the context of every call, so that remote calls send it to the
+// callee, which checks it against the access policy of the caller. It also
+// runs the calls with the priority of the caller, if any, unless they have a
+// priority set with WithPriority.
+type callerStub struct {
+	stub     codegen.Stub
+	caller   string   // calling component, if it has an access policy
+	priority Priority // priority of the caller's calls, or zero if none
+}
+
+var _ codegen.Stub = &callerStub{}
+
+// callerStub returns a stub that makes the calls of the provided requester
+// through the provided stub. If no component has an access policy or a
+// priority, it returns the provided stub.
+func (w *weavelet) callerStub(stub codegen.Stub, requester string) codegen.Stub {
+	if len(w.accessConfigs) == 0 && len(w.callerPriority) == 0 {
+		return stub
+	}
+	// A caller without a policy still replaces the caller stored in the
+	// context, if any, e.g., by a restricted component calling a colocated
+	// component through a loopbackStub.
+	c := &callerStub{stub: stub, priority: w.callerPriority[requester]}
+	if _, ok := w.accessConfigs[requester]; ok {
+		c.caller = requester
+	}
+	return c
+}
+
+// context returns the context of a call made with the provided context.
+func (c *callerStub) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, callerKey{}, c.caller)
+	if _, ok := priorityFromContext(ctx); !ok && c.priority != 0 {
+		ctx = WithPriority(ctx, c.priority)
+	}
+	return ctx
+}
+
+// Tracer implements the codegen.Stub interface.
+func (c *callerStub) Tracer() trace.Tracer {
+	return c.stub.Tracer()
+}
+
+// Run implements the codegen.Stub interface.
+func (c *callerStub) Run(ctx context.Context, method int, args []byte, shardKey uint64) ([]byte, error) {
+	return c.stub.Run(c.context(ctx), method, args, shardKey)
+}
+
+// Stream implements the codegen.Stub interface.
+func (c *callerStub) Stream(ctx context.Context, method int, args []byte, shardKey uint64) (codegen.ClientStream, error) {
+	return c.stub.Stream(c.context(ctx), method, args, shardKey)
+}
+
+// WrapError implements the codegen.Stub interface.
+func (c *callerStub) WrapError(err error) error {
+	return c.stub.WrapError(err)
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"golang.org/x/exp/slog"
+)
+
+func TestAccessPolicyCheck(t *testing.T) {
+	configs := map[string]runtime.AccessConfig{
+		"a/Plugin": {Allow: map[string][]string{
+			"a/Checkout": {"GetQuote"},
+			"a/Catalog":  {"*"},
+		}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard))
+
+	for _, test := range []struct {
+		caller, component, method string
+		allowed                   bool
+	}{
+		{"a/Plugin", "a/Checkout", "GetQuote", true},
+		{"a/Plugin", "a/Checkout", "PlaceOrder", false},
+		{"a/Plugin", "a/Catalog", "ListProducts", true},
+		{"a/Plugin", "a/Payment", "Charge", false},
+		{"a/Frontend", "a/Checkout", "PlaceOrder", true},
+		{"", "a/Checkout", "PlaceOrder", true},
+	} {
+		err := newAccessPolicy(test.component, test.method, configs, logger).check(context.Background(), test.caller)
+		if got := err == nil; got != test.allowed {
+			t.Errorf("%s calls %s.%s: got allowed %t, want %t (err: %v)", test.caller, test.component, test.method, got, test.allowed, err)
+		}
+		if err != nil && !errors.Is(err, call.PermissionDenied) {
+			t.Errorf("%s calls %s.%s: got %v, want PermissionDenied", test.caller, test.component, test.method, err)
+		}
+	}
+
+	// Without access policies, every call is allowed.
+	none := (&weavelet{}).accessPolicy("a/Checkout", "PlaceOrder")
+	if err := none.check(context.Background(), "a/Plugin"); err != nil {
+		t.Fatalf("check without policies: %v", err)
+	}
+}
+
+func TestCallerStub(t *testing.T) {
+	w := &weavelet{accessConfigs: map[string]runtime.AccessConfig{
+		"a/Plugin": {Allow: map[string][]string{"a/Catalog": {"*"}}},
+	}}
+	client := &metadataClient{}
+	s := &stub{client: client, methods: make([]call.MethodKey, 1)}
+
+	// A restricted caller sends its name.
+	plugin := w.callerStub(s, "a/Plugin")
+	if _, err := plugin.Run(context.Background(), 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got callMetadata
+	if err := json.Unmarshal(client.last, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Caller != "a/Plugin" {
+		t.Fatalf("Run: got caller %q, want %q", got.Caller, "a/Plugin")
+	}
+
+	// An unrestricted caller sends nothing, even when called by a restricted
+	// caller through a colocated component.
+	ctx := context.WithValue(context.Background(), callerKey{}, "a/Plugin")
+	if _, err := w.callerStub(s, "a/Frontend").Run(ctx, 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if client.last != nil {
+		t.Fatalf("Run: got metadata %q, want none", client.last)
+	}
+
+	// Without access policies, stubs aren't wrapped.
+	if got := (&weavelet{}).callerStub(s, "a/Plugin"); got != s {
+		t.Fatalf("callerStub: got %v, want the provided stub", got)
+	}
+}
+
+// accessClient is a call.Connection that enforces an access policy on the
+// calls made on it, and counts the calls it allows.
+type accessClient struct {
+	policy *accessPolicy
+	calls  int
+}
+
+func (c *accessClient) Call(ctx context.Context, _ call.MethodKey, args []byte, opts call.CallOptions) ([]byte, error) {
+	var md callMetadata
+	if opts.Metadata != nil {
+		if err := json.Unmarshal(opts.Metadata, &md); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.policy.check(ctx, md.Caller); err != nil {
+		return nil, err
+	}
+	c.calls++
+	enc := codegen.NewEncoder()
+	enc.String(string(args))
+	enc.Error(nil)
+	return enc.Data(), nil
+}
+
+func (c *accessClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, errors.New("streaming calls not supported")
+}
+
+func (c *accessClient) Close() {}
+
+func TestAccessPolicyCachedResults(t *testing.T) {
+	// Test plan: Cache the result of a call made by an allowed caller, and
+	// check that a caller the access policy forbids is still denied the same
+	// call, instead of getting the cached result.
+	configs := map[string]runtime.AccessConfig{
+		"a/Plugin": {Allow: map[string][]string{"a/Catalog": {"ListProducts"}}},
+		"a/Admin":  {Allow: map[string][]string{"a/Catalog": {"*"}}},
+	}
+	w := &weavelet{accessConfigs: configs}
+	logger := slog.New(slog.NewTextHandler(io.Discard))
+	client := &accessClient{policy: newAccessPolicy("a/Catalog", "DeleteProduct", configs, logger)}
+	labels := cacheLabels{Component: "TestAccessPolicyCachedResults"}
+	s := &stub{
+		client:  client,
+		methods: []call.MethodKey{call.MakeMethodKey("a/Catalog", "DeleteProduct")},
+		policies: []*cachePolicy{{
+			ttl:    time.Hour,
+			hits:   methodCacheHits.Get(labels),
+			misses: methodCacheMisses.Get(labels),
+		}},
+		cache: newMethodCache(1 << 10),
+		clock: systemClock{},
+	}
+	ctx := context.Background()
+	admin := w.callerStub(s, "a/Admin")
+	for i := 0; i < 2; i++ {
+		if _, err := admin.Run(ctx, 0, []byte("id"), 0); err != nil {
+			t.Fatalf("admin: %v", err)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("admin: got %d remote calls, want 1", client.calls)
+	}
+	plugin := w.callerStub(s, "a/Plugin")
+	if _, err := plugin.Run(ctx, 0, []byte("id"), 0); !errors.Is(err, call.PermissionDenied) {
+		t.Fatalf("plugin: got %v, want PermissionDenied", err)
+	}
+
+	// Results are not shared across principals either.
+	alice := withPrincipal(ctx, Principal{Name: "alice", Scheme: "api_key"})
+	bob := withPrincipal(ctx, Principal{Name: "bob", Scheme: "api_key"})
+	for _, ctx := range []context.Context{alice, bob, alice} {
+		if _, err := admin.Run(ctx, 0, []byte("id"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.calls != 3 {
+		t.Fatalf("principals: got %d remote calls, want 3", client.calls)
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// DefaultActorIdleTimeout is the default ActorOptions.IdleTimeout.
+const DefaultActorIdleTimeout = time.Minute
+
+type actorLabels struct {
+	Actors string // the name of the actors
+}
+
+var actorActivations = metrics.NewGaugeMap[actorLabels](
+	"serviceweaver_actor_activations",
+	"Number of actor activations held by the process",
+)
+
+// ActorOptions configure Actors.
+type ActorOptions struct {
+	// IdleTimeout is how long an activation is kept after its last call
+	// ends. An activation idle for longer is deactivated, after which its ID
+	// may be activated again, by any process. If zero,
+	// DefaultActorIdleTimeout is used.
+	IdleTimeout time.Duration
+
+	// LeaseDuration is how long an activation remains valid after its process
+	// last renewed its lease, which it does every third of LeaseDuration. If
+	// the process hosting an activation fails, its ID can be activated again
+	// once LeaseDuration has elapsed. If zero, DefaultLeaseDuration is used.
+	LeaseDuration time.Duration
+}
+
+// Actors are virtual actors of type S, keyed by ID. The runtime guarantees
+// that every ID has at most one activation across the deployment at any time.
+// An activation owns the state of its ID, of type S, and the calls to an ID
+// are run one at a time by its activation, so the state needs no other
+// synchronization. For example, a cart service can keep the cart of every
+// user in memory:
+//
+//	type cartService struct {
+//		weaver.Implements[CartService]
+//		weaver.WithRouter[cartRouter]
+//		carts *weaver.Actors[cart]
+//	}
+//
+//	type cart struct {
+//		items []Item
+//	}
+//
+//	func (c *cartService) Init(context.Context) error {
+//		carts, err := weaver.NewActors[cart](c, "carts", weaver.ActorOptions{})
+//		c.carts = carts
+//		return err
+//	}
+//
+//	func (c *cartService) AddItem(ctx context.Context, user string, item Item) error {
+//		return c.carts.Do(ctx, user, func(ctx context.Context, cart *cart) error {
+//			cart.items = append(cart.items, item)
+//			return nil
+//		})
+//	}
+//
+// Route the methods of the component by ID (see WithRouter), so that all the
+// calls to an ID reach the process hosting its activation. A call that reaches
+// another process, e.g., while keys move between replicas, waits until the
+// activation is deactivated and activates the ID in its own process.
+//
+// An ID is activated when first called, with the zero value of S. If *S has
+// an Activate(ctx context.Context, id string) error method, it is called on
+// activation, e.g., to load the state from a store; an error fails the
+// activation. An activation is deactivated once it has been idle for
+// ActorOptions.IdleTimeout, or when its process drains. If *S has a
+// Deactivate(ctx context.Context, id string) error method, it is called on
+// deactivation, e.g., to save the state to a store. Like a leader, an
+// activation is backed by a lease granted by the deployer. An activation that
+// fails to renew its lease is lost without being deactivated, since another
+// process may activate its ID as soon as the lease expires.
+type Actors[S any] struct {
+	w    *weavelet
+	name string
+	opts ActorOptions
+
+	mu          sync.Mutex
+	draining    bool                      // is the process draining?
+	activations map[string]*activation[S] // activations, by ID
+}
+
+// activation is the activation of an ID.
+type activation[S any] struct {
+	id    string
+	ready chan struct{} // closed once activated, or the activation failed
+	err   error         // activation error, set before ready is closed
+
+	// Set before ready is closed.
+	ctx    context.Context    // done once the activation is lost or deactivated
+	cancel context.CancelFunc // cancels ctx
+	done   chan struct{}      // closed once the lease is released
+
+	mu    sync.Mutex // serializes calls; guards state
+	state *S
+
+	// Guarded by Actors.mu.
+	pinned   int       // number of calls holding the activation
+	lastUsed time.Time // when the last call ended
+}
+
+// activator is implemented by actor states with an Activate method.
+type activator interface {
+	Activate(ctx context.Context, id string) error
+}
+
+// deactivator is implemented by actor states with a Deactivate method.
+type deactivator interface {
+	Deactivate(ctx context.Context, id string) error
+}
+
+// NewActors returns the actors with the provided name. Every process that
+// creates actors with the same name must create them with the same type and
+// options, typically in the Init method of the component that hosts them. A
+// process may create actors with a given name only once.
+func NewActors[S any](requester Instance, name string, opts ActorOptions) (*Actors[S], error) {
+	w := requester.rep().wlet
+	a, err := newActors[S](w, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.onDrain(a.drain)
+	return a, nil
+}
+
+// newActors returns new actors hosted by the provided weavelet.
+func newActors[S any](w *weavelet, name string, opts ActorOptions) (*Actors[S], error) {
+	if opts.IdleTimeout < 0 {
+		return nil, fmt.Errorf("NewActors(%q): negative idle timeout %v", name, opts.IdleTimeout)
+	}
+	if opts.LeaseDuration < 0 {
+		return nil, fmt.Errorf("NewActors(%q): negative lease duration %v", name, opts.LeaseDuration)
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultActorIdleTimeout
+	}
+	if opts.LeaseDuration == 0 {
+		opts.LeaseDuration = DefaultLeaseDuration
+	}
+	if err := w.actors.add(name); err != nil {
+		return nil, err
+	}
+	return &Actors[S]{
+		w:           w,
+		name:        name,
+		opts:        opts,
+		activations: map[string]*activation[S]{},
+	}, nil
+}
+
+// Do runs fn on the state of the activation of the provided ID, activating
+// the ID first if needed. Calls to Do with the same ID run one at a time. If
+// the ID is activated in another process, Do waits until it is deactivated,
+// or until ctx is done. Do returns the error returned by fn.
+func (a *Actors[S]) Do(ctx context.Context, id string, fn func(ctx context.Context, state *S) error) error {
+	for {
+		act, err := a.pin(ctx, id)
+		if err != nil {
+			return err
+		}
+		act.mu.Lock()
+		if act.ctx.Err() != nil {
+			// The activation was lost, or deactivated, while we were waiting
+			// for it. Activate the ID again.
+			act.mu.Unlock()
+			a.unpin(act)
+			continue
+		}
+		err = fn(ctx, act.state)
+		act.mu.Unlock()
+		a.unpin(act)
+		return err
+	}
+}
+
+// pin returns the activation of the provided ID, activating the ID if needed,
+// and prevents it from being deactivated for being idle until unpinned.
+func (a *Actors[S]) pin(ctx context.Context, id string) (*activation[S], error) {
+	for {
+		a.mu.Lock()
+		if a.draining {
+			a.mu.Unlock()
+			return nil, retriable{fmt.Errorf("actors %q: process is draining", a.name)}
+		}
+		act, ok := a.activations[id]
+		if !ok {
+			act = &activation[S]{id: id, ready: make(chan struct{})}
+			a.activations[id] = act
+		}
+		act.pinned++
+		a.mu.Unlock()
+
+		if !ok {
+			if err := a.activate(ctx, act); err != nil {
+				return nil, err
+			}
+			return act, nil
+		}
+		select {
+		case <-act.ready:
+		case <-ctx.Done():
+			a.unpin(act)
+			return nil, ctx.Err()
+		}
+		if act.err == nil {
+			return act, nil
+		}
+		// The activation by another call failed, e.g., because the other
+		// call's context was done. Try to activate the ID ourselves.
+		a.unpin(act)
+	}
+}
+
+// unpin unpins an activation pinned by pin.
+func (a *Actors[S]) unpin(act *activation[S]) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	act.pinned--
+	act.lastUsed = a.w.clock.Now()
+}
+
+// activate activates the ID of the provided activation, pinned by the caller.
+// If the activation fails, it is removed and unpinned.
+func (a *Actors[S]) activate(ctx context.Context, act *activation[S]) (err error) {
+	defer func() {
+		if err != nil {
+			a.remove(act)
+			a.unpin(act)
+			act.err = err
+		}
+		close(act.ready)
+	}()
+
+	// Acquire the lease, waiting for any other process that holds it to
+	// release it, or for it to expire.
+	retry := a.opts.LeaseDuration / 3
+	if retry > time.Second {
+		retry = time.Second
+	}
+	var expires time.Time
+	for {
+		start := a.w.clock.Now()
+		acquired, err := a.lease(ctx, act.id, false)
+		if err != nil {
+			a.w.env.SystemLogger().Error("acquire actor lease", err, "actors", a.name, "id", act.id)
+		}
+		if acquired {
+			expires = start.Add(a.opts.LeaseDuration)
+			break
+		}
+		if err := a.w.clock.Sleep(ctx, retry); err != nil {
+			return err
+		}
+	}
+
+	state := new(S)
+	if x, ok := any(state).(activator); ok {
+		if err := x.Activate(ctx, act.id); err != nil {
+			if _, err := a.lease(context.Background(), act.id, true); err != nil {
+				a.w.env.SystemLogger().Error("release actor lease", err, "actors", a.name, "id", act.id)
+			}
+			return fmt.Errorf("actors %q: activate %q: %w", a.name, act.id, err)
+		}
+	}
+	act.state = state
+	act.ctx, act.cancel = context.WithCancel(context.Background())
+	act.done = make(chan struct{})
+	actorActivations.Get(actorLabels{Actors: a.name}).Add(1)
+	go a.maintain(act, expires)
+	return nil
+}
+
+// maintain renews the lease of an activation until the activation is lost or
+// deactivated, deactivates the activation once it is idle, and finally
+// releases the lease. The lease expires at the provided time unless renewed.
+func (a *Actors[S]) maintain(act *activation[S], expires time.Time) {
+	defer close(act.done)
+	logger := a.w.env.SystemLogger()
+	clock := a.w.clock
+
+	// The activation is lost when the lease expires, even if a renewal is
+	// stuck.
+	expiry := afterFunc(clock, expires.Sub(clock.Now()), act.cancel)
+	ticker := clock.NewTicker(a.opts.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-act.ctx.Done():
+			expiry.Stop()
+			a.remove(act)
+			actorActivations.Get(actorLabels{Actors: a.name}).Sub(1)
+			// Release the lease, so that another process can activate the ID
+			// without waiting for the lease to expire.
+			if _, err := a.lease(context.Background(), act.id, true); err != nil {
+				logger.Error("release actor lease", err, "actors", a.name, "id", act.id)
+			}
+			return
+		case <-ticker.C():
+		}
+
+		if a.removeIfIdle(act) {
+			// The lease was renewed at most a third of the lease duration
+			// ago, so it is still held for longer than this deadline.
+			ctx, cancel := context.WithTimeout(context.Background(), a.opts.LeaseDuration/2)
+			a.deactivate(ctx, act)
+			cancel()
+			continue
+		}
+
+		start := clock.Now()
+		acquired, err := a.lease(act.ctx, act.id, false)
+		switch {
+		case err != nil:
+			// Try again, unless the lease expires first.
+			logger.Error("renew actor lease", err, "actors", a.name, "id", act.id)
+		case !acquired:
+			// Another process holds the lease.
+			act.cancel()
+		default:
+			expiry.Reset(start.Add(a.opts.LeaseDuration).Sub(clock.Now()))
+		}
+	}
+}
+
+// deactivate deactivates an activation, once the call it is running, if any,
+// ends. It does nothing if the activation was already lost or deactivated.
+func (a *Actors[S]) deactivate(ctx context.Context, act *activation[S]) {
+	act.mu.Lock()
+	defer act.mu.Unlock()
+	if act.ctx.Err() != nil {
+		return
+	}
+	if x, ok := any(act.state).(deactivator); ok {
+		if err := x.Deactivate(ctx, act.id); err != nil {
+			a.w.env.SystemLogger().Error("deactivate actor", err, "actors", a.name, "id", act.id)
+		}
+	}
+	act.cancel()
+}
+
+// remove removes an activation, if it hasn't been removed already.
+func (a *Actors[S]) remove(act *activation[S]) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.activations[act.id] == act {
+		delete(a.activations, act.id)
+	}
+}
+
+// removeIfIdle removes an activation if it has been idle for the idle
+// timeout, and returns whether it was removed. Once removed, an activation
+// can't be pinned.
+func (a *Actors[S]) removeIfIdle(act *activation[S]) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if act.pinned > 0 || a.w.clock.Since(act.lastUsed) < a.opts.IdleTimeout {
+		return false
+	}
+	if a.activations[act.id] == act {
+		delete(a.activations, act.id)
+	}
+	return true
+}
+
+// drain deactivates all the activations, and prevents new ones. It is called
+// when the process drains.
+func (a *Actors[S]) drain(ctx context.Context) error {
+	a.mu.Lock()
+	a.draining = true
+	acts := make([]*activation[S], 0, len(a.activations))
+	for _, act := range a.activations {
+		acts = append(acts, act)
+	}
+	a.activations = map[string]*activation[S]{}
+	a.mu.Unlock()
+
+	for _, act := range acts {
+		select {
+		case <-act.ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if act.err != nil {
+			continue
+		}
+		a.deactivate(ctx, act)
+		select {
+		case <-act.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// lease acquires, renews, or releases the lease of the provided ID.
+func (a *Actors[S]) lease(ctx context.Context, id string, release bool) (bool, error) {
+	reply, err := a.w.env.Lease(ctx, &protos.LeaseRequest{
+		Election:       fmt.Sprintf("serviceweaver/actors/%s/%s", a.name, id),
+		Holder:         a.w.info.Id,
+		DurationMicros: a.opts.LeaseDuration.Microseconds(),
+		Release:        release,
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.Acquired, nil
+}
+
+// actorNames holds the names of the actors created with NewActors in a
+// weavelet.
+type actorNames struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newActorNames() *actorNames {
+	return &actorNames{names: map[string]bool{}}
+}
+
+// add adds the provided actors. It returns an error if actors with the same
+// name were already added.
+func (a *actorNames) add(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.names[name] {
+		return fmt.Errorf("actors %q already created", name)
+	}
+	a.names[name] = true
+	return nil
+}
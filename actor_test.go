@@ -0,0 +1,224 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slog"
+)
+
+// leaseEnv is an env that grants leases from a lease table.
+type leaseEnv struct {
+	env
+	t     testing.TB
+	table *lease.Table
+}
+
+func (e leaseEnv) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return e.table.Handle(time.Now(), req), nil
+}
+
+func (e leaseEnv) SystemLogger() *slog.Logger {
+	return logging.NewTestLogger(e.t)
+}
+
+// events records the activations and deactivations of counters.
+var events struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func record(event string) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	events.log = append(events.log, event)
+}
+
+func recorded() []string {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	log := events.log
+	events.log = nil
+	return log
+}
+
+// counter is the state of a test actor.
+type counter struct {
+	n int
+}
+
+func (c *counter) Activate(_ context.Context, id string) error {
+	record("activate " + id)
+	return nil
+}
+
+func (c *counter) Deactivate(_ context.Context, id string) error {
+	record("deactivate " + id)
+	return nil
+}
+
+// testActors returns actors of counters hosted by a weavelet with the provided
+// id, whose leases are granted by the provided table.
+func testActors(t *testing.T, table *lease.Table, id string, opts ActorOptions) *Actors[counter] {
+	t.Helper()
+	w := &weavelet{
+		env:    leaseEnv{t: t, table: table},
+		info:   &protos.EnvelopeInfo{Id: id},
+		actors: newActorNames(),
+		clock:  systemClock{},
+	}
+	a, err := newActors[counter](w, "counters", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newActors[counter](w, "counters", opts); err == nil {
+		t.Fatal("newActors with the same name: unexpected success")
+	}
+	t.Cleanup(func() { a.drain(context.Background()) })
+	return a
+}
+
+// increment increments the counter with the provided id and returns its new
+// value.
+func increment(ctx context.Context, a *Actors[counter], id string) (int, error) {
+	var n int
+	err := a.Do(ctx, id, func(_ context.Context, c *counter) error {
+		c.n++
+		n = c.n
+		return nil
+	})
+	return n, err
+}
+
+func TestActorsSerializeCalls(t *testing.T) {
+	ctx := context.Background()
+	a := testActors(t, lease.NewTable(), "w1", ActorOptions{})
+	recorded()
+
+	// The counter isn't synchronized, so the race detector catches calls to
+	// the same id that aren't serialized.
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for _, id := range []string{"a", "b"} {
+			id := id
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := increment(ctx, a, id); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	for _, id := range []string{"a", "b"} {
+		if got, err := increment(ctx, a, id); err != nil || got != n+1 {
+			t.Errorf("increment(%q): got (%d, %v), want (%d, nil)", id, got, err, n+1)
+		}
+	}
+
+	// Every id was activated exactly once.
+	activations := map[string]int{}
+	for _, event := range recorded() {
+		activations[event]++
+	}
+	if diff := cmp.Diff(map[string]int{"activate a": 1, "activate b": 1}, activations); diff != "" {
+		t.Errorf("events (-want +got):\n%s", diff)
+	}
+}
+
+func TestActorsAtMostOneActivation(t *testing.T) {
+	ctx := context.Background()
+	table := lease.NewTable()
+	opts := ActorOptions{IdleTimeout: 200 * time.Millisecond, LeaseDuration: 300 * time.Millisecond}
+	a1 := testActors(t, table, "w1", opts)
+	a2 := testActors(t, table, "w2", opts)
+	recorded()
+
+	if _, err := increment(ctx, a1, "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	// x is activated by w1, so w2 can't activate it while it's in use.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				increment(ctx, a1, "x")
+			}
+		}
+	}()
+	shortCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if _, err := increment(shortCtx, a2, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("increment on w2 while active on w1: got %v, want DeadlineExceeded", err)
+	}
+	close(stop)
+	<-done
+
+	// Once x is idle on w1, it is deactivated and can be activated by w2,
+	// with a fresh state.
+	if got, err := increment(ctx, a2, "x"); err != nil || got != 1 {
+		t.Fatalf("increment on w2 once idle on w1: got (%d, %v), want (1, nil)", got, err)
+	}
+	want := []string{"activate x", "deactivate x", "activate x"}
+	if diff := cmp.Diff(want, recorded()); diff != "" {
+		t.Fatalf("events (-want +got):\n%s", diff)
+	}
+}
+
+func TestActorsDrain(t *testing.T) {
+	ctx := context.Background()
+	table := lease.NewTable()
+	a1 := testActors(t, table, "w1", ActorOptions{})
+	a2 := testActors(t, table, "w2", ActorOptions{})
+	recorded()
+
+	if _, err := increment(ctx, a1, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a1.drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := increment(ctx, a1, "x"); !errors.Is(err, ErrRetriable) {
+		t.Fatalf("increment while draining: got %v, want ErrRetriable", err)
+	}
+
+	// The lease was released on drain, so w2 can activate x right away.
+	shortCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := increment(shortCtx, a2, "x"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"activate x", "deactivate x", "activate x"}
+	if diff := cmp.Diff(want, recorded()); diff != "" {
+		t.Fatalf("events (-want +got):\n%s", diff)
+	}
+}
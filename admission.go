@@ -0,0 +1,297 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+type admissionLabels struct {
+	Component string // full component name
+}
+
+type admissionShedLabels struct {
+	Component string // full component name
+	Reason    string `values:"rate,queue_full,queue_delay"` // why the calls were shed
+}
+
+var (
+	admissionShedCalls = metrics.NewCounterMap[admissionShedLabels](
+		"serviceweaver_component_shed_call_count",
+		"Count of Service Weaver component method calls shed by the component's admission control",
+	)
+	admissionActiveCalls = metrics.NewGaugeMap[admissionLabels](
+		"serviceweaver_component_active_calls",
+		"Number of Service Weaver component method calls admitted by the component's admission control and running",
+	)
+	admissionQueuedCalls = metrics.NewGaugeMap[admissionLabels](
+		"serviceweaver_component_queued_calls",
+		"Number of Service Weaver component method calls waiting to be admitted by the component's admission control",
+	)
+	admissionQueueDelay = metrics.NewHistogramMap[admissionLabels](
+		"serviceweaver_component_queue_delay_micros",
+		"Duration, in microseconds, Service Weaver component method calls wait to be admitted by the component's admission control",
+		metrics.NonNegativeBuckets,
+	)
+)
+
+// An admissionController limits the remote calls to a component that a
+// weavelet runs, so that an overloaded component sheds the calls it can't
+// serve in time instead of queueing them until they all time out. Admission
+// control is configured per component:
+//
+//	[serviceweaver.admission."github.com/my/project/currency/T"]
+//	max_concurrent_calls = 100   # run at most 100 calls at a time
+//	max_queued_calls = 1000      # queue at most 1000 more
+//	rate = 500                   # admit at most 500 calls per second
+//	target_queue_delay = "20ms"  # shed queued calls early when overloaded
+//
+// See runtime.AdmissionConfig for details. Shed calls fail with ErrShedLoad.
+// Queued calls are admitted highest priority first (see Priority), and a call
+// that finds the queue full takes the place of the newest queued call of a
+// lower priority, if any, which is shed instead. Only remote calls go through
+// admission control; calls to colocated components are regular function
+// calls.
+type admissionController struct {
+	component  string
+	config     runtime.AdmissionConfig
+	shedRate   *metrics.Counter
+	shedFull   *metrics.Counter
+	shedDelay  *metrics.Counter
+	active     *metrics.Gauge
+	queued     *metrics.Gauge
+	queueDelay *metrics.Histogram
+
+	mu       sync.Mutex
+	tokens   float64          // tokens in the rate limiting bucket
+	refilled time.Time        // when tokens was last refilled
+	running  int              // number of admitted calls
+	queue    []*admissionTurn // calls waiting to be admitted, oldest first
+	busy     time.Time        // since when the queue has been non-empty
+}
+
+// admissionTurn is a call waiting to be admitted.
+type admissionTurn struct {
+	priority call.Priority // priority of the call
+	admitted chan struct{} // closed when the call is admitted
+	shed     chan struct{} // closed when the call is shed for a higher priority call
+}
+
+// admissionController returns the admission controller of the calls to the
+// provided component, or nil if calls to the component aren't limited.
+func (w *weavelet) admissionController(component string) *admissionController {
+	config, ok := w.admissionConfigs[component]
+	if !ok {
+		return nil
+	}
+	return newAdmissionController(component, config)
+}
+
+// newAdmissionController returns a new admission controller that enforces
+// the provided policy on the calls to the provided component.
+func newAdmissionController(component string, config runtime.AdmissionConfig) *admissionController {
+	labels := admissionLabels{Component: component}
+	return &admissionController{
+		component:  component,
+		config:     config,
+		shedRate:   admissionShedCalls.Get(admissionShedLabels{Component: component, Reason: "rate"}),
+		shedFull:   admissionShedCalls.Get(admissionShedLabels{Component: component, Reason: "queue_full"}),
+		shedDelay:  admissionShedCalls.Get(admissionShedLabels{Component: component, Reason: "queue_delay"}),
+		active:     admissionActiveCalls.Get(labels),
+		queued:     admissionQueuedCalls.Get(labels),
+		queueDelay: admissionQueueDelay.Get(labels),
+		tokens:     float64(config.Burst),
+	}
+}
+
+// acquire waits until a call of priority p can run. It returns a ShedLoad
+// error if the call is shed, or ctx.Err() if ctx is done before the call is
+// admitted. If acquire returns nil, the caller must call release once the
+// call finishes. A nil admissionController admits every call.
+func (a *admissionController) acquire(ctx context.Context, p call.Priority) error {
+	if a == nil {
+		return nil
+	}
+	now := time.Now()
+	a.mu.Lock()
+	if !a.allowRate(now) {
+		a.mu.Unlock()
+		a.shedRate.Add(1)
+		return a.shed("rate limit exceeded")
+	}
+	if a.config.MaxConcurrentCalls <= 0 || (a.running < a.config.MaxConcurrentCalls && len(a.queue) == 0) {
+		a.running++
+		a.active.Set(float64(a.running))
+		a.mu.Unlock()
+		return nil
+	}
+	if len(a.queue) >= a.config.MaxQueuedCalls {
+		// Make room by shedding a queued call of lower priority, if any.
+		i := a.displaced(p)
+		if i < 0 {
+			a.mu.Unlock()
+			a.shedFull.Add(1)
+			return a.shed("queue full")
+		}
+		close(a.queue[i].shed)
+		a.queue = append(a.queue[:i:i], a.queue[i+1:]...)
+	}
+	wait := a.maxQueueDelay(now)
+	if len(a.queue) == 0 {
+		a.busy = now
+	}
+	t := &admissionTurn{priority: p, admitted: make(chan struct{}), shed: make(chan struct{})}
+	a.queue = append(a.queue, t)
+	a.queued.Set(float64(len(a.queue)))
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-t.admitted:
+		a.queueDelay.Put(float64(time.Since(now).Microseconds()))
+		return nil
+	case <-t.shed:
+		a.shedFull.Add(1)
+		return a.shed("queue full of calls of higher priority")
+	case <-ctx.Done():
+		a.abandon(t)
+		return ctx.Err()
+	case <-timeout:
+		a.abandon(t)
+		a.shedDelay.Add(1)
+		return a.shed(fmt.Sprintf("queued for more than %v", wait))
+	}
+}
+
+// release marks a call admitted by acquire as finished.
+func (a *admissionController) release() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.running--
+	a.admitNext()
+}
+
+// shed returns the error returned by a shed call.
+func (a *admissionController) shed(reason string) error {
+	return fmt.Errorf("%w: component %s: %s", call.ShedLoad, a.component, reason)
+}
+
+// allowRate takes a token from the rate limiting bucket, if any, and returns
+// whether there was one.
+//
+// REQUIRES: a.mu is held.
+func (a *admissionController) allowRate(now time.Time) bool {
+	if a.config.Rate <= 0 {
+		return true
+	}
+	if !a.refilled.IsZero() {
+		elapsed := now.Sub(a.refilled).Seconds()
+		a.tokens = math.Min(float64(a.config.Burst), a.tokens+elapsed*a.config.Rate)
+	}
+	a.refilled = now
+	if a.tokens < 1 {
+		return false
+	}
+	a.tokens--
+	return true
+}
+
+// maxQueueDelay returns how long a call queued at the provided time can
+// wait to be admitted, or zero if there is no limit.
+//
+// REQUIRES: a.mu is held.
+func (a *admissionController) maxQueueDelay(now time.Time) time.Duration {
+	if a.config.TargetQueueDelay <= 0 {
+		return 0
+	}
+	if len(a.queue) > 0 && now.Sub(a.busy) >= a.config.QueueDelayInterval {
+		// The queue hasn't drained for a whole interval: we are overloaded.
+		return a.config.TargetQueueDelay
+	}
+	return a.config.QueueDelayInterval
+}
+
+// displaced returns the index in the queue of the call shed to make room for
+// a call of priority p: the newest of the queued calls of the lowest priority,
+// if lower than p, or -1 if there is none.
+//
+// REQUIRES: a.mu is held.
+func (a *admissionController) displaced(p call.Priority) int {
+	victim := -1
+	for i, t := range a.queue {
+		if t.priority < p && (victim < 0 || t.priority <= a.queue[victim].priority) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// abandon removes t from the queue, after its call stopped waiting. If the
+// call was admitted concurrently, its turn is passed on to the next call.
+func (a *admissionController) abandon(t *admissionTurn) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-t.admitted:
+		a.running--
+		a.admitNext()
+		return
+	default:
+	}
+	for i, x := range a.queue {
+		if x == t {
+			a.queue = append(a.queue[:i:i], a.queue[i+1:]...)
+			break
+		}
+	}
+	a.queued.Set(float64(len(a.queue)))
+}
+
+// admitNext admits queued calls, highest priority first, and oldest first
+// within a priority, while there is capacity to run them.
+//
+// REQUIRES: a.mu is held.
+func (a *admissionController) admitNext() {
+	for len(a.queue) > 0 && a.running < a.config.MaxConcurrentCalls {
+		next := 0
+		for i, t := range a.queue {
+			if t.priority > a.queue[next].priority {
+				next = i
+			}
+		}
+		t := a.queue[next]
+		a.queue = append(a.queue[:next:next], a.queue[next+1:]...)
+		a.running++
+		close(t.admitted)
+	}
+	a.active.Set(float64(a.running))
+	a.queued.Set(float64(len(a.queue)))
+}
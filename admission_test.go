@@ -0,0 +1,209 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// testAdmission returns an admission controller with the provided config.
+func testAdmission(config runtime.AdmissionConfig) *admissionController {
+	w := &weavelet{admissionConfigs: map[string]runtime.AdmissionConfig{"a/T": config}}
+	return w.admissionController("a/T")
+}
+
+func TestAdmissionDisabled(t *testing.T) {
+	w := &weavelet{}
+	a := w.admissionController("a/T")
+	if a != nil {
+		t.Fatalf("admissionController: got %v, want nil", a)
+	}
+	if err := a.acquire(context.Background(), call.NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+	a.release()
+}
+
+func TestAdmissionRate(t *testing.T) {
+	a := testAdmission(runtime.AdmissionConfig{Rate: 10, Burst: 2})
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := a.allowRate(now); got != want {
+			t.Fatalf("call %d: got %v, want %v", i, got, want)
+		}
+	}
+	// 100ms later, the bucket has one more token.
+	now = now.Add(100 * time.Millisecond)
+	for i, want := range []bool{true, false} {
+		if got := a.allowRate(now); got != want {
+			t.Fatalf("call %d after 100ms: got %v, want %v", i, got, want)
+		}
+	}
+	// The bucket never holds more than Burst tokens.
+	now = now.Add(time.Hour)
+	for i, want := range []bool{true, true, false} {
+		if got := a.allowRate(now); got != want {
+			t.Fatalf("call %d after an hour: got %v, want %v", i, got, want)
+		}
+	}
+
+	// Calls over the rate are shed.
+	a = testAdmission(runtime.AdmissionConfig{Rate: 0.001, Burst: 1})
+	ctx := context.Background()
+	if err := a.acquire(ctx, call.NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+	a.release()
+	if err := a.acquire(ctx, call.NormalPriority); !errors.Is(err, call.ShedLoad) {
+		t.Fatalf("acquire over the rate: got %v, want ShedLoad", err)
+	}
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	a := testAdmission(runtime.AdmissionConfig{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+	ctx := context.Background()
+	if err := a.acquire(ctx, call.NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second call waits in the queue, and the third one is shed.
+	admitted := make(chan error)
+	go func() { admitted <- a.acquire(ctx, call.NormalPriority) }()
+	for {
+		a.mu.Lock()
+		n := len(a.queue)
+		a.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := a.acquire(ctx, call.NormalPriority); !errors.Is(err, call.ShedLoad) {
+		t.Fatalf("acquire with a full queue: got %v, want ShedLoad", err)
+	}
+
+	// The queued call runs once the first one finishes.
+	a.release()
+	if err := <-admitted; err != nil {
+		t.Fatal(err)
+	}
+
+	// A queued call whose context is cancelled leaves the queue.
+	cctx, cancel := context.WithCancel(ctx)
+	go func() { admitted <- a.acquire(cctx, call.NormalPriority) }()
+	cancel()
+	if err := <-admitted; !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire with a cancelled context: got %v, want context.Canceled", err)
+	}
+	a.release()
+	if err := a.acquire(ctx, call.NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+	a.release()
+}
+
+// waitQueued waits until the provided admission controller has n queued calls.
+func waitQueued(a *admissionController, n int) {
+	for {
+		a.mu.Lock()
+		got := len(a.queue)
+		a.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionPriority(t *testing.T) {
+	a := testAdmission(runtime.AdmissionConfig{MaxConcurrentCalls: 1, MaxQueuedCalls: 2})
+	ctx := context.Background()
+	if err := a.acquire(ctx, call.NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the queue with two low priority calls.
+	first := make(chan error, 1)
+	go func() { first <- a.acquire(ctx, call.LowPriority) }()
+	waitQueued(a, 1)
+	second := make(chan error, 1)
+	go func() { second <- a.acquire(ctx, call.LowPriority) }()
+	waitQueued(a, 2)
+
+	// A high priority call displaces the newest low priority call, and a low
+	// priority call is shed.
+	high := make(chan error, 1)
+	go func() { high <- a.acquire(ctx, call.HighPriority) }()
+	if err := <-second; !errors.Is(err, call.ShedLoad) {
+		t.Fatalf("displaced acquire: got %v, want ShedLoad", err)
+	}
+	waitQueued(a, 2)
+	if err := a.acquire(ctx, call.LowPriority); !errors.Is(err, call.ShedLoad) {
+		t.Fatalf("low priority acquire with a full queue: got %v, want ShedLoad", err)
+	}
+
+	// The high priority call runs before the older low priority call.
+	a.release()
+	if err := <-high; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-first:
+		t.Fatalf("low priority call admitted before the high priority call finished: %v", err)
+	default:
+	}
+	a.release()
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	a.release()
+}
+
+func TestAdmissionQueueDelay(t *testing.T) {
+	a := testAdmission(runtime.AdmissionConfig{
+		MaxConcurrentCalls: 1,
+		MaxQueuedCalls:     10,
+		TargetQueueDelay:   time.Millisecond,
+		QueueDelayInterval: time.Hour,
+	})
+	now := time.Now()
+	if got, want := a.maxQueueDelay(now), time.Hour; got != want {
+		t.Fatalf("maxQueueDelay with an empty queue: got %v, want %v", got, want)
+	}
+	a.queue = []*admissionTurn{{}}
+	a.busy = now
+	if got, want := a.maxQueueDelay(now.Add(time.Minute)), time.Hour; got != want {
+		t.Fatalf("maxQueueDelay with a busy queue: got %v, want %v", got, want)
+	}
+	if got, want := a.maxQueueDelay(now.Add(time.Hour)), time.Millisecond; got != want {
+		t.Fatalf("maxQueueDelay with an overloaded queue: got %v, want %v", got, want)
+	}
+
+	// A call queued while overloaded is shed after the target delay.
+	a.busy = now.Add(-time.Hour)
+	a.running = 1
+	if err := a.acquire(context.Background(), call.NormalPriority); !errors.Is(err, call.ShedLoad) {
+		t.Fatalf("acquire while overloaded: got %v, want ShedLoad", err)
+	}
+	if got := len(a.queue); got != 1 {
+		t.Fatalf("queue length after shedding: got %d, want 1", got)
+	}
+}
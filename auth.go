@@ -0,0 +1,153 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/auth"
+	"github.com/ServiceWeaver/weaver/metrics"
+)
+
+type authLabels struct {
+	Listener string // listener name
+	Reason   string // "unauthenticated" or "forbidden"
+}
+
+var authRejections = metrics.NewCounterMap[authLabels](
+	"serviceweaver_http_auth_rejected_count",
+	"Count of HTTP requests rejected by the middleware returned by weaver.Authenticate",
+)
+
+// A Principal is the authenticated sender of an HTTP request. See
+// Authenticate.
+type Principal struct {
+	// The name of the principal: the "sub" claim of its token, or the name
+	// associated with its API key in the API keys file.
+	Name string
+
+	// How the principal was authenticated: "jwt" or "api_key", or "mtls" for
+	// the calls of other deployments to exported components (see
+	// runtime.FederationConfig).
+	Scheme string
+
+	// The claims of the principal's token, or nil if the principal was
+	// authenticated with an API key.
+	Claims map[string]any
+}
+
+// Authenticate returns a middleware that authenticates and authorizes the
+// HTTP requests received by the listener with the provided name, as specified
+// in the listener's section of the config. For example:
+//
+//	[serviceweaver.auth.frontend]
+//	issuer = "https://accounts.google.com"
+//	audience = "my-client-id"
+//	api_keys_file = "/etc/boutique/api_keys"
+//	routes = [
+//	  {path = "/static/", public = true},
+//	  {path = "/admin/", claims = {role = "admin"}},
+//	]
+//
+// Requests are authenticated with a JWT, e.g., an OpenID Connect ID token,
+// sent as a bearer token, or with an API key. See runtime.AuthConfig for the
+// details. Requests that cannot be authenticated are answered with a 401
+// Unauthorized, and requests that violate the policy of their route with a
+// 403 Forbidden. Health checks, on the reserved "/healthz" URL prefix, are
+// never authenticated.
+//
+// The middleware stores the principal that sent a request in the request's
+// context, where handlers read it with PrincipalFromContext. The principal is
+// propagated to the component method calls made with the context, including
+// remote calls, so components can authorize the calls they serve:
+//
+//	func (s *server) Handle(w http.ResponseWriter, r *http.Request) {
+//	    // The principal is available to the Orders component too.
+//	    orders, err := s.orders.Get().List(r.Context())
+//	    ...
+//	}
+//
+//	func (o *orders) List(ctx context.Context) ([]Order, error) {
+//	    p, ok := weaver.PrincipalFromContext(ctx)
+//	    ...
+//	}
+//
+// Note that callees trust the principals propagated by their callers, which
+// are part of the same application.
+func Authenticate(requester Instance, listener string) (Middleware, error) {
+	config, ok := requester.rep().wlet.authConfigs[listener]
+	if !ok {
+		return nil, fmt.Errorf("Authenticate(%q): no auth config for listener", listener)
+	}
+	a, err := auth.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("Authenticate(%q): %w", listener, err)
+	}
+	bearer := config.Issuer != "" || config.JWKSURL != ""
+	logger := requester.Logger()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/healthz") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			p, err := a.Authenticate(r)
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				authRejections.Get(authLabels{Listener: listener, Reason: "unauthenticated"}).Add(1)
+				logger.Debug("Unauthenticated request", "listener", listener, "path", r.URL.Path, "err", err)
+				if bearer {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				authRejections.Get(authLabels{Listener: listener, Reason: "forbidden"}).Add(1)
+				logger.Debug("Forbidden request", "listener", listener, "path", r.URL.Path, "err", err)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if p != nil {
+				ctx := withPrincipal(r.Context(), Principal{Name: p.Name, Scheme: p.Scheme, Claims: p.Claims})
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// withPrincipal returns a copy of ctx that holds the provided principal.
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	md := &callMetadata{Principal: &p}
+	if old := metadataFromContext(ctx); old != nil {
+		md.Values = old.Values
+	}
+	return withCallMetadata(ctx, md)
+}
+
+// PrincipalFromContext returns the principal that sent the HTTP request, or
+// that made the component method call, being handled with the provided
+// context, if it was authenticated by the middleware returned by
+// Authenticate.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	if md := metadataFromContext(ctx); md != nil && md.Principal != nil {
+		return *md.Principal, true
+	}
+	return Principal{}, false
+}
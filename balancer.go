@@ -0,0 +1,169 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+)
+
+// A Replica is a replica of a component, as seen by a Balancer.
+type Replica struct {
+	// Address is the address of the replica, e.g., "tcp://10.0.1.7:9000".
+	Address string
+}
+
+// A Balancer picks the replica of a component that runs a remote method call
+// on the component. By default, replicas are picked by the policy set by the
+// load_balancing config option. A Balancer registered with UseBalancer
+// replaces the policy for a single component, e.g., to prefer the replicas
+// in the caller's zone.
+//
+// Calls to routed methods with a routing key are sent to the replica the key
+// is assigned to, and are never passed to a Balancer.
+//
+// The methods of a Balancer are not called concurrently, with the exception
+// of BalancerObserver.Finished.
+type Balancer interface {
+	// Update updates the set of replicas from which the Balancer picks.
+	// Before Update is called for the first time, the set is empty.
+	Update(replicas []Replica)
+
+	// Pick picks a replica for a call. The picked replica must be one of
+	// the replicas passed to the most recent call of Update.
+	Pick() (Replica, error)
+}
+
+// A BalancerObserver is a Balancer that is informed of the calls made on the
+// replicas it picks, which it can use to balance load, e.g., to pick the
+// least loaded of two random replicas.
+type BalancerObserver interface {
+	Balancer
+
+	// Started informs the balancer that a call was started on the replica
+	// returned by the most recent call to Pick.
+	Started(replica Replica)
+
+	// Finished informs the balancer that a call on the replica finished
+	// after the provided latency, with the provided error. Finished may be
+	// called concurrently with the other methods of the balancer.
+	Finished(replica Replica, latency time.Duration, err error)
+}
+
+var balancers struct {
+	mu        sync.Mutex
+	factories map[reflect.Type]func() Balancer // by component interface type
+}
+
+// UseBalancer registers a function that returns the Balancer that picks the
+// replicas of component T. Every process that calls T gets its own Balancer.
+// For example:
+//
+//	weaver.UseBalancer[CurrencyService](func() weaver.Balancer {
+//	    return &localityBalancer{zone: myZone}
+//	})
+//
+// Like ExportLogs, UseBalancer must be called in every process, before Init,
+// typically in main or an init function.
+func UseBalancer[T any](newBalancer func() Balancer) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("UseBalancer: type %v is not an interface", t))
+	}
+	balancers.mu.Lock()
+	defer balancers.mu.Unlock()
+	if balancers.factories == nil {
+		balancers.factories = map[reflect.Type]func() Balancer{}
+	}
+	balancers.factories[t] = newBalancer
+}
+
+// registeredBalancer returns a new Balancer for the component with the
+// provided interface type, or nil if none was registered with UseBalancer.
+func registeredBalancer(t reflect.Type) Balancer {
+	balancers.mu.Lock()
+	newBalancer, ok := balancers.factories[t]
+	balancers.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return newBalancer()
+}
+
+// customBalancer is a call.Balancer that picks endpoints with a Balancer.
+type customBalancer struct {
+	balancer Balancer
+
+	mu        sync.Mutex
+	endpoints map[string]call.Endpoint // the most recent endpoints, by address
+}
+
+var (
+	_ call.Balancer     = &customBalancer{}
+	_ call.CallObserver = &customBalancer{}
+)
+
+// newCustomBalancer returns a call.Balancer that picks endpoints with the
+// provided Balancer.
+func newCustomBalancer(balancer Balancer) *customBalancer {
+	return &customBalancer{balancer: balancer, endpoints: map[string]call.Endpoint{}}
+}
+
+// Update implements the call.Balancer interface.
+func (cb *customBalancer) Update(endpoints []call.Endpoint) {
+	byAddress := make(map[string]call.Endpoint, len(endpoints))
+	replicas := make([]Replica, len(endpoints))
+	for i, e := range endpoints {
+		byAddress[e.Address()] = e
+		replicas[i] = Replica{Address: e.Address()}
+	}
+	cb.mu.Lock()
+	cb.endpoints = byAddress
+	cb.mu.Unlock()
+	cb.balancer.Update(replicas)
+}
+
+// Pick implements the call.Balancer interface.
+func (cb *customBalancer) Pick(call.CallOptions) (call.Endpoint, error) {
+	replica, err := cb.balancer.Pick()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", call.Unreachable, err)
+	}
+	cb.mu.Lock()
+	endpoint, ok := cb.endpoints[replica.Address]
+	cb.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: balancer picked unknown replica %q", call.Unreachable, replica.Address)
+	}
+	return endpoint, nil
+}
+
+// Started implements the call.CallObserver interface.
+func (cb *customBalancer) Started(endpoint call.Endpoint) {
+	if observer, ok := cb.balancer.(BalancerObserver); ok {
+		observer.Started(Replica{Address: endpoint.Address()})
+	}
+}
+
+// Finished implements the call.CallObserver interface.
+func (cb *customBalancer) Finished(endpoint call.Endpoint, latency time.Duration, err error) {
+	if observer, ok := cb.balancer.(BalancerObserver); ok {
+		observer.Finished(Replica{Address: endpoint.Address()}, latency, err)
+	}
+}
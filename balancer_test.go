@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/google/go-cmp/cmp"
+)
+
+// lastBalancer is a Balancer that picks the last replica, and records the
+// calls it observes.
+type lastBalancer struct {
+	replicas []Replica
+	started  []string
+	finished []string
+}
+
+var _ BalancerObserver = &lastBalancer{}
+
+func (b *lastBalancer) Update(replicas []Replica) { b.replicas = replicas }
+
+func (b *lastBalancer) Pick() (Replica, error) {
+	if len(b.replicas) == 0 {
+		return Replica{}, errors.New("no replicas")
+	}
+	return b.replicas[len(b.replicas)-1], nil
+}
+
+func (b *lastBalancer) Started(r Replica) { b.started = append(b.started, r.Address) }
+
+func (b *lastBalancer) Finished(r Replica, _ time.Duration, _ error) {
+	b.finished = append(b.finished, r.Address)
+}
+
+func TestCustomBalancer(t *testing.T) {
+	b := &lastBalancer{}
+	cb := newCustomBalancer(b)
+	if _, err := cb.Pick(call.CallOptions{}); !errors.Is(err, call.Unreachable) {
+		t.Fatalf("Pick with no replicas: got %v, want Unreachable", err)
+	}
+
+	cb.Update([]call.Endpoint{nilEndpoint{"tcp://a"}, nilEndpoint{"tcp://b"}})
+	if diff := cmp.Diff([]Replica{{"tcp://a"}, {"tcp://b"}}, b.replicas); diff != "" {
+		t.Fatalf("replicas (-want +got):\n%s", diff)
+	}
+	got, err := cb.Pick(call.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (nilEndpoint{"tcp://b"}); got != want {
+		t.Fatalf("Pick: got %v, want %v", got, want)
+	}
+	cb.Started(got)
+	cb.Finished(got, time.Millisecond, nil)
+	if diff := cmp.Diff([]string{"tcp://b"}, b.started); diff != "" {
+		t.Fatalf("started (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"tcp://b"}, b.finished); diff != "" {
+		t.Fatalf("finished (-want +got):\n%s", diff)
+	}
+}
+
+func TestCustomBalancerUnknownReplica(t *testing.T) {
+	b := &lastBalancer{}
+	cb := newCustomBalancer(b)
+	cb.Update([]call.Endpoint{nilEndpoint{"tcp://a"}})
+	b.replicas = []Replica{{"tcp://c"}}
+	if _, err := cb.Pick(call.CallOptions{}); !errors.Is(err, call.Unreachable) {
+		t.Fatalf("Pick of unknown replica: got %v, want Unreachable", err)
+	}
+}
+
+func TestUseBalancer(t *testing.T) {
+	type balanced interface{ Get() }
+	UseBalancer[balanced](func() Balancer { return &lastBalancer{} })
+	t.Cleanup(func() {
+		balancers.mu.Lock()
+		defer balancers.mu.Unlock()
+		delete(balancers.factories, reflect.TypeOf((*balanced)(nil)).Elem())
+	})
+
+	b1 := registeredBalancer(reflect.TypeOf((*balanced)(nil)).Elem())
+	b2 := registeredBalancer(reflect.TypeOf((*balanced)(nil)).Elem())
+	if b1 == nil || b2 == nil {
+		t.Fatal("registeredBalancer: got nil, want a balancer")
+	}
+	if b1 == b2 {
+		t.Fatal("registeredBalancer: got a shared balancer, want one per call")
+	}
+	type unbalanced interface{ Get() }
+	if b := registeredBalancer(reflect.TypeOf((*unbalanced)(nil)).Elem()); b != nil {
+		t.Fatalf("registeredBalancer: got %v, want nil", b)
+	}
+}
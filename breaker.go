@@ -0,0 +1,254 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// breakerBuckets is the number of buckets in which a circuit breaker counts
+// the calls made within its window.
+const breakerBuckets = 10
+
+type breakerLabels struct {
+	Component string // full callee component name
+}
+
+var (
+	breakerTrips = metrics.NewCounterMap[breakerLabels](
+		"serviceweaver_circuit_breaker_trip_count",
+		"Count of times a circuit breaker on calls to a Service Weaver component tripped",
+	)
+	breakerRejections = metrics.NewCounterMap[breakerLabels](
+		"serviceweaver_circuit_breaker_rejected_count",
+		"Count of Service Weaver component method calls failed fast by an open circuit breaker",
+	)
+)
+
+// circuitOpen is an error caused by a call being failed fast by an open
+// circuit breaker. If err is a circuitOpen, then errors.Is(err,
+// ErrCircuitOpen) is true.
+type circuitOpen struct {
+	err error
+}
+
+// Error implements the error interface.
+func (c circuitOpen) Error() string {
+	return c.err.Error()
+}
+
+// Is makes circuitOpen compatible with errors.Is.
+func (c circuitOpen) Is(err error) bool {
+	return err == ErrCircuitOpen
+}
+
+// Unwrap makes circuitOpen compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (c circuitOpen) Unwrap() error {
+	return c.err
+}
+
+// breakerState is the state of a circuit breaker.
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // calls go through
+	breakerOpen                         // calls fail fast
+	breakerHalfOpen                     // a single trial call goes through
+)
+
+// A circuitBreaker fails calls to a component fast once too many of them
+// fail, so that callers stop adding load to a struggling component. Circuit
+// breakers are configured per component:
+//
+//	[serviceweaver.breakers."github.com/my/project/checkout/T"]
+//	consecutive_failures = 5  # trip after 5 failed calls in a row
+//	error_rate = 0.5          # or after half of the calls in the window fail
+//	cooldown = "10s"          # fail calls fast for 10 seconds
+//
+// See runtime.BreakerConfig for details. Only remote calls go through circuit
+// breakers; calls to colocated components are regular function calls.
+type circuitBreaker struct {
+	component string
+	config    runtime.BreakerConfig
+	clock     Clock
+	trips     *metrics.Counter
+	rejected  *metrics.Counter
+
+	mu        sync.Mutex
+	state     breakerState
+	openUntil time.Time // when an open breaker becomes half-open
+	trial     bool      // is the trial call of a half-open breaker running?
+	failures  int       // consecutive failed calls
+	buckets   [breakerBuckets]breakerBucket
+}
+
+// breakerBucket counts the calls made within a slice of a breaker's window.
+type breakerBucket struct {
+	start    time.Time // start of the slice
+	calls    int
+	failures int
+}
+
+// circuitBreaker returns the circuit breaker of calls to the provided
+// component, or nil if calls to the component don't go through a breaker.
+func (w *weavelet) circuitBreaker(component string) *circuitBreaker {
+	config, ok := w.breakerConfigs[component]
+	if !ok {
+		return nil
+	}
+	labels := breakerLabels{Component: component}
+	return &circuitBreaker{
+		component: component,
+		config:    config,
+		clock:     w.clock,
+		trips:     breakerTrips.Get(labels),
+		rejected:  breakerRejections.Get(labels),
+	}
+}
+
+// run runs f, which makes a remote call, unless the breaker is open.
+func (b *circuitBreaker) run(ctx context.Context, f func(context.Context) ([]byte, error)) ([]byte, error) {
+	trial, ok := b.allow(b.clock.Now())
+	if !ok {
+		b.rejected.Add(1)
+		trace.SpanFromContext(ctx).AddEvent("circuit breaker open",
+			trace.WithAttributes(attribute.String("component", b.component)))
+		return nil, circuitOpen{fmt.Errorf("%w: calls to %s are failing", ErrCircuitOpen, b.component)}
+	}
+	results, err := f(ctx)
+	// A call cancelled by the caller, or that the caller isn't allowed to
+	// make, says nothing about the callee.
+	failed := err != nil && !errors.Is(ctx.Err(), context.Canceled) && !errors.Is(err, call.PermissionDenied)
+	b.record(b.clock.Now(), trial, failed)
+	return results, err
+}
+
+// allow returns whether a call can be made at the provided time and, if so,
+// whether it is the trial call of a half-open breaker.
+func (b *circuitBreaker) allow(now time.Time) (trial bool, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Before(b.openUntil) {
+			return false, false
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		return true, true
+	case breakerHalfOpen:
+		if b.trial {
+			return false, false
+		}
+		b.trial = true
+		return true, true
+	default:
+		return false, true
+	}
+}
+
+// record records the outcome of a call that finished at the provided time.
+func (b *circuitBreaker) record(now time.Time, trial, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		// The call was made before the breaker tripped.
+		return
+	case breakerHalfOpen:
+		if !trial {
+			return
+		}
+		b.trial = false
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		b.buckets = [breakerBuckets]breakerBucket{}
+		return
+	}
+
+	bucket := b.bucket(now)
+	bucket.calls++
+	if !failed {
+		b.failures = 0
+		return
+	}
+	bucket.failures++
+	b.failures++
+	if b.config.ConsecutiveFailures > 0 && b.failures >= b.config.ConsecutiveFailures {
+		b.trip(now)
+		return
+	}
+	if b.config.ErrorRate > 0 {
+		calls, failures := b.window(now)
+		if calls >= b.config.MinCalls && float64(failures) > b.config.ErrorRate*float64(calls) {
+			b.trip(now)
+		}
+	}
+}
+
+// trip opens the breaker.
+//
+// REQUIRES: b.mu is held.
+func (b *circuitBreaker) trip(now time.Time) {
+	b.state = breakerOpen
+	b.openUntil = now.Add(b.config.Cooldown)
+	b.trips.Add(1)
+}
+
+// bucket returns the bucket of the provided time.
+//
+// REQUIRES: b.mu is held.
+func (b *circuitBreaker) bucket(now time.Time) *breakerBucket {
+	width := b.config.Window / breakerBuckets
+	if width <= 0 {
+		width = 1
+	}
+	start := now.Truncate(width)
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// window returns the number of calls, and failed calls, made within the
+// window ending at the provided time.
+//
+// REQUIRES: b.mu is held.
+func (b *circuitBreaker) window(now time.Time) (calls, failures int) {
+	cutoff := now.Add(-b.config.Window)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(cutoff) {
+			calls += bucket.calls
+			failures += bucket.failures
+		}
+	}
+	return calls, failures
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// testBreaker returns a circuit breaker with the provided config.
+func testBreaker(config runtime.BreakerConfig) *circuitBreaker {
+	w := &weavelet{
+		breakerConfigs: map[string]runtime.BreakerConfig{"a/T": config},
+		clock:          systemClock{},
+	}
+	return w.circuitBreaker("a/T")
+}
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	b := testBreaker(runtime.BreakerConfig{ConsecutiveFailures: 3, Cooldown: time.Second})
+	now := time.Now()
+	for i, failed := range []bool{true, true, false, true, true, true} {
+		if _, ok := b.allow(now); !ok {
+			t.Fatalf("call %d: breaker open, want closed", i)
+		}
+		b.record(now, false, failed)
+	}
+	if _, ok := b.allow(now); ok {
+		t.Fatal("breaker closed after 3 consecutive failures, want open")
+	}
+
+	// Once the cooldown is over, a single trial call goes through.
+	now = now.Add(time.Second)
+	trial, ok := b.allow(now)
+	if !ok || !trial {
+		t.Fatalf("allow after cooldown: got (%v, %v), want (true, true)", trial, ok)
+	}
+	if _, ok := b.allow(now); ok {
+		t.Fatal("second call allowed while half-open")
+	}
+
+	// A failed trial trips the breaker again.
+	b.record(now, true, true)
+	if _, ok := b.allow(now.Add(time.Second / 2)); ok {
+		t.Fatal("breaker closed after failed trial, want open")
+	}
+
+	// A successful trial closes it.
+	now = now.Add(time.Second)
+	if trial, ok := b.allow(now); !ok || !trial {
+		t.Fatalf("allow after cooldown: got (%v, %v), want (true, true)", trial, ok)
+	}
+	b.record(now, true, false)
+	for i := 0; i < 2; i++ {
+		if _, ok := b.allow(now); !ok {
+			t.Fatal("breaker open after successful trial, want closed")
+		}
+		b.record(now, false, false)
+	}
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	b := testBreaker(runtime.BreakerConfig{
+		ErrorRate: 0.5,
+		Window:    10 * time.Second,
+		MinCalls:  10,
+		Cooldown:  time.Second,
+	})
+	now := time.Now()
+
+	// Half of the calls fail, which doesn't exceed the error rate.
+	for i := 0; i < 20; i++ {
+		b.record(now, false, i%2 == 1)
+	}
+	if _, ok := b.allow(now); !ok {
+		t.Fatal("breaker open at the error rate, want closed")
+	}
+
+	// Once the window has passed, old calls are forgotten: a few failures
+	// don't trip the breaker before MinCalls calls are made.
+	now = now.Add(time.Minute)
+	for i := 0; i < 5; i++ {
+		b.record(now, false, i != 0)
+	}
+	if _, ok := b.allow(now); !ok {
+		t.Fatal("breaker open before MinCalls calls, want closed")
+	}
+	for i := 0; i < 5; i++ {
+		b.record(now, false, true)
+	}
+	if _, ok := b.allow(now); ok {
+		t.Fatal("breaker closed above the error rate, want open")
+	}
+}
+
+func TestStubCircuitBreaker(t *testing.T) {
+	client := &flakyClient{failures: 100, err: errors.New("error")}
+	s := stub{
+		client:  client,
+		methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+		breaker: testBreaker(runtime.BreakerConfig{ConsecutiveFailures: 2, Cooldown: time.Hour}),
+	}
+	for i := 0; i < 5; i++ {
+		s.Run(context.Background(), 0, nil, 0) //nolint:errcheck // failures expected
+	}
+	if client.calls != 2 {
+		t.Fatalf("got %d remote calls, want 2", client.calls)
+	}
+	if _, err := s.Run(context.Background(), 0, nil, 0); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Run: got %v, want ErrCircuitOpen", err)
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// defaultCacheMaxBytes is the default size limit of a weavelet's method
+// results cache.
+const defaultCacheMaxBytes = 64 << 20
+
+type cacheLabels struct {
+	Component string // full callee component name
+	Method    string // callee component method's name
+}
+
+var (
+	methodCacheHits = metrics.NewCounterMap[cacheLabels](
+		"serviceweaver_method_cache_hit_count",
+		"Count of Service Weaver component method calls served from the cache",
+	)
+	methodCacheMisses = metrics.NewCounterMap[cacheLabels](
+		"serviceweaver_method_cache_miss_count",
+		"Count of cacheable Service Weaver component method calls not served from the cache",
+	)
+)
+
+// invalidateKey is the context key used by Invalidate.
+type invalidateKey struct{}
+
+// Invalidate returns a copy of ctx that, when passed to a call of a component
+// method with caching enabled, bypasses the cached result for the call's
+// arguments, if any. The result of the call replaces the cached result. For
+// example:
+//
+//	// Fetch the latest version of product id, and update the cache.
+//	product, err := catalog.GetProduct(weaver.Invalidate(ctx), id)
+//
+// Results are cached by the caller, so Invalidate only affects the cache of
+// the process that makes the call. Results are cached separately for every
+// calling component, principal, and call metadata (see WithMetadata), and
+// Invalidate only bypasses the result cached for those of ctx. Caching is
+// enabled per method in the config:
+//
+//	[serviceweaver.methods."github.com/my/project/catalog/T.GetProduct"]
+//	cache_ttl = "30s"          # cache successful results for 30 seconds
+//	negative_cache_ttl = "5s"  # cache application errors for 5 seconds
+//	cache_max_entries = 1000   # cache the results of at most 1000 calls
+//
+// Only remote calls are cached; calls to colocated components are regular
+// function calls.
+func Invalidate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, invalidateKey{}, true)
+}
+
+// invalidated returns whether ctx was returned by Invalidate.
+func invalidated(ctx context.Context) bool {
+	v, _ := ctx.Value(invalidateKey{}).(bool)
+	return v
+}
+
+// cachePolicy describes how the results of a method are cached.
+type cachePolicy struct {
+	ttl         time.Duration    // TTL of successful results, if positive
+	negativeTTL time.Duration    // TTL of application errors, if positive
+	maxEntries  int              // if positive, max number of cached results
+	hits        *metrics.Counter // number of cache hits
+	misses      *metrics.Counter // number of cache misses
+}
+
+// cacheKey is the key of a cached method result.
+type cacheKey struct {
+	method call.MethodKey // called method
+	args   string         // serialized method arguments
+	scope  string         // see cacheScope
+}
+
+// cacheScope returns the part of the cache key of a call made with the
+// provided context that isn't the call's arguments: the calling component (see
+// callerStub), and the principal and metadata sent along with the call. The
+// callee may answer the same arguments differently for every scope, e.g., by
+// denying the calls of a caller its access policy forbids, so results cached
+// in one scope are never served in another.
+func cacheScope(ctx context.Context) string {
+	caller := callerFromContext(ctx)
+	md := metadataFromContext(ctx)
+	if caller == "" && md == nil {
+		return ""
+	}
+	scope := &callMetadata{Caller: caller}
+	if md != nil {
+		scope.Principal = md.Principal
+		scope.Values = md.Values
+	}
+	return string(encodeMetadata(scope))
+}
+
+// cacheEntry is a cached method result.
+type cacheEntry struct {
+	key      cacheKey
+	results  []byte        // serialized method results
+	expires  time.Time     // when the entry expires
+	byMethod *list.Element // element of the method's lru, in methodCache.methods
+}
+
+// size returns the number of bytes accounted to e.
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key.args) + len(e.key.scope) + len(e.results))
+}
+
+// methodCache caches the serialized results of component method calls, keyed
+// by method, serialized arguments, and scope. The total size of the cached
+// keys and results is bounded, as is, optionally, the number of results
+// cached for every method; the least recently used entries are evicted first.
+// A methodCache is safe for concurrent use.
+type methodCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	bytes   int64                         // total size of the entries
+	lru     *list.List                    // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element    // elements of lru, by key
+	methods map[call.MethodKey]*list.List // of *cacheEntry, per method, most recently used first
+}
+
+// newMethodCache returns a new cache that holds at most maxBytes bytes.
+func newMethodCache(maxBytes int64) *methodCache {
+	return &methodCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[cacheKey]*list.Element{},
+		methods:  map[call.MethodKey]*list.List{},
+	}
+}
+
+// get returns the unexpired results cached for key, if any.
+func (c *methodCache) get(key cacheKey, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.methods[key.method].MoveToFront(entry.byMethod)
+	return entry.results, true
+}
+
+// put caches results for key until expires, evicting the least recently used
+// entries as needed to stay within the size limit. If maxEntries is positive,
+// put also evicts the least recently used results of key's method as needed
+// to cache at most maxEntries results for the method.
+func (c *methodCache) put(key cacheKey, results []byte, expires time.Time, maxEntries int) {
+	entry := &cacheEntry{key: key, results: results, expires: expires}
+	if entry.size() > c.maxBytes {
+		// Don't let a single entry flush the entire cache.
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size()
+	perMethod, ok := c.methods[key.method]
+	if !ok {
+		perMethod = list.New()
+		c.methods[key.method] = perMethod
+	}
+	entry.byMethod = perMethod.PushFront(entry)
+	if maxEntries > 0 {
+		for perMethod.Len() > maxEntries {
+			oldest := perMethod.Back().Value.(*cacheEntry)
+			c.removeElement(c.entries[oldest.key])
+		}
+	}
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// remove removes the results cached for key, if any.
+func (c *methodCache) remove(key cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement removes elem from the cache.
+//
+// REQUIRES: c.mu is held.
+func (c *methodCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size()
+	perMethod := c.methods[entry.key.method]
+	perMethod.Remove(entry.byMethod)
+	if perMethod.Len() == 0 {
+		delete(c.methods, entry.key.method)
+	}
+}
+
+// DefaultCacheMaxEntries is the default CacheOptions.MaxEntries.
+const DefaultCacheMaxEntries = 10000
+
+// DefaultCacheTTL is the default CacheOptions.TTL.
+const DefaultCacheTTL = time.Minute
+
+// cacheTopicPrefix prefixes the names of the topics on which the
+// invalidations of a cache are published.
+const cacheTopicPrefix = "serviceweaver/cache/"
+
+type namedCacheLabels struct {
+	Cache string // the cache name
+}
+
+var (
+	cacheHits = metrics.NewCounterMap[namedCacheLabels](
+		"serviceweaver_cache_hit_count",
+		"Count of Service Weaver cache lookups that found an entry",
+	)
+	cacheMisses = metrics.NewCounterMap[namedCacheLabels](
+		"serviceweaver_cache_miss_count",
+		"Count of Service Weaver cache lookups that found no entry",
+	)
+	cacheInvalidations = metrics.NewCounterMap[namedCacheLabels](
+		"serviceweaver_cache_invalidation_count",
+		"Count of Service Weaver cache invalidations received from any process",
+	)
+)
+
+// CacheOptions configure a Cache.
+type CacheOptions struct {
+	// MaxEntries is the number of entries a process keeps in the cache. Once
+	// the cache is full, the least recently used entry is evicted to make
+	// room for a new one. If zero, DefaultCacheMaxEntries is used.
+	MaxEntries int
+
+	// TTL is how long an entry is kept after it is added. It bounds how stale
+	// an entry can get if an invalidation is lost. If zero, DefaultCacheTTL
+	// is used.
+	TTL time.Duration
+}
+
+// A Cache is a named, in-memory cache that maps keys of type K to values of
+// type V. For example, a frontend can cache the product catalog it renders on
+// every page:
+//
+//	var catalog = weaver.NewCache[string, []Product]("catalog", weaver.CacheOptions{TTL: time.Minute})
+//
+//	func (s *server) products(ctx context.Context) ([]Product, error) {
+//	    return catalog.GetOrLoad(ctx, s.root, "all", func(ctx context.Context, _ string) ([]Product, error) {
+//	        return s.catalog.Get().ListProducts(ctx)
+//	    })
+//	}
+//
+// Every process keeps its own entries, up to CacheOptions.MaxEntries, evicting
+// the least recently used entry first, and drops an entry once it is older
+// than CacheOptions.TTL, as measured by the process's Clock (see ClockOf), so
+// that tests can expire entries by advancing a fake clock.
+//
+// To shard a cache across the replicas of a component, rather than having
+// every replica cache every key, use the cache in the methods of the
+// component and route the methods by key (see WithRouter), so that every
+// replica caches the keys routed to it.
+//
+// Invalidate removes a key from the cache of every process in the deployment.
+// Invalidations are broadcast best effort, like the events published with
+// Publish, so a process may miss one, e.g., while it starts; the TTL bounds
+// how long it then serves the stale entry. Values are shared by the callers
+// of a process and must not be modified.
+type Cache[K StoreKey, V any] struct {
+	name string
+	opts CacheOptions
+}
+
+// NewCache returns the cache with the provided name. Caches are typically
+// declared at package scope, and every cache in an application must have a
+// different name. NewCache panics if the options are invalid.
+func NewCache[K StoreKey, V any](name string, opts CacheOptions) Cache[K, V] {
+	if opts.MaxEntries < 0 {
+		panic(fmt.Errorf("NewCache(%q): negative max entries %d", name, opts.MaxEntries))
+	}
+	if opts.TTL < 0 {
+		panic(fmt.Errorf("NewCache(%q): negative TTL %v", name, opts.TTL))
+	}
+	if opts.MaxEntries == 0 {
+		opts.MaxEntries = DefaultCacheMaxEntries
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	return Cache[K, V]{name: name, opts: opts}
+}
+
+// Name returns the name of the cache.
+func (c Cache[K, V]) Name() string {
+	return c.name
+}
+
+// Get returns the value cached for the provided key in this process, and
+// whether there is one. If there is none, Get returns the zero value of V.
+func (c Cache[K, V]) Get(requester Instance, key K) (V, bool) {
+	return c.get(requester.rep().wlet, key)
+}
+
+// Put caches the value of the provided key in this process.
+func (c Cache[K, V]) Put(requester Instance, key K, value V) {
+	c.put(requester.rep().wlet, key, value)
+}
+
+// GetOrLoad returns the value cached for the provided key in this process. If
+// there is none, GetOrLoad calls load, caches the value it returns, and
+// returns it. Errors returned by load are not cached.
+func (c Cache[K, V]) GetOrLoad(ctx context.Context, requester Instance, key K, load func(context.Context, K) (V, error)) (V, error) {
+	w := requester.rep().wlet
+	if value, ok := c.get(w, key); ok {
+		return value, nil
+	}
+	value, err := load(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	c.put(w, key, value)
+	return value, nil
+}
+
+// Invalidate removes the provided key from the cache of this process, and
+// broadcasts the invalidation to every other process in the deployment. It
+// returns an error if the invalidation cannot be handed to the deployer; the
+// key is removed from the cache of this process regardless.
+func (c Cache[K, V]) Invalidate(ctx context.Context, requester Instance, key K) error {
+	return c.invalidate(ctx, requester.rep().wlet, key)
+}
+
+func (c Cache[K, V]) get(w *weavelet, key K) (V, bool) {
+	labels := namedCacheLabels{Cache: c.name}
+	value, ok := w.caches.cache(c.name, c.opts).get(fmt.Sprint(key), w.clock.Now())
+	if !ok {
+		cacheMisses.Get(labels).Add(1)
+		var zero V
+		return zero, false
+	}
+	cacheHits.Get(labels).Add(1)
+	return value.(V), true
+}
+
+func (c Cache[K, V]) put(w *weavelet, key K, value V) {
+	w.caches.cache(c.name, c.opts).put(fmt.Sprint(key), value, w.clock.Now())
+}
+
+func (c Cache[K, V]) invalidate(ctx context.Context, w *weavelet, key K) error {
+	k := fmt.Sprint(key)
+	w.caches.cache(c.name, c.opts).remove(k)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.env.PublishTopicEvent(&protos.TopicEvent{
+		Topic:     cacheTopicPrefix + c.name,
+		Payload:   []byte(k),
+		Publisher: w.info.Id,
+	})
+}
+
+// caches holds the entries of the caches created with NewCache in a weavelet.
+// The entries of a cache are allocated the first time it is used.
+type caches struct {
+	mu     sync.Mutex
+	caches map[string]*lruCache // by cache name
+}
+
+func newCaches() *caches {
+	return &caches{caches: map[string]*lruCache{}}
+}
+
+// cache returns the entries of the named cache, allocating them if needed.
+func (c *caches) cache(name string, opts CacheOptions) *lruCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.caches[name]; ok {
+		return l
+	}
+	l := newLRUCache(opts.MaxEntries, opts.TTL)
+	c.caches[name] = l
+	return l
+}
+
+// invalidate applies the invalidation published on the provided topic, if the
+// topic is the invalidation topic of a cache.
+func (c *caches) invalidate(topic string, payload []byte) {
+	if !strings.HasPrefix(topic, cacheTopicPrefix) {
+		return
+	}
+	name := strings.TrimPrefix(topic, cacheTopicPrefix)
+	cacheInvalidations.Get(namedCacheLabels{Cache: name}).Add(1)
+	c.mu.Lock()
+	l, ok := c.caches[name]
+	c.mu.Unlock()
+	if ok {
+		l.remove(string(payload))
+	}
+}
+
+// lruEntry is an entry of an lruCache.
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time // when the entry expires
+}
+
+// lruCache is a cache of values, keyed by string, that holds at most a fixed
+// number of entries, each for at most a fixed duration. The least recently
+// used entries are evicted first. An lruCache is safe for concurrent use.
+type lruCache struct {
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List               // of *lruEntry, most recently used first
+	entries map[string]*list.Element // elements of lru, by key
+}
+
+// newLRUCache returns a new cache that holds at most maxEntries entries, each
+// for at most ttl.
+func newLRUCache(maxEntries int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// get returns the unexpired value cached for key, if any.
+func (c *lruCache) get(key string, now time.Time) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !now.Before(entry.expires) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.value, true
+}
+
+// put caches value for key, evicting the least recently used entry if the
+// cache is full.
+func (c *lruCache) put(key string, value any, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&lruEntry{key: key, value: value, expires: now.Add(c.ttl)})
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// remove removes the value cached for key, if any.
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement removes elem from the cache.
+//
+// REQUIRES: c.mu is held.
+func (c *lruCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*lruEntry)
+	delete(c.entries, entry.key)
+}
@@ -0,0 +1,296 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestMethodCacheEviction(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	key := func(args string) cacheKey { return cacheKey{args: args} }
+
+	// Every entry is 2 bytes, so the cache holds two entries.
+	c := newMethodCache(4)
+	c.put(key("a"), []byte("1"), later, 0)
+	c.put(key("b"), []byte("2"), later, 0)
+	if _, ok := c.get(key("a"), now); !ok { // a is now most recently used
+		t.Fatal("a: unexpected miss")
+	}
+	c.put(key("c"), []byte("3"), later, 0) // evicts b
+	if _, ok := c.get(key("b"), now); ok {
+		t.Error("b: unexpected hit")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.get(key(k), now); !ok {
+			t.Errorf("%s: unexpected miss", k)
+		}
+	}
+
+	// Expired entries are not returned.
+	if _, ok := c.get(key("a"), later); ok {
+		t.Error("expired a: unexpected hit")
+	}
+
+	// Invalidated entries are not returned.
+	c.remove(key("c"))
+	if _, ok := c.get(key("c"), now); ok {
+		t.Error("removed c: unexpected hit")
+	}
+}
+
+func TestMethodCacheMaxEntries(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	foo := call.MakeMethodKey("T", "Foo")
+	bar := call.MakeMethodKey("T", "Bar")
+
+	// Foo caches at most two results; Bar is bounded only by size.
+	c := newMethodCache(1 << 10)
+	c.put(cacheKey{method: foo, args: "a"}, []byte("1"), later, 2)
+	c.put(cacheKey{method: bar, args: "x"}, []byte("1"), later, 0)
+	c.put(cacheKey{method: foo, args: "b"}, []byte("2"), later, 2)
+	if _, ok := c.get(cacheKey{method: foo, args: "a"}, now); !ok { // a is now most recently used
+		t.Fatal("Foo(a): unexpected miss")
+	}
+	c.put(cacheKey{method: foo, args: "c"}, []byte("3"), later, 2) // evicts Foo(b)
+	if _, ok := c.get(cacheKey{method: foo, args: "b"}, now); ok {
+		t.Error("Foo(b): unexpected hit")
+	}
+	for _, key := range []cacheKey{{method: foo, args: "a"}, {method: foo, args: "c"}, {method: bar, args: "x"}} {
+		if _, ok := c.get(key, now); !ok {
+			t.Errorf("%v: unexpected miss", key.args)
+		}
+	}
+
+	// Replacing a cached result doesn't evict another one.
+	c.put(cacheKey{method: foo, args: "c"}, []byte("4"), later, 2)
+	for _, args := range []string{"a", "c"} {
+		if _, ok := c.get(cacheKey{method: foo, args: args}, now); !ok {
+			t.Errorf("Foo(%s): unexpected miss", args)
+		}
+	}
+}
+
+// countingClient is a call.Connection that counts calls and returns the
+// serialization of a string result and the provided error.
+type countingClient struct {
+	calls int
+	err   error
+}
+
+var _ call.Connection = &countingClient{}
+
+func (c *countingClient) Call(_ context.Context, _ call.MethodKey, args []byte, _ call.CallOptions) ([]byte, error) {
+	c.calls++
+	enc := codegen.NewEncoder()
+	enc.String(string(args))
+	enc.Error(c.err)
+	return enc.Data(), nil
+}
+
+func (c *countingClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, errors.New("streaming calls not supported")
+}
+
+func (c *countingClient) Close() {}
+
+func TestStubCaching(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		err   error
+		calls int // expected number of remote calls
+	}{
+		// "a" is fetched once, "b" once, and the invalidated "a" once more.
+		{"Success", nil, 3},
+		// Errors are not cached without a negative TTL.
+		{"NoNegativeCaching", errors.New("error"), 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := &countingClient{err: test.err}
+			labels := cacheLabels{Component: test.name}
+			s := stub{
+				client:  client,
+				methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+				policies: []*cachePolicy{{
+					ttl:    time.Hour,
+					hits:   methodCacheHits.Get(labels),
+					misses: methodCacheMisses.Get(labels),
+				}},
+				cache: newMethodCache(1 << 10),
+				clock: systemClock{},
+			}
+			ctx := context.Background()
+			for _, arg := range []string{"a", "a", "b"} {
+				if _, err := s.Run(ctx, 0, []byte(arg), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := s.Run(Invalidate(ctx), 0, []byte("a"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if client.calls != test.calls {
+				t.Fatalf("got %d remote calls, want %d", client.calls, test.calls)
+			}
+		})
+	}
+}
+
+// steppedClock is a Clock whose time only moves when now is changed.
+type steppedClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c *steppedClock) Now() time.Time { return c.now }
+
+func TestStubCacheExpiry(t *testing.T) {
+	client := &countingClient{}
+	labels := cacheLabels{Component: "TestStubCacheExpiry"}
+	clock := &steppedClock{now: time.Now()}
+	s := stub{
+		client:  client,
+		methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+		policies: []*cachePolicy{{
+			ttl:    time.Minute,
+			hits:   methodCacheHits.Get(labels),
+			misses: methodCacheMisses.Get(labels),
+		}},
+		cache: newMethodCache(1 << 10),
+		clock: clock,
+	}
+	run := func() {
+		t.Helper()
+		if _, err := s.Run(context.Background(), 0, []byte("a"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run()
+	clock.now = clock.now.Add(59 * time.Second)
+	run()
+	if got, want := client.calls, 1; got != want {
+		t.Fatalf("before expiry: got %d remote calls, want %d", got, want)
+	}
+	clock.now = clock.now.Add(time.Second)
+	run()
+	if got, want := client.calls, 2; got != want {
+		t.Fatalf("after expiry: got %d remote calls, want %d", got, want)
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	now := time.Now()
+	c := newLRUCache(2, time.Minute)
+	c.put("a", 1, now)
+	c.put("b", 2, now)
+	if _, ok := c.get("a", now); !ok { // a is now most recently used
+		t.Fatal("a: unexpected miss")
+	}
+	c.put("c", 3, now) // evicts b
+	if _, ok := c.get("b", now); ok {
+		t.Error("b: unexpected hit")
+	}
+	for k, want := range map[string]int{"a": 1, "c": 3} {
+		if got, ok := c.get(k, now); !ok || got != want {
+			t.Errorf("%s: got %v, %t, want %d, true", k, got, ok, want)
+		}
+	}
+
+	// Expired entries are not returned.
+	if _, ok := c.get("a", now.Add(time.Minute)); ok {
+		t.Error("expired a: unexpected hit")
+	}
+
+	// Removed entries are not returned.
+	c.remove("c")
+	if _, ok := c.get("c", now); ok {
+		t.Error("removed c: unexpected hit")
+	}
+}
+
+// broadcastEnv is an env that delivers the topic events published by a
+// weavelet to a set of weavelets.
+type broadcastEnv struct {
+	env
+	weavelets *[]*weavelet
+}
+
+func (e broadcastEnv) PublishTopicEvent(event *protos.TopicEvent) error {
+	for _, w := range *e.weavelets {
+		if w.info.Id != event.Publisher {
+			w.HandleTopicEvent(event)
+		}
+	}
+	return nil
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	// Test plan: Cache a key in two weavelets. Invalidate the key in one of
+	// them, and check that it is removed from both. Check that other keys
+	// and caches are unaffected.
+	var weavelets []*weavelet
+	for _, id := range []string{"a", "b"} {
+		weavelets = append(weavelets, &weavelet{
+			env:    broadcastEnv{weavelets: &weavelets},
+			info:   &protos.EnvelopeInfo{Id: id},
+			topics: newTopics(),
+			caches: newCaches(),
+			clock:  systemClock{},
+		})
+	}
+	prices := NewCache[string, int]("TestCacheInvalidate/prices", CacheOptions{})
+	stock := NewCache[string, int]("TestCacheInvalidate/stock", CacheOptions{})
+	for _, w := range weavelets {
+		prices.put(w, "apple", 1)
+		prices.put(w, "pear", 2)
+		stock.put(w, "apple", 10)
+	}
+
+	if err := prices.invalidate(context.Background(), weavelets[0], "apple"); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range weavelets {
+		if _, ok := prices.get(w, "apple"); ok {
+			t.Errorf("%s: invalidated apple price: unexpected hit", w.info.Id)
+		}
+		if got, ok := prices.get(w, "pear"); !ok || got != 2 {
+			t.Errorf("%s: pear price: got %d, %t, want 2, true", w.info.Id, got, ok)
+		}
+		if got, ok := stock.get(w, "apple"); !ok || got != 10 {
+			t.Errorf("%s: apple stock: got %d, %t, want 10, true", w.info.Id, got, ok)
+		}
+	}
+}
+
+func TestNewCacheInvalidOptions(t *testing.T) {
+	for _, opts := range []CacheOptions{{MaxEntries: -1}, {TTL: -time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewCache(%+v): unexpected success", opts)
+				}
+			}()
+			NewCache[string, int]("TestNewCacheInvalidOptions", opts)
+		}()
+	}
+}
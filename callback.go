@@ -0,0 +1,388 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/uuid"
+)
+
+// callbackTopicPrefix prefixes the names of the topic events used to
+// broadcast the invocations of callbacks whose registering process is gone.
+const callbackTopicPrefix = "serviceweaver/callback/"
+
+// callbackComponent is the pseudo-component whose "invoke" method, served by
+// every weavelet, invokes a callback registered in the weavelet.
+const callbackComponent = "serviceweaver/callback"
+
+// invokeCallbackKey is the key of the "invoke" method of callbackComponent.
+var invokeCallbackKey = call.MakeMethodKey(callbackComponent, "invoke")
+
+type callbackLabels struct {
+	Callback string // the callback name
+	Delivery string // "direct" or "broadcast"
+}
+
+var callbackInvocations = metrics.NewCounterMap[callbackLabels](
+	"serviceweaver_callback_invocation_count",
+	"Count of Service Weaver callback invocations, by how they were delivered",
+)
+
+// A Callback is a named kind of callback that takes messages of type T. A
+// process registers a handler for a callback and hands the returned
+// CallbackRef to a component, which can invoke the handler later, e.g., to
+// push a notification back to the replica that asked for it. For example, a
+// frontend can ask the checkout service to tell it when an order ships:
+//
+//	type OrderShipped struct {
+//	    weaver.AutoMarshal
+//	    OrderID string
+//	}
+//
+//	var shipped = weaver.NewCallback[OrderShipped]("order-shipped")
+//
+//	// In the frontend.
+//	ref := shipped.Register(root, orderID, func(ctx context.Context, msg OrderShipped) error {
+//	    notifyBrowser(msg.OrderID)
+//	    return nil
+//	})
+//	defer shipped.Unregister(root, ref)
+//	err := checkout.WatchOrder(ctx, orderID, ref)
+//
+//	// In the checkout service, once the order ships.
+//	err := shipped.Invoke(ctx, s, ref, OrderShipped{OrderID: id})
+//
+// A CallbackRef is a plain value that can be passed to and returned from
+// component methods, and stored. A message of type T is serialized like a
+// component method argument, so T must be a struct that embeds
+// weaver.AutoMarshal.
+type Callback[T any] struct {
+	name string
+}
+
+// NewCallback returns the callback with the provided name. Callbacks are
+// typically declared at package scope. NewCallback panics if T is not a
+// struct that embeds weaver.AutoMarshal.
+func NewCallback[T any](name string) Callback[T] {
+	if _, ok := any(new(T)).(codegen.AutoMarshal); !ok {
+		var zero T
+		panic(fmt.Errorf("NewCallback(%q): message type %T does not embed weaver.AutoMarshal", name, zero))
+	}
+	return Callback[T]{name: name}
+}
+
+// Name returns the name of the callback.
+func (c Callback[T]) Name() string {
+	return c.name
+}
+
+// Register registers handler in the requester's process, under the provided
+// key, and returns a reference to it. The key identifies what the handler is
+// interested in, e.g., an order id; see Invoke. The handler stays registered
+// until it is unregistered with Unregister or its process exits.
+func (c Callback[T]) Register(requester Instance, key string, handler func(context.Context, T) error) CallbackRef {
+	w := requester.rep().wlet
+	handle := func(ctx context.Context, payload []byte) error {
+		var msg T
+		if err := decodeMessage(payload, any(&msg).(codegen.AutoMarshal)); err != nil {
+			return fmt.Errorf("decode callback message: %w", err)
+		}
+		return handler(ctx, msg)
+	}
+	id := w.callbacks.add(c.name, key, handle)
+	return CallbackRef{
+		Name:     c.name,
+		Key:      key,
+		ID:       id,
+		Weavelet: w.info.Id,
+		Addr:     w.dialAddr,
+	}
+}
+
+// Unregister unregisters the handler registered by Register that returned
+// ref. Later invocations of ref are broadcast, as if the registering process
+// were gone. It is a no-op if the handler isn't registered.
+func (c Callback[T]) Unregister(requester Instance, ref CallbackRef) {
+	requester.rep().wlet.callbacks.remove(ref.ID)
+}
+
+// Invoke invokes the handler referenced by ref with the provided message.
+//
+// If the process that registered the handler is still running and the
+// handler is still registered, the message is delivered directly to it, and
+// Invoke returns the error returned by the handler. Otherwise, e.g., because
+// the replica that registered the handler failed or was scaled down, the
+// message is broadcast to every process in the deployment and delivered, in
+// the background, to every handler of the callback registered under ref's
+// key, if any; Invoke then returns nil once the broadcast has been handed to
+// the deployer. Broadcasts are delivered best effort, like events published
+// with Publish.
+func (c Callback[T]) Invoke(ctx context.Context, requester Instance, ref CallbackRef, msg T) error {
+	if ref.Name != c.name {
+		return fmt.Errorf("invoke callback %q: reference to callback %q", c.name, ref.Name)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	enc := codegen.NewEncoder()
+	any(&msg).(codegen.AutoMarshal).WeaverMarshal(enc)
+	return requester.rep().wlet.invokeCallback(ctx, ref, enc.Data())
+}
+
+// A CallbackRef refers to a handler registered with Callback.Register.
+type CallbackRef struct {
+	Name     string // the callback name
+	Key      string // the key the handler was registered under
+	ID       string // the unique id of the registration
+	Weavelet string // the id of the registering weavelet
+	Addr     string // the dial address of the registering weavelet, if any
+}
+
+var _ codegen.AutoMarshal = &CallbackRef{}
+
+// WeaverMarshal implements the codegen.AutoMarshal interface.
+func (r *CallbackRef) WeaverMarshal(enc *codegen.Encoder) {
+	start := enc.BeginStruct(5)
+	enc.String(r.Name)
+	enc.String(r.Key)
+	enc.String(r.ID)
+	enc.String(r.Weavelet)
+	enc.String(r.Addr)
+	enc.EndStruct(start)
+}
+
+// WeaverUnmarshal implements the codegen.AutoMarshal interface.
+func (r *CallbackRef) WeaverUnmarshal(dec *codegen.Decoder) {
+	n, end := dec.BeginStruct()
+	for i, field := range []*string{&r.Name, &r.Key, &r.ID, &r.Weavelet, &r.Addr} {
+		if n > i {
+			*field = dec.String()
+		}
+	}
+	dec.EndStruct(end)
+}
+
+// callbackHandler is a handler registered with Callback.Register.
+type callbackHandler struct {
+	name   string // the callback name
+	key    string // the key the handler was registered under
+	handle func(context.Context, []byte) error
+}
+
+// callbacks holds the callback handlers registered in a weavelet, and the
+// connections to the weavelets whose callbacks it invokes.
+type callbacks struct {
+	mu    sync.Mutex
+	byID  map[string]*callbackHandler // handlers, by registration id
+	conns map[string]call.Connection  // connections, by dial address
+}
+
+func newCallbacks() *callbacks {
+	return &callbacks{
+		byID:  map[string]*callbackHandler{},
+		conns: map[string]call.Connection{},
+	}
+}
+
+// add registers a handler and returns its registration id.
+func (c *callbacks) add(name, key string, handle func(context.Context, []byte) error) string {
+	id := uuid.New().String()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byID[id] = &callbackHandler{name: name, key: key, handle: handle}
+	return id
+}
+
+// remove unregisters the handler with the provided registration id.
+func (c *callbacks) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byID, id)
+}
+
+// get returns the handler with the provided registration id, if any.
+func (c *callbacks) get(id string) (*callbackHandler, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.byID[id]
+	return h, ok
+}
+
+// matching returns the handlers of the named callback registered under the
+// provided key.
+func (c *callbacks) matching(name, key string) []*callbackHandler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var handlers []*callbackHandler
+	for _, h := range c.byID {
+		if h.name == name && h.key == key {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// invokeCallback delivers the encoded message to the handler referenced by
+// ref, directly if possible, or by broadcast otherwise. See Callback.Invoke.
+func (w *weavelet) invokeCallback(ctx context.Context, ref CallbackRef, payload []byte) error {
+	if ref.Weavelet == w.info.Id {
+		if h, ok := w.callbacks.get(ref.ID); ok {
+			callbackInvocations.Get(callbackLabels{Callback: ref.Name, Delivery: "direct"}).Add(1)
+			return h.handle(ctx, payload)
+		}
+	} else if ref.Addr != "" {
+		delivered, err := w.invokeRemoteCallback(ctx, ref, payload)
+		if err != nil && !errors.Is(err, call.Unreachable) {
+			return err
+		}
+		if delivered {
+			callbackInvocations.Get(callbackLabels{Callback: ref.Name, Delivery: "direct"}).Add(1)
+			return nil
+		}
+	}
+	return w.broadcastCallback(ref, payload)
+}
+
+// invokeRemoteCallback invokes the handler referenced by ref in the weavelet
+// that registered it. It returns false if the handler is no longer
+// registered there.
+func (w *weavelet) invokeRemoteCallback(ctx context.Context, ref CallbackRef, payload []byte) (bool, error) {
+	conn, err := w.callbackConn(ctx, ref.Addr)
+	if err != nil {
+		return false, err
+	}
+	enc := codegen.NewEncoder()
+	enc.String(ref.ID)
+	enc.Bytes(payload)
+	reply, err := conn.Call(ctx, invokeCallbackKey, enc.Data(), call.CallOptions{})
+	if errors.Is(err, call.Unreachable) {
+		// The weavelet is likely gone; forget the connection to it.
+		w.callbacks.mu.Lock()
+		delete(w.callbacks.conns, ref.Addr)
+		w.callbacks.mu.Unlock()
+		conn.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(reply) == 1 && reply[0] == 1, nil
+}
+
+// callbackConn returns a connection to the weavelet with the provided dial
+// address, creating it if needed.
+func (w *weavelet) callbackConn(ctx context.Context, addr string) (call.Connection, error) {
+	w.callbacks.mu.Lock()
+	defer w.callbacks.mu.Unlock()
+	if conn, ok := w.callbacks.conns[addr]; ok {
+		return conn, nil
+	}
+	endpoints, err := parseEndpoints([]string{addr}, w.quic)
+	if err != nil {
+		return nil, err
+	}
+	opts := w.transport.clientOpts
+	if w.mutualTLS && w.quic == nil {
+		creds, err := w.credentials()
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = creds.ClientConfig()
+	}
+	conn, err := call.Connect(w.ctx, call.NewConstantResolver(endpoints...), opts)
+	if err != nil {
+		return nil, err
+	}
+	w.callbacks.conns[addr] = conn
+	return conn, nil
+}
+
+// serveCallback serves invokeCallbackKey. It replies with a single byte: 1
+// if the referenced handler was invoked, or 0 if it isn't registered.
+func (w *weavelet) serveCallback(ctx context.Context, args []byte) (result []byte, err error) {
+	var id string
+	var payload []byte
+	func() {
+		defer func() { err = codegen.CatchPanics(recover()) }()
+		dec := codegen.NewDecoder(args)
+		id = dec.String()
+		payload = dec.Bytes()
+	}()
+	if err != nil {
+		return nil, err
+	}
+	h, ok := w.callbacks.get(id)
+	if !ok {
+		return []byte{0}, nil
+	}
+	if err := h.handle(ctx, payload); err != nil {
+		return nil, err
+	}
+	return []byte{1}, nil
+}
+
+// broadcastCallback delivers the encoded message to every handler of the
+// callback registered under ref's key, in every process of the deployment.
+func (w *weavelet) broadcastCallback(ref CallbackRef, payload []byte) error {
+	callbackInvocations.Get(callbackLabels{Callback: ref.Name, Delivery: "broadcast"}).Add(1)
+	enc := codegen.NewEncoder()
+	enc.String(ref.Key)
+	enc.Bytes(payload)
+	event := enc.Data()
+	w.deliverCallback(callbackTopicPrefix+ref.Name, event)
+	return w.env.PublishTopicEvent(&protos.TopicEvent{
+		Topic:     callbackTopicPrefix + ref.Name,
+		Payload:   event,
+		Publisher: w.info.Id,
+	})
+}
+
+// deliverCallback delivers the callback invocation broadcast on the provided
+// topic, if the topic is the broadcast topic of a callback, to the matching
+// handlers registered in this weavelet.
+func (w *weavelet) deliverCallback(topic string, event []byte) {
+	if !strings.HasPrefix(topic, callbackTopicPrefix) {
+		return
+	}
+	name := strings.TrimPrefix(topic, callbackTopicPrefix)
+	var key string
+	var payload []byte
+	if err := func() (err error) {
+		defer func() { err = codegen.CatchPanics(recover()) }()
+		dec := codegen.NewDecoder(event)
+		key = dec.String()
+		payload = dec.Bytes()
+		return nil
+	}(); err != nil {
+		w.env.SystemLogger().Error("decode callback broadcast", err, "callback", name)
+		return
+	}
+	for _, h := range w.callbacks.matching(name, key) {
+		h := h
+		go func() {
+			if err := h.handle(w.ctx, payload); err != nil {
+				w.env.SystemLogger().Error("callback", err, "callback", name, "key", key)
+			}
+		}()
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+var testShipped = NewCallback[testEmail]("shipped")
+
+// testCallbackWeavelets returns n weavelets that broadcast topic events to
+// each other, and a requester in each.
+func testCallbackWeavelets(t *testing.T, n int) []Instance {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	var peers []*weavelet
+	var requesters []Instance
+	for i := 0; i < n; i++ {
+		w := &weavelet{
+			ctx:       ctx,
+			env:       broadcastEnv{weavelets: &peers},
+			info:      &protos.EnvelopeInfo{Id: string(rune('a' + i))},
+			topics:    newTopics(),
+			caches:    newCaches(),
+			callbacks: newCallbacks(),
+			transport: &transport{clientOpts: call.ClientOptions{Logger: logging.NewTestLogger(t)}},
+		}
+		peers = append(peers, w)
+		requesters = append(requesters, &componentImpl{component: &component{wlet: w}})
+	}
+	return requesters
+}
+
+// recvEmail returns the next message received on c, or fails the test.
+func recvEmail(t *testing.T, c <-chan testEmail) testEmail {
+	t.Helper()
+	select {
+	case msg := <-c:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not invoked")
+		return testEmail{}
+	}
+}
+
+func TestCallbackLocal(t *testing.T) {
+	// Test plan: Register a callback and invoke it from the same weavelet.
+	// Check that the handler is invoked directly, and that its error is
+	// returned.
+	a := testCallbackWeavelets(t, 1)[0]
+	ctx := context.Background()
+	handlerErr := errors.New("handler failed")
+	var got []string
+	ref := testShipped.Register(a, "o1", func(_ context.Context, msg testEmail) error {
+		got = append(got, msg.To)
+		if msg.To == "fail" {
+			return handlerErr
+		}
+		return nil
+	})
+	if err := testShipped.Invoke(ctx, a, ref, testEmail{To: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testShipped.Invoke(ctx, a, ref, testEmail{To: "fail"}); !errors.Is(err, handlerErr) {
+		t.Fatalf("Invoke: got %v, want %v", err, handlerErr)
+	}
+	if len(got) != 2 || got[0] != "alice" {
+		t.Fatalf("handler: got %v, want [alice fail]", got)
+	}
+
+	other := NewCallback[testEmail]("other")
+	if err := other.Invoke(ctx, a, ref, testEmail{}); err == nil {
+		t.Fatal("Invoke with the reference of another callback: unexpected success")
+	}
+}
+
+func TestCallbackBroadcast(t *testing.T) {
+	// Test plan: Register callbacks in two weavelets, and invoke a callback
+	// whose weavelet is gone. Check that the invocation is delivered to
+	// every handler registered under its key, and no other.
+	ws := testCallbackWeavelets(t, 2)
+	a, b := ws[0], ws[1]
+	ctx := context.Background()
+	fromA, fromB := make(chan testEmail, 10), make(chan testEmail, 10)
+	testShipped.Register(a, "o1", func(_ context.Context, msg testEmail) error {
+		fromA <- msg
+		return nil
+	})
+	testShipped.Register(b, "o1", func(_ context.Context, msg testEmail) error {
+		fromB <- msg
+		return nil
+	})
+	testShipped.Register(b, "o2", func(_ context.Context, msg testEmail) error {
+		t.Errorf("handler for o2 invoked with %v", msg)
+		return nil
+	})
+
+	gone := CallbackRef{Name: "shipped", Key: "o1", ID: "gone", Weavelet: "c"}
+	if err := testShipped.Invoke(ctx, a, gone, testEmail{To: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []chan testEmail{fromA, fromB} {
+		if got := recvEmail(t, c); got.To != "bob" {
+			t.Fatalf("handler: got %q, want %q", got.To, "bob")
+		}
+	}
+}
+
+func TestCallbackUnregister(t *testing.T) {
+	// Test plan: Unregister a callback, and invoke it. Check that the
+	// invocation is broadcast to the other handlers registered under its
+	// key.
+	a := testCallbackWeavelets(t, 1)[0]
+	ctx := context.Background()
+	ref := testShipped.Register(a, "o1", func(context.Context, testEmail) error {
+		t.Error("unregistered handler invoked")
+		return nil
+	})
+	testShipped.Unregister(a, ref)
+	c := make(chan testEmail, 10)
+	testShipped.Register(a, "o1", func(_ context.Context, msg testEmail) error {
+		c <- msg
+		return nil
+	})
+	if err := testShipped.Invoke(ctx, a, ref, testEmail{To: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := recvEmail(t, c); got.To != "carol" {
+		t.Fatalf("handler: got %q, want %q", got.To, "carol")
+	}
+}
+
+func TestCallbackRemote(t *testing.T) {
+	// Test plan: Serve the callbacks of one weavelet, and invoke them from
+	// another. Check that a registered callback is invoked directly, and
+	// that an unregistered one is broadcast.
+	ws := testCallbackWeavelets(t, 2)
+	a, b := ws[0], ws[1]
+	wa := a.rep().wlet
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := &call.HandlerMap{}
+	handlers.Set(callbackComponent, "invoke", wa.serveCallback)
+	go call.Serve(wa.ctx, lis, handlers, call.ServerOptions{Logger: logging.NewTestLogger(t)})
+	wa.dialAddr = "tcp://" + lis.Addr().String()
+
+	c := make(chan testEmail, 10)
+	ref := testShipped.Register(a, "o1", func(_ context.Context, msg testEmail) error {
+		c <- msg
+		return nil
+	})
+	if err := testShipped.Invoke(ctx, b, ref, testEmail{To: "dave"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-c:
+		if got.To != "dave" {
+			t.Fatalf("handler: got %q, want %q", got.To, "dave")
+		}
+	default:
+		t.Fatal("Invoke returned before the handler was invoked")
+	}
+
+	testShipped.Unregister(a, ref)
+	testShipped.Register(a, "o1", func(_ context.Context, msg testEmail) error {
+		c <- msg
+		return nil
+	})
+	if err := testShipped.Invoke(ctx, b, ref, testEmail{To: "erin"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := recvEmail(t, c); got.To != "erin" {
+		t.Fatalf("handler: got %q, want %q", got.To, "erin")
+	}
+}
+
+func TestCallbackRefMarshal(t *testing.T) {
+	want := CallbackRef{Name: "shipped", Key: "o1", ID: "1234", Weavelet: "a", Addr: "tcp://localhost:1"}
+	enc := codegen.NewEncoder()
+	want.WeaverMarshal(enc)
+	var got CallbackRef
+	got.WeaverUnmarshal(codegen.NewDecoder(enc.Data()))
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/retry"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type hedgeLabels struct {
+	Component string // full callee component name
+	Method    string // callee component method's name
+}
+
+var methodHedges = metrics.NewCounterMap[hedgeLabels](
+	"serviceweaver_method_hedge_count",
+	"Count of Service Weaver component method calls hedged with a second call",
+)
+
+// callPolicy describes how remote calls to a method are bounded, retried, and
+// hedged.
+// Call policies are set in the config, per component and per method:
+//
+//	[serviceweaver.components."github.com/my/project/shipping/T"]
+//	timeout = "2s"          # fail calls that take longer than 2 seconds
+//	max_retries = 3         # retry calls that fail with a system error
+//	retry_backoff = "50ms"  # base delay between retries
+//
+//	[serviceweaver.methods."github.com/my/project/shipping/T.GetQuote"]
+//	timeout = "500ms"       # overrides the component's timeout
+//	hedge_delay = "20ms"    # hedge calls that take longer than 20ms
+//
+// A method inherits every setting it doesn't set from its component. Only
+// remote calls have call policies; calls to colocated components are regular
+// function calls.
+type callPolicy struct {
+	timeout    time.Duration    // if positive, deadline of a call, retries included
+	maxRetries int              // number of retries of retriable failures
+	backoff    time.Duration    // base delay between retries
+	hedgeDelay time.Duration    // if positive, delay before hedging an attempt
+	hedges     *metrics.Counter // number of hedged attempts, if hedging
+}
+
+// callPolicy returns the call policy of the provided method, or nil if calls
+// to the method are neither bounded, retried, nor hedged.
+func (w *weavelet) callPolicy(component, method string) *callPolicy {
+	config := w.componentConfigs[component]
+	override := w.methodConfigs[component+"."+method].CallConfig
+	if override.Timeout != 0 {
+		config.Timeout = override.Timeout
+	}
+	if override.MaxRetries != 0 {
+		config.MaxRetries = override.MaxRetries
+	}
+	if override.RetryBackoff != 0 {
+		config.RetryBackoff = override.RetryBackoff
+	}
+	if override.HedgeDelay != 0 {
+		config.HedgeDelay = override.HedgeDelay
+	}
+	if config.Timeout <= 0 && config.MaxRetries <= 0 && config.HedgeDelay <= 0 {
+		return nil
+	}
+	p := &callPolicy{
+		timeout:    config.Timeout,
+		maxRetries: config.MaxRetries,
+		backoff:    config.RetryBackoff,
+	}
+	if config.HedgeDelay > 0 {
+		p.hedgeDelay = config.HedgeDelay
+		p.hedges = methodHedges.Get(hedgeLabels{Component: component, Method: method})
+	}
+	return p
+}
+
+// run runs f, which makes a remote call with the provided options, subject to
+// the policy.
+func (p *callPolicy) run(ctx context.Context, opts call.CallOptions, f func(context.Context, call.CallOptions) ([]byte, error)) ([]byte, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	backoff := retry.Options{BackoffMultiplier: 2, BackoffMinDuration: p.backoff}
+	var err error
+	for r, attempt := retry.BeginWithOptions(backoff), 0; r.Continue(ctx); attempt++ {
+		var results []byte
+		if p.hedgeDelay > 0 {
+			results, err = p.hedge(ctx, opts, f)
+		} else {
+			results, err = f(ctx, opts)
+		}
+		if err == nil || attempt >= p.maxRetries || !retriableCall(err) {
+			return results, err
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return nil, err
+}
+
+// hedge runs f and, if f hasn't returned within the policy's hedge delay, runs
+// f again, on another endpoint if possible. It returns the results of the
+// first run to succeed, and cancels the other run. If both runs fail, hedge
+// returns the error of the first run.
+func (p *callPolicy) hedge(ctx context.Context, opts call.CallOptions, f func(context.Context, call.CallOptions) ([]byte, error)) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	opts.Hedge = &call.HedgeGroup{}
+
+	type result struct {
+		results []byte
+		err     error
+	}
+	primary := make(chan result, 1)
+	go func() {
+		results, err := f(ctx, opts)
+		primary <- result{results, err}
+	}()
+
+	timer := time.NewTimer(p.hedgeDelay)
+	defer timer.Stop()
+	select {
+	case r := <-primary:
+		return r.results, r.err
+	case <-timer.C:
+	}
+
+	if p.hedges != nil {
+		p.hedges.Add(1)
+	}
+	trace.SpanFromContext(ctx).AddEvent("hedged call")
+	hedged := make(chan result, 1)
+	go func() {
+		results, err := f(ctx, opts)
+		hedged <- result{results, err}
+	}()
+
+	var primaryErr error
+	for pending := 2; pending > 0; pending-- {
+		select {
+		case r := <-primary:
+			if r.err == nil {
+				return r.results, nil
+			}
+			primaryErr = r.err
+		case r := <-hedged:
+			if r.err == nil {
+				return r.results, nil
+			}
+		}
+	}
+	return nil, primaryErr
+}
+
+// retriableCall returns whether a call that failed with err can be retried.
+func retriableCall(err error) bool {
+	return errors.Is(err, call.CommunicationError) ||
+		errors.Is(err, call.Unreachable) ||
+		errors.Is(err, call.ShedLoad) ||
+		errors.Is(err, call.Overloaded)
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// flakyClient is a call.Connection whose calls fail with err until it has
+// been called failures times.
+type flakyClient struct {
+	calls    int
+	failures int
+	err      error
+}
+
+var _ call.Connection = &flakyClient{}
+
+func (c *flakyClient) Call(ctx context.Context, _ call.MethodKey, _ []byte, _ call.CallOptions) ([]byte, error) {
+	c.calls++
+	if c.failures < 0 {
+		// Block until the call times out.
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if c.calls <= c.failures {
+		return nil, c.err
+	}
+	return nil, nil
+}
+
+func (c *flakyClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, fmt.Errorf("streaming calls not supported")
+}
+
+func (c *flakyClient) Close() {}
+
+func TestStubRetries(t *testing.T) {
+	unreachable := fmt.Errorf("%w: no replicas", call.Unreachable)
+	for _, test := range []struct {
+		name     string
+		failures int
+		err      error // error of failed calls
+		wantErr  error // if not nil, the expected error
+		calls    int   // expected number of remote calls
+	}{
+		{"Success", 0, nil, nil, 1},
+		{"Retried", 2, unreachable, nil, 3},
+		{"TooManyFailures", 5, unreachable, call.Unreachable, 4},
+		{"NotRetriable", 5, errors.New("error"), nil, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := &flakyClient{failures: test.failures, err: test.err}
+			s := stub{
+				client:  client,
+				methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+				calls:   []*callPolicy{{maxRetries: 3, backoff: time.Millisecond}},
+			}
+			_, err := s.Run(context.Background(), 0, nil, 0)
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Fatalf("Run: got %v, want %v", err, test.wantErr)
+			}
+			if client.calls != test.calls {
+				t.Fatalf("got %d remote calls, want %d", client.calls, test.calls)
+			}
+		})
+	}
+}
+
+func TestStubTimeout(t *testing.T) {
+	s := stub{
+		client:  &flakyClient{failures: -1},
+		methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+		calls:   []*callPolicy{{timeout: 10 * time.Millisecond}},
+	}
+	if _, err := s.Run(context.Background(), 0, nil, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run: got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// slowClient is a call.Connection whose first call blocks until it is
+// cancelled, and whose other calls return right away.
+type slowClient struct {
+	calls  atomic.Int32
+	hedged atomic.Int32 // number of calls that belong to a hedge group
+}
+
+var _ call.Connection = &slowClient{}
+
+func (c *slowClient) Call(ctx context.Context, _ call.MethodKey, _ []byte, opts call.CallOptions) ([]byte, error) {
+	if opts.Hedge != nil {
+		c.hedged.Add(1)
+	}
+	if c.calls.Add(1) == 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, nil
+}
+
+func (c *slowClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, fmt.Errorf("streaming calls not supported")
+}
+
+func (c *slowClient) Close() {}
+
+func TestStubHedge(t *testing.T) {
+	// Test plan: Make a call whose first attempt never returns, and check that
+	// the call is hedged and succeeds.
+	client := &slowClient{}
+	s := stub{
+		client:  client,
+		methods: []call.MethodKey{call.MakeMethodKey("", "test")},
+		calls:   []*callPolicy{{hedgeDelay: 10 * time.Millisecond}},
+	}
+	if _, err := s.Run(context.Background(), 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.calls.Load(), int32(2); got != want {
+		t.Fatalf("got %d remote calls, want %d", got, want)
+	}
+	if got, want := client.hedged.Load(), int32(2); got != want {
+		t.Fatalf("got %d calls in a hedge group, want %d", got, want)
+	}
+
+	// A call that returns within the hedge delay isn't hedged.
+	if _, err := s.Run(context.Background(), 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.calls.Load(), int32(3); got != want {
+		t.Fatalf("got %d remote calls, want %d", got, want)
+	}
+}
+
+func TestCallPolicyOverrides(t *testing.T) {
+	w := &weavelet{
+		componentConfigs: map[string]runtime.CallConfig{
+			"a/T": {Timeout: time.Second, MaxRetries: 3},
+		},
+		methodConfigs: map[string]runtime.MethodConfig{
+			"a/T.M": {CallConfig: runtime.CallConfig{Timeout: time.Minute}},
+		},
+	}
+	if got, want := *w.callPolicy("a/T", "M"), (callPolicy{timeout: time.Minute, maxRetries: 3}); got != want {
+		t.Errorf("callPolicy(a/T, M): got %+v, want %+v", got, want)
+	}
+	if got, want := *w.callPolicy("a/T", "N"), (callPolicy{timeout: time.Second, maxRetries: 3}); got != want {
+		t.Errorf("callPolicy(a/T, N): got %+v, want %+v", got, want)
+	}
+	if got := w.callPolicy("a/U", "M"); got != nil {
+		t.Errorf("callPolicy(a/U, M): got %+v, want nil", got)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A Middleware wraps an http.Handler, typically to run some logic before
+// and/or after the wrapped handler.
+type Middleware func(http.Handler) http.Handler
+
+// An Order constrains the position of a middleware in a HandlerChain,
+// relative to another middleware. See Before and After.
+type Order struct {
+	before bool   // if true, run before name; otherwise, run after name
+	name   string // name of the other middleware
+}
+
+// Before returns an Order that runs a middleware before the middleware with
+// the provided name. A middleware that runs before another wraps it, so it
+// observes requests first and responses last.
+func Before(name string) Order {
+	return Order{before: true, name: name}
+}
+
+// After returns an Order that runs a middleware after the middleware with the
+// provided name. A middleware that runs after another is wrapped by it, so it
+// observes requests that have already been processed by the other middleware.
+func After(name string) Order {
+	return Order{before: false, name: name}
+}
+
+// A HandlerChain composes a set of named middlewares into a single
+// http.Handler. Rather than nesting middlewares by hand, every middleware is
+// added with explicit ordering constraints relative to other middlewares, and
+// the chain computes an order that satisfies them. For example:
+//
+//	var chain weaver.HandlerChain
+//	chain.Add("tracing", tracing)
+//	chain.Add("session", session, weaver.After("tracing"))
+//	chain.Add("logging", logging, weaver.After("session"))
+//	handler, err := chain.Handler(mux)
+//
+// Middlewares that are not constrained relative to one another run in the
+// order in which they were added. The zero value of a HandlerChain is an
+// empty chain.
+type HandlerChain struct {
+	links []link
+}
+
+// link is a middleware in a HandlerChain.
+type link struct {
+	name       string
+	middleware Middleware
+	orders     []Order
+}
+
+// Add adds a named middleware to the chain, subject to the provided ordering
+// constraints. Constraints may refer to middlewares that are added later.
+func (c *HandlerChain) Add(name string, middleware Middleware, orders ...Order) {
+	c.links = append(c.links, link{name: name, middleware: middleware, orders: orders})
+}
+
+// Order returns the names of the chain's middlewares in the order in which
+// they run. It returns an error if a name is duplicated, if a constraint
+// refers to an unknown middleware, or if the constraints are cyclic.
+func (c *HandlerChain) Order() ([]string, error) {
+	order, err := c.sort()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(order))
+	for i, l := range order {
+		names[i] = c.links[l].name
+	}
+	return names, nil
+}
+
+// Handler returns h wrapped in the chain's middlewares, in an order that
+// satisfies all of the chain's ordering constraints. It returns an error if
+// the constraints can't be satisfied. See Order.
+func (c *HandlerChain) Handler(h http.Handler) (http.Handler, error) {
+	order, err := c.sort()
+	if err != nil {
+		return nil, err
+	}
+	// The first middleware to run is the outermost, so wrap from the inside
+	// out.
+	for i := len(order) - 1; i >= 0; i-- {
+		h = c.links[order[i]].middleware(h)
+	}
+	return h, nil
+}
+
+// sort returns the indices of the chain's links, topologically sorted by the
+// ordering constraints. Ties are broken by insertion order.
+func (c *HandlerChain) sort() ([]int, error) {
+	index := map[string]int{}
+	for i, l := range c.links {
+		if _, ok := index[l.name]; ok {
+			return nil, fmt.Errorf("handler chain: duplicate middleware %q", l.name)
+		}
+		index[l.name] = i
+	}
+
+	// succs[i] holds the links that must run after link i.
+	succs := make([][]int, len(c.links))
+	preds := make([]int, len(c.links))
+	for i, l := range c.links {
+		for _, o := range l.orders {
+			j, ok := index[o.name]
+			if !ok {
+				return nil, fmt.Errorf("handler chain: middleware %q ordered relative to unknown middleware %q", l.name, o.name)
+			}
+			first, second := j, i
+			if o.before {
+				first, second = i, j
+			}
+			succs[first] = append(succs[first], second)
+			preds[second]++
+		}
+	}
+
+	// Repeatedly pick the earliest added link with no pending predecessors.
+	order := make([]int, 0, len(c.links))
+	done := make([]bool, len(c.links))
+	for len(order) < len(c.links) {
+		next := -1
+		for i := range c.links {
+			if !done[i] && preds[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			var cycle []string
+			for i, l := range c.links {
+				if !done[i] {
+					cycle = append(cycle, fmt.Sprintf("%q", l.name))
+				}
+			}
+			return nil, fmt.Errorf("handler chain: cyclic ordering constraints among middlewares %s", strings.Join(cycle, ", "))
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, s := range succs[next] {
+			preds[s]--
+		}
+	}
+	return order, nil
+}
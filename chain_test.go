@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder returns a middleware that appends name to the X-Order header of
+// the response before calling the wrapped handler.
+func recorder(name string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestHandlerChain(t *testing.T) {
+	var chain HandlerChain
+	chain.Add("logging", recorder("logging"), After("session"))
+	chain.Add("session", recorder("session"), After("tracing"))
+	chain.Add("metrics", recorder("metrics"))
+	chain.Add("tracing", recorder("tracing"), Before("metrics"))
+
+	want := []string{"tracing", "session", "logging", "metrics"}
+	got, err := chain.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Order (-want +got):\n%s", diff)
+	}
+
+	// Check that the composed handler runs the middlewares in order.
+	handler, err := chain.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if diff := cmp.Diff(want, w.Header().Values("X-Order")); diff != "" {
+		t.Fatalf("ServeHTTP (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandlerChainErrors(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		chain func(*HandlerChain)
+		want  string
+	}{
+		{
+			"Duplicate",
+			func(c *HandlerChain) {
+				c.Add("a", recorder("a"))
+				c.Add("a", recorder("a"))
+			},
+			"duplicate",
+		},
+		{
+			"Unknown",
+			func(c *HandlerChain) {
+				c.Add("a", recorder("a"), After("b"))
+			},
+			"unknown",
+		},
+		{
+			"Cycle",
+			func(c *HandlerChain) {
+				c.Add("a", recorder("a"), Before("b"))
+				c.Add("b", recorder("b"), Before("c"))
+				c.Add("c", recorder("c"), Before("a"))
+			},
+			"cyclic",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var chain HandlerChain
+			test.chain(&chain)
+			_, err := chain.Handler(http.NotFoundHandler())
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Handler: got error %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
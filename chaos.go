@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// chaos holds the faults that a deployer asked a weavelet to inject into its
+// calls to remote components, for chaos testing. See
+// protos.InjectFaultsRequest.
+type chaos struct {
+	mu          sync.Mutex
+	unreachable map[string]bool          // unreachable components
+	latency     map[string]time.Duration // added latency, by component
+}
+
+// set replaces the injected faults with the ones in the provided request.
+func (c *chaos) set(req *protos.InjectFaultsRequest) {
+	unreachable := map[string]bool{}
+	for _, component := range req.Unreachable {
+		unreachable[component] = true
+	}
+	latency := map[string]time.Duration{}
+	for component, micros := range req.LatencyMicros {
+		latency[component] = time.Duration(micros) * time.Microsecond
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unreachable = unreachable
+	c.latency = latency
+}
+
+// inject injects the faults, if any, into a call to the provided component,
+// and records them as events of the call's span. It returns an error if the
+// call should fail without being made.
+func (c *chaos) inject(ctx context.Context, component string) error {
+	c.mu.Lock()
+	unreachable := c.unreachable[component]
+	latency := c.latency[component]
+	c.mu.Unlock()
+
+	span := trace.SpanFromContext(ctx)
+	if unreachable {
+		span.AddEvent("chaos: partition",
+			trace.WithAttributes(attribute.String("component", component)))
+		return fmt.Errorf("%w: %s partitioned by chaos testing", call.Unreachable, component)
+	}
+	if latency <= 0 {
+		return nil
+	}
+	span.AddEvent("chaos: latency", trace.WithAttributes(
+		attribute.String("component", component),
+		attribute.String("latency", latency.String())))
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
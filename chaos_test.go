@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestStubChaos(t *testing.T) {
+	ctx := context.Background()
+	client := &countingClient{}
+	c := &chaos{}
+	s := stub{
+		component: "a/T",
+		client:    client,
+		methods:   []call.MethodKey{call.MakeMethodKey("a/T", "test")},
+		chaos:     c,
+	}
+
+	// Calls to an unreachable component fail without being made.
+	c.set(&protos.InjectFaultsRequest{Unreachable: []string{"a/T"}})
+	if _, err := s.Run(ctx, 0, nil, 0); !errors.Is(err, call.Unreachable) {
+		t.Fatalf("partitioned call: got %v, want %v", err, call.Unreachable)
+	}
+	if client.calls != 0 {
+		t.Fatalf("partitioned call: got %d remote calls, want 0", client.calls)
+	}
+
+	// Calls to a slowed component are delayed.
+	const latency = 20 * time.Millisecond
+	c.set(&protos.InjectFaultsRequest{
+		LatencyMicros: map[string]int64{"a/T": latency.Microseconds()},
+	})
+	start := time.Now()
+	if _, err := s.Run(ctx, 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Fatalf("slowed call: took %v, want at least %v", elapsed, latency)
+	}
+
+	// An empty request stops the injection of faults.
+	c.set(&protos.InjectFaultsRequest{})
+	if _, err := s.Run(ctx, 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 2 {
+		t.Fatalf("got %d remote calls, want 2", client.calls)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/cron"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// A Clock tells the time, and runs timers and tickers. Components that get
+// the time from the Clock returned by ClockOf, rather than from the time
+// package, can be tested without sleeps: weavertest replaces the clock with
+// a fake one that only moves when the test advances it, firing the timers
+// and tickers that are due. For example, a component that expires sessions
+// after an hour:
+//
+//	func (s *impl) Get(ctx context.Context, id string) (Session, error) {
+//	    session, err := s.load(ctx, id)
+//	    if err != nil {
+//	        return Session{}, err
+//	    }
+//	    if weaver.ClockOf(s).Since(session.Created) > time.Hour {
+//	        return Session{}, ErrExpired
+//	    }
+//	    return session, nil
+//	}
+//
+// can be tested by advancing the clock by an hour between two calls; see
+// weavertest.FakeClock. Outside of tests, the Clock is the system clock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// NewTimer returns a timer that sends the current time on its channel
+	// after at least duration d.
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker returns a ticker that sends the current time on its channel
+	// every d. Like a time.Ticker, it drops ticks for slow receivers. It
+	// panics if d is not positive.
+	NewTicker(d time.Duration) Ticker
+
+	// Sleep blocks for at least duration d, or until ctx is done. It returns
+	// ctx.Err() if ctx is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// A Timer is a single event created by Clock.NewTimer. See time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer
+	// has already fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after duration d. It returns true if
+	// the timer was active.
+	Reset(d time.Duration) bool
+}
+
+// A Ticker delivers ticks at intervals, created by Clock.NewTicker. See
+// time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are sent.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks are sent once Stop returns.
+	Stop()
+
+	// Reset stops the ticker and resets its period to d. The next tick
+	// arrives after d.
+	Reset(d time.Duration)
+}
+
+// ClockOf returns the clock of the process hosting requester: the system
+// clock, or the clock passed to weavertest.Options in tests.
+func ClockOf(requester Instance) Clock {
+	return requester.rep().wlet.clock
+}
+
+// systemClock is the Clock backed by the time package.
+type systemClock struct{}
+
+var _ Clock = systemClock{}
+
+func (systemClock) Now() time.Time                  { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// bootstrapClock returns the test clock of the provided bootstrap, or the
+// system clock if there is none.
+func bootstrapClock(bootstrap runtime.Bootstrap) (Clock, error) {
+	if bootstrap.TestClock == nil {
+		return systemClock{}, nil
+	}
+	clock, ok := bootstrap.TestClock.(Clock)
+	if !ok {
+		return nil, fmt.Errorf("test clock: %T is not a weaver.Clock", bootstrap.TestClock)
+	}
+	return clock, nil
+}
+
+// cronClock adapts a Clock to the cron.Clock interface.
+type cronClock struct{ Clock }
+
+func (c cronClock) NewTimer(d time.Duration) cron.Timer { return c.Clock.NewTimer(d) }
+
+// afterFunc waits for duration d on the provided clock and then calls f in
+// its own goroutine, like time.AfterFunc. Every time the returned timer is
+// reset, f is called again once it fires. Once the timer is stopped, f is
+// never called again, even if the timer is reset.
+func afterFunc(clock Clock, d time.Duration, f func()) Timer {
+	t := &funcTimer{Timer: clock.NewTimer(d), stopped: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-t.Timer.C():
+				f()
+			case <-t.stopped:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// funcTimer is a Timer returned by afterFunc.
+type funcTimer struct {
+	Timer
+	once    sync.Once
+	stopped chan struct{} // closed by Stop
+}
+
+func (t *funcTimer) Stop() bool {
+	t.once.Do(func() { close(t.stopped) })
+	return t.Timer.Stop()
+}
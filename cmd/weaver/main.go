@@ -17,26 +17,45 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/tool/aws"
+	"github.com/ServiceWeaver/weaver/internal/tool/compose"
+	"github.com/ServiceWeaver/weaver/internal/tool/config"
+	"github.com/ServiceWeaver/weaver/internal/tool/dev"
 	"github.com/ServiceWeaver/weaver/internal/tool/generate"
+	"github.com/ServiceWeaver/weaver/internal/tool/kube"
+	"github.com/ServiceWeaver/weaver/internal/tool/lint"
+	"github.com/ServiceWeaver/weaver/internal/tool/metrics"
 	"github.com/ServiceWeaver/weaver/internal/tool/multi"
+	"github.com/ServiceWeaver/weaver/internal/tool/nomad"
 	"github.com/ServiceWeaver/weaver/internal/tool/single"
 	"github.com/ServiceWeaver/weaver/internal/tool/ssh"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
+	"golang.org/x/tools/go/analysis/multichecker"
 )
 
 const usage = `USAGE
 
   weaver generate                 // weaver code generator
+  weaver lint      [packages]     // check apps for common mistakes
+  weaver dev       [args...]      // run an app, restarting it on changes
+  weaver config    <command> ...  // for checking configs
+  weaver metrics   <command> ...  // for monitoring metrics
   weaver single    <command> ...  // for single process deployments
   weaver multi     <command> ...  // for multiprocess deployments
   weaver ssh       <command> ...  // for multimachine deployments
+  weaver kube      <command> ...  // for Kubernetes deployments
+  weaver nomad     <command> ...  // for Nomad deployments
+  weaver aws       <command> ...  // for AWS ECS deployments
+  weaver compose   <command> ...  // for Docker Compose deployments
   weaver gke       <command> ...  // for GKE deployments
   weaver gke-local <command> ...  // for simulated GKE deployments
 
@@ -44,13 +63,20 @@ DESCRIPTION
 
   Use the "weaver" command to deploy and manage Weaver applications.
 
-  The "weaver generate", "weaver single", "weaver multi", and "weaver ssh"
-  subcommands are baked in, but all other subcommands of the form
+  The "weaver generate", "weaver lint", "weaver dev", "weaver config",
+  "weaver metrics", "weaver single", "weaver multi", "weaver ssh", "weaver kube",
+  "weaver nomad", "weaver aws", and "weaver compose" subcommands are baked in, but all other subcommands of the form
   "weaver <deployer>" dispatch to a binary called "weaver-<deployer>".
   "weaver gke status", for example, dispatches to "weaver-gke status".
 `
 
 func main() {
+	// "go vet -vettool=$(which weaver)" runs weaver with the flags and config
+	// files of go vet, rather than with a subcommand.
+	if isVetTool(os.Args[1:]) {
+		multichecker.Main(lint.Analyzers...)
+	}
+
 	// Parse flags.
 	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
 	flag.Parse()
@@ -61,25 +87,71 @@ func main() {
 
 	// Handle the internal deployers.
 	internals := map[string]map[string]*tool.Command{
-		"single": single.Commands,
-		"multi":  multi.Commands,
-		"ssh":    ssh.Commands,
+		"single":  single.Commands,
+		"multi":   multi.Commands,
+		"ssh":     ssh.Commands,
+		"kube":    kube.Commands,
+		"nomad":   nomad.Commands,
+		"aws":     aws.Commands,
+		"compose": compose.Commands,
+		"config":  config.Commands,
+		"metrics": metrics.Commands,
 	}
 
 	switch flag.Arg(0) {
 	case "generate":
 		generateFlags := flag.NewFlagSet("generate", flag.ExitOnError)
+		mocks := generateFlags.Bool("mocks", false, "Also generate component mocks.")
+		watch := generateFlags.Bool("watch", false, "Regenerate code whenever the packages change.")
+		grpc := generateFlags.String("grpc", "", "Comma-separated list of components to expose as gRPC services.")
+		http := generateFlags.String("http", "", "Comma-separated list of components to expose over HTTP.")
 		generateFlags.Usage = func() {
 			fmt.Fprintln(os.Stderr, generate.Usage)
 		}
 		generateFlags.Parse(flag.Args()[1:]) //nolint:errcheck // does os.Exit on error
-		if err := generate.Generate(".", flag.Args()[1:]); err != nil {
+		opt := generate.Options{Mocks: *mocks}
+		if *grpc != "" {
+			opt.GRPC = strings.Split(*grpc, ",")
+		}
+		if *http != "" {
+			opt.HTTP = strings.Split(*http, ",")
+		}
+		if *watch {
+			if err := generate.Watch(context.Background(), ".", generateFlags.Args(), opt, generate.DefaultWatchInterval, os.Stderr); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+		if err := generate.Generate(".", generateFlags.Args(), opt); err != nil {
 			fmt.Fprint(os.Stderr, err)
 			os.Exit(1)
 		}
 		return
 
-	case "single", "multi", "ssh":
+	case "lint":
+		// The analysis framework parses the flags and packages of "weaver
+		// lint", like it does for "go vet".
+		os.Args = os.Args[1:]
+		flag.Usage = func() { fmt.Fprintln(os.Stderr, lint.Usage) }
+		multichecker.Main(lint.Analyzers...)
+		return
+
+	case "dev":
+		devFlags := flag.NewFlagSet("dev", flag.ExitOnError)
+		interval := devFlags.Duration("interval", 500*time.Millisecond, "How often to check for changes.")
+		devFlags.Usage = func() {
+			fmt.Fprintln(os.Stderr, dev.Usage)
+		}
+		devFlags.Parse(flag.Args()[1:]) //nolint:errcheck // does os.Exit on error
+		opts := dev.Options{Interval: *interval}
+		if err := dev.Run(context.Background(), ".", devFlags.Args(), opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+
+	case "single", "multi", "ssh", "kube", "nomad", "aws", "compose", "config", "metrics":
 		os.Args = os.Args[1:]
 		tool.Run("weaver "+flag.Arg(0), internals[flag.Arg(0)])
 		return
@@ -94,6 +166,12 @@ func main() {
 		case n == 2 && command == "generate":
 			// weaver help generate
 			fmt.Fprintln(os.Stdout, generate.Usage)
+		case n == 2 && command == "lint":
+			// weaver help lint
+			fmt.Fprintln(os.Stdout, lint.Usage)
+		case n == 2 && command == "dev":
+			// weaver help dev
+			fmt.Fprintln(os.Stdout, dev.Usage)
 		case n == 2 && internals[command] != nil:
 			// weaver help <command>
 			fmt.Fprintln(os.Stdout, tool.MainHelp("weaver "+command, internals[command]))
@@ -118,6 +196,16 @@ func main() {
 	}
 }
 
+// isVetTool returns whether the provided arguments are those of an invocation
+// by "go vet -vettool", which queries the version and flags of the tool, and
+// then runs it on the config file of every package.
+func isVetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return args[0] == "-V=full" || args[0] == "-flags" || strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
 // run runs "weaver-<deployer> [arg]..." in a subprocess and returns the
 // subprocess' exit code and any error.
 func run(deployer string, args []string) (int, error) {
@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import "github.com/ServiceWeaver/weaver/runtime/codegen"
+
+// A Codec encodes the arguments and results of the remote calls to the
+// methods of a component, in place of the encoding generated by "weaver
+// generate", e.g., to use msgpack, or a hand-written encoding for a hot type.
+// Marshal is passed the arguments of a call, excluding the initial context,
+// or its results, excluding the final error. Unmarshal is passed pointers to
+// the values to decode, of the same types and in the same order:
+//
+//	type jsonCodec struct{}
+//
+//	func (jsonCodec) Marshal(values []any) ([]byte, error) {
+//	    return json.Marshal(values)
+//	}
+//
+//	func (jsonCodec) Unmarshal(data []byte, ptrs []any) error {
+//	    var raw []json.RawMessage
+//	    if err := json.Unmarshal(data, &raw); err != nil {
+//	        return err
+//	    }
+//	    for i, ptr := range ptrs {
+//	        if err := json.Unmarshal(raw[i], ptr); err != nil {
+//	            return err
+//	        }
+//	    }
+//	    return nil
+//	}
+//
+// Errors returned by component methods, and the values streamed to and from
+// streaming methods, are always encoded by Service Weaver. A Codec must be
+// safe for concurrent use.
+type Codec = codegen.Codec
+
+// RegisterCodec registers a codec under the provided name. The codec of a
+// component is selected by name in the config:
+//
+//	[serviceweaver.codecs]
+//	"github.com/my/project/catalog/T" = "json"
+//
+// Every process must register the same codecs, before Init, typically in
+// main or an init function. RegisterCodec panics if the name is empty or
+// already taken.
+func RegisterCodec(name string, codec Codec) {
+	codegen.RegisterCodec(name, codec)
+}
@@ -15,10 +15,18 @@
 package weaver
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"net"
+	"net/url"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
 	"github.com/ServiceWeaver/weaver/internal/register"
+	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
@@ -95,7 +103,10 @@ type component struct {
 	implErr  error          // non-nil if impl creation fails
 	impl     *componentImpl // only ever non-nil if this component is local
 	logger   *slog.Logger   // read-only after implInit.Do()
+	logLevel slog.LevelVar  // minimum level of the entries logged by logger
 	tracer   trace.Tracer   // read-only after implInit.Do()
+	config   reconfigurer   // impl, if it has a config; guarded by wlet.configMu
+	ready    readiness      // is the local component ready? see warmer
 
 	stubInit sync.Once      // used to initialize stub
 	stubErr  error          // non-nil if stub creation fails
@@ -134,6 +145,65 @@ type Implements[T any] struct {
 // setInstance is used during component initialization to fill Implements.component.
 func (i *Implements[T]) setInstance(c *componentImpl) { i.componentImpl = c }
 
+// Ref[T] is a field that can be placed inside a component implementation
+// struct. T must be a component type. Service Weaver automatically fills every
+// Ref field with a handle to the corresponding component before calling the
+// component's Init method, so there is no need to call [weaver.Get]. E.g.,
+//
+//	type checkout struct {
+//		weaver.Implements[Checkout]
+//		cart weaver.Ref[Cart]
+//	}
+//
+//	func (c *checkout) PlaceOrder(ctx context.Context, user string) error {
+//		items, err := c.cart.Get().Items(ctx, user)
+//		...
+//	}
+//
+// Ref fields may be exported or unexported, but they must be direct fields of
+// the implementation struct. Use [weaver.Fill] to fill the Ref fields of
+// other structs, e.g., a server struct created in main.
+type Ref[T any] struct {
+	value T
+}
+
+// Get returns a handle to the component of type T.
+func (r Ref[T]) Get() T { return r.value }
+
+// refType returns the component type of the Ref.
+func (r *Ref[T]) refType() reflect.Type { return reflect.TypeOf(&r.value).Elem() }
+
+// setRef is used during component initialization to fill the Ref.
+func (r *Ref[T]) setRef(value any) { r.value = value.(T) }
+
+// fillRefs fills the Ref fields of the struct pointed to by obj, using get to
+// get the component of every Ref.
+func fillRefs(obj any, get func(reflect.Type) (any, error)) error {
+	p := reflect.ValueOf(obj)
+	if p.Kind() != reflect.Pointer || p.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", obj)
+	}
+	s := p.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		// Use the field's address, rather than reflect.Value.Set, so that
+		// unexported fields can be filled too.
+		f := s.Field(i)
+		ref, ok := reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Interface().(interface {
+			refType() reflect.Type
+			setRef(any)
+		})
+		if !ok {
+			continue
+		}
+		value, err := get(ref.refType())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", s.Type().Field(i).Name, err)
+		}
+		ref.setRef(value)
+	}
+	return nil
+}
+
 // A Listener is Service Weaver's implementation of a net.Listener.
 //
 // A Listener implements the net.Listener interface, so you can use a Listener
@@ -151,19 +221,46 @@ func (i *Implements[T]) setInstance(c *componentImpl) { i.componentImpl = c }
 //	    http.HandleFunc("/c", func(http.ResponseWriter, *http.Request) {...})
 //	    http.Serve(lis, nil)
 //	}
+//
+// A Listener has two addresses. Addr returns the local address the listener
+// is bound to. AdvertisedAddr returns the address clients should use to reach
+// the listener, which is assigned by the deployer and is often different:
+//
+//   - In a single process deployment, clients connect to the listener
+//     directly, so the advertised address is the local address (with
+//     "localhost" in place of an unspecified host like "[::]").
+//   - In multiprocess deployments (e.g., "weaver multi" and weavertest),
+//     every replica of a listener listens on its own local address, and
+//     the deployer runs a proxy that balances traffic across them. The
+//     advertised address is the address of the proxy.
+//   - In cloud deployments, traffic typically reaches the listener through a
+//     load balancer. The advertised address is the address of the load
+//     balancer, and the load balancer may terminate TLS.
+//
+// Use URL to construct absolute URLs that refer back to the listener, e.g.,
+// in redirects. URL uses the "https" scheme when the deployer reports that
+// clients connect with TLS.
 type Listener struct {
-	net.Listener        // underlying listener
-	proxyAddr    string // address of proxy that forwards to the listener
+	net.Listener          // underlying listener
+	proxyAddr      string // address of proxy that forwards to the listener
+	advertisedAddr string // address assigned by the deployer, if any
+	tls            bool   // do clients connect to advertisedAddr with TLS?
+	start          func() // if not nil, runs the start hooks; see OnStart
+}
+
+// Accept waits for the hooks registered with OnStart to finish, and then
+// waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	if l.start != nil {
+		l.start()
+	}
+	return l.Listener.Accept()
 }
 
 // String returns the address clients should dial to connect to the
-// listener; this will be the proxy address if available, otherwise
-// the <host>:<port> for this listener.
+// listener. It is equivalent to AdvertisedAddr.
 func (l *Listener) String() string {
-	if l.proxyAddr != "" {
-		return l.proxyAddr
-	}
-	return l.Addr().String()
+	return l.AdvertisedAddr()
 }
 
 // ProxyAddr returns the dialable address of the proxy that forwards traffic to
@@ -172,6 +269,45 @@ func (l *Listener) ProxyAddr() string {
 	return l.proxyAddr
 }
 
+// AdvertisedAddr returns the <host>:<port> address clients should dial to
+// connect to the listener. This is the address assigned by the deployer if
+// there is one, otherwise the proxy address if there is a proxy, otherwise
+// the local address of the listener.
+func (l *Listener) AdvertisedAddr() string {
+	if l.advertisedAddr != "" {
+		return l.advertisedAddr
+	}
+	if l.proxyAddr != "" {
+		return dialableAddr(l.proxyAddr)
+	}
+	return dialableAddr(l.Addr().String())
+}
+
+// URL returns the base URL clients should use to reach the listener, e.g.,
+// "http://localhost:12345" or "https://shop.example.com". The scheme is
+// "https" if clients connect to the listener using TLS, and "http" otherwise.
+// The returned URL can be modified by the caller.
+func (l *Listener) URL() *url.URL {
+	scheme := "http"
+	if l.tls {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: l.AdvertisedAddr()}
+}
+
+// dialableAddr returns the provided <host>:<port> address, with an empty or
+// unspecified host (e.g., "0.0.0.0" or "::") replaced by localhost.
+func dialableAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return addr
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
 func (c *componentImpl) rep() *component { return c.component }
 
 // Logger returns a logger that associates its log entries with this component.
@@ -189,7 +325,11 @@ type ListenerOptions struct {
 	// deployments).
 	//
 	// The value must have the form :port or host:port, or it may
-	// be the empty string, which is treated as ":0".
+	// be the empty string, which is treated as ":0". Alternatively, it may
+	// be unix:///path/to/socket, to listen on a Unix domain socket, or
+	// systemd:name, to listen on the socket named name passed to the
+	// process by systemd socket activation (see sd_listen_fds(3)).
+	// "systemd" alone refers to the only socket passed.
 	//
 	// If the port number is zero, an unused port number is picked.
 	//
@@ -198,13 +338,40 @@ type ListenerOptions struct {
 	//
 	// Examples:
 	//
-	//	""                System picked port on all available addresses
-	//	:0                System picked port on all available addresses
-	//	:1234             Port 1234 on all available addresses
-	//	localhost:1234    Port 1234 on loopback address
-	//	example.com:1234  Port 1234 on external addresses for host
+	//	""                  System picked port on all available addresses
+	//	:0                  System picked port on all available addresses
+	//	:1234               Port 1234 on all available addresses
+	//	localhost:1234      Port 1234 on loopback address
+	//	example.com:1234    Port 1234 on external addresses for host
+	//	unix:///run/a.sock  Unix domain socket /run/a.sock
+	//	systemd:http        Socket named "http" passed by systemd
 	LocalAddress string
 
+	// If CertFile and KeyFile are not empty, the listener serves TLS, using
+	// the PEM encoded certificate and private key in the named files. The
+	// files are checked for changes every few seconds, and a new certificate
+	// is served as soon as it is written, so certificates can be renewed
+	// without restarting the application.
+	CertFile string
+	KeyFile  string
+
+	// If not nil, the listener serves TLS configured by TLSConfig. This can
+	// be used to obtain certificates from Let's Encrypt using the
+	// golang.org/x/crypto/acme/autocert package:
+	//
+	//	m := &autocert.Manager{
+	//	    Prompt:     autocert.AcceptTOS,
+	//	    HostPolicy: autocert.HostWhitelist("shop.example.com"),
+	//	    Cache:      autocert.DirCache("/var/cache/certs"),
+	//	}
+	//	lis, err := root.Listener("shop", weaver.ListenerOptions{
+	//	    LocalAddress: ":443",
+	//	    TLSConfig:    m.TLSConfig(),
+	//	})
+	//
+	// TLSConfig must not be specified together with CertFile and KeyFile.
+	TLSConfig *tls.Config
+
 	// dummy field to force users to use explicit field names
 	useNamedFieldInitialization struct{} //nolint:unused
 }
@@ -324,8 +491,19 @@ func (AutoMarshal) WeaverUnmarshal(dec *codegen.Decoder) {}
 //
 //	["example.com/mypkg/MyComponent"]
 //	Size = 1000
+//
+// The config of a component may change while the application runs, when a
+// new config is applied to the deployment, e.g., with "weaver multi config
+// apply". Components that cache values derived from their config can
+// register a function with OnConfigChange to be notified of changes.
 type WithConfig[T any] struct {
-	config T
+	config  T
+	current atomic.Pointer[T] // config applied to the running deployment, if any
+
+	mu          sync.Mutex                               // guards the following
+	subscribers []func(ctx context.Context, old, new *T) // registered with OnConfigChange
+	notified    *T                                       // config the subscribers last saw, if not the initial one
+	notifying   bool                                     // is a goroutine notifying the subscribers?
 }
 
 // Config returns the configuration information for the component that
@@ -336,6 +514,85 @@ type WithConfig[T any] struct {
 //
 // Any fields in the application config file that are not present in T
 // will be flagged as an error at application startup.
+//
+// When a new config is applied to a running deployment, later calls to Config
+// return the new config, while the configs returned by earlier calls stay
+// unchanged. The returned config must not be modified.
 func (wc *WithConfig[T]) Config() *T {
+	if config := wc.current.Load(); config != nil {
+		return config
+	}
 	return &wc.config
 }
+
+// OnConfigChange registers a function that is called whenever a new config
+// for the component is applied to the running deployment, with the previous
+// config and the new one. The functions are called one at a time, in the
+// background, after Config starts returning the new config. If the config
+// changes several times while the functions run, they are called once more,
+// with the latest config.
+//
+// OnConfigChange is typically called in Init. For example:
+//
+//	func (c *cache) Init(context.Context) error {
+//	    c.OnConfigChange(func(ctx context.Context, old, new *cacheConfig) {
+//	        if new.Size != old.Size {
+//	            c.resize(new.Size)
+//	        }
+//	    })
+//	    return nil
+//	}
+func (wc *WithConfig[T]) OnConfigChange(fn func(ctx context.Context, old, new *T)) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.subscribers = append(wc.subscribers, fn)
+}
+
+// reconfigure parses the config section for the named component into a new
+// config, makes it the config returned by Config, and notifies the
+// subscribers registered with OnConfigChange.
+func (wc *WithConfig[T]) reconfigure(ctx context.Context, name string, sections map[string]string) error {
+	config := new(T)
+	if err := runtime.ParseConfigSection(name, "", sections, config); err != nil {
+		return err
+	}
+	wc.current.Store(config)
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if len(wc.subscribers) > 0 && !wc.notifying {
+		wc.notifying = true
+		go wc.notify(ctx)
+	}
+	return nil
+}
+
+// notify notifies the subscribers of the latest config, until they have seen
+// it.
+func (wc *WithConfig[T]) notify(ctx context.Context) {
+	for {
+		wc.mu.Lock()
+		old, config := wc.notified, wc.Config()
+		if old == nil {
+			old = &wc.config
+		}
+		if old == config {
+			wc.notifying = false
+			wc.mu.Unlock()
+			return
+		}
+		wc.notified = config
+		subscribers := append([]func(context.Context, *T, *T){}, wc.subscribers...)
+		wc.mu.Unlock()
+
+		for _, fn := range subscribers {
+			fn(ctx, old, config)
+		}
+	}
+}
+
+// reconfigurer is implemented by the component implementations that embed
+// weaver.WithConfig. See WithConfig.reconfigure.
+type reconfigurer interface {
+	reconfigure(ctx context.Context, name string, sections map[string]string) error
+}
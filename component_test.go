@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+func TestListenerAdvertisedAddr(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+	local := net.JoinHostPort("localhost", strconv.Itoa(port))
+
+	for _, test := range []struct {
+		name    string
+		lis     *Listener
+		wantURL string
+	}{
+		{"Local", &Listener{Listener: l}, "http://" + local},
+		{"Proxy", &Listener{Listener: l, proxyAddr: "[::]:8000"}, "http://localhost:8000"},
+		{"Advertised", &Listener{Listener: l, proxyAddr: "10.0.0.1:8000", advertisedAddr: "shop.example.com"}, "http://shop.example.com"},
+		{"TLS", &Listener{Listener: l, advertisedAddr: "shop.example.com", tls: true}, "https://shop.example.com"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.lis.URL().String(); got != test.wantURL {
+				t.Fatalf("URL: got %q, want %q", got, test.wantURL)
+			}
+			if got, want := test.lis.Addr(), l.Addr(); got != want {
+				t.Fatalf("Addr: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDialableAddr(t *testing.T) {
+	for _, test := range []struct{ addr, want string }{
+		{":80", "localhost:80"},
+		{"0.0.0.0:80", "localhost:80"},
+		{"[::]:80", "localhost:80"},
+		{"127.0.0.1:80", "127.0.0.1:80"},
+		{"example.com:80", "example.com:80"},
+		{"garbage", "garbage"},
+	} {
+		if got := dialableAddr(test.addr); got != test.want {
+			t.Errorf("dialableAddr(%q): got %q, want %q", test.addr, got, test.want)
+		}
+	}
+}
+
+type refA interface{ A() }
+type refB interface{ B() }
+
+type refImpl struct{ name string }
+
+func (refImpl) A() {}
+func (refImpl) B() {}
+
+func TestFillRefs(t *testing.T) {
+	type refs struct {
+		A        Ref[refA]
+		b        Ref[refB]
+		notARef  refA
+		Embedded struct{ c Ref[refA] }
+	}
+	get := func(t reflect.Type) (any, error) {
+		return refImpl{name: t.Name()}, nil
+	}
+
+	var r refs
+	if err := fillRefs(&r, get); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.A.Get(), (refImpl{name: "refA"}); got != want {
+		t.Errorf("A: got %v, want %v", got, want)
+	}
+	if got, want := r.b.Get(), (refImpl{name: "refB"}); got != want {
+		t.Errorf("b: got %v, want %v", got, want)
+	}
+	if r.notARef != nil {
+		t.Errorf("notARef: got %v, want nil", r.notARef)
+	}
+	if got := r.Embedded.c.Get(); got != nil {
+		t.Errorf("Embedded.c: got %v, want nil", got)
+	}
+}
+
+func TestFillRefsErrors(t *testing.T) {
+	errNotFound := errors.New("not found")
+	get := func(t reflect.Type) (any, error) {
+		return nil, fmt.Errorf("%v: %w", t, errNotFound)
+	}
+	var r struct{ A Ref[refA] }
+	if err := fillRefs(&r, get); !errors.Is(err, errNotFound) {
+		t.Errorf("fillRefs: got %v, want %v", err, errNotFound)
+	}
+	if err := fillRefs(r, get); err == nil {
+		t.Errorf("fillRefs(non-pointer): unexpected success")
+	}
+}
+
+func TestWithConfigReconfigure(t *testing.T) {
+	type config struct {
+		Size int
+		TTL  string
+	}
+	const name = "example.com/cache/Cache"
+	var wc WithConfig[config]
+	if err := runtime.ParseConfigSection(name, "", map[string]string{name: "Size = 10\n"}, wc.Config()); err != nil {
+		t.Fatal(err)
+	}
+	initial := wc.Config()
+
+	type change struct{ old, new config }
+	changes := make(chan change, 10)
+	wc.OnConfigChange(func(_ context.Context, old, new *config) {
+		changes <- change{*old, *new}
+	})
+	next := func() change {
+		t.Helper()
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("no config change notification")
+			return change{}
+		}
+	}
+
+	// Apply a new config.
+	ctx := context.Background()
+	if err := wc.reconfigure(ctx, name, map[string]string{name: "Size = 20\nTTL = \"1m\"\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *wc.Config(), (config{Size: 20, TTL: "1m"}); got != want {
+		t.Fatalf("Config: got %v, want %v", got, want)
+	}
+	if got, want := *initial, (config{Size: 10}); got != want {
+		t.Fatalf("initial Config changed: got %v, want %v", got, want)
+	}
+	if got, want := next(), (change{config{Size: 10}, config{Size: 20, TTL: "1m"}}); got != want {
+		t.Fatalf("change: got %v, want %v", got, want)
+	}
+
+	// Removing the section resets the config. Invalid configs are rejected,
+	// and change nothing.
+	if err := wc.reconfigure(ctx, name, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := next(), (change{config{Size: 20, TTL: "1m"}, config{}}); got != want {
+		t.Fatalf("change: got %v, want %v", got, want)
+	}
+	if err := wc.reconfigure(ctx, name, map[string]string{name: "Color = \"red\"\n"}); err == nil {
+		t.Fatal("unexpected success applying an unknown key")
+	}
+	if got, want := *wc.Config(), (config{}); got != want {
+		t.Fatalf("Config: got %v, want %v", got, want)
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change notification %v", c)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/compat"
+	"github.com/ServiceWeaver/weaver/internal/grafana"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkConfigAndExit checks the config in the file named by
+// SERVICEWEAVER_CONFIG against the provided components, writes the problems
+// it finds to the provided report file, and exits. It is run, rather than
+// the application, when the binary is run by "weaver config check".
+func checkConfigAndExit(report string, regs []*codegen.Registration) {
+	var problems []string
+	file := os.Getenv("SERVICEWEAVER_CONFIG")
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		problems = []string{fmt.Sprintf("config file: %v", err)}
+	} else {
+		problems = checkConfig(file, string(contents), regs)
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+	data, err := json.Marshal(problems)
+	if err == nil {
+		err = os.WriteFile(report, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing config check report: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// listComponentsAndExit writes the names of the provided components to the
+// provided file, as a JSON array, and exits. It is run, rather than the
+// application, when a deployer previews a deployment of the binary.
+func listComponentsAndExit(file string, regs []*codegen.Registration) {
+	names := make([]string, len(regs))
+	for i, reg := range regs {
+		names[i] = reg.Name
+	}
+	sort.Strings(names)
+	data, err := json.Marshal(names)
+	if err == nil {
+		err = os.WriteFile(file, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing component list: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// describeComponentsAndExit writes the schemas of the provided components to
+// the provided file, as a binary encoded status.Schema, and exits. It is
+// run, rather than the application, when a deployer checks that the binary
+// is compatible with the running version of the application.
+func describeComponentsAndExit(file string, regs []*codegen.Registration) {
+	data, err := proto.Marshal(compat.Describe(regs))
+	if err == nil {
+		err = os.WriteFile(file, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing component schemas: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// describeMetricsAndExit writes the provided components, and the definitions
+// of the metrics registered by the binary, to the provided file, as a JSON
+// encoded grafana.App, and exits. It is run, rather than the application,
+// when the binary is run by "weaver metrics".
+func describeMetricsAndExit(file string, regs []*codegen.Registration) {
+	data, err := json.Marshal(describeMetrics(regs))
+	if err == nil {
+		err = os.WriteFile(file, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing metric definitions: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// describeMetrics returns the provided components, sorted by name, and the
+// definitions of the metrics registered by the binary.
+func describeMetrics(regs []*codegen.Registration) grafana.App {
+	app := grafana.App{Metrics: metrics.Defs()}
+	for _, reg := range regs {
+		c := grafana.Component{Name: reg.Name, Methods: []string{}}
+		for i := 0; i < reg.Iface.NumMethod(); i++ {
+			c.Methods = append(c.Methods, reg.Iface.Method(i).Name)
+		}
+		app.Components = append(app.Components, c)
+	}
+	sort.Slice(app.Components, func(i, j int) bool {
+		return app.Components[i].Name < app.Components[j].Name
+	})
+	return app
+}
+
+// checkConfig checks the provided config against the provided components,
+// and returns the problems it finds, sorted: references to components that
+// don't exist, component config sections that don't match the component's
+// config struct, and per-method config for methods that don't exist.
+func checkConfig(file, contents string, regs []*codegen.Registration) []string {
+	byName := map[string]*codegen.Registration{}
+	for _, reg := range regs {
+		byName[reg.Name] = reg
+	}
+
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	known := func(what, name string) {
+		if _, ok := byName[name]; !ok {
+			report("%s: unknown component %q", what, name)
+		}
+	}
+
+	// Check the component config sections.
+	validator := func(key, val string) error {
+		reg, ok := byName[key]
+		if !ok {
+			if runtime.IsComponentSection(key) {
+				report("[%s]: unknown component", key)
+			}
+			return nil
+		}
+		if reg.ConfigFn == nil {
+			report("[%s]: component does not support configuration (it has no weaver.WithConfig field)", key)
+			return nil
+		}
+		config := reg.ConfigFn(reg.New())
+		if err := runtime.ParseConfigSection(key, "", map[string]string{key: val}, config); err != nil {
+			report("[%s]: config does not match %T: %v", key, config, err)
+		}
+		return nil
+	}
+	app, err := runtime.ParseConfig(file, contents, validator)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	config, err := runtime.ParseWeaveletConfig(app.Sections)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	// Check the component names in the [serviceweaver] section.
+	for _, group := range app.Colocate {
+		for _, name := range group.Components {
+			known("colocate", name)
+		}
+	}
+	for name := range config.Components {
+		known("components", name)
+	}
+	for name := range config.Resources {
+		known("resources", name)
+	}
+	for _, separate := range config.Separate {
+		for _, name := range separate {
+			known("separate", name)
+		}
+	}
+	for name := range config.Autoscaling {
+		known("autoscaling", name)
+	}
+	for name := range config.Processes {
+		known("process", name)
+	}
+	for name := range config.Breakers {
+		known("breakers", name)
+	}
+	for name := range config.Admission {
+		known("admission", name)
+	}
+	for name := range config.Startup {
+		known("startup", name)
+	}
+	for name := range config.Priorities {
+		known("priorities", name)
+	}
+	for name := range config.Federation.Exports {
+		known("federation exports", name)
+	}
+	for name := range config.Imports {
+		known("imports", name)
+	}
+	for _, name := range config.Recording.Components {
+		known("recording", name)
+	}
+	for name := range config.Tracing.Components {
+		known("tracing", name)
+	}
+	for name := range config.Routing {
+		if reg, ok := byName[name]; !ok {
+			known("routing", name)
+		} else if !reg.Routed {
+			report("routing: component %q is not routed (it has no weaver.WithRouter field)", name)
+		}
+	}
+	for name := range config.Methods {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			report("methods: %q is not of the form <component>.<method>", name)
+			continue
+		}
+		component, method := name[:i], name[i+1:]
+		reg, ok := byName[component]
+		if !ok {
+			known("methods", component)
+			continue
+		}
+		if _, ok := reg.Iface.MethodByName(method); !ok {
+			report("methods: component %q has no method %q", component, method)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
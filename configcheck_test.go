@@ -0,0 +1,208 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/grafana"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/google/go-cmp/cmp"
+)
+
+type checkedConfig struct {
+	Address string
+	Timeout time.Duration
+}
+
+type checkedIface interface {
+	Get(context.Context) error
+}
+
+type checkedImpl struct {
+	config checkedConfig
+}
+
+// checkedComponents returns the components that configs are checked against
+// in TestCheckConfig.
+func checkedComponents() []*codegen.Registration {
+	iface := reflect.TypeOf((*checkedIface)(nil)).Elem()
+	return []*codegen.Registration{
+		{
+			Name:     "a/Configured",
+			Iface:    iface,
+			New:      func() any { return &checkedImpl{} },
+			ConfigFn: func(impl any) any { return &impl.(*checkedImpl).config },
+		},
+		{Name: "a/Plain", Iface: iface, New: func() any { return &checkedImpl{} }},
+		{Name: "a/Routed", Iface: iface, New: func() any { return &checkedImpl{} }, Routed: true},
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+[serviceweaver]
+colocate = [["a/Configured", "a/Plain"]]
+
+[serviceweaver.breakers."a/Plain"]
+consecutive_failures = 3
+
+[serviceweaver.routing."a/Routed"]
+strategy = "consistent_hash"
+
+[serviceweaver.methods."a/Plain.Get"]
+timeout = "1s"
+
+["a/Configured"]
+Address = "localhost:8000"
+Timeout = "1s"
+
+[kube]
+image = "my-app"
+`,
+		},
+		{
+			name: "unknown components",
+			config: `
+[serviceweaver]
+colocate = [["a/Configured", "a/Missing"]]
+separate = [["a/Plain", "a/Stray"]]
+
+[serviceweaver.components."a/Typo"]
+timeout = "1s"
+
+[serviceweaver.startup."a/Lazy"]
+lazy = true
+
+[serviceweaver.process."a/Ghost"]
+env = ["PLATFORM=gcp"]
+
+[serviceweaver.recording]
+dir = "/tmp"
+components = ["a/Plain", "a/Gone"]
+
+[serviceweaver.tracing.components."a/Noisy"]
+sampler = "never"
+
+["a/Other"]
+Address = "localhost:8000"
+`,
+			want: []string{
+				`[a/Other]: unknown component`,
+				`colocate: unknown component "a/Missing"`,
+				`components: unknown component "a/Typo"`,
+				`process: unknown component "a/Ghost"`,
+				`recording: unknown component "a/Gone"`,
+				`separate: unknown component "a/Stray"`,
+				`startup: unknown component "a/Lazy"`,
+				`tracing: unknown component "a/Noisy"`,
+			},
+		},
+		{
+			name: "bad component config",
+			config: `
+["a/Configured"]
+Address = 8000
+Timeout = "1s"
+
+["a/Plain"]
+Address = "localhost:8000"
+`,
+			want: []string{
+				`[a/Configured]: config does not match *weaver.checkedConfig: toml: line 1 (last key "Address"): incompatible types: TOML value has type int64; destination has type string`,
+				`[a/Plain]: component does not support configuration (it has no weaver.WithConfig field)`,
+			},
+		},
+		{
+			name: "unknown config key",
+			config: `
+["a/Configured"]
+Adress = "localhost:8000"
+`,
+			want: []string{
+				`[a/Configured]: config does not match *weaver.checkedConfig: section "a/Configured" has unknown keys [Adress]`,
+			},
+		},
+		{
+			name: "bad methods and routing",
+			config: `
+[serviceweaver.methods."a/Plain.Put"]
+timeout = "1s"
+
+[serviceweaver.methods."a/Missing.Get"]
+timeout = "1s"
+
+[serviceweaver.routing."a/Plain"]
+strategy = "consistent_hash"
+`,
+			want: []string{
+				`methods: component "a/Plain" has no method "Put"`,
+				`methods: unknown component "a/Missing"`,
+				`routing: component "a/Plain" is not routed (it has no weaver.WithRouter field)`,
+			},
+		},
+		{
+			name: "invalid app section",
+			config: `
+[serviceweaver]
+max_drain_time = "-1s"
+`,
+			want: []string{`section "serviceweaver": negative max_drain_time -1s`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := checkConfig("weaver.toml", test.config, checkedComponents())
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("checkConfig (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDescribeMetrics(t *testing.T) {
+	regs := checkedComponents()
+	regs[0], regs[2] = regs[2], regs[0]
+	app := describeMetrics(regs)
+	want := []grafana.Component{
+		{Name: "a/Configured", Methods: []string{"Get"}},
+		{Name: "a/Plain", Methods: []string{"Get"}},
+		{Name: "a/Routed", Methods: []string{"Get"}},
+	}
+	if diff := cmp.Diff(want, app.Components); diff != "" {
+		t.Fatalf("components (-want +got):\n%s", diff)
+	}
+
+	// The metrics maintained for every component method are defined, even if
+	// no method has been called.
+	defined := map[string]bool{}
+	for _, def := range app.Metrics {
+		defined[def.Name] = true
+	}
+	for _, name := range []string{"serviceweaver_remote_method_count", "serviceweaver_http_request_count"} {
+		if !defined[name] {
+			t.Errorf("metric %q not defined", name)
+		}
+	}
+}
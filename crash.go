@@ -0,0 +1,112 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// maxCrashArgsBytes is the number of bytes of the serialized arguments of a
+// call that are included in the report of a panic serving the call.
+const maxCrashArgsBytes = 64
+
+// fatalFlushTimeout bounds how long a weavelet that exits because of a fatal
+// error waits for its logs to be exported.
+const fatalFlushTimeout = 5 * time.Second
+
+// crashReporter reports the panics of the component methods that serve
+// remote calls to the deployer, right before they crash the weavelet, so that
+// the deployer can attribute the crash to a component method and a call. A
+// panic in a component called locally is attributed to the method serving the
+// remote call that led to it, if any.
+type crashReporter struct {
+	send   func(*protos.CrashReport) error // sends a report to the deployer
+	logger *slog.Logger
+}
+
+// catch, when deferred by the handler of a remote call to the provided
+// component method, reports a panic of the method, if any, and resumes the
+// panic. args are the serialized arguments of the call.
+func (c *crashReporter) catch(ctx context.Context, component, method string, args []byte) {
+	x := recover()
+	if x == nil {
+		return
+	}
+	// The frames of the panicking function are still on the stack, so the
+	// stack trace shows where the method panicked.
+	report := &protos.CrashReport{
+		Component:  component,
+		Method:     method,
+		Panic:      fmt.Sprint(x),
+		Stack:      string(debug.Stack()),
+		Args:       argsSummary(args),
+		TimeMicros: time.Now().UnixMicro(),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		report.TraceId = sc.TraceID().String()
+	}
+	if err := c.send(report); err != nil {
+		c.logger.Error("cannot send crash report", err, "component", component, "method", method)
+	}
+	panic(x)
+}
+
+// fatal reports a fatal error of the provided component to the deployer.
+// Unlike catch, it doesn't include a stack trace: the error is expected, and
+// the stack of the caller says nothing about its cause.
+func (c *crashReporter) fatal(component, method string, err error) {
+	report := &protos.CrashReport{
+		Component:  component,
+		Method:     method,
+		Panic:      err.Error(),
+		TimeMicros: time.Now().UnixMicro(),
+	}
+	if err := c.send(report); err != nil {
+		c.logger.Error("cannot send crash report", err, "component", component, "method", method)
+	}
+}
+
+// fatal reports a fatal error of the provided component to the deployer,
+// flushes the weavelet's logs, and exits the process with a non-zero exit
+// code. The error is also written to the standard error of the process, in
+// case the deployer doesn't keep crash reports.
+func (w *weavelet) fatal(component, method string, err error) {
+	w.crashes.fatal(component, method, err)
+	ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+	defer cancel()
+	if err := w.logs.close(ctx); err != nil {
+		w.env.SystemLogger().Error("close log exporters", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", method, err)
+	os.Exit(1)
+}
+
+// argsSummary returns a summary of the provided serialized arguments: their
+// size, and a hex dump of their first maxCrashArgsBytes bytes.
+func argsSummary(args []byte) string {
+	if len(args) <= maxCrashArgsBytes {
+		return fmt.Sprintf("%d bytes: %s", len(args), hex.EncodeToString(args))
+	}
+	return fmt.Sprintf("%d bytes: %s...", len(args), hex.EncodeToString(args[:maxCrashArgsBytes]))
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// crashingMethod serves a call to a method that panics with the provided
+// value, reporting the panic to the provided reporter.
+func crashingMethod(ctx context.Context, c *crashReporter, args []byte, x any) {
+	defer c.catch(ctx, "app/Cache", "Get", args)
+	panic(x)
+}
+
+func TestCrashReporter(t *testing.T) {
+	var reports []*protos.CrashReport
+	c := &crashReporter{
+		send: func(report *protos.CrashReport) error {
+			reports = append(reports, report)
+			return nil
+		},
+		logger: slog.Default(),
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1, 2, 3},
+		SpanID:  trace.SpanID{4, 5, 6},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	// The panic is resumed after it is reported.
+	func() {
+		defer func() {
+			if x := recover(); x != "oops" {
+				t.Errorf("recovered %v, want oops", x)
+			}
+		}()
+		crashingMethod(ctx, c, []byte{0xca, 0xfe}, "oops")
+	}()
+
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	report := reports[0]
+	if report.Component != "app/Cache" || report.Method != "Get" {
+		t.Errorf("method: got %s.%s, want app/Cache.Get", report.Component, report.Method)
+	}
+	if report.Panic != "oops" {
+		t.Errorf("panic: got %q, want %q", report.Panic, "oops")
+	}
+	if want := "2 bytes: cafe"; report.Args != want {
+		t.Errorf("args: got %q, want %q", report.Args, want)
+	}
+	if want := sc.TraceID().String(); report.TraceId != want {
+		t.Errorf("trace id: got %q, want %q", report.TraceId, want)
+	}
+	if report.TimeMicros == 0 {
+		t.Error("time: got 0")
+	}
+	// The stack shows the panicking function.
+	if !strings.Contains(report.Stack, "crashingMethod") {
+		t.Errorf("stack doesn't contain crashingMethod:\n%s", report.Stack)
+	}
+}
+
+func TestCrashReporterNoPanic(t *testing.T) {
+	c := &crashReporter{
+		send: func(*protos.CrashReport) error {
+			t.Fatal("unexpected crash report")
+			return nil
+		},
+		logger: slog.Default(),
+	}
+	func() {
+		defer c.catch(context.Background(), "app/Cache", "Get", nil)
+	}()
+}
+
+func TestArgsSummary(t *testing.T) {
+	long := make([]byte, maxCrashArgsBytes+1)
+	for _, test := range []struct {
+		args []byte
+		want string
+	}{
+		{nil, "0 bytes: "},
+		{[]byte("hi"), "2 bytes: 6869"},
+		{long, "65 bytes: " + strings.Repeat("00", maxCrashArgsBytes) + "..."},
+	} {
+		if got := argsSummary(test.args); got != test.want {
+			t.Errorf("argsSummary(%x): got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/internal/cron"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+type jobLabels struct {
+	Job string // the job name
+}
+
+var (
+	jobRuns = metrics.NewCounterMap[jobLabels](
+		"serviceweaver_cron_run_count",
+		"Count of runs of Service Weaver cron jobs",
+	)
+	failedJobRuns = metrics.NewCounterMap[jobLabels](
+		"serviceweaver_cron_failed_run_count",
+		"Count of runs of Service Weaver cron jobs that returned an error",
+	)
+)
+
+// CronOptions configure a job registered with Cron.
+type CronOptions struct {
+	// AllowOverlap, if true, lets a run of the job start while a previous
+	// run is still running. By default, a tick of the schedule that occurs
+	// while the job is running is missed.
+	AllowOverlap bool
+
+	// RunMissed, if true, runs the job once, as soon as possible, after one
+	// or more ticks of its schedule are missed, e.g., because the previous
+	// run was still running, or because no process had registered the job
+	// yet. By default, missed ticks are skipped.
+	RunMissed bool
+}
+
+// Cron runs job periodically, on the provided schedule, on exactly one of
+// the processes in the deployment that register the job. The job is
+// typically a method of a component client, so that it runs on one of the
+// component's replicas. For example, a cart service can expire abandoned
+// carts every hour:
+//
+//	cart := weaver.MustGet[CartService](root)
+//	err := weaver.Cron(root, "expire-carts", "@hourly", weaver.CronOptions{}, cart.ExpireCarts)
+//
+// A schedule is a standard five field cron expression, e.g., "*/15 * * * *",
+// evaluated in UTC; a predefined schedule like "@hourly" or "@daily"; or
+// "@every <duration>", e.g., "@every 30s". Every process that registers a job
+// with the same name must register it with the same schedule and options. A
+// process may register a job only once.
+//
+// The deployer starts every run of the job on one of the processes that
+// registered it, without waiting for the previous run to finish if
+// opts.AllowOverlap is set. A run is started at most once per tick of the
+// schedule: if the run returns an error, or its process fails, it is not
+// retried. See CronOptions for how ticks are handled when the job can't be
+// started.
+func Cron(requester Instance, name, schedule string, opts CronOptions, job func(context.Context) error) error {
+	if _, err := cron.Parse(schedule); err != nil {
+		return fmt.Errorf("Cron(%q): %w", name, err)
+	}
+	w := requester.rep().wlet
+	if err := w.jobs.add(name, job); err != nil {
+		return err
+	}
+	req := &protos.ScheduleJobRequest{
+		Name:         name,
+		Schedule:     schedule,
+		AllowOverlap: opts.AllowOverlap,
+		RunMissed:    opts.RunMissed,
+	}
+	if err := w.env.ScheduleJob(w.ctx, req); err != nil {
+		w.jobs.remove(name)
+		return err
+	}
+	return nil
+}
+
+// jobs holds the jobs registered with Cron in a weavelet.
+type jobs struct {
+	mu   sync.Mutex
+	jobs map[string]func(context.Context) error
+}
+
+func newJobs() *jobs {
+	return &jobs{jobs: map[string]func(context.Context) error{}}
+}
+
+// add adds the provided job. It returns an error if a job with the same name
+// was already added.
+func (j *jobs) add(name string, job func(context.Context) error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.jobs[name]; ok {
+		return fmt.Errorf("job %q already registered", name)
+	}
+	j.jobs[name] = job
+	return nil
+}
+
+// remove removes the provided job.
+func (j *jobs) remove(name string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.jobs, name)
+}
+
+// run runs the provided job.
+func (j *jobs) run(ctx context.Context, name string) error {
+	j.mu.Lock()
+	job, ok := j.jobs[name]
+	j.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no job %q", name)
+	}
+	jobRuns.Get(jobLabels{Job: name}).Add(1)
+	if err := job(ctx); err != nil {
+		failedJobRuns.Get(jobLabels{Job: name}).Add(1)
+		return err
+	}
+	return nil
+}
@@ -39,6 +39,35 @@ type env interface {
 	// ExportListener exports a listener.
 	ExportListener(ctx context.Context, listener, addr string, opts ListenerOptions) (*protos.ExportListenerReply, error)
 
+	// PublishTopicEvent sends an event published by this weavelet to the
+	// other weavelets in the deployment.
+	PublishTopicEvent(event *protos.TopicEvent) error
+
+	// PublishMessage stores a message published on a Topic by this weavelet,
+	// for delivery to every subscription to the topic.
+	PublishMessage(ctx context.Context, msg *protos.TopicMessage) error
+
+	// Subscribe subscribes this weavelet to a subscription to a topic. The
+	// messages of the subscription are delivered to the weavelet's
+	// DeliverMessage method.
+	Subscribe(ctx context.Context, topic, subscription string) error
+
+	// ScheduleJob schedules a job registered with Cron by this weavelet.
+	// Runs of the job are started with the weavelet's RunJob method.
+	ScheduleJob(ctx context.Context, req *protos.ScheduleJobRequest) error
+
+	// Lease acquires, renews, or releases the lease of a leader election
+	// created with NewLeaderElection by this weavelet.
+	Lease(ctx context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error)
+
+	// SendProfile sends a profile collected by the continuous profiler of
+	// this weavelet to the environment.
+	SendProfile(profile *protos.Profile) error
+
+	// SendCrashReport sends the report of a panic in a component method,
+	// which is about to crash this weavelet, to the environment.
+	SendCrashReport(report *protos.CrashReport) error
+
 	// CreateLogSaver creates and returns a function that saves log entries
 	// to the environment.
 	CreateLogSaver() func(entry *protos.LogEntry)
@@ -59,7 +88,7 @@ func getEnv(ctx context.Context, handler conn.WeaveletHandler) (env, error) {
 		return nil, err
 	}
 	if !bootstrap.HasPipes() {
-		return newSingleprocessEnv(bootstrap)
+		return newSingleprocessEnv(bootstrap, handler)
 	}
 	return newRemoteEnv(ctx, bootstrap, handler)
 }
@@ -17,13 +17,13 @@ func init() {
 		Iface: reflect.TypeOf((*ImageScaler)(nil)).Elem(),
 		New:   func() any { return &scaler{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return imageScaler_local_stub{impl: impl.(ImageScaler), tracer: tracer}
+			return imageScaler_local_stub{impl: impl.(ImageScaler), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return imageScaler_client_stub{stub: stub, scaleMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/ImageScaler", Method: "Scale"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return imageScaler_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), scaleMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/ImageScaler", Method: "Scale"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return imageScaler_server_stub{impl: impl.(ImageScaler), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return imageScaler_server_stub{impl: impl.(ImageScaler), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
@@ -31,13 +31,13 @@ func init() {
 		Iface: reflect.TypeOf((*LocalCache)(nil)).Elem(),
 		New:   func() any { return &localCache{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return localCache_local_stub{impl: impl.(LocalCache), tracer: tracer}
+			return localCache_local_stub{impl: impl.(LocalCache), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return localCache_client_stub{stub: stub, getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Get"}), putMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Put"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return localCache_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Get"}), putMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Put"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return localCache_server_stub{impl: impl.(LocalCache), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return localCache_server_stub{impl: impl.(LocalCache), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
@@ -46,13 +46,13 @@ func init() {
 		New:      func() any { return &sqlStore{} },
 		ConfigFn: func(i any) any { return i.(*sqlStore).WithConfig.Config() },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return sQLStore_local_stub{impl: impl.(SQLStore), tracer: tracer}
+			return sQLStore_local_stub{impl: impl.(SQLStore), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return sQLStore_client_stub{stub: stub, createThreadMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreateThread"}), createPostMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreatePost"}), getFeedMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetFeed"}), getImageMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetImage"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return sQLStore_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), createThreadMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreateThread"}), createPostMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreatePost"}), getFeedMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetFeed"}), getImageMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetImage"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return sQLStore_server_stub{impl: impl.(SQLStore), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return sQLStore_server_stub{impl: impl.(SQLStore), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -60,8 +60,9 @@ func init() {
 // Local stub implementations.
 
 type imageScaler_local_stub struct {
-	impl   ImageScaler
-	tracer trace.Tracer
+	impl        ImageScaler
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s imageScaler_local_stub) Scale(ctx context.Context, a0 []byte, a1 int, a2 int) (r0 []byte, err error) {
@@ -78,12 +79,20 @@ func (s imageScaler_local_stub) Scale(ctx context.Context, a0 []byte, a1 int, a2
 		}()
 	}
 
-	return s.impl.Scale(ctx, a0, a1, a2)
+	if s.interceptor == nil {
+		return s.impl.Scale(ctx, a0, a1, a2)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/ImageScaler", Method: "Scale", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Scale(ctx, a0, a1, a2)
+		return
+	})
+	return
 }
 
 type localCache_local_stub struct {
-	impl   LocalCache
-	tracer trace.Tracer
+	impl        LocalCache
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s localCache_local_stub) Get(ctx context.Context, a0 string) (r0 string, err error) {
@@ -100,7 +109,14 @@ func (s localCache_local_stub) Get(ctx context.Context, a0 string) (r0 string, e
 		}()
 	}
 
-	return s.impl.Get(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Get(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx, a0)
+		return
+	})
+	return
 }
 
 func (s localCache_local_stub) Put(ctx context.Context, a0 string, a1 string) (err error) {
@@ -117,12 +133,19 @@ func (s localCache_local_stub) Put(ctx context.Context, a0 string, a1 string) (e
 		}()
 	}
 
-	return s.impl.Put(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Put(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Put(ctx, a0, a1)
+	})
+	return
 }
 
 type sQLStore_local_stub struct {
-	impl   SQLStore
-	tracer trace.Tracer
+	impl        SQLStore
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s sQLStore_local_stub) CreateThread(ctx context.Context, a0 string, a1 time.Time, a2 []string, a3 string, a4 []byte) (r0 ThreadID, err error) {
@@ -139,7 +162,14 @@ func (s sQLStore_local_stub) CreateThread(ctx context.Context, a0 string, a1 tim
 		}()
 	}
 
-	return s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+	if s.interceptor == nil {
+		return s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreateThread", Args: []any{a0, a1, a2, a3, a4}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+		return
+	})
+	return
 }
 
 func (s sQLStore_local_stub) CreatePost(ctx context.Context, a0 string, a1 time.Time, a2 ThreadID, a3 string) (err error) {
@@ -156,7 +186,13 @@ func (s sQLStore_local_stub) CreatePost(ctx context.Context, a0 string, a1 time.
 		}()
 	}
 
-	return s.impl.CreatePost(ctx, a0, a1, a2, a3)
+	if s.interceptor == nil {
+		return s.impl.CreatePost(ctx, a0, a1, a2, a3)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreatePost", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) error {
+		return s.impl.CreatePost(ctx, a0, a1, a2, a3)
+	})
+	return
 }
 
 func (s sQLStore_local_stub) GetFeed(ctx context.Context, a0 string) (r0 []Thread, err error) {
@@ -173,7 +209,14 @@ func (s sQLStore_local_stub) GetFeed(ctx context.Context, a0 string) (r0 []Threa
 		}()
 	}
 
-	return s.impl.GetFeed(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.GetFeed(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetFeed", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetFeed(ctx, a0)
+		return
+	})
+	return
 }
 
 func (s sQLStore_local_stub) GetImage(ctx context.Context, a0 string, a1 ImageID) (r0 []byte, err error) {
@@ -190,17 +233,37 @@ func (s sQLStore_local_stub) GetImage(ctx context.Context, a0 string, a1 ImageID
 		}()
 	}
 
-	return s.impl.GetImage(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.GetImage(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetImage", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetImage(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type imageScaler_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	scaleMetrics *codegen.MethodMetrics
 }
 
 func (s imageScaler_client_stub) Scale(ctx context.Context, a0 []byte, a1 int, a2 int) (r0 []byte, err error) {
+	if s.interceptor == nil {
+		return s.callScale(ctx, a0, a1, a2)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/ImageScaler", Method: "Scale", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+		r0, err = s.callScale(ctx, a0, a1, a2)
+		return
+	})
+	return
+}
+
+func (s imageScaler_client_stub) callScale(ctx context.Context, a0 []byte, a1 int, a2 int) (r0 []byte, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.scaleMetrics.Count.Add(1)
@@ -228,6 +291,22 @@ func (s imageScaler_client_stub) Scale(ctx context.Context, a0 []byte, a1 int, a
 		s.scaleMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1, a2)
+		s.scaleMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.scaleMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + (len(a0) * 1))
@@ -240,7 +319,6 @@ func (s imageScaler_client_stub) Scale(ctx context.Context, a0 []byte, a1 int, a
 	serviceweaver_enc_slice_byte_87461245(enc, a0)
 	enc.Int(a1)
 	enc.Int(a2)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.scaleMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -259,12 +337,25 @@ func (s imageScaler_client_stub) Scale(ctx context.Context, a0 []byte, a1 int, a
 }
 
 type localCache_client_stub struct {
-	stub       codegen.Stub
-	getMetrics *codegen.MethodMetrics
-	putMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	getMetrics  *codegen.MethodMetrics
+	putMetrics  *codegen.MethodMetrics
 }
 
 func (s localCache_client_stub) Get(ctx context.Context, a0 string) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s localCache_client_stub) callGet(ctx context.Context, a0 string) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getMetrics.Count.Add(1)
@@ -292,6 +383,22 @@ func (s localCache_client_stub) Get(ctx context.Context, a0 string) (r0 string,
 		s.getMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -300,7 +407,6 @@ func (s localCache_client_stub) Get(ctx context.Context, a0 string) (r0 string,
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -319,6 +425,16 @@ func (s localCache_client_stub) Get(ctx context.Context, a0 string) (r0 string,
 }
 
 func (s localCache_client_stub) Put(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callPut(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callPut(ctx, a0, a1)
+	})
+	return
+}
+
+func (s localCache_client_stub) callPut(ctx context.Context, a0 string, a1 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.putMetrics.Count.Add(1)
@@ -346,6 +462,22 @@ func (s localCache_client_stub) Put(ctx context.Context, a0 string, a1 string) (
 		s.putMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.putMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.putMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -356,7 +488,6 @@ func (s localCache_client_stub) Put(ctx context.Context, a0 string, a1 string) (
 	// Encode arguments.
 	enc.String(a0)
 	enc.String(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.putMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -375,6 +506,8 @@ func (s localCache_client_stub) Put(ctx context.Context, a0 string, a1 string) (
 
 type sQLStore_client_stub struct {
 	stub                codegen.Stub
+	codec               codegen.Codec
+	interceptor         codegen.Interceptor
 	createThreadMetrics *codegen.MethodMetrics
 	createPostMetrics   *codegen.MethodMetrics
 	getFeedMetrics      *codegen.MethodMetrics
@@ -382,6 +515,17 @@ type sQLStore_client_stub struct {
 }
 
 func (s sQLStore_client_stub) CreateThread(ctx context.Context, a0 string, a1 time.Time, a2 []string, a3 string, a4 []byte) (r0 ThreadID, err error) {
+	if s.interceptor == nil {
+		return s.callCreateThread(ctx, a0, a1, a2, a3, a4)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreateThread", Args: []any{a0, a1, a2, a3, a4}}, func(ctx context.Context) (err error) {
+		r0, err = s.callCreateThread(ctx, a0, a1, a2, a3, a4)
+		return
+	})
+	return
+}
+
+func (s sQLStore_client_stub) callCreateThread(ctx context.Context, a0 string, a1 time.Time, a2 []string, a3 string, a4 []byte) (r0 ThreadID, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.createThreadMetrics.Count.Add(1)
@@ -409,6 +553,22 @@ func (s sQLStore_client_stub) CreateThread(ctx context.Context, a0 string, a1 ti
 		s.createThreadMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1, a2, a3, a4)
+		s.createThreadMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.createThreadMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	enc.String(a0)
@@ -416,7 +576,6 @@ func (s sQLStore_client_stub) CreateThread(ctx context.Context, a0 string, a1 ti
 	serviceweaver_enc_slice_string_4af10117(enc, a2)
 	enc.String(a3)
 	serviceweaver_enc_slice_byte_87461245(enc, a4)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.createThreadMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -435,6 +594,16 @@ func (s sQLStore_client_stub) CreateThread(ctx context.Context, a0 string, a1 ti
 }
 
 func (s sQLStore_client_stub) CreatePost(ctx context.Context, a0 string, a1 time.Time, a2 ThreadID, a3 string) (err error) {
+	if s.interceptor == nil {
+		return s.callCreatePost(ctx, a0, a1, a2, a3)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreatePost", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) error {
+		return s.callCreatePost(ctx, a0, a1, a2, a3)
+	})
+	return
+}
+
+func (s sQLStore_client_stub) callCreatePost(ctx context.Context, a0 string, a1 time.Time, a2 ThreadID, a3 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.createPostMetrics.Count.Add(1)
@@ -462,13 +631,28 @@ func (s sQLStore_client_stub) CreatePost(ctx context.Context, a0 string, a1 time
 		s.createPostMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1, a2, a3)
+		s.createPostMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.createPostMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	enc.String(a0)
 	enc.EncodeBinaryMarshaler(&a1)
 	enc.Int64((int64)(a2))
 	enc.String(a3)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.createPostMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -486,6 +670,17 @@ func (s sQLStore_client_stub) CreatePost(ctx context.Context, a0 string, a1 time
 }
 
 func (s sQLStore_client_stub) GetFeed(ctx context.Context, a0 string) (r0 []Thread, err error) {
+	if s.interceptor == nil {
+		return s.callGetFeed(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetFeed", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetFeed(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s sQLStore_client_stub) callGetFeed(ctx context.Context, a0 string) (r0 []Thread, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getFeedMetrics.Count.Add(1)
@@ -513,6 +708,22 @@ func (s sQLStore_client_stub) GetFeed(ctx context.Context, a0 string) (r0 []Thre
 		s.getFeedMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getFeedMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getFeedMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -521,7 +732,6 @@ func (s sQLStore_client_stub) GetFeed(ctx context.Context, a0 string) (r0 []Thre
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getFeedMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -540,6 +750,17 @@ func (s sQLStore_client_stub) GetFeed(ctx context.Context, a0 string) (r0 []Thre
 }
 
 func (s sQLStore_client_stub) GetImage(ctx context.Context, a0 string, a1 ImageID) (r0 []byte, err error) {
+	if s.interceptor == nil {
+		return s.callGetImage(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetImage", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetImage(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s sQLStore_client_stub) callGetImage(ctx context.Context, a0 string, a1 ImageID) (r0 []byte, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getImageMetrics.Count.Add(1)
@@ -567,6 +788,22 @@ func (s sQLStore_client_stub) GetImage(ctx context.Context, a0 string, a1 ImageI
 		s.getImageMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.getImageMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 3, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getImageMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -577,7 +814,6 @@ func (s sQLStore_client_stub) GetImage(ctx context.Context, a0 string, a1 ImageI
 	// Encode arguments.
 	enc.String(a0)
 	enc.Int64((int64)(a1))
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getImageMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -598,8 +834,10 @@ func (s sQLStore_client_stub) GetImage(ctx context.Context, a0 string, a1 ImageI
 // Server stub implementations.
 
 type imageScaler_server_stub struct {
-	impl    ImageScaler
-	addLoad func(key uint64, load float64)
+	impl        ImageScaler
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -612,6 +850,11 @@ func (s imageScaler_server_stub) GetStubFn(method string) func(ctx context.Conte
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s imageScaler_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s imageScaler_server_stub) scale(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -621,20 +864,36 @@ func (s imageScaler_server_stub) scale(ctx context.Context, args []byte) (res []
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 []byte
-	a0 = serviceweaver_dec_slice_byte_87461245(dec)
 	var a1 int
-	a1 = dec.Int()
 	var a2 int
-	a2 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1, &a2)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = serviceweaver_dec_slice_byte_87461245(dec)
+		a1 = dec.Int()
+		a2 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Scale(ctx, a0, a1, a2)
+	var r0 []byte
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Scale(ctx, a0, a1, a2)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/ImageScaler", Method: "Scale", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Scale(ctx, a0, a1, a2)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_byte_87461245(enc, r0)
 	enc.Error(appErr)
@@ -642,8 +901,10 @@ func (s imageScaler_server_stub) scale(ctx context.Context, args []byte) (res []
 }
 
 type localCache_server_stub struct {
-	impl    LocalCache
-	addLoad func(key uint64, load float64)
+	impl        LocalCache
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -658,6 +919,11 @@ func (s localCache_server_stub) GetStubFn(method string) func(ctx context.Contex
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s localCache_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s localCache_server_stub) get(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -667,16 +933,32 @@ func (s localCache_server_stub) get(ctx context.Context, args []byte) (res []byt
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Get(ctx, a0)
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.String(r0)
 	enc.Error(appErr)
@@ -692,26 +974,42 @@ func (s localCache_server_stub) put(ctx context.Context, args []byte) (res []byt
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Put(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Put(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/LocalCache", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Put(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type sQLStore_server_stub struct {
-	impl    SQLStore
-	addLoad func(key uint64, load float64)
+	impl        SQLStore
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -730,6 +1028,11 @@ func (s sQLStore_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s sQLStore_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s sQLStore_server_stub) createThread(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -739,24 +1042,40 @@ func (s sQLStore_server_stub) createThread(ctx context.Context, args []byte) (re
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 time.Time
-	dec.DecodeBinaryUnmarshaler(&a1)
 	var a2 []string
-	a2 = serviceweaver_dec_slice_string_4af10117(dec)
 	var a3 string
-	a3 = dec.String()
 	var a4 []byte
-	a4 = serviceweaver_dec_slice_byte_87461245(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1, &a2, &a3, &a4)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		dec.DecodeBinaryUnmarshaler(&a1)
+		a2 = serviceweaver_dec_slice_string_4af10117(dec)
+		a3 = dec.String()
+		a4 = serviceweaver_dec_slice_byte_87461245(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+	var r0 ThreadID
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreateThread", Args: []any{a0, a1, a2, a3, a4}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.CreateThread(ctx, a0, a1, a2, a3, a4)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Int64((int64)(r0))
 	enc.Error(appErr)
@@ -772,22 +1091,36 @@ func (s sQLStore_server_stub) createPost(ctx context.Context, args []byte) (res
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 time.Time
-	dec.DecodeBinaryUnmarshaler(&a1)
 	var a2 ThreadID
-	*(*int64)(&a2) = dec.Int64()
 	var a3 string
-	a3 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1, &a2, &a3)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		dec.DecodeBinaryUnmarshaler(&a1)
+		*(*int64)(&a2) = dec.Int64()
+		a3 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.CreatePost(ctx, a0, a1, a2, a3)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.CreatePost(ctx, a0, a1, a2, a3)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "CreatePost", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) error {
+			return s.impl.CreatePost(ctx, a0, a1, a2, a3)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -802,16 +1135,32 @@ func (s sQLStore_server_stub) getFeed(ctx context.Context, args []byte) (res []b
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetFeed(ctx, a0)
+	var r0 []Thread
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetFeed(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetFeed", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetFeed(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_Thread_511e1469(enc, r0)
 	enc.Error(appErr)
@@ -827,18 +1176,34 @@ func (s sQLStore_server_stub) getImage(ctx context.Context, args []byte) (res []
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 ImageID
-	*(*int64)(&a1) = dec.Int64()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		*(*int64)(&a1) = dec.Int64()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetImage(ctx, a0, a1)
+	var r0 []byte
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetImage(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/chat/SQLStore", Method: "GetImage", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetImage(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_byte_87461245(enc, r0)
 	enc.Error(appErr)
@@ -853,22 +1218,36 @@ func (x *Post) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Post.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(5)
 	enc.Int64((int64)(x.ID))
 	enc.String(x.Creator)
 	enc.EncodeBinaryMarshaler(&x.When)
 	enc.String(x.Text)
 	enc.Int64((int64)(x.ImageID))
+	enc.EndStruct(start)
 }
 
 func (x *Post) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Post.WeaverUnmarshal: nil receiver"))
 	}
-	*(*int64)(&x.ID) = dec.Int64()
-	x.Creator = dec.String()
-	dec.DecodeBinaryUnmarshaler(&x.When)
-	x.Text = dec.String()
-	*(*int64)(&x.ImageID) = dec.Int64()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		*(*int64)(&x.ID) = dec.Int64()
+	}
+	if n > 1 {
+		x.Creator = dec.String()
+	}
+	if n > 2 {
+		dec.DecodeBinaryUnmarshaler(&x.When)
+	}
+	if n > 3 {
+		x.Text = dec.String()
+	}
+	if n > 4 {
+		*(*int64)(&x.ImageID) = dec.Int64()
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &Thread{}
@@ -877,16 +1256,24 @@ func (x *Thread) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Thread.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	enc.Int64((int64)(x.ID))
 	serviceweaver_enc_slice_Post_29a9ee83(enc, x.Posts)
+	enc.EndStruct(start)
 }
 
 func (x *Thread) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Thread.WeaverUnmarshal: nil receiver"))
 	}
-	*(*int64)(&x.ID) = dec.Int64()
-	x.Posts = serviceweaver_dec_slice_Post_29a9ee83(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		*(*int64)(&x.ID) = dec.Int64()
+	}
+	if n > 1 {
+		x.Posts = serviceweaver_dec_slice_Post_29a9ee83(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_Post_29a9ee83(enc *codegen.Encoder, arg []Post) {
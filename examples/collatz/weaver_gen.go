@@ -12,27 +12,31 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/collatz/Even",
-		Iface:       reflect.TypeOf((*Even)(nil)).Elem(),
-		New:         func() any { return &even{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return even_local_stub{impl: impl.(Even), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return even_client_stub{stub: stub, doMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/collatz/Even", Method: "Do"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/collatz/Even",
+		Iface: reflect.TypeOf((*Even)(nil)).Elem(),
+		New:   func() any { return &even{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return even_local_stub{impl: impl.(Even), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return even_server_stub{impl: impl.(Even), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return even_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), doMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/collatz/Even", Method: "Do"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return even_server_stub{impl: impl.(Even), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/collatz/Odd",
-		Iface:       reflect.TypeOf((*Odd)(nil)).Elem(),
-		New:         func() any { return &odd{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return odd_local_stub{impl: impl.(Odd), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return odd_client_stub{stub: stub, doMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/collatz/Odd", Method: "Do"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/collatz/Odd",
+		Iface: reflect.TypeOf((*Odd)(nil)).Elem(),
+		New:   func() any { return &odd{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return odd_local_stub{impl: impl.(Odd), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return odd_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), doMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/collatz/Odd", Method: "Do"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return odd_server_stub{impl: impl.(Odd), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return odd_server_stub{impl: impl.(Odd), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -40,8 +44,9 @@ func init() {
 // Local stub implementations.
 
 type even_local_stub struct {
-	impl   Even
-	tracer trace.Tracer
+	impl        Even
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s even_local_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
@@ -58,12 +63,20 @@ func (s even_local_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 		}()
 	}
 
-	return s.impl.Do(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Do(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Even", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Do(ctx, a0)
+		return
+	})
+	return
 }
 
 type odd_local_stub struct {
-	impl   Odd
-	tracer trace.Tracer
+	impl        Odd
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s odd_local_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
@@ -80,17 +93,37 @@ func (s odd_local_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 		}()
 	}
 
-	return s.impl.Do(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Do(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Odd", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Do(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type even_client_stub struct {
-	stub      codegen.Stub
-	doMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	doMetrics   *codegen.MethodMetrics
 }
 
 func (s even_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
+	if s.interceptor == nil {
+		return s.callDo(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Even", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callDo(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s even_client_stub) callDo(ctx context.Context, a0 int) (r0 int, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.doMetrics.Count.Add(1)
@@ -118,6 +151,22 @@ func (s even_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 		s.doMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.doMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.doMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += 8
@@ -126,7 +175,6 @@ func (s even_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 
 	// Encode arguments.
 	enc.Int(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.doMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -145,11 +193,24 @@ func (s even_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 }
 
 type odd_client_stub struct {
-	stub      codegen.Stub
-	doMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	doMetrics   *codegen.MethodMetrics
 }
 
 func (s odd_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
+	if s.interceptor == nil {
+		return s.callDo(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Odd", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callDo(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s odd_client_stub) callDo(ctx context.Context, a0 int) (r0 int, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.doMetrics.Count.Add(1)
@@ -177,6 +238,22 @@ func (s odd_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 		s.doMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.doMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.doMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += 8
@@ -185,7 +262,6 @@ func (s odd_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 
 	// Encode arguments.
 	enc.Int(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.doMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -206,8 +282,10 @@ func (s odd_client_stub) Do(ctx context.Context, a0 int) (r0 int, err error) {
 // Server stub implementations.
 
 type even_server_stub struct {
-	impl    Even
-	addLoad func(key uint64, load float64)
+	impl        Even
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -220,6 +298,11 @@ func (s even_server_stub) GetStubFn(method string) func(ctx context.Context, arg
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s even_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s even_server_stub) do(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -229,16 +312,32 @@ func (s even_server_stub) do(ctx context.Context, args []byte) (res []byte, err
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 int
-	a0 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Do(ctx, a0)
+	var r0 int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Do(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Even", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Do(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Int(r0)
 	enc.Error(appErr)
@@ -246,8 +345,10 @@ func (s even_server_stub) do(ctx context.Context, args []byte) (res []byte, err
 }
 
 type odd_server_stub struct {
-	impl    Odd
-	addLoad func(key uint64, load float64)
+	impl        Odd
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -260,6 +361,11 @@ func (s odd_server_stub) GetStubFn(method string) func(ctx context.Context, args
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s odd_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s odd_server_stub) do(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -269,16 +375,32 @@ func (s odd_server_stub) do(ctx context.Context, args []byte) (res []byte, err e
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 int
-	a0 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Do(ctx, a0)
+	var r0 int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Do(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/collatz/Odd", Method: "Do", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Do(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Int(r0)
 	enc.Error(appErr)
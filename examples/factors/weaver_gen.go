@@ -17,13 +17,13 @@ func init() {
 		New:    func() any { return &factorer{} },
 		Routed: true,
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return factorer_local_stub{impl: impl.(Factorer), tracer: tracer}
+			return factorer_local_stub{impl: impl.(Factorer), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return factorer_client_stub{stub: stub, factorsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/factors/Factorer", Method: "Factors"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return factorer_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), factorsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/factors/Factorer", Method: "Factors"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return factorer_server_stub{impl: impl.(Factorer), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return factorer_server_stub{impl: impl.(Factorer), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -31,8 +31,9 @@ func init() {
 // Local stub implementations.
 
 type factorer_local_stub struct {
-	impl   Factorer
-	tracer trace.Tracer
+	impl        Factorer
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s factorer_local_stub) Factors(ctx context.Context, a0 int) (r0 []int, err error) {
@@ -49,17 +50,37 @@ func (s factorer_local_stub) Factors(ctx context.Context, a0 int) (r0 []int, err
 		}()
 	}
 
-	return s.impl.Factors(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Factors(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/factors/Factorer", Method: "Factors", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Factors(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type factorer_client_stub struct {
 	stub           codegen.Stub
+	codec          codegen.Codec
+	interceptor    codegen.Interceptor
 	factorsMetrics *codegen.MethodMetrics
 }
 
 func (s factorer_client_stub) Factors(ctx context.Context, a0 int) (r0 []int, err error) {
+	if s.interceptor == nil {
+		return s.callFactors(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/factors/Factorer", Method: "Factors", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callFactors(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s factorer_client_stub) callFactors(ctx context.Context, a0 int) (r0 []int, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.factorsMetrics.Count.Add(1)
@@ -87,6 +108,24 @@ func (s factorer_client_stub) Factors(ctx context.Context, a0 int) (r0 []int, er
 		s.factorsMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	// Set the shardKey.
+	var r router
+	shardKey := _hashFactorer(r.Factors(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.factorsMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.factorsMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += 8
@@ -96,10 +135,6 @@ func (s factorer_client_stub) Factors(ctx context.Context, a0 int) (r0 []int, er
 	// Encode arguments.
 	enc.Int(a0)
 
-	// Set the shardKey.
-	var r router
-	shardKey := _hashFactorer(r.Factors(ctx, a0))
-
 	// Call the remote method.
 	s.factorsMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
@@ -119,8 +154,10 @@ func (s factorer_client_stub) Factors(ctx context.Context, a0 int) (r0 []int, er
 // Server stub implementations.
 
 type factorer_server_stub struct {
-	impl    Factorer
-	addLoad func(key uint64, load float64)
+	impl        Factorer
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -133,6 +170,11 @@ func (s factorer_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s factorer_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s factorer_server_stub) factors(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -142,18 +184,34 @@ func (s factorer_server_stub) factors(ctx context.Context, args []byte) (res []b
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 int
-	a0 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.Int()
+	}
 	var r router
 	s.addLoad(_hashFactorer(r.Factors(ctx, a0)), 1.0)
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Factors(ctx, a0)
+	var r0 []int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Factors(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/factors/Factorer", Method: "Factors", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Factors(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_int_7c8c8866(enc, r0)
 	enc.Error(appErr)
@@ -12,15 +12,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/hello/Cache",
-		Iface:       reflect.TypeOf((*Cache)(nil)).Elem(),
-		New:         func() any { return &cache{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return cache_local_stub{impl: impl.(Cache), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return cache_client_stub{stub: stub, setMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Set"}), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Get"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/hello/Cache",
+		Iface: reflect.TypeOf((*Cache)(nil)).Elem(),
+		New:   func() any { return &cache{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return cache_local_stub{impl: impl.(Cache), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return cache_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), setMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Set"}), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Get"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return cache_server_stub{impl: impl.(Cache), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return cache_server_stub{impl: impl.(Cache), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
@@ -28,13 +30,13 @@ func init() {
 		Iface: reflect.TypeOf((*Reverser)(nil)).Elem(),
 		New:   func() any { return &reverser{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return reverser_local_stub{impl: impl.(Reverser), tracer: tracer}
+			return reverser_local_stub{impl: impl.(Reverser), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return reverser_client_stub{stub: stub, reverseMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Reverser", Method: "Reverse"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return reverser_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), reverseMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/hello/Reverser", Method: "Reverse"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return reverser_server_stub{impl: impl.(Reverser), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return reverser_server_stub{impl: impl.(Reverser), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -42,8 +44,9 @@ func init() {
 // Local stub implementations.
 
 type cache_local_stub struct {
-	impl   Cache
-	tracer trace.Tracer
+	impl        Cache
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s cache_local_stub) Set(ctx context.Context, a0 string, a1 string) (err error) {
@@ -60,7 +63,13 @@ func (s cache_local_stub) Set(ctx context.Context, a0 string, a1 string) (err er
 		}()
 	}
 
-	return s.impl.Set(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Set(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Set", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Set(ctx, a0, a1)
+	})
+	return
 }
 
 func (s cache_local_stub) Get(ctx context.Context, a0 string) (r0 string, err error) {
@@ -77,12 +86,20 @@ func (s cache_local_stub) Get(ctx context.Context, a0 string) (r0 string, err er
 		}()
 	}
 
-	return s.impl.Get(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Get(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx, a0)
+		return
+	})
+	return
 }
 
 type reverser_local_stub struct {
-	impl   Reverser
-	tracer trace.Tracer
+	impl        Reverser
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s reverser_local_stub) Reverse(ctx context.Context, a0 string) (r0 string, err error) {
@@ -99,18 +116,37 @@ func (s reverser_local_stub) Reverse(ctx context.Context, a0 string) (r0 string,
 		}()
 	}
 
-	return s.impl.Reverse(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Reverse(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Reverser", Method: "Reverse", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Reverse(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type cache_client_stub struct {
-	stub       codegen.Stub
-	setMetrics *codegen.MethodMetrics
-	getMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	setMetrics  *codegen.MethodMetrics
+	getMetrics  *codegen.MethodMetrics
 }
 
 func (s cache_client_stub) Set(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callSet(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Set", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callSet(ctx, a0, a1)
+	})
+	return
+}
+
+func (s cache_client_stub) callSet(ctx context.Context, a0 string, a1 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.setMetrics.Count.Add(1)
@@ -138,6 +174,22 @@ func (s cache_client_stub) Set(ctx context.Context, a0 string, a1 string) (err e
 		s.setMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.setMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.setMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -148,7 +200,6 @@ func (s cache_client_stub) Set(ctx context.Context, a0 string, a1 string) (err e
 	// Encode arguments.
 	enc.String(a0)
 	enc.String(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.setMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -166,6 +217,17 @@ func (s cache_client_stub) Set(ctx context.Context, a0 string, a1 string) (err e
 }
 
 func (s cache_client_stub) Get(ctx context.Context, a0 string) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s cache_client_stub) callGet(ctx context.Context, a0 string) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getMetrics.Count.Add(1)
@@ -193,6 +255,22 @@ func (s cache_client_stub) Get(ctx context.Context, a0 string) (r0 string, err e
 		s.getMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -201,7 +279,6 @@ func (s cache_client_stub) Get(ctx context.Context, a0 string) (r0 string, err e
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -221,10 +298,23 @@ func (s cache_client_stub) Get(ctx context.Context, a0 string) (r0 string, err e
 
 type reverser_client_stub struct {
 	stub           codegen.Stub
+	codec          codegen.Codec
+	interceptor    codegen.Interceptor
 	reverseMetrics *codegen.MethodMetrics
 }
 
 func (s reverser_client_stub) Reverse(ctx context.Context, a0 string) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callReverse(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Reverser", Method: "Reverse", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callReverse(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s reverser_client_stub) callReverse(ctx context.Context, a0 string) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.reverseMetrics.Count.Add(1)
@@ -252,6 +342,22 @@ func (s reverser_client_stub) Reverse(ctx context.Context, a0 string) (r0 string
 		s.reverseMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.reverseMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.reverseMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -260,7 +366,6 @@ func (s reverser_client_stub) Reverse(ctx context.Context, a0 string) (r0 string
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.reverseMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -281,8 +386,10 @@ func (s reverser_client_stub) Reverse(ctx context.Context, a0 string) (r0 string
 // Server stub implementations.
 
 type cache_server_stub struct {
-	impl    Cache
-	addLoad func(key uint64, load float64)
+	impl        Cache
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -297,6 +404,11 @@ func (s cache_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s cache_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s cache_server_stub) set(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -306,18 +418,32 @@ func (s cache_server_stub) set(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Set(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Set(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Set", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Set(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -332,16 +458,32 @@ func (s cache_server_stub) get(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Get(ctx, a0)
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Cache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.String(r0)
 	enc.Error(appErr)
@@ -349,8 +491,10 @@ func (s cache_server_stub) get(ctx context.Context, args []byte) (res []byte, er
 }
 
 type reverser_server_stub struct {
-	impl    Reverser
-	addLoad func(key uint64, load float64)
+	impl        Reverser
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -363,6 +507,11 @@ func (s reverser_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s reverser_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s reverser_server_stub) reverse(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -372,16 +521,32 @@ func (s reverser_server_stub) reverse(ctx context.Context, args []byte) (res []b
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Reverse(ctx, a0)
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Reverse(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/hello/Reverser", Method: "Reverse", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Reverse(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.String(r0)
 	enc.Error(appErr)
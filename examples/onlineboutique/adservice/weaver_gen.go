@@ -13,15 +13,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, getAdsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T", Method: "GetAds"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getAdsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T", Method: "GetAds"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -29,8 +31,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) GetAds(ctx context.Context, a0 []string) (r0 []Ad, err error) {
@@ -47,17 +50,37 @@ func (s t_local_stub) GetAds(ctx context.Context, a0 []string) (r0 []Ad, err err
 		}()
 	}
 
-	return s.impl.GetAds(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.GetAds(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T", Method: "GetAds", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetAds(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub          codegen.Stub
+	codec         codegen.Codec
+	interceptor   codegen.Interceptor
 	getAdsMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) GetAds(ctx context.Context, a0 []string) (r0 []Ad, err error) {
+	if s.interceptor == nil {
+		return s.callGetAds(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T", Method: "GetAds", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetAds(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetAds(ctx context.Context, a0 []string) (r0 []Ad, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getAdsMetrics.Count.Add(1)
@@ -85,10 +108,25 @@ func (s t_client_stub) GetAds(ctx context.Context, a0 []string) (r0 []Ad, err er
 		s.getAdsMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getAdsMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getAdsMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_string_4af10117(enc, a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getAdsMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -109,8 +147,10 @@ func (s t_client_stub) GetAds(ctx context.Context, a0 []string) (r0 []Ad, err er
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -123,6 +163,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) getAds(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -132,16 +177,32 @@ func (s t_server_stub) getAds(ctx context.Context, args []byte) (res []byte, err
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 []string
-	a0 = serviceweaver_dec_slice_string_4af10117(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = serviceweaver_dec_slice_string_4af10117(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetAds(ctx, a0)
+	var r0 []Ad
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetAds(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice/T", Method: "GetAds", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetAds(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_Ad_86ae3655(enc, r0)
 	enc.Error(appErr)
@@ -156,16 +217,24 @@ func (x *Ad) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Ad.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	enc.String(x.RedirectURL)
 	enc.String(x.Text)
+	enc.EndStruct(start)
 }
 
 func (x *Ad) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Ad.WeaverUnmarshal: nil receiver"))
 	}
-	x.RedirectURL = dec.String()
-	x.Text = dec.String()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.RedirectURL = dec.String()
+	}
+	if n > 1 {
+		x.Text = dec.String()
+	}
+	dec.EndStruct(end)
 }
 
 // Encoding/decoding implementations.
@@ -13,15 +13,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, addItemMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "AddItem"}), getCartMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "GetCart"}), emptyCartMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "EmptyCart"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), addItemMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "AddItem"}), getCartMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "GetCart"}), emptyCartMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "EmptyCart"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
@@ -30,13 +32,13 @@ func init() {
 		New:    func() any { return &cartCacheImpl{} },
 		Routed: true,
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return cartCache_local_stub{impl: impl.(cartCache), tracer: tracer}
+			return cartCache_local_stub{impl: impl.(cartCache), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return cartCache_client_stub{stub: stub, addMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Add"}), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Get"}), removeMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Remove"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return cartCache_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), addMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Add"}), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Get"}), removeMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Remove"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return cartCache_server_stub{impl: impl.(cartCache), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return cartCache_server_stub{impl: impl.(cartCache), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -44,8 +46,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err error) {
@@ -62,7 +65,13 @@ func (s t_local_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err
 		}()
 	}
 
-	return s.impl.AddItem(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.AddItem(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "AddItem", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.AddItem(ctx, a0, a1)
+	})
+	return
 }
 
 func (s t_local_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, err error) {
@@ -79,7 +88,14 @@ func (s t_local_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, er
 		}()
 	}
 
-	return s.impl.GetCart(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.GetCart(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "GetCart", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetCart(ctx, a0)
+		return
+	})
+	return
 }
 
 func (s t_local_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
@@ -96,12 +112,19 @@ func (s t_local_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
 		}()
 	}
 
-	return s.impl.EmptyCart(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.EmptyCart(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "EmptyCart", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.EmptyCart(ctx, a0)
+	})
+	return
 }
 
 type cartCache_local_stub struct {
-	impl   cartCache
-	tracer trace.Tracer
+	impl        cartCache
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s cartCache_local_stub) Add(ctx context.Context, a0 string, a1 []CartItem) (err error) {
@@ -118,7 +141,13 @@ func (s cartCache_local_stub) Add(ctx context.Context, a0 string, a1 []CartItem)
 		}()
 	}
 
-	return s.impl.Add(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Add(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Add", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Add(ctx, a0, a1)
+	})
+	return
 }
 
 func (s cartCache_local_stub) Get(ctx context.Context, a0 string) (r0 []CartItem, err error) {
@@ -135,7 +164,14 @@ func (s cartCache_local_stub) Get(ctx context.Context, a0 string) (r0 []CartItem
 		}()
 	}
 
-	return s.impl.Get(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Get(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx, a0)
+		return
+	})
+	return
 }
 
 func (s cartCache_local_stub) Remove(ctx context.Context, a0 string) (r0 bool, err error) {
@@ -152,19 +188,38 @@ func (s cartCache_local_stub) Remove(ctx context.Context, a0 string) (r0 bool, e
 		}()
 	}
 
-	return s.impl.Remove(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Remove(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Remove", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Remove(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub             codegen.Stub
+	codec            codegen.Codec
+	interceptor      codegen.Interceptor
 	addItemMetrics   *codegen.MethodMetrics
 	getCartMetrics   *codegen.MethodMetrics
 	emptyCartMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err error) {
+	if s.interceptor == nil {
+		return s.callAddItem(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "AddItem", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callAddItem(ctx, a0, a1)
+	})
+	return
+}
+
+func (s t_client_stub) callAddItem(ctx context.Context, a0 string, a1 CartItem) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.addItemMetrics.Count.Add(1)
@@ -192,6 +247,22 @@ func (s t_client_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err
 		s.addItemMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.addItemMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.addItemMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -202,7 +273,6 @@ func (s t_client_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err
 	// Encode arguments.
 	enc.String(a0)
 	(a1).WeaverMarshal(enc)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.addItemMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -220,6 +290,17 @@ func (s t_client_stub) AddItem(ctx context.Context, a0 string, a1 CartItem) (err
 }
 
 func (s t_client_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, err error) {
+	if s.interceptor == nil {
+		return s.callGetCart(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "GetCart", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetCart(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetCart(ctx context.Context, a0 string) (r0 []CartItem, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getCartMetrics.Count.Add(1)
@@ -247,6 +328,22 @@ func (s t_client_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, e
 		s.getCartMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getCartMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getCartMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -255,7 +352,6 @@ func (s t_client_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, e
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getCartMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -274,6 +370,16 @@ func (s t_client_stub) GetCart(ctx context.Context, a0 string) (r0 []CartItem, e
 }
 
 func (s t_client_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callEmptyCart(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "EmptyCart", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callEmptyCart(ctx, a0)
+	})
+	return
+}
+
+func (s t_client_stub) callEmptyCart(ctx context.Context, a0 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.emptyCartMetrics.Count.Add(1)
@@ -301,6 +407,22 @@ func (s t_client_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
 		s.emptyCartMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.emptyCartMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.emptyCartMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -309,7 +431,6 @@ func (s t_client_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.emptyCartMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -328,12 +449,24 @@ func (s t_client_stub) EmptyCart(ctx context.Context, a0 string) (err error) {
 
 type cartCache_client_stub struct {
 	stub          codegen.Stub
+	codec         codegen.Codec
+	interceptor   codegen.Interceptor
 	addMetrics    *codegen.MethodMetrics
 	getMetrics    *codegen.MethodMetrics
 	removeMetrics *codegen.MethodMetrics
 }
 
 func (s cartCache_client_stub) Add(ctx context.Context, a0 string, a1 []CartItem) (err error) {
+	if s.interceptor == nil {
+		return s.callAdd(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Add", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callAdd(ctx, a0, a1)
+	})
+	return
+}
+
+func (s cartCache_client_stub) callAdd(ctx context.Context, a0 string, a1 []CartItem) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.addMetrics.Count.Add(1)
@@ -361,15 +494,29 @@ func (s cartCache_client_stub) Add(ctx context.Context, a0 string, a1 []CartItem
 		s.addMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	// Set the shardKey.
+	var r cartCacheRouter
+	shardKey := _hashCartCache(r.Add(ctx, a0, a1))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.addMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.addMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	enc.String(a0)
 	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, a1)
 
-	// Set the shardKey.
-	var r cartCacheRouter
-	shardKey := _hashCartCache(r.Add(ctx, a0, a1))
-
 	// Call the remote method.
 	s.addMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
@@ -386,6 +533,17 @@ func (s cartCache_client_stub) Add(ctx context.Context, a0 string, a1 []CartItem
 }
 
 func (s cartCache_client_stub) Get(ctx context.Context, a0 string) (r0 []CartItem, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s cartCache_client_stub) callGet(ctx context.Context, a0 string) (r0 []CartItem, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getMetrics.Count.Add(1)
@@ -413,6 +571,24 @@ func (s cartCache_client_stub) Get(ctx context.Context, a0 string) (r0 []CartIte
 		s.getMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	// Set the shardKey.
+	var r cartCacheRouter
+	shardKey := _hashCartCache(r.Get(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -422,10 +598,6 @@ func (s cartCache_client_stub) Get(ctx context.Context, a0 string) (r0 []CartIte
 	// Encode arguments.
 	enc.String(a0)
 
-	// Set the shardKey.
-	var r cartCacheRouter
-	shardKey := _hashCartCache(r.Get(ctx, a0))
-
 	// Call the remote method.
 	s.getMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
@@ -443,6 +615,17 @@ func (s cartCache_client_stub) Get(ctx context.Context, a0 string) (r0 []CartIte
 }
 
 func (s cartCache_client_stub) Remove(ctx context.Context, a0 string) (r0 bool, err error) {
+	if s.interceptor == nil {
+		return s.callRemove(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Remove", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callRemove(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s cartCache_client_stub) callRemove(ctx context.Context, a0 string) (r0 bool, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.removeMetrics.Count.Add(1)
@@ -470,6 +653,24 @@ func (s cartCache_client_stub) Remove(ctx context.Context, a0 string) (r0 bool,
 		s.removeMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	// Set the shardKey.
+	var r cartCacheRouter
+	shardKey := _hashCartCache(r.Remove(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.removeMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.removeMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -479,10 +680,6 @@ func (s cartCache_client_stub) Remove(ctx context.Context, a0 string) (r0 bool,
 	// Encode arguments.
 	enc.String(a0)
 
-	// Set the shardKey.
-	var r cartCacheRouter
-	shardKey := _hashCartCache(r.Remove(ctx, a0))
-
 	// Call the remote method.
 	s.removeMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
@@ -502,8 +699,10 @@ func (s cartCache_client_stub) Remove(ctx context.Context, a0 string) (r0 bool,
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -520,6 +719,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) addItem(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -529,18 +733,32 @@ func (s t_server_stub) addItem(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 CartItem
-	(&a1).WeaverUnmarshal(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		(&a1).WeaverUnmarshal(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.AddItem(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.AddItem(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "AddItem", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.AddItem(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -555,16 +773,32 @@ func (s t_server_stub) getCart(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetCart(ctx, a0)
+	var r0 []CartItem
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetCart(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "GetCart", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetCart(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, r0)
 	enc.Error(appErr)
@@ -580,24 +814,40 @@ func (s t_server_stub) emptyCart(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.EmptyCart(ctx, a0)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.EmptyCart(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/T", Method: "EmptyCart", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.EmptyCart(ctx, a0)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type cartCache_server_stub struct {
-	impl    cartCache
-	addLoad func(key uint64, load float64)
+	impl        cartCache
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -614,6 +864,11 @@ func (s cartCache_server_stub) GetStubFn(method string) func(ctx context.Context
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s cartCache_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s cartCache_server_stub) add(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -623,20 +878,34 @@ func (s cartCache_server_stub) add(ctx context.Context, args []byte) (res []byte
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 []CartItem
-	a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	}
 	var r cartCacheRouter
 	s.addLoad(_hashCartCache(r.Add(ctx, a0, a1)), 1.0)
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Add(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Add(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Add", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Add(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -651,18 +920,34 @@ func (s cartCache_server_stub) get(ctx context.Context, args []byte) (res []byte
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 	var r cartCacheRouter
 	s.addLoad(_hashCartCache(r.Get(ctx, a0)), 1.0)
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Get(ctx, a0)
+	var r0 []CartItem
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, r0)
 	enc.Error(appErr)
@@ -678,18 +963,34 @@ func (s cartCache_server_stub) remove(ctx context.Context, args []byte) (res []b
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 	var r cartCacheRouter
 	s.addLoad(_hashCartCache(r.Remove(ctx, a0)), 1.0)
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Remove(ctx, a0)
+	var r0 bool
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Remove(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice/cartCache", Method: "Remove", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Remove(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Bool(r0)
 	enc.Error(appErr)
@@ -704,16 +1005,24 @@ func (x *CartItem) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("CartItem.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	enc.String(x.ProductID)
 	enc.Int32(x.Quantity)
+	enc.EndStruct(start)
 }
 
 func (x *CartItem) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("CartItem.WeaverUnmarshal: nil receiver"))
 	}
-	x.ProductID = dec.String()
-	x.Quantity = dec.Int32()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.ProductID = dec.String()
+	}
+	if n > 1 {
+		x.Quantity = dec.Int32()
+	}
+	dec.EndStruct(end)
 }
 
 // Router methods.
@@ -17,11 +17,13 @@ package checkoutservice
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ServiceWeaver/weaver"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice"
@@ -30,6 +32,15 @@ import (
 	"github.com/google/uuid"
 )
 
+type orderLabels struct {
+	Currency string `values:"USD,EUR,CAD,JPY,GBP,TRY"` // the user's currency
+}
+
+var ordersPlaced = weaver.NewCounterMap[orderLabels](
+	"boutique_orders_placed",
+	"Number of orders placed, by the user's currency",
+)
+
 type PlaceOrderRequest struct {
 	weaver.AutoMarshal
 	UserID       string
@@ -37,6 +48,7 @@ type PlaceOrderRequest struct {
 	Address      shippingservice.Address
 	Email        string
 	CreditCard   paymentservice.CreditCardInfo
+	Account      string // email of the signed in account, if any
 }
 
 type T interface {
@@ -46,41 +58,49 @@ type T interface {
 type impl struct {
 	weaver.Implements[T]
 
-	catalogService  productcatalogservice.T
-	cartService     cartservice.T
-	currencyService currencyservice.T
-	shippingService shippingservice.T
-	emailService    emailservice.T
-	paymentService  paymentservice.T
+	catalogService  weaver.Ref[productcatalogservice.T]
+	cartService     weaver.Ref[cartservice.T]
+	currencyService weaver.Ref[currencyservice.T]
+	shippingService weaver.Ref[shippingservice.T]
+	emailService    weaver.Ref[emailservice.T] // processes confirmations
+	paymentService  weaver.Ref[paymentservice.T]
+	historyService  weaver.Ref[orderhistoryservice.T]
+
+	orders        *weaver.Workflow[order]
+	confirmations *weaver.Queue[emailservice.Confirmation]
+}
+
+// order is the state of the workflow that places an order.
+type order struct {
+	weaver.AutoMarshal
+	Req           PlaceOrderRequest
+	CartItems     []cartservice.CartItem
+	Total         money.T
+	TransactionID string
+	Order         types.Order
+	PlacedAt      time.Time
 }
 
 func (s *impl) Init(context.Context) error {
-	var err error
-	s.catalogService, err = weaver.Get[productcatalogservice.T](s)
-	if err != nil {
-		return err
-	}
-	s.cartService, err = weaver.Get[cartservice.T](s)
-	if err != nil {
-		return err
-	}
-	s.currencyService, err = weaver.Get[currencyservice.T](s)
-	if err != nil {
-		return err
-	}
-	s.shippingService, err = weaver.Get[shippingservice.T](s)
-	if err != nil {
-		return err
-	}
-	s.emailService, err = weaver.Get[emailservice.T](s)
-	if err != nil {
-		return err
-	}
-	s.paymentService, err = weaver.Get[paymentservice.T](s)
+	// Orders are placed by a workflow, which completes an order even if the
+	// replica placing it fails midway, and refunds the card if the order
+	// can't be shipped.
+	orders, err := weaver.NewWorkflow(s, "orders", weaver.WorkflowOptions{},
+		weaver.WorkflowStep[order]{Name: "charge", Do: s.charge, Compensate: s.refund},
+		weaver.WorkflowStep[order]{Name: "ship", Do: s.ship, Compensate: s.cancelShipment},
+		weaver.WorkflowStep[order]{Name: "empty-cart", Do: s.emptyCart},
+		weaver.WorkflowStep[order]{Name: "email", Do: s.sendConfirmation},
+		weaver.WorkflowStep[order]{Name: "history", Do: s.recordOrder},
+	)
 	if err != nil {
 		return err
 	}
-	return nil
+	s.orders = orders
+
+	// Confirmation emails are sent by the email service in the background,
+	// rather than while the customer waits for the order to be placed.
+	s.confirmations, err = emailservice.NewConfirmations(s)
+	return err
 }
 
 func (s *impl) PlaceOrder(ctx context.Context, req PlaceOrderRequest) (types.Order, error) {
@@ -102,33 +122,90 @@ func (s *impl) PlaceOrder(ctx context.Context, req PlaceOrderRequest) (types.Ord
 		total = money.Must(money.Sum(total, multPrice))
 	}
 
-	txID, err := s.paymentService.Charge(ctx, total, req.CreditCard)
+	o := order{
+		Req:       req,
+		CartItems: prep.cartItems,
+		Total:     total,
+		PlacedAt:  time.Now(),
+		Order: types.Order{
+			OrderID:         uuid.New().String(),
+			ShippingCost:    prep.shippingCostLocalized,
+			ShippingAddress: req.Address,
+			Items:           prep.orderItems,
+		},
+	}
+	o, err = s.orders.Run(ctx, o.Order.OrderID, o)
+	if err != nil {
+		return types.Order{}, err
+	}
+	ordersPlaced.Get(orderLabels{Currency: req.UserCurrency}).Add(1)
+	return o.Order, nil
+}
+
+// charge charges the card of an order. The order id is the idempotency key of
+// the charge, so the card is charged once even if the step runs again.
+func (s *impl) charge(ctx context.Context, id string, o *order) error {
+	txID, err := s.paymentService.Get().Charge(weaver.WithIdempotencyKey(ctx, id), o.Total, o.Req.CreditCard)
 	if err != nil {
-		return types.Order{}, fmt.Errorf("failed to charge card: %w", err)
+		return fmt.Errorf("failed to charge card: %w", err)
 	}
 	s.Logger().Info("payment went through", "transaction_id", txID)
+	o.TransactionID = txID
+	return nil
+}
+
+// refund refunds the charge of an order that can't be shipped.
+func (s *impl) refund(ctx context.Context, _ string, o *order) error {
+	return s.paymentService.Get().Refund(ctx, o.TransactionID)
+}
 
-	shippingTrackingID, err := s.shippingService.ShipOrder(ctx, req.Address, prep.cartItems)
+// ship ships the items of an order.
+func (s *impl) ship(ctx context.Context, id string, o *order) error {
+	trackingID, err := s.shippingService.Get().ShipOrder(weaver.WithIdempotencyKey(ctx, id), o.Req.Address, o.CartItems)
 	if err != nil {
-		return types.Order{}, fmt.Errorf("shipping error: %w", err)
+		return fmt.Errorf("shipping error: %w", err)
 	}
+	o.Order.ShippingTrackingID = trackingID
+	return nil
+}
 
-	_ = s.cartService.EmptyCart(ctx, req.UserID)
+// cancelShipment cancels the shipment of an order.
+func (s *impl) cancelShipment(ctx context.Context, _ string, o *order) error {
+	return s.shippingService.Get().CancelShipment(ctx, o.Order.ShippingTrackingID)
+}
 
-	order := types.Order{
-		OrderID:            uuid.New().String(),
-		ShippingTrackingID: shippingTrackingID,
-		ShippingCost:       prep.shippingCostLocalized,
-		ShippingAddress:    req.Address,
-		Items:              prep.orderItems,
+// emptyCart empties the cart of an order. The order is placed even if the
+// cart can't be emptied.
+func (s *impl) emptyCart(ctx context.Context, _ string, o *order) error {
+	if err := s.cartService.Get().EmptyCart(ctx, o.Req.UserID); err != nil {
+		s.Logger().Error("failed to empty cart", err, "user_id", o.Req.UserID)
 	}
+	return nil
+}
 
-	if err := s.emailService.SendOrderConfirmation(ctx, req.Email, order); err != nil {
-		s.Logger().Error("failed to send order confirmation", err, "email", req.Email)
-	} else {
-		s.Logger().Info("order confirmation email sent", "email", req.Email)
+// sendConfirmation enqueues the confirmation email of an order, which the
+// email service sends in the background. The order id is the id of the job, so
+// that a confirmation is enqueued once. The order is placed even if the email
+// can't be enqueued.
+func (s *impl) sendConfirmation(ctx context.Context, id string, o *order) error {
+	c := emailservice.Confirmation{Email: o.Req.Email, Order: o.Order}
+	if _, err := s.confirmations.Enqueue(ctx, c, weaver.EnqueueOptions{ID: id}); err != nil {
+		s.Logger().Error("failed to enqueue order confirmation", err, "email", o.Req.Email)
 	}
-	return order, nil
+	return nil
+}
+
+// recordOrder adds an order placed by a signed in account to the account's
+// order history. The order is placed even if it can't be recorded.
+func (s *impl) recordOrder(ctx context.Context, _ string, o *order) error {
+	if o.Req.Account == "" {
+		return nil
+	}
+	placed := orderhistoryservice.PlacedOrder{Order: o.Order, Total: o.Total, PlacedAt: o.PlacedAt}
+	if err := s.historyService.Get().RecordOrder(ctx, o.Req.Account, placed); err != nil {
+		s.Logger().Error("failed to record order", err, "account", o.Req.Account)
+	}
+	return nil
 }
 
 type orderPrep struct {
@@ -139,7 +216,7 @@ type orderPrep struct {
 
 func (s *impl) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, userID, userCurrency string, address shippingservice.Address) (orderPrep, error) {
 	var out orderPrep
-	cartItems, err := s.cartService.GetCart(ctx, userID)
+	cartItems, err := s.cartService.Get().GetCart(ctx, userID)
 	if err != nil {
 		return out, fmt.Errorf("failed to get user cart during checkout: %w", err)
 	}
@@ -147,11 +224,11 @@ func (s *impl) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, us
 	if err != nil {
 		return out, fmt.Errorf("failed to prepare order: %w", err)
 	}
-	shippingUSD, err := s.shippingService.GetQuote(ctx, address, cartItems)
+	shippingUSD, err := s.shippingService.Get().GetQuote(ctx, address, cartItems)
 	if err != nil {
 		return out, fmt.Errorf("failed to get shipping quote: %w", err)
 	}
-	shippingPrice, err := s.currencyService.Convert(ctx, shippingUSD, userCurrency)
+	shippingPrice, err := s.currencyService.Get().Convert(ctx, shippingUSD, userCurrency)
 	if err != nil {
 		return out, fmt.Errorf("failed to convert shipping cost to currency: %w", err)
 	}
@@ -165,11 +242,11 @@ func (s *impl) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, us
 func (s *impl) prepOrderItems(ctx context.Context, items []cartservice.CartItem, userCurrency string) ([]types.OrderItem, error) {
 	out := make([]types.OrderItem, len(items))
 	for i, item := range items {
-		product, err := s.catalogService.GetProduct(ctx, item.ProductID)
+		product, err := s.catalogService.Get().GetProduct(ctx, item.ProductID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get product #%q: %w", item.ProductID, err)
 		}
-		price, err := s.currencyService.Convert(ctx, product.PriceUSD, userCurrency)
+		price, err := s.currencyService.Get().Convert(ctx, product.PriceUSD, userCurrency)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert price of %q to %s: %w", item.ProductID, userCurrency, err)
 		}
@@ -4,6 +4,7 @@ package checkoutservice
 import (
 	"context"
 	"fmt"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"go.opentelemetry.io/otel/codes"
@@ -14,15 +15,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, placeOrderMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T", Method: "PlaceOrder"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), placeOrderMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T", Method: "PlaceOrder"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -30,8 +33,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) PlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0 types.Order, err error) {
@@ -48,17 +52,37 @@ func (s t_local_stub) PlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0
 		}()
 	}
 
-	return s.impl.PlaceOrder(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.PlaceOrder(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T", Method: "PlaceOrder", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PlaceOrder(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub              codegen.Stub
+	codec             codegen.Codec
+	interceptor       codegen.Interceptor
 	placeOrderMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) PlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0 types.Order, err error) {
+	if s.interceptor == nil {
+		return s.callPlaceOrder(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T", Method: "PlaceOrder", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPlaceOrder(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callPlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0 types.Order, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.placeOrderMetrics.Count.Add(1)
@@ -86,10 +110,25 @@ func (s t_client_stub) PlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0
 		s.placeOrderMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.placeOrderMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.placeOrderMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.placeOrderMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -110,8 +149,10 @@ func (s t_client_stub) PlaceOrder(ctx context.Context, a0 PlaceOrderRequest) (r0
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -124,6 +165,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) placeOrder(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -133,16 +179,32 @@ func (s t_server_stub) placeOrder(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 PlaceOrderRequest
-	(&a0).WeaverUnmarshal(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PlaceOrder(ctx, a0)
+	var r0 types.Order
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PlaceOrder(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice/T", Method: "PlaceOrder", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PlaceOrder(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -157,20 +219,103 @@ func (x *PlaceOrderRequest) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("PlaceOrderRequest.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(6)
 	enc.String(x.UserID)
 	enc.String(x.UserCurrency)
 	(x.Address).WeaverMarshal(enc)
 	enc.String(x.Email)
 	(x.CreditCard).WeaverMarshal(enc)
+	enc.String(x.Account)
+	enc.EndStruct(start)
 }
 
 func (x *PlaceOrderRequest) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("PlaceOrderRequest.WeaverUnmarshal: nil receiver"))
 	}
-	x.UserID = dec.String()
-	x.UserCurrency = dec.String()
-	(&x.Address).WeaverUnmarshal(dec)
-	x.Email = dec.String()
-	(&x.CreditCard).WeaverUnmarshal(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.UserID = dec.String()
+	}
+	if n > 1 {
+		x.UserCurrency = dec.String()
+	}
+	if n > 2 {
+		(&x.Address).WeaverUnmarshal(dec)
+	}
+	if n > 3 {
+		x.Email = dec.String()
+	}
+	if n > 4 {
+		(&x.CreditCard).WeaverUnmarshal(dec)
+	}
+	if n > 5 {
+		x.Account = dec.String()
+	}
+	dec.EndStruct(end)
+}
+
+var _ codegen.AutoMarshal = &order{}
+
+func (x *order) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("order.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(6)
+	(x.Req).WeaverMarshal(enc)
+	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, x.CartItems)
+	(x.Total).WeaverMarshal(enc)
+	enc.String(x.TransactionID)
+	(x.Order).WeaverMarshal(enc)
+	enc.EncodeBinaryMarshaler(&x.PlacedAt)
+	enc.EndStruct(start)
+}
+
+func (x *order) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("order.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.Req).WeaverUnmarshal(dec)
+	}
+	if n > 1 {
+		x.CartItems = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	}
+	if n > 2 {
+		(&x.Total).WeaverUnmarshal(dec)
+	}
+	if n > 3 {
+		x.TransactionID = dec.String()
+	}
+	if n > 4 {
+		(&x.Order).WeaverUnmarshal(dec)
+	}
+	if n > 5 {
+		dec.DecodeBinaryUnmarshaler(&x.PlacedAt)
+	}
+	dec.EndStruct(end)
+}
+
+func serviceweaver_enc_slice_CartItem_7a7ff11c(enc *codegen.Encoder, arg []cartservice.CartItem) {
+	if arg == nil {
+		enc.Len(-1)
+		return
+	}
+	enc.Len(len(arg))
+	for i := 0; i < len(arg); i++ {
+		(arg[i]).WeaverMarshal(enc)
+	}
+}
+
+func serviceweaver_dec_slice_CartItem_7a7ff11c(dec *codegen.Decoder) []cartservice.CartItem {
+	n := dec.Len()
+	if n == -1 {
+		return nil
+	}
+	res := make([]cartservice.CartItem, n)
+	for i := 0; i < n; i++ {
+		(&res[i]).WeaverUnmarshal(dec)
+	}
+	return res
 }
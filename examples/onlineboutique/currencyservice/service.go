@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/ServiceWeaver/weaver"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types/money"
@@ -39,19 +40,37 @@ type T interface {
 
 type impl struct {
 	weaver.Implements[T]
-	conversionMap map[string]float64
+	conversionMap atomic.Pointer[map[string]float64] // nil until warmed up
 }
 
-func (s *impl) Init(context.Context) error {
+// Warmup loads the rate table. The service isn't ready, and doesn't receive
+// calls from the services configured to start after it, until it's loaded.
+func (s *impl) Warmup(context.Context) error {
 	m, err := createConversionMap()
-	s.conversionMap = m
-	return err
+	if err != nil {
+		return err
+	}
+	s.conversionMap.Store(&m)
+	return nil
+}
+
+// rates returns the rate table, or an error if it isn't loaded yet.
+func (s *impl) rates() (map[string]float64, error) {
+	m := s.conversionMap.Load()
+	if m == nil {
+		return nil, fmt.Errorf("currency rates not loaded yet")
+	}
+	return *m, nil
 }
 
 // GetSupportedCurrencies returns the list of supported currencies.
 func (s *impl) GetSupportedCurrencies(ctx context.Context) ([]string, error) {
 	s.Logger().Info("Getting supported currencies...")
-	return maps.Keys(s.conversionMap), nil
+	rates, err := s.rates()
+	if err != nil {
+		return nil, err
+	}
+	return maps.Keys(rates), nil
 }
 
 // Convert converts between currencies.
@@ -59,16 +78,20 @@ func (s *impl) Convert(ctx context.Context, from money.T, toCode string) (money.
 	unsupportedErr := func(code string) (money.T, error) {
 		return money.T{}, fmt.Errorf("unsupported currency code %q", from.CurrencyCode)
 	}
+	rates, err := s.rates()
+	if err != nil {
+		return money.T{}, err
+	}
 
 	// Convert: from --> EUR
-	fromRate, ok := s.conversionMap[from.CurrencyCode]
+	fromRate, ok := rates[from.CurrencyCode]
 	if !ok {
 		return unsupportedErr(from.CurrencyCode)
 	}
 	euros := carry(float64(from.Units)/fromRate, float64(from.Nanos)/fromRate)
 
 	// Convert: EUR -> toCode
-	toRate, ok := s.conversionMap[toCode]
+	toRate, ok := rates[toCode]
 	if !ok {
 		return unsupportedErr(toCode)
 	}
@@ -13,15 +13,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, getSupportedCurrenciesMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "GetSupportedCurrencies"}), convertMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "Convert"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getSupportedCurrenciesMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "GetSupportedCurrencies"}), convertMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "Convert"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -29,8 +31,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) GetSupportedCurrencies(ctx context.Context) (r0 []string, err error) {
@@ -47,7 +50,14 @@ func (s t_local_stub) GetSupportedCurrencies(ctx context.Context) (r0 []string,
 		}()
 	}
 
-	return s.impl.GetSupportedCurrencies(ctx)
+	if s.interceptor == nil {
+		return s.impl.GetSupportedCurrencies(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "GetSupportedCurrencies"}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetSupportedCurrencies(ctx)
+		return
+	})
+	return
 }
 
 func (s t_local_stub) Convert(ctx context.Context, a0 money.T, a1 string) (r0 money.T, err error) {
@@ -64,18 +74,38 @@ func (s t_local_stub) Convert(ctx context.Context, a0 money.T, a1 string) (r0 mo
 		}()
 	}
 
-	return s.impl.Convert(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Convert(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "Convert", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Convert(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub                          codegen.Stub
+	codec                         codegen.Codec
+	interceptor                   codegen.Interceptor
 	getSupportedCurrenciesMetrics *codegen.MethodMetrics
 	convertMetrics                *codegen.MethodMetrics
 }
 
 func (s t_client_stub) GetSupportedCurrencies(ctx context.Context) (r0 []string, err error) {
+	if s.interceptor == nil {
+		return s.callGetSupportedCurrencies(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "GetSupportedCurrencies"}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetSupportedCurrencies(ctx)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetSupportedCurrencies(ctx context.Context) (r0 []string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getSupportedCurrenciesMetrics.Count.Add(1)
@@ -105,6 +135,20 @@ func (s t_client_stub) GetSupportedCurrencies(ctx context.Context) (r0 []string,
 
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.getSupportedCurrenciesMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getSupportedCurrenciesMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Call the remote method.
 	s.getSupportedCurrenciesMetrics.BytesRequest.Put(0)
 	var results []byte
@@ -122,6 +166,17 @@ func (s t_client_stub) GetSupportedCurrencies(ctx context.Context) (r0 []string,
 }
 
 func (s t_client_stub) Convert(ctx context.Context, a0 money.T, a1 string) (r0 money.T, err error) {
+	if s.interceptor == nil {
+		return s.callConvert(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "Convert", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callConvert(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callConvert(ctx context.Context, a0 money.T, a1 string) (r0 money.T, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.convertMetrics.Count.Add(1)
@@ -149,11 +204,26 @@ func (s t_client_stub) Convert(ctx context.Context, a0 money.T, a1 string) (r0 m
 		s.convertMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.convertMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.convertMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.String(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.convertMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -174,8 +244,10 @@ func (s t_client_stub) Convert(ctx context.Context, a0 money.T, a1 string) (r0 m
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -190,6 +262,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) getSupportedCurrencies(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -201,9 +278,21 @@ func (s t_server_stub) getSupportedCurrencies(ctx context.Context, args []byte)
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetSupportedCurrencies(ctx)
+	var r0 []string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetSupportedCurrencies(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "GetSupportedCurrencies"}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetSupportedCurrencies(ctx)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_string_4af10117(enc, r0)
 	enc.Error(appErr)
@@ -219,18 +308,34 @@ func (s t_server_stub) convert(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 money.T
-	(&a0).WeaverUnmarshal(dec)
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Convert(ctx, a0, a1)
+	var r0 money.T
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Convert(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice/T", Method: "Convert", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Convert(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
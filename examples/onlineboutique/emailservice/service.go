@@ -44,6 +44,29 @@ type impl struct {
 	weaver.Implements[T]
 }
 
+// A Confirmation is an order confirmation email to send.
+type Confirmation struct {
+	weaver.AutoMarshal
+	Email string
+	Order types.Order
+}
+
+// NewConfirmations returns the queue of the order confirmation emails, which
+// the email service sends in the background.
+func NewConfirmations(requester weaver.Instance) (*weaver.Queue[Confirmation], error) {
+	return weaver.NewQueue[Confirmation](requester, "confirmations", weaver.QueueOptions{})
+}
+
+func (s *impl) Init(context.Context) error {
+	confirmations, err := NewConfirmations(s)
+	if err != nil {
+		return err
+	}
+	return confirmations.Process(func(ctx context.Context, c Confirmation) error {
+		return s.SendOrderConfirmation(ctx, c.Email, c.Order)
+	})
+}
+
 // SendOrderConfirmation sends the confirmation email for the order to the
 // given email address.
 func (s *impl) SendOrderConfirmation(ctx context.Context, email string, order types.Order) error {
@@ -3,6 +3,7 @@ package emailservice
 // Code generated by "weaver generate". DO NOT EDIT.
 import (
 	"context"
+	"fmt"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"go.opentelemetry.io/otel/codes"
@@ -13,15 +14,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, sendOrderConfirmationMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T", Method: "SendOrderConfirmation"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), sendOrderConfirmationMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T", Method: "SendOrderConfirmation"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -29,8 +32,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) SendOrderConfirmation(ctx context.Context, a0 string, a1 types.Order) (err error) {
@@ -47,17 +51,35 @@ func (s t_local_stub) SendOrderConfirmation(ctx context.Context, a0 string, a1 t
 		}()
 	}
 
-	return s.impl.SendOrderConfirmation(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.SendOrderConfirmation(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T", Method: "SendOrderConfirmation", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.SendOrderConfirmation(ctx, a0, a1)
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub                         codegen.Stub
+	codec                        codegen.Codec
+	interceptor                  codegen.Interceptor
 	sendOrderConfirmationMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) SendOrderConfirmation(ctx context.Context, a0 string, a1 types.Order) (err error) {
+	if s.interceptor == nil {
+		return s.callSendOrderConfirmation(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T", Method: "SendOrderConfirmation", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callSendOrderConfirmation(ctx, a0, a1)
+	})
+	return
+}
+
+func (s t_client_stub) callSendOrderConfirmation(ctx context.Context, a0 string, a1 types.Order) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.sendOrderConfirmationMetrics.Count.Add(1)
@@ -85,11 +107,26 @@ func (s t_client_stub) SendOrderConfirmation(ctx context.Context, a0 string, a1
 		s.sendOrderConfirmationMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.sendOrderConfirmationMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.sendOrderConfirmationMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	enc.String(a0)
 	(a1).WeaverMarshal(enc)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.sendOrderConfirmationMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -109,8 +146,10 @@ func (s t_client_stub) SendOrderConfirmation(ctx context.Context, a0 string, a1
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -123,6 +162,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) sendOrderConfirmation(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -132,19 +176,61 @@ func (s t_server_stub) sendOrderConfirmation(ctx context.Context, args []byte) (
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 types.Order
-	(&a1).WeaverUnmarshal(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		(&a1).WeaverUnmarshal(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.SendOrderConfirmation(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.SendOrderConfirmation(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/emailservice/T", Method: "SendOrderConfirmation", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.SendOrderConfirmation(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
+
+// AutoMarshal implementations.
+
+var _ codegen.AutoMarshal = &Confirmation{}
+
+func (x *Confirmation) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("Confirmation.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(2)
+	enc.String(x.Email)
+	(x.Order).WeaverMarshal(enc)
+	enc.EndStruct(start)
+}
+
+func (x *Confirmation) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("Confirmation.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Email = dec.String()
+	}
+	if n > 1 {
+		(&x.Order).WeaverUnmarshal(dec)
+	}
+	dec.EndStruct(end)
+}
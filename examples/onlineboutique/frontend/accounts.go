@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice"
+	"golang.org/x/exp/slog"
+)
+
+// currentUser returns the user signed in on the session of the request, or
+// nil if none is. A failure to look the user up is logged, and the request is
+// served as if no user is signed in.
+func (fe *Server) currentUser(r *http.Request) *userservice.User {
+	user, ok, err := fe.userService.Get().GetUser(r.Context(), sessionID(r))
+	if err != nil {
+		logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+		logger.Error("failed to get user", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &user
+}
+
+func (fe *Server) signupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fe.renderAccountForm(w, r, "signup", http.StatusOK, "")
+		return
+	}
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	logger.Debug("signing up")
+	_, newID, err := fe.userService.Get().SignUp(r.Context(), sessionID(r), r.FormValue("email"), r.FormValue("name"), r.FormValue("password"))
+	switch {
+	case errors.Is(err, userservice.ErrEmailTaken), errors.Is(err, userservice.ErrInvalidAccount):
+		fe.renderAccountForm(w, r, "signup", http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		fe.renderHTTPError(r, w, fmt.Errorf("failed to sign up: %w", err), http.StatusInternalServerError)
+		return
+	}
+	fe.replaceSession(w, r, newID)
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
+func (fe *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fe.renderAccountForm(w, r, "login", http.StatusOK, "")
+		return
+	}
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	logger.Debug("signing in")
+	_, newID, err := fe.userService.Get().SignIn(r.Context(), sessionID(r), r.FormValue("email"), r.FormValue("password"))
+	switch {
+	case errors.Is(err, userservice.ErrInvalidCredentials):
+		fe.renderAccountForm(w, r, "login", http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		fe.renderHTTPError(r, w, fmt.Errorf("failed to sign in: %w", err), http.StatusInternalServerError)
+		return
+	}
+	fe.replaceSession(w, r, newID)
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
+// replaceSession replaces the session of the request with the new session
+// that an account was signed in on. The cart of the old session is moved to
+// the new session. A failure to move the cart is logged, and the cart is lost.
+func (fe *Server) replaceSession(w http.ResponseWriter, r *http.Request, newID string) {
+	setSessionCookie(w, r, newID)
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	carts := fe.cartService.Get()
+	items, err := carts.GetCart(r.Context(), sessionID(r))
+	if err != nil {
+		logger.Error("failed to move cart to new session", err)
+		return
+	}
+	for _, item := range items {
+		if err := carts.AddItem(r.Context(), newID, item); err != nil {
+			logger.Error("failed to move cart to new session", err)
+			return
+		}
+	}
+	if err := carts.EmptyCart(r.Context(), sessionID(r)); err != nil {
+		logger.Error("failed to empty old cart", err)
+	}
+}
+
+// renderAccountForm renders the sign up or sign in form, with the provided
+// error message, if any.
+func (fe *Server) renderAccountForm(w http.ResponseWriter, r *http.Request, page string, code int, msg string) {
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	w.WriteHeader(code)
+	if err := templates.ExecuteTemplate(w, page, map[string]interface{}{
+		"session_id":      sessionID(r),
+		"request_id":      r.Context().Value(ctxKeyRequestID{}),
+		"hostname":        fe.hostname,
+		"user_currency":   currentCurrency(r),
+		"show_currency":   false,
+		"error":           msg,
+		"email":           r.FormValue("email"),
+		"name":            r.FormValue("name"),
+		"platform_css":    fe.platform.css,
+		"platform_name":   fe.platform.provider,
+		"is_cymbal_brand": isCymbalBrand,
+	}); err != nil {
+		logger.Error("generate "+page+" page", err)
+	}
+}
+
+func (fe *Server) ordersHandler(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	logger.Debug("view order history")
+	user := fe.currentUser(r)
+	if user == nil {
+		w.Header().Set("Location", "/login")
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+	orders, err := fe.historyService.Get().ListOrders(r.Context(), user.Email)
+	if err != nil {
+		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve orders: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if err := templates.ExecuteTemplate(w, "orders", map[string]interface{}{
+		"session_id":      sessionID(r),
+		"request_id":      r.Context().Value(ctxKeyRequestID{}),
+		"hostname":        fe.hostname,
+		"user":            user,
+		"user_currency":   currentCurrency(r),
+		"show_currency":   false,
+		"orders":          orders,
+		"platform_css":    fe.platform.css,
+		"platform_name":   fe.platform.provider,
+		"is_cymbal_brand": isCymbalBrand,
+	}); err != nil {
+		logger.Error("generate orders page", err)
+	}
+}
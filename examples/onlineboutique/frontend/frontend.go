@@ -15,6 +15,7 @@
 package frontend
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"io/fs"
@@ -28,9 +29,13 @@ import (
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/currencyservice"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/recommendationservice"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -71,47 +76,21 @@ type Server struct {
 	platform platformDetails
 	hostname string
 
-	catalogService        productcatalogservice.T
-	currencyService       currencyservice.T
-	cartService           cartservice.T
-	recommendationService recommendationservice.T
-	checkoutService       checkoutservice.T
-	shippingService       shippingservice.T
-	adService             adservice.T
+	catalogService        weaver.Ref[productcatalogservice.T]
+	currencyService       weaver.Ref[currencyservice.T]
+	cartService           weaver.Ref[cartservice.T]
+	recommendationService weaver.Ref[recommendationservice.T]
+	checkoutService       weaver.Ref[checkoutservice.T]
+	shippingService       weaver.Ref[shippingservice.T]
+	adService             weaver.Ref[adservice.T]
+	userService           weaver.Ref[userservice.T]
+	historyService        weaver.Ref[orderhistoryservice.T]
+	loadGenerator         weaver.Ref[loadgenerator.T]
+	searchService         weaver.Ref[searchservice.T]
 }
 
 // NewServer returns the new application frontend.
 func NewServer(root weaver.Instance) (*Server, error) {
-	// Setup the services.
-	catalogService, err := weaver.Get[productcatalogservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	currencyService, err := weaver.Get[currencyservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	cartService, err := weaver.Get[cartservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	recommendationService, err := weaver.Get[recommendationservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	checkoutService, err := weaver.Get[checkoutservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	shippingService, err := weaver.Get[shippingservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-	adService, err := weaver.Get[adservice.T](root)
-	if err != nil {
-		return nil, err
-	}
-
 	// Find out where we're running.
 	// Set ENV_PLATFORM (default to local if not set; use env var if set;
 	// otherwise detect GCP, which overrides env).
@@ -138,18 +117,25 @@ func NewServer(root weaver.Instance) (*Server, error) {
 
 	// Create the server.
 	s := &Server{
-		root:                  root,
-		platform:              platform,
-		hostname:              hostname,
-		catalogService:        catalogService,
-		currencyService:       currencyService,
-		cartService:           cartService,
-		recommendationService: recommendationService,
-		checkoutService:       checkoutService,
-		shippingService:       shippingService,
-		adService:             adService,
+		root:     root,
+		platform: platform,
+		hostname: hostname,
+	}
+
+	// Setup the services.
+	if err := weaver.Fill(root, s); err != nil {
+		return nil, err
 	}
 
+	// Prime the cache of supported currencies, which every page renders,
+	// before serving any traffic.
+	weaver.OnStart(root, func(ctx context.Context) error {
+		if _, err := s.getCurrencies(ctx); err != nil {
+			return fmt.Errorf("cannot prime supported currencies: %w", err)
+		}
+		return nil
+	})
+
 	// Setup the handler.
 	staticHTML, err := fs.Sub(fs.FS(staticFS), "static")
 	if err != nil {
@@ -179,24 +165,44 @@ func NewServer(root weaver.Instance) (*Server, error) {
 	const head = http.MethodHead
 	r.Handle("/", instrument("home", s.homeHandler, []string{get, head}))
 	r.Handle("/product/", instrument("product", s.productHandler, []string{get, head}))
+	r.Handle("/search", instrument("search", s.searchHandler, []string{get, head}))
 	r.Handle("/cart", instrument("cart", s.cartHandler, []string{get, head, post}))
 	r.Handle("/cart/empty", instrument("cart_empty", s.emptyCartHandler, []string{post}))
 	r.Handle("/setCurrency", instrument("setcurrency", s.setCurrencyHandler, []string{post}))
-	r.Handle("/logout", instrument("logout", s.logoutHandler, []string{get}))
+	r.Handle("/signup", instrument("signup", s.signupHandler, []string{get, head, post}))
+	r.Handle("/login", instrument("login", s.loginHandler, []string{get, head, post}))
+	r.Handle("/logout", instrument("logout", s.logoutHandler, []string{post}))
+	r.Handle("/orders", instrument("orders", s.ordersHandler, []string{get, head}))
 	r.Handle("/cart/checkout", instrument("cart_checkout", s.placeOrderHandler, []string{post}))
 	r.Handle("/static/", weaver.InstrumentHandler("static", http.StripPrefix("/static/", http.FileServer(http.FS(staticHTML)))))
 	r.Handle("/robots.txt", instrument("robots", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "User-agent: *\nDisallow: /") }, nil))
 
 	// No instrumentation of /healthz
-	r.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "ok") })
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if weaver.Draining(s.root) {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
 
 	// Set handler and return.
-	var handler http.Handler = r
 	// TODO(spetrovic): Use the Service Weaver per-component config to provisionaly
 	// add these stats.
-	handler = ensureSessionID(handler)             // add session ID
-	handler = newLogHandler(root, handler)         // add logging
-	handler = otelhttp.NewHandler(handler, "http") // add tracing
+	var chain weaver.HandlerChain
+	chain.Add("tracing", func(h http.Handler) http.Handler {
+		return otelhttp.NewHandler(h, "http")
+	})
+	chain.Add("session", func(h http.Handler) http.Handler {
+		return ensureSessionID(h)
+	}, weaver.After("tracing"))
+	chain.Add("logging", func(h http.Handler) http.Handler {
+		return newLogHandler(root, h)
+	}, weaver.After("session")) // log the session ID
+	handler, err := chain.Handler(r)
+	if err != nil {
+		return nil, err
+	}
 	s.handler = handler
 
 	return s, nil
@@ -207,6 +213,25 @@ func (s *Server) Run(localAddr string) error {
 	if err != nil {
 		return err
 	}
-	s.root.Logger().Debug("Frontend available", "addr", lis)
-	return http.Serve(lis, s.handler)
+	s.root.Logger().Debug("Frontend available", "addr", lis.Addr(), "url", lis.URL())
+
+	// Drive simulated shoppers against the frontend, if the load generator is
+	// enabled in the config.
+	if err := s.loadGenerator.Get().Start(context.Background(), lis.URL().String()); err != nil {
+		s.root.Logger().Error("cannot start load generator", err)
+	}
+
+	// Let in-flight requests, e.g., checkouts, finish when the frontend is
+	// drained.
+	srv := &http.Server{Handler: s.handler}
+	drained := make(chan struct{})
+	weaver.OnDrain(s.root, func(ctx context.Context) error {
+		defer close(drained)
+		return srv.Shutdown(ctx)
+	})
+	if err := srv.Serve(lis); err != http.ErrServerClosed {
+		return err
+	}
+	<-drained
+	return nil
 }
@@ -28,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ServiceWeaver/weaver"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/adservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/cartservice"
 	"github.com/ServiceWeaver/weaver/examples/onlineboutique/checkoutservice"
@@ -42,6 +43,15 @@ const (
 	avoidNoopCurrencyConversionRPC = false
 )
 
+var (
+	// catalogCache caches the product catalog, which every home page lists.
+	catalogCache = weaver.NewCache[string, []productcatalogservice.Product]("frontend/catalog", weaver.CacheOptions{TTL: time.Minute})
+
+	// conversionCache caches currency conversions, keyed by amount and
+	// target currency.
+	conversionCache = weaver.NewCache[string, money.T]("frontend/conversions", weaver.CacheOptions{TTL: time.Minute})
+)
+
 var (
 	isCymbalBrand = strings.ToLower(os.Getenv("CYMBAL_BRANDING")) == "true"
 
@@ -73,12 +83,12 @@ func (fe *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve currencies: %w", err), http.StatusInternalServerError)
 		return
 	}
-	products, err := fe.catalogService.ListProducts(r.Context())
+	products, err := fe.listProducts(r.Context())
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve products: %w", err), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.cartService.GetCart(r.Context(), sessionID(r))
+	cart, err := fe.cartService.Get().GetCart(r.Context(), sessionID(r))
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve cart: %w", err), http.StatusInternalServerError)
 		return
@@ -90,7 +100,7 @@ func (fe *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	ps := make([]productView, len(products))
 	for i, p := range products {
-		price, err := fe.currencyService.Convert(r.Context(), p.PriceUSD, currentCurrency(r))
+		price, err := fe.convertCurrency(r.Context(), p.PriceUSD, currentCurrency(r))
 		if err != nil {
 			fe.renderHTTPError(r, w, fmt.Errorf("failed to do currency conversion for product %s: %w", p.ID, err), http.StatusInternalServerError)
 			return
@@ -102,6 +112,7 @@ func (fe *Server) homeHandler(w http.ResponseWriter, r *http.Request) {
 		"session_id":      sessionID(r),
 		"request_id":      r.Context().Value(ctxKeyRequestID{}),
 		"hostname":        fe.hostname,
+		"user":            fe.currentUser(r),
 		"user_currency":   currentCurrency(r),
 		"show_currency":   true,
 		"currencies":      currencies,
@@ -126,7 +137,7 @@ func (fe *Server) productHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	logger.Debug("serving product page", "id", id, "currency", currentCurrency(r))
 
-	p, err := fe.catalogService.GetProduct(r.Context(), id)
+	p, err := fe.catalogService.Get().GetProduct(r.Context(), id)
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve product: %w", err), http.StatusInternalServerError)
 		return
@@ -137,7 +148,7 @@ func (fe *Server) productHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cart, err := fe.cartService.GetCart(r.Context(), sessionID(r))
+	cart, err := fe.cartService.Get().GetCart(r.Context(), sessionID(r))
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve cart: %w", err), http.StatusInternalServerError)
 		return
@@ -164,6 +175,7 @@ func (fe *Server) productHandler(w http.ResponseWriter, r *http.Request) {
 		"session_id":      sessionID(r),
 		"request_id":      r.Context().Value(ctxKeyRequestID{}),
 		"hostname":        fe.hostname,
+		"user":            fe.currentUser(r),
 		"ad":              fe.chooseAd(r.Context(), p.Categories, logger),
 		"user_currency":   currentCurrency(r),
 		"show_currency":   true,
@@ -179,6 +191,62 @@ func (fe *Server) productHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (fe *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
+	query := strings.TrimSpace(r.FormValue("q"))
+	logger.Debug("searching products", "query", query)
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve currencies: %w", err), http.StatusInternalServerError)
+		return
+	}
+	var products []productcatalogservice.Product
+	if query != "" {
+		products, err = fe.searchService.Get().Search(r.Context(), query)
+		if err != nil {
+			fe.renderHTTPError(r, w, fmt.Errorf("could not search products: %w", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	cart, err := fe.cartService.Get().GetCart(r.Context(), sessionID(r))
+	if err != nil {
+		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve cart: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	type productView struct {
+		Item  productcatalogservice.Product
+		Price money.T
+	}
+	ps := make([]productView, len(products))
+	for i, p := range products {
+		price, err := fe.convertCurrency(r.Context(), p.PriceUSD, currentCurrency(r))
+		if err != nil {
+			fe.renderHTTPError(r, w, fmt.Errorf("failed to do currency conversion for product %s: %w", p.ID, err), http.StatusInternalServerError)
+			return
+		}
+		ps[i] = productView{p, price}
+	}
+
+	if err := templates.ExecuteTemplate(w, "search", map[string]interface{}{
+		"session_id":      sessionID(r),
+		"request_id":      r.Context().Value(ctxKeyRequestID{}),
+		"hostname":        fe.hostname,
+		"user":            fe.currentUser(r),
+		"user_currency":   currentCurrency(r),
+		"show_currency":   true,
+		"currencies":      currencies,
+		"query":           query,
+		"products":        ps,
+		"cart_size":       cartSize(cart),
+		"platform_css":    fe.platform.css,
+		"platform_name":   fe.platform.provider,
+		"is_cymbal_brand": isCymbalBrand,
+	}); err != nil {
+		logger.Error("generate search page", err)
+	}
+}
+
 func (fe *Server) cartHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet || r.Method == http.MethodHead {
 		fe.viewCartHandler(w, r)
@@ -202,13 +270,13 @@ func (fe *Server) addToCartHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	logger.Debug("adding to cart", "product", productID, "quantity", quantity)
 
-	p, err := fe.catalogService.GetProduct(r.Context(), productID)
+	p, err := fe.catalogService.Get().GetProduct(r.Context(), productID)
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve product: %w", err), http.StatusInternalServerError)
 		return
 	}
 
-	if err := fe.cartService.AddItem(r.Context(), sessionID(r), cartservice.CartItem{
+	if err := fe.cartService.Get().AddItem(r.Context(), sessionID(r), cartservice.CartItem{
 		ProductID: p.ID,
 		Quantity:  int32(quantity),
 	}); err != nil {
@@ -223,7 +291,7 @@ func (fe *Server) emptyCartHandler(w http.ResponseWriter, r *http.Request) {
 	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
 	logger.Debug("emptying cart")
 
-	if err := fe.cartService.EmptyCart(r.Context(), sessionID(r)); err != nil {
+	if err := fe.cartService.Get().EmptyCart(r.Context(), sessionID(r)); err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("failed to empty cart: %w", err), http.StatusInternalServerError)
 		return
 	}
@@ -239,7 +307,7 @@ func (fe *Server) viewCartHandler(w http.ResponseWriter, r *http.Request) {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve currencies: %w", err), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.cartService.GetCart(r.Context(), sessionID(r))
+	cart, err := fe.cartService.Get().GetCart(r.Context(), sessionID(r))
 	if err != nil {
 		fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve cart: %w", err), http.StatusInternalServerError)
 		return
@@ -265,7 +333,7 @@ func (fe *Server) viewCartHandler(w http.ResponseWriter, r *http.Request) {
 	items := make([]cartItemView, len(cart))
 	totalPrice := money.T{CurrencyCode: currentCurrency(r)}
 	for i, item := range cart {
-		p, err := fe.catalogService.GetProduct(r.Context(), item.ProductID)
+		p, err := fe.catalogService.Get().GetProduct(r.Context(), item.ProductID)
 		if err != nil {
 			fe.renderHTTPError(r, w, fmt.Errorf("could not retrieve product #%s: %w", item.ProductID, err), http.StatusInternalServerError)
 			return
@@ -290,6 +358,7 @@ func (fe *Server) viewCartHandler(w http.ResponseWriter, r *http.Request) {
 		"session_id":       sessionID(r),
 		"request_id":       r.Context().Value(ctxKeyRequestID{}),
 		"hostname":         fe.hostname,
+		"user":             fe.currentUser(r),
 		"user_currency":    currentCurrency(r),
 		"currencies":       currencies,
 		"recommendations":  recommendations,
@@ -324,8 +393,18 @@ func (fe *Server) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
 		ccCVV, _      = strconv.ParseInt(r.FormValue("credit_card_cvv"), 10, 32)
 	)
 
-	order, err := fe.checkoutService.PlaceOrder(r.Context(), checkoutservice.PlaceOrderRequest{
-		Email: email,
+	// Retries of the call, e.g., after the checkout service is briefly
+	// unreachable, must not charge the customer twice.
+	requestID, _ := r.Context().Value(ctxKeyRequestID{}).(string)
+	ctx := weaver.WithIdempotencyKey(r.Context(), requestID)
+	user := fe.currentUser(r)
+	var account string
+	if user != nil {
+		account = user.Email // record the order in the account's history
+	}
+	order, err := fe.checkoutService.Get().PlaceOrder(ctx, checkoutservice.PlaceOrderRequest{
+		Email:   email,
+		Account: account,
 		CreditCard: paymentservice.CreditCardInfo{
 			Number:          ccNumber,
 			ExpirationMonth: time.Month(ccMonth),
@@ -364,6 +443,7 @@ func (fe *Server) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
 		"session_id":      sessionID(r),
 		"request_id":      r.Context().Value(ctxKeyRequestID{}),
 		"hostname":        fe.hostname,
+		"user":            user,
 		"user_currency":   currentCurrency(r),
 		"show_currency":   false,
 		"currencies":      currencies,
@@ -381,6 +461,9 @@ func (fe *Server) placeOrderHandler(w http.ResponseWriter, r *http.Request) {
 func (fe *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
 	logger := r.Context().Value(ctxKeyLogger{}).(*slog.Logger)
 	logger.Debug("logging out")
+	if err := fe.userService.Get().SignOut(r.Context(), sessionID(r)); err != nil {
+		logger.Error("failed to sign out", err)
+	}
 	for _, c := range r.Cookies() {
 		c.Expires = time.Now().Add(-time.Hour * 24 * 365)
 		c.MaxAge = -1
@@ -415,7 +498,7 @@ func (fe *Server) setCurrencyHandler(w http.ResponseWriter, r *http.Request) {
 func (fe *Server) chooseAd(ctx context.Context, ctxKeys []string, logger *slog.Logger) *adservice.Ad {
 	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
 	defer cancel()
-	ads, err := fe.adService.GetAds(ctx, ctxKeys)
+	ads, err := fe.adService.Get().GetAds(ctx, ctxKeys)
 	if err != nil {
 		logger.Error("failed to retrieve ads", err)
 		return nil
@@ -424,7 +507,7 @@ func (fe *Server) chooseAd(ctx context.Context, ctxKeys []string, logger *slog.L
 }
 
 func (fe *Server) getCurrencies(ctx context.Context) ([]string, error) {
-	codes, err := fe.currencyService.GetSupportedCurrencies(ctx)
+	codes, err := fe.currencyService.Get().GetSupportedCurrencies(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -437,15 +520,25 @@ func (fe *Server) getCurrencies(ctx context.Context) ([]string, error) {
 	return out, nil
 }
 
-func (fe *Server) convertCurrency(ctx context.Context, money money.T, currency string) (money.T, error) {
-	if avoidNoopCurrencyConversionRPC && money.CurrencyCode == currency {
-		return money, nil
+func (fe *Server) convertCurrency(ctx context.Context, amount money.T, currency string) (money.T, error) {
+	if avoidNoopCurrencyConversionRPC && amount.CurrencyCode == currency {
+		return amount, nil
 	}
-	return fe.currencyService.Convert(ctx, money, currency)
+	key := fmt.Sprintf("%d %d %s %s", amount.Units, amount.Nanos, amount.CurrencyCode, currency)
+	return conversionCache.GetOrLoad(ctx, fe.root, key, func(ctx context.Context, _ string) (money.T, error) {
+		return fe.currencyService.Get().Convert(ctx, amount, currency)
+	})
+}
+
+// listProducts returns the product catalog.
+func (fe *Server) listProducts(ctx context.Context) ([]productcatalogservice.Product, error) {
+	return catalogCache.GetOrLoad(ctx, fe.root, "all", func(ctx context.Context, _ string) ([]productcatalogservice.Product, error) {
+		return fe.catalogService.Get().ListProducts(ctx)
+	})
 }
 
 func (fe *Server) getShippingQuote(ctx context.Context, items []cartservice.CartItem, currency string) (money.T, error) {
-	quote, err := fe.shippingService.GetQuote(ctx, shippingservice.Address{}, items)
+	quote, err := fe.shippingService.Get().GetQuote(ctx, shippingservice.Address{}, items)
 	if err != nil {
 		return money.T{}, err
 	}
@@ -453,13 +546,17 @@ func (fe *Server) getShippingQuote(ctx context.Context, items []cartservice.Cart
 }
 
 func (fe *Server) getRecommendations(ctx context.Context, userID string, productIDs []string) ([]productcatalogservice.Product, error) {
-	recommendationIDs, err := fe.recommendationService.ListRecommendations(ctx, userID, productIDs)
+	recommendationIDs, err := fe.recommendationService.Get().ListRecommendations(ctx, userID, productIDs)
+	if errors.Is(err, weaver.ErrShedLoad) {
+		// Recommendations are not critical; render the page without them.
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	out := make([]productcatalogservice.Product, len(recommendationIDs))
 	for i, id := range recommendationIDs {
-		p, err := fe.catalogService.GetProduct(ctx, id)
+		p, err := fe.catalogService.Get().GetProduct(ctx, id)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get recommended product info (#%s): %w", id, err)
 		}
@@ -95,18 +95,31 @@ func ensureSessionID(next http.Handler) http.HandlerFunc {
 		if err == http.ErrNoCookie {
 			u, _ := uuid.NewRandom()
 			sessionID = u.String()
-			http.SetCookie(w, &http.Cookie{
-				Name:   cookieSessionID,
-				Value:  sessionID,
-				MaxAge: cookieMaxAge,
-			})
+			setSessionCookie(w, r, sessionID)
 		} else if err != nil {
 			return
 		} else {
 			sessionID = c.Value
 		}
 		ctx := context.WithValue(r.Context(), ctxKeySessionID{}, sessionID)
+		// Propagate the session id to the components serving the request.
+		ctx = weaver.WithMetadata(ctx, "session_id", sessionID)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	}
 }
+
+// setSessionCookie sets the session cookie of the response to the provided
+// session id. The cookie is hidden from scripts, isn't sent with cross-site
+// subrequests, and is only sent over TLS if it was set over TLS.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieSessionID,
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil,
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgenerator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds the duration of a flow.
+const requestTimeout = 10 * time.Second
+
+var currencies = []string{"EUR", "USD", "JPY", "CAD", "GBP", "TRY"}
+
+// queries are the search queries of the shoppers.
+var queries = []string{"sunglasses", "watch", "kitchen", "cotton", "hairdryer", "mug", "candle holder", "bamboo glass jar"}
+
+// A flow is a sequence of requests made by a shopper.
+type flow struct {
+	name   string
+	weight int // the relative frequency of the flow
+	run    func(context.Context, *impl, *user) error
+}
+
+// flows are the flows of the shoppers, weighted like the tasks of the load
+// generator of the original Online Boutique, plus searches.
+var flows = []flow{
+	{"index", 1, index},
+	{"set_currency", 2, setCurrency},
+	{"browse_product", 10, browseProduct},
+	{"search", 3, search},
+	{"add_to_cart", 2, addToCart},
+	{"view_cart", 3, viewCart},
+	{"checkout", 1, checkout},
+}
+
+// chooseFlow returns a random flow, chosen according to the flow weights.
+func chooseFlow() flow {
+	total := 0
+	for _, f := range flows {
+		total += f.weight
+	}
+	n := rand.Intn(total)
+	for _, f := range flows {
+		if n < f.weight {
+			return f
+		}
+		n -= f.weight
+	}
+	panic("unreachable")
+}
+
+// user is a simulated shopper. Every user keeps its cookies, and thus its
+// session and cart, across flows.
+type user struct {
+	target string
+	client *http.Client
+}
+
+func newUser(target string) *user {
+	jar, _ := cookiejar.New(nil) // never fails
+	return &user{
+		target: strings.TrimSuffix(target, "/"),
+		client: &http.Client{Jar: jar},
+	}
+}
+
+// get sends a GET request for the provided path.
+func (u *user) get(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.target+path, nil)
+	if err != nil {
+		return err
+	}
+	return u.do(req)
+}
+
+// post sends a POST request of the provided form to the provided path.
+func (u *user) post(ctx context.Context, path string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.target+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return u.do(req)
+}
+
+// do sends a request, and reads and discards its response.
+func (u *user) do(req *http.Request) error {
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return nil
+}
+
+func index(ctx context.Context, _ *impl, u *user) error {
+	return u.get(ctx, "/")
+}
+
+func setCurrency(ctx context.Context, _ *impl, u *user) error {
+	currency := currencies[rand.Intn(len(currencies))]
+	return u.post(ctx, "/setCurrency", url.Values{"currency_code": {currency}})
+}
+
+func browseProduct(ctx context.Context, g *impl, u *user) error {
+	id, err := g.randomProduct(ctx)
+	if err != nil {
+		return err
+	}
+	return u.get(ctx, "/product/"+id)
+}
+
+func search(ctx context.Context, _ *impl, u *user) error {
+	query := queries[rand.Intn(len(queries))]
+	return u.get(ctx, "/search?"+url.Values{"q": {query}}.Encode())
+}
+
+func addToCart(ctx context.Context, g *impl, u *user) error {
+	id, err := g.randomProduct(ctx)
+	if err != nil {
+		return err
+	}
+	if err := u.get(ctx, "/product/"+id); err != nil {
+		return err
+	}
+	quantity := 1 + rand.Intn(10)
+	return u.post(ctx, "/cart", url.Values{
+		"product_id": {id},
+		"quantity":   {strconv.Itoa(quantity)},
+	})
+}
+
+func viewCart(ctx context.Context, _ *impl, u *user) error {
+	return u.get(ctx, "/cart")
+}
+
+func checkout(ctx context.Context, g *impl, u *user) error {
+	if err := addToCart(ctx, g, u); err != nil {
+		return err
+	}
+	year := time.Now().Year() + 1 + rand.Intn(4)
+	return u.post(ctx, "/cart/checkout", url.Values{
+		"email":                        {"someone@example.com"},
+		"street_address":               {"1600 Amphitheatre Parkway"},
+		"zip_code":                     {"94043"},
+		"city":                         {"Mountain View"},
+		"state":                        {"CA"},
+		"country":                      {"United States"},
+		"credit_card_number":           {"4432-8015-6152-0454"},
+		"credit_card_expiration_month": {strconv.Itoa(1 + rand.Intn(12))},
+		"credit_card_expiration_year":  {strconv.Itoa(year)},
+		"credit_card_cvv":              {"672"},
+	})
+}
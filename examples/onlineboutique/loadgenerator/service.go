@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadgenerator implements a component that drives simulated shoppers
+// against the frontend, like the load generator of the original Online
+// Boutique.
+package loadgenerator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+)
+
+const (
+	defaultUsers       = 10
+	defaultMaxInFlight = 100
+	maxRPS             = 10000
+)
+
+type flowLabels struct {
+	Flow   string `values:"index,set_currency,browse_product,search,add_to_cart,view_cart,checkout"`
+	Status string `values:"ok,error"`
+}
+
+type latencyLabels struct {
+	Flow string `values:"index,set_currency,browse_product,search,add_to_cart,view_cart,checkout"`
+}
+
+var (
+	flowsRun = weaver.NewCounterMap[flowLabels](
+		"boutique_loadgen_flows",
+		"Number of flows run by the load generator, by flow and outcome",
+	)
+	flowLatencies = weaver.NewHistogramMap[latencyLabels](
+		"boutique_loadgen_flow_latency_ms",
+		"Latency of the flows run by the load generator, in milliseconds, by flow",
+		[]float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+	)
+	flowsSkipped = weaver.NewCounter(
+		"boutique_loadgen_flows_skipped",
+		"Number of flows not run because too many flows were in flight",
+	)
+)
+
+// T generates load against the frontend. It is disabled unless its rps is
+// set in the config:
+//
+//	["github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T"]
+//	rps = 5
+type T interface {
+	// Start starts generating load against the frontend served at the
+	// provided URL, unless the load generator is disabled, or already
+	// started. The target in the config, if any, overrides the URL.
+	Start(ctx context.Context, frontendURL string) error
+}
+
+type config struct {
+	RPS         float64 `toml:"rps"`           // flows started per second; zero disables the generator
+	Target      string  `toml:"target"`        // URL of the frontend
+	Users       int     `toml:"users"`         // number of simulated users, each with its own session
+	MaxInFlight int     `toml:"max_in_flight"` // maximum number of flows run at once
+}
+
+func (cfg *config) Validate() error {
+	if cfg.RPS < 0 || cfg.RPS > maxRPS {
+		return fmt.Errorf("rps must be between 0 and %d, got %v", maxRPS, cfg.RPS)
+	}
+	if cfg.Users < 0 {
+		return fmt.Errorf("negative users %d", cfg.Users)
+	}
+	if cfg.MaxInFlight < 0 {
+		return fmt.Errorf("negative max_in_flight %d", cfg.MaxInFlight)
+	}
+	if cfg.Target != "" {
+		if _, err := url.Parse(cfg.Target); err != nil {
+			return fmt.Errorf("invalid target %q: %w", cfg.Target, err)
+		}
+	}
+	return nil
+}
+
+type impl struct {
+	weaver.Implements[T]
+	weaver.WithConfig[config]
+	weaver.WithRouter[router]
+
+	catalogService weaver.Ref[productcatalogservice.T]
+
+	ctx    context.Context // cancelled when the process drains
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	started    bool
+	productIDs []string // the ids of the products in the catalog, once listed
+}
+
+func (g *impl) Init(context.Context) error {
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	weaver.OnDrain(g, func(context.Context) error {
+		g.cancel()
+		return nil
+	})
+	return nil
+}
+
+// Shutdown stops generating load.
+func (g *impl) Shutdown(context.Context) error {
+	g.cancel()
+	return nil
+}
+
+// Start starts generating load against the frontend served at the provided
+// URL.
+func (g *impl) Start(_ context.Context, frontendURL string) error {
+	cfg := g.Config()
+	if cfg.RPS == 0 {
+		return nil
+	}
+	target := frontendURL
+	if cfg.Target != "" {
+		target = cfg.Target
+	}
+	if _, err := url.Parse(target); err != nil {
+		return fmt.Errorf("invalid frontend URL %q: %w", target, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		return nil
+	}
+	g.started = true
+	g.Logger().Info("Generating load", "target", target, "rps", cfg.RPS)
+	go g.run(target, cfg)
+	return nil
+}
+
+// run starts cfg.RPS flows per second, of randomly chosen users, against the
+// target, until g.ctx is cancelled.
+func (g *impl) run(target string, cfg *config) {
+	users := make([]*user, cfg.Users)
+	if len(users) == 0 {
+		users = make([]*user, defaultUsers)
+	}
+	for i := range users {
+		users[i] = newUser(target)
+	}
+	maxInFlight := cfg.MaxInFlight
+	if maxInFlight == 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+	inFlight := make(chan struct{}, maxInFlight)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.RPS))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			flowsSkipped.Add(1)
+			continue
+		}
+		go func() {
+			defer func() { <-inFlight }()
+			g.runFlow(users[rand.Intn(len(users))])
+		}()
+	}
+}
+
+// runFlow runs a randomly chosen flow for the provided user, and records its
+// outcome and latency.
+func (g *impl) runFlow(u *user) {
+	f := chooseFlow()
+	ctx, cancel := context.WithTimeout(g.ctx, requestTimeout)
+	defer cancel()
+	start := time.Now()
+	err := f.run(ctx, g, u)
+	if g.ctx.Err() != nil {
+		return // the generator is stopping
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+		g.Logger().Debug("flow failed", "flow", f.name, "err", err)
+	}
+	flowsRun.Get(flowLabels{Flow: f.name, Status: status}).Add(1)
+	flowLatencies.Get(latencyLabels{Flow: f.name}).Put(float64(time.Since(start).Milliseconds()))
+}
+
+// randomProduct returns the id of a random product of the catalog.
+func (g *impl) randomProduct(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	ids := g.productIDs
+	g.mu.Unlock()
+	if len(ids) == 0 {
+		products, err := g.catalogService.Get().ListProducts(ctx)
+		if err != nil {
+			return "", err
+		}
+		if len(products) == 0 {
+			return "", fmt.Errorf("empty catalog")
+		}
+		ids = make([]string, len(products))
+		for i, p := range products {
+			ids[i] = p.ID
+		}
+		g.mu.Lock()
+		g.productIDs = ids
+		g.mu.Unlock()
+	}
+	return ids[rand.Intn(len(ids))], nil
+}
+
+// router routes every call to Start to the same replica, so that a single
+// replica generates load, however many frontends there are.
+type router struct{}
+
+func (router) Start(context.Context, string) string { return "" }
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgenerator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+	"github.com/ServiceWeaver/weaver/weavertest"
+)
+
+const configKey = `["github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T"]`
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) ListProducts(context.Context) ([]productcatalogservice.Product, error) {
+	return []productcatalogservice.Product{{ID: "OLJCESPC7Z"}}, nil
+}
+
+func (fakeCatalog) GetProduct(context.Context, string) (productcatalogservice.Product, error) {
+	return productcatalogservice.Product{ID: "OLJCESPC7Z"}, nil
+}
+
+func (fakeCatalog) SearchProducts(context.Context, string) ([]productcatalogservice.Product, error) {
+	return nil, nil
+}
+
+// frontend is a fake frontend that records the requests it receives. It
+// starts a session for every request without one.
+type frontend struct {
+	mu       sync.Mutex
+	requests []string // "METHOD /path" of every request
+	sessions int      // number of requests with a session
+}
+
+func (f *frontend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if _, err := r.Cookie("session"); err == nil {
+		f.sessions++
+	} else {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s", Path: "/"})
+	}
+}
+
+func (f *frontend) get() (requests []string, sessions int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...), f.sessions
+}
+
+func start(t *testing.T, config string) *frontend {
+	t.Helper()
+	f := &frontend{}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{
+		SingleProcess: true,
+		Config:        config,
+		Fakes:         []weavertest.FakeComponent{weavertest.Fake[productcatalogservice.T](fakeCatalog{})},
+	})
+	generator, err := weaver.Get[T](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.Start(ctx, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestGeneratesLoad(t *testing.T) {
+	// Test plan: Generate load with a single user against a fake frontend.
+	// Check that the requests are the ones made by the flows, and that the
+	// user keeps its session across requests.
+	f := start(t, configKey+"\nrps = 200\nusers = 1\n")
+	var requests []string
+	var sessions int
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if requests, sessions = f.get(); len(requests) >= 50 {
+			break
+		}
+	}
+	if len(requests) < 50 {
+		t.Fatalf("got %d requests, want at least 50", len(requests))
+	}
+
+	known := []string{
+		"GET /", "POST /setCurrency", "GET /product/OLJCESPC7Z", "GET /search",
+		"POST /cart", "GET /cart", "POST /cart/checkout",
+	}
+	for _, r := range requests {
+		found := false
+		for _, k := range known {
+			found = found || r == k
+		}
+		if !found {
+			t.Errorf("unexpected request %q", r)
+		}
+	}
+	if sessions == 0 {
+		t.Error("no request carried the session started by the frontend")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	f := start(t, "")
+	time.Sleep(100 * time.Millisecond)
+	if requests, _ := f.get(); len(requests) != 0 {
+		t.Fatalf("disabled generator made requests %v", requests)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cfg  config
+		err  string // substring of the expected error, or empty
+	}{
+		{"Zero", config{}, ""},
+		{"Valid", config{RPS: 10, Target: "http://localhost:12345", Users: 5, MaxInFlight: 2}, ""},
+		{"NegativeRPS", config{RPS: -1}, "rps"},
+		{"TooManyRPS", config{RPS: maxRPS + 1}, "rps"},
+		{"NegativeUsers", config{Users: -1}, "users"},
+		{"NegativeMaxInFlight", config{MaxInFlight: -1}, "max_in_flight"},
+		{"InvalidTarget", config{Target: "http://[::1"}, "target"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("Validate: %v", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Fatalf("Validate: got %v, want an error about %s", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package loadgenerator
+
+// Code generated by "weaver generate". DO NOT EDIT.
+import (
+	"context"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"time"
+)
+
+func init() {
+	codegen.Register(codegen.Registration{
+		Name:     "github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T",
+		Iface:    reflect.TypeOf((*T)(nil)).Elem(),
+		New:      func() any { return &impl{} },
+		ConfigFn: func(i any) any { return i.(*impl).WithConfig.Config() },
+		Routed:   true,
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), startMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T", Method: "Start"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
+}
+
+// Local stub implementations.
+
+type t_local_stub struct {
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s t_local_stub) Start(ctx context.Context, a0 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "loadgenerator.T.Start", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Start(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T", Method: "Start", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.Start(ctx, a0)
+	})
+	return
+}
+
+// Client stub implementations.
+
+type t_client_stub struct {
+	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
+	startMetrics *codegen.MethodMetrics
+}
+
+func (s t_client_stub) Start(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callStart(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T", Method: "Start", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callStart(ctx, a0)
+	})
+	return
+}
+
+func (s t_client_stub) callStart(ctx context.Context, a0 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.startMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "loadgenerator.T.Start", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.startMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.startMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r router
+	shardKey := _hashT(r.Start(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.startMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.startMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.startMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.startMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+// Server stub implementations.
+
+type t_server_stub struct {
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "Start":
+		return s.start
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s t_server_stub) start(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+	var r router
+	s.addLoad(_hashT(r.Start(ctx, a0)), 1.0)
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Start(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/loadgenerator/T", Method: "Start", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.Start(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+// Router methods.
+
+// _hashT returns a 64 bit hash of the provided value.
+func _hashT(r string) uint64 {
+	var h codegen.Hasher
+	h.WriteString(string(r))
+	return h.Sum64()
+}
+
+// _orderedCodeT returns an order-preserving serialization of the provided value.
+func _orderedCodeT(r string) codegen.OrderedCode {
+	var enc codegen.OrderedEncoder
+	enc.WriteString(string(r))
+	return enc.Encode()
+}
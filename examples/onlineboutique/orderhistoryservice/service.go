@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orderhistoryservice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types/money"
+)
+
+// maxOrders is the maximum number of orders kept per user. Older orders are
+// dropped.
+const maxOrders = 100
+
+// PlacedOrder is an order placed by a user.
+type PlacedOrder struct {
+	weaver.AutoMarshal
+	Order    types.Order
+	Total    money.T
+	PlacedAt time.Time
+}
+
+// history is the order history of a user, keyed by user id in the history
+// store. Orders are sorted from oldest to newest.
+type history struct {
+	weaver.AutoMarshal
+	Orders []PlacedOrder
+}
+
+// histories is kept in memory, unless it is configured in a
+// [serviceweaver.stores] section of the config.
+var histories = weaver.NewStore[string, history]("orders/history")
+
+// T keeps the orders placed by every user.
+type T interface {
+	// RecordOrder adds an order to the history of a user. Recording the same
+	// order more than once has no effect.
+	RecordOrder(ctx context.Context, userID string, order PlacedOrder) error
+
+	// ListOrders returns the orders placed by a user, newest first.
+	ListOrders(ctx context.Context, userID string) ([]PlacedOrder, error)
+}
+
+type impl struct {
+	weaver.Implements[T]
+	weaver.WithRouter[router]
+
+	// The calls for a user are routed to the same replica, which serializes
+	// the updates of the user's history, since stores are not
+	// transactional.
+	mu sync.Mutex
+}
+
+// RecordOrder adds an order to the history of a user.
+func (s *impl) RecordOrder(ctx context.Context, userID string, order PlacedOrder) error {
+	s.Logger().Info("RecordOrder called", "userID", userID, "orderID", order.Order.OrderID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, _, err := histories.Get(ctx, s, userID)
+	if err != nil {
+		return err
+	}
+	for _, o := range h.Orders {
+		if o.Order.OrderID == order.Order.OrderID {
+			return nil
+		}
+	}
+	h.Orders = append(h.Orders, order)
+	if len(h.Orders) > maxOrders {
+		h.Orders = h.Orders[len(h.Orders)-maxOrders:]
+	}
+	return histories.Put(ctx, s, userID, h)
+}
+
+// ListOrders returns the orders placed by a user, newest first.
+func (s *impl) ListOrders(ctx context.Context, userID string) ([]PlacedOrder, error) {
+	h, _, err := histories.Get(ctx, s, userID)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]PlacedOrder, len(h.Orders))
+	for i, o := range h.Orders {
+		orders[len(orders)-1-i] = o
+	}
+	return orders, nil
+}
+
+type router struct{}
+
+func (router) RecordOrder(_ context.Context, userID string, _ PlacedOrder) string { return userID }
+func (router) ListOrders(_ context.Context, userID string) string                 { return userID }
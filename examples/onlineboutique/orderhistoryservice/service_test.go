@@ -0,0 +1,125 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orderhistoryservice
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/types"
+	"github.com/ServiceWeaver/weaver/weavertest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func placed(id string) PlacedOrder {
+	return PlacedOrder{
+		Order:    types.Order{OrderID: id},
+		PlacedAt: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// ids returns the ids of the provided orders.
+func ids(orders []PlacedOrder) []string {
+	var ids []string
+	for _, o := range orders {
+		ids = append(ids, o.Order.OrderID)
+	}
+	return ids
+}
+
+func TestOrderHistory(t *testing.T) {
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true})
+	history, err := weaver.Get[T](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A user without orders has an empty history.
+	orders, err := history.ListOrders(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Fatalf("ListOrders(alice): got %v, want none", ids(orders))
+	}
+
+	// Orders are listed newest first, recording an order twice has no
+	// effect, and the histories of users are separate.
+	for _, o := range []struct{ user, id string }{
+		{"alice", "a1"},
+		{"bob", "b1"},
+		{"alice", "a2"},
+		{"alice", "a1"},
+		{"alice", "a3"},
+	} {
+		if err := history.RecordOrder(ctx, o.user, placed(o.id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for user, want := range map[string][]string{
+		"alice": {"a3", "a2", "a1"},
+		"bob":   {"b1"},
+	} {
+		orders, err := history.ListOrders(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, ids(orders)); diff != "" {
+			t.Errorf("ListOrders(%s) (-want +got):\n%s", user, diff)
+		}
+	}
+
+	// The recorded orders round trip.
+	orders, err = history.ListOrders(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]PlacedOrder{placed("b1")}, orders); diff != "" {
+		t.Errorf("ListOrders(bob) (-want +got):\n%s", diff)
+	}
+}
+
+func TestOrderHistoryKeepsNewest(t *testing.T) {
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true})
+	history, err := weaver.Get[T](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = maxOrders + 5
+	for i := 0; i < n; i++ {
+		if err := history.RecordOrder(ctx, "alice", placed(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orders, err := history.ListOrders(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(orders), maxOrders; got != want {
+		t.Fatalf("ListOrders: got %d orders, want %d", got, want)
+	}
+	newest, oldest := orders[0].Order.OrderID, orders[len(orders)-1].Order.OrderID
+	if want := fmt.Sprint(n - 1); newest != want {
+		t.Errorf("newest order: got %s, want %s", newest, want)
+	}
+	if want := fmt.Sprint(n - maxOrders); oldest != want {
+		t.Errorf("oldest order: got %s, want %s", oldest, want)
+	}
+}
@@ -0,0 +1,457 @@
+package orderhistoryservice
+
+// Code generated by "weaver generate". DO NOT EDIT.
+import (
+	"context"
+	"fmt"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"time"
+)
+
+func init() {
+	codegen.Register(codegen.Registration{
+		Name:   "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T",
+		Iface:  reflect.TypeOf((*T)(nil)).Elem(),
+		New:    func() any { return &impl{} },
+		Routed: true,
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), recordOrderMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "RecordOrder"}), listOrdersMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "ListOrders"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
+}
+
+// Local stub implementations.
+
+type t_local_stub struct {
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s t_local_stub) RecordOrder(ctx context.Context, a0 string, a1 PlacedOrder) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "orderhistoryservice.T.RecordOrder", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.RecordOrder(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "RecordOrder", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.RecordOrder(ctx, a0, a1)
+	})
+	return
+}
+
+func (s t_local_stub) ListOrders(ctx context.Context, a0 string) (r0 []PlacedOrder, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "orderhistoryservice.T.ListOrders", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.ListOrders(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "ListOrders", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.ListOrders(ctx, a0)
+		return
+	})
+	return
+}
+
+// Client stub implementations.
+
+type t_client_stub struct {
+	stub               codegen.Stub
+	codec              codegen.Codec
+	interceptor        codegen.Interceptor
+	recordOrderMetrics *codegen.MethodMetrics
+	listOrdersMetrics  *codegen.MethodMetrics
+}
+
+func (s t_client_stub) RecordOrder(ctx context.Context, a0 string, a1 PlacedOrder) (err error) {
+	if s.interceptor == nil {
+		return s.callRecordOrder(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "RecordOrder", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callRecordOrder(ctx, a0, a1)
+	})
+	return
+}
+
+func (s t_client_stub) callRecordOrder(ctx context.Context, a0 string, a1 PlacedOrder) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.recordOrderMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "orderhistoryservice.T.RecordOrder", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.recordOrderMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.recordOrderMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r router
+	shardKey := _hashT(r.RecordOrder(ctx, a0, a1))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.recordOrderMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.recordOrderMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Encode arguments.
+	enc := codegen.NewEncoder()
+	enc.String(a0)
+	(a1).WeaverMarshal(enc)
+
+	// Call the remote method.
+	s.recordOrderMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.recordOrderMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+func (s t_client_stub) ListOrders(ctx context.Context, a0 string) (r0 []PlacedOrder, err error) {
+	if s.interceptor == nil {
+		return s.callListOrders(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "ListOrders", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callListOrders(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callListOrders(ctx context.Context, a0 string) (r0 []PlacedOrder, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.listOrdersMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "orderhistoryservice.T.ListOrders", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.listOrdersMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.listOrdersMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r router
+	shardKey := _hashT(r.ListOrders(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.listOrdersMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.listOrdersMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.listOrdersMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.listOrdersMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = serviceweaver_dec_slice_PlacedOrder_a87d817f(dec)
+	err = dec.Error()
+	return
+}
+
+// Server stub implementations.
+
+type t_server_stub struct {
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "RecordOrder":
+		return s.recordOrder
+	case "ListOrders":
+		return s.listOrders
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s t_server_stub) recordOrder(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	var a1 PlacedOrder
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		(&a1).WeaverUnmarshal(dec)
+	}
+	var r router
+	s.addLoad(_hashT(r.RecordOrder(ctx, a0, a1)), 1.0)
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.RecordOrder(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "RecordOrder", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.RecordOrder(ctx, a0, a1)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s t_server_stub) listOrders(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+	var r router
+	s.addLoad(_hashT(r.ListOrders(ctx, a0)), 1.0)
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 []PlacedOrder
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.ListOrders(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/orderhistoryservice/T", Method: "ListOrders", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.ListOrders(ctx, a0)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
+	enc := codegen.NewEncoder()
+	serviceweaver_enc_slice_PlacedOrder_a87d817f(enc, r0)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+// AutoMarshal implementations.
+
+var _ codegen.AutoMarshal = &PlacedOrder{}
+
+func (x *PlacedOrder) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("PlacedOrder.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(3)
+	(x.Order).WeaverMarshal(enc)
+	(x.Total).WeaverMarshal(enc)
+	enc.EncodeBinaryMarshaler(&x.PlacedAt)
+	enc.EndStruct(start)
+}
+
+func (x *PlacedOrder) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("PlacedOrder.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.Order).WeaverUnmarshal(dec)
+	}
+	if n > 1 {
+		(&x.Total).WeaverUnmarshal(dec)
+	}
+	if n > 2 {
+		dec.DecodeBinaryUnmarshaler(&x.PlacedAt)
+	}
+	dec.EndStruct(end)
+}
+
+var _ codegen.AutoMarshal = &history{}
+
+func (x *history) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("history.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(1)
+	serviceweaver_enc_slice_PlacedOrder_a87d817f(enc, x.Orders)
+	enc.EndStruct(start)
+}
+
+func (x *history) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("history.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Orders = serviceweaver_dec_slice_PlacedOrder_a87d817f(dec)
+	}
+	dec.EndStruct(end)
+}
+
+func serviceweaver_enc_slice_PlacedOrder_a87d817f(enc *codegen.Encoder, arg []PlacedOrder) {
+	if arg == nil {
+		enc.Len(-1)
+		return
+	}
+	enc.Len(len(arg))
+	for i := 0; i < len(arg); i++ {
+		(arg[i]).WeaverMarshal(enc)
+	}
+}
+
+func serviceweaver_dec_slice_PlacedOrder_a87d817f(dec *codegen.Decoder) []PlacedOrder {
+	n := dec.Len()
+	if n == -1 {
+		return nil
+	}
+	res := make([]PlacedOrder, n)
+	for i := 0; i < n; i++ {
+		(&res[i]).WeaverUnmarshal(dec)
+	}
+	return res
+}
+
+// Router methods.
+
+// _hashT returns a 64 bit hash of the provided value.
+func _hashT(r string) uint64 {
+	var h codegen.Hasher
+	h.WriteString(string(r))
+	return h.Sum64()
+}
+
+// _orderedCodeT returns an order-preserving serialization of the provided value.
+func _orderedCodeT(r string) codegen.OrderedCode {
+	var enc codegen.OrderedEncoder
+	enc.WriteString(string(r))
+	return enc.Encode()
+}
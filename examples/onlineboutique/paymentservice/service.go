@@ -41,6 +41,7 @@ func (c CreditCardInfo) LastFour() string {
 
 type T interface {
 	Charge(ctx context.Context, amount money.T, card CreditCardInfo) (string, error)
+	Refund(ctx context.Context, transactionID string) error
 }
 
 type impl struct {
@@ -52,3 +53,9 @@ type impl struct {
 func (s *impl) Charge(ctx context.Context, amount money.T, card CreditCardInfo) (string, error) {
 	return charge(amount, card, s.Logger())
 }
+
+// Refund mocks that the given transaction is refunded.
+func (s *impl) Refund(ctx context.Context, transactionID string) error {
+	s.Logger().Info("Transaction refunded", "transaction_id", transactionID)
+	return nil
+}
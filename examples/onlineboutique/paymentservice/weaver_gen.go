@@ -14,15 +14,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, chargeMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Charge"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), chargeMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Charge"}), refundMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Refund"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -30,8 +32,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) Charge(ctx context.Context, a0 money.T, a1 CreditCardInfo) (r0 string, err error) {
@@ -48,17 +51,61 @@ func (s t_local_stub) Charge(ctx context.Context, a0 money.T, a1 CreditCardInfo)
 		}()
 	}
 
-	return s.impl.Charge(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Charge(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Charge", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Charge(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_local_stub) Refund(ctx context.Context, a0 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "paymentservice.T.Refund", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Refund(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Refund", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.Refund(ctx, a0)
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub          codegen.Stub
+	codec         codegen.Codec
+	interceptor   codegen.Interceptor
 	chargeMetrics *codegen.MethodMetrics
+	refundMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) Charge(ctx context.Context, a0 money.T, a1 CreditCardInfo) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callCharge(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Charge", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callCharge(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callCharge(ctx context.Context, a0 money.T, a1 CreditCardInfo) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.chargeMetrics.Count.Add(1)
@@ -86,11 +133,26 @@ func (s t_client_stub) Charge(ctx context.Context, a0 money.T, a1 CreditCardInfo
 		s.chargeMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.chargeMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.chargeMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	(a1).WeaverMarshal(enc)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.chargeMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -108,11 +170,91 @@ func (s t_client_stub) Charge(ctx context.Context, a0 money.T, a1 CreditCardInfo
 	return
 }
 
+func (s t_client_stub) Refund(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callRefund(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Refund", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callRefund(ctx, a0)
+	})
+	return
+}
+
+func (s t_client_stub) callRefund(ctx context.Context, a0 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.refundMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "paymentservice.T.Refund", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.refundMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.refundMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.refundMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.refundMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.refundMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.refundMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -120,11 +262,18 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	switch method {
 	case "Charge":
 		return s.charge
+	case "Refund":
+		return s.refund
 	default:
 		return nil
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) charge(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -134,24 +283,78 @@ func (s t_server_stub) charge(ctx context.Context, args []byte) (res []byte, err
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 money.T
-	(&a0).WeaverUnmarshal(dec)
 	var a1 CreditCardInfo
-	(&a1).WeaverUnmarshal(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		(&a1).WeaverUnmarshal(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Charge(ctx, a0, a1)
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Charge(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Charge", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Charge(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.String(r0)
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
+func (s t_server_stub) refund(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Refund(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/paymentservice/T", Method: "Refund", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.Refund(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
 // AutoMarshal implementations.
 
 var _ codegen.AutoMarshal = &CreditCardInfo{}
@@ -160,18 +363,30 @@ func (x *CreditCardInfo) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("CreditCardInfo.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(4)
 	enc.String(x.Number)
 	enc.Int32(x.CVV)
 	enc.Int(x.ExpirationYear)
 	enc.Int((int)(x.ExpirationMonth))
+	enc.EndStruct(start)
 }
 
 func (x *CreditCardInfo) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("CreditCardInfo.WeaverUnmarshal: nil receiver"))
 	}
-	x.Number = dec.String()
-	x.CVV = dec.Int32()
-	x.ExpirationYear = dec.Int()
-	*(*int)(&x.ExpirationMonth) = dec.Int()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Number = dec.String()
+	}
+	if n > 1 {
+		x.CVV = dec.Int32()
+	}
+	if n > 2 {
+		x.ExpirationYear = dec.Int()
+	}
+	if n > 3 {
+		*(*int)(&x.ExpirationMonth) = dec.Int()
+	}
+	dec.EndStruct(end)
 }
@@ -13,15 +13,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, listProductsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "ListProducts"}), getProductMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "GetProduct"}), searchProductsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "SearchProducts"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), listProductsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "ListProducts"}), getProductMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "GetProduct"}), searchProductsMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "SearchProducts"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -29,8 +31,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) ListProducts(ctx context.Context) (r0 []Product, err error) {
@@ -47,7 +50,14 @@ func (s t_local_stub) ListProducts(ctx context.Context) (r0 []Product, err error
 		}()
 	}
 
-	return s.impl.ListProducts(ctx)
+	if s.interceptor == nil {
+		return s.impl.ListProducts(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "ListProducts"}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.ListProducts(ctx)
+		return
+	})
+	return
 }
 
 func (s t_local_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, err error) {
@@ -64,7 +74,14 @@ func (s t_local_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, er
 		}()
 	}
 
-	return s.impl.GetProduct(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.GetProduct(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "GetProduct", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetProduct(ctx, a0)
+		return
+	})
+	return
 }
 
 func (s t_local_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Product, err error) {
@@ -81,19 +98,39 @@ func (s t_local_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Produ
 		}()
 	}
 
-	return s.impl.SearchProducts(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.SearchProducts(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "SearchProducts", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.SearchProducts(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
 	stub                  codegen.Stub
+	codec                 codegen.Codec
+	interceptor           codegen.Interceptor
 	listProductsMetrics   *codegen.MethodMetrics
 	getProductMetrics     *codegen.MethodMetrics
 	searchProductsMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) ListProducts(ctx context.Context) (r0 []Product, err error) {
+	if s.interceptor == nil {
+		return s.callListProducts(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "ListProducts"}, func(ctx context.Context) (err error) {
+		r0, err = s.callListProducts(ctx)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callListProducts(ctx context.Context) (r0 []Product, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.listProductsMetrics.Count.Add(1)
@@ -123,6 +160,20 @@ func (s t_client_stub) ListProducts(ctx context.Context) (r0 []Product, err erro
 
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.listProductsMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.listProductsMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Call the remote method.
 	s.listProductsMetrics.BytesRequest.Put(0)
 	var results []byte
@@ -140,6 +191,17 @@ func (s t_client_stub) ListProducts(ctx context.Context) (r0 []Product, err erro
 }
 
 func (s t_client_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, err error) {
+	if s.interceptor == nil {
+		return s.callGetProduct(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "GetProduct", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetProduct(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetProduct(ctx context.Context, a0 string) (r0 Product, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getProductMetrics.Count.Add(1)
@@ -167,6 +229,22 @@ func (s t_client_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, e
 		s.getProductMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getProductMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getProductMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -175,7 +253,6 @@ func (s t_client_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, e
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getProductMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -194,6 +271,17 @@ func (s t_client_stub) GetProduct(ctx context.Context, a0 string) (r0 Product, e
 }
 
 func (s t_client_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Product, err error) {
+	if s.interceptor == nil {
+		return s.callSearchProducts(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "SearchProducts", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callSearchProducts(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callSearchProducts(ctx context.Context, a0 string) (r0 []Product, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.searchProductsMetrics.Count.Add(1)
@@ -221,6 +309,22 @@ func (s t_client_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Prod
 		s.searchProductsMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.searchProductsMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.searchProductsMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -229,7 +333,6 @@ func (s t_client_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Prod
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.searchProductsMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -250,8 +353,10 @@ func (s t_client_stub) SearchProducts(ctx context.Context, a0 string) (r0 []Prod
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -268,6 +373,11 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) listProducts(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -279,9 +389,21 @@ func (s t_server_stub) listProducts(ctx context.Context, args []byte) (res []byt
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.ListProducts(ctx)
+	var r0 []Product
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.ListProducts(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "ListProducts"}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.ListProducts(ctx)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_Product_3e9d9e07(enc, r0)
 	enc.Error(appErr)
@@ -297,16 +419,32 @@ func (s t_server_stub) getProduct(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetProduct(ctx, a0)
+	var r0 Product
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetProduct(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "GetProduct", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetProduct(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -322,16 +460,32 @@ func (s t_server_stub) searchProducts(ctx context.Context, args []byte) (res []b
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.SearchProducts(ctx, a0)
+	var r0 []Product
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.SearchProducts(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice/T", Method: "SearchProducts", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.SearchProducts(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_Product_3e9d9e07(enc, r0)
 	enc.Error(appErr)
@@ -346,24 +500,40 @@ func (x *Product) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Product.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(6)
 	enc.String(x.ID)
 	enc.String(x.Name)
 	enc.String(x.Description)
 	enc.String(x.Picture)
 	(x.PriceUSD).WeaverMarshal(enc)
 	serviceweaver_enc_slice_string_4af10117(enc, x.Categories)
+	enc.EndStruct(start)
 }
 
 func (x *Product) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Product.WeaverUnmarshal: nil receiver"))
 	}
-	x.ID = dec.String()
-	x.Name = dec.String()
-	x.Description = dec.String()
-	x.Picture = dec.String()
-	(&x.PriceUSD).WeaverUnmarshal(dec)
-	x.Categories = serviceweaver_dec_slice_string_4af10117(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.ID = dec.String()
+	}
+	if n > 1 {
+		x.Name = dec.String()
+	}
+	if n > 2 {
+		x.Description = dec.String()
+	}
+	if n > 3 {
+		x.Picture = dec.String()
+	}
+	if n > 4 {
+		(&x.PriceUSD).WeaverUnmarshal(dec)
+	}
+	if n > 5 {
+		x.Categories = serviceweaver_dec_slice_string_4af10117(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_string_4af10117(enc *codegen.Encoder, arg []string) {
@@ -0,0 +1,160 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package searchservice
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+)
+
+// catalogTTL is how long an index shard uses a snapshot of the catalog before
+// it lists the catalog, and rebuilds its posting lists, again.
+const catalogTTL = time.Minute
+
+// The fields of a product are weighted by how much a match in the field says
+// about the product.
+const (
+	nameWeight        = 3
+	categoryWeight    = 2
+	descriptionWeight = 1
+)
+
+var indexedTerms = weaver.NewGauge(
+	"boutique_search_index_terms",
+	"Number of terms whose posting lists are held by the index shards of a process",
+)
+
+// Posting is an entry of the posting list of a term: a product that contains
+// the term, and how relevant the term is to the product.
+type Posting struct {
+	weaver.AutoMarshal
+	ProductID string
+	Score     float64
+}
+
+// index is an inverted index over the product catalog, which maps every term
+// to its posting list. The index is sharded by term: lookups of a term are
+// routed to the same replica, which builds the posting list of the term on
+// the first lookup, and serves the later ones from memory. Every replica thus
+// holds the posting lists of the terms routed to it only.
+type index interface {
+	Lookup(ctx context.Context, term string) ([]Posting, error)
+}
+
+type indexImpl struct {
+	weaver.Implements[index]
+	weaver.WithRouter[indexRouter]
+
+	catalogService weaver.Ref[productcatalogservice.T]
+
+	mu       sync.Mutex
+	docs     []document           // the catalog snapshot, tokenized
+	loaded   time.Time            // when docs were listed
+	postings map[string][]Posting // the posting lists of this shard, by term
+}
+
+// document is a tokenized product: the weighted frequency of every term of
+// the product.
+type document struct {
+	id    string
+	terms map[string]float64
+}
+
+// Lookup returns the posting list of the provided term, sorted by decreasing
+// score.
+func (x *indexImpl) Lookup(ctx context.Context, term string) ([]Posting, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	clock := weaver.ClockOf(x)
+	if clock.Since(x.loaded) > catalogTTL {
+		products, err := x.catalogService.Get().ListProducts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		indexedTerms.Sub(float64(len(x.postings)))
+		x.docs = tokenizeCatalog(products)
+		x.loaded = clock.Now()
+		x.postings = map[string][]Posting{}
+	}
+	if postings, ok := x.postings[term]; ok {
+		return postings, nil
+	}
+
+	// Build the posting list, with tf-idf scores.
+	var postings []Posting
+	for _, d := range x.docs {
+		if tf, ok := d.terms[term]; ok {
+			postings = append(postings, Posting{ProductID: d.id, Score: tf})
+		}
+	}
+	idf := math.Log(1 + float64(len(x.docs))/float64(1+len(postings)))
+	for i := range postings {
+		postings[i].Score *= idf
+	}
+	sort.Slice(postings, func(i, j int) bool {
+		return postings[i].Score > postings[j].Score
+	})
+	x.postings[term] = postings
+	indexedTerms.Add(1)
+	return postings, nil
+}
+
+// tokenizeCatalog returns the documents of the provided products.
+func tokenizeCatalog(products []productcatalogservice.Product) []document {
+	docs := make([]document, len(products))
+	for i, p := range products {
+		terms := map[string]float64{}
+		add := func(text string, weight float64) {
+			for _, t := range tokenize(text) {
+				terms[t] += weight
+			}
+		}
+		add(p.Name, nameWeight)
+		for _, c := range p.Categories {
+			add(c, categoryWeight)
+		}
+		add(p.Description, descriptionWeight)
+		docs[i] = document{id: p.ID, terms: terms}
+	}
+	return docs
+}
+
+// tokenize returns the terms of the provided text: its lowercased words, with
+// a trailing plural "s" removed, so that "jars" and "jar" match.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = strings.TrimSuffix(w, "s")
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+type indexRouter struct{}
+
+func (indexRouter) Lookup(_ context.Context, term string) string { return term }
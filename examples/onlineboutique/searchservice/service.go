@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package searchservice
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxQueryTerms is the maximum number of terms of a query. Extra terms are
+// ignored.
+const maxQueryTerms = 10
+
+// T searches the product catalog.
+type T interface {
+	// Search returns the products that match the provided query, most
+	// relevant first. A product matches if it contains any of the terms of
+	// the query; products that contain more of the terms rank first.
+	Search(ctx context.Context, query string) ([]productcatalogservice.Product, error)
+}
+
+type impl struct {
+	weaver.Implements[T]
+	catalogService weaver.Ref[productcatalogservice.T]
+	index          weaver.Ref[index]
+}
+
+// result is a product that matches a query.
+type result struct {
+	id      string
+	matches int     // number of query terms the product contains
+	score   float64 // sum of the scores of the terms
+}
+
+// Search returns the products that match the provided query.
+func (s *impl) Search(ctx context.Context, query string) ([]productcatalogservice.Product, error) {
+	s.Logger().Info("Search called", "query", query)
+	terms := dedup(tokenize(query))
+	if len(terms) > maxQueryTerms {
+		terms = terms[:maxQueryTerms]
+	}
+
+	// Look the terms up in parallel, each in the index shard that holds it.
+	postings := make([][]Posting, len(terms))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, term := range terms {
+		i, term := i, term
+		group.Go(func() error {
+			var err error
+			postings[i], err = s.index.Get().Lookup(groupCtx, term)
+			return err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Merge the posting lists.
+	byID := map[string]*result{}
+	for _, list := range postings {
+		for _, p := range list {
+			r, ok := byID[p.ProductID]
+			if !ok {
+				r = &result{id: p.ProductID}
+				byID[p.ProductID] = r
+			}
+			r.matches++
+			r.score += p.Score
+		}
+	}
+	results := make([]*result, 0, len(byID))
+	for _, r := range byID {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].matches != results[j].matches {
+			return results[i].matches > results[j].matches
+		}
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].id < results[j].id
+	})
+
+	products := make([]productcatalogservice.Product, 0, len(results))
+	for _, r := range results {
+		p, err := s.catalogService.Get().GetProduct(ctx, r.id)
+		if errors.Is(err, productcatalogservice.NotFoundError{}) {
+			continue // removed from the catalog since it was indexed
+		}
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// dedup returns the provided terms, without duplicates, in order.
+func dedup(terms []string) []string {
+	seen := map[string]bool{}
+	out := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package searchservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+	"github.com/ServiceWeaver/weaver/weavertest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// catalog is the catalog served by fakeCatalog. "grinder" is listed, but was
+// removed from the catalog since, so it can't be fetched.
+var catalog = []productcatalogservice.Product{
+	{ID: "mug", Name: "Coffee Mug", Categories: []string{"kitchen"}, Description: "A mug for your coffee."},
+	{ID: "jar", Name: "Mason Jar", Categories: []string{"kitchen"}, Description: "Holds coffee beans."},
+	{ID: "top", Name: "Tank Top", Categories: []string{"clothing"}, Description: "Cotton top."},
+	{ID: "grinder", Name: "Coffee Grinder", Categories: []string{"kitchen"}, Description: "Grinds beans."},
+}
+
+type fakeCatalog struct{}
+
+func (fakeCatalog) ListProducts(context.Context) ([]productcatalogservice.Product, error) {
+	return catalog, nil
+}
+
+func (fakeCatalog) GetProduct(_ context.Context, id string) (productcatalogservice.Product, error) {
+	for _, p := range catalog {
+		if p.ID == id && id != "grinder" {
+			return p, nil
+		}
+	}
+	return productcatalogservice.Product{}, productcatalogservice.NotFoundError{}
+}
+
+func (fakeCatalog) SearchProducts(context.Context, string) ([]productcatalogservice.Product, error) {
+	return nil, nil
+}
+
+func TestSearch(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		single     bool
+		placements []weavertest.Placement
+	}{
+		{"Single", true, nil},
+		// Three index shards, each holding the posting lists of its terms.
+		{"Sharded", false, []weavertest.Placement{weavertest.Remote[index](3)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			root := weavertest.Init(ctx, t, weavertest.Options{
+				SingleProcess: test.single,
+				Placements:    test.placements,
+				Fakes:         []weavertest.FakeComponent{weavertest.Fake[productcatalogservice.T](fakeCatalog{})},
+			})
+			search, err := weaver.Get[T](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, q := range []struct {
+				query string
+				want  []string // ids of the results, in order
+			}{
+				// Both mug and jar match both terms; "coffee" weighs more
+				// in the name of mug than in the description of jar. The
+				// grinder matches too, but is skipped, since it was removed
+				// from the catalog.
+				{"coffee kitchen", []string{"mug", "jar"}},
+				// Terms are case insensitive, plurals match singulars, and
+				// repeated terms count once.
+				{"KITCHEN mugs kitchen", []string{"mug", "jar"}},
+				// A product that contains more of the terms ranks first,
+				// however high the score of the other products.
+				{"coffee cotton top", []string{"top", "mug", "jar"}},
+				{"beans", []string{"jar"}},
+				{"sunglasses", nil},
+				{"", nil},
+			} {
+				products, err := search.Search(ctx, q.query)
+				if err != nil {
+					t.Fatalf("Search(%q): %v", q.query, err)
+				}
+				var got []string
+				for _, p := range products {
+					got = append(got, p.ID)
+				}
+				if diff := cmp.Diff(q.want, got); diff != "" {
+					t.Errorf("Search(%q) (-want +got):\n%s", q.query, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexRouterShardsByTerm(t *testing.T) {
+	// Every lookup of a term is routed to the shard that holds the posting
+	// list of the term, and different terms may be held by different shards.
+	ctx := context.Background()
+	r := indexRouter{}
+	if r.Lookup(ctx, "coffee") != r.Lookup(ctx, "coffee") {
+		t.Error("lookups of the same term routed differently")
+	}
+	if r.Lookup(ctx, "coffee") == r.Lookup(ctx, "kitchen") {
+		t.Error("lookups of different terms routed to the same key")
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want []string
+	}{
+		{"Coffee Mug", []string{"coffee", "mug"}},
+		{"sunglasses, watches & mugs!", []string{"sunglasse", "watche", "mug"}},
+		{"glass bus kiss", []string{"glass", "bus", "kiss"}},
+		{"Bamboo-Glass jar #2", []string{"bamboo", "glass", "jar", "2"}},
+		{"  ", nil},
+	} {
+		got := tokenize(test.text)
+		if diff := cmp.Diff(test.want, got, cmpEmpty); diff != "" {
+			t.Errorf("tokenize(%q) (-want +got):\n%s", test.text, diff)
+		}
+	}
+}
+
+// cmpEmpty treats nil and empty slices as equal.
+var cmpEmpty = cmp.FilterValues(func(x, y []string) bool {
+	return len(x) == 0 && len(y) == 0
+}, cmp.Ignore())
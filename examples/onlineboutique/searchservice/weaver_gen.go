@@ -0,0 +1,507 @@
+package searchservice
+
+// Code generated by "weaver generate". DO NOT EDIT.
+import (
+	"context"
+	"fmt"
+	"github.com/ServiceWeaver/weaver/examples/onlineboutique/productcatalogservice"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"time"
+)
+
+func init() {
+	codegen.Register(codegen.Registration{
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), searchMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/T", Method: "Search"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
+	codegen.Register(codegen.Registration{
+		Name:   "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/index",
+		Iface:  reflect.TypeOf((*index)(nil)).Elem(),
+		New:    func() any { return &indexImpl{} },
+		Routed: true,
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return index_local_stub{impl: impl.(index), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return index_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), lookupMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/index", Method: "Lookup"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return index_server_stub{impl: impl.(index), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
+}
+
+// Local stub implementations.
+
+type t_local_stub struct {
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s t_local_stub) Search(ctx context.Context, a0 string) (r0 []productcatalogservice.Product, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "searchservice.T.Search", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Search(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/T", Method: "Search", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Search(ctx, a0)
+		return
+	})
+	return
+}
+
+type index_local_stub struct {
+	impl        index
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s index_local_stub) Lookup(ctx context.Context, a0 string) (r0 []Posting, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "searchservice.index.Lookup", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Lookup(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/index", Method: "Lookup", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Lookup(ctx, a0)
+		return
+	})
+	return
+}
+
+// Client stub implementations.
+
+type t_client_stub struct {
+	stub          codegen.Stub
+	codec         codegen.Codec
+	interceptor   codegen.Interceptor
+	searchMetrics *codegen.MethodMetrics
+}
+
+func (s t_client_stub) Search(ctx context.Context, a0 string) (r0 []productcatalogservice.Product, err error) {
+	if s.interceptor == nil {
+		return s.callSearch(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/T", Method: "Search", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callSearch(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callSearch(ctx context.Context, a0 string) (r0 []productcatalogservice.Product, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.searchMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "searchservice.T.Search", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.searchMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.searchMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.searchMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.searchMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.searchMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.searchMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = serviceweaver_dec_slice_Product_3e9d9e07(dec)
+	err = dec.Error()
+	return
+}
+
+type index_client_stub struct {
+	stub          codegen.Stub
+	codec         codegen.Codec
+	interceptor   codegen.Interceptor
+	lookupMetrics *codegen.MethodMetrics
+}
+
+func (s index_client_stub) Lookup(ctx context.Context, a0 string) (r0 []Posting, err error) {
+	if s.interceptor == nil {
+		return s.callLookup(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/index", Method: "Lookup", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callLookup(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s index_client_stub) callLookup(ctx context.Context, a0 string) (r0 []Posting, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.lookupMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "searchservice.index.Lookup", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.lookupMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.lookupMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r indexRouter
+	shardKey := _hashIndex(r.Lookup(ctx, a0))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.lookupMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.lookupMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.lookupMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.lookupMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = serviceweaver_dec_slice_Posting_146f0e14(dec)
+	err = dec.Error()
+	return
+}
+
+// Server stub implementations.
+
+type t_server_stub struct {
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "Search":
+		return s.search
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s t_server_stub) search(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 []productcatalogservice.Product
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Search(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/T", Method: "Search", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Search(ctx, a0)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
+	enc := codegen.NewEncoder()
+	serviceweaver_enc_slice_Product_3e9d9e07(enc, r0)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+type index_server_stub struct {
+	impl        index
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s index_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "Lookup":
+		return s.lookup
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s index_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s index_server_stub) lookup(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+	var r indexRouter
+	s.addLoad(_hashIndex(r.Lookup(ctx, a0)), 1.0)
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 []Posting
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Lookup(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/searchservice/index", Method: "Lookup", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Lookup(ctx, a0)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
+	enc := codegen.NewEncoder()
+	serviceweaver_enc_slice_Posting_146f0e14(enc, r0)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+// AutoMarshal implementations.
+
+var _ codegen.AutoMarshal = &Posting{}
+
+func (x *Posting) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("Posting.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(2)
+	enc.String(x.ProductID)
+	enc.Float64(x.Score)
+	enc.EndStruct(start)
+}
+
+func (x *Posting) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("Posting.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.ProductID = dec.String()
+	}
+	if n > 1 {
+		x.Score = dec.Float64()
+	}
+	dec.EndStruct(end)
+}
+
+// Router methods.
+
+// _hashIndex returns a 64 bit hash of the provided value.
+func _hashIndex(r string) uint64 {
+	var h codegen.Hasher
+	h.WriteString(string(r))
+	return h.Sum64()
+}
+
+// _orderedCodeIndex returns an order-preserving serialization of the provided value.
+func _orderedCodeIndex(r string) codegen.OrderedCode {
+	var enc codegen.OrderedEncoder
+	enc.WriteString(string(r))
+	return enc.Encode()
+}
+
+// Encoding/decoding implementations.
+
+func serviceweaver_enc_slice_Posting_146f0e14(enc *codegen.Encoder, arg []Posting) {
+	if arg == nil {
+		enc.Len(-1)
+		return
+	}
+	enc.Len(len(arg))
+	for i := 0; i < len(arg); i++ {
+		(arg[i]).WeaverMarshal(enc)
+	}
+}
+
+func serviceweaver_dec_slice_Posting_146f0e14(dec *codegen.Decoder) []Posting {
+	n := dec.Len()
+	if n == -1 {
+		return nil
+	}
+	res := make([]Posting, n)
+	for i := 0; i < n; i++ {
+		(&res[i]).WeaverUnmarshal(dec)
+	}
+	return res
+}
+
+func serviceweaver_enc_slice_Product_3e9d9e07(enc *codegen.Encoder, arg []productcatalogservice.Product) {
+	if arg == nil {
+		enc.Len(-1)
+		return
+	}
+	enc.Len(len(arg))
+	for i := 0; i < len(arg); i++ {
+		(arg[i]).WeaverMarshal(enc)
+	}
+}
+
+func serviceweaver_dec_slice_Product_3e9d9e07(dec *codegen.Decoder) []productcatalogservice.Product {
+	n := dec.Len()
+	if n == -1 {
+		return nil
+	}
+	res := make([]productcatalogservice.Product, n)
+	for i := 0; i < n; i++ {
+		(&res[i]).WeaverUnmarshal(dec)
+	}
+	return res
+}
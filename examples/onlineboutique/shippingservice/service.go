@@ -35,6 +35,7 @@ type Address struct {
 type T interface {
 	GetQuote(ctx context.Context, addr Address, items []cartservice.CartItem) (money.T, error)
 	ShipOrder(ctx context.Context, addr Address, items []cartservice.CartItem) (string, error)
+	CancelShipment(ctx context.Context, trackingID string) error
 }
 
 type impl struct {
@@ -68,3 +69,10 @@ func (s *impl) ShipOrder(ctx context.Context, addr Address, items []cartservice.
 	id := createTrackingID(baseAddress)
 	return id, nil
 }
+
+// CancelShipment mocks that the shipment with the given tracking ID is
+// cancelled.
+func (s *impl) CancelShipment(ctx context.Context, trackingID string) error {
+	s.Logger().Info("[CancelShipment] shipment cancelled", "tracking_id", trackingID)
+	return nil
+}
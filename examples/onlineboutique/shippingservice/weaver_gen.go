@@ -15,15 +15,17 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T",
-		Iface:       reflect.TypeOf((*T)(nil)).Elem(),
-		New:         func() any { return &impl{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return t_local_stub{impl: impl.(T), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return t_client_stub{stub: stub, getQuoteMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "GetQuote"}), shipOrderMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "ShipOrder"})}
+		Name:  "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T",
+		Iface: reflect.TypeOf((*T)(nil)).Elem(),
+		New:   func() any { return &impl{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return t_server_stub{impl: impl.(T), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getQuoteMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "GetQuote"}), shipOrderMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "ShipOrder"}), cancelShipmentMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "CancelShipment"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -31,8 +33,9 @@ func init() {
 // Local stub implementations.
 
 type t_local_stub struct {
-	impl   T
-	tracer trace.Tracer
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s t_local_stub) GetQuote(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 money.T, err error) {
@@ -49,7 +52,14 @@ func (s t_local_stub) GetQuote(ctx context.Context, a0 Address, a1 []cartservice
 		}()
 	}
 
-	return s.impl.GetQuote(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.GetQuote(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "GetQuote", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetQuote(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s t_local_stub) ShipOrder(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 string, err error) {
@@ -66,18 +76,62 @@ func (s t_local_stub) ShipOrder(ctx context.Context, a0 Address, a1 []cartservic
 		}()
 	}
 
-	return s.impl.ShipOrder(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.ShipOrder(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "ShipOrder", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.ShipOrder(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_local_stub) CancelShipment(ctx context.Context, a0 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "shippingservice.T.CancelShipment", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.CancelShipment(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "CancelShipment", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.CancelShipment(ctx, a0)
+	})
+	return
 }
 
 // Client stub implementations.
 
 type t_client_stub struct {
-	stub             codegen.Stub
-	getQuoteMetrics  *codegen.MethodMetrics
-	shipOrderMetrics *codegen.MethodMetrics
+	stub                  codegen.Stub
+	codec                 codegen.Codec
+	interceptor           codegen.Interceptor
+	getQuoteMetrics       *codegen.MethodMetrics
+	shipOrderMetrics      *codegen.MethodMetrics
+	cancelShipmentMetrics *codegen.MethodMetrics
 }
 
 func (s t_client_stub) GetQuote(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 money.T, err error) {
+	if s.interceptor == nil {
+		return s.callGetQuote(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "GetQuote", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetQuote(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetQuote(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 money.T, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getQuoteMetrics.Count.Add(1)
@@ -105,16 +159,31 @@ func (s t_client_stub) GetQuote(ctx context.Context, a0 Address, a1 []cartservic
 		s.getQuoteMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.getQuoteMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getQuoteMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getQuoteMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
-	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
 	if err != nil {
 		return
 	}
@@ -128,6 +197,17 @@ func (s t_client_stub) GetQuote(ctx context.Context, a0 Address, a1 []cartservic
 }
 
 func (s t_client_stub) ShipOrder(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callShipOrder(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "ShipOrder", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callShipOrder(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callShipOrder(ctx context.Context, a0 Address, a1 []cartservice.CartItem) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.shipOrderMetrics.Count.Add(1)
@@ -155,16 +235,31 @@ func (s t_client_stub) ShipOrder(ctx context.Context, a0 Address, a1 []cartservi
 		s.shipOrderMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.shipOrderMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.shipOrderMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	serviceweaver_enc_slice_CartItem_7a7ff11c(enc, a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.shipOrderMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
-	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
+	results, err = s.stub.Run(ctx, 2, enc.Data(), shardKey)
 	if err != nil {
 		return
 	}
@@ -177,11 +272,91 @@ func (s t_client_stub) ShipOrder(ctx context.Context, a0 Address, a1 []cartservi
 	return
 }
 
+func (s t_client_stub) CancelShipment(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callCancelShipment(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "CancelShipment", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callCancelShipment(ctx, a0)
+	})
+	return
+}
+
+func (s t_client_stub) callCancelShipment(ctx context.Context, a0 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.cancelShipmentMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "shippingservice.T.CancelShipment", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.cancelShipmentMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.cancelShipmentMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.cancelShipmentMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.cancelShipmentMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.cancelShipmentMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.cancelShipmentMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
 // Server stub implementations.
 
 type t_server_stub struct {
-	impl    T
-	addLoad func(key uint64, load float64)
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -191,11 +366,18 @@ func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args [
 		return s.getQuote
 	case "ShipOrder":
 		return s.shipOrder
+	case "CancelShipment":
+		return s.cancelShipment
 	default:
 		return nil
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s t_server_stub) getQuote(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -205,18 +387,34 @@ func (s t_server_stub) getQuote(ctx context.Context, args []byte) (res []byte, e
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 Address
-	(&a0).WeaverUnmarshal(dec)
 	var a1 []cartservice.CartItem
-	a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetQuote(ctx, a0, a1)
+	var r0 money.T
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetQuote(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "GetQuote", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetQuote(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -232,24 +430,78 @@ func (s t_server_stub) shipOrder(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 Address
-	(&a0).WeaverUnmarshal(dec)
 	var a1 []cartservice.CartItem
-	a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = serviceweaver_dec_slice_CartItem_7a7ff11c(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.ShipOrder(ctx, a0, a1)
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.ShipOrder(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "ShipOrder", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.ShipOrder(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.String(r0)
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
+func (s t_server_stub) cancelShipment(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.CancelShipment(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/shippingservice/T", Method: "CancelShipment", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.CancelShipment(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
 // AutoMarshal implementations.
 
 var _ codegen.AutoMarshal = &Address{}
@@ -258,22 +510,36 @@ func (x *Address) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Address.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(5)
 	enc.String(x.StreetAddress)
 	enc.String(x.City)
 	enc.String(x.State)
 	enc.String(x.Country)
 	enc.Int32(x.ZipCode)
+	enc.EndStruct(start)
 }
 
 func (x *Address) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Address.WeaverUnmarshal: nil receiver"))
 	}
-	x.StreetAddress = dec.String()
-	x.City = dec.String()
-	x.State = dec.String()
-	x.Country = dec.String()
-	x.ZipCode = dec.Int32()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.StreetAddress = dec.String()
+	}
+	if n > 1 {
+		x.City = dec.String()
+	}
+	if n > 2 {
+		x.State = dec.String()
+	}
+	if n > 3 {
+		x.Country = dec.String()
+	}
+	if n > 4 {
+		x.ZipCode = dec.Int32()
+	}
+	dec.EndStruct(end)
 }
 
 // Encoding/decoding implementations.
@@ -21,16 +21,26 @@ func (x *T) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("T.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(3)
 	enc.String(x.CurrencyCode)
 	enc.Int64(x.Units)
 	enc.Int32(x.Nanos)
+	enc.EndStruct(start)
 }
 
 func (x *T) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("T.WeaverUnmarshal: nil receiver"))
 	}
-	x.CurrencyCode = dec.String()
-	x.Units = dec.Int64()
-	x.Nanos = dec.Int32()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.CurrencyCode = dec.String()
+	}
+	if n > 1 {
+		x.Units = dec.Int64()
+	}
+	if n > 2 {
+		x.Nanos = dec.Int32()
+	}
+	dec.EndStruct(end)
 }
@@ -21,22 +21,36 @@ func (x *Order) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Order.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(5)
 	enc.String(x.OrderID)
 	enc.String(x.ShippingTrackingID)
 	(x.ShippingCost).WeaverMarshal(enc)
 	(x.ShippingAddress).WeaverMarshal(enc)
 	serviceweaver_enc_slice_OrderItem_2b9377cb(enc, x.Items)
+	enc.EndStruct(start)
 }
 
 func (x *Order) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Order.WeaverUnmarshal: nil receiver"))
 	}
-	x.OrderID = dec.String()
-	x.ShippingTrackingID = dec.String()
-	(&x.ShippingCost).WeaverUnmarshal(dec)
-	(&x.ShippingAddress).WeaverUnmarshal(dec)
-	x.Items = serviceweaver_dec_slice_OrderItem_2b9377cb(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.OrderID = dec.String()
+	}
+	if n > 1 {
+		x.ShippingTrackingID = dec.String()
+	}
+	if n > 2 {
+		(&x.ShippingCost).WeaverUnmarshal(dec)
+	}
+	if n > 3 {
+		(&x.ShippingAddress).WeaverUnmarshal(dec)
+	}
+	if n > 4 {
+		x.Items = serviceweaver_dec_slice_OrderItem_2b9377cb(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_OrderItem_2b9377cb(enc *codegen.Encoder, arg []OrderItem) {
@@ -68,14 +82,22 @@ func (x *OrderItem) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("OrderItem.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	(x.Item).WeaverMarshal(enc)
 	(x.Cost).WeaverMarshal(enc)
+	enc.EndStruct(start)
 }
 
 func (x *OrderItem) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("OrderItem.WeaverUnmarshal: nil receiver"))
 	}
-	(&x.Item).WeaverUnmarshal(dec)
-	(&x.Cost).WeaverUnmarshal(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.Item).WeaverUnmarshal(dec)
+	}
+	if n > 1 {
+		(&x.Cost).WeaverUnmarshal(dec)
+	}
+	dec.EndStruct(end)
 }
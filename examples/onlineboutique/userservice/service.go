@@ -0,0 +1,207 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength and maxPasswordLength bound the length of a password.
+// bcrypt ignores the bytes of a password past the 72nd.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+var (
+	// ErrEmailTaken is returned by SignUp if the email already belongs to an
+	// account.
+	ErrEmailTaken = errors.New("email already registered")
+
+	// ErrInvalidAccount is returned by SignUp if the email, name, or password
+	// of the account is invalid.
+	ErrInvalidAccount = errors.New("invalid account")
+
+	// ErrInvalidCredentials is returned by SignIn if the email doesn't belong
+	// to an account, or if the password is wrong.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// User is a user with an account.
+type User struct {
+	weaver.AutoMarshal
+	Email string
+	Name  string
+}
+
+// account is an account, keyed by email in the accounts store.
+type account struct {
+	weaver.AutoMarshal
+	Name         string
+	PasswordHash []byte
+}
+
+// session is the account signed in on a session, keyed by session id in the
+// sessions store.
+type session struct {
+	weaver.AutoMarshal
+	Email string
+}
+
+var (
+	// accounts and sessions are kept in memory, unless they are configured
+	// in the [serviceweaver.stores] sections of the config.
+	accounts = weaver.NewStore[string, account]("users/accounts")
+	sessions = weaver.NewStore[string, session]("users/sessions")
+)
+
+// T manages user accounts, and the accounts signed in on the sessions of the
+// frontend. A session is identified by the frontend's session cookie.
+//
+// SignUp and SignIn sign the account in on a new session, with a fresh random
+// id, and end the provided session. The frontend must replace the session
+// cookie with the new id, so that a session id planted in a browser before
+// the user signs in can't be used to act as the user.
+type T interface {
+	// SignUp creates an account and signs it in on a new session, which
+	// replaces the provided one. It returns the new user, and the id of the
+	// new session.
+	SignUp(ctx context.Context, sessionID, email, name, password string) (User, string, error)
+
+	// SignIn signs an existing account in on a new session, which replaces
+	// the provided one. It returns the user, and the id of the new session.
+	SignIn(ctx context.Context, sessionID, email, password string) (User, string, error)
+
+	// SignOut signs the provided session out of its account, if any.
+	SignOut(ctx context.Context, sessionID string) error
+
+	// GetUser returns the user signed in on the provided session, and
+	// whether a user is signed in.
+	GetUser(ctx context.Context, sessionID string) (User, bool, error)
+}
+
+type impl struct {
+	weaver.Implements[T]
+	weaver.WithRouter[router]
+
+	// Stores are not transactional. Sign ups of the same email are routed to
+	// the same replica, which serializes them, so that two of them can't both
+	// create the account.
+	mu sync.Mutex
+}
+
+// SignUp creates an account and signs it in on a new session, which replaces
+// the provided one.
+func (s *impl) SignUp(ctx context.Context, sessionID, email, name, password string) (User, string, error) {
+	email = normalizeEmail(email)
+	if email == "" || strings.TrimSpace(name) == "" {
+		return User{}, "", fmt.Errorf("%w: email and name are required", ErrInvalidAccount)
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return User{}, "", fmt.Errorf("%w: password must have between %d and %d characters", ErrInvalidAccount, minPasswordLength, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok, err := accounts.Get(ctx, s, email); err != nil {
+		return User{}, "", err
+	} else if ok {
+		return User{}, "", ErrEmailTaken
+	}
+	if err := accounts.Put(ctx, s, email, account{Name: name, PasswordHash: hash}); err != nil {
+		return User{}, "", err
+	}
+	newID, err := s.newSession(ctx, sessionID, email)
+	if err != nil {
+		return User{}, "", err
+	}
+	return User{Email: email, Name: name}, newID, nil
+}
+
+// SignIn signs an existing account in on a new session, which replaces the
+// provided one.
+func (s *impl) SignIn(ctx context.Context, sessionID, email, password string) (User, string, error) {
+	email = normalizeEmail(email)
+	a, ok, err := accounts.Get(ctx, s, email)
+	if err != nil {
+		return User{}, "", err
+	}
+	if !ok || bcrypt.CompareHashAndPassword(a.PasswordHash, []byte(password)) != nil {
+		return User{}, "", ErrInvalidCredentials
+	}
+	newID, err := s.newSession(ctx, sessionID, email)
+	if err != nil {
+		return User{}, "", err
+	}
+	return User{Email: email, Name: a.Name}, newID, nil
+}
+
+// newSession signs the provided account in on a new session with a fresh
+// random id, ends the old session, and returns the id of the new session.
+func (s *impl) newSession(ctx context.Context, oldID, email string) (string, error) {
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	newID := u.String()
+	if err := sessions.Put(ctx, s, newID, session{Email: email}); err != nil {
+		return "", err
+	}
+	if err := sessions.Delete(ctx, s, oldID); err != nil {
+		return "", err
+	}
+	return newID, nil
+}
+
+// SignOut signs the provided session out of its account, if any.
+func (s *impl) SignOut(ctx context.Context, sessionID string) error {
+	return sessions.Delete(ctx, s, sessionID)
+}
+
+// GetUser returns the user signed in on the provided session, and whether a
+// user is signed in.
+func (s *impl) GetUser(ctx context.Context, sessionID string) (User, bool, error) {
+	sess, ok, err := sessions.Get(ctx, s, sessionID)
+	if err != nil || !ok {
+		return User{}, false, err
+	}
+	a, ok, err := accounts.Get(ctx, s, sess.Email)
+	if err != nil || !ok {
+		return User{}, false, err
+	}
+	return User{Email: sess.Email, Name: a.Name}, true, nil
+}
+
+// normalizeEmail returns the canonical form of an email, used as the key of
+// its account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+type router struct{}
+
+func (router) SignUp(_ context.Context, _, email, _, _ string) string { return normalizeEmail(email) }
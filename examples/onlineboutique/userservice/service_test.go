@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package userservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/weavertest"
+)
+
+func TestAccounts(t *testing.T) {
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true})
+	users, err := weaver.Get[T](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedIn := func(session string) (User, bool) {
+		t.Helper()
+		u, ok, err := users.GetUser(ctx, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u, ok
+	}
+	alice := User{Email: "alice@example.com", Name: "Alice"}
+
+	// Signing up signs the new account in on a new session. Emails are
+	// normalized.
+	u, s1, err := users.SignUp(ctx, "s0", "  Alice@Example.com ", "Alice", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != alice {
+		t.Fatalf("SignUp: got %v, want %v", u, alice)
+	}
+	if s1 == "" || s1 == "s0" {
+		t.Fatalf("SignUp: got session %q, want a new session", s1)
+	}
+	if u, ok := signedIn(s1); !ok || u != alice {
+		t.Fatalf("GetUser(%s): got (%v, %t), want (%v, true)", s1, u, ok, alice)
+	}
+	if _, ok := signedIn("s0"); ok {
+		t.Fatal("GetUser(s0): the session provided to SignUp is signed in")
+	}
+
+	// An email can't be registered twice.
+	if _, _, err := users.SignUp(ctx, "s2", "alice@example.com", "Eve", "password2"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("SignUp of a taken email: got %v, want %v", err, ErrEmailTaken)
+	}
+
+	// Signing in requires the right password.
+	if _, _, err := users.SignIn(ctx, "s2", "alice@example.com", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("SignIn with a wrong password: got %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, _, err := users.SignIn(ctx, "s2", "bob@example.com", "password1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("SignIn of an unknown email: got %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, ok := signedIn("s2"); ok {
+		t.Fatal("GetUser(s2): signed in after failed sign ins")
+	}
+
+	// Signing in on a session that is already signed in replaces it with a
+	// new one.
+	u, s2, err := users.SignIn(ctx, s1, "ALICE@example.com", "password1")
+	if err != nil || u != alice {
+		t.Fatalf("SignIn: got (%v, %v), want (%v, nil)", u, err, alice)
+	}
+	if s2 == s1 {
+		t.Fatalf("SignIn: got the provided session %q, want a new session", s2)
+	}
+	if _, ok := signedIn(s1); ok {
+		t.Fatalf("GetUser(%s): the session provided to SignIn is signed in", s1)
+	}
+	_, s3, err := users.SignIn(ctx, "s3", "alice@example.com", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Signing out only signs out the provided session.
+	if err := users.SignOut(ctx, s2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := signedIn(s2); ok {
+		t.Fatalf("GetUser(%s): signed in after signing out", s2)
+	}
+	if _, ok := signedIn(s3); !ok {
+		t.Fatalf("GetUser(%s): signed out by another session", s3)
+	}
+}
+
+func TestSignUpInvalidAccount(t *testing.T) {
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true})
+	users, err := weaver.Get[T](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name, email, user, password string
+	}{
+		{"NoEmail", " ", "Alice", "password1"},
+		{"NoName", "alice@example.com", " ", "password1"},
+		{"ShortPassword", "alice@example.com", "Alice", "short"},
+		{"LongPassword", "alice@example.com", "Alice", strings.Repeat("x", maxPasswordLength+1)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := users.SignUp(ctx, "s", test.email, test.user, test.password)
+			if !errors.Is(err, ErrInvalidAccount) {
+				t.Fatalf("SignUp: got %v, want %v", err, ErrInvalidAccount)
+			}
+		})
+	}
+}
@@ -0,0 +1,802 @@
+package userservice
+
+// Code generated by "weaver generate". DO NOT EDIT.
+import (
+	"context"
+	"fmt"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"time"
+)
+
+func init() {
+	codegen.Register(codegen.Registration{
+		Name:   "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T",
+		Iface:  reflect.TypeOf((*T)(nil)).Elem(),
+		New:    func() any { return &impl{} },
+		Routed: true,
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return t_local_stub{impl: impl.(T), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return t_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), signUpMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignUp"}), signInMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignIn"}), signOutMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignOut"}), getUserMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "GetUser"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return t_server_stub{impl: impl.(T), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
+}
+
+// Local stub implementations.
+
+type t_local_stub struct {
+	impl        T
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s t_local_stub) SignUp(ctx context.Context, a0 string, a1 string, a2 string, a3 string) (r0 User, r1 string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "userservice.T.SignUp", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.SignUp(ctx, a0, a1, a2, a3)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignUp", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.impl.SignUp(ctx, a0, a1, a2, a3)
+		return
+	})
+	return
+}
+
+func (s t_local_stub) SignIn(ctx context.Context, a0 string, a1 string, a2 string) (r0 User, r1 string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "userservice.T.SignIn", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.SignIn(ctx, a0, a1, a2)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignIn", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.impl.SignIn(ctx, a0, a1, a2)
+		return
+	})
+	return
+}
+
+func (s t_local_stub) SignOut(ctx context.Context, a0 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "userservice.T.SignOut", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.SignOut(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignOut", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.SignOut(ctx, a0)
+	})
+	return
+}
+
+func (s t_local_stub) GetUser(ctx context.Context, a0 string) (r0 User, r1 bool, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "userservice.T.GetUser", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.GetUser(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "GetUser", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.impl.GetUser(ctx, a0)
+		return
+	})
+	return
+}
+
+// Client stub implementations.
+
+type t_client_stub struct {
+	stub           codegen.Stub
+	codec          codegen.Codec
+	interceptor    codegen.Interceptor
+	signUpMetrics  *codegen.MethodMetrics
+	signInMetrics  *codegen.MethodMetrics
+	signOutMetrics *codegen.MethodMetrics
+	getUserMetrics *codegen.MethodMetrics
+}
+
+func (s t_client_stub) SignUp(ctx context.Context, a0 string, a1 string, a2 string, a3 string) (r0 User, r1 string, err error) {
+	if s.interceptor == nil {
+		return s.callSignUp(ctx, a0, a1, a2, a3)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignUp", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.callSignUp(ctx, a0, a1, a2, a3)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callSignUp(ctx context.Context, a0 string, a1 string, a2 string, a3 string) (r0 User, r1 string, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.signUpMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "userservice.T.SignUp", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.signUpMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.signUpMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r router
+	shardKey := _hashT(r.SignUp(ctx, a0, a1, a2, a3))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1, a2, a3)
+		s.signUpMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 3, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.signUpMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0, &r1)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += (4 + len(a1))
+	size += (4 + len(a2))
+	size += (4 + len(a3))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.String(a1)
+	enc.String(a2)
+	enc.String(a3)
+
+	// Call the remote method.
+	s.signUpMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 3, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.signUpMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	(&r0).WeaverUnmarshal(dec)
+	r1 = dec.String()
+	err = dec.Error()
+	return
+}
+
+func (s t_client_stub) SignIn(ctx context.Context, a0 string, a1 string, a2 string) (r0 User, r1 string, err error) {
+	if s.interceptor == nil {
+		return s.callSignIn(ctx, a0, a1, a2)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignIn", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.callSignIn(ctx, a0, a1, a2)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callSignIn(ctx context.Context, a0 string, a1 string, a2 string) (r0 User, r1 string, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.signInMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "userservice.T.SignIn", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.signInMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.signInMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1, a2)
+		s.signInMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.signInMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0, &r1)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += (4 + len(a1))
+	size += (4 + len(a2))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.String(a1)
+	enc.String(a2)
+
+	// Call the remote method.
+	s.signInMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.signInMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	(&r0).WeaverUnmarshal(dec)
+	r1 = dec.String()
+	err = dec.Error()
+	return
+}
+
+func (s t_client_stub) SignOut(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callSignOut(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignOut", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callSignOut(ctx, a0)
+	})
+	return
+}
+
+func (s t_client_stub) callSignOut(ctx context.Context, a0 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.signOutMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "userservice.T.SignOut", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.signOutMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.signOutMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.signOutMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.signOutMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.signOutMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 2, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.signOutMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+func (s t_client_stub) GetUser(ctx context.Context, a0 string) (r0 User, r1 bool, err error) {
+	if s.interceptor == nil {
+		return s.callGetUser(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "GetUser", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, r1, err = s.callGetUser(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s t_client_stub) callGetUser(ctx context.Context, a0 string) (r0 User, r1 bool, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.getUserMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "userservice.T.GetUser", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.getUserMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.getUserMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getUserMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getUserMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0, &r1)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.getUserMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.getUserMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	(&r0).WeaverUnmarshal(dec)
+	r1 = dec.Bool()
+	err = dec.Error()
+	return
+}
+
+// Server stub implementations.
+
+type t_server_stub struct {
+	impl        T
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s t_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "SignUp":
+		return s.signUp
+	case "SignIn":
+		return s.signIn
+	case "SignOut":
+		return s.signOut
+	case "GetUser":
+		return s.getUser
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s t_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s t_server_stub) signUp(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	var a1 string
+	var a2 string
+	var a3 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1, &a2, &a3)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+		a2 = dec.String()
+		a3 = dec.String()
+	}
+	var r router
+	s.addLoad(_hashT(r.SignUp(ctx, a0, a1, a2, a3)), 1.0)
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 User
+	var r1 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, r1, appErr = s.impl.SignUp(ctx, a0, a1, a2, a3)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignUp", Args: []any{a0, a1, a2, a3}}, func(ctx context.Context) (err error) {
+			r0, r1, err = s.impl.SignUp(ctx, a0, a1, a2, a3)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0, r1), nil
+	}
+	enc := codegen.NewEncoder()
+	(r0).WeaverMarshal(enc)
+	enc.String(r1)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s t_server_stub) signIn(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	var a1 string
+	var a2 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1, &a2)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+		a2 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 User
+	var r1 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, r1, appErr = s.impl.SignIn(ctx, a0, a1, a2)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignIn", Args: []any{a0, a1, a2}}, func(ctx context.Context) (err error) {
+			r0, r1, err = s.impl.SignIn(ctx, a0, a1, a2)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0, r1), nil
+	}
+	enc := codegen.NewEncoder()
+	(r0).WeaverMarshal(enc)
+	enc.String(r1)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s t_server_stub) signOut(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.SignOut(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "SignOut", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.SignOut(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s t_server_stub) getUser(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 User
+	var r1 bool
+	var appErr error
+	if s.interceptor == nil {
+		r0, r1, appErr = s.impl.GetUser(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/examples/onlineboutique/userservice/T", Method: "GetUser", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, r1, err = s.impl.GetUser(ctx, a0)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0, r1), nil
+	}
+	enc := codegen.NewEncoder()
+	(r0).WeaverMarshal(enc)
+	enc.Bool(r1)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+// AutoMarshal implementations.
+
+var _ codegen.AutoMarshal = &User{}
+
+func (x *User) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("User.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(2)
+	enc.String(x.Email)
+	enc.String(x.Name)
+	enc.EndStruct(start)
+}
+
+func (x *User) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("User.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Email = dec.String()
+	}
+	if n > 1 {
+		x.Name = dec.String()
+	}
+	dec.EndStruct(end)
+}
+
+var _ codegen.AutoMarshal = &account{}
+
+func (x *account) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("account.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(2)
+	enc.String(x.Name)
+	serviceweaver_enc_slice_byte_87461245(enc, x.PasswordHash)
+	enc.EndStruct(start)
+}
+
+func (x *account) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("account.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Name = dec.String()
+	}
+	if n > 1 {
+		x.PasswordHash = serviceweaver_dec_slice_byte_87461245(dec)
+	}
+	dec.EndStruct(end)
+}
+
+func serviceweaver_enc_slice_byte_87461245(enc *codegen.Encoder, arg []byte) {
+	if arg == nil {
+		enc.Len(-1)
+		return
+	}
+	enc.Len(len(arg))
+	for i := 0; i < len(arg); i++ {
+		enc.Byte(arg[i])
+	}
+}
+
+func serviceweaver_dec_slice_byte_87461245(dec *codegen.Decoder) []byte {
+	n := dec.Len()
+	if n == -1 {
+		return nil
+	}
+	res := make([]byte, n)
+	for i := 0; i < n; i++ {
+		res[i] = dec.Byte()
+	}
+	return res
+}
+
+var _ codegen.AutoMarshal = &session{}
+
+func (x *session) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("session.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(1)
+	enc.String(x.Email)
+	enc.EndStruct(start)
+}
+
+func (x *session) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("session.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Email = dec.String()
+	}
+	dec.EndStruct(end)
+}
+
+// Router methods.
+
+// _hashT returns a 64 bit hash of the provided value.
+func _hashT(r string) uint64 {
+	var h codegen.Hasher
+	h.WriteString(string(r))
+	return h.Sum64()
+}
+
+// _orderedCodeT returns an order-preserving serialization of the provided value.
+func _orderedCodeT(r string) codegen.OrderedCode {
+	var enc codegen.OrderedEncoder
+	enc.WriteString(string(r))
+	return enc.Encode()
+}
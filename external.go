@@ -0,0 +1,352 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// An External is a client of an external service, like a database or an API
+// outside the application, that is opened from the config and injected into a
+// component, like a Ref. The struct tag of an External field names the
+// service. For example:
+//
+//	type orders struct {
+//	    weaver.Implements[Orders]
+//	    db weaver.External[*sql.DB] `weaver:"orders_db"`
+//	}
+//
+//	func (o *orders) Get(ctx context.Context, id string) (Order, error) {
+//	    row := o.db.Get().QueryRowContext(ctx, "SELECT ...", id)
+//	    ...
+//	}
+//
+// The connection settings of the service are set in the config (see
+// runtime.ExternalConfig), rather than read from the environment by every
+// component, so that the same code runs unchanged on every deployer:
+//
+//	[serviceweaver.external.orders_db]
+//	kind = "sql"
+//	driver = "postgres"
+//	dsn = "postgres://orders@db.internal/orders"
+//	max_connections = 20
+//
+// Every process opens one client of a service, with its connection pool,
+// shared by its components, and closes it when the process shuts down. The
+// kind of the service selects the driver that opens the client, and the type
+// T of the client: the built-in "sql" kind opens a *sql.DB, and other kinds
+// are registered with RegisterExternal.
+//
+// The health of every client is checked along with the health of the
+// components (see runtime.WeaveletConfig.HealthCheckInterval), and reported
+// by "weaver multi status". A failing external service doesn't make a
+// process unhealthy, since every process shares it.
+//
+// External fields, like Ref fields, must be direct fields of the
+// implementation struct. Use [weaver.Fill] to fill the External fields of
+// other structs.
+type External[T any] struct {
+	name  string
+	value T
+}
+
+// Get returns the client of the external service.
+func (e External[T]) Get() T { return e.value }
+
+// Name returns the name of the external service.
+func (e External[T]) Name() string { return e.name }
+
+// externalType returns the client type of the External.
+func (e *External[T]) externalType() reflect.Type { return reflect.TypeOf(&e.value).Elem() }
+
+// setExternal is used during component initialization to fill the External.
+func (e *External[T]) setExternal(name string, value any) {
+	e.name = name
+	e.value = value.(T)
+}
+
+// ExternalConfig holds the connection settings of an external service.
+type ExternalConfig = runtime.ExternalConfig
+
+// An ExternalDriver opens, checks, and closes the clients, of type T, of the
+// external services of one kind.
+type ExternalDriver[T any] struct {
+	// Open opens a client of the service with the provided settings. The
+	// client should honor the connection limits of the settings, if it
+	// keeps a connection pool.
+	Open func(context.Context, ExternalConfig) (T, error)
+
+	// Check, if not nil, checks the health of the service, e.g., by pinging
+	// it.
+	Check func(context.Context, T) error
+
+	// Close, if not nil, closes the client.
+	Close func(T) error
+}
+
+// RegisterExternal registers the driver of the external services of the
+// provided kind, e.g., "redis", selected by the kind of the services in the
+// config. For example:
+//
+//	weaver.RegisterExternal("redis", weaver.ExternalDriver[*redis.Client]{
+//	    Open: func(_ context.Context, c weaver.ExternalConfig) (*redis.Client, error) {
+//	        return redis.NewClient(&redis.Options{Addr: c.Address, PoolSize: c.MaxConnections}), nil
+//	    },
+//	    Check: func(ctx context.Context, client *redis.Client) error {
+//	        return client.Ping(ctx).Err()
+//	    },
+//	    Close: (*redis.Client).Close,
+//	})
+//
+// Every process must register the same drivers, before Init, typically in
+// main or an init function. RegisterExternal panics if the kind is empty or
+// already taken, or if Open is nil.
+func RegisterExternal[T any](kind string, driver ExternalDriver[T]) {
+	if kind == "" {
+		panic(fmt.Errorf("RegisterExternal: empty kind"))
+	}
+	if driver.Open == nil {
+		panic(fmt.Errorf("RegisterExternal(%q): nil Open", kind))
+	}
+	d := &externalDriver{
+		typ: reflect.TypeOf(&driver.Open).Elem().Out(0),
+		open: func(ctx context.Context, config ExternalConfig) (any, error) {
+			return driver.Open(ctx, config)
+		},
+	}
+	if driver.Check != nil {
+		d.check = func(ctx context.Context, client any) error { return driver.Check(ctx, client.(T)) }
+	}
+	if driver.Close != nil {
+		d.close = func(client any) error { return driver.Close(client.(T)) }
+	}
+
+	externalDrivers.mu.Lock()
+	defer externalDrivers.mu.Unlock()
+	if _, ok := externalDrivers.drivers[kind]; ok {
+		panic(fmt.Errorf("RegisterExternal: kind %q already registered", kind))
+	}
+	externalDrivers.drivers[kind] = d
+}
+
+// externalDriver is a type-erased ExternalDriver.
+type externalDriver struct {
+	typ   reflect.Type // type of the clients
+	open  func(context.Context, ExternalConfig) (any, error)
+	check func(context.Context, any) error // nil if the driver has no check
+	close func(any) error                  // nil if the driver has no close
+}
+
+var externalDrivers = struct {
+	mu      sync.Mutex
+	drivers map[string]*externalDriver // by kind
+}{drivers: map[string]*externalDriver{}}
+
+func init() {
+	// The "sql" kind opens a *sql.DB with a database/sql driver.
+	RegisterExternal("sql", ExternalDriver[*sql.DB]{
+		Open: func(_ context.Context, config ExternalConfig) (*sql.DB, error) {
+			db, err := sql.Open(config.Driver, config.DSN)
+			if err != nil {
+				return nil, err
+			}
+			db.SetMaxOpenConns(config.MaxConnections)
+			if config.MaxIdleConnections > 0 {
+				db.SetMaxIdleConns(config.MaxIdleConnections)
+			}
+			db.SetConnMaxLifetime(config.ConnectionLifetime)
+			return db, nil
+		},
+		Check: func(ctx context.Context, db *sql.DB) error { return db.PingContext(ctx) },
+		Close: (*sql.DB).Close,
+	})
+}
+
+// fillExternals fills the External fields of the struct pointed to by obj,
+// using get to get the client of every External.
+func fillExternals(obj any, get func(name string, t reflect.Type) (any, error)) error {
+	p := reflect.ValueOf(obj)
+	if p.Kind() != reflect.Pointer || p.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%T is not a pointer to a struct", obj)
+	}
+	s := p.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		// See fillRefs.
+		f := s.Field(i)
+		ext, ok := reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Interface().(interface {
+			externalType() reflect.Type
+			setExternal(string, any)
+		})
+		if !ok {
+			continue
+		}
+		field := s.Type().Field(i)
+		name, ok := field.Tag.Lookup("weaver")
+		if !ok || name == "" {
+			return fmt.Errorf("field %s: missing `weaver:\"name\"` struct tag naming the external service", field.Name)
+		}
+		value, err := get(name, ext.externalType())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		ext.setExternal(name, value)
+	}
+	return nil
+}
+
+// externals holds the clients of the external services used in a weavelet. A
+// client is opened the first time a component uses its service. The zero
+// value, with no services, is ready to use.
+type externals struct {
+	configs map[string]runtime.ExternalConfig // by service name
+
+	mu      sync.Mutex
+	clients map[string]*externalClient // by service name
+}
+
+// externalClient is the client of an external service.
+type externalClient struct {
+	config runtime.ExternalConfig
+	driver *externalDriver
+	value  any
+	err    error // error of the latest health check, guarded by externals.mu
+}
+
+// get returns the client, of type t, of the named external service, opening
+// it if needed.
+func (x *externals) get(ctx context.Context, name string, t reflect.Type) (any, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if c, ok := x.clients[name]; ok {
+		if c.driver.typ != t {
+			return nil, fmt.Errorf("external service %q is a %v, not a %v", name, c.driver.typ, t)
+		}
+		return c.value, nil
+	}
+
+	config, ok := x.configs[name]
+	if !ok {
+		return nil, fmt.Errorf("external service %q has no [serviceweaver.external.%s] section in the config", name, name)
+	}
+	externalDrivers.mu.Lock()
+	driver, ok := externalDrivers.drivers[config.Kind]
+	externalDrivers.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("external service %q: unknown kind %q; register it with weaver.RegisterExternal", name, config.Kind)
+	}
+	if driver.typ != t {
+		return nil, fmt.Errorf("external service %q of kind %q is a %v, not a %v", name, config.Kind, driver.typ, t)
+	}
+	value, err := driver.open(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("external service %q: open: %w", name, err)
+	}
+	if x.clients == nil {
+		x.clients = map[string]*externalClient{}
+	}
+	x.clients[name] = &externalClient{config: config, driver: driver, value: value}
+	return value, nil
+}
+
+// getExternal returns the client, of type t, of the named external service,
+// opening it if needed, and starts checking the health of the service.
+func (w *weavelet) getExternal(ctx context.Context, name string, t reflect.Type) (any, error) {
+	value, err := w.externals.get(ctx, name, t)
+	if err != nil {
+		return nil, err
+	}
+	w.startHealthChecks()
+	return value, nil
+}
+
+// check checks the health of every external service, bounding each check by
+// timeout, and records the results. It logs the services whose health
+// changes.
+func (x *externals) check(ctx context.Context, timeout time.Duration, logger *slog.Logger) {
+	x.mu.Lock()
+	clients := make(map[string]*externalClient, len(x.clients))
+	for name, c := range x.clients {
+		clients[name] = c
+	}
+	x.mu.Unlock()
+
+	for name, c := range clients {
+		if c.driver.check == nil {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.driver.check(checkCtx, c.value)
+		cancel()
+		if ctx.Err() != nil {
+			// The weavelet is stopping.
+			return
+		}
+
+		x.mu.Lock()
+		switch {
+		case err != nil && c.err == nil:
+			logger.Error("External service health check failed", err, "external", name)
+		case err == nil && c.err != nil:
+			logger.Info("External service health check passed", "external", name)
+		}
+		c.err = err
+		x.mu.Unlock()
+	}
+}
+
+// health returns the health of the external services, sorted by name.
+func (x *externals) health() []*protos.ExternalHealth {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var health []*protos.ExternalHealth
+	for name, c := range x.clients {
+		h := &protos.ExternalHealth{Name: name, Kind: c.config.Kind}
+		if c.err != nil {
+			h.Error = c.err.Error()
+		}
+		health = append(health, h)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Name < health[j].Name })
+	return health
+}
+
+// close closes the clients of the external services, and returns the first
+// error, if any.
+func (x *externals) close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var first error
+	for name, c := range x.clients {
+		if c.driver.close == nil {
+			continue
+		}
+		if err := c.driver.close(c.value); err != nil && first == nil {
+			first = fmt.Errorf("external service %q: %w", name, err)
+		}
+	}
+	x.clients = nil
+	return first
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slog"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeExternal is the client of the "fake" kind of external services.
+type fakeExternal struct {
+	address string
+	healthy bool
+	closed  bool
+}
+
+func init() {
+	RegisterExternal("fake", ExternalDriver[*fakeExternal]{
+		Open: func(_ context.Context, config ExternalConfig) (*fakeExternal, error) {
+			return &fakeExternal{address: config.Address, healthy: true}, nil
+		},
+		Check: func(_ context.Context, f *fakeExternal) error {
+			if !f.healthy {
+				return errors.New("unreachable")
+			}
+			return nil
+		},
+		Close: func(f *fakeExternal) error {
+			f.closed = true
+			return nil
+		},
+	})
+}
+
+func TestFillExternals(t *testing.T) {
+	x := &externals{configs: map[string]runtime.ExternalConfig{
+		"orders": {Kind: "fake", Address: "orders.internal:443"},
+		"db":     {Kind: "sql", Driver: "nodriver", DSN: "dsn"},
+	}}
+	get := func(name string, t reflect.Type) (any, error) {
+		return x.get(context.Background(), name, t)
+	}
+
+	// Components share the client of a service.
+	type impl struct {
+		orders External[*fakeExternal] `weaver:"orders"`
+		other  int
+	}
+	var a, b impl
+	if err := fillExternals(&a, get); err != nil {
+		t.Fatal(err)
+	}
+	if err := fillExternals(&b, get); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := a.orders.Name(), "orders"; got != want {
+		t.Errorf("Name: got %q, want %q", got, want)
+	}
+	if got, want := a.orders.Get().address, "orders.internal:443"; got != want {
+		t.Errorf("address: got %q, want %q", got, want)
+	}
+	if a.orders.Get() != b.orders.Get() {
+		t.Errorf("components got different clients of the same service")
+	}
+
+	for _, test := range []struct {
+		name string
+		obj  any
+		want string
+	}{
+		{"NoTag", &struct{ e External[*fakeExternal] }{}, "missing"},
+		{"NoConfig", &struct {
+			e External[*fakeExternal] `weaver:"payments"`
+		}{}, "no [serviceweaver.external.payments] section"},
+		{"WrongType", &struct {
+			e External[string] `weaver:"orders"`
+		}{}, "not a string"},
+		{"UnregisteredSQLDriver", &struct {
+			e External[*sql.DB] `weaver:"db"`
+		}{}, "unknown driver"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := fillExternals(test.obj, get)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("fillExternals: got %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestExternalHealth(t *testing.T) {
+	ctx := context.Background()
+	x := &externals{configs: map[string]runtime.ExternalConfig{
+		"a": {Kind: "fake"},
+		"b": {Kind: "fake"},
+	}}
+	var clients []*fakeExternal
+	for _, name := range []string{"b", "a"} {
+		c, err := x.get(ctx, name, reflect.TypeOf(&fakeExternal{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, c.(*fakeExternal))
+	}
+	clients[0].healthy = false // b
+
+	logger := slog.New(slog.NewTextHandler(io.Discard))
+	x.check(ctx, time.Second, logger)
+	want := []*protos.ExternalHealth{
+		{Name: "a", Kind: "fake"},
+		{Name: "b", Kind: "fake", Error: "unreachable"},
+	}
+	if diff := cmp.Diff(want, x.health(), protocmp.Transform()); diff != "" {
+		t.Fatalf("health (-want +got):\n%s", diff)
+	}
+
+	if err := x.close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range clients {
+		if !c.closed {
+			t.Errorf("client %v not closed", c)
+		}
+	}
+}
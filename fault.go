@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// faultyStub is a codegen.Stub that injects faults, as specified by
+// weavertest, into the calls it makes through another codegen.Stub. inject is
+// called with the name of the method before every call; if it returns an
+// error, the call fails with the error without being made.
+type faultyStub struct {
+	stub    codegen.Stub
+	methods []string // method names, by method index
+	inject  func(ctx context.Context, method string) error
+}
+
+var _ codegen.Stub = &faultyStub{}
+
+// Tracer implements the codegen.Stub interface.
+func (f *faultyStub) Tracer() trace.Tracer {
+	return f.stub.Tracer()
+}
+
+// Run implements the codegen.Stub interface.
+func (f *faultyStub) Run(ctx context.Context, method int, args []byte, shardKey uint64) ([]byte, error) {
+	if err := f.inject(ctx, f.methods[method]); err != nil {
+		return nil, err
+	}
+	return f.stub.Run(ctx, method, args, shardKey)
+}
+
+// Stream implements the codegen.Stub interface.
+func (f *faultyStub) Stream(ctx context.Context, method int, args []byte, shardKey uint64) (codegen.ClientStream, error) {
+	if err := f.inject(ctx, f.methods[method]); err != nil {
+		return nil, err
+	}
+	return f.stub.Stream(ctx, method, args, shardKey)
+}
+
+// WrapError implements the codegen.Stub interface.
+func (f *faultyStub) WrapError(err error) error {
+	return f.stub.WrapError(err)
+}
+
+// loopbackStub is a codegen.Stub that makes calls to a local component
+// through the component's server stub, as if the component were remote. It
+// lets a faultyStub inject faults into calls to local components.
+type loopbackStub struct {
+	component string         // name of the component
+	server    codegen.Server // server stub of the component
+	methods   []string       // method names, by method index
+	tracer    trace.Tracer   // component tracer
+}
+
+var _ codegen.Stub = &loopbackStub{}
+
+// Tracer implements the codegen.Stub interface.
+func (l *loopbackStub) Tracer() trace.Tracer {
+	return l.tracer
+}
+
+// Run implements the codegen.Stub interface.
+func (l *loopbackStub) Run(ctx context.Context, method int, args []byte, _ uint64) ([]byte, error) {
+	return l.server.GetStubFn(l.methods[method])(ctx, args)
+}
+
+// Stream implements the codegen.Stub interface.
+func (l *loopbackStub) Stream(ctx context.Context, method int, args []byte, _ uint64) (codegen.ClientStream, error) {
+	handler := l.server.GetStreamFn(l.methods[method])
+	if handler == nil {
+		return nil, fmt.Errorf("%s.%s is not a streaming method", l.component, l.methods[method])
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &loopbackStream{
+		toServer: make(chan []byte),
+		toClient: make(chan []byte),
+		done:     ctx.Done(),
+		cancel:   cancel,
+	}
+	go func() {
+		s.err = handler(ctx, args, loopbackServerStream{s})
+		close(s.toClient)
+	}()
+	return s, nil
+}
+
+// WrapError implements the codegen.Stub interface.
+func (l *loopbackStub) WrapError(err error) error {
+	return (&stub{component: l.component}).WrapError(err)
+}
+
+// loopbackStream is the client side of a streaming call made by a
+// loopbackStub. loopbackServerStream is the server side.
+type loopbackStream struct {
+	toServer  chan []byte     // values sent by the client; closed by CloseSend
+	toClient  chan []byte     // values sent by the server; closed when the handler returns
+	done      <-chan struct{} // closed by Close
+	cancel    func()          // closes done
+	closeSend sync.Once       // used to close toServer
+	err       error           // the handler's error, set before toClient is closed
+}
+
+var _ codegen.ClientStream = &loopbackStream{}
+
+// errLoopbackClosed is returned by the methods of a loopbackStream, and its
+// server side, once the call has ended.
+var errLoopbackClosed = errors.New("stream closed")
+
+// Send implements the codegen.ClientStream interface.
+func (s *loopbackStream) Send(b []byte) error {
+	select {
+	case s.toServer <- b:
+		return nil
+	case <-s.done:
+		return errLoopbackClosed
+	}
+}
+
+// CloseSend implements the codegen.ClientStream interface.
+func (s *loopbackStream) CloseSend() error {
+	s.closeSend.Do(func() { close(s.toServer) })
+	return nil
+}
+
+// Recv implements the codegen.ClientStream interface.
+func (s *loopbackStream) Recv() ([]byte, error) {
+	b, ok := <-s.toClient
+	if ok {
+		return b, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return nil, io.EOF
+}
+
+// Close implements the codegen.ClientStream interface.
+func (s *loopbackStream) Close() {
+	s.cancel()
+}
+
+// loopbackServerStream is the server side of a loopbackStream.
+type loopbackServerStream struct {
+	s *loopbackStream
+}
+
+// Send implements the codegen.ServerStream interface.
+func (s loopbackServerStream) Send(b []byte) error {
+	select {
+	case s.s.toClient <- b:
+		return nil
+	case <-s.s.done:
+		return errLoopbackClosed
+	}
+}
+
+// Recv implements the codegen.ServerStream interface.
+func (s loopbackServerStream) Recv() ([]byte, error) {
+	select {
+	case b, ok := <-s.s.toServer:
+		if !ok {
+			return nil, io.EOF
+		}
+		return b, nil
+	case <-s.s.done:
+		return nil, errLoopbackClosed
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slices"
+)
+
+// Federation lets one deployment export some of its components, and other
+// deployments, e.g., the deployments of other teams, import and call them.
+//
+// The exporting deployment lists the exported components in its
+// [serviceweaver.federation] section (see runtime.FederationConfig). The
+// weavelets hosting its main component serve them on a federation gateway,
+// which authenticates the importing deployments with mutual TLS, and forwards
+// their calls to the exported components, wherever they run.
+//
+// The importing deployment links the package of an imported component, for
+// its interface and generated client stub, and lists it in an
+// [serviceweaver.imports] section (see runtime.ImportConfig). The component
+// is fetched with weaver.Ref or weaver.Get as usual, but it is never started:
+// the client stub sends its calls to the federation gateway of the exporting
+// deployment instead.
+
+// federationHandshakeTimeout bounds the TLS handshake of the connections
+// accepted by a federation gateway.
+const federationHandshakeTimeout = 10 * time.Second
+
+type federationLabels struct {
+	Component string // exported component
+	Client    string // name of the importing client
+}
+
+var federationCalls = metrics.NewCounterMap[federationLabels](
+	"serviceweaver_federation_call_count",
+	"Count of calls received by the federation gateway from other deployments",
+)
+
+// serveFederation serves the components exported to other deployments, as
+// configured by w.federation, until w.ctx is cancelled.
+func (w *weavelet) serveFederation() error {
+	for name := range w.federation.Exports {
+		if _, err := w.getComponent(name); err != nil {
+			return fmt.Errorf("federation: export %q: %w", name, err)
+		}
+	}
+	config, err := gatewayTLSConfig(w.federation)
+	if err != nil {
+		return fmt.Errorf("federation: %w", err)
+	}
+	lis, err := net.Listen("tcp", w.federation.Address)
+	if err != nil {
+		return fmt.Errorf("federation: %w", err)
+	}
+	w.env.SystemLogger().Info("Serving federation gateway", "address", lis.Addr().String())
+	startWork(w.ctx, "serve federation", func() error {
+		return w.acceptFederation(lis, config)
+	})
+	return nil
+}
+
+// acceptFederation accepts the connections of other deployments to the
+// federation gateway, until w.ctx is cancelled.
+func (w *weavelet) acceptFederation(lis net.Listener, config *tls.Config) error {
+	go func() {
+		<-w.ctx.Done()
+		lis.Close()
+	}()
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if w.ctx.Err() != nil {
+				return w.ctx.Err()
+			}
+			return fmt.Errorf("federation: %w", err)
+		}
+		go w.serveFederationConn(conn, config)
+	}
+}
+
+// serveFederationConn authenticates the client of the provided connection,
+// and serves the calls it sends to the exported components.
+func (w *weavelet) serveFederationConn(conn net.Conn, config *tls.Config) {
+	tlsConn := tls.Server(conn, config)
+	ctx, cancel := context.WithTimeout(w.ctx, federationHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		w.env.SystemLogger().Debug("Federation handshake failed", "remote", conn.RemoteAddr().String(), "err", err)
+		conn.Close()
+		return
+	}
+	names := certificateNames(tlsConn.ConnectionState().PeerCertificates[0])
+	w.env.SystemLogger().Debug("Federation client connected", "remote", conn.RemoteAddr().String(), "names", names)
+	call.ServeOn(w.ctx, tlsConn, w.federationHandlers(names), w.transport.serverOpts)
+}
+
+// federationHandlers returns the handlers of the calls to the exported
+// components made by the client with the provided certificate names.
+func (w *weavelet) federationHandlers(names []string) *call.HandlerMap {
+	var client string
+	if len(names) > 0 {
+		client = names[0]
+	}
+	handlers := &call.HandlerMap{}
+	for name, export := range w.federation.Exports {
+		c := w.componentsByName[name]
+		allowed := len(export.Clients) == 0
+		for _, n := range names {
+			allowed = allowed || slices.Contains(export.Clients, n)
+		}
+		for i, n := 0, c.info.Iface.NumMethod(); i < n; i++ {
+			m := c.info.Iface.Method(i)
+			handlers.SetVersioned(name, m.Name, methodVersion(m), w.exportHandler(c, i, client, allowed))
+		}
+	}
+	return handlers
+}
+
+// exportHandler returns the handler of the calls to the provided method of
+// the provided exported component made by the named client.
+func (w *weavelet) exportHandler(c *component, method int, client string, allowed bool) call.Handler {
+	calls := federationCalls.Get(federationLabels{Component: c.info.Name, Client: client})
+	return func(ctx context.Context, args []byte) ([]byte, error) {
+		if !allowed {
+			return nil, fmt.Errorf("%w: client %q may not call %s", call.PermissionDenied, client, c.info.Name)
+		}
+		calls.Add(1)
+		stub, err := w.clientStub(c)
+		if err != nil {
+			return nil, err
+		}
+		// The metadata sent by the client, e.g., its principal, is not
+		// trusted. The client itself is the principal of the call.
+		ctx = withPrincipal(ctx, Principal{Name: client, Scheme: "mtls"})
+		return stub.Run(ctx, method, args, 0)
+	}
+}
+
+// importStub initializes the stub of the provided imported component, which
+// sends its calls to the federation gateway of the deployment that exports
+// the component.
+func (w *weavelet) importStub(c *component, config runtime.ImportConfig) error {
+	tlsConfig, err := importTLSConfig(config)
+	if err != nil {
+		return fmt.Errorf("import %q: %w", c.info.Name, err)
+	}
+	opts := w.transport.clientOpts
+	opts.TLSConfig = tlsConfig
+	resolver := call.NewConstantResolver(call.TCP(config.Address))
+	client, err := call.Connect(w.ctx, resolver, opts)
+	if err != nil {
+		return fmt.Errorf("import %q: %w", c.info.Name, err)
+	}
+
+	n := c.info.Iface.NumMethod()
+	methods := make([]call.MethodKey, n)
+	versions := make([]uint64, n)
+	priority := make([]Priority, n)
+	policies := make([]*cachePolicy, n)
+	calls := make([]*callPolicy, n)
+	limits := make([]*payloadLimits, n)
+	for i := 0; i < n; i++ {
+		m := c.info.Iface.Method(i)
+		methods[i] = call.MakeMethodKey(c.info.Name, m.Name)
+		versions[i] = methodVersion(m)
+		priority[i] = parsePriority(w.methodConfigs[c.info.Name+"."+m.Name].Priority)
+		policies[i] = w.cachePolicy(c.info.Name, m.Name)
+		calls[i] = w.callPolicy(c.info.Name, m.Name)
+		limits[i] = w.payloadLimits(c.info.Name, m.Name)
+	}
+	c.stub = &componentStub{
+		stub: &stub{
+			component: c.info.Name,
+			client:    client,
+			methods:   methods,
+			versions:  versions,
+			priority:  priority,
+			tracer:    w.componentTracer(c.info.Name),
+			policies:  policies,
+			cache:     w.cache,
+			clock:     w.clock,
+			calls:     calls,
+			limits:    limits,
+			breaker:   w.circuitBreaker(c.info.Name),
+		},
+	}
+	return nil
+}
+
+// gatewayTLSConfig returns the TLS config of the federation gateway, which
+// requires clients to present a certificate signed by one of the authorities
+// in config.ClientCAFile.
+func gatewayTLSConfig(config runtime.FederationConfig) (*tls.Config, error) {
+	tlsConfig, err := tlsConfig(ListenerOptions{CertFile: config.CertFile, KeyFile: config.KeyFile})
+	if err != nil {
+		return nil, err
+	}
+	cas, err := loadCertPool(config.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientCAs = cas
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return tlsConfig, nil
+}
+
+// importTLSConfig returns the TLS config of the connections to the
+// federation gateway of an imported component.
+func importTLSConfig(config runtime.ImportConfig) (*tls.Config, error) {
+	cas, err := loadCertPool(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	r := &certReloader{certFile: config.CertFile, keyFile: config.KeyFile}
+	if err := r.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	serverName := config.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(config.Address)
+		if err != nil {
+			return nil, err
+		}
+		serverName = host
+	}
+	return &tls.Config{
+		RootCAs:    cas,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.getCertificate(nil)
+		},
+	}, nil
+}
+
+// loadCertPool returns the pool of the PEM encoded certificates in the
+// provided file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %q", file)
+	}
+	return pool, nil
+}
+
+// certificateNames returns the names of the subject of the provided
+// certificate: its common name, if any, followed by its DNS names.
+func certificateNames(cert *x509.Certificate) []string {
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return append(names, cert.DNSNames...)
+}
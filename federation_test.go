@@ -0,0 +1,196 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// federatedEcho is the interface of the component exported in the tests.
+type federatedEcho interface {
+	Echo(context.Context, string) (string, error)
+}
+
+// echoServer is a codegen.Server whose Echo method replies with its
+// arguments, prefixed by the principal of the call.
+type echoServer struct{}
+
+func (echoServer) GetStubFn(string) func(context.Context, []byte) ([]byte, error) {
+	return func(ctx context.Context, args []byte) ([]byte, error) {
+		p, _ := PrincipalFromContext(ctx)
+		return []byte(p.Name + ":" + string(args)), nil
+	}
+}
+
+func (echoServer) GetStreamFn(string) func(context.Context, []byte, codegen.ServerStream) error {
+	return nil
+}
+
+// writeFederationCert writes a new self-signed certificate for the provided
+// name and its key to <dir>/<name>.pem and <dir>/<name>.key.
+func writeFederationCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// testFederationWeavelet returns a weavelet hosting the federatedEcho
+// component, named "a/Echo", locally.
+func testFederationWeavelet(t *testing.T) *weavelet {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := &weavelet{
+		ctx:              ctx,
+		env:              leaseEnv{t: t},
+		info:             &protos.EnvelopeInfo{Id: "a"},
+		componentsByName: map[string]*component{},
+		transport: &transport{
+			clientOpts: call.ClientOptions{Logger: leaseEnv{t: t}.SystemLogger()},
+			serverOpts: call.ServerOptions{Logger: leaseEnv{t: t}.SystemLogger()},
+		},
+	}
+	info := &codegen.Registration{Name: "a/Echo", Iface: reflect.TypeOf((*federatedEcho)(nil)).Elem()}
+	c := &component{wlet: w, info: info}
+	c.registerInit.Do(func() {})
+	c.local.TryWrite(true)
+	c.implInit.Do(func() { c.impl = &componentImpl{component: c, serverStub: echoServer{}} })
+	w.componentsByName[info.Name] = c
+	return w
+}
+
+// testFederation starts a federation gateway that exports "a/Echo" to the
+// provided clients, and returns the stub of "a/Echo" in a deployment that
+// imports it with a certificate for the provided client name.
+func testFederation(t *testing.T, clients []string, client string) codegen.Stub {
+	t.Helper()
+	dir := t.TempDir()
+	gatewayCert, gatewayKey := writeFederationCert(t, dir, "gateway")
+	clientCert, clientKey := writeFederationCert(t, dir, client)
+
+	exporter := testFederationWeavelet(t)
+	exporter.federation = runtime.FederationConfig{
+		CertFile:     gatewayCert,
+		KeyFile:      gatewayKey,
+		ClientCAFile: clientCert,
+		Exports:      map[string]runtime.ExportConfig{"a/Echo": {Clients: clients}},
+	}
+	config, err := gatewayTLSConfig(exporter.federation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go exporter.acceptFederation(lis, config)
+
+	importer := testFederationWeavelet(t)
+	importer.imports = map[string]runtime.ImportConfig{
+		"a/Echo": {
+			Address:    lis.Addr().String(),
+			CAFile:     gatewayCert,
+			CertFile:   clientCert,
+			KeyFile:    clientKey,
+			ServerName: "gateway",
+		},
+	}
+	stub, err := importer.clientStub(importer.componentsByName["a/Echo"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stub
+}
+
+func TestFederation(t *testing.T) {
+	// Test plan: Export a component from one deployment, import it in
+	// another, and check that the calls of the importing deployment reach
+	// the component, with the client as their principal.
+	stub := testFederation(t, []string{"payments"}, "payments")
+	got, err := stub.Run(context.Background(), 0, []byte("hello"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "payments:hello"; string(got) != want {
+		t.Fatalf("Run: got %q, want %q", got, want)
+	}
+}
+
+func TestFederationForbiddenClient(t *testing.T) {
+	// Test plan: Import a component with a certificate for a client that
+	// the exporting deployment doesn't allow, and check that calls fail.
+	stub := testFederation(t, []string{"payments"}, "shipping")
+	_, err := stub.Run(context.Background(), 0, []byte("hello"), 0)
+	if !errors.Is(stub.WrapError(err), ErrPermissionDenied) {
+		t.Fatalf("Run: got %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestImportedComponentNotStarted(t *testing.T) {
+	// Imported components are never registered with the deployer.
+	w := testFederationWeavelet(t)
+	w.imports = map[string]runtime.ImportConfig{"a/Echo": {Address: "localhost:1"}}
+	if err := w.awaitReady(w.componentsByName["a/Echo"]); err != nil {
+		t.Fatalf("awaitReady: %v", err)
+	}
+}
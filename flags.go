@@ -0,0 +1,183 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+// Flags are the feature flags of a deployment. Flags are named strings, set
+// and unset at runtime with the deployer's "flags" command, e.g.,
+//
+//	weaver multi flags set 2c80d811 recommendations.v2=true
+//
+// without redeploying the application. Every process of the deployment sees
+// the same flags, although a process may briefly see stale flags while an
+// update propagates. Flags are not persisted; a new deployment starts with no
+// flags set.
+//
+// The typed accessors, like Bool, return the provided fallback if a flag
+// isn't set, or if its value can't be parsed.
+//
+//	flags := weaver.GetFlags(r)
+//	if flags.Bool("recommendations.v2", false) {
+//	    ...
+//	}
+type Flags struct {
+	ctx context.Context // passed to subscribers
+
+	mu          sync.Mutex
+	values      map[string]string                 // flag values, by name
+	subscribers []func(context.Context, []string) // registered with OnChange
+	pending     map[string]bool                   // changed flags to notify
+	notifying   bool                              // is a goroutine notifying?
+}
+
+// GetFlags returns the feature flags of the deployment.
+func GetFlags(requester Instance) *Flags {
+	return requester.rep().wlet.flags
+}
+
+// newFlags returns a new set of flags, with no flags set.
+func newFlags(ctx context.Context) *Flags {
+	return &Flags{ctx: ctx, values: map[string]string{}}
+}
+
+// Lookup returns the value of the named flag, and whether it is set.
+func (f *Flags) Lookup(name string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.values[name]
+	return value, ok
+}
+
+// String returns the value of the named flag, or fallback if it isn't set.
+func (f *Flags) String(name, fallback string) string {
+	if value, ok := f.Lookup(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// Bool returns the value of the named flag, parsed by strconv.ParseBool.
+func (f *Flags) Bool(name string, fallback bool) bool {
+	return lookupFlag(f, name, fallback, strconv.ParseBool)
+}
+
+// Int returns the value of the named flag, parsed by strconv.Atoi.
+func (f *Flags) Int(name string, fallback int) int {
+	return lookupFlag(f, name, fallback, strconv.Atoi)
+}
+
+// Float64 returns the value of the named flag, parsed by strconv.ParseFloat.
+func (f *Flags) Float64(name string, fallback float64) float64 {
+	return lookupFlag(f, name, fallback, func(s string) (float64, error) {
+		return strconv.ParseFloat(s, 64)
+	})
+}
+
+// Duration returns the value of the named flag, parsed by
+// time.ParseDuration.
+func (f *Flags) Duration(name string, fallback time.Duration) time.Duration {
+	return lookupFlag(f, name, fallback, time.ParseDuration)
+}
+
+// lookupFlag returns the parsed value of the named flag, or fallback if it
+// isn't set or can't be parsed.
+func lookupFlag[T any](f *Flags, name string, fallback T, parse func(string) (T, error)) T {
+	value, ok := f.Lookup(name)
+	if !ok {
+		return fallback
+	}
+	parsed, err := parse(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// OnChange registers a function that is called whenever flags are set,
+// changed, or unset, with the sorted names of the changed flags. The
+// functions are called one at a time, in the background, after the flags are
+// updated. Changes made while the functions run are reported by a later call.
+func (f *Flags) OnChange(fn func(ctx context.Context, changed []string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers = append(f.subscribers, fn)
+}
+
+// set replaces the flags, and notifies the subscribers of the flags that
+// changed.
+func (f *Flags) set(values map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var changed []string
+	for name, value := range values {
+		if old, ok := f.values[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range f.values {
+		if _, ok := values[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	f.values = maps.Clone(values)
+	if f.values == nil {
+		f.values = map[string]string{}
+	}
+	if len(changed) == 0 || len(f.subscribers) == 0 {
+		return
+	}
+
+	if f.pending == nil {
+		f.pending = map[string]bool{}
+	}
+	for _, name := range changed {
+		f.pending[name] = true
+	}
+	if !f.notifying {
+		f.notifying = true
+		go f.notify()
+	}
+}
+
+// notify notifies the subscribers of the pending changes, until there are
+// none left.
+func (f *Flags) notify() {
+	for {
+		f.mu.Lock()
+		if len(f.pending) == 0 {
+			f.notifying = false
+			f.mu.Unlock()
+			return
+		}
+		changed := maps.Keys(f.pending)
+		sort.Strings(changed)
+		f.pending = nil
+		subscribers := append([]func(context.Context, []string){}, f.subscribers...)
+		f.mu.Unlock()
+
+		for _, fn := range subscribers {
+			fn(f.ctx, changed)
+		}
+	}
+}
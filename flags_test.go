@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFlagsAccessors(t *testing.T) {
+	f := newFlags(context.Background())
+	f.set(map[string]string{
+		"enabled": "true",
+		"limit":   "200",
+		"ratio":   "0.25",
+		"timeout": "1.5s",
+		"name":    "v2",
+		"broken":  "not a number",
+	})
+
+	if got, want := f.Bool("enabled", false), true; got != want {
+		t.Errorf("Bool: got %v, want %v", got, want)
+	}
+	if got, want := f.Int("limit", 10), 200; got != want {
+		t.Errorf("Int: got %v, want %v", got, want)
+	}
+	if got, want := f.Float64("ratio", 1), 0.25; got != want {
+		t.Errorf("Float64: got %v, want %v", got, want)
+	}
+	if got, want := f.Duration("timeout", time.Second), 1500*time.Millisecond; got != want {
+		t.Errorf("Duration: got %v, want %v", got, want)
+	}
+	if got, want := f.String("name", "v1"), "v2"; got != want {
+		t.Errorf("String: got %v, want %v", got, want)
+	}
+
+	// Unset and unparsable flags return the fallback.
+	if got, want := f.Int("missing", 10), 10; got != want {
+		t.Errorf("Int(missing): got %v, want %v", got, want)
+	}
+	if got, want := f.Int("broken", 10), 10; got != want {
+		t.Errorf("Int(broken): got %v, want %v", got, want)
+	}
+	if _, ok := f.Lookup("missing"); ok {
+		t.Errorf("Lookup(missing): got a value, want none")
+	}
+}
+
+func TestFlagsOnChange(t *testing.T) {
+	f := newFlags(context.Background())
+	changes := make(chan []string, 10)
+	f.OnChange(func(_ context.Context, changed []string) { changes <- changed })
+
+	next := func() []string {
+		t.Helper()
+		select {
+		case changed := <-changes:
+			return changed
+		case <-time.After(5 * time.Second):
+			t.Fatal("no change notification")
+			return nil
+		}
+	}
+
+	f.set(map[string]string{"a": "1", "b": "2"})
+	if diff := cmp.Diff([]string{"a", "b"}, next()); diff != "" {
+		t.Fatalf("changed (-want +got):\n%s", diff)
+	}
+
+	// Change b, unset a, and set c. Setting the same flags again notifies
+	// nothing.
+	f.set(map[string]string{"b": "3", "c": "4"})
+	f.set(map[string]string{"b": "3", "c": "4"})
+	var got []string
+	for len(got) < 3 {
+		got = append(got, next()...)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, got); diff != "" {
+		t.Fatalf("changed (-want +got):\n%s", diff)
+	}
+	select {
+	case changed := <-changes:
+		t.Fatalf("unexpected change notification %v", changed)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got, want := f.Int("b", 0), 3; got != want {
+		t.Fatalf("Int(b): got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// healthChecker is implemented by component implementations that have a
+// HealthCheck method. For example:
+//
+//	func (c *cart) HealthCheck(ctx context.Context) error {
+//	    return c.db.PingContext(ctx)
+//	}
+//
+// A weavelet calls the HealthCheck method of its local components
+// periodically, and reports itself as unhealthy while one of them fails. See
+// runtime.WeaveletConfig.HealthCheckInterval.
+type healthChecker interface {
+	HealthCheck(context.Context) error
+}
+
+// healthChecks holds the results of the health checks of a weavelet's local
+// components. The zero value, with no components, is healthy.
+type healthChecks struct {
+	startOnce sync.Once     // used to start checking
+	added     chan struct{} // signaled when a component or service is added
+
+	mu         sync.Mutex                   // guards the following
+	components []*component                 // components with a HealthCheck method
+	results    map[*component]*healthResult // results, by component
+}
+
+// healthResult is the result of the health checks of a component.
+type healthResult struct {
+	passed bool  // has the check passed at least once?
+	err    error // error returned by the latest check, or nil
+}
+
+// addHealthCheck starts checking the health of the provided local component,
+// whose implementation has a HealthCheck method. The weavelet is STARTING
+// until the component's check first passes.
+func (w *weavelet) addHealthCheck(c *component) {
+	h := &w.health
+	h.mu.Lock()
+	h.components = append(h.components, c)
+	if h.results == nil {
+		h.results = map[*component]*healthResult{}
+	}
+	h.results[c] = &healthResult{}
+	h.mu.Unlock()
+	w.startHealthChecks()
+}
+
+// startHealthChecks starts checking the health of the local components and of
+// the external services they use, if not started already, and checks it right
+// away, rather than at the next tick.
+func (w *weavelet) startHealthChecks() {
+	h := &w.health
+	h.startOnce.Do(func() {
+		h.added = make(chan struct{}, 1)
+		go w.checkHealth()
+	})
+	select {
+	case h.added <- struct{}{}:
+	default:
+	}
+}
+
+// checkHealth checks the health of the local components, and of the external
+// services they use, every w.healthCheckInterval, and whenever a component or
+// a service is added, until the weavelet's context is done.
+func (w *weavelet) checkHealth() {
+	ticker := time.NewTicker(w.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.health.added:
+		}
+		w.health.run(w.ctx, w.healthCheckTimeout, w.env.SystemLogger())
+		w.externals.check(w.ctx, w.healthCheckTimeout, w.env.SystemLogger())
+	}
+}
+
+// run calls the HealthCheck method of every component, bounding each call by
+// timeout, and records the results. It logs the components whose health
+// changes.
+func (h *healthChecks) run(ctx context.Context, timeout time.Duration, logger *slog.Logger) {
+	h.mu.Lock()
+	components := h.components
+	h.mu.Unlock()
+
+	for _, c := range components {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.impl.impl.(healthChecker).HealthCheck(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			// The weavelet is stopping.
+			return
+		}
+
+		h.mu.Lock()
+		r := h.results[c]
+		switch {
+		case err != nil && r.err == nil && r.passed:
+			logger.Error("Health check failed", err, "component", c.info.Name)
+		case err == nil && r.err != nil:
+			logger.Info("Health check passed", "component", c.info.Name)
+		}
+		r.err = err
+		r.passed = r.passed || err == nil
+		h.mu.Unlock()
+	}
+}
+
+// status returns the health of the weavelet according to the health checks of
+// its components: UNHEALTHY if a check that passed before fails, STARTING if
+// a check has yet to pass, and HEALTHY otherwise.
+func (h *healthChecks) status() *protos.GetHealthReply {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	starting := false
+	for _, c := range h.components {
+		r := h.results[c]
+		if r.passed && r.err != nil {
+			return &protos.GetHealthReply{
+				Status: protos.HealthStatus_UNHEALTHY,
+				Reason: fmt.Sprintf("component %s: %v", c.info.Name, r.err),
+			}
+		}
+		starting = starting || !r.passed
+	}
+	if starting {
+		return &protos.GetHealthReply{Status: protos.HealthStatus_STARTING}
+	}
+	return &protos.GetHealthReply{Status: protos.HealthStatus_HEALTHY}
+}
@@ -15,6 +15,7 @@
 package weaver
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -28,14 +29,26 @@ import (
 type httpLabels struct {
 	Label string // user-provided instrumentation label
 	Host  string // URL host
+	Route string // route returned by InstrumentOptions.Route, or ""
+	Tag   string // tag returned by InstrumentOptions.Tag, or ""
 }
 
 type httpErrorLabels struct {
 	Label string // user-provided instrumentation label
 	Host  string // URL host
+	Route string // route returned by InstrumentOptions.Route, or ""
+	Tag   string // tag returned by InstrumentOptions.Tag, or ""
 	Code  int    // HTTP status code (e.g., 404)
 }
 
+type httpLatencyLabels struct {
+	Label string // user-provided instrumentation label
+	Host  string // URL host
+	Route string // route returned by InstrumentOptions.Route, or ""
+	Tag   string // tag returned by InstrumentOptions.Tag, or ""
+	Class string // class of the HTTP status code (e.g., "4xx")
+}
+
 var (
 	httpRequestCounts = metrics.NewCounterMap[httpLabels](
 		"serviceweaver_http_request_count",
@@ -45,7 +58,7 @@ var (
 		"serviceweaver_http_error_count",
 		"Count of HTTP replies with a 4XX or 5XX status code",
 	)
-	httpRequestLatencyMicros = metrics.NewHistogramMap[httpLabels](
+	httpRequestLatencyMicros = metrics.NewHistogramMap[httpLatencyLabels](
 		"serviceweaver_http_request_latency_micros",
 		"Duration, in microseconds, of HTTP request execution",
 		metrics.NonNegativeBuckets,
@@ -62,15 +75,69 @@ var (
 	)
 )
 
+// InstrumentOptions configure the metrics maintained by
+// [InstrumentHandlerWithOptions].
+//
+// Every distinct combination of label values is exported as a separate time
+// series, so Route and Tag should return a small number of distinct values.
+// For example, Route should return a route template like "/users/{id}" rather
+// than the path of the request.
+type InstrumentOptions struct {
+	// Route, if not nil, returns the route of a request, recorded in the
+	// "route" label of every metric.
+	Route func(*http.Request) string
+
+	// Tag, if not nil, returns an application-defined label of a request
+	// (e.g., the tenant named in a request header), recorded in the "tag"
+	// label of every metric.
+	Tag func(*http.Request) string
+
+	// ExcludePaths lists the URL paths (e.g., "/healthz") of requests that
+	// are served without being measured.
+	ExcludePaths []string
+}
+
 // InstrumentHandler instruments the provided HTTP handler to maintain the
 // following metrics about HTTP request execution. Every metric is labelled
 // with the supplied label.
 //
 //   - serviceweaver_http_request_count: Total number of requests.
 //   - serviceweaver_http_error_count: Total number of 4XX and 5XX replies.
-//   - serviceweaver_http_request_latency_micros: Execution latency in microseconds.
+//   - serviceweaver_http_request_latency_micros: Execution latency in
+//     microseconds, also labelled with the class (e.g., "2xx") of the status
+//     code of the reply.
+//
+// Use [InstrumentHandlerWithOptions] to label the metrics with the route of a
+// request or to exclude some requests.
 func InstrumentHandler(label string, handler http.Handler) http.Handler {
+	return InstrumentHandlerWithOptions(label, handler, InstrumentOptions{})
+}
+
+// InstrumentHandlerWithOptions is identical to [InstrumentHandler] but also
+// labels the metrics, and excludes requests, as specified by the provided
+// options. For example, the following handler labels its metrics with the
+// route of a request and doesn't measure health checks:
+//
+//	weaver.InstrumentHandlerWithOptions("users", handler, weaver.InstrumentOptions{
+//	    Route: func(r *http.Request) string {
+//	        if strings.HasPrefix(r.URL.Path, "/users/") {
+//	            return "/users/{id}"
+//	        }
+//	        return "other"
+//	    },
+//	    ExcludePaths: []string{"/healthz"},
+//	})
+func InstrumentHandlerWithOptions(label string, handler http.Handler, opts InstrumentOptions) http.Handler {
+	exclude := map[string]bool{}
+	for _, path := range opts.ExcludePaths {
+		exclude[path] = true
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL != nil && exclude[r.URL.Path] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
 		start := time.Now()
 		// TODO(spetrovic): It is possible for the user to override r.Host
 		// and therefore get an incorrect host label attached here. Consider
@@ -78,21 +145,34 @@ func InstrumentHandler(label string, handler http.Handler) http.Handler {
 		// listener attached to the HTTP server and return its associated
 		// hostname).
 		labels := httpLabels{Label: label, Host: r.Host}
+		if opts.Route != nil {
+			labels.Route = opts.Route(r)
+		}
+		if opts.Tag != nil {
+			labels.Tag = opts.Tag(r)
+		}
 
 		httpRequestCounts.Get(labels).Add(1)
-		defer func() {
-			httpRequestLatencyMicros.Get(labels).Put(
-				float64(time.Since(start).Microseconds()))
-		}()
 		if size, ok := requestSize(r); ok {
 			httpRequestBytesReceived.Get(labels).Put(float64(size))
 		}
 		writer := responseWriterInstrumenter{w: w}
+		defer func() {
+			httpRequestLatencyMicros.Get(httpLatencyLabels{
+				Label: labels.Label,
+				Host:  labels.Host,
+				Route: labels.Route,
+				Tag:   labels.Tag,
+				Class: statusClass(writer.statusCode),
+			}).Put(float64(time.Since(start).Microseconds()))
+		}()
 		handler.ServeHTTP(&writer, r)
 		if writer.statusCode >= 400 && writer.statusCode < 600 {
 			httpRequestErrors.Get(httpErrorLabels{
-				Label: label,
-				Host:  r.Host,
+				Label: labels.Label,
+				Host:  labels.Host,
+				Route: labels.Route,
+				Tag:   labels.Tag,
 				Code:  writer.statusCode,
 			}).Add(1)
 		}
@@ -100,6 +180,19 @@ func InstrumentHandler(label string, handler http.Handler) http.Handler {
 	})
 }
 
+// statusClass returns the class (e.g., "2xx") of the provided HTTP status
+// code. A code of 0 means the handler didn't write a reply, which net/http
+// treats as 200.
+func statusClass(code int) string {
+	if code == 0 {
+		code = 200
+	}
+	if code < 100 || code >= 600 {
+		return "other"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
 // InstrumentHandlerFunc is identical to [InstrumentHandler] but takes a
 // function instead of an http.Handler.
 func InstrumentHandlerFunc(label string, f func(http.ResponseWriter, *http.Request)) http.Handler {
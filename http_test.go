@@ -16,8 +16,15 @@ package weaver_test
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
 
 	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
 )
 
 func ExampleInstrumentHandler() {
@@ -26,3 +33,67 @@ func ExampleInstrumentHandler() {
 	mux.Handle("/bar", weaver.InstrumentHandler("bar", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { /*...*/ })))
 	http.ListenAndServe(":9000", &mux)
 }
+
+func ExampleInstrumentHandlerWithOptions() {
+	var mux http.ServeMux
+	mux.Handle("/", weaver.InstrumentHandlerWithOptions("users", http.HandlerFunc(func(http.ResponseWriter, *http.Request) { /*...*/ }), weaver.InstrumentOptions{
+		Route: func(r *http.Request) string {
+			if strings.HasPrefix(r.URL.Path, "/users/") {
+				return "/users/{id}"
+			}
+			return "other"
+		},
+		Tag:          func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+		ExcludePaths: []string{"/healthz"},
+	}))
+	http.ListenAndServe(":9000", &mux)
+}
+
+func TestInstrumentHandlerWithOptions(t *testing.T) {
+	label := uuid.New().String()
+	handler := weaver.InstrumentHandlerWithOptions(label, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/missing" {
+			http.NotFound(w, r)
+		}
+	}), weaver.InstrumentOptions{
+		Route:        func(*http.Request) string { return "/users/{id}" },
+		Tag:          func(r *http.Request) string { return r.Header.Get("X-Tenant") },
+		ExcludePaths: []string{"/healthz"},
+	})
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing", "/healthz"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-Tenant", "acme")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// Collect the metrics recorded by the handler.
+	type key struct {
+		name, route, tag, class, code string
+	}
+	got := map[key]float64{}
+	for _, m := range metrics.Snapshot() {
+		if m.Labels["label"] != label {
+			continue
+		}
+		k := key{m.Name, m.Labels["route"], m.Labels["tag"], m.Labels["class"], m.Labels["code"]}
+		switch m.Type {
+		case protos.MetricType_HISTOGRAM:
+			for _, c := range m.Counts {
+				got[k] += float64(c)
+			}
+		default:
+			got[k] += m.Value
+		}
+	}
+	want := map[key]float64{
+		{"serviceweaver_http_request_count", "/users/{id}", "acme", "", ""}:             3,
+		{"serviceweaver_http_error_count", "/users/{id}", "acme", "", "404"}:            1,
+		{"serviceweaver_http_request_latency_micros", "/users/{id}", "acme", "2xx", ""}: 2,
+		{"serviceweaver_http_request_latency_micros", "/users/{id}", "acme", "4xx", ""}: 1,
+		{"serviceweaver_http_request_bytes_received", "/users/{id}", "acme", "", ""}:    3,
+		{"serviceweaver_http_request_bytes_returned", "/users/{id}", "acme", "", ""}:    3,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("metrics (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/metrics"
+	"golang.org/x/exp/slices"
+)
+
+type idempotencyLabels struct {
+	Component string // full callee component name
+	Method    string // callee component method's name
+}
+
+var methodDeduplicatedCalls = metrics.NewCounterMap[idempotencyLabels](
+	"serviceweaver_method_deduplicated_count",
+	"Count of Service Weaver component method calls answered with the results of an earlier call with the same idempotency key",
+)
+
+// idempotencyKey is the context key used by WithIdempotencyKey.
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx that, when passed to a component
+// method call, attaches the provided idempotency key to the call. If the
+// method is configured with an idempotency window, the callee runs the method
+// at most once per key: a call with the key of a call that is still running
+// waits for it to finish, and a call with the key of a call that succeeded
+// within the window returns the same results, without running the method
+// again. For example:
+//
+//	// Retrying the call never places the order twice.
+//	ctx = weaver.WithIdempotencyKey(ctx, orderID)
+//	order, err := checkout.PlaceOrder(ctx, req)
+//
+// Deduplication is enabled per method in the config:
+//
+//	[serviceweaver.methods."github.com/my/project/checkout/T.PlaceOrder"]
+//	idempotency_window = "10m"  # deduplicate calls for 10 minutes
+//	max_retries = 3             # retrying is now safe
+//
+// Results that hold an application error are deduplicated like any other
+// results, but calls that fail with a system error, e.g., because the callee
+// crashed, are not, so they can be retried. A call with the key of an earlier
+// call but different arguments fails.
+//
+// Once it starts, a deduplicated call runs to completion, even if its caller
+// gives up on it, so that retries can pick up its results. Calls with the
+// same key are sent to the same replica of the callee, which remembers the
+// keys in memory, so a call may still run twice if the replica restarts, or
+// if the set of replicas changes between retries.
+//
+// The key only applies to the call it is passed to; calls made by the callee
+// have their own keys. Only remote calls are deduplicated; calls to colocated
+// components are regular function calls.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// idempotencyKeyFromContext returns the idempotency key stored in ctx by
+// WithIdempotencyKey, if any.
+func idempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// affinity returns the call.CallOptions.Affinity of calls with the provided
+// idempotency key.
+func affinity(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key)) //nolint:errcheck // hash.Hash.Write never fails
+	if a := h.Sum64(); a != 0 {
+		return a
+	}
+	return 1
+}
+
+// idempotencyTable deduplicates the calls to a method that have an
+// idempotency key. It remembers the calls that are running and the calls
+// that succeeded within the method's idempotency window, by key. An
+// idempotencyTable is safe for concurrent use.
+type idempotencyTable struct {
+	window       time.Duration    // how long the results of calls are kept
+	clock        Clock            // measures the window
+	calls        *callTracker     // tracks the calls run by the table
+	deduplicated *metrics.Counter // number of deduplicated calls
+
+	mu        sync.Mutex
+	keys      map[string]*idempotentCall // running and succeeded calls, by key
+	succeeded *list.List                 // of *idempotentCall, oldest first
+}
+
+// idempotentCall is a call with an idempotency key.
+type idempotentCall struct {
+	key     string
+	digest  [sha256.Size]byte // digest of the call's serialized arguments
+	done    chan struct{}     // closed when the call finishes
+	results []byte            // results of the call, once done
+	err     error             // system error of the call, once done
+	expires time.Time         // when a succeeded call is forgotten
+}
+
+// idempotencyTable returns the idempotency table of the provided method, or
+// nil if calls to the method should not be deduplicated.
+func (w *weavelet) idempotencyTable(component, method string) *idempotencyTable {
+	config, ok := w.methodConfigs[component+"."+method]
+	if !ok || config.IdempotencyWindow <= 0 {
+		return nil
+	}
+	labels := idempotencyLabels{Component: component, Method: method}
+	return newIdempotencyTable(config.IdempotencyWindow, w.clock, &w.calls, methodDeduplicatedCalls.Get(labels))
+}
+
+// newIdempotencyTable returns a new table that remembers succeeded calls for
+// the provided window, as measured by the provided clock.
+func newIdempotencyTable(window time.Duration, clock Clock, calls *callTracker, deduplicated *metrics.Counter) *idempotencyTable {
+	return &idempotencyTable{
+		window:       window,
+		clock:        clock,
+		calls:        calls,
+		deduplicated: deduplicated,
+		keys:         map[string]*idempotentCall{},
+		succeeded:    list.New(),
+	}
+}
+
+// run returns the results of invoke(ctx, args), for a call with the
+// provided idempotency key. If a call with the same key is running, or
+// succeeded within the table's window, run returns its results instead.
+// Otherwise, run calls invoke, in a goroutine, with a context that holds the
+// values of ctx but is never cancelled.
+func (t *idempotencyTable) run(ctx context.Context, key string, args []byte, invoke func(context.Context, []byte) ([]byte, error)) ([]byte, error) {
+	digest := sha256.Sum256(args)
+	for {
+		t.mu.Lock()
+		t.expire(t.clock.Now())
+		c, ok := t.keys[key]
+		if !ok {
+			c = &idempotentCall{key: key, digest: digest, done: make(chan struct{})}
+			t.keys[key] = c
+			t.calls.start()
+			// The call outlives the handler if the caller gives up, so it
+			// can't retain the handler's arguments.
+			go t.invoke(detachedContext{ctx}, c, slices.Clone(args), invoke)
+		}
+		t.mu.Unlock()
+
+		if c.digest != digest {
+			return nil, fmt.Errorf("idempotency key %q reused with different arguments", key)
+		}
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if c.err == nil {
+			if ok {
+				t.deduplicated.Add(1)
+			}
+			return c.results, nil
+		}
+		if !ok {
+			return nil, c.err
+		}
+		// The earlier call failed with a system error and was forgotten.
+		// Run the call again.
+	}
+}
+
+// invoke runs a call, and records its outcome.
+func (t *idempotencyTable) invoke(ctx context.Context, c *idempotentCall, args []byte, invoke func(context.Context, []byte) ([]byte, error)) {
+	defer t.calls.end()
+	results, err := invoke(ctx, args)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c.results, c.err = results, err
+	if err != nil {
+		delete(t.keys, c.key)
+	} else {
+		c.expires = t.clock.Now().Add(t.window)
+		t.succeeded.PushBack(c)
+	}
+	close(c.done)
+}
+
+// expire forgets the succeeded calls whose window has passed.
+//
+// REQUIRES: t.mu is held.
+func (t *idempotencyTable) expire(now time.Time) {
+	for elem := t.succeeded.Front(); elem != nil; elem = t.succeeded.Front() {
+		c := elem.Value.(*idempotentCall)
+		if now.Before(c.expires) {
+			return
+		}
+		t.succeeded.Remove(elem)
+		delete(t.keys, c.key)
+	}
+}
+
+// detachedContext is a context that holds the values of its parent, but has
+// no deadline and is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (d detachedContext) Value(key any) any         { return d.parent.Value(key) }
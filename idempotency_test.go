@@ -0,0 +1,207 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testIdempotencyTable returns a new idempotency table for a test.
+func testIdempotencyTable(t *testing.T, window time.Duration) *idempotencyTable {
+	labels := idempotencyLabels{Component: t.Name()}
+	return newIdempotencyTable(window, systemClock{}, &callTracker{}, methodDeduplicatedCalls.Get(labels))
+}
+
+func TestIdempotencyTableDeduplicates(t *testing.T) {
+	// Test plan: Make concurrent calls with the same key to a slow method,
+	// and check that the method runs once, and that every call returns its
+	// results.
+	table := testIdempotencyTable(t, time.Hour)
+	var runs atomic.Int32
+	release := make(chan struct{})
+	invoke := func(context.Context, []byte) ([]byte, error) {
+		runs.Add(1)
+		<-release
+		return []byte("charged"), nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := table.run(context.Background(), "order-1", []byte("args"), invoke)
+			if err == nil && string(results) != "charged" {
+				err = errors.New("got results " + string(results))
+			}
+			errs <- err
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A later retry is deduplicated too.
+	if _, err := table.run(context.Background(), "order-1", []byte("args"), invoke); err != nil {
+		t.Fatal(err)
+	}
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("method ran %d times, want 1", got)
+	}
+
+	// A call with another key runs.
+	if _, err := table.run(context.Background(), "order-2", []byte("args"), invoke); err != nil {
+		t.Fatal(err)
+	}
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("method ran %d times, want 2", got)
+	}
+}
+
+func TestIdempotencyTableDifferentArgs(t *testing.T) {
+	table := testIdempotencyTable(t, time.Hour)
+	invoke := func(context.Context, []byte) ([]byte, error) { return nil, nil }
+	if _, err := table.run(context.Background(), "key", []byte("a"), invoke); err != nil {
+		t.Fatal(err)
+	}
+	_, err := table.run(context.Background(), "key", []byte("b"), invoke)
+	if err == nil || !strings.Contains(err.Error(), "different arguments") {
+		t.Fatalf("run: got %v, want error about different arguments", err)
+	}
+}
+
+func TestIdempotencyTableRetriesSystemErrors(t *testing.T) {
+	table := testIdempotencyTable(t, time.Hour)
+	var runs int
+	invoke := func(context.Context, []byte) ([]byte, error) {
+		runs++
+		if runs == 1 {
+			return nil, errors.New("system error")
+		}
+		return []byte("ok"), nil
+	}
+	if _, err := table.run(context.Background(), "key", nil, invoke); err == nil {
+		t.Fatal("run: unexpected success")
+	}
+	results, err := table.run(context.Background(), "key", nil, invoke)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(results) != "ok" || runs != 2 {
+		t.Fatalf("run: got %q after %d runs, want \"ok\" after 2 runs", results, runs)
+	}
+}
+
+func TestIdempotencyTableExpires(t *testing.T) {
+	table := testIdempotencyTable(t, time.Minute)
+	clock := &steppedClock{now: time.Now()}
+	table.clock = clock
+	var runs int
+	invoke := func(context.Context, []byte) ([]byte, error) {
+		runs++
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := table.run(context.Background(), "key", nil, invoke); err != nil {
+			t.Fatal(err)
+		}
+		clock.now = clock.now.Add(time.Minute)
+	}
+	if runs != 2 {
+		t.Fatalf("method ran %d times, want 2", runs)
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	table.expire(clock.Now())
+	if n := len(table.keys); n != 0 {
+		t.Fatalf("got %d remembered keys, want 0", n)
+	}
+}
+
+func TestIdempotencyTableOutlivesCaller(t *testing.T) {
+	// Test plan: Cancel a call while its method runs, and check that the
+	// method runs to completion, with a live context, and that a retry
+	// returns its results.
+	table := testIdempotencyTable(t, time.Hour)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	invoke := func(ctx context.Context, _ []byte) ([]byte, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return []byte("placed"), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := table.run(ctx, "key", nil, invoke); !errors.Is(err, context.Canceled) {
+		t.Fatalf("run: got %v, want context.Canceled", err)
+	}
+	close(release)
+	results, err := table.run(context.Background(), "key", nil, invoke)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(results) != "placed" {
+		t.Fatalf("run: got %q, want \"placed\"", results)
+	}
+}
+
+func TestStubIdempotencyKey(t *testing.T) {
+	client := &metadataClient{}
+	s := &stub{client: client, methods: make([]call.MethodKey, 1)}
+
+	ctx := WithMetadata(context.Background(), "tenant", "acme")
+	ctx = WithIdempotencyKey(ctx, "order-1")
+	opts := s.callOptions(ctx, 0, 0)
+	if opts.Affinity != affinity("order-1") {
+		t.Fatalf("callOptions: got affinity %d, want %d", opts.Affinity, affinity("order-1"))
+	}
+	var got callMetadata
+	if err := json.Unmarshal(opts.Metadata, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := callMetadata{Values: map[string]string{"tenant": "acme"}, IdempotencyKey: "order-1"}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(callMetadata{})); diff != "" {
+		t.Fatalf("callOptions: metadata (-want +got):\n%s", diff)
+	}
+
+	// The key isn't propagated by the metadata of the context.
+	if md := metadataFromContext(ctx); md.IdempotencyKey != "" {
+		t.Fatalf("metadataFromContext: got key %q, want none", md.IdempotencyKey)
+	}
+}
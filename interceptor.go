@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import "github.com/ServiceWeaver/weaver/runtime/codegen"
+
+// CallInfo describes a call to a component method, as seen by an Interceptor.
+// Args holds the arguments of the call, excluding the initial context, e.g.,
+// the Args of a call to
+//
+//	AddItem(ctx context.Context, cart string, item Item) error
+//
+// hold a string and an Item.
+type CallInfo = codegen.CallInfo
+
+// An Interceptor intercepts calls to component methods, e.g., to check
+// authorization, log requests, label metrics, or validate arguments. It is
+// passed the call's context and info, and a function that runs the rest of
+// the call, i.e., the remaining interceptors and then the method itself:
+//
+//	func logCalls(ctx context.Context, info weaver.CallInfo, invoke func(context.Context) error) error {
+//	    start := time.Now()
+//	    err := invoke(ctx)
+//	    log.Printf("%s.%s took %v: %v", info.Component, info.Method, time.Since(start), err)
+//	    return err
+//	}
+//
+// An interceptor that returns without calling invoke fails the call with the
+// returned error. Errors returned by server interceptors are sent to remote
+// callers like the errors returned by component methods, so their types
+// should be registered with RegisterError. The results of the call are only
+// set if invoke is called.
+type Interceptor = codegen.Interceptor
+
+// InterceptClientCalls registers an interceptor that runs on the caller's side
+// of every component method call made by the process, whether or not the
+// called component is local. Interceptors run in the order they are
+// registered, the first being the outermost.
+//
+// Like RegisterError, InterceptClientCalls must be called in every process,
+// before Init, typically in main or an init function.
+func InterceptClientCalls(i Interceptor) {
+	codegen.RegisterClientInterceptor(i)
+}
+
+// InterceptServerCalls registers an interceptor that runs on the callee's side
+// of every component method call executed by the process, after the
+// arguments are decoded and before the method runs. For a call to a local
+// component, the client interceptors run first, followed by the server
+// interceptors.
+//
+// Like RegisterError, InterceptServerCalls must be called in every process,
+// before Init, typically in main or an init function.
+func InterceptServerCalls(i Interceptor) {
+	codegen.RegisterServerInterceptor(i)
+}
@@ -0,0 +1,296 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alerts sends alerts on the events of deployments, e.g., when the
+// replicas of a co-location group crash in a loop, to the destinations
+// configured in the [serviceweaver.alerts] section of the config: Slack,
+// PagerDuty, and webhooks.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// The names of the alerts.
+const (
+	CrashLoop      = "crash_loop"
+	Unhealthy      = "unhealthy"
+	RolloutStalled = "rollout_stalled"
+)
+
+// sendTimeout bounds the time it takes to send an alert to a destination.
+const sendTimeout = 10 * time.Second
+
+// An Alert is a problem with a deployment, detected from its events. An
+// alert fires once, and may later resolve.
+type Alert struct {
+	Name         string    `json:"name"`     // e.g., "crash_loop"
+	Resolved     bool      `json:"resolved"` // has the problem gone away?
+	Key          string    `json:"key"`      // identifies the alert, when firing and when resolved
+	Time         time.Time `json:"time"`
+	App          string    `json:"app"`
+	DeploymentId string    `json:"deployment_id"`
+	Group        string    `json:"group,omitempty"`   // co-location group, if any
+	Replica      string    `json:"replica,omitempty"` // replica address, if any
+	Summary      string    `json:"summary"`
+}
+
+// A destination is where alerts are sent, e.g., a Slack channel.
+type destination interface {
+	// name returns the name of the destination, e.g., "slack".
+	name() string
+
+	// send sends an alert to the destination.
+	send(ctx context.Context, alert Alert) error
+}
+
+// An Alerter detects the problems of a deployment from its events, and sends
+// alerts about them. Its Hook method must be passed every event of the
+// deployment, e.g., by passing it to events.Open.
+type Alerter struct {
+	config       runtime.AlertsConfig
+	destinations []destination
+	logger       *slog.Logger
+
+	mu        sync.Mutex
+	crashes   map[string][]time.Time // recent crashes, by group
+	looping   map[string]time.Time   // time of the last crash loop alert, by group
+	unhealthy map[string]Alert       // firing unhealthy alerts, by replica
+	rollout   *rolloutState          // rollout in progress, or nil
+	stopped   bool                   // has the deployment stopped?
+}
+
+// rolloutState is the state of a rollout watched for stalls.
+type rolloutState struct {
+	app          string
+	deploymentId string
+	old          string      // deployment id of the running version
+	traffic      string      // percentage of traffic sent to the new version
+	timer        *time.Timer // fires when the rollout stalls
+	stalled      *Alert      // firing rollout_stalled alert, or nil
+}
+
+// New returns an Alerter that sends the alerts enabled in the provided config
+// to its destinations, or nil if the config has no destinations.
+func New(config runtime.AlertsConfig, logger *slog.Logger) *Alerter {
+	var destinations []destination
+	if config.Slack != nil {
+		destinations = append(destinations, newSlack(*config.Slack))
+	}
+	if config.PagerDuty != nil {
+		destinations = append(destinations, newPagerDuty(*config.PagerDuty))
+	}
+	if config.Webhook != nil {
+		destinations = append(destinations, newWebhook(*config.Webhook))
+	}
+	if len(destinations) == 0 {
+		return nil
+	}
+	return &Alerter{
+		config:       config,
+		destinations: destinations,
+		logger:       logger,
+		crashes:      map[string][]time.Time{},
+		looping:      map[string]time.Time{},
+		unhealthy:    map[string]Alert{},
+	}
+}
+
+// Hook updates the alerter with a new event of the deployment, sending
+// alerts as needed. It doesn't block.
+func (a *Alerter) Hook(e events.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stopped {
+		return
+	}
+	switch e.Kind {
+	case events.ReplicaFailed:
+		a.replicaFailed(e)
+	case events.ReplicaUnhealthy:
+		if _, ok := a.unhealthy[e.Replica]; ok || !a.enabled(Unhealthy) {
+			return
+		}
+		alert := newAlert(Unhealthy, e)
+		alert.Summary = fmt.Sprintf("Replica %s of %s failed its health checks: %s", e.Replica, logging.ShortenComponent(e.Group), e.Attrs["reason"])
+		a.unhealthy[e.Replica] = alert
+		a.send(alert)
+	case events.ReplicaHealthy:
+		if alert, ok := a.unhealthy[e.Replica]; ok {
+			delete(a.unhealthy, e.Replica)
+			a.resolve(alert, e.Time)
+		}
+	case events.DeploymentStarted:
+		if old := e.Attrs["rollout_over"]; old != "" && a.enabled(RolloutStalled) {
+			a.rollout = &rolloutState{app: e.App, deploymentId: e.DeploymentId, old: old}
+			a.resetStallTimer()
+		}
+	case events.RolloutTraffic:
+		if a.rollout == nil {
+			return
+		}
+		a.rollout.traffic = e.Attrs["traffic"]
+		if a.rollout.stalled != nil {
+			a.resolve(*a.rollout.stalled, e.Time)
+			a.rollout.stalled = nil
+		}
+		a.resetStallTimer()
+	case events.RolloutPromoted, events.RolloutRolledBack:
+		a.stopRollout(e.Time)
+	case events.DeploymentStopped:
+		a.stopRollout(e.Time)
+		for _, alert := range a.unhealthy {
+			a.resolve(alert, e.Time)
+		}
+		a.unhealthy = map[string]Alert{}
+		a.stopped = true
+	}
+}
+
+// replicaFailed sends a crash loop alert if the replicas of the failed
+// replica's group crashed too many times recently.
+//
+// REQUIRES: a.mu is held.
+func (a *Alerter) replicaFailed(e events.Event) {
+	if !a.enabled(CrashLoop) {
+		return
+	}
+	window := a.config.CrashLoopWindow
+	crashes := append(a.crashes[e.Group], e.Time)
+	for len(crashes) > 0 && e.Time.Sub(crashes[0]) > window {
+		crashes = crashes[1:]
+	}
+	a.crashes[e.Group] = crashes
+	if len(crashes) < a.config.CrashLoopRestarts {
+		return
+	}
+	if last, ok := a.looping[e.Group]; ok && e.Time.Sub(last) < window {
+		// Already alerted on this crash loop.
+		return
+	}
+	a.looping[e.Group] = e.Time
+	a.crashes[e.Group] = nil
+	alert := newAlert(CrashLoop, e)
+	alert.Replica = ""
+	alert.Summary = fmt.Sprintf("Replicas of %s crashed %d times in %v; last error: %s", logging.ShortenComponent(e.Group), len(crashes), window, e.Attrs["err"])
+	a.send(alert)
+}
+
+// resetStallTimer restarts the countdown to the stall of the rollout.
+//
+// REQUIRES: a.mu is held, and a.rollout is not nil.
+func (a *Alerter) resetStallTimer() {
+	r := a.rollout
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(a.config.RolloutStallTimeout, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.rollout != r || r.timer != timer || r.stalled != nil {
+			// The rollout is done, or moved on.
+			return
+		}
+		traffic := r.traffic
+		if traffic == "" {
+			traffic = "its initial"
+		} else {
+			traffic += "%"
+		}
+		alert := Alert{
+			Name:         RolloutStalled,
+			Key:          fmt.Sprintf("%s/%s/%s", r.app, r.deploymentId, RolloutStalled),
+			Time:         time.Now(),
+			App:          r.app,
+			DeploymentId: r.deploymentId,
+			Summary:      fmt.Sprintf("Rollout over %s stuck at %s traffic for %v", logging.Shorten(r.old), traffic, a.config.RolloutStallTimeout),
+		}
+		r.stalled = &alert
+		a.send(alert)
+	})
+	r.timer = timer
+}
+
+// stopRollout stops watching the rollout, if any, resolving its stall.
+//
+// REQUIRES: a.mu is held.
+func (a *Alerter) stopRollout(now time.Time) {
+	r := a.rollout
+	if r == nil {
+		return
+	}
+	r.timer.Stop()
+	if r.stalled != nil {
+		a.resolve(*r.stalled, now)
+	}
+	a.rollout = nil
+}
+
+// enabled returns whether the provided alert is enabled.
+func (a *Alerter) enabled(name string) bool {
+	return slices.Contains(a.config.On, name)
+}
+
+// resolve sends the resolution of a firing alert.
+func (a *Alerter) resolve(alert Alert, now time.Time) {
+	alert.Resolved = true
+	alert.Time = now
+	a.send(alert)
+}
+
+// send sends an alert to all destinations, in the background.
+func (a *Alerter) send(alert Alert) {
+	if alert.Resolved {
+		a.logger.Info("Alert resolved", "alert", alert.Name, "summary", alert.Summary)
+	} else {
+		a.logger.Warn("Alert", "alert", alert.Name, "summary", alert.Summary)
+	}
+	for _, d := range a.destinations {
+		d := d
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := d.send(ctx, alert); err != nil {
+				a.logger.Error("send alert", err, "alert", alert.Name, "destination", d.name())
+			}
+		}()
+	}
+}
+
+// newAlert returns a firing alert about the provided event.
+func newAlert(name string, e events.Event) Alert {
+	key := fmt.Sprintf("%s/%s/%s/%s", e.App, e.DeploymentId, name, e.Group)
+	if name == Unhealthy {
+		key += "/" + e.Replica
+	}
+	return Alert{
+		Name:         name,
+		Key:          key,
+		Time:         e.Time,
+		App:          e.App,
+		DeploymentId: e.DeploymentId,
+		Group:        e.Group,
+		Replica:      e.Replica,
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slog"
+)
+
+// recorder is an HTTP server that records the bodies of the requests it
+// receives.
+type recorder struct {
+	*httptest.Server
+	bodies chan []byte
+}
+
+func newRecorder(t *testing.T) *recorder {
+	r := &recorder{bodies: make(chan []byte, 100)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		r.bodies <- body
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// next returns the body of the next request received.
+func (r *recorder) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case body := <-r.bodies:
+		return body
+	case <-time.After(10 * time.Second):
+		t.Fatal("no request received")
+		return nil
+	}
+}
+
+// none checks that no request is received for a while.
+func (r *recorder) none(t *testing.T) {
+	t.Helper()
+	select {
+	case body := <-r.bodies:
+		t.Fatalf("unexpected request: %s", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// nextAlert returns the next alert received by a webhook.
+func (r *recorder) nextAlert(t *testing.T) Alert {
+	t.Helper()
+	var alert Alert
+	if err := json.Unmarshal(r.next(t), &alert); err != nil {
+		t.Fatal(err)
+	}
+	return alert
+}
+
+func newTestAlerter(t *testing.T, config runtime.AlertsConfig) *Alerter {
+	t.Helper()
+	if len(config.On) == 0 {
+		config.On = []string{CrashLoop, Unhealthy, RolloutStalled}
+	}
+	if config.CrashLoopRestarts == 0 {
+		config.CrashLoopRestarts = 3
+	}
+	if config.CrashLoopWindow == 0 {
+		config.CrashLoopWindow = time.Minute
+	}
+	if config.RolloutStallTimeout == 0 {
+		config.RolloutStallTimeout = time.Hour
+	}
+	a := New(config, slog.New(slog.NewTextHandler(os.Stderr)))
+	if a == nil {
+		t.Fatal("New: no alerter")
+	}
+	return a
+}
+
+func event(kind events.Kind, group, replica string, attrs ...string) events.Event {
+	e := events.Event{
+		Time:         time.Now(),
+		App:          "todo",
+		DeploymentId: "2c80d811-0000-0000-0000-000000000000",
+		Kind:         kind,
+		Group:        group,
+		Replica:      replica,
+		Attrs:        map[string]string{},
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.Attrs[attrs[i]] = attrs[i+1]
+	}
+	return e
+}
+
+func TestNoDestinations(t *testing.T) {
+	if a := New(runtime.AlertsConfig{On: []string{CrashLoop}}, nil); a != nil {
+		t.Fatal("New: got an alerter without destinations")
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{Webhook: &runtime.WebhookAlertConfig{URL: r.URL}})
+
+	a.Hook(event(events.ReplicaFailed, "main", "tcp://a", "err", "exit status 2"))
+	a.Hook(event(events.ReplicaFailed, "other", "tcp://b", "err", "exit status 2"))
+	a.Hook(event(events.ReplicaFailed, "main", "tcp://c", "err", "exit status 2"))
+	r.none(t)
+	a.Hook(event(events.ReplicaFailed, "main", "tcp://d", "err", "panic: boom"))
+	alert := r.nextAlert(t)
+	if alert.Name != CrashLoop || alert.Resolved || alert.Group != "main" || !strings.Contains(alert.Summary, "panic: boom") {
+		t.Fatalf("got %+v, want a crash loop alert for main", alert)
+	}
+
+	// Further crashes within the window don't alert again.
+	for i := 0; i < 3; i++ {
+		a.Hook(event(events.ReplicaFailed, "main", "tcp://e", "err", "exit status 2"))
+	}
+	r.none(t)
+}
+
+func TestUnhealthy(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{Webhook: &runtime.WebhookAlertConfig{URL: r.URL}})
+
+	a.Hook(event(events.ReplicaUnhealthy, "main", "tcp://a", "reason", "timeout"))
+	fired := r.nextAlert(t)
+	if fired.Name != Unhealthy || fired.Resolved || fired.Replica != "tcp://a" {
+		t.Fatalf("got %+v, want an unhealthy alert for tcp://a", fired)
+	}
+	a.Hook(event(events.ReplicaHealthy, "main", "tcp://a"))
+	resolved := r.nextAlert(t)
+	if resolved.Name != Unhealthy || !resolved.Resolved || resolved.Key != fired.Key {
+		t.Fatalf("got %+v, want the resolution of %+v", resolved, fired)
+	}
+}
+
+func TestDisabledAlerts(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{
+		On:      []string{CrashLoop},
+		Webhook: &runtime.WebhookAlertConfig{URL: r.URL},
+	})
+	a.Hook(event(events.ReplicaUnhealthy, "main", "tcp://a", "reason", "timeout"))
+	r.none(t)
+}
+
+func TestRolloutStalled(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{
+		RolloutStallTimeout: 200 * time.Millisecond,
+		Webhook:             &runtime.WebhookAlertConfig{URL: r.URL},
+	})
+
+	a.Hook(event(events.DeploymentStarted, "", "", "rollout_over", "11111111"))
+	a.Hook(event(events.RolloutTraffic, "", "", "traffic", "10"))
+	fired := r.nextAlert(t)
+	if fired.Name != RolloutStalled || fired.Resolved || !strings.Contains(fired.Summary, "10%") {
+		t.Fatalf("got %+v, want a stalled rollout alert at 10%%", fired)
+	}
+	a.Hook(event(events.RolloutPromoted, "", ""))
+	resolved := r.nextAlert(t)
+	if resolved.Name != RolloutStalled || !resolved.Resolved {
+		t.Fatalf("got %+v, want the resolution of the stalled rollout", resolved)
+	}
+	r.none(t)
+}
+
+func TestSlack(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{Slack: &runtime.SlackAlertConfig{URL: r.URL}})
+	a.Hook(event(events.ReplicaUnhealthy, "main", "tcp://a", "reason", "timeout"))
+	var msg struct{ Text string }
+	if err := json.Unmarshal(r.next(t), &msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"unhealthy firing", "todo/2c80d811", "timeout"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("Slack message %q doesn't contain %q", msg.Text, want)
+		}
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	r := newRecorder(t)
+	a := newTestAlerter(t, runtime.AlertsConfig{
+		PagerDuty: &runtime.PagerDutyAlertConfig{RoutingKey: "key", URL: r.URL},
+	})
+	a.Hook(event(events.ReplicaUnhealthy, "main", "tcp://a", "reason", "timeout"))
+	a.Hook(event(events.ReplicaHealthy, "main", "tcp://a"))
+
+	var trigger, resolve pagerDutyEvent
+	if err := json.Unmarshal(r.next(t), &trigger); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(r.next(t), &resolve); err != nil {
+		t.Fatal(err)
+	}
+	if trigger.EventAction == "resolve" {
+		// The requests raced.
+		trigger, resolve = resolve, trigger
+	}
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key" || trigger.Payload == nil || trigger.Payload.Severity != "error" {
+		t.Fatalf("got %+v, want a trigger event", trigger)
+	}
+	if resolve.EventAction != "resolve" || resolve.DedupKey != trigger.DedupKey || resolve.Payload != nil {
+		t.Fatalf("got %+v, want the resolution of %+v", resolve, trigger)
+	}
+}
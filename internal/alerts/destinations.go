@@ -0,0 +1,190 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+)
+
+// maxPagerDutySummary is the maximum length of the summary of a PagerDuty
+// event.
+const maxPagerDutySummary = 1024
+
+// slack posts alerts to a Slack channel, through an incoming webhook [1].
+//
+// [1]: https://api.slack.com/messaging/webhooks
+type slack struct {
+	config runtime.SlackAlertConfig
+	client *http.Client
+}
+
+var _ destination = &slack{}
+
+func newSlack(config runtime.SlackAlertConfig) *slack {
+	return &slack{config: config, client: &http.Client{}}
+}
+
+// name implements the destination interface.
+func (s *slack) name() string { return "slack" }
+
+// send implements the destination interface.
+func (s *slack) send(ctx context.Context, alert Alert) error {
+	icon, status := ":rotating_light:", "firing"
+	if alert.Resolved {
+		icon, status = ":white_check_mark:", "resolved"
+	}
+	text := fmt.Sprintf("%s *%s %s* %s/%s: %s", icon, alert.Name, status, alert.App, logging.Shorten(alert.DeploymentId), alert.Summary)
+	body := struct {
+		Text string `json:"text"`
+	}{text}
+	return post(ctx, s.client, s.config.URL, nil, body)
+}
+
+// pagerDuty sends alerts to PagerDuty, with the Events API v2 [1]. Alerts are
+// deduplicated by key, so that a resolved alert resolves the incident
+// triggered when it fired.
+//
+// [1]: https://developer.pagerduty.com/docs/events-api-v2/overview/
+type pagerDuty struct {
+	config runtime.PagerDutyAlertConfig
+	client *http.Client
+}
+
+var _ destination = &pagerDuty{}
+
+// pagerDutyEvent is the body of a request to the Events API.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // "trigger" or "resolve"
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"` // only for "trigger"
+}
+
+// pagerDutyPayload is the payload of a triggered PagerDuty event.
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"` // "critical", "error", "warning", or "info"
+	Timestamp     string            `json:"timestamp"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+func newPagerDuty(config runtime.PagerDutyAlertConfig) *pagerDuty {
+	if config.URL == "" {
+		config.URL = runtime.DefaultPagerDutyURL
+	}
+	return &pagerDuty{config: config, client: &http.Client{}}
+}
+
+// name implements the destination interface.
+func (p *pagerDuty) name() string { return "pagerduty" }
+
+// send implements the destination interface.
+func (p *pagerDuty) send(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.config.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    alert.Key,
+	}
+	if alert.Resolved {
+		event.EventAction = "resolve"
+		return post(ctx, p.client, p.config.URL, nil, event)
+	}
+
+	summary := alert.Summary
+	if len(summary) > maxPagerDutySummary {
+		summary = summary[:maxPagerDutySummary]
+	}
+	severity := "error"
+	switch alert.Name {
+	case CrashLoop:
+		severity = "critical"
+	case RolloutStalled:
+		severity = "warning"
+	}
+	details := map[string]string{"deployment_id": alert.DeploymentId}
+	if alert.Replica != "" {
+		details["replica"] = alert.Replica
+	}
+	event.Payload = &pagerDutyPayload{
+		Summary:       summary,
+		Source:        alert.App,
+		Severity:      severity,
+		Timestamp:     alert.Time.Format(time.RFC3339),
+		Component:     logging.ShortenComponent(alert.Group),
+		Group:         logging.Shorten(alert.DeploymentId),
+		Class:         alert.Name,
+		CustomDetails: details,
+	}
+	return post(ctx, p.client, p.config.URL, nil, event)
+}
+
+// webhook posts alerts, JSON encoded, to an HTTP endpoint.
+type webhook struct {
+	config runtime.WebhookAlertConfig
+	client *http.Client
+}
+
+var _ destination = &webhook{}
+
+func newWebhook(config runtime.WebhookAlertConfig) *webhook {
+	return &webhook{config: config, client: &http.Client{}}
+}
+
+// name implements the destination interface.
+func (w *webhook) name() string { return "webhook" }
+
+// send implements the destination interface.
+func (w *webhook) send(ctx context.Context, alert Alert) error {
+	return post(ctx, w.client, w.config.URL, w.config.Headers, alert)
+}
+
+// post posts the provided body, JSON encoded, to the provided URL.
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
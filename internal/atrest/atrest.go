@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package atrest encrypts the data that deployers persist on their machines,
+// like log files, traces, and registrations.
+//
+// Data is encrypted with AES-256-GCM, with a key that is kept in a secret
+// store and referenced by the config of a deployment, e.g.,
+// "${secret:file:/etc/weaver/data.key}" (see runtime/secrets). The secret
+// holds the base64 encoding of 32 random bytes, e.g., the output of
+// "openssl rand -base64 32".
+//
+// Encrypted data starts with a header that holds the reference to its key,
+// but never the key itself. Readers, like "weaver ssh logs", resolve the
+// reference in their own environment, so they can decrypt the data of any
+// deployment whose key they have access to, without its config. Data without
+// a header is plaintext, so that the same readers read the data of
+// deployments without a key.
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
+)
+
+// magic starts the header of encrypted data. Read as a little-endian length,
+// it is larger than math.MaxInt32, the size limit of the length-prefixed
+// records that deployers write, so encrypted and plaintext record files can
+// be told apart.
+const magic = "SWE\xff"
+
+// maxRefLen is the maximum length of the key reference in a header.
+const maxRefLen = 4 << 10
+
+// A Key encrypts and decrypts data at rest.
+type Key struct {
+	ref  string // reference to the secret holding the key
+	aead cipher.AEAD
+}
+
+// keys caches the keys resolved by Resolve, by reference, so that readers
+// don't fetch a key from its secret store for every file they decrypt.
+var keys struct {
+	mu   sync.Mutex
+	keys map[string]*Key
+}
+
+// Resolve returns the key held by the secret with the provided reference.
+func Resolve(ctx context.Context, ref string) (*Key, error) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if k, ok := keys.keys[ref]; ok {
+		return k, nil
+	}
+	secret, err := secrets.ResolveReference(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %s: not base64 encoded", ref)
+	}
+	k, err := NewKey(ref, raw)
+	if err != nil {
+		return nil, err
+	}
+	if keys.keys == nil {
+		keys.keys = map[string]*Key{}
+	}
+	keys.keys[ref] = k
+	return k, nil
+}
+
+// NewKey returns the provided 32-byte key, held by the secret with the
+// provided reference.
+func NewKey(ref string, raw []byte) (*Key, error) {
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key %s: got %d bytes, want 32", ref, len(raw))
+	}
+	if len(ref) > maxRefLen {
+		return nil, fmt.Errorf("encryption key reference longer than %d bytes", maxRefLen)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ref: ref, aead: aead}, nil
+}
+
+// Ref returns the reference to the secret holding the key.
+func (k *Key) Ref() string {
+	return k.ref
+}
+
+// Seal encrypts and authenticates plaintext. The result holds a random nonce
+// followed by the ciphertext.
+func (k *Key) Seal(plaintext []byte) []byte {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Errorf("atrest: random nonce: %w", err))
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+// Open decrypts and authenticates data encrypted by Seal.
+func (k *Key) Open(sealed []byte) ([]byte, error) {
+	n := k.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("decrypt: data too short")
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt with key %s: %w", k.ref, err)
+	}
+	return plaintext, nil
+}
+
+// WriteHeader writes the header of data encrypted with the provided key.
+func WriteHeader(w io.Writer, k *Key) error {
+	hdr := make([]byte, 0, len(magic)+4+len(k.ref))
+	hdr = append(hdr, magic...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(len(k.ref)))
+	hdr = append(hdr, k.ref...)
+	_, err := w.Write(hdr)
+	return err
+}
+
+// ReadHeader reads a header written by WriteHeader, and returns the key it
+// references.
+func ReadHeader(ctx context.Context, r io.Reader) (*Key, error) {
+	var hdr [len(magic) + 4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+	if !HasHeader(hdr[:]) {
+		return nil, fmt.Errorf("read encryption header: bad magic")
+	}
+	n := binary.LittleEndian.Uint32(hdr[len(magic):])
+	if n > maxRefLen {
+		return nil, fmt.Errorf("read encryption header: key reference of %d bytes too long", n)
+	}
+	ref := make([]byte, n)
+	if _, err := io.ReadFull(r, ref); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+	return Resolve(ctx, string(ref))
+}
+
+// HasHeader returns whether data starts with the header of encrypted data.
+// It needs only the first four bytes of the data.
+func HasHeader(data []byte) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == magic
+}
+
+// Encrypt returns plaintext encrypted with the provided key, prefixed with
+// its header. If k is nil, Encrypt returns plaintext unchanged.
+func Encrypt(k *Key, plaintext []byte) []byte {
+	if k == nil {
+		return plaintext
+	}
+	var b bytes.Buffer
+	WriteHeader(&b, k) //nolint:errcheck // bytes.Buffer doesn't fail
+	b.Write(k.Seal(plaintext))
+	return b.Bytes()
+}
+
+// Decrypt returns data encrypted by Encrypt decrypted, or data unchanged if
+// it is plaintext.
+func Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if !HasHeader(data) {
+		return data, nil
+	}
+	r := bytes.NewReader(data)
+	k, err := ReadHeader(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return k.Open(data[len(data)-r.Len():])
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const testRef = "${secret:env:ATREST_TEST_KEY}"
+
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	t.Setenv("ATREST_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	k, err := Resolve(context.Background(), testRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := testKey(t)
+	plaintext := []byte("customer data")
+	encrypted := Encrypt(k, plaintext)
+	if bytes.Contains(encrypted, plaintext) {
+		t.Fatalf("Encrypt: plaintext found in %q", encrypted)
+	}
+	if !bytes.Contains(encrypted, []byte(testRef)) {
+		t.Fatalf("Encrypt: key reference not found in %q", encrypted)
+	}
+	got, err := Decrypt(context.Background(), encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt: got %q, want %q", got, plaintext)
+	}
+
+	// Plaintext is passed through.
+	got, err = Decrypt(context.Background(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt(plaintext): got %q, want %q", got, plaintext)
+	}
+	if got := Encrypt(nil, plaintext); !bytes.Equal(got, plaintext) {
+		t.Errorf("Encrypt(nil): got %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	encrypted := Encrypt(testKey(t), []byte("customer data"))
+	encrypted[len(encrypted)-1] ^= 1
+	if _, err := Decrypt(context.Background(), encrypted); err == nil {
+		t.Fatal("Decrypt: unexpected success")
+	}
+}
+
+func TestHeader(t *testing.T) {
+	k := testKey(t)
+	var b bytes.Buffer
+	if err := WriteHeader(&b, k); err != nil {
+		t.Fatal(err)
+	}
+	if !HasHeader(b.Bytes()) {
+		t.Fatal("HasHeader: got false, want true")
+	}
+	got, err := ReadHeader(context.Background(), &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Ref() != testRef {
+		t.Errorf("ReadHeader: got key %q, want %q", got.Ref(), testRef)
+	}
+}
+
+func TestBadKeys(t *testing.T) {
+	t.Setenv("ATREST_TEST_SHORT_KEY", base64.StdEncoding.EncodeToString([]byte("short")))
+	for _, test := range []struct{ ref, want string }{
+		{"not a reference", "not a reference"},
+		{"${secret:env:ATREST_TEST_UNSET}", "not set"},
+		{"${secret:env:ATREST_TEST_SHORT_KEY}", "want 32"},
+	} {
+		if _, err := Resolve(context.Background(), test.ref); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Resolve(%q): got error %v, want error containing %q", test.ref, err, test.want)
+		}
+	}
+}
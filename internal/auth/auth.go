@@ -0,0 +1,225 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth implements the authentication and authorization of the HTTP
+// requests received by listeners, as configured by a runtime.AuthConfig.
+//
+// A request is authenticated either with a JSON Web Token (JWT), sent as a
+// bearer token in the Authorization header and verified with the keys
+// published by its issuer, or with an API key sent in a header. The request is
+// then authorized by the policy of the route it matches.
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slices"
+)
+
+var (
+	// ErrUnauthenticated is returned for requests that could not be
+	// authenticated.
+	ErrUnauthenticated = errors.New("unauthenticated")
+
+	// ErrForbidden is returned for authenticated requests that are not
+	// authorized.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// A Principal is the authenticated sender of a request.
+type Principal struct {
+	Name   string         // the token's "sub" claim, or the API key's principal
+	Scheme string         // "jwt" or "api_key"
+	Claims map[string]any // the token's claims, or nil
+}
+
+// An Authenticator authenticates and authorizes HTTP requests.
+type Authenticator struct {
+	jwt    *verifier           // verifies tokens, or nil
+	keys   map[[32]byte]string // principals, by SHA-256 of API key
+	header string              // the header holding API keys
+	routes []runtime.AuthRoute // authorization policies
+}
+
+// New returns a new Authenticator for the provided config. It reads the API
+// keys file, if any; tokens are verified with keys fetched lazily.
+func New(config runtime.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{header: config.APIKeyHeader, routes: config.Routes}
+	if a.header == "" {
+		a.header = runtime.DefaultAPIKeyHeader
+	}
+	if config.Issuer != "" || config.JWKSURL != "" {
+		a.jwt = newVerifier(config.Issuer, config.Audience, config.JWKSURL)
+	}
+	if config.APIKeysFile != "" {
+		data, err := os.ReadFile(config.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("read API keys: %w", err)
+		}
+		keys, err := parseAPIKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse API keys %s: %w", config.APIKeysFile, err)
+		}
+		a.keys = keys
+	}
+	return a, nil
+}
+
+// parseAPIKeys parses the contents of an API keys file. See
+// runtime.AuthConfig.APIKeysFile.
+func parseAPIKeys(data []byte) (map[[32]byte]string, error) {
+	keys := map[[32]byte]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want <principal> <key>", n)
+		}
+		hash := sha256.Sum256([]byte(fields[1]))
+		if _, ok := keys[hash]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key", n)
+		}
+		keys[hash] = fields[0]
+	}
+	return keys, scanner.Err()
+}
+
+// Authenticate authenticates and authorizes the provided request. It returns
+// the request's principal, or nil if the request matches a public route. The
+// returned error, if any, wraps either ErrUnauthenticated or ErrForbidden.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	route := a.route(r.Method, r.URL.Path)
+	if route != nil && route.Public {
+		return nil, nil
+	}
+	p, err := a.authenticate(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if route != nil {
+		if err := authorize(route, p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrForbidden, err)
+		}
+	}
+	return p, nil
+}
+
+// authenticate returns the principal that sent the provided request.
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if a.jwt != nil {
+		if authz := r.Header.Get("Authorization"); authz != "" {
+			scheme, token, ok := strings.Cut(authz, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				return nil, fmt.Errorf("Authorization header without a bearer token")
+			}
+			claims, err := a.jwt.verify(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				return nil, err
+			}
+			sub, ok := claims["sub"].(string)
+			if !ok || sub == "" {
+				return nil, fmt.Errorf("token without a subject")
+			}
+			return &Principal{Name: sub, Scheme: "jwt", Claims: claims}, nil
+		}
+	}
+	if a.keys != nil {
+		if key := r.Header.Get(a.header); key != "" {
+			// Keys are looked up by hash, which doesn't leak the keys
+			// through timing.
+			name, ok := a.keys[sha256.Sum256([]byte(key))]
+			if !ok {
+				return nil, fmt.Errorf("unknown API key")
+			}
+			return &Principal{Name: name, Scheme: "api_key"}, nil
+		}
+	}
+	return nil, fmt.Errorf("no credentials")
+}
+
+// route returns the route matched by a request with the provided method and
+// path, or nil if there is none.
+func (a *Authenticator) route(method, path string) *runtime.AuthRoute {
+	var best *runtime.AuthRoute
+	for i := range a.routes {
+		r := &a.routes[i]
+		if !strings.HasPrefix(path, r.Path) {
+			continue
+		}
+		if len(r.Methods) > 0 && !containsFold(r.Methods, method) {
+			continue
+		}
+		if best == nil || len(r.Path) > len(best.Path) {
+			best = r
+		}
+	}
+	return best
+}
+
+// authorize checks that the provided principal satisfies the policy of the
+// provided route.
+func authorize(route *runtime.AuthRoute, p *Principal) error {
+	if len(route.Principals) > 0 && !slices.Contains(route.Principals, p.Name) {
+		return fmt.Errorf("principal %q may not access %s", p.Name, route.Path)
+	}
+	for name, want := range route.Claims {
+		if !hasClaim(p.Claims, name, want) {
+			return fmt.Errorf("principal %q lacks claim %s=%q needed to access %s", p.Name, name, want, route.Path)
+		}
+	}
+	return nil
+}
+
+// hasClaim returns whether the claim with the provided name is equal to want,
+// or is a list that contains want. Claims that aren't strings are compared
+// using their default formatting, e.g., "true" or "42".
+func hasClaim(claims map[string]any, name, want string) bool {
+	switch v := claims[name].(type) {
+	case nil:
+		return false
+	case string:
+		return v == want
+	case []any:
+		for _, x := range v {
+			if s, ok := x.(string); ok && s == want {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == want
+	}
+}
+
+// containsFold returns whether xs contains x, ignoring case.
+func containsFold(xs []string, x string) bool {
+	for _, y := range xs {
+		if strings.EqualFold(y, x) {
+			return true
+		}
+	}
+	return false
+}
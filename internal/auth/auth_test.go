@@ -0,0 +1,273 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// issuer is a fake OpenID Connect provider that publishes its keys.
+type issuer struct {
+	server  *httptest.Server
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed      ed25519.PrivateKey
+	fetches atomic.Int64 // number of fetches of the keys
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{rsa: rsaKey, ec: ecKey, ed: edKey}
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edKey.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+	}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": iss.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		json.NewEncoder(w).Encode(jwks)
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+// sign returns a token with the provided claims, signed with the issuer's key
+// for algorithm alg, and with key id kid.
+func (iss *issuer) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsa, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, iss.ec, digest[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "EdDSA":
+		sig = ed25519.Sign(iss.ed, []byte(signed))
+	case "none":
+	default:
+		t.Fatalf("unknown algorithm %q", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	iss := newIssuer(t)
+	now := time.Now()
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{
+			"iss": iss.server.URL,
+			"aud": "client",
+			"sub": "alice",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	for _, test := range []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"RS256", iss.sign(t, "RS256", "rsa", claims(nil)), true},
+		{"ES256", iss.sign(t, "ES256", "ec", claims(nil)), true},
+		{"EdDSA", iss.sign(t, "EdDSA", "ed", claims(nil)), true},
+		{"NoKeyID", iss.sign(t, "ES256", "", claims(nil)), true},
+		{"AudienceList", iss.sign(t, "RS256", "rsa", claims(map[string]any{"aud": []string{"other", "client"}})), true},
+		{"Expired", iss.sign(t, "RS256", "rsa", claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})), false},
+		{"NoExpiration", iss.sign(t, "RS256", "rsa", claims(map[string]any{"exp": nil})), false},
+		{"NotYetValid", iss.sign(t, "RS256", "rsa", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), false},
+		{"WrongIssuer", iss.sign(t, "RS256", "rsa", claims(map[string]any{"iss": "https://evil.example.com"})), false},
+		{"WrongAudience", iss.sign(t, "RS256", "rsa", claims(map[string]any{"aud": "other"})), false},
+		{"WrongKey", iss.sign(t, "RS256", "ec", claims(nil)), false},
+		{"EncryptionKey", iss.sign(t, "RS256", "enc", claims(nil)), false},
+		{"UnknownKey", iss.sign(t, "RS256", "unknown", claims(nil)), false},
+		{"Unsigned", iss.sign(t, "none", "rsa", claims(nil)), false},
+		{"Malformed", "not.a.token", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			v := newVerifier(iss.server.URL, "client", "")
+			_, err := v.verify(context.Background(), test.token)
+			if got, want := err == nil, test.ok; got != want {
+				t.Fatalf("verify: got error %v, want ok = %t", err, want)
+			}
+		})
+	}
+}
+
+func TestRefreshKeys(t *testing.T) {
+	iss := newIssuer(t)
+	now := time.Now()
+	v := newVerifier(iss.server.URL, "", "")
+	v.now = func() time.Time { return now }
+	claims := map[string]any{"iss": iss.server.URL, "sub": "alice", "exp": now.Add(time.Hour).Unix()}
+	ctx := context.Background()
+
+	// The keys are fetched on first use only.
+	for i := 0; i < 3; i++ {
+		if _, err := v.verify(ctx, iss.sign(t, "RS256", "rsa", claims)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := iss.fetches.Load(), int64(1); got != want {
+		t.Fatalf("fetches: got %d, want %d", got, want)
+	}
+
+	// Unknown keys trigger a refetch, but at most once per minRefresh.
+	for i := 0; i < 3; i++ {
+		if _, err := v.verify(ctx, iss.sign(t, "RS256", "unknown", claims)); err == nil {
+			t.Fatal("verify: unexpected success")
+		}
+	}
+	if got, want := iss.fetches.Load(), int64(1); got != want {
+		t.Fatalf("fetches: got %d, want %d", got, want)
+	}
+	now = now.Add(minRefresh)
+	if _, err := v.verify(ctx, iss.sign(t, "RS256", "unknown", claims)); err == nil {
+		t.Fatal("verify: unexpected success")
+	}
+	if got, want := iss.fetches.Load(), int64(2); got != want {
+		t.Fatalf("fetches: got %d, want %d", got, want)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	iss := newIssuer(t)
+	keysFile := filepath.Join(t.TempDir(), "api_keys")
+	keys := "# Principals and their keys.\nbilling b-secret\n\nreports r-secret\n"
+	if err := os.WriteFile(keysFile, []byte(keys), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(runtime.AuthConfig{
+		Issuer:      iss.server.URL,
+		APIKeysFile: keysFile,
+		Routes: []runtime.AuthRoute{
+			{Path: "/healthz", Public: true},
+			{Path: "/admin/", Claims: map[string]string{"role": "admin"}},
+			{Path: "/admin/public/", Public: true},
+			{Path: "/orders/", Methods: []string{"POST"}, Principals: []string{"billing", "alice"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	alice := "Bearer " + iss.sign(t, "RS256", "rsa", map[string]any{"iss": iss.server.URL, "sub": "alice", "exp": exp, "role": []string{"admin", "dev"}})
+	bob := "Bearer " + iss.sign(t, "RS256", "rsa", map[string]any{"iss": iss.server.URL, "sub": "bob", "exp": exp, "role": "dev"})
+	for _, test := range []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		apiKey        string
+		want          string // principal, or "" if none
+		err           error
+	}{
+		{"Public", "GET", "/healthz", "", "", "", nil},
+		{"NoCredentials", "GET", "/", "", "", "", ErrUnauthenticated},
+		{"Token", "GET", "/", alice, "", "alice", nil},
+		{"APIKey", "GET", "/", "", "r-secret", "reports", nil},
+		{"BadToken", "GET", "/", "Bearer garbage", "", "", ErrUnauthenticated},
+		{"BadScheme", "GET", "/", "Basic YWxpY2U6c2VjcmV0", "", "", ErrUnauthenticated},
+		{"BadAPIKey", "GET", "/", "", "x-secret", "", ErrUnauthenticated},
+		{"ClaimsAllowed", "GET", "/admin/users", alice, "", "alice", nil},
+		{"ClaimsForbidden", "GET", "/admin/users", bob, "", "", ErrForbidden},
+		{"ClaimsAPIKey", "GET", "/admin/users", "", "b-secret", "", ErrForbidden},
+		{"LongestPrefix", "GET", "/admin/public/logo.png", "", "", "", nil},
+		{"PrincipalsAllowed", "POST", "/orders/42", "", "b-secret", "billing", nil},
+		{"PrincipalsForbidden", "POST", "/orders/42", bob, "", "", ErrForbidden},
+		{"OtherMethod", "GET", "/orders/42", bob, "", "bob", nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, test.path, nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			if test.apiKey != "" {
+				r.Header.Set(runtime.DefaultAPIKeyHeader, test.apiKey)
+			}
+			p, err := a.Authenticate(r)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Authenticate: got error %v, want %v", err, test.err)
+			}
+			var got string
+			if p != nil {
+				got = p.Name
+			}
+			if got != test.want {
+				t.Fatalf("Authenticate: got principal %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash.New
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash.New
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// leeway is the clock skew tolerated when checking the "exp" and "nbf"
+	// claims of a token.
+	leeway = time.Minute
+
+	// minRefresh is the minimum time between two fetches of the keys of a
+	// verifier, which bounds the rate at which tokens signed with unknown
+	// keys trigger fetches.
+	minRefresh = 30 * time.Second
+)
+
+// A verifier verifies JSON Web Tokens (JWTs) signed with the keys of a JSON
+// Web Key Set (JWKS), as described in RFC 7519 and RFC 7517.
+type verifier struct {
+	issuer   string           // if not empty, the required "iss" claim
+	audience string           // if not empty, a required "aud" claim
+	client   *http.Client     // used to fetch the keys
+	now      func() time.Time // the current time
+
+	mu      sync.Mutex
+	jwksURL string                      // where the keys are, or "" if not discovered yet
+	keys    map[string]crypto.PublicKey // the keys, by key id
+	fetched time.Time                   // when the keys were last fetched
+}
+
+// newVerifier returns a verifier for tokens issued by issuer for audience,
+// and signed with the keys published at jwksURL. If jwksURL is empty, it is
+// discovered from the OpenID Connect discovery document of issuer.
+func newVerifier(issuer, audience, jwksURL string) *verifier {
+	return &verifier{
+		issuer:   issuer,
+		audience: audience,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+		jwksURL:  jwksURL,
+	}
+}
+
+// verify verifies the provided token and returns its claims.
+func (v *verifier) verify(ctx context.Context, token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	// Check the signature before looking at the claims.
+	keys, err := v.lookup(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if err := verifySignature(header.Alg, key, signed, sig); err == nil {
+			verified = true
+			break
+		} else if len(keys) == 1 {
+			return nil, err
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims checks the registered claims of a token.
+func (v *verifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token without an expiration time")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return fmt.Errorf("expired token")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token not valid yet")
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return fmt.Errorf("token issuer %v, want %q", claims["iss"], v.issuer)
+	}
+	if v.audience != "" && !hasClaim(claims, "aud", v.audience) {
+		return fmt.Errorf("token audience %v does not include %q", claims["aud"], v.audience)
+	}
+	return nil
+}
+
+// lookup returns the keys that may have signed a token with the provided
+// key id, which may be empty, fetching the keys if needed.
+func (v *verifier) lookup(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	find := func() []crypto.PublicKey {
+		if kid != "" {
+			if key, ok := v.keys[kid]; ok {
+				return []crypto.PublicKey{key}
+			}
+			return nil
+		}
+		keys := make([]crypto.PublicKey, 0, len(v.keys))
+		for _, key := range v.keys {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	if keys := find(); len(keys) > 0 {
+		return keys, nil
+	}
+
+	// The key is unknown. It may be a new key, so refetch the keys, unless
+	// they were fetched recently.
+	if !v.fetched.IsZero() && v.now().Sub(v.fetched) < minRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := v.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if keys := find(); len(keys) > 0 {
+		return keys, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// fetch fetches the keys, discovering where they are first if needed.
+//
+// REQUIRES: v.mu is held.
+func (v *verifier) fetch(ctx context.Context) error {
+	v.fetched = v.now()
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		url := strings.TrimSuffix(v.issuer, "/") + "/.well-known/openid-configuration"
+		if err := v.get(ctx, url, &discovery); err != nil {
+			return fmt.Errorf("discover keys: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("discover keys: no jwks_uri in %s", url)
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.get(ctx, v.jwksURL, &jwks); err != nil {
+		return fmt.Errorf("fetch keys: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys of unsupported types, rather than rejecting tokens
+			// signed with the other keys.
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	v.keys = keys
+	return nil
+}
+
+// get fetches the JSON document at url and decodes it into dst.
+func (v *verifier) get(ctx context.Context, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// A jwk is a JSON Web Key, as described in RFC 7517 and RFC 7518.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`   // RSA modulus
+	E   string `json:"e"`   // RSA exponent
+	Crv string `json:"crv"` // EC or OKP curve
+	X   string `json:"x"`   // EC or OKP x coordinate
+	Y   string `json:"y"`   // EC y coordinate
+}
+
+// publicKey returns the public key represented by k.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature verifies that sig is the signature of signed, made with
+// algorithm alg and the private key of key.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hash := func(h crypto.Hash) []byte {
+		hasher := h.New()
+		hasher.Write(signed)
+		return hasher.Sum(nil)
+	}
+	switch alg {
+	case "RS256", "RS384", "RS512":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s token signed with a non-RSA key", alg)
+		}
+		h := map[string]crypto.Hash{"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512}[alg]
+		if err := rsa.VerifyPKCS1v15(pub, h, hash(h), sig); err != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s token signed with a non-ECDSA key", alg)
+		}
+		h := map[string]crypto.Hash{"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512}[alg]
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, hash(h), r, s) {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s token signed with a non-Ed25519 key", alg)
+		}
+		if !ed25519.Verify(pub, signed, sig) {
+			return fmt.Errorf("invalid token signature")
+		}
+		return nil
+
+	default:
+		// Notably, unsigned tokens, with algorithm "none", are rejected.
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token into dst.
+func decodeSegment(segment string, dst any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
+
+// decodeInt decodes a base64url encoded big-endian integer.
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package autoscale decides how many weavelets a co-location group should
+// have, based on the load of the group.
+//
+// A Tracker computes the load of every co-location group, i.e., its request
+// rate, the 99th percentile latency of its requests, and its CPU usage, from
+// the metrics of the weavelets in a deployment. An Autoscaler turns the load
+// of a group into a number of weavelets, following the group's
+// runtime.AutoscalingConfig. Like the Kubernetes Horizontal Pod Autoscaler, an
+// Autoscaler scales a group in proportion to the ratio between every signal
+// and its target, scales up right away, and only scales down once the signals
+// have called for fewer weavelets for a while.
+package autoscale
+
+import (
+	"math"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+const (
+	// A signal within tolerance of its target doesn't rescale a group, to
+	// avoid flapping.
+	tolerance = 0.1
+
+	// A group at most doubles in size in a single step, to avoid overshooting
+	// on a transient spike.
+	maxScaleUpFactor = 2
+)
+
+// Signals holds the load of a co-location group over some period of time.
+type Signals struct {
+	Rate float64       // component method calls to the group, per second
+	P99  time.Duration // 99th percentile latency of the calls, or 0 if none
+	CPU  float64       // CPU cores used by all the weavelets of the group
+}
+
+// An Autoscaler recommends the number of weavelets of a co-location group.
+type Autoscaler struct {
+	config runtime.AutoscalingConfig
+
+	// Recent recommendations, oldest first, used to delay scale downs.
+	recent []recommendation
+}
+
+// A recommendation is a number of weavelets recommended at some time.
+type recommendation struct {
+	time     time.Time
+	replicas int
+}
+
+// New returns a new Autoscaler that follows the provided policy.
+func New(config runtime.AutoscalingConfig) *Autoscaler {
+	if config.MinReplicas == 0 {
+		config.MinReplicas = 1
+	}
+	return &Autoscaler{config: config}
+}
+
+// Config returns the policy followed by the autoscaler.
+func (a *Autoscaler) Config() runtime.AutoscalingConfig {
+	return a.config
+}
+
+// Recommend returns the number of weavelets that a group with the provided
+// number of weavelets and load should have.
+func (a *Autoscaler) Recommend(now time.Time, replicas int, s Signals) int {
+	desired := a.desired(replicas, s)
+	cutoff := now.Add(-a.config.ScaleDownDelay)
+	i := 0
+	for i < len(a.recent) && a.recent[i].time.Before(cutoff) {
+		i++
+	}
+	a.recent = append(a.recent[i:], recommendation{now, desired})
+	if desired >= replicas {
+		return desired
+	}
+
+	// Only scale down as far as every recommendation made within the last
+	// ScaleDownDelay allows.
+	for _, r := range a.recent {
+		if r.replicas > desired {
+			desired = r.replicas
+		}
+	}
+	if desired > replicas {
+		desired = replicas
+	}
+	return desired
+}
+
+// desired returns the number of weavelets called for by the provided load,
+// within the bounds of the policy.
+func (a *Autoscaler) desired(replicas int, s Signals) int {
+	if replicas <= 0 {
+		return a.config.MinReplicas
+	}
+
+	// consider considers the ratio between a signal and the target of the
+	// current weavelets.
+	desired := 0
+	consider := func(ratio float64) {
+		n := replicas
+		if math.Abs(ratio-1) > tolerance {
+			n = int(math.Ceil(float64(replicas) * ratio))
+		}
+		if n > desired {
+			desired = n
+		}
+	}
+	if a.config.TargetRequestRate > 0 {
+		// The rate and CPU usage are totals across the weavelets.
+		consider(s.Rate / (a.config.TargetRequestRate * float64(replicas)))
+	}
+	if a.config.TargetP99Latency > 0 {
+		// Unlike the other signals, latency is not a sum across weavelets.
+		consider(float64(s.P99) / float64(a.config.TargetP99Latency))
+	}
+	if a.config.TargetCPU > 0 {
+		consider(s.CPU / (a.config.TargetCPU * float64(replicas)))
+	}
+
+	if desired > maxScaleUpFactor*replicas {
+		desired = maxScaleUpFactor * replicas
+	}
+	if desired < a.config.MinReplicas {
+		desired = a.config.MinReplicas
+	}
+	if desired > a.config.MaxReplicas {
+		desired = a.config.MaxReplicas
+	}
+	return desired
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestRecommend(t *testing.T) {
+	config := runtime.AutoscalingConfig{
+		MinReplicas:       1,
+		MaxReplicas:       10,
+		TargetRequestRate: 100,
+		TargetP99Latency:  50 * time.Millisecond,
+		TargetCPU:         0.5,
+	}
+	for _, test := range []struct {
+		name     string
+		replicas int
+		signals  Signals
+		want     int
+	}{
+		{"idle", 4, Signals{}, 1},
+		{"within tolerance", 4, Signals{Rate: 420}, 4},
+		{"request rate", 2, Signals{Rate: 300}, 3},
+		{"latency", 2, Signals{Rate: 100, P99: 75 * time.Millisecond}, 3},
+		{"cpu", 2, Signals{CPU: 1.5}, 3},
+		{"largest signal", 2, Signals{Rate: 300, CPU: 2}, 4},
+		{"at most double", 2, Signals{Rate: 10000}, 4},
+		{"at most max", 8, Signals{Rate: 10000}, 10},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(config)
+			if got := a.Recommend(time.Now(), test.replicas, test.signals); got != test.want {
+				t.Fatalf("Recommend(%d, %+v): got %d, want %d", test.replicas, test.signals, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecommendDelaysScaleDown(t *testing.T) {
+	a := New(runtime.AutoscalingConfig{
+		MaxReplicas:       10,
+		TargetRequestRate: 100,
+		ScaleDownDelay:    time.Minute,
+	})
+	start := time.Now()
+	for _, step := range []struct {
+		elapsed  time.Duration
+		replicas int
+		rate     float64
+		want     int
+	}{
+		{0, 2, 400, 4},                // scale up right away
+		{15 * time.Second, 4, 200, 4}, // don't scale down yet
+		{30 * time.Second, 4, 100, 4},
+		{75 * time.Second, 4, 100, 2}, // 400 has expired; 200 has not
+		{95 * time.Second, 2, 100, 1},
+	} {
+		got := a.Recommend(start.Add(step.elapsed), step.replicas, Signals{Rate: step.rate})
+		if got != step.want {
+			t.Fatalf("after %v, Recommend(%d, rate=%v): got %d, want %d", step.elapsed, step.replicas, step.rate, got, step.want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	group := func(component string) string { return component }
+	bounds := []float64{1000, 2000, 5000, 10000} // microseconds
+	metricsOf := func(calls float64, counts []uint64, cpu float64) []*metrics.MetricSnapshot {
+		labels := map[string]string{"component": "b", "method": "M"}
+		return []*metrics.MetricSnapshot{
+			{Name: codegen.MethodCounts.Name(), Type: protos.MetricType_COUNTER, Labels: labels, Value: calls},
+			{Name: codegen.MethodLatencies.Name(), Type: protos.MetricType_HISTOGRAM, Labels: labels, Bounds: bounds, Counts: counts},
+			{Name: cpuMetric, Type: protos.MetricType_GAUGE, Value: cpu},
+		}
+	}
+
+	start := time.Now()
+	tracker := NewTracker(group)
+	signals := tracker.Update(map[string]Weavelet{
+		"a1": {Group: "a", Time: start, Metrics: metricsOf(0, []uint64{0, 0, 0, 0, 0}, 1)},
+		"b1": {Group: "b", Time: start, Metrics: metricsOf(0, nil, 3)},
+	})
+	if len(signals) != 0 {
+		t.Fatalf("Update: got %v, want no signals after a single sample", signals)
+	}
+
+	// Over ten seconds, a1 calls b 1000 times and uses 5 CPU seconds, and b1
+	// uses 10 CPU seconds.
+	signals = tracker.Update(map[string]Weavelet{
+		"a1": {Group: "a", Time: start.Add(10 * time.Second), Metrics: metricsOf(1000, []uint64{900, 80, 20, 0, 0}, 6)},
+		"b1": {Group: "b", Time: start.Add(10 * time.Second), Metrics: metricsOf(0, nil, 13)},
+	})
+	if got, want := signals["a"].CPU, 0.5; got != want {
+		t.Errorf("CPU of a: got %v, want %v", got, want)
+	}
+	b := signals["b"]
+	if got, want := b.Rate, 100.0; got != want {
+		t.Errorf("Rate of b: got %v, want %v", got, want)
+	}
+	if got, want := b.CPU, 1.0; got != want {
+		t.Errorf("CPU of b: got %v, want %v", got, want)
+	}
+	// The 990th fastest call falls halfway through the [2000, 5000) bucket.
+	if got, want := b.P99, 3500*time.Microsecond; got != want {
+		t.Errorf("P99 of b: got %v, want %v", got, want)
+	}
+
+	// a1 exits. Its usage is forgotten.
+	signals = tracker.Update(map[string]Weavelet{
+		"b1": {Group: "b", Time: start.Add(20 * time.Second), Metrics: metricsOf(0, nil, 13)},
+	})
+	if got, want := signals["b"], (Signals{}); got != want {
+		t.Errorf("signals of b: got %+v, want %+v", got, want)
+	}
+}
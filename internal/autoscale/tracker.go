@@ -0,0 +1,232 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscale
+
+import (
+	"math"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+)
+
+// cpuMetric is the name of the metric that holds the CPU time, in seconds,
+// used by a weavelet.
+const cpuMetric = "serviceweaver_weavelet_cpu_seconds"
+
+// Weavelet holds the metrics of a weavelet, read at some point in time.
+type Weavelet struct {
+	Group   string                    // the weavelet's co-location group
+	Time    time.Time                 // when the metrics were read
+	Metrics []*metrics.MetricSnapshot // the weavelet's metrics
+}
+
+// A Tracker computes the load of co-location groups from the metrics of their
+// weavelets, read periodically.
+//
+// The metrics of component method calls are recorded by the callers, so the
+// request rate and latency of a group are computed from the metrics of every
+// weavelet in the deployment, while the CPU usage of a group is computed from
+// the metrics of its own weavelets. Calls from outside the deployment, e.g.,
+// HTTP requests to a listener, are not counted.
+type Tracker struct {
+	group     func(component string) string // a component's group
+	weavelets map[string]*tracked           // by weavelet id
+}
+
+// tracked holds the metrics of a weavelet.
+type tracked struct {
+	last  *sample // latest sample
+	usage *usage  // usage between the two latest samples, or nil
+}
+
+// A sample holds the cumulative metrics of a weavelet at some point in time.
+type sample struct {
+	time      time.Time
+	group     string              // the weavelet's group
+	calls     map[string]float64  // calls, by callee group
+	latencies map[string][]uint64 // latency histogram counts, by callee group
+	bounds    []float64           // latency histogram bounds
+	cpu       float64             // CPU seconds
+}
+
+// usage holds the usage of a weavelet over some period of time.
+type usage struct {
+	group     string              // the weavelet's group
+	rates     map[string]float64  // calls per second, by callee group
+	latencies map[string][]uint64 // latency histogram counts, by callee group
+	bounds    []float64           // latency histogram bounds
+	cpu       float64             // CPU cores
+}
+
+// NewTracker returns a new Tracker. group returns the name of the co-location
+// group of the provided component.
+func NewTracker(group func(component string) string) *Tracker {
+	return &Tracker{group: group, weavelets: map[string]*tracked{}}
+}
+
+// Update updates the tracker with the latest metrics of the weavelets in the
+// deployment, keyed by weavelet id, and returns the load of every group
+// hosting a weavelet that was passed to at least two calls to Update.
+// Weavelets missing from the provided map are forgotten. If the metrics of a
+// weavelet have not been read since the previous call to Update, the usage
+// computed by the previous call is used.
+func (t *Tracker) Update(weavelets map[string]Weavelet) map[string]Signals {
+	for id := range t.weavelets {
+		if _, ok := weavelets[id]; !ok {
+			delete(t.weavelets, id)
+		}
+	}
+	for id, w := range weavelets {
+		s := t.sample(w)
+		tw, ok := t.weavelets[id]
+		if !ok {
+			t.weavelets[id] = &tracked{last: s}
+			continue
+		}
+		if !s.time.After(tw.last.time) {
+			continue
+		}
+		tw.usage = s.since(tw.last)
+		tw.last = s
+	}
+
+	// Sum the usage of the weavelets.
+	rates := map[string]float64{}
+	latencies := map[string][]uint64{}
+	var bounds []float64
+	cpus := map[string]float64{}
+	for _, tw := range t.weavelets {
+		u := tw.usage
+		if u == nil {
+			continue
+		}
+		cpus[u.group] += u.cpu
+		for g, rate := range u.rates {
+			rates[g] += rate
+		}
+		for g, counts := range u.latencies {
+			latencies[g] = addCounts(latencies[g], counts)
+		}
+		if u.bounds != nil {
+			// Every latency histogram has the same bounds.
+			bounds = u.bounds
+		}
+	}
+
+	signals := map[string]Signals{}
+	for g, cpu := range cpus {
+		signals[g] = Signals{
+			Rate: rates[g],
+			P99:  time.Duration(percentile(bounds, latencies[g], 0.99) * float64(time.Microsecond)),
+			CPU:  cpu,
+		}
+	}
+	return signals
+}
+
+// sample extracts a sample from the metrics of a weavelet.
+func (t *Tracker) sample(w Weavelet) *sample {
+	s := &sample{
+		time:      w.Time,
+		group:     w.Group,
+		calls:     map[string]float64{},
+		latencies: map[string][]uint64{},
+	}
+	for _, m := range w.Metrics {
+		switch m.Name {
+		case cpuMetric:
+			s.cpu += m.Value
+		case codegen.MethodCounts.Name():
+			s.calls[t.group(m.Labels["component"])] += m.Value
+		case codegen.MethodLatencies.Name():
+			g := t.group(m.Labels["component"])
+			s.latencies[g] = addCounts(s.latencies[g], m.Counts)
+			s.bounds = m.Bounds
+		}
+	}
+	return s
+}
+
+// since returns the usage between the provided sample and s.
+func (s *sample) since(prev *sample) *usage {
+	secs := s.time.Sub(prev.time).Seconds()
+	u := &usage{
+		group:     s.group,
+		rates:     map[string]float64{},
+		latencies: map[string][]uint64{},
+		bounds:    s.bounds,
+		cpu:       math.Max(0, s.cpu-prev.cpu) / secs,
+	}
+	for g, calls := range s.calls {
+		u.rates[g] = math.Max(0, calls-prev.calls[g]) / secs
+	}
+	for g, counts := range s.latencies {
+		delta := make([]uint64, len(counts))
+		old := prev.latencies[g]
+		for i, c := range counts {
+			if i < len(old) && old[i] <= c {
+				c -= old[i]
+			}
+			delta[i] = c
+		}
+		u.latencies[g] = delta
+	}
+	return u
+}
+
+// addCounts adds histogram counts b to histogram counts a and returns the
+// result.
+func addCounts(a, b []uint64) []uint64 {
+	if len(a) < len(b) {
+		a = append(a, make([]uint64, len(b)-len(a))...)
+	}
+	for i, c := range b {
+		a[i] += c
+	}
+	return a
+}
+
+// percentile returns the pth percentile, with p in [0, 1], of the values in a
+// histogram with the provided bounds and counts, or 0 if the histogram is
+// empty. A value v is counted in the bucket i such that bounds[i-1] <= v <
+// bounds[i]. The percentile is interpolated linearly within its bucket.
+func percentile(bounds []float64, counts []uint64, p float64) float64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 || len(bounds) == 0 {
+		return 0
+	}
+	rank := p * float64(total)
+	var seen float64
+	for i, c := range counts {
+		if c == 0 || seen+float64(c) < rank {
+			seen += float64(c)
+			continue
+		}
+		if i >= len(bounds) {
+			// The value is above the largest bound.
+			return bounds[len(bounds)-1]
+		}
+		lo := 0.0
+		if i > 0 {
+			lo = bounds[i-1]
+		}
+		return lo + (bounds[i]-lo)*(rank-seen)/float64(c)
+	}
+	return bounds[len(bounds)-1]
+}
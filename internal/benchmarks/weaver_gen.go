@@ -13,123 +13,143 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1",
-		Iface:       reflect.TypeOf((*Ping1)(nil)).Elem(),
-		New:         func() any { return &ping1{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping1_local_stub{impl: impl.(Ping1), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping1_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1",
+		Iface: reflect.TypeOf((*Ping1)(nil)).Elem(),
+		New:   func() any { return &ping1{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping1_local_stub{impl: impl.(Ping1), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping1_server_stub{impl: impl.(Ping1), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping1_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingS"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping1_server_stub{impl: impl.(Ping1), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10",
-		Iface:       reflect.TypeOf((*Ping10)(nil)).Elem(),
-		New:         func() any { return &ping10{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping10_local_stub{impl: impl.(Ping10), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping10_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10",
+		Iface: reflect.TypeOf((*Ping10)(nil)).Elem(),
+		New:   func() any { return &ping10{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping10_local_stub{impl: impl.(Ping10), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping10_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingS"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping10_server_stub{impl: impl.(Ping10), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping10_server_stub{impl: impl.(Ping10), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2",
-		Iface:       reflect.TypeOf((*Ping2)(nil)).Elem(),
-		New:         func() any { return &ping2{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping2_local_stub{impl: impl.(Ping2), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping2_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2",
+		Iface: reflect.TypeOf((*Ping2)(nil)).Elem(),
+		New:   func() any { return &ping2{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping2_local_stub{impl: impl.(Ping2), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping2_server_stub{impl: impl.(Ping2), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping2_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingS"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping2_server_stub{impl: impl.(Ping2), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3",
-		Iface:       reflect.TypeOf((*Ping3)(nil)).Elem(),
-		New:         func() any { return &ping3{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping3_local_stub{impl: impl.(Ping3), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping3_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3",
+		Iface: reflect.TypeOf((*Ping3)(nil)).Elem(),
+		New:   func() any { return &ping3{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping3_local_stub{impl: impl.(Ping3), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping3_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingS"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping3_server_stub{impl: impl.(Ping3), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping3_server_stub{impl: impl.(Ping3), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4",
-		Iface:       reflect.TypeOf((*Ping4)(nil)).Elem(),
-		New:         func() any { return &ping4{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping4_local_stub{impl: impl.(Ping4), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping4_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4",
+		Iface: reflect.TypeOf((*Ping4)(nil)).Elem(),
+		New:   func() any { return &ping4{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping4_local_stub{impl: impl.(Ping4), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping4_server_stub{impl: impl.(Ping4), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping4_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingS"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping4_server_stub{impl: impl.(Ping4), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5",
-		Iface:       reflect.TypeOf((*Ping5)(nil)).Elem(),
-		New:         func() any { return &ping5{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping5_local_stub{impl: impl.(Ping5), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping5_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5",
+		Iface: reflect.TypeOf((*Ping5)(nil)).Elem(),
+		New:   func() any { return &ping5{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping5_local_stub{impl: impl.(Ping5), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping5_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingS"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping5_server_stub{impl: impl.(Ping5), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping5_server_stub{impl: impl.(Ping5), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6",
-		Iface:       reflect.TypeOf((*Ping6)(nil)).Elem(),
-		New:         func() any { return &ping6{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping6_local_stub{impl: impl.(Ping6), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping6_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6",
+		Iface: reflect.TypeOf((*Ping6)(nil)).Elem(),
+		New:   func() any { return &ping6{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping6_local_stub{impl: impl.(Ping6), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping6_server_stub{impl: impl.(Ping6), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping6_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingS"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping6_server_stub{impl: impl.(Ping6), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7",
-		Iface:       reflect.TypeOf((*Ping7)(nil)).Elem(),
-		New:         func() any { return &ping7{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping7_local_stub{impl: impl.(Ping7), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping7_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7",
+		Iface: reflect.TypeOf((*Ping7)(nil)).Elem(),
+		New:   func() any { return &ping7{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping7_local_stub{impl: impl.(Ping7), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping7_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingS"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping7_server_stub{impl: impl.(Ping7), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping7_server_stub{impl: impl.(Ping7), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8",
-		Iface:       reflect.TypeOf((*Ping8)(nil)).Elem(),
-		New:         func() any { return &ping8{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping8_local_stub{impl: impl.(Ping8), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping8_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8",
+		Iface: reflect.TypeOf((*Ping8)(nil)).Elem(),
+		New:   func() any { return &ping8{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping8_local_stub{impl: impl.(Ping8), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping8_server_stub{impl: impl.(Ping8), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping8_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingS"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping8_server_stub{impl: impl.(Ping8), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9",
-		Iface:       reflect.TypeOf((*Ping9)(nil)).Elem(),
-		New:         func() any { return &ping9{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return ping9_local_stub{impl: impl.(Ping9), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return ping9_client_stub{stub: stub, pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingS"})}
+		Name:  "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9",
+		Iface: reflect.TypeOf((*Ping9)(nil)).Elem(),
+		New:   func() any { return &ping9{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return ping9_local_stub{impl: impl.(Ping9), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return ping9_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingCMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingC"}), pingSMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingS"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return ping9_server_stub{impl: impl.(Ping9), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return ping9_server_stub{impl: impl.(Ping9), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -137,8 +157,9 @@ func init() {
 // Local stub implementations.
 
 type ping1_local_stub struct {
-	impl   Ping1
-	tracer trace.Tracer
+	impl        Ping1
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping1_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -155,7 +176,14 @@ func (s ping1_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping1_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -172,12 +200,20 @@ func (s ping1_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping10_local_stub struct {
-	impl   Ping10
-	tracer trace.Tracer
+	impl        Ping10
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping10_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -194,7 +230,14 @@ func (s ping10_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping10_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -211,12 +254,20 @@ func (s ping10_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping2_local_stub struct {
-	impl   Ping2
-	tracer trace.Tracer
+	impl        Ping2
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping2_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -233,7 +284,14 @@ func (s ping2_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping2_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -250,12 +308,20 @@ func (s ping2_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping3_local_stub struct {
-	impl   Ping3
-	tracer trace.Tracer
+	impl        Ping3
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping3_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -272,7 +338,14 @@ func (s ping3_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping3_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -289,12 +362,20 @@ func (s ping3_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping4_local_stub struct {
-	impl   Ping4
-	tracer trace.Tracer
+	impl        Ping4
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping4_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -311,7 +392,14 @@ func (s ping4_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping4_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -328,12 +416,20 @@ func (s ping4_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping5_local_stub struct {
-	impl   Ping5
-	tracer trace.Tracer
+	impl        Ping5
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping5_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -350,7 +446,14 @@ func (s ping5_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping5_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -367,12 +470,20 @@ func (s ping5_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping6_local_stub struct {
-	impl   Ping6
-	tracer trace.Tracer
+	impl        Ping6
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping6_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -389,7 +500,14 @@ func (s ping6_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping6_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -406,12 +524,20 @@ func (s ping6_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping7_local_stub struct {
-	impl   Ping7
-	tracer trace.Tracer
+	impl        Ping7
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping7_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -428,7 +554,14 @@ func (s ping7_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping7_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -445,12 +578,20 @@ func (s ping7_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping8_local_stub struct {
-	impl   Ping8
-	tracer trace.Tracer
+	impl        Ping8
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping8_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -467,7 +608,14 @@ func (s ping8_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping8_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -484,12 +632,20 @@ func (s ping8_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 type ping9_local_stub struct {
-	impl   Ping9
-	tracer trace.Tracer
+	impl        Ping9
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s ping9_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
@@ -506,7 +662,14 @@ func (s ping9_local_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingC(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingC(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s ping9_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
@@ -523,18 +686,38 @@ func (s ping9_local_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 pa
 		}()
 	}
 
-	return s.impl.PingS(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.PingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.PingS(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type ping1_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping1_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping1_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -562,6 +745,22 @@ func (s ping1_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -572,7 +771,6 @@ func (s ping1_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -591,6 +789,17 @@ func (s ping1_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping1_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping1_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -618,11 +827,26 @@ func (s ping1_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -642,11 +866,24 @@ func (s ping1_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping10_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping10_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping10_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -674,6 +911,22 @@ func (s ping10_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -684,7 +937,6 @@ func (s ping10_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -703,6 +955,17 @@ func (s ping10_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0
 }
 
 func (s ping10_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping10_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -730,11 +993,26 @@ func (s ping10_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -754,11 +1032,24 @@ func (s ping10_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0
 
 type ping2_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping2_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping2_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -786,6 +1077,22 @@ func (s ping2_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -796,7 +1103,6 @@ func (s ping2_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -815,6 +1121,17 @@ func (s ping2_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping2_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping2_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -842,11 +1159,26 @@ func (s ping2_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -866,11 +1198,24 @@ func (s ping2_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping3_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping3_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping3_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -898,6 +1243,22 @@ func (s ping3_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -908,7 +1269,6 @@ func (s ping3_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -927,6 +1287,17 @@ func (s ping3_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping3_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping3_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -954,11 +1325,26 @@ func (s ping3_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -978,11 +1364,24 @@ func (s ping3_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping4_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping4_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping4_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1010,6 +1409,22 @@ func (s ping4_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1020,7 +1435,6 @@ func (s ping4_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1039,6 +1453,17 @@ func (s ping4_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping4_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping4_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1066,11 +1491,26 @@ func (s ping4_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1090,11 +1530,24 @@ func (s ping4_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping5_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping5_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping5_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1122,6 +1575,22 @@ func (s ping5_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1132,7 +1601,6 @@ func (s ping5_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1151,6 +1619,17 @@ func (s ping5_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping5_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping5_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1178,11 +1657,26 @@ func (s ping5_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1202,11 +1696,24 @@ func (s ping5_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping6_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping6_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping6_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1234,6 +1741,22 @@ func (s ping6_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1244,7 +1767,6 @@ func (s ping6_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1263,6 +1785,17 @@ func (s ping6_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping6_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping6_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1290,11 +1823,26 @@ func (s ping6_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1314,11 +1862,24 @@ func (s ping6_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping7_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping7_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping7_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1346,6 +1907,22 @@ func (s ping7_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1356,7 +1933,6 @@ func (s ping7_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1375,6 +1951,17 @@ func (s ping7_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping7_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping7_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1402,11 +1989,26 @@ func (s ping7_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1426,11 +2028,24 @@ func (s ping7_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping8_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping8_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping8_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1458,6 +2073,22 @@ func (s ping8_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1468,7 +2099,6 @@ func (s ping8_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1487,6 +2117,17 @@ func (s ping8_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping8_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping8_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1514,11 +2155,26 @@ func (s ping8_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1538,11 +2194,24 @@ func (s ping8_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 
 type ping9_client_stub struct {
 	stub         codegen.Stub
+	codec        codegen.Codec
+	interceptor  codegen.Interceptor
 	pingCMetrics *codegen.MethodMetrics
 	pingSMetrics *codegen.MethodMetrics
 }
 
 func (s ping9_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
+	if s.interceptor == nil {
+		return s.callPingC(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingC(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping9_client_stub) callPingC(ctx context.Context, a0 payloadC, a1 int) (r0 payloadC, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingCMetrics.Count.Add(1)
@@ -1570,6 +2239,22 @@ func (s ping9_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 		s.pingCMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingCMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingCMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_payloadC_7e82696e(&a0)
@@ -1580,7 +2265,6 @@ func (s ping9_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 	// Encode arguments.
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingCMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1599,6 +2283,17 @@ func (s ping9_client_stub) PingC(ctx context.Context, a0 payloadC, a1 int) (r0 p
 }
 
 func (s ping9_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
+	if s.interceptor == nil {
+		return s.callPingS(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPingS(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s ping9_client_stub) callPingS(ctx context.Context, a0 payloadS, a1 int) (r0 payloadS, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingSMetrics.Count.Add(1)
@@ -1626,11 +2321,26 @@ func (s ping9_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 		s.pingSMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.pingSMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingSMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	(a0).WeaverMarshal(enc)
 	enc.Int(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingSMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -1651,8 +2361,10 @@ func (s ping9_client_stub) PingS(ctx context.Context, a0 payloadS, a1 int) (r0 p
 // Server stub implementations.
 
 type ping1_server_stub struct {
-	impl    Ping1
-	addLoad func(key uint64, load float64)
+	impl        Ping1
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -1667,6 +2379,11 @@ func (s ping1_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping1_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping1_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -1676,18 +2393,34 @@ func (s ping1_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1703,18 +2436,34 @@ func (s ping1_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping1", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1722,8 +2471,10 @@ func (s ping1_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping10_server_stub struct {
-	impl    Ping10
-	addLoad func(key uint64, load float64)
+	impl        Ping10
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -1738,6 +2489,11 @@ func (s ping10_server_stub) GetStubFn(method string) func(ctx context.Context, a
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping10_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping10_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -1747,18 +2503,34 @@ func (s ping10_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1774,18 +2546,34 @@ func (s ping10_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping10", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1793,8 +2581,10 @@ func (s ping10_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping2_server_stub struct {
-	impl    Ping2
-	addLoad func(key uint64, load float64)
+	impl        Ping2
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -1809,6 +2599,11 @@ func (s ping2_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping2_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping2_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -1818,18 +2613,34 @@ func (s ping2_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1845,18 +2656,34 @@ func (s ping2_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping2", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1864,8 +2691,10 @@ func (s ping2_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping3_server_stub struct {
-	impl    Ping3
-	addLoad func(key uint64, load float64)
+	impl        Ping3
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -1880,6 +2709,11 @@ func (s ping3_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping3_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping3_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -1889,18 +2723,34 @@ func (s ping3_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1916,18 +2766,34 @@ func (s ping3_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping3", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1935,8 +2801,10 @@ func (s ping3_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping4_server_stub struct {
-	impl    Ping4
-	addLoad func(key uint64, load float64)
+	impl        Ping4
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -1951,6 +2819,11 @@ func (s ping4_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping4_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping4_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -1960,18 +2833,34 @@ func (s ping4_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -1987,18 +2876,34 @@ func (s ping4_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping4", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2006,8 +2911,10 @@ func (s ping4_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping5_server_stub struct {
-	impl    Ping5
-	addLoad func(key uint64, load float64)
+	impl        Ping5
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -2022,6 +2929,11 @@ func (s ping5_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping5_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping5_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -2031,18 +2943,34 @@ func (s ping5_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2058,18 +2986,34 @@ func (s ping5_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping5", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2077,8 +3021,10 @@ func (s ping5_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping6_server_stub struct {
-	impl    Ping6
-	addLoad func(key uint64, load float64)
+	impl        Ping6
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -2093,6 +3039,11 @@ func (s ping6_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping6_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping6_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -2102,18 +3053,34 @@ func (s ping6_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2129,18 +3096,34 @@ func (s ping6_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping6", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2148,8 +3131,10 @@ func (s ping6_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping7_server_stub struct {
-	impl    Ping7
-	addLoad func(key uint64, load float64)
+	impl        Ping7
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -2164,6 +3149,11 @@ func (s ping7_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping7_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping7_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -2173,18 +3163,34 @@ func (s ping7_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2200,18 +3206,34 @@ func (s ping7_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping7", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2219,8 +3241,10 @@ func (s ping7_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping8_server_stub struct {
-	impl    Ping8
-	addLoad func(key uint64, load float64)
+	impl        Ping8
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -2235,6 +3259,11 @@ func (s ping8_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping8_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping8_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -2244,18 +3273,34 @@ func (s ping8_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2271,18 +3316,34 @@ func (s ping8_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping8", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2290,8 +3351,10 @@ func (s ping8_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 }
 
 type ping9_server_stub struct {
-	impl    Ping9
-	addLoad func(key uint64, load float64)
+	impl        Ping9
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -2306,6 +3369,11 @@ func (s ping9_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s ping9_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s ping9_server_stub) pingC(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -2315,18 +3383,34 @@ func (s ping9_server_stub) pingC(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadC
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingC(ctx, a0, a1)
+	var r0 payloadC
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingC(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingC", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingC(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2342,18 +3426,34 @@ func (s ping9_server_stub) pingS(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 payloadS
-	(&a0).WeaverUnmarshal(dec)
 	var a1 int
-	a1 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+		a1 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.PingS(ctx, a0, a1)
+	var r0 payloadS
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.PingS(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/internal/benchmarks/Ping9", Method: "PingS", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.PingS(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -2368,16 +3468,24 @@ func (x *X1) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X1.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	(x.A).WeaverMarshal(enc)
 	serviceweaver_enc_slice_int64_a8f7f092(enc, x.B)
+	enc.EndStruct(start)
 }
 
 func (x *X1) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X1.WeaverUnmarshal: nil receiver"))
 	}
-	(&x.A).WeaverUnmarshal(dec)
-	x.B = serviceweaver_dec_slice_int64_a8f7f092(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.A).WeaverUnmarshal(dec)
+	}
+	if n > 1 {
+		x.B = serviceweaver_dec_slice_int64_a8f7f092(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_int64_a8f7f092(enc *codegen.Encoder, arg []int64) {
@@ -2409,14 +3517,20 @@ func (x *X2) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X2.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(1)
 	(x.A).WeaverMarshal(enc)
+	enc.EndStruct(start)
 }
 
 func (x *X2) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X2.WeaverUnmarshal: nil receiver"))
 	}
-	(&x.A).WeaverUnmarshal(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.A).WeaverUnmarshal(dec)
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &X3{}
@@ -2425,18 +3539,28 @@ func (x *X3) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X3.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(3)
 	(x.A).WeaverMarshal(enc)
 	enc.Int64(x.B)
 	enc.Int64(x.C)
+	enc.EndStruct(start)
 }
 
 func (x *X3) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X3.WeaverUnmarshal: nil receiver"))
 	}
-	(&x.A).WeaverUnmarshal(dec)
-	x.B = dec.Int64()
-	x.C = dec.Int64()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		(&x.A).WeaverUnmarshal(dec)
+	}
+	if n > 1 {
+		x.B = dec.Int64()
+	}
+	if n > 2 {
+		x.C = dec.Int64()
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &X4{}
@@ -2445,18 +3569,28 @@ func (x *X4) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X4.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(3)
 	enc.Int64(x.A)
 	(x.B).WeaverMarshal(enc)
 	enc.Int64(x.C)
+	enc.EndStruct(start)
 }
 
 func (x *X4) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X4.WeaverUnmarshal: nil receiver"))
 	}
-	x.A = dec.Int64()
-	(&x.B).WeaverUnmarshal(dec)
-	x.C = dec.Int64()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.A = dec.Int64()
+	}
+	if n > 1 {
+		(&x.B).WeaverUnmarshal(dec)
+	}
+	if n > 2 {
+		x.C = dec.Int64()
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &X5{}
@@ -2465,16 +3599,24 @@ func (x *X5) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X5.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	enc.Int64(x.A)
 	enc.Int64(x.B)
+	enc.EndStruct(start)
 }
 
 func (x *X5) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X5.WeaverUnmarshal: nil receiver"))
 	}
-	x.A = dec.Int64()
-	x.B = dec.Int64()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.A = dec.Int64()
+	}
+	if n > 1 {
+		x.B = dec.Int64()
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &X6{}
@@ -2483,14 +3625,20 @@ func (x *X6) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("X6.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(1)
 	serviceweaver_enc_slice_bool_c791c3b0(enc, x.A)
+	enc.EndStruct(start)
 }
 
 func (x *X6) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("X6.WeaverUnmarshal: nil receiver"))
 	}
-	x.A = serviceweaver_dec_slice_bool_c791c3b0(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.A = serviceweaver_dec_slice_bool_c791c3b0(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_bool_c791c3b0(enc *codegen.Encoder, arg []bool) {
@@ -2522,6 +3670,7 @@ func (x *payloadC) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("payloadC.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(11)
 	enc.Float64(x.A)
 	enc.String(x.B)
 	enc.Int64(x.C)
@@ -2533,23 +3682,48 @@ func (x *payloadC) WeaverMarshal(enc *codegen.Encoder) {
 	enc.Int64(x.I)
 	enc.Float32(x.J)
 	enc.String(x.K)
+	enc.EndStruct(start)
 }
 
 func (x *payloadC) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("payloadC.WeaverUnmarshal: nil receiver"))
 	}
-	x.A = dec.Float64()
-	x.B = dec.String()
-	x.C = dec.Int64()
-	(&x.D).WeaverUnmarshal(dec)
-	x.E = dec.String()
-	x.F = dec.Int64()
-	(&x.G).WeaverUnmarshal(dec)
-	x.H = dec.String()
-	x.I = dec.Int64()
-	x.J = dec.Float32()
-	x.K = dec.String()
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.A = dec.Float64()
+	}
+	if n > 1 {
+		x.B = dec.String()
+	}
+	if n > 2 {
+		x.C = dec.Int64()
+	}
+	if n > 3 {
+		(&x.D).WeaverUnmarshal(dec)
+	}
+	if n > 4 {
+		x.E = dec.String()
+	}
+	if n > 5 {
+		x.F = dec.Int64()
+	}
+	if n > 6 {
+		(&x.G).WeaverUnmarshal(dec)
+	}
+	if n > 7 {
+		x.H = dec.String()
+	}
+	if n > 8 {
+		x.I = dec.Int64()
+	}
+	if n > 9 {
+		x.J = dec.Float32()
+	}
+	if n > 10 {
+		x.K = dec.String()
+	}
+	dec.EndStruct(end)
 }
 
 var _ codegen.AutoMarshal = &payloadS{}
@@ -2558,14 +3732,20 @@ func (x *payloadS) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("payloadS.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(1)
 	serviceweaver_enc_slice_string_4af10117(enc, x.Values)
+	enc.EndStruct(start)
 }
 
 func (x *payloadS) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("payloadS.WeaverUnmarshal: nil receiver"))
 	}
-	x.Values = serviceweaver_dec_slice_string_4af10117(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.Values = serviceweaver_dec_slice_string_4af10117(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_slice_string_4af10117(enc *codegen.Encoder, arg []string) {
@@ -0,0 +1,281 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compat checks whether two versions of an application can run side
+// by side during a rollout.
+//
+// The versions of an application exchange the arguments and results of
+// component method calls in a compact binary encoding, which doesn't record
+// field names or types. Structs are encoded with their number of fields, so
+// fields can be appended to a struct, but if a new version changes the type
+// of a struct field, a call between the versions silently decodes garbage.
+// To catch such changes before a rollout, a binary describes the schemas of
+// its components with Describe, and the deployer compares them with the
+// schemas of the running version with Compare.
+package compat
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	contextType       = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	protoType         = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	autoMarshalType   = reflect.TypeOf((*codegen.AutoMarshal)(nil)).Elem()
+	binaryMarshalType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	textMarshalType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Describe returns the schemas of the methods of the provided components.
+func Describe(regs []*codegen.Registration) *status.Schema {
+	schema := &status.Schema{}
+	for _, reg := range regs {
+		c := &status.ComponentSchema{Name: reg.Name}
+		for i := 0; i < reg.Iface.NumMethod(); i++ {
+			m := reg.Iface.Method(i)
+			c.Methods = append(c.Methods, describeMethod(m.Name, m.Type))
+		}
+		schema.Components = append(schema.Components, c)
+	}
+	sort.Slice(schema.Components, func(i, j int) bool {
+		return schema.Components[i].Name < schema.Components[j].Name
+	})
+	return schema
+}
+
+// describeMethod returns the schema of the method with the provided name and
+// type, the type of an interface method.
+func describeMethod(name string, t reflect.Type) *status.MethodSchema {
+	m := &status.MethodSchema{Name: name}
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(i) == contextType {
+			continue
+		}
+		m.Args = append(m.Args, describeType(t.In(i), map[reflect.Type]bool{}))
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		if i == t.NumOut()-1 && t.Out(i) == errorType {
+			continue
+		}
+		m.Results = append(m.Results, describeType(t.Out(i), map[reflect.Type]bool{}))
+	}
+	return m
+}
+
+// describeType returns a description of the serialized form of the provided
+// type. Two types with the same description are serialized the same way.
+// Types serialized as protocol buffers, or with MarshalBinary or
+// MarshalText, are opaque: they are described by their name, and their
+// compatibility is the responsibility of their owners.
+//
+// expanding holds the named types being described, to describe recursive
+// types by name.
+func describeType(t reflect.Type, expanding map[reflect.Type]bool) string {
+	switch {
+	case implements(t, protoType):
+		return "proto " + t.String()
+	case implements(t, autoMarshalType):
+		// Serialized field by field, as described below.
+	case implements(t, binaryMarshalType):
+		return "binary " + t.String()
+	case implements(t, textMarshalType):
+		return "text " + t.String()
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + describeType(t.Elem(), expanding)
+	case reflect.Slice:
+		return "[]" + describeType(t.Elem(), expanding)
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), describeType(t.Elem(), expanding))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", describeType(t.Key(), expanding), describeType(t.Elem(), expanding))
+	case reflect.Struct:
+		if t.Name() != "" {
+			if expanding[t] {
+				return t.String()
+			}
+			expanding[t] = true
+			defer delete(expanding, t)
+		}
+		fields := make([]string, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fields[i] = f.Name + " " + describeType(f.Type, expanding)
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	default:
+		// Basic types are serialized according to their kind, regardless of
+		// their name.
+		return t.Kind().String()
+	}
+}
+
+// implements returns whether t, or a pointer to t, implements iface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+// Compare compares the schemas of the running version of an application,
+// old, and of a new version, and returns the changes that prevent the
+// versions from calling each other, sorted: removed components and methods,
+// and changed argument and result types. Added components and methods, and
+// fields appended to structs, are compatible.
+func Compare(old, new *status.Schema) []string {
+	components := map[string]*status.ComponentSchema{}
+	for _, c := range new.Components {
+		components[c.Name] = c
+	}
+
+	var problems []string
+	for _, oc := range old.Components {
+		nc, ok := components[oc.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("component %s removed", oc.Name))
+			continue
+		}
+		methods := map[string]*status.MethodSchema{}
+		for _, m := range nc.Methods {
+			methods[m.Name] = m
+		}
+		for _, om := range oc.Methods {
+			name := oc.Name + "." + om.Name
+			nm, ok := methods[om.Name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("method %s removed", name))
+				continue
+			}
+			problems = append(problems, compareTypes(name, "argument", om.Args, nm.Args)...)
+			problems = append(problems, compareTypes(name, "result", om.Results, nm.Results)...)
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// compareTypes compares the old and new argument or result types of the
+// provided method, and returns how they changed.
+func compareTypes(method, what string, old, new []string) []string {
+	if slices.Equal(old, new) {
+		return nil
+	}
+	if len(old) != len(new) {
+		return []string{fmt.Sprintf("method %s: %ss changed from (%s) to (%s)", method, what, strings.Join(old, ", "), strings.Join(new, ", "))}
+	}
+	var problems []string
+	for i := range old {
+		if !extends(old[i], new[i]) {
+			problems = append(problems, fmt.Sprintf("method %s: %s %d changed from %s to %s", method, what, i, old[i], new[i]))
+		}
+	}
+	return problems
+}
+
+// extends returns whether the type described by new is the type described by
+// old, possibly with fields appended to some of its structs. See
+// describeType for the format of descriptions.
+func extends(old, new string) bool {
+	if old == new {
+		return true
+	}
+	switch {
+	case strings.HasPrefix(old, "*") && strings.HasPrefix(new, "*"):
+		return extends(old[1:], new[1:])
+
+	case strings.HasPrefix(old, "map[") && strings.HasPrefix(new, "map["):
+		oldKey, oldVal := splitMap(old)
+		newKey, newVal := splitMap(new)
+		return extends(oldKey, newKey) && extends(oldVal, newVal)
+
+	case strings.HasPrefix(old, "["):
+		// A slice or an array.
+		i := strings.IndexByte(old, ']')
+		if i < 0 || !strings.HasPrefix(new, old[:i+1]) {
+			return false
+		}
+		return extends(old[i+1:], new[i+1:])
+
+	case strings.HasPrefix(old, "struct{") && strings.HasPrefix(new, "struct{"):
+		oldFields := splitFields(old)
+		newFields := splitFields(new)
+		if len(newFields) < len(oldFields) {
+			return false
+		}
+		for i, of := range oldFields {
+			oldName, oldType, _ := strings.Cut(of, " ")
+			newName, newType, _ := strings.Cut(newFields[i], " ")
+			if oldName != newName || !extends(oldType, newType) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// splitMap splits the description of a map type into the descriptions of its
+// key and value types.
+func splitMap(desc string) (string, string) {
+	rest := strings.TrimPrefix(desc, "map[")
+	depth := 0
+	for i, c := range rest {
+		switch c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth == 0 {
+				return rest[:i], rest[i+1:]
+			}
+			depth--
+		}
+	}
+	return rest, ""
+}
+
+// splitFields splits the description of a struct type into the descriptions
+// of its fields, each of the form "Name type".
+func splitFields(desc string) []string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(desc, "struct{"), "}")
+	if inner == "" {
+		return nil
+	}
+	var fields []string
+	depth, start := 0, 0
+	for i, c := range inner {
+		switch c {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ';':
+			if depth == 0 {
+				fields = append(fields, strings.TrimSpace(inner[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, strings.TrimSpace(inner[start:]))
+}
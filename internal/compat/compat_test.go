@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+type celsius float64
+
+type node struct {
+	Value    celsius
+	Children []*node
+}
+
+type auto struct{ A int }
+
+func (auto) WeaverMarshal(*codegen.Encoder)    {}
+func (*auto) WeaverUnmarshal(*codegen.Decoder) {}
+
+type store interface {
+	Get(context.Context, string) (*node, error)
+	Put(context.Context, map[string]node, time.Time, *status.Schema, auto) error
+}
+
+func TestDescribe(t *testing.T) {
+	regs := []*codegen.Registration{{
+		Name:  "store",
+		Iface: reflect.TypeOf((*store)(nil)).Elem(),
+	}}
+	node := "struct{Value float64; Children []*compat.node}"
+	want := &status.Schema{
+		Components: []*status.ComponentSchema{{
+			Name: "store",
+			Methods: []*status.MethodSchema{
+				{
+					Name:    "Get",
+					Args:    []string{"string"},
+					Results: []string{"*" + node},
+				},
+				{
+					Name: "Put",
+					Args: []string{
+						"map[string]" + node,
+						"binary time.Time",
+						"proto *status.Schema",
+						"struct{A int}",
+					},
+				},
+			},
+		}},
+	}
+	if diff := cmp.Diff(want, Describe(regs), protocmp.Transform()); diff != "" {
+		t.Fatalf("Describe (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	schema := func(methods ...*status.MethodSchema) *status.Schema {
+		return &status.Schema{Components: []*status.ComponentSchema{{Name: "c", Methods: methods}}}
+	}
+	get := &status.MethodSchema{Name: "Get", Args: []string{"string"}, Results: []string{"struct{A int}"}}
+	put := &status.MethodSchema{Name: "Put", Args: []string{"string", "int"}}
+	for _, test := range []struct {
+		name string
+		old  *status.Schema
+		new  *status.Schema
+		want []string
+	}{
+		{"Same", schema(get, put), schema(get, put), nil},
+		{"AddedMethod", schema(get), schema(get, put), nil},
+		{"AddedComponent", schema(get), &status.Schema{Components: append(schema(get).Components, &status.ComponentSchema{Name: "d"})}, nil},
+		{"RemovedComponent", schema(get), &status.Schema{}, []string{"component c removed"}},
+		{"RemovedMethod", schema(get, put), schema(get), []string{"method c.Put removed"}},
+		{
+			"ChangedField",
+			schema(get),
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"string"}, Results: []string{"struct{A string}"}}),
+			[]string{"method c.Get: result 0 changed from struct{A int} to struct{A string}"},
+		},
+		{
+			"AppendedField",
+			schema(get),
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"string"}, Results: []string{"struct{A int; B string}"}}),
+			nil,
+		},
+		{
+			"RemovedField",
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"string"}, Results: []string{"struct{A int; B string}"}}),
+			schema(get),
+			[]string{"method c.Get: result 0 changed from struct{A int; B string} to struct{A int}"},
+		},
+		{
+			"InsertedField",
+			schema(get),
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"string"}, Results: []string{"struct{B string; A int}"}}),
+			[]string{"method c.Get: result 0 changed from struct{A int} to struct{B string; A int}"},
+		},
+		{
+			"AppendedNestedField",
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"map[string][]*struct{A int; B struct{C int}}"}}),
+			schema(&status.MethodSchema{Name: "Get", Args: []string{"map[string][]*struct{A int; B struct{C int; D bool}; E string}"}}),
+			nil,
+		},
+		{
+			"AddedArgument",
+			schema(put),
+			schema(&status.MethodSchema{Name: "Put", Args: []string{"string", "int", "bool"}}),
+			[]string{"method c.Put: arguments changed from (string, int) to (string, int, bool)"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, Compare(test.old, test.new)); diff != "" {
+				t.Fatalf("Compare (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		tm, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, test := range []struct {
+		spec string
+		t    string
+		want string
+	}{
+		{"* * * * *", "2023-03-01 10:00:00", "2023-03-01 10:01:00"},
+		{"* * * * *", "2023-03-01 10:00:30", "2023-03-01 10:01:00"},
+		{"*/15 * * * *", "2023-03-01 10:07:00", "2023-03-01 10:15:00"},
+		{"*/15 * * * *", "2023-03-01 10:45:00", "2023-03-01 11:00:00"},
+		{"5,10 * * * *", "2023-03-01 10:05:00", "2023-03-01 10:10:00"},
+		{"0 9-17/4 * * *", "2023-03-01 13:00:00", "2023-03-01 17:00:00"},
+		{"30 2 * * *", "2023-03-01 03:00:00", "2023-03-02 02:30:00"},
+		{"0 0 1 * *", "2023-12-15 00:00:00", "2024-01-01 00:00:00"},
+		{"0 0 29 2 *", "2023-03-01 00:00:00", "2024-02-29 00:00:00"},
+		{"0 0 * * 0", "2023-03-01 00:00:00", "2023-03-05 00:00:00"}, // Sunday
+		{"0 0 * * 7", "2023-03-01 00:00:00", "2023-03-05 00:00:00"}, // Sunday
+		{"0 0 1 * 1", "2023-03-01 00:00:00", "2023-03-06 00:00:00"}, // Monday or the 1st
+		{"@hourly", "2023-03-01 10:20:00", "2023-03-01 11:00:00"},
+		{"@daily", "2023-03-01 10:20:00", "2023-03-02 00:00:00"},
+		{"@every 10m", "2023-03-01 10:20:00", "2023-03-01 10:30:00"},
+		{"@every 10m", "2023-03-01 10:25:00", "2023-03-01 10:30:00"},
+	} {
+		t.Run(test.spec+"/"+test.t, func(t *testing.T) {
+			s, err := Parse(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := s.Next(at(test.t)), at(test.want); !got.Equal(want) {
+				t.Fatalf("Next(%s): got %v, want %v", test.t, got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"0 0 31 2 *",
+		"@sometimes",
+		"@every",
+		"@every -1s",
+		"@every soon",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): unexpected success", spec)
+		}
+	}
+}
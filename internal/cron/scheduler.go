@@ -0,0 +1,210 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// A Runner runs a job on a weavelet. It returns once the run has finished.
+type Runner func(context.Context, *protos.RunJobRequest) error
+
+// A Scheduler runs jobs on their schedules. A job may have many runners,
+// e.g., the replicas of a component; every run of the job is started on
+// exactly one of them. A run is started at most once: if it fails, e.g.,
+// because its weavelet fails, it is not retried.
+//
+// A tick of a job's schedule is missed if there is no runner to run it, or
+// if the previous run of the job is still running and the job doesn't allow
+// overlapping runs. Missed ticks are skipped, unless the job asks to run
+// missed ticks, in which case the job is run once, as soon as possible, for
+// the latest missed tick.
+type Scheduler struct {
+	ctx    context.Context
+	logger *slog.Logger
+	clock  Clock
+
+	mu   sync.Mutex
+	jobs map[string]*job // scheduled jobs, by name
+}
+
+// job is a job being run by a Scheduler.
+type job struct {
+	req      *protos.ScheduleJobRequest
+	schedule Schedule
+	wake     chan struct{} // signaled when a runner is added
+	runners  []*Runner     // guarded by Scheduler.mu
+	next     int           // index of the next runner, guarded by Scheduler.mu
+}
+
+// A Clock tells the time and creates timers for a Scheduler.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer is a single event created by Clock.NewTimer. See time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// systemClock is a Clock that uses the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+// NewScheduler returns a new Scheduler that schedules runs using the provided
+// clock, or the system clock if clock is nil. The scheduler stops starting
+// runs when ctx is done.
+func NewScheduler(ctx context.Context, logger *slog.Logger, clock Clock) *Scheduler {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	return &Scheduler{ctx: ctx, logger: logger, clock: clock, jobs: map[string]*job{}}
+}
+
+// Schedule adds a runner to the provided job, creating the job if it doesn't
+// exist yet. Runs of the job may be started using run until the returned
+// function is called. Every runner of a job must schedule it with the same
+// schedule and options.
+func (s *Scheduler) Schedule(req *protos.ScheduleJobRequest, run Runner) (func(), error) {
+	schedule, err := Parse(req.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("job %q: %w", req.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[req.Name]
+	if ok && (j.req.Schedule != req.Schedule || j.req.AllowOverlap != req.AllowOverlap || j.req.RunMissed != req.RunMissed) {
+		return nil, fmt.Errorf("job %q: scheduled with schedule %q and options %v, but already scheduled with schedule %q and options %v",
+			req.Name, req.Schedule, options(req), j.req.Schedule, options(j.req))
+	}
+	if !ok {
+		j = &job{req: req, schedule: schedule, wake: make(chan struct{}, 1)}
+		s.jobs[req.Name] = j
+		go s.run(j)
+	}
+	r := &run
+	j.runners = append(j.runners, r)
+	j.signal()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if i := slices.Index(j.runners, r); i >= 0 {
+			j.runners = slices.Delete(j.runners, i, i+1)
+		}
+	}, nil
+}
+
+// options returns a description of the options of a job, for error messages.
+func options(req *protos.ScheduleJobRequest) string {
+	return fmt.Sprintf("{AllowOverlap: %t, RunMissed: %t}", req.AllowOverlap, req.RunMissed)
+}
+
+// run runs the provided job on its schedule until s.ctx is done.
+func (s *Scheduler) run(j *job) {
+	done := make(chan struct{}) // receives when a run finishes
+	running := 0                // number of running runs
+	var missed time.Time        // latest missed tick, if it should be run
+
+	// start starts a run of the job for the provided tick, returning false
+	// if the run cannot be started.
+	start := func(tick time.Time) bool {
+		if running > 0 && !j.req.AllowOverlap {
+			return false
+		}
+		run := s.pick(j)
+		if run == nil {
+			return false
+		}
+		running++
+		req := &protos.RunJobRequest{Name: j.req.Name, ScheduledMicros: tick.UnixMicro()}
+		go func() {
+			if err := run(s.ctx, req); err != nil && s.ctx.Err() == nil {
+				s.logger.Error("job failed", err, "job", j.req.Name, "scheduled", tick)
+			}
+			select {
+			case done <- struct{}{}:
+			case <-s.ctx.Done():
+			}
+		}()
+		return true
+	}
+
+	next := j.schedule.Next(s.clock.Now())
+	for {
+		timer := s.clock.NewTimer(next.Sub(s.clock.Now()))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-j.wake:
+			timer.Stop()
+		case <-done:
+			timer.Stop()
+			running--
+		case <-timer.C():
+			if start(next) {
+				missed = time.Time{}
+			} else {
+				s.logger.Debug("missed job tick", "job", j.req.Name, "scheduled", next)
+				if j.req.RunMissed {
+					missed = next
+				}
+			}
+			next = j.schedule.Next(s.clock.Now())
+			continue
+		}
+		if !missed.IsZero() && start(missed) {
+			missed = time.Time{}
+		}
+	}
+}
+
+// pick returns the runner of j that should start the next run, or nil if j
+// has no runners. Runners are picked round robin.
+func (s *Scheduler) pick(j *job) Runner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(j.runners) == 0 {
+		return nil
+	}
+	r := j.runners[j.next%len(j.runners)]
+	j.next++
+	return *r
+}
+
+// signal wakes up the goroutine running j, if it is waiting.
+func (j *job) signal() {
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// runner is a Runner that records the runs started on it.
+type runner struct {
+	mu      sync.Mutex
+	starts  []time.Time   // start times of the runs
+	block   chan struct{} // if not nil, runs block until it is closed
+	running int           // number of running runs
+	overlap bool          // did two runs overlap?
+}
+
+func (r *runner) run(context.Context, *protos.RunJobRequest) error {
+	r.mu.Lock()
+	r.starts = append(r.starts, time.Now())
+	r.running++
+	if r.running > 1 {
+		r.overlap = true
+	}
+	block := r.block
+	r.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	r.mu.Lock()
+	r.running--
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *runner) get() (runs int, overlap bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.starts), r.overlap
+}
+
+// await waits until the runners have started n runs in total.
+func await(t *testing.T, n int, rs ...*runner) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		got := 0
+		for _, r := range rs {
+			runs, _ := r.get()
+			got += runs
+		}
+		if got >= n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d runs", n)
+}
+
+func newScheduler(t *testing.T) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := slog.New(slog.NewTextHandler(os.Stderr))
+	return NewScheduler(ctx, logger, nil)
+}
+
+func TestSchedulerRunsOnOneRunner(t *testing.T) {
+	// Test plan: Schedule a job with two runners, and check that the runs
+	// are spread across them, one runner per tick.
+	s := newScheduler(t)
+	req := &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 10ms"}
+	a, b := &runner{}, &runner{}
+	for _, r := range []*runner{a, b} {
+		if _, err := s.Schedule(req, r.run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	await(t, 10, a, b)
+	if runs, _ := a.get(); runs == 0 {
+		t.Error("no runs on runner a")
+	}
+	if runs, _ := b.get(); runs == 0 {
+		t.Error("no runs on runner b")
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Skip", true: "Allow"}[allow], func(t *testing.T) {
+			// Test plan: Schedule a job whose runs block, and check that
+			// runs overlap only if allowed.
+			s := newScheduler(t)
+			req := &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 10ms", AllowOverlap: allow}
+			r := &runner{block: make(chan struct{})}
+			defer close(r.block)
+			if _, err := s.Schedule(req, r.run); err != nil {
+				t.Fatal(err)
+			}
+			if allow {
+				await(t, 2, r)
+			} else {
+				time.Sleep(100 * time.Millisecond)
+			}
+			if runs, overlap := r.get(); overlap != allow {
+				t.Fatalf("overlap: got %t after %d runs, want %t", overlap, runs, allow)
+			}
+		})
+	}
+}
+
+func TestSchedulerRunMissed(t *testing.T) {
+	for _, runMissed := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Skip", true: "Run"}[runMissed], func(t *testing.T) {
+			// Test plan: Schedule a job every 200ms, and block its first run
+			// for 300ms, missing a tick. Check that the next run starts
+			// right away only if missed ticks are run.
+			s := newScheduler(t)
+			req := &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 200ms", RunMissed: runMissed}
+			r := &runner{block: make(chan struct{})}
+			if _, err := s.Schedule(req, r.run); err != nil {
+				t.Fatal(err)
+			}
+			await(t, 1, r)
+			time.Sleep(300 * time.Millisecond)
+			released := time.Now()
+			close(r.block)
+			await(t, 2, r)
+
+			r.mu.Lock()
+			delay := r.starts[1].Sub(released)
+			r.mu.Unlock()
+			if runMissed && delay > 50*time.Millisecond {
+				t.Fatalf("missed tick run %v after the previous run, want right away", delay)
+			}
+			if !runMissed && delay < 50*time.Millisecond {
+				t.Fatalf("run started %v after the previous run, want at the next tick", delay)
+			}
+		})
+	}
+}
+
+// fakeClock is a Clock that stands still. Every timer it creates is sent on
+// timers, and fires only when the test sends on its channel.
+type fakeClock struct {
+	now    time.Time
+	timers chan fakeTimer
+}
+
+type fakeTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+func (c fakeClock) Now() time.Time { return c.now }
+
+func (c fakeClock) NewTimer(d time.Duration) Timer {
+	t := fakeTimer{d: d, c: make(chan time.Time, 1)}
+	c.timers <- t
+	return t
+}
+
+func (t fakeTimer) C() <-chan time.Time { return t.c }
+func (t fakeTimer) Stop() bool          { return true }
+
+func TestSchedulerUsesClock(t *testing.T) {
+	// Test plan: Schedule an hourly job on a fake clock. Check that the
+	// scheduler waits for an hour of fake time, and that the run is
+	// scheduled for the fake tick.
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	clock := fakeClock{
+		now:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		timers: make(chan fakeTimer, 10),
+	}
+	s := NewScheduler(ctx, slog.New(slog.NewTextHandler(os.Stderr)), clock)
+	scheduled := make(chan time.Time, 1)
+	run := func(_ context.Context, req *protos.RunJobRequest) error {
+		scheduled <- time.UnixMicro(req.ScheduledMicros)
+		return nil
+	}
+	req := &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 1h"}
+	if _, err := s.Schedule(req, run); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding the runner wakes the scheduler, which replaces its timer with a
+	// new one for the same tick. Fire every timer; stale ones are ignored.
+	tick := clock.now.Add(time.Hour)
+	for {
+		select {
+		case timer := <-clock.timers:
+			if got, want := timer.d, time.Hour; got != want {
+				t.Fatalf("timer duration: got %v, want %v", got, want)
+			}
+			timer.c <- tick
+		case got := <-scheduled:
+			if !got.Equal(tick) {
+				t.Fatalf("scheduled: got %v, want %v", got, tick)
+			}
+			return
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a run")
+		}
+	}
+}
+
+func TestSchedulerMismatchedSchedules(t *testing.T) {
+	s := newScheduler(t)
+	req := &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 1h"}
+	if _, err := s.Schedule(req, (&runner{}).run); err != nil {
+		t.Fatal(err)
+	}
+	req = &protos.ScheduleJobRequest{Name: "job", Schedule: "@every 2h"}
+	if _, err := s.Schedule(req, (&runner{}).run); err == nil {
+		t.Fatal("unexpected success")
+	}
+}
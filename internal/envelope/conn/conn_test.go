@@ -80,8 +80,76 @@ func TestMetricPropagation(t *testing.T) {
 	checkValue("TestMetricPropagation.hist", 1000)
 }
 
+func TestDrain(t *testing.T) {
+	// Test plan: Issue a DrainRPC to a weavelet whose Drain blocks. Check that
+	// the weavelet reports itself as draining until Drain returns, and that
+	// DrainRPC returns once Drain does.
+	wlet := &weaveletHandlerForTest{
+		draining: make(chan struct{}),
+		release:  make(chan struct{}),
+		health:   protos.HealthStatus_HEALTHY,
+	}
+	envelope, _ := makeWeaveletConnections(t, &handlerForTest{}, wlet)
+
+	// A blocked drain must not block other RPCs.
+	drained := make(chan error, 1)
+	go func() { drained <- envelope.DrainRPC() }()
+	<-wlet.draining
+	reply, err := envelope.GetHealthRPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Status != protos.HealthStatus_DRAINING {
+		t.Fatalf("health: got %v, want %v", reply.Status, protos.HealthStatus_DRAINING)
+	}
+
+	close(wlet.release)
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHealthStarting(t *testing.T) {
+	// Test plan: Check that the health reported by the weavelet handler is
+	// returned by GetHealthRPC.
+	for _, want := range []protos.HealthStatus{
+		protos.HealthStatus_STARTING,
+		protos.HealthStatus_HEALTHY,
+		protos.HealthStatus_UNHEALTHY,
+	} {
+		t.Run(want.String(), func(t *testing.T) {
+			wlet := &weaveletHandlerForTest{health: want}
+			envelope, _ := makeWeaveletConnections(t, &handlerForTest{}, wlet)
+			reply, err := envelope.GetHealthRPC()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reply.Status; got != want {
+				t.Fatalf("health: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	// Test plan: Check that the weavelet of a single machine deployment
+	// listens on, and is dialed at, a Unix socket.
+	envelope, weavelet := makeConnections(t, &handlerForTest{})
+	if got := envelope.WeaveletInfo().DialAddr; !strings.HasPrefix(got, "unix://") {
+		t.Fatalf("DialAddr: got %q, want a unix:// address", got)
+	}
+	if got := weavelet.Listener().Addr().Network(); got != "unix" {
+		t.Fatalf("Listener network: got %q, want unix", got)
+	}
+}
+
 func makeConnections(t *testing.T, handler conn.EnvelopeHandler) (*conn.EnvelopeConn, *conn.WeaveletConn) {
 	t.Helper()
+	return makeWeaveletConnections(t, handler, nil)
+}
+
+func makeWeaveletConnections(t *testing.T, handler conn.EnvelopeHandler, wletHandler conn.WeaveletHandler) (*conn.EnvelopeConn, *conn.WeaveletConn) {
+	t.Helper()
 
 	// Create the pipes. Note that we use os.Pipe instead of io.Pipe. The pipes
 	// returned by io.Pipe are synchronous, meaning that a write blocks until a
@@ -114,7 +182,7 @@ func makeConnections(t *testing.T, handler conn.EnvelopeHandler) (*conn.Envelope
 	weaveletDone := make(chan error)
 	go func() {
 		var err error
-		if w, err = conn.NewWeaveletConn(wReader, wWriter, nil /*handler*/); err != nil {
+		if w, err = conn.NewWeaveletConn(wReader, wWriter, wletHandler); err != nil {
 			panic(err)
 		}
 		created <- struct{}{}
@@ -163,6 +231,38 @@ func (*handlerForTest) HandleLogEntry(context.Context, *protos.LogEntry) error {
 	return nil
 }
 
+func (*handlerForTest) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	return nil
+}
+
+func (*handlerForTest) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) HandleProfile(context.Context, *protos.Profile) error {
+	return nil
+}
+
+func (*handlerForTest) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	return nil
+}
+
 func (*handlerForTest) ActivateComponent(context.Context, *protos.ActivateComponentRequest) (*protos.ActivateComponentReply, error) {
 	return nil, nil
 }
@@ -174,3 +274,61 @@ func (*handlerForTest) GetListenerAddress(context.Context, *protos.GetListenerAd
 func (*handlerForTest) ExportListener(context.Context, *protos.ExportListenerRequest) (*protos.ExportListenerReply, error) {
 	return nil, nil
 }
+
+// weaveletHandlerForTest is a conn.WeaveletHandler whose Drain blocks until
+// release is closed.
+type weaveletHandlerForTest struct {
+	draining chan struct{}       // closed when Drain is called
+	release  chan struct{}       // closed to unblock Drain
+	health   protos.HealthStatus // returned by Health
+}
+
+var _ conn.WeaveletHandler = &weaveletHandlerForTest{}
+
+func (*weaveletHandlerForTest) GetLoad(*protos.GetLoadRequest) (*protos.GetLoadReply, error) {
+	return &protos.GetLoadReply{}, nil
+}
+
+func (*weaveletHandlerForTest) UpdateComponents(*protos.UpdateComponentsRequest) (*protos.UpdateComponentsReply, error) {
+	return &protos.UpdateComponentsReply{}, nil
+}
+
+func (*weaveletHandlerForTest) UpdateRoutingInfo(*protos.UpdateRoutingInfoRequest) (*protos.UpdateRoutingInfoReply, error) {
+	return &protos.UpdateRoutingInfoReply{}, nil
+}
+
+func (*weaveletHandlerForTest) HandleTopicEvent(*protos.TopicEvent) {}
+
+func (*weaveletHandlerForTest) DeliverMessage(*protos.DeliverMessageRequest) (*protos.DeliverMessageReply, error) {
+	return &protos.DeliverMessageReply{}, nil
+}
+
+func (*weaveletHandlerForTest) RunJob(*protos.RunJobRequest) (*protos.RunJobReply, error) {
+	return &protos.RunJobReply{}, nil
+}
+
+func (*weaveletHandlerForTest) SetLogLevel(*protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	return &protos.SetLogLevelReply{}, nil
+}
+
+func (*weaveletHandlerForTest) InjectFaults(*protos.InjectFaultsRequest) (*protos.InjectFaultsReply, error) {
+	return &protos.InjectFaultsReply{}, nil
+}
+
+func (*weaveletHandlerForTest) SetFlags(*protos.SetFlagsRequest) (*protos.SetFlagsReply, error) {
+	return &protos.SetFlagsReply{}, nil
+}
+
+func (*weaveletHandlerForTest) SetConfig(*protos.SetConfigRequest) (*protos.SetConfigReply, error) {
+	return &protos.SetConfigReply{}, nil
+}
+
+func (h *weaveletHandlerForTest) Drain(*protos.DrainRequest) (*protos.DrainReply, error) {
+	close(h.draining)
+	<-h.release
+	return &protos.DrainReply{}, nil
+}
+
+func (h *weaveletHandlerForTest) Health() *protos.GetHealthReply {
+	return &protos.GetHealthReply{Status: h.health}
+}
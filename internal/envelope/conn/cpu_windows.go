@@ -0,0 +1,39 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package conn
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTime returns the CPU time used by the current process so far.
+func cpuTime() (time.Duration, error) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, err
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	// Filetimes count 100-nanosecond intervals.
+	ticks := func(f syscall.Filetime) int64 {
+		return int64(f.HighDateTime)<<32 | int64(f.LowDateTime)
+	}
+	return time.Duration((ticks(kernel) + ticks(user)) * 100), nil
+}
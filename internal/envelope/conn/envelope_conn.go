@@ -35,6 +35,14 @@ type EnvelopeHandler interface {
 	ExportListener(context.Context, *protos.ExportListenerRequest) (*protos.ExportListenerReply, error)
 	HandleLogEntry(context.Context, *protos.LogEntry) error
 	HandleTraceSpans(context.Context, []trace.ReadOnlySpan) error
+	HandleTopicEvent(context.Context, *protos.TopicEvent) error
+	PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error)
+	Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error)
+	ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error)
+	GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error)
+	Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error)
+	HandleProfile(context.Context, *protos.Profile) error
+	HandleCrashReport(context.Context, *protos.CrashReport) error
 }
 
 // EnvelopeConn is the envelope side of the connection between a weavelet and
@@ -227,6 +235,47 @@ func (e *EnvelopeConn) handleMessage(msg *protos.WeaveletMsg, h EnvelopeHandler)
 			traces[i] = &traceio.ReadSpan{Span: span}
 		}
 		return h.HandleTraceSpans(e.ctx, traces)
+	case msg.TopicEvent != nil:
+		return h.HandleTopicEvent(e.ctx, msg.TopicEvent)
+	case msg.Profile != nil:
+		return h.HandleProfile(e.ctx, msg.Profile)
+	case msg.CrashReport != nil:
+		return h.HandleCrashReport(e.ctx, msg.CrashReport)
+	case msg.PublishMessageRequest != nil:
+		reply, err := h.PublishMessage(e.ctx, msg.PublishMessageRequest)
+		return e.conn.send(&protos.EnvelopeMsg{
+			Id:                  -msg.Id,
+			Error:               errstring(err),
+			PublishMessageReply: reply,
+		})
+	case msg.SubscribeRequest != nil:
+		reply, err := h.Subscribe(e.ctx, msg.SubscribeRequest)
+		return e.conn.send(&protos.EnvelopeMsg{
+			Id:             -msg.Id,
+			Error:          errstring(err),
+			SubscribeReply: reply,
+		})
+	case msg.ScheduleJobRequest != nil:
+		reply, err := h.ScheduleJob(e.ctx, msg.ScheduleJobRequest)
+		return e.conn.send(&protos.EnvelopeMsg{
+			Id:               -msg.Id,
+			Error:            errstring(err),
+			ScheduleJobReply: reply,
+		})
+	case msg.GetCertificateRequest != nil:
+		reply, err := h.GetCertificate(e.ctx, msg.GetCertificateRequest)
+		return e.conn.send(&protos.EnvelopeMsg{
+			Id:                  -msg.Id,
+			Error:               errstring(err),
+			GetCertificateReply: reply,
+		})
+	case msg.LeaseRequest != nil:
+		reply, err := h.Lease(e.ctx, msg.LeaseRequest)
+		return e.conn.send(&protos.EnvelopeMsg{
+			Id:         -msg.Id,
+			Error:      errstring(err),
+			LeaseReply: reply,
+		})
 	default:
 		err := fmt.Errorf("envelope_conn: unexpected message %+v", msg)
 		e.conn.cleanup(err)
@@ -249,16 +298,16 @@ func (e *EnvelopeConn) GetMetricsRPC() ([]*metrics.MetricSnapshot, error) {
 }
 
 // GetHealthRPC gets a weavelet's health.
-func (e *EnvelopeConn) GetHealthRPC() (protos.HealthStatus, error) {
+func (e *EnvelopeConn) GetHealthRPC() (*protos.GetHealthReply, error) {
 	req := &protos.EnvelopeMsg{GetHealthRequest: &protos.GetHealthRequest{}}
 	reply, err := e.rpc(req)
 	if err != nil {
-		return protos.HealthStatus_UNHEALTHY, err
+		return nil, err
 	}
 	if reply.GetHealthReply == nil {
-		return protos.HealthStatus_UNHEALTHY, fmt.Errorf("nil HealthStatusReply received from weavelet")
+		return nil, fmt.Errorf("nil HealthStatusReply received from weavelet")
 	}
-	return reply.GetHealthReply.Status, nil
+	return reply.GetHealthReply, nil
 }
 
 // GetLoadRPC gets a load report from the weavelet.
@@ -287,6 +336,97 @@ func (e *EnvelopeConn) GetProfileRPC(req *protos.GetProfileRequest) ([]byte, err
 	return reply.GetProfileReply.Data, nil
 }
 
+// SetLogLevelRPC changes the minimum level of the log entries produced by a
+// component in the weavelet.
+func (e *EnvelopeConn) SetLogLevelRPC(req *protos.SetLogLevelRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{SetLogLevelRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.SetLogLevelReply == nil {
+		return fmt.Errorf("nil SetLogLevelReply received from weavelet")
+	}
+	return nil
+}
+
+// InjectFaultsRPC asks the weavelet to inject faults into its calls to remote
+// components.
+func (e *EnvelopeConn) InjectFaultsRPC(req *protos.InjectFaultsRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{InjectFaultsRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.InjectFaultsReply == nil {
+		return fmt.Errorf("nil InjectFaultsReply received from weavelet")
+	}
+	return nil
+}
+
+// SetFlagsRPC replaces the feature flags of the weavelet's deployment.
+func (e *EnvelopeConn) SetFlagsRPC(req *protos.SetFlagsRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{SetFlagsRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.SetFlagsReply == nil {
+		return fmt.Errorf("nil SetFlagsReply received from weavelet")
+	}
+	return nil
+}
+
+// SetConfigRPC replaces the component config sections of the weavelet's
+// deployment.
+func (e *EnvelopeConn) SetConfigRPC(req *protos.SetConfigRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{SetConfigRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.SetConfigReply == nil {
+		return fmt.Errorf("nil SetConfigReply received from weavelet")
+	}
+	return nil
+}
+
+// DrainRPC asks the weavelet to drain, and blocks until it is drained. See
+// protos.DrainRequest for details.
+func (e *EnvelopeConn) DrainRPC() error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{DrainRequest: &protos.DrainRequest{}})
+	if err != nil {
+		return err
+	}
+	if reply.DrainReply == nil {
+		return fmt.Errorf("nil DrainReply received from weavelet")
+	}
+	return nil
+}
+
+// DeliverMessageRPC delivers a message published on a topic to the weavelet,
+// and blocks until the weavelet has handled it. See
+// protos.DeliverMessageRequest for details.
+func (e *EnvelopeConn) DeliverMessageRPC(req *protos.DeliverMessageRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{DeliverMessageRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.DeliverMessageReply == nil {
+		return fmt.Errorf("nil DeliverMessageReply received from weavelet")
+	}
+	return nil
+}
+
+// RunJobRPC runs a job registered with weaver.Cron in the weavelet, and
+// blocks until the run has finished.
+func (e *EnvelopeConn) RunJobRPC(req *protos.RunJobRequest) error {
+	reply, err := e.rpc(&protos.EnvelopeMsg{RunJobRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.RunJobReply == nil {
+		return fmt.Errorf("nil RunJobReply received from weavelet")
+	}
+	return nil
+}
+
 // UpdateComponentsRPC updates the weavelet with the latest set of components
 // it should be running.
 func (e *EnvelopeConn) UpdateComponentsRPC(components []string) error {
@@ -323,6 +463,12 @@ func (e *EnvelopeConn) UpdateRoutingInfoRPC(routing *protos.RoutingInfo) error {
 	return nil
 }
 
+// SendTopicEvent delivers an event published by some other weavelet to the
+// weavelet, without waiting for a reply.
+func (e *EnvelopeConn) SendTopicEvent(event *protos.TopicEvent) error {
+	return e.conn.send(&protos.EnvelopeMsg{TopicEvent: event})
+}
+
 func (e *EnvelopeConn) rpc(request *protos.EnvelopeMsg) (*protos.WeaveletMsg, error) {
 	response, err := e.conn.doBlockingRPC(request)
 	if err != nil {
@@ -0,0 +1,27 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package conn
+
+import "io/fs"
+
+// checkPrivate returns an error if the provided directory, with the provided
+// info, is not private to the current user. On Windows, the directory is in
+// the temporary directory of the current user, which other users can't
+// access, and file modes don't reflect access control lists.
+func checkPrivate(string, fs.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package conn
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// rssBytes returns the resident set size, in bytes, of the current process.
+func rssBytes() (uint64, error) {
+	// The second field of /proc/self/statm is the resident set size, in
+	// pages. See proc(5).
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed /proc/self/statm: %q", statm)
+	}
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed /proc/self/statm: %w", err)
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
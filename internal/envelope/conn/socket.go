@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	rt "runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxSocketPath is the maximum length of the path of a Unix socket. sun_path
+// holds 108 bytes on Linux, but only 104 bytes on macOS and the BSDs,
+// including the terminating NUL byte.
+const maxSocketPath = 103
+
+// socketDir returns the directory that holds the Unix sockets of the
+// weavelets of the current user. The directory is in /tmp rather than in
+// os.TempDir(), which is too long a path for sockets on macOS, e.g.,
+// /var/folders/xx/yyyyyyyyyyyyyyyyyyyyyyyyyyyy/T/.
+func socketDir() string {
+	base := "/tmp"
+	if rt.GOOS == "windows" {
+		base = os.TempDir()
+	} else if _, err := os.Stat(base); err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, fmt.Sprintf("sw%d", os.Getuid()))
+}
+
+// listenUnix listens on a Unix socket for the weavelet with the provided id,
+// in a directory per deployment in the provided directory, after removing the
+// stale sockets of the weavelets of the deployment that exited without
+// removing theirs. It fails if the path of the socket is longer than
+// maxSocketPath, or if the directories are not private to the current user.
+func listenUnix(dir, deployment, id string) (net.Listener, error) {
+	depDir := filepath.Join(dir, shortID(deployment))
+	path := filepath.Join(depDir, shortID(id)+".sock")
+	if len(path) > maxSocketPath {
+		return nil, fmt.Errorf("socket path %q longer than %d bytes", path, maxSocketPath)
+	}
+	for _, d := range []string{dir, depDir} {
+		if err := makePrivateDir(d); err != nil {
+			return nil, err
+		}
+	}
+	removeStaleSockets(depDir)
+	return net.Listen("unix", path)
+}
+
+// shortID returns a prefix of the provided UUID. Deployment and weavelet ids
+// are UUIDs. The first 64 bits are plenty to tell apart the deployments and
+// weavelets of a machine, and keep the path of a socket short.
+func shortID(id string) string {
+	short := strings.ReplaceAll(id, "-", "")
+	if len(short) > 16 {
+		short = short[:16]
+	}
+	return short
+}
+
+// makePrivateDir creates the provided directory, accessible only by the
+// current user, if it doesn't exist. It fails if the directory exists but is a
+// symbolic link, or is not private to the current user: in a shared directory
+// like /tmp, another user may have created it to intercept the sockets in it.
+func makePrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("socket directory %q is a symbolic link", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("socket directory %q is not a directory", dir)
+	}
+	return checkPrivate(dir, info)
+}
+
+// removeStaleSockets removes the sockets in the provided directory that
+// nothing listens on anymore.
+func removeStaleSockets(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSocket == 0 || filepath.Ext(entry.Name()) != ".sock" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			continue
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			os.Remove(path) //nolint:errcheck // best effort
+		}
+	}
+}
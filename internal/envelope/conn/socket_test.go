@@ -0,0 +1,142 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/uuid"
+)
+
+// shortTempDir returns a temporary directory with a path short enough to
+// hold sockets, which t.TempDir() may not be on macOS.
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp(filepath.Dir(socketDir()), "swtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenUnixRemovesStaleSockets(t *testing.T) {
+	// Test plan: Leave a socket behind as if its weavelet crashed, and check
+	// that listening on a new socket of the same deployment removes it, but
+	// not a live one, nor the stale socket of another deployment.
+	dir := shortTempDir(t)
+	dep, otherDep := uuid.New().String(), uuid.New().String()
+	listenStale := func(dep string) string {
+		t.Helper()
+		stale, err := listenUnix(dir, dep, uuid.New().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Closing a Unix listener removes its socket, unless told otherwise.
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+		if _, err := os.Stat(stale.Addr().String()); err != nil {
+			t.Fatalf("stale socket: %v", err)
+		}
+		return stale.Addr().String()
+	}
+	live, err := listenUnix(dir, dep, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	stalePath := listenStale(dep)
+	otherPath := listenStale(otherDep)
+
+	lis, err := listenUnix(dir, dep, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Errorf("stale socket not removed: %v", err)
+	}
+	if _, err := os.Stat(live.Addr().String()); err != nil {
+		t.Errorf("live socket removed: %v", err)
+	}
+	if _, err := os.Stat(otherPath); err != nil {
+		t.Errorf("stale socket of another deployment removed: %v", err)
+	}
+	if got := len(filepath.Base(lis.Addr().String())); got != len("0123456789abcdef.sock") {
+		t.Errorf("socket name %q: got %d bytes, want a short id", lis.Addr().String(), got)
+	}
+}
+
+func TestListenUnixPathTooLong(t *testing.T) {
+	long := filepath.Join(shortTempDir(t), strings.Repeat("x", maxSocketPath))
+	if lis, err := listenUnix(long, uuid.New().String(), uuid.New().String()); err == nil {
+		lis.Close()
+		t.Fatal("listenUnix with a long path: unexpected success")
+	}
+}
+
+func TestListenUnixRejectsUnsafeDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes don't reflect access control on Windows")
+	}
+	for _, test := range []struct {
+		name  string
+		setup func(dir string) error // creates dir
+	}{
+		{"Symlink", func(dir string) error {
+			target, err := os.MkdirTemp(filepath.Dir(dir), "swtarget")
+			if err != nil {
+				return err
+			}
+			t.Cleanup(func() { os.RemoveAll(target) })
+			return os.Symlink(target, dir)
+		}},
+		{"Shared", func(dir string) error {
+			if err := os.Mkdir(dir, 0o700); err != nil {
+				return err
+			}
+			return os.Chmod(dir, 0o777)
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join(shortTempDir(t), "sw")
+			if err := test.setup(dir); err != nil {
+				t.Fatal(err)
+			}
+			if lis, err := listenUnix(dir, uuid.New().String(), uuid.New().String()); err == nil {
+				lis.Close()
+				t.Fatal("listenUnix: unexpected success")
+			}
+		})
+	}
+}
+
+func TestListenSingleMachine(t *testing.T) {
+	info := &protos.EnvelopeInfo{Id: uuid.New().String(), SingleMachine: true}
+	lis, err := listen(info, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if got := lis.Addr().Network(); got != "unix" {
+		t.Errorf("listen: got network %q, want unix", got)
+	}
+}
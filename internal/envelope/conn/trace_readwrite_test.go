@@ -52,6 +52,38 @@ func (*pipeForTest) HandleLogEntry(context.Context, *protos.LogEntry) error {
 	return nil
 }
 
+func (*pipeForTest) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	return nil
+}
+
+func (*pipeForTest) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	return nil, nil
+}
+
+func (*pipeForTest) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	return nil, nil
+}
+
+func (*pipeForTest) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	return nil, nil
+}
+
+func (*pipeForTest) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	return nil, nil
+}
+
+func (*pipeForTest) Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return nil, nil
+}
+
+func (*pipeForTest) HandleProfile(context.Context, *protos.Profile) error {
+	return nil
+}
+
+func (*pipeForTest) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	return nil
+}
+
 func (*pipeForTest) ActivateComponent(context.Context, *protos.ActivateComponentRequest) (*protos.ActivateComponentReply, error) {
 	return nil, nil
 }
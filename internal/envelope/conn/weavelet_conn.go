@@ -20,15 +20,88 @@ import (
 	"io"
 	"net"
 	"os"
+	rt "runtime"
 	"runtime/pprof"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/protomsg"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	pprofile "github.com/google/pprof/profile"
 )
 
+// mutexProfileFraction is the rate at which mutex contention events are
+// sampled while a mutex profile is collected. See
+// runtime.SetMutexProfileFraction.
+const mutexProfileFraction = 10
+
+// mutexProfileMu serializes the collection of mutex profiles, which changes
+// the process-wide mutex profile fraction.
+var mutexProfileMu sync.Mutex
+
+// cpuProfileMu serializes the collection of CPU profiles, since a process
+// can only collect one at a time. Profiles requested by the envelope wait
+// for the profiles of the continuous profiler, and vice versa.
+var cpuProfileMu sync.Mutex
+
+// cpuSeconds holds the CPU time, in seconds, used by the weavelet process. It
+// is updated whenever the envelope reads the weavelet's metrics, and is used
+// by deployers to autoscale weavelets.
+var cpuSeconds = metrics.Register(protos.MetricType_GAUGE,
+	"serviceweaver_weavelet_cpu_seconds",
+	"CPU time, in seconds, used by the Service Weaver weavelet process",
+	nil)
+
+// Runtime metrics of the weavelet process, updated whenever the envelope
+// reads the weavelet's metrics. Every metric of a weavelet is labeled with
+// its co-location group, which attributes these metrics to the components
+// the weavelet runs.
+var (
+	rssBytesGauge = metrics.Register(protos.MetricType_GAUGE,
+		"serviceweaver_weavelet_memory_rss_bytes",
+		"Resident set size, in bytes, of the Service Weaver weavelet process",
+		nil)
+	heapBytes = metrics.Register(protos.MetricType_GAUGE,
+		"serviceweaver_weavelet_heap_bytes",
+		"Bytes of allocated heap objects of the Service Weaver weavelet process",
+		nil)
+	goroutines = metrics.Register(protos.MetricType_GAUGE,
+		"serviceweaver_weavelet_goroutines",
+		"Number of goroutines of the Service Weaver weavelet process",
+		nil)
+	gcCount = metrics.Register(protos.MetricType_GAUGE,
+		"serviceweaver_weavelet_gc_count",
+		"Number of completed garbage collection cycles of the Service Weaver weavelet process",
+		nil)
+	gcPauseSeconds = metrics.Register(protos.MetricType_GAUGE,
+		"serviceweaver_weavelet_gc_pause_seconds",
+		"Total time, in seconds, the Service Weaver weavelet process was paused for garbage collection",
+		nil)
+)
+
+// UpdateRuntimeMetrics updates the CPU, memory, goroutine, and garbage
+// collection metrics of the weavelet process. The weavelet updates them
+// whenever the envelope reads its metrics, and deployers that run the
+// weavelet in their own process, like weaver single, update them whenever
+// they read the process's metrics.
+func UpdateRuntimeMetrics() {
+	if cpu, err := cpuTime(); err == nil {
+		cpuSeconds.Set(cpu.Seconds())
+	}
+	if rss, err := rssBytes(); err == nil {
+		rssBytesGauge.Set(float64(rss))
+	}
+	var stats rt.MemStats
+	rt.ReadMemStats(&stats)
+	heapBytes.Set(float64(stats.HeapAlloc))
+	gcCount.Set(float64(stats.NumGC))
+	gcPauseSeconds.Set(time.Duration(stats.PauseTotalNs).Seconds())
+	goroutines.Set(float64(rt.NumGoroutine()))
+}
+
 // WeaveletHandler handles messages from the envelope. A handler should not
 // block and should not perform RPCs over the pipe. Values passed to the
 // handlers are only valid for the duration of the handler's execution.
@@ -44,6 +117,46 @@ type WeaveletHandler interface {
 
 	// UpdateRoutingInfo updates a component's routing information.
 	UpdateRoutingInfo(*protos.UpdateRoutingInfoRequest) (*protos.UpdateRoutingInfoReply, error)
+
+	// HandleTopicEvent delivers an event published by another weavelet to
+	// the weavelet's subscribers.
+	HandleTopicEvent(*protos.TopicEvent)
+
+	// DeliverMessage delivers a message published on a topic to the
+	// weavelet's handler of the message's subscription, returning once the
+	// handler has handled it. Like Drain, DeliverMessage blocks; it is run in
+	// its own goroutine.
+	DeliverMessage(*protos.DeliverMessageRequest) (*protos.DeliverMessageReply, error)
+
+	// RunJob runs a job registered with weaver.Cron, returning once the run
+	// has finished. Like Drain, RunJob blocks; it is run in its own
+	// goroutine.
+	RunJob(*protos.RunJobRequest) (*protos.RunJobReply, error)
+
+	// SetLogLevel changes the minimum level of the log entries produced by a
+	// component.
+	SetLogLevel(*protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error)
+
+	// InjectFaults replaces the faults injected into the weavelet's calls to
+	// remote components, for chaos testing.
+	InjectFaults(*protos.InjectFaultsRequest) (*protos.InjectFaultsReply, error)
+
+	// SetFlags replaces the feature flags of the weavelet's deployment.
+	SetFlags(*protos.SetFlagsRequest) (*protos.SetFlagsReply, error)
+
+	// SetConfig replaces the component config sections of the weavelet's
+	// deployment, and reconfigures the weavelet's components.
+	SetConfig(*protos.SetConfigRequest) (*protos.SetConfigReply, error)
+
+	// Drain drains the weavelet, returning once the weavelet is drained.
+	// Unlike the other methods, Drain blocks; it is run in its own goroutine.
+	Drain(*protos.DrainRequest) (*protos.DrainReply, error)
+
+	// Health returns the health of the weavelet, e.g., STARTING if the
+	// weavelet is still initializing its components, and why the weavelet is
+	// unhealthy, if it is. Draining is tracked by the WeaveletConn and need
+	// not be reported by Health.
+	Health() *protos.GetHealthReply
 }
 
 // WeaveletConn is the weavelet side of the connection between a weavelet and
@@ -53,8 +166,11 @@ type WeaveletConn struct {
 	handler WeaveletHandler
 	conn    conn
 	info    *protos.EnvelopeInfo
-	lis     net.Listener // internal network listener for the weavelet
+	lis     net.Listener   // internal network listener for the weavelet
+	pconn   net.PacketConn // internal packet connection, for the QUIC transport
 	metrics metrics.Exporter
+
+	draining atomic.Bool // has the envelope asked the weavelet to drain?
 }
 
 // NewWeaveletConn returns a connection to an envelope. The connection sends
@@ -86,13 +202,29 @@ func NewWeaveletConn(r io.ReadCloser, w io.WriteCloser, h WeaveletHandler) (*Wea
 	}
 
 	// Second, send WeaveletInfo.
-	lis, err := listen(d.info)
+	config, err := runtime.ParseWeaveletConfig(d.info.Sections)
 	if err != nil {
 		d.conn.cleanup(err)
 		return nil, err
 	}
-	d.lis = lis
-	dialAddr := fmt.Sprintf("tcp://%s", lis.Addr().String())
+	var dialAddr string
+	if config.Transport == "quic" {
+		pconn, err := listenPacket(d.info)
+		if err != nil {
+			d.conn.cleanup(err)
+			return nil, err
+		}
+		d.pconn = pconn
+		dialAddr = fmt.Sprintf("quic://%s", pconn.LocalAddr().String())
+	} else {
+		lis, err := listen(d.info, config.Transport)
+		if err != nil {
+			d.conn.cleanup(err)
+			return nil, err
+		}
+		d.lis = lis
+		dialAddr = fmt.Sprintf("%s://%s", lis.Addr().Network(), lis.Addr().String())
+	}
 	info := &protos.WeaveletInfo{
 		DialAddr: dialAddr,
 		Pid:      int64(os.Getpid()),
@@ -127,11 +259,18 @@ func (d *WeaveletConn) EnvelopeInfo() *protos.EnvelopeInfo {
 	return d.info
 }
 
-// Listener returns the internal network listener for the weavelet.
+// Listener returns the internal network listener for the weavelet, or nil if
+// the weavelet uses the QUIC transport.
 func (d *WeaveletConn) Listener() net.Listener {
 	return d.lis
 }
 
+// PacketConn returns the internal packet connection for the weavelet, on
+// which it serves QUIC, or nil if the weavelet uses the TCP transport.
+func (d *WeaveletConn) PacketConn() net.PacketConn {
+	return d.pconn
+}
+
 // handleMessage handles all RPC requests initiated by the envelope. Note that
 // this method doesn't handle RPC replies from the envelope.
 func (d *WeaveletConn) handleMessage(msg *protos.EnvelopeMsg) error {
@@ -144,6 +283,8 @@ func (d *WeaveletConn) handleMessage(msg *protos.EnvelopeMsg) error {
 
 	switch {
 	case msg.GetMetricsRequest != nil:
+		UpdateRuntimeMetrics()
+
 		// Inject Service Weaver specific labels.
 		update := d.metrics.Export()
 		for _, def := range update.Defs {
@@ -153,15 +294,25 @@ func (d *WeaveletConn) handleMessage(msg *protos.EnvelopeMsg) error {
 			def.Labels["serviceweaver_app"] = d.info.App
 			def.Labels["serviceweaver_version"] = d.info.DeploymentId
 			def.Labels["serviceweaver_node"] = d.info.Id
+			if d.info.Region != "" {
+				def.Labels["serviceweaver_region"] = d.info.Region
+			}
+			if d.info.Group != "" {
+				def.Labels["serviceweaver_group"] = d.info.Group
+			}
 		}
 		return d.conn.send(&protos.WeaveletMsg{
 			Id:              -msg.Id,
 			GetMetricsReply: &protos.GetMetricsReply{Update: update},
 		})
 	case msg.GetHealthRequest != nil:
+		reply := d.handler.Health()
+		if d.draining.Load() {
+			reply = &protos.GetHealthReply{Status: protos.HealthStatus_DRAINING}
+		}
 		return d.conn.send(&protos.WeaveletMsg{
 			Id:             -msg.Id,
-			GetHealthReply: &protos.GetHealthReply{Status: protos.HealthStatus_HEALTHY},
+			GetHealthReply: reply,
 		})
 	case msg.GetLoadRequest != nil:
 		reply, err := d.handler.GetLoad(msg.GetLoadRequest)
@@ -201,6 +352,79 @@ func (d *WeaveletConn) handleMessage(msg *protos.EnvelopeMsg) error {
 			Error:                  errstring(err),
 			UpdateRoutingInfoReply: reply,
 		})
+	case msg.TopicEvent != nil:
+		d.handler.HandleTopicEvent(msg.TopicEvent)
+		return nil
+	case msg.SetLogLevelRequest != nil:
+		reply, err := d.handler.SetLogLevel(msg.SetLogLevelRequest)
+		return d.conn.send(&protos.WeaveletMsg{
+			Id:               -msg.Id,
+			Error:            errstring(err),
+			SetLogLevelReply: reply,
+		})
+	case msg.InjectFaultsRequest != nil:
+		reply, err := d.handler.InjectFaults(msg.InjectFaultsRequest)
+		return d.conn.send(&protos.WeaveletMsg{
+			Id:                -msg.Id,
+			Error:             errstring(err),
+			InjectFaultsReply: reply,
+		})
+	case msg.SetFlagsRequest != nil:
+		reply, err := d.handler.SetFlags(msg.SetFlagsRequest)
+		return d.conn.send(&protos.WeaveletMsg{
+			Id:            -msg.Id,
+			Error:         errstring(err),
+			SetFlagsReply: reply,
+		})
+	case msg.SetConfigRequest != nil:
+		reply, err := d.handler.SetConfig(msg.SetConfigRequest)
+		return d.conn.send(&protos.WeaveletMsg{
+			Id:             -msg.Id,
+			Error:          errstring(err),
+			SetConfigReply: reply,
+		})
+	case msg.DrainRequest != nil:
+		// Draining takes a while, so, like profiling, we drain in a separate
+		// goroutine.
+		d.draining.Store(true)
+		id := msg.Id
+		req := protomsg.Clone(msg.DrainRequest)
+		go func() {
+			reply, err := d.handler.Drain(req)
+			//nolint:errcheck //errMsg will be returned on next send
+			d.conn.send(&protos.WeaveletMsg{
+				Id:         -id,
+				Error:      errstring(err),
+				DrainReply: reply,
+			})
+		}()
+		return nil
+	case msg.DeliverMessageRequest != nil:
+		id := msg.Id
+		req := protomsg.Clone(msg.DeliverMessageRequest)
+		go func() {
+			reply, err := d.handler.DeliverMessage(req)
+			//nolint:errcheck //errMsg will be returned on next send
+			d.conn.send(&protos.WeaveletMsg{
+				Id:                  -id,
+				Error:               errstring(err),
+				DeliverMessageReply: reply,
+			})
+		}()
+		return nil
+	case msg.RunJobRequest != nil:
+		id := msg.Id
+		req := protomsg.Clone(msg.RunJobRequest)
+		go func() {
+			reply, err := d.handler.RunJob(req)
+			//nolint:errcheck //errMsg will be returned on next send
+			d.conn.send(&protos.WeaveletMsg{
+				Id:          -id,
+				Error:       errstring(err),
+				RunJobReply: reply,
+			})
+		}()
+		return nil
 	default:
 		err := fmt.Errorf("weavelet_conn: unexpected message %+v", msg)
 		d.conn.cleanup(err)
@@ -247,6 +471,71 @@ func (d *WeaveletConn) ExportListenerRPC(req *protos.ExportListenerRequest) (*pr
 	return reply.ExportListenerReply, nil
 }
 
+// PublishMessageRPC publishes a message on a topic, returning once the
+// message has been stored by the deployer.
+func (d *WeaveletConn) PublishMessageRPC(req *protos.PublishMessageRequest) error {
+	reply, err := d.rpc(&protos.WeaveletMsg{PublishMessageRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.PublishMessageReply == nil {
+		return fmt.Errorf("nil PublishMessageReply received from envelope")
+	}
+	return nil
+}
+
+// SubscribeRPC subscribes the weavelet to a subscription to a topic.
+// Messages of the subscription are delivered to WeaveletHandler.DeliverMessage.
+func (d *WeaveletConn) SubscribeRPC(req *protos.SubscribeRequest) error {
+	reply, err := d.rpc(&protos.WeaveletMsg{SubscribeRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.SubscribeReply == nil {
+		return fmt.Errorf("nil SubscribeReply received from envelope")
+	}
+	return nil
+}
+
+// ScheduleJobRPC schedules a job registered with weaver.Cron. Runs of the job
+// are started with WeaveletHandler.RunJob.
+func (d *WeaveletConn) ScheduleJobRPC(req *protos.ScheduleJobRequest) error {
+	reply, err := d.rpc(&protos.WeaveletMsg{ScheduleJobRequest: req})
+	if err != nil {
+		return err
+	}
+	if reply.ScheduleJobReply == nil {
+		return fmt.Errorf("nil ScheduleJobReply received from envelope")
+	}
+	return nil
+}
+
+// GetCertificateRPC gets a new certificate that authenticates the weavelet to
+// the other weavelets in the deployment.
+func (d *WeaveletConn) GetCertificateRPC() (*protos.GetCertificateReply, error) {
+	reply, err := d.rpc(&protos.WeaveletMsg{GetCertificateRequest: &protos.GetCertificateRequest{}})
+	if err != nil {
+		return nil, err
+	}
+	if reply.GetCertificateReply == nil {
+		return nil, fmt.Errorf("nil GetCertificateReply received from envelope")
+	}
+	return reply.GetCertificateReply, nil
+}
+
+// LeaseRPC acquires, renews, or releases the lease of a leader election
+// created with weaver.NewLeaderElection.
+func (d *WeaveletConn) LeaseRPC(req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	reply, err := d.rpc(&protos.WeaveletMsg{LeaseRequest: req})
+	if err != nil {
+		return nil, err
+	}
+	if reply.LeaseReply == nil {
+		return nil, fmt.Errorf("nil LeaseReply received from envelope")
+	}
+	return reply.LeaseReply, nil
+}
+
 func (d *WeaveletConn) rpc(request *protos.WeaveletMsg) (*protos.EnvelopeMsg, error) {
 	response, err := d.conn.doBlockingRPC(request)
 	if err != nil {
@@ -269,12 +558,30 @@ func (d *WeaveletConn) SendLogEntry(entry *protos.LogEntry) error {
 	return d.conn.send(&protos.WeaveletMsg{LogEntry: entry})
 }
 
+// SendTopicEvent sends an event published by the weavelet to the envelope,
+// without waiting for a reply.
+func (d *WeaveletConn) SendTopicEvent(event *protos.TopicEvent) error {
+	return d.conn.send(&protos.WeaveletMsg{TopicEvent: event})
+}
+
 // SendTraceSpans sends a set of trace spans to the envelope, without waiting
 // for a reply.
 func (d *WeaveletConn) SendTraceSpans(spans *protos.TraceSpans) error {
 	return d.conn.send(&protos.WeaveletMsg{TraceSpans: spans})
 }
 
+// SendProfile sends a profile collected by the weavelet's continuous profiler
+// to the envelope, without waiting for a reply.
+func (d *WeaveletConn) SendProfile(profile *protos.Profile) error {
+	return d.conn.send(&protos.WeaveletMsg{Profile: profile})
+}
+
+// SendCrashReport sends the report of a panic that is about to crash the
+// weavelet to the envelope, without waiting for a reply.
+func (d *WeaveletConn) SendCrashReport(report *protos.CrashReport) error {
+	return d.conn.send(&protos.WeaveletMsg{CrashReport: report})
+}
+
 // Profile collects profiles for the weavelet.
 func Profile(req *protos.GetProfileRequest) ([]byte, error) {
 	var buf bytes.Buffer
@@ -288,19 +595,102 @@ func Profile(req *protos.GetProfileRequest) ([]byte, error) {
 			return nil, fmt.Errorf("invalid zero duration for the CPU profile collection")
 		}
 		dur := time.Duration(req.CpuDurationNs) * time.Nanosecond
+		cpuProfileMu.Lock()
+		defer cpuProfileMu.Unlock()
 		if err := pprof.StartCPUProfile(&buf); err != nil {
 			return nil, err
 		}
 		time.Sleep(dur)
 		pprof.StopCPUProfile()
+	case protos.ProfileType_Mutex:
+		if req.CpuDurationNs == 0 {
+			return nil, fmt.Errorf("invalid zero duration for the mutex profile collection")
+		}
+		return mutexProfile(time.Duration(req.CpuDurationNs) * time.Nanosecond)
 	default:
 		return nil, fmt.Errorf("unspecified profile collection type")
 	}
 	return buf.Bytes(), nil
 }
 
-func listen(info *protos.EnvelopeInfo) (net.Listener, error) {
-	// Pick a hostname to listen on.
+// mutexProfile returns a profile of the mutex contention in the process over
+// the provided duration. Mutex profiles are cumulative, so mutexProfile
+// returns the difference between the profiles at the start and at the end of
+// the duration. Sampling is enabled for the duration if it isn't already.
+func mutexProfile(dur time.Duration) ([]byte, error) {
+	mutexProfileMu.Lock()
+	defer mutexProfileMu.Unlock()
+	if prev := rt.SetMutexProfileFraction(-1); prev == 0 {
+		rt.SetMutexProfileFraction(mutexProfileFraction)
+		defer rt.SetMutexProfileFraction(0)
+	}
+
+	read := func() (*pprofile.Profile, error) {
+		var buf bytes.Buffer
+		if err := pprof.Lookup("mutex").WriteTo(&buf, 0); err != nil {
+			return nil, err
+		}
+		return pprofile.Parse(&buf)
+	}
+	start, err := read()
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(dur)
+	end, err := read()
+	if err != nil {
+		return nil, err
+	}
+	start.Scale(-1)
+	delta, err := pprofile.Merge([]*pprofile.Profile{start, end})
+	if err != nil {
+		return nil, err
+	}
+	delta.TimeNanos = end.TimeNanos
+	delta.DurationNanos = dur.Nanoseconds()
+
+	var buf bytes.Buffer
+	if err := delta.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// listen returns a listener for the weavelet. Unless the TCP transport is
+// requested explicitly, the weavelets of a single machine deployment listen on
+// Unix sockets, which are cheaper than TCP loopback connections, if they can.
+// Other weavelets listen on TCP.
+func listen(info *protos.EnvelopeInfo, transport string) (net.Listener, error) {
+	if info.SingleMachine && transport == "" {
+		if lis, err := listenUnix(socketDir(), info.DeploymentId, info.Id); err == nil {
+			return lis, nil
+		}
+		// Fall back to TCP if we can't listen on a Unix socket, e.g.,
+		// because the path of the socket is too long, or because another
+		// user owns the socket directory.
+	}
+
+	host, err := listenHost(info)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the listener
+	return net.Listen("tcp", fmt.Sprintf("%s:0", host))
+}
+
+// listenPacket returns a UDP packet connection for the weavelet, on which it
+// serves QUIC.
+func listenPacket(info *protos.EnvelopeInfo) (net.PacketConn, error) {
+	host, err := listenHost(info)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenPacket("udp", fmt.Sprintf("%s:0", host))
+}
+
+// listenHost returns the hostname the weavelet should listen on.
+func listenHost(info *protos.EnvelopeInfo) (string, error) {
 	host := "localhost"
 	if !info.SingleMachine {
 		// TODO(mwhittaker): Right now, we resolve our hostname to get a
@@ -308,10 +698,8 @@ func listen(info *protos.EnvelopeInfo) (net.Listener, error) {
 		var err error
 		host, err = os.Hostname()
 		if err != nil {
-			return nil, fmt.Errorf("error getting local hostname: %w", err)
+			return "", fmt.Errorf("error getting local hostname: %w", err)
 		}
 	}
-
-	// Create the listener
-	return net.Listen("tcp", fmt.Sprintf("%s:0", host))
+	return host, nil
 }
@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+	"golang.org/x/exp/slices"
+)
+
+// Command returns an "events" subcommand that prints the events recorded in
+// the provided directory. toolName is the name of the command-line tool the
+// returned subcommand runs as (e.g., "weaver multi").
+func Command(toolName string, dir string) *tool.Command {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	app := flags.String("app", "", "Only print the events of the provided app")
+	version := flags.String("version", "", "Only print the events of the provided deployment")
+	kinds := flags.String("kind", "", "Only print events of the provided comma-separated kinds")
+	since := flags.Duration("since", 0, "Only print events newer than the provided duration")
+	follow := flags.Bool("follow", false, "Act like tail -f")
+	format := flags.String("format", "pretty", "Output format (pretty or json)")
+
+	var names []string
+	for _, k := range Kinds {
+		names = append(names, string(k))
+	}
+	return &tool.Command{
+		Name:        "events",
+		Description: "Print the events of deployments",
+		Help: fmt.Sprintf(`Usage:
+  %s events [--app=<app>] [--version=<deployment>] [--kind=<kinds>] [--since=<duration>] [--follow] [--format=<format>]
+
+Flags:
+  -h, --help	Print this help message.
+%s
+
+Description:
+  "%s events" prints the events recorded by deployments, oldest first:
+  deployments starting and stopping, replicas starting, crashing, and
+  failing health checks, autoscaling, rollout steps, log level and flag
+  changes, and faults injected by chaos testing. Events outlive their
+  deployments, until purged.
+
+  With --format=json, every event is printed as a JSON object on a line of
+  its own, e.g., to feed "%s events --follow" to an alerting script.
+
+Kinds:
+  %s
+
+Examples:
+  # Print all the events.
+  %s events
+
+  # Print the replica crashes of the "todo" app in the last hour.
+  %s events --app=todo --kind=replica_failed --since=1h
+
+  # Follow the events of a deployment, as JSON.
+  %s events --version=2c80d811 --follow --format=json`,
+			toolName, tool.FlagsHelp(flags), toolName, toolName, strings.Join(names, ", "), toolName, toolName, toolName),
+		Flags: flags,
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("too many arguments")
+			}
+			if *format != "pretty" && *format != "json" {
+				return fmt.Errorf("invalid format %q; want \"pretty\" or \"json\"", *format)
+			}
+			q := Query{App: *app, Version: *version}
+			if *kinds != "" {
+				for _, k := range strings.Split(*kinds, ",") {
+					kind := Kind(strings.TrimSpace(k))
+					if !slices.Contains(Kinds, kind) {
+						return fmt.Errorf("unknown event kind %q", kind)
+					}
+					q.Kinds = append(q.Kinds, kind)
+				}
+			}
+			if *since > 0 {
+				q.Since = time.Now().Add(-*since)
+			}
+			emit := func(e Event) error { return printPretty(os.Stdout, e) }
+			if *format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				emit = func(e Event) error { return enc.Encode(e) }
+			}
+			if *follow {
+				return Follow(ctx, dir, q, emit)
+			}
+			events, err := Read(dir, q)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if err := emit(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// printPretty prints an event in a human readable format, e.g.,
+//
+//	2023-06-01T12:00:00.000Z todo/2c80d811 replica_failed  group=main replica=unix:///tmp/x.sock  Weavelet failed  err="exit status 2"
+func printPretty(w io.Writer, e Event) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s/%s %-19s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.App, logging.Shorten(e.DeploymentId), e.Kind)
+	if e.Group != "" {
+		fmt.Fprintf(&b, " group=%s", logging.ShortenComponent(e.Group))
+	}
+	if e.Replica != "" {
+		fmt.Fprintf(&b, " replica=%s", e.Replica)
+	}
+	fmt.Fprintf(&b, "  %s", e.Message)
+	keys := make([]string, 0, len(e.Attrs))
+	for k := range e.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, e.Attrs[k])
+	}
+	_, err := fmt.Fprintln(w, b.String())
+	return err
+}
@@ -0,0 +1,350 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events records the significant events in the life of a
+// deployment, e.g., the start of the deployment, the crash of a replica, or
+// a step of a rollout, in an audit log that outlives the deployment.
+//
+// The events of a deployment are stored in a file of their own, one JSON
+// encoded Event per line, so that they can be read, and followed, by other
+// tools. Deployers may also pass every event to hooks as it is recorded,
+// e.g., to alert on crashes.
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// Kind is the kind of an event.
+type Kind string
+
+const (
+	DeploymentStarted Kind = "deployment_started"  // a deployment started
+	DeploymentStopped Kind = "deployment_stopped"  // a deployment stopped
+	ReplicaStarted    Kind = "replica_started"     // a replica started
+	ReplicaFailed     Kind = "replica_failed"      // a replica crashed, or failed to start
+	ReplicaPanicked   Kind = "replica_panicked"    // a component method panicked, crashing a replica
+	ReplicaUnhealthy  Kind = "replica_unhealthy"   // a replica failed its health checks
+	ReplicaHealthy    Kind = "replica_healthy"     // an unhealthy replica became healthy again
+	Autoscaled        Kind = "autoscaled"          // the replicas of a group were rescaled
+	RolloutTraffic    Kind = "rollout_traffic"     // the traffic of a rollout changed
+	RolloutMirror     Kind = "rollout_mirror"      // the mirrored traffic of a rollout changed
+	RolloutPromoted   Kind = "rollout_promoted"    // a rollout was promoted
+	RolloutRolledBack Kind = "rollout_rolled_back" // a rollout was rolled back
+	ConfigChanged     Kind = "config_changed"      // log levels or flags changed
+	FaultInjected     Kind = "fault_injected"      // chaos testing injected a fault
+	FaultRemoved      Kind = "fault_removed"       // chaos testing removed a fault
+)
+
+const (
+	// fileExtension is the extension of the files that store events.
+	fileExtension = ".events"
+
+	// followPollInterval is how often Follow checks for new events.
+	followPollInterval = 500 * time.Millisecond
+
+	// webhookTimeout bounds the requests made by Webhook hooks.
+	webhookTimeout = 5 * time.Second
+)
+
+// Kinds holds all the kinds of events, in the order of the constants above.
+var Kinds = []Kind{
+	DeploymentStarted, DeploymentStopped,
+	ReplicaStarted, ReplicaFailed, ReplicaPanicked, ReplicaUnhealthy, ReplicaHealthy,
+	Autoscaled,
+	RolloutTraffic, RolloutMirror, RolloutPromoted, RolloutRolledBack,
+	ConfigChanged,
+	FaultInjected, FaultRemoved,
+}
+
+// An Event is a significant event in the life of a deployment.
+type Event struct {
+	Time         time.Time         `json:"time"`
+	App          string            `json:"app"`
+	DeploymentId string            `json:"deployment_id"`
+	Kind         Kind              `json:"kind"`
+	Group        string            `json:"group,omitempty"`   // co-location group, if any
+	Replica      string            `json:"replica,omitempty"` // replica address, if any
+	Message      string            `json:"message"`
+	Attrs        map[string]string `json:"attrs,omitempty"`
+}
+
+// A Log is the audit log of the events of a deployment. A nil *Log is valid,
+// and records nothing.
+type Log struct {
+	app          string
+	deploymentId string
+	logger       *slog.Logger
+	hooks        []func(Event)
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens the audit log of the provided deployment, stored in the
+// provided directory. Every recorded event is passed to the provided hooks,
+// which must not block.
+func Open(dir, app, deploymentId string, logger *slog.Logger, hooks ...func(Event)) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	fname := filepath.Join(dir, deploymentId+fileExtension)
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{app: app, deploymentId: deploymentId, logger: logger, hooks: hooks, f: f}, nil
+}
+
+// Record records an event of the provided kind. group and replica are the
+// co-location group and replica address the event is about, if any. attrs
+// are key/value pairs, e.g., "pid", "1234".
+func (l *Log) Record(kind Kind, group, replica, msg string, attrs ...string) {
+	if l == nil {
+		return
+	}
+	e := Event{
+		Time:         time.Now(),
+		App:          l.app,
+		DeploymentId: l.deploymentId,
+		Kind:         kind,
+		Group:        group,
+		Replica:      replica,
+		Message:      msg,
+	}
+	if len(attrs) > 0 {
+		e.Attrs = map[string]string{}
+		for i := 0; i+1 < len(attrs); i += 2 {
+			e.Attrs[attrs[i]] = attrs[i+1]
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		l.logger.Error("encode event", err, "kind", kind)
+		return
+	}
+
+	l.mu.Lock()
+	if l.f != nil {
+		if _, err := l.f.Write(append(line, '\n')); err != nil {
+			l.logger.Error("record event", err, "kind", kind)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, hook := range l.hooks {
+		hook(e)
+	}
+}
+
+// Close closes the log. Events recorded after Close are only passed to the
+// hooks.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Webhook returns a hook that POSTs every event, JSON encoded, to the
+// provided URL. Events are posted in the background; failures are logged.
+func Webhook(url string, logger *slog.Logger) func(Event) {
+	client := &http.Client{Timeout: webhookTimeout}
+	return func(e Event) {
+		body, err := json.Marshal(e)
+		if err != nil {
+			logger.Error("encode event", err, "kind", e.Kind)
+			return
+		}
+		go func() {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				logger.Error("events webhook", err, "kind", e.Kind)
+				return
+			}
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body) //nolint:errcheck // best effort
+			if resp.StatusCode/100 != 2 {
+				logger.Error("events webhook", fmt.Errorf("%s: %s", url, resp.Status), "kind", e.Kind)
+			}
+		}()
+	}
+}
+
+// A Query selects events. Empty fields match all events.
+type Query struct {
+	App     string    // app name
+	Version string    // deployment id, or a prefix of it
+	Kinds   []Kind    // kinds of events
+	Since   time.Time // earliest event time
+}
+
+// Matches returns whether the query matches the provided event.
+func (q Query) Matches(e Event) bool {
+	if q.App != "" && e.App != q.App {
+		return false
+	}
+	if q.Version != "" && !strings.HasPrefix(e.DeploymentId, q.Version) {
+		return false
+	}
+	if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, e.Kind) {
+		return false
+	}
+	return q.Since.IsZero() || !e.Time.Before(q.Since)
+}
+
+// Read returns the events in the provided directory that match the
+// provided query, oldest first.
+func Read(dir string, q Query) ([]Event, error) {
+	events, _, err := read(dir, q)
+	return events, err
+}
+
+// read returns the events in the provided directory that match the provided
+// query, oldest first, and the number of bytes read from every file.
+func read(dir string, q Query) ([]Event, map[string]int64, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileExtension))
+	if err != nil {
+		return nil, nil, err
+	}
+	var events []Event
+	offsets := map[string]int64{}
+	for _, fname := range files {
+		if q.Version != "" && !strings.HasPrefix(filepath.Base(fname), q.Version) {
+			continue
+		}
+		offset, err := followFile(fname, 0, q, func(e Event) error {
+			events = append(events, e)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		offsets[fname] = offset
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, offsets, nil
+}
+
+// Follow calls f on the events in the provided directory that match the
+// provided query, oldest first, and then on every matching event recorded
+// later, until ctx is canceled or f returns an error. Events recorded
+// later, by different deployments, may be passed to f out of order.
+func Follow(ctx context.Context, dir string, q Query, f func(Event) error) error {
+	events, offsets, err := read(dir, q)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := f(e); err != nil {
+			return err
+		}
+	}
+
+	// Poll for the events recorded after the ones read.
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*"+fileExtension))
+		if err != nil {
+			return err
+		}
+		for _, fname := range files {
+			if q.Version != "" && !strings.HasPrefix(filepath.Base(fname), q.Version) {
+				continue
+			}
+			offset, err := followFile(fname, offsets[fname], q, f)
+			if err != nil {
+				return err
+			}
+			offsets[fname] = offset
+		}
+	}
+}
+
+// followFile calls f on the matching events of the provided file that start
+// at or after the provided offset, and returns the offset following the last
+// complete event.
+func followFile(fname string, offset int64, q Query, f func(Event) error) (int64, error) {
+	file, err := os.Open(fname)
+	if errors.Is(err, os.ErrNotExist) {
+		// The file was purged.
+		return 0, nil
+	} else if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	n, err := readEvents(file, fname, q, f)
+	return offset + n, err
+}
+
+// readEvents calls f on the matching events read from r, and returns the
+// number of bytes of the complete events read. A trailing partial line,
+// being written, is ignored.
+func readEvents(r io.Reader, fname string, q Query, f func(Event) error) (int64, error) {
+	var n int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		n += int64(len(line))
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return n, fmt.Errorf("%s: invalid event: %w", fname, err)
+		}
+		if !q.Matches(e) {
+			continue
+		}
+		if err := f(e); err != nil {
+			return n, err
+		}
+	}
+}
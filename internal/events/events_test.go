@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr))
+}
+
+func TestRecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	todo, err := Open(dir, "todo", "1111", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat, err := Open(dir, "chat", "2222", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Record(DeploymentStarted, "", "", "Deployment started")
+	chat.Record(DeploymentStarted, "", "", "Deployment started")
+	todo.Record(ReplicaFailed, "main", "tcp://127.0.0.1:1234", "Weavelet failed", "err", "exit status 2")
+	for _, l := range []*Log{todo, chat} {
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		query Query
+		want  []Kind
+	}{
+		{"all", Query{}, []Kind{DeploymentStarted, DeploymentStarted, ReplicaFailed}},
+		{"app", Query{App: "todo"}, []Kind{DeploymentStarted, ReplicaFailed}},
+		{"version", Query{Version: "22"}, []Kind{DeploymentStarted}},
+		{"kind", Query{Kinds: []Kind{ReplicaFailed}}, []Kind{ReplicaFailed}},
+		{"since", Query{Since: time.Now().Add(time.Hour)}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := Read(dir, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []Kind
+			for _, e := range events {
+				got = append(got, e.Kind)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("Read: got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("Read: got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+
+	events, err := Read(dir, Query{Kinds: []Kind{ReplicaFailed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := events[0]
+	if e.App != "todo" || e.DeploymentId != "1111" || e.Group != "main" || e.Attrs["err"] != "exit status 2" {
+		t.Fatalf("Read: got %+v", e)
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var l *Log
+	l.Record(DeploymentStarted, "", "", "Deployment started")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, "todo", "1111", testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Record(DeploymentStarted, "", "", "Deployment started")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errDone := errors.New("done")
+	var got []Kind
+	err = Follow(ctx, dir, Query{}, func(e Event) error {
+		got = append(got, e.Kind)
+		if len(got) == 1 {
+			// Record an event once the existing ones are read.
+			l.Record(ReplicaStarted, "main", "", "Replica started")
+			return nil
+		}
+		return errDone
+	})
+	if !errors.Is(err, errDone) {
+		t.Fatalf("Follow: %v", err)
+	}
+	if len(got) != 2 || got[0] != DeploymentStarted || got[1] != ReplicaStarted {
+		t.Fatalf("Follow: got %v", got)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		received <- e
+	}))
+	defer server.Close()
+
+	l, err := Open(t.TempDir(), "todo", "1111", testLogger(), Webhook(server.URL, testLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Record(ReplicaUnhealthy, "main", "", "Replica unhealthy")
+	select {
+	case e := <-received:
+		if e.Kind != ReplicaUnhealthy || e.App != "todo" {
+			t.Fatalf("webhook: got %+v", e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook: no event received")
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grafana generates Grafana dashboards for the metrics of Service
+// Weaver applications, for "weaver metrics grafana".
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// App describes the components and the metrics of an application binary, as
+// written by the binary when run with runtime.DescribeMetricsKey set.
+type App struct {
+	Components []Component   `json:"components"`
+	Metrics    []metrics.Def `json:"metrics"`
+}
+
+// Component is a component of an application.
+type Component struct {
+	Name    string   `json:"name"`    // full component name
+	Methods []string `json:"methods"` // method names, sorted
+}
+
+// Options configure a dashboard.
+type Options struct {
+	Title string // dashboard title
+	UID   string // dashboard uid, or "" to let Grafana pick one
+}
+
+// The metrics maintained by Service Weaver for every component method call
+// and for every HTTP handler instrumented with weaver.InstrumentHandler.
+const (
+	methodCount   = "serviceweaver_remote_method_count"
+	methodErrors  = "serviceweaver_remote_method_error_count"
+	methodLatency = "serviceweaver_remote_method_latency_micros"
+	httpCount     = "serviceweaver_http_request_count"
+	httpErrors    = "serviceweaver_http_error_count"
+	httpLatency   = "serviceweaver_http_request_latency_micros"
+)
+
+// panelsPerRow is the number of panels laid out side by side.
+const panelsPerRow = 3
+
+// Dashboard returns a Grafana dashboard, as JSON, that shows the metrics of
+// the provided application, as scraped by Prometheus. The dashboard has:
+//
+//   - an overview row, with the calls, errors, and latency of every component;
+//   - a row for every component with methods, with the calls, errors, and
+//     latency of every method;
+//   - an HTTP row, with the requests, errors, and latency of every handler
+//     instrumented with weaver.InstrumentHandler; and
+//   - an application row, with a panel for every metric the application
+//     defines.
+//
+// The dashboard queries the Prometheus data source picked by its
+// "datasource" variable.
+func Dashboard(app App, opts Options) ([]byte, error) {
+	defs := map[string]metrics.Def{}
+	for _, def := range app.Metrics {
+		defs[def.Name] = def
+	}
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := defs[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	b := &builder{}
+	if has(methodCount, methodErrors, methodLatency) {
+		b.row("Overview")
+		b.panel("Calls", "Component method calls per second, by component", "reqps",
+			target{Expr: rate(methodCount, "", "component"), Legend: "{{component}}"})
+		b.panel("Errors", "Component method calls that failed per second, by component", "reqps",
+			target{Expr: rate(methodErrors, "", "component"), Legend: "{{component}}"})
+		b.panel("Latency", "Median and 99th percentile component method latency, by component", "µs",
+			quantiles(methodLatency, "", []string{"component"}, "{{component}}")...)
+
+		for _, c := range app.Components {
+			if len(c.Methods) == 0 {
+				continue
+			}
+			filter := fmt.Sprintf("component=%q", c.Name)
+			b.row(logging.ShortenComponent(c.Name))
+			b.panel("Calls", fmt.Sprintf("Calls per second to the methods of %s (%s), by method", c.Name, strings.Join(c.Methods, ", ")), "reqps",
+				target{Expr: rate(methodCount, filter, "method"), Legend: "{{method}}"})
+			b.panel("Errors", fmt.Sprintf("Failed calls per second to the methods of %s, by method", c.Name), "reqps",
+				target{Expr: rate(methodErrors, filter, "method"), Legend: "{{method}}"})
+			b.panel("Latency", fmt.Sprintf("Median and 99th percentile latency of the methods of %s, by method", c.Name), "µs",
+				quantiles(methodLatency, filter, []string{"method"}, "{{method}}")...)
+		}
+	}
+
+	if has(httpCount, httpErrors, httpLatency) {
+		b.row("HTTP")
+		b.panel("Requests", "HTTP requests per second, by handler label", "reqps",
+			target{Expr: rate(httpCount, "", "label"), Legend: "{{label}}"})
+		b.panel("Errors", "HTTP replies with a 4XX or 5XX status code per second, by handler label and status code", "reqps",
+			target{Expr: rate(httpErrors, "", "label", "code"), Legend: "{{label}} {{code}}"})
+		b.panel("Latency", "Median and 99th percentile HTTP request latency, by handler label", "µs",
+			quantiles(httpLatency, "", []string{"label"}, "{{label}}")...)
+	}
+
+	first := true
+	for _, def := range app.Metrics {
+		if strings.HasPrefix(def.Name, "serviceweaver_") {
+			continue
+		}
+		if first {
+			b.row("Application")
+			first = false
+		}
+		legend := def.Name
+		if len(def.Labels) > 0 {
+			parts := make([]string, len(def.Labels))
+			for i, label := range def.Labels {
+				parts[i] = "{{" + label + "}}"
+			}
+			legend = strings.Join(parts, " ")
+		}
+		switch def.Type {
+		case protos.MetricType_COUNTER:
+			b.panel(def.Name, def.Help, "short", target{Expr: rate(def.Name, "", def.Labels...), Legend: legend})
+		case protos.MetricType_GAUGE:
+			b.panel(def.Name, def.Help, "short", target{Expr: sum(def.Name, def.Labels...), Legend: legend})
+		case protos.MetricType_HISTOGRAM:
+			b.panel(def.Name, def.Help, "short", quantiles(def.Name, "", def.Labels, legend)...)
+		}
+	}
+
+	title := opts.Title
+	if title == "" {
+		title = "Service Weaver"
+	}
+	return json.MarshalIndent(dashboard{
+		Title:         title,
+		UID:           opts.UID,
+		Tags:          []string{"serviceweaver"},
+		SchemaVersion: 38,
+		Refresh:       "30s",
+		Time:          timeRange{From: "now-1h", To: "now"},
+		Templating: templating{List: []variable{{
+			Name:  "datasource",
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		}}},
+		Panels: b.panels,
+	}, "", "  ")
+}
+
+// sum returns a PromQL expression that sums the provided expression by the
+// provided labels.
+func sum(expr string, labels ...string) string {
+	if len(labels) == 0 {
+		return fmt.Sprintf("sum(%s)", expr)
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(labels, ", "), expr)
+}
+
+// rate returns a PromQL expression for the per-second rate of the provided
+// counter, restricted to the series matching the provided label filter, if
+// any, and summed by the provided labels.
+func rate(counter, filter string, labels ...string) string {
+	if filter != "" {
+		counter = fmt.Sprintf("%s{%s}", counter, filter)
+	}
+	return sum(fmt.Sprintf("rate(%s[$__rate_interval])", counter), labels...)
+}
+
+// quantiles returns the targets of the median and the 99th percentile of the
+// provided histogram, restricted to the series matching the provided label
+// filter, if any, by the provided labels.
+func quantiles(histogram, filter string, labels []string, legend string) []target {
+	by := append([]string{"le"}, labels...)
+	bucket := rate(histogram+"_bucket", filter, by...)
+	return []target{
+		{Expr: fmt.Sprintf("histogram_quantile(0.5, %s)", bucket), Legend: legend + " p50"},
+		{Expr: fmt.Sprintf("histogram_quantile(0.99, %s)", bucket), Legend: legend + " p99"},
+	}
+}
+
+// builder lays out the panels of a dashboard, in rows of panelsPerRow panels.
+type builder struct {
+	panels []*panel
+	id     int // id of the last panel
+	x, y   int // position of the next panel
+}
+
+// row starts a new row with the provided title.
+func (b *builder) row(title string) {
+	if b.x > 0 {
+		b.x, b.y = 0, b.y+panelHeight
+	}
+	b.id++
+	b.panels = append(b.panels, &panel{
+		ID:      b.id,
+		Type:    "row",
+		Title:   title,
+		GridPos: gridPos{H: 1, W: gridWidth, X: 0, Y: b.y},
+	})
+	b.y++
+}
+
+// panel adds a time series panel with the provided title, description, unit,
+// and targets to the current row.
+func (b *builder) panel(title, description, unit string, targets ...target) {
+	b.id++
+	ds := &datasource{Type: "prometheus", UID: "${datasource}"}
+	p := &panel{
+		ID:          b.id,
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		GridPos:     gridPos{H: panelHeight, W: gridWidth / panelsPerRow, X: b.x, Y: b.y},
+		Datasource:  ds,
+		FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: unit}},
+	}
+	for i, t := range targets {
+		t.RefID = string(rune('A' + i))
+		t.Datasource = ds
+		p.Targets = append(p.Targets, t)
+	}
+	b.panels = append(b.panels, p)
+	b.x += gridWidth / panelsPerRow
+	if b.x >= gridWidth {
+		b.x, b.y = 0, b.y+panelHeight
+	}
+}
+
+// The width of a dashboard and the height of a panel, in grid units.
+const (
+	gridWidth   = 24
+	panelHeight = 8
+)
+
+// The types below are the subset of the Grafana dashboard JSON model [1] used
+// by Dashboard.
+//
+// [1]: https://grafana.com/docs/grafana/latest/dashboards/build-dashboards/view-dashboard-json-model/
+
+type dashboard struct {
+	Title         string     `json:"title"`
+	UID           string     `json:"uid,omitempty"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []*panel   `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	GridPos     gridPos      `json:"gridPos"`
+	Datasource  *datasource  `json:"datasource,omitempty"`
+	FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+	Targets     []target     `json:"targets,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type target struct {
+	RefID      string      `json:"refId"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	Expr       string      `json:"expr"`
+	Legend     string      `json:"legendFormat"`
+}
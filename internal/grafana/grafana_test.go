@@ -0,0 +1,140 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grafana
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDashboard(t *testing.T) {
+	counter := protos.MetricType_COUNTER
+	gauge := protos.MetricType_GAUGE
+	histogram := protos.MetricType_HISTOGRAM
+	app := App{
+		Components: []Component{
+			{Name: "github.com/ServiceWeaver/weaver/Main", Methods: []string{}},
+			{Name: "github.com/my/app/cart/T", Methods: []string{"AddItem", "GetCart"}},
+		},
+		Metrics: []metrics.Def{
+			{Type: counter, Name: "cart_items_added", Help: "Items added", Labels: []string{"currency"}},
+			{Type: gauge, Name: "cart_size"},
+			{Type: histogram, Name: "cart_value", Labels: []string{"currency", "region"}},
+			{Type: counter, Name: httpCount},
+			{Type: counter, Name: httpErrors},
+			{Type: histogram, Name: httpLatency},
+			{Type: counter, Name: methodCount},
+			{Type: counter, Name: methodErrors},
+			{Type: histogram, Name: methodLatency},
+		},
+	}
+	data, err := Dashboard(app, Options{Title: "cart", UID: "cart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got dashboard
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "cart" || got.UID != "cart" {
+		t.Errorf("title, uid: got %q, %q, want %q, %q", got.Title, got.UID, "cart", "cart")
+	}
+
+	// Check the panels and their layout.
+	type summary struct {
+		Type, Title string
+		X, Y        int
+		Exprs       []string
+	}
+	var panels []summary
+	for _, p := range got.Panels {
+		s := summary{Type: p.Type, Title: p.Title, X: p.GridPos.X, Y: p.GridPos.Y}
+		for _, target := range p.Targets {
+			s.Exprs = append(s.Exprs, target.Expr)
+		}
+		panels = append(panels, s)
+	}
+	cart := `component="github.com/my/app/cart/T"`
+	want := []summary{
+		{"row", "Overview", 0, 0, nil},
+		{"timeseries", "Calls", 0, 1, []string{"sum by (component) (rate(serviceweaver_remote_method_count[$__rate_interval]))"}},
+		{"timeseries", "Errors", 8, 1, []string{"sum by (component) (rate(serviceweaver_remote_method_error_count[$__rate_interval]))"}},
+		{"timeseries", "Latency", 16, 1, []string{
+			"histogram_quantile(0.5, sum by (le, component) (rate(serviceweaver_remote_method_latency_micros_bucket[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, component) (rate(serviceweaver_remote_method_latency_micros_bucket[$__rate_interval])))",
+		}},
+		{"row", "cart.T", 0, 9, nil},
+		{"timeseries", "Calls", 0, 10, []string{"sum by (method) (rate(serviceweaver_remote_method_count{" + cart + "}[$__rate_interval]))"}},
+		{"timeseries", "Errors", 8, 10, []string{"sum by (method) (rate(serviceweaver_remote_method_error_count{" + cart + "}[$__rate_interval]))"}},
+		{"timeseries", "Latency", 16, 10, []string{
+			"histogram_quantile(0.5, sum by (le, method) (rate(serviceweaver_remote_method_latency_micros_bucket{" + cart + "}[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, method) (rate(serviceweaver_remote_method_latency_micros_bucket{" + cart + "}[$__rate_interval])))",
+		}},
+		{"row", "HTTP", 0, 18, nil},
+		{"timeseries", "Requests", 0, 19, []string{"sum by (label) (rate(serviceweaver_http_request_count[$__rate_interval]))"}},
+		{"timeseries", "Errors", 8, 19, []string{"sum by (label, code) (rate(serviceweaver_http_error_count[$__rate_interval]))"}},
+		{"timeseries", "Latency", 16, 19, []string{
+			"histogram_quantile(0.5, sum by (le, label) (rate(serviceweaver_http_request_latency_micros_bucket[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, label) (rate(serviceweaver_http_request_latency_micros_bucket[$__rate_interval])))",
+		}},
+		{"row", "Application", 0, 27, nil},
+		{"timeseries", "cart_items_added", 0, 28, []string{"sum by (currency) (rate(cart_items_added[$__rate_interval]))"}},
+		{"timeseries", "cart_size", 8, 28, []string{"sum(cart_size)"}},
+		{"timeseries", "cart_value", 16, 28, []string{
+			"histogram_quantile(0.5, sum by (le, currency, region) (rate(cart_value_bucket[$__rate_interval])))",
+			"histogram_quantile(0.99, sum by (le, currency, region) (rate(cart_value_bucket[$__rate_interval])))",
+		}},
+	}
+	if diff := cmp.Diff(want, panels); diff != "" {
+		t.Fatalf("panels (-want +got):\n%s", diff)
+	}
+
+	// Panel ids are unique, and targets have distinct ref ids.
+	ids := map[int]bool{}
+	for _, p := range got.Panels {
+		if ids[p.ID] {
+			t.Errorf("duplicate panel id %d", p.ID)
+		}
+		ids[p.ID] = true
+		refs := map[string]bool{}
+		for _, target := range p.Targets {
+			if refs[target.RefID] {
+				t.Errorf("panel %d: duplicate ref id %q", p.ID, target.RefID)
+			}
+			refs[target.RefID] = true
+		}
+	}
+}
+
+func TestDashboardWithoutMetrics(t *testing.T) {
+	data, err := Dashboard(App{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got dashboard
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Service Weaver" {
+		t.Errorf("title: got %q, want %q", got.Title, "Service Weaver")
+	}
+	if len(got.Panels) != 0 {
+		t.Errorf("panels: got %d, want 0", len(got.Panels))
+	}
+}
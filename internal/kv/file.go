@@ -0,0 +1,115 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// file is a Backend that keeps its values in a SQLite database file. Many
+// stores, and many processes on the same machine, may share the same file.
+type file struct {
+	name string // store name
+	db   *sql.DB
+}
+
+var _ Backend = &file{}
+
+// openFile opens the backend of the named store in the provided database
+// file, creating the file if needed.
+func openFile(ctx context.Context, name, path string) (*file, error) {
+	// The database may be opened by multiple processes. See:
+	//   https://www.sqlite.org/pragma.html#pragma_locking_mode
+	//   https://www.sqlite.org/pragma.html#pragma_busy_timeout
+	const params = "?_locking_mode=NORMAL&_busy_timeout=10000"
+	db, err := sql.Open("sqlite", path+params)
+	if err != nil {
+		return nil, fmt.Errorf("store %q: open %q: %w", name, path, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	const initTable = `
+CREATE TABLE IF NOT EXISTS stores (
+	store TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY(store, key)
+);`
+	if _, err := db.ExecContext(ctx, initTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store %q: create table in %q: %w", name, path, err)
+	}
+	return &file{name: name, db: db}, nil
+}
+
+// Get implements the Backend interface.
+func (f *file) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	const query = `SELECT value FROM stores WHERE store=? AND key=?;`
+	var value []byte
+	err := f.db.QueryRowContext(ctx, query, f.name, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put implements the Backend interface.
+func (f *file) Put(ctx context.Context, key string, value []byte) error {
+	const query = `INSERT OR REPLACE INTO stores(store, key, value) VALUES (?, ?, ?);`
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := f.db.ExecContext(ctx, query, f.name, key, value)
+	return err
+}
+
+// Delete implements the Backend interface.
+func (f *file) Delete(ctx context.Context, key string) error {
+	const query = `DELETE FROM stores WHERE store=? AND key=?;`
+	_, err := f.db.ExecContext(ctx, query, f.name, key)
+	return err
+}
+
+// Keys implements the Backend interface.
+func (f *file) Keys(ctx context.Context) ([]string, error) {
+	const query = `SELECT key FROM stores WHERE store=?;`
+	rows, err := f.db.QueryContext(ctx, query, f.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Close implements the Backend interface.
+func (f *file) Close() error {
+	return f.db.Close()
+}
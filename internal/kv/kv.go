@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kv implements the backends of the stores created with
+// weaver.NewStore. A backend maps string keys to byte values; weaver.Store
+// encodes typed keys and values on top of it.
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// A Backend holds the keys and values of a single store. It is safe for
+// concurrent use.
+type Backend interface {
+	// Get returns the value of the provided key, and whether the key is
+	// present.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Put sets the value of the provided key.
+	Put(ctx context.Context, key string, value []byte) error
+
+	// Delete removes the provided key, if present.
+	Delete(ctx context.Context, key string) error
+
+	// Keys returns the keys of the store, in no particular order.
+	Keys(ctx context.Context) ([]string, error)
+
+	// Close releases the resources of the backend.
+	Close() error
+}
+
+// Open opens the backend of the named store, following the store's config.
+// Stores with different names are independent, even if they share a file or
+// a Redis server.
+func Open(ctx context.Context, name string, config runtime.StoreConfig) (Backend, error) {
+	switch config.Backend {
+	case "", "memory":
+		return newMemory(), nil
+	case "file":
+		return openFile(ctx, name, config.Path)
+	case "redis":
+		return newRedis(name, config), nil
+	default:
+		return nil, fmt.Errorf("store %q: unknown backend %q", name, config.Backend)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slices"
+)
+
+func TestBackends(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name   string
+		config func(t *testing.T) runtime.StoreConfig
+	}{
+		{"memory", func(*testing.T) runtime.StoreConfig {
+			return runtime.StoreConfig{}
+		}},
+		{"file", func(t *testing.T) runtime.StoreConfig {
+			return runtime.StoreConfig{Backend: "file", Path: filepath.Join(t.TempDir(), "kv.db")}
+		}},
+		{"redis", func(t *testing.T) runtime.StoreConfig {
+			return runtime.StoreConfig{Backend: "redis", Address: fakeRedis(t, "secret"), Password: "secret", DB: 1}
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config := test.config(t)
+			a, err := Open(ctx, "a", config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+			b, err := Open(ctx, "b", config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			get := func(backend Backend, key string) string {
+				t.Helper()
+				value, ok, err := backend.Get(ctx, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					return "<missing>"
+				}
+				return string(value)
+			}
+			if got, want := get(a, "k"), "<missing>"; got != want {
+				t.Fatalf("Get(k): got %q, want %q", got, want)
+			}
+			if err := a.Put(ctx, "k", []byte("v1")); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Put(ctx, "k", []byte("v2")); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Put(ctx, "empty", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := get(a, "k"), "v2"; got != want {
+				t.Fatalf("Get(k): got %q, want %q", got, want)
+			}
+			if got, want := get(a, "empty"), ""; got != want {
+				t.Fatalf("Get(empty): got %q, want %q", got, want)
+			}
+			keys := func(backend Backend) []string {
+				t.Helper()
+				keys, err := backend.Keys(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(keys)
+				return keys
+			}
+			if got, want := keys(a), []string{"empty", "k"}; !slices.Equal(got, want) {
+				t.Fatalf("Keys: got %q, want %q", got, want)
+			}
+			if test.name != "memory" {
+				// Stores that share a backend are independent.
+				if got, want := get(b, "k"), "<missing>"; got != want {
+					t.Fatalf("Get(k) in another store: got %q, want %q", got, want)
+				}
+				if got := keys(b); len(got) != 0 {
+					t.Fatalf("Keys in another store: got %q, want none", got)
+				}
+			}
+			if err := a.Delete(ctx, "k"); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := get(a, "k"), "<missing>"; got != want {
+				t.Fatalf("Get(k) after Delete: got %q, want %q", got, want)
+			}
+			if got, want := keys(a), []string{"empty"}; !slices.Equal(got, want) {
+				t.Fatalf("Keys after Delete: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFileSharedAcrossOpens(t *testing.T) {
+	ctx := context.Background()
+	config := runtime.StoreConfig{Backend: "file", Path: filepath.Join(t.TempDir(), "kv.db")}
+	a, err := Open(ctx, "carts", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Put(ctx, "alice", []byte("cart")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(ctx, "carts", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	value, ok, err := b.Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || string(value) != "cart" {
+		t.Fatalf("Get(alice): got (%q, %v), want (\"cart\", true)", value, ok)
+	}
+}
+
+// fakeRedis starts a fake Redis server that supports the commands used by
+// the redis backend and returns its address.
+func fakeRedis(t *testing.T, password string) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	var mu sync.Mutex
+	dbs := map[string]map[string]string{} // by database number
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		authed := password == ""
+		db := "0"
+		for {
+			args, err := readCommand(r)
+			if err != nil {
+				return
+			}
+			reply := func() string {
+				mu.Lock()
+				defer mu.Unlock()
+				if dbs[db] == nil {
+					dbs[db] = map[string]string{}
+				}
+				switch cmd := strings.ToUpper(args[0]); {
+				case cmd == "AUTH":
+					if args[1] != password {
+						return "-WRONGPASS invalid password\r\n"
+					}
+					authed = true
+					return "+OK\r\n"
+				case !authed:
+					return "-NOAUTH Authentication required.\r\n"
+				case cmd == "SELECT":
+					db = args[1]
+					return "+OK\r\n"
+				case cmd == "GET":
+					value, ok := dbs[db][args[1]]
+					if !ok {
+						return "$-1\r\n"
+					}
+					return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+				case cmd == "SET":
+					dbs[db][args[1]] = args[2]
+					return "+OK\r\n"
+				case cmd == "DEL":
+					_, ok := dbs[db][args[1]]
+					delete(dbs[db], args[1])
+					if ok {
+						return ":1\r\n"
+					}
+					return ":0\r\n"
+				case cmd == "SCAN":
+					// Return every matching key at once. Patterns are
+					// matched like paths, which is close enough for the
+					// patterns used by the redis backend.
+					var keys []string
+					for key := range dbs[db] {
+						if ok, _ := path.Match(args[3], key); ok {
+							keys = append(keys, fmt.Sprintf("$%d\r\n%s\r\n", len(key), key))
+						}
+					}
+					return fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n%s", len(keys), strings.Join(keys, ""))
+				default:
+					return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+				}
+			}()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// readCommand reads a command encoded by encodeCommand.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		arg, err := readReply(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = string(arg)
+	}
+	return args, nil
+}
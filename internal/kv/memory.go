@@ -0,0 +1,73 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"context"
+	"sync"
+)
+
+// memory is a Backend that keeps its values in memory.
+type memory struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+var _ Backend = &memory{}
+
+func newMemory() *memory {
+	return &memory{values: map[string][]byte{}}
+}
+
+// Get implements the Backend interface.
+func (m *memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok, nil
+}
+
+// Put implements the Backend interface.
+func (m *memory) Put(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Copy the value, so that the caller may reuse it.
+	m.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete implements the Backend interface.
+func (m *memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+// Keys implements the Backend interface.
+func (m *memory) Keys(context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Close implements the Backend interface.
+func (m *memory) Close() error {
+	return nil
+}
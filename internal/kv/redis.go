@@ -0,0 +1,337 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// redis is a Backend that keeps its values in a Redis server, under keys
+// prefixed with the store name. It speaks the Redis serialization protocol
+// (RESP) over a single connection, which is established lazily and
+// reestablished after an error.
+type redis struct {
+	prefix string // "<store name>:"
+	config runtime.StoreConfig
+
+	mu     sync.Mutex    // serializes commands; guards conn and reader
+	conn   net.Conn      // connection to the server, or nil
+	reader *bufio.Reader // reads from conn
+}
+
+var _ Backend = &redis{}
+
+// errRedisNil is the reply to a GET of a missing key.
+var errRedisNil = errors.New("redis: nil")
+
+// dialTimeout bounds how long connecting to the server takes.
+const dialTimeout = 10 * time.Second
+
+func newRedis(name string, config runtime.StoreConfig) *redis {
+	return &redis{prefix: name + ":", config: config}
+}
+
+// Get implements the Backend interface.
+func (r *redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.do(ctx, "GET", r.prefix+key)
+	if errors.Is(err, errRedisNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put implements the Backend interface.
+func (r *redis) Put(ctx context.Context, key string, value []byte) error {
+	_, err := r.do(ctx, "SET", r.prefix+key, string(value))
+	return err
+}
+
+// Delete implements the Backend interface.
+func (r *redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", r.prefix+key)
+	return err
+}
+
+// Keys implements the Backend interface.
+func (r *redis) Keys(ctx context.Context) ([]string, error) {
+	// SCAN may return a key more than once.
+	seen := map[string]bool{}
+	var keys []string
+	pattern := escapeGlob(r.prefix) + "*"
+	cursor := "0"
+	for {
+		var batch []string
+		err := r.exec(ctx, []string{"SCAN", cursor, "MATCH", pattern, "COUNT", "1000"}, func(reader *bufio.Reader) error {
+			var err error
+			cursor, batch, err = readScanReply(reader)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range batch {
+			key = strings.TrimPrefix(key, r.prefix)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// Close implements the Backend interface.
+func (r *redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// do runs a command and returns its reply. The replies of the commands run
+// with do are either strings, integers, or nil; integers are returned in
+// their decimal form.
+func (r *redis) do(ctx context.Context, args ...string) ([]byte, error) {
+	var reply []byte
+	err := r.exec(ctx, args, func(reader *bufio.Reader) error {
+		var err error
+		reply, err = readReply(reader)
+		return err
+	})
+	return reply, err
+}
+
+// exec runs a command and reads its reply with read.
+func (r *redis) exec(ctx context.Context, args []string, read func(*bufio.Reader) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return err
+		}
+	}
+	err := r.roundTrip(ctx, args, read)
+	var replyErr redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state.
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// connect connects to the server, authenticates, and selects the database.
+//
+// REQUIRES: r.mu is held.
+func (r *redis) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.config.Address)
+	if err != nil {
+		return fmt.Errorf("redis: dial %s: %w", r.config.Address, err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+	var setup [][]string
+	if r.config.Password != "" {
+		setup = append(setup, []string{"AUTH", r.config.Password})
+	}
+	if r.config.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.config.DB)})
+	}
+	for _, args := range setup {
+		read := func(reader *bufio.Reader) error {
+			_, err := readReply(reader)
+			return err
+		}
+		if err := r.roundTrip(ctx, args, read); err != nil {
+			conn.Close()
+			r.conn = nil
+			return fmt.Errorf("redis: %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// roundTrip sends a command and reads its reply with read.
+//
+// REQUIRES: r.mu is held and r.conn is not nil.
+func (r *redis) roundTrip(ctx context.Context, args []string, read func(*bufio.Reader) error) error {
+	deadline, _ := ctx.Deadline() // zero if there is no deadline
+	if err := r.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Unblock the connection if ctx is cancelled. Wait for the goroutine to
+	// exit, so that it doesn't interfere with the next command.
+	conn := r.conn
+	done, exited := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(done)
+		<-exited
+	}()
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0)) //nolint:errcheck // best effort
+		case <-done:
+		}
+	}()
+
+	if _, err := conn.Write(encodeCommand(args)); err != nil {
+		return err
+	}
+	return read(r.reader)
+}
+
+// encodeCommand encodes a command as a RESP array of bulk strings.
+func encodeCommand(args []string) []byte {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// escapeGlob escapes the characters of s that are special in the patterns
+// of the SCAN command.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// redisError is an error reply.
+type redisError string
+
+// Error implements the error interface.
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply reads a reply that isn't an array.
+func readReply(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+', ':':
+		return []byte(line), nil
+	case '-':
+		return nil, redisError(line)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk string length %q", line)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", kind)
+	}
+}
+
+// readScanReply reads the reply of a SCAN command: the next cursor, and an
+// array of keys.
+func readScanReply(r *bufio.Reader) (string, []string, error) {
+	n, err := readArrayLen(r)
+	if err != nil {
+		return "", nil, err
+	}
+	if n != 2 {
+		return "", nil, fmt.Errorf("redis: got SCAN reply of length %d, want 2", n)
+	}
+	cursor, err := readReply(r)
+	if err != nil {
+		return "", nil, err
+	}
+	n, err = readArrayLen(r)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		key, err := readReply(r)
+		if err != nil {
+			return "", nil, err
+		}
+		keys[i] = string(key)
+	}
+	return string(cursor), keys, nil
+}
+
+// readArrayLen reads the header of an array reply, and returns the length of
+// the array.
+func readArrayLen(r *bufio.Reader) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return 0, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("redis: malformed array length %q", line)
+		}
+		return n, nil
+	case '-':
+		return 0, redisError(line)
+	default:
+		return 0, fmt.Errorf("redis: unexpected reply %q", kind)
+	}
+}
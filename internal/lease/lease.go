@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lease implements the leases that deployers grant to weavelets for
+// leader elections created with weaver.NewLeaderElection.
+package lease
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// A Table holds the leases of the leader elections in a deployment. The lease
+// of an election is held by at most one weavelet at a time. A weavelet
+// acquires a lease if no other weavelet holds it, and holds it until the
+// lease expires or is released. A weavelet renews a lease it holds by
+// acquiring it again.
+//
+// A weavelet considers itself the holder of a lease from the time it sends an
+// acquire request until the lease duration has elapsed. Because a Table
+// computes the expiration time of a lease from the time it receives the
+// request, the Table considers the lease held for at least as long as the
+// weavelet does, assuming clocks advance at the same rate.
+type Table struct {
+	mu     sync.Mutex
+	leases map[string]lease // by election name
+}
+
+// lease is a lease held by a weavelet.
+type lease struct {
+	holder  string    // id of the holding weavelet
+	expires time.Time // when the lease expires
+}
+
+// NewTable returns a new, empty Table.
+func NewTable() *Table {
+	return &Table{leases: map[string]lease{}}
+}
+
+// Handle handles a request to acquire or release a lease, received at the
+// provided time.
+func (t *Table) Handle(now time.Time, req *protos.LeaseRequest) *protos.LeaseReply {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, held := t.leases[req.Election]
+	if held && now.After(l.expires) {
+		delete(t.leases, req.Election)
+		held = false
+	}
+	if req.Release {
+		if held && l.holder == req.Holder {
+			delete(t.leases, req.Election)
+		}
+		return &protos.LeaseReply{}
+	}
+	if held && l.holder != req.Holder {
+		return &protos.LeaseReply{}
+	}
+	t.leases[req.Election] = lease{
+		holder:  req.Holder,
+		expires: now.Add(time.Duration(req.DurationMicros) * time.Microsecond),
+	}
+	return &protos.LeaseReply{Acquired: true}
+}
+
+// ReleaseAll releases every lease held by the provided weavelet, e.g.,
+// because the weavelet has exited. This lets another weavelet acquire the
+// leases without waiting for them to expire.
+func (t *Table) ReleaseAll(holder string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for election, l := range t.leases {
+		if l.holder == holder {
+			delete(t.leases, election)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestTable(t *testing.T) {
+	table := NewTable()
+	start := time.Now()
+	duration := int64(10 * time.Second / time.Microsecond)
+	for _, step := range []struct {
+		elapsed time.Duration
+		holder  string
+		release bool
+		want    bool
+	}{
+		{0, "a", false, true},                 // a acquires the lease
+		{time.Second, "b", false, false},      // a holds the lease
+		{5 * time.Second, "a", false, true},   // a renews the lease
+		{12 * time.Second, "b", false, false}, // a's renewed lease hasn't expired
+		{16 * time.Second, "b", false, true},  // a's lease has expired
+		{17 * time.Second, "a", true, false},  // a doesn't hold the lease
+		{18 * time.Second, "a", false, false}, // so b still does
+		{19 * time.Second, "b", true, false},  // b releases the lease
+		{20 * time.Second, "a", false, true},  // so a can acquire it
+	} {
+		req := &protos.LeaseRequest{
+			Election:       "leader",
+			Holder:         step.holder,
+			DurationMicros: duration,
+			Release:        step.release,
+		}
+		reply := table.Handle(start.Add(step.elapsed), req)
+		if reply.Acquired != step.want {
+			t.Fatalf("after %v, %s (release=%t): got acquired %t, want %t", step.elapsed, step.holder, step.release, reply.Acquired, step.want)
+		}
+	}
+}
+
+func TestReleaseAll(t *testing.T) {
+	table := NewTable()
+	now := time.Now()
+	acquire := func(election, holder string) bool {
+		req := &protos.LeaseRequest{Election: election, Holder: holder, DurationMicros: 1e9}
+		return table.Handle(now, req).Acquired
+	}
+	if !acquire("x", "a") || !acquire("y", "a") || !acquire("z", "b") {
+		t.Fatal("initial acquires failed")
+	}
+	table.ReleaseAll("a")
+	if !acquire("x", "c") || !acquire("y", "c") {
+		t.Error("leases of a not released")
+	}
+	if acquire("z", "c") {
+		t.Error("lease of b released")
+	}
+}
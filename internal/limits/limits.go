@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package limits limits the CPU and memory used by a group of processes, like
+// the weavelets of a co-location group.
+//
+// On Linux, the limits are enforced using a cgroup v2 per group, if the
+// current process runs in a cgroup v2 hierarchy that it can delegate the cpu
+// and memory controllers from. Otherwise, the memory used by every process is
+// limited using RLIMIT_DATA. On other platforms, the limits are only enforced
+// by the Go runtime of the processes, through the environment returned by Env.
+// Once every group is closed, Shutdown removes the cgroups shared by the
+// groups.
+package limits
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+// How the limits of a group are enforced.
+const (
+	Cgroups   = "cgroups"
+	Rlimits   = "rlimits"
+	GoRuntime = "go runtime"
+)
+
+// A Group limits the resources used by every process added to it.
+type Group struct {
+	limits      runtime.ResourceLimits
+	enforcement string // Cgroups, Rlimits, or GoRuntime
+	dir         string // the group's cgroup, if enforcement is Cgroups
+}
+
+// Stats holds the number of times the processes of a group hit their limits.
+// Stats are only collected for groups enforced using cgroups.
+type Stats struct {
+	CPUThrottled  int64 // scheduler periods in which the processes were throttled
+	MemoryMaxHits int64 // times the processes hit the memory limit
+	OOMKills      int64 // processes killed for exceeding the memory limit
+}
+
+// NewGroup returns a new group, with the provided name, that enforces the
+// provided limits. name must be unique across the groups of the current
+// process.
+func NewGroup(name string, limits runtime.ResourceLimits) (*Group, error) {
+	g := &Group{limits: limits}
+	if err := g.init(name); err != nil {
+		return nil, fmt.Errorf("limits for %q: %w", name, err)
+	}
+	return g, nil
+}
+
+// Limits returns the limits of the group.
+func (g *Group) Limits() runtime.ResourceLimits {
+	return g.limits
+}
+
+// Enforcement returns how the limits of the group are enforced: Cgroups,
+// Rlimits, or GoRuntime.
+func (g *Group) Enforcement() string {
+	return g.enforcement
+}
+
+// Env returns the environment variables that make the Go runtime of a process
+// respect the provided limits: GOMAXPROCS bounds the number of threads that
+// run Go code at a time, and GOMEMLIMIT makes the garbage collector work
+// harder as the process approaches its memory limit.
+func Env(limits runtime.ResourceLimits) []string {
+	var env []string
+	if limits.CPU > 0 {
+		env = append(env, fmt.Sprintf("GOMAXPROCS=%d", int(math.Ceil(limits.CPU))))
+	}
+	if limits.MemoryBytes > 0 {
+		env = append(env, fmt.Sprintf("GOMEMLIMIT=%d", limits.MemoryBytes))
+	}
+	return env
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package limits
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slices"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// The mount point of the cgroup v2 hierarchy.
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// The period, in microseconds, over which the CPU limit is enforced.
+	cpuPeriod = 100000
+)
+
+var (
+	setupMu  sync.Mutex
+	setup    *cgroupSetup // the cgroups set up for groups, or nil
+	setupErr error        // error setting up the cgroups
+)
+
+// cgroupSetup records the cgroups created, and the changes made, by
+// setupCgroups, so that they can be undone.
+type cgroupSetup struct {
+	base    string   // the cgroup the current process started in
+	leaf    string   // the leaf cgroup the current process moved into, or ""
+	enabled []string // controllers enabled in base's subtree_control
+	parent  string   // the cgroup under which groups' cgroups are created, or ""
+}
+
+func (g *Group) init(name string) error {
+	setupMu.Lock()
+	if setup == nil && setupErr == nil {
+		setup, setupErr = setupCgroups()
+	}
+	s, err := setup, setupErr
+	setupMu.Unlock()
+	if err != nil {
+		// Fall back to rlimits.
+		g.enforcement = Rlimits
+		return nil
+	}
+
+	dir := filepath.Join(s.parent, strings.ReplaceAll(name, "/", "_"))
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	if err := limit(dir, g.limits); err != nil {
+		os.Remove(dir) //nolint:errcheck // best effort
+		return err
+	}
+	g.enforcement = Cgroups
+	g.dir = dir
+	return nil
+}
+
+// limit sets the limits of the provided cgroup.
+func limit(dir string, limits runtime.ResourceLimits) error {
+	if limits.CPU > 0 {
+		quota := int64(limits.CPU * cpuPeriod)
+		if err := write(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		if err := write(dir, "memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		// Don't let the processes exceed the limit by swapping. Swap may not
+		// be enabled, so we ignore errors.
+		write(dir, "memory.swap.max", "0") //nolint:errcheck // best effort
+	}
+	return nil
+}
+
+// setupCgroups creates the cgroup under which the cgroups of groups are
+// created, and enables the cpu and memory controllers in it. It returns an
+// error if the current process does not run in a cgroup v2 hierarchy that
+// allows it. On error, the changes made so far are undone.
+func setupCgroups() (_ *cgroupSetup, err error) {
+	// Find the cgroup of the current process.
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	var path string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			path = strings.TrimPrefix(line, "0::")
+			break
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no cgroup v2 hierarchy")
+	}
+	base := filepath.Join(cgroupRoot, path)
+	controllers, err := os.ReadFile(filepath.Join(base, "cgroup.controllers"))
+	if err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(string(controllers)); !slices.Contains(fields, "cpu") || !slices.Contains(fields, "memory") {
+		return nil, fmt.Errorf("cpu and memory controllers not available in %s", base)
+	}
+	subtree, err := os.ReadFile(filepath.Join(base, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &cgroupSetup{base: base}
+	defer func() {
+		if err != nil {
+			s.undo() //nolint:errcheck // best effort
+		}
+	}()
+
+	// A non-root cgroup that delegates controllers to its children cannot
+	// contain processes, so we move the current process into a leaf cgroup
+	// of its own. If the cgroup contains other processes, we give up.
+	pid := os.Getpid()
+	if path != "/" {
+		leaf := filepath.Join(base, fmt.Sprintf("serviceweaver-deployer-%d", pid))
+		if err := os.Mkdir(leaf, 0755); err != nil {
+			return nil, err
+		}
+		s.leaf = leaf
+		if err := write(leaf, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range []string{"cpu", "memory"} {
+		if !slices.Contains(strings.Fields(string(subtree)), c) {
+			s.enabled = append(s.enabled, c)
+		}
+	}
+	if err := write(base, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+		s.enabled = nil
+		return nil, err
+	}
+	parent := filepath.Join(base, fmt.Sprintf("serviceweaver-%d", pid))
+	if err := os.Mkdir(parent, 0755); err != nil {
+		return nil, err
+	}
+	s.parent = parent
+	if err := write(parent, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// undo undoes the changes recorded by s, in reverse order: it removes the
+// parent cgroup, disables the controllers it enabled, moves the current
+// process back to its original cgroup, and removes the leaf cgroup. It
+// returns the first error, if any, but undoes as much as it can.
+func (s *cgroupSetup) undo() error {
+	var errs []error
+	if s.parent != "" {
+		if err := os.Remove(s.parent); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(s.enabled) > 0 {
+		var disable []string
+		for _, c := range s.enabled {
+			disable = append(disable, "-"+c)
+		}
+		if err := write(s.base, "cgroup.subtree_control", strings.Join(disable, " ")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.leaf != "" {
+		if err := write(s.base, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			errs = append(errs, err)
+		}
+		if err := os.Remove(s.leaf); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Shutdown removes the cgroups created for groups, and moves the current
+// process back to its original cgroup. It should be called once every group
+// has been closed.
+func Shutdown() error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	s := setup
+	setup, setupErr = nil, nil
+	if s == nil {
+		return nil
+	}
+	return s.undo()
+}
+
+// Add limits the resources used by the process with the provided pid.
+func (g *Group) Add(pid int) error {
+	if g.enforcement == Cgroups {
+		return write(g.dir, "cgroup.procs", strconv.Itoa(pid))
+	}
+	if g.limits.MemoryBytes > 0 {
+		limit := uint64(g.limits.MemoryBytes)
+		rlimit := &unix.Rlimit{Cur: limit, Max: limit}
+		if err := unix.Prlimit(pid, unix.RLIMIT_DATA, rlimit, nil); err != nil {
+			return fmt.Errorf("set RLIMIT_DATA of %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+// Stats returns the number of times the processes of the group hit their
+// limits.
+func (g *Group) Stats() (Stats, error) {
+	var stats Stats
+	if g.enforcement != Cgroups {
+		return stats, nil
+	}
+	cpu, err := readKeyed(g.dir, "cpu.stat")
+	if err != nil {
+		return stats, err
+	}
+	memory, err := readKeyed(g.dir, "memory.events")
+	if err != nil {
+		return stats, err
+	}
+	stats.CPUThrottled = cpu["nr_throttled"]
+	stats.MemoryMaxHits = memory["max"]
+	stats.OOMKills = memory["oom_kill"]
+	return stats, nil
+}
+
+// Close releases the resources of the group. It should be called once the
+// processes added to the group have exited.
+func (g *Group) Close() error {
+	if g.enforcement != Cgroups {
+		return nil
+	}
+	return os.Remove(g.dir)
+}
+
+// write writes the provided value to a cgroup interface file.
+func write(dir, file, value string) error {
+	return os.WriteFile(filepath.Join(dir, file), []byte(value), 0)
+}
+
+// readKeyed reads a cgroup interface file with lines of the form "key value".
+func readKeyed(dir, file string) (map[string]int64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]int64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values, scanner.Err()
+}
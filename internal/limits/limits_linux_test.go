@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUndoCgroupSetup(t *testing.T) {
+	// Test plan: Undo the setup of a fake cgroup hierarchy in a temporary
+	// directory, and check that the cgroups are removed, that the enabled
+	// controllers are disabled, and that the process is moved back.
+	base := t.TempDir()
+	for _, file := range []string{"cgroup.procs", "cgroup.subtree_control"} {
+		if err := os.WriteFile(filepath.Join(base, file), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &cgroupSetup{
+		base:    base,
+		leaf:    filepath.Join(base, "leaf"),
+		enabled: []string{"cpu", "memory"},
+		parent:  filepath.Join(base, "parent"),
+	}
+	for _, dir := range []string{s.leaf, s.parent} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.undo(); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{s.leaf, s.parent} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("cgroup %s not removed: %v", dir, err)
+		}
+	}
+	for file, want := range map[string]string{
+		"cgroup.subtree_control": "-cpu -memory",
+		"cgroup.procs":           strconv.Itoa(os.Getpid()),
+	} {
+		got, err := os.ReadFile(filepath.Join(base, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestUndoPartialCgroupSetup(t *testing.T) {
+	// A setup that failed before creating the parent cgroup, or enabling
+	// controllers, only undoes what it did.
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "cgroup.procs"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := &cgroupSetup{base: base, leaf: filepath.Join(base, "leaf")}
+	if err := os.Mkdir(s.leaf, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.leaf); !os.IsNotExist(err) {
+		t.Errorf("leaf cgroup not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "cgroup.subtree_control")); !os.IsNotExist(err) {
+		t.Errorf("subtree_control written: %v", err)
+	}
+}
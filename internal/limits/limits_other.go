@@ -0,0 +1,46 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package limits
+
+func (g *Group) init(string) error {
+	g.enforcement = GoRuntime
+	return nil
+}
+
+// Add limits the resources used by the process with the provided pid.
+func (g *Group) Add(int) error {
+	// The limits are enforced by the Go runtime of the process. See Env.
+	return nil
+}
+
+// Stats returns the number of times the processes of the group hit their
+// limits.
+func (g *Group) Stats() (Stats, error) {
+	return Stats{}, nil
+}
+
+// Close releases the resources of the group. It should be called once the
+// processes added to the group have exited.
+func (g *Group) Close() error {
+	return nil
+}
+
+// Shutdown releases the resources shared by the groups. It should be called
+// once every group has been closed.
+func Shutdown() error {
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limits
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+func TestEnv(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		limits runtime.ResourceLimits
+		want   []string
+	}{
+		{"Unlimited", runtime.ResourceLimits{}, nil},
+		{"FractionalCPU", runtime.ResourceLimits{CPU: 0.5}, []string{"GOMAXPROCS=1"}},
+		{"CPU", runtime.ResourceLimits{CPU: 2.5}, []string{"GOMAXPROCS=3"}},
+		{"Memory", runtime.ResourceLimits{MemoryBytes: 1 << 20}, []string{"GOMEMLIMIT=1048576"}},
+		{"Both", runtime.ResourceLimits{CPU: 1, MemoryBytes: 1024}, []string{"GOMAXPROCS=1", "GOMEMLIMIT=1024"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Env(test.limits); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Env(%+v) = %v; want %v", test.limits, got, test.want)
+			}
+		})
+	}
+}
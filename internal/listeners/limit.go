@@ -0,0 +1,236 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+// A Limited is a net.Listener that limits its open connections, closes its
+// idle connections, and tracks its open connections, so that they can be
+// drained. See runtime.ListenerConfig.
+type Limited struct {
+	net.Listener
+	idleTimeout time.Duration
+	slots       chan struct{} // holds an element per open connection, if limited
+	stopped     chan struct{} // closed by Stop
+	closed      chan struct{} // closed by Close
+	stopOnce    sync.Once
+	closeOnce   sync.Once
+
+	mu    sync.Mutex
+	conns map[*conn]struct{} // open connections
+	empty chan struct{}      // if not nil, closed once conns is empty
+}
+
+// Limit returns a Limited that accepts the connections of l. If maxConns is
+// positive, the Limited has at most maxConns open connections, and Accept
+// blocks while it has as many. If idleTimeout is positive, a connection on
+// which no data is read or written for idleTimeout is closed.
+func Limit(l net.Listener, maxConns int, idleTimeout time.Duration) *Limited {
+	limited := &Limited{
+		Listener:    l,
+		idleTimeout: idleTimeout,
+		stopped:     make(chan struct{}),
+		closed:      make(chan struct{}),
+		conns:       map[*conn]struct{}{},
+	}
+	if maxConns > 0 {
+		limited.slots = make(chan struct{}, maxConns)
+	}
+	return limited
+}
+
+// Accept implements the net.Listener interface.
+func (l *Limited) Accept() (net.Conn, error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-l.closed:
+			return nil, net.ErrClosed
+		}
+	}
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if l.slots != nil {
+			<-l.slots
+		}
+		select {
+		case <-l.stopped:
+			// Block until closed, rather than fail, so that the server
+			// keeps serving its open connections.
+			<-l.closed
+			return nil, net.ErrClosed
+		default:
+			return nil, err
+		}
+	}
+
+	tracked := &conn{Conn: c, l: l}
+	tracked.touch()
+	l.mu.Lock()
+	l.conns[tracked] = struct{}{}
+	l.mu.Unlock()
+	if l.idleTimeout > 0 {
+		tracked.mu.Lock()
+		tracked.timer = time.AfterFunc(l.idleTimeout, tracked.closeIfIdle)
+		tracked.mu.Unlock()
+	}
+	return tracked, nil
+}
+
+// Stop stops accepting connections. New connections are refused, but Accept
+// blocks, rather than fail, until Close is called, so that a server that
+// serves the listener, e.g., with http.Serve, keeps serving the open
+// connections.
+func (l *Limited) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopped)
+		l.Listener.Close()
+	})
+}
+
+// Close implements the net.Listener interface. Close stops accepting
+// connections, and makes Accept fail. It doesn't close the open connections.
+func (l *Limited) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		select {
+		case <-l.stopped:
+		default:
+			err = l.Listener.Close()
+		}
+	})
+	return err
+}
+
+// Wait waits for the open connections to be closed. If ctx is done first,
+// Wait closes the open connections and returns an error.
+func (l *Limited) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	if len(l.conns) == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.empty == nil {
+		l.empty = make(chan struct{})
+	}
+	empty := l.empty
+	l.mu.Unlock()
+
+	select {
+	case <-empty:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	conns := maps.Keys(l.conns)
+	l.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	return fmt.Errorf("closed %d open connections: %w", len(conns), ctx.Err())
+}
+
+// NumConns returns the number of open connections.
+func (l *Limited) NumConns() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// remove removes a closed connection.
+func (l *Limited) remove(c *conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, c)
+	if l.slots != nil {
+		<-l.slots
+	}
+	if len(l.conns) == 0 && l.empty != nil {
+		close(l.empty)
+		l.empty = nil
+	}
+}
+
+// conn is a connection accepted by a Limited.
+type conn struct {
+	net.Conn
+	l         *Limited
+	last      atomic.Int64 // when data was last read or written, in Unix nanoseconds
+	closeOnce sync.Once
+	closeErr  error
+
+	mu    sync.Mutex
+	timer *time.Timer // closes the connection once idle, if not nil
+}
+
+// touch records that data was read or written.
+func (c *conn) touch() {
+	c.last.Store(time.Now().UnixNano())
+}
+
+// Read implements the net.Conn interface.
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+// Write implements the net.Conn interface.
+func (c *conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+// closeIfIdle closes the connection if no data was read or written on it for
+// the idle timeout, or checks again once it may be.
+func (c *conn) closeIfIdle() {
+	idle := time.Since(time.Unix(0, c.last.Load()))
+	if idle >= c.l.idleTimeout {
+		c.Close()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer.Reset(c.l.idleTimeout - idle)
+}
+
+// Close implements the net.Conn interface.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.Conn.Close()
+		c.mu.Lock()
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		c.mu.Unlock()
+		c.l.remove(c)
+	})
+	return c.closeErr
+}
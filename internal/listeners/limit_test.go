@@ -0,0 +1,143 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// limited returns a Limited listening on a local port.
+func limited(t *testing.T, maxConns int, idleTimeout time.Duration) *Limited {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Limit(lis, maxConns, idleTimeout)
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// dial dials l, and returns the connection accepted by l.
+func dial(t *testing.T, l *Limited) (client, server net.Conn) {
+	t.Helper()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestLimitMaxConnections(t *testing.T) {
+	l := limited(t, 1, 0)
+	_, server := dial(t, l)
+
+	// The second connection is only accepted once the first is closed.
+	if _, err := net.Dial("tcp", l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+	select {
+	case <-accepted:
+		t.Fatal("Accept: accepted a connection over the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	server.Close()
+	select {
+	case <-accepted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Accept: connection not accepted once a connection closed")
+	}
+}
+
+func TestLimitIdleTimeout(t *testing.T) {
+	l := limited(t, 0, 50*time.Millisecond)
+	client, _ := dial(t, l)
+	client.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read: got %v, want connection closed", err)
+	}
+	for start := time.Now(); l.NumConns() != 0; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("NumConns: got %d, want 0", l.NumConns())
+		}
+	}
+}
+
+func TestLimitStopAndWait(t *testing.T) {
+	l := limited(t, 0, 0)
+	_, server := dial(t, l)
+
+	// Once stopped, new connections are refused, and Accept blocks until
+	// the listener is closed.
+	l.Stop()
+	if c, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		c.Close()
+		t.Fatal("Dial: connected to a stopped listener")
+	}
+	acceptErr := make(chan error)
+	go func() {
+		_, err := l.Accept()
+		acceptErr <- err
+	}()
+	select {
+	case err := <-acceptErr:
+		t.Fatalf("Accept: returned %v before Close", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Wait returns once the open connection is closed.
+	waitErr := make(chan error)
+	go func() { waitErr <- l.Wait(context.Background()) }()
+	server.Close()
+	if err := <-waitErr; err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	l.Close()
+	if err := <-acceptErr; !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept after Close: got %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestLimitWaitTimeout(t *testing.T) {
+	l := limited(t, 0, 0)
+	client, _ := dial(t, l)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	client.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Read: connection not closed by Wait")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// unixPrefix prefixes the address of a Unix domain socket.
+	unixPrefix = "unix://"
+
+	// systemdPrefix prefixes the address of a socket passed by systemd
+	// socket activation.
+	systemdPrefix = "systemd"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation. See sd_listen_fds(3).
+var listenFdsStart = 3
+
+var (
+	// inheritedMu guards inherited.
+	inheritedMu sync.Mutex
+
+	// inherited holds the file descriptors passed by systemd that have
+	// already been turned into listeners.
+	inherited = map[int]bool{}
+)
+
+// Listen listens on the provided address, which is one of:
+//
+//   - host:port, a TCP address;
+//   - unix:///path/to/socket, a Unix domain socket at the provided path; or
+//   - systemd:name, the socket named name passed by systemd socket
+//     activation. "systemd" alone refers to the only socket passed.
+//
+// A stale Unix domain socket left at the provided path by a process that is no
+// longer running is removed before listening.
+func Listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixPrefix))
+	case addr == systemdPrefix || strings.HasPrefix(addr, systemdPrefix+":"):
+		return listenSystemd(strings.TrimPrefix(strings.TrimPrefix(addr, systemdPrefix), ":"))
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+// IsAddrInUse returns whether err, returned by Listen, means that the address
+// is already in use.
+func IsAddrInUse(err error) bool {
+	// Windows returns WSAEADDRINUSE (syscall.Errno = 10048), rather than
+	// syscall.EADDRINUSE, when the address is in use.
+	return errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.Errno(10048))
+}
+
+// listenUnix listens on a Unix domain socket at the provided path.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("listen unix: empty socket path")
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			// Somebody is listening on the socket.
+			conn.Close()
+			return nil, &net.OpError{Op: "listen", Net: "unix", Addr: &net.UnixAddr{Name: path, Net: "unix"}, Err: syscall.EADDRINUSE}
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("listen unix %s: remove stale socket: %w", path, err)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// listenSystemd returns a listener for the socket with the provided name
+// passed by systemd socket activation. If name is empty, exactly one socket
+// must have been passed.
+func listenSystemd(name string) (net.Listener, error) {
+	fds, names, err := systemdSockets()
+	if err != nil {
+		return nil, err
+	}
+	fd := -1
+	if name == "" {
+		if len(fds) != 1 {
+			return nil, fmt.Errorf("listen systemd: got %d sockets, want exactly 1; use systemd:<name> to pick one", len(fds))
+		}
+		fd = fds[0]
+	} else {
+		for i, n := range names {
+			if n == name {
+				fd = fds[i]
+				break
+			}
+		}
+		if fd == -1 {
+			return nil, fmt.Errorf("listen systemd: no socket named %q in %v", name, names)
+		}
+	}
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	if inherited[fd] {
+		return nil, fmt.Errorf("listen systemd: socket %d already in use", fd)
+	}
+	// net.FileListener duplicates the file descriptor, with close-on-exec
+	// set, so the original descriptor is closed to keep it from leaking into
+	// child processes.
+	f := os.NewFile(uintptr(fd), name)
+	lis, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("listen systemd: socket %d: %w", fd, err)
+	}
+	inherited[fd] = true
+	return lis, nil
+}
+
+// systemdSockets returns the file descriptors and names of the sockets passed
+// by systemd socket activation, as described by the LISTEN_PID, LISTEN_FDS and
+// LISTEN_FDNAMES environment variables.
+func systemdSockets() ([]int, []string, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil, fmt.Errorf("listen systemd: no sockets passed to this process")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, fmt.Errorf("listen systemd: invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+	fds := make([]int, n)
+	for i := range fds {
+		fds[i] = listenFdsStart + i
+		if i >= len(names) {
+			// systemd names unnamed sockets "unknown".
+			names = append(names, "unknown")
+		}
+	}
+	return fds, names[:n], nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package listeners
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// accept checks that lis accepts a connection dialed on network.
+func accept(t *testing.T, network string, lis net.Listener) {
+	t.Helper()
+	go func() {
+		conn, err := net.Dial(network, lis.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestIsAddrInUse(t *testing.T) {
+	lis, err := Listen("localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	_, err = Listen(lis.Addr().String())
+	if !IsAddrInUse(err) {
+		t.Fatalf("IsAddrInUse(%v) = false, want true", err)
+	}
+	for _, err := range []error{nil, syscall.ECONNREFUSED, fmt.Errorf("listen: boom")} {
+		if IsAddrInUse(err) {
+			t.Errorf("IsAddrInUse(%v) = true, want false", err)
+		}
+	}
+	// The error returned by Windows.
+	if err := fmt.Errorf("listen: %w", syscall.Errno(10048)); !IsAddrInUse(err) {
+		t.Errorf("IsAddrInUse(%v) = false, want true", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	// Unix socket paths are limited to ~100 bytes, so we avoid t.TempDir().
+	dir, err := os.MkdirTemp("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "http.sock")
+	addr := "unix://" + path
+
+	lis, err := Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept(t, "unix", lis)
+
+	// The socket is in use.
+	if _, err := Listen(addr); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("Listen(%q) of used socket: got %v, want EADDRINUSE", addr, err)
+	}
+	if got := Address("", "a", addr); got != addr {
+		t.Fatalf("Address(%q): got %q, want %q", addr, got, addr)
+	}
+
+	// Leave a stale socket behind, which is removed by Listen.
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	lis.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	lis, err = Listen(addr)
+	if err != nil {
+		t.Fatalf("Listen(%q) of stale socket: %v", addr, err)
+	}
+	defer lis.Close()
+	accept(t, "unix", lis)
+}
+
+func TestListenSystemd(t *testing.T) {
+	// Pretend that systemd passed us a socket.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := listenFdsStart
+	listenFdsStart = int(f.Fd())
+	t.Cleanup(func() {
+		listenFdsStart = old
+		inherited = map[int]bool{}
+	})
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+
+	if _, err := Listen("systemd:grpc"); err == nil {
+		t.Fatal("Listen(systemd:grpc): unexpected success")
+	}
+	lis, err := Listen("systemd:http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if got, want := lis.Addr().String(), l.Addr().String(); got != want {
+		t.Fatalf("Listen(systemd:http): got address %q, want %q", got, want)
+	}
+	accept(t, "tcp", lis)
+
+	// A socket can only be inherited once.
+	if _, err := Listen("systemd"); err == nil {
+		t.Fatal("second Listen(systemd): unexpected success")
+	}
+
+	// The sockets were passed to some other process.
+	t.Setenv("LISTEN_PID", "1")
+	if _, err := Listen("systemd:http"); err == nil {
+		t.Fatal("Listen(systemd:http) with wrong LISTEN_PID: unexpected success")
+	}
+}
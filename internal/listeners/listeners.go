@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package listeners listens on the addresses of application listeners, and
+// records the addresses of the listeners of a single process application, so
+// that an application restarted by "weaver dev" can listen on the same
+// addresses as its previous incarnation.
+package listeners
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+)
+
+// FileKey is the name of the environment variable that holds the path of the
+// file in which listener addresses are recorded. If the variable is not set,
+// addresses are neither recorded nor reused.
+const FileKey = "SERVICEWEAVER_LISTENERS_FILE"
+
+// Load returns the listener addresses recorded in the provided file, keyed by
+// listener name. It returns an empty map if the file does not exist.
+func Load(file string) (map[string]string, error) {
+	addrs := map[string]string{}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return addrs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// Record records the address of the listener with the provided name in the
+// provided file.
+func Record(file, name, addr string) error {
+	addrs, err := Load(file)
+	if err != nil {
+		return err
+	}
+	addrs[name] = addr
+	data, err := json.Marshal(addrs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// Address returns the address that the listener with the provided name should
+// listen on, given the address requested by the application. If the requested
+// address has no port or port 0, and an address was previously recorded for
+// the listener in the provided file and is free, the recorded address is
+// returned. Otherwise, the requested address is returned.
+func Address(file, name, requested string) string {
+	if _, port, err := net.SplitHostPort(requested); requested != "" && (err != nil || (port != "" && port != "0")) {
+		return requested
+	}
+	addrs, err := Load(file)
+	if err != nil {
+		return requested
+	}
+	addr, ok := addrs[name]
+	if !ok {
+		return requested
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		// The address has been taken by some other process.
+		return requested
+	}
+	lis.Close()
+	return addr
+}
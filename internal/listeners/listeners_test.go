@@ -0,0 +1,64 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package listeners
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "listeners.json")
+
+	// Nothing has been recorded yet.
+	if got, want := Address(file, "a", "localhost:0"), "localhost:0"; got != want {
+		t.Fatalf("Address before Record: got %q, want %q", got, want)
+	}
+
+	// Find a free address and record it.
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	if err := Record(file, "a", addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(file, "b", "localhost:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The recorded address is in use, so it is not reused.
+	if got, want := Address(file, "a", "localhost:0"), "localhost:0"; got != want {
+		t.Errorf("Address of taken address: got %q, want %q", got, want)
+	}
+	lis.Close()
+
+	for _, test := range []struct {
+		name, requested, want string
+	}{
+		{"a", "localhost:0", addr},
+		{"a", "", addr},
+		{"a", ":0", addr},
+		{"a", "localhost:9000", "localhost:9000"}, // explicit ports are kept
+		{"c", "localhost:0", "localhost:0"},       // nothing recorded
+	} {
+		if got := Address(file, test.name, test.requested); got != test.want {
+			t.Errorf("Address(%q, %q): got %q, want %q", test.name, test.requested, got, test.want)
+		}
+	}
+}
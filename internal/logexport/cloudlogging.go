@@ -0,0 +1,172 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+var (
+	// The endpoint of the entries.write method of the Cloud Logging API.
+	cloudLoggingURL = "https://logging.googleapis.com/v2/entries:write"
+
+	// The endpoint of the metadata server that returns access tokens for
+	// the default service account.
+	tokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// cloudLogging exports log entries to Google Cloud Logging, using the
+// entries.write method of the Cloud Logging API [1].
+//
+// [1]: https://cloud.google.com/logging/docs/reference/v2/rest/v2/entries/write
+type cloudLogging struct {
+	logName string
+	client  *http.Client
+
+	// Access token of the default service account, and its expiration time.
+	// Only accessed by Export, which is not called concurrently.
+	token   string
+	expires time.Time
+}
+
+var _ exporter = &cloudLogging{}
+
+// cloudLoggingEntry is a LogEntry [1] in the body of an entries.write request.
+//
+// [1]: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
+type cloudLoggingEntry struct {
+	Timestamp      string            `json:"timestamp"`
+	Severity       string            `json:"severity"`
+	JSONPayload    map[string]string `json:"jsonPayload"`
+	Labels         map[string]string `json:"labels"`
+	SourceLocation *sourceLocation   `json:"sourceLocation,omitempty"`
+}
+
+type sourceLocation struct {
+	File string `json:"file"`
+	Line string `json:"line"`
+}
+
+func newCloudLogging(config runtime.CloudLoggingConfig) *cloudLogging {
+	logName := config.LogName
+	if logName == "" {
+		logName = "serviceweaver"
+	}
+	return &cloudLogging{
+		logName: fmt.Sprintf("projects/%s/logs/%s", config.Project, url.PathEscape(logName)),
+		client:  &http.Client{},
+	}
+}
+
+// Export implements the logging.Exporter interface.
+func (c *cloudLogging) Export(ctx context.Context, entries []*protos.LogEntry) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body := struct {
+		LogName  string              `json:"logName"`
+		Resource map[string]string   `json:"resource"`
+		Entries  []cloudLoggingEntry `json:"entries"`
+	}{
+		LogName:  c.logName,
+		Resource: map[string]string{"type": "global"},
+	}
+	for _, entry := range entries {
+		payload := fields(entry)
+		payload["message"] = payload["msg"]
+		delete(payload, "msg")
+		e := cloudLoggingEntry{
+			Timestamp:   entryTime(entry).UTC().Format(time.RFC3339Nano),
+			Severity:    severity(entry.Level),
+			JSONPayload: payload,
+			Labels: map[string]string{
+				"app":        entry.App,
+				"deployment": entry.Version,
+				"component":  entry.Component,
+				"weavelet":   entry.Node,
+			},
+		}
+		if entry.File != "" {
+			e.SourceLocation = &sourceLocation{File: entry.File, Line: fmt.Sprint(entry.Line)}
+		}
+		body.Entries = append(body.Entries, e)
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	return post(ctx, c.client, cloudLoggingURL, headers, body)
+}
+
+// Close implements the exporter interface.
+func (c *cloudLogging) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// accessToken returns an access token of the default service account,
+// fetching a new one from the metadata server if the last one is about to
+// expire.
+func (c *cloudLogging) accessToken(ctx context.Context) (string, error) {
+	if c.token != "" && time.Until(c.expires) > time.Minute {
+		return c.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch access token: %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"` // seconds
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("fetch access token: %w", err)
+	}
+	c.token = token.AccessToken
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// severity returns the Cloud Logging severity of the provided log level.
+func severity(level string) string {
+	switch strings.ToLower(level) {
+	case "debug":
+		return "DEBUG"
+	case "info":
+		return "INFO"
+	case "warn":
+		return "WARNING"
+	case "error":
+		return "ERROR"
+	default:
+		return "DEFAULT"
+	}
+}
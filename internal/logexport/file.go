@@ -0,0 +1,131 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// file exports log entries to a file, as JSON objects, one per line, and
+// rotates the file once it reaches its maximum size. See
+// runtime.LogFileConfig.
+type file struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	f        *os.File
+	size     int64 // size of f
+}
+
+var _ exporter = &file{}
+
+func newFile(config runtime.LogFileConfig, weavelet string) (*file, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	l := &file{
+		path:     filepath.Join(config.Dir, weavelet+".log"),
+		maxBytes: config.MaxBytes,
+		maxFiles: config.MaxFiles,
+	}
+	if l.maxBytes == 0 {
+		l.maxBytes = runtime.DefaultLogFileMaxBytes
+	}
+	if l.maxFiles == 0 {
+		l.maxFiles = runtime.DefaultLogFileMaxFiles
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Export implements the logging.Exporter interface.
+func (l *file) Export(_ context.Context, entries []*protos.LogEntry) error {
+	for _, entry := range entries {
+		fields := fields(entry)
+		fields["time"] = entryTime(entry).UTC().Format(time.RFC3339Nano)
+		line, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+			if err := l.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := l.f.Write(line)
+		l.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the exporter interface.
+func (l *file) Close() error {
+	return l.f.Close()
+}
+
+// open opens the file for appending.
+func (l *file) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the file with a ".1" suffix, after renaming the file with a
+// ".i" suffix with a ".i+1" suffix, for every i, and removing the oldest
+// file, and opens a new file.
+func (l *file) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	oldest := fmt.Sprintf("%s.%d", l.path, l.maxFiles)
+	if err := os.Remove(oldest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", l.path, i)
+		dst := fmt.Sprintf("%s.%d", l.path, i+1)
+		if err := os.Rename(src, dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.open()
+}
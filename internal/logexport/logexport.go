@@ -0,0 +1,135 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logexport exports log entries to the logging backends configured in
+// the [serviceweaver.logging] section of the config: Grafana Loki, Google
+// Cloud Logging, syslog, and rotated files.
+package logexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// exporter is a logging.Exporter that must be closed once it is no longer
+// used.
+type exporter interface {
+	logging.Exporter
+	Close() error
+}
+
+// New returns the exporters enabled in the provided config, for the weavelet
+// with the provided id of the provided application, and a function that
+// closes them.
+func New(config runtime.LoggingConfig, app, weavelet string) ([]logging.Exporter, func() error, error) {
+	var exporters []exporter
+	if config.Loki != nil {
+		exporters = append(exporters, newLoki(*config.Loki))
+	}
+	if config.CloudLogging != nil {
+		exporters = append(exporters, newCloudLogging(*config.CloudLogging))
+	}
+	if config.Syslog != nil {
+		s, err := newSyslog(*config.Syslog, app)
+		if err != nil {
+			closeAll(exporters) //nolint:errcheck // already failing
+			return nil, nil, fmt.Errorf("syslog: %w", err)
+		}
+		exporters = append(exporters, s)
+	}
+	if config.File != nil {
+		f, err := newFile(*config.File, weavelet)
+		if err != nil {
+			closeAll(exporters) //nolint:errcheck // already failing
+			return nil, nil, fmt.Errorf("file: %w", err)
+		}
+		exporters = append(exporters, f)
+	}
+	result := make([]logging.Exporter, len(exporters))
+	for i, e := range exporters {
+		result[i] = e
+	}
+	return result, func() error { return closeAll(exporters) }, nil
+}
+
+// closeAll closes the provided exporters, and returns the first error
+// returned by any of them.
+func closeAll(exporters []exporter) error {
+	var first error
+	for _, e := range exporters {
+		if err := e.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// fields returns the message, attributes, and metadata of the provided log
+// entry, keyed by name. Metadata overrides attributes with the same name.
+func fields(entry *protos.LogEntry) map[string]string {
+	fields := make(map[string]string, len(entry.Attrs)/2+6)
+	for i := 0; i+1 < len(entry.Attrs); i += 2 {
+		fields[entry.Attrs[i]] = entry.Attrs[i+1]
+	}
+	fields["msg"] = entry.Msg
+	fields["level"] = entry.Level
+	fields["component"] = entry.Component
+	fields["deployment"] = entry.Version
+	fields["weavelet"] = entry.Node
+	if entry.File != "" {
+		fields["source"] = fmt.Sprintf("%s:%d", entry.File, entry.Line)
+	}
+	return fields
+}
+
+// entryTime returns the time of the provided log entry.
+func entryTime(entry *protos.LogEntry) time.Time {
+	return time.UnixMicro(entry.TimeMicros)
+}
+
+// post sends the JSON encoding of the provided body to the provided URL, and
+// returns an error if the response doesn't have a 2xx status code.
+func post(ctx context.Context, client *http.Client, url string, headers map[string]string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
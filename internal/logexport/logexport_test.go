@@ -0,0 +1,245 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logexport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+var now = time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testEntries returns log entries logged by two components.
+func testEntries() []*protos.LogEntry {
+	return []*protos.LogEntry{
+		{
+			App:        "boutique",
+			Version:    "v1",
+			Component:  "github.com/example/boutique/cartservice/T",
+			Node:       "w1",
+			TimeMicros: now.UnixMicro(),
+			Level:      "INFO",
+			File:       "cart.go",
+			Line:       42,
+			Msg:        "added item",
+			Attrs:      []string{"user", "alice", "item", "a b"},
+		},
+		{
+			App:        "boutique",
+			Version:    "v1",
+			Component:  "main",
+			Node:       "w1",
+			TimeMicros: now.Add(time.Second).UnixMicro(),
+			Level:      "ERROR",
+			Line:       -1,
+			Msg:        "checkout failed",
+		},
+	}
+}
+
+func TestLoki(t *testing.T) {
+	var got struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	var tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = r.Header.Get("X-Scope-OrgID")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	l := newLoki(runtime.LokiConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"X-Scope-OrgID": "shop"},
+		Labels:  map[string]string{"env": "prod"},
+	})
+	defer l.Close()
+	if err := l.Export(context.Background(), testEntries()); err != nil {
+		t.Fatal(err)
+	}
+	if tenant != "shop" {
+		t.Errorf("X-Scope-OrgID: got %q, want %q", tenant, "shop")
+	}
+	if len(got.Streams) != 2 {
+		t.Fatalf("got %d streams, want 2", len(got.Streams))
+	}
+	wantLabels := map[string]string{"app": "boutique", "component": "cartservice.T", "level": "INFO", "env": "prod"}
+	if diff := cmp.Diff(wantLabels, got.Streams[0].Stream); diff != "" {
+		t.Errorf("labels (-want +got):\n%s", diff)
+	}
+	value := got.Streams[0].Values[0]
+	if want := "1677672000000000000"; value[0] != want {
+		t.Errorf("timestamp: got %s, want %s", value[0], want)
+	}
+	var line map[string]string
+	if err := json.Unmarshal([]byte(value[1]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["msg"] != "added item" || line["user"] != "alice" || line["source"] != "cart.go:42" {
+		t.Errorf("line: got %v", line)
+	}
+}
+
+func TestLokiError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "entry too far behind", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	l := newLoki(runtime.LokiConfig{URL: server.URL})
+	defer l.Close()
+	err := l.Export(context.Background(), testEntries())
+	if err == nil || !strings.Contains(err.Error(), "entry too far behind") {
+		t.Fatalf("Export: got %v, want entry too far behind", err)
+	}
+}
+
+func TestCloudLogging(t *testing.T) {
+	var got struct {
+		LogName string              `json:"logName"`
+		Entries []cloudLoggingEntry `json:"entries"`
+	}
+	var auth string
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokens++
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"access_token": "secret", "expires_in": 3600}`)) //nolint:errcheck // test
+		case "/write":
+			auth = r.Header.Get("Authorization")
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Error(err)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer func(write, token string) { cloudLoggingURL, tokenURL = write, token }(cloudLoggingURL, tokenURL)
+	cloudLoggingURL, tokenURL = server.URL+"/write", server.URL+"/token"
+
+	c := newCloudLogging(runtime.CloudLoggingConfig{Project: "my-project"})
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if err := c.Export(context.Background(), testEntries()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("fetched %d access tokens, want 1", tokens)
+	}
+	if want := "Bearer secret"; auth != want {
+		t.Errorf("Authorization: got %q, want %q", auth, want)
+	}
+	if want := "projects/my-project/logs/serviceweaver"; got.LogName != want {
+		t.Errorf("logName: got %q, want %q", got.LogName, want)
+	}
+	want := []cloudLoggingEntry{
+		{
+			Timestamp: "2023-03-01T12:00:00Z",
+			Severity:  "INFO",
+			JSONPayload: map[string]string{
+				"message":    "added item",
+				"level":      "INFO",
+				"component":  "github.com/example/boutique/cartservice/T",
+				"deployment": "v1",
+				"weavelet":   "w1",
+				"source":     "cart.go:42",
+				"user":       "alice",
+				"item":       "a b",
+			},
+			Labels: map[string]string{
+				"app":        "boutique",
+				"deployment": "v1",
+				"component":  "github.com/example/boutique/cartservice/T",
+				"weavelet":   "w1",
+			},
+			SourceLocation: &sourceLocation{File: "cart.go", Line: "42"},
+		},
+	}
+	if diff := cmp.Diff(want, got.Entries[:1]); diff != "" {
+		t.Errorf("entries (-want +got):\n%s", diff)
+	}
+	if got.Entries[1].Severity != "ERROR" || got.Entries[1].SourceLocation != nil {
+		t.Errorf("second entry: got %+v", got.Entries[1])
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	f, err := newFile(runtime.LogFileConfig{Dir: dir, MaxBytes: 100, MaxFiles: 2}, "w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every entry is longer than 100 bytes, so every entry but the first
+	// rotates the file.
+	for i := 0; i < 4; i++ {
+		if err := f.Export(context.Background(), testEntries()[:1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	if diff := cmp.Diff([]string{"w1.log", "w1.log.1", "w1.log.2"}, files); diff != "" {
+		t.Fatalf("files (-want +got):\n%s", diff)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "w1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var line map[string]string
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["msg"] != "added item" || line["time"] != "2023-03-01T12:00:00Z" {
+		t.Errorf("line: got %v", line)
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	got := syslogMessage(testEntries()[0])
+	const want = `added item component=github.com/example/boutique/cartservice/T item="a b" user=alice`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
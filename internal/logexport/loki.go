@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logexport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// loki exports log entries to Grafana Loki, using its push API [1].
+//
+// [1]: https://grafana.com/docs/loki/latest/reference/api/#push-log-entries-to-loki
+type loki struct {
+	config runtime.LokiConfig
+	client *http.Client
+}
+
+var _ exporter = &loki{}
+
+// lokiStream is a stream in the body of a push request.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"` // labels
+	Values [][2]string       `json:"values"` // (nanosecond timestamp, line) pairs
+}
+
+func newLoki(config runtime.LokiConfig) *loki {
+	return &loki{config: config, client: &http.Client{}}
+}
+
+// Export implements the logging.Exporter interface.
+func (l *loki) Export(ctx context.Context, entries []*protos.LogEntry) error {
+	streams := map[string]*lokiStream{}
+	var keys []string
+	for _, entry := range entries {
+		labels := map[string]string{
+			"app":       entry.App,
+			"component": logging.ShortenComponent(entry.Component),
+			"level":     entry.Level,
+		}
+		for name, value := range l.config.Labels {
+			labels[name] = value
+		}
+		key := lokiKey(labels)
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			keys = append(keys, key)
+		}
+		line, err := json.Marshal(fields(entry))
+		if err != nil {
+			return err
+		}
+		ts := strconv.FormatInt(entryTime(entry).UnixNano(), 10)
+		stream.Values = append(stream.Values, [2]string{ts, string(line)})
+	}
+
+	body := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range keys {
+		body.Streams = append(body.Streams, streams[key])
+	}
+	return post(ctx, l.client, l.config.URL, l.config.Headers, body)
+}
+
+// Close implements the exporter interface.
+func (l *loki) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
+
+// lokiKey returns a string that uniquely identifies the provided labels.
+func lokiKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logexport
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// syslogMessage returns the syslog message of the provided log entry: the
+// entry's message followed by its attributes and component, as key=value
+// pairs.
+func syslogMessage(entry *protos.LogEntry) string {
+	var b strings.Builder
+	b.WriteString(entry.Msg)
+	attrs := map[string]string{}
+	for i := 0; i+1 < len(entry.Attrs); i += 2 {
+		attrs[entry.Attrs[i]] = entry.Attrs[i+1]
+	}
+	attrs["component"] = entry.Component
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteByte(' ')
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(syslogValue(attrs[name]))
+	}
+	return b.String()
+}
+
+// syslogValue returns the provided value, quoted if it is empty or holds
+// spaces, quotes, equal signs, or non-printable characters.
+func syslogValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=") || strconv.Quote(value) != `"`+value+`"` {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !plan9
+
+package logexport
+
+import (
+	"context"
+	"log/syslog"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// syslogExporter exports log entries to a syslog daemon.
+type syslogExporter struct {
+	w *syslog.Writer
+}
+
+var _ exporter = &syslogExporter{}
+
+func newSyslog(config runtime.SyslogConfig, app string) (*syslogExporter, error) {
+	tag := config.Tag
+	if tag == "" {
+		tag = app
+	}
+	w, err := syslog.Dial(config.Network, config.Address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogExporter{w: w}, nil
+}
+
+// Export implements the logging.Exporter interface.
+func (s *syslogExporter) Export(_ context.Context, entries []*protos.LogEntry) error {
+	for _, entry := range entries {
+		msg := syslogMessage(entry)
+		var err error
+		switch strings.ToLower(entry.Level) {
+		case "debug":
+			err = s.w.Debug(msg)
+		case "warn":
+			err = s.w.Warning(msg)
+		case "error":
+			err = s.w.Err(msg)
+		default:
+			err = s.w.Info(msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the exporter interface.
+func (s *syslogExporter) Close() error {
+	return s.w.Close()
+}
@@ -16,8 +16,11 @@ package metrics
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"math"
+	"net"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
@@ -82,6 +85,43 @@ func TranslateMetricsToPrometheusTextFormat(w *bytes.Buffer, ms []*metrics.Metri
 	}
 }
 
+// PrometheusPath is the path at which ServePrometheus serves metrics. It is
+// the path Prometheus scrapes by default.
+const PrometheusPath = "/metrics"
+
+// PrometheusHandler returns an HTTP handler that serves the metrics returned
+// by snapshot in the Prometheus text format. path is the path the handler is
+// registered at; it is used in the scrape config included in the response.
+func PrometheusHandler(path string, snapshot func(context.Context) ([]*metrics.MetricSnapshot, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, err := snapshot(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var b bytes.Buffer
+		TranslateMetricsToPrometheusTextFormat(&b, ms, r.Host, path)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(b.Bytes()) //nolint:errcheck // response write error
+	})
+}
+
+// ServePrometheus serves the metrics returned by snapshot in the Prometheus
+// text format at PrometheusPath on the provided listener, until ctx is done.
+func ServePrometheus(ctx context.Context, lis net.Listener, snapshot func(context.Context) ([]*metrics.MetricSnapshot, error)) error {
+	mux := http.NewServeMux()
+	mux.Handle(PrometheusPath, PrometheusHandler(PrometheusPath, snapshot))
+	server := http.Server{Handler: mux}
+	errs := make(chan error, 1)
+	go func() { errs <- server.Serve(lis) }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return server.Shutdown(ctx)
+	}
+}
+
 // writeHelper generates a config.yaml file that can be used by prometheus to
 // scrape the exported metrics.
 func writeHelper(w *bytes.Buffer, lisAddr, path string) {
@@ -16,7 +16,13 @@ package metrics_test
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"regexp"
+	"strings"
 	"testing"
 
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
@@ -147,3 +153,34 @@ func TestTranslateMetricsToPrometheus(t *testing.T) {
 		})
 	}
 }
+
+func TestServePrometheus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := func(context.Context) ([]*metrics.MetricSnapshot, error) {
+		return []*metrics.MetricSnapshot{
+			{Id: 1, Name: "hello", Help: "foo", Type: protos.MetricType_COUNTER, Value: 100},
+		}, nil
+	}
+	go imetrics.ServePrometheus(ctx, lis, snapshot) //nolint:errcheck // stopped by cancel
+
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", lis.Addr(), imetrics.PrometheusPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello 100"; !strings.Contains(string(body), want) {
+		t.Fatalf("response missing %q:\n%s", want, body)
+	}
+}
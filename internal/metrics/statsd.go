@@ -0,0 +1,158 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// maxStatsDPacket is the maximum size of the UDP packets sent to a StatsD
+// server, small enough to not be fragmented on most networks.
+const maxStatsDPacket = 1432
+
+// statsdEscaper replaces the characters that StatsD uses as separators.
+var statsdEscaper = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", "\n", "_")
+
+// StatsD is a metrics.Backend that sends metrics to a StatsD server over UDP,
+// with their labels as DogStatsD tags. Counters are sent as the increase
+// since the previous batch, gauges as their value, and histograms as two
+// counters, <name>_sum and <name>_count.
+type StatsD struct {
+	conn     net.Conn
+	previous map[statsdKey]float64 // cumulative values of the previous batch
+}
+
+// statsdKey identifies a cumulative value sent to StatsD: a counter, or the
+// sum or count of a histogram.
+type statsdKey struct {
+	id   uint64 // the metric id
+	name string // the StatsD name
+}
+
+var _ metrics.Backend = &StatsD{}
+
+// NewStatsD returns a StatsD backend that sends metrics to the server at the
+// provided "host:port" address.
+func NewStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn, previous: map[statsdKey]float64{}}, nil
+}
+
+// Export implements the metrics.Backend interface.
+func (s *StatsD) Export(_ context.Context, batch *metrics.Batch) error {
+	var packet bytes.Buffer
+	var lines [][]byte
+	for _, m := range batch.Metrics {
+		lines = append(lines, s.lines(m)...)
+	}
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacket {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := s.conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to the StatsD server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// lines returns the StatsD lines of the provided metric.
+func (s *StatsD) lines(m *metrics.MetricSnapshot) [][]byte {
+	tags := statsdTags(m.Labels)
+	name := statsdEscaper.Replace(m.Name)
+	switch m.Type {
+	case protos.MetricType_COUNTER:
+		return [][]byte{statsdLine(name, s.delta(statsdKey{m.Id, name}, m.Value), "c", tags)}
+	case protos.MetricType_GAUGE:
+		return [][]byte{statsdLine(name, m.Value, "g", tags)}
+	case protos.MetricType_HISTOGRAM:
+		var count uint64
+		for _, c := range m.Counts {
+			count += c
+		}
+		sum, n := name+"_sum", name+"_count"
+		return [][]byte{
+			statsdLine(sum, s.delta(statsdKey{m.Id, sum}, m.Value), "c", tags),
+			statsdLine(n, s.delta(statsdKey{m.Id, n}, float64(count)), "c", tags),
+		}
+	}
+	return nil
+}
+
+// delta returns the increase of the provided cumulative value since the
+// previous batch, and remembers the value. A value that decreased,
+// e.g., because its process restarted, increased by itself.
+func (s *StatsD) delta(key statsdKey, value float64) float64 {
+	previous, ok := s.previous[key]
+	s.previous[key] = value
+	if !ok || value < previous {
+		return value
+	}
+	return value - previous
+}
+
+// statsdLine returns a StatsD line.
+func statsdLine(name string, value float64, typ, tags string) []byte {
+	var b bytes.Buffer
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if tags != "" {
+		b.WriteString("|#")
+		b.WriteString(tags)
+	}
+	return b.Bytes()
+}
+
+// statsdTags returns the provided labels as DogStatsD tags, sorted by name.
+func statsdTags(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tags := make([]string, len(names))
+	for i, name := range names {
+		tags[i] = statsdEscaper.Replace(name) + ":" + statsdEscaper.Replace(labels[name])
+	}
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestStatsD(t *testing.T) {
+	// Test plan: Export two batches to a fake StatsD server, and check the
+	// lines it receives. Counters and histograms are sent as deltas.
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	statsd, err := imetrics.NewStatsD(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer statsd.Close()
+
+	batch := func(requests, latency float64, count uint64) *metrics.Batch {
+		return &metrics.Batch{Metrics: []*metrics.MetricSnapshot{
+			{
+				Id:     1,
+				Type:   protos.MetricType_COUNTER,
+				Name:   "requests",
+				Labels: map[string]string{"region": "us", "code": "200"},
+				Value:  requests,
+			},
+			{
+				Id:    2,
+				Type:  protos.MetricType_GAUGE,
+				Name:  "queue_size",
+				Value: 7,
+			},
+			{
+				Id:     3,
+				Type:   protos.MetricType_HISTOGRAM,
+				Name:   "latency",
+				Labels: map[string]string{"method": "a:b"},
+				Value:  latency,
+				Bounds: []float64{10},
+				Counts: []uint64{count, 1},
+			},
+		}}
+	}
+	receive := func() []string {
+		t.Helper()
+		buf := make([]byte, 2048)
+		server.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+
+	ctx := context.Background()
+	if err := statsd.Export(ctx, batch(10, 100, 3)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"requests:10|c|#code:200,region:us",
+		"queue_size:7|g",
+		"latency_sum:100|c|#method:a_b",
+		"latency_count:4|c|#method:a_b",
+	}
+	if diff := cmp.Diff(want, receive()); diff != "" {
+		t.Fatalf("first batch (-want +got):\n%s", diff)
+	}
+
+	if err := statsd.Export(ctx, batch(15, 130, 5)); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"requests:5|c|#code:200,region:us",
+		"queue_size:7|g",
+		"latency_sum:30|c|#method:a_b",
+		"latency_count:2|c|#method:a_b",
+	}
+	if diff := cmp.Diff(want, receive()); diff != "" {
+		t.Fatalf("second batch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mtls implements the mutual TLS authentication of the weavelets in a
+// deployment. A deployer creates a CA, a certificate authority unique to the
+// deployment, that mints short-lived certificates for its weavelets. Every
+// weavelet serves and dials with its certificate, and accepts only peers with
+// a certificate signed by the CA. Weavelets get a new certificate from the CA
+// before the current one expires.
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// DefaultCertLifetime is the default lifetime of the certificates minted by a
+// CA.
+const DefaultCertLifetime = 24 * time.Hour
+
+// clockSkew is the time by which the validity of minted certificates is
+// backdated, to tolerate clocks that are slightly behind.
+const clockSkew = 5 * time.Minute
+
+// A CA is a certificate authority that mints the certificates of the
+// weavelets in a deployment. A CA is safe for concurrent use.
+type CA struct {
+	cert     *x509.Certificate // self-signed certificate
+	key      *ecdsa.PrivateKey // private key
+	lifetime time.Duration     // lifetime of minted certificates
+}
+
+// NewCA returns a new CA for the provided deployment that mints certificates
+// valid for the provided lifetime. The CA's own key lives only in memory, so
+// every CA is distinct, even for the same deployment.
+func NewCA(deploymentId string, lifetime time.Duration) (*CA, error) {
+	if lifetime <= 0 {
+		return nil, fmt.Errorf("non-positive certificate lifetime %v", lifetime)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Service Weaver"},
+			CommonName:   fmt.Sprintf("serviceweaver deployment %s", deploymentId),
+		},
+		NotBefore: now.Add(-clockSkew),
+		// The CA must outlive every certificate it mints, and deployments
+		// can last arbitrarily long.
+		NotAfter:              now.Add(100 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	return &CA{cert: cert, key: key, lifetime: lifetime}, nil
+}
+
+// Mint mints a new key and certificate for the weavelet with the provided id.
+func (ca *CA) Mint(weaveletId string) (*protos.GetCertificateReply, error) {
+	key, pub, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := ca.Sign(weaveletId, pub)
+	if err != nil {
+		return nil, err
+	}
+	reply.Key = key
+	return reply, nil
+}
+
+// Sign returns a new certificate for the provided PKIX DER encoded public key
+// of the weavelet with the provided id. The returned reply has no key. Sign
+// lets a deployer mint certificates for weavelets on other machines without
+// sending their private keys over the network.
+func (ca *CA) Sign(weaveletId string, publicKey []byte) (*protos.GetCertificateReply, error) {
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Service Weaver"},
+			CommonName:   weaveletId,
+		},
+		NotBefore:   now.Add(-clockSkew),
+		NotAfter:    now.Add(ca.lifetime),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, pub, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+	return &protos.GetCertificateReply{CaCert: ca.cert.Raw, Cert: der}, nil
+}
+
+// GenerateKey returns a new PKCS #8 DER encoded private key for a weavelet,
+// along with its PKIX DER encoded public key.
+func GenerateKey() (key, publicKey []byte, err error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	key, err = x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal key: %w", err)
+	}
+	publicKey, err = x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal public key: %w", err)
+	}
+	return key, publicKey, nil
+}
+
+// serialNumber returns a random certificate serial number.
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	return serial, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// retryInterval is the time between two failed attempts to get a new
+// certificate.
+const retryInterval = 10 * time.Second
+
+// Credentials holds the certificate of a weavelet, and gets a new one from
+// the deployer before it expires. Credentials are safe for concurrent use.
+type Credentials struct {
+	logger *slog.Logger
+	fetch  func() (*protos.GetCertificateReply, error)
+	roots  *x509.CertPool // certificate of the deployment's CA
+
+	mu   sync.Mutex
+	cert *tls.Certificate // current certificate, with Leaf set
+}
+
+// NewCredentials returns the credentials of a weavelet. fetch gets a new
+// certificate from the deployer, e.g., using a GetCertificateRequest. The
+// credentials get a new certificate halfway through the remaining lifetime of
+// the current one, until ctx is done.
+func NewCredentials(ctx context.Context, logger *slog.Logger, fetch func() (*protos.GetCertificateReply, error)) (*Credentials, error) {
+	reply, err := fetch()
+	if err != nil {
+		return nil, fmt.Errorf("get certificate: %w", err)
+	}
+	ca, err := x509.ParseCertificate(reply.CaCert)
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	cert, err := parseCertificate(reply)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	c := &Credentials{logger: logger, fetch: fetch, roots: roots, cert: cert}
+	go c.refresh(ctx)
+	return c, nil
+}
+
+// ServerConfig returns the TLS config of a weavelet's server. The server
+// only accepts clients with a certificate signed by the deployment's CA.
+func (c *Credentials) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  c.roots,
+	}
+}
+
+// ClientConfig returns the TLS config of a weavelet's clients. Clients only
+// accept servers with a certificate signed by the deployment's CA.
+func (c *Credentials) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.certificate(), nil
+		},
+		// Weavelets are dialed by address, but their certificates identify
+		// weavelets, not addresses, so the default verification, which
+		// checks the server name, would fail. verifyServer checks the
+		// certificate chain instead.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: c.verifyServer,
+	}
+}
+
+// certificate returns the current certificate.
+func (c *Credentials) certificate() *tls.Certificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cert
+}
+
+// verifyServer implements the tls.Config.VerifyPeerCertificate function.
+func (c *Credentials) verifyServer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("parse server certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
+
+// refresh gets a new certificate halfway through the remaining lifetime of
+// the current one, until ctx is done.
+func (c *Credentials) refresh(ctx context.Context) {
+	leaf := c.certificate().Leaf
+	next := refreshTime(leaf)
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		reply, err := c.fetch()
+		var cert *tls.Certificate
+		if err == nil {
+			cert, err = parseCertificate(reply)
+		}
+		if err != nil {
+			// Keep using the current certificate, which is still valid
+			// for a while, and retry later.
+			c.logger.Error("get certificate", err, "expires", leaf.NotAfter)
+			next = time.Now().Add(retryInterval)
+			continue
+		}
+
+		c.mu.Lock()
+		c.cert = cert
+		c.mu.Unlock()
+		leaf = cert.Leaf
+		next = refreshTime(leaf)
+	}
+}
+
+// refreshTime returns the time halfway between now and the expiration of the
+// provided certificate.
+func refreshTime(leaf *x509.Certificate) time.Time {
+	return time.Now().Add(time.Until(leaf.NotAfter) / 2)
+}
+
+// parseCertificate parses the certificate in the provided reply.
+func parseCertificate(reply *protos.GetCertificateReply) (*tls.Certificate, error) {
+	leaf, err := x509.ParseCertificate(reply.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(reply.Key)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{reply.Cert},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtls_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/mtls"
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+var echoKey = call.MakeMethodKey("", "echo")
+
+// credentials returns credentials for a weavelet, minted by the provided CA.
+func credentials(ctx context.Context, t *testing.T, ca *mtls.CA, id string) *mtls.Credentials {
+	t.Helper()
+	fetch := func() (*protos.GetCertificateReply, error) { return ca.Mint(id) }
+	creds, err := mtls.NewCredentials(ctx, logging.NewTestLogger(t), fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creds
+}
+
+// serve starts an echo server that uses the provided credentials, and returns
+// its endpoint.
+func serve(ctx context.Context, t *testing.T, creds *mtls.Credentials) call.Endpoint {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := &call.HandlerMap{}
+	handlers.Set("", "echo", func(_ context.Context, arg []byte) ([]byte, error) {
+		return arg, nil
+	})
+	opts := call.ServerOptions{
+		Logger:    logging.NewTestLogger(t),
+		TLSConfig: creds.ServerConfig(),
+	}
+	go call.Serve(ctx, lis, handlers, opts) //nolint:errcheck // stops with ctx
+	return call.TCP(lis.Addr().String())
+}
+
+// echo makes an echo call to the provided endpoint using the provided
+// credentials.
+func echo(ctx context.Context, t *testing.T, endpoint call.Endpoint, creds *mtls.Credentials) error {
+	t.Helper()
+	opts := call.ClientOptions{
+		Logger:    logging.NewTestLogger(t),
+		TLSConfig: creds.ClientConfig(),
+	}
+	client, err := call.Connect(ctx, call.NewConstantResolver(endpoint), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	reply, err := client.Call(ctx, echoKey, []byte("hello"), call.CallOptions{})
+	if err != nil {
+		return err
+	}
+	if got, want := string(reply), "hello"; got != want {
+		t.Fatalf("echo: got %q, want %q", got, want)
+	}
+	return nil
+}
+
+func TestSameDeployment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ca, err := mtls.NewCA("deployment", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := serve(ctx, t, credentials(ctx, t, ca, "server"))
+	if err := echo(ctx, t, endpoint, credentials(ctx, t, ca, "client")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOtherDeployment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ca, err := mtls.NewCA("deployment", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := mtls.NewCA("other", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A server should reject clients from another deployment, and a client
+	// should reject servers from another deployment.
+	endpoint := serve(ctx, t, credentials(ctx, t, ca, "server"))
+	otherEndpoint := serve(ctx, t, credentials(ctx, t, other, "server"))
+	for _, test := range []struct {
+		name     string
+		endpoint call.Endpoint
+		creds    *mtls.Credentials
+	}{
+		{"OtherClient", endpoint, credentials(ctx, t, other, "client")},
+		{"OtherServer", otherEndpoint, credentials(ctx, t, ca, "client")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := echo(ctx, t, test.endpoint, test.creds)
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if !errors.Is(err, call.CommunicationError) && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("got %v, want communication error", err)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const lifetime = 2 * time.Second
+	ca, err := mtls.NewCA("deployment", lifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var minted atomic.Int64
+	fetch := func() (*protos.GetCertificateReply, error) {
+		minted.Add(1)
+		return ca.Mint("weavelet")
+	}
+	creds, err := mtls.NewCredentials(ctx, logging.NewTestLogger(t), fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls should keep succeeding after the first certificate expires.
+	endpoint := serve(ctx, t, creds)
+	time.Sleep(2 * lifetime)
+	if err := echo(ctx, t, endpoint, creds); err != nil {
+		t.Fatal(err)
+	}
+	if got := minted.Load(); got < 3 {
+		t.Fatalf("minted %d certificates, want at least 3", got)
+	}
+}
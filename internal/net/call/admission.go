@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Priority is the priority class of a call. When a server is saturated, calls
+// of higher priority are admitted before calls of lower priority.
+type Priority uint8
+
+const (
+	// UnspecifiedPriority is treated as NormalPriority.
+	UnspecifiedPriority Priority = iota
+	LowPriority
+	NormalPriority
+	HighPriority
+
+	numPriorities = int(HighPriority) + 1
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case UnspecifiedPriority, NormalPriority:
+		return "normal"
+	case LowPriority:
+		return "low"
+	case HighPriority:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", p)
+	}
+}
+
+// normalize returns p, with unspecified and unknown priorities replaced by
+// NormalPriority.
+func (p Priority) normalize() Priority {
+	if p == UnspecifiedPriority || int(p) >= numPriorities {
+		return NormalPriority
+	}
+	return p
+}
+
+// admitter performs admission control on a server. It runs at most
+// maxActive calls at a time. A call that arrives while maxActive calls are
+// running waits in the queue of its priority class. Every class has its own
+// queue of at most maxQueued calls; a call that arrives when its queue is
+// full is shed. When a running call finishes, the oldest waiting call of the
+// highest priority class is admitted, so under sustained overload the queues
+// of lower priority classes fill up, and their calls are shed, first.
+type admitter struct {
+	maxActive int
+	maxQueued int
+	onShed    func(Priority) // if not nil, called for every shed call
+
+	mu     sync.Mutex
+	active int                    // number of running calls
+	queues [numPriorities][]*turn // waiting calls, by priority
+}
+
+// turn is a call waiting to be admitted.
+type turn struct {
+	admitted chan struct{} // closed when the call is admitted
+}
+
+// newAdmitter returns a new admitter, or nil if admission control is
+// disabled by opts.
+func newAdmitter(opts ServerOptions) *admitter {
+	if opts.MaxConcurrentCalls <= 0 {
+		return nil
+	}
+	return &admitter{
+		maxActive: opts.MaxConcurrentCalls,
+		maxQueued: opts.MaxQueuedCalls,
+		onShed:    opts.OnShed,
+	}
+}
+
+// acquire waits until a call of priority p can run. It returns a ShedLoad
+// error if the call is shed, or ctx.Err() if ctx is done before the call is
+// admitted. If acquire returns nil, the caller must call release once the
+// call finishes.
+func (a *admitter) acquire(ctx context.Context, p Priority) error {
+	p = p.normalize()
+	a.mu.Lock()
+	if a.active < a.maxActive && a.waiting() == 0 {
+		a.active++
+		a.mu.Unlock()
+		return nil
+	}
+	if len(a.queues[p]) >= a.maxQueued {
+		a.mu.Unlock()
+		if a.onShed != nil {
+			a.onShed(p)
+		}
+		return fmt.Errorf("%w: %s priority queue full", ShedLoad, p)
+	}
+	t := &turn{admitted: make(chan struct{})}
+	a.queues[p] = append(a.queues[p], t)
+	a.mu.Unlock()
+
+	select {
+	case <-t.admitted:
+		return nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		select {
+		case <-t.admitted:
+			// We were admitted concurrently with ctx being done. Pass our
+			// turn on to the next call.
+			a.active--
+			a.admitNext()
+		default:
+			a.remove(p, t)
+		}
+		return ctx.Err()
+	}
+}
+
+// release marks a call admitted by acquire as finished.
+func (a *admitter) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	a.admitNext()
+}
+
+// admitNext admits waiting calls, highest priority first, while there is
+// capacity to run them.
+//
+// REQUIRES: a.mu is held.
+func (a *admitter) admitNext() {
+	for p := numPriorities - 1; p >= 0 && a.active < a.maxActive; {
+		if len(a.queues[p]) == 0 {
+			p--
+			continue
+		}
+		t := a.queues[p][0]
+		a.queues[p] = a.queues[p][1:]
+		a.active++
+		close(t.admitted)
+	}
+}
+
+// waiting returns the number of waiting calls.
+//
+// REQUIRES: a.mu is held.
+func (a *admitter) waiting() int {
+	n := 0
+	for _, q := range a.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// remove removes t from the queue of priority p.
+//
+// REQUIRES: a.mu is held.
+func (a *admitter) remove(p Priority, t *turn) {
+	q := a.queues[p]
+	for i, x := range q {
+		if x == t {
+			a.queues[p] = append(q[:i:i], q[i+1:]...)
+			return
+		}
+	}
+}
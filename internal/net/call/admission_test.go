@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until a has n waiting calls.
+func waitQueued(t *testing.T, a *admitter, n int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		a.mu.Lock()
+		got := a.waiting()
+		a.mu.Unlock()
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d queued calls", n)
+}
+
+func TestAdmitterPriorityOrder(t *testing.T) {
+	// Test plan: Saturate an admitter with a single slot, queue calls of
+	// every priority, and check that they are admitted highest priority
+	// first.
+	ctx := context.Background()
+	a := newAdmitter(ServerOptions{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+	if err := a.acquire(ctx, NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan Priority, numPriorities)
+	queued := 0
+	for _, p := range []Priority{LowPriority, NormalPriority, HighPriority} {
+		p := p
+		go func() {
+			if err := a.acquire(ctx, p); err != nil {
+				t.Error(err)
+				return
+			}
+			admitted <- p
+		}()
+		queued++
+		waitQueued(t, a, queued)
+	}
+
+	a.release()
+	for _, want := range []Priority{HighPriority, NormalPriority, LowPriority} {
+		if got := <-admitted; got != want {
+			t.Fatalf("admitted %v call, want %v call", got, want)
+		}
+		a.release()
+	}
+}
+
+func TestAdmitterShedLoad(t *testing.T) {
+	// Test plan: Saturate an admitter and fill the low priority queue. Check
+	// that further low priority calls are shed, while high priority calls
+	// are still queued.
+	ctx := context.Background()
+	shed := map[Priority]int{}
+	a := newAdmitter(ServerOptions{
+		MaxConcurrentCalls: 1,
+		MaxQueuedCalls:     1,
+		OnShed:             func(p Priority) { shed[p]++ },
+	})
+	if err := a.acquire(ctx, NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+	go a.acquire(ctx, LowPriority) //nolint:errcheck // never admitted
+	waitQueued(t, a, 1)
+
+	if err := a.acquire(ctx, LowPriority); !errors.Is(err, ShedLoad) {
+		t.Fatalf("low priority acquire: got %v, want ShedLoad", err)
+	}
+	if got, want := shed[LowPriority], 1; got != want {
+		t.Fatalf("shed low priority calls: got %d, want %d", got, want)
+	}
+	go a.acquire(ctx, HighPriority) //nolint:errcheck // never admitted
+	waitQueued(t, a, 2)
+}
+
+func TestAdmitterCancel(t *testing.T) {
+	// Test plan: Cancel a queued call, and check that it is removed from the
+	// queue.
+	a := newAdmitter(ServerOptions{MaxConcurrentCalls: 1, MaxQueuedCalls: 1})
+	if err := a.acquire(context.Background(), NormalPriority); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- a.acquire(ctx, NormalPriority) }()
+	waitQueued(t, a, 1)
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire: got %v, want context.Canceled", err)
+	}
+	waitQueued(t, a, 0)
+}
+
+func TestAdmitterDisabled(t *testing.T) {
+	if a := newAdmitter(ServerOptions{}); a != nil {
+		t.Fatalf("newAdmitter: got %v, want nil", a)
+	}
+}
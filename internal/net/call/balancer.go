@@ -17,14 +17,15 @@ package call
 import (
 	"fmt"
 	"math/rand"
+	"sync"
+	"time"
 )
 
 // A Balancer picks the endpoint to which which an RPC client performs a call. A
 // Balancer should only be used by a single goroutine.
 //
-// TODO(mwhittaker): Right now, balancers have no load information about
-// endpoints. In the short term, we can at least add information about the
-// number of pending requests for every endpoint.
+// A Balancer that also implements CallObserver is informed of the calls made
+// on the endpoints it picks, which it can use to balance load.
 //
 // TODO(mwhittaker): Right now, we pass a balancer the set of all endpoints. We
 // instead probably want to pass it only the endpoints for which we have a
@@ -45,6 +46,72 @@ type Balancer interface {
 	Pick(CallOptions) (Endpoint, error)
 }
 
+// A CallObserver is informed of the calls made on the endpoints picked by a
+// Balancer. Started is called once a call has been assigned to an endpoint
+// returned by Pick, with the same synchronization as Pick. Finished is called
+// once the call completes, and may be called concurrently with Started, Pick,
+// and Update.
+type CallObserver interface {
+	// Started informs the observer that a call was started on the endpoint.
+	Started(endpoint Endpoint)
+
+	// Finished informs the observer that a call on the endpoint finished
+	// after the provided latency, with the provided error.
+	Finished(endpoint Endpoint, latency time.Duration, err error)
+}
+
+// A DialObserver is informed of the endpoints picked by a Balancer that could
+// not be dialed, e.g., because the machine hosting them is down. DialFailed is
+// called with the same synchronization as Pick, and the call is then retried
+// on another picked endpoint.
+type DialObserver interface {
+	// DialFailed informs the observer that the endpoint could not be dialed.
+	DialFailed(endpoint Endpoint, err error)
+}
+
+// A HedgeGroup is a group of calls, made to hedge each other, that should be
+// sent to different endpoints. The zero value is an empty group. See
+// CallOptions.Hedge.
+type HedgeGroup struct {
+	mu   sync.Mutex
+	used map[string]bool // addresses of the endpoints picked so far
+}
+
+// has returns whether a call in the group was sent to the provided address.
+func (h *HedgeGroup) has(addr string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.used[addr]
+}
+
+// add records that a call in the group was sent to the provided address.
+func (h *HedgeGroup) add(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.used == nil {
+		h.used = map[string]bool{}
+	}
+	h.used[addr] = true
+}
+
+// pickEndpoint picks an endpoint for a call with the provided options. If the
+// call belongs to a hedge group, pickEndpoint asks the balancer for at most
+// one endpoint per available endpoint, until it picks one not used by the
+// group.
+func pickEndpoint(balancer Balancer, endpoints int, opts CallOptions) (Endpoint, error) {
+	endpoint, err := balancer.Pick(opts)
+	if err != nil || opts.Hedge == nil {
+		return endpoint, err
+	}
+	for i := 1; i < endpoints && opts.Hedge.has(endpoint.Address()); i++ {
+		if endpoint, err = balancer.Pick(opts); err != nil {
+			return nil, err
+		}
+	}
+	opts.Hedge.add(endpoint.Address())
+	return endpoint, nil
+}
+
 // balancerFuncImpl is the imeplementation of the "functional" balancer
 // returned by BalancerFunc.
 type balancerFuncImpl struct {
@@ -114,3 +181,123 @@ func Sharded() Balancer {
 		return endpoints[opts.ShardKey%uint64(n)], nil
 	})
 }
+
+// ewmaWeight is the weight of a new latency sample in the exponentially
+// weighted moving average of an endpoint's latency.
+const ewmaWeight = 0.2
+
+// loadBalancer is a Balancer that picks the least loaded endpoint, based on
+// the calls it observes.
+type loadBalancer struct {
+	weighted bool // weigh outstanding calls by latency?
+
+	mu        sync.Mutex
+	endpoints []Endpoint
+	loads     map[string]*endpointLoad // keys are endpoint addresses
+	next      int                      // where the next Pick starts its scan
+}
+
+// endpointLoad is the load observed on an endpoint.
+type endpointLoad struct {
+	outstanding int     // number of outstanding calls
+	ewma        float64 // moving average of call latency, in nanoseconds
+}
+
+var (
+	_ Balancer     = &loadBalancer{}
+	_ CallObserver = &loadBalancer{}
+)
+
+// LeastOutstanding returns a balancer that picks the endpoint with the fewest
+// outstanding calls, breaking ties round-robin.
+func LeastOutstanding() *loadBalancer {
+	return &loadBalancer{loads: map[string]*endpointLoad{}}
+}
+
+// LatencyWeighted returns a balancer that picks the endpoint with the lowest
+// expected latency, which it estimates as the endpoint's number of
+// outstanding calls, plus one, times a moving average of the endpoint's
+// observed call latency. Endpoints with no observed calls are picked first.
+// Ties are broken round-robin.
+func LatencyWeighted() *loadBalancer {
+	return &loadBalancer{weighted: true, loads: map[string]*endpointLoad{}}
+}
+
+// Update implements the Balancer interface.
+func (lb *loadBalancer) Update(endpoints []Endpoint) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.endpoints = endpoints
+	loads := make(map[string]*endpointLoad, len(endpoints))
+	for _, e := range endpoints {
+		addr := e.Address()
+		if load, ok := lb.loads[addr]; ok {
+			loads[addr] = load
+		} else {
+			loads[addr] = &endpointLoad{}
+		}
+	}
+	lb.loads = loads
+}
+
+// Pick implements the Balancer interface.
+func (lb *loadBalancer) Pick(CallOptions) (Endpoint, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	n := len(lb.endpoints)
+	if n == 0 {
+		return nil, fmt.Errorf("%w: no endpoints available", Unreachable)
+	}
+	if lb.next >= n {
+		lb.next = 0
+	}
+	best, bestScore := -1, 0.0
+	for i := 0; i < n; i++ {
+		j := (lb.next + i) % n
+		score := lb.score(lb.loads[lb.endpoints[j].Address()])
+		if best == -1 || score < bestScore {
+			best, bestScore = j, score
+		}
+	}
+	lb.next++
+	return lb.endpoints[best], nil
+}
+
+// score returns the score of an endpoint with the provided load. Endpoints
+// with lower scores are picked first.
+//
+// REQUIRES: lb.mu is held.
+func (lb *loadBalancer) score(load *endpointLoad) float64 {
+	if !lb.weighted {
+		return float64(load.outstanding)
+	}
+	return float64(load.outstanding+1) * load.ewma
+}
+
+// Started implements the CallObserver interface.
+func (lb *loadBalancer) Started(endpoint Endpoint) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if load, ok := lb.loads[endpoint.Address()]; ok {
+		load.outstanding++
+	}
+}
+
+// Finished implements the CallObserver interface.
+func (lb *loadBalancer) Finished(endpoint Endpoint, latency time.Duration, _ error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	load, ok := lb.loads[endpoint.Address()]
+	if !ok {
+		// The endpoint was removed while the call was running.
+		return
+	}
+	if load.outstanding > 0 {
+		load.outstanding--
+	}
+	if load.ewma == 0 {
+		load.ewma = float64(latency)
+	} else {
+		load.ewma = ewmaWeight*float64(latency) + (1-ewmaWeight)*load.ewma
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// pick picks an endpoint from b, failing the test on error.
+func pick(t *testing.T, b Balancer) Endpoint {
+	t.Helper()
+	e, err := b.Pick(CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	// Test plan: Start calls on a least-outstanding balancer, and check that
+	// every pick goes to the endpoint with the fewest outstanding calls.
+	a, b, c := TCP("a"), TCP("b"), TCP("c")
+	lb := LeastOutstanding()
+	lb.Update([]Endpoint{a, b, c})
+
+	// With no load, endpoints are picked round-robin.
+	for _, want := range []Endpoint{a, b, c} {
+		got := pick(t, lb)
+		if got != want {
+			t.Fatalf("Pick: got %v, want %v", got, want)
+		}
+		lb.Started(got)
+	}
+
+	// With every endpoint running one call, finishing the call on b makes b
+	// the least loaded endpoint.
+	lb.Finished(b, time.Millisecond, nil)
+	for i := 0; i < 3; i++ {
+		if got := pick(t, lb); got != b {
+			t.Fatalf("Pick: got %v, want %v", got, b)
+		}
+	}
+}
+
+func TestLatencyWeighted(t *testing.T) {
+	// Test plan: Finish calls on a latency-weighted balancer with different
+	// latencies, and check that picks go to the fastest endpoint until its
+	// outstanding calls outweigh its lower latency.
+	fast, slow := TCP("fast"), TCP("slow")
+	lb := LatencyWeighted()
+	lb.Update([]Endpoint{fast, slow})
+	for _, e := range []Endpoint{fast, slow} {
+		lb.Started(e)
+	}
+	lb.Finished(fast, time.Millisecond, nil)
+	lb.Finished(slow, 3*time.Millisecond, errors.New("slow"))
+
+	// fast: 1ms * (0+1) = 1ms, slow: 3ms * (0+1) = 3ms.
+	if got := pick(t, lb); got != fast {
+		t.Fatalf("Pick: got %v, want %v", got, fast)
+	}
+	// fast: 1ms * (3+1) = 4ms, slow: 3ms.
+	for i := 0; i < 3; i++ {
+		lb.Started(fast)
+	}
+	if got := pick(t, lb); got != slow {
+		t.Fatalf("Pick: got %v, want %v", got, slow)
+	}
+
+	// New endpoints have no observed latency and are picked first.
+	probe := TCP("probe")
+	lb.Update([]Endpoint{fast, slow, probe})
+	if got := pick(t, lb); got != probe {
+		t.Fatalf("Pick: got %v, want %v", got, probe)
+	}
+}
+
+func TestLoadBalancerRemovedEndpoint(t *testing.T) {
+	// Test plan: Finish a call on an endpoint that was removed while the call
+	// was running, and check that it is ignored.
+	a, b := TCP("a"), TCP("b")
+	lb := LeastOutstanding()
+	lb.Update([]Endpoint{a, b})
+	lb.Started(a)
+	lb.Update([]Endpoint{b})
+	lb.Finished(a, time.Millisecond, nil)
+	if got := pick(t, lb); got != b {
+		t.Fatalf("Pick: got %v, want %v", got, b)
+	}
+	if _, ok := lb.loads[a.Address()]; ok {
+		t.Fatalf("load of removed endpoint %v still tracked", a)
+	}
+}
+
+func TestLoadBalancerNoEndpoints(t *testing.T) {
+	if _, err := LeastOutstanding().Pick(CallOptions{}); !errors.Is(err, Unreachable) {
+		t.Fatalf("Pick: got %v, want Unreachable", err)
+	}
+}
+
+func TestPickEndpointHedge(t *testing.T) {
+	// Test plan: Pick endpoints for the calls of a hedge group, and check
+	// that every call goes to a different endpoint while one is available.
+	a, b := TCP("a"), TCP("b")
+	lb := RoundRobin()
+	lb.Update([]Endpoint{a, b})
+	opts := CallOptions{Hedge: &HedgeGroup{}}
+	first, err := pickEndpoint(lb, 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skip an endpoint so that the balancer picks first again.
+	pick(t, lb)
+	second, err := pickEndpoint(lb, 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("pickEndpoint: got %v twice, want different endpoints", first)
+	}
+
+	// Once every endpoint was used, any endpoint is picked.
+	if _, err := pickEndpoint(lb, 2, opts); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -69,6 +69,7 @@ package call
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -87,7 +88,11 @@ import (
 
 const (
 	// Size of the header included in each message.
-	msgHeaderSize = 16 + 8 + traceHeaderLen // handler_key + deadline + trace_context
+	msgHeaderSize = 16 + 8 + 8 + 1 + traceHeaderLen + 1 + 4 + 4 // handler_key + deadline + method_version + priority + trace_context + compression + threshold + metadata_len
+
+	// compressedArgs is set in the compression byte of a request header if
+	// the call arguments are compressed.
+	compressedArgs = 0x80
 
 	// maxReconnectTries is the maximum number of times a reconnecting
 	// connection will try and create a connection before erroring out.
@@ -109,6 +114,10 @@ type Connection interface {
 	// Call makes an RPC over a Connection.
 	Call(context.Context, MethodKey, []byte, CallOptions) ([]byte, error)
 
+	// Stream starts a streaming RPC over a Connection. The call is cancelled
+	// when the provided context is done.
+	Stream(context.Context, MethodKey, []byte, CallOptions) (ClientStream, error)
+
 	// Close closes a connection. Pending invocations of Call are cancelled and
 	// return an error. All future invocations of Call fail and return an error
 	// immediately. Close can be called more than once.
@@ -145,9 +154,9 @@ type clientConnection struct {
 	draining       bool             // is this clientConnection draining?
 	ended          bool             // has this clientConnection ended?
 	loggedShutdown bool             // Have we logged a shutdown error?
-	version        version          // Version number to use for connection
 	calls          map[uint64]*call // In-progress calls
 	lastID         uint64           // Last assigned request ID for a call
+	flow           *flowLimiter     // if not nil, limits the calls on c
 }
 
 // call holds the state for an active call at the client.
@@ -164,6 +173,8 @@ type call struct {
 	// This field is accessed across goroutines using atomics.
 	done uint32 // is the call done?
 
+	// If not nil, the call is a streaming call.
+	stream *streamState
 }
 
 // serverConnection manages one network connection on the server-side.
@@ -173,14 +184,17 @@ type serverConnection struct {
 	cbuf        *bufio.Reader // Buffered reader wrapped around c
 	wlock       sync.Mutex    // Guards writes to c
 	mu          sync.Mutex
-	closed      bool              // has c been closed?
-	version     version           // Version number to use for connection
-	cancelFuncs map[uint64]func() // Cancellation functions for in-progress calls
+	closed      bool                    // has c been closed?
+	cancelFuncs map[uint64]func()       // Cancellation functions for in-progress calls
+	streams     map[uint64]*streamState // In-progress streaming calls
+	admit       *admitter               // if not nil, admission control shared by all connections
+	flow        *flowLimiter            // if not nil, limits the calls received on c
 }
 
 // serverState tracks all live server-side connections so we can clean things up when canceled.
 type serverState struct {
 	opts  ServerOptions
+	admit *admitter // if not nil, admission control for all connections
 	mu    sync.Mutex
 	conns map[*serverConnection]struct{} // Live connections
 }
@@ -191,7 +205,7 @@ type serverState struct {
 // Serve always returns a non-nil error and closes l.
 func Serve(ctx context.Context, l net.Listener, hmap *HandlerMap, opts ServerOptions) error {
 	opts = opts.withDefaults()
-	ss := &serverState{opts: opts}
+	ss := &serverState{opts: opts, admit: newAdmitter(opts)}
 	defer ss.stop()
 
 	l = &onceCloseListener{Listener: l}
@@ -234,17 +248,24 @@ func (oc *onceCloseListener) Close() error {
 // network connection with a client. This can be useful in tests or
 // when using custom networking transports.
 func ServeOn(ctx context.Context, conn net.Conn, hmap *HandlerMap, opts ServerOptions) {
-	ss := &serverState{opts: opts.withDefaults()}
+	opts = opts.withDefaults()
+	ss := &serverState{opts: opts, admit: newAdmitter(opts)}
 	ss.serveConnection(ctx, conn, hmap)
 }
 
 func (ss *serverState) serveConnection(ctx context.Context, conn net.Conn, hmap *HandlerMap) {
+	if ss.opts.TLSConfig != nil {
+		// The handshake is performed on the first read.
+		conn = tls.Server(conn, ss.opts.TLSConfig)
+	}
 	c := &serverConnection{
 		opts:        ss.opts,
 		c:           conn,
 		cbuf:        bufio.NewReader(conn),
-		version:     initialVersion, // Updated when we hear from client
 		cancelFuncs: map[uint64]func(){},
+		streams:     map[uint64]*streamState{},
+		admit:       ss.admit,
+		flow:        newFlowLimiter(ss.opts.MaxInFlightCalls, ss.opts.MaxPendingCalls),
 	}
 	ss.register(c)
 
@@ -345,26 +366,22 @@ func (rc *reconnectingConnection) Close() {
 }
 
 // Call makes an RPC over connection c.
-func (rc *reconnectingConnection) Call(ctx context.Context, h MethodKey, arg []byte, opts CallOptions) ([]byte, error) {
-	var hdr [msgHeaderSize]byte
-	copy(hdr[0:], h[:])
-	deadline, haveDeadline := ctx.Deadline()
-	if haveDeadline {
-		// Send the deadline in the header. We use the relative time instead
-		// of absolute in case there is significant clock skew. This does mean
-		// that we will not count transmission delay against the deadline.
-		micros := time.Until(deadline).Microseconds()
-		if micros <= 0 {
-			// Fail immediately without attempting to send a zero or negative
-			// deadline to the server which will be misinterpreted.
-			<-ctx.Done()
-			return nil, ctx.Err()
+func (rc *reconnectingConnection) Call(ctx context.Context, h MethodKey, arg []byte, opts CallOptions) (result []byte, err error) {
+	hdr := make([]byte, msgHeaderSize+len(opts.Metadata))
+	if err := writeHeader(ctx, hdr, h, opts); err != nil {
+		return nil, err
+	}
+	if c := rc.opts.Compression; c.Codec != NoCompression {
+		// Ask the server to compress the result, and compress the arguments.
+		var compressed bool
+		arg, compressed = c.maybeCompress(arg)
+		hdr[msgHeaderSize-9] = byte(c.Codec)
+		if compressed {
+			hdr[msgHeaderSize-9] |= compressedArgs
 		}
-		binary.LittleEndian.PutUint64(hdr[16:], uint64(micros))
+		binary.LittleEndian.PutUint32(hdr[msgHeaderSize-8:], uint32(c.Threshold))
 	}
-
-	// Send trace information in the header.
-	writeTraceContext(ctx, hdr[24:])
+	deadline, haveDeadline := ctx.Deadline()
 
 	rpc := &call{}
 	rpc.doneSignal = make(chan struct{})
@@ -380,8 +397,25 @@ func (rc *reconnectingConnection) Call(ctx context.Context, h MethodKey, arg []b
 	if err != nil {
 		return nil, err
 	}
+	if observer, ok := rc.balancer(opts).(CallObserver); ok {
+		start := time.Now()
+		defer func() { observer.Finished(conn.endpoint, time.Since(start), err) }()
+	}
 
-	if err := writeMessage(conn.c, &conn.wlock, requestMessage, rpc.id, hdr[:], arg, rc.opts.WriteFlattenLimit); err != nil {
+	// Wait for the call to be in flight on the connection, if its calls are
+	// limited.
+	admitted, err := conn.flow.enter()
+	if err != nil {
+		conn.endCall(rpc)
+		return nil, err
+	}
+	defer conn.flow.leave(admitted)
+	if err := waitAdmitted(ctx, admitted); err != nil {
+		conn.endCall(rpc)
+		return nil, err
+	}
+
+	if err := writeMessage(conn.c, &conn.wlock, requestMessage, rpc.id, hdr, arg, rc.opts.WriteFlattenLimit); err != nil {
 		conn.shutdown("client send request", err)
 		conn.endCall(rpc)
 		return nil, fmt.Errorf("%w: %s", CommunicationError, err)
@@ -419,6 +453,39 @@ func (rc *reconnectingConnection) Call(ctx context.Context, h MethodKey, arg []b
 	return rpc.response, rpc.err
 }
 
+// writeHeader writes the header of a request for method h into hdr.
+// REQUIRES: len(hdr) == msgHeaderSize + len(opts.Metadata)
+func writeHeader(ctx context.Context, hdr []byte, h MethodKey, opts CallOptions) error {
+	copy(hdr[0:], h[:])
+	if deadline, ok := ctx.Deadline(); ok {
+		// Send the deadline in the header. We use the relative time instead
+		// of absolute in case there is significant clock skew. This does mean
+		// that we will not count transmission delay against the deadline.
+		micros := time.Until(deadline).Microseconds()
+		if micros <= 0 {
+			// Fail immediately without attempting to send a zero or negative
+			// deadline to the server which will be misinterpreted.
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		binary.LittleEndian.PutUint64(hdr[16:], uint64(micros))
+	}
+
+	// Send the method version in the header.
+	binary.LittleEndian.PutUint64(hdr[24:], opts.Version)
+
+	// Send the priority in the header.
+	hdr[32] = byte(opts.Priority)
+
+	// Send trace information in the header.
+	writeTraceContext(ctx, hdr[33:])
+
+	// Send the metadata after the fixed-size part of the header.
+	binary.LittleEndian.PutUint32(hdr[msgHeaderSize-4:], uint32(len(opts.Metadata)))
+	copy(hdr[msgHeaderSize:], opts.Metadata)
+	return nil
+}
+
 // watchResolver watches for updates to the set of endpoints. When a new set of
 // updates is available, watchResolver passes it to updateEndpoints.
 // REQUIRES: version != nil.
@@ -530,9 +597,8 @@ func (rc *reconnectingConnection) startCall(ctx context.Context, rpc *call, opts
 	// important that we index into rc.connections with addr while still
 	// holding rc.mu. Otherwise, a Pick() call could operate on a stale set of
 	// endpoints and return an endpoint that does not exist in rc.connections.
-	var balancer = rc.opts.Balancer
+	balancer := rc.balancer(opts)
 	if opts.Balancer != nil {
-		balancer = opts.Balancer
 		balancer.Update(rc.endpoints)
 	}
 
@@ -541,7 +607,7 @@ func (rc *reconnectingConnection) startCall(ctx context.Context, rpc *call, opts
 	// operations.
 	var connectErr error
 	for i := 0; i < maxReconnectTries; i++ {
-		endpoint, err := balancer.Pick(opts)
+		endpoint, err := pickEndpoint(balancer, len(rc.endpoints), opts)
 		if err != nil {
 			return nil, err
 		}
@@ -550,6 +616,9 @@ func (rc *reconnectingConnection) startCall(ctx context.Context, rpc *call, opts
 		if conn, ok := rc.connections[addr]; !ok || conn.ended {
 			c, err := rc.reconnect(ctx, endpoint)
 			if err != nil {
+				if observer, ok := balancer.(DialObserver); ok {
+					observer.DialFailed(endpoint, err)
+				}
 				connectErr = err
 				continue
 			}
@@ -560,11 +629,22 @@ func (rc *reconnectingConnection) startCall(ctx context.Context, rpc *call, opts
 		c.lastID++
 		rpc.id = c.lastID
 		c.calls[rpc.id] = rpc
+		if observer, ok := balancer.(CallObserver); ok {
+			observer.Started(endpoint)
+		}
 		return c, nil
 	}
 	return nil, connectErr
 }
 
+// balancer returns the Balancer to use for a call with the provided options.
+func (rc *reconnectingConnection) balancer(opts CallOptions) Balancer {
+	if opts.Balancer != nil {
+		return opts.Balancer
+	}
+	return rc.opts.Balancer
+}
+
 // reconnect establishes (or re-establishes) the network connection to the server.
 // REQUIRES: rc.mu is held.
 func (rc *reconnectingConnection) reconnect(ctx context.Context, endpoint Endpoint) (*clientConnection, error) {
@@ -572,15 +652,23 @@ func (rc *reconnectingConnection) reconnect(ctx context.Context, endpoint Endpoi
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", CommunicationError, err)
 	}
+	if rc.opts.TLSConfig != nil {
+		tc := tls.Client(nc, rc.opts.TLSConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("%w: TLS handshake: %s", CommunicationError, err)
+		}
+		nc = tc
+	}
 	conn := &clientConnection{
 		logger:   rc.opts.Logger,
 		endpoint: endpoint,
 		c:        nc,
 		cbuf:     bufio.NewReader(nc),
 		mu:       &rc.mu,
-		version:  initialVersion, // Updated when we hear from server
 		calls:    map[uint64]*call{},
 		lastID:   0,
+		flow:     newFlowLimiter(rc.opts.MaxInFlightCalls, rc.opts.MaxPendingCalls),
 	}
 	if err := writeVersion(conn.c, &conn.wlock); err != nil {
 		return nil, fmt.Errorf("%w: client send version: %s", CommunicationError, err)
@@ -661,15 +749,11 @@ func (c *clientConnection) readResponses() {
 
 		switch mt {
 		case versionMessage:
-			v, err := getVersion(id, msg)
-			if err != nil {
-				c.shutdown("client read", err)
+			if err := checkVersion(id, msg); err != nil {
+				c.shutdown("client read version", err)
 				return
 			}
-			c.mu.Lock()
-			c.version = v
-			c.mu.Unlock()
-		case responseMessage, responseError:
+		case responseMessage, responseError, compressedResponseMessage:
 			rpc := c.findAndEndCall(id)
 			if rpc == nil {
 				continue // May have been canceled
@@ -680,11 +764,30 @@ func (c *clientConnection) readResponses() {
 				} else {
 					rpc.err = fmt.Errorf("%w: could not decode error", CommunicationError)
 				}
+			} else if mt == compressedResponseMessage {
+				if len(msg) == 0 {
+					rpc.err = fmt.Errorf("%w: missing compression codec", CommunicationError)
+				} else if result, err := decompress(Codec(msg[0]), msg[1:]); err != nil {
+					rpc.err = fmt.Errorf("%w: decompress result: %v", CommunicationError, err)
+				} else {
+					rpc.response = result
+				}
 			} else {
 				rpc.response = msg
 			}
 			atomic.StoreUint32(&rpc.done, 1)
 			close(rpc.doneSignal)
+		case streamMessage, streamAckMessage:
+			c.mu.Lock()
+			rpc := c.calls[id]
+			c.mu.Unlock()
+			if rpc == nil || rpc.stream == nil {
+				continue // May have been canceled
+			}
+			if err := rpc.stream.handle(mt, msg); err != nil {
+				c.shutdown("client read", err)
+				return
+			}
 		default:
 			c.shutdown("client read", fmt.Errorf("invalid response %d", mt))
 			return
@@ -704,37 +807,56 @@ func (c *serverConnection) readRequests(ctx context.Context, hmap *HandlerMap, o
 
 		switch mt {
 		case versionMessage:
-			v, err := getVersion(id, msg)
-			if err != nil {
-				c.shutdown("server read version", err)
+			// Respond with my version, even if the client's version is not
+			// supported, so that the client reports the mismatch too.
+			if err := writeVersion(c.c, &c.wlock); err != nil {
+				c.shutdown("server send version", err)
 				onDone()
 				return
 			}
-			c.mu.Lock()
-			c.version = v
-			c.mu.Unlock()
-
-			// Respond with my version.
-			if err := writeVersion(c.c, &c.wlock); err != nil {
-				c.shutdown("server send version", err)
+			if err := checkVersion(id, msg); err != nil {
+				c.shutdown("server read version", err)
 				onDone()
 				return
 			}
 		case requestMessage:
-			if c.opts.InlineHandlerDuration > 0 {
+			// Reject the call right away if too many calls are pending on
+			// the connection, rather than keeping it in memory.
+			admitted, err := c.flow.enter()
+			if err != nil {
+				if err := writeMessage(c.c, &c.wlock, responseError, id, nil, encodeError(err), c.opts.WriteFlattenLimit); err != nil {
+					c.shutdown("server send overloaded", err)
+					onDone()
+					return
+				}
+				continue
+			}
+			if c.opts.InlineHandlerDuration > 0 && admitted == admittedNow {
 				// Run the handler inline. If it doesn't return in the specified
 				// time period, launch another goroutine to read incoming requests.
 				t := time.AfterFunc(c.opts.InlineHandlerDuration, func() {
 					c.readRequests(ctx, hmap, onDone)
 				})
-				c.runHandler(hmap, id, msg)
+				c.runHandler(hmap, id, msg, nil, admitted)
 				if !t.Stop() {
 					// Another goroutine is reading incoming requests: bail out.
 					return
 				}
 			} else {
 				// Run the handler in a separate goroutine.
-				go c.runHandler(hmap, id, msg)
+				go c.runHandler(hmap, id, msg, nil, admitted)
+			}
+		case streamRequestMessage:
+			// Register the stream before reading any more messages, since
+			// the following messages may be sent on the stream. Streaming
+			// handlers are never run inline, since they need this goroutine
+			// to keep reading messages.
+			go c.runHandler(hmap, id, msg, c.startStream(id), nil)
+		case streamMessage, streamCloseMessage, streamAckMessage:
+			if err := c.handleStream(mt, id, msg); err != nil {
+				c.shutdown("server read", err)
+				onDone()
+				return
 			}
 		case cancelMessage:
 			c.endRequest(id)
@@ -750,12 +872,26 @@ func (c *serverConnection) readRequests(ctx context.Context, hmap *HandlerMap, o
 
 // runHandler runs an application specified RPC handler at the server side.
 // The result (or error) from the handler is sent back to the client over c.
-func (c *serverConnection) runHandler(hmap *HandlerMap, id uint64, msg []byte) {
+// If stream is not nil, the call is a streaming call. If admitted is not nil,
+// it was returned by c.flow.enter, and the handler is run once it is closed.
+func (c *serverConnection) runHandler(hmap *HandlerMap, id uint64, msg []byte, stream *streamState, admitted chan struct{}) {
+	if stream != nil {
+		defer c.endStream(id)
+	}
+	if admitted != nil {
+		defer c.flow.leave(admitted)
+	}
+
 	// Extract request header from front of payload.
 	if len(msg) < msgHeaderSize {
 		c.shutdown("server handler", fmt.Errorf("missing request header"))
 		return
 	}
+	mdLen := int(binary.LittleEndian.Uint32(msg[msgHeaderSize-4:]))
+	if len(msg)-msgHeaderSize < mdLen {
+		c.shutdown("server handler", fmt.Errorf("truncated request metadata"))
+		return
+	}
 
 	// Extract handler key.
 	var hkey MethodKey
@@ -773,7 +909,7 @@ func (c *serverConnection) runHandler(hmap *HandlerMap, id uint64, msg []byte) {
 	// call on the server.
 	ctx := context.Background()
 	span := trace.SpanFromContext(ctx) // noop span
-	if sc := readTraceContext(msg[24:]); sc.IsValid() {
+	if sc := readTraceContext(msg[33:]); sc.IsValid() {
 		ctx, span = c.opts.Tracer.Start(trace.ContextWithSpanContext(ctx, sc), methodName, trace.WithSpanKind(trace.SpanKindServer))
 		defer span.End()
 	}
@@ -793,13 +929,40 @@ func (c *serverConnection) runHandler(hmap *HandlerMap, id uint64, msg []byte) {
 		}
 	}()
 
+	// Add the metadata sent by the caller, if any, to the context.
+	if mdLen > 0 {
+		md := make([]byte, mdLen)
+		copy(md, msg[msgHeaderSize:])
+		ctx = context.WithValue(ctx, metadataKey{}, md)
+	}
+	ctx = context.WithValue(ctx, priorityKey{}, Priority(msg[32]).normalize())
+
 	// Call the handler passing it the payload.
-	payload := msg[msgHeaderSize:]
+	payload := msg[msgHeaderSize+mdLen:]
+	compression := Compression{
+		Codec:     Codec(msg[msgHeaderSize-9] &^ compressedArgs),
+		Threshold: int(binary.LittleEndian.Uint32(msg[msgHeaderSize-8:])),
+	}
 	var err error
 	var result []byte
 	fn, ok := hmap.handlers[hkey]
+	if stream != nil {
+		var sfn StreamHandler
+		if sfn, ok = hmap.streams[hkey]; ok {
+			fn = func(ctx context.Context, args []byte) ([]byte, error) {
+				return nil, sfn(ctx, args, &serverStream{ctx: ctx, conn: c, id: id, state: stream})
+			}
+		}
+	}
 	if !ok {
 		err = fmt.Errorf("internal error: unknown function")
+	} else if want, got := hmap.versions[hkey], binary.LittleEndian.Uint64(msg[24:]); want != 0 && got != 0 && want != got {
+		// The caller was built against a different version of the method.
+		// Fail the call instead of attempting to decode arguments that may have
+		// been encoded differently.
+		err = fmt.Errorf("%w: method %s: caller version %016x, server version %016x", VersionMismatch, methodName, got, want)
+	} else if payload, err = decompressArgs(msg[msgHeaderSize-9], payload); err != nil {
+		err = fmt.Errorf("%w: decompress arguments: %v", CommunicationError, err)
 	} else {
 		if err := c.startRequest(id, cancelFunc); err != nil {
 			logError(c.opts.Logger, "handle "+hmap.names[hkey], err)
@@ -807,28 +970,60 @@ func (c *serverConnection) runHandler(hmap *HandlerMap, id uint64, msg []byte) {
 		}
 		cancelFunc = nil // endRequest() or cancellation will deal with it
 		defer c.endRequest(id)
-		result, err = fn(ctx, payload)
+		result, err = c.admitAndRun(ctx, Priority(msg[32]), admitted, fn, payload)
 	}
 
 	mt := responseMessage
+	var extraHdr []byte
 	if err != nil {
 		mt = responseError
 		result = encodeError(err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
+	} else if stream == nil {
+		// Compress the result as requested by the caller.
+		if compressed, ok := compression.maybeCompress(result); ok {
+			mt = compressedResponseMessage
+			extraHdr = []byte{byte(compression.Codec)}
+			result = compressed
+		}
 	}
 
-	if err := writeMessage(c.c, &c.wlock, mt, id, nil, result, c.opts.WriteFlattenLimit); err != nil {
+	if err := writeMessage(c.c, &c.wlock, mt, id, extraHdr, result, c.opts.WriteFlattenLimit); err != nil {
 		c.shutdown("server write "+hmap.names[hkey], err)
 	}
 }
 
+// admitAndRun runs fn on the provided payload once the call is in flight on
+// the connection, i.e., once admitted, if not nil, is closed, and once
+// admission control, if any, admits a call of priority p.
+func (c *serverConnection) admitAndRun(ctx context.Context, p Priority, admitted chan struct{}, fn Handler, payload []byte) ([]byte, error) {
+	if admitted != nil {
+		if err := waitAdmitted(ctx, admitted); err != nil {
+			return nil, err
+		}
+	}
+	if c.admit == nil {
+		return fn(ctx, payload)
+	}
+	if err := c.admit.acquire(ctx, p); err != nil {
+		return nil, err
+	}
+	defer c.admit.release()
+	return fn(ctx, payload)
+}
+
 func (c *serverConnection) startRequest(id uint64, cancelFunc func()) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.closed {
 		return fmt.Errorf("startRequest: %w", net.ErrClosed)
 	}
+	if s, ok := c.streams[id]; ok && s.cancelled {
+		// The client cancelled the streaming call before its handler
+		// started.
+		cancelFunc()
+	}
 	c.cancelFuncs[id] = cancelFunc
 	return nil
 }
@@ -836,6 +1031,9 @@ func (c *serverConnection) startRequest(id uint64, cancelFunc func()) error {
 func (c *serverConnection) endRequest(id uint64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if s, ok := c.streams[id]; ok {
+		s.cancelled = true
+	}
 	if cancelFunc, ok := c.cancelFuncs[id]; ok {
 		delete(c.cancelFuncs, id)
 		cancelFunc()
@@ -15,10 +15,13 @@
 package call_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"os"
@@ -68,7 +71,8 @@ func makeHandlerMap() *call.HandlerMap {
 }
 
 // startServers starts a new long-running server for each tested network
-// protocol (e.g., "tcp"), returning the endpoints for those servers.
+// protocol (e.g., "tcp"), returning the endpoints for those servers. QUIC is
+// only tested when built with the "quic" build tag.
 func startServers(ctx context.Context, opts call.ServerOptions) map[string]call.Endpoint {
 	// Start the server that uses the TCP protocol.
 	tcpListener, err := net.Listen("tcp", ":0")
@@ -78,9 +82,26 @@ func startServers(ctx context.Context, opts call.ServerOptions) map[string]call.
 	// TODO(mwhittaker): Use test logger.
 	go call.Serve(ctx, tcpListener, handlers, opts)
 
-	return map[string]call.Endpoint{
+	endpoints := map[string]call.Endpoint{
 		"tcp": call.TCP(tcpListener.Addr().String()),
 	}
+	if !call.QUICEnabled {
+		return endpoints
+	}
+
+	// Start the server that uses the QUIC protocol.
+	udpConn, err := net.ListenPacket("udp", "localhost:0")
+	if err != nil {
+		panic(err)
+	}
+	quicListener, err := call.ListenQUIC(udpConn, nil)
+	if err != nil {
+		panic(err)
+	}
+	go call.Serve(ctx, quicListener, handlers, opts)
+	quic := call.NewQUICTransport(func() (*tls.Config, error) { return nil, nil })
+	endpoints["quic"] = quic.Endpoint(quicListener.Addr().String())
+	return endpoints
 }
 
 const (
@@ -476,6 +497,9 @@ func TestSingleTCPServer(t *testing.T) {
 	}
 
 	protocols := []string{"tcp"}
+	if call.QUICEnabled {
+		protocols = append(protocols, "quic")
+	}
 	ctx := context.Background()
 	opts := call.ServerOptions{Logger: logging.NewTestLogger(t)}
 	endpoints := startServers(ctx, opts)
@@ -523,6 +547,277 @@ func TestTracePropagation(t *testing.T) {
 	}
 }
 
+// TestMetadataPropagation tests that call metadata is sent to the server
+// along with the call's arguments.
+func TestMetadataPropagation(t *testing.T) {
+	h := &call.HandlerMap{}
+	h.Set("", "metadata", func(ctx context.Context, arg []byte) ([]byte, error) {
+		return append(call.Metadata(ctx), arg...), nil
+	})
+	ep := pipeEndpoint{t: t, handlers: h}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t)}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	key := call.MakeMethodKey("", "metadata")
+	for _, md := range []string{"", "alice"} {
+		result, err := client.Call(context.Background(), key, []byte("|hello"), call.CallOptions{Metadata: []byte(md)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(result), md+"|hello"; got != want {
+			t.Fatalf("Call(%q): got %q, want %q", md, got, want)
+		}
+	}
+}
+
+// countingConn is a net.Conn that counts the bytes read and written.
+type countingConn struct {
+	net.Conn
+	read, written atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// TestCompression tests that call arguments and results are compressed as
+// per ClientOptions.Compression.
+func TestCompression(t *testing.T) {
+	large := []byte(strings.Repeat("hello, world! ", 10000))
+	for _, codec := range []call.Codec{call.NoCompression, call.Snappy, call.Zstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			client, server := pipe(t)
+			conn := &countingConn{Conn: client}
+			call.ServeOn(context.Background(), server, handlers, call.ServerOptions{Logger: logging.NewTestLogger(t)})
+			opts := call.ClientOptions{
+				Logger:      logging.NewTestLogger(t),
+				Compression: call.Compression{Codec: codec, Threshold: 1024},
+			}
+			c, err := call.Connect(context.Background(), call.NewConstantResolver(&connEndpoint{name: "server", conn: conn}), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			for _, arg := range [][]byte{[]byte("small"), large} {
+				read, written := conn.read.Load(), conn.written.Load()
+				result, err := c.Call(context.Background(), echoKey, arg, call.CallOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(result, arg) {
+					t.Fatalf("Call(%d bytes): got %d bytes, want the argument back", len(arg), len(result))
+				}
+				read, written = conn.read.Load()-read, conn.written.Load()-written
+				compressed := codec != call.NoCompression && len(arg) >= opts.Compression.Threshold
+				for _, n := range []int64{read, written} {
+					if got, want := n < int64(len(arg)), compressed; got != want {
+						t.Errorf("Call(%d bytes): sent %d bytes and received %d bytes, want compressed = %t", len(arg), written, read, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestVersionMismatch tests that a call made with a method version that
+// differs from the server's version fails with a VersionMismatch error.
+func TestVersionMismatch(t *testing.T) {
+	h := &call.HandlerMap{}
+	h.SetVersioned("", "echo", 42, echoHandler)
+	ep := pipeEndpoint{t: t, handlers: h}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t)}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, test := range []struct {
+		version  uint64
+		mismatch bool
+	}{
+		{0, false},  // unversioned callers are not checked
+		{42, false}, // matching versions
+		{43, true},  // mismatched versions
+	} {
+		t.Run(fmt.Sprint(test.version), func(t *testing.T) {
+			_, err := client.Call(context.Background(), echoKey, []byte("hello"), call.CallOptions{Version: test.version})
+			if got, want := errors.Is(err, call.VersionMismatch), test.mismatch; got != want {
+				t.Fatalf("errors.Is(%v, VersionMismatch) = %t, want %t", err, got, want)
+			}
+		})
+	}
+}
+
+func TestShedLoad(t *testing.T) {
+	// Test plan: Run a server that runs one call at a time and queues no
+	// calls. Block the server with a call, and check that a concurrent call
+	// is shed.
+	block := make(chan struct{})
+	h := &call.HandlerMap{}
+	h.Set("", "block", func(context.Context, []byte) ([]byte, error) {
+		<-block
+		return nil, nil
+	})
+	h.Set("", "echo", echoHandler)
+
+	clientConn, serverConn := pipe(t)
+	call.ServeOn(context.Background(), serverConn, h, call.ServerOptions{
+		Logger:             logging.NewTestLogger(t),
+		MaxConcurrentCalls: 1,
+	})
+	ep := connEndpoint{name: "shed", conn: clientConn}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t)}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	blocked := make(chan error)
+	go func() {
+		_, err := client.Call(context.Background(), call.MakeMethodKey("", "block"), nil, call.CallOptions{})
+		blocked <- err
+	}()
+
+	// Wait for the blocking call to occupy the server.
+	opt := call.CallOptions{Priority: call.HighPriority}
+	waitUntil(t, func() bool {
+		_, err := client.Call(context.Background(), echoKey, []byte("hello"), opt)
+		return errors.Is(err, call.ShedLoad)
+	})
+
+	close(block)
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Call(context.Background(), echoKey, []byte("hello"), opt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerOverloaded(t *testing.T) {
+	// Test plan: Run a server that runs one call per connection at a time
+	// and keeps one more pending. Block the server with two calls, and check
+	// that a third call is rejected as overloaded.
+	block := make(chan struct{})
+	h := &call.HandlerMap{}
+	h.Set("", "block", func(context.Context, []byte) ([]byte, error) {
+		<-block
+		return nil, nil
+	})
+	h.Set("", "echo", echoHandler)
+
+	clientConn, serverConn := pipe(t)
+	call.ServeOn(context.Background(), serverConn, h, call.ServerOptions{
+		Logger:           logging.NewTestLogger(t),
+		MaxInFlightCalls: 1,
+		MaxPendingCalls:  1,
+	})
+	ep := connEndpoint{name: "overloaded", conn: clientConn}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t)}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	blocked := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Call(context.Background(), call.MakeMethodKey("", "block"), nil, call.CallOptions{})
+			blocked <- err
+		}()
+	}
+
+	// Wait for the blocking calls to occupy the connection. Echo calls that
+	// end up pending time out.
+	waitUntil(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), shortDelay)
+		defer cancel()
+		_, err := client.Call(ctx, echoKey, []byte("hello"), call.CallOptions{})
+		return errors.Is(err, call.Overloaded)
+	})
+
+	close(block)
+	for i := 0; i < 2; i++ {
+		if err := <-blocked; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Call(context.Background(), echoKey, []byte("hello"), call.CallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientOverloaded(t *testing.T) {
+	// Test plan: Run a client that sends one call per connection at a time
+	// and keeps no calls pending. Block the server with a call, and check
+	// that a concurrent call fails as overloaded, without being sent.
+	block := make(chan struct{})
+	var echoes int64
+	h := &call.HandlerMap{}
+	h.Set("", "block", func(context.Context, []byte) ([]byte, error) {
+		<-block
+		return nil, nil
+	})
+	h.Set("", "echo", func(ctx context.Context, arg []byte) ([]byte, error) {
+		atomic.AddInt64(&echoes, 1)
+		return arg, nil
+	})
+
+	clientConn, serverConn := pipe(t)
+	call.ServeOn(context.Background(), serverConn, h, call.ServerOptions{Logger: logging.NewTestLogger(t)})
+	ep := connEndpoint{name: "overloaded", conn: clientConn}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t), MaxInFlightCalls: 1}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	blocked := make(chan error)
+	go func() {
+		_, err := client.Call(context.Background(), call.MakeMethodKey("", "block"), nil, call.CallOptions{})
+		blocked <- err
+	}()
+
+	// Wait for the blocking call to occupy the connection. Echo calls sent
+	// before it are run.
+	var sent int64
+	waitUntil(t, func() bool {
+		_, err := client.Call(context.Background(), echoKey, []byte("hello"), call.CallOptions{})
+		if err == nil {
+			sent++
+		}
+		return errors.Is(err, call.Overloaded)
+	})
+	if got := atomic.LoadInt64(&echoes); got != sent {
+		t.Errorf("echo calls run: got %d, want %d", got, sent)
+	}
+
+	close(block)
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Call(context.Background(), echoKey, []byte("hello"), call.CallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestMultipleEndpoints tests that RPC calls succeed when the resolver returns
 // a constant set of multiple endpoints.
 func TestMultipleEndpoints(t *testing.T) {
@@ -1539,3 +1834,189 @@ func sizeString(s int) string {
 	}
 	return fmt.Sprint(s)
 }
+
+// streamClient returns a client connected to a server running the provided
+// handlers.
+func streamClient(t *testing.T, h *call.HandlerMap) call.Connection {
+	t.Helper()
+	ep := pipeEndpoint{t: t, handlers: h}
+	opts := call.ClientOptions{Logger: logging.NewTestLogger(t)}
+	client, err := call.Connect(context.Background(), call.NewConstantResolver(&ep), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestStream(t *testing.T) {
+	// Test plan: Run a streaming handler that echoes every message it
+	// receives, prefixed by the call's argument. Send more messages than fit
+	// in a stream window while concurrently receiving the echoes.
+	h := &call.HandlerMap{}
+	h.SetStream("", "echo", 0, func(_ context.Context, arg []byte, s call.ServerStream) error {
+		for {
+			msg, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.Send(append(append([]byte{}, arg...), msg...)); err != nil {
+				return err
+			}
+		}
+	})
+	client := streamClient(t, h)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	s, err := client.Stream(ctx, echoKey, []byte("echo:"), call.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	const n = 1000
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := s.Send([]byte(strconv.Itoa(i))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := s.CloseSend(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for i := 0; i < n; i++ {
+		msg, err := s.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(msg), fmt.Sprintf("echo:%d", i); got != want {
+			t.Fatalf("Recv: got %q, want %q", got, want)
+		}
+	}
+	if _, err := s.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv: got %v, want io.EOF", err)
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	// Test plan: Run a streaming handler that sends many messages. Check that
+	// the handler is blocked while the client doesn't receive them, and that
+	// the client then receives all of them.
+	const n = 1000
+	var sent atomic.Int64
+	h := &call.HandlerMap{}
+	h.SetStream("", "send", 0, func(_ context.Context, _ []byte, s call.ServerStream) error {
+		for i := 0; i < n; i++ {
+			if err := s.Send([]byte(strconv.Itoa(i))); err != nil {
+				return err
+			}
+			sent.Add(1)
+		}
+		return nil
+	})
+	client := streamClient(t, h)
+
+	s, err := client.Stream(context.Background(), call.MakeMethodKey("", "send"), nil, call.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Wait for the handler to stop sending.
+	waitUntil(t, func() bool {
+		before := sent.Load()
+		time.Sleep(shortDelay)
+		return before > 0 && before == sent.Load()
+	})
+	if got := sent.Load(); got >= n {
+		t.Fatalf("handler sent %d messages before any was received", got)
+	}
+
+	for i := 0; i < n; i++ {
+		msg, err := s.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(msg), strconv.Itoa(i); got != want {
+			t.Fatalf("Recv: got %q, want %q", got, want)
+		}
+	}
+	if _, err := s.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv: got %v, want io.EOF", err)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	h := &call.HandlerMap{}
+	h.SetStream("", "error", 0, func(_ context.Context, arg []byte, s call.ServerStream) error {
+		if err := s.Send(arg); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", os.ErrInvalid, string(arg))
+	})
+	client := streamClient(t, h)
+
+	s, err := client.Stream(context.Background(), errorKey, []byte("oops"), call.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Messages sent before the handler failed are received first.
+	if msg, err := s.Recv(); err != nil || string(msg) != "oops" {
+		t.Fatalf("Recv: got (%q, %v), want (%q, nil)", msg, err, "oops")
+	}
+	if _, err := s.Recv(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("Recv: got %v, want os.ErrInvalid", err)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	// Test plan: Start streaming calls to a handler that blocks until it is
+	// cancelled. Check that closing the stream, or cancelling its context,
+	// cancels the handler.
+	cancelled := make(chan struct{}, 2)
+	h := &call.HandlerMap{}
+	h.SetStream("", "cancelwait", 0, func(ctx context.Context, _ []byte, s call.ServerStream) error {
+		_, err := s.Recv()
+		if ctx.Err() != nil {
+			cancelled <- struct{}{}
+		}
+		return err
+	})
+	client := streamClient(t, h)
+
+	for _, test := range []struct {
+		name string
+		end  func(s call.ClientStream, cancel context.CancelFunc)
+	}{
+		{"Close", func(s call.ClientStream, _ context.CancelFunc) { s.Close() }},
+		{"Context", func(_ call.ClientStream, cancel context.CancelFunc) { cancel() }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s, err := client.Stream(ctx, cancelWaitKey, nil, call.CallOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			test.end(s, cancel)
+			select {
+			case <-cancelled:
+			case <-time.After(testTimeout):
+				t.Fatal("handler not cancelled")
+			}
+			if _, err := s.Recv(); err == nil {
+				t.Fatal("Recv: unexpected success")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A Codec is an algorithm used to compress call payloads.
+type Codec uint8
+
+const (
+	NoCompression Codec = iota
+	Snappy
+	Zstd
+)
+
+// Compression specifies how the arguments and results of calls are compressed.
+//
+// A client compresses the arguments of its calls, and the server compresses
+// their results, using Codec. Payloads smaller than Threshold bytes, and
+// payloads that don't get smaller when compressed, are sent uncompressed.
+// Streaming calls are never compressed.
+type Compression struct {
+	Codec     Codec
+	Threshold int
+}
+
+// ParseCodec returns the codec with the provided name: "none", "snappy", or
+// "zstd". The empty string is treated as "none".
+func ParseCodec(name string) (Codec, error) {
+	switch name {
+	case "", "none":
+		return NoCompression, nil
+	case "snappy":
+		return Snappy, nil
+	case "zstd":
+		return Zstd, nil
+	default:
+		return NoCompression, fmt.Errorf("unknown compression codec %q", name)
+	}
+}
+
+// String returns the name of the codec, as accepted by ParseCodec.
+func (c Codec) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Snappy:
+		return "snappy"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Codec(%d)", c)
+	}
+}
+
+var (
+	// zstd encoders and decoders are expensive to create, but are safe for
+	// concurrent use by EncodeAll and DecodeAll, so we share a single one of
+	// each.
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// initZstd initializes zstdEncoder and zstdDecoder.
+func initZstd() {
+	zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if zstdErr != nil {
+		return
+	}
+	zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxMessageSize), zstd.WithDecoderConcurrency(0))
+}
+
+// maybeCompress returns data compressed as per c, and whether it was
+// compressed. It returns data itself if data is smaller than c.Threshold, or
+// if compression doesn't make it smaller.
+func (c Compression) maybeCompress(data []byte) ([]byte, bool) {
+	if c.Codec == NoCompression || len(data) < c.Threshold {
+		return data, false
+	}
+	var compressed []byte
+	switch c.Codec {
+	case Snappy:
+		compressed = snappy.Encode(nil, data)
+	case Zstd:
+		if zstdOnce.Do(initZstd); zstdErr != nil {
+			return data, false
+		}
+		compressed = zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)))
+	default:
+		return data, false
+	}
+	if len(compressed) >= len(data) {
+		return data, false
+	}
+	return compressed, true
+}
+
+// decompressArgs returns the call arguments in payload, decompressed if the
+// compression byte of the request header says so.
+func decompressArgs(compression byte, payload []byte) ([]byte, error) {
+	if compression&compressedArgs == 0 {
+		return payload, nil
+	}
+	return decompress(Codec(compression&^compressedArgs), payload)
+}
+
+// decompress returns data decompressed with the provided codec.
+func decompress(codec Codec, data []byte) ([]byte, error) {
+	switch codec {
+	case Snappy:
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxMessageSize {
+			return nil, fmt.Errorf("overly large decompressed length %d", n)
+		}
+		return snappy.Decode(nil, data)
+	case Zstd:
+		if zstdOnce.Do(initZstd); zstdErr != nil {
+			return nil, zstdErr
+		}
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression codec %d", codec)
+	}
+}
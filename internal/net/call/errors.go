@@ -32,6 +32,31 @@ const (
 	// server is unreachable. Check for it via errors.Is(call.Unreachable).
 	Unreachable
 
+	// VersionMismatch is the type of the error returned by a call when the
+	// caller and the server were built with different versions of the called
+	// method. Check for it via errors.Is(call.VersionMismatch).
+	VersionMismatch
+
+	// ShedLoad is the type of the error returned by a call when the server
+	// is overloaded and rejected the call without running it. Check for it
+	// via errors.Is(call.ShedLoad).
+	ShedLoad
+
+	// Overloaded is the type of the error returned by a call when too many
+	// calls are in flight, or waiting to be in flight, on its connection.
+	// The call is not run. Check for it via errors.Is(call.Overloaded).
+	Overloaded
+
+	// PermissionDenied is the type of the error returned by a call when the
+	// server rejected the call, without running it, because the caller isn't
+	// allowed to make it. Check for it via errors.Is(call.PermissionDenied).
+	PermissionDenied
+
+	// PayloadTooLarge is the type of the error returned by a call when its
+	// arguments or results are larger than the limit of the called method.
+	// Check for it via errors.Is(call.PayloadTooLarge).
+	PayloadTooLarge
+
 	// TODO: Decide what error most applications will want to check for. We may
 	// need to combine CommunicationError and Unreachable. We may also want to
 	// make errors.Is(CommunicationError) return true for both types of errors.
@@ -45,6 +70,16 @@ func (e transportError) Error() string {
 		return "communication error"
 	case Unreachable:
 		return "unreachable"
+	case VersionMismatch:
+		return "version mismatch"
+	case ShedLoad:
+		return "load shed"
+	case Overloaded:
+		return "overloaded"
+	case PermissionDenied:
+		return "permission denied"
+	case PayloadTooLarge:
+		return "payload too large"
 	default:
 		return fmt.Sprintf("unknown error %d", e)
 	}
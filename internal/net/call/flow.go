@@ -0,0 +1,135 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flowLimiter limits the calls in flight on a single connection. At most
+// maxInFlight calls are in flight at a time, and at most maxPending
+// additional calls wait, in arrival order, for an in-flight call to finish.
+// Calls that arrive when maxPending calls are waiting fail with an Overloaded
+// error.
+//
+// Unlike an admitter, which is shared by all the connections of a server and
+// orders calls by priority, a flowLimiter applies backpressure to a single
+// peer, so that a slow server or a busy client can't pile up an unbounded
+// number of calls on a connection.
+type flowLimiter struct {
+	maxInFlight int
+	maxPending  int
+
+	mu       sync.Mutex
+	inFlight int             // number of calls in flight
+	pending  []chan struct{} // waiting calls, closed when admitted
+}
+
+// newFlowLimiter returns a new flowLimiter, or nil if maxInFlight is not
+// positive, in which case calls aren't limited.
+func newFlowLimiter(maxInFlight, maxPending int) *flowLimiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &flowLimiter{maxInFlight: maxInFlight, maxPending: maxPending}
+}
+
+// admittedNow is the closed channel returned by enter for calls that are in
+// flight right away.
+var admittedNow = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// enter registers a new call. It returns an Overloaded error if too many calls
+// are waiting already. Otherwise, it returns a channel that is closed once the
+// call is in flight, and the caller must call leave with the channel once the
+// call finishes, whether or not it got in flight. enter never blocks.
+func (f *flowLimiter) enter() (chan struct{}, error) {
+	if f == nil {
+		return admittedNow, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.inFlight < f.maxInFlight && len(f.pending) == 0 {
+		f.inFlight++
+		return admittedNow, nil
+	}
+	if len(f.pending) >= f.maxPending {
+		return nil, fmt.Errorf("%w: %d calls in flight and %d pending on the connection", Overloaded, f.inFlight, len(f.pending))
+	}
+	admitted := make(chan struct{})
+	f.pending = append(f.pending, admitted)
+	return admitted, nil
+}
+
+// leave marks a call registered by enter as finished.
+func (f *flowLimiter) leave(admitted chan struct{}) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-admitted:
+		f.inFlight--
+		f.admitNext()
+	default:
+		f.remove(admitted)
+	}
+}
+
+// waitAdmitted waits until the provided channel, returned by enter, is
+// closed. It returns ctx.Err() if ctx is done first.
+func waitAdmitted(ctx context.Context, admitted chan struct{}) error {
+	select {
+	case <-admitted:
+		return nil
+	default:
+	}
+	select {
+	case <-admitted:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// admitNext admits waiting calls, oldest first, while there is room for them.
+//
+// REQUIRES: f.mu is held.
+func (f *flowLimiter) admitNext() {
+	for len(f.pending) > 0 && f.inFlight < f.maxInFlight {
+		admitted := f.pending[0]
+		f.pending = f.pending[1:]
+		f.inFlight++
+		close(admitted)
+	}
+}
+
+// remove removes the waiting call with the provided channel.
+//
+// REQUIRES: f.mu is held.
+func (f *flowLimiter) remove(admitted chan struct{}) {
+	for i, x := range f.pending {
+		if x == admitted {
+			f.pending = append(f.pending[:i:i], f.pending[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// isClosed returns whether c is closed.
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFlowLimiter(t *testing.T) {
+	// Test plan: Fill a limiter with two calls in flight and one pending
+	// call, check that another call is overloaded, and check that pending
+	// calls get in flight, in order, as in-flight calls leave.
+	f := newFlowLimiter(2, 1)
+	var calls []chan struct{}
+	for i := 0; i < 3; i++ {
+		admitted, err := f.enter()
+		if err != nil {
+			t.Fatalf("enter %d: %v", i, err)
+		}
+		calls = append(calls, admitted)
+	}
+	if !isClosed(calls[0]) || !isClosed(calls[1]) {
+		t.Fatal("first two calls not in flight")
+	}
+	if isClosed(calls[2]) {
+		t.Fatal("third call in flight, want pending")
+	}
+	if _, err := f.enter(); !errors.Is(err, Overloaded) {
+		t.Fatalf("enter: got %v, want Overloaded", err)
+	}
+
+	f.leave(calls[0])
+	if !isClosed(calls[2]) {
+		t.Fatal("pending call not in flight after another call left")
+	}
+	admitted, err := f.enter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A pending call that leaves frees its spot without getting in flight.
+	f.leave(admitted)
+	if _, err := f.enter(); err != nil {
+		t.Fatalf("enter after a pending call left: %v", err)
+	}
+}
+
+func TestFlowLimiterWaitCancel(t *testing.T) {
+	f := newFlowLimiter(1, 1)
+	first, err := f.enter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := f.enter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitAdmitted(ctx, second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("waitAdmitted: got %v, want context.Canceled", err)
+	}
+	f.leave(second)
+	f.leave(first)
+	if f.inFlight != 0 || len(f.pending) != 0 {
+		t.Fatalf("got %d in flight and %d pending, want none", f.inFlight, len(f.pending))
+	}
+}
+
+func TestNilFlowLimiter(t *testing.T) {
+	f := newFlowLimiter(0, 10)
+	if f != nil {
+		t.Fatal("newFlowLimiter(0, 10) not nil")
+	}
+	for i := 0; i < 100; i++ {
+		admitted, err := f.enter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := waitAdmitted(context.Background(), admitted); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
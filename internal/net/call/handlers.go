@@ -37,20 +37,71 @@ func MakeMethodKey(component, method string) MethodKey {
 // successfully.
 type Handler func(ctx context.Context, args []byte) ([]byte, error)
 
+// metadataKey is the context key for the metadata sent by a caller.
+type metadataKey struct{}
+
+// Metadata returns the metadata sent by the caller (see CallOptions.Metadata)
+// of the call being handled with the provided context, or nil if there is
+// none.
+func Metadata(ctx context.Context) []byte {
+	md, _ := ctx.Value(metadataKey{}).([]byte)
+	return md
+}
+
+// priorityKey is the context key for the priority of a call.
+type priorityKey struct{}
+
+// CallPriority returns the priority (see CallOptions.Priority) of the call
+// being handled with the provided context. Unspecified priorities are
+// returned as NormalPriority.
+func CallPriority(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p.normalize()
+}
+
 // HandlerMap is a mapping from MethodID to a Handler. The zero value for a
 // HandlerMap is an empty map.
 type HandlerMap struct {
 	handlers map[MethodKey]Handler
+	streams  map[MethodKey]StreamHandler
 	names    map[MethodKey]string
+	versions map[MethodKey]uint64
 }
 
 // Set registers a handler for the specified method of component.
 func (hm *HandlerMap) Set(component, method string, handler Handler) {
-	if hm.handlers == nil {
+	hm.SetVersioned(component, method, 0, handler)
+}
+
+// SetVersioned registers a handler for the specified version of the specified
+// method of component. A call that carries a non-zero version different from
+// version fails with a VersionMismatch error without invoking the handler. A
+// zero version disables the check.
+func (hm *HandlerMap) SetVersioned(component, method string, version uint64, handler Handler) {
+	fp := hm.add(component, method, version)
+	hm.handlers[fp] = handler
+}
+
+// SetStream registers a handler for streaming calls of the specified version
+// of the specified method of component. Versions are checked as in
+// SetVersioned. A method can have both a Handler and a StreamHandler: the
+// former handles Call, the latter Stream.
+func (hm *HandlerMap) SetStream(component, method string, version uint64, handler StreamHandler) {
+	fp := hm.add(component, method, version)
+	hm.streams[fp] = handler
+}
+
+// add records the name and version of the specified method of component, and
+// returns its key.
+func (hm *HandlerMap) add(component, method string, version uint64) MethodKey {
+	if hm.names == nil {
 		hm.handlers = map[MethodKey]Handler{}
+		hm.streams = map[MethodKey]StreamHandler{}
 		hm.names = map[MethodKey]string{}
+		hm.versions = map[MethodKey]uint64{}
 	}
 	fp := MakeMethodKey(component, method)
-	hm.handlers[fp] = handler
 	hm.names[fp] = component + "." + method
+	hm.versions[fp] = version
+	return fp
 }
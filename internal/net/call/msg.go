@@ -31,6 +31,11 @@ const (
 	responseMessage
 	responseError
 	cancelMessage
+	streamRequestMessage
+	streamMessage
+	streamCloseMessage
+	streamAckMessage
+	compressedResponseMessage
 	// Other types to add?
 	// - chunked request/response messages?
 	// - health check
@@ -42,9 +47,19 @@ type version uint32
 
 const (
 	initialVersion version = iota
+	methodVersionVersion
+	priorityVersion
+	streamingVersion
+	metadataVersion    // requests carry metadata
+	compressionVersion // requests carry a codec and threshold; compressed responses
 )
 
-const currentVersion = initialVersion
+// currentVersion is the version of the protocol spoken by this package. Only
+// the message formats of currentVersion (see below) are implemented, so both
+// sides of a connection must speak it: the handshake of a connection to a
+// peer that speaks another version fails (see checkVersion). Every change to
+// the message formats must bump currentVersion.
+const currentVersion = compressionVersion
 
 // # Message formats
 //
@@ -62,17 +77,46 @@ const currentVersion = initialVersion
 // requestMessage:
 //    headerKey    [16]byte   -- fingerprint of method name
 //    deadline      [8]byte   -- zero, or deadline in microseconds
+//    version       [8]byte   -- zero, or fingerprint of method signature
+//    priority      [1]byte   -- zero, or priority class of the call
 //    traceContext [25]byte   -- zero, or trace context
+//    compression   [1]byte   -- codec accepted by the caller; high bit set if
+//                               the call argument serialization is compressed
+//    threshold     [4]byte   -- minimum size of a compressed call result
+//    metadataLen   [4]byte   -- length of metadata
+//    metadata   [metadataLen]byte -- metadata sent by the caller
 //    remainder               -- call argument serialization
 //
 // responseMessage:
 //    payload holds call result serialization
 //
+// compressedResponseMessage:
+//    codec         [1]byte   -- codec used to compress the call result
+//    remainder               -- compressed call result serialization
+//
 // responseError:
 //    payload holds error serialization
 //
 // cancelMessage:
 //    payload is empty
+//
+// streamRequestMessage: starts a streaming call.
+//    payload has the same format as requestMessage
+//
+// streamMessage: a message sent on a streaming call, in either direction.
+//    payload holds the message
+//
+// streamCloseMessage: sent by the client when it has no more messages to
+// send on a streaming call.
+//    payload is empty
+//
+// streamAckMessage: sent by the receiver of stream messages, in either
+// direction, to grant the sender credit for more messages.
+//    count    [8]byte  -- number of messages received since the last ack
+//
+// A streaming call ends with a responseMessage (with an empty payload) or a
+// responseError sent by the server. Every side of a streaming call starts
+// with streamWindow credits, and sends at most one streamMessage per credit.
 
 // writeMessage formats and sends a message over w.
 //
@@ -142,6 +186,10 @@ func writeFlat(w io.Writer, wlock *sync.Mutex, mt messageType, id uint64, extraH
 	return err
 }
 
+// maxMessageSize is the maximum size of a message, before or after
+// decompression.
+const maxMessageSize = 100 << 20
+
 // readMessage reads, parses, and returns the next message from r.
 func readMessage(r io.Reader) (messageType, uint64, []byte, error) {
 	// Read the header.
@@ -156,8 +204,7 @@ func readMessage(r io.Reader) (messageType, uint64, []byte, error) {
 	w2 := binary.LittleEndian.Uint64(hdr[8:])
 	mt := messageType(w2 & 0xff)
 	dataLen := w2 >> 8
-	const maxSize = 100 << 20
-	if dataLen > maxSize {
+	if dataLen > maxMessageSize {
 		return 0, 0, nil, fmt.Errorf("overly large message length %d", dataLen)
 	}
 
@@ -176,21 +223,20 @@ func writeVersion(w io.Writer, wlock *sync.Mutex) error {
 	return writeFlat(w, wlock, versionMessage, 0, nil, msg[:])
 }
 
-// getVersion extracts the version number sent by the peer and picks the
-// appropriate version number to use for communicating with the peer.
-func getVersion(id uint64, msg []byte) (version, error) {
+// checkVersion checks the version number sent by the peer in its handshake.
+// It fails if the peer speaks an older version of the protocol, whose messages
+// we can't parse. A peer that speaks a newer version either speaks
+// currentVersion too, or fails the handshake likewise.
+func checkVersion(id uint64, msg []byte) error {
 	if id != 0 {
-		return 0, fmt.Errorf("invalid ID %d in handshake", id)
+		return fmt.Errorf("invalid ID %d in handshake", id)
 	}
 	// Allow messages longer than needed so that future updates can send more info.
 	if len(msg) < 4 {
-		return 0, fmt.Errorf("bad version message length %d, must be >= 4", len(msg))
+		return fmt.Errorf("bad version message length %d, must be >= 4", len(msg))
 	}
-	v := binary.LittleEndian.Uint32(msg)
-
-	// We use the minimum of the peer and my version numbers.
-	if v < uint32(currentVersion) {
-		return version(v), nil
+	if v := binary.LittleEndian.Uint32(msg); v < uint32(currentVersion) {
+		return fmt.Errorf("call protocol version mismatch: peer speaks version %d, want version %d; all the processes of a deployment must be built with the same version of Service Weaver", v, currentVersion)
 	}
-	return currentVersion, nil
+	return nil
 }
@@ -16,12 +16,17 @@ package call
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/exp/slog"
 )
 
 func TestConcurrentWrites(t *testing.T) {
@@ -148,3 +153,61 @@ func sizeString(s int) string {
 	}
 	return fmt.Sprint(s)
 }
+
+// versionMsg returns the payload of a versionMessage with the provided version.
+func versionMsg(v version) []byte {
+	var msg [4]byte
+	binary.LittleEndian.PutUint32(msg[:], uint32(v))
+	return msg[:]
+}
+
+func TestCheckVersion(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		id   uint64
+		msg  []byte
+		ok   bool
+	}{
+		{"current", 0, versionMsg(currentVersion), true},
+		{"newer", 0, versionMsg(currentVersion + 1), true},
+		{"older", 0, versionMsg(currentVersion - 1), false},
+		{"initial", 0, versionMsg(initialVersion), false},
+		{"bad id", 1, versionMsg(currentVersion), false},
+		{"short", 0, []byte{1}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := checkVersion(test.id, test.msg); (err == nil) != test.ok {
+				t.Fatalf("checkVersion: got %v, want ok %t", err, test.ok)
+			}
+		})
+	}
+}
+
+func TestServerRejectsOlderVersion(t *testing.T) {
+	// Test plan: Send the handshake of a client that speaks an older version
+	// of the protocol to a server. Check that the server replies with its
+	// own version, so that the client can detect the mismatch, and then
+	// closes the connection.
+	client, server := net.Pipe()
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := ServerOptions{Logger: slog.New(slog.NewTextHandler(io.Discard))}
+	go ServeOn(ctx, server, &HandlerMap{}, opts)
+
+	var wlock sync.Mutex
+	go writeFlat(client, &wlock, versionMessage, 0, nil, versionMsg(currentVersion-1)) //nolint:errcheck // checked by the reads below
+	mt, id, msg, err := readMessage(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != versionMessage {
+		t.Fatalf("got message type %d, want versionMessage", mt)
+	}
+	if id != 0 || !bytes.Equal(msg, versionMsg(currentVersion)) {
+		t.Fatalf("got version message (%d, %v), want (0, %v)", id, msg, versionMsg(currentVersion))
+	}
+	if _, _, _, err := readMessage(client); err == nil {
+		t.Fatal("server didn't close the connection")
+	}
+}
@@ -15,6 +15,7 @@
 package call
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/ServiceWeaver/weaver/internal/traceio"
@@ -38,6 +39,22 @@ type ClientOptions struct {
 	// If non-zero, all writes smaller than this limit are flattened into
 	// a single buffer before being written on the connection.
 	WriteFlattenLimit int
+
+	// If not nil, connections to servers are secured with TLS, using
+	// TLSConfig. The servers must be configured with TLS as well.
+	TLSConfig *tls.Config
+
+	// Compression of the arguments and results of calls. Servers compress
+	// results as requested by the client.
+	Compression Compression
+
+	// If positive, at most MaxInFlightCalls calls are in flight on every
+	// connection to a server, and at most MaxPendingCalls additional calls
+	// wait, in call order, to be sent on the connection. Calls made when
+	// MaxPendingCalls calls are waiting fail with an Overloaded error,
+	// without being sent. Streaming calls are not limited.
+	MaxInFlightCalls int
+	MaxPendingCalls  int
 }
 
 // ServerOption are the options to configure an RPC server.
@@ -55,6 +72,31 @@ type ServerOptions struct {
 	// If non-zero, all writes smaller than this limit are flattened into
 	// a single buffer before being written on the connection.
 	WriteFlattenLimit int
+
+	// If positive, at most MaxConcurrentCalls calls are run at a time, and
+	// every priority class queues at most MaxQueuedCalls additional calls.
+	// Calls that arrive when the queue of their priority class is full fail
+	// with a ShedLoad error. Queued calls are run highest priority first.
+	MaxConcurrentCalls int
+	MaxQueuedCalls     int
+
+	// If not nil, OnShed is called with the priority of every call that fails
+	// with a ShedLoad error.
+	OnShed func(Priority)
+
+	// If positive, at most MaxInFlightCalls calls received on every
+	// connection are run, or admitted by MaxConcurrentCalls, at a time, and
+	// at most MaxPendingCalls additional calls received on the connection
+	// wait, in arrival order. Calls that arrive when MaxPendingCalls calls
+	// are waiting fail with an Overloaded error, without being run. Unlike
+	// MaxConcurrentCalls, these limits bound the calls that a single client
+	// can pile up on a server. Streaming calls are not limited.
+	MaxInFlightCalls int
+	MaxPendingCalls  int
+
+	// If not nil, connections from clients are secured with TLS, using
+	// TLSConfig. The clients must be configured with TLS as well.
+	TLSConfig *tls.Config
 }
 
 // CallOptions are call-specific options.
@@ -66,10 +108,35 @@ type CallOptions struct {
 	// change to *uint64 for example.
 	ShardKey uint64
 
+	// Affinity, if not 0, asks the Balancer to send calls with the same
+	// affinity to the same endpoint, as long as the set of endpoints doesn't
+	// change. It is only used by calls without a ShardKey, and a Balancer can
+	// always choose to ignore it.
+	Affinity uint64
+
 	// Balancer, if not nil, is the Balancer to use for a call, instead of the
 	// Balancer that the client was constructed with (provided in
 	// ClientOptions).
 	Balancer Balancer
+
+	// Version, if not 0, is the version of the method being called. If the
+	// server has registered a different non-zero version of the method, the
+	// call fails with a VersionMismatch error.
+	Version uint64
+
+	// Priority is the priority class of the call. It is used by servers that
+	// perform admission control (see ServerOptions.MaxConcurrentCalls) to
+	// decide which calls to run, and which to shed, under load.
+	Priority Priority
+
+	// Hedge, if not nil, is the group of hedged calls the call belongs to.
+	// The call is sent to an endpoint that no other call in the group was
+	// sent to, if the Balancer picks one within a few tries.
+	Hedge *HedgeGroup
+
+	// Metadata, if not empty, is sent to the server along with the call.
+	// The server handler can read it using the Metadata function.
+	Metadata []byte
 }
 
 // withDefaults returns a copy of the ClientOptions with zero values replaced
@@ -0,0 +1,305 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build quic
+
+package call
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// QUICEnabled is true if the QUIC transport is compiled in, i.e., if the
+// binary is built with the "quic" build tag. The quic-go package does not
+// build with every Go release, so the transport is opt-in.
+const QUICEnabled = true
+
+// quicProtocol is the ALPN protocol negotiated by QUIC clients and servers.
+const quicProtocol = "serviceweaver"
+
+// quicConfig is the configuration of QUIC clients and servers. Every
+// connection from a client to a server carries a stream per client of the
+// call package, so we allow many streams, and keep connections alive like
+// TCP connections are.
+var quicConfig = &quic.Config{
+	MaxIncomingStreams: 1 << 16,
+	KeepAlivePeriod:    10 * time.Second,
+}
+
+// QUICTransport dials QUIC endpoints. All the endpoints of a transport with
+// the same address share a single QUIC connection, and every call to Dial
+// opens a new stream on it. Because QUIC already encrypts streams, the
+// ClientOptions and ServerOptions of QUIC clients and servers should not set
+// a TLSConfig.
+type QUICTransport struct {
+	config func() (*tls.Config, error) // see NewQUICTransport
+
+	mu    sync.Mutex
+	conns map[string]quic.Connection // QUIC connections, by address
+}
+
+// NewQUICTransport returns a new QUICTransport. The provided function
+// returns the TLS config of the QUIC handshakes. If it returns a nil config,
+// connections are encrypted, but servers are not authenticated.
+func NewQUICTransport(config func() (*tls.Config, error)) *QUICTransport {
+	return &QUICTransport{config: config, conns: map[string]quic.Connection{}}
+}
+
+// Endpoint returns an endpoint that dials the QUIC server at the provided
+// address, e.g., "localhost:8000".
+func (t *QUICTransport) Endpoint(addr string) Endpoint {
+	return quicEndpoint{t: t, addr: addr}
+}
+
+// connection returns a QUIC connection to the provided address, dialing one
+// if there is no live connection to the address.
+func (t *QUICTransport) connection(ctx context.Context, addr string) (quic.Connection, error) {
+	t.mu.Lock()
+	conn, ok := t.conns[addr]
+	t.mu.Unlock()
+	if ok && conn.Context().Err() == nil {
+		return conn, nil
+	}
+
+	// Dial without holding the lock, so that a slow or unreachable server
+	// doesn't delay the dials of other addresses.
+	config, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &tls.Config{
+			MinVersion: tls.VersionTLS13,
+			// Without mutual TLS, weavelets don't authenticate each other,
+			// as with TCP.
+			InsecureSkipVerify: true,
+		}
+	}
+	config = config.Clone()
+	config.NextProtos = []string{quicProtocol}
+	dialed, err := quic.DialAddrContext(ctx, addr, config, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conn, ok := t.conns[addr]; ok && conn.Context().Err() == nil {
+		// Another caller dialed the address concurrently.
+		dialed.CloseWithError(0, "duplicate connection") //nolint:errcheck // best effort
+		return conn, nil
+	}
+	t.conns[addr] = dialed
+	return dialed, nil
+}
+
+// quicEndpoint is an Endpoint that opens a stream on a QUIC connection.
+type quicEndpoint struct {
+	t    *QUICTransport
+	addr string
+}
+
+// Check that quicEndpoint implements the Endpoint interface.
+var _ Endpoint = quicEndpoint{}
+
+// Dial implements the Endpoint interface.
+func (qe quicEndpoint) Dial(ctx context.Context) (net.Conn, error) {
+	conn, err := qe.t.connection(ctx, qe.addr)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &quicStream{Stream: stream, conn: conn}, nil
+}
+
+// Address implements the Endpoint interface.
+func (qe quicEndpoint) Address() string {
+	return fmt.Sprintf("quic://%s", qe.addr)
+}
+
+func (qe quicEndpoint) String() string {
+	return qe.Address()
+}
+
+// quicStream is a net.Conn backed by a QUIC stream.
+type quicStream struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+// Check that quicStream implements the net.Conn interface.
+var _ net.Conn = &quicStream{}
+
+// Close implements the net.Conn interface. Unlike quic.Stream.Close, which
+// only closes the sending side of the stream, it closes both sides.
+func (s *quicStream) Close() error {
+	s.Stream.CancelRead(0)
+	return s.Stream.Close()
+}
+
+// LocalAddr implements the net.Conn interface.
+func (s *quicStream) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr implements the net.Conn interface.
+func (s *quicStream) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// ListenQUIC returns a listener that accepts QUIC connections on the provided
+// packet connection. The listener's Accept method returns the streams opened
+// by clients, on any connection, so the listener can be passed to Serve. If
+// config is nil, the server uses a self-signed certificate.
+func ListenQUIC(pconn net.PacketConn, config *tls.Config) (net.Listener, error) {
+	if config == nil {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		config = &tls.Config{
+			MinVersion:   tls.VersionTLS13,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+	config = config.Clone()
+	config.NextProtos = []string{quicProtocol}
+	l, err := quic.Listen(pconn, config, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ql := &quicListener{l: l, ctx: ctx, cancel: cancel, streams: make(chan net.Conn)}
+	go ql.acceptConnections()
+	return ql, nil
+}
+
+// quicListener is a net.Listener that accepts the streams of QUIC
+// connections.
+type quicListener struct {
+	l       quic.Listener
+	ctx     context.Context // canceled when the listener is closed
+	cancel  func()
+	streams chan net.Conn // accepted streams
+
+	mu  sync.Mutex
+	err error // error accepting connections, if any
+}
+
+// Check that quicListener implements the net.Listener interface.
+var _ net.Listener = &quicListener{}
+
+// Accept implements the net.Listener interface.
+func (ql *quicListener) Accept() (net.Conn, error) {
+	select {
+	case s := <-ql.streams:
+		return s, nil
+	case <-ql.ctx.Done():
+		ql.mu.Lock()
+		defer ql.mu.Unlock()
+		if ql.err != nil {
+			return nil, ql.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements the net.Listener interface.
+func (ql *quicListener) Close() error {
+	ql.cancel()
+	return ql.l.Close()
+}
+
+// Addr implements the net.Listener interface.
+func (ql *quicListener) Addr() net.Addr {
+	return ql.l.Addr()
+}
+
+// acceptConnections accepts QUIC connections until the listener is closed.
+func (ql *quicListener) acceptConnections() {
+	for {
+		conn, err := ql.l.Accept(ql.ctx)
+		if err != nil {
+			if ql.ctx.Err() == nil {
+				ql.mu.Lock()
+				ql.err = err
+				ql.mu.Unlock()
+				ql.cancel()
+			}
+			return
+		}
+		go ql.acceptStreams(conn)
+	}
+}
+
+// acceptStreams accepts the streams of a QUIC connection until the
+// connection or the listener is closed.
+func (ql *quicListener) acceptStreams(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(ql.ctx)
+		if err != nil {
+			// The connection is closed, or the listener is.
+			return
+		}
+		select {
+		case ql.streams <- &quicStream{Stream: stream, conn: conn}:
+		case <-ql.ctx.Done():
+			stream.CancelRead(0)
+			stream.Close()
+			return
+		}
+	}
+}
+
+// selfSignedCertificate returns a new self-signed certificate, for servers
+// that don't authenticate themselves.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Service Weaver"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
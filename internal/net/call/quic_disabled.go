@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !quic
+
+package call
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// QUICEnabled is true if the QUIC transport is compiled in, i.e., if the
+// binary is built with the "quic" build tag.
+const QUICEnabled = false
+
+// errQUICDisabled is returned when using QUIC in a binary built without the
+// "quic" build tag.
+var errQUICDisabled = errors.New(`QUIC transport not compiled in; build with "-tags quic"`)
+
+// QUICTransport dials QUIC endpoints. Without the "quic" build tag, it fails
+// every dial.
+type QUICTransport struct{}
+
+// NewQUICTransport returns a new QUICTransport.
+func NewQUICTransport(func() (*tls.Config, error)) *QUICTransport {
+	return &QUICTransport{}
+}
+
+// Endpoint returns an endpoint that dials the QUIC server at the provided
+// address, e.g., "localhost:8000".
+func (t *QUICTransport) Endpoint(addr string) Endpoint {
+	return quicEndpoint{addr: addr}
+}
+
+// quicEndpoint is an Endpoint that fails to dial.
+type quicEndpoint struct {
+	addr string
+}
+
+// Check that quicEndpoint implements the Endpoint interface.
+var _ Endpoint = quicEndpoint{}
+
+// Dial implements the Endpoint interface.
+func (qe quicEndpoint) Dial(context.Context) (net.Conn, error) {
+	return nil, errQUICDisabled
+}
+
+// Address implements the Endpoint interface.
+func (qe quicEndpoint) Address() string {
+	return fmt.Sprintf("quic://%s", qe.addr)
+}
+
+func (qe quicEndpoint) String() string {
+	return qe.Address()
+}
+
+// ListenQUIC returns a listener that accepts QUIC connections. Without the
+// "quic" build tag, it always fails.
+func ListenQUIC(net.PacketConn, *tls.Config) (net.Listener, error) {
+	return nil, errQUICDisabled
+}
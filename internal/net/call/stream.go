@@ -0,0 +1,377 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package call
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// streamWindow is the number of messages the sender of a stream may send
+// before hearing back from the receiver, and so the number of messages the
+// receiver of a stream buffers.
+const streamWindow = 64
+
+// errStreamClosed is the error returned by the methods of a ClientStream
+// once Close has been called.
+var errStreamClosed = errors.New("stream closed")
+
+// StreamHandler is a function that handles streaming remote procedure calls.
+// The handler receives the messages sent by the client, and sends messages to
+// the client, over s. The call ends when the handler returns. Like a Handler,
+// a StreamHandler should only return a non-nil error if the handler was not
+// able to execute successfully.
+type StreamHandler func(ctx context.Context, args []byte, s ServerStream) error
+
+// ServerStream is the server side of a streaming call. Send and Recv may be
+// called concurrently with each other, but neither may be called
+// concurrently with itself.
+type ServerStream interface {
+	// Send sends a message to the client. Send blocks while the client is
+	// streamWindow messages behind.
+	Send([]byte) error
+
+	// Recv returns the next message sent by the client. Recv returns io.EOF
+	// once the client has called CloseSend and all of its messages have been
+	// received.
+	Recv() ([]byte, error)
+}
+
+// ClientStream is the client side of a streaming call. Send and Recv may be
+// called concurrently with each other, but neither may be called
+// concurrently with itself.
+type ClientStream interface {
+	// Send sends a message to the server. Send blocks while the server is
+	// streamWindow messages behind. Send returns io.EOF if the call has
+	// ended successfully; Recv returns the reason for the call ending.
+	Send([]byte) error
+
+	// CloseSend informs the server that the client will not send any more
+	// messages.
+	CloseSend() error
+
+	// Recv returns the next message sent by the server. Recv returns io.EOF
+	// once the server handler has returned successfully and all of its
+	// messages have been received.
+	Recv() ([]byte, error)
+
+	// Close ends the call, cancelling the server handler if it is still
+	// running. Close must be called once the client is done with the
+	// stream. Close can be called more than once.
+	Close()
+}
+
+// streamState holds the state shared by the two sides of a streaming call
+// on one end of a connection: the messages received from the peer and the
+// credits to send messages to the peer.
+type streamState struct {
+	in      chan []byte   // messages received from the peer
+	credits chan struct{} // one token per message we may send to the peer
+	begun   atomic.Int64  // when the first message was received, in UnixNano
+
+	// inClosed is true once in has been closed. Accessed only by the reader
+	// of the connection.
+	inClosed bool
+
+	// cancelled is true once the client has cancelled the call. Only used on
+	// the server, and guarded by serverConnection.mu.
+	cancelled bool
+
+	// unacked is the number of received messages that haven't been acked
+	// yet. Accessed only by the receiver of the stream.
+	unacked uint64
+}
+
+func newStreamState() *streamState {
+	s := &streamState{
+		in:      make(chan []byte, streamWindow),
+		credits: make(chan struct{}, streamWindow),
+	}
+	for i := 0; i < streamWindow; i++ {
+		s.credits <- struct{}{}
+	}
+	return s
+}
+
+// handle handles a stream message of type mt received from the peer.
+func (s *streamState) handle(mt messageType, msg []byte) error {
+	switch mt {
+	case streamMessage:
+		if s.inClosed {
+			return fmt.Errorf("stream message after close")
+		}
+		s.begun.CompareAndSwap(0, time.Now().UnixNano())
+		select {
+		case s.in <- msg:
+		default:
+			// The peer can't have sent more than streamWindow unacked
+			// messages.
+			return fmt.Errorf("stream window exceeded")
+		}
+	case streamCloseMessage:
+		if !s.inClosed {
+			s.inClosed = true
+			close(s.in)
+		}
+	case streamAckMessage:
+		if len(msg) < 8 {
+			return fmt.Errorf("bad stream ack length %d, must be >= 8", len(msg))
+		}
+		for n := binary.LittleEndian.Uint64(msg); n > 0; n-- {
+			select {
+			case s.credits <- struct{}{}:
+			default:
+				// The peer acked more messages than we sent. Ignore the
+				// excess credits.
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// received records that a message was received from the peer. It returns
+// the payload of the ack to send to the peer, or nil if no ack is due yet.
+// Acks are batched to avoid sending one per message.
+func (s *streamState) received() []byte {
+	s.unacked++
+	if s.unacked < streamWindow/2 {
+		return nil
+	}
+	var ack [8]byte
+	binary.LittleEndian.PutUint64(ack[:], s.unacked)
+	s.unacked = 0
+	return ack[:]
+}
+
+// Stream starts a streaming call over connection c.
+func (rc *reconnectingConnection) Stream(ctx context.Context, h MethodKey, arg []byte, opts CallOptions) (ClientStream, error) {
+	hdr := make([]byte, msgHeaderSize+len(opts.Metadata))
+	if err := writeHeader(ctx, hdr, h, opts); err != nil {
+		return nil, err
+	}
+
+	rpc := &call{doneSignal: make(chan struct{}), stream: newStreamState()}
+	conn, err := rc.startCall(ctx, rpc, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &clientStream{conn: conn, rpc: rpc, flattenLimit: rc.opts.WriteFlattenLimit}
+	observer, _ := rc.balancer(opts).(CallObserver)
+	go s.watch(ctx, observer)
+
+	if err := writeMessage(conn.c, &conn.wlock, streamRequestMessage, rpc.id, hdr, arg, s.flattenLimit); err != nil {
+		conn.shutdown("client send stream request", err)
+		return nil, fmt.Errorf("%w: %s", CommunicationError, err)
+	}
+	return s, nil
+}
+
+// clientStream is the concrete ClientStream implementation.
+type clientStream struct {
+	conn         *clientConnection
+	rpc          *call
+	flattenLimit int
+}
+
+var _ ClientStream = &clientStream{}
+
+// watch aborts the call if ctx is done before the call ends. Once the call
+// ends, watch informs observer, if not nil, that the call finished.
+func (s *clientStream) watch(ctx context.Context, observer CallObserver) {
+	start := time.Now()
+	select {
+	case <-s.rpc.doneSignal:
+	case <-ctx.Done():
+		s.abort(ctx.Err())
+	}
+	if observer == nil {
+		return
+	}
+	// Report the time to the first message, rather than the duration of
+	// the whole call, which mostly depends on the application.
+	latency := time.Since(start)
+	if begun := s.rpc.stream.begun.Load(); begun != 0 {
+		latency = time.Unix(0, begun).Sub(start)
+	}
+	err := s.rpc.err
+	if errors.Is(err, errStreamClosed) {
+		err = nil
+	}
+	observer.Finished(s.conn.endpoint, latency, err)
+}
+
+// Send implements the ClientStream interface.
+func (s *clientStream) Send(msg []byte) error {
+	select {
+	case <-s.rpc.stream.credits:
+	case <-s.rpc.doneSignal:
+		return s.doneErr()
+	}
+	if err := writeMessage(s.conn.c, &s.conn.wlock, streamMessage, s.rpc.id, nil, msg, s.flattenLimit); err != nil {
+		s.conn.shutdown("client send stream", err)
+		return fmt.Errorf("%w: %s", CommunicationError, err)
+	}
+	return nil
+}
+
+// CloseSend implements the ClientStream interface.
+func (s *clientStream) CloseSend() error {
+	if atomic.LoadUint32(&s.rpc.done) > 0 {
+		return s.doneErr()
+	}
+	if err := writeMessage(s.conn.c, &s.conn.wlock, streamCloseMessage, s.rpc.id, nil, nil, s.flattenLimit); err != nil {
+		s.conn.shutdown("client send stream close", err)
+		return fmt.Errorf("%w: %s", CommunicationError, err)
+	}
+	return nil
+}
+
+// Recv implements the ClientStream interface.
+func (s *clientStream) Recv() ([]byte, error) {
+	select {
+	case msg := <-s.rpc.stream.in:
+		s.ack()
+		return msg, nil
+	case <-s.rpc.doneSignal:
+		// Messages are buffered before the call is marked done, so return
+		// any remaining buffered messages first.
+		select {
+		case msg := <-s.rpc.stream.in:
+			return msg, nil
+		default:
+		}
+		if s.rpc.err != nil {
+			return nil, s.rpc.err
+		}
+		return nil, io.EOF
+	}
+}
+
+// Close implements the ClientStream interface.
+func (s *clientStream) Close() {
+	s.abort(errStreamClosed)
+}
+
+// ack acks a received message, if an ack is due.
+func (s *clientStream) ack() {
+	ack := s.rpc.stream.received()
+	if ack == nil || atomic.LoadUint32(&s.rpc.done) > 0 {
+		return
+	}
+	if err := writeMessage(s.conn.c, &s.conn.wlock, streamAckMessage, s.rpc.id, nil, ack, s.flattenLimit); err != nil {
+		s.conn.shutdown("client send stream ack", err)
+	}
+}
+
+// abort ends the call with the provided error, if it hasn't ended already,
+// and tells the server to cancel it.
+func (s *clientStream) abort(err error) {
+	rpc := s.conn.findAndEndCall(s.rpc.id)
+	if rpc == nil {
+		return // the call has already ended
+	}
+	rpc.err = err
+	atomic.StoreUint32(&rpc.done, 1)
+	close(rpc.doneSignal)
+	if err := writeMessage(s.conn.c, &s.conn.wlock, cancelMessage, rpc.id, nil, nil, s.flattenLimit); err != nil {
+		s.conn.shutdown("client send cancel", err)
+	}
+}
+
+// doneErr returns the error returned by Send once the call has ended.
+//
+// REQUIRES: The call has ended.
+func (s *clientStream) doneErr() error {
+	if s.rpc.err != nil {
+		return s.rpc.err
+	}
+	return io.EOF
+}
+
+// serverStream is the concrete ServerStream implementation.
+type serverStream struct {
+	ctx   context.Context
+	conn  *serverConnection
+	id    uint64
+	state *streamState
+}
+
+var _ ServerStream = &serverStream{}
+
+// Send implements the ServerStream interface.
+func (s *serverStream) Send(msg []byte) error {
+	select {
+	case <-s.state.credits:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+	if err := writeMessage(s.conn.c, &s.conn.wlock, streamMessage, s.id, nil, msg, s.conn.opts.WriteFlattenLimit); err != nil {
+		s.conn.shutdown("server send stream", err)
+		return err
+	}
+	return nil
+}
+
+// Recv implements the ServerStream interface.
+func (s *serverStream) Recv() ([]byte, error) {
+	select {
+	case msg, ok := <-s.state.in:
+		if !ok {
+			return nil, io.EOF
+		}
+		if ack := s.state.received(); ack != nil {
+			if err := writeMessage(s.conn.c, &s.conn.wlock, streamAckMessage, s.id, nil, ack, s.conn.opts.WriteFlattenLimit); err != nil {
+				s.conn.shutdown("server send stream ack", err)
+			}
+		}
+		return msg, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+// startStream registers a new streaming call with the provided id.
+func (c *serverConnection) startStream(id uint64) *streamState {
+	s := newStreamState()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.streams[id] = s
+	return s
+}
+
+// endStream unregisters the streaming call with the provided id.
+func (c *serverConnection) endStream(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.streams, id)
+}
+
+// handleStream handles a stream message of type mt sent by the client on
+// the streaming call with the provided id.
+func (c *serverConnection) handleStream(mt messageType, id uint64, msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.streams[id]
+	if !ok {
+		return nil // the call may have ended
+	}
+	return s.handle(mt, msg)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp exports traces and metrics using the OpenTelemetry protocol
+// (OTLP), as configured in the [serviceweaver.otlp] section of the config.
+package otlp
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
+)
+
+// scope is the instrumentation scope of the exported metrics.
+var scope = instrumentation.Scope{Name: "github.com/ServiceWeaver/weaver/serviceweaver"}
+
+// NewTraceExporter returns an exporter that sends trace spans to the endpoint
+// in the provided config.
+//
+// REQUIRES: config.Endpoint != "".
+func NewTraceExporter(ctx context.Context, config runtime.OTLPConfig) (sdktrace.SpanExporter, error) {
+	if config.Protocol == "http" {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(config.Endpoint),
+			otlptracehttp.WithHeaders(config.Headers),
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(config.Endpoint),
+		otlptracegrpc.WithHeaders(config.Headers),
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// NewMetricExporter returns an exporter that sends metrics to the endpoint in
+// the provided config.
+//
+// REQUIRES: config.Endpoint != "".
+func NewMetricExporter(ctx context.Context, config runtime.OTLPConfig) (sdkmetric.Exporter, error) {
+	if config.Protocol == "http" {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(config.Endpoint),
+			otlpmetrichttp.WithHeaders(config.Headers),
+		}
+		if config.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(config.Endpoint),
+		otlpmetricgrpc.WithHeaders(config.Headers),
+	}
+	if config.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// ExportMetrics exports the metrics returned by snapshot to exporter every
+// interval, until ctx is cancelled. The metrics are attributed to res. start
+// is when the metrics started being recorded, and is reported as the start
+// time of the counters and histograms, whose values are cumulative.
+func ExportMetrics(ctx context.Context, logger *slog.Logger, exporter sdkmetric.Exporter, res *resource.Resource, start time.Time, interval time.Duration, snapshot func() []*metrics.MetricSnapshot) error {
+	defer func() {
+		// Use a fresh context, since ctx is cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := exporter.Shutdown(ctx); err != nil {
+			logger.Error("shut down OTLP metric exporter", err)
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rm := Translate(res, start, time.Now(), snapshot())
+			if err := exporter.Export(ctx, rm); err != nil {
+				logger.Error("export metrics over OTLP", err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Translate translates metric snapshots taken at time now into OpenTelemetry
+// metrics attributed to res. Counters become monotonic cumulative sums,
+// gauges become gauges, and histograms become cumulative histograms. start is
+// reported as the start time of sums and histograms.
+func Translate(res *resource.Resource, start, now time.Time, snapshots []*metrics.MetricSnapshot) metricdata.ResourceMetrics {
+	// Group the snapshots by metric name. Every snapshot is a data point.
+	byName := map[string][]*metrics.MetricSnapshot{}
+	var names []string
+	for _, s := range snapshots {
+		if _, ok := byName[s.Name]; !ok {
+			names = append(names, s.Name)
+		}
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+	sort.Strings(names)
+
+	ms := make([]metricdata.Metrics, 0, len(names))
+	for _, name := range names {
+		group := byName[name]
+		m := metricdata.Metrics{Name: name, Description: group[0].Help}
+		switch group[0].Type {
+		case protos.MetricType_COUNTER:
+			sum := metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+			for _, s := range group {
+				sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(s.Labels),
+					StartTime:  start,
+					Time:       now,
+					Value:      s.Value,
+				})
+			}
+			m.Data = sum
+		case protos.MetricType_GAUGE:
+			var gauge metricdata.Gauge[float64]
+			for _, s := range group {
+				gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(s.Labels),
+					Time:       now,
+					Value:      s.Value,
+				})
+			}
+			m.Data = gauge
+		case protos.MetricType_HISTOGRAM:
+			hist := metricdata.Histogram{Temporality: metricdata.CumulativeTemporality}
+			for _, s := range group {
+				var count uint64
+				for _, c := range s.Counts {
+					count += c
+				}
+				hist.DataPoints = append(hist.DataPoints, metricdata.HistogramDataPoint{
+					Attributes:   attributes(s.Labels),
+					StartTime:    start,
+					Time:         now,
+					Count:        count,
+					Bounds:       s.Bounds,
+					BucketCounts: s.Counts,
+					Sum:          s.Value,
+				})
+			}
+			m.Data = hist
+		default:
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return metricdata.ResourceMetrics{
+		Resource:     res,
+		ScopeMetrics: []metricdata.ScopeMetrics{{Scope: scope, Metrics: ms}},
+	}
+}
+
+// attributes returns the provided labels as a set of attributes.
+func attributes(labels map[string]string) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return attribute.NewSet(kvs...)
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestTranslate(t *testing.T) {
+	start := time.Unix(100, 0)
+	now := time.Unix(200, 0)
+	snapshots := []*metrics.MetricSnapshot{
+		{Name: "requests", Type: protos.MetricType_COUNTER, Labels: map[string]string{"method": "a"}, Value: 3},
+		{Name: "requests", Type: protos.MetricType_COUNTER, Labels: map[string]string{"method": "b"}, Value: 4},
+		{Name: "in_flight", Type: protos.MetricType_GAUGE, Help: "Calls in flight", Value: 2},
+		{Name: "latency", Type: protos.MetricType_HISTOGRAM, Value: 15, Bounds: []float64{1, 10}, Counts: []uint64{1, 2, 3}},
+	}
+	res := resource.Empty()
+	rm := Translate(res, start, now, snapshots)
+	if rm.Resource != res {
+		t.Fatalf("resource: got %v, want %v", rm.Resource, res)
+	}
+	if got, want := len(rm.ScopeMetrics), 1; got != want {
+		t.Fatalf("scope metrics: got %d, want %d", got, want)
+	}
+	ms := rm.ScopeMetrics[0].Metrics
+	var names []string
+	for _, m := range ms {
+		names = append(names, m.Name)
+	}
+	if got, want := names, []string{"in_flight", "latency", "requests"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("names: got %v, want %v", got, want)
+	}
+
+	// Gauge.
+	if got, want := ms[0].Description, "Calls in flight"; got != want {
+		t.Errorf("description: got %q, want %q", got, want)
+	}
+	gauge, ok := ms[0].Data.(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 2 || gauge.DataPoints[0].Time != now {
+		t.Errorf("in_flight: got %+v, want one gauge data point with value 2", ms[0].Data)
+	}
+
+	// Histogram.
+	hist, ok := ms[1].Data.(metricdata.Histogram)
+	if !ok || len(hist.DataPoints) != 1 {
+		t.Fatalf("latency: got %+v, want one histogram data point", ms[1].Data)
+	}
+	if p := hist.DataPoints[0]; p.Count != 6 || p.Sum != 15 || p.StartTime != start || hist.Temporality != metricdata.CumulativeTemporality {
+		t.Errorf("latency: got %+v, want count 6 and sum 15", p)
+	}
+
+	// Counter.
+	sum, ok := ms[2].Data.(metricdata.Sum[float64])
+	if !ok || !sum.IsMonotonic || len(sum.DataPoints) != 2 {
+		t.Fatalf("requests: got %+v, want a monotonic sum with two data points", ms[2].Data)
+	}
+	for _, p := range sum.DataPoints {
+		method, _ := p.Attributes.Value(attribute.Key("method"))
+		want := map[string]float64{"a": 3, "b": 4}[method.AsString()]
+		if p.Value != want {
+			t.Errorf("requests{method=%q}: got %v, want %v", method.AsString(), p.Value, want)
+		}
+	}
+}
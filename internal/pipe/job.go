@@ -0,0 +1,27 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package pipe
+
+import "os"
+
+// bindToParent ensures that the provided process is killed when the current
+// process exits. It is a no-op on this platform: the processes started by
+// Service Weaver deployers exit once their pipes to the deployer are closed,
+// which the kernel does when the deployer exits.
+func bindToParent(*os.Process) error {
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pipe
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	jobOnce sync.Once
+	job     windows.Handle // job object of the processes started by Start
+	jobErr  error          // error creating job, if any
+)
+
+// bindToParent ensures that the provided process is killed when the current
+// process exits, even if it crashes, by adding it to a job object that kills
+// its processes once its last handle is closed. The only handle is held by
+// the current process, and Windows closes it when the process exits.
+//
+// A process that is blocked, or that has started processes of its own, may
+// otherwise outlive the pipes connecting it to the current process.
+func bindToParent(p *os.Process) error {
+	jobOnce.Do(func() { job, jobErr = newJob() })
+	if jobErr != nil {
+		return jobErr
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck // nothing to do on error
+	return windows.AssignProcessToJobObject(job, h)
+}
+
+// newJob returns a job object that kills its processes once its last handle
+// is closed.
+func newJob() (windows.Handle, error) {
+	h, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(h, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(h) //nolint:errcheck // supplanted by err
+		return 0, err
+	}
+	return h, nil
+}
@@ -92,12 +92,20 @@ func (c *Cmd) registerPipe(local, remote *os.File) uintptr {
 	return addInheritedFile(c.Cmd, remote)
 }
 
-// Start is identical to exec.Command.Start.
+// Start is identical to exec.Command.Start, except that the started process
+// is killed when the current process exits on platforms where the process
+// would otherwise outlive it; see bindToParent.
 func (c *Cmd) Start() error {
 	if err := c.Cmd.Start(); err != nil {
 		return err
 	}
 	closeAll(&c.closeAfterStart)
+	if err := bindToParent(c.Process); err != nil {
+		c.Process.Kill() //nolint:errcheck // the process may have exited
+		c.Cmd.Wait()     //nolint:errcheck // supplanted by err
+		closeAll(&c.closeAfterWait)
+		return fmt.Errorf("bind %s to the current process: %w", c.Path, err)
+	}
 	return nil
 }
 
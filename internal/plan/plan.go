@@ -0,0 +1,430 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plan previews how a deployer deploys an application, for the dry
+// runs of the deploy commands, e.g., "weaver multi deploy --dry-run".
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/rollout"
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/colors"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+)
+
+// listTimeout bounds how long the application binary may take to list or
+// describe its components, or describe its metrics.
+const listTimeout = time.Minute
+
+// A Plan describes how a deployer deploys an application.
+type Plan struct {
+	App      string            // application name
+	Deployer string            // deploying tool, e.g., "weaver multi"
+	Rollout  string            // how the deployment is rolled out
+	Groups   []Group           // co-location groups, sorted by name
+	Config   *protos.AppConfig // application config
+
+	// Listeners, sorted by name, or nil if the listeners of the application
+	// are only known once it runs. ListenerNote, if not empty, explains how
+	// the addresses of the listeners are chosen.
+	Listeners    []Listener
+	ListenerNote string
+}
+
+// A Group is a co-location group.
+type Group struct {
+	Name       string   // group name
+	Components []string // components in the group, sorted
+	Replicas   string   // number of replicas, e.g., "2" or "1-10 (autoscaled)"
+}
+
+// A Listener is a network listener of an application.
+type Listener struct {
+	Name    string // listener name
+	Address string // address at which the listener is reachable
+}
+
+// Components runs the binary of the provided application, with the
+// application's args and env, and returns the names of its components,
+// sorted. The binary lists its components and exits when it calls
+// weaver.Init, rather than running.
+func Components(ctx context.Context, app *protos.AppConfig) ([]string, error) {
+	data, err := RunBinary(ctx, app, runtime.ListComponentsKey, "list its components")
+	if err != nil {
+		return nil, err
+	}
+	var components []string
+	if err := json.Unmarshal(data, &components); err != nil {
+		return nil, fmt.Errorf("binary %q: invalid component list: %w", app.Binary, err)
+	}
+	sort.Strings(components)
+	return components, nil
+}
+
+// Schema runs the binary of the provided application, like Components, and
+// returns the schemas of its components.
+func Schema(ctx context.Context, app *protos.AppConfig) (*status.Schema, error) {
+	data, err := RunBinary(ctx, app, runtime.DescribeComponentsKey, "describe its components")
+	if err != nil {
+		return nil, err
+	}
+	schema := &status.Schema{}
+	if err := proto.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("binary %q: invalid component schemas: %w", app.Binary, err)
+	}
+	return schema, nil
+}
+
+// RunBinary runs the binary of the provided application, with the
+// application's args and env, and with the provided environment variable
+// set to the name of a file. It returns the contents the binary writes to
+// the file when it calls weaver.Init. what describes what the binary writes,
+// for error messages.
+func RunBinary(ctx context.Context, app *protos.AppConfig, key, what string) ([]byte, error) {
+	if _, err := os.Stat(app.Binary); err != nil {
+		return nil, fmt.Errorf("binary %q doesn't exist", app.Binary)
+	}
+	tmp, err := os.MkdirTemp("", "weaver-plan")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "out")
+
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, app.Binary, app.Args...)
+	cmd.Env = append(os.Environ(), app.Env...)
+	cmd.Env = append(cmd.Env, key+"="+file)
+	out, runErr := cmd.CombinedOutput()
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		// The binary exited, or timed out, before calling weaver.Init.
+		return nil, fmt.Errorf("binary %q didn't %s; make sure it calls weaver.Init and is built with this version of Service Weaver: %v\n%s", app.Binary, what, runErr, out)
+	}
+	return data, err
+}
+
+// Groups returns the co-location groups of the provided components, as
+// placed by the colocate field of the provided application config, sorted by
+// name. A group is named after its first component, as listed in the config.
+// replicas returns the number of replicas of a group, given its name.
+func Groups(app *protos.AppConfig, components []string, replicas func(group string) string) []Group {
+	groupName := GroupNamer(app)
+	byName := map[string]*Group{}
+	for _, c := range components {
+		name := groupName(c)
+		g, ok := byName[name]
+		if !ok {
+			g = &Group{Name: name, Replicas: replicas(name)}
+			byName[name] = g
+		}
+		g.Components = append(g.Components, c)
+	}
+	groups := make([]Group, 0, len(byName))
+	for _, g := range byName {
+		sort.Strings(g.Components)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// Replicas returns a function, suitable for Groups, that returns n replicas
+// for every group, except for the groups autoscaled by the provided policies,
+// keyed by component.
+func Replicas(app *protos.AppConfig, n int, autoscaling map[string]runtime.AutoscalingConfig) func(group string) string {
+	groupName := GroupNamer(app)
+	autoscaled := map[string]string{}
+	for component, config := range autoscaling {
+		autoscaled[groupName(component)] = fmt.Sprintf("%d-%d (autoscaled)", config.MinReplicas, config.MaxReplicas)
+	}
+	return func(group string) string {
+		if replicas, ok := autoscaled[group]; ok {
+			return replicas
+		}
+		return fmt.Sprint(n)
+	}
+}
+
+// GroupNamer returns a function that returns the name of the co-location
+// group of a component, as placed by the colocate field of the provided
+// application config.
+func GroupNamer(app *protos.AppConfig) func(component string) string {
+	colocation := map[string]string{}
+	for _, group := range app.Colocate {
+		for _, c := range group.Components {
+			colocation[c] = group.Components[0]
+		}
+	}
+	return func(component string) string {
+		if name, ok := colocation[component]; ok {
+			return name
+		}
+		return component
+	}
+}
+
+// Rollout describes how a deployer that supports the --rollout, --traffic, and
+// --mirror flags, like "weaver multi", rolls out the provided application.
+// running is the deployment rolled out over, or nil if not rolling out, and
+// traffic and mirror are the values of the --traffic and --mirror flags.
+func Rollout(deployer string, app *protos.AppConfig, running *status.Registration, traffic, mirror float64) string {
+	if running == nil {
+		return "new deployment, alongside any running deployments"
+	}
+	id := logging.Shorten(running.DeploymentId)
+	if traffic >= 0 || mirror > 0 {
+		start := fmt.Sprintf("%v%% of traffic", math.Max(0, traffic))
+		if mirror > 0 {
+			start += fmt.Sprintf(" and %v%% of traffic mirrored", mirror)
+		}
+		return fmt.Sprintf("manual rollout over deployment %s, starting at %s, driven by %q and %q", id, start, deployer+" rollout", deployer+" rollback")
+	}
+	duration := time.Duration(app.RolloutNanos)
+	if duration == 0 {
+		duration = rollout.DefaultDuration
+	}
+	return fmt.Sprintf("gradual rollout over deployment %s, in %v, which is then retired", id, duration)
+}
+
+// Running returns the plans of the running deployments of the provided
+// application, registered with the provided registry, by deployment id.
+func Running(ctx context.Context, registry *status.Registry, app string) (map[string]*Plan, error) {
+	regs, err := registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plans := map[string]*Plan{}
+	for _, reg := range regs {
+		if reg.App != app {
+			continue
+		}
+		s, err := status.NewClient(reg.Addr).Status(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("status of deployment %s: %w", reg.DeploymentId, err)
+		}
+		plans[reg.DeploymentId] = FromStatus(s)
+	}
+	return plans, nil
+}
+
+// FromStatus returns the plan of a running deployment, given its status.
+func FromStatus(s *status.Status) *Plan {
+	byName := map[string]*Group{}
+	pids := map[string]map[int64]bool{}
+	for _, c := range s.Components {
+		g, ok := byName[c.Group]
+		if !ok {
+			g = &Group{Name: c.Group}
+			byName[c.Group] = g
+			pids[c.Group] = map[int64]bool{}
+		}
+		g.Components = append(g.Components, c.Name)
+		for _, pid := range c.Pids {
+			pids[c.Group][pid] = true
+		}
+	}
+	p := &Plan{App: s.App, Config: s.Config, Listeners: []Listener{}}
+	for name, g := range byName {
+		sort.Strings(g.Components)
+		g.Replicas = fmt.Sprint(len(pids[name]))
+		p.Groups = append(p.Groups, *g)
+	}
+	sort.Slice(p.Groups, func(i, j int) bool { return p.Groups[i].Name < p.Groups[j].Name })
+	for _, l := range s.Listeners {
+		p.Listeners = append(p.Listeners, Listener{Name: l.Name, Address: l.Addr})
+	}
+	sort.Slice(p.Listeners, func(i, j int) bool { return p.Listeners[i].Name < p.Listeners[j].Name })
+	return p
+}
+
+// Diff returns the changes that deploying the provided plan makes to the
+// provided running deployment, one per line, sorted by what they change:
+// lines that start with "+" add something, lines that start with "-" remove
+// something, and lines that start with "~" change something. Listeners are
+// only compared if the listeners of the new plan are known.
+func Diff(running, p *Plan) []string {
+	var changes []string
+	add := func(format string, args ...any) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
+
+	// Compare the groups.
+	before := map[string]Group{}
+	for _, g := range running.Groups {
+		before[g.Name] = g
+	}
+	after := map[string]Group{}
+	for _, g := range p.Groups {
+		after[g.Name] = g
+	}
+	for _, name := range sortedUnion(maps.Keys(before), maps.Keys(after)) {
+		old, inOld := before[name]
+		g, inNew := after[name]
+		switch {
+		case !inOld:
+			add("+ group %s: %d component(s), %s replica(s)", name, len(g.Components), g.Replicas)
+		case !inNew:
+			add("- group %s", name)
+		default:
+			if old.Replicas != g.Replicas {
+				add("~ group %s: %s -> %s replica(s)", name, old.Replicas, g.Replicas)
+			}
+			for _, c := range sortedUnion(old.Components, g.Components) {
+				switch {
+				case !slices.Contains(old.Components, c):
+					add("~ group %s: + component %s", name, c)
+				case !slices.Contains(g.Components, c):
+					add("~ group %s: - component %s", name, c)
+				}
+			}
+		}
+	}
+
+	// Compare the listeners.
+	if p.Listeners != nil {
+		before := map[string]string{}
+		for _, l := range running.Listeners {
+			before[l.Name] = l.Address
+		}
+		after := map[string]string{}
+		for _, l := range p.Listeners {
+			after[l.Name] = l.Address
+		}
+		for _, name := range sortedUnion(maps.Keys(before), maps.Keys(after)) {
+			old, inOld := before[name]
+			addr, inNew := after[name]
+			switch {
+			case !inOld:
+				add("+ listener %s at %s", name, addr)
+			case !inNew:
+				add("- listener %s at %s", name, old)
+			case old != addr:
+				add("~ listener %s: %s -> %s", name, old, addr)
+			}
+		}
+	}
+
+	// Compare the configs. The binary is not compared, since deployers may
+	// copy it elsewhere.
+	if running.Config != nil && p.Config != nil {
+		if !slices.Equal(running.Config.Args, p.Config.Args) {
+			add("~ config: args %q -> %q", running.Config.Args, p.Config.Args)
+		}
+		if !slices.Equal(running.Config.Env, p.Config.Env) {
+			add("~ config: env %q -> %q", running.Config.Env, p.Config.Env)
+		}
+		if running.Config.RolloutNanos != p.Config.RolloutNanos {
+			add("~ config: rollout %v -> %v", time.Duration(running.Config.RolloutNanos), time.Duration(p.Config.RolloutNanos))
+		}
+		before, after := running.Config.Sections, p.Config.Sections
+		for _, key := range sortedUnion(maps.Keys(before), maps.Keys(after)) {
+			old, inOld := before[key]
+			section, inNew := after[key]
+			switch {
+			case !inOld:
+				add("+ config: [%s]", key)
+			case !inNew:
+				add("- config: [%s]", key)
+			case strings.TrimSpace(old) != strings.TrimSpace(section):
+				add("~ config: [%s]", key)
+			}
+		}
+	}
+	return changes
+}
+
+// sortedUnion returns the union of the provided slices, sorted and without
+// duplicates.
+func sortedUnion(x, y []string) []string {
+	union := append(slices.Clone(x), y...)
+	sort.Strings(union)
+	return slices.Compact(union)
+}
+
+// Write pretty-prints the provided plan, and the changes it makes to the
+// provided running deployments, keyed by deployment id, to w. If running is
+// nil, the running deployments are unknown, and no changes are printed.
+func Write(w io.Writer, p *Plan, running map[string]*Plan) {
+	title := []colors.Text{{{S: "PLAN", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.NoDim)
+	t.Row("APP", "DEPLOYER", "ROLLOUT")
+	t.Row(p.App, p.Deployer, p.Rollout)
+	t.Flush()
+
+	title = []colors.Text{{{S: "GROUPS", Bold: true}}}
+	t = colors.NewTabularizer(w, title, colors.PrefixDim)
+	t.Row("GROUP", "REPLICAS", "COMPONENT")
+	for _, g := range p.Groups {
+		for _, c := range g.Components {
+			t.Row(logging.ShortenComponent(g.Name), g.Replicas, logging.ShortenComponent(c))
+		}
+	}
+	t.Flush()
+
+	if p.Listeners != nil {
+		title = []colors.Text{{{S: "LISTENERS", Bold: true}}}
+		t = colors.NewTabularizer(w, title, colors.NoDim)
+		t.Row("LISTENER", "ADDRESS")
+		for _, l := range p.Listeners {
+			t.Row(l.Name, l.Address)
+		}
+		t.Flush()
+	}
+	if p.ListenerNote != "" {
+		fmt.Fprintf(w, "%s\n\n", p.ListenerNote)
+	}
+
+	if running == nil {
+		return
+	}
+	if len(running) == 0 {
+		fmt.Fprintf(w, "No running deployment of app %s.\n", p.App)
+		return
+	}
+	ids := maps.Keys(running)
+	sort.Strings(ids)
+	for _, id := range ids {
+		changes := Diff(running[id], p)
+		title = []colors.Text{{{S: fmt.Sprintf("CHANGES FROM RUNNING DEPLOYMENT %s", logging.Shorten(id)), Bold: true}}}
+		t = colors.NewTabularizer(w, title, colors.NoDim)
+		if len(changes) == 0 {
+			t.Row("none")
+		}
+		for _, change := range changes {
+			t.Row(change)
+		}
+		t.Flush()
+	}
+}
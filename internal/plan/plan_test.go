@@ -0,0 +1,211 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComponents(t *testing.T) {
+	// Test plan: Build the chat example, and list its components.
+	binary := buildChat(t)
+	got, err := Components(context.Background(), &protos.AppConfig{Binary: binary})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"github.com/ServiceWeaver/weaver/examples/chat/ImageScaler",
+		"github.com/ServiceWeaver/weaver/examples/chat/LocalCache",
+		"github.com/ServiceWeaver/weaver/examples/chat/SQLStore",
+		"main",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Components (-want +got):\n%s", diff)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	// Test plan: Build the chat example, and describe its components.
+	binary := buildChat(t)
+	schema, err := Schema(context.Background(), &protos.AppConfig{Binary: binary})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range schema.Components {
+		names = append(names, c.Name)
+	}
+	want := []string{
+		"github.com/ServiceWeaver/weaver/examples/chat/ImageScaler",
+		"github.com/ServiceWeaver/weaver/examples/chat/LocalCache",
+		"github.com/ServiceWeaver/weaver/examples/chat/SQLStore",
+		"main",
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("Schema components (-want +got):\n%s", diff)
+	}
+	cache := schema.Components[1]
+	if len(cache.Methods) == 0 || len(cache.Methods[0].Args) == 0 {
+		t.Fatalf("LocalCache schema: got %v, want methods with arguments", cache)
+	}
+}
+
+// buildChat builds the chat example, and returns the path of its binary.
+func buildChat(t *testing.T) string {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "chat")
+	build := exec.Command("go", "build", "-o", binary, "github.com/ServiceWeaver/weaver/examples/chat")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	return binary
+}
+
+func TestGroups(t *testing.T) {
+	app := &protos.AppConfig{
+		Colocate: []*protos.ComponentGroup{{Components: []string{"a/C", "a/A"}}},
+	}
+	replicas := func(group string) string {
+		if group == "a/C" {
+			return "3"
+		}
+		return "2"
+	}
+	got := Groups(app, []string{"a/A", "a/B", "a/C", "main"}, replicas)
+	want := []Group{
+		{Name: "a/B", Components: []string{"a/B"}, Replicas: "2"},
+		{Name: "a/C", Components: []string{"a/A", "a/C"}, Replicas: "3"},
+		{Name: "main", Components: []string{"main"}, Replicas: "2"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Groups (-want +got):\n%s", diff)
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	s := &status.Status{
+		App: "app",
+		Components: []*status.Component{
+			{Name: "a/B", Group: "a/A", Pids: []int64{3, 4}},
+			{Name: "a/A", Group: "a/A", Pids: []int64{3, 4}},
+			{Name: "main", Group: "main", Pids: []int64{1}},
+		},
+		Listeners: []*status.Listener{{Name: "lis", Addr: "localhost:9000"}},
+	}
+	got := FromStatus(s)
+	want := &Plan{
+		App: "app",
+		Groups: []Group{
+			{Name: "a/A", Components: []string{"a/A", "a/B"}, Replicas: "2"},
+			{Name: "main", Components: []string{"main"}, Replicas: "1"},
+		},
+		Listeners: []Listener{{Name: "lis", Address: "localhost:9000"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("FromStatus (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	running := &Plan{
+		Groups: []Group{
+			{Name: "a/A", Components: []string{"a/A", "a/B"}, Replicas: "2"},
+			{Name: "a/C", Components: []string{"a/C"}, Replicas: "2"},
+			{Name: "main", Components: []string{"main"}, Replicas: "2"},
+		},
+		Listeners: []Listener{{Name: "a", Address: ":80"}, {Name: "b", Address: ":81"}},
+		Config: &protos.AppConfig{
+			Args:     []string{"--x"},
+			Sections: map[string]string{"a/A": "x = 1", "a/C": "y = 1"},
+		},
+	}
+	p := &Plan{
+		Groups: []Group{
+			{Name: "a/A", Components: []string{"a/A", "a/D"}, Replicas: "3"},
+			{Name: "a/B", Components: []string{"a/B"}, Replicas: "2"},
+			{Name: "main", Components: []string{"main"}, Replicas: "2"},
+		},
+		Listeners: []Listener{{Name: "a", Address: ":8080"}, {Name: "c", Address: ":82"}},
+		Config: &protos.AppConfig{
+			Args:     []string{"--x"},
+			Sections: map[string]string{"a/A": "x = 2", "a/B": "z = 1"},
+		},
+	}
+	want := []string{
+		"~ group a/A: 2 -> 3 replica(s)",
+		"~ group a/A: - component a/B",
+		"~ group a/A: + component a/D",
+		"+ group a/B: 1 component(s), 2 replica(s)",
+		"- group a/C",
+		"~ listener a: :80 -> :8080",
+		"- listener b at :81",
+		"+ listener c at :82",
+		"~ config: [a/A]",
+		"+ config: [a/B]",
+		"- config: [a/C]",
+	}
+	if diff := cmp.Diff(want, Diff(running, p)); diff != "" {
+		t.Fatalf("Diff (-want +got):\n%s", diff)
+	}
+
+	// Listeners aren't compared if they're unknown.
+	p.Listeners = nil
+	for _, change := range Diff(running, p) {
+		if strings.Contains(change, "listener") {
+			t.Errorf("Diff: unexpected change %q", change)
+		}
+	}
+
+	// A plan doesn't change itself.
+	if changes := Diff(p, p); len(changes) != 0 {
+		t.Errorf("Diff(p, p): got %q, want no changes", changes)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	p := &Plan{
+		App:          "app",
+		Deployer:     "weaver multi",
+		Rollout:      "new deployment",
+		Groups:       []Group{{Name: "main", Components: []string{"main"}, Replicas: "2"}},
+		ListenerNote: "Listener addresses are chosen by the application.",
+	}
+	running := map[string]*Plan{
+		"11111111-1111-1111-1111-111111111111": {
+			Groups: []Group{{Name: "main", Components: []string{"main"}, Replicas: "1"}},
+		},
+	}
+	var b bytes.Buffer
+	Write(&b, p, running)
+	for _, want := range []string{
+		"weaver multi",
+		"Listener addresses are chosen by the application.",
+		"CHANGES FROM RUNNING DEPLOYMENT 11111111",
+		"~ group main: 1 -> 2 replica(s)",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Write: output doesn't contain %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profexport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+var (
+	// The endpoint of the projects.profiles.createOffline method of the
+	// Cloud Profiler API, for a project.
+	cloudProfilerURL = "https://cloudprofiler.googleapis.com/v2/projects/%s/profiles:createOffline"
+
+	// The endpoint of the metadata server that returns access tokens for
+	// the default service account.
+	tokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// cloudProfiler exports profiles to Google Cloud Profiler, using the
+// projects.profiles.createOffline method of the Cloud Profiler API [1].
+//
+// [1]: https://cloud.google.com/profiler/docs/reference/v2/rest/v2/projects.profiles/createOffline
+type cloudProfiler struct {
+	project string
+	service string
+	version string
+	client  *http.Client
+
+	// Access token of the default service account, and its expiration time.
+	// Only accessed by Export, which is not called concurrently.
+	token   string
+	expires time.Time
+}
+
+var _ Exporter = &cloudProfiler{}
+
+// cloudProfile is a Profile [1] in the body of a createOffline request.
+//
+// [1]: https://cloud.google.com/profiler/docs/reference/v2/rest/v2/projects.profiles#Profile
+type cloudProfile struct {
+	ProfileType  string          `json:"profileType"`
+	Deployment   cloudDeployment `json:"deployment"`
+	Duration     string          `json:"duration,omitempty"`
+	ProfileBytes []byte          `json:"profileBytes"` // gzipped pprof, encoded in base64
+}
+
+type cloudDeployment struct {
+	ProjectID string            `json:"projectId"`
+	Target    string            `json:"target"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func newCloudProfiler(config runtime.CloudProfilerConfig, app, deployment string) *cloudProfiler {
+	service := config.Service
+	if service == "" {
+		service = app
+	}
+	return &cloudProfiler{
+		project: config.Project,
+		service: service,
+		version: deployment,
+		client:  &http.Client{},
+	}
+}
+
+// Export implements the Exporter interface.
+func (c *cloudProfiler) Export(ctx context.Context, profile *protos.Profile) error {
+	var profileType string
+	switch profile.ProfileType {
+	case protos.ProfileType_CPU:
+		profileType = "CPU"
+	case protos.ProfileType_Heap:
+		profileType = "HEAP"
+	case protos.ProfileType_Mutex:
+		profileType = "CONTENTION"
+	default:
+		return fmt.Errorf("unsupported profile type %v", profile.ProfileType)
+	}
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body := cloudProfile{
+		ProfileType: profileType,
+		Deployment: cloudDeployment{
+			ProjectID: c.project,
+			Target:    c.service,
+			Labels:    map[string]string{"version": c.version},
+		},
+		ProfileBytes: profile.Data,
+	}
+	if _, duration := profileTime(profile); duration > 0 {
+		body.Duration = fmt.Sprintf("%gs", duration.Seconds())
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	return post(ctx, c.client, fmt.Sprintf(cloudProfilerURL, c.project), "application/json", headers, data)
+}
+
+// Close implements the Exporter interface.
+func (c *cloudProfiler) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// accessToken returns an access token of the default service account,
+// fetching a new one from the metadata server if the last one is about to
+// expire.
+func (c *cloudProfiler) accessToken(ctx context.Context) (string, error) {
+	if c.token != "" && time.Until(c.expires) > time.Minute {
+		return c.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch access token: %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"` // seconds
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("fetch access token: %w", err)
+	}
+	c.token = token.AccessToken
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profexport exports the profiles collected by the continuous
+// profiler of a weavelet to the profiling backends configured in the
+// [serviceweaver.profiling] section of the config: Grafana Pyroscope and
+// Google Cloud Profiler.
+package profexport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// An Exporter exports profiles to a profiling backend. Export is never
+// called concurrently.
+type Exporter interface {
+	// Export exports a profile.
+	Export(ctx context.Context, profile *protos.Profile) error
+
+	// Close releases the resources of the exporter.
+	Close() error
+}
+
+// New returns the exporters enabled in the provided config, for the weavelet
+// with the provided id, of the provided deployment of the provided
+// application.
+func New(config runtime.ProfilingConfig, app, deployment, weavelet string) []Exporter {
+	var exporters []Exporter
+	if config.Pyroscope != nil {
+		exporters = append(exporters, newPyroscope(*config.Pyroscope, app, deployment, weavelet))
+	}
+	if config.CloudProfiler != nil {
+		exporters = append(exporters, newCloudProfiler(*config.CloudProfiler, app, deployment))
+	}
+	return exporters
+}
+
+// profileTime returns the time at which the collection of the provided
+// profile started, and its duration.
+func profileTime(profile *protos.Profile) (time.Time, time.Duration) {
+	return time.UnixMicro(profile.TimeMicros), time.Duration(profile.DurationMicros) * time.Microsecond
+}
+
+// post sends the provided body, of the provided content type, to the
+// provided URL, and returns an error if the response doesn't have a 2xx
+// status code.
+func post(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
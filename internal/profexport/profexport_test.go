@@ -0,0 +1,161 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profexport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testProfile returns a CPU profile for testing.
+func testProfile() *protos.Profile {
+	return &protos.Profile{
+		ProfileType:    protos.ProfileType_CPU,
+		TimeMicros:     time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC).UnixMicro(),
+		DurationMicros: (10 * time.Second).Microseconds(),
+		Components:     []string{"github.com/example/boutique/cartservice/T"},
+		Data:           []byte("pprof data"),
+	}
+}
+
+func TestPyroscope(t *testing.T) {
+	var query map[string]string
+	var data, tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ingest" {
+			http.NotFound(w, r)
+			return
+		}
+		query = map[string]string{}
+		for name := range r.URL.Query() {
+			query[name] = r.URL.Query().Get(name)
+		}
+		tenant = r.Header.Get("X-Scope-OrgID")
+		f, _, err := r.FormFile("profile")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, err := io.ReadAll(f)
+		if err != nil {
+			t.Error(err)
+		}
+		data = string(b)
+	}))
+	defer server.Close()
+
+	p := newPyroscope(runtime.PyroscopeConfig{
+		URL:     server.URL + "/",
+		Headers: map[string]string{"X-Scope-OrgID": "team-a"},
+		Labels:  map[string]string{"env": "prod"},
+	}, "boutique", "v1", "w1")
+	defer p.Close()
+	if err := p.Export(context.Background(), testProfile()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"name":    "boutique{deployment=v1,env=prod,weavelet=w1}",
+		"from":    "1677672000",
+		"until":   "1677672010",
+		"format":  "pprof",
+		"spyName": "gospy",
+	}
+	if diff := cmp.Diff(want, query); diff != "" {
+		t.Errorf("query (-want +got):\n%s", diff)
+	}
+	if tenant != "team-a" {
+		t.Errorf("X-Scope-OrgID: got %q, want %q", tenant, "team-a")
+	}
+	if data != "pprof data" {
+		t.Errorf("profile: got %q, want %q", data, "pprof data")
+	}
+}
+
+func TestPyroscopeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many profiles", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	p := newPyroscope(runtime.PyroscopeConfig{URL: server.URL}, "boutique", "v1", "w1")
+	defer p.Close()
+	if err := p.Export(context.Background(), testProfile()); err == nil {
+		t.Fatal("Export: unexpected success")
+	}
+}
+
+func TestCloudProfiler(t *testing.T) {
+	var got cloudProfile
+	var path, auth string
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokens++
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"access_token": "secret", "expires_in": 3600}`)) //nolint:errcheck // test
+		default:
+			path = r.URL.Path
+			auth = r.Header.Get("Authorization")
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Error(err)
+			}
+		}
+	}))
+	defer server.Close()
+	defer func(create, token string) { cloudProfilerURL, tokenURL = create, token }(cloudProfilerURL, tokenURL)
+	cloudProfilerURL, tokenURL = server.URL+"/v2/projects/%s/profiles:createOffline", server.URL+"/token"
+
+	c := newCloudProfiler(runtime.CloudProfilerConfig{Project: "my-project"}, "boutique", "v1")
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if err := c.Export(context.Background(), testProfile()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("fetched %d access tokens, want 1", tokens)
+	}
+	if want := "Bearer secret"; auth != want {
+		t.Errorf("Authorization: got %q, want %q", auth, want)
+	}
+	if want := "/v2/projects/my-project/profiles:createOffline"; path != want {
+		t.Errorf("path: got %q, want %q", path, want)
+	}
+	want := cloudProfile{
+		ProfileType: "CPU",
+		Deployment: cloudDeployment{
+			ProjectID: "my-project",
+			Target:    "boutique",
+			Labels:    map[string]string{"version": "v1"},
+		},
+		Duration:     "10s",
+		ProfileBytes: []byte("pprof data"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("profile (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profexport
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// pyroscope exports profiles to Grafana Pyroscope, using its ingest API [1].
+//
+// [1]: https://grafana.com/docs/pyroscope/latest/configure-server/about-server-api/
+type pyroscope struct {
+	url     string            // URL of the ingest API
+	name    string            // application name, with labels
+	headers map[string]string // headers sent with every profile
+	client  *http.Client
+}
+
+var _ Exporter = &pyroscope{}
+
+func newPyroscope(config runtime.PyroscopeConfig, app, deployment, weavelet string) *pyroscope {
+	labels := map[string]string{}
+	for name, value := range config.Labels {
+		labels[name] = value
+	}
+	labels["deployment"] = deployment
+	labels["weavelet"] = weavelet
+	return &pyroscope{
+		url:     strings.TrimSuffix(config.URL, "/") + "/ingest",
+		name:    pyroscopeName(app, labels),
+		headers: config.Headers,
+		client:  &http.Client{},
+	}
+}
+
+// pyroscopeName returns the name of an application with the provided labels,
+// e.g., "boutique{deployment=1234,weavelet=5678}".
+func pyroscopeName(app string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(app)
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Export implements the Exporter interface.
+func (p *pyroscope) Export(ctx context.Context, profile *protos.Profile) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile.Data); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	start, duration := profileTime(profile)
+	query := url.Values{}
+	query.Set("name", p.name)
+	query.Set("from", strconv.FormatInt(start.Unix(), 10))
+	query.Set("until", strconv.FormatInt(start.Add(duration).Unix(), 10))
+	query.Set("format", "pprof")
+	query.Set("spyName", "gospy")
+	return post(ctx, p.client, p.url+"?"+query.Encode(), form.FormDataContentType(), p.headers, body.Bytes())
+}
+
+// Close implements the Exporter interface.
+func (p *pyroscope) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
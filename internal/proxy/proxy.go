@@ -15,53 +15,559 @@
 package proxy
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
-	"math/rand"
+	"fmt"
+	"hash/fnv"
+	"io"
+	mrand "math/rand"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ServiceWeaver/weaver/runtime"
+	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 )
 
+// MirrorHeader is the header set on the copies of the requests mirrored by a
+// proxy, so that the application receiving them can tell them apart, e.g., to
+// skip their side effects.
+const MirrorHeader = "X-Serviceweaver-Mirror"
+
+const (
+	// maxMirrorBytes is the size of the largest request body a proxy
+	// mirrors. Requests with larger bodies aren't mirrored.
+	maxMirrorBytes = 1 << 20
+
+	// maxMirrors bounds the number of mirrored requests a proxy has in
+	// flight. Requests aren't mirrored while the bound is reached, so that a
+	// slow mirror doesn't pile requests up.
+	maxMirrors = 64
+
+	// mirrorTimeout bounds the duration of a mirrored request.
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirrorClient sends mirrored requests. Redirects are returned, like they are
+// to the clients of the proxy, rather than followed.
+var mirrorClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
 // Proxy is an HTTP proxy that forwards traffic to a set of backends.
+//
+// Besides regular requests, a proxy forwards streams: WebSocket and other
+// connections upgraded to another protocol, and Server-Sent Events streams.
+// A stream is closed once it has been idle for the proxy's stream idle
+// timeout, if any, or once its backend is removed, e.g., because the backend
+// is draining, so that its client reconnects to another backend.
+//
+// A proxy may also mirror a fraction of the regular requests to another
+// proxy: a copy of a mirrored request is sent to the other proxy, and its
+// response is discarded.
+//
+// With session affinity, a proxy forwards the requests of a session to the
+// same backend, for as long as the backend is not removed. See
+// SetSessionAffinity.
 type Proxy struct {
-	logger   *slog.Logger          // logger
-	reverse  httputil.ReverseProxy // underlying proxy
-	mu       sync.Mutex            // guards backends
-	backends []string              // backend addresses
+	logger         *slog.Logger          // logger
+	reverse        httputil.ReverseProxy // underlying proxy
+	scheme         string                // scheme used to reach the backends
+	mirrors        chan struct{}         // mirrored requests in flight
+	mu             sync.Mutex            // guards the following
+	backends       []string              // backend addresses
+	split          string                // address of the proxy receiving a split of the traffic, if any
+	fraction       float64               // fraction of the traffic sent to split
+	mirror         string                // address of the proxy receiving mirrored traffic, if any
+	mirrorFraction float64               // fraction of the traffic mirrored to mirror
+	idleTimeout    time.Duration         // stream idle timeout, if positive
+	affinity       SessionAffinity       // session affinity
+	streams        map[*stream]struct{}  // streams being forwarded
 }
 
 // NewProxy returns a new proxy.
 func NewProxy(logger *slog.Logger) *Proxy {
-	p := &Proxy{logger: logger}
+	p := &Proxy{
+		logger:  logger,
+		scheme:  "http",
+		mirrors: make(chan struct{}, maxMirrors),
+		streams: map[*stream]struct{}{},
+	}
 	p.reverse = httputil.ReverseProxy{Director: p.director}
 	return p
 }
 
+// NewTLSProxy returns a new proxy that forwards traffic to backends that
+// serve TLS. The backends' certificates are not verified, as they are
+// typically issued for the public name of the application rather than for the
+// backend addresses.
+func NewTLSProxy(logger *slog.Logger) *Proxy {
+	p := &Proxy{
+		logger:  logger,
+		scheme:  "https",
+		mirrors: make(chan struct{}, maxMirrors),
+		streams: map[*stream]struct{}{},
+	}
+	p.reverse = httputil.ReverseProxy{
+		Director: p.director,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // see above
+		},
+	}
+	return p
+}
+
+// SessionAffinity specifies how a proxy identifies the session of a request,
+// whose requests it forwards to the same backend. At most one of Cookie and
+// Header is set. The zero value disables session affinity.
+type SessionAffinity struct {
+	// If not empty, a session is identified by the value of the cookie with
+	// this name. The proxy sets the cookie, to a random value, on the
+	// requests that don't have it, and on their responses, so that clients
+	// send it back.
+	Cookie string
+
+	// If not empty, a session is identified by the value of the header with
+	// this name. Requests without the header are spread across backends.
+	Header string
+}
+
+// ListenerAffinity returns the session affinity of the proxy of a listener
+// with the provided config.
+func ListenerAffinity(config runtime.ListenerConfig) SessionAffinity {
+	switch config.SessionAffinity {
+	case "cookie":
+		return SessionAffinity{Cookie: config.SessionKey}
+	case "header":
+		return SessionAffinity{Header: config.SessionKey}
+	default:
+		return SessionAffinity{}
+	}
+}
+
 // ServeHTTP implements the http.Handler interface.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	p.reverse.ServeHTTP(w, r)
+	if session := p.session(w, r); session != "" {
+		r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, session))
+	}
+	if !isStream(r) {
+		if addr := p.mirrorTo(); addr != "" {
+			r = p.mirrorRequest(r, addr)
+		}
+		p.reverse.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	s := &stream{cancel: cancel}
+	s.touch()
+	p.mu.Lock()
+	idleTimeout := p.idleTimeout
+	p.mu.Unlock()
+	if idleTimeout > 0 {
+		stop := s.watch(idleTimeout)
+		defer stop()
+	}
+	defer p.untrack(s)
+	r = r.WithContext(context.WithValue(ctx, streamKey{}, s))
+	p.reverse.ServeHTTP(&streamWriter{ResponseWriter: w, s: s}, r)
+}
+
+// SetStreamIdleTimeout sets how long a stream may go without data flowing in
+// either direction before the proxy closes it. A non-positive timeout, the
+// default, leaves idle streams open. The timeout applies to the streams
+// opened after the call.
+func (p *Proxy) SetStreamIdleTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idleTimeout = timeout
 }
 
-// AddBackend adds a backend to the proxy. Note that backends cannot be
-// removed.
+// SetSessionAffinity sets how the proxy identifies the sessions whose
+// requests it forwards to the same backend. A session is mapped to a backend
+// by rendezvous hashing of the session and the backend addresses, so that
+// proxies with the same backends agree on the backend of every session, and
+// removing a backend only moves the sessions of that backend. Adding a
+// backend moves a fair share of the sessions to it. The traffic split to
+// another proxy is split regardless of sessions.
+func (p *Proxy) SetSessionAffinity(affinity SessionAffinity) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.affinity = affinity
+}
+
+// session returns the session of the provided request, or the empty string
+// if the proxy has no session affinity, or the request has no session. With
+// cookie affinity, a new session is started for a request without one. The
+// session cookie is only sent over HTTPS if the proxy serves TLS, or forwards
+// to backends that do.
+func (p *Proxy) session(w http.ResponseWriter, r *http.Request) string {
+	p.mu.Lock()
+	affinity := p.affinity
+	p.mu.Unlock()
+	switch {
+	case affinity.Cookie != "":
+		if c, err := r.Cookie(affinity.Cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			p.logger.Error("new session", err)
+			return ""
+		}
+		session := hex.EncodeToString(b[:])
+		// Forward the cookie too, so that the backend sees the same session
+		// as the client on its next requests.
+		r.AddCookie(&http.Cookie{Name: affinity.Cookie, Value: session})
+		http.SetCookie(w, &http.Cookie{
+			Name:     affinity.Cookie,
+			Value:    session,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   p.scheme == "https" || r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return session
+	case affinity.Header != "":
+		return r.Header.Get(affinity.Header)
+	default:
+		return ""
+	}
+}
+
+// CloseStreams closes the streams being forwarded by the proxy. It is
+// typically called once the proxy stops serving, since shutting down an HTTP
+// server neither closes upgraded connections nor waits for long-lived
+// responses to end.
+func (p *Proxy) CloseStreams() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for s := range p.streams {
+		s.cancel()
+	}
+}
+
+// AddBackend adds a backend to the proxy.
 func (p *Proxy) AddBackend(backend string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.backends = append(p.backends, backend)
 }
 
+// RemoveBackend removes a backend from the proxy.
+func (p *Proxy) RemoveBackend(backend string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := slices.Index(p.backends, backend); i >= 0 {
+		p.backends = slices.Delete(p.backends, i, i+1)
+	}
+
+	// Close the streams to the backend, so that their clients reconnect to
+	// the remaining backends.
+	for s := range p.streams {
+		if s.backend == backend {
+			s.cancel()
+		}
+	}
+}
+
+// Split forwards the provided fraction, in [0, 1], of the traffic to the
+// proxy at the provided address, rather than to the backends. The proxy is
+// reached over HTTP, even if the backends serve TLS. It is typically the
+// proxy of another version of the application, being rolled out. An empty
+// address stops splitting the traffic.
+func (p *Proxy) Split(addr string, fraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.split = addr
+	p.fraction = fraction
+}
+
+// Mirror mirrors the provided fraction, in [0, 1], of the regular requests
+// to the proxy at the provided address: the requests are forwarded as usual,
+// and a copy of every mirrored request, with the MirrorHeader header set, is
+// sent to the proxy, whose response is discarded. Mirrored requests are
+// forwarded to the backends even if the traffic is split, so that the same
+// request isn't served twice by the proxy receiving the split. Streams, and
+// requests with bodies over 1 MiB, aren't mirrored. The proxy is reached over
+// HTTP. An empty address stops mirroring the traffic.
+func (p *Proxy) Mirror(addr string, fraction float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mirror = addr
+	p.mirrorFraction = fraction
+}
+
+// mirrorTo returns the address of the proxy to mirror a request to, or the
+// empty string if the request shouldn't be mirrored.
+func (p *Proxy) mirrorTo() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mirror == "" || mrand.Float64() >= p.mirrorFraction {
+		return ""
+	}
+	return p.mirror
+}
+
+// mirrorRequest sends a copy of the provided request to the proxy at the
+// provided address in the background, and returns the request to forward in
+// place of the provided one.
+func (p *Proxy) mirrorRequest(r *http.Request, addr string) *http.Request {
+	if r.ContentLength > maxMirrorBytes {
+		return r
+	}
+	select {
+	case p.mirrors <- struct{}{}:
+	default:
+		return r
+	}
+	release := func() { <-p.mirrors }
+
+	// Read the body, and put it back for the request to forward.
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMirrorBytes+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > maxMirrorBytes {
+		release()
+		return r
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	mirrored, err := http.NewRequestWithContext(ctx, r.Method, "http://"+addr+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		release()
+		return r
+	}
+	mirrored.Header = r.Header.Clone()
+	mirrored.Header.Set(MirrorHeader, "1")
+	// The copy starts a trace of its own.
+	mirrored.Header.Del("traceparent")
+	mirrored.Header.Del("tracestate")
+	mirrored.Host = r.Host
+	go func() {
+		defer release()
+		defer cancel()
+		resp, err := mirrorClient.Do(mirrored)
+		if err != nil {
+			p.logger.Debug("Mirrored request failed", "url", mirrored.URL, "err", err)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck // the response is discarded
+	}()
+	return r.WithContext(context.WithValue(r.Context(), mirroredKey{}, true))
+}
+
 // director implements a ReverseProxy.Director function [1].
 //
 // [1]: https://pkg.go.dev/net/http/httputil#ReverseProxy
 func (p *Proxy) director(r *http.Request) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	_, mirrored := r.Context().Value(mirroredKey{}).(bool)
+	if p.split != "" && !mirrored && mrand.Float64() < p.fraction {
+		r.URL.Scheme = "http"
+		r.URL.Host = p.split
+		p.track(r, p.split)
+		return
+	}
 	if len(p.backends) == 0 {
 		p.logger.Error("director", errors.New("no backends"), "url", r.URL)
 		return
 	}
-	r.URL.Scheme = "http" // TODO(mwhittaker): Support HTTPS.
-	r.URL.Host = p.backends[rand.Intn(len(p.backends))]
+	r.URL.Scheme = p.scheme
+	if session, ok := r.Context().Value(sessionKey{}).(string); ok {
+		r.URL.Host = pickBackend(p.backends, session)
+	} else {
+		r.URL.Host = p.backends[mrand.Intn(len(p.backends))]
+	}
+	p.track(r, r.URL.Host)
+}
+
+// pickBackend returns the backend of the provided session, using rendezvous
+// hashing: the backend with the highest hash of the session and the backend's
+// address.
+//
+// REQUIRES: len(backends) > 0.
+func pickBackend(backends []string, session string) string {
+	var best string
+	var bestHash uint64
+	for _, b := range backends {
+		h := fnv.New64a()
+		h.Write([]byte(session + "\x00" + b)) //nolint:errcheck // hashes never fail
+		if x := h.Sum64(); best == "" || x > bestHash {
+			best, bestHash = b, x
+		}
+	}
+	return best
+}
+
+// track records that the provided request, if it is a stream, is forwarded
+// to the provided address.
+//
+// REQUIRES: p.mu is held.
+func (p *Proxy) track(r *http.Request, addr string) {
+	if s, ok := r.Context().Value(streamKey{}).(*stream); ok {
+		s.backend = addr
+		p.streams[s] = struct{}{}
+	}
+}
+
+// untrack stops tracking the provided stream.
+func (p *Proxy) untrack(s *stream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.streams, s)
+}
+
+// isStream returns whether the provided request opens a stream, i.e., asks to
+// upgrade the connection to another protocol, like WebSocket, or accepts a
+// Server-Sent Events response.
+func isStream(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" && headerContains(r.Header, "Connection", "upgrade") {
+		return true
+	}
+	return headerContains(r.Header, "Accept", "text/event-stream")
+}
+
+// headerContains returns whether the comma-separated values of the provided
+// header contain the provided token, ignoring case and parameters.
+func headerContains(h http.Header, key, token string) bool {
+	for _, value := range h.Values(key) {
+		for _, v := range strings.Split(value, ",") {
+			v, _, _ = strings.Cut(v, ";")
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamKey is the context key of the stream of a request.
+type streamKey struct{}
+
+// sessionKey is the context key of the session of a request.
+type sessionKey struct{}
+
+// mirroredKey is the context key that marks mirrored requests.
+type mirroredKey struct{}
+
+// readCloser is an io.ReadCloser made of an io.Reader and an io.Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// stream is a stream being forwarded by a proxy.
+type stream struct {
+	cancel     context.CancelFunc // closes the stream
+	lastActive atomic.Int64       // when data last flowed, in Unix nanoseconds
+	backend    string             // address the stream is forwarded to; guarded by Proxy.mu
+}
+
+// touch records that data flowed on the stream.
+func (s *stream) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// watch closes the stream once it has been idle for the provided timeout. It
+// returns a function that stops watching the stream.
+func (s *stream) watch(timeout time.Duration) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	var check func()
+	check = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		idle := time.Since(time.Unix(0, s.lastActive.Load()))
+		if idle >= timeout {
+			s.cancel()
+			return
+		}
+		timer = time.AfterFunc(timeout-idle, check)
+	}
+	mu.Lock()
+	timer = time.AfterFunc(timeout, check)
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		timer.Stop()
+	}
+}
+
+// streamWriter is the http.ResponseWriter of a stream. It records the data
+// written on the stream, and, once the connection is upgraded, the data read
+// from and written to the connection.
+type streamWriter struct {
+	http.ResponseWriter
+	s *stream
+}
+
+// Write implements the http.ResponseWriter interface.
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.s.touch()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements the http.Flusher interface.
+func (w *streamWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface.
+func (w *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T is not an http.Hijacker", w.ResponseWriter)
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return streamConn{Conn: conn, s: w.s}, brw, nil
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for use by
+// http.ResponseController.
+func (w *streamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// streamConn is the upgraded connection of a stream. It records the data read
+// from and written to the connection.
+type streamConn struct {
+	net.Conn
+	s *stream
+}
+
+// Read implements the net.Conn interface.
+func (c streamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.s.touch()
+	}
+	return n, err
+}
+
+// Write implements the net.Conn interface.
+func (c streamConn) Write(b []byte) (int, error) {
+	c.s.touch()
+	return c.Conn.Write(b)
 }
@@ -0,0 +1,434 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"golang.org/x/exp/slog"
+)
+
+// backend is a test backend that serves a regular response at "/", a
+// Server-Sent Events stream at "/events", and upgrades connections to an
+// "echo" protocol at "/echo".
+func backend(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw) //nolint:errcheck // ends when the connection is closed
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serve serves a proxy to the provided backend, and returns the proxy and its
+// address.
+func serve(t *testing.T, b *httptest.Server) (*Proxy, string) {
+	t.Helper()
+	p := NewProxy(logging.NewTestLogger(t))
+	p.AddBackend(b.Listener.Addr().String())
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return p, server.Listener.Addr().String()
+}
+
+// openEvents opens a Server-Sent Events stream through the proxy at the
+// provided address, reads the first event, and returns the stream.
+func openEvents(t *testing.T, addr string) io.ReadCloser {
+	t.Helper()
+	req, err := http.NewRequest("GET", "http://"+addr+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "data: first\n"; line != want {
+		t.Fatalf("event: got %q, want %q", line, want)
+	}
+	return resp.Body
+}
+
+// openEcho opens an upgraded connection through the proxy at the provided
+// address, and checks that data is echoed on it.
+func openEcho(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintf(conn, "GET /echo HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n", addr)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status: got %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	fmt.Fprint(conn, "ping\n")
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "ping\n" {
+		t.Fatalf("echo: got %q, want %q", line, "ping\n")
+	}
+	return conn
+}
+
+// expectClosed checks that the provided stream is closed promptly.
+func expectClosed(t *testing.T, r io.Reader) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, r)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed")
+	}
+}
+
+func TestProxyRequest(t *testing.T) {
+	_, addr := serve(t, backend(t))
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("body: got %q, want %q", body, "hello")
+	}
+}
+
+func TestProxyRemoveBackendClosesStreams(t *testing.T) {
+	// Test plan: Open an event stream and an upgraded connection through a
+	// proxy. Remove their backend, and check that both are closed.
+	b := backend(t)
+	p, addr := serve(t, b)
+	events := openEvents(t, addr)
+	echo := openEcho(t, addr)
+	p.RemoveBackend(b.Listener.Addr().String())
+	expectClosed(t, events)
+	expectClosed(t, echo)
+}
+
+func TestProxyCloseStreams(t *testing.T) {
+	p, addr := serve(t, backend(t))
+	events := openEvents(t, addr)
+	echo := openEcho(t, addr)
+	p.CloseStreams()
+	expectClosed(t, events)
+	expectClosed(t, echo)
+}
+
+func TestProxyStreamIdleTimeout(t *testing.T) {
+	// Test plan: Keep an upgraded connection busy for longer than the idle
+	// timeout, and check that it stays open. Then, leave it idle, and check
+	// that it is closed.
+	p, addr := serve(t, backend(t))
+	const timeout = 200 * time.Millisecond
+	p.SetStreamIdleTimeout(timeout)
+	echo := openEcho(t, addr)
+	r := bufio.NewReader(echo)
+	for i := 0; i < 5; i++ {
+		time.Sleep(timeout / 2)
+		fmt.Fprint(echo, "ping\n")
+		if _, err := r.ReadString('\n'); err != nil {
+			t.Fatalf("busy connection closed: %v", err)
+		}
+	}
+	expectClosed(t, r)
+}
+
+func TestProxyMirror(t *testing.T) {
+	// Test plan: Mirror all the traffic of a proxy, which also splits all
+	// its traffic, to a mirror that records the requests it receives. Check
+	// that a request is served by the backend, and that a copy of it reaches
+	// the mirror.
+	type mirrored struct {
+		method, path, body, header string
+	}
+	received := make(chan mirrored, 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		received <- mirrored{r.Method, r.URL.RequestURI(), string(body), r.Header.Get(MirrorHeader)}
+		fmt.Fprint(w, "mirror")
+	}))
+	t.Cleanup(mirror.Close)
+	p, addr := serve(t, backend(t))
+	p.Split(mirror.Listener.Addr().String(), 1)
+	p.Mirror(mirror.Listener.Addr().String(), 1)
+
+	resp, err := http.Post("http://"+addr+"/orders?id=1", "text/plain", strings.NewReader("order"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("body: got %q, want %q", body, "hello")
+	}
+	select {
+	case got := <-received:
+		want := mirrored{"POST", "/orders?id=1", "order", "1"}
+		if got != want {
+			t.Fatalf("mirrored request: got %+v, want %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not mirrored")
+	}
+}
+
+// named returns a test backend that responds with its name, followed by the
+// value of the "session" cookie it received, if any.
+func named(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+		if c, err := r.Cookie("session"); err == nil {
+			fmt.Fprint(w, " ", c.Value)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get sends a GET request through the proxy at the provided address, with the
+// provided cookie and header, if any, and returns the response.
+func get(t *testing.T, addr string, cookie *http.Cookie, header string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", "http://"+addr+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	if header != "" {
+		req.Header.Set("X-Session", header)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestProxySessionAffinityCookie(t *testing.T) {
+	p := NewProxy(logging.NewTestLogger(t))
+	p.SetSessionAffinity(SessionAffinity{Cookie: "session"})
+	backends := map[string]string{}
+	for _, name := range []string{"a", "b", "c"} {
+		b := named(t, name)
+		backends[name] = b.Listener.Addr().String()
+		p.AddBackend(b.Listener.Addr().String())
+	}
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	addr := server.Listener.Addr().String()
+
+	// The proxy starts a session, and forwards its cookie to the backend.
+	resp, body := get(t, addr, nil, "")
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value == "" {
+		t.Fatalf("cookies: got %v, want a session cookie", cookies)
+	}
+	cookie := &http.Cookie{Name: "session", Value: cookies[0].Value}
+	name, session, _ := strings.Cut(body, " ")
+	if session != cookie.Value {
+		t.Fatalf("backend session: got %q, want %q", session, cookie.Value)
+	}
+
+	// The requests of the session go to the same backend, and the proxy
+	// doesn't start a new session.
+	for i := 0; i < 10; i++ {
+		resp, body := get(t, addr, cookie, "")
+		if len(resp.Cookies()) != 0 {
+			t.Fatalf("cookies: got %v, want none", resp.Cookies())
+		}
+		if want := name + " " + cookie.Value; body != want {
+			t.Fatalf("body: got %q, want %q", body, want)
+		}
+	}
+
+	// Once its backend is removed, the session moves to another backend, and
+	// stays there.
+	p.RemoveBackend(backends[name])
+	_, moved := get(t, addr, cookie, "")
+	if strings.HasPrefix(moved, name+" ") {
+		t.Fatalf("body: got %q from a removed backend", moved)
+	}
+	for i := 0; i < 10; i++ {
+		if _, body := get(t, addr, cookie, ""); body != moved {
+			t.Fatalf("body: got %q, want %q", body, moved)
+		}
+	}
+}
+
+func TestProxySessionCookieSecure(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		proxy  func(*slog.Logger) *Proxy
+		tls    bool // does the request arrive over TLS?
+		secure bool
+	}{
+		{"HTTP", NewProxy, false, false},
+		{"HTTPSRequest", NewProxy, true, true},
+		{"TLSProxy", NewTLSProxy, false, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.proxy(logging.NewTestLogger(t))
+			p.SetSessionAffinity(SessionAffinity{Cookie: "session"})
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+			if p.session(w, r) == "" {
+				t.Fatal("no session started")
+			}
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("cookies: got %v, want a session cookie", cookies)
+			}
+			if got := cookies[0].Secure; got != test.secure {
+				t.Fatalf("Secure: got %t, want %t", got, test.secure)
+			}
+		})
+	}
+}
+
+func TestProxySessionAffinityHeader(t *testing.T) {
+	p := NewProxy(logging.NewTestLogger(t))
+	p.SetSessionAffinity(SessionAffinity{Header: "X-Session"})
+	for _, name := range []string{"a", "b", "c"} {
+		p.AddBackend(named(t, name).Listener.Addr().String())
+	}
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	addr := server.Listener.Addr().String()
+
+	// The requests of a session go to the same backend, and sessions are
+	// spread across backends.
+	seen := map[string]bool{}
+	for i := 0; i < 30; i++ {
+		session := fmt.Sprint("user", i)
+		resp, first := get(t, addr, nil, session)
+		if len(resp.Cookies()) != 0 {
+			t.Fatalf("cookies: got %v, want none", resp.Cookies())
+		}
+		for j := 0; j < 3; j++ {
+			if _, body := get(t, addr, nil, session); body != first {
+				t.Fatalf("session %s: got %q, want %q", session, body, first)
+			}
+		}
+		seen[first] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("sessions all sent to %v", seen)
+	}
+}
+
+func TestPickBackend(t *testing.T) {
+	// Removing a backend only moves the sessions of that backend.
+	backends := []string{"a:1", "b:2", "c:3", "d:4"}
+	for i := 0; i < 100; i++ {
+		session := fmt.Sprint(i)
+		before := pickBackend(backends, session)
+		after := pickBackend([]string{"a:1", "c:3", "d:4"}, session)
+		if before != "b:2" && after != before {
+			t.Errorf("session %s: moved from %s to %s", session, before, after)
+		}
+	}
+}
+
+func TestIsStream(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"Accept: text/html", false},
+		{"Accept: text/html, text/event-stream;q=0.9", true},
+		{"Connection: keep-alive, Upgrade\nUpgrade: websocket", true},
+		{"Upgrade: websocket", false},
+		{"Connection: Upgrade", false},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		for _, line := range strings.Split(test.header, "\n") {
+			if key, value, ok := strings.Cut(line, ": "); ok {
+				r.Header.Add(key, value)
+			}
+		}
+		if got := isStream(r); got != test.want {
+			t.Errorf("isStream(%q): got %t, want %t", test.header, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// minRedeliveryDelay and maxRedeliveryDelay bound the delay before a
+	// message whose delivery failed is redelivered. The delay doubles with
+	// every failed delivery of the same message.
+	minRedeliveryDelay = 10 * time.Millisecond
+	maxRedeliveryDelay = 10 * time.Second
+)
+
+// A Deliverer delivers a message of a subscription to a subscriber. It
+// returns nil once the subscriber has handled the message, acknowledging it.
+type Deliverer func(context.Context, *protos.DeliverMessageRequest) error
+
+// A Broker delivers the messages published on topics to the subscribers of
+// every subscription to the topics. A subscription may have many subscribers,
+// e.g., the replicas of a component; every message is delivered to one of
+// them. The messages of a subscription are delivered one at a time, in the
+// order they were published, and a message is redelivered, to the same or a
+// different subscriber, until a delivery succeeds. A message is thus
+// delivered at least once, and possibly more than once, to every
+// subscription.
+type Broker struct {
+	ctx    context.Context
+	store  Store
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	subs map[Subscription]*subscription // subscriptions being delivered
+}
+
+// subscription is a subscription whose messages are being delivered by a
+// Broker.
+type subscription struct {
+	wake        chan struct{} // signaled when a message or subscriber is added
+	subscribers []*Deliverer  // guarded by Broker.mu
+	next        int           // index of the next subscriber, guarded by Broker.mu
+}
+
+// NewBroker returns a Broker that stores messages in the provided store. The
+// broker stops delivering messages when ctx is done.
+func NewBroker(ctx context.Context, store Store, logger *slog.Logger) *Broker {
+	return &Broker{
+		ctx:    ctx,
+		store:  store,
+		logger: logger,
+		subs:   map[Subscription]*subscription{},
+	}
+}
+
+// Publish stores the provided message for delivery to every subscription to
+// the topic. Publish returns once the message is stored.
+func (b *Broker) Publish(topic string, payload []byte) error {
+	if _, err := b.store.Publish(topic, payload); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub, s := range b.subs {
+		if sub.Topic == topic {
+			s.signal()
+		}
+	}
+	return nil
+}
+
+// Subscribe adds a subscriber to the provided subscription, creating the
+// subscription if it doesn't exist yet. Messages of the subscription are
+// delivered using deliver until the returned function is called.
+func (b *Broker) Subscribe(sub Subscription, deliver Deliverer) (func(), error) {
+	if err := b.store.Subscribe(sub); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.subs[sub]
+	if !ok {
+		s = &subscription{wake: make(chan struct{}, 1)}
+		b.subs[sub] = s
+		go b.deliver(sub, s)
+	}
+	d := &deliver
+	s.subscribers = append(s.subscribers, d)
+	s.signal()
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if i := slices.Index(s.subscribers, d); i >= 0 {
+			s.subscribers = slices.Delete(s.subscribers, i, i+1)
+		}
+	}, nil
+}
+
+// deliver delivers the messages of the provided subscription until b.ctx is
+// done.
+func (b *Broker) deliver(sub Subscription, s *subscription) {
+	delay := minRedeliveryDelay
+	for {
+		msg, err := b.store.Next(sub)
+		if err != nil {
+			b.logger.Error("cannot read message", err, "topic", sub.Topic, "subscription", sub.Name)
+			if !b.sleep(maxRedeliveryDelay) {
+				return
+			}
+			continue
+		}
+		var deliver Deliverer
+		if msg != nil {
+			deliver = b.pick(s)
+		}
+		if deliver == nil {
+			// Wait for a message or a subscriber.
+			select {
+			case <-s.wake:
+				continue
+			case <-b.ctx.Done():
+				return
+			}
+		}
+
+		req := &protos.DeliverMessageRequest{Subscription: sub.Name, Message: msg}
+		if err := deliver(b.ctx, req); err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			b.logger.Error("cannot deliver message", err, "topic", sub.Topic, "subscription", sub.Name, "id", msg.Id)
+			if !b.sleep(delay) {
+				return
+			}
+			delay *= 2
+			if delay > maxRedeliveryDelay {
+				delay = maxRedeliveryDelay
+			}
+			continue
+		}
+		delay = minRedeliveryDelay
+		if err := b.store.Ack(sub, msg.Id); err != nil {
+			// The message will be redelivered.
+			b.logger.Error("cannot ack message", err, "topic", sub.Topic, "subscription", sub.Name, "id", msg.Id)
+		}
+	}
+}
+
+// pick returns the subscriber of s that should receive the next message, or
+// nil if s has no subscribers. Subscribers are picked round robin.
+func (b *Broker) pick(s *subscription) Deliverer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(s.subscribers) == 0 {
+		return nil
+	}
+	d := s.subscribers[s.next%len(s.subscribers)]
+	s.next++
+	return *d
+}
+
+// sleep sleeps for the provided duration, returning false if b.ctx is done
+// first.
+func (b *Broker) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.ctx.Done():
+		return false
+	}
+}
+
+// signal wakes up the goroutine delivering the messages of s, if it is
+// waiting.
+func (s *subscription) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
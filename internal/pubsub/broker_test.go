@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// recorder is a subscriber that records the messages delivered to it.
+type recorder struct {
+	mu       sync.Mutex
+	payloads []string
+	fails    int // number of deliveries to fail
+}
+
+func (r *recorder) deliver(_ context.Context, req *protos.DeliverMessageRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fails > 0 {
+		r.fails--
+		return fmt.Errorf("injected failure")
+	}
+	r.payloads = append(r.payloads, string(req.Message.Payload))
+	return nil
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.payloads)
+}
+
+// await waits until the recorders have received n messages in total.
+func await(t *testing.T, n int, rs ...*recorder) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		got := 0
+		for _, r := range rs {
+			got += len(r.get())
+		}
+		if got >= n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d messages", n)
+}
+
+func newTestBroker(t *testing.T) *Broker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := slog.New(slog.NewTextHandler(os.Stderr))
+	return NewBroker(ctx, NewMemoryStore(), logger)
+}
+
+func TestBrokerFanOut(t *testing.T) {
+	// Test plan: Subscribe two subscriptions to a topic, and check that both
+	// receive every message, in order.
+	b := newTestBroker(t)
+	var x, y recorder
+	if _, err := b.Subscribe(Subscription{Topic: "orders", Name: "x"}, x.deliver); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscribe(Subscription{Topic: "orders", Name: "y"}, y.deliver); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "3"}
+	for _, p := range want {
+		if err := b.Publish("orders", []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	await(t, 3, &x)
+	await(t, 3, &y)
+	if got := x.get(); !slices.Equal(got, want) {
+		t.Fatalf("subscription x: got %v, want %v", got, want)
+	}
+	if got := y.get(); !slices.Equal(got, want) {
+		t.Fatalf("subscription y: got %v, want %v", got, want)
+	}
+}
+
+func TestBrokerRedelivery(t *testing.T) {
+	// Test plan: Fail the first deliveries of a message, and check that the
+	// message is redelivered until it is delivered, before later messages.
+	b := newTestBroker(t)
+	r := recorder{fails: 3}
+	if _, err := b.Subscribe(Subscription{Topic: "orders", Name: "x"}, r.deliver); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"1", "2"} {
+		if err := b.Publish("orders", []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	await(t, 2, &r)
+	if got, want := r.get(), []string{"1", "2"}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestBrokerSharedSubscription(t *testing.T) {
+	// Test plan: Subscribe two subscribers to the same subscription, and
+	// check that every message is delivered to one of them. Then, remove one
+	// subscriber, and check that the other receives the remaining messages,
+	// including those published while it had no peer.
+	b := newTestBroker(t)
+	sub := Subscription{Topic: "orders", Name: "x"}
+	var x, y recorder
+	if _, err := b.Subscribe(sub, x.deliver); err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe, err := b.Subscribe(sub, y.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := b.Publish("orders", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	await(t, 10, &x, &y)
+	if len(x.get()) == 0 || len(y.get()) == 0 {
+		t.Fatalf("messages not spread across subscribers: %v, %v", x.get(), y.get())
+	}
+
+	unsubscribe()
+	ny := len(y.get())
+	for i := 10; i < 20; i++ {
+		if err := b.Publish("orders", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	await(t, 20, &x, &y)
+	if got := len(y.get()); got != ny {
+		t.Fatalf("unsubscribed subscriber received %d more messages", got-ny)
+	}
+}
+
+func TestBrokerLateSubscriber(t *testing.T) {
+	// Test plan: Publish messages on a subscription with no subscribers, and
+	// check that they are delivered once a subscriber subscribes.
+	b := newTestBroker(t)
+	sub := Subscription{Topic: "orders", Name: "x"}
+	var x recorder
+	unsubscribe, err := b.Subscribe(sub, x.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe()
+	if err := b.Publish("orders", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	var y recorder
+	if _, err := b.Subscribe(sub, y.deliver); err != nil {
+		t.Fatal(err)
+	}
+	await(t, 1, &y)
+	if got := x.get(); len(got) != 0 {
+		t.Fatalf("unsubscribed subscriber received %v", got)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// ErrLocked is returned by OpenFileStore when the directory is in use by
+// another process.
+var ErrLocked = errors.New("topic store in use by another process")
+
+// fileStore is a Store that durably stores messages in a log file. Every
+// change to the store is appended to the log, and synced, before it is
+// applied. When the store is opened, the log is replayed and then compacted
+// to hold only the subscriptions and their unacked messages.
+type fileStore struct {
+	*memoryStore          // state of the store; memoryStore.mu guards file
+	filename     string   // log file name
+	file         *os.File // log file, opened for appending
+}
+
+var _ Store = &fileStore{}
+
+// record is an entry in a fileStore's log. Exactly one field is set.
+type record struct {
+	Subscribe *Subscription  `json:",omitempty"` // subscription created
+	Publish   *messageRecord `json:",omitempty"` // message published
+	Pending   *messageRecord `json:",omitempty"` // message pending on a subscription
+	Ack       *messageRecord `json:",omitempty"` // message acked on a subscription
+	NextId    uint64         `json:",omitempty"` // id of the next message
+}
+
+type messageRecord struct {
+	Subscription string `json:",omitempty"` // for Pending and Ack records
+	Id           uint64
+	Topic        string
+	Payload      []byte `json:",omitempty"` // for Publish and Pending records
+}
+
+// OpenFileStore returns a Store that durably stores messages in the provided
+// directory, creating the directory if needed. Subscriptions and unacked
+// messages are preserved across processes that open the same directory, one
+// at a time: if the directory is in use by another running process,
+// OpenFileStore returns ErrLocked.
+func OpenFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := lock(filepath.Join(dir, "pubsub.lock")); err != nil {
+		return nil, err
+	}
+	s := &fileStore{
+		memoryStore: newMemoryStore(),
+		filename:    filepath.Join(dir, "pubsub.log"),
+	}
+	if err := s.replay(); err != nil {
+		return nil, fmt.Errorf("replay %q: %w", s.filename, err)
+	}
+	if err := s.compact(); err != nil {
+		return nil, fmt.Errorf("compact %q: %w", s.filename, err)
+	}
+	return s, nil
+}
+
+// lock records the current process as the user of a store in the provided
+// lock file. It returns ErrLocked if the lock file names another process that
+// is still running. Lock files are never removed; a lock file is taken over
+// once the process it names has exited.
+func lock(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid != os.Getpid() && running(pid) {
+		return fmt.Errorf("%w: pid %d", ErrLocked, pid)
+	}
+	return os.WriteFile(filename, []byte(strconv.Itoa(os.Getpid())), 0600)
+}
+
+// replay applies the records in the log, if any.
+func (s *fileStore) replay() error {
+	f, err := os.Open(s.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var r record
+		err := dec.Decode(&r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// A truncated final record was never synced, so the change it
+			// records was never applied.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case r.Subscribe != nil:
+			s.subscribe(*r.Subscribe)
+		case r.Publish != nil:
+			s.publish(r.Publish.message())
+		case r.Pending != nil:
+			sub := Subscription{Topic: r.Pending.Topic, Name: r.Pending.Subscription}
+			s.enqueue(sub, r.Pending.message())
+		case r.Ack != nil:
+			sub := Subscription{Topic: r.Ack.Topic, Name: r.Ack.Subscription}
+			s.ack(sub, r.Ack.Id)
+		case r.NextId != 0:
+			if r.NextId > s.nextId {
+				s.nextId = r.NextId
+			}
+		}
+	}
+}
+
+// compact rewrites the log to hold only the current state of the store, and
+// opens the rewritten log for appending.
+func (s *fileStore) compact() error {
+	tmp := s.filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(record{NextId: s.nextId}); err != nil {
+		return err
+	}
+	for _, subs := range s.topics {
+		for _, sub := range subs {
+			sub := sub
+			if err := enc.Encode(record{Subscribe: &sub}); err != nil {
+				return err
+			}
+			for _, msg := range s.pending[sub] {
+				r := &messageRecord{Subscription: sub.Name, Id: msg.Id, Topic: msg.Topic, Payload: msg.Payload}
+				if err := enc.Encode(record{Pending: r}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.filename); err != nil {
+		return err
+	}
+	s.file, err = os.OpenFile(s.filename, os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// append appends a record to the log and syncs it.
+//
+// REQUIRES: s.mu is held.
+func (s *fileStore) append(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Subscribe implements the Store interface.
+func (s *fileStore) Subscribe(sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[sub]; ok {
+		return nil
+	}
+	if err := s.append(record{Subscribe: &sub}); err != nil {
+		return err
+	}
+	s.subscribe(sub)
+	return nil
+}
+
+// Publish implements the Store interface.
+func (s *fileStore) Publish(topic string, payload []byte) (*protos.TopicMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &messageRecord{Id: s.nextId, Topic: topic, Payload: payload}
+	if err := s.append(record{Publish: r}); err != nil {
+		return nil, err
+	}
+	msg := r.message()
+	s.publish(msg)
+	return msg, nil
+}
+
+// Ack implements the Store interface.
+func (s *fileStore) Ack(sub Subscription, id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isPending(sub, id) {
+		return nil
+	}
+	if err := s.append(record{Ack: &messageRecord{Subscription: sub.Name, Id: id, Topic: sub.Topic}}); err != nil {
+		return err
+	}
+	s.ack(sub, id)
+	return nil
+}
+
+// message returns the message recorded by r.
+func (r *messageRecord) message() *protos.TopicMessage {
+	return &protos.TopicMessage{Id: r.Id, Topic: r.Topic, Payload: r.Payload}
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pubsub implements the storage and delivery of the messages
+// published on weaver.Topics. A Broker stores the messages published on a
+// topic in a Store and delivers them, at least once, to the subscribers of
+// every subscription to the topic.
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// A Subscription identifies a subscription to a topic. A subscription
+// receives every message published on its topic after the subscription is
+// created. Subscriptions to the same topic are independent: every one of them
+// receives every message.
+type Subscription struct {
+	Topic string // topic name
+	Name  string // subscription name, unique per topic
+}
+
+// A Store stores the messages published on topics until every subscription
+// to their topic has acknowledged them. A Store must be safe for concurrent
+// use.
+type Store interface {
+	// Subscribe creates the provided subscription, if it doesn't exist yet.
+	Subscribe(Subscription) error
+
+	// Publish stores a message for every subscription to the provided topic,
+	// returning the stored message. Every message is assigned a unique id.
+	Publish(topic string, payload []byte) (*protos.TopicMessage, error)
+
+	// Next returns the oldest message not yet acknowledged by the provided
+	// subscription, or nil if there is none.
+	Next(Subscription) (*protos.TopicMessage, error)
+
+	// Ack acknowledges the message with the provided id on the provided
+	// subscription. An acknowledged message is never returned by Next again.
+	Ack(sub Subscription, id uint64) error
+}
+
+// memoryStore is a Store that keeps messages in memory.
+type memoryStore struct {
+	mu      sync.Mutex
+	nextId  uint64                                  // id of the next published message
+	topics  map[string][]Subscription               // subscriptions, by topic
+	pending map[Subscription][]*protos.TopicMessage // unacked messages, oldest first
+}
+
+var _ Store = &memoryStore{}
+
+// NewMemoryStore returns a Store that keeps messages in memory. Messages are
+// lost when the process exits.
+func NewMemoryStore() Store {
+	return newMemoryStore()
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		nextId:  1,
+		topics:  map[string][]Subscription{},
+		pending: map[Subscription][]*protos.TopicMessage{},
+	}
+}
+
+// Subscribe implements the Store interface.
+func (m *memoryStore) Subscribe(sub Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribe(sub)
+	return nil
+}
+
+// subscribe creates the provided subscription, if it doesn't exist yet.
+//
+// REQUIRES: m.mu is held.
+func (m *memoryStore) subscribe(sub Subscription) {
+	if _, ok := m.pending[sub]; ok {
+		return
+	}
+	m.topics[sub.Topic] = append(m.topics[sub.Topic], sub)
+	m.pending[sub] = []*protos.TopicMessage{}
+}
+
+// Publish implements the Store interface.
+func (m *memoryStore) Publish(topic string, payload []byte) (*protos.TopicMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := &protos.TopicMessage{Id: m.nextId, Topic: topic, Payload: payload}
+	m.publish(msg)
+	return msg, nil
+}
+
+// publish stores the provided message for every subscription to its topic.
+//
+// REQUIRES: m.mu is held.
+func (m *memoryStore) publish(msg *protos.TopicMessage) {
+	if msg.Id >= m.nextId {
+		m.nextId = msg.Id + 1
+	}
+	for _, sub := range m.topics[msg.Topic] {
+		m.enqueue(sub, msg)
+	}
+}
+
+// enqueue adds the provided message to the subscription's pending messages.
+//
+// REQUIRES: m.mu is held.
+func (m *memoryStore) enqueue(sub Subscription, msg *protos.TopicMessage) {
+	if msg.Id >= m.nextId {
+		m.nextId = msg.Id + 1
+	}
+	if _, ok := m.pending[sub]; ok {
+		m.pending[sub] = append(m.pending[sub], msg)
+	}
+}
+
+// Next implements the Store interface.
+func (m *memoryStore) Next(sub Subscription) (*protos.TopicMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending, ok := m.pending[sub]
+	if !ok {
+		return nil, fmt.Errorf("subscription %q to topic %q not found", sub.Name, sub.Topic)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	return pending[0], nil
+}
+
+// Ack implements the Store interface.
+func (m *memoryStore) Ack(sub Subscription, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ack(sub, id)
+	return nil
+}
+
+// isPending returns whether the message with the provided id is pending on
+// the subscription.
+//
+// REQUIRES: m.mu is held.
+func (m *memoryStore) isPending(sub Subscription, id uint64) bool {
+	for _, msg := range m.pending[sub] {
+		if msg.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ack removes the message with the provided id, if any, from the
+// subscription's pending messages.
+//
+// REQUIRES: m.mu is held.
+func (m *memoryStore) ack(sub Subscription, id uint64) {
+	pending := m.pending[sub]
+	for i, msg := range pending {
+		if msg.Id == id {
+			m.pending[sub] = append(pending[:i:i], pending[i+1:]...)
+			return
+		}
+	}
+}
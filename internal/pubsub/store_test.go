@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+)
+
+// drain returns the payloads of sub's pending messages, acking them.
+func drain(t *testing.T, s Store, sub Subscription) []string {
+	t.Helper()
+	var payloads []string
+	for {
+		msg, err := s.Next(sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil {
+			return payloads
+		}
+		payloads = append(payloads, string(msg.Payload))
+		if err := s.Ack(sub, msg.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func publish(t *testing.T, s Store, topic string, payloads ...string) []*protos.TopicMessage {
+	t.Helper()
+	var msgs []*protos.TopicMessage
+	for _, p := range payloads {
+		msg, err := s.Publish(topic, []byte(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func testStore(t *testing.T, s Store) {
+	a := Subscription{Topic: "orders", Name: "a"}
+	b := Subscription{Topic: "orders", Name: "b"}
+	other := Subscription{Topic: "other", Name: "a"}
+	for _, sub := range []Subscription{a, b, other} {
+		if err := s.Subscribe(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msgs := publish(t, s, "orders", "1", "2", "3")
+	if msgs[0].Id == msgs[1].Id || msgs[1].Id == msgs[2].Id {
+		t.Fatalf("duplicate message ids: %v", msgs)
+	}
+
+	// Every subscription receives every message, in order.
+	if got, want := drain(t, s, a), []string{"1", "2", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("subscription a: got %v, want %v", got, want)
+	}
+	if got, want := drain(t, s, b), []string{"1", "2", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("subscription b: got %v, want %v", got, want)
+	}
+	if got := drain(t, s, other); len(got) != 0 {
+		t.Fatalf("subscription to other topic: got %v, want nothing", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	// Test plan: Publish messages, and ack some of them. Reopen the store,
+	// twice to exercise compaction, and check that the subscriptions and their
+	// unacked messages are preserved.
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Subscription{Topic: "orders", Name: "a"}
+	b := Subscription{Topic: "orders", Name: "b"}
+	for _, sub := range []Subscription{a, b} {
+		if err := s.Subscribe(sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := publish(t, s, "orders", "1", "2", "3")
+	if err := s.Ack(a, msgs[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ack(b, msgs[1].Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err = OpenFileStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := drain(t, s, a), []string{"2", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("subscription a: got %v, want %v", got, want)
+	}
+	if got, want := drain(t, s, b), []string{"1", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("subscription b: got %v, want %v", got, want)
+	}
+
+	// Ids are not reused.
+	msg, err := s.Publish("orders", []byte("4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Id <= msgs[2].Id {
+		t.Fatalf("reused message id %d after reopening", msg.Id)
+	}
+}
+
+func TestFileStoreLocked(t *testing.T) {
+	// Test plan: Mark a store as in use by a running process, our parent, and
+	// check that it cannot be opened.
+	dir := t.TempDir()
+	lock := []byte(strconv.Itoa(os.Getppid()))
+	if err := os.WriteFile(filepath.Join(dir, "pubsub.lock"), lock, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("OpenFileStore: got %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,431 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rollout implements the rollouts of new versions of applications
+// deployed by the multi and ssh deployers.
+//
+// A new version is deployed side by side with the running version of an
+// application. The listener proxies of the running version forward a growing
+// fraction of their traffic to the listener proxies of the new version, until
+// the new version is either promoted or rolled back. Once promoted, the
+// running version stops serving its listeners and shuts down, and the new
+// version takes the addresses of the listeners over. Once rolled back, the
+// new version shuts down.
+//
+// The listener proxies of the running version may also mirror a fraction of
+// their traffic to the new version: the new version serves copies of the
+// requests served by the running version, and its responses are discarded.
+// Mirroring validates the new version against real traffic, through its
+// metrics, logs, and traces, before it serves any user.
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/compat"
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/internal/listeners"
+	"github.com/ServiceWeaver/weaver/internal/proxy"
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slog"
+)
+
+// DefaultDuration is the duration of a gradual rollout, if the config of
+// the app doesn't specify one.
+const DefaultDuration = 5 * time.Minute
+
+const (
+	// steps is the number of steps in which a gradual rollout shifts
+	// traffic to the new version.
+	steps = 10
+
+	// takeoverTimeout bounds the time the new version waits for the
+	// addresses of the listeners of the retired version to be released.
+	takeoverTimeout = 10 * time.Second
+)
+
+// Running returns the registration of the running deployment of the provided
+// app, over which a new version is rolled out.
+func Running(ctx context.Context, registry *status.Registry, app string) (status.Registration, error) {
+	regs, err := registry.List(ctx)
+	if err != nil {
+		return status.Registration{}, err
+	}
+	var running []status.Registration
+	for _, reg := range regs {
+		if reg.App == app {
+			running = append(running, reg)
+		}
+	}
+	switch len(running) {
+	case 0:
+		return status.Registration{}, fmt.Errorf("no running deployment of app %q to roll out over", app)
+	case 1:
+		return running[0], nil
+	default:
+		return status.Registration{}, fmt.Errorf("%d running deployments of app %q; want 1", len(running), app)
+	}
+}
+
+// CheckCompatible checks that the new version of an application, whose
+// components have the provided schemas, can run side by side with the
+// running version, reachable at the provided status server. If the versions
+// are incompatible, CheckCompatible returns an error that lists the
+// incompatible changes or, if allow is true, prints them to w as a warning.
+// The check is skipped, with a warning, if the running version doesn't
+// report its schemas.
+func CheckCompatible(ctx context.Context, server status.Server, schema *status.Schema, allow bool, w io.Writer) error {
+	running, err := server.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("status of the running version: %w", err)
+	}
+	if running.Schema == nil {
+		fmt.Fprintf(w, "Warning: running version %s doesn't report its component schemas; skipping the compatibility check\n", running.DeploymentId)
+		return nil
+	}
+	problems := compat.Compare(running.Schema, schema)
+	if len(problems) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "new version is incompatible with running version %s:\n", running.DeploymentId)
+	for _, problem := range problems {
+		fmt.Fprintf(&b, "  - %s\n", problem)
+	}
+	if !allow {
+		return fmt.Errorf("%sUse --allow-incompatible to roll it out anyway", b.String())
+	}
+	fmt.Fprintf(w, "Warning: %s", b.String())
+	return nil
+}
+
+// A Rollout rolls out a new version of an application over the running
+// version. It is used by the deployer of the new version.
+type Rollout struct {
+	version string        // deployment id of the new version
+	old     string        // deployment id of the running version
+	server  status.Server // status server of the running version
+	events  *events.Log   // audit log of the new version, or nil
+	logger  *slog.Logger
+
+	mu        sync.Mutex        // guards the following, and the requests sent to server
+	traffic   float64           // percentage of traffic sent to the new version
+	mirror    float64           // percentage of traffic mirrored to the new version
+	manual    bool              // is the rollout controlled manually?
+	proxies   map[string]string // proxies of the new version, by listener name
+	takeovers map[string]string // addresses held by the running version, by listener name
+	done      bool              // has the rollout been promoted or rolled back?
+}
+
+// New returns a new rollout of the new version with the provided deployment
+// id over the running version old, reachable at the provided status server.
+// Initially, traffic percent of the listener traffic is sent to the new
+// version, and mirror percent of it is mirrored to the new version. If manual
+// is true, the traffic only changes when requested with Handle; otherwise, Run
+// shifts it gradually. The steps of the rollout are recorded in the provided
+// audit log, which may be nil.
+func New(version string, old status.Registration, server status.Server, traffic, mirror float64, manual bool, events *events.Log, logger *slog.Logger) *Rollout {
+	return &Rollout{
+		version:   version,
+		old:       old.DeploymentId,
+		server:    server,
+		events:    events,
+		logger:    logger,
+		traffic:   traffic,
+		mirror:    mirror,
+		manual:    manual,
+		proxies:   map[string]string{},
+		takeovers: map[string]string{},
+	}
+}
+
+// Listen listens on the provided address for the proxy of the provided
+// listener. If the running version holds the address, Listen listens on a
+// random port on localhost instead, and the proxy takes the address over
+// once the new version is promoted.
+func (r *Rollout) Listen(listener, addr string) (net.Listener, error) {
+	lis, err := listeners.Listen(addr)
+	if !listeners.IsAddrInUse(err) {
+		return lis, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return nil, err
+	}
+	lis, err = net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	r.takeovers[listener] = addr
+	return lis, nil
+}
+
+// AddProxy registers the proxy of the provided listener of the new version,
+// reachable at the provided address, so that the running version forwards
+// traffic to it.
+func (r *Rollout) AddProxy(ctx context.Context, listener, addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.proxies[listener] = addr
+	if r.done {
+		return nil
+	}
+	return r.split(ctx, false)
+}
+
+// Handle handles a request to change the course of the rollout. serve serves
+// the proxy of a listener on the listener address taken over from the
+// running version, once promoted. It is called without holding any lock of
+// the Rollout.
+func (r *Rollout) Handle(ctx context.Context, req *status.RolloutRequest, serve func(listener string, lis net.Listener)) error {
+	r.mu.Lock()
+	r.manual = true
+	r.mu.Unlock()
+	switch {
+	case req.Promote && req.Rollback:
+		return fmt.Errorf("cannot both promote and roll back")
+	case req.Promote:
+		return r.promote(ctx, serve)
+	case req.Rollback:
+		return r.rollback(ctx)
+	case req.Mirror:
+		return r.setMirror(ctx, req.TrafficPercent)
+	default:
+		return r.setTraffic(ctx, req.TrafficPercent)
+	}
+}
+
+// Run shifts traffic to the new version gradually, in equal steps over the
+// provided duration, and then promotes it. Run returns early if the rollout
+// is controlled manually.
+func (r *Rollout) Run(ctx context.Context, duration time.Duration, serve func(listener string, lis net.Listener)) error {
+	ticker := time.NewTicker(duration / steps)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		manual, traffic := r.manual, r.traffic
+		r.mu.Unlock()
+		if manual {
+			return nil
+		}
+		if traffic >= 100 {
+			return r.promote(ctx, serve)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if err := r.setTraffic(ctx, traffic+100.0/steps); err != nil {
+			return err
+		}
+	}
+}
+
+// Abort stops sending traffic to the new version, if it was neither promoted
+// nor rolled back. It is called when the new version stops.
+func (r *Rollout) Abort(ctx context.Context) error {
+	return r.rollback(ctx)
+}
+
+// Status returns the status of the rollout, or nil if the rollout is done.
+func (r *Rollout) Status() *status.Rollout {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return nil
+	}
+	return &status.Rollout{OldVersion: r.old, NewVersion: r.version, TrafficPercent: r.traffic, MirrorPercent: r.mirror}
+}
+
+// setTraffic sets the percentage of the listener traffic sent to the new
+// version.
+func (r *Rollout) setTraffic(ctx context.Context, percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("traffic percentage %v not in [0, 100]", percent)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return fmt.Errorf("rollout of %s already done", r.version)
+	}
+	r.traffic = percent
+	r.logger.Info("Rollout traffic changed", "old", r.old, "new", r.version, "traffic", percent)
+	if err := r.split(ctx, false); err != nil {
+		return err
+	}
+	r.events.Record(events.RolloutTraffic, "", "", "Rollout traffic changed", "old", r.old, "traffic", fmt.Sprint(percent))
+	return nil
+}
+
+// setMirror sets the percentage of the listener traffic mirrored to the new
+// version.
+func (r *Rollout) setMirror(ctx context.Context, percent float64) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("mirrored traffic percentage %v not in [0, 100]", percent)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return fmt.Errorf("rollout of %s already done", r.version)
+	}
+	r.mirror = percent
+	r.logger.Info("Rollout mirrored traffic changed", "old", r.old, "new", r.version, "mirror", percent)
+	if err := r.split(ctx, false); err != nil {
+		return err
+	}
+	r.events.Record(events.RolloutMirror, "", "", "Rollout mirrored traffic changed", "old", r.old, "mirror", fmt.Sprint(percent))
+	return nil
+}
+
+// promote sends all traffic to the new version, retires the running version,
+// and takes the addresses of its listeners over.
+func (r *Rollout) promote(ctx context.Context, serve func(listener string, lis net.Listener)) error {
+	r.mu.Lock()
+	if r.done {
+		r.mu.Unlock()
+		return fmt.Errorf("rollout of %s already done", r.version)
+	}
+	r.traffic = 100
+	r.mirror = 0
+	if err := r.split(ctx, true); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.done = true
+	takeovers := maps.Clone(r.takeovers)
+	r.mu.Unlock()
+	r.logger.Info("Rollout promoted", "old", r.old, "new", r.version)
+	r.events.Record(events.RolloutPromoted, "", "", "Rollout promoted", "old", r.old)
+
+	// The running version closes its listeners before replying, but the
+	// operating system may take a moment to release their addresses.
+	var errs []error
+	for listener, addr := range takeovers {
+		lis, err := listenRetry(ctx, addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listener %q: %w", listener, err))
+			continue
+		}
+		r.logger.Info("Proxy listening", "listener", listener, "address", addr)
+		serve(listener, lis)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("take listeners over: %v", errs)
+	}
+	return nil
+}
+
+// rollback sends all traffic back to the running version.
+func (r *Rollout) rollback(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return nil
+	}
+	r.traffic = 0
+	r.mirror = 0
+	maps.Clear(r.proxies)
+	if err := r.split(ctx, false); err != nil {
+		return err
+	}
+	r.done = true
+	r.logger.Info("Rollout rolled back", "old", r.old, "new", r.version)
+	r.events.Record(events.RolloutRolledBack, "", "", "Rollout rolled back", "old", r.old)
+	return nil
+}
+
+// split asks the running version to split its traffic as per the state of
+// the rollout.
+//
+// REQUIRES: r.mu is held.
+func (r *Rollout) split(ctx context.Context, retire bool) error {
+	req := &status.SplitTrafficRequest{
+		Version:        r.version,
+		Proxies:        maps.Clone(r.proxies),
+		TrafficPercent: r.traffic,
+		Retire:         retire,
+		MirrorPercent:  r.mirror,
+	}
+	if _, err := r.server.SplitTraffic(ctx, req); err != nil {
+		return fmt.Errorf("split traffic of %s: %w", r.old, err)
+	}
+	return nil
+}
+
+// listenRetry listens on the provided address, retrying while the address is
+// in use, for up to takeoverTimeout.
+func listenRetry(ctx context.Context, addr string) (net.Listener, error) {
+	ctx, cancel := context.WithTimeout(ctx, takeoverTimeout)
+	defer cancel()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		lis, err := listeners.Listen(addr)
+		if !listeners.IsAddrInUse(err) {
+			return lis, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// A Split splits the traffic of the listeners of the running version of an
+// application with a new version being rolled out, as requested by the new
+// version. It is used by the deployer of the running version.
+type Split struct {
+	mu  sync.Mutex
+	req *status.SplitTrafficRequest // latest request, or nil
+}
+
+// Set records a request to split traffic.
+func (s *Split) Set(req *status.SplitTrafficRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.req = req
+}
+
+// Apply splits and mirrors the traffic of the proxy of the provided listener as
+// requested.
+func (s *Split) Apply(listener string, p *proxy.Proxy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.req == nil {
+		return
+	}
+	p.Split(s.req.Proxies[listener], s.req.TrafficPercent/100)
+	p.Mirror(s.req.Proxies[listener], s.req.MirrorPercent/100)
+}
+
+// Status returns the status of the rollout over the running version with the
+// provided deployment id, or nil if no traffic is split.
+func (s *Split) Status(version string) *status.Rollout {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.req == nil || len(s.req.Proxies) == 0 {
+		return nil
+	}
+	return &status.Rollout{OldVersion: version, NewVersion: s.req.Version, TrafficPercent: s.req.TrafficPercent, MirrorPercent: s.req.MirrorPercent}
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollout
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slog"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeServer is a fake status server of the running version that records
+// the requests to split traffic.
+type fakeServer struct {
+	status.Server // unimplemented methods panic
+
+	mu       sync.Mutex
+	requests []*status.SplitTrafficRequest
+	onRetire func() // if not nil, called when retired
+}
+
+// SplitTraffic implements the status.Server interface.
+func (f *fakeServer) SplitTraffic(_ context.Context, req *status.SplitTrafficRequest) (*status.SplitTrafficReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if req.Retire && f.onRetire != nil {
+		f.onRetire()
+	}
+	return &status.SplitTrafficReply{}, nil
+}
+
+func newRollout(server status.Server, traffic float64) *Rollout {
+	logger := slog.New(slog.NewTextHandler(os.Stderr))
+	old := status.Registration{DeploymentId: "old", App: "app"}
+	return New("new", old, server, traffic, 0, true, nil, logger)
+}
+
+func TestRolloutPromote(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	r := newRollout(server, 25)
+	if err := r.AddProxy(ctx, "a", "localhost:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{TrafficPercent: 50}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := &status.Rollout{OldVersion: "old", NewVersion: "new", TrafficPercent: 50}
+	if diff := cmp.Diff(want, r.Status(), protocmp.Transform()); diff != "" {
+		t.Fatalf("Status (-want +got):\n%s", diff)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{Promote: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Status(); got != nil {
+		t.Fatalf("Status after promotion: got %v, want nil", got)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{TrafficPercent: 10}, nil); err == nil {
+		t.Fatal("Handle after promotion: unexpected success")
+	}
+
+	proxies := map[string]string{"a": "localhost:1"}
+	wantReqs := []*status.SplitTrafficRequest{
+		{Version: "new", Proxies: proxies, TrafficPercent: 25},
+		{Version: "new", Proxies: proxies, TrafficPercent: 50},
+		{Version: "new", Proxies: proxies, TrafficPercent: 100, Retire: true},
+	}
+	if diff := cmp.Diff(wantReqs, server.requests, protocmp.Transform()); diff != "" {
+		t.Fatalf("SplitTraffic requests (-want +got):\n%s", diff)
+	}
+}
+
+func TestRolloutRollback(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	r := newRollout(server, 50)
+	if err := r.AddProxy(ctx, "a", "localhost:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{Rollback: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Aborting a rolled back rollout is a no-op.
+	if err := r.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	wantReqs := []*status.SplitTrafficRequest{
+		{Version: "new", Proxies: map[string]string{"a": "localhost:1"}, TrafficPercent: 50},
+		{Version: "new", Proxies: map[string]string{}, TrafficPercent: 0},
+	}
+	if diff := cmp.Diff(wantReqs, server.requests, protocmp.Transform()); diff != "" {
+		t.Fatalf("SplitTraffic requests (-want +got):\n%s", diff)
+	}
+}
+
+func TestRolloutMirror(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	r := newRollout(server, 0)
+	if err := r.AddProxy(ctx, "a", "localhost:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{TrafficPercent: 10, Mirror: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{TrafficPercent: 150, Mirror: true}, nil); err == nil {
+		t.Fatal("Handle with mirrored traffic over 100%: unexpected success")
+	}
+	want := &status.Rollout{OldVersion: "old", NewVersion: "new", MirrorPercent: 10}
+	if diff := cmp.Diff(want, r.Status(), protocmp.Transform()); diff != "" {
+		t.Fatalf("Status (-want +got):\n%s", diff)
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{Rollback: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	proxies := map[string]string{"a": "localhost:1"}
+	wantReqs := []*status.SplitTrafficRequest{
+		{Version: "new", Proxies: proxies},
+		{Version: "new", Proxies: proxies, MirrorPercent: 10},
+		{Version: "new", Proxies: map[string]string{}},
+	}
+	if diff := cmp.Diff(wantReqs, server.requests, protocmp.Transform()); diff != "" {
+		t.Fatalf("SplitTraffic requests (-want +got):\n%s", diff)
+	}
+}
+
+func TestRolloutTakeover(t *testing.T) {
+	ctx := context.Background()
+
+	// The running version holds the listener address, and releases it when
+	// retired.
+	held, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := held.Addr().String()
+	server := &fakeServer{onRetire: func() { held.Close() }}
+	r := newRollout(server, 0)
+
+	lis, err := r.Listen("a", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	if lis.Addr().String() == addr {
+		t.Fatalf("Listen(%q): listening on the held address", addr)
+	}
+
+	served := map[string]string{}
+	serve := func(listener string, lis net.Listener) {
+		served[listener] = lis.Addr().String()
+		lis.Close()
+	}
+	if err := r.Handle(ctx, &status.RolloutRequest{Promote: true}, serve); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"a": addr}, served); diff != "" {
+		t.Fatalf("served listeners (-want +got):\n%s", diff)
+	}
+}
+
+func TestSplitStatus(t *testing.T) {
+	var s Split
+	if got := s.Status("old"); got != nil {
+		t.Fatalf("Status before Set: got %v, want nil", got)
+	}
+	s.Set(&status.SplitTrafficRequest{Version: "new", Proxies: map[string]string{"a": "localhost:1"}, TrafficPercent: 10})
+	want := &status.Rollout{OldVersion: "old", NewVersion: "new", TrafficPercent: 10}
+	if diff := cmp.Diff(want, s.Status("old"), protocmp.Transform()); diff != "" {
+		t.Fatalf("Status (-want +got):\n%s", diff)
+	}
+	s.Set(&status.SplitTrafficRequest{Version: "new"})
+	if got := s.Status("old"); got != nil {
+		t.Fatalf("Status after rollback: got %v, want nil", got)
+	}
+}
+
+// schemaServer is a fake status server of the running version that reports
+// the provided component schemas.
+type schemaServer struct {
+	status.Server // unimplemented methods panic
+	schema        *status.Schema
+}
+
+// Status implements the status.Server interface.
+func (s schemaServer) Status(context.Context) (*status.Status, error) {
+	return &status.Status{DeploymentId: "old", Schema: s.schema}, nil
+}
+
+func TestCheckCompatible(t *testing.T) {
+	ctx := context.Background()
+	schema := func(args ...string) *status.Schema {
+		return &status.Schema{Components: []*status.ComponentSchema{{
+			Name:    "c",
+			Methods: []*status.MethodSchema{{Name: "M", Args: args}},
+		}}}
+	}
+	old := schemaServer{schema: schema("int")}
+
+	var w strings.Builder
+	if err := CheckCompatible(ctx, old, schema("int"), false, &w); err != nil || w.Len() > 0 {
+		t.Fatalf("CheckCompatible(compatible): got %v, %q", err, w.String())
+	}
+	err := CheckCompatible(ctx, old, schema("string"), false, &w)
+	if err == nil || !strings.Contains(err.Error(), "argument 0 changed from int to string") {
+		t.Fatalf("CheckCompatible(incompatible): got %v, want incompatibility", err)
+	}
+	if err := CheckCompatible(ctx, old, schema("string"), true, &w); err != nil || !strings.Contains(w.String(), "Warning") {
+		t.Fatalf("CheckCompatible(incompatible, allowed): got %v, %q", err, w.String())
+	}
+	w.Reset()
+	if err := CheckCompatible(ctx, schemaServer{}, schema("string"), false, &w); err != nil || !strings.Contains(w.String(), "skipping") {
+		t.Fatalf("CheckCompatible(unknown schema): got %v, %q", err, w.String())
+	}
+}
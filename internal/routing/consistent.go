@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// virtualNodes is the number of points every replica has on the hash
+	// ring of ConsistentHash. More points spread the key space more evenly
+	// across replicas, at the cost of larger assignments.
+	virtualNodes = 64
+
+	// DefaultLoadFactor is the default load factor of ConsistentHash.
+	DefaultLoadFactor = 1.25
+)
+
+// point is a point on the hash ring of ConsistentHash.
+type point struct {
+	hash    uint64
+	replica string
+}
+
+// ConsistentHash returns an assignment that assigns keys to replicas using
+// consistent hashing with bounded loads. Every replica is hashed to a number
+// of points on a ring of keys, and a key is assigned to the replica of the
+// first point at or before the key, along with the replicas of the next
+// points on the ring, for a total of replication distinct replicas.
+//
+// Unlike with EqualSlices, adding or removing a replica only moves the keys
+// next to the replica's points, so most keys stay assigned to the same
+// replicas as the replica set changes. The key space assigned to a replica,
+// split evenly among the replicas of every slice, is bounded by loadFactor
+// times the average; slices that would exceed the bound are assigned to the
+// following replicas on the ring instead. A loadFactor of zero means
+// DefaultLoadFactor. A replication of zero means one; a replication larger
+// than the number of replicas means every replica. The returned assignment
+// has a version of 0.
+func ConsistentHash(replicas []string, replication int, loadFactor float64) *protos.Assignment {
+	if len(replicas) == 0 {
+		return &protos.Assignment{}
+	}
+	replicas = slices.Clone(replicas)
+	sort.Strings(replicas)
+	replicas = slices.Compact(replicas)
+	if replication <= 0 {
+		replication = 1
+	}
+	if replication > len(replicas) {
+		replication = len(replicas)
+	}
+	if loadFactor == 0 {
+		loadFactor = DefaultLoadFactor
+	}
+
+	// Place the replicas on the ring.
+	points := make([]point, 0, len(replicas)*virtualNodes)
+	for _, replica := range replicas {
+		for i := 0; i < virtualNodes; i++ {
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%s#%d", replica, i)))
+			points = append(points, point{binary.LittleEndian.Uint64(sum[:8]), replica})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].replica < points[j].replica
+	})
+
+	// Every point starts a slice that ends at the next point. The keys
+	// before the first point belong to the last point, so if the first point
+	// isn't at zero, the last point also starts the slice at zero.
+	type slice struct {
+		start uint64
+		width float64
+		point int // index of the point that owns the slice
+	}
+	var ring []slice
+	last := len(points) - 1
+	if points[0].hash != 0 {
+		ring = append(ring, slice{start: 0, width: float64(points[0].hash), point: last})
+	}
+	for i, p := range points {
+		if i < last && points[i+1].hash == p.hash {
+			// Skip an empty slice.
+			continue
+		}
+		end := float64(math.MaxUint64)
+		if i < last {
+			end = float64(points[i+1].hash)
+		}
+		ring = append(ring, slice{start: p.hash, width: end - float64(p.hash), point: i})
+	}
+
+	// Assign every slice, in ring order, to the first replicas after its
+	// point that have room for it, or to the least loaded replicas if too
+	// few do.
+	bound := loadFactor * float64(math.MaxUint64) / float64(len(replicas))
+	load := map[string]float64{}
+	assignment := &protos.Assignment{}
+	for _, s := range ring {
+		share := s.width / float64(replication)
+		var candidates []string
+		for i := 0; i < len(points) && len(candidates) < len(replicas); i++ {
+			replica := points[(s.point+i)%len(points)].replica
+			if !slices.Contains(candidates, replica) {
+				candidates = append(candidates, replica)
+			}
+		}
+		var assigned []string
+		for _, replica := range candidates {
+			if len(assigned) < replication && load[replica]+share <= bound {
+				assigned = append(assigned, replica)
+			}
+		}
+		if len(assigned) < replication {
+			rest := slices.Clone(candidates)
+			sort.SliceStable(rest, func(i, j int) bool { return load[rest[i]] < load[rest[j]] })
+			for _, replica := range rest {
+				if len(assigned) < replication && !slices.Contains(assigned, replica) {
+					assigned = append(assigned, replica)
+				}
+			}
+		}
+		for _, replica := range assigned {
+			load[replica] += share
+		}
+
+		// Merge adjacent slices with the same replicas.
+		if n := len(assignment.Slices); n > 0 && slices.Equal(assignment.Slices[n-1].Replicas, assigned) {
+			continue
+		}
+		assignment.Slices = append(assignment.Slices, &protos.Assignment_Slice{Start: s.start, Replicas: assigned})
+	}
+	return assignment
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// replicasFor returns the replicas assigned to the provided key.
+func replicasFor(a *protos.Assignment, key uint64) []string {
+	i := sort.Search(len(a.Slices), func(i int) bool { return a.Slices[i].Start > key })
+	return a.Slices[i-1].Replicas
+}
+
+// names returns n replica names.
+func names(n int) []string {
+	var replicas []string
+	for i := 0; i < n; i++ {
+		replicas = append(replicas, fmt.Sprintf("tcp://10.0.0.%d:9000", i))
+	}
+	return replicas
+}
+
+// checkValid checks that a is a valid assignment in which every key is
+// assigned to replication distinct replicas.
+func checkValid(t *testing.T, a *protos.Assignment, replication int) {
+	t.Helper()
+	if len(a.Slices) == 0 || a.Slices[0].Start != 0 {
+		t.Fatalf("assignment doesn't start at 0:\n%s", FormatAssignment(a))
+	}
+	for i, s := range a.Slices {
+		if i > 0 && s.Start <= a.Slices[i-1].Start {
+			t.Fatalf("slice %d doesn't start after slice %d:\n%s", i, i-1, FormatAssignment(a))
+		}
+		if len(s.Replicas) != replication {
+			t.Fatalf("slice %d has replicas %v, want %d replicas", i, s.Replicas, replication)
+		}
+		if len(slices.Compact(slices.Clone(s.Replicas))) != len(s.Replicas) {
+			t.Fatalf("slice %d has duplicate replicas %v", i, s.Replicas)
+		}
+	}
+}
+
+func TestConsistentHashNoReplicas(t *testing.T) {
+	got := ConsistentHash([]string{}, 1, 0)
+	want := &protos.Assignment{}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("ConsistentHash: (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsistentHashOneReplica(t *testing.T) {
+	got := ConsistentHash([]string{"a"}, 3, 0)
+	want := &protos.Assignment{
+		Slices: []*protos.Assignment_Slice{
+			{Start: 0, Replicas: []string{"a"}},
+		},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("ConsistentHash: (-want +got):\n%s", diff)
+	}
+}
+
+func TestConsistentHashReplication(t *testing.T) {
+	for _, replication := range []int{1, 2, 3} {
+		t.Run(fmt.Sprint(replication), func(t *testing.T) {
+			checkValid(t, ConsistentHash(names(5), replication, 0), replication)
+		})
+	}
+}
+
+func TestConsistentHashBoundedLoad(t *testing.T) {
+	// Test plan: Compute the key space assigned to every replica and check
+	// that it is within the bound.
+	const n = 10
+	const loadFactor = 1.1
+	a := ConsistentHash(names(n), 2, loadFactor)
+	checkValid(t, a, 2)
+	load := map[string]float64{}
+	for i, s := range a.Slices {
+		end := float64(math.MaxUint64)
+		if i+1 < len(a.Slices) {
+			end = float64(a.Slices[i+1].Start)
+		}
+		for _, replica := range s.Replicas {
+			load[replica] += (end - float64(s.Start)) / float64(len(s.Replicas))
+		}
+	}
+	bound := loadFactor * float64(math.MaxUint64) / n
+	for replica, l := range load {
+		if l > bound*1.0001 {
+			t.Errorf("replica %s: load %.3f of average, want at most %.3f", replica, l*n/float64(math.MaxUint64), loadFactor)
+		}
+	}
+}
+
+func TestConsistentHashStability(t *testing.T) {
+	// Test plan: Add a replica to an assignment of 10 replicas, and check
+	// that few keys move. Ideally, 1/11 of the keys move to the new replica.
+	// With EqualSlices, most keys move.
+	before := ConsistentHash(names(10), 1, 0)
+	after := ConsistentHash(names(11), 1, 0)
+	checkValid(t, after, 1)
+	r := rand.New(rand.NewSource(0))
+	const keys = 10000
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := r.Uint64()
+		if !slices.Equal(replicasFor(before, key), replicasFor(after, key)) {
+			moved++
+		}
+	}
+	if got, max := float64(moved)/keys, 0.2; got > max {
+		t.Fatalf("%.3f of the keys moved, want at most %.3f", got, max)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
+	"golang.org/x/exp/slices"
+)
+
+// AttachCommand returns an "attach" subcommand that serves a Delve debugger
+// attached to a replica of a component of a running deployment, for a local
+// debugger to connect to. tool is the name of the command-line tool the
+// returned subcommand runs as (e.g., "weaver multi").
+func AttachCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	flags := flag.NewFlagSet("attach", flag.ContinueOnError)
+	pidFlag := flags.Int64("pid", 0, "PID of the replica to attach to; defaults to the replica with the lowest PID")
+	portFlag := flags.Int("port", 2345, "Local port the debugger is served on")
+	dlvFlag := flags.String("dlv", "dlv", "Path of the dlv binary on the machine the replica runs on")
+	return &dtool.Command{
+		Name:        "attach",
+		Description: "Attach a debugger to a replica of a component",
+		Help: fmt.Sprintf(`Usage:
+  %s attach [--pid=<pid>] [--port=<port>] <deployment> <component>
+
+Flags:
+  -h, --help	Print this help message.
+%s
+
+Description:
+  "%s attach" attaches a headless Delve debugger (https://github.com/go-delve/delve)
+  to a replica of a component of a running deployment, and serves it on
+  localhost:<port> until interrupted. Connect to it with "dlv connect", or
+  with an editor that supports remote Delve debugging. Interrupting the
+  command detaches the debugger, and the replica runs on.
+
+  If the replica runs on another machine, the debugger is started there
+  over ssh, and the port is forwarded to the same port on that machine. dlv
+  must be installed on the machine that runs the replica, and must be
+  allowed to trace its processes (e.g., with kernel.yama.ptrace_scope=0 on
+  Linux). Binaries built with -gcflags="all=-N -l" are easier to debug.
+
+  While the debugger stops the replica, e.g., at a breakpoint, the replica
+  fails its health checks, and may be taken out of service.
+
+  <deployment> is the id of the deployment, or a uniquely identifying prefix
+  of it. <component> is the full name (e.g., github.com/my/app/cart/Cart) or
+  short name (e.g., cart.Cart) of the component.
+
+Examples:
+  # Debug a replica of the cart.Cart component.
+  %s attach 2c80d811 cart.Cart
+  dlv connect localhost:2345
+
+  # Debug the replica of the cart.Cart component with PID 1234.
+  %s attach --pid=1234 2c80d811 cart.Cart`, tool, dtool.FlagsHelp(flags), tool, tool, tool),
+		Flags: flags,
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) != 2 || args[0] == "" {
+				return fmt.Errorf("usage: %s attach [--pid=<pid>] [--port=<port>] <deployment> <component>", tool)
+			}
+			if *portFlag <= 0 || *portFlag > 65535 {
+				return fmt.Errorf("invalid --port %d", *portFlag)
+			}
+
+			client, err := findDeployment(ctx, registry, args[0])
+			if err != nil {
+				return err
+			}
+			status, err := client.Status(ctx)
+			if err != nil {
+				return err
+			}
+			component, err := findComponent(status, args[1])
+			if err != nil {
+				return err
+			}
+			r, err := pickReplica(status, component, *pidFlag)
+			if err != nil {
+				return err
+			}
+
+			where := "on this machine"
+			if r.location != "" {
+				where = "on " + r.location
+			}
+			fmt.Fprintf(os.Stderr, "Attaching a debugger to replica %d of %s %s.\n", r.pid, logging.ShortenComponent(component), where)
+			if len(r.others) > 0 {
+				fmt.Fprintf(os.Stderr, "Other replicas: %v. Pick one with --pid.\n", r.others)
+			}
+			fmt.Fprintf(os.Stderr, "Connect with \"dlv connect localhost:%d\". Press Ctrl-C to detach.\n", *portFlag)
+
+			// Leave interrupts to the debugger, which detaches from the
+			// replica and exits when interrupted.
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt)
+			defer signal.Stop(signals)
+
+			cmd := attachCmd(r, *dlvFlag, *portFlag)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil && ctx.Err() == nil && len(signals) == 0 {
+				return fmt.Errorf("debugger: %w", err)
+			}
+			return nil
+		},
+	}
+}
+
+// replica is a replica of a component to attach a debugger to.
+type replica struct {
+	group    string
+	pid      int64
+	location string  // machine the replica runs on, or empty if local
+	others   []int64 // pids of the other replicas of the component
+}
+
+// pickReplica returns the replica of the provided component with the provided
+// pid, or the replica with the lowest pid if pid is zero.
+func pickReplica(status *Status, component string, pid int64) (replica, error) {
+	var c *Component
+	for _, x := range status.Components {
+		if x.Name == component {
+			c = x
+			break
+		}
+	}
+	if c == nil || len(c.Pids) == 0 {
+		return replica{}, fmt.Errorf("component %s has no replicas", logging.ShortenComponent(component))
+	}
+	pids := slices.Clone(c.Pids)
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	if pid == 0 {
+		pid = pids[0]
+	} else if !slices.Contains(pids, pid) {
+		return replica{}, fmt.Errorf("component %s has no replica with pid %d; replicas: %v", logging.ShortenComponent(component), pid, pids)
+	}
+
+	r := replica{group: c.Group, pid: pid}
+	for _, p := range pids {
+		if p != pid {
+			r.others = append(r.others, p)
+		}
+	}
+	for _, loc := range status.Locations {
+		if loc.Group == c.Group && loc.Pid == pid {
+			r.location = loc.Location
+		}
+	}
+	return r, nil
+}
+
+// attachCmd returns the command that attaches the provided dlv binary to the
+// replica, and serves the debugger on localhost:port.
+func attachCmd(r replica, dlv string, port int) *exec.Cmd {
+	args := []string{
+		"attach", fmt.Sprint(r.pid),
+		"--headless",
+		fmt.Sprintf("--listen=localhost:%d", port),
+		"--api-version=2",
+		"--accept-multiclient",
+		"--continue",
+	}
+	if r.location == "" {
+		return exec.Command(dlv, args...)
+	}
+	// Forward the port to the debugger on the machine of the replica. A
+	// terminal is allocated so that interrupts reach the remote debugger.
+	forward := fmt.Sprintf("%d:localhost:%d", port, port)
+	return exec.Command("ssh", append([]string{"-tt", "-L", forward, r.location, dlv}, args...)...)
+}
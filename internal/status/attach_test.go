@@ -0,0 +1,76 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPickReplica(t *testing.T) {
+	status := &Status{
+		Components: []*Component{
+			{Name: "github.com/a/cart/Cart", Group: "github.com/a/cart/Cart", Pids: []int64{30, 10, 20}},
+			{Name: "github.com/a/cart/Idle", Group: "github.com/a/cart/Idle"},
+		},
+		Locations: []*ReplicaLocation{
+			{Group: "github.com/a/cart/Cart", Pid: 20, Location: "10.0.0.2"},
+			{Group: "github.com/a/cart/Idle", Pid: 10, Location: "10.0.0.9"},
+		},
+	}
+	const cart = "github.com/a/cart/Cart"
+	for _, test := range []struct {
+		name      string
+		component string
+		pid       int64
+		want      replica // zero if an error is expected
+	}{
+		{"LowestPid", cart, 0, replica{group: cart, pid: 10, others: []int64{20, 30}}},
+		{"Remote", cart, 20, replica{group: cart, pid: 20, location: "10.0.0.2", others: []int64{10, 30}}},
+		{"NoSuchPid", cart, 40, replica{}},
+		{"NoReplicas", "github.com/a/cart/Idle", 0, replica{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := pickReplica(status, test.component, test.pid)
+			if test.want.pid == 0 {
+				if err == nil {
+					t.Fatalf("pickReplica: got %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("pickReplica: got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAttachCmd(t *testing.T) {
+	dlvArgs := []string{"attach", "10", "--headless", "--listen=localhost:2345", "--api-version=2", "--accept-multiclient", "--continue"}
+
+	local := attachCmd(replica{pid: 10}, "/bin/dlv", 2345)
+	if want := append([]string{"/bin/dlv"}, dlvArgs...); !reflect.DeepEqual(local.Args, want) {
+		t.Errorf("local: got %v, want %v", local.Args, want)
+	}
+
+	remote := attachCmd(replica{pid: 10, location: "10.0.0.2"}, "dlv", 2345)
+	want := append([]string{"ssh", "-tt", "-L", "2345:localhost:2345", "10.0.0.2", "dlv"}, dlvArgs...)
+	if !reflect.DeepEqual(remote.Args, want) {
+		t.Errorf("remote: got %v, want %v", remote.Args, want)
+	}
+}
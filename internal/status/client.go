@@ -75,3 +75,81 @@ func (c *Client) Profile(ctx context.Context, req *protos.GetProfileRequest) (*p
 	})
 	return reply, err
 }
+
+// Profiles implements the Server interface.
+func (c *Client) Profiles(ctx context.Context, req *ProfilesRequest) (*ProfilesReply, error) {
+	reply := &ProfilesReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: profilesEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
+
+// SetLogLevel implements the Server interface.
+func (c *Client) SetLogLevel(ctx context.Context, req *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	reply := &protos.SetLogLevelReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: logLevelEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
+
+// SplitTraffic implements the Server interface.
+func (c *Client) SplitTraffic(ctx context.Context, req *SplitTrafficRequest) (*SplitTrafficReply, error) {
+	reply := &SplitTrafficReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: splitEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
+
+// Rollout implements the Server interface.
+func (c *Client) Rollout(ctx context.Context, req *RolloutRequest) (*RolloutReply, error) {
+	reply := &RolloutReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: rolloutEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
+
+// Flags implements the Server interface.
+func (c *Client) Flags(ctx context.Context, req *FlagsRequest) (*FlagsReply, error) {
+	reply := &FlagsReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: flagsEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
+
+// ApplyConfig implements the Server interface.
+func (c *Client) ApplyConfig(ctx context.Context, req *ApplyConfigRequest) (*ApplyConfigReply, error) {
+	reply := &ApplyConfigReply{}
+	err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    "http://" + c.addr,
+		URLPath: configEndpoint,
+		Request: req,
+		Reply:   reply,
+	})
+	return reply, err
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConfigCommand returns a "config" subcommand that applies a new config to a
+// running deployment. tool is the name of the command-line tool the returned
+// subcommand runs as (e.g., "weaver multi"). check checks a config file, and
+// returns the problems it finds, before the config is applied.
+func ConfigCommand(tool string, registry func(context.Context) (*Registry, error), check func(context.Context, string) ([]string, error)) *dtool.Command {
+	return &dtool.Command{
+		Name:        "config",
+		Description: "Apply a new config to a deployment",
+		Help: fmt.Sprintf(`Usage:
+  %s config apply <deployment> <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+
+Description:
+  "%s config apply" applies the component config sections of a config
+  file to a running deployment, without redeploying it. Components read
+  their config with the Config method of weaver.WithConfig, and are
+  notified when it changes with OnConfigChange. The new config applies to
+  every replica of every component, including replicas started later.
+
+  The config is checked, like "weaver config check" does, before it is
+  applied. Only the component config sections can change; changing any
+  other setting, e.g., in the [serviceweaver] section, requires a new
+  deployment. If the config of a component doesn't match its config
+  struct, the config isn't applied.
+
+  <deployment> is the id of the deployment, or a uniquely identifying prefix
+  of it.
+
+Examples:
+  # Apply the component configs in weaver.toml.
+  %s config apply 2c80d811 weaver.toml`, tool, tool, tool),
+		Flags: flag.NewFlagSet("config", flag.ContinueOnError),
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) != 3 || args[0] != "apply" {
+				return fmt.Errorf("usage: %s config apply <deployment> <configfile>", tool)
+			}
+			deployment, file := args[1], args[2]
+
+			// Check and load the config.
+			problems, err := check(ctx, file)
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", file, problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%s: %d problem(s) found", file, len(problems))
+			}
+			contents, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("load config file %q: %w", file, err)
+			}
+			config, err := runtime.ParseConfig(file, string(contents), func(string, string) error { return nil })
+			if err != nil {
+				return fmt.Errorf("load config file %q: %w", file, err)
+			}
+
+			client, err := findDeployment(ctx, registry, deployment)
+			if err != nil {
+				return err
+			}
+			reply, err := client.ApplyConfig(ctx, &ApplyConfigRequest{Config: config})
+			if err != nil {
+				return err
+			}
+			if len(reply.Changed) == 0 {
+				fmt.Println("No component configs changed.")
+				return nil
+			}
+			for _, component := range reply.Changed {
+				fmt.Printf("Reconfigured %s\n", component)
+			}
+			return nil
+		},
+	}
+}
+
+// ChangedComponents returns the names of the components whose config
+// sections differ between the config of a running deployment and a new
+// config applied to it, sorted. It returns an error if the configs differ in
+// anything but their component config sections, which can't change without
+// a new deployment.
+func ChangedComponents(running, applied *protos.AppConfig) ([]string, error) {
+	// Compare the rest of the configs first.
+	strip := func(config *protos.AppConfig) *protos.AppConfig {
+		stripped := proto.Clone(config).(*protos.AppConfig)
+		for key := range stripped.Sections {
+			if runtime.IsComponentSection(key) {
+				delete(stripped.Sections, key)
+			}
+		}
+		return stripped
+	}
+	before, after := strip(running), strip(applied)
+	if !proto.Equal(before, after) {
+		for key, section := range after.Sections {
+			if before.Sections[key] != section {
+				return nil, fmt.Errorf("section [%s] can't change without a new deployment", key)
+			}
+		}
+		for key := range before.Sections {
+			if _, ok := after.Sections[key]; !ok {
+				return nil, fmt.Errorf("section [%s] can't change without a new deployment", key)
+			}
+		}
+		return nil, fmt.Errorf("only the component config sections can change without a new deployment")
+	}
+
+	var changed []string
+	for key, section := range applied.Sections {
+		if old, ok := running.Sections[key]; runtime.IsComponentSection(key) && (!ok || old != section) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range running.Sections {
+		if _, ok := applied.Sections[key]; runtime.IsComponentSection(key) && !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// ComponentSections returns the component config sections of the provided
+// config, by key.
+func ComponentSections(config *protos.AppConfig) map[string]string {
+	sections := map[string]string{}
+	for key, section := range config.Sections {
+		if runtime.IsComponentSection(key) {
+			sections[key] = section
+		}
+	}
+	return sections
+}
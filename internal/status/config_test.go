@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChangedComponents(t *testing.T) {
+	const running = `
+[serviceweaver]
+binary = "/bin/app"
+
+[multi]
+listeners.shop = {address = "localhost:8000"}
+
+["shop/Cache"]
+ttl = "1m"
+
+["shop/Ads"]
+weights = [1, 2]
+`
+	parse := func(contents string) *protos.AppConfig {
+		t.Helper()
+		config, err := runtime.ParseConfig("weaver.toml", contents, func(string, string) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config
+	}
+
+	for _, test := range []struct {
+		name    string
+		applied string
+		want    []string
+		wantErr string
+	}{
+		{"Same", running, nil, ""},
+		{"Changed", strings.Replace(running, `"1m"`, `"5m"`, 1), []string{"shop/Cache"}, ""},
+		{"Added", running + "\n[\"shop/Cart\"]\nmax_items = 10\n", []string{"shop/Cart"}, ""},
+		{"Removed", strings.Replace(running, "[\"shop/Ads\"]\nweights = [1, 2]\n", "", 1), []string{"shop/Ads"}, ""},
+		{"Deployer", strings.Replace(running, "8000", "9000", 1), nil, "section [multi] can't change"},
+		{"Binary", strings.Replace(running, "/bin/app", "/bin/app2", 1), nil, "can't change"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ChangedComponents(parse(running), parse(test.applied))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("changed (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
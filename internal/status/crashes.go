@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sync"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+)
+
+// maxCrashes is the number of crashes that a CrashStore keeps.
+const maxCrashes = 20
+
+// CrashStore keeps the reports of the latest maxCrashes weavelet crashes of a
+// deployment that were caused by a panic in a component method. A CrashStore
+// is safe for concurrent use.
+type CrashStore struct {
+	mu      sync.Mutex
+	crashes []*Crash // oldest first
+}
+
+// NewCrashStore returns a new, empty CrashStore.
+func NewCrashStore() *CrashStore {
+	return &CrashStore{}
+}
+
+// Add adds the crash report of the weavelet with the provided id and pid, a
+// replica of the provided colocation group, to the store, forgetting the
+// oldest crash if the store has too many.
+func (s *CrashStore) Add(group, weavelet string, pid int64, report *protos.CrashReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crashes = append(s.crashes, &Crash{
+		Group:      group,
+		Pid:        pid,
+		WeaveletId: weavelet,
+		Report:     report,
+	})
+	if len(s.crashes) > maxCrashes {
+		s.crashes = slices.Delete(s.crashes, 0, len(s.crashes)-maxCrashes)
+	}
+}
+
+// Crashes returns the crashes in the store, newest first.
+func (s *CrashStore) Crashes() []*Crash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	crashes := make([]*Crash, 0, len(s.crashes))
+	for i := len(s.crashes) - 1; i >= 0; i-- {
+		crashes = append(crashes, s.crashes[i])
+	}
+	return crashes
+}
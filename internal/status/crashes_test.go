@@ -0,0 +1,45 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+func TestCrashStore(t *testing.T) {
+	s := NewCrashStore()
+	if got := s.Crashes(); len(got) != 0 {
+		t.Fatalf("new store: got %d crashes, want 0", len(got))
+	}
+
+	for i := 0; i < maxCrashes+5; i++ {
+		s.Add("app/Cache", "w1", int64(i), &protos.CrashReport{Component: "app/Cache", Method: "Get"})
+	}
+	crashes := s.Crashes()
+	if len(crashes) != maxCrashes {
+		t.Fatalf("got %d crashes, want %d", len(crashes), maxCrashes)
+	}
+	// The newest crashes are kept, newest first.
+	for i, c := range crashes {
+		if want := int64(maxCrashes + 4 - i); c.Pid != want {
+			t.Errorf("crash %d: got pid %d, want %d", i, c.Pid, want)
+		}
+		if c.Group != "app/Cache" || c.WeaveletId != "w1" || c.Report.Method != "Get" {
+			t.Errorf("crash %d: got %v", i, c)
+		}
+	}
+}
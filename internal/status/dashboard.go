@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
@@ -26,15 +27,16 @@ import (
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
-	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logdb"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	"github.com/ServiceWeaver/weaver/runtime/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/perfetto"
-	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
 	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
 	"github.com/pkg/browser"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
@@ -47,11 +49,12 @@ var (
 
 	//go:embed templates/index.html
 	indexHTML     string
-	indexTemplate = template.Must(template.New("index").Parse(indexHTML))
+	indexTemplate = newTemplate("index", indexHTML, nil, serverLinks{})
 
 	//go:embed templates/deployment.html
 	deploymentHTML     string
-	deploymentTemplate = template.Must(template.New("deployment").Funcs(template.FuncMap{
+	deploymentTemplate = newTemplate("deployment", deploymentHTML, deploymentFuncs, serverLinks{})
+	deploymentFuncs    = template.FuncMap{
 		"shorten": logging.ShortenComponent,
 		"pidjoin": func(pids []int64) string {
 			s := make([]string, len(pids))
@@ -63,22 +66,144 @@ var (
 		"age": func(t *timestamppb.Timestamp) string {
 			return time.Since(t.AsTime()).Truncate(time.Second).String()
 		},
+		"agemicros": func(micros int64) string {
+			return time.Since(time.UnixMicro(micros)).Truncate(time.Second).String()
+		},
 		"dec": func(x int) int {
 			return x - 1
 		},
-		"traceurl": func(app, version string) string {
-			v := url.Values{}
-			v.Set("app", app)
-			v.Set("version", version)
-			tracerURL := url.QueryEscape("http://127.0.0.1:9001?" + v.Encode())
-			return "https://ui.perfetto.dev/#!/?url=" + tracerURL
+		"mib": func(bytes float64) string {
+			return fmt.Sprintf("%.1f", bytes/(1<<20))
+		},
+		"sparkline": sparkline,
+		"last":      lastValue,
+	}
+
+	//go:embed templates/logs.html
+	logsHTML     string
+	logsTemplate = newTemplate("logs", logsHTML, logsFuncs, serverLinks{})
+	logsFuncs    = template.FuncMap{
+		"shorten": logging.ShortenComponent,
+		"time": func(micros int64) string {
+			return time.UnixMicro(micros).Format("2006-01-02 15:04:05.000000")
+		},
+		"attrs": func(attrs []string) string {
+			var kvs []string
+			for i := 0; i+1 < len(attrs); i += 2 {
+				kvs = append(kvs, fmt.Sprintf("%s=%s", attrs[i], attrs[i+1]))
+			}
+			return strings.Join(kvs, " ")
+		},
+	}
+
+	//go:embed templates/traces.html
+	tracesHTML     string
+	tracesTemplate = newTemplate("traces", tracesHTML, tracesFuncs, serverLinks{})
+	tracesFuncs    = template.FuncMap{
+		"time": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04:05.000000")
+		},
+		"duration": formatDuration,
+		"bars":     latencyBars,
+	}
+
+	//go:embed templates/trace.html
+	traceHTML     string
+	traceTemplate = newTemplate("trace", traceHTML, traceFuncs, serverLinks{})
+	traceFuncs    = template.FuncMap{
+		"shorten": logging.ShortenComponent,
+		"time": func(t time.Time) string {
+			return t.Format("2006-01-02 15:04:05.000000")
+		},
+		"duration": formatDuration,
+	}
+
+	//go:embed templates/profiles.html
+	profilesHTML     string
+	profilesTemplate = newTemplate("profiles", profilesHTML, template.FuncMap{
+		"shorten": logging.ShortenComponent,
+		"shortenall": func(components []string) string {
+			short := make([]string, len(components))
+			for i, c := range components {
+				short[i] = logging.ShortenComponent(c)
+			}
+			return strings.Join(short, ", ")
+		},
+		"time": func(t *timestamppb.Timestamp) string {
+			return t.AsTime().Local().Format("2006-01-02 15:04:05")
 		},
-	}).Parse(deploymentHTML))
+		"micros": func(micros int64) string {
+			return time.Duration(micros * int64(time.Microsecond)).String()
+		},
+		"type": profileTypeName,
+	}, serverLinks{})
 
 	//go:embed assets/*
 	assets embed.FS
 )
 
+// A linker links the pages of a dashboard to each other, and to the assets
+// they use. The dashboard server serves pages at URLs with query parameters,
+// while an exported dashboard bundle stores them in files.
+type linker interface {
+	// link returns the URL of the page with the provided path and query
+	// parameters, given as alternating keys and values.
+	link(page string, params ...string) string
+
+	// asset returns the URL of the provided asset, e.g., "main.css".
+	asset(name string) string
+
+	// live returns whether pages are served by a running dashboard, and so
+	// can query the deployment, e.g., to refresh its topology.
+	live() bool
+}
+
+// serverLinks links the pages served by the dashboard server.
+type serverLinks struct{}
+
+var _ linker = serverLinks{}
+
+// link implements the linker interface.
+func (serverLinks) link(page string, params ...string) string {
+	v := url.Values{}
+	for i := 0; i+1 < len(params); i += 2 {
+		v.Set(params[i], params[i+1])
+	}
+	if page == "perfetto" {
+		// The Perfetto UI, loading the traces served by the trace database.
+		tracerURL := url.QueryEscape("http://127.0.0.1:9001?" + v.Encode())
+		return "https://ui.perfetto.dev/#!/?url=" + tracerURL
+	}
+	if len(v) == 0 {
+		return page
+	}
+	return page + "?" + v.Encode()
+}
+
+// asset implements the linker interface.
+func (serverLinks) asset(name string) string {
+	return "/assets/" + name
+}
+
+// live implements the linker interface.
+func (serverLinks) live() bool {
+	return true
+}
+
+// newTemplate parses the template of a dashboard page, with the provided
+// functions and the link, asset, and live functions of the provided linker.
+func newTemplate(name, text string, funcs template.FuncMap, links linker) *template.Template {
+	all := template.FuncMap{
+		"link":  links.link,
+		"asset": links.asset,
+		"live":  links.live,
+	}
+	for k, f := range funcs {
+		all[k] = f
+	}
+	return template.Must(template.New(name).Funcs(all).Parse(text))
+}
+
 // A Command is a labeled terminal command that a user can run. We show these
 // commands on the dashboard so that users can copy and run them.
 type Command struct {
@@ -91,20 +216,43 @@ type DashboardSpec struct {
 	Tool     string                                   // tool name (e.g., "weaver single")
 	Registry func(context.Context) (*Registry, error) // registry of deployments
 	Commands func(deploymentId string) []Command      // commands for a deployment
+	LogDB    func(context.Context) (*logdb.DB, error) // optional log database, enables the logs page
 }
 
 // DashboardCommand returns a "dashboard" subcommand that serves a dashboard
-// with information about the active applications.
+// with information about the active applications, and a "dashboard export"
+// subcommand that exports the dashboard of a deployment to static files.
 func DashboardCommand(spec *DashboardSpec) *dtool.Command {
 	const help = `Usage:
   {{.Tool}} dashboard [--host=<host>] [--port=<port>]
+  {{.Tool}} dashboard export [--out=<dir>] <deployment id>
 
 Flags:
   -h, --help	Print this help message.
-{{.Flags}}`
+{{.Flags}}
+
+Export flags:
+{{.ExportFlags}}
+
+Description:
+  "{{.Tool}} dashboard" serves a dashboard with the status, metrics, logs,
+  traces, and profiles of the active deployments. While it runs, the
+  dashboard samples the resources used by every deployment, to show them
+  over time.
+
+  "{{.Tool}} dashboard export" exports the dashboard of an active deployment
+  to a directory of static HTML files, with its status, metrics, logs, and
+  traces, which can be viewed offline, or shared, e.g., in a bug report.
+
+Examples:
+  # Serve the dashboard.
+  {{.Tool}} dashboard
+
+  # Export the dashboard of a deployment to ./export.
+  {{.Tool}} dashboard export --out=export <deployment id>`
 	var b strings.Builder
 	t := template.Must(template.New("dashboard-help").Parse(help))
-	content := struct{ Tool, Flags string }{spec.Tool, dtool.FlagsHelp(dashboardFlags)}
+	content := struct{ Tool, Flags, ExportFlags string }{spec.Tool, dtool.FlagsHelp(dashboardFlags), dtool.FlagsHelp(newExportFlags().FlagSet)}
 	if err := t.Execute(&b, content); err != nil {
 		panic(err)
 	}
@@ -114,16 +262,45 @@ Flags:
 		Description: "Inspect Service Weaver applications",
 		Help:        b.String(),
 		Flags:       dashboardFlags,
-		Fn: func(ctx context.Context, _ []string) error {
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) > 0 && args[0] == "export" {
+				return exportFn(ctx, spec, args[1:])
+			}
+			if len(args) > 0 {
+				return fmt.Errorf("unexpected arguments: %v", args)
+			}
 			r, err := spec.Registry(ctx)
 			if err != nil {
 				return err
 			}
-			dashboard := &dashboard{spec, r}
+			dashboard := &dashboard{spec: spec, registry: r, history: newHistory()}
+			if spec.LogDB != nil {
+				logDB, err := spec.LogDB(ctx)
+				if err != nil {
+					return err
+				}
+				defer logDB.Close()
+				dashboard.logDB = logDB
+			}
+			traceDB, err := perfetto.Open(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cannot open Perfetto database: %v\n", err)
+			} else {
+				go traceDB.Serve(ctx)
+				dashboard.traceDB = traceDB
+			}
+			go dashboard.recordHistory(ctx)
 			http.HandleFunc("/", dashboard.handleIndex)
 			http.HandleFunc("/favicon.ico", http.NotFound)
 			http.HandleFunc("/deployment", dashboard.handleDeployment)
 			http.HandleFunc("/metrics", dashboard.handleMetrics)
+			http.HandleFunc("/topology", dashboard.handleTopology)
+			http.HandleFunc("/logs", dashboard.handleLogs)
+			http.HandleFunc("/status.json", dashboard.handleStatusJSON)
+			http.HandleFunc("/traces", dashboard.handleTraces)
+			http.HandleFunc("/trace", dashboard.handleTrace)
+			http.HandleFunc("/profiles", dashboard.handleProfiles)
+			http.HandleFunc("/profiles/download", dashboard.handleProfileDownload)
 			http.Handle("/assets/", http.FileServer(http.FS(assets)))
 
 			lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", *dashboardHost, *dashboardPort))
@@ -132,13 +309,6 @@ Flags:
 			}
 			url := "http://" + lis.Addr().String()
 
-			traceDB, err := perfetto.Open(ctx)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "cannot open Perfetto database: %v\n", err)
-			} else {
-				go traceDB.Serve(ctx)
-			}
-
 			fmt.Fprintln(os.Stderr, "Dashboard available at:", url)
 			go browser.OpenURL(url) //nolint:errcheck // browser open is optional
 			return http.Serve(lis, nil)
@@ -150,24 +320,18 @@ Flags:
 type dashboard struct {
 	spec     *DashboardSpec // e.g., "weaver multi" or "weaver single"
 	registry *Registry      // registry of deployments
+	logDB    *logdb.DB      // log database, or nil
+	traceDB  *perfetto.DB   // trace database, or nil
+	history  *history       // resources used by deployments over time, or nil
 }
 
 // handleIndex handles requests to /
 func (d *dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
-	regs, err := d.registry.List(r.Context())
+	statuses, err := fetchStatuses(r.Context(), d.registry)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	var statuses []*Status
-	for _, reg := range regs {
-		status, err := NewClient(reg.Addr).Status(r.Context())
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		statuses = append(statuses, status)
-	}
 	content := struct {
 		Tool     string
 		Statuses []*Status
@@ -180,6 +344,39 @@ func (d *dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleStatusJSON handles requests to /status.json and
+// /status.json?id=<deployment id>. It serves the Report of every deployment,
+// or of the provided deployment.
+func (d *dashboard) handleStatusJSON(w http.ResponseWriter, r *http.Request) {
+	var statuses []*Status
+	if id := r.URL.Query().Get("id"); id != "" {
+		reg, err := d.registry.Get(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, err := NewClient(reg.Addr).Status(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		statuses = []*Status{status}
+	} else {
+		var err error
+		statuses, err = fetchStatuses(r.Context(), d.registry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report(statuses)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // handleDeployment handles requests to /deployment?id=<deployment id>
 func (d *dashboard) handleDeployment(w http.ResponseWriter, r *http.Request) {
 	// TODO(mwhittaker): Change to /<deployment id>?
@@ -194,13 +391,37 @@ func (d *dashboard) handleDeployment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	client := NewClient(reg.Addr)
-	status, err := client.Status(r.Context())
+	page, err := d.deploymentPage(r.Context(), reg)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := deploymentTemplate.Execute(w, page); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// deploymentPage is the content of the deployment page.
+type deploymentPage struct {
+	*Status
+	Tool            string
+	Commands        []Command
+	Logs            bool
+	Traces          bool
+	Resources       []*GroupResources
+	History         []*ResourceHistory
+	HistoryInterval time.Duration
+	Topology        *topology // snapshot of the topology, if exported
+}
+
+// deploymentPage returns the content of the deployment page of the provided
+// deployment.
+func (d *dashboard) deploymentPage(ctx context.Context, reg Registration) (*deploymentPage, error) {
+	client := NewClient(reg.Addr)
+	status, err := client.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Sort components and methods so that they appear in a deterministic order
 	// on the deployment page.
@@ -217,67 +438,66 @@ func (d *dashboard) handleDeployment(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Fetch metrics.
-	metrics, err := client.Metrics(r.Context())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Summarize the resources used by every co-location group. The rest of
+	// the page is still useful if the metrics are unavailable.
+	var resources []*GroupResources
+	if ms, err := client.Metrics(ctx); err == nil {
+		resources = groupResources(status, ms.Metrics)
 	}
-
-	// Display content.
-	content := struct {
-		*Status
-		Tool     string
-		Traffic  []edge
-		Commands []Command
-	}{
-		Status:   status,
-		Tool:     d.spec.Tool,
-		Traffic:  computeTraffic(status, metrics.Metrics),
-		Commands: d.spec.Commands(id),
-	}
-	if err := deploymentTemplate.Execute(w, content); err != nil {
-		fmt.Println(err)
+	var history []*ResourceHistory
+	if d.history != nil {
+		history = d.history.get(reg.DeploymentId)
 	}
+
+	return &deploymentPage{
+		Status:          status,
+		Tool:            d.spec.Tool,
+		Commands:        d.spec.Commands(reg.DeploymentId),
+		Logs:            d.logDB != nil,
+		Traces:          d.traceDB != nil,
+		Resources:       resources,
+		History:         history,
+		HistoryInterval: historyInterval,
+	}, nil
 }
 
-// An edge represents an edge in a traffic graph. If a component s calls n
-// methods on component t, then an edge is formed from s to t with weight v.
-type edge struct {
-	Source string // calling component
-	Target string // callee component
-	Value  int    // number of method calls
+// recordHistory samples the resources used by every active deployment every
+// historyInterval, until the provided context is canceled.
+func (d *dashboard) recordHistory(ctx context.Context) {
+	ticker := time.NewTicker(historyInterval)
+	defer ticker.Stop()
+	for {
+		d.sampleHistory(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-// computeTraffic calculates cross-component traffic.
-func computeTraffic(status *Status, metrics []*protos.MetricSnapshot) []edge {
-	// Aggregate traffic by component.
-	type pair struct {
-		caller    string
-		component string
+// sampleHistory records the resources currently used by every active
+// deployment, and forgets the history of the deployments that are gone.
+func (d *dashboard) sampleHistory(ctx context.Context) {
+	regs, err := d.registry.List(ctx)
+	if err != nil {
+		return
 	}
-	byPair := map[pair]int{}
-	for _, metric := range metrics {
-		if metric.Name != codegen.MethodCounts.Name() {
+	active := map[string]bool{}
+	for _, reg := range regs {
+		active[reg.DeploymentId] = true
+		client := NewClient(reg.Addr)
+		status, err := client.Status(ctx)
+		if err != nil {
 			continue
 		}
-		call := pair{
-			caller:    metric.Labels["caller"],
-			component: metric.Labels["component"],
+		ms, err := client.Metrics(ctx)
+		if err != nil {
+			continue
 		}
-		byPair[call] += int(metric.Value)
+		d.history.add(reg.DeploymentId, time.Now(), groupResources(status, ms.Metrics))
 	}
-
-	// Massage data into graph format.
-	var edges []edge
-	for call, value := range byPair {
-		edges = append(edges, edge{
-			Source: call.caller,
-			Target: call.component,
-			Value:  value,
-		})
-	}
-	return edges
+	d.history.retain(active)
 }
 
 // handleMetrics handles requests to /metrics?id=<deployment id>
@@ -294,12 +514,21 @@ func (d *dashboard) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	ms, err := NewClient(reg.Addr).Metrics(r.Context())
+	b, err := prometheusMetrics(r.Context(), reg)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Write(b) //nolint:errcheck // response write error
+}
+
+// prometheusMetrics returns the metrics of the provided deployment, in the
+// Prometheus text format.
+func prometheusMetrics(ctx context.Context, reg Registration) ([]byte, error) {
+	ms, err := NewClient(reg.Addr).Metrics(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	snapshots := make([]*metrics.MetricSnapshot, len(ms.Metrics))
 	for i, m := range ms.Metrics {
@@ -308,5 +537,348 @@ func (d *dashboard) handleMetrics(w http.ResponseWriter, r *http.Request) {
 
 	var b bytes.Buffer
 	imetrics.TranslateMetricsToPrometheusTextFormat(&b, snapshots, reg.Addr, prometheusEndpoint)
-	w.Write(b.Bytes()) //nolint:errcheck // response write error
+	return b.Bytes(), nil
+}
+
+// handleLogs handles requests to /logs?id=<deployment id>. The other query
+// parameters, all optional, filter the logs of the deployment: component,
+// level, since (a duration), text, and where (see logdb.Query).
+func (d *dashboard) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if d.logDB == nil {
+		http.Error(w, "logs not available", http.StatusNotFound)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := logsTemplate.Execute(w, d.logsPage(r.Context(), reg, r.URL.Query())); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// logsPage is the content of the logs page.
+type logsPage struct {
+	Tool         string
+	App          string
+	DeploymentId string
+	Params       url.Values
+	Entries      []*protos.LogEntry
+	Limit        int
+	Error        error
+}
+
+// logsPage returns the content of the logs page of the provided deployment,
+// showing the logs that match the provided query parameters.
+func (d *dashboard) logsPage(ctx context.Context, reg Registration, params url.Values) *logsPage {
+	const limit = 500
+	q := logdb.Query{
+		Version:   reg.DeploymentId,
+		Component: params.Get("component"),
+		MinLevel:  params.Get("level"),
+		Text:      params.Get("text"),
+		Where:     params.Get("where"),
+		Limit:     limit,
+	}
+	var entries []*protos.LogEntry
+	since, err := time.ParseDuration(params.Get("since"))
+	if params.Get("since") != "" && err != nil {
+		err = fmt.Errorf("invalid since: %w", err)
+	} else {
+		if since > 0 {
+			q.Since = time.Now().Add(-since)
+		}
+		entries, err = d.logDB.Query(ctx, q)
+	}
+
+	return &logsPage{
+		Tool:         d.spec.Tool,
+		App:          reg.App,
+		DeploymentId: reg.DeploymentId,
+		Params:       params,
+		Entries:      entries,
+		Limit:        limit,
+		Error:        err,
+	}
+}
+
+// handleTraces handles requests to /traces?id=<deployment id>. It shows the
+// latency histograms of the methods of the deployment, and the traces with the
+// slowest spans that match the other query parameters, all optional:
+// component, method, min (a duration), and errors (see perfetto.TraceQuery).
+func (d *dashboard) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if d.traceDB == nil {
+		http.Error(w, "traces not available", http.StatusNotFound)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := tracesTemplate.Execute(w, d.tracesPage(r.Context(), reg, r.URL.Query())); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// tracesPage is the content of the traces page.
+type tracesPage struct {
+	Tool         string
+	App          string
+	DeploymentId string
+	Params       url.Values
+	Latencies    []perfetto.MethodLatency
+	Traces       []perfetto.TraceSummary
+	Limit        int
+	Error        error
+}
+
+// tracesPage returns the content of the traces page of the provided
+// deployment, showing the traces that match the provided query parameters.
+func (d *dashboard) tracesPage(ctx context.Context, reg Registration, params url.Values) *tracesPage {
+	q := perfetto.TraceQuery{
+		App:       reg.App,
+		Version:   reg.DeploymentId,
+		Component: params.Get("component"),
+		Method:    params.Get("method"),
+		Errors:    params.Get("errors") != "",
+		Limit:     perfetto.DefaultTraceLimit,
+	}
+	var traces []perfetto.TraceSummary
+	var latencies []perfetto.MethodLatency
+	minDuration, err := time.ParseDuration(params.Get("min"))
+	if params.Get("min") != "" && err != nil {
+		err = fmt.Errorf("invalid min duration: %w", err)
+	} else {
+		q.MinDuration = minDuration
+		traces, err = d.traceDB.QueryTraces(ctx, q)
+	}
+	if err == nil {
+		latencies, err = d.traceDB.MethodLatencies(ctx, reg.App, reg.DeploymentId)
+	}
+
+	return &tracesPage{
+		Tool:         d.spec.Tool,
+		App:          reg.App,
+		DeploymentId: reg.DeploymentId,
+		Params:       params,
+		Latencies:    latencies,
+		Traces:       traces,
+		Limit:        q.Limit,
+		Error:        err,
+	}
+}
+
+// handleTrace handles requests to /trace?id=<deployment id>&trace=<trace id>.
+// It shows the spans of the trace as a flame graph, on a shared time axis.
+func (d *dashboard) handleTrace(w http.ResponseWriter, r *http.Request) {
+	if d.traceDB == nil {
+		http.Error(w, "traces not available", http.StatusNotFound)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+	traceID := r.URL.Query().Get("trace")
+	if traceID == "" {
+		http.Error(w, "no trace id provided", http.StatusBadRequest)
+		return
+	}
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := d.tracePage(r.Context(), reg, traceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if page == nil {
+		http.Error(w, fmt.Sprintf("trace %s not found", traceID), http.StatusNotFound)
+		return
+	}
+	if err := traceTemplate.Execute(w, page); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// tracePage is the content of the trace page.
+type tracePage struct {
+	Tool         string
+	App          string
+	DeploymentId string
+	TraceID      string
+	Rows         []spanRow
+}
+
+// tracePage returns the content of the page of the provided trace of the
+// provided deployment, or nil if the trace doesn't exist.
+func (d *dashboard) tracePage(ctx context.Context, reg Registration, traceID string) (*tracePage, error) {
+	spans, err := d.traceDB.TraceSpans(ctx, reg.App, reg.DeploymentId, traceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(spans) == 0 {
+		return nil, nil
+	}
+	return &tracePage{
+		Tool:         d.spec.Tool,
+		App:          reg.App,
+		DeploymentId: reg.DeploymentId,
+		TraceID:      traceID,
+		Rows:         layoutTrace(spans),
+	}, nil
+}
+
+// handleProfiles handles requests to /profiles?id=<deployment id>. The
+// optional component and type query parameters filter the recent profiles
+// collected by the continuous profilers of the deployment.
+func (d *dashboard) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client := NewClient(reg.Addr)
+	status, err := client.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var components []string
+	for _, c := range status.Components {
+		components = append(components, c.Name)
+	}
+	sort.Strings(components)
+
+	params := r.URL.Query()
+	var profiles []*ProfileInfo
+	req, err := profilesRequest(params)
+	if err == nil {
+		var reply *ProfilesReply
+		reply, err = client.Profiles(r.Context(), req)
+		if err == nil {
+			profiles = reply.Profiles
+		}
+	}
+
+	content := struct {
+		Tool         string
+		App          string
+		DeploymentId string
+		Params       url.Values
+		Components   []string
+		Profiles     []*ProfileInfo
+		Error        error
+	}{
+		Tool:         d.spec.Tool,
+		App:          reg.App,
+		DeploymentId: id,
+		Params:       params,
+		Components:   components,
+		Profiles:     profiles,
+		Error:        err,
+	}
+	if err := profilesTemplate.Execute(w, content); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// handleProfileDownload handles requests to
+// /profiles/download?id=<deployment id>. It serves, in a form that can be
+// passed to pprof, the profile with the provided profile id, or the merge of
+// the profiles of the provided type, and component, if any.
+func (d *dashboard) handleProfileDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := profilesRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Id == 0 && req.ProfileType == protos.ProfileType_Unspecified {
+		http.Error(w, "no profile or type provided", http.StatusBadRequest)
+		return
+	}
+	req.Merge = true
+	reply, err := NewClient(reg.Addr).Profiles(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(reply.Profiles) == 0 {
+		http.Error(w, "no matching profiles", http.StatusNotFound)
+		return
+	}
+	name := fmt.Sprintf("serviceweaver_%s_%s_profile.pb.gz", reg.App, profileTypeName(reply.Profiles[0].ProfileType))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(reply.Data) //nolint:errcheck // response write error
+}
+
+// profilesRequest returns the ProfilesRequest described by the component,
+// type, and profile query parameters.
+func profilesRequest(params url.Values) (*ProfilesRequest, error) {
+	req := &ProfilesRequest{Component: params.Get("component")}
+	switch t := params.Get("type"); t {
+	case "":
+	case "cpu":
+		req.ProfileType = protos.ProfileType_CPU
+	case "heap":
+		req.ProfileType = protos.ProfileType_Heap
+	case "mutex":
+		req.ProfileType = protos.ProfileType_Mutex
+	default:
+		return nil, fmt.Errorf("invalid profile type %q; want %q, %q, or %q", t, "cpu", "heap", "mutex")
+	}
+	if p := params.Get("profile"); p != "" {
+		id, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile id %q: %w", p, err)
+		}
+		req.Id = id
+	}
+	return req, nil
+}
+
+// profileTypeName returns the name of the provided profile type, as used in
+// query parameters and config files, e.g., "cpu".
+func profileTypeName(t protos.ProfileType) string {
+	switch t {
+	case protos.ProfileType_CPU:
+		return "cpu"
+	case protos.ProfileType_Heap:
+		return "heap"
+	case protos.ProfileType_Mutex:
+		return "mutex"
+	default:
+		return "unspecified"
+	}
 }
@@ -0,0 +1,247 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+)
+
+// exportFlagSet holds the flags of the "dashboard export" command.
+type exportFlagSet struct {
+	*flag.FlagSet
+	out string
+}
+
+// newExportFlags returns the flags of the "dashboard export" command.
+func newExportFlags() *exportFlagSet {
+	f := &exportFlagSet{FlagSet: flag.NewFlagSet("export", flag.ContinueOnError)}
+	f.StringVar(&f.out, "out", "", "Output directory, named after the app and deployment if empty")
+	f.Usage = func() {}
+	return f
+}
+
+// exportFn implements the "dashboard export" command.
+func exportFn(ctx context.Context, spec *DashboardSpec, args []string) error {
+	f := newExportFlags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("dashboard export: %w", err)
+	}
+	if f.NArg() != 1 {
+		return fmt.Errorf("dashboard export: want exactly one deployment id, got %d", f.NArg())
+	}
+
+	r, err := spec.Registry(ctx)
+	if err != nil {
+		return err
+	}
+	reg, err := r.Get(ctx, f.Arg(0))
+	if err != nil {
+		return err
+	}
+	d := &dashboard{spec: spec, registry: r}
+	if spec.LogDB != nil {
+		logDB, err := spec.LogDB(ctx)
+		if err != nil {
+			return err
+		}
+		defer logDB.Close()
+		d.logDB = logDB
+	}
+	traceDB, err := perfetto.Open(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot open Perfetto database: %v\n", err)
+	} else {
+		defer traceDB.Close()
+		d.traceDB = traceDB
+	}
+
+	dir := f.out
+	if dir == "" {
+		dir = fmt.Sprintf("%s-%s", reg.App, logging.Shorten(reg.DeploymentId))
+	}
+	if err := d.export(ctx, reg, dir); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Dashboard exported to:", filepath.Join(dir, "index.html"))
+	return nil
+}
+
+// export exports the dashboard of the provided deployment to a bundle of
+// static files in the provided directory:
+//
+//   - index.html: the deployment page, with a snapshot of the topology;
+//   - status.json: the status of the deployment;
+//   - metrics.txt: the metrics of the deployment, in the Prometheus format;
+//   - logs.html: the latest logs, if the dashboard has a log database;
+//   - traces.html: the method latencies and slowest traces, if the dashboard
+//     has a trace database, and a trace-<trace id>.html page per trace; and
+//   - assets/: the stylesheets and scripts of the pages.
+//
+// The pages link to each other with relative links, so the bundle can be
+// moved, archived, or shared as a whole.
+func (d *dashboard) export(ctx context.Context, reg Registration, dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0750); err != nil {
+		return err
+	}
+	write := func(name string, data []byte) error {
+		return os.WriteFile(filepath.Join(dir, name), data, 0640)
+	}
+	render := func(name string, t *template.Template, content any) error {
+		var b bytes.Buffer
+		if err := t.Execute(&b, content); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		return write(name, b.Bytes())
+	}
+
+	// Assets.
+	names, err := fs.Glob(assets, "assets/*")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := assets.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := write(name, data); err != nil {
+			return err
+		}
+	}
+
+	// Deployment page, status, and metrics.
+	page, err := d.deploymentPage(ctx, reg)
+	if err != nil {
+		return err
+	}
+	ms, err := NewClient(reg.Addr).Metrics(ctx)
+	if err != nil {
+		return err
+	}
+	topology := computeTopology(page.Status, ms.Metrics)
+	page.Topology = &topology
+	if err := render("index.html", newTemplate("deployment", deploymentHTML, deploymentFuncs, bundleLinks{}), page); err != nil {
+		return err
+	}
+	status, err := json.MarshalIndent(report([]*Status{page.Status}), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := write("status.json", status); err != nil {
+		return err
+	}
+	metrics, err := prometheusMetrics(ctx, reg)
+	if err != nil {
+		return err
+	}
+	if err := write("metrics.txt", metrics); err != nil {
+		return err
+	}
+
+	// Logs.
+	if d.logDB != nil {
+		logs := d.logsPage(ctx, reg, url.Values{})
+		if logs.Error != nil {
+			return logs.Error
+		}
+		if err := render("logs.html", newTemplate("logs", logsHTML, logsFuncs, bundleLinks{}), logs); err != nil {
+			return err
+		}
+	}
+
+	// Traces, including the traces of the crashes on the deployment page.
+	if d.traceDB != nil {
+		traces := d.tracesPage(ctx, reg, url.Values{})
+		if traces.Error != nil {
+			return traces.Error
+		}
+		if err := render("traces.html", newTemplate("traces", tracesHTML, tracesFuncs, bundleLinks{}), traces); err != nil {
+			return err
+		}
+		var ids []string
+		for _, trace := range traces.Traces {
+			ids = append(ids, trace.TraceID)
+		}
+		for _, crash := range page.Crashes {
+			if crash.Report.GetTraceId() != "" {
+				ids = append(ids, crash.Report.TraceId)
+			}
+		}
+		traceTemplate := newTemplate("trace", traceHTML, traceFuncs, bundleLinks{})
+		for _, id := range ids {
+			trace, err := d.tracePage(ctx, reg, id)
+			if err != nil {
+				return err
+			}
+			if trace == nil {
+				continue
+			}
+			if err := render(bundleLinks{}.link("trace", "trace", id), traceTemplate, trace); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bundleLinks links the pages of a dashboard bundle exported by export.
+type bundleLinks struct{}
+
+var _ linker = bundleLinks{}
+
+// link implements the linker interface.
+func (bundleLinks) link(page string, params ...string) string {
+	switch page {
+	case "/", "deployment":
+		return "index.html"
+	case "metrics":
+		return "metrics.txt"
+	case "status.json":
+		return page
+	case "logs", "traces":
+		// Exported pages can't be queried, so links with query parameters
+		// lead to the unfiltered page.
+		return page + ".html"
+	case "trace":
+		for i := 0; i+1 < len(params); i += 2 {
+			if params[i] == "trace" {
+				return "trace-" + url.PathEscape(params[i+1]) + ".html"
+			}
+		}
+	}
+	return "index.html"
+}
+
+// asset implements the linker interface.
+func (bundleLinks) asset(name string) string {
+	return "assets/" + name
+}
+
+// live implements the linker interface.
+func (bundleLinks) live() bool {
+	return false
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// metricsClient is a fake Server that returns the provided status and
+// metrics.
+type metricsClient struct {
+	fakeClient
+	metrics *Metrics
+}
+
+// Metrics implements the Server interface.
+func (m metricsClient) Metrics(context.Context) (*Metrics, error) {
+	return m.metrics, nil
+}
+
+func TestExport(t *testing.T) {
+	// Serve the status of a fake deployment.
+	status := &Status{
+		App:          "shop",
+		DeploymentId: "1234",
+		Components: []*Component{
+			{Name: "shop/Main", Group: "main", Pids: []int64{1}},
+			{Name: "shop/Cart", Group: "main", Pids: []int64{1}},
+		},
+		Config: &protos.AppConfig{},
+	}
+	metrics := &Metrics{Metrics: []*protos.MetricSnapshot{{
+		Name:   "serviceweaver_weavelet_goroutines",
+		Labels: map[string]string{"serviceweaver_group": "main", "serviceweaver_node": "n1"},
+		Value:  10,
+	}}}
+	mux := http.NewServeMux()
+	RegisterServer(mux, metricsClient{fakeClient{status: status}, metrics}, slog.Default())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := Registration{DeploymentId: "1234", App: "shop", Addr: strings.TrimPrefix(server.URL, "http://")}
+	if err := registry.Register(ctx, reg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Export the dashboard.
+	spec := &DashboardSpec{
+		Tool:     "weaver single",
+		Registry: func(context.Context) (*Registry, error) { return registry, nil },
+		Commands: func(string) []Command { return nil },
+	}
+	d := &dashboard{spec: spec, registry: registry}
+	dir := t.TempDir()
+	if err := d.export(ctx, reg, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	index := read("index.html")
+	for _, want := range []string{
+		`href="assets/main.css"`,
+		`href="metrics.txt"`,
+		`href="status.json"`,
+		`"name":"shop/Cart"`, // the topology snapshot
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html: %q not found", want)
+		}
+	}
+	for _, unwanted := range []string{"metrics?id=", "status.json?id=", "/assets/", "Profiles"} {
+		if strings.Contains(index, unwanted) {
+			t.Errorf("index.html: unexpected %q", unwanted)
+		}
+	}
+	if got := read("metrics.txt"); !strings.Contains(got, "serviceweaver_weavelet_goroutines") {
+		t.Errorf("metrics.txt: goroutines not found in\n%s", got)
+	}
+	if got := read("status.json"); !strings.Contains(got, `"1234"`) {
+		t.Errorf("status.json: deployment not found in\n%s", got)
+	}
+	read("assets/main.css")
+}
+
+func TestLinks(t *testing.T) {
+	for _, test := range []struct {
+		links  linker
+		page   string
+		params []string
+		want   string
+	}{
+		{serverLinks{}, "/", nil, "/"},
+		{serverLinks{}, "traces", []string{"id", "1234", "method", "Get"}, "traces?id=1234&method=Get"},
+		{serverLinks{}, "trace", []string{"id", "1234", "trace", "ab"}, "trace?id=1234&trace=ab"},
+		{bundleLinks{}, "/", nil, "index.html"},
+		{bundleLinks{}, "deployment", []string{"id", "1234"}, "index.html"},
+		{bundleLinks{}, "traces", []string{"id", "1234", "method", "Get"}, "traces.html"},
+		{bundleLinks{}, "trace", []string{"id", "1234", "trace", "ab"}, "trace-ab.html"},
+		{bundleLinks{}, "metrics", []string{"id", "1234"}, "metrics.txt"},
+	} {
+		if got := test.links.link(test.page, test.params...); got != test.want {
+			t.Errorf("%T.link(%q, %q): got %q, want %q", test.links, test.page, test.params, got, test.want)
+		}
+	}
+}
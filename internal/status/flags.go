@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+// FlagsCommand returns a "flags" subcommand that lists and changes the
+// feature flags of a running deployment. tool is the name of the
+// command-line tool the returned subcommand runs as (e.g., "weaver multi").
+func FlagsCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	return &dtool.Command{
+		Name:        "flags",
+		Description: "List and change the feature flags of a deployment",
+		Help: fmt.Sprintf(`Usage:
+  %s flags <deployment>
+  %s flags set <deployment> <name>=<value>...
+  %s flags unset <deployment> <name>...
+
+Flags:
+  -h, --help	Print this help message.
+
+Description:
+  "%s flags" lists the feature flags of a running deployment. "%s flags set"
+  and "%s flags unset" change them, without redeploying the deployment.
+  Components read the flags with weaver.GetFlags, and are notified when they
+  change. Flags apply to every replica of every component, including
+  replicas started later.
+
+  <deployment> is the id of the deployment, or a uniquely identifying prefix
+  of it.
+
+Examples:
+  # List the flags of a deployment.
+  %s flags 2c80d811
+
+  # Enable the new recommendation algorithm, and raise a limit.
+  %s flags set 2c80d811 recommendations.v2=true cart.max_items=200
+
+  # Go back to the default recommendation algorithm.
+  %s flags unset 2c80d811 recommendations.v2`, tool, tool, tool, tool, tool, tool, tool, tool, tool),
+		Flags: flag.NewFlagSet("flags", flag.ContinueOnError),
+		Fn: func(ctx context.Context, args []string) error {
+			usage := fmt.Errorf("usage: %s flags [set|unset] <deployment> ...", tool)
+			req := &FlagsRequest{}
+			switch {
+			case len(args) == 1 && args[0] != "":
+			case len(args) >= 3 && args[0] == "set":
+				req.Set = map[string]string{}
+				for _, arg := range args[2:] {
+					name, value, ok := strings.Cut(arg, "=")
+					if !ok {
+						return fmt.Errorf("invalid flag %q; want <name>=<value>", arg)
+					}
+					req.Set[name] = value
+				}
+				args = args[1:]
+			case len(args) >= 3 && args[0] == "unset":
+				req.Unset = args[2:]
+				args = args[1:]
+			default:
+				return usage
+			}
+			if err := validateFlags(req); err != nil {
+				return err
+			}
+
+			client, err := findDeployment(ctx, registry, args[0])
+			if err != nil {
+				return err
+			}
+			reply, err := client.Flags(ctx, req)
+			if err != nil {
+				return err
+			}
+			if len(reply.Flags) == 0 {
+				fmt.Println("No flags are set.")
+				return nil
+			}
+			names := make([]string, 0, len(reply.Flags))
+			for name := range reply.Flags {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s=%s\n", name, reply.Flags[name])
+			}
+			return nil
+		},
+	}
+}
+
+// UpdateFlags applies a FlagsRequest to the provided flags, by name.
+func UpdateFlags(flags map[string]string, req *FlagsRequest) error {
+	if err := validateFlags(req); err != nil {
+		return err
+	}
+	for name, value := range req.Set {
+		flags[name] = value
+	}
+	for _, name := range req.Unset {
+		delete(flags, name)
+	}
+	return nil
+}
+
+// validateFlags returns an error if the provided request sets or unsets a flag
+// with an invalid name.
+func validateFlags(req *FlagsRequest) error {
+	check := func(name string) error {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("invalid flag name %q", name)
+		}
+		return nil
+	}
+	for name := range req.Set {
+		if err := check(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range req.Unset {
+		if err := check(name); err != nil {
+			return err
+		}
+		if _, ok := req.Set[name]; ok {
+			return fmt.Errorf("flag %q both set and unset", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// maxGraphWindow is the longest window that can be requested from the
+// dependency graph endpoint.
+const maxGraphWindow = 5 * time.Minute
+
+// A CallEdge is an edge in a component dependency graph. If component Caller
+// has called method Method of component Component, an edge is formed from
+// Caller to Component.Method.
+type CallEdge struct {
+	Caller    string  `json:"caller"`    // calling component
+	Component string  `json:"component"` // callee component
+	Method    string  `json:"method"`    // callee method
+	Calls     float64 `json:"calls"`     // number of calls
+	Errors    float64 `json:"errors"`    // number of calls that returned an error
+}
+
+// callKey identifies a CallEdge.
+type callKey struct {
+	caller    string
+	component string
+	method    string
+}
+
+// computeCallGraph computes the component dependency graph from the method
+// call metrics in the provided metric snapshots. Metrics from different
+// replicas of the same component are aggregated.
+//
+// The size of the graph is bounded by the number of component methods, since
+// the method call metrics are.
+func computeCallGraph(metrics []*protos.MetricSnapshot) map[callKey]*CallEdge {
+	graph := map[callKey]*CallEdge{}
+	for _, metric := range metrics {
+		name := metric.Name
+		if name != codegen.MethodCounts.Name() && name != codegen.MethodErrors.Name() {
+			continue
+		}
+		key := callKey{
+			caller:    metric.Labels["caller"],
+			component: metric.Labels["component"],
+			method:    metric.Labels["method"],
+		}
+		e, ok := graph[key]
+		if !ok {
+			e = &CallEdge{Caller: key.caller, Component: key.component, Method: key.method}
+			graph[key] = e
+		}
+		if name == codegen.MethodCounts.Name() {
+			e.Calls += metric.Value
+		} else {
+			e.Errors += metric.Value
+		}
+	}
+	return graph
+}
+
+// callGraphDelta returns the edges in after, with the calls already present
+// in before subtracted. Edges without calls are omitted. The returned edges
+// are sorted by caller, component, and method.
+func callGraphDelta(before, after map[callKey]*CallEdge) []CallEdge {
+	var edges []CallEdge
+	for key, a := range after {
+		e := *a
+		if b, ok := before[key]; ok {
+			e.Calls -= b.Calls
+			e.Errors -= b.Errors
+		}
+		if e.Calls <= 0 {
+			// Either no calls or the counters were reset (e.g., a replica
+			// restarted). Either way, there's nothing meaningful to report.
+			continue
+		}
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		x, y := edges[i], edges[j]
+		if x.Caller != y.Caller {
+			return x.Caller < y.Caller
+		}
+		if x.Component != y.Component {
+			return x.Component < y.Component
+		}
+		return x.Method < y.Method
+	})
+	return edges
+}
+
+// writeDOT writes the provided edges to w in Graphviz DOT format.
+func writeDOT(w io.Writer, edges []CallEdge) error {
+	if _, err := fmt.Fprintln(w, "digraph {"); err != nil {
+		return err
+	}
+	for _, e := range edges {
+		label := fmt.Sprintf("%s (%v calls, %v errors)", e.Method, e.Calls, e.Errors)
+		if _, err := fmt.Fprintf(w, "  %q -> %q [label=%q];\n", logging.ShortenComponent(e.Caller), logging.ShortenComponent(e.Component), label); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// graphHandler returns a handler that serves the component dependency graph
+// of the deployment served by server. The handler accepts the following
+// query parameters:
+//
+//   - format: "json" (the default) or "dot".
+//   - window: if present, only calls made during a window of the provided
+//     duration (e.g., "30s") are reported. The request blocks for the
+//     duration of the window. Otherwise, all calls since the start of the
+//     deployment are reported.
+func graphHandler(server Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		status, err := server.Status(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !status.GetConfig().GetDependencyGraph() {
+			http.Error(w, "dependency graph disabled; set dependency_graph = true in the [serviceweaver] config section to enable it", http.StatusNotFound)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "dot" {
+			http.Error(w, fmt.Sprintf("unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+		var window time.Duration
+		if s := r.URL.Query().Get("window"); s != "" {
+			window, err = time.ParseDuration(s)
+			if err != nil || window <= 0 || window > maxGraphWindow {
+				http.Error(w, fmt.Sprintf("invalid window %q: want a positive duration of at most %v", s, maxGraphWindow), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var before map[callKey]*CallEdge
+		if window > 0 {
+			ms, err := server.Metrics(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			before = computeCallGraph(ms.Metrics)
+			select {
+			case <-time.After(window):
+			case <-ctx.Done():
+				return
+			}
+		}
+		ms, err := server.Metrics(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		edges := callGraphDelta(before, computeCallGraph(ms.Metrics))
+
+		if format == "dot" {
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			writeDOT(w, edges) //nolint:errcheck // response write error
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if edges == nil {
+			edges = []CallEdge{}
+		}
+		json.NewEncoder(w).Encode(edges) //nolint:errcheck // response write error
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func methodMetric(name, caller, component, method string, value float64) *protos.MetricSnapshot {
+	return &protos.MetricSnapshot{
+		Name: name,
+		Labels: map[string]string{
+			"caller":    caller,
+			"component": component,
+			"method":    method,
+		},
+		Value: value,
+	}
+}
+
+func TestCallGraph(t *testing.T) {
+	counts := codegen.MethodCounts.Name()
+	errors := codegen.MethodErrors.Name()
+	before := computeCallGraph([]*protos.MetricSnapshot{
+		methodMetric(counts, "main", "a/A", "Foo", 10),
+		methodMetric(counts, "main", "b/B", "Bar", 5),
+	})
+	after := computeCallGraph([]*protos.MetricSnapshot{
+		// Two replicas of main calling A.Foo.
+		methodMetric(counts, "main", "a/A", "Foo", 10),
+		methodMetric(counts, "main", "a/A", "Foo", 7),
+		methodMetric(errors, "main", "a/A", "Foo", 2),
+		// No new calls to B.Bar.
+		methodMetric(counts, "main", "b/B", "Bar", 5),
+		// A new edge.
+		methodMetric(counts, "a/A", "b/B", "Bar", 3),
+		// Unrelated metric.
+		methodMetric("other", "main", "a/A", "Foo", 100),
+	})
+
+	got := callGraphDelta(before, after)
+	want := []CallEdge{
+		{Caller: "a/A", Component: "b/B", Method: "Bar", Calls: 3},
+		{Caller: "main", Component: "a/A", Method: "Foo", Calls: 7, Errors: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("callGraphDelta (-want +got):\n%s", diff)
+	}
+
+	var b strings.Builder
+	if err := writeDOT(&b, got); err != nil {
+		t.Fatal(err)
+	}
+	for _, edge := range []string{`"a.A" -> "b.B"`, `"main" -> "a.A"`} {
+		if !strings.Contains(b.String(), edge) {
+			t.Errorf("DOT graph missing edge %s:\n%s", edge, b.String())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// historyInterval is how often the dashboard samples the resources used
+	// by every deployment.
+	historyInterval = 10 * time.Second
+
+	// maxHistory is the number of samples that a history keeps per
+	// deployment, an hour's worth.
+	maxHistory = 360
+)
+
+// ResourceHistory holds the resources used by the replicas of a co-location
+// group over time, one value per sample, oldest first.
+type ResourceHistory struct {
+	Group        string    // co-location group
+	Components   []string  // components in the group, sorted
+	CPU          []float64 // CPU cores used since the previous sample
+	RSSBytes     []float64 // total resident set size, in bytes
+	HeapBytes    []float64 // total bytes of allocated heap objects
+	Goroutines   []float64 // total number of goroutines
+	ServingCalls []float64 // component method calls being served
+}
+
+// resourceSample holds the resources used by the co-location groups of a
+// deployment at some point in time.
+type resourceSample struct {
+	time   time.Time
+	groups []*GroupResources
+}
+
+// history records the resources used by the co-location groups of a set of
+// deployments over time, keeping the latest maxHistory samples of every
+// deployment. A history is safe for concurrent use.
+type history struct {
+	mu      sync.Mutex
+	samples map[string][]resourceSample // samples, by deployment id
+}
+
+// newHistory returns a new, empty history.
+func newHistory() *history {
+	return &history{samples: map[string][]resourceSample{}}
+}
+
+// add records the resources used by the co-location groups of the provided
+// deployment at the provided time.
+func (h *history) add(deploymentId string, now time.Time, groups []*GroupResources) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := append(h.samples[deploymentId], resourceSample{now, groups})
+	if len(samples) > maxHistory {
+		samples = slices.Delete(samples, 0, len(samples)-maxHistory)
+	}
+	h.samples[deploymentId] = samples
+}
+
+// retain forgets the samples of every deployment not in the provided set.
+func (h *history) retain(deploymentIds map[string]bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id := range h.samples {
+		if !deploymentIds[id] {
+			delete(h.samples, id)
+		}
+	}
+}
+
+// get returns the resources used by the co-location groups of the provided
+// deployment over time, sorted by group. Groups with fewer than two samples
+// are omitted.
+func (h *history) get(deploymentId string) []*ResourceHistory {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	groups := map[string]*ResourceHistory{}
+	type last struct {
+		time       time.Time
+		cpuSeconds float64
+	}
+	previous := map[string]last{}
+	for _, sample := range h.samples[deploymentId] {
+		for _, g := range sample.groups {
+			r, ok := groups[g.Group]
+			if !ok {
+				r = &ResourceHistory{Group: g.Group}
+				groups[g.Group] = r
+			}
+			r.Components = g.Components
+
+			// CPU time is cumulative, so the cores used are the CPU time
+			// used since the previous sample, per second. CPU time that went
+			// down, e.g., because a replica restarted, counts as none.
+			cpu := 0.0
+			if p, ok := previous[g.Group]; ok {
+				if secs := sample.time.Sub(p.time).Seconds(); secs > 0 {
+					cpu = math.Max(0, g.CPUSeconds-p.cpuSeconds) / secs
+				}
+			}
+			previous[g.Group] = last{sample.time, g.CPUSeconds}
+			r.CPU = append(r.CPU, cpu)
+			r.RSSBytes = append(r.RSSBytes, g.RSSBytes)
+			r.HeapBytes = append(r.HeapBytes, g.HeapBytes)
+			r.Goroutines = append(r.Goroutines, g.Goroutines)
+			r.ServingCalls = append(r.ServingCalls, g.ServingCalls)
+		}
+	}
+
+	result := make([]*ResourceHistory, 0, len(groups))
+	for _, r := range groups {
+		if len(r.CPU) < 2 {
+			continue
+		}
+		// The first CPU value has no previous sample to compare against.
+		r.CPU = r.CPU[1:]
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+	return result
+}
+
+// sparkline returns an inline SVG line chart of the provided values, scaled
+// to fit between zero and the largest value.
+func sparkline(values []float64) template.HTML {
+	const width, height = 120, 24
+	if len(values) == 0 {
+		return ""
+	}
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	var points strings.Builder
+	for i, v := range values {
+		x := 0.0
+		if len(values) > 1 {
+			x = float64(i) * width / float64(len(values)-1)
+		}
+		y := float64(height)
+		if max > 0 {
+			y = height - v*(height-1)/max
+		}
+		fmt.Fprintf(&points, "%.1f,%.1f ", x, y)
+	}
+	return template.HTML(fmt.Sprintf(
+		`<svg class="sparkline" width="%d" height="%d" viewBox="0 0 %d %d"><polyline fill="none" stroke="#4e79a7" stroke-width="1.5" points="%s"/></svg>`,
+		width, height, width, height, strings.TrimSpace(points.String())))
+}
+
+// lastValue returns the last of the provided values, or zero.
+func lastValue(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistory(t *testing.T) {
+	h := newHistory()
+	start := time.Now()
+	sample := func(cpu, rss float64) []*GroupResources {
+		return []*GroupResources{
+			{Group: "main", Components: []string{"main"}, CPUSeconds: cpu, RSSBytes: rss},
+		}
+	}
+	h.add("v1", start, sample(10, 100))
+	h.add("v1", start.Add(10*time.Second), sample(15, 200))
+	// The process restarted, so its CPU time went down.
+	h.add("v1", start.Add(20*time.Second), sample(2, 300))
+	h.add("v2", start, sample(1, 1))
+
+	want := []*ResourceHistory{{
+		Group:        "main",
+		Components:   []string{"main"},
+		CPU:          []float64{0.5, 0},
+		RSSBytes:     []float64{100, 200, 300},
+		HeapBytes:    []float64{0, 0, 0},
+		Goroutines:   []float64{0, 0, 0},
+		ServingCalls: []float64{0, 0, 0},
+	}}
+	if diff := cmp.Diff(want, h.get("v1")); diff != "" {
+		t.Errorf("get (-want +got):\n%s", diff)
+	}
+
+	// v2 has a single sample, too few to show.
+	if got := h.get("v2"); len(got) != 0 {
+		t.Errorf("get: got %v, want nothing", got)
+	}
+
+	// Forget v2.
+	h.retain(map[string]bool{"v1": true})
+	if _, ok := h.samples["v2"]; ok {
+		t.Error("retain: v2 not forgotten")
+	}
+}
+
+func TestHistoryForgets(t *testing.T) {
+	h := newHistory()
+	start := time.Now()
+	for i := 0; i < maxHistory+10; i++ {
+		h.add("v1", start.Add(time.Duration(i)*time.Second), []*GroupResources{{Group: "main", Goroutines: float64(i)}})
+	}
+	got := h.get("v1")
+	if len(got) != 1 {
+		t.Fatalf("get: got %d groups, want 1", len(got))
+	}
+	if n := len(got[0].Goroutines); n != maxHistory {
+		t.Errorf("get: got %d samples, want %d", n, maxHistory)
+	}
+	if first := got[0].Goroutines[0]; first != 10 {
+		t.Errorf("get: got oldest sample %v, want 10", first)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	got := string(sparkline([]float64{0, 1, 2}))
+	if !strings.Contains(got, `points="0.0,24.0 60.0,12.5 120.0,1.0"`) {
+		t.Errorf("sparkline: got %s", got)
+	}
+	if got := sparkline(nil); got != "" {
+		t.Errorf("sparkline(nil): got %s, want empty", got)
+	}
+}
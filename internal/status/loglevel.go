@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+// LogLevelCommand returns a "loglevel" subcommand that changes the minimum
+// level of the log entries produced by a component of a running deployment.
+// tool is the name of the command-line tool the returned subcommand runs as
+// (e.g., "weaver multi").
+func LogLevelCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	return &dtool.Command{
+		Name:        "loglevel",
+		Description: "Change the log level of a component",
+		Help: fmt.Sprintf(`Usage:
+  %s loglevel <deployment> <component> <level>
+
+Flags:
+  -h, --help	Print this help message.
+
+Description:
+  "%s loglevel" changes the minimum level of the log entries produced by a
+  component of a running deployment, without redeploying it. Log entries
+  below the level are dropped. <level> is one of "debug", "info", "warn", or
+  "error". By default, every log entry is kept.
+
+  <deployment> is the id of the deployment, or a uniquely identifying prefix
+  of it. <component> is the full name (e.g., github.com/my/app/cart/Cart) or
+  short name (e.g., cart.Cart) of the component. The level applies to every
+  replica of the component, including replicas started later.
+
+Examples:
+  # Show the debug logs of the cart.Cart component.
+  %s loglevel 2c80d811 cart.Cart debug
+
+  # Only show the warnings and errors of the main component.
+  %s loglevel 2c80d811 main warn`, tool, tool, tool, tool),
+		Flags: flag.NewFlagSet("loglevel", flag.ContinueOnError),
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) != 3 || args[0] == "" {
+				return fmt.Errorf("usage: %s loglevel <deployment> <component> <level>", tool)
+			}
+			if _, err := logging.ParseLevel(args[2]); err != nil {
+				return err
+			}
+
+			client, err := findDeployment(ctx, registry, args[0])
+			if err != nil {
+				return err
+			}
+			status, err := client.Status(ctx)
+			if err != nil {
+				return err
+			}
+			component, err := findComponent(status, args[1])
+			if err != nil {
+				return err
+			}
+			req := &protos.SetLogLevelRequest{Component: component, Level: args[2]}
+			if _, err := client.SetLogLevel(ctx, req); err != nil {
+				return err
+			}
+			fmt.Printf("Set the log level of %s to %s.\n", logging.ShortenComponent(component), args[2])
+			return nil
+		},
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/runtime/logging"
 	protos "github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
 	pprof "github.com/google/pprof/profile"
@@ -33,9 +34,10 @@ import (
 // have `weaver profile` wrap `pprof` to make this a single step instead of two.
 
 var (
-	profileFlags    = flag.NewFlagSet("profile", flag.ContinueOnError)
-	profileDuration = profileFlags.Duration("duration", 30*time.Second, "Duration of cpu profiles")
-	profileType     = profileFlags.String("type", "cpu", `Profile type; "cpu" or "heap"`)
+	profileFlags     = flag.NewFlagSet("profile", flag.ContinueOnError)
+	profileDuration  = profileFlags.Duration("duration", 30*time.Second, "Duration of cpu and mutex profiles")
+	profileType      = profileFlags.String("type", "cpu", `Profile type; "cpu", "heap", or "mutex"`)
+	profileComponent = profileFlags.String("component", "", "If not empty, only profile the processes hosting this component")
 )
 
 // ProfileCommand returns a "profile" subcommand that gathers pprof profiles.
@@ -54,6 +56,11 @@ Description:
   dashboard'. The id is also printed when the deployment starts. '{{.Tool}}
   profile' writes the profile to a file that can be passed to pprof.
 
+  By default, the whole application is profiled. With --component, only the
+  processes hosting the provided component are profiled, and their profiles
+  are merged into a single profile. The component can be named by its full
+  name (e.g., github.com/my/app/cart/Cart) or its short name (e.g., cart.Cart).
+
 Deployment Ids:
   For convenience, '{{.Tool}} profile' accepts a uniquely identifying prefix
   of a deployment id. For example, consider deployments with the following ids:
@@ -87,36 +94,15 @@ Examples:
 			if prefix == "" {
 				return fmt.Errorf("usage: %s profile [options] <deployment>", toolName)
 			}
-			if *profileType != "cpu" && *profileType != "heap" {
-				return fmt.Errorf("invalid profile type %q; want %q or %q", *profileType, "cpu", "heap")
+			if *profileType != "cpu" && *profileType != "heap" && *profileType != "mutex" {
+				return fmt.Errorf("invalid profile type %q; want %q, %q, or %q", *profileType, "cpu", "heap", "mutex")
 			}
 
-			// Get the corresponding deployment id.
-			registry, err := registry(ctx)
-			if err != nil {
-				return fmt.Errorf("create registry: %w", err)
-			}
-			regs, err := registry.List(ctx)
+			// Get the corresponding deployment.
+			client, err := findDeployment(ctx, registry, prefix)
 			if err != nil {
-				return fmt.Errorf("get registrations: %w", err)
-			}
-			var candidates []Registration
-			for _, reg := range regs {
-				if strings.HasPrefix(reg.DeploymentId, prefix) {
-					candidates = append(candidates, reg)
-				}
-			}
-			if len(candidates) == 0 {
-				return fmt.Errorf("no deployment with prefix %q found", prefix)
-			}
-			if len(candidates) > 1 {
-				fmt.Fprintf(os.Stderr, "The deployment id prefix %q is ambiguous. Expand the prefix to identify one of the following deployments:\n", prefix)
-				for _, candidate := range candidates {
-					fmt.Fprintf(os.Stderr, "  - %s\n", candidate.DeploymentId)
-				}
-				return fmt.Errorf("multiple deployments with prefix %q found", prefix)
+				return err
 			}
-			client := NewClient(candidates[0].Addr) // candidates[0] is the only candidate
 
 			// Get the deployment's status.
 			status, err := client.Status(ctx)
@@ -126,12 +112,22 @@ Examples:
 
 			// Form the profile request.
 			req := &protos.GetProfileRequest{}
-			if *profileType == "heap" {
+			switch *profileType {
+			case "heap":
 				req.ProfileType = protos.ProfileType_Heap
-			} else {
+			case "mutex":
+				req.ProfileType = protos.ProfileType_Mutex
+				req.CpuDurationNs = profileDuration.Nanoseconds()
+			default:
 				req.ProfileType = protos.ProfileType_CPU
 				req.CpuDurationNs = profileDuration.Nanoseconds()
 			}
+			if *profileComponent != "" {
+				req.Component, err = findComponent(status, *profileComponent)
+				if err != nil {
+					return err
+				}
+			}
 
 			// Start the profile request.
 			var reply *protos.GetProfileReply
@@ -143,7 +139,7 @@ Examples:
 
 			// Wait for the profile to finish. If the profile is going to take a long
 			// time, show a spinner to the user, so they know how long to wait.
-			if *profileType == "cpu" && *profileDuration > time.Second {
+			if *profileType != "heap" && *profileDuration > time.Second {
 				spinner("Profiling in progress...", *profileDuration, done)
 			} else {
 				<-done
@@ -187,6 +183,70 @@ Examples:
 	}
 }
 
+// findDeployment returns a client to the deployment whose id has the provided
+// prefix. If the prefix is ambiguous, the candidate deployments are printed to
+// stderr.
+func findDeployment(ctx context.Context, registry func(context.Context) (*Registry, error), prefix string) (*Client, error) {
+	reg, err := FindRegistration(ctx, registry, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(reg.Addr), nil
+}
+
+// FindRegistration returns the registration of the deployment whose id has
+// the provided prefix. If the prefix is ambiguous, the candidate deployments
+// are printed to stderr.
+func FindRegistration(ctx context.Context, registry func(context.Context) (*Registry, error), prefix string) (Registration, error) {
+	r, err := registry(ctx)
+	if err != nil {
+		return Registration{}, fmt.Errorf("create registry: %w", err)
+	}
+	regs, err := r.List(ctx)
+	if err != nil {
+		return Registration{}, fmt.Errorf("get registrations: %w", err)
+	}
+	var candidates []Registration
+	for _, reg := range regs {
+		if strings.HasPrefix(reg.DeploymentId, prefix) {
+			candidates = append(candidates, reg)
+		}
+	}
+	if len(candidates) == 0 {
+		return Registration{}, fmt.Errorf("no deployment with prefix %q found", prefix)
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(os.Stderr, "The deployment id prefix %q is ambiguous. Expand the prefix to identify one of the following deployments:\n", prefix)
+		for _, candidate := range candidates {
+			fmt.Fprintf(os.Stderr, "  - %s\n", candidate.DeploymentId)
+		}
+		return Registration{}, fmt.Errorf("multiple deployments with prefix %q found", prefix)
+	}
+	return candidates[0], nil // candidates[0] is the only candidate
+}
+
+// findComponent returns the full name of the component of the provided
+// deployment with the provided full or short name.
+func findComponent(status *Status, name string) (string, error) {
+	var matches []string
+	for _, c := range status.Components {
+		if c.Name == name {
+			return c.Name, nil
+		}
+		if logging.ShortenComponent(c.Name) == name {
+			matches = append(matches, c.Name)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no component %q in deployment %s", name, status.DeploymentId)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous component %q in deployment %s: matches %s", name, status.DeploymentId, strings.Join(matches, ", "))
+	}
+}
+
 // spinner prints a spinning progress bar to stderr:
 //
 //	⠏ [8s/10s] Profiling in progress...
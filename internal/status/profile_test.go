@@ -0,0 +1,58 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+)
+
+func TestFindComponent(t *testing.T) {
+	status := &Status{
+		DeploymentId: "1234",
+		Components: []*Component{
+			{Name: "main"},
+			{Name: "github.com/a/cart/Cart"},
+			{Name: "github.com/a/cart/Store"},
+			{Name: "github.com/b/cart/Store"},
+		},
+	}
+	for _, test := range []struct {
+		name string
+		want string // empty if an error is expected
+	}{
+		{"main", "main"},
+		{"github.com/a/cart/Cart", "github.com/a/cart/Cart"},
+		{"cart.Cart", "github.com/a/cart/Cart"},
+		{"github.com/b/cart/Store", "github.com/b/cart/Store"},
+		{"cart.Store", ""}, // ambiguous
+		{"cart.Checkout", ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := findComponent(status, test.name)
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("findComponent(%q): got %q, want error", test.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Fatalf("findComponent(%q): got %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
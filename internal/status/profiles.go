@@ -0,0 +1,142 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+	pprof "github.com/google/pprof/profile"
+	"golang.org/x/exp/slices"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxProfiles is the number of profiles of every type that a ProfileStore
+// keeps for every colocation group.
+const maxProfiles = 60
+
+// ProfileStore keeps the recent profiles collected by the continuous
+// profilers of the weavelets of a deployment, and answers ProfilesRequests
+// about them. For every colocation group and profile type, it keeps the
+// latest maxProfiles profiles, across all the replicas of the group. A
+// ProfileStore is safe for concurrent use.
+type ProfileStore struct {
+	mu       sync.Mutex
+	nextID   int64
+	profiles []storedProfile // oldest first
+}
+
+// storedProfile is a profile kept by a ProfileStore.
+type storedProfile struct {
+	info *ProfileInfo
+	data []byte
+}
+
+// NewProfileStore returns a new, empty ProfileStore.
+func NewProfileStore() *ProfileStore {
+	return &ProfileStore{nextID: 1}
+}
+
+// Add adds a profile collected by the weavelet with the provided id, a
+// replica of the provided colocation group, to the store, forgetting the
+// oldest profile of the group and type if the store has too many.
+func (s *ProfileStore) Add(group, weavelet string, profile *protos.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := &ProfileInfo{
+		Id:             s.nextID,
+		Group:          group,
+		WeaveletId:     weavelet,
+		Components:     slices.Clone(profile.Components),
+		ProfileType:    profile.ProfileType,
+		Time:           timestamppb.New(time.UnixMicro(profile.TimeMicros)),
+		DurationMicros: profile.DurationMicros,
+		SizeBytes:      int64(len(profile.Data)),
+	}
+	s.nextID++
+	s.profiles = append(s.profiles, storedProfile{info: info, data: slices.Clone(profile.Data)})
+
+	n := 0
+	for _, p := range s.profiles {
+		if p.info.Group == group && p.info.ProfileType == profile.ProfileType {
+			n++
+		}
+	}
+	if n <= maxProfiles {
+		return
+	}
+	for i, p := range s.profiles {
+		if p.info.Group == group && p.info.ProfileType == profile.ProfileType {
+			s.profiles = slices.Delete(s.profiles, i, i+1)
+			return
+		}
+	}
+}
+
+// Profiles returns the profiles that match the provided request, newest
+// first, and merges them if requested.
+func (s *ProfileStore) Profiles(req *ProfilesRequest) (*ProfilesReply, error) {
+	var matches []storedProfile
+	s.mu.Lock()
+	for i := len(s.profiles) - 1; i >= 0; i-- {
+		p := s.profiles[i]
+		if req.Id != 0 && p.info.Id != req.Id {
+			continue
+		}
+		if req.ProfileType != protos.ProfileType_Unspecified && p.info.ProfileType != req.ProfileType {
+			continue
+		}
+		if req.Component != "" && !slices.Contains(p.info.Components, req.Component) {
+			continue
+		}
+		matches = append(matches, p)
+	}
+	s.mu.Unlock()
+
+	reply := &ProfilesReply{}
+	for _, p := range matches {
+		reply.Profiles = append(reply.Profiles, p.info)
+	}
+	if !req.Merge || len(matches) == 0 {
+		return reply, nil
+	}
+
+	// Merge the profiles. Profiles are immutable once stored, so they can be
+	// read without holding the lock.
+	profs := make([]*pprof.Profile, len(matches))
+	for i, p := range matches {
+		if p.info.ProfileType != matches[0].info.ProfileType {
+			return nil, fmt.Errorf("cannot merge profiles of types %v and %v", matches[0].info.ProfileType, p.info.ProfileType)
+		}
+		prof, err := pprof.ParseData(p.data)
+		if err != nil {
+			return nil, fmt.Errorf("profile %d: %w", p.info.Id, err)
+		}
+		profs[i] = prof
+	}
+	merged, err := pprof.Merge(profs)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := merged.Write(&buf); err != nil {
+		return nil, err
+	}
+	reply.Data = buf.Bytes()
+	return reply, nil
+}
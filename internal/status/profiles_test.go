@@ -0,0 +1,138 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"bytes"
+	"testing"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	pprof "github.com/google/pprof/profile"
+)
+
+// testProfileData returns an encoded profile with a single sample of the
+// provided value.
+func testProfileData(t *testing.T, value int64) []byte {
+	t.Helper()
+	fn := &pprof.Function{ID: 1, Name: "main.work"}
+	loc := &pprof.Location{ID: 1, Line: []pprof.Line{{Function: fn}}}
+	p := &pprof.Profile{
+		SampleType: []*pprof.ValueType{{Type: "samples", Unit: "count"}},
+		Sample:     []*pprof.Sample{{Location: []*pprof.Location{loc}, Value: []int64{value}}},
+		Location:   []*pprof.Location{loc},
+		Function:   []*pprof.Function{fn},
+	}
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// ids returns the ids of the provided profiles.
+func ids(profiles []*ProfileInfo) []int64 {
+	var ids []int64
+	for _, p := range profiles {
+		ids = append(ids, p.Id)
+	}
+	return ids
+}
+
+func TestProfileStore(t *testing.T) {
+	s := NewProfileStore()
+	cart := []string{"app/Cart", "app/Store"}
+	s.Add("app/Cart", "w1", &protos.Profile{ProfileType: protos.ProfileType_CPU, Components: cart, Data: testProfileData(t, 1)})
+	s.Add("app/Cart", "w2", &protos.Profile{ProfileType: protos.ProfileType_CPU, Components: cart, Data: testProfileData(t, 2)})
+	s.Add("app/Cart", "w1", &protos.Profile{ProfileType: protos.ProfileType_Heap, Components: cart, Data: testProfileData(t, 3)})
+	s.Add("main", "w3", &protos.Profile{ProfileType: protos.ProfileType_CPU, Components: []string{"main"}, Data: testProfileData(t, 4)})
+
+	for _, test := range []struct {
+		name string
+		req  *ProfilesRequest
+		want []int64
+	}{
+		{"All", &ProfilesRequest{}, []int64{4, 3, 2, 1}},
+		{"Component", &ProfilesRequest{Component: "app/Store"}, []int64{3, 2, 1}},
+		{"Type", &ProfilesRequest{ProfileType: protos.ProfileType_CPU}, []int64{4, 2, 1}},
+		{"ComponentAndType", &ProfilesRequest{Component: "app/Cart", ProfileType: protos.ProfileType_CPU}, []int64{2, 1}},
+		{"Id", &ProfilesRequest{Id: 3}, []int64{3}},
+		{"NoMatch", &ProfilesRequest{Component: "app/Payment"}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reply, err := s.Profiles(test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, ids(reply.Profiles)); diff != "" {
+				t.Fatalf("Profiles (-want +got):\n%s", diff)
+			}
+			if len(reply.Data) != 0 {
+				t.Fatalf("Profiles: got data without merge")
+			}
+		})
+	}
+
+	// Merge the CPU profiles of the cart group.
+	reply, err := s.Profiles(&ProfilesRequest{Component: "app/Cart", ProfileType: protos.ProfileType_CPU, Merge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := pprof.ParseData(reply.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, sample := range merged.Sample {
+		total += sample.Value[0]
+	}
+	if total != 3 {
+		t.Fatalf("merged profile: got %d samples, want 3", total)
+	}
+
+	// Profiles of different types can't be merged.
+	if _, err := s.Profiles(&ProfilesRequest{Component: "app/Cart", Merge: true}); err == nil {
+		t.Fatal("Profiles: merging cpu and heap profiles: unexpected success")
+	}
+}
+
+func TestProfileStoreForgets(t *testing.T) {
+	s := NewProfileStore()
+	for i := 0; i < maxProfiles+2; i++ {
+		s.Add("app/Cart", "w1", &protos.Profile{ProfileType: protos.ProfileType_CPU})
+	}
+	s.Add("app/Cart", "w1", &protos.Profile{ProfileType: protos.ProfileType_Heap})
+	s.Add("main", "w2", &protos.Profile{ProfileType: protos.ProfileType_CPU})
+
+	reply, err := s.Profiles(&ProfilesRequest{ProfileType: protos.ProfileType_CPU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ids(reply.Profiles)
+	if len(got) != maxProfiles+1 {
+		t.Fatalf("got %d cpu profiles, want %d", len(got), maxProfiles+1)
+	}
+	// The two oldest profiles of the cart group are forgotten.
+	if oldest := got[len(got)-1]; oldest != 3 {
+		t.Fatalf("oldest cpu profile: got %d, want 3", oldest)
+	}
+	reply, err = s.Profiles(&ProfilesRequest{ProfileType: protos.ProfileType_Heap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply.Profiles) != 1 {
+		t.Fatalf("got %d heap profiles, want 1", len(reply.Profiles))
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/ServiceWeaver/weaver/internal/files"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
 )
@@ -114,6 +115,13 @@ func NewRegistry(_ context.Context, dir string) (*Registry, error) {
 
 // Register adds a registration to the registry.
 func (r *Registry) Register(ctx context.Context, reg Registration) error {
+	return r.RegisterEncrypted(ctx, reg, nil)
+}
+
+// RegisterEncrypted adds a registration to the registry, encrypted with the
+// provided key. If key is nil, the registration is not encrypted.
+// Encrypted registrations are decrypted transparently when they are read.
+func (r *Registry) RegisterEncrypted(ctx context.Context, reg Registration, key *atrest.Key) error {
 	bytes, err := json.Marshal(reg)
 	if err != nil {
 		return err
@@ -121,12 +129,29 @@ func (r *Registry) Register(ctx context.Context, reg Registration) error {
 	filename := fmt.Sprintf("%s.json", reg.DeploymentId)
 	w := files.NewWriter(filepath.Join(r.dir, filename))
 	defer w.Cleanup()
-	if _, err := w.Write(bytes); err != nil {
+	if _, err := w.Write(atrest.Encrypt(key, bytes)); err != nil {
 		return err
 	}
 	return w.Close()
 }
 
+// read reads the registration stored in the named file.
+func (r *Registry) read(ctx context.Context, filename string) (Registration, error) {
+	bytes, err := os.ReadFile(filepath.Join(r.dir, filename))
+	if err != nil {
+		return Registration{}, err
+	}
+	bytes, err = atrest.Decrypt(ctx, bytes)
+	if err != nil {
+		return Registration{}, fmt.Errorf("registration %s: %w", filename, err)
+	}
+	var reg Registration
+	if err := json.Unmarshal(bytes, &reg); err != nil {
+		return Registration{}, err
+	}
+	return reg, nil
+}
+
 // Unregister removes a registration from the registry.
 func (r *Registry) Unregister(_ context.Context, deploymentId string) error {
 	filename := fmt.Sprintf("%s.json", deploymentId)
@@ -141,14 +166,10 @@ func (r *Registry) Get(ctx context.Context, deploymentId string) (Registration,
 		return Registration{}, err
 	}
 	for _, entry := range entries {
-		bytes, err := os.ReadFile(filepath.Join(r.dir, entry.Name()))
+		reg, err := r.read(ctx, entry.Name())
 		if err != nil {
 			return Registration{}, err
 		}
-		var reg Registration
-		if err := json.Unmarshal(bytes, &reg); err != nil {
-			return Registration{}, err
-		}
 		if reg.DeploymentId != deploymentId {
 			continue
 		}
@@ -162,7 +183,7 @@ func (r *Registry) Get(ctx context.Context, deploymentId string) (Registration,
 
 // List returns all active Registrations.
 func (r *Registry) List(ctx context.Context) ([]Registration, error) {
-	regs, err := r.list()
+	regs, err := r.list(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -191,8 +212,51 @@ func (r *Registry) List(ctx context.Context) ([]Registration, error) {
 	return alive, nil
 }
 
+// Running returns the ids of all active deployments.
+func (r *Registry) Running(ctx context.Context) (map[string]bool, error) {
+	regs, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]bool, len(regs))
+	for _, reg := range regs {
+		running[reg.DeploymentId] = true
+	}
+	return running, nil
+}
+
+// RunningDeployments returns the ids of the active deployments in the
+// registries of all the deployers on the local machine, e.g., to keep from
+// deleting the traces of a running deployment from the trace database that
+// the deployers share.
+func RunningDeployments(ctx context.Context) (map[string]bool, error) {
+	dir, err := files.DefaultDataDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := filepath.Glob(filepath.Join(dir, "*_registry"))
+	if err != nil {
+		return nil, err
+	}
+	running := map[string]bool{}
+	for _, dir := range dirs {
+		registry, err := NewRegistry(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := registry.Running(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for id := range ids {
+			running[id] = true
+		}
+	}
+	return running, nil
+}
+
 // list returns all registrations, dead or alive.
-func (r *Registry) list() ([]Registration, error) {
+func (r *Registry) list(ctx context.Context) ([]Registration, error) {
 	entries, err := os.ReadDir(r.dir)
 	if err != nil {
 		return nil, err
@@ -200,14 +264,10 @@ func (r *Registry) list() ([]Registration, error) {
 
 	var regs []Registration
 	for _, entry := range entries {
-		bytes, err := os.ReadFile(filepath.Join(r.dir, entry.Name()))
+		reg, err := r.read(ctx, entry.Name())
 		if err != nil {
 			return nil, err
 		}
-		var reg Registration
-		if err := json.Unmarshal(bytes, &reg); err != nil {
-			return nil, err
-		}
 		regs = append(regs, reg)
 	}
 	return regs, nil
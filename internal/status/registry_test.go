@@ -15,11 +15,16 @@
 package status
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -46,6 +51,36 @@ func (f fakeClient) Profile(context.Context, *protos.GetProfileRequest) (*protos
 	return nil, fmt.Errorf("unimplemented")
 }
 
+// Profiles implements the Server interface.
+func (f fakeClient) Profiles(context.Context, *ProfilesRequest) (*ProfilesReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+// SetLogLevel implements the Server interface.
+func (f fakeClient) SetLogLevel(context.Context, *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+// SplitTraffic implements the Server interface.
+func (f fakeClient) SplitTraffic(context.Context, *SplitTrafficRequest) (*SplitTrafficReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+// Rollout implements the Server interface.
+func (f fakeClient) Rollout(context.Context, *RolloutRequest) (*RolloutReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+// Flags implements the Server interface.
+func (f fakeClient) Flags(context.Context, *FlagsRequest) (*FlagsReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
+// ApplyConfig implements the Server interface.
+func (f fakeClient) ApplyConfig(context.Context, *ApplyConfigRequest) (*ApplyConfigReply, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
 func TestRegister(t *testing.T) {
 	// Create the registry.
 	ctx := context.Background()
@@ -68,7 +103,50 @@ func TestRegister(t *testing.T) {
 	}
 
 	// List the deployments.
-	got, err := registry.list()
+	got, err := registry.list(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(regs, got); diff != "" {
+		t.Fatalf("List (-want +got):\n%s", diff)
+	}
+}
+
+func TestRegisterEncrypted(t *testing.T) {
+	// Create the registry.
+	ctx := context.Background()
+	dir := t.TempDir()
+	registry, err := NewRegistry(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REGISTRY_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, 32)))
+	key, err := atrest.Resolve(ctx, "${secret:env:REGISTRY_TEST_KEY}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Register the deployments, one of them encrypted.
+	regs := []Registration{
+		{"0", "todo", "localhost:0"},
+		{"1", "chat", "localhost:1"},
+	}
+	if err := registry.Register(ctx, regs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RegisterEncrypted(ctx, regs[1], key); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("chat")) {
+		t.Fatalf("registration stored in plaintext: %q", data)
+	}
+
+	// List the deployments.
+	got, err := registry.list(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -106,7 +184,7 @@ func TestUnregister(t *testing.T) {
 	}
 
 	// List the deployments.
-	got, err := registry.list()
+	got, err := registry.list(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,235 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sort"
+	"time"
+)
+
+// ReportVersion is the version of the schema of a Report. Fields may be added
+// to a version, but a field is never removed, renamed, or given a new meaning
+// without changing the version.
+const ReportVersion = 1
+
+// A Report is the machine-readable status of a set of deployments. It is
+// printed by "status --format=json", and served by the dashboard at
+// /status.json, for tools and CI checks to consume. Lists are never null, and
+// are sorted, so that reports are easy to compare.
+type Report struct {
+	Version     int                `json:"version"`     // ReportVersion
+	Deployments []DeploymentReport `json:"deployments"` // by app, then submission time
+}
+
+// DeploymentReport is the status of a deployment.
+type DeploymentReport struct {
+	App           string            `json:"app"`            // application name
+	DeploymentID  string            `json:"deployment_id"`  // deployment id
+	SubmittedAt   time.Time         `json:"submitted_at"`   // when the app was submitted
+	StatusAddress string            `json:"status_address"` // status server address
+	Healthy       bool              `json:"healthy"`        // do all replicas pass their health checks?
+	Components    []ComponentReport `json:"components"`     // by name
+	Replicas      []ReplicaReport   `json:"replicas"`       // by group, then pid
+	Listeners     []ListenerReport  `json:"listeners"`      // by name
+	Limits        []LimitsReport    `json:"limits"`         // by group
+	Rollout       *RolloutReport    `json:"rollout"`        // rollout in progress, or null
+	Externals     []ExternalReport  `json:"externals"`      // by group, then name
+	Crashes       []CrashReport     `json:"crashes"`        // newest first
+}
+
+// ComponentReport is the status of a component.
+type ComponentReport struct {
+	Name        string  `json:"name"`         // full component name
+	Group       string  `json:"group"`        // colocation group name
+	ReplicaPIDs []int64 `json:"replica_pids"` // pids of the component's replicas, sorted
+}
+
+// ReplicaReport is the status of a replica of a colocation group.
+type ReplicaReport struct {
+	Group   string `json:"group"`             // colocation group name
+	PID     int64  `json:"pid"`               // weavelet pid
+	Healthy bool   `json:"healthy"`           // does the replica pass its health checks?
+	Address string `json:"address,omitempty"` // weavelet address, if unhealthy
+	Reason  string `json:"reason,omitempty"`  // why the replica is unhealthy
+}
+
+// ListenerReport is the status of a listener.
+type ListenerReport struct {
+	Name    string `json:"name"`    // listener name
+	Address string `json:"address"` // dialable listener address
+}
+
+// LimitsReport is the status of the resource limits of a colocation group.
+type LimitsReport struct {
+	Group         string  `json:"group"`           // colocation group name
+	CPU           float64 `json:"cpu"`             // CPU limit, in cores, or 0 if unlimited
+	MemoryBytes   int64   `json:"memory_bytes"`    // memory limit, in bytes, or 0 if unlimited
+	Enforcement   string  `json:"enforcement"`     // how the limits are enforced
+	CPUThrottled  int64   `json:"cpu_throttled"`   // periods in which the replicas were throttled
+	MemoryMaxHits int64   `json:"memory_max_hits"` // times the replicas hit the memory limit
+	OOMKills      int64   `json:"oom_kills"`       // replicas killed for exceeding the memory limit
+}
+
+// ExternalReport is the health of an external service used by the replicas of
+// a colocation group.
+type ExternalReport struct {
+	Name      string `json:"name"`            // service name
+	Kind      string `json:"kind"`            // service kind
+	Group     string `json:"group"`           // colocation group name
+	Replicas  int64  `json:"replicas"`        // replicas that use the service
+	Unhealthy int64  `json:"unhealthy"`       // replicas whose checks of the service fail
+	Error     string `json:"error,omitempty"` // latest error of a failing check
+}
+
+// CrashReport describes a replica crash caused by a panic in a component
+// method serving a remote call.
+type CrashReport struct {
+	Group     string    `json:"group"`              // colocation group name
+	PID       int64     `json:"pid"`                // weavelet pid
+	Component string    `json:"component"`          // full component name
+	Method    string    `json:"method"`             // method name
+	Time      time.Time `json:"time"`               // when the method panicked
+	Panic     string    `json:"panic"`              // panic value
+	Args      string    `json:"args"`               // summary of the serialized arguments
+	TraceID   string    `json:"trace_id,omitempty"` // trace id of the call, if traced
+	Stack     string    `json:"stack"`              // stack trace of the panicking goroutine
+}
+
+// RolloutReport is the status of a rollout in progress.
+type RolloutReport struct {
+	OldDeploymentID string  `json:"old_deployment_id"` // deployment id of the running version
+	NewDeploymentID string  `json:"new_deployment_id"` // deployment id of the new version
+	TrafficPercent  float64 `json:"traffic_percent"`   // percentage of traffic sent to the new version
+	MirrorPercent   float64 `json:"mirror_percent"`    // percentage of traffic mirrored to the new version
+}
+
+// report returns the report of the provided statuses.
+func report(statuses []*Status) *Report {
+	r := &Report{Version: ReportVersion, Deployments: []DeploymentReport{}}
+	for _, status := range statuses {
+		r.Deployments = append(r.Deployments, deploymentReport(status))
+	}
+	sort.Slice(r.Deployments, func(i, j int) bool {
+		x, y := r.Deployments[i], r.Deployments[j]
+		if x.App != y.App {
+			return x.App < y.App
+		}
+		return x.SubmittedAt.Before(y.SubmittedAt)
+	})
+	return r
+}
+
+// deploymentReport returns the report of the provided status.
+func deploymentReport(status *Status) DeploymentReport {
+	d := DeploymentReport{
+		App:           status.App,
+		DeploymentID:  status.DeploymentId,
+		SubmittedAt:   status.SubmissionTime.AsTime(),
+		StatusAddress: status.StatusAddr,
+		Healthy:       len(status.Unhealthy) == 0,
+		Components:    []ComponentReport{},
+		Replicas:      []ReplicaReport{},
+		Listeners:     []ListenerReport{},
+		Limits:        []LimitsReport{},
+		Externals:     []ExternalReport{},
+		Crashes:       []CrashReport{},
+	}
+
+	// The replicas of a group host every component of the group, and are
+	// healthy unless reported otherwise.
+	type replica struct {
+		group string
+		pid   int64
+	}
+	replicas := map[replica]ReplicaReport{}
+	for _, c := range status.Components {
+		pids := append([]int64{}, c.Pids...)
+		sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+		d.Components = append(d.Components, ComponentReport{Name: c.Name, Group: c.Group, ReplicaPIDs: pids})
+		for _, pid := range pids {
+			replicas[replica{c.Group, pid}] = ReplicaReport{Group: c.Group, PID: pid, Healthy: true}
+		}
+	}
+	for _, u := range status.Unhealthy {
+		replicas[replica{u.Group, u.Pid}] = ReplicaReport{Group: u.Group, PID: u.Pid, Address: u.Addr, Reason: u.Reason}
+	}
+	for _, r := range replicas {
+		d.Replicas = append(d.Replicas, r)
+	}
+	for _, l := range status.Listeners {
+		d.Listeners = append(d.Listeners, ListenerReport{Name: l.Name, Address: l.Addr})
+	}
+	for _, l := range status.Limits {
+		d.Limits = append(d.Limits, LimitsReport{
+			Group:         l.Group,
+			CPU:           l.Cpu,
+			MemoryBytes:   l.MemoryBytes,
+			Enforcement:   l.Enforcement,
+			CPUThrottled:  l.CpuThrottled,
+			MemoryMaxHits: l.MemoryMaxHits,
+			OOMKills:      l.OomKills,
+		})
+	}
+	for _, e := range status.Externals {
+		d.Externals = append(d.Externals, ExternalReport{
+			Name:      e.Name,
+			Kind:      e.Kind,
+			Group:     e.Group,
+			Replicas:  e.Replicas,
+			Unhealthy: e.Unhealthy,
+			Error:     e.Error,
+		})
+	}
+	for _, c := range status.Crashes {
+		d.Crashes = append(d.Crashes, CrashReport{
+			Group:     c.Group,
+			PID:       c.Pid,
+			Component: c.Report.Component,
+			Method:    c.Report.Method,
+			Time:      time.UnixMicro(c.Report.TimeMicros).UTC(),
+			Panic:     c.Report.Panic,
+			Args:      c.Report.Args,
+			TraceID:   c.Report.TraceId,
+			Stack:     c.Report.Stack,
+		})
+	}
+	if r := status.Rollout; r != nil {
+		d.Rollout = &RolloutReport{
+			OldDeploymentID: r.OldVersion,
+			NewDeploymentID: r.NewVersion,
+			TrafficPercent:  r.TrafficPercent,
+			MirrorPercent:   r.MirrorPercent,
+		}
+	}
+
+	sort.Slice(d.Components, func(i, j int) bool { return d.Components[i].Name < d.Components[j].Name })
+	sort.Slice(d.Replicas, func(i, j int) bool {
+		x, y := d.Replicas[i], d.Replicas[j]
+		if x.Group != y.Group {
+			return x.Group < y.Group
+		}
+		return x.PID < y.PID
+	})
+	sort.Slice(d.Listeners, func(i, j int) bool { return d.Listeners[i].Name < d.Listeners[j].Name })
+	sort.Slice(d.Limits, func(i, j int) bool { return d.Limits[i].Group < d.Limits[j].Group })
+	sort.Slice(d.Externals, func(i, j int) bool {
+		x, y := d.Externals[i], d.Externals[j]
+		if x.Group != y.Group {
+			return x.Group < y.Group
+		}
+		return x.Name < y.Name
+	})
+	return d
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestReport(t *testing.T) {
+	submitted := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	statuses := []*Status{
+		{
+			App:            "hello",
+			DeploymentId:   "new",
+			SubmissionTime: timestamppb.New(submitted.Add(time.Minute)),
+			Components: []*Component{
+				{Name: "main", Group: "main", Pids: []int64{2, 1}},
+				{Name: "hello/Cache", Group: "hello/Reverser", Pids: []int64{3}},
+				{Name: "hello/Reverser", Group: "hello/Reverser", Pids: []int64{3}},
+			},
+			Listeners: []*Listener{{Name: "hello", Addr: "[::]:1234"}},
+			Unhealthy: []*UnhealthyReplica{{Group: "main", Pid: 2, Addr: "tcp://main", Reason: "timeout"}},
+			Rollout:   &Rollout{OldVersion: "old", NewVersion: "new", TrafficPercent: 10},
+			Externals: []*ExternalService{
+				{Name: "orders", Kind: "sql", Group: "main", Replicas: 2, Unhealthy: 1, Error: "connection refused"},
+				{Name: "cache", Kind: "redis", Group: "main", Replicas: 2},
+			},
+			Crashes: []*Crash{
+				{Group: "hello/Reverser", Pid: 4, WeaveletId: "w4", Report: &protos.CrashReport{
+					Component:  "hello/Reverser",
+					Method:     "Reverse",
+					Panic:      "index out of range",
+					Stack:      "goroutine 1 [running]:",
+					Args:       "2 bytes: 6869",
+					TraceId:    "0102",
+					TimeMicros: submitted.Add(2 * time.Minute).UnixMicro(),
+				}},
+			},
+		},
+		{
+			App:            "hello",
+			DeploymentId:   "old",
+			SubmissionTime: timestamppb.New(submitted),
+		},
+	}
+	want := &Report{
+		Version: ReportVersion,
+		Deployments: []DeploymentReport{
+			{
+				App:          "hello",
+				DeploymentID: "old",
+				SubmittedAt:  submitted,
+				Healthy:      true,
+				Components:   []ComponentReport{},
+				Replicas:     []ReplicaReport{},
+				Listeners:    []ListenerReport{},
+				Limits:       []LimitsReport{},
+				Externals:    []ExternalReport{},
+				Crashes:      []CrashReport{},
+			},
+			{
+				App:          "hello",
+				DeploymentID: "new",
+				SubmittedAt:  submitted.Add(time.Minute),
+				Components: []ComponentReport{
+					{Name: "hello/Cache", Group: "hello/Reverser", ReplicaPIDs: []int64{3}},
+					{Name: "hello/Reverser", Group: "hello/Reverser", ReplicaPIDs: []int64{3}},
+					{Name: "main", Group: "main", ReplicaPIDs: []int64{1, 2}},
+				},
+				Replicas: []ReplicaReport{
+					{Group: "hello/Reverser", PID: 3, Healthy: true},
+					{Group: "main", PID: 1, Healthy: true},
+					{Group: "main", PID: 2, Address: "tcp://main", Reason: "timeout"},
+				},
+				Listeners: []ListenerReport{{Name: "hello", Address: "[::]:1234"}},
+				Limits:    []LimitsReport{},
+				Rollout:   &RolloutReport{OldDeploymentID: "old", NewDeploymentID: "new", TrafficPercent: 10},
+				Externals: []ExternalReport{
+					{Name: "cache", Kind: "redis", Group: "main", Replicas: 2},
+					{Name: "orders", Kind: "sql", Group: "main", Replicas: 2, Unhealthy: 1, Error: "connection refused"},
+				},
+				Crashes: []CrashReport{
+					{
+						Group:     "hello/Reverser",
+						PID:       4,
+						Component: "hello/Reverser",
+						Method:    "Reverse",
+						Time:      submitted.Add(2 * time.Minute),
+						Panic:     "index out of range",
+						Args:      "2 bytes: 6869",
+						TraceID:   "0102",
+						Stack:     "goroutine 1 [running]:",
+					},
+				},
+			},
+		},
+	}
+	got := report(statuses)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("report (-want +got):\n%s", diff)
+	}
+
+	// Empty lists are encoded as lists, not null.
+	data, err := json.Marshal(got.Deployments[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantJSON = `{"app":"hello","deployment_id":"old","submitted_at":"2023-06-01T12:00:00Z","status_address":"","healthy":true,"components":[],"replicas":[],"listeners":[],"limits":[],"rollout":null,"externals":[],"crashes":[]}`
+	if string(data) != wantJSON {
+		t.Fatalf("json: got %s, want %s", data, wantJSON)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"sort"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// GroupResources summarizes the resources used by the replicas of a
+// co-location group, and so by the components in the group.
+type GroupResources struct {
+	Group          string   // co-location group
+	Components     []string // components in the group, sorted
+	Replicas       int      // number of replicas reporting metrics
+	CPUSeconds     float64  // total CPU time, in seconds
+	RSSBytes       float64  // total resident set size, in bytes
+	HeapBytes      float64  // total bytes of allocated heap objects
+	Goroutines     float64  // total number of goroutines
+	GCPauseSeconds float64  // total garbage collection pause time, in seconds
+	ServingCalls   float64  // component method calls being served
+}
+
+// groupResources computes the resources used by the co-location groups of
+// the provided deployment from the weavelet metrics in the provided metric
+// snapshots. Metrics from the replicas of a group are summed. Groups without
+// metrics are omitted. The returned groups are sorted by name.
+func groupResources(status *Status, metrics []*protos.MetricSnapshot) []*GroupResources {
+	groups := map[string]*GroupResources{}
+	get := func(group string) *GroupResources {
+		g, ok := groups[group]
+		if !ok {
+			g = &GroupResources{Group: group}
+			groups[group] = g
+		}
+		return g
+	}
+	groupOf := map[string]string{} // component -> group
+	for _, c := range status.Components {
+		groupOf[c.Name] = c.Group
+	}
+
+	replicas := map[string]map[string]bool{} // group -> weavelet ids
+	for _, metric := range metrics {
+		group, ok := metric.Labels["serviceweaver_group"]
+		if !ok {
+			continue
+		}
+		if v := metric.Labels["serviceweaver_version"]; v != "" && v != status.DeploymentId {
+			continue
+		}
+		if node := metric.Labels["serviceweaver_node"]; node != "" {
+			if replicas[group] == nil {
+				replicas[group] = map[string]bool{}
+			}
+			replicas[group][node] = true
+		}
+		switch metric.Name {
+		case "serviceweaver_weavelet_cpu_seconds":
+			get(group).CPUSeconds += metric.Value
+		case "serviceweaver_weavelet_memory_rss_bytes":
+			get(group).RSSBytes += metric.Value
+		case "serviceweaver_weavelet_heap_bytes":
+			get(group).HeapBytes += metric.Value
+		case "serviceweaver_weavelet_goroutines":
+			get(group).Goroutines += metric.Value
+		case "serviceweaver_weavelet_gc_pause_seconds":
+			get(group).GCPauseSeconds += metric.Value
+		case "serviceweaver_component_serving_calls":
+			// Attribute calls to the group of the component serving them.
+			if g, ok := groupOf[metric.Labels["component"]]; ok {
+				group = g
+			}
+			get(group).ServingCalls += metric.Value
+		}
+	}
+
+	result := make([]*GroupResources, 0, len(groups))
+	for _, g := range groups {
+		g.Replicas = len(replicas[g.Group])
+		for _, c := range status.Components {
+			if c.Group == g.Group {
+				g.Components = append(g.Components, c.Name)
+			}
+		}
+		sort.Strings(g.Components)
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Group < result[j].Group })
+	return result
+}
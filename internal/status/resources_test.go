@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGroupResources(t *testing.T) {
+	status := &Status{
+		DeploymentId: "v1",
+		Components: []*Component{
+			{Name: "main", Group: "main"},
+			{Name: "app/B", Group: "app/A"},
+			{Name: "app/A", Group: "app/A"},
+		},
+	}
+	metric := func(name, group, node string, value float64) *protos.MetricSnapshot {
+		return &protos.MetricSnapshot{
+			Name: name,
+			Labels: map[string]string{
+				"serviceweaver_version": "v1",
+				"serviceweaver_group":   group,
+				"serviceweaver_node":    node,
+			},
+			Value: value,
+		}
+	}
+	serving := metric("serviceweaver_component_serving_calls", "app/A", "n2", 3)
+	serving.Labels["component"] = "app/B"
+	other := metric("serviceweaver_weavelet_goroutines", "main", "n4", 100)
+	other.Labels["serviceweaver_version"] = "v2"
+	got := groupResources(status, []*protos.MetricSnapshot{
+		metric("serviceweaver_weavelet_cpu_seconds", "main", "n1", 1.5),
+		metric("serviceweaver_weavelet_goroutines", "main", "n1", 10),
+		// Two replicas of group app/A.
+		metric("serviceweaver_weavelet_cpu_seconds", "app/A", "n2", 2),
+		metric("serviceweaver_weavelet_cpu_seconds", "app/A", "n3", 3),
+		metric("serviceweaver_weavelet_memory_rss_bytes", "app/A", "n2", 100),
+		metric("serviceweaver_weavelet_memory_rss_bytes", "app/A", "n3", 200),
+		metric("serviceweaver_weavelet_heap_bytes", "app/A", "n2", 50),
+		metric("serviceweaver_weavelet_gc_pause_seconds", "app/A", "n3", 0.25),
+		serving,
+		// A different deployment.
+		other,
+		// A metric without a group, e.g., of the deployer.
+		{Name: "serviceweaver_weavelet_goroutines", Value: 1000},
+	})
+	want := []*GroupResources{
+		{
+			Group:          "app/A",
+			Components:     []string{"app/A", "app/B"},
+			Replicas:       2,
+			CPUSeconds:     5,
+			RSSBytes:       300,
+			HeapBytes:      50,
+			GCPauseSeconds: 0.25,
+			ServingCalls:   3,
+		},
+		{
+			Group:      "main",
+			Components: []string{"main"},
+			Replicas:   1,
+			CPUSeconds: 1.5,
+			Goroutines: 10,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("groupResources (-want +got):\n%s", diff)
+	}
+}
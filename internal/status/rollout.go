@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+// RolloutCommand returns a "rollout" subcommand that changes the course of the
+// rollout of a new version of an application, deployed with "deploy
+// --rollout". tool is the name of the command-line tool the returned
+// subcommand runs as (e.g., "weaver multi").
+func RolloutCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	flags := flag.NewFlagSet("rollout", flag.ContinueOnError)
+	traffic := flags.Float64("traffic", -1, "Percentage of the listener traffic sent to the new version")
+	mirror := flags.Float64("mirror", -1, "Percentage of the listener traffic mirrored to the new version")
+	promote := flags.Bool("promote", false, "Promote the new version")
+	return &dtool.Command{
+		Name:        "rollout",
+		Description: "Shift traffic to a new version of an app",
+		Help: fmt.Sprintf(`Usage:
+  %s rollout (--traffic=<percent> | --mirror=<percent> | --promote) <deployment>
+
+Flags:
+  -h, --help	Print this help message.
+  --traffic	Percentage of the listener traffic sent to the new version.
+  --mirror	Percentage of the listener traffic mirrored to the new
+		version.
+  --promote	Send all the traffic to the new version, and shut down the
+		running version.
+
+Description:
+  "%s deploy --rollout" deploys a new version of an app side by side with
+  the running version. "%s rollout" changes the percentage of the traffic
+  of the app's listeners sent to the new version, or promotes it: all the
+  traffic is sent to the new version, and the running version is shut down.
+  Use "%s rollback" to roll the new version back instead.
+
+  "%s rollout --mirror" changes the percentage of the traffic mirrored to
+  the new version instead. The running version serves the mirrored
+  requests, and copies of them are sent to the new version, whose
+  responses are discarded. The copies carry the X-Serviceweaver-Mirror
+  header. Use mirroring to check the metrics, logs, and traces of the new
+  version against real traffic before sending it any.
+
+  <deployment> is the id of the deployment of the new version, or a uniquely
+  identifying prefix of it.
+
+Examples:
+  # Send a quarter of the traffic to the new version.
+  %s rollout --traffic=25 2c80d811
+
+  # Mirror a tenth of the traffic to the new version.
+  %s rollout --mirror=10 2c80d811
+
+  # Promote the new version.
+  %s rollout --promote 2c80d811`, tool, tool, tool, tool, tool, tool, tool, tool),
+		Flags: flags,
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || args[0] == "" {
+				return fmt.Errorf("usage: %s rollout (--traffic=<percent> | --mirror=<percent> | --promote) <deployment>", tool)
+			}
+			provided := 0
+			for _, ok := range []bool{*traffic >= 0, *mirror >= 0, *promote} {
+				if ok {
+					provided++
+				}
+			}
+			if provided != 1 {
+				return fmt.Errorf("exactly one of --traffic, --mirror, and --promote must be provided")
+			}
+			client, err := findDeployment(ctx, registry, args[0])
+			if err != nil {
+				return err
+			}
+			req := &RolloutRequest{TrafficPercent: *traffic, Promote: *promote}
+			if *mirror >= 0 {
+				req = &RolloutRequest{TrafficPercent: *mirror, Mirror: true}
+			}
+			if _, err := client.Rollout(ctx, req); err != nil {
+				return err
+			}
+			switch {
+			case *promote:
+				fmt.Println("Promoted the new version.")
+			case *mirror >= 0:
+				fmt.Printf("Mirroring %g%% of the traffic to the new version.\n", *mirror)
+			default:
+				fmt.Printf("Sending %g%% of the traffic to the new version.\n", *traffic)
+			}
+			return nil
+		},
+	}
+}
+
+// RollbackCommand returns a "rollback" subcommand that rolls back a new
+// version of an application, deployed with "deploy --rollout". tool is the
+// name of the command-line tool the returned subcommand runs as (e.g.,
+// "weaver multi").
+func RollbackCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	return &dtool.Command{
+		Name:        "rollback",
+		Description: "Roll back a new version of an app",
+		Help: fmt.Sprintf(`Usage:
+  %s rollback <deployment>
+
+Flags:
+  -h, --help	Print this help message.
+
+Description:
+  "%s rollback" sends all the traffic of the app's listeners back to the
+  running version of an app, and shuts down the new version being rolled
+  out. <deployment> is the id of the deployment of the new version, or a
+  uniquely identifying prefix of it.`, tool, tool),
+		Flags: flag.NewFlagSet("rollback", flag.ContinueOnError),
+		Fn: func(ctx context.Context, args []string) error {
+			if len(args) != 1 || args[0] == "" {
+				return fmt.Errorf("usage: %s rollback <deployment>", tool)
+			}
+			client, err := findDeployment(ctx, registry, args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := client.Rollout(ctx, &RolloutRequest{Rollback: true}); err != nil {
+				return err
+			}
+			fmt.Println("Rolled back the new version.")
+			return nil
+		},
+	}
+}
@@ -15,8 +15,8 @@
 package status
 
 import (
-	"bytes"
 	"context"
+	"net"
 	"net/http"
 
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
@@ -31,6 +31,13 @@ const (
 	metricsEndpoint    = "/debug/serviceweaver/metrics"
 	prometheusEndpoint = "/debug/serviceweaver/prometheus"
 	profileEndpoint    = "/debug/serviceweaver/profile"
+	profilesEndpoint   = "/debug/serviceweaver/profiles"
+	graphEndpoint      = "/debug/serviceweaver/graph"
+	logLevelEndpoint   = "/debug/serviceweaver/loglevel"
+	splitEndpoint      = "/debug/serviceweaver/split"
+	rolloutEndpoint    = "/debug/serviceweaver/rollout"
+	flagsEndpoint      = "/debug/serviceweaver/flags"
+	configEndpoint     = "/debug/serviceweaver/config"
 )
 
 // A Server returns information about a Service Weaver deployment.
@@ -43,6 +50,31 @@ type Server interface {
 
 	// Profile returns a profile of the deployment.
 	Profile(context.Context, *protos.GetProfileRequest) (*protos.GetProfileReply, error)
+
+	// Profiles returns the recent profiles collected by the continuous
+	// profilers of the deployment's weavelets. See ProfileStore.
+	Profiles(context.Context, *ProfilesRequest) (*ProfilesReply, error)
+
+	// SetLogLevel changes the minimum level of the log entries produced by a
+	// component of the deployment.
+	SetLogLevel(context.Context, *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error)
+
+	// SplitTraffic forwards a fraction of the traffic of the deployment's
+	// listeners to a new version of the application being rolled out. It is
+	// called by the deployer of the new version.
+	SplitTraffic(context.Context, *SplitTrafficRequest) (*SplitTrafficReply, error)
+
+	// Rollout changes the course of the rollout of the deployment over the
+	// running version of the application.
+	Rollout(context.Context, *RolloutRequest) (*RolloutReply, error)
+
+	// Flags updates the feature flags of the deployment, and returns the
+	// updated flags.
+	Flags(context.Context, *FlagsRequest) (*FlagsReply, error)
+
+	// ApplyConfig applies a new config to the deployment, reconfiguring the
+	// components whose config sections changed.
+	ApplyConfig(context.Context, *ApplyConfigRequest) (*ApplyConfigReply, error)
 }
 
 // RegisterServer registers a Server's methods with the provided mux under the
@@ -51,18 +83,37 @@ func RegisterServer(mux *http.ServeMux, server Server, logger *slog.Logger) {
 	mux.Handle(statusEndpoint, protomsg.HandlerThunk(logger, server.Status))
 	mux.Handle(metricsEndpoint, protomsg.HandlerThunk(logger, server.Metrics))
 	mux.Handle(profileEndpoint, protomsg.HandlerFunc(logger, server.Profile))
-	mux.HandleFunc(prometheusEndpoint, func(w http.ResponseWriter, r *http.Request) {
-		ms, err := server.Metrics(r.Context())
+	mux.Handle(profilesEndpoint, protomsg.HandlerFunc(logger, server.Profiles))
+	mux.Handle(logLevelEndpoint, protomsg.HandlerFunc(logger, server.SetLogLevel))
+	mux.Handle(splitEndpoint, protomsg.HandlerFunc(logger, server.SplitTraffic))
+	mux.Handle(rolloutEndpoint, protomsg.HandlerFunc(logger, server.Rollout))
+	mux.Handle(flagsEndpoint, protomsg.HandlerFunc(logger, server.Flags))
+	mux.Handle(configEndpoint, protomsg.HandlerFunc(logger, server.ApplyConfig))
+	mux.Handle(graphEndpoint, graphHandler(server))
+	mux.Handle(prometheusEndpoint, imetrics.PrometheusHandler(prometheusEndpoint, snapshots(server)))
+}
+
+// ServePrometheus serves the metrics of the deployment managed by server, in
+// the Prometheus text format, at /metrics on the provided listener, until ctx
+// is done. Unlike the status server, whose address changes with every
+// deployment, the listener can be bound to a fixed address that Prometheus is
+// configured to scrape.
+func ServePrometheus(ctx context.Context, lis net.Listener, server Server) error {
+	return imetrics.ServePrometheus(ctx, lis, snapshots(server))
+}
+
+// snapshots returns a function that returns snapshots of the metrics of the
+// deployment managed by server.
+func snapshots(server Server) func(context.Context) ([]*metrics.MetricSnapshot, error) {
+	return func(ctx context.Context) ([]*metrics.MetricSnapshot, error) {
+		ms, err := server.Metrics(ctx)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		snapshots := make([]*metrics.MetricSnapshot, len(ms.Metrics))
 		for i, m := range ms.Metrics {
 			snapshots[i] = metrics.UnProto(m)
 		}
-		var b bytes.Buffer
-		imetrics.TranslateMetricsToPrometheusTextFormat(&b, snapshots, r.Host, prometheusEndpoint)
-		w.Write(b.Bytes()) //nolint:errcheck // response write error
-	})
+		return snapshots, nil
+	}
 }
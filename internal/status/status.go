@@ -16,13 +16,16 @@ package status
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/limits"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	dtool "github.com/ServiceWeaver/weaver/runtime/tool"
@@ -33,31 +36,60 @@ import (
 // name of the command-line tool the returned subcommand runs as (e.g.,
 // "weaver single").
 func StatusCommand(tool string, registry func(context.Context) (*Registry, error)) *dtool.Command {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	formatFlag := flags.String("format", "text", `Output format, "text" or "json"`)
 	return &dtool.Command{
 		Name:        "status",
 		Description: "Show the status of Service Weaver applications",
 		Help: fmt.Sprintf(`Usage:
-  %s status
+  %s status [--format=<format>]
 
 Flags:
-  -h, --help	Print this help message.`, tool),
-		Flags: flag.NewFlagSet("status", flag.ContinueOnError),
+  -h, --help	Print this help message.
+%s
+
+Description:
+  With --format=json, the status is printed as a JSON object, with a
+  stable schema, for tools and CI checks to consume:
+
+      {
+        "version": 1,
+        "deployments": [{
+          "app": "hello",
+          "deployment_id": "...",
+          "submitted_at": "2023-06-01T12:00:00Z",
+          "status_address": "...",
+          "healthy": true,
+          "components": [{"name": "...", "group": "...", "replica_pids": [...]}],
+          "replicas": [{"group": "...", "pid": 1234, "healthy": true}],
+          "listeners": [{"name": "hello", "address": "[::]:12345"}],
+          "limits": [{"group": "...", "cpu": 1, "memory_bytes": 0, ...}],
+          "rollout": null,
+          "externals": [{"name": "...", "kind": "sql", "group": "...", "replicas": 2, "unhealthy": 0}]
+        }]
+      }
+
+  An unhealthy replica also has an "address" and a "reason". A rollout in
+  progress has an "old_deployment_id", a "new_deployment_id", a
+  "traffic_percent", and a "mirror_percent". An external service that
+  fails its health checks also has an "error".`, tool, dtool.FlagsHelp(flags)),
+		Flags: flags,
 		Fn: func(ctx context.Context, _ []string) error {
+			if *formatFlag != "text" && *formatFlag != "json" {
+				return fmt.Errorf("invalid --format %q: want \"text\" or \"json\"", *formatFlag)
+			}
 			r, err := registry(ctx)
 			if err != nil {
 				return err
 			}
-			regs, err := r.List(ctx)
+			statuses, err := fetchStatuses(ctx, r)
 			if err != nil {
 				return err
 			}
-			var statuses []*Status
-			for _, reg := range regs {
-				status, err := NewClient(reg.Addr).Status(ctx)
-				if err != nil {
-					return err
-				}
-				statuses = append(statuses, status)
+			if *formatFlag == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report(statuses))
 			}
 			fmt.Print(format(statuses))
 			return nil
@@ -65,6 +97,24 @@ Flags:
 	}
 }
 
+// fetchStatuses returns the statuses of the deployments registered with the
+// provided registry.
+func fetchStatuses(ctx context.Context, r *Registry) ([]*Status, error) {
+	regs, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []*Status
+	for _, reg := range regs {
+		status, err := NewClient(reg.Addr).Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
 // format pretty-prints the provided statuses.
 func format(statuses []*Status) string {
 	sort.Slice(statuses, func(i, j int) bool {
@@ -79,7 +129,12 @@ func format(statuses []*Status) string {
 	var b strings.Builder
 	formatDeployments(&b, statuses)
 	formatComponents(&b, statuses)
+	formatUnhealthy(&b, statuses)
+	formatCrashes(&b, statuses)
+	formatExternals(&b, statuses)
+	formatLimits(&b, statuses)
 	formatListeners(&b, statuses)
+	formatRollouts(&b, statuses)
 	return b.String()
 }
 
@@ -131,6 +186,135 @@ func formatComponents(w io.Writer, statuses []*Status) {
 	}
 }
 
+// formatUnhealthy pretty-prints the replicas failing their health checks.
+func formatUnhealthy(w io.Writer, statuses []*Status) {
+	var unhealthy bool
+	for _, status := range statuses {
+		unhealthy = unhealthy || len(status.Unhealthy) > 0
+	}
+	if !unhealthy {
+		return
+	}
+
+	title := []colors.Text{{{S: "UNHEALTHY REPLICAS", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.PrefixDim)
+	defer t.Flush()
+	t.Row("APP", "DEPLOYMENT", "GROUP", "PID", "ADDRESS", "REASON")
+	for _, status := range statuses {
+		sort.Slice(status.Unhealthy, func(i, j int) bool {
+			return status.Unhealthy[i].Pid < status.Unhealthy[j].Pid
+		})
+		for _, r := range status.Unhealthy {
+			prefix, _ := formatId(status.DeploymentId)
+			reason := colors.Text{{S: r.Reason, Color: colors.Color256(160)}}
+			t.Row(status.App, prefix, logging.ShortenComponent(r.Group), fmt.Sprint(r.Pid), r.Addr, reason)
+		}
+	}
+}
+
+// formatCrashes pretty-prints the recent crashes caused by panicking component
+// methods. Stack traces are omitted; they are printed with --format=json.
+func formatCrashes(w io.Writer, statuses []*Status) {
+	var crashed bool
+	for _, status := range statuses {
+		crashed = crashed || len(status.Crashes) > 0
+	}
+	if !crashed {
+		return
+	}
+
+	title := []colors.Text{{{S: "CRASHES", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.PrefixDim)
+	defer t.Flush()
+	t.Row("APP", "DEPLOYMENT", "GROUP", "PID", "METHOD", "AGE", "TRACE", "PANIC")
+	for _, status := range statuses {
+		for _, c := range status.Crashes {
+			prefix, _ := formatId(status.DeploymentId)
+			method := logging.ShortenComponent(c.Report.Component) + "." + c.Report.Method
+			age := time.Since(time.UnixMicro(c.Report.TimeMicros)).Truncate(time.Second)
+			panicText := colors.Text{{S: c.Report.Panic, Color: colors.Color256(160)}}
+			t.Row(status.App, prefix, logging.ShortenComponent(c.Group), fmt.Sprint(c.Pid), method, age, c.Report.TraceId, panicText)
+		}
+	}
+}
+
+// formatExternals pretty-prints the health of the external services used by
+// co-location groups.
+func formatExternals(w io.Writer, statuses []*Status) {
+	var used bool
+	for _, status := range statuses {
+		used = used || len(status.Externals) > 0
+	}
+	if !used {
+		return
+	}
+
+	title := []colors.Text{{{S: "EXTERNAL SERVICES", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.PrefixDim)
+	defer t.Flush()
+	t.Row("APP", "DEPLOYMENT", "GROUP", "SERVICE", "KIND", "HEALTHY", "ERROR")
+	for _, status := range statuses {
+		for _, e := range status.Externals {
+			prefix, _ := formatId(status.DeploymentId)
+			healthy := colors.Text{{S: fmt.Sprintf("%d/%d", e.Replicas-e.Unhealthy, e.Replicas)}}
+			if e.Unhealthy > 0 {
+				healthy[0].Color = colors.Color256(160)
+			}
+			errText := colors.Text{{S: e.Error, Color: colors.Color256(160)}}
+			t.Row(status.App, prefix, logging.ShortenComponent(e.Group), e.Name, e.Kind, healthy, errText)
+		}
+	}
+}
+
+// formatLimits pretty-prints the resource limits of co-location groups.
+func formatLimits(w io.Writer, statuses []*Status) {
+	var limited bool
+	for _, status := range statuses {
+		limited = limited || len(status.Limits) > 0
+	}
+	if !limited {
+		return
+	}
+
+	title := []colors.Text{{{S: "RESOURCE LIMITS", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.PrefixDim)
+	defer t.Flush()
+	t.Row("APP", "DEPLOYMENT", "GROUP", "CPU", "MEMORY", "ENFORCEMENT", "VIOLATIONS")
+	for _, status := range statuses {
+		sort.Slice(status.Limits, func(i, j int) bool {
+			return status.Limits[i].Group < status.Limits[j].Group
+		})
+		for _, l := range status.Limits {
+			prefix, _ := formatId(status.DeploymentId)
+			cpu, memory := "-", "-"
+			if l.Cpu > 0 {
+				cpu = fmt.Sprint(l.Cpu)
+			}
+			if l.MemoryBytes > 0 {
+				memory = fmt.Sprintf("%.1f MiB", float64(l.MemoryBytes)/(1<<20))
+			}
+			var violations []string
+			if l.CpuThrottled > 0 {
+				violations = append(violations, fmt.Sprintf("%d cpu throttled", l.CpuThrottled))
+			}
+			if l.MemoryMaxHits > 0 {
+				violations = append(violations, fmt.Sprintf("%d memory max", l.MemoryMaxHits))
+			}
+			if l.OomKills > 0 {
+				violations = append(violations, fmt.Sprintf("%d oom killed", l.OomKills))
+			}
+			v := colors.Text{{S: "none"}}
+			if l.Enforcement != limits.Cgroups {
+				// Violations are only tracked for cgroups.
+				v = colors.Text{{S: "-"}}
+			} else if len(violations) > 0 {
+				v = colors.Text{{S: strings.Join(violations, ", "), Color: colors.Color256(160)}}
+			}
+			t.Row(status.App, prefix, logging.ShortenComponent(l.Group), cpu, memory, l.Enforcement, v)
+		}
+	}
+}
+
 // formatDeployments pretty-prints the set of listeners.
 func formatListeners(w io.Writer, statuses []*Status) {
 	title := []colors.Text{{{S: "LISTENERS", Bold: true}}}
@@ -147,3 +331,31 @@ func formatListeners(w io.Writer, statuses []*Status) {
 		}
 	}
 }
+
+// formatRollouts pretty-prints the rollouts in progress.
+func formatRollouts(w io.Writer, statuses []*Status) {
+	// Both versions of an app report the rollout.
+	var rollouts []*Rollout
+	apps := map[string]string{}
+	for _, status := range statuses {
+		if r := status.Rollout; r != nil {
+			if _, ok := apps[r.NewVersion]; !ok {
+				rollouts = append(rollouts, r)
+			}
+			apps[r.NewVersion] = status.App
+		}
+	}
+	if len(rollouts) == 0 {
+		return
+	}
+
+	title := []colors.Text{{{S: "ROLLOUTS", Bold: true}}}
+	t := colors.NewTabularizer(w, title, colors.PrefixDim)
+	defer t.Flush()
+	t.Row("APP", "OLD DEPLOYMENT", "NEW DEPLOYMENT", "NEW TRAFFIC", "MIRRORED")
+	for _, r := range rollouts {
+		oldPrefix, _ := formatId(r.OldVersion)
+		newPrefix, _ := formatId(r.NewVersion)
+		t.Row(apps[r.NewVersion], oldPrefix, newPrefix, fmt.Sprintf("%g%%", r.TrafficPercent), fmt.Sprintf("%g%%", r.MirrorPercent))
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	protos "github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// A topology is a snapshot of the components of a deployment, and of the
+// calls between them, served by the dashboard as JSON. The dashboard polls it
+// to draw a live topology graph, computing call rates from the difference
+// between successive snapshots.
+type topology struct {
+	Components []topologyNode `json:"components"`
+	Edges      []topologyEdge `json:"edges"`
+}
+
+// A topologyNode is a component in a topology.
+type topologyNode struct {
+	Name     string `json:"name"`     // component name
+	Group    string `json:"group"`    // colocation group name, if any
+	Replicas int    `json:"replicas"` // number of replicas of the group
+}
+
+// A topologyEdge is an edge in a topology. If component Caller has called
+// methods of component Component, an edge is formed from Caller to Component.
+type topologyEdge struct {
+	Caller    string  `json:"caller"`    // calling component
+	Component string  `json:"component"` // callee component
+	Calls     float64 `json:"calls"`     // number of calls, since the start of the deployment
+	Errors    float64 `json:"errors"`    // number of calls that returned an error
+}
+
+// computeTopology computes the topology of a deployment from its status and
+// the method call metrics in the provided metric snapshots. Callers that
+// aren't components of the deployment, e.g., "main" in some deployers, are
+// included as components without a group. Components and edges are sorted
+// by name.
+func computeTopology(status *Status, metrics []*protos.MetricSnapshot) topology {
+	var t topology
+	known := map[string]bool{}
+	for _, c := range status.Components {
+		known[c.Name] = true
+		t.Components = append(t.Components, topologyNode{
+			Name:     c.Name,
+			Group:    c.Group,
+			Replicas: len(c.Pids),
+		})
+	}
+
+	type pair struct{ caller, component string }
+	byPair := map[pair]*topologyEdge{}
+	for _, e := range computeCallGraph(metrics) {
+		key := pair{e.Caller, e.Component}
+		edge, ok := byPair[key]
+		if !ok {
+			edge = &topologyEdge{Caller: e.Caller, Component: e.Component}
+			byPair[key] = edge
+		}
+		edge.Calls += e.Calls
+		edge.Errors += e.Errors
+	}
+	for _, e := range byPair {
+		for _, name := range []string{e.Caller, e.Component} {
+			if !known[name] {
+				known[name] = true
+				t.Components = append(t.Components, topologyNode{Name: name})
+			}
+		}
+		t.Edges = append(t.Edges, *e)
+	}
+
+	sort.Slice(t.Components, func(i, j int) bool {
+		return t.Components[i].Name < t.Components[j].Name
+	})
+	sort.Slice(t.Edges, func(i, j int) bool {
+		x, y := t.Edges[i], t.Edges[j]
+		if x.Caller != y.Caller {
+			return x.Caller < y.Caller
+		}
+		return x.Component < y.Component
+	})
+	if t.Components == nil {
+		t.Components = []topologyNode{}
+	}
+	if t.Edges == nil {
+		t.Edges = []topologyEdge{}
+	}
+	return t
+}
+
+// handleTopology handles requests to /topology?id=<deployment id>
+func (d *dashboard) handleTopology(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "no deployment id provided", http.StatusBadRequest)
+		return
+	}
+
+	reg, err := d.registry.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := NewClient(reg.Addr)
+	status, err := client.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ms, err := client.Metrics(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeTopology(status, ms.Metrics)) //nolint:errcheck // response write error
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopology(t *testing.T) {
+	counts := codegen.MethodCounts.Name()
+	errors := codegen.MethodErrors.Name()
+	status := &Status{
+		Components: []*Component{
+			{Name: "b/B", Group: "a/A", Pids: []int64{1, 2}},
+			{Name: "a/A", Group: "a/A", Pids: []int64{1, 2}},
+			{Name: "c/C", Group: "c/C", Pids: []int64{3}},
+		},
+	}
+	got := computeTopology(status, []*protos.MetricSnapshot{
+		// Calls to two methods of A, from two replicas of main.
+		methodMetric(counts, "main", "a/A", "Foo", 10),
+		methodMetric(counts, "main", "a/A", "Foo", 5),
+		methodMetric(counts, "main", "a/A", "Bar", 1),
+		methodMetric(errors, "main", "a/A", "Bar", 1),
+		methodMetric(counts, "a/A", "b/B", "Baz", 3),
+		// Unrelated metric.
+		methodMetric("other", "a/A", "c/C", "Foo", 100),
+	})
+	want := topology{
+		Components: []topologyNode{
+			{Name: "a/A", Group: "a/A", Replicas: 2},
+			{Name: "b/B", Group: "a/A", Replicas: 2},
+			{Name: "c/C", Group: "c/C", Replicas: 1},
+			{Name: "main"},
+		},
+		Edges: []topologyEdge{
+			{Caller: "a/A", Component: "b/B", Calls: 3},
+			{Caller: "main", Component: "a/A", Calls: 16, Errors: 1},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("computeTopology (-want +got):\n%s", diff)
+	}
+}
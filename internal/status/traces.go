@@ -0,0 +1,145 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+)
+
+// A spanRow is a row of the flame view of a trace on the dashboard.
+type spanRow struct {
+	perfetto.Span
+	Depth int     // depth of the span in the trace; roots have depth 0
+	Left  float64 // start of the span, in percent of the trace's extent
+	Width float64 // duration of the span, in percent of the trace's extent
+}
+
+// layoutTrace returns the rows of the flame view of a trace with the provided
+// spans, ordered by start time. Every span is followed by its descendants, so
+// that the rows form a tree, and is indented by its depth. The bars of the
+// spans share the time axis of the trace.
+func layoutTrace(spans []perfetto.Span) []spanRow {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// Compute the extent of the trace.
+	start, end := spans[0].Start, spans[0].Start
+	ids := map[string]bool{}
+	for _, s := range spans {
+		if s.Start.Before(start) {
+			start = s.Start
+		}
+		if e := s.Start.Add(s.Duration); e.After(end) {
+			end = e
+		}
+		ids[s.SpanID] = true
+	}
+	extent := end.Sub(start)
+	percent := func(d time.Duration) float64 {
+		if extent <= 0 {
+			return 0
+		}
+		return 100 * float64(d) / float64(extent)
+	}
+
+	// Spans whose parent is missing, e.g., because it was recorded by a
+	// process that didn't export it, are displayed as roots.
+	children := map[string][]perfetto.Span{}
+	var roots []perfetto.Span
+	for _, s := range spans {
+		if ids[s.ParentSpanID] {
+			children[s.ParentSpanID] = append(children[s.ParentSpanID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	rows := make([]spanRow, 0, len(spans))
+	var visit func(s perfetto.Span, depth int)
+	visit = func(s perfetto.Span, depth int) {
+		width := percent(s.Duration)
+		if extent <= 0 {
+			width = 100
+		}
+		rows = append(rows, spanRow{
+			Span:  s,
+			Depth: depth,
+			Left:  percent(s.Start.Sub(start)),
+			Width: width,
+		})
+		for _, child := range children[s.SpanID] {
+			visit(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		visit(root, 0)
+	}
+	return rows
+}
+
+// A latencyBar is a bar of the latency histogram of a method on the
+// dashboard.
+type latencyBar struct {
+	Label  string        // e.g., "≤ 10ms"
+	Min    time.Duration // lower bound of the latencies counted by the bar
+	Count  int           // number of calls counted by the bar
+	Height float64       // height of the bar, in percent of the tallest bar
+}
+
+// latencyBars returns the bars of the latency histogram of the provided
+// method.
+func latencyBars(l perfetto.MethodLatency) []latencyBar {
+	tallest := 0
+	for _, count := range l.Counts {
+		if count > tallest {
+			tallest = count
+		}
+	}
+	bars := make([]latencyBar, len(l.Counts))
+	for i, count := range l.Counts {
+		var bar latencyBar
+		if i > 0 {
+			bar.Min = perfetto.LatencyBounds[i-1]
+		}
+		if i < len(perfetto.LatencyBounds) {
+			bar.Label = fmt.Sprintf("≤ %v", perfetto.LatencyBounds[i])
+		} else {
+			bar.Label = fmt.Sprintf("> %v", bar.Min)
+		}
+		bar.Count = count
+		if tallest > 0 {
+			bar.Height = 100 * float64(count) / float64(tallest)
+		}
+		bars[i] = bar
+	}
+	return bars
+}
+
+// formatDuration formats the provided duration, at a precision suitable for
+// the dashboard.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLayoutTrace(t *testing.T) {
+	start := time.Now()
+	span := func(id, parent string, offset, dur time.Duration) perfetto.Span {
+		return perfetto.Span{
+			SpanID:       id,
+			ParentSpanID: parent,
+			Name:         id,
+			Start:        start.Add(offset),
+			Duration:     dur,
+		}
+	}
+	spans := []perfetto.Span{
+		span("root", "none", 0, 100*time.Millisecond),
+		span("a", "root", 10*time.Millisecond, 50*time.Millisecond),
+		span("b", "root", 20*time.Millisecond, 70*time.Millisecond),
+		span("a1", "a", 30*time.Millisecond, 10*time.Millisecond),
+		span("orphan", "missing", 50*time.Millisecond, 25*time.Millisecond),
+	}
+
+	type row struct {
+		Name        string
+		Depth       int
+		Left, Width float64
+	}
+	var got []row
+	for _, r := range layoutTrace(spans) {
+		got = append(got, row{r.Name, r.Depth, r.Left, r.Width})
+	}
+	want := []row{
+		{"root", 0, 0, 100},
+		{"a", 1, 10, 50},
+		{"a1", 2, 30, 10},
+		{"b", 1, 20, 70},
+		{"orphan", 0, 50, 25},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("layoutTrace (-want +got):\n%s", diff)
+	}
+}
+
+func TestTraceTemplates(t *testing.T) {
+	// Test Plan: render the traces and trace pages with fake content, to
+	// catch errors in the templates.
+	latency := perfetto.MethodLatency{
+		Component: "shop.Checkout",
+		Method:    "PlaceOrder",
+		Calls:     3,
+		Counts:    make([]int, len(perfetto.LatencyBounds)+1),
+	}
+	latency.Counts[2] = 2
+	latency.Counts[len(perfetto.LatencyBounds)] = 1
+	root := perfetto.Span{TraceID: "t", SpanID: "s", Name: "shop.Checkout.PlaceOrder", Kind: "server", Start: time.Now(), Duration: time.Second}
+	if err := tracesTemplate.Execute(io.Discard, map[string]any{
+		"Tool":         "weaver multi",
+		"App":          "shop",
+		"DeploymentId": "1234",
+		"Latencies":    []perfetto.MethodLatency{latency},
+		"Traces":       []perfetto.TraceSummary{{TraceID: "t", Root: root, Match: root, Duration: time.Second, Spans: 1}},
+		"Limit":        perfetto.DefaultTraceLimit,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := traceTemplate.Execute(io.Discard, map[string]any{
+		"Tool":         "weaver multi",
+		"App":          "shop",
+		"DeploymentId": "1234",
+		"TraceID":      "t",
+		"Rows":         layoutTrace([]perfetto.Span{root}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aws implements the "weaver aws" deployer, which deploys Service
+// Weaver applications to Amazon ECS on AWS Fargate.
+//
+// "weaver aws deploy" creates a target group of an Application Load Balancer
+// for every public listener, and an ECS service that runs a manager, which
+// the target groups send traffic to. The manager creates a service for every
+// colocation group, whose tasks run a babysitter and a weavelet and assume
+// the group's IAM role, and proxies the application's listeners. The manager
+// and babysitters speak the same protocol as the ssh deployer's manager and
+// babysitters. Logs are sent to CloudWatch Logs, and metrics to CloudWatch.
+package aws
+
+import (
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var Commands = map[string]*tool.Command{
+	"deploy":  &deployCmd,
+	"version": tool.VersionCmd("weaver aws"),
+
+	// Hidden commands.
+	"manager":    &managerCmd,
+	"babysitter": &babysitterCmd,
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client is a minimal client of the AWS APIs used by the aws deployer. It
+// speaks the two protocols these APIs use: the JSON protocol of ECS, and the
+// query protocol of Elastic Load Balancing and CloudWatch.
+type client struct {
+	region string
+	client *http.Client
+
+	mu    sync.Mutex   // guards creds
+	creds *credentials // cached credentials, or nil
+}
+
+// apiError is an error returned by an AWS API.
+type apiError struct {
+	service string // e.g., "ecs"
+	action  string // e.g., "CreateService"
+	code    string // error code, e.g., "InvalidParameterException"
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.service, e.action, e.code, e.message)
+}
+
+// isCode returns whether err is an apiError with the provided code.
+func isCode(err error, code string) bool {
+	e, ok := err.(*apiError)
+	return ok && e.code == code
+}
+
+func newClient(region string) *client {
+	return &client{region: region, client: &http.Client{}}
+}
+
+// credentials returns the cached credentials, loading new ones if they are
+// about to expire.
+func (c *client) credentials(ctx context.Context) (*credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.creds != nil && (c.creds.expires.IsZero() || time.Until(c.creds.expires) > 5*time.Minute) {
+		return c.creds, nil
+	}
+	creds, err := loadCredentials(ctx, c.client)
+	if err != nil {
+		return nil, err
+	}
+	c.creds = creds
+	return creds, nil
+}
+
+// post sends a signed POST request with the provided body and content type
+// to the regional endpoint of the provided service, and returns the status
+// code and body of the response.
+func (c *client) post(ctx context.Context, service string, headers map[string]string, body []byte) (int, []byte, error) {
+	creds, err := c.credentials(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	endpoint := fmt.Sprintf("https://%s.%s.amazonaws.com/", service, c.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	sign(req, body, creds, service, c.region, time.Now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// callJSON invokes an action of an API that uses the JSON protocol, such as
+// ECS, with in as the request and out, if not nil, as the response.
+func (c *client) callJSON(ctx context.Context, service, targetPrefix, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	code, data, err := c.post(ctx, service, map[string]string{
+		"Content-Type": "application/x-amz-json-1.1",
+		"X-Amz-Target": targetPrefix + "." + action,
+	}, body)
+	if err != nil {
+		return err
+	}
+	if code/100 != 2 {
+		var e struct {
+			Type        string `json:"__type"`
+			Message     string `json:"message"`
+			MessageCaps string `json:"Message"`
+		}
+		json.Unmarshal(data, &e) //nolint:errcheck // best effort
+		if e.Message == "" {
+			e.Message = e.MessageCaps
+		}
+		// The type may be prefixed with a namespace, e.g.,
+		// "com.amazonaws.ecs#ClientException".
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return &apiError{service: service, action: action, code: e.Type, message: e.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// callQuery invokes an action of an API that uses the query protocol, such
+// as Elastic Load Balancing and CloudWatch, with params as the request
+// parameters and out, if not nil, as the XML response.
+func (c *client) callQuery(ctx context.Context, service, version, action string, params url.Values, out any) error {
+	form := url.Values{}
+	for name, values := range params {
+		form[name] = values
+	}
+	form.Set("Action", action)
+	form.Set("Version", version)
+	code, data, err := c.post(ctx, service, map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
+	}, []byte(form.Encode()))
+	if err != nil {
+		return err
+	}
+	if code/100 != 2 {
+		var e struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}
+		xml.Unmarshal(data, &e) //nolint:errcheck // best effort
+		return &apiError{service: service, action: action, code: e.Error.Code, message: e.Error.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
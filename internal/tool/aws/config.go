@@ -0,0 +1,217 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// awsKey and shortAWSKey are the keys of the aws section of the config.
+	awsKey      = "github.com/ServiceWeaver/weaver/aws"
+	shortAWSKey = "aws"
+
+	// Default values of the aws config.
+	defaultTool             = "weaver"
+	defaultReplicas         = 2
+	defaultCPU              = 256
+	defaultMemory           = 512
+	defaultMetricsNamespace = "ServiceWeaver"
+)
+
+// config is the aws section of an application config, e.g.:
+//
+//	[aws]
+//	region = "us-east-1"
+//	cluster = "my-cluster"
+//	image = "123456789012.dkr.ecr.us-east-1.amazonaws.com/my-app:v1"
+//	subnets = ["subnet-0123456789abcdef0"]
+//	security_groups = ["sg-0123456789abcdef0"]
+//	execution_role = "arn:aws:iam::123456789012:role/ecsTaskExecutionRole"
+//	manager_role = "arn:aws:iam::123456789012:role/weaver-manager"
+//	roles = {"github.com/my/app/Cart" = "arn:aws:iam::123456789012:role/cart"}
+//	load_balancer = "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/my-alb/0123456789abcdef"
+//	vpc = "vpc-0123456789abcdef0"
+//	listeners = {hello = 80}
+type config struct {
+	// Region is the AWS region in which the application is deployed.
+	// Defaults to $AWS_REGION, or $AWS_DEFAULT_REGION.
+	Region string `toml:"region"`
+
+	// Cluster is the name of the ECS cluster in which the application is
+	// deployed.
+	Cluster string `toml:"cluster"`
+
+	// Image is the container image that runs the application. The image must
+	// contain the application binary, at the path given by the binary field
+	// of the [serviceweaver] section, and the weaver tool.
+	Image string `toml:"image"`
+
+	// Tool is the path of the weaver tool in the image. Defaults to "weaver",
+	// i.e., the weaver tool is looked up in $PATH.
+	Tool string `toml:"tool"`
+
+	// Subnets and SecurityGroups configure the network of the Fargate
+	// tasks. The security groups must allow the tasks of a deployment to
+	// reach each other, and the load balancer to reach the manager.
+	// AssignPublicIP gives the tasks public IP addresses, which they need to
+	// pull images and reach AWS APIs from public subnets without a NAT
+	// gateway.
+	Subnets        []string `toml:"subnets"`
+	SecurityGroups []string `toml:"security_groups"`
+	AssignPublicIP bool     `toml:"assign_public_ip"`
+
+	// ExecutionRole is the ARN of the IAM role that ECS uses to pull the
+	// image and send the logs of the tasks to CloudWatch Logs.
+	ExecutionRole string `toml:"execution_role"`
+
+	// ManagerRole is the ARN of the IAM role of the manager, which must be
+	// allowed to register task definitions, create services, pass the roles
+	// of the colocation groups, and put metric data.
+	ManagerRole string `toml:"manager_role"`
+
+	// Roles maps the full name of a component to the ARN of the IAM role
+	// that the replicas of its colocation group assume. TaskRole, if not
+	// empty, is the role of the colocation groups without a role in Roles.
+	Roles    map[string]string `toml:"roles"`
+	TaskRole string            `toml:"task_role"`
+
+	// Replicas is the number of replicas of every colocation group. Defaults
+	// to 2.
+	Replicas int `toml:"replicas"`
+
+	// CPU, in CPU units, and Memory, in MiB, are the resources of every
+	// Fargate task. Default to 256 and 512.
+	CPU    int `toml:"cpu"`
+	Memory int `toml:"memory"`
+
+	// LoadBalancer is the ARN of the Application Load Balancer that fronts
+	// the listeners in Listeners, which are served on the provided ports.
+	// Vpc is the id of the VPC of the load balancer.
+	LoadBalancer string         `toml:"load_balancer"`
+	Vpc          string         `toml:"vpc"`
+	Listeners    map[string]int `toml:"listeners"`
+
+	// LogGroup is the CloudWatch Logs log group that receives the logs of the
+	// application. Defaults to "/serviceweaver/<app name>".
+	LogGroup string `toml:"log_group"`
+
+	// MetricsNamespace is the CloudWatch namespace of the application's
+	// metrics. Defaults to "ServiceWeaver".
+	MetricsNamespace string `toml:"metrics_namespace"`
+}
+
+// Validate implements the runtime.ParseConfigSection validation hook.
+func (c *config) Validate() error {
+	switch {
+	case c.Cluster == "":
+		return fmt.Errorf("no cluster provided")
+	case c.Image == "":
+		return fmt.Errorf("no image provided")
+	case len(c.Subnets) == 0:
+		return fmt.Errorf("no subnets provided")
+	case c.ExecutionRole == "":
+		return fmt.Errorf("no execution_role provided")
+	case c.ManagerRole == "":
+		return fmt.Errorf("no manager_role provided")
+	}
+	if c.Replicas < 0 {
+		return fmt.Errorf("negative replicas %d", c.Replicas)
+	}
+	if c.CPU < 0 {
+		return fmt.Errorf("negative cpu %d", c.CPU)
+	}
+	if c.Memory < 0 {
+		return fmt.Errorf("negative memory %d", c.Memory)
+	}
+	for component, role := range c.Roles {
+		if role == "" {
+			return fmt.Errorf("component %q: empty role", component)
+		}
+	}
+	if len(c.Listeners) > 0 && (c.LoadBalancer == "" || c.Vpc == "") {
+		return fmt.Errorf("listeners require a load_balancer and a vpc")
+	}
+	for name, port := range c.Listeners {
+		if port <= 0 || port > 65535 || port == managerPort {
+			return fmt.Errorf("listener %q: invalid port %d", name, port)
+		}
+	}
+	return nil
+}
+
+// loadConfig returns the aws config of the provided application, with
+// defaults filled in.
+func loadConfig(app *protos.AppConfig) (*config, error) {
+	c := &config{}
+	_, long := app.Sections[awsKey]
+	_, short := app.Sections[shortAWSKey]
+	if !long && !short {
+		return nil, fmt.Errorf("unable to parse aws config: no [aws] section")
+	}
+	if err := runtime.ParseConfigSection(awsKey, shortAWSKey, app.Sections, c); err != nil {
+		return nil, fmt.Errorf("unable to parse aws config: %w", err)
+	}
+	if c.Region == "" {
+		c.Region = os.Getenv("AWS_REGION")
+	}
+	if c.Region == "" {
+		c.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.Region == "" {
+		return nil, fmt.Errorf("unable to parse aws config: no region provided")
+	}
+	if c.Tool == "" {
+		c.Tool = defaultTool
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultReplicas
+	}
+	if c.CPU == 0 {
+		c.CPU = defaultCPU
+	}
+	if c.Memory == 0 {
+		c.Memory = defaultMemory
+	}
+	if c.LogGroup == "" {
+		c.LogGroup = "/serviceweaver/" + app.Name
+	}
+	if c.MetricsNamespace == "" {
+		c.MetricsNamespace = defaultMetricsNamespace
+	}
+	return c, nil
+}
+
+// CheckConfig checks the aws section of the provided application config, if
+// it has one. It is used by "weaver config check".
+func CheckConfig(app *protos.AppConfig) error {
+	_, long := app.Sections[awsKey]
+	_, short := app.Sections[shortAWSKey]
+	if !long && !short {
+		return nil
+	}
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return err
+	}
+	if _, err := groupRoles(app, cfg); err != nil {
+		return fmt.Errorf("unable to parse aws config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var deployCmd = tool.Command{
+	Name:        "deploy",
+	Description: "Deploy a Service Weaver app to Amazon ECS on AWS Fargate",
+	Help: `Usage:
+  weaver aws deploy [--dry-run] <configfile>
+
+Flags:
+  -h, --help   Print this help message.
+  --dry-run    Print the colocation groups, replicas, and listeners of
+               the deployment, instead of deploying it (default false)
+
+Description:
+  "weaver aws deploy" deploys the application to an ECS cluster, on AWS
+  Fargate. It runs a manager as an ECS service, which creates a service for
+  every colocation group of the application as its components are
+  activated. AWS credentials are read from the environment, or from the
+  shared credentials file.
+
+  The application is configured in the config file's [aws] section:
+
+      [aws]
+      region = "us-east-1"                 # defaults to $AWS_REGION
+      cluster = "my-cluster"               # required
+      image = "<account>.dkr.ecr.us-east-1.amazonaws.com/my-app:v1"  # required
+      tool = "/weaver"                     # the weaver binary in the image
+      subnets = ["subnet-..."]             # required
+      security_groups = ["sg-..."]
+      execution_role = "arn:aws:iam::..."  # required
+      manager_role = "arn:aws:iam::..."    # required
+      task_role = "arn:aws:iam::..."       # role of the colocation groups
+      roles = {"github.com/my/app/Cart" = "arn:aws:iam::..."}
+      replicas = 2                         # replicas per colocation group
+      load_balancer = "arn:aws:elasticloadbalancing:..."
+      vpc = "vpc-..."                      # the VPC of the load balancer
+      listeners = {hello = 80}             # listeners fronted, by port
+
+  The image must contain both the application binary, at the path given by
+  the binary field of the [serviceweaver] section, and the weaver binary.
+  Every resource of a deployment is tagged with its id; delete the services
+  of a deployment with:
+
+      aws ecs delete-service --force --cluster <cluster> --service <name>`,
+	Flags: deployFlags,
+	Fn:    deploy,
+}
+
+var (
+	deployFlags  = flag.NewFlagSet("deploy", flag.ContinueOnError)
+	deployDryRun = deployFlags.Bool("dry-run", false, "Print the deployment plan without deploying")
+)
+
+// deploy deploys an application to ECS.
+func deploy(ctx context.Context, args []string) error {
+	// Validate command line arguments.
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	// Load the config file.
+	cfgFile := args[0]
+	contents, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	app, err := runtime.ParseConfig(cfgFile, string(contents), codegen.ComponentConfigValidator)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return err
+	}
+	if _, err := groupRoles(app, cfg); err != nil {
+		return fmt.Errorf("unable to parse aws config: %w", err)
+	}
+
+	// Print the deployment plan instead, if requested.
+	if *deployDryRun {
+		return dryRun(ctx, app, cfg)
+	}
+
+	dep := &protos.Deployment{
+		Id:  uuid.New().String(),
+		App: app,
+	}
+	aws := newClient(cfg.Region)
+
+	// Front the listeners with the load balancer. The target groups must be
+	// attached to the load balancer before the manager service can use them.
+	targetGroups := map[string]string{}
+	for _, l := range sortedListeners(cfg) {
+		arn, err := aws.createTargetGroup(ctx, dep, cfg, l)
+		if err != nil {
+			return fmt.Errorf("listener %q: %w", l, err)
+		}
+		if err := aws.forward(ctx, cfg.LoadBalancer, cfg.Listeners[l], arn); err != nil {
+			return fmt.Errorf("listener %q: %w", l, err)
+		}
+		targetGroups[l] = arn
+	}
+
+	// Run the manager.
+	td, err := managerTaskDefinition(dep, cfg)
+	if err != nil {
+		return err
+	}
+	arn, err := aws.registerTaskDefinition(ctx, td)
+	if err != nil {
+		return err
+	}
+	if err := aws.createService(ctx, managerService(dep, cfg, arn, targetGroups)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Deployed deployment %s of app %s\n", dep.Id, app.Name)
+	fmt.Fprintf(os.Stderr, "Follow its logs with:\n\n    aws logs tail %s --follow --log-stream-name-prefix %s\n", cfg.LogGroup, logging.Shorten(dep.Id))
+	return nil
+}
+
+// dryRun prints the plan of deploying the provided app to ECS, without
+// deploying it. The application binary must run on the local machine.
+func dryRun(ctx context.Context, app *protos.AppConfig, cfg *config) error {
+	components, err := plan.Components(ctx, app)
+	if err != nil {
+		return err
+	}
+	p := &plan.Plan{
+		App:          app.Name,
+		Deployer:     "weaver aws",
+		Rollout:      "new ECS services; the load balancer's listeners switch to the new deployment",
+		Groups:       plan.Groups(app, components, plan.Replicas(app, cfg.Replicas, nil)),
+		Config:       app,
+		Listeners:    []plan.Listener{},
+		ListenerNote: "Other listeners are only reachable from within the manager's task.",
+	}
+	for _, l := range sortedListeners(cfg) {
+		p.Listeners = append(p.Listeners, plan.Listener{
+			Name:    l,
+			Address: fmt.Sprintf("port %d of the load balancer", cfg.Listeners[l]),
+		})
+	}
+	plan.Write(os.Stdout, p, nil)
+	fmt.Printf("Running deployments are not compared; list them with:\n\n    aws ecs list-services --cluster %s\n", cfg.Cluster)
+	return nil
+}
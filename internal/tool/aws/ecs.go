@@ -0,0 +1,371 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ServiceWeaver/weaver/internal/proto"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// This file contains the subset of the ECS API types used by the aws
+// deployer, along with the functions that build the task definitions and
+// services of a deployment. We define the types ourselves, rather than depend
+// on the AWS SDK, to keep the weaver tool's dependencies small.
+
+const (
+	// managerPort is the port the manager listens on.
+	managerPort = 8000
+
+	// Tags attached to every resource of a deployment.
+	appTag        = "serviceweaver:app"
+	deploymentTag = "serviceweaver:deployment"
+	groupTag      = "serviceweaver:group"
+
+	// deploymentKey is the environment variable that passes the deployment
+	// to the manager.
+	deploymentKey = "SERVICEWEAVER_DEPLOYMENT"
+
+	// ecsTarget is the prefix of the X-Amz-Target header of ECS requests.
+	ecsTarget = "AmazonEC2ContainerServiceV20141113"
+)
+
+type taskDefinition struct {
+	Family                  string                `json:"family"`
+	TaskRoleArn             string                `json:"taskRoleArn,omitempty"`
+	ExecutionRoleArn        string                `json:"executionRoleArn"`
+	NetworkMode             string                `json:"networkMode"`
+	RequiresCompatibilities []string              `json:"requiresCompatibilities"`
+	CPU                     string                `json:"cpu"`
+	Memory                  string                `json:"memory"`
+	ContainerDefinitions    []containerDefinition `json:"containerDefinitions"`
+	Tags                    []tag                 `json:"tags,omitempty"`
+}
+
+type containerDefinition struct {
+	Name             string            `json:"name"`
+	Image            string            `json:"image"`
+	Essential        bool              `json:"essential"`
+	Command          []string          `json:"command"`
+	Environment      []keyValuePair    `json:"environment,omitempty"`
+	PortMappings     []portMapping     `json:"portMappings,omitempty"`
+	LogConfiguration *logConfiguration `json:"logConfiguration,omitempty"`
+}
+
+type keyValuePair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type portMapping struct {
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type logConfiguration struct {
+	LogDriver string            `json:"logDriver"`
+	Options   map[string]string `json:"options"`
+}
+
+type tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type service struct {
+	Cluster                       string               `json:"cluster"`
+	ServiceName                   string               `json:"serviceName"`
+	TaskDefinition                string               `json:"taskDefinition"`
+	DesiredCount                  int                  `json:"desiredCount"`
+	LaunchType                    string               `json:"launchType"`
+	NetworkConfiguration          networkConfiguration `json:"networkConfiguration"`
+	LoadBalancers                 []loadBalancer       `json:"loadBalancers,omitempty"`
+	HealthCheckGracePeriodSeconds int                  `json:"healthCheckGracePeriodSeconds,omitempty"`
+	Tags                          []tag                `json:"tags,omitempty"`
+	PropagateTags                 string               `json:"propagateTags"`
+	ClientToken                   string               `json:"clientToken"`
+}
+
+type networkConfiguration struct {
+	AwsvpcConfiguration awsvpcConfiguration `json:"awsvpcConfiguration"`
+}
+
+type awsvpcConfiguration struct {
+	Subnets        []string `json:"subnets"`
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	AssignPublicIp string   `json:"assignPublicIp"`
+}
+
+type loadBalancer struct {
+	TargetGroupArn string `json:"targetGroupArn"`
+	ContainerName  string `json:"containerName"`
+	ContainerPort  int    `json:"containerPort"`
+}
+
+// registerTaskDefinition registers the provided task definition, and returns
+// the ARN of its new revision.
+func (c *client) registerTaskDefinition(ctx context.Context, td *taskDefinition) (string, error) {
+	var out struct {
+		TaskDefinition struct {
+			TaskDefinitionArn string `json:"taskDefinitionArn"`
+		} `json:"taskDefinition"`
+	}
+	if err := c.callJSON(ctx, "ecs", ecsTarget, "RegisterTaskDefinition", td, &out); err != nil {
+		return "", err
+	}
+	return out.TaskDefinition.TaskDefinitionArn, nil
+}
+
+// createService creates the provided service. Creating a service again with
+// the same parameters and client token is not an error.
+func (c *client) createService(ctx context.Context, s *service) error {
+	return c.callJSON(ctx, "ecs", ecsTarget, "CreateService", s, nil)
+}
+
+// invalidNameChars matches runs of characters that may not appear in the
+// names of ECS services, task definition families, and target groups.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// name returns a name of at most maxLen characters formed from the provided
+// parts, which contains only lowercase letters, digits, and dashes. Longer
+// names are truncated and suffixed with a hash of the full name to keep them
+// unique.
+func name(maxLen int, parts ...string) string {
+	s := strings.ToLower(strings.Join(parts, "-"))
+	s = invalidNameChars.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	if len(s) <= maxLen {
+		return s
+	}
+	h := fnv.New32a()
+	h.Write([]byte(s)) //nolint:errcheck // hash.Hash.Write never fails
+	suffix := fmt.Sprintf("%08x", h.Sum32())
+	return strings.TrimRight(s[:maxLen-len(suffix)-1], "-") + "-" + suffix
+}
+
+// managerName returns the name of the service and task definition family of
+// the manager of a deployment.
+func managerName(dep *protos.Deployment) string {
+	return name(255, dep.App.Name, logging.Shorten(dep.Id), "manager")
+}
+
+// groupName returns the name of the service and task definition family that
+// run a colocation group.
+func groupName(dep *protos.Deployment, group string) string {
+	return name(255, dep.App.Name, logging.Shorten(dep.Id), logging.ShortenComponent(group))
+}
+
+// targetGroupName returns the name of the target group of a listener. Target
+// group names have at most 32 characters.
+func targetGroupName(dep *protos.Deployment, listener string) string {
+	return name(32, dep.App.Name, logging.Shorten(dep.Id), listener)
+}
+
+// tags returns the tags attached to the resources of a deployment.
+func tags(dep *protos.Deployment, group string) []tag {
+	return []tag{
+		{Key: appTag, Value: dep.App.Name},
+		{Key: deploymentTag, Value: dep.Id},
+		{Key: groupTag, Value: group},
+	}
+}
+
+// groupRoles returns the IAM roles of the colocation groups that have one in
+// the roles of the config, by group name.
+func groupRoles(app *protos.AppConfig, cfg *config) (map[string]string, error) {
+	colocation := map[string]string{}
+	for _, group := range app.Colocate {
+		for _, c := range group.Components {
+			colocation[c] = group.Components[0]
+		}
+	}
+	roles := map[string]string{}
+	by := map[string]string{} // the component that set the role of a group
+	components := maps.Keys(cfg.Roles)
+	slices.Sort(components)
+	for _, c := range components {
+		group, ok := colocation[c]
+		if !ok {
+			group = c
+		}
+		if role, ok := roles[group]; ok && role != cfg.Roles[c] {
+			return nil, fmt.Errorf("components %q and %q are colocated but have different roles", by[group], c)
+		}
+		roles[group] = cfg.Roles[c]
+		by[group] = c
+	}
+	return roles, nil
+}
+
+// logConfig returns the configuration that sends the logs of a container to
+// CloudWatch Logs.
+func logConfig(dep *protos.Deployment, cfg *config) *logConfiguration {
+	return &logConfiguration{
+		LogDriver: "awslogs",
+		Options: map[string]string{
+			"awslogs-group":         cfg.LogGroup,
+			"awslogs-region":        cfg.Region,
+			"awslogs-stream-prefix": logging.Shorten(dep.Id),
+			"awslogs-create-group":  "true",
+		},
+	}
+}
+
+// taskDef returns a Fargate task definition with a single container.
+func taskDef(family, role string, cfg *config, c containerDefinition, tags []tag) *taskDefinition {
+	return &taskDefinition{
+		Family:                  family,
+		TaskRoleArn:             role,
+		ExecutionRoleArn:        cfg.ExecutionRole,
+		NetworkMode:             "awsvpc",
+		RequiresCompatibilities: []string{"FARGATE"},
+		CPU:                     fmt.Sprint(cfg.CPU),
+		Memory:                  fmt.Sprint(cfg.Memory),
+		ContainerDefinitions:    []containerDefinition{c},
+		Tags:                    tags,
+	}
+}
+
+// managerTaskDefinition returns the task definition of the manager of a
+// deployment.
+func managerTaskDefinition(dep *protos.Deployment, cfg *config) (*taskDefinition, error) {
+	encoded, err := encodeDeployment(dep)
+	if err != nil {
+		return nil, err
+	}
+	ports := []portMapping{{ContainerPort: managerPort, Protocol: "tcp"}}
+	for _, l := range sortedListeners(cfg) {
+		ports = append(ports, portMapping{ContainerPort: cfg.Listeners[l], Protocol: "tcp"})
+	}
+	c := containerDefinition{
+		Name:             "manager",
+		Image:            cfg.Image,
+		Essential:        true,
+		Command:          []string{cfg.Tool, "aws", "manager"},
+		Environment:      []keyValuePair{{Name: deploymentKey, Value: encoded}},
+		PortMappings:     ports,
+		LogConfiguration: logConfig(dep, cfg),
+	}
+	return taskDef(managerName(dep), cfg.ManagerRole, cfg, c, tags(dep, "manager")), nil
+}
+
+// groupTaskDefinition returns the task definition of the replicas of the
+// colocation group described by info, which assume the provided IAM role.
+func groupTaskDefinition(cfg *config, info *impl.BabysitterInfo, role string) (*taskDefinition, error) {
+	env, err := impl.BabysitterEnv(info)
+	if err != nil {
+		return nil, err
+	}
+	key, value, _ := strings.Cut(env, "=")
+	dep := info.Deployment
+	c := containerDefinition{
+		Name:             "babysitter",
+		Image:            cfg.Image,
+		Essential:        true,
+		Command:          []string{cfg.Tool, "aws", "babysitter"},
+		Environment:      []keyValuePair{{Name: key, Value: value}},
+		LogConfiguration: logConfig(dep, cfg),
+	}
+	return taskDef(groupName(dep, info.Group), role, cfg, c, tags(dep, info.Group)), nil
+}
+
+// newService returns a Fargate service with the provided name, which runs
+// the provided number of tasks of the provided task definition.
+func newService(cfg *config, name, taskDefinition string, replicas int, tags []tag) *service {
+	publicIP := "DISABLED"
+	if cfg.AssignPublicIP {
+		publicIP = "ENABLED"
+	}
+	return &service{
+		Cluster:        cfg.Cluster,
+		ServiceName:    name,
+		TaskDefinition: taskDefinition,
+		DesiredCount:   replicas,
+		LaunchType:     "FARGATE",
+		NetworkConfiguration: networkConfiguration{
+			AwsvpcConfiguration: awsvpcConfiguration{
+				Subnets:        cfg.Subnets,
+				SecurityGroups: cfg.SecurityGroups,
+				AssignPublicIp: publicIP,
+			},
+		},
+		Tags:          tags,
+		PropagateTags: "SERVICE",
+		// A client token derived from the name makes creating the same
+		// service twice, e.g., by a restarted manager, idempotent.
+		ClientToken: uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String(),
+	}
+}
+
+// managerService returns the service that runs the manager of a deployment,
+// which receives the traffic of the listeners' target groups, by listener.
+func managerService(dep *protos.Deployment, cfg *config, taskDefinition string, targetGroups map[string]string) *service {
+	s := newService(cfg, managerName(dep), taskDefinition, 1, tags(dep, "manager"))
+	for _, l := range sortedListeners(cfg) {
+		s.LoadBalancers = append(s.LoadBalancers, loadBalancer{
+			TargetGroupArn: targetGroups[l],
+			ContainerName:  "manager",
+			ContainerPort:  cfg.Listeners[l],
+		})
+	}
+	if len(s.LoadBalancers) > 0 {
+		s.HealthCheckGracePeriodSeconds = 60
+	}
+	return s
+}
+
+// groupService returns the service that runs the replicas of the colocation
+// group described by info.
+func groupService(cfg *config, info *impl.BabysitterInfo, taskDefinition string) *service {
+	dep := info.Deployment
+	return newService(cfg, groupName(dep, info.Group), taskDefinition, cfg.Replicas, tags(dep, info.Group))
+}
+
+// sortedListeners returns the names of the listeners fronted by the load
+// balancer, in sorted order.
+func sortedListeners(cfg *config) []string {
+	listeners := maps.Keys(cfg.Listeners)
+	slices.Sort(listeners)
+	return listeners
+}
+
+// encodeDeployment encodes a deployment into a string that can be passed to
+// the manager in the environment.
+func encodeDeployment(dep *protos.Deployment) (string, error) {
+	return proto.ToEnv(dep)
+}
+
+// decodeDeployment decodes a deployment encoded by encodeDeployment.
+func decodeDeployment(s string) (*protos.Deployment, error) {
+	dep := &protos.Deployment{}
+	if err := proto.FromEnv(s, dep); err != nil {
+		return nil, err
+	}
+	if dep.App == nil {
+		return nil, fmt.Errorf("invalid deployment")
+	}
+	return dep, nil
+}
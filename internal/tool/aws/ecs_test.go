@@ -0,0 +1,225 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/internal/tool/tooltest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testConfig = `
+[serviceweaver]
+name = "Hello_App"
+binary = "/app/hello"
+colocate = [["github.com/example/hello/Reverser", "github.com/example/hello/Cache"]]
+
+[aws]
+region = "us-west-2"
+cluster = "weaver"
+image = "example.com/hello:v1"
+subnets = ["subnet-a", "subnet-b"]
+execution_role = "arn:aws:iam::1:role/exec"
+manager_role = "arn:aws:iam::1:role/manager"
+task_role = "arn:aws:iam::1:role/default"
+roles = {"github.com/example/hello/Cache" = "arn:aws:iam::1:role/cache"}
+replicas = 3
+load_balancer = "arn:aws:elasticloadbalancing:us-west-2:1:loadbalancer/app/hello/1"
+vpc = "vpc-a"
+listeners = {hello = 80, admin = 9000}
+`
+
+// required holds the required fields of the aws section.
+const required = `
+region = "r"
+cluster = "c"
+image = "i"
+subnets = ["s"]
+execution_role = "e"
+manager_role = "m"
+`
+
+func TestName(t *testing.T) {
+	for _, test := range []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"hello"}, "hello"},
+		{[]string{"Hello_App", "01234567", "main"}, "hello-app-01234567-main"},
+		{[]string{"--a--", "b"}, "a-b"},
+	} {
+		if got := name(63, test.parts...); got != test.want {
+			t.Errorf("name(%q): got %q, want %q", test.parts, got, test.want)
+		}
+	}
+
+	long := name(32, strings.Repeat("a", 100))
+	if len(long) != 32 {
+		t.Errorf("name(32, <100 a's>): got %d characters, want 32", len(long))
+	}
+	if other := name(32, strings.Repeat("a", 101)); other == long {
+		t.Errorf("name(32, <100 a's>) == name(32, <101 a's>) == %q", long)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		section string
+		want    string
+	}{
+		{"NoSection", "", "no [aws] section"},
+		{"NoCluster", "[aws]\nimage = \"i\"", "no cluster"},
+		{"NoManagerRole", "[aws]\ncluster = \"c\"\nimage = \"i\"\nsubnets = [\"s\"]\nexecution_role = \"e\"", "no manager_role"},
+		{"NegativeReplicas", "[aws]" + required + "replicas = -1", "negative replicas"},
+		{"NoLoadBalancer", "[aws]" + required + "listeners = {a = 80}", "require a load_balancer"},
+		{"BadPort", "[aws]" + required + "load_balancer = \"l\"\nvpc = \"v\"\nlisteners = {a = 70000}", "invalid port"},
+		{"ManagerPort", "[aws]" + required + "load_balancer = \"l\"\nvpc = \"v\"\nlisteners = {a = 8000}", "invalid port"},
+		{"EmptyRole", "[aws]" + required + "roles = {a = \"\"}", "empty role"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dep := tooltest.Deployment(t, "[serviceweaver]\nbinary = \"/app\"\n"+test.section)
+			_, err := loadConfig(dep.App)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("loadConfig: got %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestGroupRoles(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, err := groupRoles(dep.App, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"github.com/example/hello/Reverser": "arn:aws:iam::1:role/cache"}
+	if diff := cmp.Diff(want, roles); diff != "" {
+		t.Fatalf("groupRoles (-want +got):\n%s", diff)
+	}
+
+	// Colocated components may not have different roles.
+	cfg.Roles["github.com/example/hello/Reverser"] = "arn:aws:iam::1:role/reverser"
+	if _, err := groupRoles(dep.App, cfg); err == nil || !strings.Contains(err.Error(), "different roles") {
+		t.Fatalf("groupRoles: got %v, want error about different roles", err)
+	}
+}
+
+func TestManagerTaskDefinition(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := managerTaskDefinition(dep, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := td.Family, "hello-app-01234567-manager"; got != want {
+		t.Errorf("family: got %q, want %q", got, want)
+	}
+	if got, want := td.TaskRoleArn, cfg.ManagerRole; got != want {
+		t.Errorf("task role: got %q, want %q", got, want)
+	}
+	c := td.ContainerDefinitions[0]
+	var ports []int
+	for _, p := range c.PortMappings {
+		ports = append(ports, p.ContainerPort)
+	}
+	if want := []int{managerPort, 9000, 80}; !cmp.Equal(ports, want) {
+		t.Errorf("ports: got %v, want %v", ports, want)
+	}
+	if got, want := c.LogConfiguration.Options["awslogs-group"], "/serviceweaver/Hello_App"; got != want {
+		t.Errorf("log group: got %q, want %q", got, want)
+	}
+
+	// Check that the manager receives the deployment.
+	if len(c.Environment) != 1 || c.Environment[0].Name != deploymentKey {
+		t.Fatalf("manager env: got %v, want %s", c.Environment, deploymentKey)
+	}
+	decoded, err := decodeDeployment(c.Environment[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(dep, decoded, protocmp.Transform()); diff != "" {
+		t.Fatalf("decoded deployment (-want +got):\n%s", diff)
+	}
+
+	// The manager service receives the traffic of the target groups.
+	s := managerService(dep, cfg, "td-arn", map[string]string{"hello": "tg-hello", "admin": "tg-admin"})
+	want := []loadBalancer{
+		{TargetGroupArn: "tg-admin", ContainerName: "manager", ContainerPort: 9000},
+		{TargetGroupArn: "tg-hello", ContainerName: "manager", ContainerPort: 80},
+	}
+	if diff := cmp.Diff(want, s.LoadBalancers); diff != "" {
+		t.Errorf("load balancers (-want +got):\n%s", diff)
+	}
+	if s.DesiredCount != 1 {
+		t.Errorf("desired count: got %d, want 1", s.DesiredCount)
+	}
+}
+
+func TestGroupTaskDefinition(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &impl.BabysitterInfo{
+		Deployment:  dep,
+		Group:       "github.com/example/hello/Reverser",
+		ManagerAddr: "http://10.0.0.1:8000",
+	}
+	td, err := groupTaskDefinition(cfg, info, "arn:aws:iam::1:role/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := td.Family, "hello-app-01234567-hello-reverser"; got != want {
+		t.Errorf("family: got %q, want %q", got, want)
+	}
+	if got, want := td.TaskRoleArn, "arn:aws:iam::1:role/cache"; got != want {
+		t.Errorf("task role: got %q, want %q", got, want)
+	}
+
+	// The babysitter receives info in the environment.
+	c := td.ContainerDefinitions[0]
+	if got, want := c.Command, []string{"weaver", "aws", "babysitter"}; !cmp.Equal(got, want) {
+		t.Errorf("command: got %v, want %v", got, want)
+	}
+	t.Setenv(c.Environment[0].Name, c.Environment[0].Value)
+	got, err := impl.BabysitterInfoFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(info, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("babysitter info (-want +got):\n%s", diff)
+	}
+
+	s := groupService(cfg, info, "td-arn")
+	if s.DesiredCount != 3 || len(s.LoadBalancers) != 0 {
+		t.Errorf("service: got %d tasks and %d load balancers, want 3 and 0", s.DesiredCount, len(s.LoadBalancers))
+	}
+	if again := groupService(cfg, info, "td-arn"); again.ClientToken != s.ClientToken {
+		t.Errorf("client tokens %q and %q differ", s.ClientToken, again.ClientToken)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// The Elastic Load Balancing API, which uses the query protocol.
+const (
+	elbService = "elasticloadbalancing"
+	elbVersion = "2015-12-01"
+)
+
+// createTargetGroup creates the target group of the provided listener of a
+// deployment, whose targets are the manager's tasks, and returns its ARN.
+func (c *client) createTargetGroup(ctx context.Context, dep *protos.Deployment, cfg *config, listener string) (string, error) {
+	params := url.Values{
+		"Name":       {targetGroupName(dep, listener)},
+		"Protocol":   {"HTTP"},
+		"Port":       {fmt.Sprint(cfg.Listeners[listener])},
+		"VpcId":      {cfg.Vpc},
+		"TargetType": {"ip"},
+		// Any response, even an error, shows that the listener is served.
+		"Matcher.HttpCode": {"200-499"},
+	}
+	for i, t := range tags(dep, "manager") {
+		params.Set(fmt.Sprintf("Tags.member.%d.Key", i+1), t.Key)
+		params.Set(fmt.Sprintf("Tags.member.%d.Value", i+1), t.Value)
+	}
+	var out struct {
+		TargetGroups []struct {
+			TargetGroupArn string
+		} `xml:"CreateTargetGroupResult>TargetGroups>member"`
+	}
+	if err := c.callQuery(ctx, elbService, elbVersion, "CreateTargetGroup", params, &out); err != nil {
+		return "", err
+	}
+	if len(out.TargetGroups) == 0 {
+		return "", fmt.Errorf("%s CreateTargetGroup: no target group returned", elbService)
+	}
+	return out.TargetGroups[0].TargetGroupArn, nil
+}
+
+// forward makes the load balancer forward the traffic it receives on the
+// provided port to the provided target group. If the load balancer already
+// has a listener on the port, e.g., for a previous deployment of the
+// application, the listener's traffic is switched to the target group.
+func (c *client) forward(ctx context.Context, loadBalancer string, port int, targetGroup string) error {
+	action := url.Values{
+		"DefaultActions.member.1.Type":           {"forward"},
+		"DefaultActions.member.1.TargetGroupArn": {targetGroup},
+	}
+	params := url.Values{
+		"LoadBalancerArn": {loadBalancer},
+		"Protocol":        {"HTTP"},
+		"Port":            {fmt.Sprint(port)},
+	}
+	for k, v := range action {
+		params[k] = v
+	}
+	err := c.callQuery(ctx, elbService, elbVersion, "CreateListener", params, nil)
+	if !isCode(err, "DuplicateListener") {
+		return err
+	}
+
+	// Switch the existing listener to the target group.
+	var out struct {
+		Listeners []struct {
+			ListenerArn string
+			Port        int
+		} `xml:"DescribeListenersResult>Listeners>member"`
+	}
+	if err := c.callQuery(ctx, elbService, elbVersion, "DescribeListeners", url.Values{
+		"LoadBalancerArn": {loadBalancer},
+	}, &out); err != nil {
+		return err
+	}
+	for _, l := range out.Listeners {
+		if l.Port != port {
+			continue
+		}
+		action.Set("ListenerArn", l.ListenerArn)
+		return c.callQuery(ctx, elbService, elbVersion, "ModifyListener", action, nil)
+	}
+	return fmt.Errorf("no listener found on port %d of load balancer %s", port, loadBalancer)
+}
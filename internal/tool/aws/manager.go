@@ -0,0 +1,187 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/internal/tool/cluster"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// metadataKey is the environment variable in which ECS passes the URI of the
+// task metadata endpoint to containers.
+const metadataKey = "ECS_CONTAINER_METADATA_URI_V4"
+
+var (
+	managerCmd    = cluster.ManagerCommand("aws", runManager)
+	babysitterCmd = cluster.BabysitterCommand("aws", runBabysitter)
+)
+
+// runManager runs the manager of a deployment in an ECS task. The manager
+// creates a service for every colocation group, proxies the application's
+// listeners, and exports the application's metrics to CloudWatch.
+func runManager(ctx context.Context, _ []string) error {
+	dep, err := decodeDeployment(os.Getenv(deploymentKey))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve deployment: %w", err)
+	}
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		return err
+	}
+	roles, err := groupRoles(dep.App, cfg)
+	if err != nil {
+		return err
+	}
+	meta, err := taskMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	aws := newClient(cfg.Region)
+
+	// Print logs to stdout, as JSON, from where they are sent to CloudWatch
+	// Logs.
+	var mu sync.Mutex
+	logSaver := func(entry *protos.LogEntry) {
+		line, err := json.Marshal(jsonEntry(entry))
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Println(string(line))
+	}
+
+	// Export the metrics that babysitters report to the manager.
+	server := status.NewClient(fmt.Sprintf("localhost:%d", managerPort))
+	go newMetricExporter(aws, cfg.MetricsNamespace).run(ctx, server)
+
+	return cluster.RunManager(ctx, dep, cluster.ManagerOptions{
+		StartGroup: func(info *impl.BabysitterInfo) error {
+			role, ok := roles[info.Group]
+			if !ok {
+				role = cfg.TaskRole
+			}
+			td, err := groupTaskDefinition(cfg, info, role)
+			if err != nil {
+				return err
+			}
+			arn, err := aws.registerTaskDefinition(ctx, td)
+			if err != nil {
+				return err
+			}
+			return aws.createService(ctx, groupService(cfg, info, arn))
+		},
+		Port:      managerPort,
+		Addr:      fmt.Sprintf("http://%s:%d", meta.ip, managerPort),
+		Listeners: cfg.Listeners,
+		LogSaver:  logSaver,
+	})
+}
+
+// runBabysitter runs a babysitter and weavelet in an ECS task of a
+// colocation group's service.
+func runBabysitter(ctx context.Context, _ []string) error {
+	meta, err := taskMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	return cluster.RunBabysitter(ctx, meta.taskARN)
+}
+
+// metadata is the metadata of the ECS task a container runs in.
+type metadata struct {
+	taskARN string
+	ip      string // private IP address of the task
+}
+
+// taskMetadata returns the metadata of the ECS task the calling container
+// runs in, fetched from the task metadata endpoint [1].
+//
+// [1]: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-metadata-endpoint-v4.html
+func taskMetadata(ctx context.Context) (*metadata, error) {
+	uri := os.Getenv(metadataKey)
+	if uri == "" {
+		return nil, fmt.Errorf("not running in an ECS task")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri+"/task", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch task metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch task metadata: %s", resp.Status)
+	}
+	var task struct {
+		TaskARN    string
+		Containers []struct {
+			Networks []struct {
+				IPv4Addresses []string
+			}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return nil, fmt.Errorf("fetch task metadata: %w", err)
+	}
+	for _, c := range task.Containers {
+		for _, n := range c.Networks {
+			if len(n.IPv4Addresses) > 0 {
+				return &metadata{taskARN: task.TaskARN, ip: n.IPv4Addresses[0]}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("fetch task metadata: task %s has no IP address", task.TaskARN)
+}
+
+// jsonEntry returns the fields of a log entry that are printed, as JSON, to
+// CloudWatch Logs, where they can be queried with CloudWatch Logs Insights.
+func jsonEntry(entry *protos.LogEntry) map[string]any {
+	attrs := map[string]string{}
+	for i := 0; i+1 < len(entry.Attrs); i += 2 {
+		attrs[entry.Attrs[i]] = entry.Attrs[i+1]
+	}
+	fields := map[string]any{
+		"time":       time.UnixMicro(entry.TimeMicros).UTC().Format(time.RFC3339Nano),
+		"level":      entry.Level,
+		"app":        entry.App,
+		"deployment": entry.Version,
+		"component":  logging.ShortenComponent(entry.Component),
+		"weavelet":   entry.Node,
+		"msg":        entry.Msg,
+	}
+	if entry.File != "" {
+		fields["source"] = fmt.Sprintf("%s:%d", entry.File, entry.Line)
+	}
+	if len(attrs) > 0 {
+		fields["attrs"] = attrs
+	}
+	return fields
+}
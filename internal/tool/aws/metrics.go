@@ -0,0 +1,244 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// The CloudWatch API, which uses the query protocol.
+	cloudWatchService = "monitoring"
+	cloudWatchVersion = "2010-08-01"
+
+	// How often metrics are exported. Babysitters report metrics to the
+	// manager every minute.
+	metricsInterval = time.Minute
+
+	// The maximum number of metrics, and of dimensions per metric, accepted
+	// by PutMetricData.
+	maxDatumsPerRequest = 150
+	maxDimensions       = 30
+)
+
+// datum is a CloudWatch metric datum. Counters have a single value, the
+// increase of the counter since the last export. Gauges have the value of
+// every replica. Histograms have the upper bounds of their buckets as values,
+// with the number of samples added to every bucket since the last export as
+// counts.
+type datum struct {
+	name       string
+	dimensions [][2]string // (name, value) pairs, sorted by name
+	values     []float64
+	counts     []float64 // nil if every value has a count of 1
+}
+
+// metricExporter exports the metrics of a deployment to CloudWatch.
+type metricExporter struct {
+	cw        *client
+	namespace string
+	last      map[string]*protos.MetricSnapshot // last exported snapshot, by series
+}
+
+func newMetricExporter(cw *client, namespace string) *metricExporter {
+	return &metricExporter{cw: cw, namespace: namespace, last: map[string]*protos.MetricSnapshot{}}
+}
+
+// run exports the metrics reported by the provided status server every
+// metricsInterval, until ctx is cancelled.
+func (e *metricExporter) run(ctx context.Context, server status.Server) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			metrics, err := server.Metrics(ctx)
+			if err == nil {
+				err = e.export(ctx, e.datums(metrics.Metrics))
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "export metrics to CloudWatch: %v\n", err)
+			}
+		}
+	}
+}
+
+// datums returns the datums of the provided snapshots, which hold the
+// cumulative metrics of every replica, aggregated across replicas.
+func (e *metricExporter) datums(snapshots []*protos.MetricSnapshot) []*datum {
+	byKey := map[string]*datum{}
+	var keys []string
+	seen := map[string]bool{}
+	for _, s := range snapshots {
+		series := seriesKey(s.Name, s.Labels)
+		seen[series] = true
+		last := e.last[series]
+		e.last[series] = s
+
+		dims := dimensions(s.Labels)
+		key := s.Name + "|" + fmt.Sprint(dims)
+		d, ok := byKey[key]
+		if !ok {
+			d = &datum{name: s.Name, dimensions: dims}
+		}
+
+		switch s.Typ {
+		case protos.MetricType_COUNTER:
+			delta := s.Value
+			if last != nil && last.Value <= s.Value {
+				delta -= last.Value
+			}
+			if len(d.values) == 0 {
+				d.values = []float64{0}
+			}
+			d.values[0] += delta
+		case protos.MetricType_GAUGE:
+			d.values = append(d.values, s.Value)
+		case protos.MetricType_HISTOGRAM:
+			if len(s.Bounds) == 0 {
+				continue
+			}
+			if d.counts == nil {
+				d.values = make([]float64, len(s.Counts))
+				d.counts = make([]float64, len(s.Counts))
+				for i := range d.values {
+					// Samples above the last bound are counted at it.
+					d.values[i] = s.Bounds[min(i, len(s.Bounds)-1)]
+				}
+			}
+			for i, count := range s.Counts {
+				if i >= len(d.counts) {
+					break
+				}
+				delta := count
+				if last != nil && i < len(last.Counts) && last.Counts[i] <= count {
+					delta -= last.Counts[i]
+				}
+				d.counts[i] += float64(delta)
+			}
+		default:
+			continue
+		}
+		if !ok {
+			byKey[key] = d
+			keys = append(keys, key)
+		}
+	}
+
+	// Forget the series of replicas that stopped.
+	for series := range e.last {
+		if !seen[series] {
+			delete(e.last, series)
+		}
+	}
+
+	sort.Strings(keys)
+	var datums []*datum
+	for _, key := range keys {
+		d := byKey[key]
+		if d.counts != nil {
+			// Drop the empty buckets.
+			var values, counts []float64
+			for i, count := range d.counts {
+				if count > 0 {
+					values = append(values, d.values[i])
+					counts = append(counts, count)
+				}
+			}
+			if len(values) == 0 {
+				continue
+			}
+			d.values, d.counts = values, counts
+		}
+		datums = append(datums, d)
+	}
+	return datums
+}
+
+// export puts the provided datums in CloudWatch.
+func (e *metricExporter) export(ctx context.Context, datums []*datum) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for start := 0; start < len(datums); start += maxDatumsPerRequest {
+		end := min(start+maxDatumsPerRequest, len(datums))
+		params := url.Values{"Namespace": {e.namespace}}
+		for i, d := range datums[start:end] {
+			prefix := fmt.Sprintf("MetricData.member.%d.", i+1)
+			params.Set(prefix+"MetricName", d.name)
+			params.Set(prefix+"Timestamp", now)
+			params.Set(prefix+"Unit", "None")
+			for j, dim := range d.dimensions {
+				params.Set(fmt.Sprintf("%sDimensions.member.%d.Name", prefix, j+1), dim[0])
+				params.Set(fmt.Sprintf("%sDimensions.member.%d.Value", prefix, j+1), dim[1])
+			}
+			for j, v := range d.values {
+				params.Set(fmt.Sprintf("%sValues.member.%d", prefix, j+1), fmt.Sprint(v))
+			}
+			for j, c := range d.counts {
+				params.Set(fmt.Sprintf("%sCounts.member.%d", prefix, j+1), fmt.Sprint(c))
+			}
+		}
+		if err := e.cw.callQuery(ctx, cloudWatchService, cloudWatchVersion, "PutMetricData", params, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dimensions returns the CloudWatch dimensions of a metric with the provided
+// labels. The replica label is dropped, so that metrics are aggregated across
+// replicas, and so are empty labels, which CloudWatch doesn't accept.
+func dimensions(labels map[string]string) [][2]string {
+	var dims [][2]string
+	for name, value := range labels {
+		if name == "serviceweaver_node" || value == "" {
+			continue
+		}
+		dims = append(dims, [2]string{name, value})
+	}
+	sort.Slice(dims, func(i, j int) bool { return dims[i][0] < dims[j][0] })
+	if len(dims) > maxDimensions {
+		dims = dims[:maxDimensions]
+	}
+	return dims
+}
+
+// seriesKey returns a string that uniquely identifies the time series of a
+// metric with the provided name and labels.
+func seriesKey(name string, labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
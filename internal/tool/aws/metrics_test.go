@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDatums(t *testing.T) {
+	// Test plan: Export the metrics of two replicas twice, and check that
+	// counters and histograms are exported as increases aggregated across
+	// replicas, and gauges as the values of every replica.
+	snapshots := func(replica string, counter, gauge float64, counts []uint64) []*protos.MetricSnapshot {
+		labels := map[string]string{"serviceweaver_node": replica, "method": "Get"}
+		return []*protos.MetricSnapshot{
+			{Name: "calls", Typ: protos.MetricType_COUNTER, Labels: labels, Value: counter},
+			{Name: "load", Typ: protos.MetricType_GAUGE, Labels: labels, Value: gauge},
+			{Name: "latency", Typ: protos.MetricType_HISTOGRAM, Labels: labels, Bounds: []float64{1, 10}, Counts: counts},
+		}
+	}
+	dims := [][2]string{{"method", "Get"}}
+	e := newMetricExporter(nil, "test")
+
+	first := append(snapshots("a", 5, 1, []uint64{1, 0, 0}), snapshots("b", 3, 2, []uint64{0, 2, 0})...)
+	want := []*datum{
+		{name: "calls", dimensions: dims, values: []float64{8}},
+		{name: "latency", dimensions: dims, values: []float64{1, 10}, counts: []float64{1, 2}},
+		{name: "load", dimensions: dims, values: []float64{1, 2}},
+	}
+	if diff := cmp.Diff(want, e.datums(first), cmp.AllowUnexported(datum{})); diff != "" {
+		t.Fatalf("first datums (-want +got):\n%s", diff)
+	}
+
+	// Replica b stops, and replica a's latency histogram doesn't change.
+	second := snapshots("a", 7, 4, []uint64{1, 0, 0})
+	want = []*datum{
+		{name: "calls", dimensions: dims, values: []float64{2}},
+		{name: "load", dimensions: dims, values: []float64{4}},
+	}
+	if diff := cmp.Diff(want, e.datums(second), cmp.AllowUnexported(datum{})); diff != "" {
+		t.Fatalf("second datums (-want +got):\n%s", diff)
+	}
+	if len(e.last) != 3 {
+		t.Fatalf("got %d series, want 3", len(e.last))
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &credentials{
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	sign(req, nil, c, "service", "us-east-1", now)
+	const want = "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	query := map[string][]string{
+		"b":     {"2", "1"},
+		"a":     {"x y"},
+		"Param": {"a/b~c"},
+	}
+	const want = "Param=a%2Fb~c&a=x%20y&b=1&b=2"
+	if got := canonicalQuery(query); got != want {
+		t.Fatalf("canonicalQuery: got %q, want %q", got, want)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials")
+	const contents = `
+[default]
+aws_access_key_id = default-key
+aws_secret_access_key = default-secret
+
+# A comment.
+[dev]
+aws_access_key_id=dev-key
+aws_secret_access_key=dev-secret
+aws_session_token=dev-token
+`
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := fileCredentials(file, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.accessKey != "dev-key" || c.secretKey != "dev-secret" || c.token != "dev-token" {
+		t.Fatalf("fileCredentials: got %+v, want the dev profile", c)
+	}
+	if _, err := fileCredentials(file, "missing"); err == nil {
+		t.Fatal("fileCredentials of a missing profile: unexpected success")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cluster implements the manager and babysitter that the kube,
+// compose, and aws deployers run in containers. The manager runs in a
+// container of its own, and starts the colocation groups through the
+// container platform, which runs a babysitter and weavelet in every container
+// of a group.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+// ManagerCommand returns the "manager" subcommand of the provided deployer,
+// which runs fn.
+func ManagerCommand(deployer string, fn func(context.Context, []string) error) tool.Command {
+	return tool.Command{
+		Name:        "manager",
+		Description: fmt.Sprintf("The weaver %s manager", deployer),
+		Help: fmt.Sprintf(`Usage:
+  weaver %s manager
+
+Flags:
+  -h, --help   Print this help message.`, deployer),
+		Fn: fn,
+	}
+}
+
+// BabysitterCommand returns the "babysitter" subcommand of the provided
+// deployer, which runs fn.
+func BabysitterCommand(deployer string, fn func(context.Context, []string) error) tool.Command {
+	return tool.Command{
+		Name:        "babysitter",
+		Description: fmt.Sprintf("The weaver %s babysitter", deployer),
+		Help: fmt.Sprintf(`Usage:
+  weaver %s babysitter
+
+Flags:
+  -h, --help   Print this help message.`, deployer),
+		Fn: fn,
+	}
+}
+
+// ManagerOptions configure RunManager.
+type ManagerOptions struct {
+	// StartGroup starts the containers of the colocation group described by
+	// info. See impl.ManagerOptions.StartGroup.
+	StartGroup func(info *impl.BabysitterInfo) error
+
+	// Port is the port the manager listens on, and Addr is the URL
+	// babysitters use to reach the manager.
+	Port int
+	Addr string
+
+	// Listeners holds the ports the manager proxies the application's
+	// listeners on, by listener name.
+	Listeners map[string]int
+
+	// LogSaver, if not nil, saves the log entries of the deployment.
+	// Otherwise, log entries are pretty printed to stdout, from where the
+	// container platform collects them.
+	LogSaver func(*protos.LogEntry)
+}
+
+// RunManager runs the manager of the provided deployment until ctx is
+// canceled. The manager proxies the application's listeners, and stops
+// routing traffic to the replicas whose containers stop.
+//
+// The manager only keeps its state in memory, so a restarted manager
+// container forgets the running replicas.
+func RunManager(ctx context.Context, dep *protos.Deployment, opts ManagerOptions) error {
+	listeners := map[string]string{}
+	for name, port := range opts.Listeners {
+		listeners[name] = fmt.Sprintf(":%d", port)
+	}
+
+	logSaver := opts.LogSaver
+	if logSaver == nil {
+		var mu sync.Mutex
+		pp := logging.NewPrettyPrinter(false)
+		logSaver = func(entry *protos.LogEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Println(pp.Format(entry))
+		}
+	}
+
+	if _, err := impl.RunManager(ctx, dep, "", impl.ManagerOptions{
+		StartGroup: opts.StartGroup,
+		ListenAddr: fmt.Sprintf(":%d", opts.Port),
+		Addr:       opts.Addr,
+		Listeners:  listeners,
+		LogSaver:   logSaver,
+		NoRegistry: true,
+	}); err != nil {
+		return fmt.Errorf("cannot instantiate the manager: %w", err)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// RunBabysitter runs a babysitter and weavelet in a container of a colocation
+// group. container uniquely identifies the container, like the name of a
+// Kubernetes pod. A container restarted in place keeps its replica id.
+func RunBabysitter(ctx context.Context, container string) error {
+	info, err := impl.BabysitterInfoFromEnv()
+	if err != nil {
+		return err
+	}
+	info.ReplicaId = replicaId(container)
+	return impl.RunBabysitter(ctx, info)
+}
+
+// replicaId returns the replica id of the babysitter running in the provided
+// container. The containers of a colocation group are not numbered, so the id
+// is derived from the container's identifier.
+func replicaId(container string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(container)) //nolint:errcheck // hash.Hash.Write never fails
+	return int32(h.Sum32() & 0x7fffffff)
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compose implements the "weaver compose" deployer, which exports
+// Service Weaver applications as Docker Compose projects.
+//
+// "weaver compose generate" builds a container image holding the application
+// binary and the weaver binary, and writes a docker-compose.yml file with a
+// service for a manager, and a service for every colocation group, whose
+// containers run a babysitter and a weavelet. Unlike the kube deployer's
+// manager, which starts the colocation groups as their components are
+// activated, the compose manager finds every group running from the start.
+// The manager and babysitters speak the same protocol as the ssh deployer's
+// manager and babysitters.
+package compose
+
+import (
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var Commands = map[string]*tool.Command{
+	"generate": &generateCmd,
+	"version":  tool.VersionCmd("weaver compose"),
+
+	// Hidden commands.
+	"manager":    &managerCmd,
+	"babysitter": &babysitterCmd,
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// composeKey and shortComposeKey are the keys of the compose section of
+	// the config.
+	composeKey      = "github.com/ServiceWeaver/weaver/compose"
+	shortComposeKey = "compose"
+
+	// Default values of the compose config.
+	defaultBaseImage = "debian:bookworm-slim"
+	defaultReplicas  = 1
+)
+
+// config is the compose section of an application config, e.g.:
+//
+//	[compose]
+//	image = "registry.example.com/hello:v1"
+//	replicas = 2
+//	listeners = {hello = 8080}
+//
+// The section is optional.
+type config struct {
+	// Image is the tag of the container image built for the application.
+	// Defaults to "<app>:<deployment>", where <deployment> is the short id of
+	// the deployment.
+	Image string `toml:"image"`
+
+	// BaseImage is the image that the application's image is built from.
+	// It must be a Linux image that can run the application binary. Defaults
+	// to "debian:bookworm-slim".
+	BaseImage string `toml:"base_image"`
+
+	// WeaverBinary is the path of the weaver binary copied into the image,
+	// on the local machine. It must be built for Linux. Defaults to the
+	// running weaver binary, if it runs on Linux.
+	WeaverBinary string `toml:"weaver_binary"`
+
+	// Replicas is the number of replicas of every colocation group. Defaults
+	// to 1.
+	Replicas int `toml:"replicas"`
+
+	// Listeners maps the name of every listener published on the host to
+	// the host port it is published on.
+	Listeners map[string]int `toml:"listeners"`
+
+	// Env holds environment variables set in every container, e.g., to
+	// pass the credentials of a database.
+	Env map[string]string `toml:"env"`
+}
+
+// Validate implements the runtime.ParseConfigSection validation hook.
+func (c *config) Validate() error {
+	if c.Replicas < 0 {
+		return fmt.Errorf("negative replicas %d", c.Replicas)
+	}
+	for name, port := range c.Listeners {
+		if port <= 0 || port > 65535 || port == managerPort {
+			return fmt.Errorf("listener %q: invalid port %d", name, port)
+		}
+	}
+	return nil
+}
+
+// loadConfig returns the compose config of the provided application, with
+// defaults filled in, except for the image, which depends on the deployment.
+func loadConfig(app *protos.AppConfig) (*config, error) {
+	c := &config{}
+	if err := runtime.ParseConfigSection(composeKey, shortComposeKey, app.Sections, c); err != nil {
+		return nil, fmt.Errorf("unable to parse compose config: %w", err)
+	}
+	if c.BaseImage == "" {
+		c.BaseImage = defaultBaseImage
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultReplicas
+	}
+	return c, nil
+}
+
+// CheckConfig checks the compose section of the provided application config,
+// if it has one. It is used by "weaver config check".
+func CheckConfig(app *protos.AppConfig) error {
+	_, err := loadConfig(app)
+	return err
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var generateCmd = tool.Command{
+	Name:        "generate",
+	Description: "Export a Service Weaver app as a Docker Compose project",
+	Help: `Usage:
+  weaver compose generate [--out=<dir>] [--build] <configfile>
+
+Flags:
+  -h, --help   Print this help message.
+` + tool.FlagsHelp(generateFlags) + `
+
+Description:
+  "weaver compose generate" writes a Docker Compose project that runs the
+  application to a directory: a Dockerfile, the application binary and the
+  weaver binary, which the Dockerfile copies into an image, and a
+  docker-compose.yml file. It then builds the image with docker, unless
+  --build=false. Run the project with:
+
+      docker compose --project-directory <dir> up
+
+  The project has a service for a manager, which proxies the application's
+  listeners and prints its logs, and a service for every colocation group.
+  The application binary runs on the local machine to list its components.
+
+  The project is configured in the config file's optional [compose]
+  section:
+
+      [compose]
+      image = "hello:v1"                # tag of the built image
+      base_image = "debian:bookworm-slim"
+      weaver_binary = "/tmp/weaver"     # a weaver binary built for Linux
+      replicas = 1                      # replicas per colocation group
+      listeners = {hello = 8080}        # listeners published, by host port
+      env = {DB_HOST = "db"}            # environment of every container`,
+	Flags: generateFlags,
+	Fn:    generate,
+}
+
+var (
+	generateFlags = flag.NewFlagSet("generate", flag.ContinueOnError)
+	generateOut   = generateFlags.String("out", "weaver-compose", "Directory the project is written to")
+	generateBuild = generateFlags.Bool("build", true, "Build the image of the project with docker")
+)
+
+// generate writes the Docker Compose project of an application, and builds
+// its image.
+func generate(ctx context.Context, args []string) error {
+	// Validate command line arguments.
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	// Load the config file.
+	cfgFile := args[0]
+	contents, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	app, err := runtime.ParseConfig(cfgFile, string(contents), codegen.ComponentConfigValidator)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return err
+	}
+	weaverBinary := cfg.WeaverBinary
+	if weaverBinary == "" {
+		if goruntime.GOOS != "linux" {
+			return fmt.Errorf("weaver runs on %s; set weaver_binary in the [compose] section to a weaver binary built for Linux", goruntime.GOOS)
+		}
+		weaverBinary, err = os.Executable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// List the colocation groups.
+	components, err := plan.Components(ctx, app)
+	if err != nil {
+		return err
+	}
+	var groups []string
+	for _, g := range plan.Groups(app, components, func(string) string { return "" }) {
+		groups = append(groups, g.Name)
+	}
+
+	// Write the project.
+	dep := &protos.Deployment{
+		Id:  uuid.New().String(),
+		App: proto.Clone(app).(*protos.AppConfig),
+	}
+	dep.App.Binary = imageBinary
+	image := cfg.Image
+	if image == "" {
+		image = fmt.Sprintf("%s:%s", serviceName(app.Name), logging.Shorten(dep.Id))
+	}
+	p, err := newProject(dep, cfg, image, groups, plan.GroupNamer(app)("main"))
+	if err != nil {
+		return err
+	}
+	dir := *generateOut
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := copyFile(app.Binary, filepath.Join(dir, "app")); err != nil {
+		return fmt.Errorf("copy application binary: %w", err)
+	}
+	if err := copyFile(weaverBinary, filepath.Join(dir, "weaver")); err != nil {
+		return fmt.Errorf("copy weaver binary: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), dockerfile(cfg), 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), p.yaml(), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Generated deployment %s of app %s in %s\n", dep.Id, app.Name, dir)
+
+	// Build the image.
+	if *generateBuild {
+		cmd := exec.CommandContext(ctx, "docker", "build", "-t", image, dir)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("build image %s: %w", image, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Run it with:\n\n    docker compose --project-directory %s up\n", dir)
+	return nil
+}
+
+// dockerfile returns the Dockerfile of the image of a project.
+func dockerfile(cfg *config) []byte {
+	return []byte(fmt.Sprintf(`# Generated by "weaver compose generate".
+FROM %s
+COPY app weaver %s/
+ENTRYPOINT [%q, "compose"]
+`, cfg.BaseImage, imageDir, imageTool))
+}
+
+// copyFile copies the file src to dst, as an executable.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/cluster"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+)
+
+var (
+	managerCmd    = cluster.ManagerCommand("compose", runManager)
+	babysitterCmd = cluster.BabysitterCommand("compose", runBabysitter)
+)
+
+// runManager runs the manager of a deployment in a container of a Docker
+// Compose project, and proxies the application's listeners. The colocation
+// groups are services of the project, so the manager doesn't start them.
+// Logs are printed to stdout, where they can be read with "docker compose
+// logs".
+func runManager(ctx context.Context, _ []string) error {
+	dep, err := decodeDeployment(os.Getenv(deploymentKey))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve deployment: %w", err)
+	}
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		return err
+	}
+	return cluster.RunManager(ctx, dep, cluster.ManagerOptions{
+		StartGroup: func(*impl.BabysitterInfo) error {
+			// Docker Compose runs every colocation group from the start.
+			return nil
+		},
+		Port:      managerPort,
+		Addr:      managerAddr,
+		Listeners: cfg.Listeners,
+	})
+}
+
+// runBabysitter runs a babysitter and weavelet in a colocation group's
+// container. Docker sets the hostname of a container to its id.
+func runBabysitter(ctx context.Context, _ []string) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	return cluster.RunBabysitter(ctx, host)
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/proto"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// managerService is the name of the manager's service, which other
+	// services reach it at.
+	managerService = "manager"
+
+	// managerPort is the port the manager listens on, inside its container.
+	managerPort = 8000
+
+	// managerAddr is the address of the manager, inside the project.
+	managerAddr = "http://manager:8000"
+
+	// deploymentKey is the environment variable that holds the deployment,
+	// in the manager's container.
+	deploymentKey = "SERVICEWEAVER_DEPLOYMENT"
+
+	// Paths of the binaries in the image.
+	imageDir    = "/weaver"
+	imageBinary = imageDir + "/app"
+	imageTool   = imageDir + "/weaver"
+)
+
+// project is a Docker Compose project.
+type project struct {
+	name     string
+	services []service // the manager's service first
+}
+
+// service is a service of a Docker Compose project.
+type service struct {
+	name      string
+	image     string
+	command   string   // "manager" or "babysitter"
+	env       []string // KEY=VALUE, sorted
+	ports     []string // e.g., "8080:8080"
+	replicas  int
+	dependsOn []string
+}
+
+// newProject returns the Docker Compose project that runs the provided
+// deployment, with a service for each of the provided colocation groups.
+func newProject(dep *protos.Deployment, cfg *config, image string, groups []string, mainGroup string) (*project, error) {
+	encoded, err := encodeDeployment(dep)
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for k, v := range cfg.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	manager := service{
+		name:     managerService,
+		image:    image,
+		command:  "manager",
+		env:      append([]string{fmt.Sprintf("%s=%s", deploymentKey, encoded)}, env...),
+		replicas: 1,
+	}
+	for _, port := range cfg.Listeners {
+		manager.ports = append(manager.ports, fmt.Sprintf("%d:%d", port, port))
+	}
+	sort.Strings(manager.ports)
+	sort.Strings(manager.env)
+	p := &project{name: serviceName(dep.App.Name), services: []service{manager}}
+
+	used := map[string]bool{managerService: true}
+	for _, g := range groups {
+		info := &impl.BabysitterInfo{
+			Deployment:  dep,
+			Group:       g,
+			ManagerAddr: managerAddr,
+			RunMain:     g == mainGroup,
+		}
+		kv, err := impl.BabysitterEnv(info)
+		if err != nil {
+			return nil, err
+		}
+		s := service{
+			name:      uniqueName(serviceName(logging.ShortenComponent(g)), used),
+			image:     image,
+			command:   "babysitter",
+			env:       append([]string{kv}, env...),
+			replicas:  cfg.Replicas,
+			dependsOn: []string{managerService},
+		}
+		sort.Strings(s.env)
+		p.services = append(p.services, s)
+	}
+	return p, nil
+}
+
+// yaml returns the docker-compose.yml file of the project.
+func (p *project) yaml() []byte {
+	var b strings.Builder
+	b.WriteString("# Generated by \"weaver compose generate\".\n")
+	fmt.Fprintf(&b, "name: %s\n", quote(p.name))
+	b.WriteString("services:\n")
+	for _, s := range p.services {
+		fmt.Fprintf(&b, "  %s:\n", s.name)
+		fmt.Fprintf(&b, "    image: %s\n", quote(s.image))
+		b.WriteString("    build: .\n")
+		fmt.Fprintf(&b, "    command: [%s]\n", quote(s.command))
+		if len(s.env) > 0 {
+			b.WriteString("    environment:\n")
+			for _, kv := range s.env {
+				fmt.Fprintf(&b, "      - %s\n", quote(kv))
+			}
+		}
+		if len(s.ports) > 0 {
+			b.WriteString("    ports:\n")
+			for _, port := range s.ports {
+				fmt.Fprintf(&b, "      - %s\n", quote(port))
+			}
+		}
+		if len(s.dependsOn) > 0 {
+			b.WriteString("    depends_on:\n")
+			for _, d := range s.dependsOn {
+				fmt.Fprintf(&b, "      - %s\n", d)
+			}
+		}
+		b.WriteString("    deploy:\n")
+		fmt.Fprintf(&b, "      replicas: %d\n", s.replicas)
+		b.WriteString("    restart: unless-stopped\n")
+	}
+	return []byte(b.String())
+}
+
+// quote returns s as a double-quoted YAML string. Dollar signs are escaped,
+// so that Docker Compose doesn't interpolate them.
+func quote(s string) string {
+	return strconv.Quote(strings.ReplaceAll(s, "$", "$$"))
+}
+
+// serviceName returns a valid Docker Compose service name derived from the
+// provided name, e.g., "main.Reverser" becomes "main-reverser".
+func serviceName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	s := strings.Trim(b.String(), "-_")
+	if s == "" {
+		return "app"
+	}
+	return s
+}
+
+// uniqueName returns name, or name with a numeric suffix if it is already
+// used, and marks the returned name as used.
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// encodeDeployment encodes a deployment, to pass it to the manager in an
+// environment variable.
+func encodeDeployment(dep *protos.Deployment) (string, error) {
+	return proto.ToEnv(dep)
+}
+
+// decodeDeployment decodes a deployment encoded by encodeDeployment.
+func decodeDeployment(s string) (*protos.Deployment, error) {
+	dep := &protos.Deployment{}
+	if err := proto.FromEnv(s, dep); err != nil {
+		return nil, err
+	}
+	if dep.App == nil {
+		return nil, fmt.Errorf("invalid deployment")
+	}
+	return dep, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/internal/tool/tooltest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testConfig = `
+[serviceweaver]
+name = "Hello_App"
+binary = "/app/hello"
+
+[compose]
+image = "hello:v1"
+replicas = 2
+listeners = {hello = 8080}
+env = {DB_HOST = "db", PRICE = "$5"}
+`
+
+func TestServiceName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"main", "main"},
+		{"Hello_App", "hello_app"},
+		{"reverser.Reverser", "reverser-reverser"},
+		{"--a--", "a"},
+		{"...", "app"},
+	} {
+		if got := serviceName(test.name); got != test.want {
+			t.Errorf("serviceName(%q): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		section string
+		want    string
+	}{
+		{"NegativeReplicas", "[compose]\nreplicas = -1", "negative replicas"},
+		{"BadPort", "[compose]\nlisteners = {a = 70000}", "invalid port"},
+		{"ManagerPort", "[compose]\nlisteners = {a = 8000}", "invalid port"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dep := tooltest.Deployment(t, "[serviceweaver]\nbinary = \"/app\"\n"+test.section)
+			_, err := loadConfig(dep.App)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("loadConfig: got %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	dep := tooltest.Deployment(t, "[serviceweaver]\nbinary = \"/app\"\n")
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseImage != defaultBaseImage || cfg.Replicas != defaultReplicas {
+		t.Fatalf("loadConfig: got %+v, want defaults", cfg)
+	}
+}
+
+func TestNewProject(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := []string{"github.com/x/a/A", "github.com/y/a/A", "main"}
+	p, err := newProject(dep, cfg, cfg.Image, groups, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.name != "hello_app" {
+		t.Errorf("name: got %q, want %q", p.name, "hello_app")
+	}
+
+	var names []string
+	for _, s := range p.services {
+		names = append(names, s.name)
+	}
+	if diff := cmp.Diff([]string{"manager", "a-a", "a-a-2", "main"}, names); diff != "" {
+		t.Fatalf("services (-want +got):\n%s", diff)
+	}
+
+	// Check the manager.
+	manager := p.services[0]
+	if diff := cmp.Diff([]string{"8080:8080"}, manager.ports); diff != "" {
+		t.Errorf("manager ports (-want +got):\n%s", diff)
+	}
+	var encoded string
+	for _, kv := range manager.env {
+		if k, v, _ := strings.Cut(kv, "="); k == deploymentKey {
+			encoded = v
+		}
+	}
+	got, err := decodeDeployment(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(dep, got, protocmp.Transform()); diff != "" {
+		t.Errorf("manager deployment (-want +got):\n%s", diff)
+	}
+
+	// Check the babysitters.
+	for i, s := range p.services[1:] {
+		if s.command != "babysitter" || s.replicas != 2 {
+			t.Errorf("service %s: got command %q with %d replicas", s.name, s.command, s.replicas)
+		}
+		var info *impl.BabysitterInfo
+		for _, kv := range s.env {
+			k, v, _ := strings.Cut(kv, "=")
+			if k != impl.BabysitterInfoKey {
+				continue
+			}
+			t.Setenv(k, v)
+			if info, err = impl.BabysitterInfoFromEnv(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if info == nil {
+			t.Fatalf("service %s: no babysitter info", s.name)
+		}
+		if info.Group != groups[i] || info.RunMain != (groups[i] == "main") || info.ManagerAddr != managerAddr {
+			t.Errorf("service %s: got info %v", s.name, info)
+		}
+		if !strings.Contains(strings.Join(s.env, " "), "DB_HOST=db") {
+			t.Errorf("service %s: missing env, got %v", s.name, s.env)
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newProject(dep, cfg, cfg.Image, []string{"main"}, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(p.yaml())
+	for _, want := range []string{
+		"name: \"hello_app\"\n",
+		"  manager:\n    image: \"hello:v1\"\n    build: .\n    command: [\"manager\"]\n",
+		"    ports:\n      - \"8080:8080\"\n",
+		"  main:\n    image: \"hello:v1\"\n    build: .\n    command: [\"babysitter\"]\n",
+		"      - \"PRICE=$$5\"\n", // escaped from interpolation
+		"    depends_on:\n      - manager\n",
+		"    deploy:\n      replicas: 2\n    restart: unless-stopped\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("yaml: missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config implements the "weaver config" commands, which check
+// application configs before they are deployed.
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/aws"
+	"github.com/ServiceWeaver/weaver/internal/tool/compose"
+	"github.com/ServiceWeaver/weaver/internal/tool/kube"
+	"github.com/ServiceWeaver/weaver/internal/tool/nomad"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+// checkTimeout bounds how long the application binary may take to check its
+// config.
+const checkTimeout = time.Minute
+
+var (
+	checkCmd = tool.Command{
+		Name:        "check",
+		Description: "Check a Service Weaver config file",
+		Help: `Usage:
+  weaver config check <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+
+Description:
+  "weaver config check" checks a config file, and the application binary it
+  names, without deploying the application. It reports:
+
+    - invalid [serviceweaver] settings;
+    - invalid [aws], [compose], [kube], [nomad], and [ssh] deployer
+      settings;
+    - components named in the config that the binary doesn't have;
+    - component config sections that don't match the component's config
+      struct, e.g., because of an unknown key or a value of the wrong type;
+    - per-method settings for methods that don't exist.
+
+  To check the components, the binary is run with the config's args and
+  env, but it only checks the config and exits when it calls weaver.Init.
+  The command exits with a non-zero status if the config has problems.`,
+		Fn: check,
+	}
+
+	Commands = map[string]*tool.Command{
+		"check":   &checkCmd,
+		"version": tool.VersionCmd("weaver config"),
+	}
+)
+
+// check implements "weaver config check".
+func check(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	file := args[0]
+	problems, err := Check(ctx, file)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", file, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", file, len(problems))
+	}
+	fmt.Printf("%s: OK\n", file)
+	return nil
+}
+
+// Check checks the provided config file, and the application binary it
+// names, and returns the problems it finds. It returns an error if the
+// config can't be checked, e.g., because the binary fails to run.
+func Check(ctx context.Context, file string) ([]string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("load config file %q: %w", file, err)
+	}
+
+	// Check the [serviceweaver] section. The component config sections are
+	// checked by the binary, which has the components registered.
+	app, err := runtime.ParseConfig(file, string(contents), func(string, string) error { return nil })
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+	if _, err := runtime.ParseWeaveletConfig(app.Sections); err != nil {
+		return []string{err.Error()}, nil
+	}
+
+	// Check the sections of the built-in deployers.
+	var problems []string
+	for _, check := range []func(*protos.AppConfig) error{aws.CheckConfig, compose.CheckConfig, kube.CheckConfig, nomad.CheckConfig, ssh.CheckConfig} {
+		if err := check(app); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	// Check the components.
+	if _, err := os.Stat(app.Binary); err != nil {
+		return append(problems, fmt.Sprintf("binary %q doesn't exist", app.Binary)), nil
+	}
+	fromBinary, err := checkBinary(ctx, file, app)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, fromBinary...), nil
+}
+
+// checkBinary runs the application binary to check the config in the
+// provided file, and returns the problems it finds.
+func checkBinary(ctx context.Context, file string, app *protos.AppConfig) ([]string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "weaver-config-check")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	report := filepath.Join(tmp, "report.json")
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, app.Binary, app.Args...)
+	cmd.Env = append(os.Environ(), app.Env...)
+	cmd.Env = append(cmd.Env, "SERVICEWEAVER_CONFIG="+abs, runtime.CheckConfigKey+"="+report)
+	out, runErr := cmd.CombinedOutput()
+
+	data, err := os.ReadFile(report)
+	if errors.Is(err, os.ErrNotExist) {
+		// The binary exited, or timed out, before calling weaver.Init.
+		return nil, fmt.Errorf("binary %q didn't check the config; make sure it calls weaver.Init and is built with this version of Service Weaver: %v\n%s", app.Binary, runErr, out)
+	} else if err != nil {
+		return nil, err
+	}
+	var problems []string
+	if err := json.Unmarshal(data, &problems); err != nil {
+		return nil, fmt.Errorf("binary %q: invalid config check report: %w", app.Binary, err)
+	}
+	return problems, nil
+}
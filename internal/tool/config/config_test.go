@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheck(t *testing.T) {
+	// Test plan: Build the chat example, and check configs for it.
+	tmp := t.TempDir()
+	binary := filepath.Join(tmp, "chat")
+	build := exec.Command("go", "build", "-o", binary, "github.com/ServiceWeaver/weaver/examples/chat")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+[serviceweaver]
+binary = "./chat"
+
+["github.com/ServiceWeaver/weaver/examples/chat/SQLStore"]
+db_driver = "mysql"
+db_uri = "root:@tcp(localhost:3306)/"
+`,
+		},
+		{
+			name: "problems",
+			config: `
+[serviceweaver]
+binary = "./chat"
+colocate = [["github.com/ServiceWeaver/weaver/examples/chat/SQLStore", "github.com/ServiceWeaver/weaver/examples/chat/Cache"]]
+
+[aws]
+image = "chat:v1"
+
+[kube]
+replicas = 3
+
+[nomad]
+driver = "podman"
+
+["github.com/ServiceWeaver/weaver/examples/chat/SQLStore"]
+db_driver = "mysql"
+db_url = "root:@tcp(localhost:3306)/"
+`,
+			want: []string{
+				`unable to parse aws config: section "aws": no cluster provided`,
+				`unable to parse kube config: section "kube": no image provided`,
+				`unable to parse nomad config: section "nomad": unknown driver "podman"`,
+				`[github.com/ServiceWeaver/weaver/examples/chat/SQLStore]: config does not match *main.config: section "github.com/ServiceWeaver/weaver/examples/chat/SQLStore" has unknown keys [db_url]`,
+				`colocate: unknown component "github.com/ServiceWeaver/weaver/examples/chat/Cache"`,
+			},
+		},
+		{
+			name: "missing binary",
+			config: `
+[serviceweaver]
+binary = "./missing"
+`,
+			want: []string{`binary "` + filepath.Join(tmp, "missing") + `" doesn't exist`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := filepath.Join(tmp, "weaver.toml")
+			if err := os.WriteFile(file, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Check(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("Check (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckBinaryWithoutInit(t *testing.T) {
+	// Test plan: Check a config whose binary never calls weaver.Init.
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "weaver.toml")
+	config := "[serviceweaver]\nbinary = \"/bin/true\"\n"
+	if _, err := os.Stat("/bin/true"); err != nil {
+		t.Skip("/bin/true not found")
+	}
+	if err := os.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Check(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "didn't check the config") {
+		t.Fatalf("Check: got %v, want an error containing %q", err, "didn't check the config")
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dev implements the "weaver dev" command, which runs a Service Weaver
+// application in a single process and rebuilds and restarts it whenever its
+// source code changes.
+package dev
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/listeners"
+	"github.com/ServiceWeaver/weaver/internal/tool/generate"
+)
+
+// Usage is the help message of the "weaver dev" command.
+const Usage = `Run a Service Weaver application, restarting it whenever it changes.
+
+Usage:
+  weaver dev [--interval=<duration>] [args...]
+
+Description:
+  "weaver dev" runs "weaver generate ./..." and "go build ." in the current
+  directory, which should contain the main package of a Service Weaver
+  application, and runs the resulting binary in a single process with the
+  provided arguments. Arguments that start with a dash must follow "--", as
+  in "weaver dev -- --port=9000". Whenever a file in the current directory or one of its
+  subdirectories changes, "weaver dev" regenerates and rebuilds the
+  application and, if the build succeeds, restarts it. If the build fails, the
+  previous binary keeps running.
+
+  Listeners that are given a LocalAddress with a port, like "localhost:9000",
+  listen on that address in every run. Listeners with no port or port 0 listen
+  on the address they were assigned in the first run, so that a restarted
+  application is reachable at the same address.
+
+  As with "go run", the config file named by the SERVICEWEAVER_CONFIG
+  environment variable, if any, is read every time the application starts.
+
+Flags:
+  -h, --help	Print this help message.
+  --interval	How often to check for changes (default 500ms).`
+
+// stopTimeout is how long an application is given to exit after being
+// interrupted, before it is killed.
+const stopTimeout = 10 * time.Second
+
+// Options configure "weaver dev".
+type Options struct {
+	// How often to check the source code for changes.
+	Interval time.Duration
+}
+
+// Run runs the Service Weaver application whose main package is in the
+// provided directory, passing it the provided arguments, and rebuilds and
+// restarts it whenever a file in the directory changes. Run returns when the
+// provided context is cancelled or the user interrupts it.
+func Run(ctx context.Context, dir string, args []string, opts Options) error {
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "weaver-dev-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	d := &developer{
+		dir:  dir,
+		tmp:  tmp,
+		args: args,
+		env:  append(os.Environ(), fmt.Sprintf("%s=%s", listeners.FileKey, filepath.Join(tmp, "listeners.json"))),
+	}
+	defer d.stop()
+
+	last, err := snapshot(dir)
+	if err != nil {
+		return err
+	}
+	d.rebuild(ctx)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		files, err := snapshot(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "weaver dev: %v\n", err)
+			continue
+		}
+		if files.equal(last) {
+			continue
+		}
+		last = files
+		fmt.Fprintln(os.Stderr, "weaver dev: change detected; rebuilding")
+		d.rebuild(ctx)
+	}
+}
+
+// developer builds and runs an application.
+type developer struct {
+	dir  string   // the application's main package
+	tmp  string   // directory in which binaries are built
+	args []string // command line arguments of the application
+	env  []string // environment of the application
+
+	builds  int      // number of builds so far
+	running *process // the running application, or nil
+}
+
+// process is a running application.
+type process struct {
+	cmd      *exec.Cmd
+	binary   string        // the binary run by cmd
+	stopping chan struct{} // closed when the process is being stopped
+	done     chan struct{} // closed when the process exits
+}
+
+// rebuild regenerates and builds the application and, if it builds, restarts
+// it. Errors are printed rather than returned, since they are expected to be
+// fixed by the user.
+func (d *developer) rebuild(ctx context.Context) {
+	if err := generate.Generate(d.dir, []string{"./..."}, generate.Options{}); err != nil {
+		fmt.Fprintf(os.Stderr, "weaver dev: weaver generate failed; waiting for changes:\n%v\n", err)
+		return
+	}
+
+	// The binary is named after the directory, like "go build" does, since a
+	// single process application is named after its binary.
+	d.builds++
+	name := filepath.Base(d.dir)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	binary := filepath.Join(d.tmp, fmt.Sprint(d.builds), name)
+	build := exec.CommandContext(ctx, "go", "build", "-o", binary, ".")
+	build.Dir = d.dir
+	build.Stdout = os.Stderr
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "weaver dev: go build failed; waiting for changes: %v\n", err)
+		return
+	}
+
+	// Stop the old binary before starting the new one, so that the new one
+	// can listen on the same addresses.
+	d.stop()
+	if err := d.start(binary); err != nil {
+		fmt.Fprintf(os.Stderr, "weaver dev: start %s: %v\n", name, err)
+	}
+}
+
+// start runs the provided binary.
+func (d *developer) start(binary string) error {
+	cmd := exec.Command(binary, d.args...)
+	cmd.Dir = d.dir
+	cmd.Env = d.env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p := &process{
+		cmd:      cmd,
+		binary:   binary,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		err := cmd.Wait()
+		select {
+		case <-p.stopping:
+		default:
+			fmt.Fprintf(os.Stderr, "weaver dev: application exited (%v); waiting for changes\n", err)
+		}
+		close(p.done)
+	}()
+	d.running = p
+	return nil
+}
+
+// stop stops the running application, if any, and removes its binary.
+func (d *developer) stop() {
+	p := d.running
+	if p == nil {
+		return
+	}
+	d.running = nil
+	defer os.RemoveAll(filepath.Dir(p.binary)) //nolint:errcheck // best effort
+
+	// Interrupt the application, giving it a chance to shut down cleanly, and
+	// kill it if it doesn't exit in time.
+	close(p.stopping)
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		p.cmd.Process.Kill() //nolint:errcheck // best effort
+	}
+	select {
+	case <-p.done:
+	case <-time.After(stopTimeout):
+		p.cmd.Process.Kill() //nolint:errcheck // best effort
+		<-p.done
+	}
+}
+
+// files maps the files of a directory tree to their modification times and
+// sizes.
+type files map[string]fileInfo
+
+type fileInfo struct {
+	modTime time.Time
+	size    int64
+}
+
+// equal returns whether two snapshots are the same.
+func (f files) equal(other files) bool {
+	if len(f) != len(other) {
+		return false
+	}
+	for path, info := range f {
+		if other[path] != info {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot returns the files in the provided directory tree that affect the
+// application. Hidden files and directories, test files, and generated files
+// are ignored.
+func snapshot(dir string) (files, error) {
+	snap := files{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// The file was removed during the walk.
+				return nil
+			}
+			return err
+		}
+		name := entry.Name()
+		if path != dir && strings.HasPrefix(name, ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || name == "weaver_gen.go" || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		snap[path] = fileInfo{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return snap, err
+}
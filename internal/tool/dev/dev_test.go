@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the modification time changes, even on file systems with
+		// coarse timestamps.
+		mtime := time.Now().Add(time.Duration(len(contents)) * time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := func() files {
+		t.Helper()
+		s, err := snapshot(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	write("main.go", "package main")
+	write("foo/foo.go", "package foo")
+	write("index.html", "<html></html>")
+	before := snap()
+	if got, want := len(before), 3; got != want {
+		t.Fatalf("snapshot: got %d files, want %d", got, want)
+	}
+
+	// Changes to ignored files are not detected.
+	write("weaver_gen.go", "package main")
+	write("main_test.go", "package main")
+	write(".git/HEAD", "ref: refs/heads/main")
+	write(".main.go.swp", "")
+	if after := snap(); !after.equal(before) {
+		t.Fatalf("snapshot changed after writing ignored files: %v", after)
+	}
+
+	// Other changes are.
+	for _, name := range []string{"foo/foo.go", "index.html", "bar/bar.go"} {
+		write(name, "changed "+name)
+		after := snap()
+		if after.equal(before) {
+			t.Fatalf("snapshot unchanged after writing %s", name)
+		}
+		before = after
+	}
+	if err := os.Remove(filepath.Join(dir, "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	if snap().equal(before) {
+		t.Fatal("snapshot unchanged after removing main.go")
+	}
+}
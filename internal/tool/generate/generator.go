@@ -23,7 +23,8 @@ import (
 	"go/parser"
 	"go/token"
 	"go/types"
-	"io"
+	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -41,12 +42,17 @@ import (
 // which can be confusing and also we might do unnecessary work.
 
 const (
-	generatedCodeFile = "weaver_gen.go"
+	generatedCodeFile    = "weaver_gen.go"
+	generatedMocksFile   = "weaver_gen_mocks.go"
+	generatedGRPCFile    = "weaver_gen_grpc.go"
+	generatedProtoFile   = "weaver_gen.proto"
+	generatedHTTPFile    = "weaver_gen_http.go"
+	generatedOpenAPIFile = "weaver_gen_openapi.json"
 
 	Usage = `Generate code for a Service Weaver application.
 
 Usage:
-  weaver generate [packages]
+  weaver generate [-mocks] [-watch] [-grpc components] [-http components] [packages]
 
 Description:
   "weaver generate" generates code for the Service Weaver applications in the provided
@@ -56,10 +62,53 @@ Description:
   package's directory.  For example, running "weaver generate . ./foo" will
   create ./weaver_gen.go and ./foo/weaver_gen.go.
 
+  If the -mocks flag is provided, "weaver generate" also generates a mock
+  implementation of every component interface, placed in a weaver_gen_mocks.go
+  file in the package's directory. The mock for a component interface Foo is
+  called MockFoo (or mockFoo if Foo is not exported). It records the calls made
+  to it, and it has an ExpectBar method for every method Bar that expects a
+  call with arguments matching the provided matchers (e.g., codegen.Eq(42) or
+  codegen.Any[string]()) and sets the results it returns. AssertExpectations
+  reports the expected calls that were not made, and the unexpected calls. A
+  method without expectations calls its stub function, the mock's BarFn
+  field, if set, and otherwise returns zero values and the mock's Err field.
+  Mocks are not generated in test files so that they can be used by the tests
+  of other packages.
+
+  A generic component implementation, e.g., "type cache[K comparable, V any]
+  struct { weaver.Implements[Cache[K, V]] }", is a template. Code is generated
+  for every instantiation of it declared with a type alias in the package,
+  e.g., "type stringCache = cache[string, string]".
+
   You specify packages for "weaver generate" in the same way you specify
   packages for go build, go test, go vet, etc. See "go help packages" for more
   information.
 
+  If the -grpc flag is provided, "weaver generate" also exposes the provided
+  components, a comma-separated list, as gRPC services. A component is named
+  by its full name (e.g., github.com/my/app/cart/Cart) or by a suffix of it
+  (e.g., cart/Cart or Cart). The services are declared in a weaver_gen.proto
+  file in the package's directory, which can be used to generate clients in
+  other languages, and implemented in a weaver_gen_grpc.go file. The service
+  for a component interface Foo is registered with a gRPC server by calling
+  RegisterFooGRPCServer.
+
+  If the -http flag is provided, "weaver generate" also exposes the provided
+  components, named like for -grpc, over HTTP with JSON encoded arguments and
+  results. A method M of a component interface Foo is served at /Foo/M. The
+  gateways are implemented in a weaver_gen_http.go file and described by an
+  OpenAPI spec in a weaver_gen_openapi.json file. The gateway for a component
+  interface Foo is returned by NewFooHTTPHandler and can be served on a
+  weaver.Listener.
+
+  If the -watch flag is provided, "weaver generate" keeps running after
+  generating code, and regenerates the code of a package whenever the
+  declarations in the package change, along with the code of the packages
+  that import it. Edits that only change the bodies of functions and methods
+  don't affect the generated code and are skipped. A weaver_gen.go file is
+  only rewritten if its contents change. Packages created after "weaver
+  generate -watch" starts aren't watched.
+
   Rather than invoking "weaver generate" directly, you can place a line of the
   following form in one of the .go files in the package:
 
@@ -78,7 +127,22 @@ Examples:
   weaver generate ./foo
 
   # Generate code for all packages in all subdirectories of current directory.
-  weaver generate ./...`
+  weaver generate ./...
+
+  # Generate code and component mocks for the package in the current directory.
+  weaver generate -mocks .
+
+  # Generate code, and a gRPC service for the Cart component, for the package
+  # in the ./cart directory.
+  weaver generate -grpc=Cart ./cart
+
+  # Generate code, and an HTTP gateway for the Cart component, for the package
+  # in the ./cart directory.
+  weaver generate -http=Cart ./cart
+
+  # Generate code for all packages in all subdirectories of current directory,
+  # and regenerate it whenever they change.
+  weaver generate -watch ./...`
 )
 
 // ErrorList holds a list of errors.
@@ -92,45 +156,105 @@ func (list ErrorList) Error() string {
 	return b.String()
 }
 
+// Options configure code generation.
+type Options struct {
+	// If true, generate mock implementations of component interfaces.
+	Mocks bool
+
+	// Components to expose as gRPC services. A component is selected by its
+	// full name, e.g., "github.com/my/app/cart/Cart", or by a suffix of it
+	// made of whole path elements, e.g., "cart/Cart" or "Cart".
+	GRPC []string
+
+	// Components to expose over HTTP, selected like for GRPC.
+	HTTP []string
+}
+
 // Generate generates Service Weaver code for the specified packages.
 // The list of supplied packages are treated similarly to the arguments
 // passed to "go build" (see "go help packages" for details).
-func Generate(dir string, pkgs []string) error {
+func Generate(dir string, pkgs []string, opt Options) error {
+	results, err := generate(dir, pkgs, opt)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	selected := map[string]bool{}
+	for _, r := range results {
+		errs = append(errs, r.errors...)
+		for _, pattern := range r.selected {
+			selected[pattern] = true
+		}
+	}
+	if len(errs) == 0 {
+		for _, pattern := range opt.GRPC {
+			if !selected["-grpc "+pattern] {
+				errs = append(errs, fmt.Errorf("-grpc %s: no such component", pattern))
+			}
+		}
+		for _, pattern := range opt.HTTP {
+			if !selected["-http "+pattern] {
+				errs = append(errs, fmt.Errorf("-http %s: no such component", pattern))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return ErrorList(errs)
+	}
+	return nil
+}
+
+// result is the result of generating code for a package.
+type result struct {
+	pkg      *packages.Package
+	errors   []error
+	selected []string // the -grpc and -http patterns that selected a component, e.g., "-grpc Cart"
+}
+
+// generate generates Service Weaver code for the specified packages, like
+// Generate, and returns the loaded packages along with their errors.
+func generate(dir string, pkgs []string, opt Options) ([]result, error) {
 	fset := token.NewFileSet()
 	cfg := &packages.Config{
-		Mode:      packages.NeedName | packages.NeedSyntax | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo,
+		Mode:      packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo,
 		Dir:       dir,
 		Fset:      fset,
 		ParseFile: parseNonWeaverGenFile,
 	}
 	pkgList, err := packages.Load(cfg, pkgs...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var automarshals typeutil.Map
-	var errs []error
-	for _, p := range pkgList {
+	results := make([]result, len(pkgList))
+	for i, p := range pkgList {
 		g := &generator{
 			pkg:            p,
 			tset:           newTypeSet(p, &automarshals, &typeutil.Map{}),
 			fileset:        fset,
 			componentImpls: map[string]token.Pos{},
+			genericImpls:   map[*types.TypeName]bool{},
+			opt:            opt,
 		}
 		g.processPackage(p)
-		errs = append(errs, g.errors...)
+		results[i] = result{pkg: p, errors: g.errors, selected: g.selected}
 	}
-	if len(errs) != 0 {
-		return ErrorList(errs)
-	}
-	return nil
+	return results, nil
+}
+
+// isGeneratedFile returns whether the provided file was generated by "weaver
+// generate".
+func isGeneratedFile(filename string) bool {
+	base := filepath.Base(filename)
+	return base == generatedCodeFile || base == generatedMocksFile || base == generatedGRPCFile || base == generatedHTTPFile
 }
 
-// parseNonWeaverGenFile parses a Go file, except for weaver_gen.go files whose
-// contents are ignored since those contents may reference types that no longer
-// exist.
+// parseNonWeaverGenFile parses a Go file, except for weaver_gen.go,
+// weaver_gen_mocks.go, and weaver_gen_grpc.go files whose contents are
+// ignored since those contents may reference types that no longer exist.
 func parseNonWeaverGenFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
-	if filepath.Base(filename) == generatedCodeFile {
+	if isGeneratedFile(filename) {
 		return parser.ParseFile(fset, filename, src, parser.PackageClauseOnly)
 	}
 	return parser.ParseFile(fset, filename, src, parser.ParseComments|parser.DeclarationErrors)
@@ -143,9 +267,12 @@ type generator struct {
 	errors         []error
 	components     []*component
 	componentImpls map[string]token.Pos
-	types          []types.Type // all types that need to be serialized
-	sizeFuncNeeded typeutil.Map // types that need a serviceweaver_size_* function
-	generated      typeutil.Map // memo cache for generateEncDecMethodsFor
+	genericImpls   map[*types.TypeName]bool // generic component implementations, and whether they're instantiated
+	types          []types.Type             // all types that need to be serialized
+	sizeFuncNeeded typeutil.Map             // types that need a serviceweaver_size_* function
+	generated      typeutil.Map             // memo cache for generateEncDecMethodsFor
+	selected       []string                 // the -grpc and -http patterns that selected a component
+	opt            Options
 }
 
 func (g *generator) addError(pos token.Pos, err error) {
@@ -178,7 +305,7 @@ func (g *generator) processPackage(pkg *packages.Package) {
 	// Find all weaver.AutoMarshal annotations.
 	for _, f := range pkg.Syntax {
 		fname := g.fileset.Position(f.Package).Filename
-		if filepath.Base(fname) == generatedCodeFile {
+		if isGeneratedFile(fname) {
 			continue
 		}
 		for _, t := range g.findAutoMarshals(f) {
@@ -201,15 +328,55 @@ func (g *generator) processPackage(pkg *packages.Package) {
 	// Find and process all components.
 	for _, f := range pkg.Syntax {
 		fname := g.fileset.Position(f.Package).Filename
-		if filepath.Base(fname) == generatedCodeFile {
+		if isGeneratedFile(fname) {
 			continue
 		}
 		g.findComponents(f)
 	}
+	for impl, instantiated := range g.genericImpls {
+		if !instantiated {
+			g.errorf(impl.Pos(), "generic component implementation %s is never instantiated. Declare an instantiation with a type alias, e.g., type intImpl = %s[int].", impl.Name(), impl.Name())
+		}
+	}
 
 	if len(g.errors) == 0 && len(g.components)+g.tset.automarshalCandidates.Len() > 0 {
 		g.generate()
 	}
+	if len(g.errors) == 0 && len(g.components) > 0 && g.opt.Mocks {
+		g.generateMocksFile()
+	}
+	if len(g.errors) == 0 && len(g.components) > 0 && len(g.opt.GRPC) > 0 {
+		g.generateGRPCFiles()
+	}
+	if len(g.errors) == 0 && len(g.components) > 0 && len(g.opt.HTTP) > 0 {
+		g.generateHTTPFiles()
+	}
+}
+
+// selectComponents returns the components selected by the provided patterns
+// of the provided flag, e.g., -grpc. A pattern selects a component if it is
+// the component's full name, e.g., "github.com/my/app/cart/Cart", or a suffix
+// of it made of whole path elements, e.g., "cart/Cart" or "Cart". Type
+// arguments may be omitted from the names of generic components.
+func (g *generator) selectComponents(flag string, patterns []string) []*component {
+	var selected []*component
+	for _, comp := range g.components {
+		base := path.Join(comp.iface.Obj().Pkg().Path(), comp.iface.Obj().Name())
+		matched := false
+		for _, pattern := range patterns {
+			for _, name := range []string{comp.fullName, base} {
+				if name == pattern || strings.HasSuffix(name, "/"+pattern) {
+					matched = true
+					g.selected = append(g.selected, flag+" "+pattern)
+					break
+				}
+			}
+		}
+		if matched {
+			selected = append(selected, comp)
+		}
+	}
+	return selected
 }
 
 func (g *generator) findComponents(f *ast.File) {
@@ -313,12 +480,49 @@ func (g *generator) findAutoMarshals(f *ast.File) []*types.Named {
 }
 
 func (g *generator) processComponentImplementation(file *ast.File, spec ast.Spec) {
+	// A component implementation is a struct that embeds weaver.Implements:
+	//
+	//	type impl struct {
+	//	    weaver.Implements[Foo]
+	//	    ...
+	//	}
+	//
+	// A generic struct that embeds weaver.Implements is not a component
+	// implementation, but a template for component implementations. Every
+	// instantiation of the template declared with a type alias is a
+	// component implementation:
+	//
+	//	type Cache[K comparable, V any] interface { ... }
+	//	type cache[K comparable, V any] struct {
+	//	    weaver.Implements[Cache[K, V]]
+	//	    ...
+	//	}
+	//	type stringCache = cache[string, string] // implements Cache[string, string]
 	ts, ok := spec.(*ast.TypeSpec)
 	if !ok {
 		return
 	}
 	implName := ts.Name.Name
-	s, ok := ts.Type.(*ast.StructType)
+	def, ok := g.pkg.TypesInfo.Defs[ts.Name]
+	if !ok {
+		loc := g.fileset.Position(ts.Pos())
+		panic(fmt.Errorf("%v: name %v not found", loc, ts.Name))
+	}
+	n, ok := def.Type().(*types.Named)
+	if !ok {
+		// For type aliases like `type Int = int`, Int has type int and
+		// not type Named. We ignore these.
+		return
+	}
+	if ts.Assign.IsValid() && (n.Obj().Pkg() != g.tset.pkg.Types || n.TypeArgs().Len() == 0) {
+		// We only consider aliases to instantiations of generic types
+		// declared in this package.
+		return
+	}
+	if _, ok := ts.Type.(*ast.StructType); !ok && !ts.Assign.IsValid() {
+		return
+	}
+	s, ok := n.Underlying().(*types.Struct)
 	if !ok {
 		return
 	}
@@ -327,15 +531,12 @@ func (g *generator) processComponentImplementation(file *ast.File, spec ast.Spec
 	var routerType *types.Named    // Router implementation (if any)
 	var hasConfig bool             // Does struct contain weaver.WithConfig[] field?
 
-	for _, f := range s.Fields.List {
-		if len(f.Names) != 0 {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !f.Embedded() {
 			continue // Only an embedded field counts
 		}
-		typeAndValue, ok := g.tset.pkg.TypesInfo.Types[f.Type]
-		if !ok {
-			continue
-		}
-		t := typeAndValue.Type
+		t := f.Type()
 
 		switch {
 		case isWeaverImplements(t):
@@ -354,19 +555,10 @@ func (g *generator) processComponentImplementation(file *ast.File, spec ast.Spec
 				g.errorf(f.Pos(), "weaver.Implements argument %s is not an interface.", g.tset.typeString(cn))
 				return
 			}
-
-			def, ok := g.pkg.TypesInfo.Defs[ts.Name]
-			if !ok {
-				loc := g.fileset.Position(ts.Pos())
-				panic(fmt.Errorf("%v: name %v not found", loc, ts.Name))
-			}
-			n, ok := def.Type().(*types.Named)
-			if !ok {
-				// For type aliases like `type Int = int`, Int has type int and
-				// not type Named. We ignore these.
-				break
-			}
-			if !types.Implements(types.NewPointer(n), intf) {
+			// A template is checked when it is instantiated. go/types
+			// doesn't reliably check that an uninstantiated generic type
+			// implements an interface.
+			if n.TypeParams().Len() == 0 && !types.Implements(types.NewPointer(n), intf) {
 				g.errorf(f.Pos(), "type %s embeds %s but does not implement interface %v.", g.tset.typeString(n), g.tset.typeString(t), g.tset.typeString(cn))
 				return
 			}
@@ -388,23 +580,44 @@ func (g *generator) processComponentImplementation(file *ast.File, spec ast.Spec
 		return
 	}
 
-	fullName := filepath.Join(componentType.Obj().Pkg().Path(), componentType.Obj().Name())
+	if ts.TypeParams != nil && ts.TypeParams.NumFields() != 0 {
+		// A template; see above.
+		if _, ok := g.genericImpls[n.Obj()]; !ok {
+			g.genericImpls[n.Obj()] = false
+		}
+		return
+	}
+	if ts.Assign.IsValid() {
+		g.genericImpls[n.Origin().Obj()] = true
+	}
+
+	// The name of a component whose interface is an instantiation of a
+	// generic interface includes the type arguments, fully qualified, e.g.,
+	// "github.com/my/project/cache/Cache[string, github.com/my/project/product.ID]".
+	qualifier := func(pkg *types.Package) string {
+		if pkg == g.tset.pkg.Types {
+			return ""
+		}
+		return pkg.Path()
+	}
+	fullName := filepath.Join(componentType.Obj().Pkg().Path(), types.TypeString(componentType, qualifier))
 	if pos, exists := g.componentImpls[fullName]; exists {
 		g.errorf(spec.Pos(), "Duplicate implementation for component %v, other declaration: %v", fullName, g.fileset.Position(pos))
 		return
 	}
 	g.componentImpls[fullName] = spec.Pos()
 
-	if ts.TypeParams != nil && ts.TypeParams.NumFields() != 0 {
-		g.errorf(spec.Pos(), "component implementation cannot be generic")
-		return
+	name := componentType.Obj().Name()
+	if componentType.TypeArgs().Len() > 0 {
+		// The name is used as a prefix of generated identifiers.
+		name = sanitize(componentType)
 	}
-
 	comp := &component{
-		name:      componentType.Obj().Name(),
+		name:      name,
 		pos:       spec.Pos(),
 		fullName:  fullName,
 		implName:  implName,
+		iface:     componentType,
 		intf:      componentType.Underlying().(*types.Interface),
 		file:      file,
 		router:    routerType,
@@ -422,10 +635,11 @@ func (g *generator) processComponentImplementation(file *ast.File, spec ast.Spec
 }
 
 type component struct {
-	name          string           // component interface name
+	name          string           // component interface name, sanitized if generic
 	pos           token.Pos        // Location of component implementation
 	fullName      string           // package-prefixed component interface name
 	implName      string           // name of the component implementation type
+	iface         *types.Named     // component interface type
 	intf          *types.Interface // component's interface type
 	file          *ast.File        // file that contains component's implementation
 	methods       []*types.Func
@@ -461,9 +675,25 @@ func (g *generator) processMethods(comp *component) {
 			continue
 		}
 
-		// All arguments but context.Context must be serializable.
+		// All arguments but context.Context must be serializable. The last
+		// argument of a streaming method can be a stream of serializable
+		// values instead.
+		in, out := streamTypes(mt)
 		for i := 1; i < mt.Params().Len(); i++ {
 			arg := mt.Params().At(i)
+			if elem, ok := streamElem(arg.Type()); ok {
+				if in == nil || i != mt.Params().Len()-1 {
+					g.errorf(m.Pos(), bad("argument",
+						"Argument %d has type %v. Only the last argument of a method that returns a weaver.Stream can be a weaver.Stream.",
+						i, pretty(arg.Type())))
+					continue
+				}
+				for _, err := range g.tset.checkSerializable(elem) {
+					g.addError(arg.Pos(), err)
+				}
+				g.types = append(g.types, elem)
+				continue
+			}
 			errs := g.tset.checkSerializable(arg.Type())
 			for _, err := range errs {
 				g.addError(arg.Pos(), err)
@@ -485,9 +715,22 @@ func (g *generator) processMethods(comp *component) {
 			continue
 		}
 
-		// All results but error must be serializable.
+		// All results but error must be serializable. A streaming method
+		// returns a stream of serializable values instead.
 		for i := 0; i < mt.Results().Len()-1; i++ {
 			res := mt.Results().At(i)
+			if elem, ok := streamElem(res.Type()); ok {
+				if out == nil {
+					g.errorf(m.Pos(), bad("return",
+						"A method that returns a weaver.Stream must return exactly a weaver.Stream and an error."))
+					continue
+				}
+				for _, err := range g.tset.checkSerializable(elem) {
+					g.addError(res.Pos(), err)
+				}
+				g.types = append(g.types, elem)
+				continue
+			}
 			for _, err := range g.tset.checkSerializable(res.Type()) {
 				g.addError(res.Pos(), err)
 			}
@@ -504,6 +747,12 @@ func (g *generator) processMethods(comp *component) {
 	}
 	comp.routingKey = routingKey
 	comp.routedMethods = routedMethods
+	for _, m := range comp.methods {
+		if in, _ := streamTypes(m.Type().(*types.Signature)); in != nil && routedMethods[m.Name()] {
+			g.errorf(m.Pos(), "Method %q of Service Weaver component %q takes a weaver.Stream and cannot be routed.",
+				m.Name(), comp.name)
+		}
+	}
 
 	// Sort into deterministic order.
 	loc := func(pos token.Pos) (string, int) {
@@ -520,6 +769,26 @@ func (g *generator) processMethods(comp *component) {
 	})
 }
 
+// streamTypes returns the types of the values streamed by a method with the
+// provided signature: out is the type of the values returned by a streaming
+// method, and in is the type of the values passed to a bidirectional
+// streaming method. A streaming method returns (weaver.Stream[out], error),
+// and a bidirectional streaming method also takes a weaver.Stream[in] as its
+// last argument. Both types are nil for other methods.
+func streamTypes(sig *types.Signature) (in, out types.Type) {
+	if sig.Results().Len() != 2 {
+		return nil, nil
+	}
+	out, ok := streamElem(sig.Results().At(0).Type())
+	if !ok {
+		return nil, nil
+	}
+	if n := sig.Params().Len(); n > 1 && !sig.Variadic() {
+		in, _ = streamElem(sig.Params().At(n - 1).Type())
+	}
+	return in, out
+}
+
 // routerMethods returns the routing key and the set of routed methods for comp.
 //
 // A developer can annotate a Service Weaver component with a router, like this:
@@ -645,11 +914,14 @@ func (g *generator) generate() {
 		g.generateImports(fn)
 	}
 
-	// Create a generated file.
-	filename := filepath.Join(g.pkgDir(), generatedCodeFile)
-	dst := files.NewWriter(filename)
-	defer dst.Cleanup()
+	g.writeFile(generatedCodeFile, header, body)
+}
 
+// writeFile formats header and body and writes them to the provided file in
+// the package's directory. The file is left untouched if it already holds the
+// same code, so that regenerating unchanged code doesn't trigger rebuilds.
+func (g *generator) writeFile(name string, header, body bytes.Buffer) {
+	var code bytes.Buffer
 	fmtAndWrite := func(buf bytes.Buffer) {
 		// Format the code.
 		b := buf.Bytes()
@@ -660,16 +932,25 @@ func (g *generator) generate() {
 		} else {
 			b = formatted
 		}
-
-		// Write to dst.
-		if _, err := io.Copy(dst, bytes.NewReader(b)); err != nil {
-			g.errors = append(g.errors, err)
-		}
+		code.Write(b)
 	}
-
 	fmtAndWrite(header)
 	fmtAndWrite(body)
+	g.write(name, code.Bytes())
+}
 
+// write writes the provided contents to the provided file in the package's
+// directory, unless the file already holds them.
+func (g *generator) write(name string, contents []byte) {
+	filename := filepath.Join(g.pkgDir(), name)
+	if old, err := os.ReadFile(filename); err == nil && bytes.Equal(old, contents) {
+		return
+	}
+	dst := files.NewWriter(filename)
+	defer dst.Cleanup()
+	if _, err := dst.Write(contents); err != nil {
+		g.errors = append(g.errors, err)
+	}
 	if err := dst.Close(); err != nil {
 		g.errors = append(g.errors, err)
 	}
@@ -712,29 +993,30 @@ func (g *generator) generateRegisteredComponents(p printFn) {
 	p(`func init() {`)
 	for _, comp := range g.components {
 		name := comp.name
+		iface := g.tset.genTypeString(comp.iface)
 
 		// E.g.,
-		//   func(impl any, caller string, tracer trace.Tracer) any {
-		//       return foo_local_stub{imple: impl.(Foo), tracer: tracer, ...}
+		//   func(impl any, tracer trace.Tracer) any {
+		//       return foo_local_stub{impl: impl.(Foo), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		//   }
-		localStubFn := fmt.Sprintf(`func(impl any, tracer %v) any { return %s_local_stub{impl: impl.(%s), tracer: tracer } }`, g.trace().qualify("Tracer"), notExported(name), name)
+		localStubFn := fmt.Sprintf(`func(impl any, tracer %v) any { return %s_local_stub{impl: impl.(%s), tracer: tracer, interceptor: %s() } }`, g.trace().qualify("Tracer"), notExported(name), iface, g.codegen().qualify("LocalInterceptor"))
 
 		// E.g.,
-		//   func(stub *codegen.Stub, caller string) any {
-		//       return Foo_stub{stub: stub, ...}
+		//   func(stub *codegen.Stub, caller string, codec codegen.Codec) any {
+		//       return Foo_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), ...}
 		//   }
 		var b strings.Builder
 		for _, m := range comp.methods {
 			fmt.Fprintf(&b, ", %sMetrics: %s(%s{Caller: caller, Component: %q, Method: %q})", notExported(m.Name()), g.codegen().qualify("MethodMetricsFor"), g.codegen().qualify("MethodLabels"), comp.fullName, m.Name())
 		}
-		clientStubFn := fmt.Sprintf(`func(stub %s, caller string) any { return %s_client_stub{stub: stub %s } }`,
-			g.codegen().qualify("Stub"), notExported(name), b.String())
+		clientStubFn := fmt.Sprintf(`func(stub %s, caller string, codec %s) any { return %s_client_stub{stub: stub, codec: codec, interceptor: %s() %s } }`,
+			g.codegen().qualify("Stub"), g.codegen().qualify("Codec"), notExported(name), g.codegen().qualify("ClientInterceptor"), b.String())
 
 		// E.g.,
-		//   func(impl any, addLoad func(uint64, float64)) codegen.Server {
-		//       return foo_server_stub{impl: impl.(Foo), addLoad: addLoad}
+		//   func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+		//       return foo_server_stub{impl: impl.(Foo), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		//   }
-		serverStubFn := fmt.Sprintf(`func(impl any, addLoad func(uint64, float64)) %s { return %s_server_stub{impl: impl.(%s), addLoad: addLoad } }`, g.codegen().qualify("Server"), notExported(name), name)
+		serverStubFn := fmt.Sprintf(`func(impl any, addLoad func(uint64, float64), codec %s) %s { return %s_server_stub{impl: impl.(%s), addLoad: addLoad, codec: codec, interceptor: %s() } }`, g.codegen().qualify("Codec"), g.codegen().qualify("Server"), notExported(name), iface, g.codegen().qualify("ServerInterceptor"))
 
 		// E.g.,
 		//	weaver.Register(weaver.Registration{
@@ -747,7 +1029,7 @@ func (g *generator) generateRegisteredComponents(p printFn) {
 		// To get a reflect.Type for an interface, we have to first get a type
 		// of its pointer and then resolve the underlying type. See:
 		//   https://pkg.go.dev/reflect#example-TypeOf
-		p(`		Iface: %s((*%s)(nil)).Elem(),`, reflect.qualify("TypeOf"), name)
+		p(`		Iface: %s((*%s)(nil)).Elem(),`, reflect.qualify("TypeOf"), iface)
 		p(`		New: func() any { return &%s{} },`, comp.implName)
 		if comp.hasConfig {
 			p(`		ConfigFn: func(i any) any { return i.(*%s).WithConfig.Config() },`, comp.implName)
@@ -774,8 +1056,9 @@ func (g *generator) generateLocalStubs(p printFn) {
 		stub := notExported(comp.name) + "_local_stub"
 		p(``)
 		p(`type %s struct{`, stub)
-		p(`	impl %s`, comp.name)
+		p(`	impl %s`, g.tset.genTypeString(comp.iface))
 		p(`	tracer %s`, g.trace().qualify("Tracer"))
+		p(`	interceptor %s`, g.codegen().qualify("Interceptor"))
 		p(`}`)
 		for _, m := range comp.methods {
 			mt := m.Type().(*types.Signature)
@@ -786,7 +1069,7 @@ func (g *generator) generateLocalStubs(p printFn) {
 			p(`	span := %s(ctx)`, g.trace().qualify("SpanFromContext"))
 			p(`	if span.SpanContext().IsValid() {`)
 			p(`		// Create a child span for this method.`)
-			p(`		ctx, span = s.tracer.Start(ctx, "%s.%s.%s", trace.WithSpanKind(trace.SpanKindInternal))`, g.pkg.Name, comp.name, m.Name())
+			p(`		ctx, span = s.tracer.Start(ctx, "%s.%s.%s", trace.WithSpanKind(trace.SpanKindInternal))`, g.pkg.Name, g.tset.typeString(comp.iface), m.Name())
 			p(`		defer func() {`)
 			p(`			if err != nil {`)
 			p(`				span.RecordError(err)`)
@@ -808,7 +1091,11 @@ func (g *generator) generateLocalStubs(p printFn) {
 			}
 			argList := b.String()
 			p(``)
-			p(`	return s.impl.%s(%s)`, m.Name(), argList)
+			p(`	if s.interceptor == nil {`)
+			p(`		return s.impl.%s(%s)`, m.Name(), argList)
+			p(`	}`)
+			g.interceptedCall(p, comp, m, fmt.Sprintf("s.impl.%s(%s)", m.Name(), argList), "err")
+			p(`	return`)
 			p(`}`)
 		}
 	}
@@ -826,6 +1113,8 @@ func (g *generator) generateClientStubs(p printFn) {
 		p(``)
 		p(`type %s struct{`, stub)
 		p(`	stub %s`, g.codegen().qualify("Stub"))
+		p(`	codec %s`, g.codegen().qualify("Codec"))
+		p(`	interceptor %s`, g.codegen().qualify("Interceptor"))
 		for _, m := range comp.methods {
 			p(`	%sMetrics *%s`, notExported(m.Name()), g.codegen().qualify("MethodMetrics"))
 		}
@@ -844,8 +1133,38 @@ func (g *generator) generateClientStubs(p printFn) {
 
 		for _, m := range comp.methods {
 			mt := m.Type().(*types.Signature)
+
+			// The stream passed to a bidirectional streaming method isn't
+			// encoded with the other arguments.
+			in, out := streamTypes(mt)
+			nargs := mt.Params().Len()
+			if in != nil {
+				nargs--
+			}
+
+			// Run the call through the interceptors, if any. The call itself
+			// is made in a separate method, e.g., callFoo for method Foo.
+			b.Reset()
+			fmt.Fprintf(&b, "ctx")
+			for i := 1; i < mt.Params().Len(); i++ {
+				if mt.Variadic() && i == mt.Params().Len()-1 {
+					fmt.Fprintf(&b, ", a%d...", i-1)
+				} else {
+					fmt.Fprintf(&b, ", a%d", i-1)
+				}
+			}
+			call := fmt.Sprintf("s.call%s(%s)", m.Name(), b.String())
 			p(``)
 			p(`func (s %s) %s(%s) (%s) {`, stub, m.Name(), g.args(mt), g.returns(mt))
+			p(`	if s.interceptor == nil {`)
+			p(`		return %s`, call)
+			p(`	}`)
+			g.interceptedCall(p, comp, m, call, "err")
+			p(`	return`)
+			p(`}`)
+
+			p(``)
+			p(`func (s %s) call%s(%s) (%s) {`, stub, m.Name(), g.args(mt), g.returns(mt))
 
 			// Update metrics.
 			p(`	// Update metrics.`)
@@ -857,7 +1176,7 @@ func (g *generator) generateClientStubs(p printFn) {
 			p(`	span := %s(ctx)`, g.trace().qualify("SpanFromContext"))
 			p(`	if span.SpanContext().IsValid() {`)
 			p(`		// Create a child span for this method.`)
-			p(`		ctx, span = s.stub.Tracer().Start(ctx, "%s.%s.%s", trace.WithSpanKind(trace.SpanKindClient))`, g.pkg.Name, comp.name, m.Name())
+			p(`		ctx, span = s.stub.Tracer().Start(ctx, "%s.%s.%s", trace.WithSpanKind(trace.SpanKindClient))`, g.pkg.Name, g.tset.typeString(comp.iface), m.Name())
 			p(`	}`)
 
 			// Handle cleanup.
@@ -880,11 +1199,31 @@ func (g *generator) generateClientStubs(p printFn) {
 			p(`	}()`)
 			p(``)
 
+			// Set the routing key, if there is one.
+			if comp.routedMethods[m.Name()] {
+				p(``)
+				p(`	// Set the shardKey.`)
+				p(`     var r %s`, g.tset.genTypeString(comp.router))
+				n := mt.Params().Len()
+				args := make([]string, n)
+				args[0] = "ctx"
+				for i := 1; i < n; i++ {
+					args[i] = fmt.Sprintf("a%d", i-1)
+				}
+				p(`	shardKey := _hash%s(r.%s(%s))`, exported(comp.name), m.Name(), strings.Join(args, ", "))
+			} else {
+				p(`	var shardKey uint64`)
+			}
+
+			if out == nil {
+				g.generateClientCodecCall(p, m, methodIndex[m.Name()], nargs)
+			}
+
 			preallocated := false
-			if mt.Params().Len() > 1 {
+			if nargs > 1 {
 				// Preallocate a perfectly sized buffer if possible.
 				canPreallocate := true
-				for i := 1; i < mt.Params().Len(); i++ { // Skip initial context.Context
+				for i := 1; i < nargs; i++ { // Skip initial context.Context
 					if !g.preallocatable(mt.Params().At(i).Type()) {
 						canPreallocate = false
 						break
@@ -894,7 +1233,7 @@ func (g *generator) generateClientStubs(p printFn) {
 					p("")
 					p("	// Preallocate a buffer of the right size.")
 					p("	size := 0")
-					for i := 1; i < mt.Params().Len(); i++ {
+					for i := 1; i < nargs; i++ {
 						at := mt.Params().At(i).Type()
 						p("	size += %s", g.size(fmt.Sprintf("a%d", i-1), at))
 					}
@@ -906,45 +1245,34 @@ func (g *generator) generateClientStubs(p printFn) {
 
 			// Invoke call.Encode.
 			b.Reset()
-			if mt.Params().Len() > 1 {
+			if nargs > 1 {
 				p(``)
 				p(`	// Encode arguments.`)
 				if !preallocated {
 					p("	enc := %s", g.codegen().qualify("NewEncoder()"))
 				}
 			}
-			for i := 1; i < mt.Params().Len(); i++ { // Skip initial context.Context
+			for i := 1; i < nargs; i++ { // Skip initial context.Context
 				at := mt.Params().At(i).Type()
 				arg := fmt.Sprintf("a%d", i-1)
 				p(`	%s`, g.encode("enc", arg, at))
 			}
 
-			// Set the routing key, if there is one.
-			if comp.routedMethods[m.Name()] {
-				p(``)
-				p(`	// Set the shardKey.`)
-				p(`     var r %s`, g.tset.genTypeString(comp.router))
-				n := mt.Params().Len()
-				args := make([]string, n)
-				args[0] = "ctx"
-				for i := 1; i < n; i++ {
-					args[i] = fmt.Sprintf("a%d", i-1)
-				}
-				p(`	shardKey := _hash%s(r.%s(%s))`, exported(comp.name), m.Name(), strings.Join(args, ", "))
-			} else {
-				p(`	var shardKey uint64`)
-			}
-
 			// Invoke call.Run.
 			p(``)
 			p(`	// Call the remote method.`)
 			data := "nil"
-			if mt.Params().Len() > 1 {
+			if nargs > 1 {
 				data = "enc.Data()"
 				p(`	s.%sMetrics.BytesRequest.Put(float64(len(enc.Data())))`, notExported(m.Name()))
 			} else {
 				p(`	s.%sMetrics.BytesRequest.Put(0)`, notExported(m.Name()))
 			}
+			if out != nil {
+				g.generateClientStream(p, methodIndex[m.Name()], data, nargs, in, out)
+				p(`}`)
+				continue
+			}
 			p(`	var results []byte`)
 			p(`	results, err = s.stub.Run(ctx, %d, %s, shardKey)`, methodIndex[m.Name()], data)
 			p(`	if err != nil {`)
@@ -981,6 +1309,111 @@ func (g *generator) generateClientStubs(p printFn) {
 	}
 }
 
+// generateClientCodecCall generates code that makes a call to method m, with
+// the provided index and number of arguments, including the initial context,
+// if the client stub has a codec. The arguments and results of the call are
+// encoded with the codec.
+func (g *generator) generateClientCodecCall(p printFn, m *types.Func, index, nargs int) {
+	mt := m.Type().(*types.Signature)
+	metrics := notExported(m.Name()) + "Metrics"
+	args := make([]string, 0, nargs)
+	args = append(args, "s.codec")
+	for i := 1; i < nargs; i++ {
+		args = append(args, fmt.Sprintf("a%d", i-1))
+	}
+	results := []string{"s.codec", "results"}
+	for i := 0; i < mt.Results().Len()-1; i++ { // Skip final error
+		results = append(results, fmt.Sprintf("&r%d", i))
+	}
+
+	p(``)
+	p(`	if s.codec != nil {`)
+	p(`		// Call the remote method, with the component's codec.`)
+	p(`		args := %s(%s)`, g.codegen().qualify("EncodeArgs"), strings.Join(args, ", "))
+	p(`		s.%s.BytesRequest.Put(float64(len(args)))`, metrics)
+	p(`		var results []byte`)
+	p(`		results, err = s.stub.Run(ctx, %d, args, shardKey)`, index)
+	p(`		if err != nil {`)
+	p(`			return`)
+	p(`		}`)
+	p(`		s.%s.BytesReply.Put(float64(len(results)))`, metrics)
+	p(`		err = %s(%s)`, g.codegen().qualify("DecodeResults"), strings.Join(results, ", "))
+	p(`		return`)
+	p(`	}`)
+}
+
+// interceptedCall generates code that runs the provided call to method m of
+// component comp through s.interceptor. The call's results are assigned to
+// r0, r1, and so on, and its error is assigned to errVar.
+func (g *generator) interceptedCall(p printFn, comp *component, m *types.Func, call, errVar string) {
+	mt := m.Type().(*types.Signature)
+	args := make([]string, 0, mt.Params().Len()-1)
+	for i := 1; i < mt.Params().Len(); i++ { // Skip initial context.Context
+		args = append(args, fmt.Sprintf("a%d", i-1))
+	}
+	info := fmt.Sprintf(`%s{Component: %q, Method: %q}`, g.codegen().qualify("CallInfo"), comp.fullName, m.Name())
+	if len(args) > 0 {
+		info = fmt.Sprintf(`%s{Component: %q, Method: %q, Args: []any{%s}}`, g.codegen().qualify("CallInfo"), comp.fullName, m.Name(), strings.Join(args, ", "))
+	}
+
+	if mt.Results().Len() == 1 {
+		p(`	%s = s.interceptor(ctx, %s, func(ctx context.Context) error {`, errVar, info)
+		p(`		return %s`, call)
+		p(`	})`)
+		return
+	}
+	results := make([]string, 0, mt.Results().Len())
+	for i := 0; i < mt.Results().Len()-1; i++ { // Skip final error
+		results = append(results, fmt.Sprintf("r%d", i))
+	}
+	p(`	%s = s.interceptor(ctx, %s, func(ctx context.Context) (err error) {`, errVar, info)
+	p(`		%s, err = %s`, strings.Join(results, ", "), call)
+	p(`		return`)
+	p(`	})`)
+}
+
+// generateClientStream generates the code that calls the streaming method
+// with the provided index in a client stub. The encoded arguments are in
+// data. For a bidirectional streaming method, the stream passed to the method
+// is argument nargs-1 (ignoring the initial context.Context).
+func (g *generator) generateClientStream(p printFn, index int, data string, nargs int, in, out types.Type) {
+	p(`	var stream %s`, g.codegen().qualify("ClientStream"))
+	p(`	stream, err = s.stub.Stream(ctx, %d, %s, shardKey)`, index, data)
+	p(`	if err != nil {`)
+	p(`		return`)
+	p(`	}`)
+	p(``)
+	p(`	// Decode the streamed results.`)
+	if in == nil {
+		p(`	r0 = %s[%s](stream, func(dec *%s) (v %s) {`,
+			g.codegen().qualify("ClientValues"), g.tset.genTypeString(out),
+			g.codegen().qualify("Decoder"), g.tset.genTypeString(out))
+	} else {
+		p(`	r0 = %s[%s, %s](stream, a%d, func(enc *%s, v %s) {`,
+			g.codegen().qualify("BidiClientValues"), g.tset.genTypeString(in), g.tset.genTypeString(out),
+			nargs-1, g.codegen().qualify("Encoder"), g.tset.genTypeString(in))
+		p(`		%s`, g.encode("enc", "v", in))
+		p(`	}, func(dec *%s) (v %s) {`, g.codegen().qualify("Decoder"), g.tset.genTypeString(out))
+	}
+	g.decodeStreamed(p, out)
+	p(`	}, s.stub.WrapError)`)
+	p(`	return`)
+}
+
+// decodeStreamed generates the body of a function that decodes a value of
+// type t streamed to or from a method from dec into v.
+func (g *generator) decodeStreamed(p printFn, t types.Type) {
+	if x, ok := t.(*types.Pointer); ok && (g.tset.isProto(x) || g.tset.hasMarshalBinary(x)) {
+		// See the decoding of results in generateClientStubs.
+		p(`		var tmp %s`, g.tset.genTypeString(x.Elem()))
+		p(`		%s`, g.decode("dec", ref("tmp"), x.Elem()))
+		p(`		v = %s`, ref("tmp"))
+	} else {
+		p(`		%s`, g.decode("dec", ref("v"), t))
+	}
+	p(`		return`)
+}
+
 // args returns a textual representation of the arguments of the provided
 // signature. The first argument must be a context.Context. The returned code
 // names the first argument ctx and all subsequent arguments a0, a1, and so on.
@@ -1025,7 +1458,7 @@ func (g *generator) preallocatable(t types.Type) bool {
 //
 // REQUIRES: t is serializable.
 func (g *generator) isWeaverEncoded(t types.Type) bool {
-	if g.tset.isProto(t) || g.tset.hasMarshalBinary(t) {
+	if g.tset.isProto(t) || g.tset.hasMarshalBinary(t) || g.tset.hasMarshalText(t) {
 		return false
 	}
 
@@ -1299,14 +1732,19 @@ func (g *generator) generateServerStubs(p printFn) {
 		stub := fmt.Sprintf("%s_server_stub", notExported(comp.name))
 		p(``)
 		p(`type %s struct{`, stub)
-		p(`	impl %s`, comp.name)
+		p(`	impl %s`, g.tset.genTypeString(comp.iface))
 		p(`	addLoad func(key uint64, load float64)`)
+		p(`	codec %s`, g.codegen().qualify("Codec"))
+		p(`	interceptor %s`, g.codegen().qualify("Interceptor"))
 		p(`}`)
 		p(``)
 		p(`// GetStubFn implements the stub.Server interface.`)
 		p(`func (s %s) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {`, stub)
 		p(`	switch method {`)
 		for _, m := range comp.methods {
+			if _, out := streamTypes(m.Type().(*types.Signature)); out != nil {
+				continue
+			}
 			p(`	case "%s":`, m.Name())
 			p(`		return s.%s`, notExported(m.Name()))
 		}
@@ -1314,14 +1752,49 @@ func (g *generator) generateServerStubs(p printFn) {
 		p(`		return nil`)
 		p(`	}`)
 		p(`}`)
+		p(``)
+		p(`// GetStreamFn implements the stub.Server interface.`)
+		p(`func (s %s) GetStreamFn(method string) func(ctx context.Context, args []byte, stream %s) error {`, stub, g.codegen().qualify("ServerStream"))
+		var streaming []*types.Func
+		for _, m := range comp.methods {
+			if _, out := streamTypes(m.Type().(*types.Signature)); out != nil {
+				streaming = append(streaming, m)
+			}
+		}
+		if len(streaming) == 0 {
+			p(`	return nil`)
+		} else {
+			p(`	switch method {`)
+			for _, m := range streaming {
+				p(`	case "%s":`, m.Name())
+				p(`		return s.%s`, notExported(m.Name()))
+			}
+			p(`	default:`)
+			p(`		return nil`)
+			p(`	}`)
+		}
+		p(`}`)
 
 		// Generate server stub implementation for the methods exported by the component.
 		for _, m := range comp.methods {
 			mt := m.Type().(*types.Signature)
 
+			// The stream passed to a bidirectional streaming method isn't
+			// encoded with the other arguments.
+			in, out := streamTypes(mt)
+			nargs := mt.Params().Len()
+			if in != nil {
+				nargs--
+			}
+
 			p(``)
-			p(`func (s %s) %s(ctx context.Context, args []byte) (res []byte, err error) {`,
-				stub, notExported(m.Name()))
+			if out != nil {
+				p(`func (s %s) %s(ctx context.Context, args []byte, stream %s) (err error) {`,
+					stub, notExported(m.Name()), g.codegen().qualify("ServerStream"))
+			} else {
+				p(`func (s %s) %s(ctx context.Context, args []byte) (res []byte, err error) {`,
+					stub, notExported(m.Name()))
+			}
 
 			// Handle errors triggered during execution.
 			p(`	// Catch and return any panics detected during encoding/decoding/rpc.`)
@@ -1331,28 +1804,31 @@ func (g *generator) generateServerStubs(p printFn) {
 			p(`		}`)
 			p(`	}()`)
 
-			if mt.Params().Len() > 1 {
+			if out != nil {
+				// The arguments of streaming methods are always encoded by
+				// Service Weaver.
+				g.decodeArgs(p, mt, nargs, "")
+			} else if nargs > 1 {
 				p(``)
 				p(`	// Decode arguments.`)
-				p(`	dec := %s(args)`, g.codegen().qualify("NewDecoder"))
-			}
-			b.Reset()
-			for i := 1; i < mt.Params().Len(); i++ { // Skip initial context.Context
-				at := mt.Params().At(i).Type()
-				arg := fmt.Sprintf("a%d", i-1)
-				if x, ok := at.(*types.Pointer); ok && (g.tset.isProto(x) || g.tset.hasMarshalBinary(x)) {
-					// To decode a pointer *t where t is a proto or
-					// BinaryUnmarshaler, we need to instantiate a zero value
-					// of type t before calling the appropriate decoding
-					// function. For all other types, this is unnecessary.
-					tmp := fmt.Sprintf("tmp%d", i)
-					p(`	var %s %s`, tmp, g.tset.genTypeString(x.Elem()))
-					p(`	%s`, g.decode("dec", ref(tmp), x.Elem()))
-					p(`	%s := %s`, arg, ref(tmp))
-				} else {
-					p(`	var %s %s`, arg, g.tset.genTypeString(at))
-					p(`	%s`, g.decode("dec", ref(arg), at))
+				ptrs := []string{"s.codec", "args"}
+				for i := 1; i < nargs; i++ { // Skip initial context.Context
+					p(`	var a%d %s`, i-1, g.tset.genTypeString(mt.Params().At(i).Type()))
+					ptrs = append(ptrs, fmt.Sprintf("&a%d", i-1))
 				}
+				p(`	if s.codec != nil {`)
+				p(`		%s(%s)`, g.codegen().qualify("DecodeArgs"), strings.Join(ptrs, ", "))
+				p(`	} else {`)
+				g.decodeArgs(p, mt, nargs, "	")
+				p(`	}`)
+			}
+
+			if in != nil {
+				p(`	a%d := %s[%s](stream, func(dec *%s) (v %s) {`, nargs-1,
+					g.codegen().qualify("ServerValues"), g.tset.genTypeString(in),
+					g.codegen().qualify("Decoder"), g.tset.genTypeString(in))
+				g.decodeStreamed(p, in)
+				p(`	})`)
 			}
 
 			b.Reset()
@@ -1378,12 +1854,14 @@ func (g *generator) generateServerStubs(p printFn) {
 			p(`	// user code: fix this.`)
 			p(`	// Call the local method.`)
 			for i := 0; i < mt.Results().Len()-1; i++ { // Skip final error
+				p(`	var r%d %s`, i, g.tset.genTypeString(mt.Results().At(i).Type()))
 				if b.Len() == 0 {
 					fmt.Fprintf(&b, "r%d", i)
 				} else {
 					fmt.Fprintf(&b, ", r%d", i)
 				}
 			}
+			p(`	var appErr error`)
 
 			var res string
 			if b.Len() == 0 {
@@ -1392,10 +1870,34 @@ func (g *generator) generateServerStubs(p printFn) {
 				res = fmt.Sprintf("%s, appErr", b.String())
 			}
 
-			p(`	%s := s.impl.%s(%s)`, res, m.Name(), argList)
+			call := fmt.Sprintf("s.impl.%s(%s)", m.Name(), argList)
+			p(`	if s.interceptor == nil {`)
+			p(`		%s = %s`, res, call)
+			p(`	} else {`)
+			g.interceptedCall(p, comp, m, call, "appErr")
+			p(`	}`)
+
+			if out != nil {
+				p(``)
+				p(`	// Stream the results.`)
+				p(`	return %s[%s](stream, r0, appErr, func(enc *%s, v %s) {`,
+					g.codegen().qualify("SendServerValues"), g.tset.genTypeString(out),
+					g.codegen().qualify("Encoder"), g.tset.genTypeString(out))
+				p(`		%s`, g.encode("enc", "v", out))
+				p(`	})`)
+				p(`}`)
+				continue
+			}
 
 			p(``)
 			p(`	// Encode the results.`)
+			results := []string{"s.codec", "appErr"}
+			for i := 0; i < mt.Results().Len()-1; i++ { // Skip final error
+				results = append(results, fmt.Sprintf("r%d", i))
+			}
+			p(`	if s.codec != nil {`)
+			p(`		return %s(%s), nil`, g.codegen().qualify("EncodeResults"), strings.Join(results, ", "))
+			p(`	}`)
 			p(` enc := %s()`, g.codegen().qualify("NewEncoder"))
 
 			b.Reset()
@@ -1411,6 +1913,46 @@ func (g *generator) generateServerStubs(p printFn) {
 	}
 }
 
+// decodeArgs generates code that decodes the arguments of a method with the
+// provided signature and number of arguments, including the initial context,
+// from args, with the generated encoding. If indent is empty, the code
+// declares the arguments. Otherwise, they must already be declared, and every
+// generated line is prefixed with indent.
+func (g *generator) decodeArgs(p printFn, mt *types.Signature, nargs int, indent string) {
+	if nargs <= 1 {
+		return
+	}
+	declare := indent == ""
+	if declare {
+		p(``)
+		p(`	// Decode arguments.`)
+	}
+	p(`%s	dec := %s(args)`, indent, g.codegen().qualify("NewDecoder"))
+	for i := 1; i < nargs; i++ { // Skip initial context.Context
+		at := mt.Params().At(i).Type()
+		arg := fmt.Sprintf("a%d", i-1)
+		if x, ok := at.(*types.Pointer); ok && (g.tset.isProto(x) || g.tset.hasMarshalBinary(x)) {
+			// To decode a pointer *t where t is a proto or
+			// BinaryUnmarshaler, we need to instantiate a zero value
+			// of type t before calling the appropriate decoding
+			// function. For all other types, this is unnecessary.
+			tmp := fmt.Sprintf("tmp%d", i)
+			p(`%s	var %s %s`, indent, tmp, g.tset.genTypeString(x.Elem()))
+			p(`%s	%s`, indent, g.decode("dec", ref(tmp), x.Elem()))
+			if declare {
+				p(`	%s := %s`, arg, ref(tmp))
+			} else {
+				p(`%s	%s = %s`, indent, arg, ref(tmp))
+			}
+		} else {
+			if declare {
+				p(`	var %s %s`, arg, g.tset.genTypeString(at))
+			}
+			p(`%s	%s`, indent, g.decode("dec", ref(arg), at))
+		}
+	}
+}
+
 // generateAutoMarshalMethods generates WeaverMarshal and WeaverUnmarshal methods
 // for any types that declares itself as weaver.AutoMarshal.
 func (g *generator) generateAutoMarshalMethods(p printFn) {
@@ -1442,13 +1984,21 @@ func (g *generator) generateAutoMarshalMethods(p printFn) {
 		p(`	if x == nil {`)
 		p(`		panic(%s("%s.WeaverMarshal: nil receiver"))`, fmt.qualify("Errorf"), ts(t))
 		p(`	}`)
+		var fields []*types.Var
 		for i := 0; i < s.NumFields(); i++ {
-			fi := s.Field(i)
-			if !isWeaverAutoMarshal(fi.Type()) {
-				p(`	%s`, g.encode("enc", "x."+fi.Name(), fi.Type()))
-				innerTypes = append(innerTypes, fi.Type())
+			if fi := s.Field(i); !isWeaverAutoMarshal(fi.Type()) {
+				fields = append(fields, fi)
 			}
 		}
+		// The fields are framed by a header, so that a struct can be decoded
+		// by a version of the struct with more or fewer trailing fields. See
+		// codegen.Encoder.BeginStruct.
+		p(`	start := enc.BeginStruct(%d)`, len(fields))
+		for _, fi := range fields {
+			p(`	%s`, g.encode("enc", "x."+fi.Name(), fi.Type()))
+			innerTypes = append(innerTypes, fi.Type())
+		}
+		p(`	enc.EndStruct(start)`)
 		p(`}`)
 
 		// Generate WeaverUnmarshal method.
@@ -1457,12 +2007,18 @@ func (g *generator) generateAutoMarshalMethods(p printFn) {
 		p(`	if x == nil {`)
 		p(`		panic(%s("%s.WeaverUnmarshal: nil receiver"))`, fmt.qualify("Errorf"), ts(t))
 		p(`	}`)
-		for i := 0; i < s.NumFields(); i++ {
-			fi := s.Field(i)
-			if !isWeaverAutoMarshal(fi.Type()) {
-				p(`	%s`, g.decode("dec", "&x."+fi.Name(), fi.Type()))
-			}
+		if len(fields) == 0 {
+			p(`	_, end := dec.BeginStruct()`)
+		} else {
+			p(`	n, end := dec.BeginStruct()`)
 		}
+		for i, fi := range fields {
+			// Fields missing from the encoding are left unset.
+			p(`	if n > %d {`, i)
+			p(`		%s`, g.decode("dec", "&x."+fi.Name(), fi.Type()))
+			p(`	}`)
+		}
+		p(`	dec.EndStruct(end)`)
 		p(`}`)
 
 		// Generate encoding/decoding methods for any inner types.
@@ -1567,6 +2123,7 @@ func (g *generator) encode(stub, e string, t types.Type) string {
 	// enc(stub, e: type t u) = stub.EncodeProto(&e)           // t implements proto.Message
 	// enc(stub, e: type t u) = (e).WeaverMarshal(stub)         // t implements AutoMarshal
 	// enc(stub, e: type t u) = stub.EncodeBinaryMarshaler(&e) // t implements BinaryMarshaler
+	// enc(stub, e: type t u) = stub.EncodeTextMarshaler(&e)   // u = struct{...}, t implements TextMarshaler
 	// enc(stub, e: type t u) = serviceweaver_enc_[t](&stub, &e)       // under(u) = struct{...}
 	// enc(stub, e: type t u) = enc(&stub, under(t)(e))        // otherwise
 	switch x := t.(type) {
@@ -1608,6 +2165,9 @@ func (g *generator) encode(stub, e string, t types.Type) string {
 		if g.tset.hasMarshalBinary(x) {
 			return fmt.Sprintf("%s.EncodeBinaryMarshaler(%s)", stub, ref(e))
 		}
+		if g.tset.hasMarshalText(x) {
+			return fmt.Sprintf("%s.EncodeTextMarshaler(%s)", stub, ref(e))
+		}
 		under := x.Underlying()
 		if _, ok := under.(*types.Struct); ok {
 			return fmt.Sprintf("%s(%s, %s)", f(x), stub, ref(e))
@@ -1640,6 +2200,7 @@ func (g *generator) decode(stub, v string, t types.Type) string {
 	// dec(stub, v: type t u) = stub.DecodeProto(v)             // t implements proto.Message
 	// dec(stub, v: type t u) = (v).WeaverUnmarshal(stub)        // t implements AutoMarshal
 	// dec(stub, v: type t u) = stub.DecodeBinaryUnmarshaler(v) // t implements BinaryUnmarshaler
+	// dec(stub, v: type t u) = stub.DecodeTextUnmarshaler(v)   // u = struct{...}, t implements TextUnmarshaler
 	// dec(stub, v: type t u) = serviceweaver_dec_[t](stub, v)          // under(u) = struct{...}
 	// dec(stub, v: type t u) = dec(stub, (*under(t))(v))       // otherwise
 	switch x := t.(type) {
@@ -1681,6 +2242,9 @@ func (g *generator) decode(stub, v string, t types.Type) string {
 		if g.tset.hasMarshalBinary(x) {
 			return fmt.Sprintf("%s.DecodeBinaryUnmarshaler(%s)", stub, v)
 		}
+		if g.tset.hasMarshalText(x) {
+			return fmt.Sprintf("%s.DecodeTextUnmarshaler(%s)", stub, v)
+		}
 		under := x.Underlying()
 		if _, ok := under.(*types.Struct); ok {
 			return fmt.Sprintf("%s(%s, %s)", f(x), stub, v)
@@ -1842,9 +2406,10 @@ func (g *generator) generateEncDecMethodsFor(p printFn, t types.Type) {
 		panic(fmt.Sprintf("generateEncDecFor: unexpected type: %v", t))
 
 	case *types.Named:
-		if g.tset.isProto(x) || g.tset.automarshals.At(x) != nil || g.tset.implementsAutoMarshal(x) || g.tset.hasMarshalBinary(x) {
-			// Types implementing proto.Marshal, weaver.AutoMarshal, or
-			// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler don't
+		if g.tset.isProto(x) || g.tset.automarshals.At(x) != nil || g.tset.implementsAutoMarshal(x) || g.tset.hasMarshalBinary(x) || g.tset.hasMarshalText(x) {
+			// Types implementing proto.Marshal, weaver.AutoMarshal,
+			// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, or
+			// encoding.TextMarshaler and encoding.TextUnmarshaler don't
 			// need encoding or decoding methods. Instead, we call methods
 			// directly on a codegen.Encoder or codegen.Decoder (e.g.,
 			// enc.EncodeProto(x), dec.DecodeBinaryUnmarshaler(x)).
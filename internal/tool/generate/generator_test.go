@@ -99,7 +99,7 @@ func runGenerator(t *testing.T, directory, filename, contents string, subdirs []
 	}
 
 	// Run "weaver generate".
-	if err := Generate(tmp, []string{tmp}); err != nil {
+	if err := Generate(tmp, []string{tmp}, Options{}); err != nil {
 		return "", err
 	}
 	output, err := os.ReadFile(filepath.Join(tmp, generatedCodeFile))
@@ -296,6 +296,174 @@ func TestGeneratorErrors(t *testing.T) {
 	}
 }
 
+func TestGenerateMocks(t *testing.T) {
+	// Test plan: Run "weaver generate -mocks" on a package with an exported
+	// and an unexported component, and run tests that use the stub functions
+	// and the call expectations of the generated mocks.
+	const src = `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Adder interface {
+	Add(context.Context, int, ...int) (int, error)
+}
+
+type adder struct {
+	weaver.Implements[Adder]
+}
+
+func (adder) Add(_ context.Context, x int, ys ...int) (int, error) {
+	for _, y := range ys {
+		x += y
+	}
+	return x, nil
+}
+
+type pinger interface {
+	Ping(context.Context) error
+}
+
+type pingerImpl struct {
+	weaver.Implements[pinger]
+}
+
+func (pingerImpl) Ping(context.Context) error { return nil }
+`
+	const test = `package foo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+)
+
+func TestMocks(t *testing.T) {
+	ctx := context.Background()
+
+	var adder MockAdder
+	adder.AddFn = func(_ context.Context, x int, ys ...int) (int, error) {
+		return x + len(ys), nil
+	}
+	if got, err := adder.Add(ctx, 1, 2, 3); err != nil || got != 3 {
+		t.Errorf("Add: got (%d, %v), want (3, nil)", got, err)
+	}
+	want := []any{1, []int{2, 3}}
+	if calls := adder.Calls("Add"); len(calls) != 1 || !reflect.DeepEqual(calls[0].Args, want) {
+		t.Errorf("Calls: got %v, want one call with args %v", calls, want)
+	}
+
+	oops := errors.New("oops")
+	p := mockPinger{Err: oops}
+	if err := p.Ping(ctx); err != oops {
+		t.Errorf("Ping: got %v, want %v", err, oops)
+	}
+	p.ResetCalls()
+	if calls := p.Calls(""); len(calls) != 0 {
+		t.Errorf("Calls after ResetCalls: got %v, want none", calls)
+	}
+}
+
+// fakeT records the errors reported by AssertExpectations.
+type fakeT struct{ errors []string }
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpectations(t *testing.T) {
+	ctx := context.Background()
+
+	var adder MockAdder
+	adder.AddFn = func(context.Context, int, ...int) (int, error) {
+		return 0, errors.New("stub called")
+	}
+	even := codegen.Match("even", func(x int) bool { return x%2 == 0 })
+	adder.ExpectAdd(codegen.Eq(1), codegen.Any[[]int]()).Return(10, nil).Times(2)
+	adder.ExpectAdd(even, nil).Do(func(_ context.Context, x int, ys ...int) (int, error) {
+		return x * len(ys), nil
+	}).AnyTimes()
+	adder.ExpectAdd(codegen.Eq(3), codegen.Eq([]int{4}))
+
+	for _, test := range []struct {
+		x    int
+		ys   []int
+		want int
+	}{
+		{1, nil, 10},
+		{1, []int{2, 3}, 10},
+		{2, []int{1, 1, 1}, 6},
+		{4, nil, 0},
+	} {
+		if got, err := adder.Add(ctx, test.x, test.ys...); err != nil || got != test.want {
+			t.Errorf("Add(%d, %v): got (%d, %v), want (%d, nil)", test.x, test.ys, got, err, test.want)
+		}
+	}
+	if _, err := adder.Add(ctx, 1); err == nil {
+		t.Errorf("Add(1): called too many times, but no error")
+	}
+	if _, err := adder.Add(ctx, 5); err == nil {
+		t.Errorf("Add(5): unexpected call, but no error")
+	}
+
+	var ft fakeT
+	adder.AssertExpectations(&ft)
+	if len(ft.errors) != 3 {
+		t.Errorf("AssertExpectations: got errors %q, want a missing call and two unexpected calls", ft.errors)
+	}
+	adder.ResetExpectations()
+	if got, err := adder.Add(ctx, 1); err == nil || err.Error() != "stub called" {
+		t.Errorf("Add after ResetExpectations: got (%d, %v), want stub error", got, err)
+	}
+
+	var p mockPinger
+	p.ExpectPing().Return(nil)
+	if err := p.Ping(ctx); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	ft = fakeT{}
+	p.AssertExpectations(&ft)
+	if len(ft.errors) != 0 {
+		t.Errorf("AssertExpectations: unexpected errors %q", ft.errors)
+	}
+}
+`
+	tmp := t.TempDir()
+	for f, data := range map[string]string{"foo.go": src, "foo_test.go": test, "go.mod": goModFile} {
+		if err := os.WriteFile(filepath.Join(tmp, f), []byte(data), 0644); err != nil {
+			t.Fatalf("error writing %s: %v", f, err)
+		}
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("go", args...)
+		cmd.Dir = tmp
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("go %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	run("mod", "tidy")
+	if err := Generate(tmp, []string{tmp}, Options{Mocks: true}); err != nil {
+		t.Fatalf("error running generator: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, generatedMocksFile)); err != nil {
+		t.Fatal(err)
+	}
+	run("mod", "tidy")
+	run("test", ".")
+}
+
 func TestSanitize(t *testing.T) {
 	// Test plan: Check that sanitize returns the expected sanitized name for
 	// various types. Also check that sanitize is injective; i.e. every type
@@ -0,0 +1,859 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// The gRPC gateway of a component is a gRPC service with one method per
+// component method, declared in the weaver_gen.proto file of the component's
+// package. A request message holds the arguments of a component method, and a
+// reply message holds its results. A method that returns a weaver.Stream is a
+// server streaming method whose replies hold one value each. Named structs
+// are represented by messages, slices by repeated fields, and maps by map
+// fields.
+//
+// The weaver_gen_grpc.go file holds a Go struct for every message. The
+// structs carry protobuf struct tags, from which the protobuf runtime derives
+// the messages' descriptors, so that no protoc generated code is needed. For
+// every component interface Foo, the file also holds a RegisterFooGRPCServer
+// function that registers a Foo with a gRPC server.
+
+// protoScalar describes how values of a scalar Go type are represented in
+// protocol buffers.
+type protoScalar struct {
+	proto  string // protocol buffer type, e.g., "int64"
+	goType string // Go type of message fields, e.g., "int64"
+	wire   string // encoding in protobuf struct tags, e.g., "varint"
+}
+
+var (
+	protoScalars = map[types.BasicKind]protoScalar{
+		types.Bool:    {"bool", "bool", "varint"},
+		types.Int:     {"int64", "int64", "varint"},
+		types.Int8:    {"int32", "int32", "varint"},
+		types.Int16:   {"int32", "int32", "varint"},
+		types.Int32:   {"int32", "int32", "varint"},
+		types.Int64:   {"int64", "int64", "varint"},
+		types.Uint:    {"uint64", "uint64", "varint"},
+		types.Uint8:   {"uint32", "uint32", "varint"},
+		types.Uint16:  {"uint32", "uint32", "varint"},
+		types.Uint32:  {"uint32", "uint32", "varint"},
+		types.Uint64:  {"uint64", "uint64", "varint"},
+		types.Float32: {"float", "float32", "fixed32"},
+		types.Float64: {"double", "float64", "fixed64"},
+		types.String:  {"string", "string", "bytes"},
+	}
+	protoBytes = protoScalar{"bytes", "[]byte", "bytes"}
+)
+
+// grpcScalar returns how values of the provided type are represented in
+// protocol buffers, if the type is a scalar.
+func grpcScalar(t types.Type) (protoScalar, bool) {
+	if isByteSlice(t.Underlying()) {
+		return protoBytes, true
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return protoScalar{}, false
+	}
+	s, ok := protoScalars[b.Kind()]
+	return s, ok
+}
+
+// grpcMessage is a message declared in a weaver_gen.proto file.
+type grpcMessage struct {
+	name   string      // message name, e.g., "AddRequest"
+	fields []grpcField // message fields
+}
+
+// grpcField is a field of a grpcMessage.
+type grpcField struct {
+	name   string     // field name, e.g., "user_id"
+	goName string     // name of the Go struct field, e.g., "UserId"
+	t      types.Type // Go type of the values held by the field
+}
+
+// grpcMethod is a method of a gRPC service.
+type grpcMethod struct {
+	m      *types.Func  // component method
+	req    *grpcMessage // request message
+	reply  *grpcMessage // reply message
+	stream bool         // does the method stream its replies?
+}
+
+// grpcService is the gRPC service of a component.
+type grpcService struct {
+	comp    *component
+	methods []*grpcMethod
+}
+
+// grpcGenerator generates the weaver_gen.proto and weaver_gen_grpc.go files
+// of a package.
+type grpcGenerator struct {
+	*generator
+	protoPkg string         // protocol buffer package
+	services []*grpcService // gRPC services, one per selected component
+	messages []*grpcMessage // messages, in declaration order
+	structs  typeutil.Map   // the *grpcMessage for every named struct type
+	names    map[string]bool
+	helpers  typeutil.Map // types with grpc_enc_* and grpc_dec_* functions
+	pending  []types.Type // types whose helpers are yet to be generated
+}
+
+// generateGRPCFiles generates the weaver_gen.proto and weaver_gen_grpc.go
+// files, which declare and implement a gRPC service for every component
+// selected by the -grpc flag.
+func (g *generator) generateGRPCFiles() {
+	gg := &grpcGenerator{
+		// The services are written to their own file, so they need their own
+		// set of imports.
+		generator: &generator{
+			pkg:     g.pkg,
+			tset:    newTypeSet(g.pkg, g.tset.automarshals, &typeutil.Map{}),
+			fileset: g.fileset,
+			opt:     g.opt,
+		},
+		protoPkg: protoPackage(g.pkg.PkgPath),
+		names:    map[string]bool{},
+	}
+	for _, comp := range g.selectComponents("-grpc", g.opt.GRPC) {
+		gg.services = append(gg.services, &grpcService{comp: comp})
+	}
+	if len(gg.services) == 0 {
+		return
+	}
+
+	gg.processServices()
+	if len(gg.errors) == 0 {
+		var proto bytes.Buffer
+		gg.generateProto(func(format string, args ...interface{}) {
+			fmt.Fprintln(&proto, fmt.Sprintf(format, args...))
+		})
+		gg.write(generatedProtoFile, proto.Bytes())
+
+		var body bytes.Buffer
+		gg.generateServices(func(format string, args ...interface{}) {
+			fmt.Fprintln(&body, fmt.Sprintf(format, args...))
+		})
+		var header bytes.Buffer
+		gg.generateImports(func(format string, args ...interface{}) {
+			fmt.Fprintln(&header, fmt.Sprintf(format, args...))
+		})
+		gg.writeFile(generatedGRPCFile, header, body)
+	}
+	g.errors = append(g.errors, gg.errors...)
+}
+
+// protoPackage returns the protocol buffer package of the weaver_gen.proto
+// file of the Go package with the provided path, e.g.,
+// "github.com.my_org.app.cart" for "github.com/my-org/app/cart".
+func protoPackage(pkgPath string) string {
+	var parts []string
+	for _, elem := range strings.FieldsFunc(pkgPath, func(r rune) bool { return r == '/' || r == '.' }) {
+		part := []rune(elem)
+		for i, r := range part {
+			if r > unicode.MaxASCII || !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				part[i] = '_'
+			}
+		}
+		if unicode.IsDigit(part[0]) {
+			part = append([]rune{'_'}, part...)
+		}
+		parts = append(parts, string(part))
+	}
+	return strings.Join(parts, ".")
+}
+
+// snakeCase converts a Go identifier to a protocol buffer field name, e.g.,
+// "ProductID" to "product_id".
+func snakeCase(name string) string {
+	var b strings.Builder
+	rs := []rune(name)
+	for i, r := range rs {
+		if !unicode.IsUpper(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if i > 0 && rs[i-1] != '_' && (!unicode.IsUpper(rs[i-1]) || (i+1 < len(rs) && unicode.IsLower(rs[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// goFieldName returns the name of the Go struct field that holds the message
+// field with the provided name, e.g., "UserId" for "user_id".
+func goFieldName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		b.WriteString(exported(part))
+	}
+	switch s := b.String(); s {
+	case "", "Reset", "String", "ProtoMessage", "XXX_MessageName":
+		// Avoid clashes with the methods of the message.
+		return s + "_"
+	default:
+		return s
+	}
+}
+
+// addField adds a field with the provided name and type to a message.
+func (m *grpcMessage) addField(name string, t types.Type) {
+	name = snakeCase(name)
+	for _, f := range m.fields {
+		if f.name == name {
+			name = fmt.Sprintf("%s_%d", name, len(m.fields)+1)
+			break
+		}
+	}
+	m.fields = append(m.fields, grpcField{name: name, goName: goFieldName(name), t: t})
+}
+
+// processServices computes the methods and messages of the gRPC services,
+// and checks that every method can be exposed over gRPC.
+func (g *grpcGenerator) processServices() {
+	// Name the services, and then their requests and replies, before naming
+	// the messages of structs, which yield on clashes.
+	methods := map[string]int{}
+	for _, s := range g.services {
+		g.names[s.comp.name] = true
+		for _, m := range s.comp.methods {
+			methods[m.Name()]++
+		}
+	}
+	for _, s := range g.services {
+		for _, m := range s.comp.methods {
+			prefix := m.Name()
+			if methods[m.Name()] > 1 {
+				prefix = s.comp.name + m.Name()
+			}
+			method := &grpcMethod{
+				m:     m,
+				req:   g.newMessage(prefix + "Request"),
+				reply: g.newMessage(prefix + "Reply"),
+			}
+			s.methods = append(s.methods, method)
+		}
+	}
+
+	for _, s := range g.services {
+		for _, method := range s.methods {
+			g.processMethod(s.comp, method)
+		}
+	}
+}
+
+// newMessage declares a message with the provided name.
+func (g *grpcGenerator) newMessage(name string) *grpcMessage {
+	m := &grpcMessage{name: name}
+	g.names[name] = true
+	g.messages = append(g.messages, m)
+	return m
+}
+
+// processMethod computes the request and reply fields of a method.
+func (g *grpcGenerator) processMethod(comp *component, method *grpcMethod) {
+	m := method.m
+	sig := m.Type().(*types.Signature)
+	check := func(what string, t types.Type) {
+		if err := g.check(t); err != nil {
+			g.errorf(m.Pos(), "Method %s of component %q cannot be exposed over gRPC: %s has type %s, which %v.",
+				m.Name(), comp.name, what, g.tset.typeString(t), err)
+		}
+	}
+
+	in, out := streamTypes(sig)
+	if in != nil {
+		g.errorf(m.Pos(), "Method %s of component %q cannot be exposed over gRPC: methods that take a weaver.Stream are not supported.",
+			m.Name(), comp.name)
+		return
+	}
+	for i := 1; i < sig.Params().Len(); i++ {
+		arg := sig.Params().At(i)
+		name := arg.Name()
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("arg%d", i-1)
+		}
+		check(fmt.Sprintf("argument %d", i), arg.Type())
+		method.req.addField(name, arg.Type())
+	}
+	if out != nil {
+		check("the streamed value", out)
+		method.reply.addField("value", out)
+		method.stream = true
+		return
+	}
+	n := sig.Results().Len() - 1
+	for i := 0; i < n; i++ {
+		res := sig.Results().At(i)
+		name := res.Name()
+		switch {
+		case name != "" && name != "_":
+		case n == 1:
+			name = "result"
+		default:
+			name = fmt.Sprintf("result%d", i)
+		}
+		check(fmt.Sprintf("result %d", i), res.Type())
+		method.reply.addField(name, res.Type())
+	}
+}
+
+// check checks that values of the provided type can be held by a message
+// field, declaring messages for the named structs it refers to.
+func (g *grpcGenerator) check(t types.Type) error {
+	if _, ok := grpcScalar(t); ok {
+		return nil
+	}
+	switch x := t.Underlying().(type) {
+	case *types.Slice:
+		if err := g.checkElem(x.Elem()); err != nil {
+			return fmt.Errorf("holds %s, which %w", g.tset.typeString(x.Elem()), err)
+		}
+		return nil
+	case *types.Map:
+		if s, ok := grpcScalar(x.Key()); !ok || s.goType == "[]byte" || s.wire != "varint" && s.proto != "string" {
+			return fmt.Errorf("is a map whose keys are not integers, booleans, or strings")
+		}
+		if err := g.checkElem(x.Elem()); err != nil {
+			return fmt.Errorf("holds %s, which %w", g.tset.typeString(x.Elem()), err)
+		}
+		return nil
+	}
+	return g.checkElem(t)
+}
+
+// checkElem checks that values of the provided type can be elements of a
+// repeated field or values of a map field.
+func (g *grpcGenerator) checkElem(t types.Type) error {
+	if _, ok := grpcScalar(t); ok {
+		return nil
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		if _, ok := p.Elem().(*types.Named); !ok || !isStruct(p.Elem()) {
+			return fmt.Errorf("is a pointer to a type other than a named struct")
+		}
+		t = p.Elem()
+	}
+	switch x := t.(type) {
+	case *types.Named:
+		if !isStruct(x) {
+			break
+		}
+		if g.tset.isProto(x) || g.tset.hasMarshalBinary(x) || g.tset.hasMarshalText(x) || g.tset.implementsAutoMarshal(x) && !embedsAutoMarshal(x) {
+			return fmt.Errorf("has custom serialization methods")
+		}
+		return g.declareStruct(x)
+	}
+	switch t.Underlying().(type) {
+	case *types.Slice, *types.Map:
+		return fmt.Errorf("is nested in a slice or map")
+	}
+	return fmt.Errorf("has no protocol buffer representation")
+}
+
+// isStruct returns whether the underlying type of t is a struct.
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// embedsAutoMarshal returns whether the provided struct type embeds
+// weaver.AutoMarshal.
+func embedsAutoMarshal(t types.Type) bool {
+	s := t.Underlying().(*types.Struct)
+	for i := 0; i < s.NumFields(); i++ {
+		if f := s.Field(i); f.Embedded() && isWeaverAutoMarshal(f.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// declareStruct declares the message of a named struct type.
+func (g *grpcGenerator) declareStruct(t *types.Named) error {
+	if g.structs.At(t) != nil {
+		return nil
+	}
+	// Qualify the name of the message with the name of the struct's package
+	// if it clashes with another message, e.g., "MoneyT" for money.T.
+	name := t.Obj().Name()
+	if g.names[name] {
+		name = exported(t.Obj().Pkg().Name()) + name
+	}
+	if t.TypeArgs().Len() > 0 || g.names[name] {
+		name = sanitize(t)
+	}
+	msg := g.newMessage(name)
+	g.structs.Set(t, msg)
+
+	s := t.Underlying().(*types.Struct)
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if f.Embedded() && isWeaverAutoMarshal(f.Type()) {
+			continue
+		}
+		if !f.Exported() && f.Pkg() != g.pkg.Types {
+			return fmt.Errorf("has unexported field %s", f.Name())
+		}
+		if err := g.check(f.Type()); err != nil {
+			return fmt.Errorf("has field %s of type %s, which %w", f.Name(), g.tset.typeString(f.Type()), err)
+		}
+		msg.addField(f.Name(), f.Type())
+	}
+	return nil
+}
+
+// message returns the message of a named struct type, or of a pointer to one.
+func (g *grpcGenerator) message(t types.Type) *grpcMessage {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return g.structs.At(t).(*grpcMessage)
+}
+
+// protoType returns the type of a message field that holds values of type t.
+//
+// REQUIRES: g.check(t) == nil.
+func (g *grpcGenerator) protoType(t types.Type) string {
+	if s, ok := grpcScalar(t); ok {
+		return s.proto
+	}
+	switch x := t.Underlying().(type) {
+	case *types.Slice:
+		return "repeated " + g.protoType(x.Elem())
+	case *types.Map:
+		return fmt.Sprintf("map<%s, %s>", g.protoType(x.Key()), g.protoType(x.Elem()))
+	}
+	return g.message(t).name
+}
+
+// goType returns the Go type of a message field that holds values of type t.
+//
+// REQUIRES: g.check(t) == nil.
+func (g *grpcGenerator) goType(t types.Type) string {
+	if s, ok := grpcScalar(t); ok {
+		return s.goType
+	}
+	switch x := t.Underlying().(type) {
+	case *types.Slice:
+		return "[]" + g.goType(x.Elem())
+	case *types.Map:
+		return fmt.Sprintf("map[%s]%s", g.goType(x.Key()), g.goType(x.Elem()))
+	}
+	return "*grpc_" + g.message(t).name
+}
+
+// tag returns the protobuf struct tag of a message field.
+//
+// REQUIRES: g.check(f.t) == nil.
+func (g *grpcGenerator) tag(f grpcField, number int) string {
+	wire := func(t types.Type) string {
+		if s, ok := grpcScalar(t); ok {
+			return s.wire
+		}
+		return "bytes"
+	}
+	t := f.t
+	if isByteSlice(t.Underlying()) {
+		return fmt.Sprintf(`protobuf:"bytes,%d,opt,name=%s,proto3"`, number, f.name)
+	}
+	switch x := t.Underlying().(type) {
+	case *types.Slice:
+		packed := ""
+		if w := wire(x.Elem()); w != "bytes" {
+			packed = "packed,"
+		}
+		return fmt.Sprintf(`protobuf:"%s,%d,rep,%sname=%s,proto3"`, wire(x.Elem()), number, packed, f.name)
+	case *types.Map:
+		return fmt.Sprintf(`protobuf:"bytes,%d,rep,name=%s,proto3" protobuf_key:"%s,1,opt,name=key,proto3" protobuf_val:"%s,2,opt,name=value,proto3"`,
+			number, f.name, wire(x.Key()), wire(x.Elem()))
+	}
+	return fmt.Sprintf(`protobuf:"%s,%d,opt,name=%s,proto3"`, wire(t), number, f.name)
+}
+
+// generateProto generates the weaver_gen.proto file.
+func (g *grpcGenerator) generateProto(p printFn) {
+	p(`// Code generated by "weaver generate". DO NOT EDIT.`)
+	p(``)
+	p(`syntax = "proto3";`)
+	p(``)
+	p(`package %s;`, g.protoPkg)
+	for _, s := range g.services {
+		p(``)
+		p(`// %s is the gRPC gateway of the %s component.`, s.comp.name, s.comp.fullName)
+		p(`service %s {`, s.comp.name)
+		for _, method := range s.methods {
+			stream := ""
+			if method.stream {
+				stream = "stream "
+			}
+			p(`  rpc %s(%s) returns (%s%s);`, method.m.Name(), method.req.name, stream, method.reply.name)
+		}
+		p(`}`)
+	}
+	for _, msg := range g.messages {
+		p(``)
+		if len(msg.fields) == 0 {
+			p(`message %s {}`, msg.name)
+			continue
+		}
+		p(`message %s {`, msg.name)
+		for i, f := range msg.fields {
+			p(`  %s %s = %d;`, g.protoType(f.t), f.name, i+1)
+		}
+		p(`}`)
+	}
+}
+
+// registerName returns the name of the function that registers
+// implementations of the provided component with a gRPC server. The
+// function is exported iff the component interface is.
+func registerName(comp *component) string {
+	if ast.IsExported(comp.name) {
+		return "Register" + comp.name + "GRPCServer"
+	}
+	return "register" + exported(comp.name) + "GRPCServer"
+}
+
+// generateServices generates the body of the weaver_gen_grpc.go file.
+func (g *grpcGenerator) generateServices(p printFn) {
+	grpc := g.tset.importPackage("google.golang.org/grpc", "grpc")
+	g.tset.importPackage("context", "context")
+
+	p(``)
+	p(`// gRPC gateways.`)
+	for _, s := range g.services {
+		comp := s.comp
+		iface := g.tset.genTypeString(comp.iface)
+		desc := notExported(comp.name) + "_grpc_desc"
+		p(``)
+		p(`// %s registers impl with s as the %s gRPC service declared in`, registerName(comp), comp.name)
+		p(`// %s. Calls to the service are forwarded to impl, which is typically`, generatedProtoFile)
+		p(`// obtained from weaver.Ref or weaver.Get.`)
+		p(`func %s(s %s, impl %s) {`, registerName(comp), grpc.qualify("ServiceRegistrar"), iface)
+		p(`	s.RegisterService(&%s, impl)`, desc)
+		p(`}`)
+
+		p(``)
+		p(`var %s = %s{`, desc, grpc.qualify("ServiceDesc"))
+		p(`	ServiceName: %q,`, g.protoPkg+"."+comp.name)
+		p(`	HandlerType: (*%s)(nil),`, iface)
+		var methods, streams []string
+		for _, method := range s.methods {
+			if method.stream {
+				streams = append(streams, fmt.Sprintf(`{StreamName: %q, Handler: %s, ServerStreams: true},`, method.m.Name(), handlerName(comp, method)))
+			} else {
+				methods = append(methods, fmt.Sprintf(`{MethodName: %q, Handler: %s},`, method.m.Name(), handlerName(comp, method)))
+			}
+		}
+		if len(methods) > 0 {
+			p(`	Methods: []%s{`, grpc.qualify("MethodDesc"))
+			p(`		%s`, strings.Join(methods, "\n"))
+			p(`	},`)
+		}
+		if len(streams) > 0 {
+			p(`	Streams: []%s{`, grpc.qualify("StreamDesc"))
+			p(`		%s`, strings.Join(streams, "\n"))
+			p(`	},`)
+		}
+		p(`	Metadata: %q,`, generatedProtoFile)
+		p(`}`)
+
+		for _, method := range s.methods {
+			if method.stream {
+				g.generateStreamHandler(p, comp, method)
+			} else {
+				g.generateUnaryHandler(p, comp, method)
+			}
+		}
+	}
+
+	g.generateMessages(p)
+	g.generateHelpers(p)
+}
+
+// handlerName returns the name of the gRPC handler of a method.
+func handlerName(comp *component, method *grpcMethod) string {
+	return fmt.Sprintf("%s_grpc_%s", notExported(comp.name), method.m.Name())
+}
+
+// call returns the code that calls a component method with the arguments in
+// the request message in.
+func (g *grpcGenerator) call(comp *component, method *grpcMethod, ctx string) string {
+	sig := method.m.Type().(*types.Signature)
+	args := []string{ctx}
+	for i, f := range method.req.fields {
+		arg := g.decode("in."+f.goName, f.t)
+		if sig.Variadic() && i == len(method.req.fields)-1 {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	return fmt.Sprintf("srv.(%s).%s(%s)", g.tset.genTypeString(comp.iface), method.m.Name(), strings.Join(args, ", "))
+}
+
+// generateUnaryHandler generates the gRPC handler of a method that doesn't
+// stream its results.
+func (g *grpcGenerator) generateUnaryHandler(p printFn, comp *component, method *grpcMethod) {
+	grpc := g.tset.importPackage("google.golang.org/grpc", "grpc")
+	req, reply := "grpc_"+method.req.name, "grpc_"+method.reply.name
+
+	var results []string
+	for i := range method.reply.fields {
+		results = append(results, fmt.Sprintf("r%d", i))
+	}
+	results = append(results, "err")
+
+	p(``)
+	p(`func %s(srv any, ctx context.Context, dec func(any) error, interceptor %s) (any, error) {`, handlerName(comp, method), grpc.qualify("UnaryServerInterceptor"))
+	p(`	in := new(%s)`, req)
+	p(`	if err := dec(in); err != nil {`)
+	p(`		return nil, err`)
+	p(`	}`)
+	if len(method.req.fields) == 0 {
+		p(`	call := func(ctx context.Context, _ any) (any, error) {`)
+	} else {
+		p(`	call := func(ctx context.Context, req any) (any, error) {`)
+		p(`		in := req.(*%s)`, req)
+	}
+	p(`		%s := %s`, strings.Join(results, ", "), g.call(comp, method, "ctx"))
+	p(`		if err != nil {`)
+	p(`			return nil, err`)
+	p(`		}`)
+	p(`		return &%s{`, reply)
+	for i, f := range method.reply.fields {
+		p(`			%s: %s,`, f.goName, g.encode(fmt.Sprintf("r%d", i), f.t))
+	}
+	p(`		}, nil`)
+	p(`	}`)
+	p(`	if interceptor == nil {`)
+	p(`		return call(ctx, in)`)
+	p(`	}`)
+	p(`	info := &%s{Server: srv, FullMethod: %q}`, grpc.qualify("UnaryServerInfo"), fmt.Sprintf("/%s.%s/%s", g.protoPkg, comp.name, method.m.Name()))
+	p(`	return interceptor(ctx, in, info, call)`)
+	p(`}`)
+}
+
+// generateStreamHandler generates the gRPC handler of a method that returns
+// a weaver.Stream.
+func (g *grpcGenerator) generateStreamHandler(p printFn, comp *component, method *grpcMethod) {
+	grpc := g.tset.importPackage("google.golang.org/grpc", "grpc")
+	io := g.tset.importPackage("io", "io")
+	f := method.reply.fields[0]
+
+	p(``)
+	p(`func %s(srv any, stream %s) error {`, handlerName(comp, method), grpc.qualify("ServerStream"))
+	p(`	in := new(grpc_%s)`, method.req.name)
+	p(`	if err := stream.RecvMsg(in); err != nil {`)
+	p(`		return err`)
+	p(`	}`)
+	p(`	values, err := %s`, g.call(comp, method, "stream.Context()"))
+	p(`	if err != nil {`)
+	p(`		return err`)
+	p(`	}`)
+	p(`	defer values.Close()`)
+	p(`	for {`)
+	p(`		v, err := values.Recv()`)
+	p(`		if err == %s {`, io.qualify("EOF"))
+	p(`			return nil`)
+	p(`		} else if err != nil {`)
+	p(`			return err`)
+	p(`		}`)
+	p(`		if err := stream.SendMsg(&grpc_%s{%s: %s}); err != nil {`, method.reply.name, f.goName, g.encode("v", f.t))
+	p(`			return err`)
+	p(`		}`)
+	p(`	}`)
+	p(`}`)
+}
+
+// generateMessages generates a Go struct for every message. The protobuf
+// runtime derives the message descriptors from the struct tags.
+func (g *grpcGenerator) generateMessages(p printFn) {
+	protoimpl := g.tset.importPackage("google.golang.org/protobuf/runtime/protoimpl", "protoimpl")
+	for _, msg := range g.messages {
+		name := "grpc_" + msg.name
+		p(``)
+		p(`// %s is the %s.%s message.`, name, g.protoPkg, msg.name)
+		p(`type %s struct {`, name)
+		for i, f := range msg.fields {
+			p("	%s %s `%s`", f.goName, g.goType(f.t), g.tag(f, i+1))
+		}
+		p(`}`)
+		p(``)
+		p(`func (m *%s) Reset() { *m = %s{} }`, name, name)
+		p(`func (m *%s) String() string { return %s(%s(m)) }`, name, protoimpl.qualify("X.MessageStringOf"), protoimpl.qualify("X.ProtoMessageV2Of"))
+		p(`func (*%s) ProtoMessage() {}`, name)
+		p(`func (*%s) XXX_MessageName() string { return %q }`, name, g.protoPkg+"."+msg.name)
+	}
+}
+
+// encode returns an expression that converts e, of type t, to the Go type
+// of the message field that holds it.
+func (g *grpcGenerator) encode(e string, t types.Type) string {
+	if s, ok := grpcScalar(t); ok {
+		if sameScalar(t, s) {
+			return e
+		}
+		return fmt.Sprintf("%s(%s)", s.goType, e)
+	}
+	g.needHelpers(t)
+	return fmt.Sprintf("grpc_enc_%s(%s)", sanitize(t), e)
+}
+
+// decode returns an expression that converts e, a message field, to type t.
+func (g *grpcGenerator) decode(e string, t types.Type) string {
+	if s, ok := grpcScalar(t); ok {
+		if sameScalar(t, s) {
+			return e
+		}
+		return fmt.Sprintf("%s(%s)", g.tset.genTypeString(t), e)
+	}
+	g.needHelpers(t)
+	return fmt.Sprintf("grpc_dec_%s(%s)", sanitize(t), e)
+}
+
+// sameScalar returns whether t is the Go type of the message fields that
+// hold values of type t, which is represented by the provided scalar.
+func sameScalar(t types.Type, s protoScalar) bool {
+	if b, ok := t.(*types.Basic); ok {
+		return b.Name() == s.goType
+	}
+	return isByteSlice(t)
+}
+
+// needHelpers records that the grpc_enc_* and grpc_dec_* functions of the
+// provided type are needed.
+func (g *grpcGenerator) needHelpers(t types.Type) {
+	if g.helpers.At(t) == nil {
+		g.helpers.Set(t, true)
+		g.pending = append(g.pending, t)
+	}
+}
+
+// generateHelpers generates the grpc_enc_* and grpc_dec_* functions, which
+// convert values to and from the fields of messages.
+func (g *grpcGenerator) generateHelpers(p printFn) {
+	for len(g.pending) > 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+
+		name, ts, fs := sanitize(t), g.tset.genTypeString(t), g.goType(t)
+		p(``)
+		switch x := t.(type) {
+		case *types.Pointer:
+			p(`func grpc_enc_%s(x %s) %s {`, name, ts, fs)
+			p(`	if x == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	return %s`, g.encode("*x", x.Elem()))
+			p(`}`)
+			p(``)
+			p(`func grpc_dec_%s(m %s) %s {`, name, fs, ts)
+			p(`	if m == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	x := %s`, g.decode("m", x.Elem()))
+			p(`	return &x`)
+			p(`}`)
+			continue
+		}
+
+		switch x := t.Underlying().(type) {
+		case *types.Slice:
+			p(`func grpc_enc_%s(x %s) %s {`, name, ts, fs)
+			p(`	if x == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	m := make(%s, len(x))`, fs)
+			p(`	for i, v := range x {`)
+			p(`		m[i] = %s`, g.encode("v", x.Elem()))
+			p(`	}`)
+			p(`	return m`)
+			p(`}`)
+			p(``)
+			p(`func grpc_dec_%s(m %s) %s {`, name, fs, ts)
+			p(`	if m == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	x := make(%s, len(m))`, ts)
+			p(`	for i, v := range m {`)
+			p(`		x[i] = %s`, g.decode("v", x.Elem()))
+			p(`	}`)
+			p(`	return x`)
+			p(`}`)
+
+		case *types.Map:
+			p(`func grpc_enc_%s(x %s) %s {`, name, ts, fs)
+			p(`	if x == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	m := make(%s, len(x))`, fs)
+			p(`	for k, v := range x {`)
+			p(`		m[%s] = %s`, g.encode("k", x.Key()), g.encode("v", x.Elem()))
+			p(`	}`)
+			p(`	return m`)
+			p(`}`)
+			p(``)
+			p(`func grpc_dec_%s(m %s) %s {`, name, fs, ts)
+			p(`	if m == nil {`)
+			p(`		return nil`)
+			p(`	}`)
+			p(`	x := make(%s, len(m))`, ts)
+			p(`	for k, v := range m {`)
+			p(`		x[%s] = %s`, g.decode("k", x.Key()), g.decode("v", x.Elem()))
+			p(`	}`)
+			p(`	return x`)
+			p(`}`)
+
+		case *types.Struct:
+			msg := g.message(t)
+			var fields []*types.Var
+			for i := 0; i < x.NumFields(); i++ {
+				if f := x.Field(i); !f.Embedded() || !isWeaverAutoMarshal(f.Type()) {
+					fields = append(fields, f)
+				}
+			}
+			p(`func grpc_enc_%s(x %s) %s {`, name, ts, fs)
+			p(`	return &grpc_%s{`, msg.name)
+			for i, f := range fields {
+				p(`		%s: %s,`, msg.fields[i].goName, g.encode("x."+f.Name(), f.Type()))
+			}
+			p(`	}`)
+			p(`}`)
+			p(``)
+			p(`func grpc_dec_%s(m %s) %s {`, name, fs, ts)
+			p(`	var x %s`, ts)
+			p(`	if m == nil {`)
+			p(`		return x`)
+			p(`	}`)
+			for i, f := range fields {
+				p(`	x.%s = %s`, f.Name(), g.decode("m."+msg.fields[i].goName, f.Type()))
+			}
+			p(`	return x`)
+			p(`}`)
+		}
+	}
+}
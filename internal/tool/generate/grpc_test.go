@@ -0,0 +1,338 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for _, test := range []struct{ name, want string }{
+		{"x", "x"},
+		{"userID", "user_id"},
+		{"ProductID", "product_id"},
+		{"HTTPServer", "http_server"},
+		{"a0", "a0"},
+		{"already_snake", "already_snake"},
+	} {
+		if got := snakeCase(test.name); got != test.want {
+			t.Errorf("snakeCase(%q): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestProtoPackage(t *testing.T) {
+	for _, test := range []struct{ path, want string }{
+		{"foo", "foo"},
+		{"github.com/my-org/app/cart", "github.com.my_org.app.cart"},
+		{"example.com/v2/3d", "example.com.v2._3d"},
+	} {
+		if got := protoPackage(test.path); got != test.want {
+			t.Errorf("protoPackage(%q): got %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestGenerateGRPC(t *testing.T) {
+	// Test plan: Run "weaver generate -grpc" on a package with two
+	// components, one of which is selected, and run a test that calls the
+	// selected component over gRPC.
+	const src = `package foo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Currency string
+
+type Category struct {
+	weaver.AutoMarshal
+	Name string
+}
+
+type Item struct {
+	weaver.AutoMarshal
+	Name     string
+	Tags     []string
+	Price    float64
+	Unit     Currency
+	Category *Category
+}
+
+type Store interface {
+	Put(ctx context.Context, key string, item Item) error
+	Get(ctx context.Context, key string) (Item, error)
+	Total(ctx context.Context, counts map[string]int, extra ...int) (int64, error)
+	Scan(ctx context.Context, prefix string) (weaver.Stream[*Item], error)
+}
+
+type store struct {
+	weaver.Implements[Store]
+	items map[string]Item
+}
+
+func (s *store) Put(_ context.Context, key string, item Item) error {
+	s.items[key] = item
+	return nil
+}
+
+func (s *store) Get(_ context.Context, key string) (Item, error) {
+	item, ok := s.items[key]
+	if !ok {
+		return Item{}, fmt.Errorf("item %q not found", key)
+	}
+	return item, nil
+}
+
+func (s *store) Total(_ context.Context, counts map[string]int, extra ...int) (int64, error) {
+	var total int64
+	for _, n := range counts {
+		total += int64(n)
+	}
+	for _, n := range extra {
+		total += int64(n)
+	}
+	return total, nil
+}
+
+func (s *store) Scan(ctx context.Context, prefix string) (weaver.Stream[*Item], error) {
+	var keys []string
+	for key := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return weaver.NewStream(ctx, func(_ context.Context, send func(*Item) error) error {
+		for _, key := range keys {
+			item := s.items[key]
+			if err := send(&item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+type pinger interface {
+	Ping(context.Context) error
+}
+
+type pingerImpl struct {
+	weaver.Implements[pinger]
+}
+
+func (pingerImpl) Ping(context.Context) error { return nil }
+`
+	const test = `package foo
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPC(t *testing.T) {
+	ctx := context.Background()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	RegisterStoreGRPCServer(s, &store{items: map[string]Item{}})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, key := range []string{"fruit/apple", "fruit/banana", "veg/leek"} {
+		name := key[strings.Index(key, "/")+1:]
+		req := &grpc_PutRequest{Key: key, Item: &grpc_Item{Name: name, Tags: []string{"fresh"}, Price: 1.5, Unit: "EUR", Category: &grpc_Category{Name: "food"}}}
+		if err := conn.Invoke(ctx, "/foo.Store/Put", req, &grpc_PutReply{}); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	var get grpc_GetReply
+	if err := conn.Invoke(ctx, "/foo.Store/Get", &grpc_GetRequest{Key: "fruit/apple"}, &get); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := get.Result; got.Name != "apple" || len(got.Tags) != 1 || got.Price != 1.5 || got.Unit != "EUR" || got.Category.GetName() != "food" {
+		t.Fatalf("Get: got %v", got)
+	}
+	err = conn.Invoke(ctx, "/foo.Store/Get", &grpc_GetRequest{Key: "meat/ham"}, &get)
+	if status.Code(err) != codes.Unknown || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Get of a missing item: got %v, want a not found error", err)
+	}
+
+	var total grpc_TotalReply
+	req := &grpc_TotalRequest{Counts: map[string]int64{"a": 1, "b": 2}, Extra: []int64{3, 4}}
+	if err := conn.Invoke(ctx, "/foo.Store/Total", req, &total); err != nil {
+		t.Fatalf("Total: %v", err)
+	}
+	if total.Result != 10 {
+		t.Fatalf("Total: got %d, want 10", total.Result)
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/foo.Store/Scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&grpc_ScanRequest{Prefix: "fruit/"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for {
+		var reply grpc_ScanReply
+		if err := stream.RecvMsg(&reply); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		names = append(names, reply.Value.Name)
+	}
+	if got, want := strings.Join(names, ","), "apple,banana"; got != want {
+		t.Fatalf("Scan: got %s, want %s", got, want)
+	}
+}
+
+func (m *grpc_Category) GetName() string {
+	if m == nil {
+		return ""
+	}
+	return m.Name
+}
+`
+	tmp := t.TempDir()
+	for f, data := range map[string]string{"foo.go": src, "foo_test.go": test, "go.mod": goModFile} {
+		if err := os.WriteFile(filepath.Join(tmp, f), []byte(data), 0644); err != nil {
+			t.Fatalf("error writing %s: %v", f, err)
+		}
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("go", args...)
+		cmd.Dir = tmp
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("go %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	run("mod", "tidy")
+	if err := Generate(tmp, []string{tmp}, Options{GRPC: []string{"Store"}}); err != nil {
+		t.Fatalf("error running generator: %v", err)
+	}
+	proto, err := os.ReadFile(filepath.Join(tmp, generatedProtoFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package foo;",
+		"rpc Scan(ScanRequest) returns (stream ScanReply);",
+		"map<string, int64> counts = 1;",
+		"repeated int64 extra = 2;",
+		"Category category = 5;",
+	} {
+		if !strings.Contains(string(proto), want) {
+			t.Errorf("%s doesn't contain %q:\n%s", generatedProtoFile, want, proto)
+		}
+	}
+	if strings.Contains(string(proto), "pinger") {
+		t.Errorf("%s declares a service for an unselected component:\n%s", generatedProtoFile, proto)
+	}
+	run("mod", "tidy")
+	run("test", ".")
+}
+
+func TestGenerateGRPCErrors(t *testing.T) {
+	// Test plan: Run "weaver generate -grpc" on components that cannot be
+	// exposed over gRPC, and check the errors.
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(goModFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		method string
+		grpc   string
+		want   string
+	}{
+		{"NestedSlice", "M(context.Context, [][]int) error", "Foo", "which is nested in a slice or map"},
+		{"Complex", "M(context.Context, complex128) error", "Foo", "has no protocol buffer representation"},
+		{"FloatKey", "M(context.Context, map[float64]int) error", "Foo", "keys are not integers, booleans, or strings"},
+		{"ScalarPointer", "M(context.Context) (*int, error)", "Foo", "pointer to a type other than a named struct"},
+		{"StreamArg", "M(context.Context, weaver.Stream[int]) (weaver.Stream[int], error)", "Foo", "methods that take a weaver.Stream are not supported"},
+		{"NoSuchComponent", "M(context.Context) error", "Bar", "-grpc Bar: no such component"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src := `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Foo interface {
+	` + test.method + `
+}
+
+type foo struct {
+	weaver.Implements[Foo]
+}
+`
+			impl := "func (foo) " + test.method + " { panic(\"unimplemented\") }\n"
+			// Remove the code generated for the previous test case, which
+			// doesn't compile against this one.
+			os.Remove(filepath.Join(tmp, generatedCodeFile))
+			if err := os.WriteFile(filepath.Join(tmp, "foo.go"), []byte(src+"\n"+impl), 0644); err != nil {
+				t.Fatal(err)
+			}
+			tidy := exec.Command("go", "mod", "tidy")
+			tidy.Dir = tmp
+			if out, err := tidy.CombinedOutput(); err != nil {
+				t.Fatalf("go mod tidy: %v\n%s", err, out)
+			}
+			err := Generate(tmp, []string{tmp}, Options{GRPC: []string{test.grpc}})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Generate: got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
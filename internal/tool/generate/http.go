@@ -0,0 +1,482 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// The HTTP gateway of a component serves every component method M at
+// /Component/M. A POST request to the endpoint holds a JSON object with the
+// arguments of the method, and the reply holds a JSON object with its
+// results. Arguments and results are encoded with encoding/json. A method
+// that returns a weaver.Stream replies with newline-delimited JSON objects,
+// one per value. The endpoints are described by an OpenAPI 3 spec in the
+// weaver_gen_openapi.json file of the package.
+
+// httpField is an argument or a result of a method served by an HTTP gateway.
+type httpField struct {
+	name   string     // JSON name, e.g., "userID"
+	goName string     // name of the Go struct field, e.g., "UserID"
+	t      types.Type // Go type
+}
+
+// httpMethod is a method served by an HTTP gateway.
+type httpMethod struct {
+	m       *types.Func
+	args    []httpField
+	results []httpField // the streamed value, if stream is true
+	stream  bool        // does the method return a weaver.Stream?
+}
+
+// httpGateway is the HTTP gateway of a component.
+type httpGateway struct {
+	comp    *component
+	methods []*httpMethod
+}
+
+// httpGenerator generates the weaver_gen_http.go and weaver_gen_openapi.json
+// files of a package.
+type httpGenerator struct {
+	*generator
+	gateways []*httpGateway
+	schemas  map[string]any  // OpenAPI schemas of named structs, by name
+	names    typeutil.Map    // the schema name of every named struct
+	used     map[string]bool // schema names in use
+}
+
+// generateHTTPFiles generates the weaver_gen_http.go and
+// weaver_gen_openapi.json files, which implement and describe an HTTP
+// gateway for every component selected by the -http flag.
+func (g *generator) generateHTTPFiles() {
+	hg := &httpGenerator{
+		// The gateways are written to their own file, so they need their own
+		// set of imports.
+		generator: &generator{
+			pkg:     g.pkg,
+			tset:    newTypeSet(g.pkg, g.tset.automarshals, &typeutil.Map{}),
+			fileset: g.fileset,
+			opt:     g.opt,
+		},
+		schemas: map[string]any{},
+		used:    map[string]bool{"Error": true},
+	}
+	for _, comp := range g.selectComponents("-http", g.opt.HTTP) {
+		hg.gateways = append(hg.gateways, &httpGateway{comp: comp})
+	}
+	if len(hg.gateways) == 0 {
+		return
+	}
+
+	spec := hg.processGateways()
+	if len(hg.errors) == 0 {
+		b, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			hg.errors = append(hg.errors, err)
+		} else {
+			hg.write(generatedOpenAPIFile, append(b, '\n'))
+		}
+
+		var body bytes.Buffer
+		hg.generateGateways(func(format string, args ...interface{}) {
+			fmt.Fprintln(&body, fmt.Sprintf(format, args...))
+		})
+		var header bytes.Buffer
+		hg.generateImports(func(format string, args ...interface{}) {
+			fmt.Fprintln(&header, fmt.Sprintf(format, args...))
+		})
+		hg.writeFile(generatedHTTPFile, header, body)
+	}
+	g.errors = append(g.errors, hg.errors...)
+}
+
+// httpFields returns the fields of a tuple of arguments or results. Unnamed
+// fields are named by calling unnamed with their index.
+func httpFields(vars []*types.Var, unnamed func(i int) string) []httpField {
+	var fields []httpField
+	seen := map[string]bool{}
+	for i, v := range vars {
+		name := v.Name()
+		if name == "" || name == "_" {
+			name = unnamed(i)
+		}
+		goName := exported(name)
+		if seen[goName] {
+			goName = fmt.Sprintf("%s_%d", goName, i)
+		}
+		seen[goName] = true
+		fields = append(fields, httpField{name: name, goName: goName, t: v.Type()})
+	}
+	return fields
+}
+
+// processGateways computes the methods of the gateways, checks that their
+// arguments and results can be encoded as JSON, and returns the OpenAPI spec
+// of the gateways.
+func (g *httpGenerator) processGateways() map[string]any {
+	errorContent := map[string]any{
+		"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}},
+	}
+	g.schemas["Error"] = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+	}
+
+	paths := map[string]any{}
+	for _, gw := range g.gateways {
+		comp := gw.comp
+		for _, m := range comp.methods {
+			sig := m.Type().(*types.Signature)
+			method := &httpMethod{m: m}
+			gw.methods = append(gw.methods, method)
+			schema := func(what string, t types.Type) map[string]any {
+				s, err := g.schema(t)
+				if err != nil {
+					g.errorf(m.Pos(), "Method %s of component %q cannot be exposed over HTTP: %s has type %s, which %v.",
+						m.Name(), comp.name, what, g.tset.typeString(t), err)
+				}
+				return s
+			}
+
+			in, out := streamTypes(sig)
+			if in != nil {
+				g.errorf(m.Pos(), "Method %s of component %q cannot be exposed over HTTP: methods that take a weaver.Stream are not supported.",
+					m.Name(), comp.name)
+				continue
+			}
+			var params []*types.Var
+			for i := 1; i < sig.Params().Len(); i++ {
+				params = append(params, sig.Params().At(i))
+			}
+			method.args = httpFields(params, func(i int) string { return fmt.Sprintf("arg%d", i) })
+			var results []*types.Var
+			for i := 0; i < sig.Results().Len()-1; i++ {
+				results = append(results, sig.Results().At(i))
+			}
+			method.results = httpFields(results, func(i int) string {
+				if len(results) == 1 {
+					return "result"
+				}
+				return fmt.Sprintf("result%d", i)
+			})
+			if out != nil {
+				method.stream = true
+				method.results = []httpField{{name: "value", goName: "Value", t: out}}
+			}
+
+			request := object()
+			for i, f := range method.args {
+				request["properties"].(map[string]any)[f.name] = schema(fmt.Sprintf("argument %d", i+1), f.t)
+			}
+			request["additionalProperties"] = false
+			reply := object()
+			for i, f := range method.results {
+				what := fmt.Sprintf("result %d", i)
+				if method.stream {
+					what = "the streamed value"
+				}
+				reply["properties"].(map[string]any)[f.name] = schema(what, f.t)
+			}
+			replyContent := map[string]any{"application/json": map[string]any{"schema": reply}}
+			replyDescription := "The results of the method."
+			if method.stream {
+				replyContent = map[string]any{"application/x-ndjson": map[string]any{"schema": reply}}
+				replyDescription = "The values of the stream returned by the method, one JSON object per line. If the stream ends with an error, the last object holds the error."
+			}
+
+			paths[httpPath(comp, m)] = map[string]any{
+				"post": map[string]any{
+					"operationId": comp.name + "_" + m.Name(),
+					"tags":        []string{comp.name},
+					"summary":     fmt.Sprintf("Calls the %s method of the %s component.", m.Name(), comp.fullName),
+					"requestBody": map[string]any{
+						"content": map[string]any{"application/json": map[string]any{"schema": request}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{"description": replyDescription, "content": replyContent},
+						"400": map[string]any{"description": "The arguments are malformed.", "content": errorContent},
+						"500": map[string]any{"description": "The method returned an error.", "content": errorContent},
+					},
+				},
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   g.pkg.PkgPath,
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+}
+
+// object returns the OpenAPI schema of an object with no properties.
+func object() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+// httpPath returns the path at which an HTTP gateway serves a method.
+func httpPath(comp *component, m *types.Func) string {
+	return fmt.Sprintf("/%s/%s", comp.name, m.Name())
+}
+
+// hasMethod returns whether t or *t has a method with the provided name.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// schema returns the OpenAPI schema of the JSON encoding of type t, or an
+// error if values of type t can't be encoded as JSON.
+func (g *httpGenerator) schema(t types.Type) (map[string]any, error) {
+	if n, ok := t.(*types.Named); ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "time" && n.Obj().Name() == "Time" {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+	if _, ok := t.(*types.Pointer); !ok {
+		if hasMethod(t, "MarshalJSON") {
+			return map[string]any{}, nil
+		}
+		if hasMethod(t, "MarshalText") {
+			return map[string]any{"type": "string"}, nil
+		}
+	}
+
+	switch x := t.Underlying().(type) {
+	case *types.Basic:
+		switch x.Kind() {
+		case types.Bool:
+			return map[string]any{"type": "boolean"}, nil
+		case types.Int8, types.Int16, types.Int32, types.Uint8, types.Uint16:
+			return map[string]any{"type": "integer", "format": "int32"}, nil
+		case types.Int, types.Int64, types.Uint, types.Uint32, types.Uint64:
+			return map[string]any{"type": "integer", "format": "int64"}, nil
+		case types.Float32:
+			return map[string]any{"type": "number", "format": "float"}, nil
+		case types.Float64:
+			return map[string]any{"type": "number", "format": "double"}, nil
+		case types.String:
+			return map[string]any{"type": "string"}, nil
+		}
+		return nil, fmt.Errorf("has no JSON encoding")
+
+	case *types.Pointer:
+		return g.schema(x.Elem())
+
+	case *types.Slice:
+		if isByteSlice(x) {
+			return map[string]any{"type": "string", "format": "byte"}, nil
+		}
+		items, err := g.schema(x.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+
+	case *types.Array:
+		items, err := g.schema(x.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items, "minItems": x.Len(), "maxItems": x.Len()}, nil
+
+	case *types.Map:
+		key, ok := x.Key().Underlying().(*types.Basic)
+		if !hasMethod(x.Key(), "MarshalText") && (!ok || key.Info()&(types.IsInteger|types.IsString) == 0) {
+			return nil, fmt.Errorf("is a map whose keys are not integers or strings")
+		}
+		values, err := g.schema(x.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+
+	case *types.Struct:
+		n, ok := t.(*types.Named)
+		if !ok {
+			return g.structSchema(x)
+		}
+		if name := g.names.At(n); name != nil {
+			return map[string]any{"$ref": "#/components/schemas/" + name.(string)}, nil
+		}
+		// Qualify the name of the schema with the name of the struct's
+		// package if it clashes with another schema, e.g., "MoneyT" for
+		// money.T.
+		name := n.Obj().Name()
+		if g.used[name] {
+			name = exported(n.Obj().Pkg().Name()) + name
+		}
+		if n.TypeArgs().Len() > 0 || g.used[name] {
+			name = sanitize(n)
+		}
+		g.used[name] = true
+		g.names.Set(n, name)
+		s, err := g.structSchema(x)
+		if err != nil {
+			return nil, err
+		}
+		g.schemas[name] = s
+		return map[string]any{"$ref": "#/components/schemas/" + name}, nil
+	}
+	return nil, fmt.Errorf("has no JSON encoding")
+}
+
+// structSchema returns the OpenAPI schema of the JSON encoding of a struct,
+// which follows the rules of encoding/json: unexported fields are omitted,
+// the fields of embedded structs are promoted, and json struct tags rename
+// or omit fields.
+func (g *httpGenerator) structSchema(s *types.Struct) (map[string]any, error) {
+	schema := object()
+	properties := schema["properties"].(map[string]any)
+	var add func(s *types.Struct) error
+	add = func(s *types.Struct) error {
+		for i := 0; i < s.NumFields(); i++ {
+			f := s.Field(i)
+			tag := reflect.StructTag(s.Tag(i)).Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Embedded() && name == "" {
+				t := f.Type()
+				if p, ok := t.(*types.Pointer); ok {
+					t = p.Elem()
+				}
+				if embedded, ok := t.Underlying().(*types.Struct); ok {
+					if err := add(embedded); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if !f.Exported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name()
+			}
+			fs, err := g.schema(f.Type())
+			if err != nil {
+				return fmt.Errorf("has field %s of type %s, which %w", f.Name(), g.tset.typeString(f.Type()), err)
+			}
+			if strings.Contains(","+opts+",", ",string,") {
+				fs = map[string]any{"type": "string"}
+			}
+			properties[name] = fs
+		}
+		return nil
+	}
+	if err := add(s); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// httpHandlerName returns the name of the function that returns the HTTP
+// gateway of the provided component. The function is exported iff the
+// component interface is.
+func httpHandlerName(comp *component) string {
+	if ast.IsExported(comp.name) {
+		return "New" + comp.name + "HTTPHandler"
+	}
+	return "new" + exported(comp.name) + "HTTPHandler"
+}
+
+// generateGateways generates the body of the weaver_gen_http.go file.
+func (g *httpGenerator) generateGateways(p printFn) {
+	http := g.tset.importPackage("net/http", "http")
+
+	p(``)
+	p(`// HTTP gateways.`)
+	for _, gw := range g.gateways {
+		comp := gw.comp
+		name := httpHandlerName(comp)
+		p(``)
+		p(`// %s returns an HTTP handler that serves the methods of impl,`, name)
+		p(`// typically obtained from weaver.Ref or weaver.Get, as JSON endpoints. A`)
+		p(`// method M is called by POSTing a JSON object with its arguments to /%s/M.`, comp.name)
+		p(`// The endpoints are described by the OpenAPI spec in %s.`, generatedOpenAPIFile)
+		p(`func %s(impl %s) %s {`, name, g.tset.genTypeString(comp.iface), http.qualify("Handler"))
+		p(`	mux := %s()`, http.qualify("NewServeMux"))
+		for _, method := range gw.methods {
+			g.generateEndpoint(p, method, httpPath(comp, method.m))
+		}
+		p(`	return mux`)
+		p(`}`)
+	}
+}
+
+// structType returns the type of an anonymous struct with the provided
+// fields.
+func (g *httpGenerator) structType(fields []httpField) string {
+	if len(fields) == 0 {
+		return "struct{}"
+	}
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s %s `json:%q`\n", f.goName, g.tset.genTypeString(f.t), f.name)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// generateEndpoint generates the code that serves a method at the provided
+// path.
+func (g *httpGenerator) generateEndpoint(p printFn, method *httpMethod, path string) {
+	http := g.tset.importPackage("net/http", "http")
+	codegen := g.codegen()
+	sig := method.m.Type().(*types.Signature)
+
+	args := []string{"r.Context()"}
+	for i, f := range method.args {
+		arg := "req." + f.goName
+		if sig.Variadic() && i == len(method.args)-1 {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	call := fmt.Sprintf("impl.%s(%s)", method.m.Name(), strings.Join(args, ", "))
+
+	p(`	mux.HandleFunc(%q, func(w %s, r *%s) {`, path, http.qualify("ResponseWriter"), http.qualify("Request"))
+	p(`		var req %s`, g.structType(method.args))
+	p(`		if !%s(w, r, &req) {`, codegen.qualify("DecodeJSONRequest"))
+	p(`			return`)
+	p(`		}`)
+	if method.stream {
+		p(`		s, err := %s`, call)
+		p(`		%s[%s](w, s, err)`, codegen.qualify("WriteJSONStream"), g.tset.genTypeString(method.results[0].t))
+		p(`	})`)
+		return
+	}
+	var results []string
+	for i := range method.results {
+		results = append(results, fmt.Sprintf("r%d", i))
+	}
+	p(`		%s := %s`, strings.Join(append(results, "err"), ", "), call)
+	p(`		%s(w, %s{%s}, err)`, codegen.qualify("WriteJSONReply"), g.structType(method.results), strings.Join(results, ", "))
+	p(`	})`)
+}
@@ -0,0 +1,295 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGenerateHTTP(t *testing.T) {
+	// Test plan: Run "weaver generate -http" on a package with two
+	// components, one of which is selected, check the OpenAPI spec, and run a
+	// test that calls the selected component over HTTP.
+	const src = `package foo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Base struct {
+	weaver.AutoMarshal
+	Created time.Time
+}
+
+type Item struct {
+	weaver.AutoMarshal
+	Base
+	Name   string   ` + "`json:\"name\"`" + `
+	Tags   []string ` + "`json:\"tags,omitempty\"`" + `
+	Price  float64  ` + "`json:\"price\"`" + `
+	secret string
+}
+
+type Store interface {
+	Put(ctx context.Context, key string, item Item) error
+	Get(ctx context.Context, key string) (Item, error)
+	Total(ctx context.Context, counts map[int]int, extra ...int) (int64, error)
+	Scan(ctx context.Context, prefix string) (weaver.Stream[*Item], error)
+}
+
+type store struct {
+	weaver.Implements[Store]
+	items map[string]Item
+}
+
+func (s *store) Put(_ context.Context, key string, item Item) error {
+	s.items[key] = item
+	return nil
+}
+
+func (s *store) Get(_ context.Context, key string) (Item, error) {
+	item, ok := s.items[key]
+	if !ok {
+		return Item{}, fmt.Errorf("item %q not found", key)
+	}
+	return item, nil
+}
+
+func (s *store) Total(_ context.Context, counts map[int]int, extra ...int) (int64, error) {
+	var total int64
+	for k, n := range counts {
+		total += int64(k * n)
+	}
+	for _, n := range extra {
+		total += int64(n)
+	}
+	return total, nil
+}
+
+func (s *store) Scan(ctx context.Context, prefix string) (weaver.Stream[*Item], error) {
+	var keys []string
+	for key := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return weaver.NewStream(ctx, func(_ context.Context, send func(*Item) error) error {
+		for _, key := range keys {
+			item := s.items[key]
+			if err := send(&item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+type pinger interface {
+	Ping(context.Context) error
+}
+
+type pingerImpl struct {
+	weaver.Implements[pinger]
+}
+
+func (pingerImpl) Ping(context.Context) error { return nil }
+`
+	const test = `package foo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(NewStoreHTTPHandler(&store{items: map[string]Item{}}))
+	defer server.Close()
+
+	call := func(method, body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/Store/"+method, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		reply, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, strings.TrimSpace(string(reply))
+	}
+
+	for _, name := range []string{"apple", "banana"} {
+		body := ` + "`" + `{"key": "fruit/` + "`" + ` + name + ` + "`" + `", "item": {"name": "` + "`" + ` + name + ` + "`" + `", "price": 1.5, "Created": "2023-01-02T00:00:00Z"}}` + "`" + `
+		if code, reply := call("Put", body); code != http.StatusOK || reply != "{}" {
+			t.Fatalf("Put(%q): got %d %s", name, code, reply)
+		}
+	}
+
+	for _, test := range []struct {
+		method, body string
+		code         int
+		reply        string
+	}{
+		{"Get", ` + "`" + `{"key": "fruit/apple"}` + "`" + `, 200, ` + "`" + `{"result":{"Created":"2023-01-02T00:00:00Z","name":"apple","price":1.5}}` + "`" + `},
+		{"Get", ` + "`" + `{"key": "meat/ham"}` + "`" + `, 500, ` + "`" + `{"error":"item \"meat/ham\" not found"}` + "`" + `},
+		{"Get", ` + "`" + `{"id": "fruit/apple"}` + "`" + `, 400, ` + "`" + `{"error":"bad request: json: unknown field \"id\""}` + "`" + `},
+		{"Total", ` + "`" + `{"counts": {"1": 1, "2": 2}, "extra": [3, 4]}` + "`" + `, 200, ` + "`" + `{"result":12}` + "`" + `},
+		{"Total", ` + "``" + `, 200, ` + "`" + `{"result":0}` + "`" + `},
+		{"Scan", ` + "`" + `{"prefix": "fruit/"}` + "`" + `, 200, ` + "`" + `{"value":{"Created":"2023-01-02T00:00:00Z","name":"apple","price":1.5}}
+{"value":{"Created":"2023-01-02T00:00:00Z","name":"banana","price":1.5}}` + "`" + `},
+	} {
+		if code, reply := call(test.method, test.body); code != test.code || reply != test.reply {
+			t.Errorf("%s(%s): got %d %s, want %d %s", test.method, test.body, code, reply, test.code, test.reply)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/Store/Get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /Store/Get: got %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+`
+	tmp := t.TempDir()
+	for f, data := range map[string]string{"foo.go": src, "foo_test.go": test, "go.mod": goModFile} {
+		if err := os.WriteFile(filepath.Join(tmp, f), []byte(data), 0644); err != nil {
+			t.Fatalf("error writing %s: %v", f, err)
+		}
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("go", args...)
+		cmd.Dir = tmp
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("go %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	run("mod", "tidy")
+	if err := Generate(tmp, []string{tmp}, Options{HTTP: []string{"Store"}}); err != nil {
+		t.Fatalf("error running generator: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmp, generatedOpenAPIFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Paths      map[string]any
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("error decoding %s: %v", generatedOpenAPIFile, err)
+	}
+	for _, path := range []string{"/Store/Put", "/Store/Get", "/Store/Total", "/Store/Scan"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("%s doesn't describe %s:\n%s", generatedOpenAPIFile, path, data)
+		}
+	}
+	if len(spec.Paths) != 4 {
+		t.Errorf("%s describes %d paths, want 4:\n%s", generatedOpenAPIFile, len(spec.Paths), data)
+	}
+	var props []string
+	for prop := range spec.Components.Schemas["Item"].Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	if got, want := strings.Join(props, ","), "Created,name,price,tags"; got != want {
+		t.Errorf("properties of Item: got %s, want %s", got, want)
+	}
+
+	run("mod", "tidy")
+	run("test", ".")
+}
+
+func TestGenerateHTTPErrors(t *testing.T) {
+	// Test plan: Run "weaver generate -http" on components that cannot be
+	// exposed over HTTP, and check the errors.
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(goModFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name   string
+		method string
+		http   string
+		want   string
+	}{
+		{"Complex", "M(context.Context, complex128) error", "Foo", "has no JSON encoding"},
+		{"FloatKey", "M(context.Context, map[float64]int) error", "Foo", "is a map whose keys are not integers or strings"},
+		{"StreamArg", "M(context.Context, weaver.Stream[int]) (weaver.Stream[int], error)", "Foo", "methods that take a weaver.Stream are not supported"},
+		{"NoSuchComponent", "M(context.Context) error", "Bar", "-http Bar: no such component"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			src := `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Foo interface {
+	` + test.method + `
+}
+
+type foo struct {
+	weaver.Implements[Foo]
+}
+`
+			impl := "func (foo) " + test.method + " { panic(\"unimplemented\") }\n"
+			// Remove the code generated for the previous test case, which
+			// doesn't compile against this one.
+			os.Remove(filepath.Join(tmp, generatedCodeFile))
+			if err := os.WriteFile(filepath.Join(tmp, "foo.go"), []byte(src+"\n"+impl), 0644); err != nil {
+				t.Fatal(err)
+			}
+			tidy := exec.Command("go", "mod", "tidy")
+			tidy.Dir = tmp
+			if out, err := tidy.CombinedOutput(); err != nil {
+				t.Fatalf("go mod tidy: %v\n%s", err, out)
+			}
+			err := Generate(tmp, []string{tmp}, Options{HTTP: []string{test.http}})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("Generate: got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
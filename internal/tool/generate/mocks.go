@@ -0,0 +1,208 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// mockName returns the name of the mock generated for the provided component
+// interface. The mock is exported iff the interface is.
+func mockName(iface string) string {
+	if ast.IsExported(iface) {
+		return "Mock" + iface
+	}
+	return "mock" + exported(iface)
+}
+
+// generateMocksFile generates the weaver_gen_mocks.go file, which holds a mock
+// implementation of every component interface in the package.
+func (g *generator) generateMocksFile() {
+	// The mocks are written to their own file, so they need their own set of
+	// imports.
+	m := &generator{
+		pkg:        g.pkg,
+		tset:       newTypeSet(g.pkg, g.tset.automarshals, &typeutil.Map{}),
+		fileset:    g.fileset,
+		components: g.components,
+	}
+
+	var body bytes.Buffer
+	{
+		fn := func(format string, args ...interface{}) {
+			fmt.Fprintln(&body, fmt.Sprintf(format, args...))
+		}
+		m.generateMocks(fn)
+	}
+	var header bytes.Buffer
+	{
+		fn := func(format string, args ...interface{}) {
+			fmt.Fprintln(&header, fmt.Sprintf(format, args...))
+		}
+		m.generateImports(fn)
+	}
+	m.writeFile(generatedMocksFile, header, body)
+	g.errors = append(g.errors, m.errors...)
+}
+
+// generateMocks generates mock implementations of the components.
+func (g *generator) generateMocks(p printFn) {
+	g.tset.importPackage("context", "context")
+	p(``)
+	p(`// Mock implementations.`)
+
+	var b strings.Builder
+	for _, comp := range g.components {
+		mock := mockName(comp.name)
+		p(``)
+		p(`// %s is a mock implementation of the %s component interface, for use in`, mock, g.tset.typeString(comp.iface))
+		p(`// tests. %s records every call made to it.`, mock)
+		p(`//`)
+		p(`// A test can expect calls using the ExpectFoo method of every method Foo,`)
+		p(`// with one matcher per argument (see %s), and check that the expected`, g.codegen().qualify("Matcher"))
+		p(`// calls were made using AssertExpectations. A method with expectations`)
+		p(`// returns the results given to the expected call, and fails calls that`)
+		p(`// were not expected.`)
+		p(`//`)
+		p(`// A method without expectations calls its stub function (e.g., FooFn for`)
+		p(`// method Foo) if it is not nil. Otherwise, it returns zero values and Err.`)
+		p(`type %s struct {`, mock)
+		p(`	%s`, g.codegen().qualify("MockRecorder"))
+		p(``)
+		p(`	// Err is returned by methods without expectations or a stub function.`)
+		p(`	Err error`)
+		p(``)
+		p(`	// Stub functions.`)
+		for _, m := range comp.methods {
+			mt := m.Type().(*types.Signature)
+			p(`	%sFn func(%s) (%s)`, m.Name(), g.args(mt), g.returns(mt))
+		}
+		p(`}`)
+		p(``)
+		p(`var _ %s = (*%s)(nil)`, g.tset.genTypeString(comp.iface), mock)
+
+		for _, m := range comp.methods {
+			mt := m.Type().(*types.Signature)
+			p(``)
+			p(`func (m *%s) %s(%s) (%s) {`, mock, m.Name(), g.args(mt), g.returns(mt))
+
+			b.Reset()
+			var record strings.Builder
+			fmt.Fprintf(&b, "ctx")
+			for i := 1; i < mt.Params().Len(); i++ {
+				fmt.Fprintf(&record, ", a%d", i-1)
+				if mt.Variadic() && i == mt.Params().Len()-1 {
+					fmt.Fprintf(&b, ", a%d...", i-1)
+				} else {
+					fmt.Fprintf(&b, ", a%d", i-1)
+				}
+			}
+			p(`	m.MockRecorder.Record(%q%s)`, m.Name(), record.String())
+			p(`	e, err := m.MockRecorder.Expected(%q%s)`, m.Name(), record.String())
+			p(`	if err != nil {`)
+			p(`		return`)
+			p(`	}`)
+			p(`	if e != nil {`)
+			p(`		if fn, ok := e.Fn().(func(%s) (%s)); ok {`, g.args(mt), g.returns(mt))
+			p(`			return fn(%s)`, b.String())
+			p(`		}`)
+			p(`		return`)
+			p(`	}`)
+			p(`	if m.%sFn != nil {`, m.Name())
+			p(`		return m.%sFn(%s)`, m.Name(), b.String())
+			p(`	}`)
+			p(`	err = m.Err`)
+			p(`	return`)
+			p(`}`)
+		}
+
+		for _, m := range comp.methods {
+			g.generateMockCall(p, mock, m)
+		}
+	}
+}
+
+// generateMockCall generates the ExpectFoo method of a mock for method Foo,
+// and the type of the expected calls it returns.
+func (g *generator) generateMockCall(p printFn, mock string, m *types.Func) {
+	mt := m.Type().(*types.Signature)
+	call := mock + m.Name() + "Call"
+	expectation := g.codegen().qualify("MockExpectation")
+
+	// The matchers of the arguments, and the unnamed results.
+	var matchers, args, results strings.Builder
+	for i := 1; i < mt.Params().Len(); i++ {
+		at := mt.Params().At(i).Type()
+		if i > 1 {
+			matchers.WriteString(", ")
+		}
+		fmt.Fprintf(&matchers, "a%d %s[%s]", i-1, g.codegen().qualify("Matcher"), g.tset.genTypeString(at))
+		fmt.Fprintf(&args, ", %s(a%d)", g.codegen().qualify("MockArg"), i-1)
+	}
+	for i := 0; i < mt.Results().Len(); i++ {
+		if i > 0 {
+			results.WriteString(", ")
+		}
+		results.WriteString(g.tset.genTypeString(mt.Results().At(i).Type()))
+	}
+	var names strings.Builder
+	for i := 0; i < mt.Results().Len()-1; i++ {
+		fmt.Fprintf(&names, "r%d, ", i)
+	}
+
+	p(``)
+	p(`// Expect%s expects a call to %s with arguments that match the provided`, m.Name(), m.Name())
+	p(`// matchers. A nil matcher matches any argument. The call is expected exactly`)
+	p(`// once, unless the returned %s says otherwise.`, call)
+	p(`func (m *%s) Expect%s(%s) *%s {`, mock, m.Name(), matchers.String(), call)
+	p(`	return &%s{m.MockRecorder.Expect(%q%s)}`, call, m.Name(), args.String())
+	p(`}`)
+	p(``)
+	p(`// %s is a call to %s.%s expected by a test.`, call, mock, m.Name())
+	p(`type %s struct {`, call)
+	p(`	e *%s`, expectation)
+	p(`}`)
+	p(``)
+	p(`// Return makes the expected call return the provided results.`)
+	p(`func (c *%s) Return(%s) *%s {`, call, g.returns(mt), call)
+	p(`	return c.Do(func(%s) (%s) {`, g.args(mt), results.String())
+	p(`		return %serr`, names.String())
+	p(`	})`)
+	p(`}`)
+	p(``)
+	p(`// Do makes the expected call call fn and return its results.`)
+	p(`func (c *%s) Do(fn func(%s) (%s)) *%s {`, call, g.args(mt), g.returns(mt), call)
+	p(`	c.e.SetFn(fn)`)
+	p(`	return c`)
+	p(`}`)
+	p(``)
+	p(`// Times sets the number of times the call is expected to be made.`)
+	p(`func (c *%s) Times(n int) *%s {`, call, call)
+	p(`	c.e.Times(n)`)
+	p(`	return c`)
+	p(`}`)
+	p(``)
+	p(`// AnyTimes lets the call be made any number of times, including zero.`)
+	p(`func (c *%s) AnyTimes() *%s {`, call, call)
+	p(`	c.e.AnyTimes()`)
+	p(`	return c`)
+	p(`}`)
+}
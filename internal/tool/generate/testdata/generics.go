@@ -0,0 +1,64 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// EXPECTED
+// /Cache[string, int]",
+// Iface: reflect.TypeOf((*Cache[string, int])(nil)).Elem(),
+// Iface: reflect.TypeOf((*Cache[int, []byte])(nil)).Elem(),
+// impl.(Cache[string, int])
+// ctx, span = s.tracer.Start(ctx, "foo.Cache[string, int].Get"
+// impl.(Cache[int, []byte])
+
+// UNEXPECTED
+// cache[K, V]
+
+// Instantiations of a generic component.
+package foo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Cache[K comparable, V any] interface {
+	Get(context.Context, K) (V, error)
+	Put(context.Context, K, V) error
+}
+
+type cache[K comparable, V any] struct {
+	weaver.Implements[Cache[K, V]]
+	mu sync.Mutex
+	m  map[K]V
+}
+
+func (c *cache[K, V]) Get(_ context.Context, k K) (V, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[k], nil
+}
+
+func (c *cache[K, V]) Put(_ context.Context, k K, v V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = map[K]V{}
+	}
+	c.m[k] = v
+	return nil
+}
+
+type stringIntCache = cache[string, int]
+type intBytesCache = cache[int, []byte]
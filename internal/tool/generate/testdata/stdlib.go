@@ -0,0 +1,56 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// EXPECTED
+// enc.EncodeBinaryMarshaler(&x.Placed)
+// enc.Int64((int64)(x.Timeout))
+// enc.EncodeBinaryMarshaler(&x.Addr)
+// enc.EncodeBinaryMarshaler(&x.Link)
+// enc.EncodeTextMarshaler(&x.Total)
+// dec.DecodeTextUnmarshaler(&x.Total)
+// dec.DecodeTextUnmarshaler(&res)
+
+// Verify that common standard library types, like time.Time and big.Int, are
+// serializable without wrappers.
+package foo
+
+import (
+	"context"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type order struct {
+	weaver.AutoMarshal
+	Placed  time.Time
+	Timeout time.Duration
+	Addr    netip.Addr
+	Link    url.URL
+	Total   big.Int
+	Due     *time.Time
+}
+
+type foo interface {
+	M(context.Context, time.Time, time.Duration, netip.Addr, netip.AddrPort, netip.Prefix, *url.URL, *big.Int, order) (time.Time, error)
+}
+
+type impl struct{ weaver.Implements[foo] }
+
+func (impl) M(context.Context, time.Time, time.Duration, netip.Addr, netip.AddrPort, netip.Prefix, *url.URL, *big.Int, order) (time.Time, error) {
+	return time.Time{}, nil
+}
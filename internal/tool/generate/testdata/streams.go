@@ -0,0 +1,56 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// EXPECTED
+// s.stub.Stream(ctx, 0, enc.Data(), shardKey)
+// codegen.ClientValues[int](stream,
+// s.stub.Stream(ctx, 1, enc.Data(), shardKey)
+// codegen.BidiClientValues[[]string, pair](stream, a1,
+// func (s foo_server_stub) GetStreamFn
+// func (s foo_server_stub) count(ctx context.Context, args []byte, stream codegen.ServerStream) (err error) {
+// codegen.SendServerValues[int](stream, r0, appErr,
+// a1 := codegen.ServerValues[[]string](stream,
+
+// UNEXPECTED
+// s.stub.Run(ctx, 0
+
+// Server-streaming and bidirectional streaming methods.
+package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type pair struct {
+	weaver.AutoMarshal
+	key   string
+	value int
+}
+
+type foo interface {
+	Count(ctx context.Context, n int) (weaver.Stream[int], error)
+	Pairs(ctx context.Context, prefix string, keys weaver.Stream[[]string]) (weaver.Stream[pair], error)
+}
+
+type impl struct{ weaver.Implements[foo] }
+
+func (impl) Count(context.Context, int) (weaver.Stream[int], error) {
+	return nil, nil
+}
+
+func (impl) Pairs(context.Context, string, weaver.Stream[[]string]) (weaver.Stream[pair], error) {
+	return nil, nil
+}
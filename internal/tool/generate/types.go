@@ -144,6 +144,20 @@ func (tset *typeSet) imports() []importPkg {
 	return tset.imported
 }
 
+// CheckSerializable returns why type t, used by package pkg, is not
+// serializable, or nil if it is. automarshals are the struct types of pkg that
+// embed weaver.AutoMarshal, which are serializable once "weaver generate" runs
+// on pkg. Types of other packages that embed weaver.AutoMarshal are only
+// serializable if "weaver generate" already ran on their packages.
+func CheckSerializable(pkg *types.Package, automarshals []*types.Named, t types.Type) []error {
+	candidates := &typeutil.Map{}
+	for _, n := range automarshals {
+		candidates.Set(n, struct{}{})
+	}
+	tset := newTypeSet(&packages.Package{PkgPath: pkg.Path(), Types: pkg}, &typeutil.Map{}, candidates)
+	return tset.checkSerializable(t)
+}
+
 // checkSerializable checks that type t is serializable.
 func (tset *typeSet) checkSerializable(t types.Type) []error {
 	// lineage can generate a human readable description of the lineage of a
@@ -290,7 +304,7 @@ func (tset *typeSet) checkSerializable(t types.Type) []error {
 			// since the Go compiler takes care of that.
 
 			// Check if the type implements one of the marshaler interfaces.
-			if tset.isProto(x) || tset.automarshals.At(t) != nil || tset.implementsAutoMarshal(x) || tset.hasMarshalBinary(x) {
+			if tset.isProto(x) || tset.automarshals.At(t) != nil || tset.implementsAutoMarshal(x) || tset.hasMarshalBinary(x) || tset.hasMarshalText(x) {
 				tset.checked.Set(t, true)
 				break
 			}
@@ -747,24 +761,43 @@ func isDecoderPtr(t types.Type) bool {
 // implements the encoding.BinaryMarshaler and binary.BinaryUnmarshaler
 // interfaces.
 func (tset *typeSet) hasMarshalBinary(t types.Type) bool {
+	return tset.hasMarshalMethods(t, "MarshalBinary", "UnmarshalBinary")
+}
+
+// hasMarshalText returns whether the provided type is a named struct type that
+// implements the encoding.TextMarshaler and encoding.TextUnmarshaler
+// interfaces, like big.Int. Named types with other underlying types are
+// serialized like their underlying type, even if they implement the
+// interfaces.
+func (tset *typeSet) hasMarshalText(t types.Type) bool {
+	if _, ok := t.(*types.Named); !ok || !isStruct(t) {
+		return false
+	}
+	return tset.hasMarshalMethods(t, "MarshalText", "UnmarshalText")
+}
+
+// hasMarshalMethods returns whether the provided type is a concrete type with
+// a marshal method, e.g., MarshalBinary() ([]byte, error), and an unmarshal
+// method, e.g., UnmarshalBinary([]byte) error, with the provided names.
+func (tset *typeSet) hasMarshalMethods(t types.Type, marshalName, unmarshalName string) bool {
 	if _, ok := t.Underlying().(*types.Interface); ok {
-		// A superinterface of BinaryMarshaler and BinaryUnmarshaler does
-		// "implement" the interfaces, but we only accept concrete types that
-		// implement the interfaces.
+		// A superinterface of the marshaler interfaces does "implement" the
+		// interfaces, but we only accept concrete types that implement the
+		// interfaces.
 		return false
 	}
 
-	obj, _, _ := types.LookupFieldOrMethod(t, true, tset.pkg.Types, "MarshalBinary")
+	obj, _, _ := types.LookupFieldOrMethod(t, true, tset.pkg.Types, marshalName)
 	marshal, ok := obj.(*types.Func)
 	if !ok {
 		return false
 	}
-	obj, _, _ = types.LookupFieldOrMethod(t, true, tset.pkg.Types, "UnmarshalBinary")
+	obj, _, _ = types.LookupFieldOrMethod(t, true, tset.pkg.Types, unmarshalName)
 	unmarshal, ok := obj.(*types.Func)
 	if !ok {
 		return false
 	}
-	return isMarshalBinary(t, marshal) && isUnmarshalBinary(t, unmarshal)
+	return isMarshalMethod(t, marshal, marshalName) && isUnmarshalMethod(t, unmarshal, unmarshalName)
 }
 
 func isByteSlice(t types.Type) bool {
@@ -779,9 +812,10 @@ func isByteSlice(t types.Type) bool {
 	return e.Kind() == types.Byte
 }
 
-// isMarshalBinary returns true if m is MarshalBinary() ([]byte, error).
-func isMarshalBinary(t types.Type, m *types.Func) bool {
-	if m.Name() != "MarshalBinary" {
+// isMarshalMethod returns true if m is <name>() ([]byte, error), e.g.,
+// MarshalBinary() ([]byte, error).
+func isMarshalMethod(t types.Type, m *types.Func, name string) bool {
+	if m.Name() != name {
 		return false
 	}
 	sig, ok := m.Type().(*types.Signature)
@@ -810,9 +844,10 @@ func isMarshalBinary(t types.Type, m *types.Func) bool {
 	}
 }
 
-// isUnmarshalBinary returns true if m is UnmarshalBinary([]byte) error
-func isUnmarshalBinary(t types.Type, m *types.Func) bool {
-	if m.Name() != "UnmarshalBinary" {
+// isUnmarshalMethod returns true if m is <name>([]byte) error, e.g.,
+// UnmarshalBinary([]byte) error.
+func isUnmarshalMethod(t types.Type, m *types.Func, name string) bool {
+	if m.Name() != name {
 		return false
 	}
 	sig, ok := m.Type().(*types.Signature)
@@ -868,6 +903,14 @@ func isWeaverAutoMarshal(t types.Type) bool {
 	return isWeaverType(t, "AutoMarshal", 0)
 }
 
+// streamElem returns T if t is weaver.Stream[T].
+func streamElem(t types.Type) (types.Type, bool) {
+	if !isWeaverType(t, "Stream", 1) {
+		return nil, false
+	}
+	return t.(*types.Named).TypeArgs().At(0), true
+}
+
 func isContext(t types.Type) bool {
 	n, ok := t.(*types.Named)
 	if !ok {
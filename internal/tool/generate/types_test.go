@@ -81,6 +81,16 @@ type target struct { next *target }
 func (t *target) MarshalBinary() ([]byte, error) { return nil, nil }
 func (t *target) UnmarshalBinary([]byte) error { return nil }
 `, ""},
+		{"TextMarshaler", `
+type target struct{ notSerializable chan int }
+func (t *target) MarshalText() ([]byte, error) { return nil, nil }
+func (t *target) UnmarshalText([]byte) error { return nil }
+`, ""},
+		{"time.Time", `import "time"; type target []time.Time`, ""},
+		{"netip.Addr", `import "net/netip"; type target map[netip.Addr]netip.Prefix`, ""},
+		{"url.URL", `import "net/url"; type target *url.URL`, ""},
+		{"big.Int", `import "math/big"; type target []*big.Int`, ""},
+		{"big.Float", `import "math/big"; type target [2]big.Float`, ""},
 
 		// Non-serializable types:
 		{"function", "type target func()", "not a serializable type"},
@@ -98,6 +108,10 @@ type target struct{
 	notSerializable chan int
 }
 func (t *target) UnmarshalBinary([]byte) error { return nil }
+`, "not serializable"},
+		{"TextMarshaler missing unmarshal", `
+type target struct{ notSerializable chan int }
+func (t *target) MarshalText() ([]byte, error) { return nil, nil }
 `, "not serializable"},
 		{"interface", `
 type target interface{
@@ -0,0 +1,285 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval is the default interval at which Watch checks the
+// watched packages for changes.
+const DefaultWatchInterval = 250 * time.Millisecond
+
+// A watchedPackage is a package whose code is regenerated by Watch.
+type watchedPackage struct {
+	path      string              // package path
+	dir       string              // package directory
+	files     map[string]fileStat // the package's source files
+	decls     [sha256.Size]byte   // fingerprint of the package's declarations
+	failed    bool                // did the last generation fail?
+	importers []*watchedPackage   // watched packages that import this one
+}
+
+// fileStat holds the modification time and size of a file.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch generates code for the provided packages, like Generate, and then
+// checks the packages for changes every interval, until ctx is done. When
+// the declarations in a package change, e.g., a component method is added,
+// Watch regenerates the code of the package and of the watched packages that
+// import it, directly or indirectly. Changes to function bodies alone don't
+// affect the generated code and are skipped, unless the last generation of
+// the package failed. Progress and errors are written to w.
+//
+// The set of watched packages is fixed when Watch starts. Packages created
+// afterwards aren't watched. Watch returns when ctx is done or the user
+// interrupts it.
+func Watch(ctx context.Context, dir string, pkgs []string, opt Options, interval time.Duration, w io.Writer) error {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	results, err := generate(dir, pkgs, opt)
+	if err != nil {
+		return err
+	}
+	watched := map[string]*watchedPackage{}
+	for _, r := range results {
+		if len(r.pkg.GoFiles) == 0 {
+			continue
+		}
+		p := &watchedPackage{
+			path:   r.pkg.PkgPath,
+			dir:    filepath.Dir(r.pkg.GoFiles[0]),
+			failed: len(r.errors) > 0,
+		}
+		if p.files, err = stat(p.dir); err != nil {
+			return err
+		}
+		if p.decls, err = declarations(p.dir); err != nil {
+			return err
+		}
+		watched[p.path] = p
+	}
+	for _, r := range results {
+		importer, ok := watched[r.pkg.PkgPath]
+		if !ok {
+			continue
+		}
+		for path := range r.pkg.Imports {
+			if p, ok := watched[path]; ok {
+				p.importers = append(p.importers, importer)
+			}
+		}
+	}
+	report(w, results, time.Since(start))
+	fmt.Fprintf(w, "weaver generate: watching %d packages for changes\n", len(watched))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Find the packages whose declarations changed, and their importers.
+		stale := map[string]*watchedPackage{}
+		var mark func(p *watchedPackage)
+		mark = func(p *watchedPackage) {
+			if _, ok := stale[p.path]; ok {
+				return
+			}
+			stale[p.path] = p
+			for _, importer := range p.importers {
+				mark(importer)
+			}
+		}
+		for _, p := range watched {
+			files, err := stat(p.dir)
+			if err != nil {
+				fmt.Fprintf(w, "weaver generate: %v\n", err)
+				continue
+			}
+			if equalStats(files, p.files) {
+				continue
+			}
+			p.files = files
+			decls, err := declarations(p.dir)
+			if err != nil {
+				fmt.Fprintf(w, "weaver generate: %v\n", err)
+				continue
+			}
+			if decls == p.decls && !p.failed {
+				continue
+			}
+			p.decls = decls
+			mark(p)
+		}
+		if len(stale) == 0 {
+			continue
+		}
+
+		start := time.Now()
+		var paths []string
+		for path := range stale {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		results, err := generate(dir, paths, opt)
+		if err != nil {
+			fmt.Fprintf(w, "weaver generate: %v\n", err)
+			continue
+		}
+		for _, r := range results {
+			if p, ok := watched[r.pkg.PkgPath]; ok {
+				p.failed = len(r.errors) > 0
+			}
+		}
+		report(w, results, time.Since(start))
+	}
+}
+
+// report writes the outcome of generating code for the provided packages.
+func report(w io.Writer, results []result, latency time.Duration) {
+	var paths []string
+	var errs []error
+	for _, r := range results {
+		paths = append(paths, r.pkg.PkgPath)
+		errs = append(errs, r.errors...)
+	}
+	latency = latency.Round(time.Millisecond)
+	if len(errs) > 0 {
+		fmt.Fprintf(w, "weaver generate: %s failed in %v; waiting for changes:\n%v", strings.Join(paths, ", "), latency, ErrorList(errs))
+		return
+	}
+	fmt.Fprintf(w, "weaver generate: generated %s in %v\n", strings.Join(paths, ", "), latency)
+}
+
+// watchedFile returns whether the provided file may contain declarations that
+// affect the code generated for its package. Test files and generated files
+// don't.
+func watchedFile(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") && !isGeneratedFile(name) && !strings.HasPrefix(name, ".")
+}
+
+// stat returns the modification times and sizes of the watched files in the
+// provided directory.
+func stat(dir string) (map[string]fileStat, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]fileStat{}
+	for _, entry := range entries {
+		if entry.IsDir() || !watchedFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// The file was removed in the meantime.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = fileStat{modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+// equalStats returns whether two results of stat are the same.
+func equalStats(x, y map[string]fileStat) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for name, s := range x {
+		if y[name] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// declarations returns a fingerprint of the declarations in the watched files
+// of the provided directory that match the current build constraints. The
+// fingerprint covers everything in the files except comments and the bodies
+// of functions and methods, which don't affect the generated code. A file
+// that doesn't parse is fingerprinted by its contents, so that the package is
+// regenerated, and the error reported, whenever the file changes.
+func declarations(dir string) ([sha256.Size]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h := sha256.New()
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !watchedFile(name) {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		src, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintln(h, name)
+		f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+		if err != nil {
+			h.Write(src)
+			continue
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				fn.Body = nil
+			}
+		}
+		if err := printer.Fprint(h, fset, f); err != nil {
+			return [sha256.Size]byte{}, err
+		}
+	}
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const adderSrc = `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Adder interface {
+	Add(context.Context, int, int) (int, error)
+}
+
+type adder struct {
+	weaver.Implements[Adder]
+}
+
+func (adder) Add(_ context.Context, x, y int) (int, error) {
+	return x + y, nil
+}
+`
+
+func TestDeclarations(t *testing.T) {
+	// Test plan: Edit a file in various ways, and check that the fingerprint
+	// of its declarations changes only if a declaration changes.
+	dir := t.TempDir()
+	write := func(src string) [sha256.Size]byte {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		decls, err := declarations(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decls
+	}
+
+	base := write(adderSrc)
+	for _, test := range []struct {
+		name    string
+		src     string
+		changed bool
+	}{
+		{"Body", strings.Replace(adderSrc, "return x + y, nil", "return y + x, nil", 1), false},
+		{"Comment", strings.Replace(adderSrc, "type Adder", "// Adder adds.\ntype Adder", 1), false},
+		{"Method", strings.Replace(adderSrc, "(int, error)\n}", "(int, error)\n\tSub(context.Context, int, int) (int, error)\n}", 1), true},
+		{"Formatting", strings.Replace(adderSrc, "x, y int) (int, error) {", "x, y int) (int, error) {\n", 1), false},
+		{"Type", strings.Replace(adderSrc, "Add(context.Context, int, int)", "Add(context.Context, int64, int64)", 1), true},
+		{"SyntaxError", adderSrc + "func {", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := write(test.src)
+			if changed := got != base; changed != test.changed {
+				t.Fatalf("declarations changed: got %t, want %t", changed, test.changed)
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	// Test plan: Watch a package, edit the body of a component method, and
+	// check that the generated code is left untouched. Then, add a method to
+	// the component, and check that the code is regenerated.
+	tmp := t.TempDir()
+	save := func(f, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, f), []byte(data), 0644); err != nil {
+			t.Fatalf("error writing %s: %v", f, err)
+		}
+	}
+	save("foo.go", adderSrc)
+	save("go.mod", goModFile)
+	tidy := exec.Command("go", "mod", "tidy")
+	tidy.Dir = tmp
+	tidy.Stdout = os.Stdout
+	tidy.Stderr = os.Stderr
+	if err := tidy.Run(); err != nil {
+		t.Fatalf("go mod tidy: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- Watch(ctx, tmp, []string{tmp}, Options{}, 10*time.Millisecond, &out) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// await waits for the output of Watch to contain the provided string.
+	await := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Minute)
+		for !strings.Contains(out.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("output %q doesn't contain %q", out.String(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	await("watching 1 packages")
+	gen := filepath.Join(tmp, generatedCodeFile)
+	before, err := os.Stat(gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change a method body.
+	save("foo.go", strings.Replace(adderSrc, "return x + y, nil", "return y + x, nil", 1))
+	time.Sleep(100 * time.Millisecond)
+	if got, want := strings.Count(out.String(), "generated foo"), 1; got != want {
+		t.Fatalf("generations after editing a body: got %d, want %d", got, want)
+	}
+	if after, err := os.Stat(gen); err != nil {
+		t.Fatal(err)
+	} else if !after.ModTime().Equal(before.ModTime()) {
+		t.Fatalf("%s rewritten after editing a body", generatedCodeFile)
+	}
+
+	// Add a method.
+	src := strings.Replace(adderSrc, "(int, error)\n}", "(int, error)\n\tSub(context.Context, int, int) (int, error)\n}", 1)
+	src += `
+func (adder) Sub(_ context.Context, x, y int) (int, error) {
+	return x - y, nil
+}
+`
+	save("foo.go", src)
+	deadline := time.Now().Add(time.Minute)
+	for {
+		code, err := os.ReadFile(gen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(code), "Sub") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not regenerated after adding a method; output:\n%s", generatedCodeFile, out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// serviceAccountDir is the directory in which Kubernetes mounts the
+// credentials of a pod's service account.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// client is a minimal client of the Kubernetes API server, for use inside a
+// cluster. It authenticates with the credentials of the pod's service
+// account.
+type client struct {
+	client *http.Client
+	addr   string // address of the API server, e.g., "https://10.0.0.1:443"
+	token  string // service account bearer token
+}
+
+// newInClusterClient returns a client of the API server of the cluster the
+// calling pod runs in.
+func newInClusterClient() (*client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster")
+	}
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid service account CA")
+	}
+	return &client{
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		addr:  "https://" + net.JoinHostPort(host, port),
+		token: string(bytes.TrimSpace(token)),
+	}, nil
+}
+
+// statusError is an error returned by the API server.
+type statusError struct {
+	code    int    // HTTP status code
+	message string // message returned by the API server
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("kubernetes API: %d %s: %s", e.code, http.StatusText(e.code), e.message)
+}
+
+// do issues a request to the API server, with in as the JSON request body, if
+// not nil, and decodes the JSON response body into out, if not nil.
+func (c *client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &status) //nolint:errcheck // best effort
+		return &statusError{code: resp.StatusCode, message: status.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// getDeployment returns the named Deployment.
+func (c *client) getDeployment(ctx context.Context, namespace, name string) (*deployment, error) {
+	d := &deployment{}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s", namespace, name)
+	if err := c.do(ctx, http.MethodGet, path, nil, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// createDeployment creates the provided Deployment. It is not an error if the
+// Deployment already exists.
+func (c *client) createDeployment(ctx context.Context, d *deployment) error {
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments", d.Metadata.Namespace)
+	err := c.do(ctx, http.MethodPost, path, d, nil)
+	if se, ok := err.(*statusError); ok && se.code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
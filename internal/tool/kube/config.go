@@ -0,0 +1,208 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// kubeKey and shortKubeKey are the keys of the kube section of the config.
+	kubeKey      = "github.com/ServiceWeaver/weaver/kube"
+	shortKubeKey = "kube"
+
+	// Default values of the kube config.
+	defaultTool        = "weaver"
+	defaultNamespace   = "default"
+	defaultReplicas    = 2
+	defaultServiceType = "LoadBalancer"
+)
+
+// config is the kube section of an application config, e.g.:
+//
+//	[kube]
+//	image = "us-docker.pkg.dev/my-project/my-repo/my-app:v1"
+//	namespace = "my-app"
+//	replicas = 3
+//	listeners = {hello = 80}
+//	node_selector = {"cloud.google.com/gke-nodepool" = "weaver"}
+//	service_account_annotations = {"iam.gke.io/gcp-service-account" = "my-app@my-project.iam.gserviceaccount.com"}
+//
+//	[[kube.sidecars]]
+//	name = "cloud-sql-proxy"
+//	image = "gcr.io/cloud-sql-connectors/cloud-sql-proxy:2.1.0"
+//	args = ["--port=5432", "my-project:us-central1:my-db"]
+type config struct {
+	// Image is the container image that runs the application. The image must
+	// contain the application binary, at the path given by the binary field
+	// of the [serviceweaver] section, and the weaver tool.
+	Image string `toml:"image"`
+
+	// Tool is the path of the weaver tool in the image. Defaults to "weaver",
+	// i.e., the weaver tool is looked up in $PATH.
+	Tool string `toml:"tool"`
+
+	// Namespace is the Kubernetes namespace in which the application is
+	// deployed. Defaults to "default".
+	Namespace string `toml:"namespace"`
+
+	// Replicas is the number of replicas of every colocation group. Defaults
+	// to 2.
+	Replicas int `toml:"replicas"`
+
+	// ServiceType is the type of the Kubernetes services that expose the
+	// application's listeners, e.g., "LoadBalancer" or "NodePort". Defaults
+	// to "LoadBalancer".
+	ServiceType string `toml:"service_type"`
+
+	// Listeners maps the name of every listener exposed outside of the
+	// cluster to the port it is exposed on.
+	Listeners map[string]int `toml:"listeners"`
+
+	// ImagePullSecrets are the names of the secrets used to pull Image, and
+	// the images of the sidecars, from private registries.
+	ImagePullSecrets []string `toml:"image_pull_secrets"`
+
+	// NodeSelector and Tolerations constrain the nodes that the pods of the
+	// deployment are scheduled on.
+	NodeSelector map[string]string  `toml:"node_selector"`
+	Tolerations  []tolerationConfig `toml:"tolerations"`
+
+	// ServiceAccount is the name of an existing Kubernetes service account
+	// that the pods of the colocation groups run as. If empty, and
+	// ServiceAccountAnnotations is not empty, the pods run as a service
+	// account created for the deployment, with the provided annotations,
+	// e.g., to bind it to a Google service account with Workload Identity.
+	// Otherwise, they run as the namespace's default service account.
+	ServiceAccount            string            `toml:"service_account"`
+	ServiceAccountAnnotations map[string]string `toml:"service_account_annotations"`
+
+	// Sidecars are containers run next to the babysitter in the pods of the
+	// colocation groups, e.g., a database proxy or an OpenTelemetry
+	// collector.
+	Sidecars []sidecarConfig `toml:"sidecars"`
+}
+
+// tolerationConfig lets pods be scheduled on nodes with a matching taint. See
+// https://kubernetes.io/docs/concepts/scheduling-eviction/taint-and-toleration/.
+type tolerationConfig struct {
+	Key      string `toml:"key"`
+	Operator string `toml:"operator"` // "Equal" or "Exists"; defaults to "Equal"
+	Value    string `toml:"value"`
+	Effect   string `toml:"effect"` // "NoSchedule", "PreferNoSchedule", or "NoExecute"; empty matches all
+}
+
+// sidecarConfig is a container run next to the babysitter of a colocation
+// group.
+type sidecarConfig struct {
+	Name  string            `toml:"name"`
+	Image string            `toml:"image"`
+	Args  []string          `toml:"args"`
+	Env   map[string]string `toml:"env"`
+	Ports []int             `toml:"ports"`
+}
+
+// Validate implements the runtime.ParseConfigSection validation hook.
+func (c *config) Validate() error {
+	if c.Image == "" {
+		return fmt.Errorf("no image provided")
+	}
+	if c.Replicas < 0 {
+		return fmt.Errorf("negative replicas %d", c.Replicas)
+	}
+	for name, port := range c.Listeners {
+		if port <= 0 || port > 65535 || port == managerPort {
+			return fmt.Errorf("listener %q: invalid port %d", name, port)
+		}
+	}
+	for _, t := range c.Tolerations {
+		switch t.Operator {
+		case "", "Equal":
+		case "Exists":
+			if t.Value != "" {
+				return fmt.Errorf("toleration %q: value with the Exists operator", t.Key)
+			}
+		default:
+			return fmt.Errorf("toleration %q: unknown operator %q; want \"Equal\" or \"Exists\"", t.Key, t.Operator)
+		}
+		switch t.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return fmt.Errorf("toleration %q: unknown effect %q", t.Key, t.Effect)
+		}
+	}
+	if c.ServiceAccount != "" && len(c.ServiceAccountAnnotations) > 0 {
+		return fmt.Errorf("service_account along with service_account_annotations")
+	}
+	names := map[string]bool{"babysitter": true}
+	for _, s := range c.Sidecars {
+		if s.Name == "" {
+			return fmt.Errorf("sidecar without a name")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("sidecar %q: duplicate container name", s.Name)
+		}
+		names[s.Name] = true
+		if s.Image == "" {
+			return fmt.Errorf("sidecar %q: no image provided", s.Name)
+		}
+		for _, port := range s.Ports {
+			if port <= 0 || port > 65535 {
+				return fmt.Errorf("sidecar %q: invalid port %d", s.Name, port)
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfig returns the kube config of the provided application, with
+// defaults filled in.
+func loadConfig(app *protos.AppConfig) (*config, error) {
+	c := &config{}
+	if err := runtime.ParseConfigSection(kubeKey, shortKubeKey, app.Sections, c); err != nil {
+		return nil, fmt.Errorf("unable to parse kube config: %w", err)
+	}
+	if c.Image == "" {
+		return nil, fmt.Errorf("unable to parse kube config: no image provided")
+	}
+	if c.Tool == "" {
+		c.Tool = defaultTool
+	}
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultReplicas
+	}
+	if c.ServiceType == "" {
+		c.ServiceType = defaultServiceType
+	}
+	return c, nil
+}
+
+// CheckConfig checks the kube section of the provided application config, if
+// it has one. It is used by "weaver config check".
+func CheckConfig(app *protos.AppConfig) error {
+	_, long := app.Sections[kubeKey]
+	_, short := app.Sections[shortKubeKey]
+	if !long && !short {
+		return nil
+	}
+	_, err := loadConfig(app)
+	return err
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var deployCmd = tool.Command{
+	Name:        "deploy",
+	Description: "Deploy a Service Weaver app to Kubernetes",
+	Help: `Usage:
+  weaver kube deploy [--dry-run] <configfile>
+
+Flags:
+  -h, --help   Print this help message.
+  --dry-run    Print the colocation groups, replicas, and listeners of
+               the deployment, instead of its resources (default false)
+
+Description:
+  "weaver kube deploy" prints the Kubernetes resources that deploy the
+  application to stdout. Apply them with kubectl:
+
+      weaver kube deploy weaver.toml | kubectl apply -f -
+
+  The application is configured in the config file's [kube] section:
+
+      [kube]
+      image = "docker.io/my-user/my-app:v1"  # required
+      tool = "/weaver"                       # the weaver binary in the image
+      namespace = "default"
+      replicas = 2                           # replicas per colocation group
+      service_type = "LoadBalancer"          # type of the listener services
+      listeners = {hello = 80}               # listeners exposed, by port
+
+  The image must contain both the application binary, at the path given by
+  the binary field of the [serviceweaver] section, and the weaver binary.
+  Every deployment is labeled with its id; delete it with:
+
+      kubectl delete deployments,services,serviceaccounts,roles,rolebindings \
+          -l serviceweaver/deployment=<id>`,
+	Flags: deployFlags,
+	Fn:    deploy,
+}
+
+var (
+	deployFlags  = flag.NewFlagSet("deploy", flag.ContinueOnError)
+	deployDryRun = deployFlags.Bool("dry-run", false, "Print the deployment plan without generating resources")
+)
+
+// deploy prints the Kubernetes resources that deploy an application.
+func deploy(ctx context.Context, args []string) error {
+	// Validate command line arguments.
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	// Load the config file.
+	cfgFile := args[0]
+	contents, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	app, err := runtime.ParseConfig(cfgFile, string(contents), codegen.ComponentConfigValidator)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return err
+	}
+
+	// Print the deployment plan instead, if requested.
+	if *deployDryRun {
+		return dryRun(ctx, app, cfg)
+	}
+
+	// Generate the resources.
+	dep := &protos.Deployment{
+		Id:  uuid.New().String(),
+		App: app,
+	}
+	resources, err := managerResources(dep, cfg)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(&list{
+		typeMeta: typeMeta{APIVersion: "v1", Kind: "List"},
+		Items:    resources,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	fmt.Fprintf(os.Stderr, "Generated deployment %s of app %s\n", dep.Id, app.Name)
+	return nil
+}
+
+// dryRun prints the plan of deploying the provided app to Kubernetes, without
+// generating its resources. The application binary must run on the local
+// machine.
+func dryRun(ctx context.Context, app *protos.AppConfig, cfg *config) error {
+	components, err := plan.Components(ctx, app)
+	if err != nil {
+		return err
+	}
+	p := &plan.Plan{
+		App:          app.Name,
+		Deployer:     "weaver kube",
+		Rollout:      "new Kubernetes deployment, applied with kubectl",
+		Groups:       plan.Groups(app, components, plan.Replicas(app, cfg.Replicas, nil)),
+		Config:       app,
+		Listeners:    []plan.Listener{},
+		ListenerNote: "Other listeners are only reachable from within the manager's pod.",
+	}
+	for _, l := range sortedListeners(cfg) {
+		p.Listeners = append(p.Listeners, plan.Listener{
+			Name:    l,
+			Address: fmt.Sprintf("port %d of a %s service", cfg.Listeners[l], cfg.ServiceType),
+		})
+	}
+	plan.Write(os.Stdout, p, nil)
+	fmt.Printf("Running deployments are not compared; list them with:\n\n    kubectl get deployments -l %s=%s\n", appLabel, name(app.Name))
+	return nil
+}
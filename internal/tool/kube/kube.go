@@ -0,0 +1,36 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube implements the "weaver kube" deployer, which deploys Service
+// Weaver applications to a Kubernetes cluster.
+//
+// "weaver kube deploy" generates the Kubernetes resources of a manager, which
+// runs inside the cluster. The manager starts a Deployment for every
+// colocation group, whose pods run a babysitter and a weavelet, and proxies
+// the application's listeners. The manager and babysitters speak the same
+// protocol as the ssh deployer's manager and babysitters.
+package kube
+
+import (
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var Commands = map[string]*tool.Command{
+	"deploy":  &deployCmd,
+	"version": tool.VersionCmd("weaver kube"),
+
+	// Hidden commands.
+	"manager":    &managerCmd,
+	"babysitter": &babysitterCmd,
+}
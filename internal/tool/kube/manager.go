@@ -0,0 +1,73 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/cluster"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+)
+
+var (
+	managerCmd    = cluster.ManagerCommand("kube", runManager)
+	babysitterCmd = cluster.BabysitterCommand("kube", runBabysitter)
+)
+
+// runManager runs the manager of a deployment inside a Kubernetes cluster.
+// The manager creates a Deployment for every colocation group, and proxies
+// the application's listeners. Logs are printed to stdout, where they can be
+// read with "kubectl logs".
+func runManager(ctx context.Context, _ []string) error {
+	dep, err := decodeDeployment(os.Getenv(deploymentKey))
+	if err != nil {
+		return fmt.Errorf("unable to retrieve deployment: %w", err)
+	}
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		return err
+	}
+	kube, err := newInClusterClient()
+	if err != nil {
+		return err
+	}
+
+	// Group Deployments are owned by the manager Deployment, so that they are
+	// deleted along with it.
+	mgr, err := kube.getDeployment(ctx, cfg.Namespace, managerName(dep))
+	if err != nil {
+		return fmt.Errorf("get manager deployment: %w", err)
+	}
+
+	return cluster.RunManager(ctx, dep, cluster.ManagerOptions{
+		StartGroup: func(info *impl.BabysitterInfo) error {
+			d, err := groupDeployment(cfg, info, mgr.Metadata.UID)
+			if err != nil {
+				return err
+			}
+			return kube.createDeployment(ctx, d)
+		},
+		Port:      managerPort,
+		Addr:      managerAddr(dep, cfg),
+		Listeners: cfg.Listeners,
+	})
+}
+
+// runBabysitter runs a babysitter and weavelet in a colocation group's pod.
+func runBabysitter(ctx context.Context, _ []string) error {
+	return cluster.RunBabysitter(ctx, os.Getenv(podNameKey))
+}
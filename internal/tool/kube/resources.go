@@ -0,0 +1,479 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/proto"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// This file contains the subset of the Kubernetes API types used by the kube
+// deployer, along with the functions that build the Kubernetes resources of
+// a deployment. We define the types ourselves, rather than depend on the
+// Kubernetes client libraries, to keep the weaver tool's dependencies small.
+
+const (
+	// managerPort is the port the manager listens on.
+	managerPort = 8000
+
+	// Labels attached to every resource of a deployment.
+	appLabel        = "serviceweaver/app"
+	deploymentLabel = "serviceweaver/deployment"
+	groupLabel      = "serviceweaver/group"
+
+	// Environment variables passed to the manager and babysitters.
+	deploymentKey = "SERVICEWEAVER_DEPLOYMENT"
+	podNameKey    = "SERVICEWEAVER_POD_NAME"
+)
+
+type object = any
+
+type typeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+type objectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	OwnerReferences []ownerReference  `json:"ownerReferences,omitempty"`
+}
+
+type ownerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+type list struct {
+	typeMeta
+	Items []object `json:"items"`
+}
+
+type deployment struct {
+	typeMeta
+	Metadata objectMeta     `json:"metadata"`
+	Spec     deploymentSpec `json:"spec"`
+}
+
+type deploymentSpec struct {
+	Replicas int             `json:"replicas"`
+	Selector labelSelector   `json:"selector"`
+	Template podTemplateSpec `json:"template"`
+}
+
+type labelSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+type podTemplateSpec struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+type podSpec struct {
+	ServiceAccountName           string                 `json:"serviceAccountName,omitempty"`
+	AutomountServiceAccountToken *bool                  `json:"automountServiceAccountToken,omitempty"`
+	NodeSelector                 map[string]string      `json:"nodeSelector,omitempty"`
+	Tolerations                  []toleration           `json:"tolerations,omitempty"`
+	ImagePullSecrets             []localObjectReference `json:"imagePullSecrets,omitempty"`
+	Containers                   []container            `json:"containers"`
+}
+
+type toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+type localObjectReference struct {
+	Name string `json:"name"`
+}
+
+type container struct {
+	Name  string          `json:"name"`
+	Image string          `json:"image"`
+	Args  []string        `json:"args,omitempty"`
+	Env   []envVar        `json:"env,omitempty"`
+	Ports []containerPort `json:"ports,omitempty"`
+}
+
+type envVar struct {
+	Name      string        `json:"name"`
+	Value     string        `json:"value,omitempty"`
+	ValueFrom *envVarSource `json:"valueFrom,omitempty"`
+}
+
+type envVarSource struct {
+	FieldRef *objectFieldSelector `json:"fieldRef,omitempty"`
+}
+
+type objectFieldSelector struct {
+	FieldPath string `json:"fieldPath"`
+}
+
+type containerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int    `json:"containerPort"`
+}
+
+type service struct {
+	typeMeta
+	Metadata objectMeta  `json:"metadata"`
+	Spec     serviceSpec `json:"spec"`
+}
+
+type serviceSpec struct {
+	Type     string            `json:"type,omitempty"`
+	Selector map[string]string `json:"selector"`
+	Ports    []servicePort     `json:"ports"`
+}
+
+type servicePort struct {
+	Name       string `json:"name,omitempty"`
+	Port       int    `json:"port"`
+	TargetPort int    `json:"targetPort"`
+}
+
+type serviceAccount struct {
+	typeMeta
+	Metadata objectMeta `json:"metadata"`
+}
+
+type role struct {
+	typeMeta
+	Metadata objectMeta   `json:"metadata"`
+	Rules    []policyRule `json:"rules"`
+}
+
+type policyRule struct {
+	APIGroups []string `json:"apiGroups"`
+	Resources []string `json:"resources"`
+	Verbs     []string `json:"verbs"`
+}
+
+type roleBinding struct {
+	typeMeta
+	Metadata objectMeta `json:"metadata"`
+	RoleRef  roleRef    `json:"roleRef"`
+	Subjects []subject  `json:"subjects"`
+}
+
+type roleRef struct {
+	APIGroup string `json:"apiGroup"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+}
+
+type subject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// invalidNameChars matches runs of characters that may not appear in the name
+// of a Kubernetes resource, along with dashes, so that they are collapsed.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// name returns a valid Kubernetes resource name (an RFC 1123 label) formed
+// from the provided parts. Names longer than 63 characters are truncated and
+// suffixed with a hash of the full name to keep them unique.
+func name(parts ...string) string {
+	const maxLen = 63
+	s := strings.ToLower(strings.Join(parts, "-"))
+	s = invalidNameChars.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	if len(s) <= maxLen {
+		return s
+	}
+	h := fnv.New32a()
+	h.Write([]byte(s)) //nolint:errcheck // hash.Hash.Write never fails
+	suffix := fmt.Sprintf("%08x", h.Sum32())
+	return strings.TrimRight(s[:maxLen-len(suffix)-1], "-") + "-" + suffix
+}
+
+// managerName returns the name of the manager resources of a deployment.
+func managerName(dep *protos.Deployment) string {
+	return name(dep.App.Name, logging.Shorten(dep.Id), "manager")
+}
+
+// groupName returns the name of the Deployment that runs a colocation group.
+func groupName(dep *protos.Deployment, group string) string {
+	return name(dep.App.Name, logging.Shorten(dep.Id), logging.ShortenComponent(group))
+}
+
+// listenerName returns the name of the Service that exposes a listener.
+func listenerName(dep *protos.Deployment, listener string) string {
+	return name(dep.App.Name, logging.Shorten(dep.Id), listener)
+}
+
+// managerAddr returns the URL babysitters use to reach the manager.
+func managerAddr(dep *protos.Deployment, cfg *config) string {
+	return fmt.Sprintf("http://%s.%s:%d", managerName(dep), cfg.Namespace, managerPort)
+}
+
+// componentsName returns the name of the service account created for the
+// pods of the colocation groups of a deployment, if any.
+func componentsName(dep *protos.Deployment) string {
+	return name(dep.App.Name, logging.Shorten(dep.Id), "components")
+}
+
+// labels returns the labels attached to the resources of a deployment.
+func labels(dep *protos.Deployment) map[string]string {
+	return map[string]string{
+		appLabel:        name(dep.App.Name),
+		deploymentLabel: dep.Id,
+	}
+}
+
+// managerLabels returns the labels of the manager pod.
+func managerLabels(dep *protos.Deployment) map[string]string {
+	l := labels(dep)
+	l[groupLabel] = "manager"
+	return l
+}
+
+// managerResources returns the resources that run the manager of a
+// deployment: a service account allowed to manage Deployments, the manager
+// Deployment, a Service that lets babysitters reach the manager, and a
+// Service per exposed listener. If configured, it also includes the service
+// account of the pods of the colocation groups. The manager starts the
+// Deployments of the colocation groups itself, when their components are
+// first activated.
+func managerResources(dep *protos.Deployment, cfg *config) ([]object, error) {
+	encoded, err := encodeDeployment(dep)
+	if err != nil {
+		return nil, err
+	}
+	mgr := managerName(dep)
+	meta := func(name string) objectMeta {
+		return objectMeta{Name: name, Namespace: cfg.Namespace, Labels: labels(dep)}
+	}
+
+	ports := []containerPort{{Name: "manager", ContainerPort: managerPort}}
+	for _, l := range sortedListeners(cfg) {
+		ports = append(ports, containerPort{ContainerPort: cfg.Listeners[l]})
+	}
+
+	resources := []object{
+		&serviceAccount{
+			typeMeta: typeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			Metadata: meta(mgr),
+		},
+		&role{
+			typeMeta: typeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			Metadata: meta(mgr),
+			Rules: []policyRule{{
+				APIGroups: []string{"apps"},
+				Resources: []string{"deployments"},
+				Verbs:     []string{"get", "create"},
+			}},
+		},
+		&roleBinding{
+			typeMeta: typeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			Metadata: meta(mgr),
+			RoleRef:  roleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: mgr},
+			Subjects: []subject{{Kind: "ServiceAccount", Name: mgr, Namespace: cfg.Namespace}},
+		},
+		&deployment{
+			typeMeta: typeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			Metadata: meta(mgr),
+			Spec: deploymentSpec{
+				Replicas: 1,
+				Selector: labelSelector{MatchLabels: managerLabels(dep)},
+				Template: podTemplateSpec{
+					Metadata: objectMeta{Labels: managerLabels(dep)},
+					Spec: podSpec{
+						ServiceAccountName: mgr,
+						NodeSelector:       cfg.NodeSelector,
+						Tolerations:        tolerations(cfg),
+						ImagePullSecrets:   imagePullSecrets(cfg),
+						Containers: []container{{
+							Name:  "manager",
+							Image: cfg.Image,
+							Args:  []string{cfg.Tool, "kube", "manager"},
+							Env:   []envVar{{Name: deploymentKey, Value: encoded}},
+							Ports: ports,
+						}},
+					},
+				},
+			},
+		},
+		&service{
+			typeMeta: typeMeta{APIVersion: "v1", Kind: "Service"},
+			Metadata: meta(mgr),
+			Spec: serviceSpec{
+				Selector: managerLabels(dep),
+				Ports:    []servicePort{{Name: "manager", Port: managerPort, TargetPort: managerPort}},
+			},
+		},
+	}
+	if cfg.ServiceAccount == "" && len(cfg.ServiceAccountAnnotations) > 0 {
+		m := meta(componentsName(dep))
+		m.Annotations = cfg.ServiceAccountAnnotations
+		resources = append(resources, &serviceAccount{
+			typeMeta: typeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			Metadata: m,
+		})
+	}
+	for _, l := range sortedListeners(cfg) {
+		port := cfg.Listeners[l]
+		resources = append(resources, &service{
+			typeMeta: typeMeta{APIVersion: "v1", Kind: "Service"},
+			Metadata: meta(listenerName(dep, l)),
+			Spec: serviceSpec{
+				Type:     cfg.ServiceType,
+				Selector: managerLabels(dep),
+				Ports:    []servicePort{{Port: port, TargetPort: port}},
+			},
+		})
+	}
+	return resources, nil
+}
+
+// groupDeployment returns the Deployment that runs the colocation group
+// described by info. The Deployment is owned by the manager Deployment, with
+// the provided UID, so that deleting the manager deletes the group as well.
+func groupDeployment(cfg *config, info *impl.BabysitterInfo, managerUID string) (*deployment, error) {
+	env, err := impl.BabysitterEnv(info)
+	if err != nil {
+		return nil, err
+	}
+	key, value, _ := strings.Cut(env, "=")
+
+	dep := info.Deployment
+	podLabels := labels(dep)
+	podLabels[groupLabel] = name(logging.ShortenComponent(info.Group))
+	meta := objectMeta{
+		Name:      groupName(dep, info.Group),
+		Namespace: cfg.Namespace,
+		Labels:    labels(dep),
+		OwnerReferences: []ownerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       managerName(dep),
+			UID:        managerUID,
+		}},
+	}
+	containers := []container{{
+		Name:  "babysitter",
+		Image: cfg.Image,
+		Args:  []string{cfg.Tool, "kube", "babysitter"},
+		Env: []envVar{
+			{Name: key, Value: value},
+			{
+				Name: podNameKey,
+				ValueFrom: &envVarSource{
+					FieldRef: &objectFieldSelector{FieldPath: "metadata.name"},
+				},
+			},
+		},
+	}}
+	for _, s := range cfg.Sidecars {
+		c := container{Name: s.Name, Image: s.Image, Args: s.Args}
+		envNames := maps.Keys(s.Env)
+		slices.Sort(envNames)
+		for _, n := range envNames {
+			c.Env = append(c.Env, envVar{Name: n, Value: s.Env[n]})
+		}
+		for _, port := range s.Ports {
+			c.Ports = append(c.Ports, containerPort{ContainerPort: port})
+		}
+		containers = append(containers, c)
+	}
+	serviceAccount := cfg.ServiceAccount
+	if serviceAccount == "" && len(cfg.ServiceAccountAnnotations) > 0 {
+		serviceAccount = componentsName(dep)
+	}
+	automount := false
+	return &deployment{
+		typeMeta: typeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		Metadata: meta,
+		Spec: deploymentSpec{
+			Replicas: cfg.Replicas,
+			Selector: labelSelector{MatchLabels: podLabels},
+			Template: podTemplateSpec{
+				Metadata: objectMeta{Labels: podLabels},
+				Spec: podSpec{
+					ServiceAccountName:           serviceAccount,
+					AutomountServiceAccountToken: &automount,
+					NodeSelector:                 cfg.NodeSelector,
+					Tolerations:                  tolerations(cfg),
+					ImagePullSecrets:             imagePullSecrets(cfg),
+					Containers:                   containers,
+				},
+			},
+		},
+	}, nil
+}
+
+// tolerations returns the tolerations of the pods of a deployment.
+func tolerations(cfg *config) []toleration {
+	var ts []toleration
+	for _, t := range cfg.Tolerations {
+		ts = append(ts, toleration(t))
+	}
+	return ts
+}
+
+// imagePullSecrets returns the image pull secrets of the pods of a
+// deployment.
+func imagePullSecrets(cfg *config) []localObjectReference {
+	var refs []localObjectReference
+	for _, s := range cfg.ImagePullSecrets {
+		refs = append(refs, localObjectReference{Name: s})
+	}
+	return refs
+}
+
+// sortedListeners returns the names of the exposed listeners, in sorted
+// order.
+func sortedListeners(cfg *config) []string {
+	listeners := maps.Keys(cfg.Listeners)
+	slices.Sort(listeners)
+	return listeners
+}
+
+// encodeDeployment encodes a deployment into a string that can be passed to
+// the manager in the environment.
+func encodeDeployment(dep *protos.Deployment) (string, error) {
+	return proto.ToEnv(dep)
+}
+
+// decodeDeployment decodes a deployment encoded by encodeDeployment.
+func decodeDeployment(s string) (*protos.Deployment, error) {
+	dep := &protos.Deployment{}
+	if err := proto.FromEnv(s, dep); err != nil {
+		return nil, err
+	}
+	if dep.App == nil {
+		return nil, fmt.Errorf("invalid deployment")
+	}
+	return dep, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/internal/tool/tooltest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testConfig = `
+[serviceweaver]
+name = "Hello_App"
+binary = "/app/hello"
+
+[kube]
+image = "example.com/hello:v1"
+replicas = 3
+listeners = {hello = 80, admin = 9000}
+`
+
+func TestName(t *testing.T) {
+	for _, test := range []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"hello"}, "hello"},
+		{[]string{"Hello_App", "01234567", "main"}, "hello-app-01234567-main"},
+		{[]string{"hello", "reverser.Reverser"}, "hello-reverser-reverser"},
+		{[]string{"--a--", "b"}, "a-b"},
+	} {
+		if got := name(test.parts...); got != test.want {
+			t.Errorf("name(%q): got %q, want %q", test.parts, got, test.want)
+		}
+	}
+
+	long := name(strings.Repeat("a", 100))
+	if len(long) != 63 {
+		t.Errorf("name(<100 a's>): got %d characters, want 63", len(long))
+	}
+	if other := name(strings.Repeat("a", 101)); other == long {
+		t.Errorf("name(<100 a's>) == name(<101 a's>) == %q", long)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		section string
+		want    string
+	}{
+		{"NoSection", "", "no image"},
+		{"NoImage", "[kube]\nreplicas = 2", "no image"},
+		{"NegativeReplicas", "[kube]\nimage = \"i\"\nreplicas = -1", "negative replicas"},
+		{"BadPort", "[kube]\nimage = \"i\"\nlisteners = {a = 70000}", "invalid port"},
+		{"ManagerPort", "[kube]\nimage = \"i\"\nlisteners = {a = 8000}", "invalid port"},
+		{"BadTolerationOperator", "[kube]\nimage = \"i\"\n[[kube.tolerations]]\nkey = \"k\"\noperator = \"In\"", "unknown operator"},
+		{"BadTolerationEffect", "[kube]\nimage = \"i\"\n[[kube.tolerations]]\nkey = \"k\"\neffect = \"Evict\"", "unknown effect"},
+		{"ServiceAccountConflict", "[kube]\nimage = \"i\"\nservice_account = \"sa\"\nservice_account_annotations = {a = \"b\"}", "along with service_account_annotations"},
+		{"SidecarNoImage", "[kube]\nimage = \"i\"\n[[kube.sidecars]]\nname = \"proxy\"", "no image"},
+		{"SidecarBabysitter", "[kube]\nimage = \"i\"\n[[kube.sidecars]]\nname = \"babysitter\"\nimage = \"i\"", "duplicate container name"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dep := tooltest.Deployment(t, "[serviceweaver]\nbinary = \"/app\"\n"+test.section)
+			_, err := loadConfig(dep.App)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("loadConfig: got %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestManagerResources(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := managerResources(dep, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the kinds and names of the resources.
+	var got []string
+	for _, r := range resources {
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var obj struct {
+			Kind     string     `json:"kind"`
+			Metadata objectMeta `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			t.Fatal(err)
+		}
+		if obj.Metadata.Namespace != "default" {
+			t.Errorf("%s %s: got namespace %q, want %q", obj.Kind, obj.Metadata.Name, obj.Metadata.Namespace, "default")
+		}
+		if obj.Metadata.Labels[deploymentLabel] != dep.Id {
+			t.Errorf("%s %s: missing deployment label", obj.Kind, obj.Metadata.Name)
+		}
+		got = append(got, obj.Kind+"/"+obj.Metadata.Name)
+	}
+	const mgr = "hello-app-01234567-manager"
+	want := []string{
+		"ServiceAccount/" + mgr,
+		"Role/" + mgr,
+		"RoleBinding/" + mgr,
+		"Deployment/" + mgr,
+		"Service/" + mgr,
+		"Service/hello-app-01234567-admin",
+		"Service/hello-app-01234567-hello",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("resources (-want +got):\n%s", diff)
+	}
+
+	// Check that the manager receives the deployment.
+	d := resources[3].(*deployment)
+	env := d.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 1 || env[0].Name != deploymentKey {
+		t.Fatalf("manager env: got %v, want %s", env, deploymentKey)
+	}
+	decoded, err := decodeDeployment(env[0].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(dep, decoded, protocmp.Transform()); diff != "" {
+		t.Fatalf("decoded deployment (-want +got):\n%s", diff)
+	}
+	if got, want := managerAddr(dep, cfg), "http://"+mgr+".default:8000"; got != want {
+		t.Fatalf("managerAddr: got %q, want %q", got, want)
+	}
+}
+
+func TestGroupDeployment(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &impl.BabysitterInfo{
+		Deployment:  dep,
+		Group:       "github.com/example/hello/Reverser",
+		ManagerAddr: managerAddr(dep, cfg),
+	}
+	d, err := groupDeployment(cfg, info, "manager-uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.Metadata.Name, "hello-app-01234567-hello-reverser"; got != want {
+		t.Errorf("name: got %q, want %q", got, want)
+	}
+	if got, want := d.Spec.Replicas, 3; got != want {
+		t.Errorf("replicas: got %d, want %d", got, want)
+	}
+	owners := d.Metadata.OwnerReferences
+	if len(owners) != 1 || owners[0].UID != "manager-uid" || owners[0].Name != managerName(dep) {
+		t.Errorf("owner references: got %v, want the manager deployment", owners)
+	}
+
+	// The babysitter receives info in the environment.
+	c := d.Spec.Template.Spec.Containers[0]
+	if got, want := c.Args, []string{"weaver", "kube", "babysitter"}; !cmp.Equal(got, want) {
+		t.Errorf("args: got %v, want %v", got, want)
+	}
+	t.Setenv(c.Env[0].Name, c.Env[0].Value)
+	got, err := impl.BabysitterInfoFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(info, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("babysitter info (-want +got):\n%s", diff)
+	}
+}
+
+func TestPodSettings(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig+`
+image_pull_secrets = ["registry"]
+node_selector = {"cloud.google.com/gke-nodepool" = "weaver"}
+service_account_annotations = {"iam.gke.io/gcp-service-account" = "hello@p.iam.gserviceaccount.com"}
+
+[[kube.tolerations]]
+key = "dedicated"
+value = "weaver"
+effect = "NoSchedule"
+
+[[kube.sidecars]]
+name = "cloud-sql-proxy"
+image = "gcr.io/cloud-sql-connectors/cloud-sql-proxy:2.1.0"
+args = ["--port=5432", "p:us-central1:db"]
+env = {B = "2", A = "1"}
+ports = [5432]
+`)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The manager creates the service account of the groups.
+	resources, err := managerResources(dep, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sa *serviceAccount
+	for _, r := range resources {
+		if s, ok := r.(*serviceAccount); ok && s.Metadata.Name == componentsName(dep) {
+			sa = s
+		}
+	}
+	if sa == nil {
+		t.Fatalf("no %s service account", componentsName(dep))
+	}
+	if got := sa.Metadata.Annotations["iam.gke.io/gcp-service-account"]; got != "hello@p.iam.gserviceaccount.com" {
+		t.Errorf("service account annotation: got %q", got)
+	}
+	mgrPod := resources[3].(*deployment).Spec.Template.Spec
+	if mgrPod.NodeSelector["cloud.google.com/gke-nodepool"] != "weaver" || len(mgrPod.Tolerations) != 1 {
+		t.Errorf("manager pod: got %+v, want the node selector and tolerations", mgrPod)
+	}
+
+	info := &impl.BabysitterInfo{Deployment: dep, Group: "main", ManagerAddr: managerAddr(dep, cfg)}
+	d, err := groupDeployment(cfg, info, "manager-uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := d.Spec.Template.Spec
+	if got, want := pod.ServiceAccountName, componentsName(dep); got != want {
+		t.Errorf("service account: got %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([]toleration{{Key: "dedicated", Value: "weaver", Effect: "NoSchedule"}}, pod.Tolerations); diff != "" {
+		t.Errorf("tolerations (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]localObjectReference{{Name: "registry"}}, pod.ImagePullSecrets); diff != "" {
+		t.Errorf("image pull secrets (-want +got):\n%s", diff)
+	}
+	if len(pod.Containers) != 2 {
+		t.Fatalf("got %d containers, want the babysitter and a sidecar", len(pod.Containers))
+	}
+	want := container{
+		Name:  "cloud-sql-proxy",
+		Image: "gcr.io/cloud-sql-connectors/cloud-sql-proxy:2.1.0",
+		Args:  []string{"--port=5432", "p:us-central1:db"},
+		Env:   []envVar{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}},
+		Ports: []containerPort{{ContainerPort: 5432}},
+	}
+	if diff := cmp.Diff(want, pod.Containers[1]); diff != "" {
+		t.Errorf("sidecar (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// blockingFuncs are the functions and methods that block for long, or
+// forever, by full name.
+var blockingFuncs = map[string]bool{
+	"time.Sleep":                                         true,
+	"net/http.ListenAndServe":                            true,
+	"net/http.ListenAndServeTLS":                         true,
+	"net/http.Serve":                                     true,
+	"net/http.ServeTLS":                                  true,
+	"(*net/http.Server).ListenAndServe":                  true,
+	"(*net/http.Server).ListenAndServeTLS":               true,
+	"(*net/http.Server).Serve":                           true,
+	"(*net/http.Server).ServeTLS":                        true,
+	"(net.Listener).Accept":                              true,
+	"(*sync.WaitGroup).Wait":                             true,
+	"(*sync.Cond).Wait":                                  true,
+	"(*google.golang.org/grpc.Server).Serve":             true,
+	"(*github.com/ServiceWeaver/weaver.Listener).Accept": true,
+}
+
+// BlockingInit reports the Init methods of components that block. A
+// component, and every component that calls it, is unusable until its Init
+// method returns, and an application doesn't start until the Init methods of
+// its components return. Long-running work, like serving requests, belongs in
+// a goroutine.
+var BlockingInit = &analysis.Analyzer{
+	Name: "blockinginit",
+	Doc:  "report component Init methods that block\n\nA component can't be called until its Init method returns, so Init\nshould run long-running work, like serving requests, in a goroutine.",
+	Run:  runBlockingInit,
+}
+
+func runBlockingInit(pass *analysis.Pass) (any, error) {
+	for _, c := range components(pass) {
+		fn, ok := c.methods["Init"]
+		if !ok {
+			continue
+		}
+		report := func(n ast.Node, what string) {
+			pass.Reportf(n.Pos(), "Init method of component %s %s, which blocks; run it in a goroutine", c.name(), what)
+		}
+		var visit func(n ast.Node) bool
+		visit = func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if f := callee(pass.TypesInfo, n); f != nil && blockingFuncs[f.FullName()] {
+					report(n, "calls "+f.FullName())
+				}
+			case *ast.SelectStmt:
+				// A select with a default case doesn't block, and the
+				// communications of a select are reported as one.
+				blocks := true
+				for _, clause := range n.Body.List {
+					clause := clause.(*ast.CommClause)
+					if clause.Comm == nil {
+						blocks = false
+					}
+					for _, stmt := range clause.Body {
+						inspectCall(stmt, visit)
+					}
+				}
+				if blocks {
+					report(n, "selects without a default case")
+				}
+				return false
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					report(n, "receives from a channel")
+				}
+			case *ast.RangeStmt:
+				if t := pass.TypesInfo.TypeOf(n.X); t != nil && isChan(t) {
+					report(n, "ranges over a channel")
+				}
+			case *ast.ForStmt:
+				if n.Cond == nil && !exits(n.Body) {
+					report(n, "loops forever")
+				}
+			}
+			return true
+		}
+		inspectCall(fn.Body, visit)
+	}
+	return nil, nil
+}
+
+// isChan returns whether t is a channel type.
+func isChan(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
+
+// exits returns whether the provided loop body may exit the loop, i.e.,
+// whether it returns, breaks, or jumps.
+func exits(body *ast.BlockStmt) bool {
+	exits := false
+	inspectCall(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ReturnStmt:
+			exits = true
+		case *ast.BranchStmt:
+			if n.Tok == token.BREAK || n.Tok == token.GOTO {
+				exits = true
+			}
+		}
+		return !exits
+	})
+	return exits
+}
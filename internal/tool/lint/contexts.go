@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Contexts reports the component methods that misuse the context of their
+// calls:
+//
+//   - methods that call context.Background or context.TODO, rather than use
+//     their context, so that the deadline, the cancellation, and the trace of
+//     the call, and the metadata propagated by Service Weaver, are lost;
+//   - methods that store their context in a field, from which later calls
+//     may use it once it is cancelled; and
+//   - methods that use their context in a goroutine, which may outlive the
+//     call and its context.
+//
+// Goroutines that use context.Background are fine: background work must not
+// be cancelled when the call that started it returns.
+var Contexts = &analysis.Analyzer{
+	Name: "contexts",
+	Doc:  "report component methods that misuse the context of their calls\n\nComponent methods should pass their context to the functions they call,\nrather than context.Background, and should not use it once they return.",
+	Run:  runContexts,
+}
+
+func runContexts(pass *analysis.Pass) (any, error) {
+	for _, c := range components(pass) {
+		for name, fn := range c.methods {
+			if !c.isComponentMethod(name) || len(fn.Type.Params.List) == 0 {
+				continue
+			}
+			param := fn.Type.Params.List[0]
+			if !isNamed(pass.TypesInfo.TypeOf(param.Type), "context", "Context") {
+				continue
+			}
+			var ctx *types.Var
+			if len(param.Names) > 0 {
+				ctx, _ = pass.TypesInfo.Defs[param.Names[0]].(*types.Var)
+			}
+			method := "method " + name + " of component " + c.name()
+
+			inspectCall(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					if f := callee(pass.TypesInfo, n); f != nil && (f.FullName() == "context.Background" || f.FullName() == "context.TODO") {
+						pass.Reportf(n.Pos(), "%s calls %s; use the context of the call, so that its deadline, cancellation, and trace propagate", method, f.FullName())
+					}
+				case *ast.AssignStmt:
+					if ctx == nil || len(n.Lhs) != len(n.Rhs) {
+						break
+					}
+					for i, rhs := range n.Rhs {
+						id, ok := unparen(rhs).(*ast.Ident)
+						if !ok || pass.TypesInfo.Uses[id] != ctx {
+							continue
+						}
+						if _, ok := unparen(n.Lhs[i]).(*ast.SelectorExpr); ok {
+							pass.Reportf(n.Pos(), "%s stores the context of the call, which is cancelled once the call returns; pass contexts explicitly instead", method)
+						}
+					}
+				}
+				return true
+			})
+
+			if ctx == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				g, ok := n.(*ast.GoStmt)
+				if !ok {
+					return true
+				}
+				reported := false
+				ast.Inspect(g, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok && !reported && pass.TypesInfo.Uses[id] == ctx {
+						pass.Reportf(id.Pos(), "%s uses the context of the call in a goroutine, which may outlive the call; the context is cancelled once the call returns", method)
+						reported = true
+					}
+					return !reported
+				})
+				return false
+			})
+		}
+	}
+	return nil, nil
+}
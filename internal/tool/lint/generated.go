@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Generated reports the components and the types that embed
+// weaver.AutoMarshal that "weaver generate" hasn't processed since they were
+// declared. An application whose components aren't registered by the
+// generated code fails when it starts, and a type without generated
+// WeaverMarshal and WeaverUnmarshal methods is silently serialized as an
+// empty struct.
+var Generated = &analysis.Analyzer{
+	Name: "generated",
+	Doc:  "report components and weaver.AutoMarshal types missing from weaver_gen.go\n\nRun \"weaver generate\" to fix the reported problems.",
+	Run:  runGenerated,
+}
+
+func runGenerated(pass *analysis.Pass) (any, error) {
+	components := components(pass)
+	automarshals := automarshals(pass)
+	if len(components) == 0 && len(automarshals) == 0 {
+		return nil, nil
+	}
+
+	// Find the objects referenced by the generated code.
+	var generated *ast.File
+	for _, f := range pass.Files {
+		if isGeneratedFile(pass, f) {
+			generated = f
+			break
+		}
+	}
+	if generated == nil {
+		for _, c := range components {
+			pass.Reportf(c.pos, "component %s has no generated code; run \"weaver generate\"", c.name())
+		}
+		for _, n := range automarshals {
+			pass.Reportf(n.Obj().Pos(), "type %s embeds weaver.AutoMarshal but has no generated code; run \"weaver generate\"", n.Obj().Name())
+		}
+		return nil, nil
+	}
+	used := map[types.Object]bool{}
+	ast.Inspect(generated, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := pass.TypesInfo.Uses[id]; obj != nil {
+				used[obj] = true
+			}
+		}
+		return true
+	})
+
+	for _, c := range components {
+		if !used[c.impl.Obj()] {
+			pass.Reportf(c.pos, "component %s is not registered by %s; run \"weaver generate\"", c.name(), generatedCodeFile)
+		}
+	}
+	for _, n := range automarshals {
+		// The generated methods are declared on the type itself, while the
+		// methods of the embedded weaver.AutoMarshal are promoted.
+		obj, index, _ := types.LookupFieldOrMethod(types.NewPointer(n), false, pass.Pkg, "WeaverMarshal")
+		if _, ok := obj.(*types.Func); !ok || len(index) != 1 {
+			pass.Reportf(n.Obj().Pos(), "type %s embeds weaver.AutoMarshal but has no generated WeaverMarshal method; run \"weaver generate\"", n.Obj().Name())
+		}
+	}
+	return nil, nil
+}
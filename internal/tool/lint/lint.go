@@ -0,0 +1,301 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint implements "weaver lint", a set of go/analysis analyzers that
+// flag common mistakes in Service Weaver applications: component methods with
+// arguments or results that can't be serialized, components that modify their
+// fields without synchronization, packages whose generated code is missing or
+// stale, Init methods that block, and component methods that misuse their
+// context.
+//
+// The analyzers run with "weaver lint [packages]", or with
+// "go vet -vettool=$(which weaver) [packages]".
+package lint
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	weaverPackagePath = "github.com/ServiceWeaver/weaver"
+	generatedCodeFile = "weaver_gen.go"
+)
+
+// Usage is the usage of "weaver lint".
+const Usage = `Check Service Weaver applications for common mistakes.
+
+Usage:
+  weaver lint [-flag] [packages]
+
+Description:
+  "weaver lint" runs static checks on the provided packages, like "go vet",
+  and reports the following mistakes:
+
+    serializable  component method arguments and results, and types that embed
+                  weaver.AutoMarshal, that can't be serialized
+    generated     components and weaver.AutoMarshal types that "weaver generate"
+                  hasn't processed yet
+    mutablestate  component methods that modify the fields of their component
+                  without holding a lock
+    blockinginit  Init methods that block, e.g., by serving requests
+    contexts      component methods that ignore their context, or use it after
+                  they return
+
+  The checks also run as part of "go vet":
+
+    go vet -vettool=$(which weaver) ./...
+
+  Run "weaver lint help" for the flags of the checks, e.g., to disable some.
+
+Examples:
+  # Check the packages in the current directory and its subdirectories.
+  weaver lint ./...
+
+  # Only check that the generated code is up to date.
+  weaver lint -generated ./...`
+
+// Analyzers are the analyzers run by "weaver lint".
+var Analyzers = []*analysis.Analyzer{
+	Serializable,
+	Generated,
+	MutableState,
+	BlockingInit,
+	Contexts,
+}
+
+// component is a component implementation declared in the analyzed package,
+// i.e., a struct that embeds weaver.Implements[T].
+type component struct {
+	impl    *types.Named                 // the implementation
+	iface   *types.Named                 // the component interface, T
+	pos     token.Pos                    // the position of the implementation
+	methods map[string]*ast.FuncDecl     // the methods of the implementation, by name
+	recvs   map[*ast.FuncDecl]*types.Var // the receivers of the methods, if named
+}
+
+// name returns the name of the component interface.
+func (c *component) name() string {
+	return c.iface.Obj().Name()
+}
+
+// isComponentMethod returns whether the provided method of the implementation
+// implements a method of the component interface.
+func (c *component) isComponentMethod(name string) bool {
+	iface := c.iface.Underlying().(*types.Interface)
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// components returns the component implementations declared in the package
+// analyzed by the provided pass.
+func components(pass *analysis.Pass) []*component {
+	if pass.Pkg.Path() == weaverPackagePath {
+		// The weaver package registers its components by hand.
+		return nil
+	}
+	var components []*component
+	byImpl := map[*types.TypeName]*component{}
+	for _, f := range pass.Files {
+		if isGeneratedFile(pass, f) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				def, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+				if !ok || def.IsAlias() {
+					continue
+				}
+				impl, ok := def.Type().(*types.Named)
+				if !ok || impl.TypeParams() != nil {
+					// Generic components are ignored.
+					continue
+				}
+				s, ok := impl.Underlying().(*types.Struct)
+				if !ok {
+					continue
+				}
+				for i := 0; i < s.NumFields(); i++ {
+					f := s.Field(i)
+					if !f.Embedded() || !isWeaverType(f.Type(), "Implements", 1) {
+						continue
+					}
+					iface, ok := f.Type().(*types.Named).TypeArgs().At(0).(*types.Named)
+					if !ok {
+						continue
+					}
+					if _, ok := iface.Underlying().(*types.Interface); !ok {
+						continue
+					}
+					c := &component{
+						impl:    impl,
+						iface:   iface,
+						pos:     spec.Name.Pos(),
+						methods: map[string]*ast.FuncDecl{},
+						recvs:   map[*ast.FuncDecl]*types.Var{},
+					}
+					components = append(components, c)
+					byImpl[def] = c
+					break
+				}
+			}
+		}
+	}
+
+	// Find the methods of the implementations.
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil || len(fn.Recv.List) != 1 {
+				continue
+			}
+			recv := fn.Recv.List[0]
+			t := pass.TypesInfo.TypeOf(recv.Type)
+			if p, ok := t.(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			n, ok := t.(*types.Named)
+			if !ok {
+				continue
+			}
+			c, ok := byImpl[n.Obj()]
+			if !ok {
+				continue
+			}
+			c.methods[fn.Name.Name] = fn
+			if len(recv.Names) == 1 {
+				if v, ok := pass.TypesInfo.Defs[recv.Names[0]].(*types.Var); ok {
+					c.recvs[fn] = v
+				}
+			}
+		}
+	}
+	return components
+}
+
+// automarshals returns the struct types declared in the package analyzed by
+// the provided pass that embed weaver.AutoMarshal. Generic types, which can't
+// embed weaver.AutoMarshal, are ignored.
+func automarshals(pass *analysis.Pass) []*types.Named {
+	var automarshals []*types.Named
+	for _, f := range pass.Files {
+		if isGeneratedFile(pass, f) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				def, ok := pass.TypesInfo.Defs[spec.(*ast.TypeSpec).Name].(*types.TypeName)
+				if !ok || def.IsAlias() {
+					continue
+				}
+				n, ok := def.Type().(*types.Named)
+				if !ok || n.TypeParams() != nil {
+					continue
+				}
+				s, ok := n.Underlying().(*types.Struct)
+				if !ok {
+					continue
+				}
+				for i := 0; i < s.NumFields(); i++ {
+					if s.Field(i).Embedded() && isWeaverType(s.Field(i).Type(), "AutoMarshal", 0) {
+						automarshals = append(automarshals, n)
+						break
+					}
+				}
+			}
+		}
+	}
+	return automarshals
+}
+
+// isGeneratedFile returns whether the provided file was generated by "weaver
+// generate".
+func isGeneratedFile(pass *analysis.Pass, f *ast.File) bool {
+	return filepath.Base(pass.Fset.Position(f.Package).Filename) == generatedCodeFile
+}
+
+// isWeaverType returns whether t is a named type from the weaver package with
+// the provided name and number of type arguments.
+func isWeaverType(t types.Type, name string, n int) bool {
+	named, ok := t.(*types.Named)
+	return ok &&
+		named.Obj().Pkg() != nil &&
+		named.Obj().Pkg().Path() == weaverPackagePath &&
+		named.Obj().Name() == name &&
+		named.TypeArgs().Len() == n
+}
+
+// isNamed returns whether t is the named type with the provided package path
+// and name.
+func isNamed(t types.Type, path, name string) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == path && n.Obj().Name() == name
+}
+
+// callee returns the function or method called by the provided call, or nil
+// if the call is not a static call of a declared function or method.
+func callee(info *types.Info, call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, _ := info.Uses[id].(*types.Func)
+	return fn
+}
+
+// unparen returns e with any enclosing parentheses stripped.
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+// inspectCall calls f on the nodes of the provided part of a function body in
+// depth first order, like ast.Inspect, but skips the function literals and
+// the go statements in it, which may run after the function returns.
+func inspectCall(node ast.Node, f func(ast.Node) bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit, *ast.GoStmt:
+			return false
+		}
+		return f(n)
+	})
+}
@@ -0,0 +1,419 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/generate"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// wantRE matches the comments that annotate the lines on which diagnostics
+// are expected, e.g., // want "not serializable".
+var wantRE = regexp.MustCompile(`// want (".*")$`)
+
+// setup writes the provided files, keyed by name, to a new module that
+// depends on this repository, and returns the module directory. If generated
+// is not nil, "weaver generate" runs before the generated files are written.
+func setup(t *testing.T, files, generated map[string]string) string {
+	t.Helper()
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("no caller information")
+	}
+	weaverSrcDir := filepath.Join(filepath.Dir(filename), "../../../")
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", fmt.Sprintf(`module "foo"
+
+go 1.19
+
+require github.com/ServiceWeaver/weaver v0.0.0
+replace github.com/ServiceWeaver/weaver => %s
+`, weaverSrcDir))
+	for name, contents := range files {
+		write(name, contents)
+	}
+	tidy := exec.Command("go", "mod", "tidy")
+	tidy.Dir = dir
+	if out, err := tidy.CombinedOutput(); err != nil {
+		t.Fatalf("go mod tidy: %v\n%s", err, out)
+	}
+	if generated != nil {
+		if err := generate.Generate(dir, []string{dir}, generate.Options{}); err != nil {
+			t.Fatalf("weaver generate: %v", err)
+		}
+		for name, contents := range generated {
+			write(name, contents)
+		}
+	}
+	return dir
+}
+
+// check runs the provided analyzer on the package in the provided directory,
+// and checks that it reports a diagnostic on every line annotated with a want
+// comment, and on no other line.
+func check(t *testing.T, a *analysis.Analyzer, dir string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedTypesSizes,
+		Dir:  dir,
+		Fset: fset,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || len(pkgs[0].Errors) > 0 {
+		t.Fatalf("load: got %d packages, errors %v", len(pkgs), pkgs[0].Errors)
+	}
+	pkg := pkgs[0]
+
+	// Collect the expected diagnostics.
+	want := map[string]*regexp.Regexp{} // by file:line
+	for _, f := range pkg.Syntax {
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				m := wantRE.FindStringSubmatch(c.Text)
+				if m == nil {
+					continue
+				}
+				re, err := strconv.Unquote(m[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				pos := fset.Position(c.Pos())
+				want[fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line)] = regexp.MustCompile(re)
+			}
+		}
+	}
+
+	// Run the analyzer.
+	got := map[string][]string{} // by file:line
+	pass := &analysis.Pass{
+		Analyzer:   a,
+		Fset:       fset,
+		Files:      pkg.Syntax,
+		Pkg:        pkg.Types,
+		TypesInfo:  pkg.TypesInfo,
+		TypesSizes: pkg.TypesSizes,
+		ResultOf:   map[*analysis.Analyzer]any{},
+		Report: func(d analysis.Diagnostic) {
+			pos := fset.Position(d.Pos)
+			line := fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line)
+			got[line] = append(got[line], d.Message)
+		},
+	}
+	if _, err := a.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+
+	for line, re := range want {
+		if len(got[line]) == 0 {
+			t.Errorf("%s: no diagnostic, want one matching %q", line, re)
+		}
+		for _, msg := range got[line] {
+			if !re.MatchString(msg) {
+				t.Errorf("%s: got diagnostic %q, want one matching %q", line, msg, re)
+			}
+		}
+	}
+	var unexpected []string
+	for line, msgs := range got {
+		if want[line] == nil {
+			unexpected = append(unexpected, fmt.Sprintf("%s: %s", line, strings.Join(msgs, "; ")))
+		}
+	}
+	sort.Strings(unexpected)
+	if len(unexpected) > 0 {
+		t.Errorf("unexpected diagnostics:\n%s", strings.Join(unexpected, "\n"))
+	}
+}
+
+func TestSerializable(t *testing.T) {
+	dir := setup(t, map[string]string{"foo.go": `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Pair struct {
+	weaver.AutoMarshal
+	Key   string
+	Value int
+}
+
+type Bad struct { // want "type Bad embeds weaver.AutoMarshal but is not serializable"
+	weaver.AutoMarshal
+	Done chan struct{}
+}
+
+type Unmarked struct {
+	Key string
+}
+
+type Store interface {
+	Put(ctx context.Context, p Pair) error
+	Watch(ctx context.Context, ch chan string) error // want "argument ch of method Watch of component Store is not serializable"
+	Get(context.Context, Unmarked) (func(), error)   // want "(argument 1|result 0) of method Get of component Store is not serializable"
+	Scan(ctx context.Context) (weaver.Stream[Pair], error)
+}
+
+type store struct {
+	weaver.Implements[Store]
+}
+
+func (*store) Put(context.Context, Pair) error                      { return nil }
+func (*store) Watch(context.Context, chan string) error             { return nil }
+func (*store) Get(context.Context, Unmarked) (func(), error)        { return nil, nil }
+func (*store) Scan(context.Context) (weaver.Stream[Pair], error)    { return nil, nil }
+`}, nil)
+	check(t, Serializable, dir)
+}
+
+func TestGenerated(t *testing.T) {
+	const a = `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Pair struct {
+	weaver.AutoMarshal
+	Key string
+}
+
+type A interface {
+	Put(context.Context, Pair) error
+}
+
+type a struct {
+	weaver.Implements[A]
+}
+
+func (*a) Put(context.Context, Pair) error { return nil }
+`
+	// Components and types declared after "weaver generate" ran.
+	const b = `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Triple struct { // want "type Triple embeds weaver.AutoMarshal but has no generated WeaverMarshal method"
+	weaver.AutoMarshal
+	Key string
+}
+
+type B interface {
+	Get(context.Context) error
+}
+
+type b struct { // want "component B is not registered by weaver_gen.go"
+	weaver.Implements[B]
+}
+
+func (*b) Get(context.Context) error { return nil }
+`
+	t.Run("Stale", func(t *testing.T) {
+		dir := setup(t, map[string]string{"a.go": a}, map[string]string{"b.go": b})
+		check(t, Generated, dir)
+	})
+	t.Run("Missing", func(t *testing.T) {
+		missing := strings.NewReplacer(
+			"type Pair struct {", `type Pair struct { // want "type Pair embeds weaver.AutoMarshal but has no generated code"`,
+			"type a struct {", `type a struct { // want "component A has no generated code"`,
+		).Replace(a)
+		dir := setup(t, map[string]string{"a.go": missing}, nil)
+		check(t, Generated, dir)
+	})
+}
+
+func TestMutableState(t *testing.T) {
+	dir := setup(t, map[string]string{"foo.go": `package foo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Counter interface {
+	Add(context.Context, string) error
+}
+
+type counter struct {
+	weaver.Implements[Counter]
+	counts map[string]int
+	total  int
+	last   struct{ key string }
+}
+
+func (c *counter) Init(context.Context) error {
+	c.counts = map[string]int{}
+	return nil
+}
+
+func (c *counter) Add(_ context.Context, key string) error {
+	c.counts[key]++ // want "method Add of component Counter modifies field counts without a mutex"
+	c.total += 1    // want "modifies field total"
+	c.last.key = key // want "modifies field last"
+	local := c.counts
+	local = nil
+	_ = local
+	return nil
+}
+
+func (c *counter) reset() {
+	delete(c.counts, "") // want "method reset of component Counter modifies field counts"
+}
+
+type Locked interface {
+	Add(context.Context, string) error
+}
+
+type locked struct {
+	weaver.Implements[Locked]
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (l *locked) Add(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[key]++
+	return nil
+}
+`}, nil)
+	check(t, MutableState, dir)
+}
+
+func TestBlockingInit(t *testing.T) {
+	dir := setup(t, map[string]string{"foo.go": `package foo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Server interface{}
+
+type server struct {
+	weaver.Implements[Server]
+	lis  weaver.Listener ` + "`weaver:\"server\"`" + `
+	done chan struct{}
+}
+
+func (s *server) Init(ctx context.Context) error {
+	go http.Serve(&s.lis, nil)
+	go func() {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+	}()
+	select {
+	case <-s.done:
+	default:
+	}
+	time.Sleep(time.Second)  // want "Init method of component Server calls time.Sleep"
+	<-s.done                 // want "receives from a channel"
+	for range s.done {       // want "ranges over a channel"
+	}
+	for {                    // want "loops forever"
+		s.lis.Accept()       // want "calls \\(\\*github.com/ServiceWeaver/weaver.Listener\\).Accept"
+	}
+	select {                 // want "selects without a default case"
+	case <-s.done:
+	case <-ctx.Done():
+	}
+	return http.Serve(&s.lis, nil) // want "calls net/http.Serve"
+}
+`}, nil)
+	check(t, BlockingInit, dir)
+}
+
+func TestContexts(t *testing.T) {
+	dir := setup(t, map[string]string{"foo.go": `package foo
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+type Mailer interface {
+	Send(context.Context, string) error
+	Flush(context.Context) error
+}
+
+type mailer struct {
+	weaver.Implements[Mailer]
+	ctx context.Context
+}
+
+func (m *mailer) Send(ctx context.Context, to string) error {
+	m.ctx = ctx                    // want "method Send of component Mailer stores the context of the call"
+	go m.send(ctx, to)             // want "uses the context of the call in a goroutine"
+	go m.send(context.Background(), to)
+	return m.send(context.TODO(), to) // want "calls context.TODO"
+}
+
+func (m *mailer) Flush(context.Context) error {
+	return m.send(context.Background(), "") // want "method Flush of component Mailer calls context.Background"
+}
+
+func (m *mailer) send(ctx context.Context, to string) error {
+	return nil
+}
+`}, nil)
+	check(t, Contexts, dir)
+}
+
+func TestAnalyzersValid(t *testing.T) {
+	if err := analysis.Validate(Analyzers); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range Analyzers {
+		if !strings.Contains(Usage, "    "+a.Name+" ") {
+			t.Errorf("Usage doesn't describe analyzer %q", a.Name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// MutableState reports the methods of a component that modify its fields,
+// when the component has no mutex. The methods of a component are called
+// concurrently, so unsynchronized modifications are data races. The Init
+// method, which runs before any other, may modify the fields.
+//
+// The check is deliberately simple: any sync.Mutex or sync.RWMutex field
+// silences it, whether or not the methods hold the mutex.
+var MutableState = &analysis.Analyzer{
+	Name: "mutablestate",
+	Doc:  "report component methods that modify the fields of their component without a mutex\n\nThe methods of a component are called concurrently, so they must\nsynchronize their accesses to the fields of the component.",
+	Run:  runMutableState,
+}
+
+func runMutableState(pass *analysis.Pass) (any, error) {
+	for _, c := range components(pass) {
+		if hasMutex(c.impl.Underlying().(*types.Struct)) {
+			continue
+		}
+		for name, fn := range c.methods {
+			recv, ok := c.recvs[fn]
+			if name == "Init" || !ok {
+				continue
+			}
+			reported := map[*types.Var]bool{}
+			report := func(pos token.Pos, e ast.Expr) {
+				field := receiverField(pass.TypesInfo, recv, e)
+				if field == nil || reported[field] {
+					return
+				}
+				reported[field] = true
+				pass.Reportf(pos, "method %s of component %s modifies field %s without a mutex; the methods of a component are called concurrently", name, c.name(), field.Name())
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.AssignStmt:
+					if n.Tok == token.DEFINE {
+						break
+					}
+					for _, lhs := range n.Lhs {
+						report(lhs.Pos(), lhs)
+					}
+				case *ast.IncDecStmt:
+					report(n.X.Pos(), n.X)
+				case *ast.CallExpr:
+					if id, ok := unparen(n.Fun).(*ast.Ident); ok && len(n.Args) > 0 {
+						if b, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok && b.Name() == "delete" {
+							report(n.Args[0].Pos(), n.Args[0])
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return nil, nil
+}
+
+// hasMutex returns whether the provided struct has a sync.Mutex or
+// sync.RWMutex field, or a pointer to one.
+func hasMutex(s *types.Struct) bool {
+	for i := 0; i < s.NumFields(); i++ {
+		t := s.Field(i).Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if isNamed(t, "sync", "Mutex") || isNamed(t, "sync", "RWMutex") {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverField returns the field of the provided receiver that the provided
+// expression, the target of a modification, modifies, if any. For example,
+// the target s.counts[k] modifies the field counts of receiver s.
+func receiverField(info *types.Info, recv *types.Var, e ast.Expr) *types.Var {
+	for {
+		switch x := unparen(e).(type) {
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			if id, ok := unparen(x.X).(*ast.Ident); ok && info.Uses[id] == recv {
+				field, _ := info.Uses[x.Sel].(*types.Var)
+				if field == nil || !field.IsField() {
+					return nil
+				}
+				return field
+			}
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
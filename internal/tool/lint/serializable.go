@@ -0,0 +1,85 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"go/types"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/generate"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Serializable reports the arguments and results of component methods, and
+// the fields of types that embed weaver.AutoMarshal, that can't be
+// serialized. "weaver generate" rejects them too, but only once it runs.
+var Serializable = &analysis.Analyzer{
+	Name: "serializable",
+	Doc:  "report component method arguments and results that can't be serialized\n\nSee https://serviceweaver.dev/docs.html#serializable-types.",
+	Run:  runSerializable,
+}
+
+func runSerializable(pass *analysis.Pass) (any, error) {
+	automarshals := automarshals(pass)
+	for _, n := range automarshals {
+		for _, err := range generate.CheckSerializable(pass.Pkg, automarshals, n) {
+			pass.Reportf(n.Obj().Pos(), "type %s embeds weaver.AutoMarshal but is not serializable: %v", n.Obj().Name(), err)
+		}
+	}
+
+	for _, c := range components(pass) {
+		iface := c.iface.Underlying().(*types.Interface)
+		for i := 0; i < iface.NumMethods(); i++ {
+			m := iface.Method(i)
+			sig := m.Type().(*types.Signature)
+			check := func(what string, v *types.Var) {
+				t := v.Type()
+				if isWeaverType(t, "Stream", 1) {
+					t = t.(*types.Named).TypeArgs().At(0)
+				}
+				pos := v.Pos()
+				if m.Pkg() != pass.Pkg || !pos.IsValid() {
+					// Report the errors of interfaces declared in other
+					// packages at the implementation.
+					pos = c.pos
+				}
+				for _, err := range generate.CheckSerializable(pass.Pkg, automarshals, t) {
+					pass.Reportf(pos, "%s of method %s of component %s is not serializable: %v", what, m.Name(), c.name(), err)
+				}
+			}
+			for j := 0; j < sig.Params().Len(); j++ {
+				if p := sig.Params().At(j); j > 0 || !isNamed(p.Type(), "context", "Context") {
+					check(argName(j, p), p)
+				}
+			}
+			for j := 0; j < sig.Results().Len(); j++ {
+				r := sig.Results().At(j)
+				if j == sig.Results().Len()-1 && types.Identical(r.Type(), types.Universe.Lookup("error").Type()) {
+					continue
+				}
+				check(fmt.Sprintf("result %d", j), r)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// argName returns the name of the provided argument in diagnostics.
+func argName(i int, v *types.Var) string {
+	if v.Name() != "" && v.Name() != "_" {
+		return "argument " + v.Name()
+	}
+	return fmt.Sprintf("argument %d", i)
+}
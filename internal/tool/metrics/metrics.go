@@ -0,0 +1,115 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics implements the "weaver metrics" commands, which help
+// monitor the metrics of applications.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/internal/grafana"
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var (
+	grafanaFlags = flag.NewFlagSet("grafana", flag.ContinueOnError)
+	grafanaTitle = grafanaFlags.String("title", "", "Dashboard title (default: the app name)")
+	grafanaUID   = grafanaFlags.String("uid", "", "Dashboard uid (default: picked by Grafana)")
+
+	grafanaCmd = tool.Command{
+		Name:        "grafana",
+		Description: "Generate a Grafana dashboard for an app",
+		Help: `Usage:
+  weaver metrics grafana [--title=<title>] [--uid=<uid>] <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+  --title	The title of the dashboard (default: the app name)
+  --uid		The uid of the dashboard, to update the same dashboard
+		when importing it again (default: picked by Grafana)
+
+Description:
+  "weaver metrics grafana" prints a Grafana dashboard, as JSON, for the
+  metrics of the app whose config file is provided, as scraped by
+  Prometheus. Import it in Grafana, e.g., with Dashboards > New > Import.
+
+  The dashboard has an overview row with the calls, errors, and latency of
+  every component; a row for every component, with the calls, errors, and
+  latency of every method; a row for the HTTP handlers instrumented with
+  weaver.InstrumentHandler; and a row with a panel for every metric the app
+  defines. It queries the Prometheus data source picked by its
+  "datasource" variable.
+
+  To find its components and metrics, the binary is run with the config's
+  args and env, but it only describes them and exits when it calls
+  weaver.Init.
+
+Examples:
+  # Generate a dashboard for the app.
+  weaver metrics grafana weaver.toml > dashboard.json`,
+		Flags: grafanaFlags,
+		Fn:    generateGrafana,
+	}
+
+	Commands = map[string]*tool.Command{
+		"grafana": &grafanaCmd,
+		"version": tool.VersionCmd("weaver metrics"),
+	}
+)
+
+// generateGrafana implements "weaver metrics grafana".
+func generateGrafana(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	file := args[0]
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", file, err)
+	}
+	config, err := runtime.ParseConfig(file, string(contents), func(string, string) error { return nil })
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", file, err)
+	}
+
+	data, err := plan.RunBinary(ctx, config, runtime.DescribeMetricsKey, "describe its metrics")
+	if err != nil {
+		return err
+	}
+	var app grafana.App
+	if err := json.Unmarshal(data, &app); err != nil {
+		return fmt.Errorf("binary %q: invalid metric definitions: %w", config.Binary, err)
+	}
+
+	title := *grafanaTitle
+	if title == "" {
+		title = config.Name
+	}
+	dashboard, err := grafana.Dashboard(app, grafana.Options{Title: title, UID: *grafanaUID})
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(dashboard))
+	return nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multi
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/internal/traceio"
+	"github.com/ServiceWeaver/weaver/runtime/envelope"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// chaosGroup is the co-location group under which the faults injected by
+// chaos testing appear in the deployment's traces.
+const chaosGroup = "chaos"
+
+// A chaosFault is a partition or latency fault in progress, injected by chaos
+// testing. See runtime.ChaosConfig.
+type chaosFault struct {
+	kind   string   // "partition" or "latency"
+	groups []string // the two partitioned groups, or the slowed group
+}
+
+// chaos injects faults into the deployment, following the schedule in
+// d.chaosConfig, until the deployer is stopped. Every fault is recorded as a
+// span in the deployment's traces.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) chaos() {
+	config := d.chaosConfig
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	d.logger.Info("Chaos testing enabled", "seed", seed, "interval", config.Interval, "faults", config.Faults)
+
+	tracer := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(chaosExporter{d}),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("serviceweaver/chaos"),
+			semconv.ProcessPIDKey.Int(os.Getpid()),
+			traceio.AppNameTraceKey.String(d.config.Name),
+			traceio.VersionTraceKey.String(d.deploymentId),
+			traceio.ColocationGroupNameTraceKey.String(chaosGroup),
+			traceio.GroupReplicaIDTraceKey.String(d.deploymentId),
+		)),
+	).Tracer("github.com/ServiceWeaver/weaver/multi/chaos")
+
+	for {
+		// Faults are injected at exponentially distributed intervals, i.e.,
+		// as a Poisson process, so that they are hard to anticipate.
+		timer := time.NewTimer(time.Duration(rng.ExpFloat64() * float64(config.Interval)))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		kind := config.Faults[rng.Intn(len(config.Faults))]
+		var err error
+		switch kind {
+		case "kill":
+			err = d.chaosKill(rng, tracer)
+		case "partition", "latency":
+			err = d.chaosInject(rng, tracer, kind)
+		}
+		if err != nil {
+			d.logger.Error("chaos: cannot inject fault", err, "fault", kind)
+		}
+	}
+}
+
+// chaosKill kills a random weavelet outside the main group. The weavelet is
+// then replaced, like any failed weavelet.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) chaosKill(rng *rand.Rand, tracer trace.Tracer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil
+	}
+
+	type victim struct {
+		g *group
+		e *envelope.Envelope
+	}
+	var victims []victim
+	for _, name := range d.chaosGroups(false) {
+		g := d.groups[name]
+		for _, e := range g.envelopes {
+			if !g.removed[e] && !g.killed[e] {
+				victims = append(victims, victim{g, e})
+			}
+		}
+	}
+	if len(victims) == 0 {
+		return nil
+	}
+	v := victims[rng.Intn(len(victims))]
+	wlet := v.e.WeaveletInfo()
+	_, span := tracer.Start(d.ctx, "chaos: kill", trace.WithAttributes(
+		attribute.String("group", v.g.name),
+		attribute.String("weavelet", wlet.DialAddr),
+		attribute.Int64("pid", wlet.Pid),
+	))
+	defer span.End()
+	d.logger.Warn("chaos: killing weavelet", "group", v.g.name, "weavelet", wlet.DialAddr, "pid", wlet.Pid)
+	d.events.Record(events.FaultInjected, v.g.name, wlet.DialAddr, "Chaos testing killed the weavelet", "fault", "kill", "pid", fmt.Sprint(wlet.Pid))
+	v.g.killed[v.e] = true
+	v.g.cancels[v.e]()
+	return nil
+}
+
+// chaosInject partitions two random groups from each other, or slows down
+// the calls to a random group, depending on the provided kind of fault, for
+// d.chaosConfig.Duration.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) chaosInject(rng *rand.Rand, tracer trace.Tracer, kind string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil
+	}
+
+	f := &chaosFault{kind: kind}
+	var attrs []attribute.KeyValue
+	switch kind {
+	case "partition":
+		groups := d.chaosGroups(true)
+		if len(groups) < 2 {
+			return nil
+		}
+		rng.Shuffle(len(groups), func(i, j int) { groups[i], groups[j] = groups[j], groups[i] })
+		f.groups = groups[:2]
+		attrs = append(attrs, attribute.StringSlice("groups", f.groups))
+		d.logger.Warn("chaos: partitioning groups", "groups", f.groups, "duration", d.chaosConfig.Duration)
+	case "latency":
+		// Calls to the main group are rare, so it is never slowed down.
+		groups := d.chaosGroups(false)
+		if len(groups) == 0 {
+			return nil
+		}
+		f.groups = []string{groups[rng.Intn(len(groups))]}
+		attrs = append(attrs,
+			attribute.String("group", f.groups[0]),
+			attribute.String("latency", d.chaosConfig.Latency.String()))
+		d.logger.Warn("chaos: slowing down group", "group", f.groups[0], "latency", d.chaosConfig.Latency, "duration", d.chaosConfig.Duration)
+	}
+	d.events.Record(events.FaultInjected, "", "", "Chaos testing injected a fault", "fault", kind, "groups", strings.Join(f.groups, ","), "duration", d.chaosConfig.Duration.String())
+	_, span := tracer.Start(d.ctx, "chaos: "+kind, trace.WithAttributes(attrs...))
+	d.faults = append(d.faults, f)
+	err := d.injectFaults()
+
+	// Heal the fault once it has lasted long enough.
+	d.running.Go(func() error {
+		defer span.End()
+		timer := time.NewTimer(d.chaosConfig.Duration)
+		defer timer.Stop()
+		select {
+		case <-d.ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if i := slices.Index(d.faults, f); i >= 0 {
+			d.faults = slices.Delete(d.faults, i, i+1)
+		}
+		if d.err != nil {
+			return nil
+		}
+		d.logger.Info("chaos: fault healed", "fault", kind, "groups", f.groups)
+		d.events.Record(events.FaultRemoved, "", "", "Chaos testing healed a fault", "fault", kind, "groups", strings.Join(f.groups, ","))
+		if err := d.injectFaults(); err != nil {
+			d.logger.Error("chaos: cannot heal fault", err, "fault", kind)
+		}
+		return nil
+	})
+	return err
+}
+
+// chaosGroups returns the names of the started groups, in sorted order,
+// including the main group if main is true.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) chaosGroups(main bool) []string {
+	var names []string
+	for name, g := range d.groups {
+		if g.started && (main || !g.components["main"]) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// injectFaults sends the faults in progress to every weavelet.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) injectFaults() error {
+	var errs []error
+	for _, g := range d.groups {
+		req := d.faultsRequest(g)
+		for _, e := range g.envelopes {
+			if g.removed[e] || g.killed[e] {
+				// The weavelet is stopping.
+				continue
+			}
+			if err := e.InjectFaults(req); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("inject faults: %v", errs)
+	}
+	return nil
+}
+
+// faultsRequest returns the faults in progress that the weavelets of the
+// provided group should inject into their calls.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) faultsRequest(g *group) *protos.InjectFaultsRequest {
+	req := &protos.InjectFaultsRequest{}
+	for _, f := range d.faults {
+		switch f.kind {
+		case "partition":
+			var other *group
+			switch g.name {
+			case f.groups[0]:
+				other = d.groups[f.groups[1]]
+			case f.groups[1]:
+				other = d.groups[f.groups[0]]
+			default:
+				continue
+			}
+			req.Unreachable = append(req.Unreachable, maps.Keys(other.components)...)
+		case "latency":
+			if req.LatencyMicros == nil {
+				req.LatencyMicros = map[string]int64{}
+			}
+			for component := range d.groups[f.groups[0]].components {
+				req.LatencyMicros[component] = d.chaosConfig.Latency.Microseconds()
+			}
+		}
+	}
+	return req
+}
+
+// chaosExporter is a trace exporter that stores the spans of the faults
+// injected by chaos testing alongside the spans of the weavelets.
+type chaosExporter struct {
+	d *deployer
+}
+
+var _ sdktrace.SpanExporter = chaosExporter{}
+
+// ExportSpans implements the sdktrace.SpanExporter interface.
+func (c chaosExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return c.d.HandleTraceSpans(ctx, spans)
+}
+
+// Shutdown implements the sdktrace.SpanExporter interface.
+func (chaosExporter) Shutdown(context.Context) error {
+	return nil
+}
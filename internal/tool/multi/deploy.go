@@ -20,31 +20,76 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/events"
 	"github.com/ServiceWeaver/weaver/internal/files"
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/internal/rollout"
 	"github.com/ServiceWeaver/weaver/internal/status"
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"github.com/ServiceWeaver/weaver/runtime/retry"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
 	"github.com/google/uuid"
 )
 
-var deployCmd = tool.Command{
-	Name:        "deploy",
-	Description: "Deploy a Service Weaver app",
-	Help:        "Usage:\n  weaver multi deploy <configfile>",
-	Flags:       flag.NewFlagSet("deploy", flag.ContinueOnError),
-	Fn:          deploy,
-}
+var (
+	deployFlags        = flag.NewFlagSet("deploy", flag.ContinueOnError)
+	deployChaos        = deployFlags.Bool("chaos", false, "Inject faults into the app")
+	deployDryRun       = deployFlags.Bool("dry-run", false, "Print the deployment plan without deploying")
+	deployRollout      = deployFlags.Bool("rollout", false, "Roll the app out over its running version")
+	deployTraffic      = deployFlags.Float64("traffic", -1, "Percentage of traffic sent to the new version")
+	deployMirror       = deployFlags.Float64("mirror", 0, "Percentage of traffic mirrored to the new version")
+	deployIncompatible = deployFlags.Bool("allow-incompatible", false, "Roll out a version incompatible with the running version")
+
+	deployCmd = tool.Command{
+		Name:        "deploy",
+		Description: "Deploy a Service Weaver app",
+		Help: `Usage:
+  weaver multi deploy [--chaos] [--dry-run] [--rollout [--traffic=<percent>] [--mirror=<percent>] [--allow-incompatible]] <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+  --chaos	Inject faults into the app, as specified by the
+		[serviceweaver.chaos] config section (default false)
+  --dry-run	Print the co-location groups, replicas, listeners, and
+		rollout strategy of the deployment, and how it differs
+		from the running deployments of the app, without
+		deploying (default false)
+  --rollout	Deploy the app as a new version of its running
+		deployment. Traffic shifts gradually to the new version
+		over the rollout duration of the config, and the running
+		version is then retired (default false)
+  --traffic	With --rollout, send the provided percentage of traffic
+		to the new version and leave the rollout to be driven by
+		"weaver multi rollout" and "weaver multi rollback"
+  --mirror	With --rollout, mirror the provided percentage of traffic
+		to the new version: the new version serves copies of the
+		requests, whose responses are discarded. The rollout is
+		driven by "weaver multi rollout" and "weaver multi rollback",
+		and no traffic is sent to the new version unless --traffic
+		is provided (default 0)
+  --allow-incompatible
+		With --rollout, warn about, rather than refuse, changes
+		to the methods of the components, or to the types of
+		their arguments and results, that prevent the new and
+		running versions from calling each other (default false)`,
+		Flags: deployFlags,
+		Fn:    deploy,
+	}
+)
 
 // deploy deploys an application on the local machine using a multiprocess
 // deployer. Note that each component is deployed as a separate OS process.
@@ -73,12 +118,72 @@ func deploy(ctx context.Context, args []string) error {
 		return fmt.Errorf("binary %q doesn't exist", config.Binary)
 	}
 
+	manual := *deployTraffic >= 0 || *deployMirror > 0
+	if *deployTraffic >= 0 && !*deployRollout {
+		return fmt.Errorf("--traffic requires --rollout")
+	}
+	if *deployMirror > 0 && !*deployRollout {
+		return fmt.Errorf("--mirror requires --rollout")
+	}
+	if *deployIncompatible && !*deployRollout {
+		return fmt.Errorf("--allow-incompatible requires --rollout")
+	}
+	if *deployTraffic > 100 {
+		return fmt.Errorf("--traffic=%v not in [0, 100]", *deployTraffic)
+	}
+	if *deployMirror < 0 || *deployMirror > 100 {
+		return fmt.Errorf("--mirror=%v not in [0, 100]", *deployMirror)
+	}
+
+	// Find the running version of the app, if rolling out.
+	registry, err := defaultRegistry(ctx)
+	if err != nil {
+		return fmt.Errorf("create registry: %w", err)
+	}
+	var running status.Registration
+	if *deployRollout {
+		running, err = rollout.Running(ctx, registry, config.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Describe the components of the app, and check that they are compatible
+	// with the running version, if rolling out.
+	var schema *status.Schema
+	if *deployRollout || !*deployDryRun {
+		schema, err = plan.Schema(ctx, config)
+		if err != nil && *deployRollout {
+			return err
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: new versions can't be checked for compatibility with this deployment: %v\n", err)
+		}
+	}
+	if *deployRollout {
+		if err := rollout.CheckCompatible(ctx, status.NewClient(running.Addr), schema, *deployIncompatible, os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	// Print the deployment plan instead, if requested.
+	if *deployDryRun {
+		var over *status.Registration
+		if *deployRollout {
+			over = &running
+		}
+		return dryRun(ctx, registry, config, over)
+	}
+
 	// Create the deployer.
 	deploymentId := uuid.New().String()
-	d, err := newDeployer(ctx, deploymentId, config)
+	d, err := newDeployer(ctx, deploymentId, config, *deployChaos)
 	if err != nil {
 		return fmt.Errorf("create deployer: %w", err)
 	}
+	d.schema = schema
+	if *deployRollout {
+		d.rollout = rollout.New(deploymentId, running, status.NewClient(running.Addr), math.Max(0, *deployTraffic), *deployMirror, manual, d.events, d.logger)
+	}
 
 	// Run a status server.
 	lis, err := net.Listen("tcp", "localhost:0")
@@ -93,6 +198,20 @@ func deploy(ctx context.Context, args []string) error {
 		}
 	}()
 
+	// Serve the deployment's metrics to Prometheus, if requested.
+	if d.metricsAddr != "" {
+		lis, err := net.Listen("tcp", d.metricsAddr)
+		if err != nil {
+			return fmt.Errorf("metrics listener: %w", err)
+		}
+		go func() {
+			if err := status.ServePrometheus(ctx, lis, d); err != nil {
+				fmt.Fprintf(os.Stderr, "metrics server: %v\n", err)
+			}
+		}()
+		fmt.Fprintf(os.Stderr, "Serving metrics at http://%s/metrics\n", lis.Addr())
+	}
+
 	// Deploy main.
 	if err := d.startMain(); err != nil {
 		return fmt.Errorf("start main process: %w", err)
@@ -109,10 +228,6 @@ func deploy(ctx context.Context, args []string) error {
 	}
 
 	// Register the deployment.
-	registry, err := defaultRegistry(ctx)
-	if err != nil {
-		return fmt.Errorf("create registry: %w", err)
-	}
 	reg := status.Registration{
 		DeploymentId: deploymentId,
 		App:          config.Name,
@@ -123,6 +238,37 @@ func deploy(ctx context.Context, args []string) error {
 		return fmt.Errorf("register deployment: %w", err)
 	}
 
+	// Delete the logs and traces of the old deployments of the app, if the
+	// config has retention policies.
+	janitor := &retention.Janitor{
+		App:     config.Name,
+		Version: deploymentId,
+		Config:  d.retention,
+		Stores:  dataStores(),
+		Logger:  d.logger,
+		Running: status.RunningDeployments,
+	}
+	go janitor.Run(ctx)
+
+	if d.rollout != nil {
+		d.events.Record(events.DeploymentStarted, "", "", "Deployment started", "rollout_over", running.DeploymentId)
+	} else {
+		d.events.Record(events.DeploymentStarted, "", "", "Deployment started")
+	}
+
+	// Shift traffic to the new version gradually, if rolling out.
+	if d.rollout != nil && !manual {
+		duration := time.Duration(config.RolloutNanos)
+		if duration == 0 {
+			duration = rollout.DefaultDuration
+		}
+		go func() {
+			if err := d.rollout.Run(ctx, duration, d.takeOver); err != nil {
+				d.logger.Error("rollout", err)
+			}
+		}()
+	}
+
 	userDone := make(chan os.Signal, 1)
 	deployerDone := make(chan error, 1)
 	go func() {
@@ -130,17 +276,59 @@ func deploy(ctx context.Context, args []string) error {
 		deployerDone <- err
 	}()
 	signal.Notify(userDone, syscall.SIGINT, syscall.SIGTERM)
+	abort := func() {
+		// Stop sending traffic to this version, if still rolling out.
+		if d.rollout == nil {
+			return
+		}
+		if err := d.rollout.Abort(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "abort rollout: %v\n", err)
+		}
+	}
 	go func() {
 		// Wait for the user to kill the app or the app to return an error.
 		select {
 		case <-userDone:
-			fmt.Fprintf(os.Stderr, "Application %s terminated by the user\n", config.Name)
+			fmt.Fprintf(os.Stderr, "Application %s terminated by the user; draining (interrupt again to stop immediately)\n", config.Name)
+			d.events.Record(events.DeploymentStopped, "", "", "Deployment terminated by the user")
+			abort()
+			drained := make(chan struct{})
+			go func() {
+				d.drain()
+				close(drained)
+			}()
+			select {
+			case <-drained:
+			case <-userDone:
+			}
+		case <-d.retired:
+			fmt.Fprintf(os.Stderr, "Application %s retired; draining\n", config.Name)
+			d.events.Record(events.DeploymentStopped, "", "", "Deployment retired")
+			d.drain()
+			if err := registry.Unregister(ctx, deploymentId); err != nil {
+				fmt.Fprintf(os.Stderr, "unregister deployment: %v\n", err)
+			}
+			if err := d.logIndex.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "close log database: %v\n", err)
+			}
+			if err := d.events.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "close event log: %v\n", err)
+			}
+			os.Exit(0)
 		case err := <-deployerDone:
 			fmt.Fprintf(os.Stderr, "Application %s error: %v\n", config.Name, err)
+			d.events.Record(events.DeploymentStopped, "", "", "Deployment failed", "err", fmt.Sprint(err))
+			abort()
 		}
 		if err := registry.Unregister(ctx, deploymentId); err != nil {
 			fmt.Fprintf(os.Stderr, "unregister deployment: %v\n", err)
 		}
+		if err := d.logIndex.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "close log database: %v\n", err)
+		}
+		if err := d.events.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "close event log: %v\n", err)
+		}
 		os.Exit(1)
 	}()
 
@@ -163,6 +351,38 @@ func deploy(ctx context.Context, args []string) error {
 	}
 }
 
+// dryRun prints the plan of deploying the provided app, and how it differs
+// from the running deployments of the app, without deploying it. running is
+// the deployment rolled out over, or nil if not rolling out.
+func dryRun(ctx context.Context, registry *status.Registry, config *protos.AppConfig, running *status.Registration) error {
+	wletConfig, err := runtime.ParseWeaveletConfig(config.Sections)
+	if err != nil {
+		return err
+	}
+	components, err := plan.Components(ctx, config)
+	if err != nil {
+		return err
+	}
+	note := "Listeners are served at the local addresses in their weaver.ListenerOptions, or at random ports, once the app runs."
+	if running != nil {
+		note = "Listeners are served at the addresses of the running deployment."
+	}
+	p := &plan.Plan{
+		App:          config.Name,
+		Deployer:     "weaver multi",
+		Rollout:      plan.Rollout("weaver multi", config, running, *deployTraffic, *deployMirror),
+		Groups:       plan.Groups(config, components, plan.Replicas(config, defaultReplication, wletConfig.Autoscaling)),
+		Config:       config,
+		ListenerNote: note,
+	}
+	deployments, err := plan.Running(ctx, registry, config.Name)
+	if err != nil {
+		return err
+	}
+	plan.Write(os.Stdout, p, deployments)
+	return nil
+}
+
 // defaultRegistryDir() returns $XDG_DATA_HOME/serviceweaver/multi_registry, or
 // ~/.local/share/serviceweaver/multi_registry if XDG_DATA_HOME is not set.
 func defaultRegistryDir() (string, error) {
@@ -173,6 +393,16 @@ func defaultRegistryDir() (string, error) {
 	return filepath.Join(dir, "multi_registry"), nil
 }
 
+// defaultPubSubDir returns $XDG_DATA_HOME/serviceweaver/multi_pubsub, or
+// ~/.local/share/serviceweaver/multi_pubsub if XDG_DATA_HOME is not set.
+func defaultPubSubDir() (string, error) {
+	dir, err := files.DefaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "multi_pubsub"), nil
+}
+
 // defaultRegistry returns a registry in defaultRegistryDir().
 func defaultRegistry(ctx context.Context) (*status.Registry, error) {
 	dir, err := defaultRegistryDir()
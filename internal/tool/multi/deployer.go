@@ -20,32 +20,54 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/alerts"
+	"github.com/ServiceWeaver/weaver/internal/autoscale"
+	"github.com/ServiceWeaver/weaver/internal/cron"
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/internal/limits"
+	"github.com/ServiceWeaver/weaver/internal/listeners"
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/internal/mtls"
 	"github.com/ServiceWeaver/weaver/internal/proxy"
+	"github.com/ServiceWeaver/weaver/internal/pubsub"
+	"github.com/ServiceWeaver/weaver/internal/rollout"
 	"github.com/ServiceWeaver/weaver/internal/routing"
 	"github.com/ServiceWeaver/weaver/internal/status"
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/envelope"
+	"github.com/ServiceWeaver/weaver/runtime/logdb"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	"github.com/ServiceWeaver/weaver/runtime/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/perfetto"
 	"github.com/ServiceWeaver/weaver/runtime/profiling"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// The default number of times a component is replicated.
-const defaultReplication = 2
+const (
+	// The default number of times a component is replicated.
+	defaultReplication = 2
+
+	// How often the health of a starting weavelet is checked.
+	readyPollInterval = 100 * time.Millisecond
+
+	// How often autoscaled co-location groups are rescaled.
+	autoscaleInterval = 15 * time.Second
+)
 
 // A deployer manages an application deployment.
 type deployer struct {
@@ -53,15 +75,42 @@ type deployer struct {
 	ctxCancel    context.CancelFunc
 	deploymentId string
 	config       *protos.AppConfig
+	resolved     *protos.AppConfig // config with secrets resolved, only passed to weavelets; guarded by mu
 	started      time.Time
 	logger       *slog.Logger
 	running      errgroup.Group
 	logsDB       *logging.FileStore
+	logIndex     *logdb.DB // indexed copy of the logs, see "weaver multi logs query"
 	traceDB      *perfetto.DB
+	events       *events.Log // audit log, see "weaver multi events"
 
 	// statsProcessor tracks and computes stats to be rendered on the /statusz page.
 	statsProcessor *imetrics.StatsProcessor
 
+	// broker stores the messages published on topics and delivers them to
+	// the subscribed weavelets.
+	broker *pubsub.Broker
+
+	// scheduler runs the jobs registered with weaver.Cron on the weavelets
+	// that scheduled them.
+	scheduler *cron.Scheduler
+
+	// leases holds the leases of the leader elections created with
+	// weaver.NewLeaderElection.
+	leases *lease.Table
+
+	// profiles holds the recent profiles collected by the continuous
+	// profilers of the weavelets. See runtime.ProfilingConfig.
+	profiles *status.ProfileStore
+
+	// crashes holds the reports of the recent weavelet crashes caused by
+	// panicking component methods.
+	crashes *status.CrashStore
+
+	// ca mints the certificates that weavelets use to authenticate each
+	// other, if the app is configured with mtls = true.
+	ca *mtls.CA
+
 	// The deployer places components into co-location groups based on the
 	// colocate stanza in a config. For example, consider the following config.
 	//
@@ -79,49 +128,153 @@ type deployer struct {
 	// their group.
 	colocation map[string]string
 
-	mu      sync.Mutex            // guards the following
-	err     error                 // error that stopped the babysitter
-	groups  map[string]*group     // groups, by group name
-	proxies map[string]*proxyInfo // proxies, by listener name
-
+	// Every co-location group other than the main group keeps warmPoolSize
+	// healthy weavelets, in addition to its serving weavelets, that receive
+	// no traffic. When a serving weavelet fails, a warm weavelet takes its
+	// place. startupTimeout bounds the time a weavelet may take to become
+	// healthy. See runtime.WeaveletConfig.
+	warmPoolSize   int
+	startupTimeout time.Duration
+
+	// How often the health of the weavelets is checked once they are
+	// healthy. See runtime.WeaveletConfig.
+	healthInterval time.Duration
+
+	// If not empty, the address at which the deployer serves the metrics of
+	// the deployment to Prometheus. See runtime.WeaveletConfig.
+	metricsAddr string
+
+	// How long the proxies keep idle listener streams open. See
+	// runtime.WeaveletConfig.
+	streamIdleTimeout time.Duration
+
+	// listenerConfigs holds the configs of the listeners, by listener name.
+	// See runtime.ListenerConfig.
+	listenerConfigs map[string]runtime.ListenerConfig
+
+	// routingConfigs holds the routing policies of routed components, by
+	// component name. See runtime.RoutingConfig.
+	routingConfigs map[string]runtime.RoutingConfig
+
+	// resources holds the resource limits of co-location groups, by group
+	// name. See runtime.ResourceLimits.
+	resources map[string]runtime.ResourceLimits
+
+	// processes holds the process settings of co-location groups, by group
+	// name. See runtime.ProcessConfig.
+	processes map[string]runtime.ProcessConfig
+
+	// autoscalers holds the autoscalers of autoscaled co-location groups, by
+	// group name, and tracker computes the load of the groups from the
+	// metrics of their weavelets. tracker is only accessed by the autoscale
+	// goroutine. See runtime.AutoscalingConfig.
+	autoscalers map[string]*autoscale.Autoscaler
+	tracker     *autoscale.Tracker
+
+	// chaosConfig holds the schedule of the faults injected into the
+	// deployment, if chaos testing is enabled. See runtime.ChaosConfig.
+	chaosConfig runtime.ChaosConfig
+
+	// retention holds the policies used to delete the logs and traces of
+	// the old deployments of the app. See runtime.RetentionConfig.
+	retention runtime.RetentionConfig
+
+	// If the deployment is a new version of an app rolled out over its
+	// running version, rollout drives the rollout. Otherwise, rollout is nil.
+	// split splits the listener traffic with a new version rolled out over
+	// this one. retired is closed when the deployment is retired by a
+	// rollout, after which it should shut down.
+	rollout    *rollout.Rollout
+	split      rollout.Split
+	retired    chan struct{}
+	retireOnce sync.Once
+
+	// schema describes the components of the deployment, to check the
+	// compatibility of new versions rolled out over it. It is nil if the
+	// application binary failed to describe them.
+	schema *status.Schema
+
+	// applied is the latest config applied with ApplyConfig, or config if
+	// none was. Only the component sections of the configs differ. applyMu
+	// serializes calls to ApplyConfig.
+	applyMu sync.Mutex
+	applied *protos.AppConfig
+
+	mu        sync.Mutex                            // guards the following
+	err       error                                 // error that stopped the babysitter
+	groups    map[string]*group                     // groups, by group name
+	proxies   map[string]*proxyInfo                 // proxies, by listener name
+	logLevels map[string]*protos.SetLogLevelRequest // log levels set with SetLogLevel, by component
+	flags     map[string]string                     // feature flags set with Flags, by name
+	faults    []*chaosFault                         // faults in progress, injected by chaos testing
 }
 
 // A group contains information about a co-location group.
 type group struct {
-	name        string                          // group name
-	envelopes   []*envelope.Envelope            // envelopes, one per weavelet
-	pids        []int64                         // weavelet pids
-	components  map[string]bool                 // started components
-	addresses   map[string]bool                 // weavelet addresses
-	assignments map[string]*protos.Assignment   // assignment, by component
-	subscribers map[string][]*envelope.Envelope // routing info subscribers, by component
+	name        string                                          // group name
+	started     bool                                            // have the weavelets been started?
+	replicas    int                                             // desired number of serving weavelets
+	envelopes   []*envelope.Envelope                            // envelopes, one per weavelet
+	pids        []int64                                         // weavelet pids
+	components  map[string]bool                                 // started components
+	addresses   map[string]bool                                 // serving weavelet addresses
+	warm        []*envelope.Envelope                            // healthy weavelets not serving
+	assignments map[string]*protos.Assignment                   // assignment, by component
+	subscribers map[string][]*envelope.Envelope                 // routing info subscribers, by component
+	limiter     *limits.Group                                   // enforces resource limits, or nil
+	cancels     map[*envelope.Envelope]context.CancelFunc       // stop the weavelets
+	backends    map[*envelope.Envelope][]backend                // proxy backends, by weavelet
+	removed     map[*envelope.Envelope]bool                     // weavelets removed by the autoscaler
+	killed      map[*envelope.Envelope]bool                     // weavelets killed by chaos testing
+	unhealthy   map[*envelope.Envelope]string                   // weavelets failing health checks, with the reason
+	externals   map[*envelope.Envelope][]*protos.ExternalHealth // health of external services, by weavelet
+}
+
+// A backend is the address of a listener exported by a weavelet, to which
+// the listener's proxy forwards traffic.
+type backend struct {
+	listener string // listener name
+	address  string // listener address
 }
 
 // A proxyInfo contains information about a proxy.
 type proxyInfo struct {
-	listener string       // listener associated with the proxy
-	proxy    *proxy.Proxy // the proxy
-	addr     string       // dialable address of the proxy
+	listener string             // listener associated with the proxy
+	proxy    *proxy.Proxy       // the proxy
+	addr     string             // dialable address of the proxy
+	ctx      context.Context    // canceled when the proxy stops serving
+	stop     context.CancelFunc // stops serving the proxy
 }
 
 // handler handles a connection to a weavelet.
 type handler struct {
 	*deployer
 	g          *group
+	id         string // weavelet id
 	envelope   *envelope.Envelope
 	subscribed map[string]bool // routing info subscriptions, by component
+
+	// Functions that unsubscribe the weavelet from the topic subscriptions
+	// and cron jobs it subscribed to. Only accessed while serving the
+	// envelope.
+	unsubscribes []func()
 }
 
 var _ envelope.EnvelopeHandler = &handler{}
 
 // newDeployer creates a new deployer. The deployer can be stopped at any
-// time by canceling the passed-in context.
-func newDeployer(ctx context.Context, deploymentId string, config *protos.AppConfig) (*deployer, error) {
-	// Create the log saver.
+// time by canceling the passed-in context. If chaos is true, the deployer
+// injects faults into the deployment, as specified by runtime.ChaosConfig.
+func newDeployer(ctx context.Context, deploymentId string, config *protos.AppConfig, chaos bool) (*deployer, error) {
+	// Create the log saver, and the log index.
 	logsDB, err := logging.NewFileStore(logdir)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create log storage: %w", err)
 	}
+	logIndex, err := openLogIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open log database: %w", err)
+	}
 	logger := slog.New(&logging.LogHandler{
 		Opts: logging.Options{
 			App:       config.Name,
@@ -129,7 +282,10 @@ func newDeployer(ctx context.Context, deploymentId string, config *protos.AppCon
 			Weavelet:  uuid.NewString(),
 			Attrs:     []string{"serviceweaver/system", ""},
 		},
-		Write: logsDB.Add,
+		Write: func(entry *protos.LogEntry) {
+			logsDB.Add(entry)
+			logIndex.Add(entry)
+		},
 	})
 
 	// Create the trace saver.
@@ -138,6 +294,55 @@ func newDeployer(ctx context.Context, deploymentId string, config *protos.AppCon
 		return nil, fmt.Errorf("cannot open Perfetto database: %w", err)
 	}
 
+	wletConfig, err := runtime.ParseWeaveletConfig(config.Sections)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := secrets.Resolve(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve secrets: %w", err)
+	}
+
+	// Open the audit log, and alert on its events. The destinations of
+	// alerts often hold secrets, so they are read from the resolved config.
+	var hooks []func(events.Event)
+	if wletConfig.EventsWebhook != "" {
+		hooks = append(hooks, events.Webhook(wletConfig.EventsWebhook, logger))
+	}
+	resolvedConfig, err := runtime.ParseWeaveletConfig(resolved.Sections)
+	if err != nil {
+		return nil, err
+	}
+	if alerter := alerts.New(resolvedConfig.Alerts, logger); alerter != nil {
+		hooks = append(hooks, alerter.Hook)
+	}
+	eventLog, err := events.Open(eventsDir, config.Name, deploymentId, logger, hooks...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open event log: %w", err)
+	}
+
+	// Open the store of messages published on topics. The store is shared by
+	// all deployments of the app, so that the messages that a deployment did
+	// not deliver before it stopped are delivered by the next one.
+	pubsubDir, err := defaultPubSubDir()
+	if err != nil {
+		return nil, err
+	}
+	pubsubStore, err := pubsub.OpenFileStore(filepath.Join(pubsubDir, config.Name))
+	if errors.Is(err, pubsub.ErrLocked) {
+		// Another deployment of the app is running.
+		logger.Info("Topic messages are kept in memory", "reason", err)
+		pubsubStore = pubsub.NewMemoryStore()
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot open topic store: %w", err)
+	}
+
+	ca, err := mtls.NewCA(deploymentId, mtls.DefaultCertLifetime)
+	if err != nil {
+		return nil, err
+	}
+
 	// Form co-location.
 	colocation := map[string]string{}
 	for _, group := range config.Colocate {
@@ -146,21 +351,63 @@ func newDeployer(ctx context.Context, deploymentId string, config *protos.AppCon
 		}
 	}
 
+	// Assign resource limits and autoscaling policies to co-location groups.
+	groupName := func(component string) string {
+		if name, ok := colocation[component]; ok {
+			return name
+		}
+		return component
+	}
+	resources := map[string]runtime.ResourceLimits{}
+	for component, limits := range wletConfig.Resources {
+		resources[groupName(component)] = limits
+	}
+	autoscalers := map[string]*autoscale.Autoscaler{}
+	for component, config := range wletConfig.Autoscaling {
+		autoscalers[groupName(component)] = autoscale.New(config)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	d := &deployer{
-		ctx:            ctx,
-		ctxCancel:      cancel,
-		logger:         logger,
-		logsDB:         logsDB,
-		traceDB:        traceDB,
-		statsProcessor: imetrics.NewStatsProcessor(),
-		deploymentId:   deploymentId,
-		config:         config,
-		started:        time.Now(),
-		colocation:     colocation,
-		groups:         map[string]*group{},
-		proxies:        map[string]*proxyInfo{},
+		ctx:               ctx,
+		ctxCancel:         cancel,
+		logger:            logger,
+		logsDB:            logsDB,
+		logIndex:          logIndex,
+		traceDB:           traceDB,
+		events:            eventLog,
+		statsProcessor:    imetrics.NewStatsProcessor(),
+		ca:                ca,
+		deploymentId:      deploymentId,
+		config:            config,
+		resolved:          resolved,
+		applied:           config,
+		started:           time.Now(),
+		colocation:        colocation,
+		warmPoolSize:      wletConfig.WarmPoolSize,
+		startupTimeout:    wletConfig.StartupTimeout,
+		healthInterval:    wletConfig.HealthCheckInterval,
+		metricsAddr:       wletConfig.DeployerMetricsAddress,
+		streamIdleTimeout: wletConfig.StreamIdleTimeout,
+		listenerConfigs:   wletConfig.Listeners,
+		routingConfigs:    wletConfig.Routing,
+		resources:         resources,
+		processes:         runtime.GroupProcessConfigs(config, wletConfig),
+		autoscalers:       autoscalers,
+		tracker:           autoscale.NewTracker(groupName),
+		chaosConfig:       wletConfig.Chaos,
+		retention:         wletConfig.Retention,
+		retired:           make(chan struct{}),
+		groups:            map[string]*group{},
+		proxies:           map[string]*proxyInfo{},
+		logLevels:         map[string]*protos.SetLogLevelRequest{},
+		flags:             map[string]string{},
 	}
+	d.broker = pubsub.NewBroker(ctx, pubsubStore, logger)
+	d.scheduler = cron.NewScheduler(ctx, logger, nil)
+	d.leases = lease.NewTable()
+	d.profiles = status.NewProfileStore()
+	d.crashes = status.NewCrashStore()
 
 	// Start a goroutine that collects metrics.
 	d.running.Go(func() error {
@@ -177,6 +424,28 @@ func newDeployer(ctx context.Context, deploymentId string, config *protos.AppCon
 		return err
 	})
 
+	// Start a goroutine that checks the health of weavelets.
+	d.running.Go(func() error {
+		d.checkHealth()
+		return nil
+	})
+
+	// Start a goroutine that autoscales co-location groups.
+	if len(autoscalers) > 0 {
+		d.running.Go(func() error {
+			d.autoscale()
+			return nil
+		})
+	}
+
+	// Start a goroutine that injects faults.
+	if chaos {
+		d.running.Go(func() error {
+			d.chaos()
+			return nil
+		})
+	}
+
 	return d, nil
 }
 
@@ -189,6 +458,18 @@ func (d *deployer) wait() error {
 	d.running.Wait() //nolint:errcheck // supplanted by b.err
 	d.mu.Lock()
 	defer d.mu.Unlock()
+
+	// Every weavelet has exited, so we can release their resource limits.
+	for _, g := range d.groups {
+		if g.limiter != nil {
+			if err := g.limiter.Close(); err != nil {
+				d.logger.Error("release resource limits", err, "group", g.name)
+			}
+		}
+	}
+	if err := limits.Shutdown(); err != nil {
+		d.logger.Error("release resource limits", err)
+	}
 	return d.err
 }
 
@@ -206,6 +487,43 @@ func (d *deployer) stop(err error) {
 	d.ctxCancel()
 }
 
+// drain drains every weavelet in the deployment, giving them a chance to
+// finish the requests they are serving before the deployment is stopped. The
+// weavelets are drained concurrently, and none of them is stopped until all
+// of them are drained, so calls between weavelets keep working while they
+// drain.
+func (d *deployer) drain() {
+	drainTime := runtime.DefaultMaxDrainTime + runtime.DefaultShutdownTimeout
+	if config, err := runtime.ParseWeaveletConfig(d.config.Sections); err == nil {
+		drainTime = config.MaxDrainTime + config.ShutdownTimeout
+	}
+
+	d.mu.Lock()
+	var envelopes []*envelope.Envelope
+	for _, g := range d.groups {
+		envelopes = append(envelopes, g.envelopes...)
+	}
+	d.mu.Unlock()
+
+	// Weavelets bound their own draining, including the shutdown of their
+	// components, by drainTime. We wait a bit longer in case a weavelet
+	// is unresponsive.
+	ctx, cancel := context.WithTimeout(d.ctx, drainTime+time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, e := range envelopes {
+		e := e
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := e.Drain(ctx); err != nil {
+				d.logger.Error("drain", err, "weavelet", e.WeaveletInfo().DialAddr)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // group returns the co-location group containing the provided component.
 //
 // REQUIRES: d.mu is held.
@@ -219,10 +537,17 @@ func (d *deployer) group(component string) *group {
 	if !ok {
 		g = &group{
 			name:        name,
+			replicas:    defaultReplication,
 			components:  map[string]bool{},
 			addresses:   map[string]bool{},
 			assignments: map[string]*protos.Assignment{},
 			subscribers: map[string][]*envelope.Envelope{},
+			cancels:     map[*envelope.Envelope]context.CancelFunc{},
+			backends:    map[*envelope.Envelope][]backend{},
+			removed:     map[*envelope.Envelope]bool{},
+			killed:      map[*envelope.Envelope]bool{},
+			unhealthy:   map[*envelope.Envelope]string{},
+			externals:   map[*envelope.Envelope][]*protos.ExternalHealth{},
 		}
 		d.groups[name] = g
 	}
@@ -251,53 +576,458 @@ func (d *deployer) startColocationGroup(g *group) error {
 	if d.err != nil {
 		return d.err
 	}
-	if len(g.envelopes) == defaultReplication {
-		// Already started.
+	if g.started {
 		return nil
 	}
+	g.started = true
 
-	components := maps.Keys(g.components)
-	for r := 0; r < defaultReplication; r++ {
-		// Start the weavelet and capture its logs, traces, and metrics.
-		info := &protos.EnvelopeInfo{
-			App:           d.config.Name,
-			DeploymentId:  d.deploymentId,
-			Id:            uuid.New().String(),
-			Sections:      d.config.Sections,
-			SingleProcess: false,
-			SingleMachine: true,
-			RunMain:       g.components["main"],
-		}
-		e, err := envelope.NewEnvelope(d.ctx, info, d.config)
+	if resources, ok := d.resources[g.name]; ok {
+		limiter, err := limits.NewGroup(fmt.Sprintf("%s-%s", d.deploymentId, g.name), resources)
 		if err != nil {
 			return err
 		}
+		d.logger.Debug("Limiting resources", "group", g.name, "enforcement", limiter.Enforcement())
+		g.limiter = limiter
+	}
 
-		// Make sure the version of the deployer matches the version of the
-		// compiled binary.
-		wlet := e.WeaveletInfo()
-		if err := checkVersion(wlet.Version); err != nil {
+	if a, ok := d.autoscalers[g.name]; ok {
+		g.replicas = a.Config().MinReplicas
+	}
+	n := g.replicas + d.warmPool(g)
+	for r := 0; r < n; r++ {
+		if err := d.startReplica(g); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		d.running.Go(func() error {
-			h := &handler{
-				deployer:   d,
-				g:          g,
-				subscribed: map[string]bool{},
-				envelope:   e,
-			}
-			err := e.Serve(h)
-			d.stop(err)
+// warmPool returns the size of the warm pool of the provided group. The main
+// group has no warm pool.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) warmPool(g *group) int {
+	if g.components["main"] {
+		return 0
+	}
+	return d.warmPoolSize
+}
+
+// startReplica starts a new weavelet in the provided co-location group. The
+// weavelet receives traffic, or joins the group's warm pool, once it is
+// healthy. As an exception, the first weavelet of a group receives traffic
+// right away: calls to the group have nowhere else to go, and components
+// that get each other in their Init methods would otherwise wait for each
+// other to become healthy.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) startReplica(g *group) error {
+	// Start the weavelet and capture its logs, traces, and metrics.
+	info := &protos.EnvelopeInfo{
+		App:           d.config.Name,
+		DeploymentId:  d.deploymentId,
+		Id:            uuid.New().String(),
+		Sections:      d.resolved.Sections,
+		SingleProcess: false,
+		SingleMachine: true,
+		RunMain:       g.components["main"],
+		Group:         g.name,
+	}
+	config := d.resolved
+	if p, ok := d.processes[g.name]; ok {
+		config = runtime.ApplyProcessConfig(config, p)
+	}
+	if g.limiter != nil {
+		// Let the Go runtime of the weavelet know about its limits. Env
+		// variables set in the config take precedence.
+		config = proto.Clone(config).(*protos.AppConfig)
+		config.Env = append(limits.Env(g.limiter.Limits()), config.Env...)
+	}
+	ctx, cancel := context.WithCancel(d.ctx)
+	e, err := envelope.NewEnvelope(ctx, info, config)
+	if err != nil {
+		cancel()
+		return err
+	}
+	g.cancels[e] = cancel
+
+	// Make sure the version of the deployer matches the version of the
+	// compiled binary.
+	wlet := e.WeaveletInfo()
+	if err := checkVersion(wlet.Version); err != nil {
+		return err
+	}
+	if g.limiter != nil {
+		if err := g.limiter.Add(int(wlet.Pid)); err != nil {
 			return err
-		})
+		}
+	}
+
+	d.running.Go(func() error {
+		h := &handler{
+			deployer:   d,
+			g:          g,
+			id:         info.Id,
+			subscribed: map[string]bool{},
+			envelope:   e,
+		}
+		err := e.Serve(h)
+		for _, unsubscribe := range h.unsubscribes {
+			unsubscribe()
+		}
+		d.leases.ReleaseAll(h.id)
+		if d.replaceReplica(g, e, err) {
+			return nil
+		}
+		d.stop(err)
+		return err
+	})
+	g.pids = append(g.pids, wlet.Pid)
+	d.events.Record(events.ReplicaStarted, g.name, wlet.DialAddr, "Weavelet started", "pid", fmt.Sprint(wlet.Pid))
+	for _, req := range d.logLevels {
+		if err := e.SetLogLevel(req); err != nil {
+			return err
+		}
+	}
+	if len(d.flags) > 0 {
+		if err := e.SetFlags(&protos.SetFlagsRequest{Flags: maps.Clone(d.flags)}); err != nil {
+			return err
+		}
+	}
+	if len(d.faults) > 0 {
+		if err := e.InjectFaults(d.faultsRequest(g)); err != nil {
+			return err
+		}
+	}
+	if err := e.UpdateComponents(maps.Keys(g.components)); err != nil {
+		return err
+	}
+	g.envelopes = append(g.envelopes, e)
+	if len(g.addresses) == 0 {
 		if err := d.registerReplica(g, wlet); err != nil {
 			return err
 		}
-		if err := e.UpdateComponents(components); err != nil {
+	}
+
+	d.running.Go(func() error {
+		err := d.awaitReady(g, e)
+		if err != nil {
+			d.events.Record(events.ReplicaFailed, g.name, wlet.DialAddr, "Weavelet failed to start", "err", err.Error())
+			d.stop(err)
+		}
+		return err
+	})
+	return nil
+}
+
+// awaitReady waits for a weavelet to initialize its components and report
+// itself healthy. It then registers the weavelet to receive traffic or, if
+// its group already has enough serving weavelets, adds it to the group's warm
+// pool. awaitReady returns an error if the weavelet fails to become healthy
+// within d.startupTimeout.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) awaitReady(g *group, e *envelope.Envelope) error {
+	addr := e.WeaveletInfo().DialAddr
+	start := time.Now()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for {
+		status := e.GetHealth()
+		if status == protos.HealthStatus_HEALTHY {
+			break
+		}
+		if status == protos.HealthStatus_DRAINING {
+			// The deployment is being shut down.
+			return nil
+		}
+		if status == protos.HealthStatus_UNHEALTHY {
+			return fmt.Errorf("weavelet %s failed to start", addr)
+		}
+		if time.Since(start) > d.startupTimeout {
+			return fmt.Errorf("weavelet %s not healthy after %v", addr, d.startupTimeout)
+		}
+		select {
+		case <-d.ctx.Done():
+			return d.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	d.logger.Debug("Weavelet healthy", "weavelet", addr, "group", g.name, "startup", time.Since(start))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !slices.Contains(g.envelopes, e) || g.addresses[addr] || g.removed[e] {
+		// The weavelet failed in the meantime, is already serving, or was
+		// removed by the autoscaler.
+		return nil
+	}
+	if len(g.addresses) < g.replicas {
+		return d.registerReplica(g, e.WeaveletInfo())
+	}
+	if len(g.warm) < d.warmPool(g) {
+		g.warm = append(g.warm, e)
+		return nil
+	}
+	// The group was scaled down while the weavelet was starting.
+	g.removed[e] = true
+	g.cancels[e]()
+	return nil
+}
+
+// replaceReplica replaces a weavelet that failed with the provided error, if
+// possible, and reports whether it did. A failed serving weavelet is replaced
+// by a weavelet from its group's warm pool, and the warm pool is refilled. A
+// weavelet that fails before becoming healthy, or whose group has an empty
+// warm pool, is not replaced, unless it was killed by chaos testing.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) replaceReplica(g *group, e *envelope.Envelope, err error) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil || d.ctx.Err() != nil {
+		// The deployer is stopping.
+		return false
+	}
+
+	wlet := e.WeaveletInfo()
+	if g.removed[e] {
+		// The weavelet was stopped by the autoscaler.
+		d.forget(g, e)
+		return true
+	}
+	d.events.Record(events.ReplicaFailed, g.name, wlet.DialAddr, "Weavelet failed", "err", fmt.Sprint(err), "pid", fmt.Sprint(wlet.Pid))
+	warm := slices.Index(g.warm, e)
+	serving := g.addresses[wlet.DialAddr]
+	_, unhealthy := g.unhealthy[e]
+	switch {
+	case warm >= 0:
+		g.warm = slices.Delete(g.warm, warm, warm+1)
+	case serving && len(g.warm) > 0:
+		// Replace the failed weavelet before it is removed from the routing
+		// info, so that the group always has a serving weavelet.
+		promoted := g.warm[0]
+		g.warm = g.warm[1:]
+		if err := d.registerReplica(g, promoted.WeaveletInfo()); err != nil {
+			d.logger.Error("cannot promote warm weavelet", err, "group", g.name)
+			return false
+		}
+	case g.killed[e]:
+		// The weavelet was killed by chaos testing. A new weavelet takes its
+		// place once healthy.
+	case unhealthy:
+		// The weavelet was taken out of service for being unhealthy. A new
+		// weavelet takes its place once healthy.
+	default:
+		return false
+	}
+	d.logger.Error("Weavelet failed; replacing it", err, "weavelet", wlet.DialAddr, "group", g.name)
+
+	d.forget(g, e)
+	if serving {
+		delete(g.addresses, wlet.DialAddr)
+		if err := d.updateRouting(g); err != nil {
+			d.logger.Error("cannot update routing info", err, "group", g.name)
+			return false
+		}
+	}
+
+	// Refill the warm pool.
+	if err := d.startReplica(g); err != nil {
+		d.logger.Error("cannot start warm weavelet", err, "group", g.name)
+		return false
+	}
+	return true
+}
+
+// forget forgets a weavelet that has exited. It does not remove the weavelet
+// from its group's serving weavelets.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) forget(g *group, e *envelope.Envelope) {
+	if i := slices.Index(g.envelopes, e); i >= 0 {
+		g.envelopes = slices.Delete(g.envelopes, i, i+1)
+	}
+	if i := slices.Index(g.pids, e.WeaveletInfo().Pid); i >= 0 {
+		g.pids = slices.Delete(g.pids, i, i+1)
+	}
+	for _, group := range d.groups {
+		for component, subs := range group.subscribers {
+			if i := slices.Index(subs, e); i >= 0 {
+				group.subscribers[component] = slices.Delete(subs, i, i+1)
+			}
+		}
+	}
+	d.removeBackends(g, e)
+	g.cancels[e]()
+	delete(g.cancels, e)
+	delete(g.removed, e)
+	delete(g.killed, e)
+	delete(g.unhealthy, e)
+	delete(g.externals, e)
+}
+
+// removeBackends stops forwarding the traffic of listeners to a weavelet.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) removeBackends(g *group, e *envelope.Envelope) {
+	for _, b := range g.backends[e] {
+		if p, ok := d.proxies[b.listener]; ok {
+			p.proxy.RemoveBackend(b.address)
+		}
+	}
+	delete(g.backends, e)
+}
+
+// autoscale periodically rescales the co-location groups that have an
+// autoscaling policy, until the deployer is stopped.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) autoscale() {
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			d.rescale(d.tracker.Update(d.weaveletMetrics()))
+		}
+	}
+}
+
+// weaveletMetrics returns the metrics of the serving weavelets, keyed by
+// weavelet address.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) weaveletMetrics() map[string]autoscale.Weavelet {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	weavelets := map[string]autoscale.Weavelet{}
+	for _, g := range d.groups {
+		for _, e := range g.envelopes {
+			addr := e.WeaveletInfo().DialAddr
+			if !g.addresses[addr] {
+				continue
+			}
+			ms, err := e.GetMetrics()
+			if err != nil {
+				continue
+			}
+			weavelets[addr] = autoscale.Weavelet{Group: g.name, Time: time.Now(), Metrics: ms}
+		}
+	}
+	return weavelets
+}
+
+// rescale rescales the autoscaled co-location groups based on their load.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) rescale(signals map[string]autoscale.Signals) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return
+	}
+
+	now := time.Now()
+	for name, a := range d.autoscalers {
+		g, ok := d.groups[name]
+		if !ok || !g.started {
+			continue
+		}
+		s, ok := signals[name]
+		if !ok {
+			continue
+		}
+		replicas := a.Recommend(now, g.replicas, s)
+		if replicas == g.replicas {
+			continue
+		}
+		d.logger.Info("Autoscaling", "group", name, "from", g.replicas, "to", replicas,
+			"rate", s.Rate, "p99", s.P99, "cpu", s.CPU)
+		d.events.Record(events.Autoscaled, name, "", "Autoscaling",
+			"from", fmt.Sprint(g.replicas), "to", fmt.Sprint(replicas))
+		var err error
+		if replicas > g.replicas {
+			err = d.scaleUp(g, replicas)
+		} else {
+			err = d.scaleDown(g, replicas)
+		}
+		if err != nil {
+			d.logger.Error("cannot autoscale", err, "group", name)
+		}
+	}
+}
+
+// scaleUp grows the provided group to the provided number of serving
+// weavelets. Weavelets from the group's warm pool start serving right away,
+// and are replaced. Other new weavelets start serving once they are healthy.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) scaleUp(g *group, replicas int) error {
+	n := replicas - g.replicas
+	g.replicas = replicas
+	for i := 0; i < n; i++ {
+		if len(g.warm) > 0 && len(g.addresses) < g.replicas {
+			promoted := g.warm[0]
+			g.warm = g.warm[1:]
+			if err := d.registerReplica(g, promoted.WeaveletInfo()); err != nil {
+				return err
+			}
+		}
+		if err := d.startReplica(g); err != nil {
 			return err
 		}
-		g.envelopes = append(g.envelopes, e)
+	}
+	return nil
+}
+
+// scaleDown shrinks the provided group to the provided number of serving
+// weavelets. The removed weavelets stop receiving traffic right away, and are
+// stopped once they are drained. Weavelets that are still starting are
+// stopped once they are healthy; see awaitReady.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) scaleDown(g *group, replicas int) error {
+	g.replicas = replicas
+
+	// Remove the most recently started serving weavelets.
+	var removed []*envelope.Envelope
+	for i := len(g.envelopes) - 1; i >= 0 && len(g.addresses) > g.replicas; i-- {
+		e := g.envelopes[i]
+		addr := e.WeaveletInfo().DialAddr
+		if !g.addresses[addr] {
+			continue
+		}
+		delete(g.addresses, addr)
+		d.removeBackends(g, e)
+		g.removed[e] = true
+		removed = append(removed, e)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	if err := d.updateRouting(g); err != nil {
+		return err
+	}
+
+	drainTime := runtime.DefaultMaxDrainTime + runtime.DefaultShutdownTimeout
+	if config, err := runtime.ParseWeaveletConfig(d.config.Sections); err == nil {
+		drainTime = config.MaxDrainTime + config.ShutdownTimeout
+	}
+	for _, e := range removed {
+		e, cancel := e, g.cancels[e]
+		d.running.Go(func() error {
+			ctx, cancelDrain := context.WithTimeout(d.ctx, drainTime+time.Second)
+			defer cancelDrain()
+			if err := e.Drain(ctx); err != nil {
+				d.logger.Error("drain", err, "weavelet", e.WeaveletInfo().DialAddr)
+			}
+			cancel()
+			return nil
+		})
 	}
 	return nil
 }
@@ -333,6 +1063,94 @@ func (h *handler) ActivateComponent(_ context.Context, req *protos.ActivateCompo
 	return &protos.ActivateComponentReply{}, h.activateComponent(req)
 }
 
+// HandleTopicEvent implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleTopicEvent(_ context.Context, event *protos.TopicEvent) error {
+	// Forward the event to every other weavelet in the deployment. We send
+	// the events without holding the lock, as sending may block.
+	h.mu.Lock()
+	var targets []*envelope.Envelope
+	for _, g := range h.groups {
+		for _, e := range g.envelopes {
+			if e != h.envelope {
+				targets = append(targets, e)
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	for _, e := range targets {
+		if err := e.SendTopicEvent(event); err != nil {
+			h.logger.Error("cannot forward topic event", err, "topic", event.Topic)
+		}
+	}
+	return nil
+}
+
+// PublishMessage implements the envelope.EnvelopeHandler interface.
+func (h *handler) PublishMessage(_ context.Context, req *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	if err := h.broker.Publish(req.Message.Topic, req.Message.Payload); err != nil {
+		return nil, err
+	}
+	return &protos.PublishMessageReply{}, nil
+}
+
+// Subscribe implements the envelope.EnvelopeHandler interface.
+func (h *handler) Subscribe(_ context.Context, req *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	sub := pubsub.Subscription{Topic: req.Topic, Name: req.Subscription}
+	deliver := func(_ context.Context, req *protos.DeliverMessageRequest) error {
+		return h.envelope.DeliverMessage(req)
+	}
+	unsubscribe, err := h.broker.Subscribe(sub, deliver)
+	if err != nil {
+		return nil, err
+	}
+	h.unsubscribes = append(h.unsubscribes, unsubscribe)
+	return &protos.SubscribeReply{}, nil
+}
+
+// ScheduleJob implements the envelope.EnvelopeHandler interface.
+func (h *handler) ScheduleJob(_ context.Context, req *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	run := func(_ context.Context, req *protos.RunJobRequest) error {
+		return h.envelope.RunJob(req)
+	}
+	unschedule, err := h.scheduler.Schedule(req, run)
+	if err != nil {
+		return nil, err
+	}
+	h.unsubscribes = append(h.unsubscribes, unschedule)
+	return &protos.ScheduleJobReply{}, nil
+}
+
+// Lease implements the envelope.EnvelopeHandler interface.
+func (h *handler) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return h.leases.Handle(time.Now(), req), nil
+}
+
+// HandleProfile implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleProfile(_ context.Context, profile *protos.Profile) error {
+	h.profiles.Add(h.g.name, h.id, profile)
+	return nil
+}
+
+// HandleCrashReport implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleCrashReport(_ context.Context, report *protos.CrashReport) error {
+	wlet := h.envelope.WeaveletInfo()
+	h.crashes.Add(h.g.name, h.id, wlet.Pid, report)
+	msg := "Component method panicked"
+	if report.Stack == "" {
+		// A fatal error, like a failed weaver.MustGet, rather than a panic.
+		msg = "Component failed"
+	}
+	h.events.Record(events.ReplicaPanicked, h.g.name, wlet.DialAddr, msg,
+		"component", report.Component, "method", report.Method, "panic", report.Panic, "trace_id", report.TraceId, "pid", fmt.Sprint(wlet.Pid))
+	return nil
+}
+
+// GetCertificate implements the envelope.EnvelopeHandler interface.
+func (h *handler) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	return h.ca.Mint(h.id)
+}
+
 func (h *handler) subscribeTo(req *protos.ActivateComponentRequest) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -374,7 +1192,7 @@ func (d *deployer) activateComponent(req *protos.ActivateComponentRequest) error
 		// Create an initial assignment.
 		if req.Routed {
 			replicas := maps.Keys(target.addresses)
-			assignment := routingAlgo(&protos.Assignment{}, replicas)
+			assignment := routingAlgo(d.routingConfigs[req.Component], &protos.Assignment{}, replicas)
 			target.assignments[req.Component] = assignment
 			d.logger.Debug(fmt.Sprintf("Initial assignment for component %s:\n%s", req.Component, routing.FormatAssignment(assignment)))
 		}
@@ -393,20 +1211,28 @@ func (d *deployer) activateComponent(req *protos.ActivateComponentRequest) error
 }
 
 // registerReplica registers the information about a colocation group replica
-// (i.e., a weavelet).
+// (i.e., a weavelet), so that it receives traffic.
+//
+// REQUIRES: d.mu is held.
 func (d *deployer) registerReplica(g *group, info *protos.WeaveletInfo) error {
-	// Update addresses and pids.
+	// Update addresses.
 	if g.addresses[info.DialAddr] {
 		// Replica already registered.
 		return nil
 	}
 	g.addresses[info.DialAddr] = true
-	g.pids = append(g.pids, info.Pid)
+	return d.updateRouting(g)
+}
 
+// updateRouting updates the assignments of the provided group to match its
+// serving weavelets and notifies the subscribers.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) updateRouting(g *group) error {
 	// Update all assignments.
 	replicas := maps.Keys(g.addresses)
 	for component, assignment := range g.assignments {
-		assignment = routingAlgo(assignment, replicas)
+		assignment = routingAlgo(d.routingConfigs[component], assignment, replicas)
 		g.assignments[component] = assignment
 		d.logger.Debug(fmt.Sprintf("Updated assignment for component %s:\n%s", component, routing.FormatAssignment(assignment)))
 	}
@@ -427,6 +1253,7 @@ func (d *deployer) registerReplica(g *group, info *protos.WeaveletInfo) error {
 // HandleLogEntry implements the envelope.EnvelopeHandler interface.
 func (d *deployer) HandleLogEntry(_ context.Context, entry *protos.LogEntry) error {
 	d.logsDB.Add(entry)
+	d.logIndex.Add(entry)
 	return nil
 }
 
@@ -441,9 +1268,13 @@ func (d *deployer) GetListenerAddress(context.Context, *protos.GetListenerAddres
 }
 
 // ExportListener implements the envelope.EnvelopeHandler interface.
-func (d *deployer) ExportListener(_ context.Context, req *protos.ExportListenerRequest) (*protos.ExportListenerReply, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+func (h *handler) ExportListener(_ context.Context, req *protos.ExportListenerRequest) (*protos.ExportListenerReply, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Record the backend, so that it can be removed when the weavelet stops.
+	h.g.backends[h.envelope] = append(h.g.backends[h.envelope], backend{listener: req.Listener, address: req.Address})
+	d := h.deployer
 
 	// Update the proxy.
 	if p, ok := d.proxies[req.Listener]; ok {
@@ -451,8 +1282,14 @@ func (d *deployer) ExportListener(_ context.Context, req *protos.ExportListenerR
 		return &protos.ExportListenerReply{ProxyAddress: p.addr}, nil
 	}
 
-	lis, err := net.Listen("tcp", req.LocalAddress)
-	if errors.Is(err, syscall.EADDRINUSE) {
+	var lis net.Listener
+	var err error
+	if d.rollout != nil {
+		lis, err = d.rollout.Listen(req.Listener, req.LocalAddress)
+	} else {
+		lis, err = listeners.Listen(req.LocalAddress)
+	}
+	if listeners.IsAddrInUse(err) {
 		// Don't retry if this address is already in use.
 		return &protos.ExportListenerReply{Error: err.Error()}, nil
 	}
@@ -461,19 +1298,101 @@ func (d *deployer) ExportListener(_ context.Context, req *protos.ExportListenerR
 	}
 	addr := lis.Addr().String()
 	d.logger.Info("Proxy listening", "address", addr)
-	proxy := proxy.NewProxy(d.logger)
+	newProxy := proxy.NewProxy
+	if req.Tls {
+		newProxy = proxy.NewTLSProxy
+	}
+	affinity := proxy.ListenerAffinity(d.listenerConfigs[req.Listener])
+	proxy := newProxy(d.logger)
+	proxy.SetStreamIdleTimeout(d.streamIdleTimeout)
+	proxy.SetSessionAffinity(affinity)
 	proxy.AddBackend(req.Address)
-	d.proxies[req.Listener] = &proxyInfo{
+	d.split.Apply(req.Listener, proxy)
+	ctx, stop := context.WithCancel(d.ctx)
+	info := &proxyInfo{
 		listener: req.Listener,
 		proxy:    proxy,
 		addr:     addr,
+		ctx:      ctx,
+		stop:     stop,
+	}
+	d.proxies[req.Listener] = info
+	d.serveProxy(info, lis)
+	if d.rollout != nil {
+		if err := d.rollout.AddProxy(d.ctx, req.Listener, addr); err != nil {
+			d.logger.Error("cannot send traffic to the new version", err, "listener", req.Listener)
+		}
 	}
+	return &protos.ExportListenerReply{ProxyAddress: addr}, nil
+}
+
+// serveProxy serves the provided proxy on the provided listener, until the
+// proxy is stopped. The streams forwarded by the proxy are then closed.
+func (d *deployer) serveProxy(p *proxyInfo, lis net.Listener) {
 	go func() {
-		if err := serveHTTP(d.ctx, lis, proxy); err != nil {
+		if err := serveHTTP(p.ctx, lis, p.proxy); err != nil && p.ctx.Err() == nil {
 			d.logger.Error("proxy", err)
 		}
+		if p.ctx.Err() != nil {
+			p.proxy.CloseStreams()
+		}
 	}()
-	return &protos.ExportListenerReply{ProxyAddress: addr}, nil
+}
+
+// takeOver serves the proxy of the provided listener on the provided
+// listener, taken over from the running version once a rollout is promoted.
+func (d *deployer) takeOver(listener string, lis net.Listener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p, ok := d.proxies[listener]
+	if !ok {
+		lis.Close()
+		return
+	}
+	p.addr = lis.Addr().String()
+	d.serveProxy(p, lis)
+}
+
+// SplitTraffic implements the status.Server interface.
+func (d *deployer) SplitTraffic(_ context.Context, req *status.SplitTrafficRequest) (*status.SplitTrafficReply, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.split.Set(req)
+	for name, p := range d.proxies {
+		d.split.Apply(name, p.proxy)
+	}
+	if !req.Retire {
+		return &status.SplitTrafficReply{}, nil
+	}
+
+	// Stop serving the listeners, so that the new version can take their
+	// addresses over, and shut down.
+	d.logger.Info("Retired by the rollout of a new version", "version", req.Version)
+	d.events.Record(events.RolloutPromoted, "", "", "Retired by the rollout of a new version", "new", req.Version)
+	for _, p := range d.proxies {
+		p.stop()
+	}
+	d.retire()
+	return &status.SplitTrafficReply{}, nil
+}
+
+// Rollout implements the status.Server interface.
+func (d *deployer) Rollout(ctx context.Context, req *status.RolloutRequest) (*status.RolloutReply, error) {
+	if d.rollout == nil {
+		return nil, fmt.Errorf("deployment %s is not being rolled out", d.deploymentId)
+	}
+	if err := d.rollout.Handle(ctx, req, d.takeOver); err != nil {
+		return nil, err
+	}
+	if req.Rollback {
+		d.retire()
+	}
+	return &status.RolloutReply{}, nil
+}
+
+// retire retires the deployment, which then shuts down.
+func (d *deployer) retire() {
+	d.retireOnce.Do(func() { close(d.retired) })
 }
 
 func (d *deployer) readMetrics() []*metrics.MetricSnapshot {
@@ -500,9 +1419,15 @@ func (d *deployer) Profile(_ context.Context, req *protos.GetProfileRequest) (*p
 	d.mu.Lock()
 	envelopes := map[string][]*envelope.Envelope{}
 	for _, group := range d.groups {
+		if req.Component != "" && !group.components[req.Component] {
+			continue
+		}
 		envelopes[group.name] = slices.Clone(group.envelopes)
 	}
 	d.mu.Unlock()
+	if req.Component != "" && len(envelopes) == 0 {
+		return nil, fmt.Errorf("component %q is not running", req.Component)
+	}
 
 	profile, err := runProfiling(d.ctx, req, envelopes)
 	if err != nil {
@@ -511,6 +1436,132 @@ func (d *deployer) Profile(_ context.Context, req *protos.GetProfileRequest) (*p
 	return profile, nil
 }
 
+// Profiles implements the status.Server interface.
+func (d *deployer) Profiles(_ context.Context, req *status.ProfilesRequest) (*status.ProfilesReply, error) {
+	return d.profiles.Profiles(req)
+}
+
+// SetLogLevel implements the status.Server interface.
+func (d *deployer) SetLogLevel(_ context.Context, req *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	if _, err := logging.ParseLevel(req.Level); err != nil {
+		return nil, err
+	}
+
+	// Record the level, so that it also applies to weavelets started later,
+	// and make a copy of the envelopes, so we can update them without
+	// holding the lock.
+	d.mu.Lock()
+	d.logLevels[req.Component] = req
+	d.events.Record(events.ConfigChanged, "", "", "Log level changed", "component", req.Component, "level", req.Level)
+	var envelopes []*envelope.Envelope
+	for _, group := range d.groups {
+		if group.components[req.Component] {
+			envelopes = append(envelopes, group.envelopes...)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, e := range envelopes {
+		if err := e.SetLogLevel(req); err != nil {
+			return nil, err
+		}
+	}
+	return &protos.SetLogLevelReply{}, nil
+}
+
+// Flags implements the status.Server interface.
+func (d *deployer) Flags(_ context.Context, req *status.FlagsRequest) (*status.FlagsReply, error) {
+	// Record the flags, so that they also apply to weavelets started later,
+	// and make a copy of the envelopes, so we can update them without
+	// holding the lock.
+	d.mu.Lock()
+	if err := status.UpdateFlags(d.flags, req); err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	flags := maps.Clone(d.flags)
+	var envelopes []*envelope.Envelope
+	if len(req.Set) > 0 || len(req.Unset) > 0 {
+		d.events.Record(events.ConfigChanged, "", "", "Flags changed", "set", fmt.Sprint(req.Set), "unset", strings.Join(req.Unset, ","))
+		for _, group := range d.groups {
+			envelopes = append(envelopes, group.envelopes...)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, e := range envelopes {
+		if err := e.SetFlags(&protos.SetFlagsRequest{Flags: flags}); err != nil {
+			return nil, err
+		}
+	}
+	return &status.FlagsReply{Flags: flags}, nil
+}
+
+// ApplyConfig implements the status.Server interface.
+func (d *deployer) ApplyConfig(ctx context.Context, req *status.ApplyConfigRequest) (*status.ApplyConfigReply, error) {
+	if req.Config == nil {
+		return nil, fmt.Errorf("no config provided")
+	}
+	d.applyMu.Lock()
+	defer d.applyMu.Unlock()
+	changed, err := status.ChangedComponents(d.applied, req.Config)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return &status.ApplyConfigReply{}, nil
+	}
+	resolved, err := secrets.Resolve(ctx, req.Config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve secrets: %w", err)
+	}
+	setConfig := &protos.SetConfigRequest{Sections: status.ComponentSections(resolved)}
+
+	// Every weavelet checks the new config against the config structs of
+	// the components, so that if one weavelet rejects it, they all do.
+	// Reconfigure one weavelet first, and only record the config for
+	// weavelets started later once it succeeds.
+	d.mu.Lock()
+	var first *envelope.Envelope
+	for _, group := range d.groups {
+		if len(group.envelopes) > 0 {
+			first = group.envelopes[0]
+			break
+		}
+	}
+	d.mu.Unlock()
+	if first != nil {
+		if err := first.SetConfig(setConfig); err != nil {
+			return nil, fmt.Errorf("config rejected: %w", err)
+		}
+	}
+
+	// Record the config, so that it also applies to weavelets started
+	// later, and make a copy of the envelopes, so we can update them without
+	// holding the lock.
+	d.mu.Lock()
+	d.applied = req.Config
+	d.resolved = resolved
+	var envelopes []*envelope.Envelope
+	for _, group := range d.groups {
+		for _, e := range group.envelopes {
+			if e != first {
+				envelopes = append(envelopes, e)
+			}
+		}
+	}
+	d.events.Record(events.ConfigChanged, "", "", "Config applied", "components", strings.Join(changed, ","))
+	d.mu.Unlock()
+	d.logger.Info("Config applied", "components", changed)
+
+	for _, e := range envelopes {
+		if err := e.SetConfig(setConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &status.ApplyConfigReply{Changed: changed}, nil
+}
+
 // Status implements the status.Server interface.
 func (d *deployer) Status(context.Context) (*status.Status, error) {
 	d.mu.Lock()
@@ -558,6 +1609,27 @@ func (d *deployer) Status(context.Context) (*status.Status, error) {
 		}
 	}
 
+	var groupLimits []*status.Limits
+	for _, group := range d.groups {
+		if group.limiter == nil {
+			continue
+		}
+		resources := group.limiter.Limits()
+		stats, err := group.limiter.Stats()
+		if err != nil {
+			d.logger.Error("read resource limit stats", err, "group", group.name)
+		}
+		groupLimits = append(groupLimits, &status.Limits{
+			Group:         group.name,
+			Cpu:           resources.CPU,
+			MemoryBytes:   resources.MemoryBytes,
+			Enforcement:   group.limiter.Enforcement(),
+			CpuThrottled:  stats.CPUThrottled,
+			MemoryMaxHits: stats.MemoryMaxHits,
+			OomKills:      stats.OOMKills,
+		})
+	}
+
 	var listeners []*status.Listener
 	for _, proxy := range d.proxies {
 		listeners = append(listeners, &status.Listener{
@@ -573,9 +1645,26 @@ func (d *deployer) Status(context.Context) (*status.Status, error) {
 		Components:     components,
 		Listeners:      listeners,
 		Config:         d.config,
+		Limits:         groupLimits,
+		Rollout:        d.rolloutStatus(),
+		Unhealthy:      d.unhealthyReplicas(),
+		Schema:         d.schema,
+		Externals:      d.externalServices(),
+		Crashes:        d.crashes.Crashes(),
 	}, nil
 }
 
+// rolloutStatus returns the status of the rollout of the deployment, or of a
+// new version over the deployment, if any.
+func (d *deployer) rolloutStatus() *status.Rollout {
+	if d.rollout != nil {
+		if s := d.rollout.Status(); s != nil {
+			return s
+		}
+	}
+	return d.split.Status(d.deploymentId)
+}
+
 // Metrics implements the status.Server interface.
 func (d *deployer) Metrics(context.Context) (*status.Metrics, error) {
 	m := &status.Metrics{}
@@ -585,8 +1674,14 @@ func (d *deployer) Metrics(context.Context) (*status.Metrics, error) {
 	return m, nil
 }
 
-func routingAlgo(currAssignment *protos.Assignment, candidates []string) *protos.Assignment {
-	assignment := routing.EqualSlices(candidates)
+func routingAlgo(config runtime.RoutingConfig, currAssignment *protos.Assignment, candidates []string) *protos.Assignment {
+	var assignment *protos.Assignment
+	switch config.Strategy {
+	case "consistent_hash":
+		assignment = routing.ConsistentHash(candidates, config.Replication, config.LoadFactor)
+	default:
+		assignment = routing.EqualSlices(candidates)
+	}
 	assignment.Version = currAssignment.Version + 1
 	return assignment
 }
@@ -605,13 +1700,26 @@ func serveHTTP(ctx context.Context, lis net.Listener, handler http.Handler) erro
 	}
 }
 
-// runProfiling runs a profiling request on a set of processes.
+// runProfiling runs a profiling request on a set of processes. By default, a
+// single replica of every co-location group is profiled, and its profile
+// scaled by the number of replicas. When profiling a single component, every
+// replica is profiled instead, and the profiles are merged.
 func runProfiling(_ context.Context, req *protos.GetProfileRequest, processes map[string][]*envelope.Envelope) (*protos.GetProfileReply, error) {
 	// Collect together the groups we want to profile.
 	groups := make([][]func() ([]byte, error), 0, len(processes))
 	for _, envelopes := range processes {
+		if req.Component != "" {
+			for _, e := range envelopes {
+				e := e
+				groups = append(groups, []func() ([]byte, error){func() ([]byte, error) {
+					return e.GetProfile(req)
+				}})
+			}
+			continue
+		}
 		group := make([]func() ([]byte, error), 0, len(envelopes))
 		for _, e := range envelopes {
+			e := e
 			group = append(group, func() ([]byte, error) {
 				return e.GetProfile(req)
 			})
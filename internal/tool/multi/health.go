@@ -0,0 +1,199 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multi
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/events"
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/envelope"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+)
+
+// checkHealth periodically checks the health of the weavelets that have
+// become healthy since they started, until the deployer is stopped. Serving
+// weavelets that become unhealthy stop receiving calls and listener traffic
+// until they are healthy again; see updateHealth.
+//
+// REQUIRES: d.mu is NOT held.
+func (d *deployer) checkHealth() {
+	ticker := time.NewTicker(d.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		type checked struct {
+			g *group
+			e *envelope.Envelope
+		}
+		var weavelets []checked
+		d.mu.Lock()
+		for _, g := range d.groups {
+			for _, e := range g.envelopes {
+				_, unhealthy := g.unhealthy[e]
+				if g.addresses[e.WeaveletInfo().DialAddr] || slices.Contains(g.warm, e) || unhealthy {
+					weavelets = append(weavelets, checked{g, e})
+				}
+			}
+		}
+		d.mu.Unlock()
+
+		for _, w := range weavelets {
+			reply := w.e.CheckHealth()
+			d.mu.Lock()
+			if err := d.updateHealth(w.g, w.e, reply); err != nil {
+				d.logger.Error("cannot update routing info", err, "group", w.g.name)
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// updateHealth updates the serving weavelets of the provided group according
+// to the health of one of its weavelets, and records the health of the
+// external services the weavelet uses. A serving weavelet that becomes
+// unhealthy is replaced by a weavelet from the warm pool, if any, and stops
+// receiving calls and listener traffic, unless it is the last serving
+// weavelet of its group. An unhealthy warm weavelet leaves the warm pool. A
+// weavelet that becomes healthy again serves, or rejoins the warm pool if the
+// group has enough serving weavelets.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) updateHealth(g *group, e *envelope.Envelope, reply *protos.GetHealthReply) error {
+	if !slices.Contains(g.envelopes, e) || g.removed[e] || g.killed[e] {
+		// The weavelet is stopping, or has stopped.
+		return nil
+	}
+	addr := e.WeaveletInfo().DialAddr
+	_, unhealthy := g.unhealthy[e]
+	g.externals[e] = reply.Externals
+
+	switch reply.Status {
+	case protos.HealthStatus_UNHEALTHY:
+		g.unhealthy[e] = reply.Reason
+		if unhealthy {
+			return nil
+		}
+		d.events.Record(events.ReplicaUnhealthy, g.name, addr, "Weavelet unhealthy", "reason", reply.Reason)
+		if i := slices.Index(g.warm, e); i >= 0 {
+			d.logger.Warn("Weavelet unhealthy; removing it from the warm pool", "weavelet", addr, "group", g.name, "reason", reply.Reason)
+			g.warm = slices.Delete(g.warm, i, i+1)
+			return nil
+		}
+		if !g.addresses[addr] {
+			return nil
+		}
+		if len(g.warm) > 0 {
+			promoted := g.warm[0]
+			g.warm = g.warm[1:]
+			if err := d.registerReplica(g, promoted.WeaveletInfo()); err != nil {
+				return err
+			}
+		} else if len(g.addresses) == 1 {
+			d.logger.Warn("Weavelet unhealthy; no other weavelet can take its place", "weavelet", addr, "group", g.name, "reason", reply.Reason)
+			return nil
+		}
+		d.logger.Warn("Weavelet unhealthy; no longer sending it traffic", "weavelet", addr, "group", g.name, "reason", reply.Reason)
+		for _, b := range g.backends[e] {
+			if p, ok := d.proxies[b.listener]; ok {
+				p.proxy.RemoveBackend(b.address)
+			}
+		}
+		delete(g.addresses, addr)
+		return d.updateRouting(g)
+
+	case protos.HealthStatus_HEALTHY:
+		if !unhealthy {
+			return nil
+		}
+		delete(g.unhealthy, e)
+		d.logger.Info("Weavelet healthy again", "weavelet", addr, "group", g.name)
+		d.events.Record(events.ReplicaHealthy, g.name, addr, "Weavelet healthy again")
+		if g.addresses[addr] {
+			// The weavelet kept serving.
+			return nil
+		}
+		for _, b := range g.backends[e] {
+			if p, ok := d.proxies[b.listener]; ok {
+				p.proxy.AddBackend(b.address)
+			}
+		}
+		if len(g.addresses) < g.replicas {
+			return d.registerReplica(g, e.WeaveletInfo())
+		}
+		g.warm = append(g.warm, e)
+		return nil
+	}
+	return nil
+}
+
+// externalServices returns the health of the external services used by the
+// weavelets of every group, sorted by group and service name.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) externalServices() []*status.ExternalService {
+	var services []*status.ExternalService
+	for _, g := range d.groups {
+		byName := map[string]*status.ExternalService{}
+		for _, health := range g.externals {
+			for _, h := range health {
+				s, ok := byName[h.Name]
+				if !ok {
+					s = &status.ExternalService{Name: h.Name, Kind: h.Kind, Group: g.name}
+					byName[h.Name] = s
+					services = append(services, s)
+				}
+				s.Replicas++
+				if h.Error != "" {
+					s.Unhealthy++
+					s.Error = h.Error
+				}
+			}
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Group != services[j].Group {
+			return services[i].Group < services[j].Group
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+// unhealthyReplicas returns the weavelets that fail their health checks.
+//
+// REQUIRES: d.mu is held.
+func (d *deployer) unhealthyReplicas() []*status.UnhealthyReplica {
+	var replicas []*status.UnhealthyReplica
+	for _, g := range d.groups {
+		for e, reason := range g.unhealthy {
+			wlet := e.WeaveletInfo()
+			replicas = append(replicas, &status.UnhealthyReplica{
+				Group:  g.name,
+				Pid:    wlet.Pid,
+				Addr:   wlet.DialAddr,
+				Reason: reason,
+			})
+		}
+	}
+	return replicas
+}
@@ -17,11 +17,17 @@ package multi
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 
+	"github.com/ServiceWeaver/weaver/internal/events"
 	"github.com/ServiceWeaver/weaver/internal/must"
 	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/internal/tool/config"
+	"github.com/ServiceWeaver/weaver/runtime/logdb"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
 )
 
@@ -29,6 +35,18 @@ var (
 	// logdir is where weaver multi deployed applications store their logs.
 	logdir = filepath.Join(logging.DefaultLogDir, "weaver-multi")
 
+	// logIndexDir is where weaver multi deployed applications store the
+	// database that indexes their logs.
+	logIndexDir = filepath.Join(logging.DefaultLogDir, "weaver-multi-db")
+
+	// eventsDir is where weaver multi deployed applications record their
+	// events.
+	eventsDir = filepath.Join(logging.DefaultLogDir, "weaver-multi-events")
+
+	// serviceLogDir is where the deployers of weaver multi deployed
+	// applications run as services write their output.
+	serviceLogDir = filepath.Join(logging.DefaultLogDir, "weaver-multi-services")
+
 	dashboardSpec = &status.DashboardSpec{
 		Tool:     "weaver multi",
 		Registry: defaultRegistry,
@@ -37,33 +55,70 @@ var (
 				{Label: "status", Command: "weaver multi status"},
 				{Label: "cat logs", Command: fmt.Sprintf("weaver multi logs 'version==%q'", logging.Shorten(deploymentId))},
 				{Label: "follow logs", Command: fmt.Sprintf("weaver multi logs --follow 'version==%q'", logging.Shorten(deploymentId))},
+				{Label: "query logs", Command: fmt.Sprintf("weaver multi logs query --version=%s --level=warn", logging.Shorten(deploymentId))},
 				{Label: "profile", Command: fmt.Sprintf("weaver multi profile --duration=30s %s", deploymentId)},
+				{Label: "export dashboard", Command: fmt.Sprintf("weaver multi dashboard export %s", deploymentId)},
 			}
 		},
+		LogDB: openLogIndex,
 	}
 
 	purgeSpec = &tool.PurgeSpec{
 		Tool: "weaver multi",
-		Kill: "weaver multi (dashboard|deploy|logs|profile)",
+		Kill: "weaver multi (attach|dashboard|deploy|events|logs|profile)",
 		Paths: []string{
 			logdir,
+			logIndexDir,
+			eventsDir,
+			serviceLogDir,
 			must.Must(defaultRegistryDir()),
+			must.Must(defaultPubSubDir()),
 		},
+		Data:    dataStores(),
+		Running: status.RunningDeployments,
 	}
 
 	Commands = map[string]*tool.Command{
-		"deploy": &deployCmd,
+		"deploy":  &deployCmd,
+		"service": &serviceCmd,
 		"logs": tool.LogsCmd(&tool.LogsSpec{
 			Tool: "weaver multi",
 			Source: func(context.Context) (logging.Source, error) {
 				return logging.FileSource(logdir), nil
 			},
+			DB: openLogIndex,
 		}),
+		"events":    events.Command("weaver multi", eventsDir),
 		"dashboard": status.DashboardCommand(dashboardSpec),
 		"status":    status.StatusCommand("weaver multi", defaultRegistry),
 		"metrics":   status.MetricsCommand("weaver multi", defaultRegistry),
 		"profile":   status.ProfileCommand("weaver multi", defaultRegistry),
+		"loglevel":  status.LogLevelCommand("weaver multi", defaultRegistry),
+		"flags":     status.FlagsCommand("weaver multi", defaultRegistry),
+		"config":    status.ConfigCommand("weaver multi", defaultRegistry, config.Check),
+		"rollout":   status.RolloutCommand("weaver multi", defaultRegistry),
+		"rollback":  status.RollbackCommand("weaver multi", defaultRegistry),
+		"attach":    status.AttachCommand("weaver multi", defaultRegistry),
 		"purge":     tool.PurgeCmd(purgeSpec),
 		"version":   tool.VersionCmd("weaver multi"),
 	}
 )
+
+// openLogIndex opens the database that indexes the logs of weaver multi
+// deployed applications.
+func openLogIndex(ctx context.Context) (*logdb.DB, error) {
+	if err := os.MkdirAll(logIndexDir, 0750); err != nil {
+		return nil, err
+	}
+	return logdb.Open(ctx, filepath.Join(logIndexDir, "logs.db"))
+}
+
+// dataStores returns the stores of the logs and traces of weaver multi
+// deployed applications.
+func dataStores() []retention.Store {
+	return []retention.Store{
+		retention.LogFiles(logdir),
+		retention.LogDB(filepath.Join(logIndexDir, "logs.db")),
+		retention.TraceDB(perfetto.Open),
+	}
+}
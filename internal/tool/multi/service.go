@@ -0,0 +1,339 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multi
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var (
+	serviceFlags     = flag.NewFlagSet("service", flag.ContinueOnError)
+	serviceInit      = serviceFlags.String("init", defaultInit(), `Init system that runs the deployer: "launchd" or "systemd"`)
+	serviceInstall   = serviceFlags.Bool("install", false, "Install and start the service")
+	serviceUninstall = serviceFlags.Bool("uninstall", false, "Stop and remove the service")
+
+	serviceCmd = tool.Command{
+		Name:        "service",
+		Description: "Run a Service Weaver app as a launchd or systemd service",
+		Help: `Usage:
+  weaver multi service [--init=<launchd|systemd>] [--install|--uninstall] <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+  --init	The init system that runs and restarts the deployer of
+		the app: "launchd" or "systemd" (default "launchd" on
+		macOS and "systemd" on Linux)
+  --install	Install the service for the current user and start it
+		(default false)
+  --uninstall	Stop the service and remove it (default false)
+
+Description:
+  "weaver multi service" prints a launchd agent or a systemd user unit that
+  runs "weaver multi deploy <configfile>", restarting it if it fails, and
+  starting it when the user logs in. With --install, the service is written
+  to ~/Library/LaunchAgents or ~/.config/systemd/user and started; with
+  --uninstall, it is stopped and removed.
+
+  When the service is stopped, the deployer drains the app, as when
+  "weaver multi deploy" is interrupted.
+
+Examples:
+  # Print the service of the app.
+  weaver multi service weaver.toml
+
+  # Run the app as a service.
+  weaver multi service --install weaver.toml`,
+		Flags: serviceFlags,
+		Fn:    service,
+	}
+)
+
+// defaultInit returns the init system of the current platform, or "" if it
+// has none supported by "weaver multi service".
+func defaultInit() string {
+	switch goruntime.GOOS {
+	case "darwin":
+		return "launchd"
+	case "linux":
+		return "systemd"
+	default:
+		return ""
+	}
+}
+
+// serviceSpec describes the service of an application.
+type serviceSpec struct {
+	App         string        // application name
+	Name        string        // service name
+	Weaver      string        // absolute path of the weaver binary
+	Config      string        // absolute path of the config file
+	Dir         string        // working directory of the deployer
+	Log         string        // file where the deployer's output is written
+	StopTimeout time.Duration // how long the deployer has to drain the app
+}
+
+// service prints, installs, or uninstalls the service of an application.
+func service(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+	if *serviceInstall && *serviceUninstall {
+		return fmt.Errorf("--install and --uninstall are mutually exclusive")
+	}
+
+	// Load the config file.
+	configFile, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	contents, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", configFile, err)
+	}
+	config, err := runtime.ParseConfig(configFile, string(contents), codegen.ComponentConfigValidator)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", configFile, err)
+	}
+	stopTimeout := runtime.DefaultMaxDrainTime + runtime.DefaultShutdownTimeout
+	if wc, err := runtime.ParseWeaveletConfig(config.Sections); err == nil {
+		stopTimeout = wc.MaxDrainTime + wc.ShutdownTimeout
+	}
+
+	weaver, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find weaver binary: %w", err)
+	}
+	spec := serviceSpec{
+		App:    config.Name,
+		Weaver: weaver,
+		Config: configFile,
+		Dir:    filepath.Dir(configFile),
+		// Leave the deployer time to stop the weavelets once drained.
+		StopTimeout: stopTimeout + 10*time.Second,
+	}
+
+	var unit, path string
+	var start, stop [][]string
+	switch *serviceInit {
+	case "launchd":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		spec.Name = "dev.serviceweaver." + serviceName(config.Name)
+		spec.Log = filepath.Join(serviceLogDir, spec.Name+".log")
+		if unit, err = launchdUnit(spec); err != nil {
+			return err
+		}
+		path = filepath.Join(home, "Library", "LaunchAgents", spec.Name+".plist")
+		start = [][]string{{"launchctl", "load", "-w", path}}
+		stop = [][]string{{"launchctl", "unload", "-w", path}}
+
+	case "systemd":
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			dir = filepath.Join(home, ".config")
+		}
+		spec.Name = "serviceweaver-" + serviceName(config.Name)
+		if unit, err = systemdUnit(spec); err != nil {
+			return err
+		}
+		path = filepath.Join(dir, "systemd", "user", spec.Name+".service")
+		start = [][]string{
+			{"systemctl", "--user", "daemon-reload"},
+			{"systemctl", "--user", "enable", "--now", spec.Name},
+		}
+		stop = [][]string{{"systemctl", "--user", "disable", "--now", spec.Name}}
+
+	case "":
+		return fmt.Errorf("no supported init system on %s; run \"weaver multi deploy\" with the service manager of your choice", goruntime.GOOS)
+
+	default:
+		return fmt.Errorf("unknown init system %q; want \"launchd\" or \"systemd\"", *serviceInit)
+	}
+
+	switch {
+	case *serviceInstall:
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(serviceLogDir, 0750); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+			return err
+		}
+		if err := runAll(ctx, start); err != nil {
+			return err
+		}
+		fmt.Printf("Service %s installed at %s.\n", spec.Name, path)
+		fmt.Printf("Stop and remove it with \"weaver multi service --init=%s --uninstall %s\".\n", *serviceInit, args[0])
+
+	case *serviceUninstall:
+		if err := runAll(ctx, stop); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Printf("Service %s removed.\n", spec.Name)
+
+	default:
+		fmt.Print(unit)
+	}
+	return nil
+}
+
+// runAll runs the provided commands in order, stopping at the first failure.
+func runAll(ctx context.Context, cmds [][]string) error {
+	for _, args := range cmds {
+		fmt.Println(strings.Join(args, " "))
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// serviceName returns the provided application name, with the characters not
+// allowed in service names replaced by dashes.
+func serviceName(app string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, app)
+}
+
+var (
+	launchdTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{
+		"xml":     xmlEscape,
+		"seconds": func(d time.Duration) int { return int(d.Seconds()) },
+	}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>{{xml .Name}}</string>
+  <key>ProgramArguments</key>
+  <array>
+    <string>{{xml .Weaver}}</string>
+    <string>multi</string>
+    <string>deploy</string>
+    <string>{{xml .Config}}</string>
+  </array>
+  <key>WorkingDirectory</key>
+  <string>{{xml .Dir}}</string>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <dict>
+    <key>SuccessfulExit</key>
+    <false/>
+  </dict>
+  <key>ExitTimeOut</key>
+  <integer>{{seconds .StopTimeout}}</integer>
+  <key>StandardOutPath</key>
+  <string>{{xml .Log}}</string>
+  <key>StandardErrorPath</key>
+  <string>{{xml .Log}}</string>
+</dict>
+</plist>
+`))
+
+	systemdTemplate = template.Must(template.New("systemd").Funcs(template.FuncMap{
+		"quote":   systemdQuote,
+		"escape":  func(s string) string { return strings.ReplaceAll(s, "%", "%%") },
+		"seconds": func(d time.Duration) int { return int(d.Seconds()) },
+	}).Parse(`[Unit]
+Description=Service Weaver application {{escape .App}}
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart={{quote .Weaver}} multi deploy {{quote .Config}}
+WorkingDirectory={{escape .Dir}}
+Restart=on-failure
+RestartSec=5
+# Only signal the deployer, which drains and stops the weavelets.
+KillMode=mixed
+TimeoutStopSec={{seconds .StopTimeout}}
+
+[Install]
+WantedBy=default.target
+`))
+)
+
+// launchdUnit returns the launchd agent of the provided service.
+func launchdUnit(spec serviceSpec) (string, error) {
+	var b strings.Builder
+	if err := launchdTemplate.Execute(&b, spec); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// systemdUnit returns the systemd unit of the provided service.
+func systemdUnit(spec serviceSpec) (string, error) {
+	var b strings.Builder
+	if err := systemdTemplate.Execute(&b, spec); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// xmlEscape escapes the provided string for use in XML text.
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s)) //nolint:errcheck // bytes.Buffer never fails
+	return b.String()
+}
+
+// systemdQuote quotes the provided argument of a systemd command line, with
+// the specifiers and environment variables it contains, if any, escaped.
+func systemdQuote(s string) string {
+	s = strconv.Quote(s)
+	s = strings.ReplaceAll(s, "%", "%%")
+	return strings.ReplaceAll(s, "$", "$$")
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multi
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceName(t *testing.T) {
+	for _, test := range []struct{ app, want string }{
+		{"collatz", "collatz"},
+		{"my_app-v1.2", "my_app-v1.2"},
+		{"my app/v1", "my-app-v1"},
+	} {
+		if got := serviceName(test.app); got != test.want {
+			t.Errorf("serviceName(%q): got %q, want %q", test.app, got, test.want)
+		}
+	}
+}
+
+func TestServiceUnits(t *testing.T) {
+	spec := serviceSpec{
+		App:         "todo",
+		Name:        "todo",
+		Weaver:      "/home/me/go/bin/weaver",
+		Config:      "/home/me/my apps/100%/weaver.toml",
+		Dir:         "/home/me/my apps/100%",
+		Log:         "/tmp/a&b.log",
+		StopTimeout: 40 * time.Second,
+	}
+
+	systemd, err := systemdUnit(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`ExecStart="/home/me/go/bin/weaver" multi deploy "/home/me/my apps/100%%/weaver.toml"`,
+		"WorkingDirectory=/home/me/my apps/100%%\n",
+		"Restart=on-failure\n",
+		"KillMode=mixed\n",
+		"TimeoutStopSec=40\n",
+	} {
+		if !strings.Contains(systemd, want) {
+			t.Errorf("systemd unit does not contain %q:\n%s", want, systemd)
+		}
+	}
+
+	launchd, err := launchdUnit(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<string>/home/me/my apps/100%/weaver.toml</string>",
+		"<key>SuccessfulExit</key>\n    <false/>",
+		"<integer>40</integer>",
+		"<string>/tmp/a&amp;b.log</string>",
+	} {
+		if !strings.Contains(launchd, want) {
+			t.Errorf("launchd agent does not contain %q:\n%s", want, launchd)
+		}
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	for _, test := range []struct{ arg, want string }{
+		{"weaver", `"weaver"`},
+		{"a b", `"a b"`},
+		{`a"b`, `"a\"b"`},
+		{"$HOME/%h", `"$$HOME/%%h"`},
+	} {
+		if got := systemdQuote(test.arg); got != test.want {
+			t.Errorf("systemdQuote(%q): got %s, want %s", test.arg, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var babysitterCmd = tool.Command{
+	Name:        "babysitter",
+	Description: "The weaver nomad babysitter",
+	Help: `Usage:
+  weaver nomad babysitter
+
+Flags:
+  -h, --help   Print this help message.`,
+	Fn: runBabysitter,
+}
+
+// runBabysitter runs a babysitter and weavelet in an allocation of a
+// colocation group's job.
+func runBabysitter(ctx context.Context, _ []string) error {
+	info, err := impl.BabysitterInfoFromEnv()
+	if err != nil {
+		return err
+	}
+	info.ReplicaId = replicaId(os.Getenv(allocIdKey))
+	return impl.RunBabysitter(ctx, info)
+}
+
+// replicaId returns the replica id of the babysitter running in the
+// allocation with the provided id. Allocations that replace failed ones have
+// new ids, so they are added to the routing info as new replicas.
+func replicaId(alloc string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(alloc)) //nolint:errcheck // hash.Hash.Write never fails
+	return int32(h.Sum32() & 0x7fffffff)
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// client is a minimal client of the HTTP APIs of Nomad and Consul, which
+// both take a JSON request body and authenticate requests with an ACL token
+// in a header.
+type client struct {
+	api         string // name of the API, used in errors, e.g., "nomad"
+	client      *http.Client
+	addr        string // address of the API, e.g., "http://127.0.0.1:4646"
+	tokenHeader string // header that holds the ACL token
+	token       string // ACL token, or empty
+	query       url.Values
+}
+
+// newNomadClient returns a client of the Nomad HTTP API, as configured by
+// cfg.
+func newNomadClient(cfg *config) *client {
+	query := url.Values{}
+	if cfg.Region != "" {
+		query.Set("region", cfg.Region)
+	}
+	if cfg.Namespace != "" {
+		query.Set("namespace", cfg.Namespace)
+	}
+	return &client{
+		api:         "nomad",
+		client:      &http.Client{},
+		addr:        withScheme(cfg.Address),
+		tokenHeader: "X-Nomad-Token",
+		token:       cfg.Token,
+		query:       query,
+	}
+}
+
+// newConsulClient returns a client of the HTTP API of a Consul agent, as
+// configured by cfg.
+func newConsulClient(cfg *consulConfig) *client {
+	return &client{
+		api:         "consul",
+		client:      &http.Client{},
+		addr:        withScheme(cfg.Address),
+		tokenHeader: "X-Consul-Token",
+		token:       cfg.Token,
+	}
+}
+
+// withScheme returns addr prefixed with "http://", if it has no scheme.
+// $NOMAD_ADDR and $CONSUL_HTTP_ADDR may omit it.
+func withScheme(addr string) string {
+	if strings.Contains(addr, "://") {
+		return strings.TrimSuffix(addr, "/")
+	}
+	return "http://" + strings.TrimSuffix(addr, "/")
+}
+
+// do issues a request to the API, with in as the JSON request body, if not
+// nil, and decodes the JSON response body into out, if not nil.
+func (c *client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	u := c.addr + path
+	if len(c.query) > 0 {
+		u += "?" + c.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set(c.tokenHeader, c.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s API: %s %s: %s: %s", c.api, method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// registerJob registers the provided job with Nomad, which schedules its
+// allocations.
+func (c *client) registerJob(ctx context.Context, j *job) error {
+	return c.do(ctx, http.MethodPut, "/v1/jobs", struct{ Job *job }{j}, nil)
+}
+
+// stopJob stops and purges the job with the provided id.
+func (c *client) stopJob(ctx context.Context, id string) error {
+	path := "/v1/job/" + url.PathEscape(id)
+	cc := *c
+	cc.query = url.Values{"purge": {"true"}}
+	for k, v := range c.query {
+		cc.query[k] = v
+	}
+	return cc.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// registerService registers the provided service with the Consul agent.
+func (c *client) registerService(ctx context.Context, s *service) error {
+	return c.do(ctx, http.MethodPut, "/v1/agent/service/register", s, nil)
+}
+
+// deregisterService deregisters the service with the provided id from the
+// Consul agent.
+func (c *client) deregisterService(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(id), nil, nil)
+}
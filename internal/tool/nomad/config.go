@@ -0,0 +1,215 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// nomadKey and shortNomadKey are the keys of the nomad section of the
+	// config.
+	nomadKey      = "github.com/ServiceWeaver/weaver/nomad"
+	shortNomadKey = "nomad"
+
+	// Default values of the nomad config.
+	defaultAddress       = "http://127.0.0.1:4646"
+	defaultDatacenter    = "dc1"
+	defaultDriver        = "docker"
+	defaultTool          = "weaver"
+	defaultReplicas      = 2
+	defaultConsulAddress = "http://127.0.0.1:8500"
+)
+
+// config is the nomad section of an application config, e.g.:
+//
+//	[nomad]
+//	image = "docker.io/my-user/my-app:v1"
+//	datacenters = ["us-east-1"]
+//	replicas = 3
+//	manager_address = "10.0.0.2:9000"
+//
+//	[nomad.consul]
+//	services = {hello = "hello-web"}
+type config struct {
+	// Address is the address of the Nomad HTTP API. Defaults to $NOMAD_ADDR,
+	// or "http://127.0.0.1:4646" if not set.
+	Address string `toml:"address"`
+
+	// Token is the ACL token used to submit jobs. Defaults to $NOMAD_TOKEN.
+	Token string `toml:"token"`
+
+	// Region and Namespace are the Nomad region and namespace in which jobs
+	// are submitted. If empty, Nomad's defaults are used.
+	Region    string `toml:"region"`
+	Namespace string `toml:"namespace"`
+
+	// Datacenters are the datacenters in which jobs may run. Defaults to
+	// ["dc1"].
+	Datacenters []string `toml:"datacenters"`
+
+	// Driver is the task driver that runs the babysitters: "docker", "exec",
+	// or "raw_exec". Defaults to "docker".
+	//
+	// With the docker driver, Image must contain both the application binary,
+	// at the path given by the binary field of the [serviceweaver] section,
+	// and the weaver tool. With the exec and raw_exec drivers, both binaries
+	// must already be present at these paths on the Nomad clients.
+	Driver string `toml:"driver"`
+
+	// Image is the container image that runs the application, if the driver
+	// is docker.
+	Image string `toml:"image"`
+
+	// Tool is the path of the weaver tool on the Nomad clients. Defaults to
+	// "weaver", i.e., the weaver tool is looked up in $PATH.
+	Tool string `toml:"tool"`
+
+	// Replicas is the number of replicas of every colocation group. Defaults
+	// to 2.
+	Replicas int `toml:"replicas"`
+
+	// CPU, in MHz, and Memory, in MB, are the resources reserved for every
+	// replica. If zero, Nomad's defaults are used.
+	CPU    int `toml:"cpu"`
+	Memory int `toml:"memory"`
+
+	// ManagerAddress is the address the manager listens on, which must be
+	// reachable from the Nomad clients. If empty, the manager listens on a
+	// random port on the local hostname.
+	ManagerAddress string `toml:"manager_address"`
+
+	// Consul, if not nil, advertises the application's listeners as Consul
+	// services.
+	Consul *consulConfig `toml:"consul"`
+}
+
+// consulConfig is the [nomad.consul] section of an application config.
+type consulConfig struct {
+	// Address is the address of the HTTP API of the Consul agent with which
+	// services are registered. Defaults to $CONSUL_HTTP_ADDR, or
+	// "http://127.0.0.1:8500" if not set.
+	Address string `toml:"address"`
+
+	// Token is the ACL token used to register services. Defaults to
+	// $CONSUL_HTTP_TOKEN.
+	Token string `toml:"token"`
+
+	// AdvertiseAddress is the host at which the listeners are advertised,
+	// unless they listen on a specific host. Defaults to the local hostname.
+	AdvertiseAddress string `toml:"advertise_address"`
+
+	// Services maps the name of a listener to the name of the Consul service
+	// it is advertised as. Listeners missing from the map are advertised
+	// under their own name.
+	Services map[string]string `toml:"services"`
+}
+
+// Validate implements the runtime.ParseConfigSection validation hook.
+func (c *config) Validate() error {
+	switch c.Driver {
+	case "", "docker":
+		if c.Image == "" {
+			return fmt.Errorf("no image provided for the docker driver")
+		}
+	case "exec", "raw_exec":
+		if c.Image != "" {
+			return fmt.Errorf("image provided for the %s driver", c.Driver)
+		}
+	default:
+		return fmt.Errorf("unknown driver %q", c.Driver)
+	}
+	if c.Replicas < 0 {
+		return fmt.Errorf("negative replicas %d", c.Replicas)
+	}
+	if c.CPU < 0 {
+		return fmt.Errorf("negative cpu %d", c.CPU)
+	}
+	if c.Memory < 0 {
+		return fmt.Errorf("negative memory %d", c.Memory)
+	}
+	if c.Consul != nil {
+		for listener, service := range c.Consul.Services {
+			if service == "" {
+				return fmt.Errorf("consul: listener %q: empty service name", listener)
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfig returns the nomad config of the provided application, with
+// defaults filled in.
+func loadConfig(app *protos.AppConfig) (*config, error) {
+	c := &config{}
+	if err := runtime.ParseConfigSection(nomadKey, shortNomadKey, app.Sections, c); err != nil {
+		return nil, fmt.Errorf("unable to parse nomad config: %w", err)
+	}
+	if c.Driver == "" && c.Image == "" {
+		return nil, fmt.Errorf("unable to parse nomad config: no image provided for the docker driver")
+	}
+	if c.Address == "" {
+		c.Address = envOr("NOMAD_ADDR", defaultAddress)
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("NOMAD_TOKEN")
+	}
+	if len(c.Datacenters) == 0 {
+		c.Datacenters = []string{defaultDatacenter}
+	}
+	if c.Driver == "" {
+		c.Driver = defaultDriver
+	}
+	if c.Tool == "" {
+		c.Tool = defaultTool
+	}
+	if c.Replicas == 0 {
+		c.Replicas = defaultReplicas
+	}
+	if c.Consul != nil {
+		if c.Consul.Address == "" {
+			c.Consul.Address = envOr("CONSUL_HTTP_ADDR", defaultConsulAddress)
+		}
+		if c.Consul.Token == "" {
+			c.Consul.Token = os.Getenv("CONSUL_HTTP_TOKEN")
+		}
+	}
+	return c, nil
+}
+
+// envOr returns the value of the named environment variable, or def if the
+// variable is not set.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// CheckConfig checks the nomad section of the provided application config,
+// if it has one. It is used by "weaver config check".
+func CheckConfig(app *protos.AppConfig) error {
+	_, long := app.Sections[nomadKey]
+	_, short := app.Sections[shortNomadKey]
+	if !long && !short {
+		return nil
+	}
+	_, err := loadConfig(app)
+	return err
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// service is the subset of a Consul service definition [1] used by the nomad
+// deployer.
+//
+// [1]: https://developer.hashicorp.com/consul/api-docs/agent/service#register-service
+type service struct {
+	ID      string
+	Name    string
+	Tags    []string
+	Address string
+	Port    int
+	Meta    map[string]string
+	Check   *check
+}
+
+type check struct {
+	TCP                            string
+	Interval                       string
+	DeregisterCriticalServiceAfter string
+}
+
+// advertiser advertises the listeners of a deployment as Consul services.
+// The services are health checked over TCP, and deregistered once the
+// deployment stops, or by Consul if they remain unhealthy.
+type advertiser struct {
+	consul *client
+	cfg    *consulConfig
+	dep    *protos.Deployment
+	host   string // host at which listeners are advertised
+
+	wg  sync.WaitGroup  // pending registrations
+	mu  sync.Mutex      // guards ids
+	ids map[string]bool // ids of the registered services
+}
+
+func newAdvertiser(cfg *consulConfig, dep *protos.Deployment) (*advertiser, error) {
+	host := cfg.AdvertiseAddress
+	if host == "" {
+		var err error
+		host, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("get hostname: %w", err)
+		}
+	}
+	return &advertiser{
+		consul: newConsulClient(cfg),
+		cfg:    cfg,
+		dep:    dep,
+		host:   host,
+		ids:    map[string]bool{},
+	}, nil
+}
+
+// listenerService returns the Consul service that advertises the listener
+// with the provided name, whose proxy listens on the provided address.
+func (a *advertiser) listenerService(listener, addr string) (*service, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = a.host
+	}
+	serviceName := listener
+	if s, ok := a.cfg.Services[listener]; ok {
+		serviceName = s
+	}
+	m := meta(a.dep)
+	m[listenerMeta] = listener
+	return &service{
+		ID:      name(a.dep.App.Name, logging.Shorten(a.dep.Id), listener),
+		Name:    serviceName,
+		Tags:    []string{"serviceweaver", logging.Shorten(a.dep.Id)},
+		Address: host,
+		Port:    port,
+		Meta:    m,
+		Check: &check{
+			TCP:                            net.JoinHostPort(host, portStr),
+			Interval:                       "10s",
+			DeregisterCriticalServiceAfter: "1m",
+		},
+	}, nil
+}
+
+// advertise registers, in the background, the Consul service that
+// advertises the listener with the provided name, whose proxy listens on the
+// provided address.
+func (a *advertiser) advertise(ctx context.Context, listener, addr string) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		s, err := a.listenerService(listener, addr)
+		if err == nil {
+			err = a.consul.registerService(ctx, s)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "advertise listener %q in Consul: %v\n", listener, err)
+			return
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.ids[s.ID] = true
+	}()
+}
+
+// stop waits for pending registrations, and deregisters all the registered
+// services.
+func (a *advertiser) stop(ctx context.Context) error {
+	a.wg.Wait()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids := maps.Keys(a.ids)
+	slices.Sort(ids)
+	for _, id := range ids {
+		if err := a.consul.deregisterService(ctx, id); err != nil {
+			return err
+		}
+		delete(a.ids, id)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/google/uuid"
+
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/colors"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var deployCmd = tool.Command{
+	Name:        "deploy",
+	Description: "Deploy a Service Weaver app to Nomad",
+	Help: `Usage:
+  weaver nomad deploy [--dry-run] <configfile>
+
+Flags:
+  -h, --help   Print this help message.
+  --dry-run    Print the colocation groups, replicas, and listeners of
+               the deployment, and how it differs from the running
+               deployments of the app, without deploying (default false)
+
+Description:
+  "weaver nomad deploy" runs a manager on the local machine, which submits a
+  Nomad job for every colocation group of the application and proxies its
+  listeners. The deployment runs until the command is interrupted, at which
+  point its jobs are stopped.
+
+  The application is configured in the config file's [nomad] section:
+
+      [nomad]
+      address = "http://127.0.0.1:4646"      # defaults to $NOMAD_ADDR
+      datacenters = ["dc1"]
+      driver = "docker"                      # or "exec" or "raw_exec"
+      image = "docker.io/my-user/my-app:v1"  # required by docker
+      tool = "/weaver"                       # the weaver binary
+      replicas = 2                           # replicas per colocation group
+      manager_address = "10.0.0.2:9000"      # reachable from Nomad clients
+
+      [nomad.consul]                         # advertise listeners in Consul
+      address = "http://127.0.0.1:8500"      # defaults to $CONSUL_HTTP_ADDR
+      services = {hello = "hello-web"}       # Consul service of a listener
+
+  The image must contain both the application binary, at the path given by
+  the binary field of the [serviceweaver] section, and the weaver binary.
+  Inspect the deployment with "weaver nomad status", "weaver nomad logs",
+  and "weaver nomad metrics".`,
+	Flags: deployFlags,
+	Fn:    deploy,
+}
+
+var (
+	deployFlags  = flag.NewFlagSet("deploy", flag.ContinueOnError)
+	deployDryRun = deployFlags.Bool("dry-run", false, "Print the deployment plan without deploying")
+)
+
+// deploy deploys an application to a Nomad cluster.
+func deploy(ctx context.Context, args []string) error {
+	// Validate command line arguments.
+	if len(args) == 0 {
+		return fmt.Errorf("no config file provided")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments")
+	}
+
+	// Load the config file.
+	cfgFile := args[0]
+	contents, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	app, err := runtime.ParseConfig(cfgFile, string(contents), codegen.ComponentConfigValidator)
+	if err != nil {
+		return fmt.Errorf("load config file %q: %w", cfgFile, err)
+	}
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return err
+	}
+
+	// Print the deployment plan instead, if requested.
+	if *deployDryRun {
+		return dryRun(ctx, app, cfg)
+	}
+
+	// Create a deployment.
+	dep := &protos.Deployment{
+		Id:  uuid.New().String(),
+		App: app,
+	}
+	nomad := newNomadClient(cfg)
+	var ads *advertiser
+	if cfg.Consul != nil {
+		ads, err = newAdvertiser(cfg.Consul, dep)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Run the manager. It submits the job of a colocation group when the
+	// group's components are first activated.
+	var mu sync.Mutex
+	var jobs []string // ids of the submitted jobs
+	opts := impl.ManagerOptions{
+		StartGroup: func(info *impl.BabysitterInfo) error {
+			j, err := groupJob(cfg, info)
+			if err != nil {
+				return err
+			}
+			if err := nomad.registerJob(ctx, j); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			jobs = append(jobs, j.ID)
+			return nil
+		},
+		ListenAddr: cfg.ManagerAddress,
+		Registry:   defaultRegistry,
+	}
+	if ads != nil {
+		opts.ListenerProxied = func(listener, addr string) {
+			ads.advertise(ctx, listener, addr)
+		}
+	}
+	stopFn, err := impl.RunManager(ctx, dep, logDir, opts)
+	if err != nil {
+		return fmt.Errorf("cannot instantiate the manager: %w", err)
+	}
+
+	// Wait for the user to kill the app.
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-done // Will block here until user hits ctrl+c
+		if err := stopFn(); err != nil {
+			fmt.Fprintf(os.Stderr, "stop the manager: %v\n", err)
+		}
+		if ads != nil {
+			if err := ads.stop(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "deregister Consul services: %v\n", err)
+			}
+		}
+		mu.Lock()
+		for _, id := range jobs {
+			if err := nomad.stopJob(ctx, id); err != nil {
+				fmt.Fprintf(os.Stderr, "stop Nomad job %s: %v\n", id, err)
+			}
+		}
+		mu.Unlock()
+		fmt.Fprintf(os.Stderr, "Application %s terminated\n", app.Name)
+		os.Exit(1)
+	}()
+
+	// Follow the logs.
+	source := logging.FileSource(logDir)
+	query := fmt.Sprintf(`full_version == %q && !("serviceweaver/system" in attrs)`, dep.Id)
+	r, err := source.Query(ctx, query, true)
+	if err != nil {
+		return err
+	}
+	pp := logging.NewPrettyPrinter(colors.Enabled())
+	for {
+		entry, err := r.Read(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Println(pp.Format(entry))
+	}
+}
+
+// dryRun prints the plan of deploying the provided app to Nomad, and how it
+// differs from the running deployments of the app, without deploying it.
+// The application binary must run on the local machine.
+func dryRun(ctx context.Context, app *protos.AppConfig, cfg *config) error {
+	components, err := plan.Components(ctx, app)
+	if err != nil {
+		return err
+	}
+	note := "Listeners are served at the local addresses in their weaver.ListenerOptions, or at random ports, on the manager's machine once the app runs."
+	if cfg.Consul != nil {
+		note += " They are advertised as Consul services."
+	}
+	p := &plan.Plan{
+		App:          app.Name,
+		Deployer:     "weaver nomad",
+		Rollout:      "new Nomad jobs, one per colocation group",
+		Groups:       plan.Groups(app, components, plan.Replicas(app, cfg.Replicas, nil)),
+		Config:       app,
+		ListenerNote: note,
+	}
+	registry, err := defaultRegistry(ctx)
+	if err != nil {
+		return fmt.Errorf("create registry: %w", err)
+	}
+	deployments, err := plan.Running(ctx, registry, app.Name)
+	if err != nil {
+		return err
+	}
+	plan.Write(os.Stdout, p, deployments)
+	return nil
+}
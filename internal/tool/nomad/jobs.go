@@ -0,0 +1,149 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// This file contains the subset of the Nomad job specification [1] used by
+// the nomad deployer, along with the function that builds the job of a
+// colocation group. We define the types ourselves, rather than depend on the
+// Nomad API package, to keep the weaver tool's dependencies small.
+//
+// [1]: https://developer.hashicorp.com/nomad/api-docs/json-jobs
+
+const (
+	// Metadata attached to the jobs and Consul services of a deployment.
+	appMeta        = "serviceweaver_app"
+	deploymentMeta = "serviceweaver_deployment"
+	groupMeta      = "serviceweaver_group"
+	listenerMeta   = "serviceweaver_listener"
+
+	// allocIdKey is the environment variable in which Nomad passes the id of
+	// an allocation to its tasks.
+	allocIdKey = "NOMAD_ALLOC_ID"
+)
+
+type job struct {
+	ID          string
+	Name        string
+	Type        string
+	Region      string `json:",omitempty"`
+	Namespace   string `json:",omitempty"`
+	Datacenters []string
+	Meta        map[string]string
+	TaskGroups  []*taskGroup
+}
+
+type taskGroup struct {
+	Name  string
+	Count int
+	Tasks []*task
+}
+
+type task struct {
+	Name      string
+	Driver    string
+	Config    map[string]any
+	Env       map[string]string
+	Resources *resources `json:",omitempty"`
+}
+
+type resources struct {
+	CPU      int `json:",omitempty"`
+	MemoryMB int `json:",omitempty"`
+}
+
+// invalidNameChars matches runs of characters that may not appear in the
+// names of jobs and Consul services, along with dashes, so that they are
+// collapsed.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// name returns a valid name for a Nomad job, task group, or Consul service,
+// formed from the provided parts.
+func name(parts ...string) string {
+	s := strings.ToLower(strings.Join(parts, "-"))
+	s = invalidNameChars.ReplaceAllString(s, "-")
+	return strings.Trim(s, "-")
+}
+
+// jobName returns the id of the job that runs a colocation group.
+func jobName(dep *protos.Deployment, group string) string {
+	return name(dep.App.Name, logging.Shorten(dep.Id), logging.ShortenComponent(group))
+}
+
+// meta returns the metadata attached to the jobs and Consul services of a
+// deployment.
+func meta(dep *protos.Deployment) map[string]string {
+	return map[string]string{
+		appMeta:        dep.App.Name,
+		deploymentMeta: dep.Id,
+	}
+}
+
+// groupJob returns the job that runs the colocation group described by info.
+// The job has a single task group, with one allocation per replica, whose
+// task runs "weaver nomad babysitter".
+func groupJob(cfg *config, info *impl.BabysitterInfo) (*job, error) {
+	env, err := impl.BabysitterEnv(info)
+	if err != nil {
+		return nil, err
+	}
+	key, value, _ := strings.Cut(env, "=")
+
+	args := []string{"nomad", "babysitter"}
+	taskConfig := map[string]any{"command": cfg.Tool, "args": args}
+	if cfg.Driver == "docker" {
+		// Weavelets dial each other at the addresses they listen on, so they
+		// must share the network of their host.
+		taskConfig["image"] = cfg.Image
+		taskConfig["network_mode"] = "host"
+	}
+	var res *resources
+	if cfg.CPU != 0 || cfg.Memory != 0 {
+		res = &resources{CPU: cfg.CPU, MemoryMB: cfg.Memory}
+	}
+
+	dep := info.Deployment
+	m := meta(dep)
+	m[groupMeta] = info.Group
+	id := jobName(dep, info.Group)
+	return &job{
+		ID:          id,
+		Name:        id,
+		Type:        "service",
+		Region:      cfg.Region,
+		Namespace:   cfg.Namespace,
+		Datacenters: cfg.Datacenters,
+		Meta:        m,
+		TaskGroups: []*taskGroup{{
+			Name:  name(logging.ShortenComponent(info.Group)),
+			Count: cfg.Replicas,
+			Tasks: []*task{{
+				Name:      "babysitter",
+				Driver:    cfg.Driver,
+				Config:    taskConfig,
+				Env:       map[string]string{key: value},
+				Resources: res,
+			}},
+		}},
+	}, nil
+}
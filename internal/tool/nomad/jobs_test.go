@@ -0,0 +1,157 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nomad
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/internal/tool/tooltest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+const testConfig = `
+[serviceweaver]
+name = "Hello_App"
+binary = "/app/hello"
+
+[nomad]
+image = "example.com/hello:v1"
+datacenters = ["east", "west"]
+replicas = 3
+memory = 512
+
+[nomad.consul]
+advertise_address = "10.0.0.2"
+services = {hello = "hello-web"}
+`
+
+func TestLoadConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		section string
+		want    string
+	}{
+		{"NoSection", "", "no image"},
+		{"NoImage", "[nomad]\nreplicas = 2", "no image"},
+		{"ImageWithExec", "[nomad]\ndriver = \"exec\"\nimage = \"i\"", "image provided"},
+		{"UnknownDriver", "[nomad]\ndriver = \"podman\"", "unknown driver"},
+		{"NegativeReplicas", "[nomad]\nimage = \"i\"\nreplicas = -1", "negative replicas"},
+		{"NegativeMemory", "[nomad]\nimage = \"i\"\nmemory = -1", "negative memory"},
+		{"EmptyService", "[nomad]\nimage = \"i\"\n[nomad.consul]\nservices = {a = \"\"}", "empty service name"},
+		{"UnknownKey", "[nomad]\nimage = \"i\"\nreplica = 2", "unknown keys"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dep := tooltest.Deployment(t, "[serviceweaver]\nbinary = \"/app\"\n"+test.section)
+			_, err := loadConfig(dep.App)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("loadConfig: got %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestGroupJob(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &impl.BabysitterInfo{
+		Deployment:  dep,
+		Group:       "github.com/example/hello/Reverser",
+		ManagerAddr: "http://10.0.0.2:9000",
+	}
+	j, err := groupJob(cfg, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := j.ID, "hello-app-01234567-hello-reverser"; got != want {
+		t.Errorf("id: got %q, want %q", got, want)
+	}
+	if got, want := j.Datacenters, []string{"east", "west"}; !cmp.Equal(got, want) {
+		t.Errorf("datacenters: got %v, want %v", got, want)
+	}
+	if got := j.Meta[deploymentMeta]; got != dep.Id {
+		t.Errorf("deployment meta: got %q, want %q", got, dep.Id)
+	}
+	if len(j.TaskGroups) != 1 || len(j.TaskGroups[0].Tasks) != 1 {
+		t.Fatalf("got %d task groups, want 1 task group with 1 task", len(j.TaskGroups))
+	}
+	if got, want := j.TaskGroups[0].Count, 3; got != want {
+		t.Errorf("count: got %d, want %d", got, want)
+	}
+
+	// The babysitter runs in the image, and receives info in the environment.
+	task := j.TaskGroups[0].Tasks[0]
+	wantConfig := map[string]any{
+		"image":        "example.com/hello:v1",
+		"command":      "weaver",
+		"args":         []string{"nomad", "babysitter"},
+		"network_mode": "host",
+	}
+	if diff := cmp.Diff(wantConfig, task.Config); diff != "" {
+		t.Errorf("task config (-want +got):\n%s", diff)
+	}
+	if task.Resources == nil || task.Resources.MemoryMB != 512 || task.Resources.CPU != 0 {
+		t.Errorf("resources: got %+v, want 512 MB of memory", task.Resources)
+	}
+	if len(task.Env) != 1 {
+		t.Fatalf("env: got %v, want %s", task.Env, impl.BabysitterInfoKey)
+	}
+	t.Setenv(impl.BabysitterInfoKey, task.Env[impl.BabysitterInfoKey])
+	got, err := impl.BabysitterInfoFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(info, got, protocmp.Transform()); diff != "" {
+		t.Fatalf("babysitter info (-want +got):\n%s", diff)
+	}
+}
+
+func TestListenerService(t *testing.T) {
+	dep := tooltest.Deployment(t, testConfig)
+	cfg, err := loadConfig(dep.App)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAdvertiser(cfg.Consul, dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		listener string
+		addr     string
+		name     string // service name
+		address  string // service address
+	}{
+		{"hello", "[::]:8080", "hello-web", "10.0.0.2"},
+		{"admin", "localhost:9000", "admin", "localhost"},
+		{"other", ":80", "other", "10.0.0.2"},
+	} {
+		s, err := a.listenerService(test.listener, test.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Name != test.name || s.Address != test.address {
+			t.Errorf("%s at %s: got service %q at %q, want %q at %q", test.listener, test.addr, s.Name, s.Address, test.name, test.address)
+		}
+		if s.ID != "hello-app-01234567-"+test.listener {
+			t.Errorf("%s: got id %q", test.listener, s.ID)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nomad implements the "weaver nomad" deployer, which deploys Service
+// Weaver applications to a HashiCorp Nomad cluster.
+//
+// "weaver nomad deploy" runs a manager on the deploying machine, like the ssh
+// deployer. The manager submits a Nomad job for every colocation group, whose
+// allocations run a babysitter and a weavelet, and proxies the application's
+// listeners, which it can advertise as Consul services. The manager and
+// babysitters speak the same protocol as the ssh deployer's manager and
+// babysitters, and the deployment is registered in a local registry, so that
+// it can be inspected with "weaver nomad status", "logs", and "metrics".
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ServiceWeaver/weaver/internal/files"
+	"github.com/ServiceWeaver/weaver/internal/must"
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var (
+	// logDir is where weaver nomad deployed applications store their logs.
+	logDir = filepath.Join(logging.DefaultLogDir, "weaver_nomad")
+
+	dashboardSpec = &status.DashboardSpec{
+		Tool:     "weaver nomad",
+		Registry: defaultRegistry,
+		Commands: func(deploymentId string) []status.Command {
+			return []status.Command{
+				{Label: "status", Command: "weaver nomad status"},
+				{Label: "cat logs", Command: fmt.Sprintf("weaver nomad logs 'version==%q'", logging.Shorten(deploymentId))},
+				{Label: "follow logs", Command: fmt.Sprintf("weaver nomad logs --follow 'version==%q'", logging.Shorten(deploymentId))},
+				{Label: "export dashboard", Command: fmt.Sprintf("weaver nomad dashboard export %s", deploymentId)},
+			}
+		},
+	}
+
+	purgeSpec = &tool.PurgeSpec{
+		Tool:  "weaver nomad",
+		Kill:  "weaver nomad (dashboard|deploy|logs)",
+		Paths: []string{logDir, must.Must(defaultRegistryDir())},
+	}
+
+	Commands = map[string]*tool.Command{
+		"deploy": &deployCmd,
+		"logs": tool.LogsCmd(&tool.LogsSpec{
+			Tool: "weaver nomad",
+			Source: func(context.Context) (logging.Source, error) {
+				return logging.FileSource(logDir), nil
+			},
+		}),
+		"dashboard": status.DashboardCommand(dashboardSpec),
+		"status":    status.StatusCommand("weaver nomad", defaultRegistry),
+		"metrics":   status.MetricsCommand("weaver nomad", defaultRegistry),
+		"purge":     tool.PurgeCmd(purgeSpec),
+		"version":   tool.VersionCmd("weaver nomad"),
+
+		// Hidden commands.
+		"babysitter": &babysitterCmd,
+	}
+)
+
+// defaultRegistryDir returns $XDG_DATA_HOME/serviceweaver/nomad_registry, or
+// ~/.local/share/serviceweaver/nomad_registry if XDG_DATA_HOME is not set.
+func defaultRegistryDir() (string, error) {
+	dir, err := files.DefaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nomad_registry"), nil
+}
+
+// defaultRegistry returns a registry in defaultRegistryDir().
+func defaultRegistry(ctx context.Context) (*status.Registry, error) {
+	dir, err := defaultRegistryDir()
+	if err != nil {
+		return nil, err
+	}
+	return status.NewRegistry(ctx, dir)
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/ServiceWeaver/weaver/internal/files"
 	"github.com/ServiceWeaver/weaver/internal/must"
 	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
 )
 
@@ -33,6 +35,7 @@ var (
 			return []status.Command{
 				{Label: "status", Command: "weaver single status"},
 				{Label: "profile", Command: fmt.Sprintf("weaver single profile --duration=30s %s", deploymentId)},
+				{Label: "export dashboard", Command: fmt.Sprintf("weaver single dashboard export %s", deploymentId)},
 			}
 		},
 	}
@@ -41,6 +44,10 @@ var (
 		Tool:  "weaver single",
 		Kill:  "weaver single (dashboard|profile)",
 		Paths: []string{filepath.Join(must.Must(files.DefaultDataDir()), "single_registry")},
+		// Single process deployments write their logs to stdout, so they
+		// only store traces.
+		Data:    []retention.Store{retention.TraceDB(perfetto.Open)},
+		Running: status.RunningDeployments,
 	}
 
 	Commands = map[string]*tool.Command{
@@ -48,6 +55,8 @@ var (
 		"dashboard": status.DashboardCommand(dashboardSpec),
 		"metrics":   status.MetricsCommand("weaver single", defaultRegistry),
 		"profile":   status.ProfileCommand("weaver single", defaultRegistry),
+		"loglevel":  status.LogLevelCommand("weaver single", defaultRegistry),
+		"flags":     status.FlagsCommand("weaver single", defaultRegistry),
 		"purge":     tool.PurgeCmd(purgeSpec),
 		"version":   tool.VersionCmd("weaver single"),
 	}
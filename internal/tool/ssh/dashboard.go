@@ -29,6 +29,7 @@ var dashboardSpec = &status.DashboardSpec{
 		return []status.Command{
 			{Label: "cat logs", Command: fmt.Sprintf("weaver ssh logs 'version==%q'", logging.Shorten(deploymentId))},
 			{Label: "follow logs", Command: fmt.Sprintf("weaver ssh logs --follow 'version==%q'", logging.Shorten(deploymentId))},
+			{Label: "export dashboard", Command: fmt.Sprintf("weaver ssh dashboard export %s", deploymentId)},
 		}
 	},
 }
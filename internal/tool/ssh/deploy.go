@@ -26,26 +26,70 @@ import (
 	"os/signal"
 	"os/user"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/google/uuid"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
+	"github.com/ServiceWeaver/weaver/internal/plan"
+	"github.com/ServiceWeaver/weaver/internal/rollout"
+	"github.com/ServiceWeaver/weaver/internal/status"
 	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
+	"golang.org/x/exp/slices"
 )
 
-var deployCmd = tool.Command{
-	Name:        "deploy",
-	Description: "Deploy a Service Weaver app",
-	Help:        "Usage:\n  weaver ssh deploy <configfile>",
-	Flags:       flag.NewFlagSet("deploy", flag.ContinueOnError),
-	Fn:          deploy,
-}
+var (
+	deployFlags        = flag.NewFlagSet("deploy", flag.ContinueOnError)
+	deployDryRun       = deployFlags.Bool("dry-run", false, "Print the deployment plan without deploying")
+	deployRollout      = deployFlags.Bool("rollout", false, "Roll the app out over its running version")
+	deployTraffic      = deployFlags.Float64("traffic", -1, "Percentage of traffic sent to the new version")
+	deployMirror       = deployFlags.Float64("mirror", 0, "Percentage of traffic mirrored to the new version")
+	deployIncompatible = deployFlags.Bool("allow-incompatible", false, "Roll out a version incompatible with the running version")
+
+	deployCmd = tool.Command{
+		Name:        "deploy",
+		Description: "Deploy a Service Weaver app",
+		Help: `Usage:
+  weaver ssh deploy [--dry-run] [--rollout [--traffic=<percent>] [--mirror=<percent>] [--allow-incompatible]] <configfile>
+
+Flags:
+  -h, --help	Print this help message.
+  --dry-run	Print the colocation groups, replicas, listeners, and
+		rollout strategy of the deployment, and how it differs
+		from the running deployments of the app, without
+		deploying (default false)
+  --rollout	Deploy the app as a new version of its running
+		deployment. Traffic shifts gradually to the new version
+		over the rollout duration of the config, and the running
+		version is then retired (default false)
+  --traffic	With --rollout, send the provided percentage of traffic
+		to the new version and leave the rollout to be driven by
+		"weaver ssh rollout" and "weaver ssh rollback"
+  --mirror	With --rollout, mirror the provided percentage of traffic
+		to the new version: the new version serves copies of the
+		requests, whose responses are discarded. The rollout is
+		driven by "weaver ssh rollout" and "weaver ssh rollback",
+		and no traffic is sent to the new version unless --traffic
+		is provided (default 0)
+  --allow-incompatible
+		With --rollout, warn about, rather than refuse, changes
+		to the methods of the components, or to the types of
+		their arguments and results, that prevent the new and
+		running versions from calling each other (default false)`,
+		Flags: deployFlags,
+		Fn:    deploy,
+	}
+)
 
 // deploy deploys an application on a cluster of machines using an SSH deployer.
 // Note that each component is deployed as a separate OS process.
@@ -74,11 +118,85 @@ func deploy(ctx context.Context, args []string) error {
 		return fmt.Errorf("binary %q doesn't exist", app.Binary)
 	}
 
-	// Retrieve the list of locations to deploy.
+	if *deployTraffic >= 0 && !*deployRollout {
+		return fmt.Errorf("--traffic requires --rollout")
+	}
+	if *deployMirror > 0 && !*deployRollout {
+		return fmt.Errorf("--mirror requires --rollout")
+	}
+	if *deployIncompatible && !*deployRollout {
+		return fmt.Errorf("--allow-incompatible requires --rollout")
+	}
+	if *deployTraffic > 100 {
+		return fmt.Errorf("--traffic=%v not in [0, 100]", *deployTraffic)
+	}
+	if *deployMirror < 0 || *deployMirror > 100 {
+		return fmt.Errorf("--mirror=%v not in [0, 100]", *deployMirror)
+	}
+
+	// Find the running version of the app, if rolling out.
+	opts := impl.ManagerOptions{RolloutTraffic: *deployTraffic, RolloutMirror: *deployMirror}
+	if *deployRollout {
+		registry, err := impl.DefaultRegistry(ctx)
+		if err != nil {
+			return fmt.Errorf("create registry: %w", err)
+		}
+		running, err := rollout.Running(ctx, registry, app.Name)
+		if err != nil {
+			return err
+		}
+		opts.RolloutOver = &running
+	}
+
+	// Describe the components of the app, and check that they are compatible
+	// with the running version, if rolling out.
+	if *deployRollout || !*deployDryRun {
+		opts.Schema, err = plan.Schema(ctx, app)
+		if err != nil && *deployRollout {
+			return err
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: new versions can't be checked for compatibility with this deployment: %v\n", err)
+		}
+	}
+	if *deployRollout {
+		if err := rollout.CheckCompatible(ctx, status.NewClient(opts.RolloutOver.Addr), opts.Schema, *deployIncompatible, os.Stderr); err != nil {
+			return err
+		}
+	}
+
+	// Resolve the key that encrypts the data persisted by the deployment, if
+	// any.
+	opts.EncryptionKey, err = getEncryptionKey(ctx, app)
+	if err != nil {
+		return err
+	}
+
+	// Retrieve the list of locations to deploy, and the components pinned to
+	// some of them.
 	locs, err := getLocations(app)
 	if err != nil {
 		return err
 	}
+	pins, err := getPins(app, locs)
+	if err != nil {
+		return err
+	}
+	pinned, err := impl.PinnedLocations(app, pins)
+	if err != nil {
+		return err
+	}
+	regions, err := getRegions(app, locs)
+	if err != nil {
+		return err
+	}
+	if regions != nil {
+		locs = spreadRegions(locs, regions)
+	}
+
+	// Print the deployment plan instead, if requested.
+	if *deployDryRun {
+		return dryRun(ctx, app, locs, pinned, regions, opts.RolloutOver)
+	}
 
 	// Create a deployment.
 	dep := &protos.Deployment{
@@ -92,24 +210,37 @@ func deploy(ctx context.Context, args []string) error {
 	}
 
 	// Run the manager.
-	stopFn, err := impl.RunManager(ctx, dep, locs, logDir)
+	retired := make(chan struct{})
+	var retireOnce sync.Once
+	opts.Locations = locs
+	opts.Pins = pins
+	opts.Regions = regions
+	opts.Retire = func() { retireOnce.Do(func() { close(retired) }) }
+	stopFn, err := impl.RunManager(ctx, dep, logDir, opts)
 	if err != nil {
 		return fmt.Errorf("cannot instantiate the manager: %w", err)
 	}
 
-	// Wait for the user to kill the app.
+	// Wait for the user to kill the app, or for the app to be retired by a
+	// rollout.
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		<-done // Will block here until user hits ctrl+c
-		if err := terminateDeployment(locs, dep); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to terminate deployment: %v\n", err)
+		code := 1
+		select {
+		case <-done: // Will block here until user hits ctrl+c
+		case <-retired:
+			fmt.Fprintf(os.Stderr, "Application %s retired\n", app.Name)
+			code = 0
 		}
-		fmt.Fprintf(os.Stderr, "Application %s terminated\n", app.Name)
 		if err := stopFn(); err != nil {
 			fmt.Fprintf(os.Stderr, "stop the manager: %v\n", err)
 		}
-		os.Exit(1)
+		if err := terminateDeployment(locs, dep); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to terminate deployment: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Application %s terminated\n", app.Name)
+		os.Exit(code)
 	}()
 
 	// Follow the logs.
@@ -131,6 +262,59 @@ func deploy(ctx context.Context, args []string) error {
 	}
 }
 
+// dryRun prints the plan of deploying the provided app at the provided
+// locations, and how it differs from the running deployments of the app,
+// without deploying it. pinned holds the locations of the pinned colocation
+// groups, regions the regions of the locations, if any, and running is the
+// deployment rolled out over, or nil if not rolling out.
+func dryRun(ctx context.Context, app *protos.AppConfig, locs []string, pinned map[string][]string, regions map[string]string, running *status.Registration) error {
+	wletConfig, err := runtime.ParseWeaveletConfig(app.Sections)
+	if err != nil {
+		return err
+	}
+	components, err := plan.Components(ctx, app)
+	if err != nil {
+		return err
+	}
+	groupName := plan.GroupNamer(app)
+	autoscaled := map[string]bool{}
+	for component := range wletConfig.Autoscaling {
+		autoscaled[groupName(component)] = true
+	}
+	unpinned := plan.Replicas(app, len(locs), wletConfig.Autoscaling)
+	replicas := func(group string) string {
+		if locs, ok := pinned[group]; ok && !autoscaled[group] {
+			return fmt.Sprintf("%d (pinned to %s)", len(locs), strings.Join(locs, ", "))
+		}
+		if regions != nil && !autoscaled[group] {
+			return fmt.Sprintf("%s (across %d regions)", unpinned(group), len(regionNames(regions)))
+		}
+		return unpinned(group)
+	}
+	note := "Listeners are served at the local addresses in their weaver.ListenerOptions, or at random ports, on the manager's machine once the app runs."
+	if running != nil {
+		note = "Listeners are served at the addresses of the running deployment."
+	}
+	p := &plan.Plan{
+		App:          app.Name,
+		Deployer:     "weaver ssh",
+		Rollout:      plan.Rollout("weaver ssh", app, running, *deployTraffic, *deployMirror),
+		Groups:       plan.Groups(app, components, replicas),
+		Config:       app,
+		ListenerNote: note,
+	}
+	registry, err := impl.DefaultRegistry(ctx)
+	if err != nil {
+		return fmt.Errorf("create registry: %w", err)
+	}
+	deployments, err := plan.Running(ctx, registry, app.Name)
+	if err != nil {
+		return err
+	}
+	plan.Write(os.Stdout, p, deployments)
+	return nil
+}
+
 // copyBinaries copies the tool and the application binary to the given set
 // of locations.
 func copyBinaries(locs []string, dep *protos.Deployment) error {
@@ -172,15 +356,80 @@ func terminateDeployment(locs []string, dep *protos.Deployment) error {
 	return nil
 }
 
-// getLocations returns the list of locations at which to deploy the application.
-func getLocations(app *protos.AppConfig) ([]string, error) {
-	// SSH config as found in TOML config file.
-	const sshKey = "github.com/ServiceWeaver/weaver/ssh"
-	const shortSSHKey = "ssh"
+const (
+	// sshKey and shortSSHKey are the keys of the ssh section of the config.
+	sshKey      = "github.com/ServiceWeaver/weaver/ssh"
+	shortSSHKey = "ssh"
+)
+
+// sshConfigSchema is the ssh section of an application config, as found in
+// the TOML config file.
+type sshConfigSchema struct {
+	LocationsFile string `toml:"locations_file"`
+
+	// Pin maps the full name of a component to the locations, from the
+	// locations file, that the replicas of its colocation group are
+	// restricted to.
+	Pin map[string][]string `toml:"pin"`
+
+	// Regions maps the name of every region to the locations, from the
+	// locations file, in the region. If set, every location must be in
+	// exactly one region.
+	Regions map[string][]string `toml:"regions"`
 
-	type sshConfigSchema struct {
-		LocationsFile string `toml:"locations_file"`
+	// EncryptionKey, if not empty, is a reference to the secret holding the
+	// key that encrypts the logs, traces, and registration that the
+	// deployment persists, e.g., "${secret:file:/etc/weaver/data.key}".
+	// See internal/atrest.
+	EncryptionKey string `toml:"encryption_key"`
+}
+
+// CheckConfig checks the ssh section of the provided application config, if
+// it has one. It is used by "weaver config check".
+func CheckConfig(app *protos.AppConfig) error {
+	_, long := app.Sections[sshKey]
+	_, short := app.Sections[shortSSHKey]
+	if !long && !short {
+		return nil
 	}
+	parsed := &sshConfigSchema{}
+	if err := runtime.ParseConfigSection(sshKey, shortSSHKey, app.Sections, parsed); err != nil {
+		return fmt.Errorf("unable to parse ssh config: %w", err)
+	}
+	if parsed.LocationsFile == "" {
+		return fmt.Errorf("unable to parse ssh config: no locations_file provided")
+	}
+	for component, locs := range parsed.Pin {
+		if len(locs) == 0 {
+			return fmt.Errorf("unable to parse ssh config: component %q pinned to no locations", component)
+		}
+	}
+	for region, locs := range parsed.Regions {
+		if len(locs) == 0 {
+			return fmt.Errorf("unable to parse ssh config: region %q has no locations", region)
+		}
+	}
+	if parsed.EncryptionKey != "" && !secrets.IsReference(parsed.EncryptionKey) {
+		return fmt.Errorf("unable to parse ssh config: encryption_key must reference a secret, e.g., \"${secret:file:/path/to/key}\"")
+	}
+	return nil
+}
+
+// getEncryptionKey returns the key that encrypts the data persisted by the
+// deployment of the application, or nil if the data is not encrypted.
+func getEncryptionKey(ctx context.Context, app *protos.AppConfig) (*atrest.Key, error) {
+	parsed := &sshConfigSchema{}
+	if err := runtime.ParseConfigSection(sshKey, shortSSHKey, app.Sections, parsed); err != nil {
+		return nil, fmt.Errorf("unable to parse ssh config: %w", err)
+	}
+	if parsed.EncryptionKey == "" {
+		return nil, nil
+	}
+	return atrest.Resolve(ctx, parsed.EncryptionKey)
+}
+
+// getLocations returns the list of locations at which to deploy the application.
+func getLocations(app *protos.AppConfig) ([]string, error) {
 	parsed := &sshConfigSchema{}
 	if err := runtime.ParseConfigSection(sshKey, shortSSHKey, app.Sections, parsed); err != nil {
 		return nil, fmt.Errorf("unable to parse ssh config: %w", err)
@@ -209,6 +458,104 @@ func getLocations(app *protos.AppConfig) ([]string, error) {
 	return locations, nil
 }
 
+// getPins returns the locations that components are pinned to, checking that
+// they are among the provided locations.
+func getPins(app *protos.AppConfig, locs []string) (map[string][]string, error) {
+	parsed := &sshConfigSchema{}
+	if err := runtime.ParseConfigSection(sshKey, shortSSHKey, app.Sections, parsed); err != nil {
+		return nil, fmt.Errorf("unable to parse ssh config: %w", err)
+	}
+	for component, pinned := range parsed.Pin {
+		if len(pinned) == 0 {
+			return nil, fmt.Errorf("component %q pinned to no locations", component)
+		}
+		seen := map[string]bool{}
+		for _, loc := range pinned {
+			if !slices.Contains(locs, loc) {
+				return nil, fmt.Errorf("component %q pinned to location %q, which is not in the locations file", component, loc)
+			}
+			if seen[loc] {
+				return nil, fmt.Errorf("component %q pinned to location %q more than once", component, loc)
+			}
+			seen[loc] = true
+		}
+	}
+	return parsed.Pin, nil
+}
+
+// getRegions returns the regions of the provided locations, keyed by
+// location, or nil if the application doesn't run in regions. It checks that
+// every location is in exactly one region.
+func getRegions(app *protos.AppConfig, locs []string) (map[string]string, error) {
+	parsed := &sshConfigSchema{}
+	if err := runtime.ParseConfigSection(sshKey, shortSSHKey, app.Sections, parsed); err != nil {
+		return nil, fmt.Errorf("unable to parse ssh config: %w", err)
+	}
+	if len(parsed.Regions) == 0 {
+		return nil, nil
+	}
+	regions := map[string]string{}
+	for region, inRegion := range parsed.Regions {
+		if len(inRegion) == 0 {
+			return nil, fmt.Errorf("region %q has no locations", region)
+		}
+		for _, loc := range inRegion {
+			if !slices.Contains(locs, loc) {
+				return nil, fmt.Errorf("region %q has location %q, which is not in the locations file", region, loc)
+			}
+			if other, ok := regions[loc]; ok {
+				return nil, fmt.Errorf("location %q is in regions %q and %q", loc, other, region)
+			}
+			regions[loc] = region
+		}
+	}
+	for _, loc := range locs {
+		if _, ok := regions[loc]; !ok {
+			return nil, fmt.Errorf("location %q is in no region", loc)
+		}
+	}
+	return regions, nil
+}
+
+// spreadRegions reorders the provided locations so that consecutive
+// locations are in different regions, when possible, keeping the order of the
+// locations file within every region. Replicas are started at the locations
+// in a round-robin fashion, so the replicas of an autoscaled group span as
+// many regions as they can.
+func spreadRegions(locs []string, regions map[string]string) []string {
+	var names []string
+	byRegion := map[string][]string{}
+	for _, loc := range locs {
+		region := regions[loc]
+		if _, ok := byRegion[region]; !ok {
+			names = append(names, region)
+		}
+		byRegion[region] = append(byRegion[region], loc)
+	}
+	spread := make([]string, 0, len(locs))
+	for i := 0; len(spread) < len(locs); i++ {
+		for _, region := range names {
+			if i < len(byRegion[region]) {
+				spread = append(spread, byRegion[region][i])
+			}
+		}
+	}
+	return spread
+}
+
+// regionNames returns the sorted names of the provided regions, keyed by
+// location.
+func regionNames(regions map[string]string) []string {
+	var names []string
+	for _, region := range regions {
+		if !slices.Contains(names, region) {
+			names = append(names, region)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // getAbsoluteFilePath returns the absolute path for a file.
 func getAbsoluteFilePath(file string) (string, error) {
 	if len(file) == 0 {
@@ -16,12 +16,15 @@ package impl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/mtls"
 	"github.com/ServiceWeaver/weaver/internal/proto"
 	"github.com/ServiceWeaver/weaver/internal/traceio"
 	"github.com/ServiceWeaver/weaver/runtime"
@@ -31,6 +34,7 @@ import (
 	"github.com/ServiceWeaver/weaver/runtime/protomsg"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/ServiceWeaver/weaver/runtime/retry"
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/exp/slog"
@@ -40,37 +44,65 @@ import (
 // group for a single application version, on the local machine.
 type babysitter struct {
 	ctx           context.Context
+	ctxCancel     context.CancelFunc
 	info          *BabysitterInfo
+	id            string // weavelet id
 	logger        *slog.Logger
 	traceExporter *traceio.Writer // to export traces to the manager
 	envelope      *envelope.Envelope
 
+	// stopped is set when the manager asks the babysitter to stop, because
+	// its replica was removed by the autoscaler.
+	stopped atomic.Bool
+
 	mu                  sync.Mutex
 	watchingRoutingInfo map[string]bool
 }
 
 var _ envelope.EnvelopeHandler = &babysitter{}
 
-// RunBabysitter creates and runs an envelope.Envelope and a metrics collector for a
-// weavelet deployed with SSH.
-func RunBabysitter(ctx context.Context) error {
-	// Retrieve the deployment information.
+// BabysitterInfoFromEnv returns the BabysitterInfo passed to the babysitter
+// in the environment by the manager.
+func BabysitterInfoFromEnv() (*BabysitterInfo, error) {
 	info := &BabysitterInfo{}
-	if err := proto.FromEnv(os.Getenv(babysitterInfoKey), info); err != nil {
-		return fmt.Errorf("unable to retrieve deployment info: %w", err)
+	if err := proto.FromEnv(os.Getenv(BabysitterInfoKey), info); err != nil {
+		return nil, fmt.Errorf("unable to retrieve deployment info: %w", err)
 	}
+	return info, nil
+}
 
-	// Create the log saver.
-	fs, err := logging.NewFileStore(info.LogDir)
-	if err != nil {
-		return fmt.Errorf("cannot create log storage: %w", err)
+// RunBabysitter creates and runs an envelope.Envelope and a metrics collector
+// for a weavelet described by info.
+func RunBabysitter(ctx context.Context, info *BabysitterInfo) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Create the log saver. If the babysitter has no log directory, its logs
+	// are sent to the manager, like the logs of the weavelet.
+	var logSaver func(*protos.LogEntry)
+	if info.LogDir != "" {
+		fs, err := logging.NewFileStore(info.LogDir)
+		if err != nil {
+			return fmt.Errorf("cannot create log storage: %w", err)
+		}
+		logSaver = fs.Add
+	} else {
+		logSaver = func(entry *protos.LogEntry) {
+			protomsg.Call(ctx, protomsg.CallArgs{ //nolint:errcheck // best effort
+				Client:  http.DefaultClient,
+				Addr:    info.ManagerAddr,
+				URLPath: recvLogEntryURL,
+				Request: entry,
+			})
+		}
 	}
-	logSaver := fs.Add
 
 	id := uuid.New().String()
 	b := &babysitter{
-		ctx:  ctx,
-		info: info,
+		ctx:       ctx,
+		ctxCancel: cancel,
+		info:      info,
+		id:        id,
 		logger: slog.New(&logging.LogHandler{
 			Opts: logging.Options{
 				App:        info.Deployment.App.Name,
@@ -92,16 +124,30 @@ func RunBabysitter(ctx context.Context) error {
 		watchingRoutingInfo: map[string]bool{},
 	}
 
-	// Start the envelope.
+	// Start the envelope. Secrets are resolved on the babysitter's machine,
+	// and only passed to the weavelet.
+	app, err := secrets.Resolve(ctx, info.Deployment.App)
+	if err != nil {
+		return fmt.Errorf("cannot resolve secrets: %w", err)
+	}
+	config, err := runtime.ParseWeaveletConfig(app.Sections)
+	if err != nil {
+		return err
+	}
+	if p, ok := runtime.GroupProcessConfigs(app, config)[info.Group]; ok {
+		app = runtime.ApplyProcessConfig(app, p)
+	}
 	wlet := &protos.EnvelopeInfo{
-		App:           info.Deployment.App.Name,
+		App:           app.Name,
 		DeploymentId:  info.Deployment.Id,
 		Id:            id,
-		Sections:      info.Deployment.App.Sections,
+		Sections:      app.Sections,
 		SingleProcess: info.Deployment.SingleProcess,
 		RunMain:       info.RunMain,
+		Region:        info.Region,
+		Group:         info.Group,
 	}
-	e, err := envelope.NewEnvelope(ctx, wlet, info.Deployment.App)
+	e, err := envelope.NewEnvelope(ctx, wlet, app)
 	if err != nil {
 		return err
 	}
@@ -121,7 +167,12 @@ func RunBabysitter(ctx context.Context) error {
 	}
 	c := metricsCollector{logger: b.logger, envelope: e, info: info}
 	go c.run(ctx)
-	return e.Serve(b)
+	err = e.Serve(b)
+	b.unregisterReplica(winfo)
+	if b.stopped.Load() {
+		return nil
+	}
+	return err
 }
 
 // checkVersion checks that the deployer API version the deployer was built
@@ -213,18 +264,92 @@ func (b *babysitter) registerReplica(info *protos.WeaveletInfo) error {
 		Addr:    b.info.ManagerAddr,
 		URLPath: registerReplicaURL,
 		Request: &ReplicaToRegister{
-			Group:   b.info.Group,
-			Address: info.DialAddr,
-			Pid:     info.Pid,
+			Group:     b.info.Group,
+			Address:   info.DialAddr,
+			Pid:       info.Pid,
+			ReplicaId: b.info.ReplicaId,
+			Region:    b.info.Region,
 		},
 	}); err != nil {
 		return err
 	}
 
 	go b.watchComponents()
+	go b.reportHealthy()
 	return nil
 }
 
+// unregisterReplica tells the manager that the weavelet stopped, so that
+// the manager stops routing traffic to it.
+func (b *babysitter) unregisterReplica(info *protos.WeaveletInfo) {
+	// b.ctx is likely canceled, so use a fresh context.
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatInterval)
+	defer cancel()
+	if err := protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    b.info.ManagerAddr,
+		URLPath: unregisterReplicaURL,
+		Request: &ReplicaToRegister{
+			Group:     b.info.Group,
+			Address:   info.DialAddr,
+			Pid:       info.Pid,
+			ReplicaId: b.info.ReplicaId,
+			Region:    b.info.Region,
+		},
+	}); err != nil {
+		b.logger.Error("cannot unregister replica", err)
+	}
+}
+
+// reportHealthy waits for the weavelet to initialize its components and
+// report itself healthy, and then tells the manager, which waits for the
+// replicas it starts to be healthy when the deployment restarts. It keeps
+// telling the manager every heartbeatInterval while the weavelet is healthy,
+// so that the manager stops routing traffic to replicas that die.
+func (b *babysitter) reportHealthy() {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for b.envelope.GetHealth() != protos.HealthStatus_HEALTHY {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	for r := retry.Begin(); r.Continue(b.ctx); {
+		if err := b.sendHeartbeat(); err != nil {
+			b.logger.Error("cannot report health; will retry", err)
+			continue
+		}
+		break
+	}
+
+	ticker.Reset(heartbeatInterval)
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+			if b.envelope.GetHealth() != protos.HealthStatus_HEALTHY {
+				continue
+			}
+			if err := b.sendHeartbeat(); err != nil {
+				b.logger.Error("cannot report health", err)
+			}
+		}
+	}
+}
+
+// sendHeartbeat tells the manager that the weavelet is healthy.
+func (b *babysitter) sendHeartbeat() error {
+	return protomsg.Call(b.ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    b.info.ManagerAddr,
+		URLPath: replicaHealthyURL,
+		Request: &ReplicaHealthy{Group: b.info.Group, ReplicaId: b.info.ReplicaId},
+	})
+}
+
 // GetListenerAddress implements the protos.EnvelopeHandler interface.
 func (b *babysitter) GetListenerAddress(_ context.Context, req *protos.GetListenerAddressRequest) (*protos.GetListenerAddressReply, error) {
 	host, err := os.Hostname()
@@ -241,8 +366,12 @@ func (b *babysitter) ExportListener(_ context.Context, req *protos.ExportListene
 		Client:  http.DefaultClient,
 		Addr:    b.info.ManagerAddr,
 		URLPath: exportListenerURL,
-		Request: req,
-		Reply:   reply,
+		Request: &ListenerToExport{
+			Group:     b.info.Group,
+			ReplicaId: b.info.ReplicaId,
+			Request:   req,
+		},
+		Reply: reply,
 	}); err != nil {
 		return nil, err
 	}
@@ -291,8 +420,12 @@ func (b *babysitter) watchRoutingInfo(component string, routed bool) {
 	}
 }
 
-func (b *babysitter) getComponentsToStart(version string) ([]string, string, error) {
-	req := &GetComponentsRequest{Group: b.info.Group, Version: version}
+func (b *babysitter) getComponentsToStart(version string) (*GetComponentsReply, error) {
+	req := &GetComponentsRequest{
+		Group:     b.info.Group,
+		Version:   version,
+		ReplicaId: b.info.ReplicaId,
+	}
 	reply := &GetComponentsReply{}
 	if err := protomsg.Call(b.ctx, protomsg.CallArgs{
 		Client:  http.DefaultClient,
@@ -301,21 +434,25 @@ func (b *babysitter) getComponentsToStart(version string) ([]string, string, err
 		Request: req,
 		Reply:   reply,
 	}); err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	return reply.Components, reply.Version, nil
+	return reply, nil
 }
 
 func (b *babysitter) watchComponents() {
 	version := ""
 	for r := retry.Begin(); r.Continue(b.ctx); {
-		components, newVersion, err := b.getComponentsToStart(version)
+		reply, err := b.getComponentsToStart(version)
 		if err != nil {
 			b.logger.Error("cannot get components to start; will retry", err)
 			continue
 		}
-		version = newVersion
-		if err := b.envelope.UpdateComponents(components); err != nil {
+		if reply.Stop {
+			b.stop()
+			return
+		}
+		version = reply.Version
+		if err := b.envelope.UpdateComponents(reply.Components); err != nil {
 			b.logger.Error("cannot update components to start; will retry", err)
 			continue
 		}
@@ -323,6 +460,23 @@ func (b *babysitter) watchComponents() {
 	}
 }
 
+// stop drains the weavelet and stops the babysitter. The manager has already
+// stopped routing traffic to the weavelet.
+func (b *babysitter) stop() {
+	b.logger.Info("Replica removed by the autoscaler; stopping")
+	drainTime := runtime.DefaultMaxDrainTime + runtime.DefaultShutdownTimeout
+	if config, err := runtime.ParseWeaveletConfig(b.info.Deployment.App.Sections); err == nil {
+		drainTime = config.MaxDrainTime + config.ShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, drainTime+time.Second)
+	defer cancel()
+	if err := b.envelope.Drain(ctx); err != nil {
+		b.logger.Error("drain", err)
+	}
+	b.stopped.Store(true)
+	b.ctxCancel()
+}
+
 // HandleLogEntry implements the protos.EnvelopeHandler interface.
 func (b *babysitter) HandleLogEntry(_ context.Context, req *protos.LogEntry) error {
 	return protomsg.Call(b.ctx, protomsg.CallArgs{
@@ -337,3 +491,88 @@ func (b *babysitter) HandleLogEntry(_ context.Context, req *protos.LogEntry) err
 func (b *babysitter) HandleTraceSpans(_ context.Context, spans []trace.ReadOnlySpan) error {
 	return b.traceExporter.ExportSpans(b.ctx, spans)
 }
+
+// HandleProfile implements the protos.EnvelopeHandler interface.
+func (b *babysitter) HandleProfile(context.Context, *protos.Profile) error {
+	// TODO(mwhittaker): Forward profiles to the manager, so that they can be
+	// viewed in the dashboard. For now, profiles are only exported to the
+	// profiling backends configured in the app config.
+	return nil
+}
+
+// HandleCrashReport implements the protos.EnvelopeHandler interface.
+func (b *babysitter) HandleCrashReport(_ context.Context, report *protos.CrashReport) error {
+	// TODO(mwhittaker): Forward crash reports to the manager, so that they
+	// can be viewed in the dashboard. For now, they are only logged.
+	msg := "component method panicked"
+	if report.Stack == "" {
+		// A fatal error, like a failed weaver.MustGet, rather than a panic.
+		msg = "component failed"
+	}
+	b.logger.Error(msg, errors.New(report.Panic), "component", report.Component, "method", report.Method, "trace_id", report.TraceId)
+	return nil
+}
+
+// HandleTopicEvent implements the protos.EnvelopeHandler interface.
+func (b *babysitter) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	// TODO(mwhittaker): Forward topic events to the other weavelets via the
+	// manager. For now, events are only delivered to subscribers in the
+	// publishing weavelet.
+	return nil
+}
+
+// PublishMessage implements the protos.EnvelopeHandler interface.
+func (b *babysitter) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	// TODO(mwhittaker): Store messages in the manager, and deliver them to
+	// the weavelets on every machine.
+	return nil, fmt.Errorf("topics are not supported by weaver ssh")
+}
+
+// ScheduleJob implements the protos.EnvelopeHandler interface.
+func (b *babysitter) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	// TODO(mwhittaker): Schedule jobs in the manager, so that every run is
+	// started on exactly one machine.
+	return nil, fmt.Errorf("cron jobs are not supported by weaver ssh")
+}
+
+// GetCertificate implements the protos.EnvelopeHandler interface.
+func (b *babysitter) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	// The key is generated here, on the weavelet's machine, and only its
+	// public key is sent to the manager for signing.
+	key, pub, err := mtls.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	reply := &protos.GetCertificateReply{}
+	if err := protomsg.Call(b.ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    b.info.ManagerAddr,
+		URLPath: signCertificateURL,
+		Request: &SignCertificateRequest{WeaveletId: b.id, PublicKey: pub},
+		Reply:   reply,
+	}); err != nil {
+		return nil, err
+	}
+	reply.Key = key
+	return reply, nil
+}
+
+// Lease implements the protos.EnvelopeHandler interface.
+func (b *babysitter) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	reply := &protos.LeaseReply{}
+	if err := protomsg.Call(b.ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    b.info.ManagerAddr,
+		URLPath: leaseURL,
+		Request: req,
+		Reply:   reply,
+	}); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Subscribe implements the protos.EnvelopeHandler interface.
+func (b *babysitter) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	return nil, fmt.Errorf("topics are not supported by weaver ssh")
+}
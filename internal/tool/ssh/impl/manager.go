@@ -18,21 +18,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
+	"github.com/ServiceWeaver/weaver/internal/autoscale"
 	"github.com/ServiceWeaver/weaver/internal/files"
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/internal/listeners"
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/internal/mtls"
 	"github.com/ServiceWeaver/weaver/internal/routing"
 	"github.com/ServiceWeaver/weaver/runtime/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/perfetto"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
@@ -40,9 +47,11 @@ import (
 
 	"github.com/ServiceWeaver/weaver/internal/proto"
 	"github.com/ServiceWeaver/weaver/internal/proxy"
+	"github.com/ServiceWeaver/weaver/internal/rollout"
 	"github.com/ServiceWeaver/weaver/internal/status"
 	"github.com/ServiceWeaver/weaver/internal/traceio"
 	"github.com/ServiceWeaver/weaver/internal/versioned"
+	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	"github.com/ServiceWeaver/weaver/runtime/protomsg"
 	"github.com/ServiceWeaver/weaver/runtime/retry"
@@ -60,10 +69,33 @@ const (
 	recvLogEntryURL         = "/manager/recv_log_entry"
 	recvTraceSpansURL       = "/manager/recv_trace_spans"
 	recvMetricsURL          = "/manager/recv_metrics"
-
-	// babysitterInfoKey is the name of the env variable that contains deployment
-	// information for a babysitter deployed using SSH.
-	babysitterInfoKey = "SERVICEWEAVER_BABYSITTER_INFO"
+	signCertificateURL      = "/manager/sign_certificate"
+	leaseURL                = "/manager/lease"
+	replicaHealthyURL       = "/manager/replica_healthy"
+	unregisterReplicaURL    = "/manager/unregister_replica"
+	restartURL              = "/manager/restart"
+
+	// BabysitterInfoKey is the name of the env variable that contains
+	// deployment information for a babysitter.
+	BabysitterInfoKey = "SERVICEWEAVER_BABYSITTER_INFO"
+
+	// How often autoscaled colocation groups are rescaled. Babysitters
+	// report metrics every minute, so rescaling more often would mostly
+	// reuse the same metrics.
+	autoscaleInterval = 30 * time.Second
+
+	// How often babysitters check whether their weavelets are healthy, and
+	// restarts check whether the replicas they started reported themselves
+	// healthy.
+	healthPollInterval = time.Second
+
+	// How often babysitters report that their healthy weavelets are still
+	// healthy, and how long the manager routes traffic to a replica started
+	// by ManagerOptions.StartGroup that stops reporting it. The timeout also
+	// bounds how long a new replica can take to become healthy before the
+	// manager stops routing traffic to it, until it does.
+	heartbeatInterval = 5 * time.Second
+	heartbeatTimeout  = 30 * time.Second
 )
 
 // manager manages an application version deployment across a set of locations,
@@ -77,8 +109,8 @@ type manager struct {
 	dep        *protos.Deployment
 	logger     *slog.Logger
 	logDir     string
-	locations  []string // addresses of the locations
-	mgrAddress string   // manager address
+	opts       ManagerOptions
+	mgrAddress string // manager address
 	registry   *status.Registry
 	started    time.Time
 
@@ -106,10 +138,58 @@ type manager struct {
 	// itself.
 	colocation map[string]string
 
-	mu      sync.Mutex                                    // guards following structures, but not contents
-	groups  map[string]*group                             // groups, by group name
-	proxies map[string]*proxyInfo                         // proxies, by listener name
-	metrics map[groupReplicaInfo][]*protos.MetricSnapshot // latest metrics, by group name and replica id
+	// routingConfigs holds the routing policies of routed components, by
+	// component name.
+	routingConfigs map[string]runtime.RoutingConfig
+
+	// streamIdleTimeout is how long the proxies keep idle listener streams
+	// open. See runtime.WeaveletConfig.
+	streamIdleTimeout time.Duration
+
+	// listenerConfigs holds the configs of the listeners, by listener name.
+	// See runtime.ListenerConfig.
+	listenerConfigs map[string]runtime.ListenerConfig
+
+	// ca signs the certificates that weavelets use to authenticate each
+	// other, if the app is configured with mtls = true.
+	ca *mtls.CA
+
+	// leases holds the leases of the leader elections created with
+	// weaver.NewLeaderElection.
+	leases *lease.Table
+
+	// pinned holds the locations that the replicas of pinned colocation
+	// groups are restricted to, by group name. See ManagerOptions.Pins.
+	pinned map[string][]string
+
+	// autoscalers holds the autoscalers of autoscaled colocation groups, by
+	// group name, and tracker computes the load of the groups from the
+	// metrics of their replicas. tracker is only accessed by the autoscale
+	// goroutine. See runtime.AutoscalingConfig.
+	autoscalers map[string]*autoscale.Autoscaler
+	tracker     *autoscale.Tracker
+
+	// If the deployment is rolled out over the running version of the
+	// application, rollout drives the rollout. Otherwise, rollout is nil.
+	// split splits the listener traffic with a new version rolled out over
+	// the deployment. See ManagerOptions.RolloutOver.
+	rollout *rollout.Rollout
+	split   rollout.Split
+
+	// replicaDep is the deployment run by newly started replicas. It starts
+	// out as dep, and is replaced when the deployment is restarted with a
+	// new config. restarting is set while the deployment restarts.
+	replicaDep atomic.Pointer[protos.Deployment]
+	restarting atomic.Bool
+
+	// launchBabysitter starts a babysitter at a location. It is
+	// startBabysitter, unless replaced by a test.
+	launchBabysitter func(loc string, info *BabysitterInfo) error
+
+	mu      sync.Mutex                          // guards following structures, but not contents
+	groups  map[string]*group                   // groups, by group name
+	proxies map[string]*proxyInfo               // proxies, by listener name
+	metrics map[groupReplicaInfo]replicaMetrics // latest metrics, by group name and replica id
 }
 
 type group struct {
@@ -118,15 +198,49 @@ type group struct {
 
 	mu        sync.Mutex                                           // guards the following
 	started   bool                                                 // has this group been started?
+	runMain   bool                                                 // do the replicas run main?
 	addresses map[string]bool                                      // weavelet addresses
+	regions   map[string]string                                    // weavelet regions, by address
 	routings  map[string]*versioned.Versioned[*protos.RoutingInfo] // routing info, by component
 	pids      []int64                                              // weavelet pids
+
+	// The fields below are only used by groups whose replicas are started
+	// over ssh.
+	replicaIds []int32                      // ids of the running replicas, in start order
+	nextId     int32                        // id of the next replica
+	registered map[int32]*ReplicaToRegister // registered replicas, by replica id
+	stopping   map[int32]bool               // replicas removed by the autoscaler or a restart
+	backends   map[int32][]backend          // proxy backends, by replica id
+	locs       map[int32]string             // locations of the replicas, by replica id
+	healthy    map[int32]bool               // replicas that reported themselves healthy
+
+	// The fields below are used to stop routing traffic to registered
+	// replicas that stop, like the replicas in the pods of a Kubernetes
+	// deployment. A pruned replica is routed to again if it reports itself
+	// healthy.
+	heartbeats map[int32]time.Time // when the replicas last registered or reported themselves healthy
+	pruned     map[int32]bool      // registered replicas not routed to
+}
+
+// A backend is the address of a listener exported by a replica, to which the
+// listener's proxy forwards traffic.
+type backend struct {
+	listener string // listener name
+	address  string // listener address
+}
+
+// replicaMetrics holds the latest metrics of a replica.
+type replicaMetrics struct {
+	time    time.Time                // when the metrics were received
+	metrics []*protos.MetricSnapshot // the metrics
 }
 
 type proxyInfo struct {
-	listener string       // listener name
-	proxy    *proxy.Proxy // the proxy
-	addr     string       // dialable address of the proxy
+	listener string             // listener name
+	proxy    *proxy.Proxy       // the proxy
+	addr     string             // dialable address of the proxy
+	ctx      context.Context    // canceled when the proxy stops serving
+	stop     context.CancelFunc // stops serving the proxy
 }
 
 type groupReplicaInfo struct {
@@ -136,14 +250,103 @@ type groupReplicaInfo struct {
 
 var _ status.Server = &manager{}
 
+// ManagerOptions configure a manager. The zero value of every field other
+// than Locations selects the behavior of the ssh deployer.
+type ManagerOptions struct {
+	// Locations are the addresses of the machines on which the ssh deployer
+	// starts babysitters. Every colocation group has one replica per
+	// location. Locations is ignored if StartGroup is not nil.
+	Locations []string
+
+	// Pins, if not nil, maps a component to the locations that the replicas
+	// of its colocation group are restricted to. A pinned group has one
+	// replica per location it is restricted to. See PinnedLocations.
+	Pins map[string][]string
+
+	// Regions, if not nil, maps every location to the region it is in. The
+	// replicas of a deployment that spans regions prefer to call the
+	// replicas in their own region, and fail over to other regions.
+	Regions map[string]string
+
+	// StartGroup, if not nil, starts the replicas of the colocation group
+	// described by info, instead of starting them over ssh. StartGroup is
+	// called once per colocation group.
+	StartGroup func(info *BabysitterInfo) error
+
+	// ListenAddr is the address the manager listens on. If empty, the
+	// manager listens on a random port on the local hostname.
+	ListenAddr string
+
+	// Addr, if not empty, is the URL babysitters use to reach the manager.
+	// If empty, the address of the manager's listener is used.
+	Addr string
+
+	// Listeners, if not nil, maps a listener name to the address its proxy
+	// listens on. Listeners missing from the map are proxied on the local
+	// address requested by the application.
+	Listeners map[string]string
+
+	// LogSaver, if not nil, processes the log entries of the deployment,
+	// instead of storing them in the log directory. It is called
+	// concurrently from multiple goroutines, so it should be thread safe.
+	LogSaver func(*protos.LogEntry)
+
+	// NoRegistry, if true, prevents the manager from registering the
+	// deployment in the local registry and from storing traces locally.
+	// It is set when the manager does not run on the deploying machine.
+	NoRegistry bool
+
+	// Registry, if not nil, returns the registry in which the deployment is
+	// registered. Defaults to DefaultRegistry.
+	Registry func(context.Context) (*status.Registry, error)
+
+	// EncryptionKey, if not nil, encrypts the log files, traces, and
+	// registration that the manager persists. The babysitters then send
+	// their logs to the manager, rather than store them on their machines.
+	EncryptionKey *atrest.Key
+
+	// ListenerProxied, if not nil, is called with the name of a listener and
+	// the address of its proxy whenever the proxy starts serving at a new
+	// address. It is called with the manager's lock held, so it should not
+	// block.
+	ListenerProxied func(listener, addr string)
+
+	// RolloutOver, if not nil, is the running deployment of the application
+	// over which the deployment is rolled out as a new version. If
+	// RolloutTraffic is negative, traffic shifts gradually to the new
+	// version over the rollout duration of the config. Otherwise,
+	// RolloutTraffic percent of the traffic is sent to the new version, and
+	// the rollout is driven by the Rollout method of the status server. If
+	// RolloutMirror is positive, RolloutMirror percent of the traffic is
+	// mirrored to the new version, and the rollout is also driven by the
+	// Rollout method of the status server.
+	RolloutOver    *status.Registration
+	RolloutTraffic float64
+	RolloutMirror  float64
+
+	// Schema, if not nil, describes the components of the deployment. It is
+	// reported by the status server, to check the compatibility of new
+	// versions rolled out over the deployment.
+	Schema *status.Schema
+
+	// Retire, if not nil, is called when the deployment is retired by the
+	// rollout of a new version, or rolled back. It should shut the
+	// deployment down. If nil, new versions cannot be rolled out over the
+	// deployment.
+	Retire func()
+}
+
 // RunManager creates and runs a new manager.
-func RunManager(ctx context.Context, dep *protos.Deployment, locations []string, logDir string) (func() error, error) {
+func RunManager(ctx context.Context, dep *protos.Deployment, logDir string, opts ManagerOptions) (func() error, error) {
 	// Create log saver.
-	fs, err := logging.NewFileStore(logDir)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create log storage: %w", err)
+	logSaver := opts.LogSaver
+	if logSaver == nil {
+		fs, err := logging.NewEncryptedFileStore(logDir, opts.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create log storage: %w", err)
+		}
+		logSaver = fs.Add
 	}
-	logSaver := fs.Add
 
 	logger := slog.New(&logging.LogHandler{
 		Opts: logging.Options{
@@ -156,16 +359,19 @@ func RunManager(ctx context.Context, dep *protos.Deployment, locations []string,
 	})
 
 	// Create the trace saver.
-	traceDB, err := perfetto.Open(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open Perfetto database: %w", err)
-	}
-	traceSaver := func(spans *protos.TraceSpans) error {
-		var traces []trace.ReadOnlySpan
-		for _, span := range spans.Span {
-			traces = append(traces, &traceio.ReadSpan{Span: span})
+	var traceSaver func(spans *protos.TraceSpans) error
+	if !opts.NoRegistry {
+		traceDB, err := perfetto.OpenEncrypted(ctx, opts.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open Perfetto database: %w", err)
+		}
+		traceSaver = func(spans *protos.TraceSpans) error {
+			var traces []trace.ReadOnlySpan
+			for _, span := range spans.Span {
+				traces = append(traces, &traceio.ReadSpan{Span: span})
+			}
+			return traceDB.Store(ctx, dep.App.Name, dep.Id, traces)
 		}
-		return traceDB.Store(ctx, dep.App.Name, dep.Id, traces)
 	}
 
 	// Form co-location.
@@ -176,21 +382,79 @@ func RunManager(ctx context.Context, dep *protos.Deployment, locations []string,
 		}
 	}
 
+	config, err := runtime.ParseWeaveletConfig(dep.App.Sections)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := mtls.NewCA(dep.Id, mtls.DefaultCertLifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assign autoscaling policies to colocation groups. Groups are only
+	// autoscaled if their replicas are started over ssh.
+	groupName := func(component string) string {
+		if name, ok := colocation[component]; ok {
+			return name
+		}
+		return component
+	}
+	autoscalers := map[string]*autoscale.Autoscaler{}
+	if opts.StartGroup == nil {
+		for component, config := range config.Autoscaling {
+			autoscalers[groupName(component)] = autoscale.New(config)
+		}
+	}
+
+	// Restrict the pinned colocation groups to their locations.
+	pinned, err := PinnedLocations(dep.App, opts.Pins)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the manager.
 	m := &manager{
-		ctx:            ctx,
-		dep:            dep,
-		locations:      locations,
-		logger:         logger,
-		logDir:         logDir,
-		logSaver:       logSaver,
-		traceSaver:     traceSaver,
-		statsProcessor: imetrics.NewStatsProcessor(),
-		started:        time.Now(),
-		colocation:     colocation,
-		groups:         map[string]*group{},
-		proxies:        map[string]*proxyInfo{},
-		metrics:        map[groupReplicaInfo][]*protos.MetricSnapshot{},
+		ctx:               ctx,
+		dep:               dep,
+		opts:              opts,
+		logger:            logger,
+		logDir:            logDir,
+		logSaver:          logSaver,
+		traceSaver:        traceSaver,
+		statsProcessor:    imetrics.NewStatsProcessor(),
+		started:           time.Now(),
+		colocation:        colocation,
+		routingConfigs:    config.Routing,
+		streamIdleTimeout: config.StreamIdleTimeout,
+		listenerConfigs:   config.Listeners,
+		ca:                ca,
+		leases:            lease.NewTable(),
+		pinned:            pinned,
+		autoscalers:       autoscalers,
+		tracker:           autoscale.NewTracker(groupName),
+		groups:            map[string]*group{},
+		proxies:           map[string]*proxyInfo{},
+		metrics:           map[groupReplicaInfo]replicaMetrics{},
+	}
+	m.replicaDep.Store(dep)
+	m.launchBabysitter = m.startBabysitter
+
+	// Roll the deployment out, if requested.
+	if over := opts.RolloutOver; over != nil {
+		manual := opts.RolloutTraffic >= 0 || opts.RolloutMirror > 0
+		m.rollout = rollout.New(dep.Id, *over, status.NewClient(over.Addr), math.Max(0, opts.RolloutTraffic), opts.RolloutMirror, manual, nil, logger)
+		if !manual {
+			duration := time.Duration(dep.App.RolloutNanos)
+			if duration == 0 {
+				duration = rollout.DefaultDuration
+			}
+			go func() {
+				if err := m.rollout.Run(ctx, duration, m.takeOver); err != nil {
+					m.logger.Error("Rollout", err)
+				}
+			}()
+		}
 	}
 
 	// Run the manager.
@@ -209,7 +473,7 @@ func RunManager(ctx context.Context, dep *protos.Deployment, locations []string,
 				defer m.mu.Unlock()
 				var result []*metrics.MetricSnapshot
 				for _, ms := range m.metrics {
-					for _, m := range ms {
+					for _, m := range ms.metrics {
 						result = append(result, metrics.UnProto(m))
 					}
 				}
@@ -220,21 +484,48 @@ func RunManager(ctx context.Context, dep *protos.Deployment, locations []string,
 		}
 	}()
 
+	// Run the autoscaler.
+	if len(autoscalers) > 0 {
+		go m.autoscale()
+	}
+
+	// Stop routing traffic to the replicas started by StartGroup that die.
+	// The replicas started over ssh are stopped by the manager.
+	if opts.StartGroup != nil {
+		go m.pruneDeadReplicas()
+	}
+
 	return func() error {
+		if m.rollout != nil {
+			// Stop sending traffic to the deployment, if still rolling out.
+			if err := m.rollout.Abort(m.ctx); err != nil {
+				m.logger.Error("Unable to abort the rollout", err)
+			}
+		}
+		if m.registry == nil {
+			return nil
+		}
 		return m.registry.Unregister(m.ctx, m.dep.Id)
 	}, nil
 }
 
 func (m *manager) run() error {
-	host, err := os.Hostname()
-	if err != nil {
-		return fmt.Errorf("manager: get hostname: %v", err)
+	addr := m.opts.ListenAddr
+	if addr == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("manager: get hostname: %v", err)
+		}
+		addr = fmt.Sprintf("%s:0", host)
 	}
-	lis, err := net.Listen("tcp", fmt.Sprintf("%s:0", host))
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("listen: %w", err)
 	}
 	m.mgrAddress = fmt.Sprintf("http://%s", lis.Addr())
+	if m.opts.Addr != "" {
+		m.mgrAddress = m.opts.Addr
+	}
 
 	m.logger.Info("Manager listening", "address", m.mgrAddress)
 
@@ -248,6 +539,24 @@ func (m *manager) run() error {
 		}
 	}()
 
+	// Serve the deployment's metrics to Prometheus, if requested.
+	config, err := runtime.ParseWeaveletConfig(m.dep.App.Sections)
+	if err != nil {
+		return err
+	}
+	if addr := config.DeployerMetricsAddress; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("metrics listener: %w", err)
+		}
+		m.logger.Info("Serving metrics", "address", fmt.Sprintf("http://%s/metrics", lis.Addr()))
+		go func() {
+			if err := status.ServePrometheus(m.ctx, lis, m); err != nil {
+				m.logger.Error("Unable to serve metrics", err)
+			}
+		}()
+	}
+
 	// Start the main process.
 	if err := m.startComponent(m.ctx, &protos.ActivateComponentRequest{
 		Component: "main",
@@ -264,9 +573,16 @@ func (m *manager) run() error {
 		}
 		m.logger.Error("Error starting status server", err, "address", lis.Addr())
 	}
+	if m.opts.NoRegistry {
+		return nil
+	}
 
 	// Register the deployment.
-	registry, err := DefaultRegistry(m.ctx)
+	newRegistry := DefaultRegistry
+	if m.opts.Registry != nil {
+		newRegistry = m.opts.Registry
+	}
+	registry, err := newRegistry(m.ctx)
 	if err != nil {
 		return fmt.Errorf("create registry: %w", err)
 	}
@@ -277,7 +593,26 @@ func (m *manager) run() error {
 		Addr:         lis.Addr().String(),
 	}
 	fmt.Fprint(os.Stderr, reg.Rolodex())
-	return registry.Register(m.ctx, reg)
+	if err := registry.RegisterEncrypted(m.ctx, reg, m.opts.EncryptionKey); err != nil {
+		return err
+	}
+
+	// Delete the logs and traces of the old deployments of the app, if the
+	// config has retention policies.
+	stores := []retention.Store{retention.TraceDB(perfetto.Open)}
+	if m.opts.LogSaver == nil {
+		stores = append(stores, retention.LogFiles(m.logDir))
+	}
+	janitor := &retention.Janitor{
+		App:     m.dep.App.Name,
+		Version: m.dep.Id,
+		Config:  config.Retention,
+		Stores:  stores,
+		Logger:  m.logger,
+		Running: status.RunningDeployments,
+	}
+	go janitor.Run(m.ctx)
+	return nil
 }
 
 // addHTTPHandlers adds handlers for the HTTP endpoints exposed by the SSH manager.
@@ -290,6 +625,11 @@ func (m *manager) addHTTPHandlers(mux *http.ServeMux) {
 	mux.HandleFunc(recvLogEntryURL, protomsg.HandlerDo(m.logger, m.handleLogEntry))
 	mux.HandleFunc(recvTraceSpansURL, protomsg.HandlerDo(m.logger, m.handleTraceSpans))
 	mux.HandleFunc(recvMetricsURL, protomsg.HandlerDo(m.logger, m.handleRecvMetrics))
+	mux.HandleFunc(signCertificateURL, protomsg.HandlerFunc(m.logger, m.signCertificate))
+	mux.HandleFunc(leaseURL, protomsg.HandlerFunc(m.logger, m.lease))
+	mux.HandleFunc(replicaHealthyURL, protomsg.HandlerDo(m.logger, m.replicaHealthy))
+	mux.HandleFunc(unregisterReplicaURL, protomsg.HandlerDo(m.logger, m.unregisterReplica))
+	mux.HandleFunc(restartURL, protomsg.HandlerFunc(m.logger, m.restart))
 }
 
 // registerStatusPages registers the status pages with the provided mux.
@@ -304,12 +644,18 @@ func (m *manager) registerStatusPages(mux *http.ServeMux) {
 func (m *manager) Status(ctx context.Context) (*status.Status, error) {
 	stats := m.statsProcessor.GetStatsStatusz()
 	var components []*status.Component
+	var locations []*status.ReplicaLocation
 	for _, g := range m.allGroups() {
 		g.components.Lock()
 		cs := maps.Keys(g.components.Val)
 		g.components.Unlock()
 		g.mu.Lock()
 		pids := slices.Clone(g.pids)
+		for id, r := range g.registered {
+			if loc, ok := g.locs[id]; ok {
+				locations = append(locations, &status.ReplicaLocation{Group: g.name, Pid: r.Pid, Location: loc})
+			}
+		}
 		g.mu.Unlock()
 		for _, component := range cs {
 			c := &status.Component{
@@ -365,7 +711,10 @@ func (m *manager) Status(ctx context.Context) (*status.Status, error) {
 		SubmissionTime: timestamppb.New(m.started),
 		Components:     components,
 		Listeners:      listeners,
-		Config:         m.dep.App,
+		Config:         m.replicaDep.Load().App,
+		Rollout:        m.rolloutStatus(),
+		Schema:         m.opts.Schema,
+		Locations:      locations,
 	}, nil
 }
 
@@ -375,7 +724,7 @@ func (m *manager) Metrics(context.Context) (*status.Metrics, error) {
 	defer m.mu.Unlock()
 	ms := &status.Metrics{}
 	for _, snap := range m.metrics {
-		ms.Metrics = append(ms.Metrics, snap...)
+		ms.Metrics = append(ms.Metrics, snap.metrics...)
 	}
 	return ms, nil
 }
@@ -385,6 +734,76 @@ func (m *manager) Profile(context.Context, *protos.GetProfileRequest) (*protos.G
 	return nil, nil
 }
 
+// SetLogLevel implements the status.Server interface.
+func (m *manager) SetLogLevel(context.Context, *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	return nil, fmt.Errorf("changing log levels is not supported by the ssh deployer")
+}
+
+// Flags implements the status.Server interface.
+func (m *manager) Flags(context.Context, *status.FlagsRequest) (*status.FlagsReply, error) {
+	return nil, fmt.Errorf("feature flags are not supported by the ssh deployer")
+}
+
+// ApplyConfig implements the status.Server interface.
+func (m *manager) ApplyConfig(context.Context, *status.ApplyConfigRequest) (*status.ApplyConfigReply, error) {
+	return nil, fmt.Errorf("applying configs is not supported by the ssh deployer; use \"weaver ssh restart\"")
+}
+
+// Profiles implements the status.Server interface.
+func (m *manager) Profiles(context.Context, *status.ProfilesRequest) (*status.ProfilesReply, error) {
+	return nil, fmt.Errorf("deployment %s does not keep continuous profiles", m.dep.Id)
+}
+
+// SplitTraffic implements the status.Server interface.
+func (m *manager) SplitTraffic(_ context.Context, req *status.SplitTrafficRequest) (*status.SplitTrafficReply, error) {
+	if m.opts.Retire == nil {
+		return nil, fmt.Errorf("deployment %s does not support rollouts", m.dep.Id)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.split.Set(req)
+	for name, p := range m.proxies {
+		m.split.Apply(name, p.proxy)
+	}
+	if !req.Retire {
+		return &status.SplitTrafficReply{}, nil
+	}
+
+	// Stop serving the listeners, so that the new version can take their
+	// addresses over, and shut down.
+	m.logger.Info("Retired by the rollout of a new version", "version", req.Version)
+	for _, p := range m.proxies {
+		p.stop()
+	}
+	go m.opts.Retire()
+	return &status.SplitTrafficReply{}, nil
+}
+
+// Rollout implements the status.Server interface.
+func (m *manager) Rollout(ctx context.Context, req *status.RolloutRequest) (*status.RolloutReply, error) {
+	if m.rollout == nil {
+		return nil, fmt.Errorf("deployment %s is not being rolled out", m.dep.Id)
+	}
+	if err := m.rollout.Handle(ctx, req, m.takeOver); err != nil {
+		return nil, err
+	}
+	if req.Rollback && m.opts.Retire != nil {
+		go m.opts.Retire()
+	}
+	return &status.RolloutReply{}, nil
+}
+
+// rolloutStatus returns the status of the rollout of the deployment, or of a
+// new version over the deployment, if any.
+func (m *manager) rolloutStatus() *status.Rollout {
+	if m.rollout != nil {
+		if s := m.rollout.Status(); s != nil {
+			return s
+		}
+	}
+	return m.split.Status(m.dep.Id)
+}
+
 // group returns the named co-location group.
 //
 // REQUIRES: m.mu is not held.
@@ -402,8 +821,16 @@ func (m *manager) group(component string) *group {
 		g = &group{
 			name:       name,
 			addresses:  map[string]bool{},
+			regions:    map[string]string{},
 			components: versioned.Version(map[string]bool{}),
 			routings:   map[string]*versioned.Versioned[*protos.RoutingInfo]{},
+			registered: map[int32]*ReplicaToRegister{},
+			stopping:   map[int32]bool{},
+			backends:   map[int32][]backend{},
+			locs:       map[int32]string{},
+			healthy:    map[int32]bool{},
+			heartbeats: map[int32]time.Time{},
+			pruned:     map[int32]bool{},
 		}
 		m.groups[name] = g
 	}
@@ -419,6 +846,17 @@ func (g *group) allAddresses() []string {
 	return maps.Keys(g.addresses) // creates a new slice.
 }
 
+// allRegions returns the regions of the weavelets, by address, or nil if
+// the weavelets don't run in regions.
+func (g *group) allRegions() map[string]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.regions) == 0 {
+		return nil
+	}
+	return maps.Clone(g.regions)
+}
+
 // routing returns the RoutingInfo for the provided component.
 //
 // REQUIRES: g.mu is NOT held.
@@ -444,47 +882,114 @@ func (m *manager) getComponentsToStart(_ context.Context, req *GetComponentsRequ
 	// TODO(mwhittaker): Right now, this code assumes a group is named after
 	// its first component. Update the code to not depend on that assumption.
 	g := m.group(req.Group)
+	if g.isStopping(req.ReplicaId) {
+		return &GetComponentsReply{Stop: true}, nil
+	}
 	version := g.components.RLock(req.Version)
 	defer g.components.RUnlock()
 	return &GetComponentsReply{
 		Components: maps.Keys(g.components.Val),
 		Version:    version,
+		Stop:       g.isStopping(req.ReplicaId),
 	}, nil
 }
 
+// isStopping returns whether the replica with the provided id was removed by
+// the autoscaler or a restart.
+//
+// REQUIRES: g.mu is NOT held.
+func (g *group) isStopping(replicaId int32) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stopping[replicaId]
+}
+
 func (m *manager) registerReplica(_ context.Context, req *ReplicaToRegister) error {
 	g := m.group(req.Group)
 
 	// Update addresses and pids.
+	var backends []backend
 	record := func() bool {
 		g.mu.Lock()
 		defer g.mu.Unlock()
-		if g.addresses[req.Address] {
-			// Replica already registered.
+		if g.addresses[req.Address] || g.stopping[req.ReplicaId] {
+			// Replica already registered, or removed by the autoscaler.
 			return true
 		}
+		if old, ok := g.registered[req.ReplicaId]; ok {
+			// The replica was restarted in place, like a container
+			// restarted in its pod. Forget the stopped weavelet.
+			delete(g.addresses, old.Address)
+			delete(g.regions, old.Address)
+			if i := slices.Index(g.pids, old.Pid); i >= 0 {
+				g.pids = slices.Delete(g.pids, i, i+1)
+			}
+			if !g.pruned[req.ReplicaId] {
+				backends = g.backends[req.ReplicaId]
+			}
+			delete(g.backends, req.ReplicaId)
+			delete(g.pruned, req.ReplicaId)
+		}
 		g.addresses[req.Address] = true
+		if req.Region != "" {
+			g.regions[req.Address] = req.Region
+		}
 		g.pids = append(g.pids, req.Pid)
+		g.registered[req.ReplicaId] = req
+		g.heartbeats[req.ReplicaId] = time.Now()
 		return false
 	}
 	if record() {
 		return nil
 	}
+	m.updateRouting(g)
+	m.removeBackends(backends)
+	return nil
+}
+
+// updateRouting updates the routing info of the group's components to match
+// the group's replicas.
+//
+// REQUIRES: g.mu is NOT held.
+func (m *manager) updateRouting(g *group) {
+	g.mu.Lock()
+	replicas := maps.Keys(g.addresses)
+	routings := maps.Clone(g.routings)
+	g.mu.Unlock()
+	regions := g.allRegions()
 
-	// Update routing.
-	replicas := g.allAddresses()
-	for _, routing := range g.routings {
+	for component, routing := range routings {
 		routing.Lock()
 		routing.Val.Replicas = replicas
+		routing.Val.Regions = regions
 		if routing.Val.Assignment != nil {
-			routing.Val.Assignment = routingAlgo(routing.Val.Assignment, replicas)
+			routing.Val.Assignment = routingAlgo(m.routingConfigs[component], routing.Val.Assignment, replicas)
 		}
 		routing.Unlock()
 	}
-	return nil
 }
 
-func (m *manager) exportListener(_ context.Context, req *protos.ExportListenerRequest) (*protos.ExportListenerReply, error) {
+func (m *manager) signCertificate(_ context.Context, req *SignCertificateRequest) (*protos.GetCertificateReply, error) {
+	// TODO(mwhittaker): Authenticate babysitters. For now, anyone who can
+	// reach the manager can get a certificate signed.
+	return m.ca.Sign(req.WeaveletId, req.PublicKey)
+}
+
+func (m *manager) lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	// Leases held by replicas that fail are not released; they expire.
+	return m.leases.Handle(time.Now(), req), nil
+}
+
+func (m *manager) exportListener(_ context.Context, export *ListenerToExport) (*protos.ExportListenerReply, error) {
+	req := export.Request
+
+	// Record the backend, so that it can be removed if the replica is
+	// removed by the autoscaler.
+	g := m.group(export.Group)
+	g.mu.Lock()
+	g.backends[export.ReplicaId] = append(g.backends[export.ReplicaId], backend{listener: req.Listener, address: req.Address})
+	g.mu.Unlock()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -494,8 +999,18 @@ func (m *manager) exportListener(_ context.Context, req *protos.ExportListenerRe
 		return &protos.ExportListenerReply{ProxyAddress: p.addr}, nil
 	}
 
-	lis, err := net.Listen("tcp", req.LocalAddress)
-	if errors.Is(err, syscall.EADDRINUSE) {
+	localAddr := req.LocalAddress
+	if addr, ok := m.opts.Listeners[req.Listener]; ok {
+		localAddr = addr
+	}
+	var lis net.Listener
+	var err error
+	if m.rollout != nil {
+		lis, err = m.rollout.Listen(req.Listener, localAddr)
+	} else {
+		lis, err = listeners.Listen(localAddr)
+	}
+	if listeners.IsAddrInUse(err) {
 		// Don't retry if the address is already in use.
 		return &protos.ExportListenerReply{Error: err.Error()}, nil
 	}
@@ -504,19 +1019,65 @@ func (m *manager) exportListener(_ context.Context, req *protos.ExportListenerRe
 	}
 	addr := lis.Addr().String()
 	m.logger.Info("Proxy listening", "address", addr)
-	proxy := proxy.NewProxy(m.logger)
+	newProxy := proxy.NewProxy
+	if req.Tls {
+		newProxy = proxy.NewTLSProxy
+	}
+	affinity := proxy.ListenerAffinity(m.listenerConfigs[req.Listener])
+	proxy := newProxy(m.logger)
+	proxy.SetStreamIdleTimeout(m.streamIdleTimeout)
+	proxy.SetSessionAffinity(affinity)
 	proxy.AddBackend(req.Address)
-	m.proxies[req.Listener] = &proxyInfo{
+	m.split.Apply(req.Listener, proxy)
+	ctx, stop := context.WithCancel(m.ctx)
+	info := &proxyInfo{
 		listener: req.Listener,
 		proxy:    proxy,
 		addr:     addr,
+		ctx:      ctx,
+		stop:     stop,
+	}
+	m.proxies[req.Listener] = info
+	m.serveProxy(info, lis)
+	if m.opts.ListenerProxied != nil {
+		m.opts.ListenerProxied(req.Listener, addr)
 	}
+	if m.rollout != nil {
+		if err := m.rollout.AddProxy(m.ctx, req.Listener, addr); err != nil {
+			m.logger.Error("Cannot send traffic to the new version", err, "listener", req.Listener)
+		}
+	}
+	return &protos.ExportListenerReply{ProxyAddress: addr}, nil
+}
+
+// serveProxy serves the provided proxy on the provided listener, until the
+// proxy is stopped. The streams forwarded by the proxy are then closed.
+func (m *manager) serveProxy(p *proxyInfo, lis net.Listener) {
 	go func() {
-		if err := serveHTTP(m.ctx, lis, proxy); err != nil {
+		if err := serveHTTP(p.ctx, lis, p.proxy); err != nil && p.ctx.Err() == nil {
 			m.logger.Error("Proxy", err)
 		}
+		if p.ctx.Err() != nil {
+			p.proxy.CloseStreams()
+		}
 	}()
-	return &protos.ExportListenerReply{ProxyAddress: addr}, nil
+}
+
+// takeOver serves the proxy of the provided listener on the provided
+// listener, taken over from the running version once a rollout is promoted.
+func (m *manager) takeOver(listener string, lis net.Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.proxies[listener]
+	if !ok {
+		lis.Close()
+		return
+	}
+	p.addr = lis.Addr().String()
+	m.serveProxy(p, lis)
+	if m.opts.ListenerProxied != nil {
+		m.opts.ListenerProxied(listener, p.addr)
+	}
 }
 
 func (m *manager) startComponent(ctx context.Context, req *protos.ActivateComponentRequest) error {
@@ -540,13 +1101,15 @@ func (m *manager) startComponent(ctx context.Context, req *protos.ActivateCompon
 	// Update the routing info.
 	routing := g.routing(req.Component)
 	addresses := g.allAddresses()
+	regions := g.allRegions()
 	update := func() {
 		routing.Lock()
 		defer routing.Unlock()
 
 		routing.Val.Replicas = addresses
+		routing.Val.Regions = regions
 		if req.Routed {
-			routing.Val.Assignment = routingAlgo(&protos.Assignment{}, routing.Val.Replicas)
+			routing.Val.Assignment = routingAlgo(m.routingConfigs[req.Component], &protos.Assignment{}, routing.Val.Replicas)
 		}
 	}
 	update()
@@ -565,24 +1128,489 @@ func (m *manager) startColocationGroup(g *group, runMain bool) error {
 	}
 	g.started = true
 
-	// Start the colocation group. Right now, the number of replicas for each
-	// colocation group is equal to the number of locations.
-	//
-	// TODO(rgrandl): Implement some smarter logic to determine the number of
-	// replicas for each group.
-	for replicaId, loc := range m.locations {
+	if m.opts.StartGroup != nil {
 		info := &BabysitterInfo{
 			ManagerAddr: m.mgrAddress,
 			Deployment:  m.dep,
 			Group:       g.name,
-			ReplicaId:   int32(replicaId),
-			LogDir:      m.logDir,
 			RunMain:     runMain,
 		}
-		if err := m.startBabysitter(loc, info); err != nil {
-			return fmt.Errorf("unable to start babysitter for group %s at location %s: %w\n", g.name, loc, err)
+		if err := m.opts.StartGroup(info); err != nil {
+			return fmt.Errorf("unable to start colocation group %s: %w", g.name, err)
+		}
+		m.logger.Info("Started colocation group", "colocation group", g.name)
+		return nil
+	}
+
+	// Start the colocation group. Unless the group is autoscaled, the number
+	// of replicas for each colocation group is equal to the number of
+	// locations it may run at.
+	g.runMain = runMain
+	n := len(m.locations(g))
+	if a, ok := m.autoscalers[g.name]; ok {
+		n = a.Config().MinReplicas
+	}
+	for i := 0; i < n; i++ {
+		if err := m.startReplica(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locations returns the locations that the replicas of the provided group
+// may run at.
+func (m *manager) locations(g *group) []string {
+	if locs, ok := m.pinned[g.name]; ok {
+		return locs
+	}
+	return m.opts.Locations
+}
+
+// startReplica starts a new replica of the provided group. Replicas are
+// spread across the locations of the group in a round-robin fashion.
+//
+// REQUIRES: g.mu is held.
+func (m *manager) startReplica(g *group) error {
+	locs := m.locations(g)
+	_, err := m.startReplicaAt(g, locs[int(g.nextId)%len(locs)])
+	return err
+}
+
+// startReplicaAt starts a new replica of the provided group at the provided
+// location, and returns its id.
+//
+// REQUIRES: g.mu is held.
+func (m *manager) startReplicaAt(g *group, loc string) (int32, error) {
+	replicaId := g.nextId
+	info := &BabysitterInfo{
+		ManagerAddr: m.mgrAddress,
+		Deployment:  m.replicaDep.Load(),
+		Group:       g.name,
+		ReplicaId:   replicaId,
+		LogDir:      m.logDir,
+		RunMain:     g.runMain,
+		Region:      m.opts.Regions[loc],
+	}
+	if m.opts.EncryptionKey != nil {
+		// Keep the logs off the babysitter's machine. They are sent to the
+		// manager, which encrypts them.
+		info.LogDir = ""
+	}
+	if err := m.launchBabysitter(loc, info); err != nil {
+		return 0, fmt.Errorf("unable to start babysitter for group %s at location %s: %w\n", g.name, loc, err)
+	}
+	g.nextId++
+	g.replicaIds = append(g.replicaIds, replicaId)
+	g.locs[replicaId] = loc
+	m.logger.Info("Started babysitter", "location", loc, "colocation group", g.name)
+	return replicaId, nil
+}
+
+// autoscale periodically rescales the colocation groups that have an
+// autoscaling policy, until the manager is stopped.
+func (m *manager) autoscale() {
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if m.restarting.Load() {
+				// Don't add or remove replicas while the deployment
+				// restarts.
+				continue
+			}
+			m.rescale(m.tracker.Update(m.replicaMetrics()))
+		}
+	}
+}
+
+// replicaMetrics returns the latest metrics of the replicas, keyed by group
+// name and replica id.
+func (m *manager) replicaMetrics() map[string]autoscale.Weavelet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	weavelets := map[string]autoscale.Weavelet{}
+	for info, ms := range m.metrics {
+		w := autoscale.Weavelet{Group: info.name, Time: ms.time}
+		for _, snap := range ms.metrics {
+			w.Metrics = append(w.Metrics, metrics.UnProto(snap))
+		}
+		weavelets[fmt.Sprintf("%s/%d", info.name, info.id)] = w
+	}
+	return weavelets
+}
+
+// rescale rescales the autoscaled colocation groups based on their load.
+func (m *manager) rescale(signals map[string]autoscale.Signals) {
+	now := time.Now()
+	for name, a := range m.autoscalers {
+		m.mu.Lock()
+		g, ok := m.groups[name]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+		s, ok := signals[name]
+		if !ok {
+			continue
+		}
+
+		g.mu.Lock()
+		if !g.started {
+			g.mu.Unlock()
+			continue
+		}
+		current := len(g.replicaIds)
+		replicas := a.Recommend(now, current, s)
+		if replicas == current {
+			g.mu.Unlock()
+			continue
+		}
+		m.logger.Info("Autoscaling", "colocation group", name, "from", current, "to", replicas,
+			"rate", s.Rate, "p99", s.P99, "cpu", s.CPU)
+		var err error
+		var removed []int32
+		if replicas > current {
+			for i := current; i < replicas && err == nil; i++ {
+				err = m.startReplica(g)
+			}
+		} else {
+			removed = g.replicaIds[replicas:]
+			g.replicaIds = slices.Clone(g.replicaIds[:replicas])
+		}
+		g.mu.Unlock()
+		if err != nil {
+			m.logger.Error("cannot autoscale", err, "colocation group", name)
+		}
+		if len(removed) > 0 {
+			m.removeReplicas(g, removed)
+		}
+	}
+}
+
+// removeReplicas removes the replicas with the provided ids from the provided
+// group. The replicas stop receiving traffic right away, and their
+// babysitters are told to drain their weavelets and exit. The caller must
+// have removed the ids from g.replicaIds.
+//
+// REQUIRES: g.mu is NOT held.
+func (m *manager) removeReplicas(g *group, ids []int32) {
+	var backends []backend
+	g.mu.Lock()
+	for _, id := range ids {
+		g.stopping[id] = true
+		delete(g.locs, id)
+		delete(g.healthy, id)
+		delete(g.heartbeats, id)
+		if !g.pruned[id] {
+			backends = append(backends, g.backends[id]...)
+		}
+		delete(g.pruned, id)
+		if r, ok := g.registered[id]; ok {
+			delete(g.addresses, r.Address)
+			delete(g.regions, r.Address)
+			if i := slices.Index(g.pids, r.Pid); i >= 0 {
+				g.pids = slices.Delete(g.pids, i, i+1)
+			}
+			delete(g.registered, id)
+		}
+		delete(g.backends, id)
+	}
+	g.mu.Unlock()
+
+	// Stop routing traffic to the replicas.
+	m.updateRouting(g)
+	m.removeBackends(backends)
+	m.mu.Lock()
+	for _, id := range ids {
+		delete(m.metrics, groupReplicaInfo{name: g.name, id: id})
+	}
+	m.mu.Unlock()
+
+	// Wake up the babysitters watching the components to start, so that
+	// they learn that they should stop.
+	g.components.Lock()
+	g.components.Unlock() //nolint:staticcheck // an empty critical section bumps the version
+}
+
+// removeBackends stops forwarding traffic to the provided proxy backends.
+//
+// REQUIRES: m.mu is NOT held.
+func (m *manager) removeBackends(backends []backend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range backends {
+		if p, ok := m.proxies[b.listener]; ok {
+			p.proxy.RemoveBackend(b.address)
+		}
+	}
+}
+
+// replicaHealthy records that a replica is healthy. Babysitters keep
+// reporting that their replicas are healthy every heartbeatInterval, and a
+// replica that was pruned for missing its heartbeats is routed to again.
+func (m *manager) replicaHealthy(_ context.Context, req *ReplicaHealthy) error {
+	g := m.group(req.Group)
+	g.mu.Lock()
+	if g.stopping[req.ReplicaId] {
+		g.mu.Unlock()
+		return nil
+	}
+	g.healthy[req.ReplicaId] = true
+	g.heartbeats[req.ReplicaId] = time.Now()
+	r, ok := g.registered[req.ReplicaId]
+	if !ok || !g.pruned[req.ReplicaId] {
+		g.mu.Unlock()
+		return nil
+	}
+	delete(g.pruned, req.ReplicaId)
+	g.addresses[r.Address] = true
+	if r.Region != "" {
+		g.regions[r.Address] = r.Region
+	}
+	backends := slices.Clone(g.backends[req.ReplicaId])
+	g.mu.Unlock()
+
+	m.logger.Info("Routing to replica again", "colocation group", g.name, "replica", req.ReplicaId)
+	m.updateRouting(g)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range backends {
+		if p, ok := m.proxies[b.listener]; ok {
+			p.proxy.AddBackend(b.address)
+		}
+	}
+	return nil
+}
+
+// unregisterReplica stops routing traffic to a replica whose babysitter
+// stopped, like when its pod is deleted, instead of waiting for the replica
+// to miss its heartbeats.
+func (m *manager) unregisterReplica(_ context.Context, req *ReplicaToRegister) error {
+	g := m.group(req.Group)
+	m.pruneReplicas(g, func(id int32, r *ReplicaToRegister) bool {
+		// A replica restarted in place reuses the replica id, but not the
+		// address.
+		return id == req.ReplicaId && r.Address == req.Address
+	})
+	return nil
+}
+
+// pruneDeadReplicas periodically stops routing traffic to the replicas that
+// miss their heartbeats, until the manager is stopped.
+func (m *manager) pruneDeadReplicas() {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.pruneStaleReplicas(now)
+		}
+	}
+}
+
+// pruneStaleReplicas stops routing traffic to the replicas that haven't
+// registered or reported themselves healthy in the heartbeatTimeout before
+// now.
+func (m *manager) pruneStaleReplicas(now time.Time) {
+	for _, g := range m.allGroups() {
+		m.pruneReplicas(g, func(id int32, _ *ReplicaToRegister) bool {
+			return now.Sub(g.heartbeats[id]) > heartbeatTimeout
+		})
+	}
+}
+
+// pruneReplicas stops routing traffic to the registered replicas of g for
+// which prune returns true. Unlike removeReplicas, the replicas are not told
+// to stop, and are routed to again if they report themselves healthy.
+//
+// REQUIRES: g.mu is NOT held. prune is called with g.mu held.
+func (m *manager) pruneReplicas(g *group, prune func(id int32, r *ReplicaToRegister) bool) {
+	var pruned []int32
+	var backends []backend
+	g.mu.Lock()
+	for id, r := range g.registered {
+		if g.pruned[id] || !prune(id, r) {
+			continue
+		}
+		g.pruned[id] = true
+		delete(g.addresses, r.Address)
+		delete(g.regions, r.Address)
+		pruned = append(pruned, id)
+		backends = append(backends, g.backends[id]...)
+	}
+	g.mu.Unlock()
+	if len(pruned) == 0 {
+		return
+	}
+
+	m.logger.Info("Not routing to stopped replicas", "colocation group", g.name, "replicas", pruned)
+	m.updateRouting(g)
+	m.removeBackends(backends)
+}
+
+// restart restarts the replicas of the deployment, one location at a time,
+// in the order of the locations file. At every location, new replicas are
+// started in place of the replicas running there, and the running replicas
+// are drained and stopped once the new ones are healthy. If the new replicas
+// aren't healthy within the startup timeout, they are stopped instead, and
+// the restart fails, leaving the remaining locations untouched. Replicas
+// started after a failed restart, e.g., by the autoscaler, run the
+// deployment that ran before the restart.
+func (m *manager) restart(ctx context.Context, req *RestartRequest) (*RestartReply, error) {
+	if m.opts.StartGroup != nil {
+		return nil, fmt.Errorf("deployment %s does not support restarts", m.dep.Id)
+	}
+	if !m.restarting.CompareAndSwap(false, true) {
+		return nil, fmt.Errorf("deployment %s is already restarting", m.dep.Id)
+	}
+	defer m.restarting.Store(false)
+
+	previous := m.replicaDep.Load()
+	if req.Config != nil {
+		dep, err := m.restartDeployment(req.Config)
+		if err != nil {
+			return nil, err
+		}
+		m.replicaDep.Store(dep)
+	}
+	config, err := runtime.ParseWeaveletConfig(m.replicaDep.Load().App.Sections)
+	if err != nil {
+		m.replicaDep.Store(previous)
+		return nil, err
+	}
+
+	m.logger.Info("Restarting", "locations", len(m.opts.Locations), "new config", req.Config != nil)
+	for _, loc := range m.opts.Locations {
+		if err := m.restartLocation(ctx, loc, config.StartupTimeout); err != nil {
+			m.replicaDep.Store(previous)
+			m.logger.Error("Restart failed", err, "location", loc)
+			return nil, fmt.Errorf("restart replicas at %s: %w", loc, err)
+		}
+	}
+	m.logger.Info("Restarted")
+	return &RestartReply{}, nil
+}
+
+// restartDeployment returns the deployment to restart with the provided
+// config, checking that only the config sections of the application change.
+func (m *manager) restartDeployment(app *protos.AppConfig) (*protos.Deployment, error) {
+	current := m.replicaDep.Load()
+	if app.Name != current.App.Name {
+		return nil, fmt.Errorf("cannot restart app %q with the config of app %q", current.App.Name, app.Name)
+	}
+	colocate := func(app *protos.AppConfig) [][]string {
+		var groups [][]string
+		for _, g := range app.Colocate {
+			groups = append(groups, g.Components)
+		}
+		return groups
+	}
+	if !slices.EqualFunc(colocate(app), colocate(current.App), slices.Equal[string]) {
+		return nil, fmt.Errorf("cannot change the colocation groups of a running deployment; roll out a new deployment instead")
+	}
+	if _, err := runtime.ParseWeaveletConfig(app.Sections); err != nil {
+		return nil, err
+	}
+	app = protomsg.Clone(app)
+	app.Binary = current.App.Binary // the binary copied to every location
+	return &protos.Deployment{Id: current.Id, App: app, SingleProcess: current.SingleProcess}, nil
+}
+
+// restartLocation restarts the replicas of every colocation group at the
+// provided location, gated on the health of the new replicas.
+func (m *manager) restartLocation(ctx context.Context, loc string, startupTimeout time.Duration) error {
+	// Start the new replicas.
+	started := map[*group][]int32{}
+	replaced := map[*group][]int32{}
+	var err error
+	for _, g := range m.allGroups() {
+		g.mu.Lock()
+		if g.started {
+			for _, id := range slices.Clone(g.replicaIds) {
+				if g.locs[id] != loc {
+					continue
+				}
+				replaced[g] = append(replaced[g], id)
+				var newId int32
+				if newId, err = m.startReplicaAt(g, loc); err != nil {
+					break
+				}
+				started[g] = append(started[g], newId)
+			}
+		}
+		g.mu.Unlock()
+		if err != nil {
+			break
+		}
+	}
+	if len(replaced) == 0 {
+		return err
+	}
+	m.logger.Info("Restarting replicas", "location", loc)
+
+	// Wait for the new replicas to be healthy.
+	if err == nil {
+		err = m.awaitHealthy(ctx, started, startupTimeout)
+	}
+
+	// If they aren't, stop them. Otherwise, stop the replicas they replace.
+	stop := replaced
+	if err != nil {
+		stop = started
+	}
+	for g, ids := range stop {
+		g.mu.Lock()
+		var remaining []int32
+		for _, id := range g.replicaIds {
+			if !slices.Contains(ids, id) {
+				remaining = append(remaining, id)
+			}
+		}
+		g.replicaIds = remaining
+		g.mu.Unlock()
+		m.removeReplicas(g, ids)
+	}
+	return err
+}
+
+// awaitHealthy waits for the provided replicas to report themselves healthy.
+// It returns an error if they aren't healthy within the provided timeout.
+func (m *manager) awaitHealthy(ctx context.Context, replicas map[*group][]int32, timeout time.Duration) error {
+	healthy := func() bool {
+		for g, ids := range replicas {
+			g.mu.Lock()
+			ok := true
+			for _, id := range ids {
+				ok = ok && g.healthy[id]
+			}
+			g.mu.Unlock()
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for !healthy() {
+		select {
+		case <-ctx.Done():
+			if m.ctx.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("new replicas not healthy after %v", timeout)
+			}
+			return ctx.Err()
+		case <-m.ctx.Done():
+			return m.ctx.Err()
+		case <-ticker.C:
 		}
-		m.logger.Info("Started babysitter", "location", loc, "colocation group", g.name)
 	}
 	return nil
 }
@@ -600,25 +1628,53 @@ func (m *manager) handleTraceSpans(_ context.Context, spans *protos.TraceSpans)
 }
 
 func (m *manager) handleRecvMetrics(_ context.Context, metrics *BabysitterMetrics) error {
+	if m.group(metrics.GroupName).isStopping(metrics.ReplicaId) {
+		// Ignore the metrics of replicas removed by the autoscaler.
+		return nil
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.metrics[groupReplicaInfo{name: metrics.GroupName, id: metrics.ReplicaId}] = metrics.Metrics
+	m.metrics[groupReplicaInfo{name: metrics.GroupName, id: metrics.ReplicaId}] = replicaMetrics{
+		time:    time.Now(),
+		metrics: metrics.Metrics,
+	}
 	return nil
 }
 
 // startBabysitter starts a new babysitter that manages a colocation group using SSH.
 func (m *manager) startBabysitter(loc string, info *BabysitterInfo) error {
-	input, err := proto.ToEnv(info)
+	env, err := BabysitterEnv(info)
 	if err != nil {
 		return err
 	}
-
-	env := fmt.Sprintf("%s=%s", babysitterInfoKey, input)
 	binaryPath := filepath.Join(os.TempDir(), m.dep.Id, "weaver")
 	cmd := exec.Command("ssh", loc, env, binaryPath, "ssh", "babysitter")
 	return cmd.Start()
 }
 
+// BabysitterEnv returns the "KEY=VALUE" environment variable that passes info
+// to a babysitter.
+func BabysitterEnv(info *BabysitterInfo) (string, error) {
+	input, err := proto.ToEnv(info)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s=%s", BabysitterInfoKey, input), nil
+}
+
+// Restart asks the manager at the provided address to restart the replicas
+// of its deployment, one location at a time, and waits for the restart to
+// finish.
+func Restart(ctx context.Context, addr string, req *RestartRequest) error {
+	return protomsg.Call(ctx, protomsg.CallArgs{
+		Client:  http.DefaultClient,
+		Addr:    addr,
+		URLPath: restartURL,
+		Request: req,
+		Reply:   &RestartReply{},
+	})
+}
+
 func (m *manager) getRoutingInfo(_ context.Context, req *GetRoutingInfoRequest) (*GetRoutingInfoReply, error) {
 	g := m.group(req.RequestingGroup)
 	target := m.group(req.Component)
@@ -642,8 +1698,14 @@ func (m *manager) getRoutingInfo(_ context.Context, req *GetRoutingInfoRequest)
 	}, nil
 }
 
-func routingAlgo(currAssignment *protos.Assignment, candidates []string) *protos.Assignment {
-	assignment := routing.EqualSlices(candidates)
+func routingAlgo(config runtime.RoutingConfig, currAssignment *protos.Assignment, candidates []string) *protos.Assignment {
+	var assignment *protos.Assignment
+	switch config.Strategy {
+	case "consistent_hash":
+		assignment = routing.ConsistentHash(candidates, config.Replication, config.LoadFactor)
+	default:
+		assignment = routing.EqualSlices(candidates)
+	}
 	assignment.Version = currAssignment.Version + 1
 	return assignment
 }
@@ -662,13 +1724,21 @@ func serveHTTP(ctx context.Context, lis net.Listener, handler http.Handler) erro
 	}
 }
 
-// DefaultRegistry returns the default registry in
-// $XDG_DATA_HOME/serviceweaver/ssh_registry, or
+// DefaultRegistryDir returns $XDG_DATA_HOME/serviceweaver/ssh_registry, or
 // ~/.local/share/serviceweaver/ssh_registry if XDG_DATA_HOME is not set.
-func DefaultRegistry(ctx context.Context) (*status.Registry, error) {
+func DefaultRegistryDir() (string, error) {
 	dir, err := files.DefaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ssh_registry"), nil
+}
+
+// DefaultRegistry returns a registry in DefaultRegistryDir().
+func DefaultRegistry(ctx context.Context) (*status.Registry, error) {
+	dir, err := DefaultRegistryDir()
 	if err != nil {
 		return nil, err
 	}
-	return status.NewRegistry(ctx, filepath.Join(dir, "ssh_registry"))
+	return status.NewRegistry(ctx, dir)
 }
@@ -0,0 +1,253 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// fakeBabysitters records the babysitters started by a manager, instead of
+// starting them over ssh.
+type fakeBabysitters struct {
+	mu      sync.Mutex
+	started []*BabysitterInfo // started babysitters, in start order
+	fail    func(n int) bool  // if not nil, fails the n-th start (from 0)
+	healthy func(*BabysitterInfo)
+}
+
+func (f *fakeBabysitters) launch(_ string, info *BabysitterInfo) error {
+	f.mu.Lock()
+	n := len(f.started)
+	f.started = append(f.started, info)
+	f.mu.Unlock()
+	if f.fail != nil && f.fail(n) {
+		return errors.New("ssh: connection refused")
+	}
+	if f.healthy != nil {
+		// The manager holds the group's lock, so report health from
+		// another goroutine, like a real babysitter.
+		go f.healthy(info)
+	}
+	return nil
+}
+
+// newTestManager returns a manager whose "main" colocation group has one
+// replica at every provided location, with ids 0, 1, and so on. The new
+// replicas are healthy.
+func newTestManager(t *testing.T, sections map[string]string, f *fakeBabysitters, locs ...string) (*manager, *group) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dep := &protos.Deployment{
+		Id:  "dep",
+		App: &protos.AppConfig{Name: "app", Binary: "/bin/app", Sections: sections},
+	}
+	m := &manager{
+		ctx:              ctx,
+		dep:              dep,
+		opts:             ManagerOptions{Locations: locs},
+		logger:           slog.New(slog.NewTextHandler(io.Discard)),
+		groups:           map[string]*group{},
+		proxies:          map[string]*proxyInfo{},
+		metrics:          map[groupReplicaInfo]replicaMetrics{},
+		launchBabysitter: f.launch,
+	}
+	m.replicaDep.Store(dep)
+
+	g := m.group("main")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.started = true
+	for _, loc := range locs {
+		id, err := m.startReplicaAt(g, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.healthy[id] = true
+	}
+	return m, g
+}
+
+// replicas returns the ids of the running and the stopping replicas of g.
+func replicas(g *group) (running, stopping []int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for id := range g.stopping {
+		stopping = append(stopping, id)
+	}
+	slices.Sort(stopping)
+	return slices.Clone(g.replicaIds), stopping
+}
+
+func TestRestartReplacesHealthyReplicas(t *testing.T) {
+	// Test plan: Restart a deployment with a replica at each of two
+	// locations, with new replicas that report themselves healthy. Check
+	// that both replicas are replaced.
+	f := &fakeBabysitters{}
+	m, g := newTestManager(t, nil, f, "a", "b")
+	f.healthy = func(info *BabysitterInfo) {
+		m.replicaHealthy(context.Background(), &ReplicaHealthy{Group: info.Group, ReplicaId: info.ReplicaId}) //nolint:errcheck // never fails
+	}
+	if _, err := m.restart(context.Background(), &RestartRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	running, stopping := replicas(g)
+	if want := []int32{2, 3}; !slices.Equal(running, want) {
+		t.Errorf("running replicas: got %v, want %v", running, want)
+	}
+	if want := []int32{0, 1}; !slices.Equal(stopping, want) {
+		t.Errorf("stopping replicas: got %v, want %v", stopping, want)
+	}
+}
+
+func TestRestartRollsBackUnhealthyReplicas(t *testing.T) {
+	// Test plan: Restart a deployment with a new config, with new replicas
+	// that never report themselves healthy. Check that the new replicas are
+	// stopped after the startup timeout, that the old replicas keep
+	// running, that the second location is left untouched, and that
+	// replicas started later run the old config.
+	sections := map[string]string{"github.com/ServiceWeaver/weaver": `startup_timeout = "100ms"`}
+	f := &fakeBabysitters{}
+	m, g := newTestManager(t, sections, f, "a", "b")
+	old := m.replicaDep.Load()
+	config := &protos.AppConfig{Name: "app", Sections: map[string]string{
+		"github.com/ServiceWeaver/weaver": `startup_timeout = "100ms"`,
+		"app/Main":                        `greeting = "hi"`,
+	}}
+	_, err := m.restart(context.Background(), &RestartRequest{Config: config})
+	if err == nil || !strings.Contains(err.Error(), "not healthy") {
+		t.Fatalf("restart: got %v, want a health error", err)
+	}
+
+	running, stopping := replicas(g)
+	if want := []int32{0, 1}; !slices.Equal(running, want) {
+		t.Errorf("running replicas: got %v, want %v", running, want)
+	}
+	if want := []int32{2}; !slices.Equal(stopping, want) {
+		t.Errorf("stopping replicas: got %v, want %v", stopping, want)
+	}
+	if got := len(f.started); got != 3 {
+		t.Errorf("started babysitters: got %d, want 3", got)
+	}
+	if got := f.started[2].Deployment.App.Sections["app/Main"]; got == "" {
+		t.Errorf("restarted replica did not run the new config")
+	}
+	if got := m.replicaDep.Load(); got != old {
+		t.Errorf("replica deployment after failed restart: got %v, want %v", got, old)
+	}
+	if m.restarting.Load() {
+		t.Error("still restarting after failed restart")
+	}
+}
+
+func TestRestartPartialStartFailure(t *testing.T) {
+	// Test plan: Restart a location with two replicas, where the replica
+	// that replaces the first one starts, but the one that replaces the
+	// second one fails to start. Check that the started replica is
+	// stopped, and that both old replicas keep running.
+	f := &fakeBabysitters{}
+	m, g := newTestManager(t, nil, f, "a", "a")
+	f.fail = func(n int) bool { return n == 3 }
+	old := m.replicaDep.Load()
+	config := &protos.AppConfig{Name: "app"}
+	_, err := m.restart(context.Background(), &RestartRequest{Config: config})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("restart: got %v, want a start error", err)
+	}
+
+	running, stopping := replicas(g)
+	if want := []int32{0, 1}; !slices.Equal(running, want) {
+		t.Errorf("running replicas: got %v, want %v", running, want)
+	}
+	if want := []int32{2}; !slices.Equal(stopping, want) {
+		t.Errorf("stopping replicas: got %v, want %v", stopping, want)
+	}
+	if got := m.replicaDep.Load(); got != old {
+		t.Errorf("replica deployment after failed restart: got %v, want %v", got, old)
+	}
+}
+
+// routed returns the sorted addresses of the replicas of g that are routed
+// to.
+func routed(g *group) []string {
+	addrs := g.allAddresses()
+	slices.Sort(addrs)
+	return addrs
+}
+
+func TestPruneDeadReplicas(t *testing.T) {
+	// Test plan: Register two replicas, like a manager whose replicas are
+	// started by StartGroup. Check that a replica that misses its heartbeats
+	// is not routed to until it reports itself healthy again, that a replica
+	// whose babysitter stops is not routed to, and that a replica restarted
+	// in place replaces its stopped weavelet.
+	ctx := context.Background()
+	m, _ := newTestManager(t, nil, &fakeBabysitters{})
+	g := m.group("main")
+	a := &ReplicaToRegister{Group: "main", Address: "tcp://a:1", Pid: 1, ReplicaId: 10}
+	b := &ReplicaToRegister{Group: "main", Address: "tcp://b:1", Pid: 2, ReplicaId: 20}
+	for _, r := range []*ReplicaToRegister{a, b} {
+		if err := m.registerReplica(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Replica a misses its heartbeats.
+	g.mu.Lock()
+	g.heartbeats[a.ReplicaId] = time.Now().Add(-2 * heartbeatTimeout)
+	g.mu.Unlock()
+	m.pruneStaleReplicas(time.Now())
+	if got, want := routed(g), []string{b.Address}; !slices.Equal(got, want) {
+		t.Fatalf("routed after missed heartbeats: got %v, want %v", got, want)
+	}
+	m.replicaHealthy(ctx, &ReplicaHealthy{Group: "main", ReplicaId: a.ReplicaId}) //nolint:errcheck // never fails
+	if got, want := routed(g), []string{a.Address, b.Address}; !slices.Equal(got, want) {
+		t.Fatalf("routed after heartbeat: got %v, want %v", got, want)
+	}
+
+	// The babysitter of replica b stops.
+	m.unregisterReplica(ctx, b) //nolint:errcheck // never fails
+	if got, want := routed(g), []string{a.Address}; !slices.Equal(got, want) {
+		t.Fatalf("routed after unregister: got %v, want %v", got, want)
+	}
+
+	// Replica b is restarted in place, with a new address. A late unregister
+	// of the stopped weavelet doesn't affect the new one.
+	restarted := &ReplicaToRegister{Group: "main", Address: "tcp://b:2", Pid: 3, ReplicaId: b.ReplicaId}
+	if err := m.registerReplica(ctx, restarted); err != nil {
+		t.Fatal(err)
+	}
+	m.unregisterReplica(ctx, b) //nolint:errcheck // never fails
+	if got, want := routed(g), []string{a.Address, restarted.Address}; !slices.Equal(got, want) {
+		t.Fatalf("routed after restart: got %v, want %v", got, want)
+	}
+	g.mu.Lock()
+	pids := slices.Clone(g.pids)
+	g.mu.Unlock()
+	slices.Sort(pids)
+	if want := []int64{1, 3}; !slices.Equal(pids, want) {
+		t.Fatalf("pids after restart: got %v, want %v", pids, want)
+	}
+}
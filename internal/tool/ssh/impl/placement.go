@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package impl
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// PinnedLocations returns the locations that the replicas of the colocation
+// groups of the provided application are restricted to, keyed by group name,
+// given the locations that components are pinned to. A group is restricted to
+// the locations that all of its pinned components are pinned to. Groups with
+// no pinned components are not restricted, and are missing from the returned
+// map.
+//
+// PinnedLocations returns an error if the components of a group are pinned to
+// disjoint locations, or if two components that must be kept separate (see
+// runtime.WeaveletConfig.Separate) are pinned to a common location.
+func PinnedLocations(app *protos.AppConfig, pins map[string][]string) (map[string][]string, error) {
+	colocation := map[string]string{}
+	for _, group := range app.Colocate {
+		for _, c := range group.Components {
+			colocation[c] = group.Components[0]
+		}
+	}
+	groupName := func(component string) string {
+		if name, ok := colocation[component]; ok {
+			return name
+		}
+		return component
+	}
+
+	pinned := map[string][]string{}
+	components := maps.Keys(pins)
+	sort.Strings(components)
+	for _, c := range components {
+		group := groupName(c)
+		locs, ok := pinned[group]
+		if !ok {
+			pinned[group] = slices.Clone(pins[c])
+			continue
+		}
+		var common []string
+		for _, loc := range locs {
+			if slices.Contains(pins[c], loc) {
+				common = append(common, loc)
+			}
+		}
+		if len(common) == 0 {
+			return nil, fmt.Errorf("colocation group %s: components pinned to disjoint locations", group)
+		}
+		pinned[group] = common
+	}
+
+	config, err := runtime.ParseWeaveletConfig(app.Sections)
+	if err != nil {
+		return nil, err
+	}
+	for _, separate := range config.Separate {
+		for i, a := range separate {
+			for _, b := range separate[i+1:] {
+				for _, loc := range pinned[groupName(a)] {
+					if slices.Contains(pinned[groupName(b)], loc) {
+						return nil, fmt.Errorf("separate %q and %q: components pinned to a common location %s", a, b, loc)
+					}
+				}
+			}
+		}
+	}
+	return pinned, nil
+}
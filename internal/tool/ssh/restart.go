@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/tool"
+)
+
+var (
+	restartFlags  = flag.NewFlagSet("restart", flag.ContinueOnError)
+	restartConfig = restartFlags.String("config", "", "Config file to restart the deployment with")
+
+	restartCmd = tool.Command{
+		Name:        "restart",
+		Description: "Restart a Service Weaver app, one machine at a time",
+		Help: `Usage:
+  weaver ssh restart [--config=<configfile>] <deployment>
+
+Flags:
+  -h, --help	Print this help message.
+  --config	Restart the deployment with the provided config file.
+		The binary and the colocation groups of the app can't
+		change; deploy a new version with "weaver ssh deploy
+		--rollout" instead
+
+Description:
+  "weaver ssh restart" restarts the processes of a running deployment, one
+  machine at a time, in the order of the locations file, alternating
+  between regions if the deployment spans regions. On every machine,
+  new processes are started next to the running ones. Once the new
+  processes are healthy, the running processes stop receiving traffic, are
+  drained, and exit. If the new processes aren't healthy within the
+  startup_timeout of the config, they are stopped instead, and the restart
+  stops, leaving the remaining machines untouched.
+
+  <deployment> is the id of the deployment, or a uniquely identifying prefix
+  of it.
+
+Examples:
+  # Restart a deployment.
+  weaver ssh restart 2c80d811
+
+  # Restart a deployment with a new config.
+  weaver ssh restart --config=weaver.toml 2c80d811`,
+		Flags: restartFlags,
+		Fn:    restart,
+	}
+)
+
+// restart restarts the deployment with the provided id prefix.
+func restart(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return fmt.Errorf("usage: weaver ssh restart [--config=<configfile>] <deployment>")
+	}
+
+	req := &impl.RestartRequest{}
+	if *restartConfig != "" {
+		cfg, err := os.ReadFile(*restartConfig)
+		if err != nil {
+			return fmt.Errorf("load config file %q: %w", *restartConfig, err)
+		}
+		app, err := runtime.ParseConfig(*restartConfig, string(cfg), codegen.ComponentConfigValidator)
+		if err != nil {
+			return fmt.Errorf("load config file %q: %w", *restartConfig, err)
+		}
+		req.Config = app
+	}
+
+	reg, err := status.FindRegistration(ctx, impl.DefaultRegistry, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Restarting deployment %s...\n", reg.DeploymentId)
+	if err := impl.Restart(ctx, "http://"+reg.Addr, req); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Deployment %s restarted\n", reg.DeploymentId)
+	return nil
+}
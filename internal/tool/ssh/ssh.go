@@ -17,8 +17,12 @@ package ssh
 import (
 	"path/filepath"
 
+	"github.com/ServiceWeaver/weaver/internal/must"
 	"github.com/ServiceWeaver/weaver/internal/status"
+	"github.com/ServiceWeaver/weaver/internal/tool/ssh/impl"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"github.com/ServiceWeaver/weaver/runtime/tool"
 )
 
@@ -26,10 +30,26 @@ var (
 	// logDir is where weaver ssh deployed applications store their logs.
 	logDir = filepath.Join(logging.DefaultLogDir, "weaver_ssh")
 
+	purgeSpec = &tool.PurgeSpec{
+		Tool:  "weaver ssh",
+		Kill:  "weaver ssh (dashboard|deploy|logs)",
+		Paths: []string{logDir, must.Must(impl.DefaultRegistryDir())},
+		Data: []retention.Store{
+			retention.LogFiles(logDir),
+			retention.TraceDB(perfetto.Open),
+		},
+		Running: status.RunningDeployments,
+	}
+
 	Commands = map[string]*tool.Command{
 		"deploy":    &deployCmd,
 		"logs":      tool.LogsCmd(&logsSpec),
 		"dashboard": status.DashboardCommand(dashboardSpec),
+		"rollout":   status.RolloutCommand("weaver ssh", impl.DefaultRegistry),
+		"rollback":  status.RollbackCommand("weaver ssh", impl.DefaultRegistry),
+		"attach":    status.AttachCommand("weaver ssh", impl.DefaultRegistry),
+		"restart":   &restartCmd,
+		"purge":     tool.PurgeCmd(purgeSpec),
 		"version":   tool.VersionCmd("weaver ssh"),
 
 		// Hidden commands.
@@ -29,6 +29,7 @@ const (
 	VersionTraceKey             = attribute.Key("serviceweaver.version")
 	ColocationGroupNameTraceKey = attribute.Key("serviceweaver.coloc_group")
 	GroupReplicaIDTraceKey      = attribute.Key("serviceweaver.group_replica_id")
+	RegionTraceKey              = attribute.Key("serviceweaver.region")
 )
 
 // TestTracer returns a simple tracer suitable for tests.
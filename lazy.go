@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// lazyStub is a codegen.Stub for a lazily started component; see
+// runtime.StartupConfig. The component is started, and the codegen.Stub that
+// calls it created, on the first call. Calls wait until the component has
+// started, or until their context is done.
+type lazyStub struct {
+	component string                       // name of the component
+	tracer    trace.Tracer                 // component tracer
+	start     func() (codegen.Stub, error) // starts the component
+
+	once    sync.Once     // used to call start
+	started chan struct{} // closed once start returns
+	stub    codegen.Stub  // stub returned by start
+	err     error         // error returned by start
+}
+
+var _ codegen.Stub = &lazyStub{}
+
+// newLazyStub returns a new lazyStub for the provided component.
+func newLazyStub(component string, tracer trace.Tracer, start func() (codegen.Stub, error)) *lazyStub {
+	return &lazyStub{
+		component: component,
+		tracer:    tracer,
+		start:     start,
+		started:   make(chan struct{}),
+	}
+}
+
+// get starts the component, if it hasn't been started already, and returns
+// the stub that calls it.
+func (l *lazyStub) get(ctx context.Context) (codegen.Stub, error) {
+	l.once.Do(func() {
+		// Starting the component may take a while. The call that starts it
+		// shouldn't wait beyond its deadline, but other calls may still want
+		// the component, so we don't cancel the start.
+		go func() {
+			defer close(l.started)
+			l.stub, l.err = l.start()
+		}()
+	})
+	select {
+	case <-l.started:
+		return l.stub, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Tracer implements the codegen.Stub interface.
+func (l *lazyStub) Tracer() trace.Tracer {
+	return l.tracer
+}
+
+// Run implements the codegen.Stub interface.
+func (l *lazyStub) Run(ctx context.Context, method int, args []byte, shardKey uint64) ([]byte, error) {
+	stub, err := l.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stub.Run(ctx, method, args, shardKey)
+}
+
+// Stream implements the codegen.Stub interface.
+func (l *lazyStub) Stream(ctx context.Context, method int, args []byte, shardKey uint64) (codegen.ClientStream, error) {
+	stub, err := l.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stub.Stream(ctx, method, args, shardKey)
+}
+
+// WrapError implements the codegen.Stub interface.
+func (l *lazyStub) WrapError(err error) error {
+	return (&stub{component: l.component}).WrapError(err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// echoStub is a codegen.Stub that returns the arguments of every call.
+type echoStub struct{}
+
+func (echoStub) Tracer() trace.Tracer { return nil }
+
+func (echoStub) Run(_ context.Context, _ int, args []byte, _ uint64) ([]byte, error) {
+	return args, nil
+}
+
+func (echoStub) Stream(context.Context, int, []byte, uint64) (codegen.ClientStream, error) {
+	return nil, errors.New("unimplemented")
+}
+
+func (echoStub) WrapError(err error) error { return err }
+
+func TestLazyStubStartsOnFirstCall(t *testing.T) {
+	var starts atomic.Int32
+	stub := newLazyStub("a/T", nil, func() (codegen.Stub, error) {
+		starts.Add(1)
+		return echoStub{}, nil
+	})
+	if got := starts.Load(); got != 0 {
+		t.Fatalf("started %d times before the first call, want 0", got)
+	}
+	for i := 0; i < 3; i++ {
+		got, err := stub.Run(context.Background(), 0, []byte("hello"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "hello" {
+			t.Fatalf("Run: got %q, want %q", got, "hello")
+		}
+	}
+	if got := starts.Load(); got != 1 {
+		t.Fatalf("started %d times, want 1", got)
+	}
+}
+
+func TestLazyStubStartError(t *testing.T) {
+	errStart := errors.New("start failed")
+	stub := newLazyStub("a/T", nil, func() (codegen.Stub, error) {
+		return nil, errStart
+	})
+	if _, err := stub.Run(context.Background(), 0, nil, 0); !errors.Is(err, errStart) {
+		t.Fatalf("Run: got %v, want %v", err, errStart)
+	}
+}
+
+func TestLazyStubSlowStart(t *testing.T) {
+	// A call that gives up waiting for the component to start doesn't stop
+	// later calls from using it.
+	release := make(chan struct{})
+	stub := newLazyStub("a/T", nil, func() (codegen.Stub, error) {
+		<-release
+		return echoStub{}, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := stub.Run(ctx, 0, nil, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	if _, err := stub.Run(context.Background(), 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// DefaultLeaseDuration is the default LeaderElectionOptions.LeaseDuration.
+const DefaultLeaseDuration = 15 * time.Second
+
+// LeaderElectionOptions configure a LeaderElection.
+type LeaderElectionOptions struct {
+	// LeaseDuration is how long a leader remains the leader after it last
+	// renewed its lease. The leader renews its lease every third of
+	// LeaseDuration. A shorter duration lets another process take over
+	// sooner when the leader fails, at the cost of more renewals. If zero,
+	// DefaultLeaseDuration is used.
+	LeaseDuration time.Duration
+}
+
+// A LeaderElection elects a leader among the processes in the deployment that
+// create an election with the same name. At most one process is the leader at
+// any time. It is typically used to run singleton background work, like a
+// periodic reload, on one of the replicas of a component:
+//
+//	func (c *catalog) Init(ctx context.Context) error {
+//		election, err := weaver.NewLeaderElection(c, "catalog-reload", weaver.LeaderElectionOptions{})
+//		if err != nil {
+//			return err
+//		}
+//		go func() {
+//			for {
+//				leaderCtx, err := election.Campaign(ctx)
+//				if err != nil {
+//					return
+//				}
+//				c.reloadPeriodically(leaderCtx) // returns when leaderCtx is done
+//			}
+//		}()
+//		return nil
+//	}
+//
+// Leadership is a lease granted by the deployer. The leader renews the lease
+// in the background and stops considering itself the leader as soon as it
+// fails to renew the lease before the lease expires, e.g., because it can't
+// reach the deployer. Work done as the leader should be stopped when the
+// context returned by Campaign is done.
+type LeaderElection struct {
+	w        *weavelet
+	name     string
+	duration time.Duration
+
+	mu     sync.Mutex
+	busy   bool        // campaigning or leading?
+	leader *leadership // latest leadership, or nil
+}
+
+// leadership is a term of leadership.
+type leadership struct {
+	ctx    context.Context    // done when leadership is lost
+	cancel context.CancelFunc // cancels ctx
+	done   chan struct{}      // closed when the lease is released
+}
+
+// NewLeaderElection returns the leader election with the provided name. Every
+// process that creates an election with the same name must create it with the
+// same options. A process may create an election with a given name only once.
+func NewLeaderElection(requester Instance, name string, opts LeaderElectionOptions) (*LeaderElection, error) {
+	if opts.LeaseDuration < 0 {
+		return nil, fmt.Errorf("NewLeaderElection(%q): negative lease duration %v", name, opts.LeaseDuration)
+	}
+	if opts.LeaseDuration == 0 {
+		opts.LeaseDuration = DefaultLeaseDuration
+	}
+	w := requester.rep().wlet
+	if err := w.elections.add(name); err != nil {
+		return nil, err
+	}
+	return &LeaderElection{w: w, name: name, duration: opts.LeaseDuration}, nil
+}
+
+// Campaign blocks until this process becomes the leader, or until ctx is
+// done. On success, Campaign returns a context that is done once this
+// process is no longer the leader: because it failed to renew its lease,
+// Resign was called, or ctx is done. Once leadership is lost, Campaign may be
+// called again to run for leader again. Campaign returns an error if this
+// process is already campaigning or the leader.
+func (e *LeaderElection) Campaign(ctx context.Context) (context.Context, error) {
+	e.mu.Lock()
+	if e.busy {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("election %q: already campaigning or the leader", e.name)
+	}
+	e.busy = true
+	e.mu.Unlock()
+
+	for {
+		start := e.w.clock.Now()
+		acquired, err := e.lease(ctx, false)
+		if err != nil {
+			e.w.env.SystemLogger().Error("acquire lease", err, "election", e.name)
+		}
+		if acquired {
+			l := e.lead(ctx, start.Add(e.duration))
+			e.mu.Lock()
+			e.leader = l
+			e.mu.Unlock()
+			return l.ctx, nil
+		}
+		if err := e.w.clock.Sleep(ctx, e.duration/3); err != nil {
+			e.mu.Lock()
+			e.busy = false
+			e.mu.Unlock()
+			return nil, err
+		}
+	}
+}
+
+// lead starts a term of leadership that lasts until the provided time unless
+// the lease is renewed.
+func (e *LeaderElection) lead(ctx context.Context, expires time.Time) *leadership {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &leadership{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+
+	// Leadership is lost when the lease expires, even if a renewal is stuck.
+	clock := e.w.clock
+	expiry := afterFunc(clock, expires.Sub(clock.Now()), cancel)
+	go func() {
+		defer func() {
+			e.mu.Lock()
+			e.busy = false
+			e.mu.Unlock()
+			close(l.done)
+		}()
+		defer cancel()
+		ticker := clock.NewTicker(e.duration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				expiry.Stop()
+				// Release the lease, so that another process can take over
+				// without waiting for the lease to expire.
+				if _, err := e.lease(context.Background(), true); err != nil {
+					e.w.env.SystemLogger().Error("release lease", err, "election", e.name)
+				}
+				return
+			case <-ticker.C():
+			}
+			start := clock.Now()
+			acquired, err := e.lease(ctx, false)
+			switch {
+			case err != nil:
+				// Try again, unless the lease expires first.
+				e.w.env.SystemLogger().Error("renew lease", err, "election", e.name)
+			case !acquired:
+				// Another process holds the lease.
+				cancel()
+			default:
+				expiry.Reset(start.Add(e.duration).Sub(clock.Now()))
+			}
+		}
+	}()
+	return l
+}
+
+// Resign gives up leadership, if this process is the leader, and returns once
+// the lease has been released or ctx is done.
+func (e *LeaderElection) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	l := e.leader
+	e.mu.Unlock()
+	if l == nil {
+		return nil
+	}
+	l.cancel()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsLeader returns whether this process is currently the leader.
+func (e *LeaderElection) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader != nil && e.leader.ctx.Err() == nil
+}
+
+// lease acquires or releases the lease of the election.
+func (e *LeaderElection) lease(ctx context.Context, release bool) (bool, error) {
+	reply, err := e.w.env.Lease(ctx, &protos.LeaseRequest{
+		Election:       e.name,
+		Holder:         e.w.info.Id,
+		DurationMicros: e.duration.Microseconds(),
+		Release:        release,
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.Acquired, nil
+}
+
+// elections holds the names of the elections created with NewLeaderElection
+// in a weavelet.
+type elections struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newElections() *elections {
+	return &elections{names: map[string]bool{}}
+}
+
+// add adds the provided election. It returns an error if an election with the
+// same name was already added.
+func (e *elections) add(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.names[name] {
+		return fmt.Errorf("leader election %q already created", name)
+	}
+	e.names[name] = true
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+)
+
+// failoverPeriod is how long a weavelet stops sending calls to a replica in
+// its region after the replica fails a call or can't be dialed.
+const failoverPeriod = 10 * time.Second
+
+type failoverLabels struct {
+	Component string // the called component
+	Region    string // the caller's region
+}
+
+var failovers = metrics.NewCounterMap[failoverLabels](
+	"serviceweaver_region_failover_count",
+	"Count of Service Weaver remote calls sent out of the caller's region because no replica in the region was available",
+)
+
+// localityBalancer is a call.Balancer that prefers the replicas in the
+// caller's region. Calls are balanced among the replicas in the caller's
+// region, unless the region has no replicas, or all of them failed recently,
+// in which case calls fail over to the replicas in the other regions.
+//
+// A replica in the caller's region that fails a call with a communication
+// error, or can't be dialed, is avoided for failoverPeriod, so that the
+// caller fails over quickly when its region has an outage, without waiting
+// for the deployer to remove the region's replicas.
+type localityBalancer struct {
+	component string           // the called component
+	region    string           // the caller's region
+	local     call.Balancer    // balances the replicas in the caller's region
+	all       call.Balancer    // balances the replicas in every region
+	now       func() time.Time // the current time, replaced in tests
+
+	mu        sync.Mutex
+	regions   map[string]string    // region of every replica, by address
+	endpoints []call.Endpoint      // the most recent endpoints
+	failed    map[string]time.Time // failed replicas in the region, by address
+	stale     bool                 // must local and all be updated?
+	recheck   time.Time            // when a failed replica is next retried
+	nlocal    int                  // number of endpoints balanced by local
+}
+
+var (
+	_ call.Balancer     = &localityBalancer{}
+	_ call.CallObserver = &localityBalancer{}
+	_ call.DialObserver = &localityBalancer{}
+)
+
+// newLocalityBalancer returns a new localityBalancer for calls to the
+// provided component from the provided region. newBalancer returns the
+// balancers that balance the calls within and across regions.
+func newLocalityBalancer(component, region string, newBalancer func() call.Balancer) *localityBalancer {
+	return &localityBalancer{
+		component: component,
+		region:    region,
+		local:     newBalancer(),
+		all:       newBalancer(),
+		now:       time.Now,
+		failed:    map[string]time.Time{},
+	}
+}
+
+// setRegions sets the regions of the replicas, by address. Replicas with no
+// region are never preferred.
+func (lb *localityBalancer) setRegions(regions map[string]string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.regions = regions
+	lb.stale = true
+}
+
+// Update implements the call.Balancer interface.
+func (lb *localityBalancer) Update(endpoints []call.Endpoint) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.endpoints = endpoints
+	lb.stale = true
+	lb.refresh(lb.now())
+}
+
+// Pick implements the call.Balancer interface.
+func (lb *localityBalancer) Pick(opts call.CallOptions) (call.Endpoint, error) {
+	lb.mu.Lock()
+	now := lb.now()
+	if lb.stale || (!lb.recheck.IsZero() && !now.Before(lb.recheck)) {
+		lb.refresh(now)
+	}
+	nlocal := lb.nlocal
+	lb.mu.Unlock()
+
+	if nlocal > 0 {
+		return lb.local.Pick(opts)
+	}
+	endpoint, err := lb.all.Pick(opts)
+	if err == nil {
+		failovers.Get(failoverLabels{Component: lb.component, Region: lb.region}).Add(1)
+	}
+	return endpoint, err
+}
+
+// refresh updates the endpoints balanced by local and all, forgetting the
+// failures older than failoverPeriod.
+//
+// REQUIRES: lb.mu is held.
+func (lb *localityBalancer) refresh(now time.Time) {
+	lb.recheck = time.Time{}
+	for addr, t := range lb.failed {
+		expiry := t.Add(failoverPeriod)
+		if !now.Before(expiry) {
+			delete(lb.failed, addr)
+			continue
+		}
+		if lb.recheck.IsZero() || expiry.Before(lb.recheck) {
+			lb.recheck = expiry
+		}
+	}
+
+	var local, healthy []call.Endpoint
+	for _, e := range lb.endpoints {
+		if _, ok := lb.failed[e.Address()]; ok {
+			continue
+		}
+		healthy = append(healthy, e)
+		if lb.regions[e.Address()] == lb.region {
+			local = append(local, e)
+		}
+	}
+	if len(healthy) == 0 {
+		// Every replica failed recently. Keep trying them all.
+		healthy = lb.endpoints
+	}
+	lb.local.Update(local)
+	lb.all.Update(healthy)
+	lb.nlocal = len(local)
+	lb.stale = false
+}
+
+// fail records that a replica failed, if it is in the caller's region.
+func (lb *localityBalancer) fail(endpoint call.Endpoint) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	addr := endpoint.Address()
+	if lb.regions[addr] != lb.region {
+		return
+	}
+	lb.failed[addr] = lb.now()
+	lb.stale = true
+}
+
+// Started implements the call.CallObserver interface.
+func (lb *localityBalancer) Started(endpoint call.Endpoint) {
+	for _, b := range []call.Balancer{lb.local, lb.all} {
+		if observer, ok := b.(call.CallObserver); ok {
+			observer.Started(endpoint)
+		}
+	}
+}
+
+// Finished implements the call.CallObserver interface.
+func (lb *localityBalancer) Finished(endpoint call.Endpoint, latency time.Duration, err error) {
+	if errors.Is(err, call.CommunicationError) {
+		lb.fail(endpoint)
+	}
+	for _, b := range []call.Balancer{lb.local, lb.all} {
+		if observer, ok := b.(call.CallObserver); ok {
+			observer.Finished(endpoint, latency, err)
+		}
+	}
+}
+
+// DialFailed implements the call.DialObserver interface.
+func (lb *localityBalancer) DialFailed(endpoint call.Endpoint, _ error) {
+	lb.fail(endpoint)
+}
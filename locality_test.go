@@ -0,0 +1,105 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+)
+
+// testLocalityBalancer returns a round-robin localityBalancer in region
+// "east", over replicas "e1" and "e2" in "east", and "w1" in "west", with a
+// fake clock.
+func testLocalityBalancer(t *testing.T) (*localityBalancer, *time.Time) {
+	t.Helper()
+	now := time.Now()
+	lb := newLocalityBalancer("component", "east", func() call.Balancer { return call.RoundRobin() })
+	lb.now = func() time.Time { return now }
+	lb.setRegions(map[string]string{"e1": "east", "e2": "east", "w1": "west"})
+	lb.Update([]call.Endpoint{nilEndpoint{"e1"}, nilEndpoint{"w1"}, nilEndpoint{"e2"}})
+	return lb, &now
+}
+
+// picks returns the addresses of the endpoints picked by n calls to Pick.
+func picks(t *testing.T, lb call.Balancer, n int) map[string]bool {
+	t.Helper()
+	picked := map[string]bool{}
+	for i := 0; i < n; i++ {
+		e, err := lb.Pick(call.CallOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked[e.Address()] = true
+	}
+	return picked
+}
+
+func TestLocalityBalancerPrefersRegion(t *testing.T) {
+	lb, _ := testLocalityBalancer(t)
+	got := picks(t, lb, 10)
+	if len(got) != 2 || !got["e1"] || !got["e2"] {
+		t.Fatalf("picks: got %v, want e1 and e2", got)
+	}
+}
+
+func TestLocalityBalancerNoLocalReplicas(t *testing.T) {
+	lb, _ := testLocalityBalancer(t)
+	lb.Update([]call.Endpoint{nilEndpoint{"w1"}})
+	if got := picks(t, lb, 3); len(got) != 1 || !got["w1"] {
+		t.Fatalf("picks: got %v, want w1", got)
+	}
+}
+
+func TestLocalityBalancerFailover(t *testing.T) {
+	lb, now := testLocalityBalancer(t)
+
+	// e1 fails a call, and e2 can't be dialed. Calls fail over to w1.
+	lb.Finished(nilEndpoint{"e1"}, time.Millisecond, fmt.Errorf("%w: connection reset", call.CommunicationError))
+	lb.DialFailed(nilEndpoint{"e2"}, fmt.Errorf("connection refused"))
+	if got := picks(t, lb, 3); len(got) != 1 || !got["w1"] {
+		t.Fatalf("picks after failures: got %v, want w1", got)
+	}
+
+	// Application errors don't cause failovers.
+	*now = now.Add(failoverPeriod)
+	lb.Finished(nilEndpoint{"e1"}, time.Millisecond, fmt.Errorf("not found"))
+	if got := picks(t, lb, 10); len(got) != 2 || !got["e1"] || !got["e2"] {
+		t.Fatalf("picks after recovery: got %v, want e1 and e2", got)
+	}
+}
+
+func TestLocalityBalancerEveryReplicaFailed(t *testing.T) {
+	lb, _ := testLocalityBalancer(t)
+	lb.Update([]call.Endpoint{nilEndpoint{"e1"}})
+	lb.DialFailed(nilEndpoint{"e1"}, fmt.Errorf("connection refused"))
+	if got := picks(t, lb, 3); len(got) != 1 || !got["e1"] {
+		t.Fatalf("picks: got %v, want e1", got)
+	}
+}
+
+func TestRoutingBalancerRegions(t *testing.T) {
+	rb := newRoutingBalancer("component", "round_robin", "west")
+	rb.updateRegions(map[string]string{"e1": "east", "w1": "west"})
+	rb.Update([]call.Endpoint{nilEndpoint{"e1"}, nilEndpoint{"w1"}})
+	if got := picks(t, rb, 3); len(got) != 1 || !got["w1"] {
+		t.Fatalf("picks: got %v, want w1", got)
+	}
+	if got := rb.labels(nilEndpoint{"e1"}).Region; got != "east" {
+		t.Fatalf("labels(e1).Region: got %q, want %q", got, "east")
+	}
+}
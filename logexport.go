@@ -0,0 +1,199 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// logExportBatchSize is the maximum number of log entries exported at
+	// once.
+	logExportBatchSize = 100
+
+	// logExportInterval is how often buffered log entries are exported.
+	logExportInterval = time.Second
+
+	// logExportBuffer is the maximum number of buffered log entries. Log
+	// entries logged when the buffer is full are dropped.
+	logExportBuffer = 10000
+)
+
+var droppedLogEntries = metrics.NewCounter(
+	"serviceweaver_log_export_dropped_count",
+	"Count of log entries dropped because log exporters fell behind",
+)
+
+// A LogExporter exports the log entries of a process's components to a
+// logging backend. Built-in exporters for Grafana Loki, Google Cloud Logging,
+// syslog, and rotated files are enabled in the [serviceweaver.logging]
+// section of the config; see runtime.LoggingConfig. Other backends can be
+// supported by registering a LogExporter with ExportLogs.
+type LogExporter = logging.Exporter
+
+var logExporters struct {
+	mu        sync.Mutex
+	exporters []LogExporter
+}
+
+// ExportLogs registers an exporter that the log entries of the process's
+// components are exported to, in batches, in addition to the deployer. The
+// exporter is shared by all the weavelets of the process, and outlives them.
+//
+// Like RegisterError, ExportLogs must be called in every process, before
+// Init, typically in main or an init function.
+func ExportLogs(e LogExporter) {
+	logExporters.mu.Lock()
+	defer logExporters.mu.Unlock()
+	logExporters.exporters = append(logExporters.exporters, e)
+}
+
+// registeredLogExporters returns the exporters registered with ExportLogs.
+func registeredLogExporters() []LogExporter {
+	logExporters.mu.Lock()
+	defer logExporters.mu.Unlock()
+	return append([]LogExporter(nil), logExporters.exporters...)
+}
+
+// logShipper exports log entries to a set of exporters, in batches, in the
+// background. A nil logShipper exports nothing.
+type logShipper struct {
+	exporters []LogExporter
+	closeFn   func() error // closes the exporters
+	logger    *slog.Logger // logs export errors
+	entries   chan *protos.LogEntry
+	closed    chan struct{} // closed by close
+	done      chan struct{} // closed once the last batch is exported
+	closeOnce sync.Once
+}
+
+// newLogShipper returns a new logShipper that exports log entries to the
+// provided exporters, and closes them with closeFn once closed, or nil if
+// there are no exporters.
+func newLogShipper(exporters []LogExporter, closeFn func() error, logger *slog.Logger) *logShipper {
+	if len(exporters) == 0 {
+		return nil
+	}
+	s := &logShipper{
+		exporters: exporters,
+		closeFn:   closeFn,
+		logger:    logger,
+		entries:   make(chan *protos.LogEntry, logExportBuffer),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// add buffers a log entry for export. add never blocks: the entry is dropped
+// if the buffer is full, or if the shipper is closed.
+func (s *logShipper) add(entry *protos.LogEntry) {
+	if s == nil {
+		return
+	}
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+	select {
+	case s.entries <- entry:
+	default:
+		droppedLogEntries.Add(1)
+	}
+}
+
+// run exports the buffered log entries until the shipper is closed.
+func (s *logShipper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(logExportInterval)
+	defer ticker.Stop()
+	var batch []*protos.LogEntry
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) < logExportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-s.closed:
+			// Export the entries buffered so far, and stop.
+			for len(s.entries) > 0 {
+				batch = append(batch, <-s.entries)
+			}
+			for len(batch) > 0 {
+				n := len(batch)
+				if n > logExportBatchSize {
+					n = logExportBatchSize
+				}
+				s.export(batch[:n])
+				batch = batch[n:]
+			}
+			return
+		}
+		if len(batch) > 0 {
+			s.export(batch)
+			batch = nil
+		}
+	}
+}
+
+// export exports a batch of log entries to every exporter.
+func (s *logShipper) export(batch []*protos.LogEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*logExportInterval)
+	defer cancel()
+	for _, e := range s.exporters {
+		if err := e.Export(ctx, batch); err != nil {
+			s.logger.Error("export log entries", err, "entries", len(batch))
+		}
+	}
+}
+
+// close exports the buffered log entries, and closes the exporters. It
+// returns once the entries are exported, or ctx is done.
+func (s *logShipper) close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.closeOnce.Do(func() { close(s.closed) })
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.closeFn()
+}
+
+// createLogSaver returns a function that saves log entries with the
+// weavelet's environment, and exports them with the weavelet's log exporters.
+func (w *weavelet) createLogSaver() func(entry *protos.LogEntry) {
+	save := w.env.CreateLogSaver()
+	if w.logs == nil {
+		return save
+	}
+	return func(entry *protos.LogEntry) {
+		save(entry)
+		w.logs.add(entry)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+// fakeLogExporter is a LogExporter that records the batches it exports.
+type fakeLogExporter struct {
+	mu      sync.Mutex
+	batches [][]*protos.LogEntry
+}
+
+// Export implements the LogExporter interface.
+func (f *fakeLogExporter) Export(_ context.Context, entries []*protos.LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, entries)
+	return nil
+}
+
+func TestLogShipper(t *testing.T) {
+	// Test plan: Add more entries than fit in a batch, close the shipper,
+	// and check that all the entries were exported, in order and in
+	// batches, and that the exporters were closed.
+	exporter := &fakeLogExporter{}
+	closed := false
+	s := newLogShipper([]LogExporter{exporter}, func() error {
+		closed = true
+		return nil
+	}, slog.Default())
+	const n = 2*logExportBatchSize + 1
+	for i := 0; i < n; i++ {
+		s.add(&protos.LogEntry{Msg: fmt.Sprint(i)})
+	}
+	if err := s.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !closed {
+		t.Error("exporters not closed")
+	}
+
+	// Entries added after close are dropped.
+	s.add(&protos.LogEntry{Msg: "late"})
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	var i int
+	for _, batch := range exporter.batches {
+		if len(batch) > logExportBatchSize {
+			t.Errorf("batch of %d entries, want at most %d", len(batch), logExportBatchSize)
+		}
+		for _, entry := range batch {
+			if want := fmt.Sprint(i); entry.Msg != want {
+				t.Fatalf("entry %d: got %q, want %q", i, entry.Msg, want)
+			}
+			i++
+		}
+	}
+	if i != n {
+		t.Fatalf("exported %d entries, want %d", i, n)
+	}
+}
+
+func TestNilLogShipper(t *testing.T) {
+	s := newLogShipper(nil, nil, slog.Default())
+	if s != nil {
+		t.Fatal("newLogShipper with no exporters not nil")
+	}
+	s.add(&protos.LogEntry{})
+	if err := s.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/maps"
+)
+
+// WithMetadata returns a copy of ctx that holds the provided key/value pair,
+// replacing the value of key in ctx, if any. Metadata is propagated to the
+// component method calls made with the returned context, and to the calls
+// they make in turn, whether the called components are local or remote. For
+// example, an HTTP handler can attach the tenant of a request once, rather
+// than pass it to every method:
+//
+//	ctx := weaver.WithMetadata(r.Context(), "tenant", tenant)
+//	cart, err := s.cart.Get().GetCart(ctx, user)
+//
+// and any component involved in the request can read it:
+//
+//	tenant := weaver.MetadataFromContext(ctx)["tenant"]
+//
+// The metadata is also recorded as attributes, named
+// "serviceweaver.metadata.<key>", of the current span of ctx and of the spans
+// of remote calls. Metadata is sent along with every remote call, so keep it
+// small.
+func WithMetadata(ctx context.Context, key, value string) context.Context {
+	md := &callMetadata{Values: map[string]string{key: value}}
+	if old := metadataFromContext(ctx); old != nil {
+		md.Principal = old.Principal
+		for k, v := range old.Values {
+			if k != key {
+				md.Values[k] = v
+			}
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(metadataAttribute(key, value))
+	return withCallMetadata(ctx, md)
+}
+
+// MetadataFromContext returns the metadata attached to ctx with WithMetadata,
+// either by the caller or by a remote component that made the call being
+// handled with ctx. The returned map is a copy, and is empty if there is no
+// metadata.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	md := metadataFromContext(ctx)
+	if md == nil {
+		return map[string]string{}
+	}
+	return maps.Clone(md.Values)
+}
+
+// metadataKey is the context key for the callMetadata stored by
+// withCallMetadata.
+type metadataKey struct{}
+
+// callMetadata is the metadata propagated across component method calls. It
+// is sent to remote components encoded as JSON.
+type callMetadata struct {
+	Principal *Principal        `json:"principal,omitempty"` // see Authenticate
+	Values    map[string]string `json:"values,omitempty"`    // see WithMetadata
+
+	// IdempotencyKey is the idempotency key of a single call (see
+	// WithIdempotencyKey). Unlike the rest of the metadata, it is not
+	// propagated to the calls made by the callee.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Caller is the name of the component that made a single call, if it
+	// has an access policy (see accessPolicy). Like the idempotency key, it
+	// is not propagated to the calls made by the callee.
+	Caller string `json:"caller,omitempty"`
+
+	encoded []byte // the metadata, encoded as JSON
+}
+
+// withCallMetadata returns a copy of ctx that holds md.
+func withCallMetadata(ctx context.Context, md *callMetadata) context.Context {
+	md.encoded = encodeMetadata(md)
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// encodeMetadata returns md, encoded as JSON.
+func encodeMetadata(md *callMetadata) []byte {
+	encoded, err := json.Marshal(md)
+	if err != nil {
+		// Claims are decoded from JSON, so they can be encoded back.
+		panic(fmt.Errorf("encode call metadata: %w", err))
+	}
+	return encoded
+}
+
+// metadataFromContext returns the metadata stored in ctx, or nil if there is
+// none.
+func metadataFromContext(ctx context.Context) *callMetadata {
+	md, _ := ctx.Value(metadataKey{}).(*callMetadata)
+	return md
+}
+
+// receiveMetadata returns a copy of ctx, the context of a remote call being
+// handled, that holds the metadata sent by the caller, if any, along with the
+// idempotency key of the call and the name of the calling component, if any.
+// The metadata is recorded in the call's span.
+func receiveMetadata(ctx context.Context) (context.Context, string, string) {
+	encoded := call.Metadata(ctx)
+	if encoded == nil {
+		return ctx, "", ""
+	}
+	md := &callMetadata{}
+	if err := json.Unmarshal(encoded, md); err != nil {
+		// The metadata is only sent by other weavelets of the application.
+		return ctx, "", ""
+	}
+	key, caller := md.IdempotencyKey, md.Caller
+	if key == "" && caller == "" {
+		md.encoded = encoded
+	} else {
+		// Don't propagate the idempotency key and the caller to the calls
+		// made by the callee.
+		md.IdempotencyKey = ""
+		md.Caller = ""
+		if md.Principal == nil && len(md.Values) == 0 {
+			return ctx, key, caller
+		}
+		md.encoded = encodeMetadata(md)
+	}
+	recordMetadata(ctx, md)
+	return context.WithValue(ctx, metadataKey{}, md), key, caller
+}
+
+// recordMetadata records the metadata in the current span of ctx.
+func recordMetadata(ctx context.Context, md *callMetadata) {
+	span := trace.SpanFromContext(ctx)
+	if md == nil || !span.IsRecording() {
+		return
+	}
+	for k, v := range md.Values {
+		span.SetAttributes(metadataAttribute(k, v))
+	}
+}
+
+// metadataAttribute returns the span attribute that records the provided
+// metadata.
+func metadataAttribute(key, value string) attribute.KeyValue {
+	return attribute.String("serviceweaver.metadata."+key, value)
+}
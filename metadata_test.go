@@ -0,0 +1,89 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/google/go-cmp/cmp"
+)
+
+// metadataClient is a call.Connection that records the metadata sent with
+// the last call made on it.
+type metadataClient struct {
+	last []byte
+}
+
+func (c *metadataClient) Call(_ context.Context, _ call.MethodKey, _ []byte, opts call.CallOptions) ([]byte, error) {
+	c.last = opts.Metadata
+	return nil, nil
+}
+
+func (c *metadataClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, fmt.Errorf("streaming calls not supported")
+}
+
+func (c *metadataClient) Close() {}
+
+func TestWithMetadata(t *testing.T) {
+	ctx := context.Background()
+	ctx = WithMetadata(ctx, "tenant", "acme")
+	ctx = withPrincipal(ctx, Principal{Name: "alice", Scheme: "api_key"})
+	parent := WithMetadata(ctx, "session", "1234")
+	child := WithMetadata(parent, "tenant", "globex")
+
+	if diff := cmp.Diff(map[string]string{"tenant": "acme", "session": "1234"}, MetadataFromContext(parent)); diff != "" {
+		t.Fatalf("MetadataFromContext(parent) (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"tenant": "globex", "session": "1234"}, MetadataFromContext(child)); diff != "" {
+		t.Fatalf("MetadataFromContext(child) (-want +got):\n%s", diff)
+	}
+	if p, ok := PrincipalFromContext(child); !ok || p.Name != "alice" {
+		t.Fatalf("PrincipalFromContext(child): got (%v, %t), want alice", p, ok)
+	}
+	if got := MetadataFromContext(context.Background()); len(got) != 0 {
+		t.Fatalf("MetadataFromContext(Background): got %v, want empty", got)
+	}
+}
+
+func TestStubMetadata(t *testing.T) {
+	client := &metadataClient{}
+	s := &stub{client: client, methods: make([]call.MethodKey, 1)}
+
+	// No metadata is sent when there is none.
+	if _, err := s.Run(context.Background(), 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if client.last != nil {
+		t.Fatalf("Run: got metadata %q, want none", client.last)
+	}
+
+	ctx := WithMetadata(context.Background(), "tenant", "acme")
+	if _, err := s.Run(ctx, 0, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got callMetadata
+	if err := json.Unmarshal(client.last, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := callMetadata{Values: map[string]string{"tenant": "acme"}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(callMetadata{})); diff != "" {
+		t.Fatalf("Run: metadata (-want +got):\n%s", diff)
+	}
+}
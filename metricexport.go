@@ -0,0 +1,161 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+	"golang.org/x/exp/slog"
+)
+
+// A MetricExporter exports the metrics of a process to a metrics backend,
+// like StatsD, InfluxDB, or CloudWatch. Exporters are enabled in the
+// [serviceweaver.metrics] sections of the config (see runtime.MetricsConfig),
+// and every kind of exporter other than the built-in "statsd" is registered
+// with RegisterMetricExporter.
+//
+// Every weavelet calls Export with a snapshot of all of its metrics, at the
+// interval set in the config, and once more when it stops. If the exporter
+// implements io.Closer, it is closed when the weavelet stops.
+type MetricExporter = metrics.Backend
+
+// A MetricBatch is a set of metric snapshots passed to a MetricExporter.
+type MetricBatch = metrics.Batch
+
+// MetricExporterConfig holds the settings of a metrics exporter.
+type MetricExporterConfig = runtime.MetricsConfig
+
+var metricExporters = struct {
+	mu    sync.Mutex
+	kinds map[string]func(context.Context, MetricExporterConfig) (MetricExporter, error)
+}{kinds: map[string]func(context.Context, MetricExporterConfig) (MetricExporter, error){}}
+
+// RegisterMetricExporter registers the function that opens the metrics
+// exporters of the provided kind, e.g., "cloudwatch", selected by the kind of
+// the [serviceweaver.metrics] sections in the config. For example:
+//
+//	weaver.RegisterMetricExporter("cloudwatch", func(ctx context.Context, c weaver.MetricExporterConfig) (weaver.MetricExporter, error) {
+//	    return newCloudWatchExporter(ctx, c.Options["namespace"], c.Options["region"])
+//	})
+//
+// Every process must register the same kinds, before Init, typically in main
+// or an init function. RegisterMetricExporter panics if the kind is empty or
+// already taken, or if open is nil.
+func RegisterMetricExporter(kind string, open func(context.Context, MetricExporterConfig) (MetricExporter, error)) {
+	if kind == "" {
+		panic(fmt.Errorf("RegisterMetricExporter: empty kind"))
+	}
+	if open == nil {
+		panic(fmt.Errorf("RegisterMetricExporter(%q): nil open", kind))
+	}
+	metricExporters.mu.Lock()
+	defer metricExporters.mu.Unlock()
+	if _, ok := metricExporters.kinds[kind]; ok {
+		panic(fmt.Errorf("RegisterMetricExporter: kind %q already registered", kind))
+	}
+	metricExporters.kinds[kind] = open
+}
+
+func init() {
+	// The "statsd" kind sends metrics to a StatsD server over UDP.
+	RegisterMetricExporter("statsd", func(_ context.Context, config MetricExporterConfig) (MetricExporter, error) {
+		return imetrics.NewStatsD(config.Address)
+	})
+}
+
+// openMetricExporter opens the exporter configured by the named section.
+func openMetricExporter(ctx context.Context, name string, config runtime.MetricsConfig) (MetricExporter, error) {
+	kind := config.KindOrName(name)
+	metricExporters.mu.Lock()
+	open, ok := metricExporters.kinds[kind]
+	metricExporters.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("metrics %q: unknown kind %q; register it with weaver.RegisterMetricExporter", name, kind)
+	}
+	exporter, err := open(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("metrics %q: %w", name, err)
+	}
+	return exporter, nil
+}
+
+// exportMetricsToBackends exports the weavelet's metrics to the exporters
+// enabled in the [serviceweaver.metrics] sections of the config.
+func (w *weavelet) exportMetricsToBackends() error {
+	names := make([]string, 0, len(w.metricBackends))
+	for name := range w.metricBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config := w.metricBackends[name]
+		exporter, err := openMetricExporter(w.ctx, name, config)
+		if err != nil {
+			return err
+		}
+		interval := config.Interval
+		if interval == 0 {
+			interval = runtime.DefaultMetricsExportInterval
+		}
+		snapshot := func() []*metrics.MetricSnapshot {
+			snapshots, _ := w.metricSnapshots(w.ctx)
+			return snapshots
+		}
+		logger := w.env.SystemLogger().With("exporter", name)
+		go exportMetricBatches(w.ctx, logger, exporter, w.created, interval, snapshot)
+	}
+	return nil
+}
+
+// exportMetricBatches exports the metrics returned by snapshot to exporter
+// every interval, until ctx is cancelled, and once more then. start is when
+// the metrics started being recorded.
+func exportMetricBatches(ctx context.Context, logger *slog.Logger, exporter MetricExporter, start time.Time, interval time.Duration, snapshot func() []*metrics.MetricSnapshot) {
+	export := func(ctx context.Context) {
+		batch := &metrics.Batch{Time: time.Now(), Start: start, Metrics: snapshot()}
+		if err := exporter.Export(ctx, batch); err != nil {
+			logger.Error("export metrics", err)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			export(ctx)
+		case <-ctx.Done():
+			// Export the latest values, using a fresh context, since ctx is
+			// cancelled.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			export(ctx)
+			if c, ok := exporter.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					logger.Error("close metric exporter", err)
+				}
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+)
+
+// fakeMetricExporter is a MetricExporter that sends the batches it exports
+// on a channel.
+type fakeMetricExporter struct {
+	namespace string
+	batches   chan *MetricBatch
+	closed    chan struct{}
+}
+
+func (f *fakeMetricExporter) Export(_ context.Context, batch *MetricBatch) error {
+	f.batches <- batch
+	return nil
+}
+
+func (f *fakeMetricExporter) Close() error {
+	close(f.closed)
+	return nil
+}
+
+func TestMetricExporter(t *testing.T) {
+	// Test plan: Register a kind of exporter, open one from the config, and
+	// check that it exports batches periodically and when stopped.
+	var exporter *fakeMetricExporter
+	RegisterMetricExporter("test-fake", func(_ context.Context, c MetricExporterConfig) (MetricExporter, error) {
+		exporter = &fakeMetricExporter{
+			namespace: c.Options["namespace"],
+			batches:   make(chan *MetricBatch, 100),
+			closed:    make(chan struct{}),
+		}
+		return exporter, nil
+	})
+	config := runtime.MetricsConfig{Kind: "test-fake", Options: map[string]string{"namespace": "Boutique"}}
+	e, err := openMetricExporter(context.Background(), "ops", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exporter.namespace != "Boutique" {
+		t.Fatalf("namespace: got %q, want %q", exporter.namespace, "Boutique")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	snapshot := func() []*metrics.MetricSnapshot {
+		return []*metrics.MetricSnapshot{{Name: "requests", Value: 1}}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exportMetricBatches(ctx, leaseEnv{t: t}.SystemLogger(), e, start, time.Millisecond, snapshot)
+	}()
+	batch := <-exporter.batches
+	if !batch.Start.Equal(start) || len(batch.Metrics) != 1 || batch.Metrics[0].Name != "requests" {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+	cancel()
+	<-done
+	<-exporter.closed
+}
+
+func TestMetricExporterUnknownKind(t *testing.T) {
+	config := runtime.MetricsConfig{Kind: "unregistered"}
+	if _, err := openMetricExporter(context.Background(), "ops", config); err == nil {
+		t.Fatal("openMetricExporter: unexpected success")
+	}
+}
+
+func TestRegisterMetricExporterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("RegisterMetricExporter: unexpected success")
+		}
+	}()
+	RegisterMetricExporter("statsd", func(context.Context, MetricExporterConfig) (MetricExporter, error) {
+		return nil, nil
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import "github.com/ServiceWeaver/weaver/metrics"
+
+// This file re-exports the metric types and constructors in the metrics
+// package, so that application metrics can be declared next to the rest of
+// the Service Weaver API. For example:
+//
+//	type orderLabels struct {
+//	    Currency string `values:"USD,EUR,JPY"`
+//	}
+//
+//	var ordersPlaced = weaver.NewCounterMap[orderLabels](
+//	    "boutique_orders_placed",
+//	    "Number of orders placed, by currency",
+//	)
+//
+// Metrics are declared at package scope and can be updated from any
+// component. They are exported alongside the metrics recorded by
+// InstrumentHandler and by the Service Weaver runtime. See the metrics
+// package for details.
+
+type (
+	// A Counter is a float-valued, monotonically increasing metric.
+	// See metrics.Counter.
+	Counter = metrics.Counter
+
+	// A Gauge is a float-valued metric that can increase or decrease.
+	// See metrics.Gauge.
+	Gauge = metrics.Gauge
+
+	// A Histogram is a metric that counts values in buckets.
+	// See metrics.Histogram.
+	Histogram = metrics.Histogram
+)
+
+// NewCounter returns a new Counter. See metrics.NewCounter.
+func NewCounter(name, help string) *Counter {
+	return metrics.NewCounter(name, help)
+}
+
+// NewCounterMap returns a new CounterMap. See metrics.NewCounterMap.
+func NewCounterMap[L comparable](name, help string) *metrics.CounterMap[L] {
+	return metrics.NewCounterMap[L](name, help)
+}
+
+// NewGauge returns a new Gauge. See metrics.NewGauge.
+func NewGauge(name, help string) *Gauge {
+	return metrics.NewGauge(name, help)
+}
+
+// NewGaugeMap returns a new GaugeMap. See metrics.NewGaugeMap.
+func NewGaugeMap[L comparable](name, help string) *metrics.GaugeMap[L] {
+	return metrics.NewGaugeMap[L](name, help)
+}
+
+// NewHistogram returns a new Histogram. See metrics.NewHistogram.
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	return metrics.NewHistogram(name, help, bounds)
+}
+
+// NewHistogramMap returns a new HistogramMap. See metrics.NewHistogramMap.
+func NewHistogramMap[L comparable](name, help string, bounds []float64) *metrics.HistogramMap[L] {
+	return metrics.NewHistogramMap[L](name, help, bounds)
+}
@@ -80,6 +80,17 @@ func (c *Counter) Add(delta float64) {
 //	    Bar string `weaver:"Bar"` // "Bar"
 //	    Baz string `weaver:"sup"` // "sup"
 //	}
+//
+// Every distinct combination of label values is exported as a separate time
+// series, so labels whose values come from user input (e.g., a currency code
+// in a request) can produce an unbounded number of time series. To bound a
+// string label, declare its values with the "values" struct tag. Values that
+// are not declared are recorded as "other". For example,
+//
+//	struct {
+//	    Currency string `values:"USD,EUR,JPY"` // "USD", "EUR", "JPY", or "other"
+//	    Cached   bool
+//	}
 type CounterMap[L comparable] struct {
 	impl *metrics.MetricMap[L]
 }
@@ -210,3 +210,25 @@ func TestHistogramMap(t *testing.T) {
 		Counts: []uint64{0, 0, 0, 1},
 	})
 }
+
+func TestCounterMapDeclaredValues(t *testing.T) {
+	type labels struct {
+		Status string `values:"ok,error"`
+	}
+	c := metrics.NewCounterMap[labels](uuid.New().String(), "")
+	c.Get(labels{"ok"}).Add(1)
+	c.Get(labels{"teapot"}).Add(1)
+	c.Get(labels{"gone"}).Add(1)
+	expect(t, &imetrics.MetricSnapshot{
+		Type:   protos.MetricType_COUNTER,
+		Name:   c.Name(),
+		Labels: map[string]string{"status": "ok"},
+		Value:  1,
+	})
+	expect(t, &imetrics.MetricSnapshot{
+		Type:   protos.MetricType_COUNTER,
+		Name:   c.Name(),
+		Labels: map[string]string{"status": imetrics.OtherLabelValue},
+		Value:  2,
+	})
+}
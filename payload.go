@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"fmt"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+)
+
+type payloadLabels struct {
+	Component string // full callee component name
+	Method    string // callee component method's name
+	Direction string `values:"request,response"` // the arguments, or the results
+}
+
+var (
+	methodPayloadBytes = metrics.NewHistogramMap[payloadLabels](
+		"serviceweaver_method_payload_bytes",
+		"Size, in bytes, of the serialized arguments (request) and results (response) of the Service Weaver component method calls served",
+		[]float64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20},
+	)
+	methodPayloadsTooLarge = metrics.NewCounterMap[payloadLabels](
+		"serviceweaver_method_payload_too_large_count",
+		"Count of Service Weaver component method calls failed because their arguments (request) or results (response) exceeded the size limit of the method",
+	)
+)
+
+// payloadLimits bounds the size of the serialized arguments and results of
+// the remote calls to a method. Limits are set in the config, per component
+// and per method:
+//
+//	[serviceweaver.components."github.com/my/project/catalog/T"]
+//	max_request_bytes = 65536       # 64 KiB of arguments
+//
+//	[serviceweaver.methods."github.com/my/project/catalog/T.ListProducts"]
+//	max_response_bytes = 4194304    # 4 MiB of results
+//
+// A method inherits every limit it doesn't set from its component. The caller
+// checks the arguments before sending them, and the callee checks them again
+// on receipt, in case the caller runs with another config. The callee checks
+// the results before sending them back. Calls that exceed a limit fail with
+// ErrPayloadTooLarge. Only remote calls are checked; calls to colocated
+// components are regular function calls. The messages of streaming methods
+// are not limited.
+type payloadLimits struct {
+	component   string // called component
+	method      string // called method
+	maxRequest  int64  // if positive, the maximum size of the arguments
+	maxResponse int64  // if positive, the maximum size of the results
+}
+
+// payloadLimits returns the payload limits of the provided method. The
+// returned limits are never nil, but they may not limit anything.
+func (w *weavelet) payloadLimits(component, method string) *payloadLimits {
+	config := w.componentConfigs[component]
+	override := w.methodConfigs[component+"."+method].CallConfig
+	if override.MaxRequestBytes != 0 {
+		config.MaxRequestBytes = override.MaxRequestBytes
+	}
+	if override.MaxResponseBytes != 0 {
+		config.MaxResponseBytes = override.MaxResponseBytes
+	}
+	return &payloadLimits{
+		component:   component,
+		method:      method,
+		maxRequest:  config.MaxRequestBytes,
+		maxResponse: config.MaxResponseBytes,
+	}
+}
+
+// checkRequest returns a PayloadTooLarge error if n bytes of arguments exceed
+// the limits, or nil otherwise.
+func (p *payloadLimits) checkRequest(n int) error {
+	if p.maxRequest <= 0 || int64(n) <= p.maxRequest {
+		return nil
+	}
+	methodPayloadsTooLarge.Get(payloadLabels{Component: p.component, Method: p.method, Direction: "request"}).Add(1)
+	return fmt.Errorf("%w: arguments of %s.%s are %d bytes, more than max_request_bytes %d", call.PayloadTooLarge, p.component, p.method, n, p.maxRequest)
+}
+
+// checkResponse returns a PayloadTooLarge error if n bytes of results exceed
+// the limits, or nil otherwise.
+func (p *payloadLimits) checkResponse(n int) error {
+	if p.maxResponse <= 0 || int64(n) <= p.maxResponse {
+		return nil
+	}
+	methodPayloadsTooLarge.Get(payloadLabels{Component: p.component, Method: p.method, Direction: "response"}).Add(1)
+	return fmt.Errorf("%w: results of %s.%s are %d bytes, more than max_response_bytes %d", call.PayloadTooLarge, p.component, p.method, n, p.maxResponse)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+func TestPayloadLimitOverrides(t *testing.T) {
+	w := &weavelet{
+		componentConfigs: map[string]runtime.CallConfig{
+			"a/T": {MaxRequestBytes: 100, MaxResponseBytes: 1000},
+		},
+		methodConfigs: map[string]runtime.MethodConfig{
+			"a/T.M": {CallConfig: runtime.CallConfig{MaxResponseBytes: 10}},
+		},
+	}
+	for _, test := range []struct {
+		component, method string
+		want              payloadLimits
+	}{
+		{"a/T", "M", payloadLimits{component: "a/T", method: "M", maxRequest: 100, maxResponse: 10}},
+		{"a/T", "N", payloadLimits{component: "a/T", method: "N", maxRequest: 100, maxResponse: 1000}},
+		{"a/U", "M", payloadLimits{component: "a/U", method: "M"}},
+	} {
+		if got := *w.payloadLimits(test.component, test.method); got != test.want {
+			t.Errorf("payloadLimits(%s, %s): got %+v, want %+v", test.component, test.method, got, test.want)
+		}
+	}
+}
+
+func TestPayloadLimitChecks(t *testing.T) {
+	limits := &payloadLimits{component: "a/T", method: "M", maxRequest: 10, maxResponse: 20}
+	for _, test := range []struct {
+		name    string
+		check   func(int) error
+		n       int
+		wantErr bool
+	}{
+		{"SmallRequest", limits.checkRequest, 10, false},
+		{"LargeRequest", limits.checkRequest, 11, true},
+		{"SmallResponse", limits.checkResponse, 20, false},
+		{"LargeResponse", limits.checkResponse, 21, true},
+		{"Unlimited", (&payloadLimits{}).checkRequest, 1 << 30, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.check(test.n)
+			if test.wantErr != errors.Is(err, call.PayloadTooLarge) {
+				t.Fatalf("check(%d): got %v, want PayloadTooLarge: %t", test.n, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestStubPayloadLimit(t *testing.T) {
+	// Oversized arguments are never sent.
+	client := &flakyClient{}
+	s := stub{
+		client:  client,
+		methods: []call.MethodKey{call.MakeMethodKey("a/T", "M")},
+		limits:  []*payloadLimits{{component: "a/T", method: "M", maxRequest: 4}},
+	}
+	if _, err := s.Run(context.Background(), 0, []byte("short"), 0); !errors.Is(s.WrapError(err), ErrPayloadTooLarge) {
+		t.Fatalf("Run: got %v, want ErrPayloadTooLarge", err)
+	}
+	if client.calls != 0 {
+		t.Fatalf("got %d remote calls, want 0", client.calls)
+	}
+	if _, err := s.Run(context.Background(), 0, []byte("ok"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 1 {
+		t.Fatalf("got %d remote calls, want 1", client.calls)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
+)
+
+// Priority is the priority class of a component method call. When a process
+// hosting a component is overloaded, calls of higher priority are run before
+// calls of lower priority, and calls of lower priority are shed first. Shed
+// calls fail with ErrShedLoad.
+//
+// Admission control is enabled in the config:
+//
+//	[serviceweaver]
+//	max_concurrent_calls = 1000  # run at most 1000 calls at a time
+//	max_queued_calls = 100       # queue at most 100 calls per priority class
+//
+// The default priority of calls to a method is set in the method's config,
+// and can be overridden per call with WithPriority:
+//
+//	[serviceweaver.methods."github.com/my/project/ads/T.GetAds"]
+//	priority = "low"
+//
+// The priority of the calls made by a component can also be set, overriding
+// the priorities of the methods it calls:
+//
+//	[serviceweaver.priorities]
+//	"github.com/my/project/indexer/T" = "low"
+//
+// The per-component admission control set under [serviceweaver.admission]
+// also runs and sheds queued calls by priority.
+//
+// Priorities only apply to remote calls; calls to colocated components are
+// regular function calls.
+type Priority uint8
+
+const (
+	LowPriority    = Priority(call.LowPriority)
+	NormalPriority = Priority(call.NormalPriority)
+	HighPriority   = Priority(call.HighPriority)
+)
+
+// String returns the name of the priority, as used in the config.
+func (p Priority) String() string {
+	return call.Priority(p).String()
+}
+
+type shedLabels struct {
+	Priority string // priority class of the shed calls
+}
+
+var shedCalls = metrics.NewCounterMap[shedLabels](
+	"serviceweaver_shed_call_count",
+	"Count of Service Weaver component method calls shed by admission control",
+)
+
+// priorityKey is the context key used by WithPriority.
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx that, when passed to a component method
+// call, runs the call with the provided priority, overriding the priorities
+// of the caller and the method in the config. For example:
+//
+//	// Keep the checkout path alive under load.
+//	err := checkout.PlaceOrder(weaver.WithPriority(ctx, weaver.HighPriority), order)
+//
+// The priority only applies to the call it is passed to; calls made by the
+// callee have their own priority.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityFromContext returns the priority stored in ctx by WithPriority, if
+// any.
+func priorityFromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	return p, ok
+}
+
+// parsePriority parses a priority specified in the config. The empty string
+// is parsed as NormalPriority.
+func parsePriority(s string) Priority {
+	switch s {
+	case "low":
+		return LowPriority
+	case "high":
+		return HighPriority
+	default:
+		return NormalPriority
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+)
+
+// priorityClient is a call.Connection that records the priority of the last
+// call made on it.
+type priorityClient struct {
+	last call.Priority
+}
+
+func (c *priorityClient) Call(_ context.Context, _ call.MethodKey, _ []byte, opts call.CallOptions) ([]byte, error) {
+	c.last = opts.Priority
+	return nil, nil
+}
+
+func (c *priorityClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, fmt.Errorf("streaming calls not supported")
+}
+
+func (c *priorityClient) Close() {}
+
+func TestStubPriority(t *testing.T) {
+	client := &priorityClient{}
+	s := &stub{
+		client:   client,
+		methods:  make([]call.MethodKey, 2),
+		priority: []Priority{NormalPriority, parsePriority("low")},
+	}
+	for _, test := range []struct {
+		name   string
+		ctx    context.Context
+		method int
+		want   call.Priority
+	}{
+		{"Default", context.Background(), 0, call.NormalPriority},
+		{"Config", context.Background(), 1, call.LowPriority},
+		{"Override", WithPriority(context.Background(), HighPriority), 1, call.HighPriority},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := s.Run(test.ctx, test.method, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+			if got := client.last; got != test.want {
+				t.Fatalf("priority: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWrapShedLoad(t *testing.T) {
+	err := (&stub{}).WrapError(fmt.Errorf("%w: low priority queue full", call.ShedLoad))
+	if !errors.Is(err, ErrShedLoad) {
+		t.Fatalf("errors.Is(%v, ErrShedLoad) = false, want true", err)
+	}
+	if errors.Is(err, ErrRetriable) {
+		t.Fatalf("errors.Is(%v, ErrRetriable) = true, want false", err)
+	}
+}
+
+func TestCallerPriority(t *testing.T) {
+	client := &priorityClient{}
+	s := &stub{
+		client:   client,
+		methods:  make([]call.MethodKey, 1),
+		priority: []Priority{NormalPriority},
+	}
+	w := &weavelet{callerPriority: map[string]Priority{"a/Indexer": LowPriority}}
+	for _, test := range []struct {
+		name   string
+		caller string
+		ctx    context.Context
+		want   call.Priority
+	}{
+		{"Default", "a/Frontend", context.Background(), call.NormalPriority},
+		{"Caller", "a/Indexer", context.Background(), call.LowPriority},
+		{"Override", "a/Indexer", WithPriority(context.Background(), HighPriority), call.HighPriority},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := w.callerStub(s, test.caller).Run(test.ctx, 0, nil, 0); err != nil {
+				t.Fatal(err)
+			}
+			if got := client.last; got != test.want {
+				t.Fatalf("priority: got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
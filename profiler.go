@@ -0,0 +1,182 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/envelope/conn"
+	"github.com/ServiceWeaver/weaver/internal/profexport"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+type profileLabels struct {
+	Type    string // "cpu", "heap", or "mutex"
+	Outcome string // "collected" or "failed"
+}
+
+var continuousProfiles = metrics.NewCounterMap[profileLabels](
+	"serviceweaver_continuous_profile_count",
+	"Count of profiles collected by the Service Weaver continuous profiler",
+)
+
+// profiler is the continuous profiler of a weavelet. It profiles the process
+// once per interval, and sends every profile to the deployer and to the
+// profiling backends. See runtime.ProfilingConfig. A nil profiler profiles
+// nothing.
+type profiler struct {
+	config    runtime.ProfilingConfig
+	send      func(*protos.Profile) error // sends a profile to the deployer
+	exporters []profexport.Exporter
+	logger    *slog.Logger
+
+	mu         sync.Mutex
+	components map[string]bool // components hosted by the weavelet
+}
+
+// newProfiler returns a new profiler, or nil if continuous profiling is
+// disabled in the provided config.
+func newProfiler(config runtime.ProfilingConfig, send func(*protos.Profile) error, exporters []profexport.Exporter, logger *slog.Logger) *profiler {
+	if config.Interval == 0 {
+		return nil
+	}
+	return &profiler{
+		config:     config,
+		send:       send,
+		exporters:  exporters,
+		logger:     logger,
+		components: map[string]bool{},
+	}
+}
+
+// host records that the weavelet hosts the provided components, whose names
+// are attached to its profiles.
+func (p *profiler) host(components []string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range components {
+		p.components[c] = true
+	}
+}
+
+// run profiles the process until ctx is done, and then closes the exporters.
+func (p *profiler) run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	defer func() {
+		for _, e := range p.exporters {
+			if err := e.Close(); err != nil {
+				p.logger.Error("close profile exporter", err)
+			}
+		}
+	}()
+
+	// Spread the profiles of replicas started together over the interval.
+	delay := time.NewTimer(time.Duration(rand.Int63n(int64(p.config.Interval))))
+	defer delay.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-delay.C:
+	}
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		for _, t := range p.config.Types {
+			if ctx.Err() != nil {
+				return
+			}
+			profile, err := p.profile(t)
+			if err != nil {
+				continuousProfiles.Get(profileLabels{Type: t, Outcome: "failed"}).Add(1)
+				p.logger.Error("continuous profile", err, "type", t)
+				continue
+			}
+			continuousProfiles.Get(profileLabels{Type: t, Outcome: "collected"}).Add(1)
+			p.export(ctx, profile)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// profile collects a profile of the provided type.
+func (p *profiler) profile(t string) (*protos.Profile, error) {
+	req := &protos.GetProfileRequest{}
+	switch t {
+	case "cpu":
+		req.ProfileType = protos.ProfileType_CPU
+		req.CpuDurationNs = p.config.CPUDuration.Nanoseconds()
+	case "heap":
+		req.ProfileType = protos.ProfileType_Heap
+	case "mutex":
+		req.ProfileType = protos.ProfileType_Mutex
+		req.CpuDurationNs = p.config.CPUDuration.Nanoseconds()
+	default:
+		return nil, fmt.Errorf("unknown profile type %q", t)
+	}
+
+	p.mu.Lock()
+	components := make([]string, 0, len(p.components))
+	for c := range p.components {
+		components = append(components, c)
+	}
+	p.mu.Unlock()
+	sort.Strings(components)
+
+	start := time.Now()
+	data, err := conn.Profile(req)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.Profile{
+		ProfileType:    req.ProfileType,
+		TimeMicros:     start.UnixMicro(),
+		DurationMicros: time.Duration(req.CpuDurationNs).Microseconds(),
+		Components:     components,
+		Data:           data,
+	}, nil
+}
+
+// export sends a profile to the deployer, and exports it to the profiling
+// backends. Failures are logged.
+func (p *profiler) export(ctx context.Context, profile *protos.Profile) {
+	if err := p.send(profile); err != nil {
+		p.logger.Error("send profile", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.config.Interval)
+	defer cancel()
+	for _, e := range p.exporters {
+		if err := e.Export(ctx, profile); err != nil {
+			p.logger.Error("export profile", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	pprof "github.com/google/pprof/profile"
+	"golang.org/x/exp/slog"
+)
+
+func TestProfiler(t *testing.T) {
+	config := runtime.ProfilingConfig{
+		Interval:    200 * time.Millisecond,
+		CPUDuration: 20 * time.Millisecond,
+		Types:       []string{"cpu", "heap"},
+	}
+	profiles := make(chan *protos.Profile, 10)
+	send := func(p *protos.Profile) error {
+		select {
+		case profiles <- p:
+		default:
+		}
+		return nil
+	}
+	logger := slog.New(slog.HandlerOptions{Level: slog.LevelError + 1}.NewTextHandler(io.Discard))
+	p := newProfiler(config, send, nil, logger)
+	p.host([]string{"app/Store", "app/Cart"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for _, want := range []protos.ProfileType{protos.ProfileType_CPU, protos.ProfileType_Heap} {
+		var got *protos.Profile
+		select {
+		case got = <-profiles:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %v profile sent", want)
+		}
+		if got.ProfileType != want {
+			t.Fatalf("profile type: got %v, want %v", got.ProfileType, want)
+		}
+		if diff := cmp.Diff([]string{"app/Cart", "app/Store"}, got.Components); diff != "" {
+			t.Fatalf("components (-want +got):\n%s", diff)
+		}
+		if _, err := pprof.ParseData(got.Data); err != nil {
+			t.Fatalf("%v profile: %v", want, err)
+		}
+	}
+}
+
+func TestProfilerDisabled(t *testing.T) {
+	p := newProfiler(runtime.ProfilingConfig{}, nil, nil, nil)
+	if p != nil {
+		t.Fatal("newProfiler: got a profiler, want nil")
+	}
+	// A nil profiler does nothing.
+	p.host([]string{"app/Cart"})
+	p.run(context.Background())
+}
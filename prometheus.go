@@ -0,0 +1,80 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/internal/otlp"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+)
+
+// serveMetrics serves the weavelet's metrics in the Prometheus text format at
+// /metrics on the provided address, as configured by the metrics_address
+// config key. It returns the URL of the endpoint.
+func (w *weavelet) serveMetrics(addr string) (string, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("metrics listener: %w", err)
+	}
+	startWork(w.ctx, "serve metrics", func() error {
+		return imetrics.ServePrometheus(w.ctx, lis, w.metricSnapshots)
+	})
+	return fmt.Sprintf("http://%s%s", lis.Addr(), imetrics.PrometheusPath), nil
+}
+
+// exportMetrics exports the weavelet's metrics over OTLP, as configured by
+// the [serviceweaver.otlp] config section.
+func (w *weavelet) exportMetrics() error {
+	exporter, err := otlp.NewMetricExporter(w.ctx, w.otlp)
+	if err != nil {
+		return fmt.Errorf("OTLP metric exporter: %w", err)
+	}
+	interval := w.otlp.MetricsInterval
+	if interval == 0 {
+		interval = runtime.DefaultOTLPMetricsInterval
+	}
+	snapshot := func() []*metrics.MetricSnapshot {
+		snapshots, _ := w.metricSnapshots(w.ctx)
+		return snapshots
+	}
+	startWork(w.ctx, "export metrics", func() error {
+		return otlp.ExportMetrics(w.ctx, w.env.SystemLogger(), exporter, w.resource, w.created, interval, snapshot)
+	})
+	return nil
+}
+
+// metricSnapshots returns snapshots of the metrics in this process, labeled
+// with the app, deployment, weavelet, and region they belong to, the same way
+// the deployer labels the metrics it aggregates.
+func (w *weavelet) metricSnapshots(context.Context) ([]*metrics.MetricSnapshot, error) {
+	snapshots := metrics.Snapshot()
+	for _, s := range snapshots {
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		s.Labels["serviceweaver_app"] = w.info.App
+		s.Labels["serviceweaver_version"] = w.info.DeploymentId
+		s.Labels["serviceweaver_node"] = w.info.Id
+		if w.info.Region != "" {
+			s.Labels["serviceweaver_region"] = w.info.Region
+		}
+	}
+	return snapshots, nil
+}
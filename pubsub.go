@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// defaultSubscriptionBuffer is the default value of SubscribeOptions.Buffer.
+const defaultSubscriptionBuffer = 64
+
+type topicLabels struct {
+	Topic string // the topic name
+}
+
+var (
+	publishedEvents = metrics.NewCounterMap[topicLabels](
+		"serviceweaver_pubsub_published_event_count",
+		"Count of Service Weaver events published on a topic",
+	)
+	droppedEvents = metrics.NewCounterMap[topicLabels](
+		"serviceweaver_pubsub_dropped_event_count",
+		"Count of Service Weaver events dropped because a subscriber was full",
+	)
+)
+
+// Publish publishes an event on the named topic. The event is delivered to
+// every subscription to the topic in the deployment, across all processes.
+// For example, a cart service can notify frontends that an item went out of
+// stock:
+//
+//	weaver.Publish(ctx, cart, "out-of-stock", []byte(productID))
+//
+// Topics are scoped to a deployment: subscribers in other deployments of the
+// same application, including other versions, do not receive the event.
+//
+// Delivery is best effort and at most once. Publish does not wait for the
+// event to be delivered, and an event may be lost, e.g., if a process fails
+// or a subscriber is too slow to keep up (see SubscribeOptions). Events
+// published by a single process on a single topic are received by every
+// subscriber in the order they were published. Events are not persisted, so
+// a subscription only receives the events published while it is open.
+//
+// Publish must not be used for state that needs to be delivered reliably; use
+// a component method call instead. Publish returns an error if the event
+// cannot be handed to the deployer; the event may have been delivered to
+// subscribers in the publishing process by then.
+func Publish(ctx context.Context, requester Instance, topic string, event []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w := requester.rep().wlet
+	publishedEvents.Get(topicLabels{Topic: topic}).Add(1)
+	w.topics.deliver(topic, event)
+	return w.env.PublishTopicEvent(&protos.TopicEvent{
+		Topic:     topic,
+		Payload:   event,
+		Publisher: w.info.Id,
+	})
+}
+
+// SubscribeOptions configure a subscription created by Subscribe.
+type SubscribeOptions struct {
+	// Buffer is the number of events buffered by the subscription's channel.
+	// When the buffer is full, new events are dropped rather than blocking
+	// the publisher or other subscribers. If Buffer is zero, a default of 64
+	// is used.
+	Buffer int
+}
+
+// A Subscription receives the events published on a topic. See Subscribe.
+type Subscription struct {
+	// C receives the events published on the topic. Events are shared with
+	// other subscribers and must not be modified. C is closed when the
+	// subscription is closed.
+	C <-chan []byte
+
+	topic  string
+	c      chan []byte
+	topics *topics
+}
+
+// Subscribe returns a new subscription to the named topic. The subscription
+// receives every event published on the topic, by any process in the
+// deployment, from the time Subscribe returns until the subscription is
+// closed. For example, a frontend can relay out-of-stock notifications to a
+// browser:
+//
+//	sub := weaver.Subscribe(root, "out-of-stock", weaver.SubscribeOptions{})
+//	defer sub.Close()
+//	for event := range sub.C {
+//	    fmt.Fprintf(w, "data: %s\n\n", event)
+//	    flusher.Flush()
+//	}
+//
+// A subscription is local to the process that created it and receives events
+// directly from the deployer, so it is unaffected by components being
+// started, restarted, or moved between processes; there is nothing to
+// reconnect. Events published while no subscription is open are lost.
+//
+// A subscriber that falls behind does not slow down publishers. Once a
+// subscription's buffer is full, further events are dropped for that
+// subscription, and counted by the serviceweaver_pubsub_dropped_event_count
+// metric, until the subscriber catches up.
+func Subscribe(requester Instance, topic string, opts SubscribeOptions) *Subscription {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+	return requester.rep().wlet.topics.subscribe(topic, buffer)
+}
+
+// Close closes the subscription. Close closes s.C, after which no more events
+// are received. It is safe to call Close more than once.
+func (s *Subscription) Close() {
+	s.topics.unsubscribe(s)
+}
+
+// topics holds the subscriptions in a weavelet.
+type topics struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{} // subscriptions, by topic
+}
+
+func newTopics() *topics {
+	return &topics{subs: map[string]map[*Subscription]struct{}{}}
+}
+
+// subscribe returns a new subscription to the provided topic.
+func (t *topics) subscribe(topic string, buffer int) *Subscription {
+	c := make(chan []byte, buffer)
+	s := &Subscription{C: c, topic: topic, c: c, topics: t}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs[topic] == nil {
+		t.subs[topic] = map[*Subscription]struct{}{}
+	}
+	t.subs[topic][s] = struct{}{}
+	return s
+}
+
+// unsubscribe removes the provided subscription and closes its channel, if it
+// hasn't been removed already.
+func (t *topics) unsubscribe(s *Subscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := t.subs[s.topic]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(t.subs, s.topic)
+	}
+	close(s.c)
+}
+
+// deliver delivers an event to the local subscriptions to the provided topic,
+// dropping the event for subscriptions whose buffer is full.
+func (t *topics) deliver(topic string, event []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for s := range t.subs[topic] {
+		select {
+		case s.c <- event:
+		default:
+			droppedEvents.Get(topicLabels{Topic: topic}).Add(1)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// recv returns the events buffered in s.
+func recv(s *Subscription) []string {
+	var events []string
+	for {
+		select {
+		case e := <-s.C:
+			events = append(events, string(e))
+		default:
+			return events
+		}
+	}
+}
+
+func TestTopicsDeliver(t *testing.T) {
+	// Test plan: Subscribe twice to one topic and once to another. Check that
+	// events are delivered, in order, to the subscriptions of their topic
+	// only.
+	topics := newTopics()
+	a1 := topics.subscribe("a", 10)
+	a2 := topics.subscribe("a", 10)
+	b := topics.subscribe("b", 10)
+	topics.deliver("a", []byte("1"))
+	topics.deliver("a", []byte("2"))
+	topics.deliver("c", []byte("3"))
+
+	for _, s := range []*Subscription{a1, a2} {
+		if got, want := recv(s), []string{"1", "2"}; !slices.Equal(got, want) {
+			t.Fatalf("events: got %v, want %v", got, want)
+		}
+	}
+	if got := recv(b); len(got) != 0 {
+		t.Fatalf("events: got %v, want none", got)
+	}
+}
+
+func TestTopicsSlowSubscriber(t *testing.T) {
+	// Test plan: Deliver more events than a subscription buffers. Check that
+	// the extra events are dropped, and that delivery doesn't block.
+	topics := newTopics()
+	s := topics.subscribe("a", 2)
+	for _, e := range []string{"1", "2", "3"} {
+		topics.deliver("a", []byte(e))
+	}
+	if got, want := recv(s), []string{"1", "2"}; !slices.Equal(got, want) {
+		t.Fatalf("events: got %v, want %v", got, want)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	// Test plan: Close a subscription twice. Check that its channel is
+	// closed and that it stops receiving events.
+	topics := newTopics()
+	s := topics.subscribe("a", 10)
+	s.Close()
+	s.Close()
+	topics.deliver("a", []byte("1"))
+	if _, ok := <-s.C; ok {
+		t.Fatal("subscription channel not closed")
+	}
+	if n := len(topics.subs); n != 0 {
+		t.Fatalf("topics: got %d, want 0", n)
+	}
+}
@@ -0,0 +1,745 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/kv"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultQueueMaxAttempts is the default QueueOptions.MaxAttempts.
+	DefaultQueueMaxAttempts = 5
+
+	// DefaultQueueRetryBackoff is the default QueueOptions.RetryBackoff.
+	DefaultQueueRetryBackoff = time.Second
+
+	// DefaultQueueConcurrency is the default QueueOptions.Concurrency.
+	DefaultQueueConcurrency = 10
+
+	// DefaultQueuePollInterval is the default QueueOptions.PollInterval.
+	DefaultQueuePollInterval = time.Second
+)
+
+// maxQueueRetryBackoff bounds the backoff between two attempts of a job.
+const maxQueueRetryBackoff = 10 * time.Minute
+
+type queueLabels struct {
+	Queue string // the queue name
+}
+
+type queueJobLabels struct {
+	Queue   string // the queue name
+	Outcome string // "succeeded", "retried", or "dead"
+}
+
+var (
+	queueJobsEnqueued = metrics.NewCounterMap[queueLabels](
+		"serviceweaver_queue_enqueued_count",
+		"Count of jobs enqueued on Service Weaver queues by the process",
+	)
+	queueJobAttempts = metrics.NewCounterMap[queueJobLabels](
+		"serviceweaver_queue_attempt_count",
+		"Count of attempts of Service Weaver queue jobs by the process, by outcome",
+	)
+)
+
+// QueueOptions configure a Queue.
+type QueueOptions struct {
+	// MaxAttempts is how many times a job is attempted before it is moved to
+	// the dead letters of the queue. If zero, DefaultQueueMaxAttempts is used.
+	MaxAttempts int
+
+	// RetryBackoff is how long to wait before attempting a failed job again.
+	// It doubles after every failed attempt, up to ten minutes. If zero,
+	// DefaultQueueRetryBackoff is used.
+	RetryBackoff time.Duration
+
+	// Concurrency is how many jobs a process that processes the queue runs
+	// at once. If zero, DefaultQueueConcurrency is used.
+	Concurrency int
+
+	// PollInterval is how often a process that processes the queue looks for
+	// jobs that are due. Jobs enqueued by the process itself are run right
+	// away. If zero, DefaultQueuePollInterval is used.
+	PollInterval time.Duration
+
+	// LeaseDuration is how long a process remains the only one running a
+	// job after it last renewed the job's lease, which it does every third
+	// of LeaseDuration. If zero, DefaultLeaseDuration is used.
+	LeaseDuration time.Duration
+}
+
+// EnqueueOptions configure a job enqueued with Queue.Enqueue.
+type EnqueueOptions struct {
+	// ID is the ID of the job. If a job with the same ID is queued or in the
+	// dead letters, the job is not enqueued again. If empty, a random ID is
+	// used.
+	ID string
+
+	// Delay is how long to wait before running the job.
+	Delay time.Duration
+}
+
+// A Queue is a durable queue of background jobs of type T. Jobs are persisted
+// in a store, and run by the processes that call Process, typically in the
+// Init method of a designated component, outside of the request path of the
+// components that enqueue them. A job that fails is retried with backoff; if
+// it keeps failing, it is moved to the dead letters of the queue, where it
+// can be inspected, requeued, or discarded. For example, a checkout service
+// can send order confirmation emails in the background with:
+//
+//	type confirmation struct {
+//	    weaver.AutoMarshal
+//	    Email string
+//	    Order Order
+//	}
+//
+//	func (c *checkout) Init(context.Context) error {
+//	    var err error
+//	    c.confirmations, err = weaver.NewQueue[confirmation](c, "confirmations", weaver.QueueOptions{})
+//	    return err
+//	}
+//
+//	func (c *checkout) PlaceOrder(ctx context.Context, req PlaceOrderRequest) (Order, error) {
+//	    ...
+//	    _, err := c.confirmations.Enqueue(ctx, confirmation{Email: req.Email, Order: order},
+//	        weaver.EnqueueOptions{ID: order.ID})
+//	    return order, err
+//	}
+//
+//	func (e *emailer) Init(context.Context) error {
+//	    confirmations, err := weaver.NewQueue[confirmation](e, "confirmations", weaver.QueueOptions{})
+//	    if err != nil {
+//	        return err
+//	    }
+//	    return confirmations.Process(func(ctx context.Context, c confirmation) error {
+//	        return e.send(ctx, c.Email, c.Order)
+//	    })
+//	}
+//
+// A job may run more than once, e.g., if its process fails before the job's
+// completion is persisted, so handlers should be idempotent.
+//
+// The jobs of a queue with name "confirmations" are kept in the store with
+// name "queues/confirmations". The default store is in memory, and private to
+// every process; for the processes that enqueue and process jobs to share a
+// queue, and for jobs to survive their processes, configure the store in the
+// config file, e.g.:
+//
+//	[serviceweaver.stores."queues/confirmations"]
+//	backend = "redis"
+//	address = "redis.internal:6379"
+//
+// The jobs of type T are serialized with the Service Weaver encoding, so T
+// must be a struct that embeds weaver.AutoMarshal.
+type Queue[T any] struct {
+	w    *weavelet
+	name string
+	opts QueueOptions
+}
+
+// queueRecord is a persisted job.
+type queueRecord struct {
+	Job      []byte    `json:"job"`                // the encoded job
+	Enqueued time.Time `json:"enqueued"`           // when the job was enqueued
+	Due      time.Time `json:"due"`                // when the job is next run
+	Attempts int       `json:"attempts,omitempty"` // the number of failed attempts
+	Error    string    `json:"error,omitempty"`    // the error of the last attempt, if any
+	Dead     bool      `json:"dead,omitempty"`     // is the job a dead letter?
+	Failed   time.Time `json:"failed,omitempty"`   // when the job became a dead letter
+}
+
+// A DeadLetter is a job that failed every one of its attempts.
+type DeadLetter[T any] struct {
+	ID       string    // the ID of the job
+	Job      T         // the job
+	Enqueued time.Time // when the job was enqueued
+	Failed   time.Time // when the last attempt failed
+	Attempts int       // the number of attempts
+	Error    string    // the error of the last attempt
+}
+
+// NewQueue returns the queue with the provided name. Every process that
+// creates a queue with the same name must create it with the same type and
+// options. A process may create a queue with a given name more than once,
+// e.g., in the Init methods of two components, but only one of them may
+// process it.
+func NewQueue[T any](requester Instance, name string, opts QueueOptions) (*Queue[T], error) {
+	return newQueue[T](requester.rep().wlet, name, opts)
+}
+
+// newQueue returns a new queue used by the provided weavelet.
+func newQueue[T any](w *weavelet, name string, opts QueueOptions) (*Queue[T], error) {
+	if _, ok := any(new(T)).(codegen.AutoMarshal); !ok {
+		var zero T
+		return nil, fmt.Errorf("NewQueue(%q): job type %T does not embed weaver.AutoMarshal", name, zero)
+	}
+	switch {
+	case name == "":
+		return nil, fmt.Errorf("NewQueue: empty name")
+	case opts.MaxAttempts < 0:
+		return nil, fmt.Errorf("NewQueue(%q): negative max attempts %d", name, opts.MaxAttempts)
+	case opts.RetryBackoff < 0:
+		return nil, fmt.Errorf("NewQueue(%q): negative retry backoff %v", name, opts.RetryBackoff)
+	case opts.Concurrency < 0:
+		return nil, fmt.Errorf("NewQueue(%q): negative concurrency %d", name, opts.Concurrency)
+	case opts.PollInterval < 0:
+		return nil, fmt.Errorf("NewQueue(%q): negative poll interval %v", name, opts.PollInterval)
+	case opts.LeaseDuration < 0:
+		return nil, fmt.Errorf("NewQueue(%q): negative lease duration %v", name, opts.LeaseDuration)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = DefaultQueueMaxAttempts
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = DefaultQueueRetryBackoff
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = DefaultQueueConcurrency
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = DefaultQueuePollInterval
+	}
+	if opts.LeaseDuration == 0 {
+		opts.LeaseDuration = DefaultLeaseDuration
+	}
+	return &Queue[T]{w: w, name: name, opts: opts}, nil
+}
+
+// Name returns the name of the queue.
+func (q *Queue[T]) Name() string {
+	return q.name
+}
+
+// Enqueue enqueues the provided job, and returns its ID once the job is
+// persisted. The job runs in the background, once the delay of the provided
+// options has elapsed.
+func (q *Queue[T]) Enqueue(ctx context.Context, job T, opts EnqueueOptions) (string, error) {
+	if opts.Delay < 0 {
+		return "", fmt.Errorf("queue %q: negative delay %v", q.name, opts.Delay)
+	}
+	backend, err := q.backend(ctx)
+	if err != nil {
+		return "", err
+	}
+	id := opts.ID
+	if id == "" {
+		id = uuid.New().String()
+	} else if _, ok, err := q.get(ctx, backend, id); err != nil || ok {
+		return id, err
+	}
+	now := q.w.clock.Now()
+	enc := codegen.NewEncoder()
+	any(&job).(codegen.AutoMarshal).WeaverMarshal(enc)
+	rec := &queueRecord{Job: enc.Data(), Enqueued: now, Due: now.Add(opts.Delay)}
+	if err := q.put(ctx, backend, id, rec); err != nil {
+		return "", err
+	}
+	queueJobsEnqueued.Get(queueLabels{Queue: q.name}).Add(1)
+	if opts.Delay == 0 {
+		q.w.queues.wake(q.name)
+	}
+	return id, nil
+}
+
+// Process runs the jobs of the queue with the provided handler, in the
+// background, until the process drains. A job whose handler returns an error
+// is retried, up to the maximum number of attempts of the queue. A process
+// may process a queue with a given name only once.
+func (q *Queue[T]) Process(handler func(context.Context, T) error) error {
+	p, err := q.newProcessor(handler)
+	if err != nil {
+		return err
+	}
+	q.w.onDrain(p.drain)
+	go p.run()
+	return nil
+}
+
+// newProcessor returns a processor of the queue that runs jobs with the
+// provided handler.
+func (q *Queue[T]) newProcessor(handler func(context.Context, T) error) (*queueProcessor[T], error) {
+	if handler == nil {
+		return nil, fmt.Errorf("queue %q: nil handler", q.name)
+	}
+	wake, err := q.w.queues.add(q.name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &queueProcessor[T]{
+		q:       q,
+		handler: handler,
+		wake:    wake,
+		ctx:     ctx,
+		cancel:  cancel,
+		running: map[string]chan struct{}{},
+	}, nil
+}
+
+// DeadLetters returns the dead letters of the queue, in the order they
+// failed.
+func (q *Queue[T]) DeadLetters(ctx context.Context) ([]DeadLetter[T], error) {
+	backend, err := q.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := backend.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("queue %q: list jobs: %w", q.name, err)
+	}
+	var letters []DeadLetter[T]
+	for _, id := range ids {
+		rec, ok, err := q.get(ctx, backend, id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !rec.Dead {
+			continue
+		}
+		letter := DeadLetter[T]{
+			ID:       id,
+			Enqueued: rec.Enqueued,
+			Failed:   rec.Failed,
+			Attempts: rec.Attempts,
+			Error:    rec.Error,
+		}
+		if err := decodeMessage(rec.Job, any(&letter.Job).(codegen.AutoMarshal)); err != nil {
+			return nil, fmt.Errorf("queue %q: decode job %q: %w", q.name, id, err)
+		}
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].Failed.Before(letters[j].Failed)
+	})
+	return letters, nil
+}
+
+// Requeue moves the dead letter with the provided ID back to the queue,
+// where it is attempted again as many times as a new job.
+func (q *Queue[T]) Requeue(ctx context.Context, id string) error {
+	backend, err := q.backend(ctx)
+	if err != nil {
+		return err
+	}
+	rec, err := q.getDead(ctx, backend, id)
+	if err != nil {
+		return err
+	}
+	rec.Due = q.w.clock.Now()
+	rec.Attempts = 0
+	rec.Error = ""
+	rec.Dead = false
+	rec.Failed = time.Time{}
+	if err := q.put(ctx, backend, id, rec); err != nil {
+		return err
+	}
+	q.w.queues.wake(q.name)
+	return nil
+}
+
+// Discard deletes the dead letter with the provided ID.
+func (q *Queue[T]) Discard(ctx context.Context, id string) error {
+	backend, err := q.backend(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := q.getDead(ctx, backend, id); err != nil {
+		return err
+	}
+	if err := backend.Delete(ctx, id); err != nil {
+		return fmt.Errorf("queue %q: delete job %q: %w", q.name, id, err)
+	}
+	return nil
+}
+
+// getDead returns the record of the dead letter with the provided ID, or an
+// error if there is no such dead letter.
+func (q *Queue[T]) getDead(ctx context.Context, backend kv.Backend, id string) (*queueRecord, error) {
+	rec, ok, err := q.get(ctx, backend, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || !rec.Dead {
+		return nil, fmt.Errorf("queue %q: dead letter %q not found", q.name, id)
+	}
+	return rec, nil
+}
+
+// backend returns the backend of the store of the queue.
+func (q *Queue[T]) backend(ctx context.Context) (kv.Backend, error) {
+	return q.w.stores.backend(ctx, "queues/"+q.name)
+}
+
+// get returns the record of the job with the provided ID, and whether the
+// job exists.
+func (q *Queue[T]) get(ctx context.Context, backend kv.Backend, id string) (*queueRecord, bool, error) {
+	data, ok, err := backend.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var rec queueRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false, fmt.Errorf("queue %q: decode job %q: %w", q.name, id, err)
+	}
+	return &rec, true, nil
+}
+
+// put persists the record of the job with the provided ID.
+func (q *Queue[T]) put(ctx context.Context, backend kv.Backend, id string, rec *queueRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, id, data)
+}
+
+// queueProcessor runs the jobs of a queue in a process.
+type queueProcessor[T any] struct {
+	q       *Queue[T]
+	handler func(context.Context, T) error
+	wake    <-chan struct{}    // receives when jobs may be due
+	ctx     context.Context    // done once the process drains
+	cancel  context.CancelFunc // cancels ctx
+
+	mu      sync.Mutex
+	running map[string]chan struct{} // jobs run by the process, closed once done
+}
+
+// run runs the due jobs, every poll interval or when woken up, until the
+// process drains.
+func (p *queueProcessor[T]) run() {
+	ticker := p.q.w.clock.NewTicker(p.q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.poll(p.ctx); err != nil && p.ctx.Err() == nil {
+			p.q.w.env.SystemLogger().Error("poll queue", err, "queue", p.q.name)
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+		case <-p.wake:
+		}
+	}
+}
+
+// poll starts the due jobs that no process runs, earliest first, up to the
+// concurrency of the queue.
+func (p *queueProcessor[T]) poll(ctx context.Context) error {
+	backend, err := p.q.backend(ctx)
+	if err != nil {
+		return err
+	}
+	ids, err := backend.Keys(ctx)
+	if err != nil {
+		return fmt.Errorf("queue %q: list jobs: %w", p.q.name, err)
+	}
+	var due []queueDueJob
+	now := p.q.w.clock.Now()
+	for _, id := range ids {
+		p.mu.Lock()
+		_, running := p.running[id]
+		p.mu.Unlock()
+		if running {
+			continue
+		}
+		rec, ok, err := p.q.get(ctx, backend, id)
+		if err != nil {
+			return err
+		}
+		if ok && !rec.Dead && !rec.Due.After(now) {
+			due = append(due, queueDueJob{id, rec.Due})
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+
+	for _, job := range due {
+		done, ok := p.reserve(job.id)
+		if !ok {
+			// The process runs as many jobs as it may.
+			return nil
+		}
+		expires, acquired, err := p.acquire(ctx, job.id)
+		if err != nil || !acquired {
+			// Another process runs the job.
+			p.finish(job.id, done)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		go p.runJob(job.id, expires, done)
+	}
+	return nil
+}
+
+// queueDueJob is a job that is due.
+type queueDueJob struct {
+	id  string
+	due time.Time // when the job became due
+}
+
+// reserve reserves a slot to run the job with the provided ID, and returns
+// the channel to close once the job is done. It returns false if the process
+// runs as many jobs as it may, or drains.
+func (p *queueProcessor[T]) reserve(id string) (chan struct{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil || len(p.running) >= p.q.opts.Concurrency {
+		return nil, false
+	}
+	done := make(chan struct{})
+	p.running[id] = done
+	return done, true
+}
+
+// finish releases the slot of the job with the provided ID.
+func (p *queueProcessor[T]) finish(id string, done chan struct{}) {
+	p.mu.Lock()
+	delete(p.running, id)
+	p.mu.Unlock()
+	close(done)
+}
+
+// runJob runs the job with the provided ID, whose lease the process holds
+// until the provided time, and records its outcome.
+func (p *queueProcessor[T]) runJob(id string, expires time.Time, done chan struct{}) {
+	// Jobs are not cancelled when the process drains, so that they don't stop
+	// halfway, but they are cancelled when the lease is lost.
+	ctx, cancel := context.WithCancel(context.Background())
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		p.renew(ctx, cancel, id, expires)
+	}()
+	if err := p.attempt(ctx, id); err != nil && ctx.Err() == nil {
+		// The job is attempted again by the next poll.
+		p.q.w.env.SystemLogger().Error("run queue job", err, "queue", p.q.name, "id", id)
+	}
+	cancel()
+	<-renewed
+	p.release(id)
+	p.finish(id, done)
+
+	// Look for the jobs that didn't fit while the job ran.
+	p.q.w.queues.wake(p.q.name)
+}
+
+// attempt runs the job with the provided ID once, and persists the outcome:
+// the job is deleted if it succeeds, and retried later, or moved to the dead
+// letters, if it fails.
+func (p *queueProcessor[T]) attempt(ctx context.Context, id string) error {
+	backend, err := p.q.backend(ctx)
+	if err != nil {
+		return err
+	}
+	// Read the job again, as another process may have run it since it was
+	// found due.
+	rec, ok, err := p.q.get(ctx, backend, id)
+	if err != nil || !ok || rec.Dead || rec.Due.After(p.q.w.clock.Now()) {
+		return err
+	}
+
+	job := new(T)
+	err = decodeMessage(rec.Job, any(job).(codegen.AutoMarshal))
+	if err != nil {
+		err = fmt.Errorf("decode job: %w", err)
+	} else {
+		err = p.handle(ctx, *job)
+	}
+	if ctx.Err() != nil {
+		// The lease was lost. The job is attempted again by the process that
+		// holds it.
+		return ctx.Err()
+	}
+	if err == nil {
+		queueJobAttempts.Get(queueJobLabels{Queue: p.q.name, Outcome: "succeeded"}).Add(1)
+		if err := backend.Delete(ctx, id); err != nil {
+			return fmt.Errorf("queue %q: delete job %q: %w", p.q.name, id, err)
+		}
+		return nil
+	}
+
+	now := p.q.w.clock.Now()
+	rec.Attempts++
+	rec.Error = err.Error()
+	if rec.Attempts >= p.q.opts.MaxAttempts {
+		rec.Dead = true
+		rec.Failed = now
+		queueJobAttempts.Get(queueJobLabels{Queue: p.q.name, Outcome: "dead"}).Add(1)
+		p.q.w.env.SystemLogger().Error("queue job moved to dead letters", err, "queue", p.q.name, "id", id, "attempts", rec.Attempts)
+	} else {
+		rec.Due = now.Add(queueBackoff(p.q.opts.RetryBackoff, rec.Attempts))
+		queueJobAttempts.Get(queueJobLabels{Queue: p.q.name, Outcome: "retried"}).Add(1)
+	}
+	return p.q.put(ctx, backend, id, rec)
+}
+
+// handle runs the handler on the provided job, and turns a panic into an
+// error.
+func (p *queueProcessor[T]) handle(ctx context.Context, job T) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v", x)
+		}
+	}()
+	return p.handler(ctx, job)
+}
+
+// queueBackoff returns how long to wait before attempting a job again, after
+// the provided number of failed attempts.
+func queueBackoff(backoff time.Duration, attempts int) time.Duration {
+	for i := 1; i < attempts; i++ {
+		if backoff *= 2; backoff > maxQueueRetryBackoff {
+			return maxQueueRetryBackoff
+		}
+	}
+	return backoff
+}
+
+// renew renews the lease of the job with the provided ID until ctx is done,
+// and calls cancel if the lease is lost. The lease expires at the provided
+// time unless renewed.
+func (p *queueProcessor[T]) renew(ctx context.Context, cancel context.CancelFunc, id string, expires time.Time) {
+	// The lease is lost when it expires, even if a renewal is stuck.
+	clock := p.q.w.clock
+	expiry := afterFunc(clock, expires.Sub(clock.Now()), cancel)
+	defer expiry.Stop()
+	ticker := clock.NewTicker(p.q.opts.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		expires, acquired, err := p.acquire(ctx, id)
+		switch {
+		case err != nil:
+			// Try again, unless the lease expires first.
+			p.q.w.env.SystemLogger().Error("renew queue job lease", err, "queue", p.q.name, "id", id)
+		case !acquired:
+			// Another process holds the lease.
+			cancel()
+			return
+		default:
+			expiry.Reset(expires.Sub(clock.Now()))
+		}
+	}
+}
+
+// drain stops looking for jobs, and waits for the jobs run by the process to
+// finish. It is called when the process drains.
+func (p *queueProcessor[T]) drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.cancel()
+	running := make([]chan struct{}, 0, len(p.running))
+	for _, done := range p.running {
+		running = append(running, done)
+	}
+	p.mu.Unlock()
+
+	for _, done := range running {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// acquire acquires, or renews, the lease of the job with the provided ID, and
+// returns when the lease expires unless renewed.
+func (p *queueProcessor[T]) acquire(ctx context.Context, id string) (time.Time, bool, error) {
+	start := p.q.w.clock.Now()
+	acquired, err := p.lease(ctx, id, false)
+	return start.Add(p.q.opts.LeaseDuration), acquired, err
+}
+
+// release releases the lease of the job with the provided ID, so that another
+// process can retry the job without waiting for the lease to expire.
+func (p *queueProcessor[T]) release(id string) {
+	if _, err := p.lease(context.Background(), id, true); err != nil {
+		p.q.w.env.SystemLogger().Error("release queue job lease", err, "queue", p.q.name, "id", id)
+	}
+}
+
+// lease acquires, renews, or releases the lease of the provided job.
+func (p *queueProcessor[T]) lease(ctx context.Context, id string, release bool) (bool, error) {
+	reply, err := p.q.w.env.Lease(ctx, &protos.LeaseRequest{
+		Election:       fmt.Sprintf("serviceweaver/queues/%s/%s", p.q.name, id),
+		Holder:         p.q.w.info.Id,
+		DurationMicros: p.q.opts.LeaseDuration.Microseconds(),
+		Release:        release,
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.Acquired, nil
+}
+
+// queueProcessors holds the queues processed by a weavelet, so that the jobs
+// enqueued by the weavelet run right away.
+type queueProcessors struct {
+	mu    sync.Mutex
+	wakes map[string]chan struct{} // by queue name
+}
+
+func newQueueProcessors() *queueProcessors {
+	return &queueProcessors{wakes: map[string]chan struct{}{}}
+}
+
+// add adds the provided queue, and returns the channel that receives when
+// jobs may be due. It returns an error if the queue was already added.
+func (q *queueProcessors) add(name string) (<-chan struct{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.wakes[name]; ok {
+		return nil, fmt.Errorf("queue %q already processed", name)
+	}
+	wake := make(chan struct{}, 1)
+	q.wakes[name] = wake
+	return wake, nil
+}
+
+// channel returns the channel of the provided queue, or nil if the queue is
+// not processed by the weavelet.
+func (q *queueProcessors) channel(name string) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.wakes[name]
+}
+
+// wake signals the processor of the provided queue, if any, that jobs may be
+// due.
+func (q *queueProcessors) wake(name string) {
+	select {
+	case q.channel(name) <- struct{}{}:
+	default:
+	}
+}
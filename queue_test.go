@@ -0,0 +1,317 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// cmpSorted compares slices of strings regardless of their order.
+var cmpSorted = cmpopts.SortSlices(func(x, y string) bool { return x < y })
+
+// testEmail is a test queue job.
+type testEmail struct {
+	To string
+}
+
+func (e *testEmail) WeaverMarshal(enc *codegen.Encoder) {
+	enc.String(e.To)
+}
+
+func (e *testEmail) WeaverUnmarshal(dec *codegen.Decoder) {
+	e.To = dec.String()
+}
+
+// testQueue returns a queue used by a weavelet with the provided id, whose
+// leases are granted by the provided table, and whose jobs are kept in the
+// provided stores.
+func testQueue(t *testing.T, table *lease.Table, s *stores, id string, opts QueueOptions) *Queue[testEmail] {
+	t.Helper()
+	w := &weavelet{
+		env:    leaseEnv{t: t, table: table},
+		info:   &protos.EnvelopeInfo{Id: id},
+		queues: newQueueProcessors(),
+		stores: s,
+		clock:  systemClock{},
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Millisecond
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 10 * time.Millisecond
+	}
+	q, err := newQueue[testEmail](w, "emails", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// process processes q with the provided handler until the test ends.
+func process(t *testing.T, q *Queue[testEmail], handler func(context.Context, testEmail) error) {
+	t.Helper()
+	p, err := q.newProcessor(handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.newProcessor(handler); err == nil {
+		t.Fatal("Process twice: unexpected success")
+	}
+	go p.run()
+	t.Cleanup(func() { p.drain(context.Background()) })
+}
+
+// sent records the recipients of the jobs handled by a test queue.
+type sent struct {
+	mu  sync.Mutex
+	to  []string
+	new chan struct{} // receives whenever a job is handled
+}
+
+func newSent() *sent {
+	return &sent{new: make(chan struct{}, 100)}
+}
+
+func (s *sent) handle(_ context.Context, e testEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.to = append(s.to, e.To)
+	s.new <- struct{}{}
+	return nil
+}
+
+// wait waits for n jobs to be handled, and returns their recipients.
+func (s *sent) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.new:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%d of %d jobs handled", i, n)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.to...)
+}
+
+func TestQueueProcesses(t *testing.T) {
+	ctx := context.Background()
+	q := testQueue(t, lease.NewTable(), newStores(nil), "w1", QueueOptions{})
+	s := newSent()
+	process(t, q, s.handle)
+
+	for _, to := range []string{"alice", "bob"} {
+		if _, err := q.Enqueue(ctx, testEmail{To: to}, EnqueueOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := s.wait(t, 2)
+	if diff := cmp.Diff([]string{"alice", "bob"}, got, cmpSorted); diff != "" {
+		t.Fatalf("handled (-want +got):\n%s", diff)
+	}
+}
+
+func TestQueueDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	q := testQueue(t, lease.NewTable(), newStores(nil), "w1", QueueOptions{})
+
+	// Enqueue the same job twice before processing the queue.
+	for _, to := range []string{"alice", "bob"} {
+		id, err := q.Enqueue(ctx, testEmail{To: to}, EnqueueOptions{ID: "order-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "order-1" {
+			t.Fatalf("Enqueue: got ID %q, want %q", id, "order-1")
+		}
+	}
+	s := newSent()
+	process(t, q, s.handle)
+	if got := s.wait(t, 1); !cmp.Equal(got, []string{"alice"}) {
+		t.Fatalf("handled: got %v, want [alice]", got)
+	}
+	select {
+	case <-s.new:
+		t.Fatal("duplicate job handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestQueueDelay(t *testing.T) {
+	ctx := context.Background()
+	q := testQueue(t, lease.NewTable(), newStores(nil), "w1", QueueOptions{})
+	s := newSent()
+	process(t, q, s.handle)
+
+	start := time.Now()
+	const delay = 200 * time.Millisecond
+	if _, err := q.Enqueue(ctx, testEmail{To: "alice"}, EnqueueOptions{Delay: delay}); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t, 1)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("job handled after %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestQueueDeadLetters(t *testing.T) {
+	// Test plan: Fail every attempt of a job, and check that it is moved to
+	// the dead letters after MaxAttempts attempts. Then requeue it, and check
+	// that it succeeds.
+	ctx := context.Background()
+	q := testQueue(t, lease.NewTable(), newStores(nil), "w1", QueueOptions{MaxAttempts: 3})
+	var mu sync.Mutex
+	attempts := 0
+	fail := true
+	s := newSent()
+	process(t, q, func(ctx context.Context, e testEmail) error {
+		mu.Lock()
+		attempts++
+		failing := fail
+		mu.Unlock()
+		if failing {
+			return errors.New("mail server down")
+		}
+		return s.handle(ctx, e)
+	})
+
+	id, err := q.Enqueue(ctx, testEmail{To: "alice"}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var letters []DeadLetter[testEmail]
+	for start := time.Now(); len(letters) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("job not moved to the dead letters")
+		}
+		if letters, err = q.DeadLetters(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []DeadLetter[testEmail]{{
+		ID:       id,
+		Job:      testEmail{To: "alice"},
+		Attempts: 3,
+		Error:    "mail server down",
+	}}
+	ignoreTimes := cmp.FilterPath(func(p cmp.Path) bool {
+		name := p.Last().String()
+		return name == ".Enqueued" || name == ".Failed"
+	}, cmp.Ignore())
+	if diff := cmp.Diff(want, letters, ignoreTimes); diff != "" {
+		t.Fatalf("DeadLetters (-want +got):\n%s", diff)
+	}
+	mu.Lock()
+	if attempts != 3 {
+		t.Errorf("attempts: got %d, want 3", attempts)
+	}
+	fail = false
+	mu.Unlock()
+
+	if err := q.Requeue(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t, 1)
+	if letters, err := q.DeadLetters(ctx); err != nil || len(letters) != 0 {
+		t.Fatalf("DeadLetters after Requeue: got %v, %v, want none", letters, err)
+	}
+	if err := q.Requeue(ctx, id); err == nil {
+		t.Fatal("Requeue of a finished job: unexpected success")
+	}
+}
+
+func TestQueueDiscard(t *testing.T) {
+	ctx := context.Background()
+	q := testQueue(t, lease.NewTable(), newStores(nil), "w1", QueueOptions{MaxAttempts: 1})
+	process(t, q, func(context.Context, testEmail) error {
+		return errors.New("invalid address")
+	})
+	id, err := q.Enqueue(ctx, testEmail{To: "alice"}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("job not moved to the dead letters")
+		}
+		letters, err := q.DeadLetters(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(letters) == 1 {
+			break
+		}
+	}
+	if err := q.Discard(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if letters, err := q.DeadLetters(ctx); err != nil || len(letters) != 0 {
+		t.Fatalf("DeadLetters after Discard: got %v, %v, want none", letters, err)
+	}
+}
+
+func TestQueueSharedByProcesses(t *testing.T) {
+	// Test plan: Enqueue jobs in one process, and process them in two other
+	// processes sharing the same store. Check that every job is handled once.
+	ctx := context.Background()
+	table, s := lease.NewTable(), newStores(nil)
+	enqueuer := testQueue(t, table, s, "w1", QueueOptions{})
+	sent := newSent()
+	process(t, testQueue(t, table, s, "w2", QueueOptions{}), sent.handle)
+	process(t, testQueue(t, table, s, "w3", QueueOptions{}), sent.handle)
+
+	want := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, to := range want {
+		if _, err := enqueuer.Enqueue(ctx, testEmail{To: to}, EnqueueOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := sent.wait(t, len(want))
+	if diff := cmp.Diff(want, got, cmpSorted); diff != "" {
+		t.Fatalf("handled (-want +got):\n%s", diff)
+	}
+	select {
+	case <-sent.new:
+		t.Fatal("job handled twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestQueueBackoff(t *testing.T) {
+	for _, test := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{20, maxQueueRetryBackoff},
+	} {
+		if got := queueBackoff(time.Second, test.attempts); got != test.want {
+			t.Errorf("queueBackoff(1s, %d): got %v, want %v", test.attempts, got, test.want)
+		}
+	}
+}
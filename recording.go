@@ -0,0 +1,209 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// A RecordedCall is a remote component method call recorded by a weavelet.
+// Calls are recorded when enabled in the config:
+//
+//	[serviceweaver.recording]
+//	dir = "/var/log/boutique/calls"
+//	components = ["github.com/my/project/cart/T"]
+//
+// See runtime.RecordingConfig for details. Use ReadRecording to read the
+// calls recorded in a directory, and Replay, or weavertest.Replay, to make
+// them again, e.g., against a new version of a component.
+type RecordedCall struct {
+	Component string        // full component name
+	Method    string        // method name
+	Start     time.Time     // when the call started
+	Duration  time.Duration // how long the call took
+	Args      []byte        // serialized arguments
+	Results   []byte        // serialized results, including the application error
+	Error     string        // the error, other than an application error, the call failed with
+}
+
+// ReadRecording returns the calls recorded in the provided directory by all
+// the weavelets of a deployment, ordered by start time.
+func ReadRecording(dir string) ([]RecordedCall, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var calls []RecordedCall
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(f)
+		for {
+			var call RecordedCall
+			err := dec.Decode(&call)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// The last call may be truncated if the weavelet was killed
+				// while recording it.
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					break
+				}
+				f.Close()
+				return nil, fmt.Errorf("read recorded calls from %s: %w", file, err)
+			}
+			calls = append(calls, call)
+		}
+		f.Close()
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+	return calls, nil
+}
+
+// Replay makes the recorded call again, on behalf of the provided requester,
+// and returns its serialized results. The results can be compared with
+// call.Results to check whether the call behaves as it did when recorded.
+// Note that the serialization of maps is not deterministic, so the results
+// of a method that returns a map with more than one entry may differ even
+// if the call behaves the same. Replay returns an error if the call can't be
+// made, e.g., because the component no longer has the method, or its
+// arguments changed.
+func Replay(ctx context.Context, requester Instance, call RecordedCall) ([]byte, error) {
+	rep := requester.rep()
+	c, err := rep.wlet.getComponent(call.Component)
+	if err != nil {
+		return nil, err
+	}
+	client, err := rep.wlet.getInstance(c, rep.info.Name)
+	if err != nil {
+		return nil, err
+	}
+	// Decode the arguments, and encode the results, with the component's
+	// server stub, wrapped around a client of the component.
+	server := c.info.ServerStubFn(client, func(uint64, float64) {}, rep.wlet.codecs[call.Component])
+	fn := server.GetStubFn(call.Method)
+	if fn == nil {
+		return nil, fmt.Errorf("component %q: no method %s, or it is a streaming method", call.Component, call.Method)
+	}
+	return fn(ctx, call.Args)
+}
+
+// A callRecorder records the remote calls served by a weavelet in a file.
+// Writes are not buffered, so that the calls served before a weavelet
+// crashes are recorded.
+type callRecorder struct {
+	path       string          // the file calls are recorded in
+	components map[string]bool // components whose calls are recorded, or nil for all
+	logger     *slog.Logger
+
+	mu     sync.Mutex
+	file   *os.File      // opened on the first recorded call
+	enc    *json.Encoder // encodes calls to file
+	failed bool          // has recording failed?
+	closed bool          // has the recorder been closed?
+}
+
+// newCallRecorder returns a recorder of the calls served by the weavelet
+// with the provided id, or nil if calls aren't recorded.
+func newCallRecorder(config runtime.RecordingConfig, id string, logger *slog.Logger) *callRecorder {
+	if config.Dir == "" {
+		return nil
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	r := &callRecorder{
+		path:   filepath.Join(config.Dir, id+".jsonl"),
+		logger: logger,
+	}
+	if len(config.Components) > 0 {
+		r.components = map[string]bool{}
+		for _, c := range config.Components {
+			r.components[c] = true
+		}
+	}
+	return r
+}
+
+// records returns whether the calls to the provided component are recorded.
+// A nil callRecorder records no calls.
+func (r *callRecorder) records(component string) bool {
+	return r != nil && (r.components == nil || r.components[component])
+}
+
+// record records a call. Failures are logged, and don't fail the call.
+func (r *callRecorder) record(call RecordedCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed || r.closed {
+		return
+	}
+	if r.file == nil {
+		if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+			r.fail(err)
+			return
+		}
+		f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		r.file = f
+		r.enc = json.NewEncoder(f)
+	}
+	if err := r.enc.Encode(call); err != nil {
+		r.fail(err)
+	}
+}
+
+// fail stops recording after an error.
+//
+// REQUIRES: r.mu is held.
+func (r *callRecorder) fail(err error) {
+	r.failed = true
+	r.logger.Error("recording calls failed; no more calls are recorded", err, "file", r.path)
+}
+
+// close stops recording. A nil callRecorder is a no-op.
+func (r *callRecorder) close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slog"
+)
+
+func TestRecordingDisabled(t *testing.T) {
+	r := newCallRecorder(runtime.RecordingConfig{}, "id", slog.Default())
+	if r != nil {
+		t.Fatalf("newCallRecorder: got %v, want nil", r)
+	}
+	if r.records("a/T") {
+		t.Error("nil recorder records calls")
+	}
+	if err := r.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndRead(t *testing.T) {
+	// Test plan: Record calls with two weavelets, one of which records the
+	// calls to a single component, and check that the calls are read back in
+	// start order.
+	dir := filepath.Join(t.TempDir(), "calls")
+	all := newCallRecorder(runtime.RecordingConfig{Dir: dir}, "all", slog.Default())
+	some := newCallRecorder(runtime.RecordingConfig{Dir: dir, Components: []string{"a/T"}}, "some", slog.Default())
+	for _, test := range []struct {
+		r         *callRecorder
+		component string
+		want      bool
+	}{
+		{all, "a/T", true},
+		{all, "b/T", true},
+		{some, "a/T", true},
+		{some, "b/T", false},
+	} {
+		if got := test.r.records(test.component); got != test.want {
+			t.Errorf("%s records %s: got %v, want %v", test.r.path, test.component, got, test.want)
+		}
+	}
+
+	start := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := []RecordedCall{
+		{Component: "a/T", Method: "Put", Start: start, Duration: time.Millisecond, Args: []byte("args0"), Results: []byte("results0")},
+		{Component: "b/T", Method: "Get", Start: start.Add(time.Second), Args: []byte("args1"), Error: "boom"},
+		{Component: "a/T", Method: "Get", Start: start.Add(2 * time.Second), Args: []byte("args2"), Results: []byte("results2")},
+	}
+	some.record(calls[2])
+	all.record(calls[0])
+	all.record(calls[1])
+	if err := all.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := some.close(); err != nil {
+		t.Fatal(err)
+	}
+	// Calls recorded after close are dropped.
+	all.record(calls[0])
+
+	// A call truncated by a crash is ignored.
+	f, err := os.OpenFile(some.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"Component": "a/T", "Meth`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := ReadRecording(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(calls, got); diff != "" {
+		t.Fatalf("ReadRecording (-want +got):\n%s", diff)
+	}
+}
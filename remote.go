@@ -102,10 +102,50 @@ func (e *remoteEnv) ExportListener(_ context.Context, listener, addr string, opt
 		Listener:     listener,
 		Address:      addr,
 		LocalAddress: opts.LocalAddress,
+		Tls:          opts.CertFile != "" || opts.TLSConfig != nil,
 	}
 	return e.conn.ExportListenerRPC(request)
 }
 
+// PublishTopicEvent implements the Env interface.
+func (e *remoteEnv) PublishTopicEvent(event *protos.TopicEvent) error {
+	return e.conn.SendTopicEvent(event)
+}
+
+// PublishMessage implements the Env interface.
+func (e *remoteEnv) PublishMessage(_ context.Context, msg *protos.TopicMessage) error {
+	return e.conn.PublishMessageRPC(&protos.PublishMessageRequest{Message: msg})
+}
+
+// Subscribe implements the Env interface.
+func (e *remoteEnv) Subscribe(_ context.Context, topic, subscription string) error {
+	request := &protos.SubscribeRequest{
+		Topic:        topic,
+		Subscription: subscription,
+	}
+	return e.conn.SubscribeRPC(request)
+}
+
+// ScheduleJob implements the Env interface.
+func (e *remoteEnv) ScheduleJob(_ context.Context, req *protos.ScheduleJobRequest) error {
+	return e.conn.ScheduleJobRPC(req)
+}
+
+// Lease implements the Env interface.
+func (e *remoteEnv) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return e.conn.LeaseRPC(req)
+}
+
+// SendProfile implements the Env interface.
+func (e *remoteEnv) SendProfile(profile *protos.Profile) error {
+	return e.conn.SendProfile(profile)
+}
+
+// SendCrashReport implements the Env interface.
+func (e *remoteEnv) SendCrashReport(report *protos.CrashReport) error {
+	return e.conn.SendCrashReport(report)
+}
+
 // CreateLogSaver implements the Env interface.
 func (e *remoteEnv) CreateLogSaver() func(entry *protos.LogEntry) {
 	return func(entry *protos.LogEntry) {
@@ -129,14 +169,21 @@ func (e *remoteEnv) CreateTraceExporter() sdktrace.SpanExporter {
 }
 
 // parseEndpoints parses a list of endpoint addresses into a list of
-// call.Endpoints.
-func parseEndpoints(addrs []string) ([]call.Endpoint, error) {
+// call.Endpoints. "quic://" addresses are dialed with the provided transport.
+func parseEndpoints(addrs []string, quic *call.QUICTransport) ([]call.Endpoint, error) {
 	var endpoints []call.Endpoint
 	for _, addr := range addrs {
 		endpoint, err := call.ParseNetEndpoint(addr)
 		if err != nil {
 			return nil, err
 		}
+		if endpoint.Net == "quic" {
+			if quic == nil {
+				return nil, fmt.Errorf("%q: the transport is not QUIC", addr)
+			}
+			endpoints = append(endpoints, quic.Endpoint(endpoint.Addr))
+			continue
+		}
 		endpoints = append(endpoints, endpoint)
 	}
 	return endpoints, nil
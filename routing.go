@@ -18,30 +18,83 @@ import (
 	"context"
 	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/ServiceWeaver/weaver/internal/cond"
 	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/google/uuid"
 )
 
+type replicaLabels struct {
+	Component string // the called component
+	Replica   string // the address of the picked replica
+	Region    string // the region of the picked replica, if any
+}
+
+var (
+	replicaPicks = metrics.NewCounterMap[replicaLabels](
+		"serviceweaver_replica_pick_count",
+		"Count of Service Weaver remote calls assigned to a component replica",
+	)
+	replicaLatencyMicros = metrics.NewHistogramMap[replicaLabels](
+		"serviceweaver_replica_call_latency_micros",
+		"Latency, in microseconds, of Service Weaver remote calls to a component replica, as observed by the caller",
+		metrics.NonNegativeBuckets,
+	)
+)
+
 // routingBalancer balances requests according to a routing assignment.
+// Requests without a routing key are balanced by a default balancer, picked
+// by the load_balancing config option or registered with UseBalancer, unless
+// they have an affinity.
 type routingBalancer struct {
-	balancer call.Balancer // default balancer
+	component string              // the component whose replicas are balanced
+	balancer  call.Balancer       // default balancer
+	locality  *localityBalancer   // default balancer, if it prefers a region
+	quic      *call.QUICTransport // dials "quic://" replicas, if not nil
 
 	mu         sync.RWMutex
+	endpoints  []call.Endpoint // endpoints of calls with an affinity
 	assignment *protos.Assignment
 	index      index
+	regions    map[string]string // regions of the replicas, by address
 }
 
-// newRoutingBalancer returns a new routingBalancer.
-func newRoutingBalancer() *routingBalancer {
-	return &routingBalancer{balancer: call.RoundRobin()}
+var (
+	_ call.CallObserver = &routingBalancer{}
+	_ call.DialObserver = &routingBalancer{}
+)
+
+// newRoutingBalancer returns a new routingBalancer for the provided
+// component, with a default balancer that implements the provided
+// load_balancing policy. See runtime.WeaveletConfig.LoadBalancing. If region
+// is not empty, the default balancer prefers the replicas in the region.
+func newRoutingBalancer(component, policy, region string) *routingBalancer {
+	newBalancer := func() call.Balancer {
+		switch policy {
+		case "least_outstanding":
+			return call.LeastOutstanding()
+		case "latency_weighted":
+			return call.LatencyWeighted()
+		default:
+			return call.RoundRobin()
+		}
+	}
+	if region == "" {
+		return &routingBalancer{component: component, balancer: newBalancer()}
+	}
+	locality := newLocalityBalancer(component, region, newBalancer)
+	return &routingBalancer{component: component, balancer: locality, locality: locality}
 }
 
 // Update implements the call.Balancer interface.
 func (rb *routingBalancer) Update(endpoints []call.Endpoint) {
 	rb.balancer.Update(endpoints)
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.endpoints = endpoints
 }
 
 // update updates the balancer with the provided assignment
@@ -57,11 +110,72 @@ func (rb *routingBalancer) update(assignment *protos.Assignment) {
 	rb.index = index
 }
 
+// updateRegions updates the balancer with the regions of the replicas, by
+// address.
+func (rb *routingBalancer) updateRegions(regions map[string]string) {
+	rb.mu.Lock()
+	rb.regions = regions
+	rb.mu.Unlock()
+	if rb.locality != nil {
+		rb.locality.setRegions(regions)
+	}
+}
+
 // Pick implements the call.Balancer interface.
 func (rb *routingBalancer) Pick(opts call.CallOptions) (call.Endpoint, error) {
+	endpoint, err := rb.pick(opts)
+	if err != nil {
+		return nil, err
+	}
+	replicaPicks.Get(rb.labels(endpoint)).Add(1)
+	return endpoint, nil
+}
+
+// labels returns the metric labels of calls to the provided endpoint.
+func (rb *routingBalancer) labels(endpoint call.Endpoint) replicaLabels {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	addr := endpoint.Address()
+	return replicaLabels{Component: rb.component, Replica: addr, Region: rb.regions[addr]}
+}
+
+// Started implements the call.CallObserver interface.
+func (rb *routingBalancer) Started(endpoint call.Endpoint) {
+	if observer, ok := rb.balancer.(call.CallObserver); ok {
+		observer.Started(endpoint)
+	}
+}
+
+// Finished implements the call.CallObserver interface.
+func (rb *routingBalancer) Finished(endpoint call.Endpoint, latency time.Duration, err error) {
+	replicaLatencyMicros.Get(rb.labels(endpoint)).Put(float64(latency.Microseconds()))
+	if observer, ok := rb.balancer.(call.CallObserver); ok {
+		observer.Finished(endpoint, latency, err)
+	}
+}
+
+// DialFailed implements the call.DialObserver interface.
+func (rb *routingBalancer) DialFailed(endpoint call.Endpoint, err error) {
+	if observer, ok := rb.balancer.(call.DialObserver); ok {
+		observer.DialFailed(endpoint, err)
+	}
+}
+
+// pick picks an endpoint, using the assignment for calls with a routing key.
+func (rb *routingBalancer) pick(opts call.CallOptions) (call.Endpoint, error) {
 	if opts.ShardKey == 0 {
 		// If the method we're calling is not sharded (which is guaranteed to
-		// be true for nonsharded components), then the shard key is 0.
+		// be true for nonsharded components), then the shard key is 0. Calls
+		// with the same affinity, e.g., the retries of a call with an
+		// idempotency key, are sent to the same endpoint.
+		if opts.Affinity != 0 {
+			rb.mu.RLock()
+			endpoints := rb.endpoints
+			rb.mu.RUnlock()
+			if n := len(endpoints); n > 0 {
+				return endpoints[opts.Affinity%uint64(n)], nil
+			}
+		}
 		return rb.balancer.Pick(opts)
 	}
 
@@ -92,7 +206,7 @@ func (rb *routingBalancer) Pick(opts call.CallOptions) (call.Endpoint, error) {
 	// TODO(mwhittaker): Parse the endpoints when an assignment is received,
 	// rather than once per call.
 	addr := slice.replicas[rand.Intn(len(slice.replicas))]
-	endpoints, err := parseEndpoints([]string{addr})
+	endpoints, err := parseEndpoints([]string{addr}, rb.quic)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +103,23 @@ func TestRoutingBalancer(t *testing.T) {
 	}
 }
 
+// TestRoutingBalancerAffinity tests that a routingBalancer sends calls with
+// the same affinity, and no shard key, to the same endpoint.
+func TestRoutingBalancerAffinity(t *testing.T) {
+	rb := newRoutingBalancer("component", "round_robin", "")
+	endpoints := []call.Endpoint{nilEndpoint{"a"}, nilEndpoint{"b"}, nilEndpoint{"c"}}
+	rb.Update(endpoints)
+	for i := 0; i < 5; i++ {
+		got, err := rb.Pick(call.CallOptions{Affinity: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := endpoints[1]; got != want {
+			t.Fatalf("rb.Pick(Affinity: 4): got %v, want %v", got, want)
+		}
+	}
+}
+
 // TestRoutingResolverInitialResolve tests that the first Resolve invocation on
 // a routingResolver returns a nil set of endpoints but a non-nil version.
 func TestRoutingResolverInitialResolve(t *testing.T) {
@@ -152,3 +169,42 @@ func TestRoutingResolverUpdate(t *testing.T) {
 		t.Fatalf("endpoints (-want +got):\n%s", diff)
 	}
 }
+
+// TestRoutingBalancerLoadBalancing tests that a routingBalancer forwards the
+// calls it observes to a load-aware default balancer.
+func TestRoutingBalancerLoadBalancing(t *testing.T) {
+	a, b := nilEndpoint{"a"}, nilEndpoint{"b"}
+	rb := newRoutingBalancer("component", "least_outstanding", "")
+	rb.Update([]call.Endpoint{a, b})
+
+	// Keep a call running on the first endpoint picked. Every following pick
+	// should go to the other endpoint.
+	busy, err := rb.Pick(call.CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb.Started(busy)
+	for i := 0; i < 3; i++ {
+		got, err := rb.Pick(call.CallOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == busy {
+			t.Fatalf("rb.Pick: got busy endpoint %v", got)
+		}
+	}
+
+	// Once the call finishes, both endpoints are picked again.
+	rb.Finished(busy, time.Millisecond, nil)
+	picked := map[call.Endpoint]bool{}
+	for i := 0; i < 2; i++ {
+		got, err := rb.Pick(call.CallOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked[got] = true
+	}
+	if len(picked) != 2 {
+		t.Fatalf("rb.Pick: got endpoints %v, want both %v and %v", picked, a, b)
+	}
+}
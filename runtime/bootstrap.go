@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"strconv"
 )
 
@@ -34,6 +35,35 @@ const (
 	// for messages sent from weavelet to envelope is stored. For internal use by
 	// Service Weaver infrastructure.
 	ToEnvelopeKey = "WEAVELET_TO_ENVELOPE_FD"
+
+	// CheckConfigKey is the environment variable under which "weaver config
+	// check" passes the name of a file to an application binary. Rather than
+	// running, the binary checks its config, read from the file named by
+	// SERVICEWEAVER_CONFIG, and writes the problems it finds to the file, as
+	// a JSON array of strings. For internal use by Service Weaver
+	// infrastructure.
+	CheckConfigKey = "SERVICEWEAVER_CHECK_CONFIG"
+
+	// ListComponentsKey is the environment variable under which deployers
+	// pass the name of a file to an application binary, e.g., to preview a
+	// deployment. Rather than running, the binary writes the names of its
+	// components to the file, as a JSON array of strings. For internal use
+	// by Service Weaver infrastructure.
+	ListComponentsKey = "SERVICEWEAVER_LIST_COMPONENTS"
+
+	// DescribeComponentsKey is the environment variable under which
+	// deployers pass the name of a file to an application binary, before
+	// rolling it out. Rather than running, the binary writes the schemas of
+	// the methods of its components to the file, as a binary encoded
+	// status.Schema. For internal use by Service Weaver infrastructure.
+	DescribeComponentsKey = "SERVICEWEAVER_DESCRIBE_COMPONENTS"
+
+	// DescribeMetricsKey is the environment variable under which "weaver
+	// metrics" passes the name of a file to an application binary. Rather
+	// than running, the binary writes its components and the definitions of
+	// its metrics to the file, as a JSON encoded grafana.App. For internal
+	// use by Service Weaver infrastructure.
+	DescribeMetricsKey = "SERVICEWEAVER_DESCRIBE_METRICS"
 )
 
 // Bootstrap holds configuration information used to start a process execution.
@@ -43,6 +73,20 @@ type Bootstrap struct {
 	ToWeaveletFile *os.File // Pipe to send to weavelet (weavertest only).
 	ToEnvelopeFile *os.File // Pipe to send to envelope (weavertest only).
 	TestConfig     string   // Config file contents (weavertest only).
+
+	// Fake component implementations, keyed by component interface type
+	// (weavertest only).
+	TestFakes map[reflect.Type]any
+
+	// Fault injectors, keyed by component interface type (weavertest only).
+	// An injector is called with the name of the method before every call
+	// to the component. If it returns an error, the call fails with the
+	// error without reaching the component.
+	TestFaults map[reflect.Type]func(ctx context.Context, method string) error
+
+	// Clock that replaces the system clock, a weaver.Clock (weavertest
+	// only).
+	TestClock any
 }
 
 // BootstrapKey is the Context key used by weavertest to pass Bootstrap to [weaver.Init].
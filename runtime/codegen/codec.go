@@ -0,0 +1,129 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A Codec encodes the arguments and results of the remote calls to the
+// methods of a component, in place of the encoding generated by "weaver
+// generate". Application errors returned by methods are always encoded by
+// Service Weaver, as are the values streamed to and from streaming methods.
+// A Codec must be safe for concurrent use.
+type Codec interface {
+	// Marshal encodes the provided values, in order.
+	Marshal(values []any) ([]byte, error)
+
+	// Unmarshal decodes data, encoded by Marshal, into the values pointed to
+	// by the provided pointers, in order.
+	Unmarshal(data []byte, ptrs []any) error
+}
+
+// codecs holds the codecs registered with RegisterCodec, by name.
+var codecs struct {
+	mu     sync.Mutex
+	byName map[string]Codec
+}
+
+// RegisterCodec registers a codec under the provided name. It panics if the
+// name is empty or already taken. Codecs must be registered before the
+// components that use them are created.
+func RegisterCodec(name string, codec Codec) {
+	if name == "" {
+		panic(fmt.Errorf("codegen: RegisterCodec with an empty name"))
+	}
+	if codec == nil {
+		panic(fmt.Errorf("codegen: RegisterCodec(%q, nil)", name))
+	}
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	if _, ok := codecs.byName[name]; ok {
+		panic(fmt.Errorf("codegen: codec %q registered twice", name))
+	}
+	if codecs.byName == nil {
+		codecs.byName = map[string]Codec{}
+	}
+	codecs.byName[name] = codec
+}
+
+// GetCodec returns the codec registered under the provided name, or nil if
+// the name is empty, which stands for the default encoding.
+func GetCodec(name string) (Codec, error) {
+	if name == "" {
+		return nil, nil
+	}
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	codec, ok := codecs.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("codec %q not registered", name)
+	}
+	return codec, nil
+}
+
+// EncodeArgs encodes the provided arguments of a call with codec. It is used
+// by client stubs.
+func EncodeArgs(codec Codec, args ...any) []byte {
+	if len(args) == 0 {
+		return nil
+	}
+	data, err := codec.Marshal(args)
+	if err != nil {
+		panic(makeEncodeError("error encoding arguments: %w", err))
+	}
+	return data
+}
+
+// DecodeArgs decodes arguments encoded by EncodeArgs into the values pointed
+// to by ptrs. It is used by server stubs.
+func DecodeArgs(codec Codec, data []byte, ptrs ...any) {
+	if len(ptrs) == 0 {
+		return
+	}
+	if err := codec.Unmarshal(data, ptrs); err != nil {
+		panic(makeDecodeError("error decoding arguments: %w", err))
+	}
+}
+
+// EncodeResults encodes the provided results of a call with codec, followed
+// by the provided application error, encoded by Service Weaver. It is used by
+// server stubs.
+func EncodeResults(codec Codec, appErr error, results ...any) []byte {
+	enc := NewEncoder()
+	if len(results) > 0 {
+		data, err := codec.Marshal(results)
+		if err != nil {
+			panic(makeEncodeError("error encoding results: %w", err))
+		}
+		enc.Bytes(data)
+	}
+	enc.Error(appErr)
+	return enc.Data()
+}
+
+// DecodeResults decodes results encoded by EncodeResults into the values
+// pointed to by ptrs, and returns the application error. It is used by client
+// stubs.
+func DecodeResults(codec Codec, data []byte, ptrs ...any) error {
+	dec := NewDecoder(data)
+	if len(ptrs) > 0 {
+		if err := codec.Unmarshal(dec.Bytes(), ptrs); err != nil {
+			panic(makeDecodeError("error decoding results: %w", err))
+		}
+	}
+	return dec.Error()
+}
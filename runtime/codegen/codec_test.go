@@ -0,0 +1,113 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// gobCodec is a Codec that encodes values with encoding/gob.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(values []any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := gob.NewEncoder(&b)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, ptrs []any) error {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	for _, ptr := range ptrs {
+		if err := dec.Decode(ptr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec := gobCodec{}
+
+	// Arguments.
+	var s string
+	var xs []int
+	DecodeArgs(codec, EncodeArgs(codec, "hello", []int{1, 2}), &s, &xs)
+	if s != "hello" || len(xs) != 2 || xs[1] != 2 {
+		t.Fatalf("DecodeArgs: got %q, %v", s, xs)
+	}
+
+	// Results.
+	var n int
+	if err := DecodeResults(codec, EncodeResults(codec, nil, 42), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Fatalf("DecodeResults: got %d, want 42", n)
+	}
+
+	// Application errors.
+	appErr := DecodeResults(codec, EncodeResults(codec, fmt.Errorf("boom"), 0), &n)
+	if appErr == nil || appErr.Error() != "boom" {
+		t.Fatalf("DecodeResults: got error %v, want boom", appErr)
+	}
+
+	// Methods without arguments or results.
+	DecodeArgs(codec, EncodeArgs(codec))
+	if err := DecodeResults(codec, EncodeResults(codec, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodecDecodeError(t *testing.T) {
+	err := func() (err error) {
+		defer func() { err = CatchPanics(recover()) }()
+		var s string
+		DecodeArgs(gobCodec{}, []byte("garbage"), &s)
+		return nil
+	}()
+	if err == nil || !errors.As(err, &decoderError{}) {
+		t.Fatalf("DecodeArgs: got %v, want a decoding error", err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("codegen_test_gob", gobCodec{})
+	if codec, err := GetCodec("codegen_test_gob"); err != nil || codec == nil {
+		t.Fatalf("GetCodec: got %v, %v", codec, err)
+	}
+	if codec, err := GetCodec(""); err != nil || codec != nil {
+		t.Fatalf("GetCodec(\"\"): got %v, %v; want the default encoding", codec, err)
+	}
+	if _, err := GetCodec("codegen_test_unknown"); err == nil {
+		t.Fatal("GetCodec of an unregistered codec: unexpected success")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("RegisterCodec twice: unexpected success")
+			}
+		}()
+		RegisterCodec("codegen_test_gob", gobCodec{})
+	}()
+}
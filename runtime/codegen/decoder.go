@@ -17,6 +17,7 @@ package codegen
 import (
 	"encoding"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
@@ -75,6 +76,14 @@ func (d *Decoder) DecodeBinaryUnmarshaler(value encoding.BinaryUnmarshaler) {
 	}
 }
 
+// DecodeTextUnmarshaler deserializes the value from a byte slice using
+// UnmarshalText.
+func (d *Decoder) DecodeTextUnmarshaler(value encoding.TextUnmarshaler) {
+	if err := value.UnmarshalText(d.Bytes()); err != nil {
+		panic(makeDecodeError("error decoding TextUnmarshaler %T: %w", value, err))
+	}
+}
+
 // Read reads and returns n bytes from the decoder and advances the decode past
 // the read bytes.
 func (d *Decoder) Read(n int) []byte {
@@ -212,8 +221,37 @@ func (d *Decoder) Len() int {
 	return n
 }
 
-// Error decodes an error. We construct an instance of a special error value
-// that provides Is and Unwrap support.
+// BeginStruct starts the decoding of a struct encoded by
+// Encoder.BeginStruct. It returns the number of fields that were encoded,
+// and a token that must be passed to EndStruct once the known fields have
+// been decoded. Generated code decodes only the first fields fields, leaving
+// the rest at their zero values.
+//
+// NOTE that this method should be called only in the generated code.
+func (d *Decoder) BeginStruct() (fields int, end int) {
+	fields = d.Len()
+	if fields < 0 {
+		panic(makeDecodeError("unable to decode struct; expected field count >= 0 got %d", fields))
+	}
+	n := int(d.Uint32())
+	if n > len(d.data) {
+		panic(makeDecodeError("unable to decode struct; length %d exceeds remaining %d bytes", n, len(d.data)))
+	}
+	return fields, len(d.data) - n
+}
+
+// EndStruct ends the decoding of a struct started by BeginStruct, skipping
+// any encoded fields that were not decoded.
+func (d *Decoder) EndStruct(end int) {
+	if len(d.data) < end {
+		panic(makeDecodeError("unable to decode struct; fields overran their encoding"))
+	}
+	d.data = d.data[len(d.data)-end:]
+}
+
+// Error decodes an error. If the error's type was registered with
+// RegisterError, we return a value of that type. Otherwise, we construct an
+// instance of a special error value that provides Is, As, and Unwrap support.
 func (d *Decoder) Error() error {
 	n := d.Int()
 	if n == 0 {
@@ -221,9 +259,16 @@ func (d *Decoder) Error() error {
 	}
 	var err decodedErrorStack
 	for i := 0; i < n; i++ {
+		var value error
+		if name := d.String(); name != "" {
+			value = decodeErrorValue(name, d.Bytes())
+		}
 		msg := d.String()
 		f := d.String()
-		err = append(err, decodedErrorEntry{msg, f})
+		err = append(err, decodedErrorEntry{msg, f, value})
+	}
+	if err[0].value != nil {
+		return err[0].value
 	}
 	// Note that we intentionally return nil when n==0 so that the deserialization
 	// of a serialized nil error remains nil
@@ -233,8 +278,9 @@ func (d *Decoder) Error() error {
 type decodedErrorStack []decodedErrorEntry
 
 type decodedErrorEntry struct {
-	msg string // Error() result
-	fmt string // Result of fmtError
+	msg   string // Error() result
+	fmt   string // Result of fmtError
+	value error  // decoded value, if the error's type is registered
 }
 
 // Error implements error.Error.
@@ -253,6 +299,15 @@ func (e decodedErrorStack) Is(target error) bool {
 	return e[0].fmt == fmtError(target)
 }
 
+// As sets target to the decoded value of e, if e's type was registered with
+// RegisterError and the value is assignable to target.
+func (e decodedErrorStack) As(target any) bool {
+	if e[0].value == nil {
+		return false
+	}
+	return errors.As(e[0].value, target)
+}
+
 // fmtError serializes an error value including its type info using fmt.Sprintf.
 func fmtError(v error) string {
 	// Include package and type info explicitly since %#v uses a shortened path.
@@ -89,6 +89,16 @@ func (e *Encoder) EncodeBinaryMarshaler(value encoding.BinaryMarshaler) {
 	e.Bytes(enc)
 }
 
+// EncodeTextMarshaler serializes value into a byte slice using its
+// MarshalText method.
+func (e *Encoder) EncodeTextMarshaler(value encoding.TextMarshaler) {
+	enc, err := value.MarshalText()
+	if err != nil {
+		panic(makeEncodeError("error encoding TextMarshaler %T: %w", value, err))
+	}
+	e.Bytes(enc)
+}
+
 // Data returns the byte slice that contains the serialized arguments.
 func (e *Encoder) Data() []byte {
 	return e.data
@@ -250,6 +260,33 @@ func (e *Encoder) Len(l int) {
 	e.Int32(int32(l))
 }
 
+// BeginStruct starts the encoding of a struct with the provided number of
+// fields, and returns a token that must be passed to EndStruct once the
+// fields have been encoded.
+//
+// A struct is encoded as its number of fields and the length of its encoded
+// fields, followed by the fields in declaration order. The header lets a
+// decoder built with a different version of the struct tolerate added
+// fields: fields it doesn't know about are skipped, and fields it expects but
+// that were not sent keep their zero values. See Decoder.BeginStruct.
+//
+// NOTE that this method should be called only in the generated code.
+func (e *Encoder) BeginStruct(fields int) int {
+	e.Len(fields)
+	start := len(e.data)
+	e.Grow(4)
+	return start
+}
+
+// EndStruct ends the encoding of a struct started by BeginStruct.
+func (e *Encoder) EndStruct(start int) {
+	n := len(e.data) - start - 4
+	if n > math.MaxUint32 {
+		panic(makeEncodeError("unable to encode struct; length doesn't fit in 4 bytes"))
+	}
+	binary.LittleEndian.PutUint32(e.data[start:], uint32(n))
+}
+
 // Error encodes an arg of type error. We save enough type information
 // to allow errors.Unwrap() and errors.Is() to work correctly.
 func (e *Encoder) Error(err error) {
@@ -262,11 +299,34 @@ func (e *Encoder) Error(err error) {
 
 	e.Int(len(stack))
 	for _, err := range stack {
+		// Errors of registered types are also encoded by value, so they can be
+		// reconstructed at the receiver. Note that we encode the value first,
+		// so that the encoding of an error always ends with its non-empty
+		// fmtError string; see EndsWithNilError.
+		name, data := encodeErrorValue(err)
+		e.String(name)
+		if name != "" {
+			e.Bytes(data)
+		}
 		e.String(err.Error())
 		e.String(fmtError(err))
+	}
+}
 
-		// TODO(sanjay): If a wrapped errors can be serialized using Gob, consider
-		// saving that serialization. This may allow us to implement the As() method
-		// that reconstructs an error value with the original type at the receiver.
+// EndsWithNilError returns whether data, the serialized results of a
+// component method call, ends with the serialization of a nil error. Server
+// stubs always encode the error returned by a method last. A nil error is
+// encoded as a zero count, whereas a non-nil error ends with a non-empty
+// string, so the two can be told apart without decoding the other results.
+func EndsWithNilError(data []byte) bool {
+	const n = 8 // size of an encoded int
+	if len(data) < n {
+		return false
+	}
+	for _, b := range data[len(data)-n:] {
+		if b != 0 {
+			return false
+		}
 	}
+	return true
 }
@@ -14,7 +14,14 @@
 
 package codegen
 
-import "errors"
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
 
 // CatchPanics recovers from panic() calls that occur during encoding,
 // decoding, and RPC execution.
@@ -31,3 +38,100 @@ func CatchPanics(r interface{}) error {
 	}
 	panic(r)
 }
+
+// errorTypes holds the error types registered with RegisterError.
+var errorTypes = struct {
+	mu     sync.Mutex
+	byName map[string]reflect.Type // registered types, by name
+	names  map[reflect.Type]string // names, by registered type
+}{
+	byName: map[string]reflect.Type{},
+	names:  map[reflect.Type]string{},
+}
+
+// RegisterError registers the concrete type of err, so that errors of this
+// type are encoded and decoded with their type and fields intact, rather than
+// being flattened to their message. Like gob.Register, RegisterError panics if
+// two different types are registered under the same name. Values of a
+// registered type are serialized using encoding/gob.
+func RegisterError(err error) {
+	if err == nil {
+		panic(fmt.Errorf("codegen: RegisterError(nil)"))
+	}
+	t := reflect.TypeOf(err)
+	name := errorTypeName(t)
+
+	errorTypes.mu.Lock()
+	defer errorTypes.mu.Unlock()
+	if old, ok := errorTypes.byName[name]; ok && old != t {
+		panic(fmt.Errorf("codegen: registering duplicate error types for %q: %v != %v", name, old, t))
+	}
+	errorTypes.byName[name] = t
+	errorTypes.names[t] = name
+}
+
+// errorTypeName returns the name an error type is registered under, e.g.,
+// "*github.com/my/app/shipping.InvalidAddressError".
+func errorTypeName(t reflect.Type) string {
+	star := ""
+	if t.Name() == "" && t.Kind() == reflect.Pointer {
+		star = "*"
+		t = t.Elem()
+	}
+	return star + t.PkgPath() + "." + t.Name()
+}
+
+// encodeErrorValue returns the name of the registered type of err and the
+// gob encoding of err. It returns an empty name if the type of err is not
+// registered or err cannot be gob encoded.
+func encodeErrorValue(err error) (string, []byte) {
+	errorTypes.mu.Lock()
+	name, ok := errorTypes.names[reflect.TypeOf(err)]
+	errorTypes.mu.Unlock()
+	if !ok {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if gob.NewEncoder(&buf).Encode(err) != nil {
+		return "", nil
+	}
+	return name, buf.Bytes()
+}
+
+// decodeErrorValue decodes an error encoded by encodeErrorValue. It returns
+// nil if the named type is not registered or the data cannot be decoded.
+func decodeErrorValue(name string, data []byte) error {
+	errorTypes.mu.Lock()
+	t, ok := errorTypes.byName[name]
+	errorTypes.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	// Decode into a pointer to the underlying value.
+	var ptr reflect.Value
+	if t.Kind() == reflect.Pointer {
+		ptr = reflect.New(t.Elem())
+	} else {
+		ptr = reflect.New(t)
+	}
+	if gob.NewDecoder(bytes.NewReader(data)).DecodeValue(ptr) != nil {
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		return ptr.Interface().(error)
+	}
+	err, ok := ptr.Elem().Interface().(error)
+	if !ok {
+		return nil
+	}
+	return err
+}
+
+// IsDecodedError returns whether err is an error decoded by Decoder.Error
+// whose type was not registered with RegisterError. Such an error only
+// preserves the messages of the original error and the errors it wraps.
+func IsDecodedError(err error) bool {
+	_, ok := err.(decodedErrorStack)
+	return ok
+}
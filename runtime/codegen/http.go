@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// jsonError is the body of the replies of HTTP gateways that report an error.
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v, encoded as JSON, with the provided status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck // the client has gone away
+}
+
+// DecodeJSONRequest decodes the body of a request to an HTTP gateway
+// generated by "weaver generate -http", which holds the arguments of a
+// component method, into req. An empty body leaves req untouched. If the
+// request isn't a POST request, or its body isn't a JSON object with the
+// fields of req, DecodeJSONRequest replies with an error and returns false.
+func DecodeJSONRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, jsonError{fmt.Sprintf("method %s not allowed", r.Method)})
+		return false
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, jsonError{fmt.Sprintf("bad request: %v", err)})
+		return false
+	}
+	return true
+}
+
+// WriteJSONReply writes the reply of an HTTP gateway generated by "weaver
+// generate -http", which holds the results of a component method. If err is
+// not nil, the reply is a JSON object with an "error" field instead.
+func WriteJSONReply(w http.ResponseWriter, reply any, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, jsonError{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// WriteJSONStream writes the reply of an HTTP gateway generated by "weaver
+// generate -http" for a component method that returns a stream. The values
+// of the stream are written as they are received, as newline-delimited JSON
+// objects with a "value" field. If the stream ends with an error, the last
+// object has an "error" field instead. If err is not nil, the reply is a
+// JSON object with an "error" field, like for WriteJSONReply.
+func WriteJSONStream[T any](w http.ResponseWriter, s Stream[T], err error) {
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, jsonError{err.Error()})
+		return
+	}
+	defer s.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for {
+		v, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			enc.Encode(jsonError{err.Error()}) //nolint:errcheck // the client has gone away
+			return
+		}
+		if err := enc.Encode(struct {
+			Value T `json:"value"`
+		}{v}); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
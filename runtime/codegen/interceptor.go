@@ -0,0 +1,110 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"context"
+	"sync"
+)
+
+// CallInfo describes a call to a component method.
+type CallInfo struct {
+	Component string // full name of the component, e.g., "github.com/my/app/Cart"
+	Method    string // name of the method, e.g., "AddItem"
+	Args      []any  // arguments of the call, excluding the initial context
+}
+
+// An Interceptor intercepts calls to component methods. It is passed the
+// call's context and info, and a function that runs the rest of the call. An
+// interceptor may inspect the call, run it with a derived context, run code
+// before and after it, or fail it without running it at all. The error it
+// returns is the call's error.
+type Interceptor func(ctx context.Context, info CallInfo, invoke func(context.Context) error) error
+
+// interceptors holds the interceptors registered with RegisterClientInterceptor
+// and RegisterServerInterceptor.
+var interceptors struct {
+	mu     sync.Mutex
+	client []Interceptor
+	server []Interceptor
+}
+
+// RegisterClientInterceptor registers an interceptor for the calls a process
+// makes to component methods. Interceptors must be registered before the
+// components they intercept are created.
+func RegisterClientInterceptor(i Interceptor) {
+	interceptors.mu.Lock()
+	defer interceptors.mu.Unlock()
+	interceptors.client = append(interceptors.client, i)
+}
+
+// RegisterServerInterceptor registers an interceptor for the calls to
+// component methods a process executes. Interceptors must be registered
+// before the components they intercept are created.
+func RegisterServerInterceptor(i Interceptor) {
+	interceptors.mu.Lock()
+	defer interceptors.mu.Unlock()
+	interceptors.server = append(interceptors.server, i)
+}
+
+// ClientInterceptor returns the registered client interceptors, chained, or
+// nil if there are none. It is used by client stubs.
+func ClientInterceptor() Interceptor {
+	interceptors.mu.Lock()
+	defer interceptors.mu.Unlock()
+	return chain(interceptors.client)
+}
+
+// ServerInterceptor returns the registered server interceptors, chained, or
+// nil if there are none. It is used by server stubs.
+func ServerInterceptor() Interceptor {
+	interceptors.mu.Lock()
+	defer interceptors.mu.Unlock()
+	return chain(interceptors.server)
+}
+
+// LocalInterceptor returns the registered client interceptors followed by the
+// registered server interceptors, chained, or nil if there are none. It is
+// used by local stubs, which are both the client and the server of a call.
+func LocalInterceptor() Interceptor {
+	interceptors.mu.Lock()
+	defer interceptors.mu.Unlock()
+	all := make([]Interceptor, 0, len(interceptors.client)+len(interceptors.server))
+	all = append(all, interceptors.client...)
+	all = append(all, interceptors.server...)
+	return chain(all)
+}
+
+// chain returns an interceptor that runs the provided interceptors in order,
+// the first being the outermost, or nil if there are no interceptors.
+func chain(is []Interceptor) Interceptor {
+	if len(is) == 0 {
+		return nil
+	}
+	is = append([]Interceptor(nil), is...)
+	return func(ctx context.Context, info CallInfo, invoke func(context.Context) error) error {
+		return intercept(ctx, is, info, invoke)
+	}
+}
+
+// intercept runs the call through the provided interceptors.
+func intercept(ctx context.Context, is []Interceptor, info CallInfo, invoke func(context.Context) error) error {
+	if len(is) == 0 {
+		return invoke(ctx)
+	}
+	return is[0](ctx, info, func(ctx context.Context) error {
+		return intercept(ctx, is[1:], info, invoke)
+	})
+}
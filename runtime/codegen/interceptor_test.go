@@ -0,0 +1,88 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChainEmpty(t *testing.T) {
+	if i := chain(nil); i != nil {
+		t.Fatal("chain(nil): got non-nil interceptor")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, info CallInfo, invoke func(context.Context) error) error {
+			trace = append(trace, name+" before")
+			err := invoke(ctx)
+			trace = append(trace, name+" after")
+			return err
+		}
+	}
+	i := chain([]Interceptor{record("a"), record("b")})
+	info := CallInfo{Component: "Foo", Method: "Bar"}
+	err := i(context.Background(), info, func(context.Context) error {
+		trace = append(trace, "call")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a before", "b before", "call", "b after", "a after"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("got %v, want %v", trace, want)
+	}
+}
+
+func TestChainShortCircuit(t *testing.T) {
+	errRejected := errors.New("rejected")
+	reject := func(context.Context, CallInfo, func(context.Context) error) error {
+		return errRejected
+	}
+	called := false
+	i := chain([]Interceptor{reject})
+	err := i(context.Background(), CallInfo{}, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("got %v, want %v", err, errRejected)
+	}
+	if called {
+		t.Fatal("call ran despite being rejected")
+	}
+}
+
+func TestChainContext(t *testing.T) {
+	type key struct{}
+	withValue := func(ctx context.Context, info CallInfo, invoke func(context.Context) error) error {
+		return invoke(context.WithValue(ctx, key{}, info.Method))
+	}
+	i := chain([]Interceptor{withValue})
+	var got any
+	i(context.Background(), CallInfo{Method: "Bar"}, func(ctx context.Context) error {
+		got = ctx.Value(key{})
+		return nil
+	})
+	if got != "Bar" {
+		t.Fatalf("got %v, want Bar", got)
+	}
+}
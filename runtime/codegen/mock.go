@@ -0,0 +1,302 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A MockCall is a method call recorded by a component mock generated by
+// "weaver generate -mocks".
+type MockCall struct {
+	Method string // name of the called method
+	Args   []any  // arguments of the call, excluding the initial context.Context
+}
+
+// MockRecorder records the method calls made to a component mock generated
+// by "weaver generate -mocks", and the calls the test expects. Every generated
+// mock embeds a MockRecorder. A MockRecorder is safe for concurrent use.
+type MockRecorder struct {
+	mu           sync.Mutex
+	calls        []MockCall
+	expectations []*MockExpectation
+	unexpected   []string // descriptions of the unexpected calls
+}
+
+// Record records a call to the provided method with the provided arguments.
+func (r *MockRecorder) Record(method string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, MockCall{Method: method, Args: args})
+}
+
+// Calls returns the recorded calls to the provided method, in the order in
+// which they were made. If method is empty, Calls returns all recorded calls.
+func (r *MockRecorder) Calls(method string) []MockCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []MockCall
+	for _, call := range r.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Expect adds an expectation that the provided method is called with
+// arguments matching the provided matchers, one per argument excluding the
+// initial context.Context. A nil matcher matches any argument. The call is
+// expected exactly once, unless the returned expectation says otherwise.
+//
+// Generated mocks have a typed ExpectFoo method for every method Foo that
+// calls Expect; tests should use those instead.
+func (r *MockRecorder) Expect(method string, args ...ArgMatcher) *MockExpectation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := &MockExpectation{r: r, method: method, args: args, min: 1, max: 1}
+	r.expectations = append(r.expectations, e)
+	return e
+}
+
+// Expected returns the expectation that a call to the provided method with
+// the provided arguments satisfies, and counts the call towards it. If there
+// are several, the expectation added first that still expects calls is
+// returned. Expected returns nil if no expectations were added for the
+// method, and an error if expectations were added but the call satisfies none
+// of them. The error is also reported by AssertExpectations.
+func (r *MockRecorder) Expected(method string, args ...any) (*MockExpectation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found, exhausted bool
+	for _, e := range r.expectations {
+		if e.method != method {
+			continue
+		}
+		found = true
+		if !e.matches(args) {
+			continue
+		}
+		if e.max >= 0 && e.calls >= e.max {
+			exhausted = true
+			continue
+		}
+		e.calls++
+		return e, nil
+	}
+	if !found {
+		return nil, nil
+	}
+	call := formatCall(method, args)
+	if exhausted {
+		call = fmt.Sprintf("%s, called more times than expected", call)
+	}
+	r.unexpected = append(r.unexpected, call)
+	return nil, fmt.Errorf("mock: unexpected call %s", call)
+}
+
+// MockT is the subset of testing.TB used by AssertExpectations.
+type MockT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertExpectations reports an error to t for every expectation that did
+// not get the calls it expects, and for every unexpected call.
+func (r *MockRecorder) AssertExpectations(t MockT) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.expectations {
+		if e.calls < e.min {
+			t.Errorf("mock: missing calls to %s: got %d, want %s", e, e.calls, e.times())
+		}
+	}
+	for _, call := range r.unexpected {
+		t.Errorf("mock: unexpected call %s", call)
+	}
+}
+
+// ResetExpectations discards all expectations and unexpected calls.
+func (r *MockRecorder) ResetExpectations() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expectations = nil
+	r.unexpected = nil
+}
+
+// ResetCalls discards all recorded calls.
+func (r *MockRecorder) ResetCalls() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// A MockExpectation is a call to a component mock expected by a test. See
+// MockRecorder.Expect.
+type MockExpectation struct {
+	r      *MockRecorder
+	method string
+	args   []ArgMatcher
+	min    int // minimum number of calls
+	max    int // maximum number of calls, or -1 if unbounded
+	calls  int // number of calls made
+	fn     any // function called for every call, or nil
+}
+
+// Times sets the number of times the call is expected to be made.
+func (e *MockExpectation) Times(n int) *MockExpectation {
+	e.r.mu.Lock()
+	defer e.r.mu.Unlock()
+	e.min, e.max = n, n
+	return e
+}
+
+// AnyTimes lets the call be made any number of times, including zero.
+func (e *MockExpectation) AnyTimes() *MockExpectation {
+	e.r.mu.Lock()
+	defer e.r.mu.Unlock()
+	e.min, e.max = 0, -1
+	return e
+}
+
+// SetFn sets the function that handles the expected calls. Generated mocks
+// call the function, which must have the type of the mocked method, and
+// return its results.
+func (e *MockExpectation) SetFn(fn any) *MockExpectation {
+	e.r.mu.Lock()
+	defer e.r.mu.Unlock()
+	e.fn = fn
+	return e
+}
+
+// Fn returns the function set by SetFn, or nil.
+func (e *MockExpectation) Fn() any {
+	e.r.mu.Lock()
+	defer e.r.mu.Unlock()
+	return e.fn
+}
+
+// String returns a description of the expected call, e.g., "Add(1, any)".
+func (e *MockExpectation) String() string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		if arg == nil {
+			args[i] = "any"
+		} else {
+			args[i] = arg.String()
+		}
+	}
+	return fmt.Sprintf("%s(%s)", e.method, strings.Join(args, ", "))
+}
+
+// times returns a description of the number of times e is expected.
+func (e *MockExpectation) times() string {
+	if e.max < 0 {
+		return fmt.Sprintf("at least %d", e.min)
+	}
+	return fmt.Sprint(e.min)
+}
+
+// matches returns whether the provided arguments match the matchers of e.
+func (e *MockExpectation) matches(args []any) bool {
+	if len(args) != len(e.args) {
+		return false
+	}
+	for i, arg := range args {
+		if e.args[i] != nil && !e.args[i].matches(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatCall returns a description of a call, e.g., "Add(1, [2 3])".
+func formatCall(method string, args []any) string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = fmt.Sprintf("%v", arg)
+	}
+	return fmt.Sprintf("%s(%s)", method, strings.Join(strs, ", "))
+}
+
+// A Matcher matches an argument of type T of a call to a component mock.
+// Eq, Any, and Match return matchers; a test can also implement its own.
+type Matcher[T any] interface {
+	// Matches returns whether the argument matches.
+	Matches(arg T) bool
+
+	// String returns a description of the matched arguments, used in error
+	// messages.
+	String() string
+}
+
+// Eq returns a matcher of the arguments that are equal to v, as reported by
+// reflect.DeepEqual.
+func Eq[T any](v T) Matcher[T] {
+	return Match(fmt.Sprintf("%v", v), func(arg T) bool {
+		return reflect.DeepEqual(arg, v)
+	})
+}
+
+// Any returns a matcher of every argument.
+func Any[T any]() Matcher[T] {
+	return Match("any", func(T) bool { return true })
+}
+
+// Match returns a matcher of the arguments for which f returns true,
+// described by desc.
+func Match[T any](desc string, f func(T) bool) Matcher[T] {
+	return funcMatcher[T]{desc, f}
+}
+
+type funcMatcher[T any] struct {
+	desc string
+	f    func(T) bool
+}
+
+func (m funcMatcher[T]) Matches(arg T) bool { return m.f(arg) }
+func (m funcMatcher[T]) String() string     { return m.desc }
+
+// An ArgMatcher is a Matcher of any type. Generated mocks convert the typed
+// matchers passed to their ExpectFoo methods to ArgMatchers using MockArg.
+type ArgMatcher interface {
+	matches(arg any) bool
+	String() string
+}
+
+// MockArg returns the provided matcher as an ArgMatcher, or nil if m is nil.
+func MockArg[T any](m Matcher[T]) ArgMatcher {
+	if m == nil {
+		return nil
+	}
+	return argMatcher[T]{m}
+}
+
+type argMatcher[T any] struct{ m Matcher[T] }
+
+func (a argMatcher[T]) String() string { return a.m.String() }
+
+func (a argMatcher[T]) matches(arg any) bool {
+	t, ok := arg.(T)
+	if !ok && arg != nil {
+		return false
+	}
+	// A nil arg is the zero value of an interface type T.
+	return a.m.Matches(t)
+}
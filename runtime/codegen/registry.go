@@ -57,10 +57,12 @@ type Registration struct {
 	ConfigFn func(impl any) any // returns pointer to config field in local impl if non-nil
 	Routed   bool               // True if calls to this component should be routed
 
-	// Functions that return different types of stubs.
+	// Functions that return different types of stubs. Client and server
+	// stubs encode the arguments and results of calls with codec, or with the
+	// generated encoding if codec is nil.
 	LocalStubFn  func(impl any, tracer trace.Tracer) any
-	ClientStubFn func(stub Stub, caller string) any
-	ServerStubFn func(impl any, load func(key uint64, load float64)) Server
+	ClientStubFn func(stub Stub, caller string, codec Codec) any
+	ServerStubFn func(impl any, load func(key uint64, load float64), codec Codec) Server
 }
 
 // register registers a Service Weaver component. If the registry's close method was
@@ -92,8 +92,8 @@ func (t testconfig) Validate() error {
 // Register dummy components for test.
 func init() {
 	local := func(any, trace.Tracer) any { return nil }
-	client := func(codegen.Stub, string) any { return nil }
-	server := func(any, func(uint64, float64)) codegen.Server { return nil }
+	client := func(codegen.Stub, string, codegen.Codec) any { return nil }
+	server := func(any, func(uint64, float64), codegen.Codec) codegen.Server { return nil }
 
 	codegen.Register(codegen.Registration{
 		Name:         typeWithoutConfig,
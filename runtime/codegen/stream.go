@@ -0,0 +1,178 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codegen
+
+import (
+	"errors"
+	"io"
+)
+
+// Stream is a stream of values returned by, or passed to, a streaming
+// component method. It mirrors weaver.Stream, which this package can't
+// import.
+type Stream[T any] interface {
+	Recv() (T, error)
+	Close()
+}
+
+// A ServerStream allows a server stub to exchange serialized values with the
+// caller of a streaming method.
+type ServerStream interface {
+	// Send sends a serialized value to the caller.
+	Send([]byte) error
+
+	// Recv returns the next serialized value sent by the caller, or io.EOF
+	// once the caller has sent all of its values.
+	Recv() ([]byte, error)
+}
+
+// A ClientStream allows a client stub to exchange serialized values with the
+// server stub of a streaming method.
+type ClientStream interface {
+	// Send sends a serialized value to the server.
+	Send([]byte) error
+
+	// CloseSend informs the server that no more values will be sent.
+	CloseSend() error
+
+	// Recv returns the next serialized value sent by the server, or io.EOF
+	// once the server has sent all of its values.
+	Recv() ([]byte, error)
+
+	// Close ends the call.
+	Close()
+}
+
+// Every value sent on a stream is encoded as a nil error followed by the
+// value. A stream that fails ends with the encoding of the non-nil error.
+
+// sendValues sends the values of src using send, until src ends or send
+// fails. If src fails, its error is sent as well. sendValues closes src.
+func sendValues[T any](send func([]byte) error, src Stream[T], encode func(*Encoder, T)) (err error) {
+	defer src.Close()
+	defer func() {
+		if err == nil {
+			err = CatchPanics(recover())
+		}
+	}()
+	for {
+		v, err := src.Recv()
+		enc := NewEncoder()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			enc.Error(err)
+			return send(enc.Data())
+		}
+		enc.Error(nil)
+		encode(enc, v)
+		if err := send(enc.Data()); err != nil {
+			return err
+		}
+	}
+}
+
+// SendServerValues sends the values of the stream returned by a streaming
+// method to the caller. If the method failed with appErr, appErr is sent
+// instead.
+func SendServerValues[T any](s ServerStream, src Stream[T], appErr error, encode func(*Encoder, T)) error {
+	if appErr != nil {
+		enc := NewEncoder()
+		enc.Error(appErr)
+		return s.Send(enc.Data())
+	}
+	if src == nil {
+		return nil
+	}
+	return sendValues(s.Send, src, encode)
+}
+
+// ServerValues returns the stream of values sent by the caller of a
+// bidirectional streaming method.
+func ServerValues[T any](s ServerStream, decode func(*Decoder) T) Stream[T] {
+	return &streamValues[T]{recv: s.Recv, decode: decode, wrap: func(err error) error { return err }}
+}
+
+// ClientValues returns the stream of values sent by the server stub of a
+// streaming method. Errors returned by s are wrapped with wrap.
+func ClientValues[T any](s ClientStream, decode func(*Decoder) T, wrap func(error) error) Stream[T] {
+	return &streamValues[T]{recv: s.Recv, decode: decode, wrap: wrap, close: s.Close}
+}
+
+// BidiClientValues is like ClientValues, but also sends the values of src,
+// which are passed to a bidirectional streaming method, to the server stub.
+// src is closed once all of its values have been sent, or the returned
+// stream is closed.
+func BidiClientValues[In, Out any](s ClientStream, src Stream[In], encode func(*Encoder, In), decode func(*Decoder) Out, wrap func(error) error) Stream[Out] {
+	go func() {
+		if err := sendValues(s.Send, src, encode); err == nil {
+			s.CloseSend() //nolint:errcheck // reported by Recv
+		}
+	}()
+	return &streamValues[Out]{
+		recv:   s.Recv,
+		decode: decode,
+		wrap:   wrap,
+		close: func() {
+			s.Close()
+			src.Close()
+		},
+	}
+}
+
+// streamValues is a Stream of the values received by a stub.
+type streamValues[T any] struct {
+	recv   func() ([]byte, error)
+	decode func(*Decoder) T
+	wrap   func(error) error
+	close  func() // if not nil, called by Close
+
+	err error // if not nil, the error that ended the stream
+}
+
+// Recv implements the Stream interface.
+func (v *streamValues[T]) Recv() (value T, err error) {
+	if v.err != nil {
+		return value, v.err
+	}
+	defer func() {
+		if err == nil {
+			err = CatchPanics(recover())
+		}
+		if err != nil {
+			v.err = err
+		}
+	}()
+
+	data, err := v.recv()
+	if errors.Is(err, io.EOF) {
+		return value, io.EOF
+	} else if err != nil {
+		return value, v.wrap(err)
+	}
+	dec := NewDecoder(data)
+	if err := dec.Error(); err != nil {
+		return value, err
+	}
+	return v.decode(dec), nil
+}
+
+// Close implements the Stream interface. Close may be called concurrently
+// with Recv.
+func (v *streamValues[T]) Close() {
+	if v.close != nil {
+		v.close()
+	}
+}
@@ -36,6 +36,11 @@ type Stub interface {
 	// key for routed components, and 0 otherwise.
 	Run(ctx context.Context, method int, args []byte, shardKey uint64) (results []byte, err error)
 
+	// Stream starts a call of the provided streaming method, with the
+	// provided serialized arguments. The values streamed to and from the
+	// method are exchanged over the returned stream.
+	Stream(ctx context.Context, method int, args []byte, shardKey uint64) (ClientStream, error)
+
 	// WrapError embeds ErrRetriable into the appropriate errors. The codegen
 	// package cannot perform this wrapping itself because of cyclic
 	// dependencies.
@@ -53,4 +58,10 @@ type Server interface {
 	//
 	// TODO(mwhittaker): Rename GetHandler? This is returning a call.Handler.
 	GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error)
+
+	// GetStreamFn returns a handler function for the given streaming method,
+	// or nil if the method isn't a streaming method. The handler deserializes
+	// the arguments, executes the method, and streams the serialized values
+	// to and from the caller over the provided stream.
+	GetStreamFn(method string) func(ctx context.Context, args []byte, stream ServerStream) error
 }
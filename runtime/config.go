@@ -16,13 +16,18 @@ package runtime
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/ServiceWeaver/weaver/internal/env"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"google.golang.org/protobuf/proto"
 )
 
 // ParseConfig parses the specified configuration input, which should
@@ -62,6 +67,15 @@ func ParseConfig(file string, input string, sectionValidator func(string, string
 	return config, nil
 }
 
+// IsComponentSection returns whether the config section with the provided key
+// configures a component, rather than Service Weaver or one of its deployers.
+// The sections of Service Weaver and its deployers have a short name without
+// slashes, or are named after a package path under
+// github.com/ServiceWeaver/weaver.
+func IsComponentSection(key string) bool {
+	return strings.Contains(key, "/") && !strings.HasPrefix(key+"/", "github.com/ServiceWeaver/weaver/")
+}
+
 // ParseConfigSection parses the config section for key into dst.
 // If shortKey is not empty, either key or shortKey is accepted.
 // If the named section is not found, returns nil without changing dst.
@@ -95,21 +109,2678 @@ func ParseConfigSection(key, shortKey string, sections map[string]string, dst an
 	return nil
 }
 
-func extractApp(file string, config *protos.AppConfig) error {
-	const appKey = "github.com/ServiceWeaver/weaver"
-	const shortAppKey = "serviceweaver"
+const (
+	appKey      = "github.com/ServiceWeaver/weaver"
+	shortAppKey = "serviceweaver"
+)
+
+// appConfig holds the data from under appKey in the TOML config.
+// It matches the contents of the Config proto, with the exception of the
+// per-method configuration, which is consumed by weavelets directly from the
+// config sections.
+type appConfig struct {
+	Name     string
+	Binary   string
+	Args     []string
+	Env      []string
+	Colocate [][]string
+	Separate [][]string
+	Rollout  time.Duration
+
+	DependencyGraph bool `toml:"dependency_graph"`
+
+	// Size limit, in bytes, of the cache of method results kept by every
+	// process. See MethodConfig.CacheTTL.
+	CacheMaxBytes int64 `toml:"cache_max_bytes"`
+
+	// Admission control of remote calls. See WeaveletConfig.
+	MaxConcurrentCalls int `toml:"max_concurrent_calls"`
+	MaxQueuedCalls     int `toml:"max_queued_calls"`
+
+	// Flow control of remote calls. See WeaveletConfig.
+	MaxInFlightCalls int `toml:"max_in_flight_calls"`
+	MaxPendingCalls  int `toml:"max_pending_calls"`
+
+	// Draining of weavelets. See WeaveletConfig.
+	DrainGracePeriod time.Duration `toml:"drain_grace_period"`
+	MaxDrainTime     time.Duration `toml:"max_drain_time"`
+	ShutdownTimeout  time.Duration `toml:"shutdown_timeout"`
+
+	// Load balancing of remote calls. See WeaveletConfig.
+	LoadBalancing string `toml:"load_balancing"`
+
+	// Compression of remote calls. See WeaveletConfig.
+	Compression          string `toml:"compression"`
+	CompressionThreshold int    `toml:"compression_threshold"`
+
+	// Startup of weavelets. See WeaveletConfig.
+	WarmPoolSize   int           `toml:"warm_pool_size"`
+	StartupTimeout time.Duration `toml:"startup_timeout"`
+
+	// Health checks of components. See WeaveletConfig.
+	HealthCheckInterval time.Duration `toml:"health_check_interval"`
+	HealthCheckTimeout  time.Duration `toml:"health_check_timeout"`
+
+	// Listener streams. See WeaveletConfig.
+	StreamIdleTimeout time.Duration `toml:"stream_idle_timeout"`
+
+	// Prometheus metrics endpoints. See WeaveletConfig.
+	MetricsAddress         string `toml:"metrics_address"`
+	DeployerMetricsAddress string `toml:"deployer_metrics_address"`
+
+	// Webhook notified of deployment events. See WeaveletConfig.
+	EventsWebhook string `toml:"events_webhook"`
+
+	// Alerts on deployment events. See WeaveletConfig.
+	Alerts AlertsConfig `toml:"alerts"`
+
+	// Mutual TLS between weavelets. See WeaveletConfig.
+	MTLS bool `toml:"mtls"`
+
+	// Transport of calls between weavelets. See WeaveletConfig.
+	Transport string `toml:"transport"`
+
+	// Export of traces and metrics over OTLP. See WeaveletConfig.
+	OTLP OTLPConfig `toml:"otlp"`
+
+	// Sampling of traces. See WeaveletConfig.
+	Tracing TracingConfig `toml:"tracing"`
+
+	// Fault injection for chaos testing. See WeaveletConfig.
+	Chaos ChaosConfig `toml:"chaos"`
+
+	// Recording of remote calls. See WeaveletConfig.
+	Recording RecordingConfig `toml:"recording"`
+
+	// Export of log entries. See WeaveletConfig.
+	Logging LoggingConfig `toml:"logging"`
+
+	// Continuous profiling. See WeaveletConfig.
+	Profiling ProfilingConfig `toml:"profiling"`
+
+	// The components exported to other deployments. See WeaveletConfig.
+	Federation FederationConfig `toml:"federation"`
+
+	// Retention of the data stored by deployers. See WeaveletConfig.
+	Retention RetentionConfig `toml:"retention"`
+
+	// Per-component call policies, keyed by full component name, e.g.,
+	// "github.com/my/project/package/ComponentName".
+	Components map[string]CallConfig
+
+	// Per-co-location group resource limits, keyed by full component name.
+	Resources map[string]ResourceLimits
+
+	// Per-co-location group process settings, keyed by full component name.
+	Process map[string]ProcessConfig
+
+	// Per-co-location group autoscaling policies, keyed by full component
+	// name.
+	Autoscaling map[string]AutoscalingConfig
+
+	// Per-component circuit breakers, keyed by full component name.
+	Breakers map[string]BreakerConfig
+
+	// Per-component admission control, keyed by full component name.
+	Admission map[string]AdmissionConfig
+
+	// Per-component routing policies, keyed by full component name.
+	Routing map[string]RoutingConfig
+
+	// Per-component startup policies, keyed by full component name.
+	Startup map[string]StartupConfig
+
+	// Backends of the stores created with weaver.NewStore, keyed by store
+	// name.
+	Stores map[string]StoreConfig
+
+	// Connection settings of external services, keyed by service name.
+	External map[string]ExternalConfig
+
+	// Metrics backends, keyed by exporter name.
+	Metrics map[string]MetricsConfig
+
+	// Components of other deployments, keyed by full component name.
+	Imports map[string]ImportConfig
+
+	// Authentication and authorization of the requests received by
+	// listeners, keyed by listener name.
+	Auth map[string]AuthConfig
+
+	// Connection limits of listeners, keyed by listener name.
+	Listeners map[string]ListenerConfig
+
+	// Authorization of the calls made by components, keyed by the full name
+	// of the calling component.
+	Access map[string]AccessConfig
+
+	// Priorities of the calls made by components, keyed by the full name of
+	// the calling component.
+	Priorities map[string]string
+
+	// Names of the codecs of components, keyed by full component name.
+	Codecs map[string]string
+
+	// Per-method configuration, keyed by full method name, e.g.,
+	// "github.com/my/project/package/ComponentName.MethodName".
+	Methods map[string]MethodConfig
+}
+
+// WeaveletConfig holds the parts of the application config that weavelets,
+// and the deployers that manage them, read directly from the config sections,
+// rather than from the Config proto.
+type WeaveletConfig struct {
+	// Size limit, in bytes, of the cache of method results, or zero if not
+	// specified.
+	CacheMaxBytes int64
+
+	// If positive, every weavelet runs at most MaxConcurrentCalls remote
+	// calls at a time, and queues at most MaxQueuedCalls additional calls
+	// of every priority class. Other calls are shed.
+	MaxConcurrentCalls int
+	MaxQueuedCalls     int
+
+	// If positive, at most MaxInFlightCalls calls are in flight, and at most
+	// MaxPendingCalls additional calls wait, on every connection between two
+	// weavelets. Both the caller and the callee enforce the limits, so that
+	// a slow weavelet can't be flooded with calls. Other calls fail with
+	// weaver.ErrOverloaded, without being run.
+	MaxInFlightCalls int
+	MaxPendingCalls  int
+
+	// When asked to drain by the deployer, a weavelet reports itself as
+	// draining and keeps serving as usual for DrainGracePeriod, giving load
+	// balancers and callers time to shift traffic away from it. It then runs
+	// the hooks registered with weaver.OnDrain and waits for outstanding
+	// component method calls to finish. The whole drain takes at most
+	// MaxDrainTime, which defaults to DefaultMaxDrainTime. Finally, the
+	// weavelet calls the Shutdown methods of its components, which together
+	// take at most ShutdownTimeout, which defaults to DefaultShutdownTimeout.
+	DrainGracePeriod time.Duration
+	MaxDrainTime     time.Duration
+	ShutdownTimeout  time.Duration
+
+	// The policy used to pick the replica of a component that runs a remote
+	// call: "round_robin", "least_outstanding", or "latency_weighted". Empty
+	// means "round_robin". See the LeastOutstanding and LatencyWeighted
+	// balancers in the internal/net/call package. Calls to routed methods
+	// with a routing key ignore the policy, as do calls to components with a
+	// balancer registered with weaver.UseBalancer.
+	LoadBalancing string
+
+	// The algorithm used to compress the arguments and results of remote
+	// calls: "none", "snappy", or "zstd". Empty means "none". Arguments and
+	// results smaller than CompressionThreshold bytes, which defaults to
+	// DefaultCompressionThreshold, are not compressed.
+	Compression          string
+	CompressionThreshold int
+
+	// A weavelet only receives calls once it has initialized its components
+	// and reports itself as healthy. A weavelet that fails to become healthy
+	// within StartupTimeout, which defaults to DefaultStartupTimeout, is
+	// considered failed. Deployers that support it additionally keep
+	// WarmPoolSize healthy weavelets per co-location group that receive no
+	// calls, and swap one in, already initialized, when a serving weavelet
+	// fails.
+	WarmPoolSize   int
+	StartupTimeout time.Duration
+
+	// Every weavelet calls the HealthCheck method of every local component
+	// that has one every HealthCheckInterval, which defaults to
+	// DefaultHealthCheckInterval, and fails checks that take longer than
+	// HealthCheckTimeout, which defaults to DefaultHealthCheckTimeout. A
+	// weavelet isn't healthy until the checks of its components first pass,
+	// and is unhealthy while a check fails afterwards. Deployers that support
+	// it check the health of weavelets every HealthCheckInterval, and stop
+	// sending calls and listener traffic to unhealthy weavelets until they
+	// are healthy again.
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+
+	// Deployers that proxy listener traffic, like the multi and ssh
+	// deployers, forward WebSocket and other upgraded connections, and
+	// Server-Sent Events streams, to the weavelets. A proxy closes a stream
+	// once no data has flowed on it for StreamIdleTimeout, which defaults to
+	// DefaultStreamIdleTimeout, and closes the streams to a weavelet once it
+	// stops receiving listener traffic, e.g., because it drains, so that
+	// clients reconnect to another weavelet.
+	StreamIdleTimeout time.Duration
+
+	// If not empty, every weavelet serves its own metrics in the Prometheus
+	// text format at /metrics on MetricsAddress, which has the form
+	// "host:port". If the port is zero, every weavelet picks an unused port
+	// and logs it; use a fixed port only if at most one weavelet runs per
+	// machine. If DeployerMetricsAddress is not empty, deployers that support
+	// it serve the metrics of all the weavelets in the deployment, aggregated,
+	// at /metrics on DeployerMetricsAddress.
+	MetricsAddress         string
+	DeployerMetricsAddress string
+
+	// If not empty, deployers that record the events of deployments, e.g.,
+	// the crash of a replica or a step of a rollout, POST every event, JSON
+	// encoded, to the EventsWebhook URL, e.g., to page someone.
+	EventsWebhook string
+
+	// The alerts that deployers that record the events of deployments send
+	// to Slack, PagerDuty, or a webhook, e.g., when the replicas of a
+	// co-location group crash in a loop.
+	Alerts AlertsConfig
+
+	// If true, weavelets authenticate each other, and encrypt the calls
+	// between them, using mutual TLS. Every weavelet gets a short-lived
+	// certificate, signed by a certificate authority unique to the
+	// deployment, from its deployer, and gets a new one before it expires.
+	// Weavelets fail to start if their deployer doesn't support mutual TLS.
+	MTLS bool
+
+	// The transport of remote calls between weavelets: "tcp" or "quic".
+	// Empty means "tcp", except in single machine deployments, where
+	// weavelets listen on Unix sockets instead, which are cheaper than TCP
+	// loopback connections. With "quic", weavelets listen on UDP, and all the
+	// calls from one weavelet to another share a single QUIC connection, with
+	// the calls to every component on a stream of their own, so that a lost
+	// packet only delays the calls on its stream. QUIC calls are always
+	// encrypted; if MTLS is false, they are not authenticated. The QUIC
+	// transport is only compiled into binaries built with "-tags quic".
+	Transport string
+
+	// If OTLP.Endpoint is not empty, every weavelet exports its traces and
+	// metrics to OTLP.Endpoint, in addition to its deployer.
+	OTLP OTLPConfig
+
+	// The samplers that decide which traces are recorded and exported, both
+	// to the deployer and to OTLP.Endpoint.
+	Tracing TracingConfig
+
+	// The schedule of the faults injected into the deployment by deployers
+	// that support chaos testing, when enabled, e.g., with "weaver multi
+	// deploy --chaos".
+	Chaos ChaosConfig
+
+	// If Recording.Dir is not empty, every weavelet records the remote calls
+	// to the components it hosts in Recording.Dir, so that they can be
+	// replayed later, e.g., with weavertest.Replay.
+	Recording RecordingConfig
+
+	// The logging backends every weavelet exports the log entries of its
+	// components to, in addition to its deployer.
+	Logging LoggingConfig
+
+	// If Profiling.Interval is not zero, every weavelet profiles itself
+	// periodically, and sends its profiles to its deployer and to the
+	// enabled profiling backends.
+	Profiling ProfilingConfig
+
+	// The policies that deployers that store the logs and traces of
+	// deployments on the local machine use to delete the data of old
+	// deployments of the application.
+	Retention RetentionConfig
+
+	// Per-component call policies, keyed by full component name. A
+	// component's policy applies to all of its methods, except where
+	// overridden by the method's config.
+	Components map[string]CallConfig
+
+	// Per-component routing policies, keyed by full component name. Used by
+	// deployers to assign the routing keys of routed components to replicas.
+	Routing map[string]RoutingConfig
+
+	// Resource limits of co-location groups, keyed by the full name of a
+	// component in the group. Deployers that support it limit the CPU and
+	// memory used by every weavelet of the group hosting the component.
+	Resources map[string]ResourceLimits
+
+	// Sets of components, by full name, that must never be co-located with
+	// each other, e.g., to isolate their failures. The config is rejected if
+	// the colocate field places two components of a set in the same group.
+	Separate [][]string
+
+	// Process settings of co-location groups, keyed by the full name of a
+	// component in the group. The single process, multiprocess, and SSH
+	// deployers start the weavelets of the group hosting the component with
+	// the settings. See GroupProcessConfigs and ApplyProcessConfig.
+	Processes map[string]ProcessConfig
+
+	// Autoscaling policies of co-location groups, keyed by the full name of
+	// a component in the group. Deployers that support it scale the number
+	// of weavelets of the group hosting the component between the policy's
+	// bounds. Other groups have a fixed number of weavelets.
+	Autoscaling map[string]AutoscalingConfig
+
+	// Circuit breakers, keyed by full component name. Every weavelet that
+	// calls a component with a breaker stops calling it for a while once
+	// too many of its calls to the component fail.
+	Breakers map[string]BreakerConfig
+
+	// Admission control policies, keyed by full component name. Every
+	// weavelet hosting a component with a policy limits the remote calls to
+	// the component it runs, in addition to the limits set by
+	// MaxConcurrentCalls and MaxQueuedCalls for all the calls it receives.
+	Admission map[string]AdmissionConfig
+
+	// Startup policies, keyed by full component name. A lazy component is
+	// only started once it is first called, rather than when it is first
+	// fetched with weaver.Ref or weaver.Get.
+	Startup map[string]StartupConfig
+
+	// Backends of the stores created with weaver.NewStore, keyed by store
+	// name. A store without a config is kept in memory.
+	Stores map[string]StoreConfig
+
+	// Connection settings of the external services, like databases, used by
+	// components, keyed by service name. Every weavelet opens one client of
+	// a service, shared by its components, and checks its health along with
+	// the health of its components. See weaver.External.
+	External map[string]ExternalConfig
+
+	// Metrics backends, keyed by exporter name. Every weavelet exports its
+	// metrics to every backend periodically. See
+	// weaver.RegisterMetricExporter.
+	Metrics map[string]MetricsConfig
+
+	// If Federation.Address is not empty, the weavelets hosting the main
+	// component serve the components in Federation.Exports to other
+	// deployments, authenticated with mutual TLS, on Federation.Address.
+	Federation FederationConfig
+
+	// Components of other deployments, keyed by full component name. Calls
+	// to an imported component are sent to the federation gateway of the
+	// deployment that exports it, and the component is never started in
+	// this deployment.
+	Imports map[string]ImportConfig
+
+	// Authentication and authorization of the HTTP requests received by
+	// listeners, keyed by listener name, enforced by the middleware returned
+	// by weaver.Authenticate.
+	Auth map[string]AuthConfig
+
+	// Connection limits of listeners, keyed by listener name. Every
+	// weavelet enforces the limits of the listeners it creates.
+	Listeners map[string]ListenerConfig
+
+	// Authorization policies of the calls between components, keyed by the
+	// full name of the calling component. Every weavelet hosting a component
+	// rejects the remote calls to the component that the policy of the
+	// caller doesn't allow. Calls from components without a policy are
+	// allowed.
+	Access map[string]AccessConfig
+
+	// Priority classes of the remote calls made by components, keyed by the
+	// full name of the calling component, e.g.:
+	//
+	//	[serviceweaver.priorities]
+	//	"main" = "high"
+	//	"github.com/my/project/indexer/Reindexer" = "low"
+	//
+	// A priority is "low", "normal", or "high". The priority of a call
+	// selects the admission control queue the call waits in at the callee
+	// (see MaxConcurrentCalls and AdmissionConfig). A priority passed to
+	// weaver.WithPriority overrides the priority of the caller, which
+	// overrides the priority of the called method (see MethodConfig).
+	Priorities map[string]string
+
+	// Names of the codecs that encode the arguments and results of the
+	// remote calls to components, keyed by full component name, e.g.:
+	//
+	//	[serviceweaver.codecs]
+	//	"github.com/my/project/frontend/T" = "msgpack"
+	//
+	// Codecs are registered with weaver.RegisterCodec. Calls to components
+	// without a codec are encoded with the code generated by "weaver
+	// generate".
+	Codecs map[string]string
+
+	// Per-method configuration, keyed by full method name.
+	Methods map[string]MethodConfig
+}
+
+const (
+	// DefaultMaxDrainTime is the default value of WeaveletConfig.MaxDrainTime.
+	DefaultMaxDrainTime = 30 * time.Second
+
+	// DefaultShutdownTimeout is the default value of
+	// WeaveletConfig.ShutdownTimeout.
+	DefaultShutdownTimeout = 10 * time.Second
+
+	// DefaultStartupTimeout is the default value of
+	// WeaveletConfig.StartupTimeout.
+	DefaultStartupTimeout = 5 * time.Minute
+
+	// DefaultHealthCheckInterval is the default value of
+	// WeaveletConfig.HealthCheckInterval.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultHealthCheckTimeout is the default value of
+	// WeaveletConfig.HealthCheckTimeout.
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	// DefaultStreamIdleTimeout is the default value of
+	// WeaveletConfig.StreamIdleTimeout.
+	DefaultStreamIdleTimeout = 5 * time.Minute
+
+	// DefaultCompressionThreshold is the default value of
+	// WeaveletConfig.CompressionThreshold.
+	DefaultCompressionThreshold = 1024
+
+	// DefaultScaleDownDelay is the default value of
+	// AutoscalingConfig.ScaleDownDelay.
+	DefaultScaleDownDelay = 5 * time.Minute
+
+	// DefaultBreakerWindow is the default value of BreakerConfig.Window.
+	DefaultBreakerWindow = 10 * time.Second
+
+	// DefaultBreakerMinCalls is the default value of BreakerConfig.MinCalls.
+	DefaultBreakerMinCalls = 20
+
+	// DefaultBreakerCooldown is the default value of BreakerConfig.Cooldown.
+	DefaultBreakerCooldown = 5 * time.Second
+
+	// DefaultQueueDelayInterval is the default value of
+	// AdmissionConfig.QueueDelayInterval.
+	DefaultQueueDelayInterval = 100 * time.Millisecond
+)
+
+// CallConfig holds the policy of remote calls to component methods. It is
+// specified in the config for all the methods of a component, in a section of
+// the form:
+//
+//	[serviceweaver.components."github.com/my/project/package/ComponentName"]
+//	timeout = "2s"
+//
+// and for a single method in the method's config (see MethodConfig). Every
+// setting of a method's policy that is not specified for the method is taken
+// from its component's policy.
+type CallConfig struct {
+	// If positive, calls to the method fail with context.DeadlineExceeded if
+	// they don't finish within Timeout, retries included.
+	Timeout time.Duration `toml:"timeout"`
+
+	// The number of times a call that fails with a retriable system error,
+	// e.g., because the callee is unreachable or shed the call, is retried.
+	// A retried call may have already run, so retries should only be enabled
+	// for idempotent methods.
+	MaxRetries int `toml:"max_retries"`
+
+	// The base delay of the exponential backoff between retries. Zero means
+	// that calls are retried immediately.
+	RetryBackoff time.Duration `toml:"retry_backoff"`
+
+	// If positive, a call that hasn't finished within HedgeDelay is hedged:
+	// a second call is sent to another replica, and the results of the first
+	// call to succeed are used. A hedged call may run twice, so hedging should
+	// only be enabled for idempotent methods.
+	HedgeDelay time.Duration `toml:"hedge_delay"`
+
+	// If positive, a call whose serialized arguments are larger than
+	// MaxRequestBytes fails with weaver.ErrPayloadTooLarge without being
+	// sent, and a call whose serialized results are larger than
+	// MaxResponseBytes fails with weaver.ErrPayloadTooLarge, without the
+	// results being sent back. This keeps an oversized payload from stalling
+	// the other calls that share its connection.
+	MaxRequestBytes  int64 `toml:"max_request_bytes"`
+	MaxResponseBytes int64 `toml:"max_response_bytes"`
+}
+
+// RoutingConfig holds the policy used to assign the routing keys of a routed
+// component to the component's replicas. It is specified in the config in a
+// section of the form:
+//
+//	[serviceweaver.routing."github.com/my/project/package/ComponentName"]
+//	strategy = "consistent_hash"
+//	replication = 2
+type RoutingConfig struct {
+	// The assignment strategy: "equal_slices" or "consistent_hash". Empty
+	// means "equal_slices", which splits the key space into equal slices,
+	// but moves most keys whenever the set of replicas changes.
+	// "consistent_hash" moves few keys when replicas are added or removed.
+	// See the EqualSlices and ConsistentHash functions in the
+	// internal/routing package.
+	Strategy string `toml:"strategy"`
+
+	// The number of replicas every key is assigned to, for
+	// "consistent_hash". Calls with the key are spread across these
+	// replicas. Zero means one.
+	Replication int `toml:"replication"`
+
+	// For "consistent_hash", the key space assigned to any replica is at
+	// most LoadFactor times the average. Zero means 1.25. Must be at least 1
+	// if specified.
+	LoadFactor float64 `toml:"load_factor"`
+}
+
+// ResourceLimits holds the CPU and memory limits of every weavelet of a
+// co-location group. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.resources."github.com/my/project/package/ComponentName"]
+//	cpu = 1.5
+//	memory_bytes = 536870912
+//
+// The limits apply to the co-location group hosting the component.
+type ResourceLimits struct {
+	// The number of CPU cores every weavelet may use, e.g., 0.5 for half a
+	// core. Zero means unlimited.
+	CPU float64 `toml:"cpu"`
+
+	// The amount of memory, in bytes, every weavelet may use. Zero means
+	// unlimited.
+	MemoryBytes int64 `toml:"memory_bytes"`
+}
+
+// ProcessConfig holds the settings of the processes of a co-location group.
+// It is specified in the config in a section of the form:
+//
+//	[serviceweaver.process."github.com/my/project/package/ComponentName"]
+//	env = ["PLATFORM=gcp", "CACHE_SIZE=1024"]
+//	args = ["--verbose"]
+//	dir = "/var/lib/frontend"
+//
+// The settings apply to the co-location group hosting the component. In a
+// single process deployment, where all the components run in the process
+// that is already running, the env variables and working directories of all
+// the groups are applied to the process, and args are ignored.
+type ProcessConfig struct {
+	// Environment variables, of the form "key=value", set in addition to the
+	// env variables of the [serviceweaver] section. A variable set in both
+	// takes its value from Env.
+	Env []string `toml:"env"`
+
+	// Command-line arguments passed after the args of the [serviceweaver]
+	// section.
+	Args []string `toml:"args"`
+
+	// The working directory of the processes. Relative paths are interpreted
+	// relative to the working directory of the deployer. Empty means the
+	// working directory of the deployer.
+	Dir string `toml:"dir"`
+}
+
+// AutoscalingConfig holds the policy used to scale the number of weavelets of
+// a co-location group. It is specified in the config in a section of the
+// form:
+//
+//	[serviceweaver.autoscaling."github.com/my/project/package/ComponentName"]
+//	min_replicas = 2
+//	max_replicas = 10
+//	target_request_rate = 100
+//	target_p99_latency = "50ms"
+//	target_cpu = 0.5
+//
+// The policy applies to the co-location group hosting the component. Every
+// target is optional, but at least one must be specified. The group is scaled
+// so that every signal with a target is close to it, and is never scaled
+// below the number of weavelets that any of the signals calls for.
+type AutoscalingConfig struct {
+	// Bounds on the number of weavelets. MinReplicas defaults to 1.
+	// MaxReplicas is required.
+	MinReplicas int `toml:"min_replicas"`
+	MaxReplicas int `toml:"max_replicas"`
+
+	// The number of component method calls per second that every weavelet
+	// of the group should receive.
+	TargetRequestRate float64 `toml:"target_request_rate"`
+
+	// The 99th percentile latency of the component method calls to the
+	// group, as seen by the callers.
+	TargetP99Latency time.Duration `toml:"target_p99_latency"`
+
+	// The number of CPU cores that every weavelet of the group should use,
+	// e.g., 0.5 for half a core.
+	TargetCPU float64 `toml:"target_cpu"`
+
+	// The group is only scaled down once the signals have called for fewer
+	// weavelets for ScaleDownDelay, which defaults to DefaultScaleDownDelay.
+	// The group is scaled up right away.
+	ScaleDownDelay time.Duration `toml:"scale_down_delay"`
+}
+
+// BreakerConfig holds the policy of the circuit breaker of calls to a
+// component. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.breakers."github.com/my/project/package/ComponentName"]
+//	consecutive_failures = 5
+//	error_rate = 0.5
+//	cooldown = "10s"
+//
+// Every weavelet keeps its own breaker for every component it calls. A call
+// fails if it returns an error other than an application error, e.g., because
+// the component is unreachable, sheds the call, or doesn't reply before the
+// call's deadline. Once the breaker trips, calls fail fast with
+// weaver.ErrCircuitOpen for Cooldown. Then, a single trial call is let
+// through: the breaker closes if it succeeds, and trips again if it fails.
+type BreakerConfig struct {
+	// If positive, the breaker trips after ConsecutiveFailures calls in a row
+	// fail.
+	ConsecutiveFailures int `toml:"consecutive_failures"`
+
+	// If positive, the breaker trips when more than ErrorRate, a fraction in
+	// (0, 1], of the calls made in the last Window fail, provided at least
+	// MinCalls calls were made. Window defaults to DefaultBreakerWindow, and
+	// MinCalls to DefaultBreakerMinCalls.
+	ErrorRate float64       `toml:"error_rate"`
+	Window    time.Duration `toml:"window"`
+	MinCalls  int           `toml:"min_calls"`
+
+	// How long calls fail fast once the breaker trips. Defaults to
+	// DefaultBreakerCooldown.
+	Cooldown time.Duration `toml:"cooldown"`
+}
+
+// AdmissionConfig holds the admission control policy of the remote calls to
+// a component. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.admission."github.com/my/project/package/ComponentName"]
+//	max_concurrent_calls = 100
+//	max_queued_calls = 1000
+//	rate = 500
+//	target_queue_delay = "20ms"
+//
+// Every weavelet hosting the component enforces the policy on its own. Calls
+// that are not admitted are shed: they fail with weaver.ErrShedLoad without
+// being run.
+type AdmissionConfig struct {
+	// If positive, at most MaxConcurrentCalls calls to the component run at a
+	// time, and at most MaxQueuedCalls additional calls wait, in arrival
+	// order, for a running call to finish. Other calls are shed.
+	MaxConcurrentCalls int `toml:"max_concurrent_calls"`
+	MaxQueuedCalls     int `toml:"max_queued_calls"`
+
+	// If positive, at most Rate calls per second to the component are
+	// admitted, in bursts of at most Burst calls, which defaults to Rate
+	// rounded up. Other calls are shed.
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+
+	// If positive, queued calls are shed adaptively. The queue is considered
+	// overloaded once it hasn't been empty for QueueDelayInterval, which
+	// defaults to DefaultQueueDelayInterval. A call that waits in the queue
+	// for QueueDelayInterval, or for TargetQueueDelay while the queue is
+	// overloaded, is shed. Under sustained overload, calls thus either run
+	// promptly or fail fast, rather than waiting until they time out.
+	TargetQueueDelay   time.Duration `toml:"target_queue_delay"`
+	QueueDelayInterval time.Duration `toml:"queue_delay_interval"`
+}
+
+// AccessConfig holds the authorization policy of the calls made by a
+// component. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.access."github.com/my/project/plugins/Recommender".allow]
+//	"github.com/my/project/catalogservice/T" = ["ListProducts", "GetProduct"]
+//	"github.com/my/project/currencyservice/T" = ["*"]
+//
+// A component with a policy may only call the listed methods of the listed
+// components, where "*" stands for every method of a component. Its other
+// remote calls are rejected by the weavelet hosting the called component:
+// they fail with weaver.ErrPermissionDenied without being run, and are logged
+// by the weavelet. Calls between components hosted in the same process are not
+// checked.
+type AccessConfig struct {
+	// The methods the component may call, keyed by the full name of the
+	// called component.
+	Allow map[string][]string `toml:"allow"`
+}
+
+// validate validates the AccessConfig.
+func (a AccessConfig) validate() error {
+	for component, methods := range a.Allow {
+		for _, method := range methods {
+			if method == "" {
+				return fmt.Errorf("allow %q: empty method name", component)
+			}
+		}
+	}
+	return nil
+}
+
+// StartupConfig holds the startup policy of a component. It is specified in
+// the config in a section of the form:
+//
+//	[serviceweaver.startup."github.com/my/project/package/ComponentName"]
+//	lazy = true
+//	order = 1
+//	after = ["github.com/my/project/package/OtherComponent"]
+type StartupConfig struct {
+	// By default, a component is started, and its Init method run, when it
+	// is first fetched, e.g., when the component that references it is
+	// constructed, and the fetching component waits until it is ready. A
+	// lazy component is instead started when it is first called. The first
+	// call waits until the component is ready. Calls to a lazy component
+	// hosted in the same process as its caller are encoded as if the
+	// component were remote. The main component cannot be lazy.
+	Lazy bool `toml:"lazy"`
+
+	// Every process initializes the components it hosts in increasing
+	// order of Order, which defaults to 0. Components with the same order
+	// are initialized in an unspecified order.
+	Order int `toml:"order"`
+
+	// The full names of the components that must be ready, in some process
+	// of the deployment, before the component is initialized. A component
+	// is ready once its Init method, and its Warmup method if any, return
+	// successfully.
+	After []string `toml:"after"`
+}
+
+// validateStartupOrder checks that the components don't wait for each other
+// to be ready, directly or transitively, before they are initialized.
+func validateStartupOrder(startup map[string]StartupConfig) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("startup %q: components wait for each other: %s", name, strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range startup[name].After {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	names := make([]string, 0, len(startup))
+	for name := range startup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreConfig configures the backend of a store created with weaver.NewStore.
+// It is specified in the config in a section of the form:
+//
+//	[serviceweaver.stores.carts]
+//	backend = "file"
+//	path = "/var/lib/boutique/carts.db"
+//
+// The "memory" backend, the default, keeps the store in the memory of every
+// process, so that every process has its own store, which is lost when the
+// process exits. It suits tests and single process deployments. The "file"
+// backend keeps the store in the SQLite database at Path, which may be
+// shared by the processes on one machine and by several stores. The "redis"
+// backend keeps the store in the Redis server at Address, which may be shared
+// by all the processes of a deployment.
+type StoreConfig struct {
+	// The backend: "memory", "file", or "redis". Empty means "memory".
+	Backend string `toml:"backend"`
+
+	// The path of the database file of the "file" backend.
+	Path string `toml:"path"`
+
+	// The address, of the form "host:port", of the server of the "redis"
+	// backend, and optionally the password and database number used.
+	Address  string `toml:"address"`
+	Password string `toml:"password"`
+	DB       int    `toml:"db"`
+}
+
+// ExternalConfig holds the connection settings of an external service, like
+// a database or an API outside the application, whose clients are injected
+// into components by weaver.External fields. It is specified in the config in
+// a section of the form:
+//
+//	[serviceweaver.external.orders_db]
+//	kind = "sql"
+//	driver = "postgres"
+//	dsn = "postgres://orders@db.internal/orders"
+//	max_connections = 20
+//
+// Kind selects the driver that opens the clients of the service. The "sql"
+// kind opens a *sql.DB with the database/sql driver named Driver, which the
+// application must import; other kinds are registered with
+// weaver.RegisterExternal.
+type ExternalConfig struct {
+	// The kind of the service, e.g., "sql".
+	Kind string `toml:"kind"`
+
+	// The address of the service, e.g., "host:port" or a URL, for kinds
+	// other than "sql".
+	Address string `toml:"address"`
+
+	// The name of the database/sql driver, and the data source name passed
+	// to it, of the "sql" kind.
+	Driver string `toml:"driver"`
+	DSN    string `toml:"dsn"`
+
+	// If positive, the client of every process keeps at most MaxConnections
+	// open connections, and at most MaxIdleConnections idle ones, and closes
+	// connections once they are ConnectionLifetime old.
+	MaxConnections     int           `toml:"max_connections"`
+	MaxIdleConnections int           `toml:"max_idle_connections"`
+	ConnectionLifetime time.Duration `toml:"connection_lifetime"`
+
+	// Kind-specific settings.
+	Options map[string]string `toml:"options"`
+}
+
+// MetricsConfig configures the export of metrics to a metrics backend, like
+// StatsD or CloudWatch. It is specified in the config in a section of the
+// form:
+//
+//	[serviceweaver.metrics.cloudwatch]
+//	kind = "cloudwatch"
+//	interval = "30s"
+//	options = {namespace = "Boutique", region = "us-west-2"}
+//
+// Kind selects the exporter. The "statsd" kind sends the metrics to the StatsD
+// server at Address over UDP; other kinds are registered with
+// weaver.RegisterMetricExporter.
+type MetricsConfig struct {
+	// The kind of the exporter, e.g., "statsd". Empty means the name of the
+	// section.
+	Kind string `toml:"kind"`
+
+	// The address of the backend, e.g., "host:port" or a URL.
+	Address string `toml:"address"`
+
+	// How often metrics are exported. Zero means
+	// DefaultMetricsExportInterval.
+	Interval time.Duration `toml:"interval"`
 
-	// appConfig holds the data from under appKey in the TOML config.
-	// It matches the contents of the Config proto.
-	type appConfig struct {
-		Name     string
-		Binary   string
-		Args     []string
-		Env      []string
-		Colocate [][]string
-		Rollout  time.Duration
+	// Kind-specific settings.
+	Options map[string]string `toml:"options"`
+}
+
+// DefaultMetricsExportInterval is the default value of
+// MetricsConfig.Interval.
+const DefaultMetricsExportInterval = time.Minute
+
+// KindOrName returns the kind of the exporter configured in the section with
+// the provided name.
+func (m MetricsConfig) KindOrName(name string) string {
+	if m.Kind != "" {
+		return m.Kind
 	}
+	return name
+}
+
+// FederationConfig configures the federation gateway through which a
+// deployment exports some of its components to other deployments, e.g., the
+// deployments of other teams. It is specified in the config in sections of
+// the form:
+//
+//	[serviceweaver.federation]
+//	address = ":9000"
+//	cert_file = "/etc/orders/gateway.pem"
+//	key_file = "/etc/orders/gateway.key"
+//	client_ca_file = "/etc/orders/partners-ca.pem"
+//
+//	[serviceweaver.federation.exports."github.com/orders/Orders"]
+//	clients = ["payments"]
+//
+// Other deployments connect to the gateway with mutual TLS: the gateway
+// presents the certificate in CertFile, and only accepts clients with a
+// certificate signed by a certificate authority in ClientCAFile.
+type FederationConfig struct {
+	// The "host:port" address the gateway listens on.
+	Address string `toml:"address"`
+
+	// The PEM encoded certificate and key files of the gateway. The files
+	// are reloaded when they change.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+
+	// The PEM encoded certificates of the authorities that sign the
+	// certificates of the clients.
+	ClientCAFile string `toml:"client_ca_file"`
+
+	// The exported components, keyed by full component name.
+	Exports map[string]ExportConfig `toml:"exports"`
+}
+
+// ExportConfig holds the policy of a component exported to other deployments.
+// See FederationConfig.
+type ExportConfig struct {
+	// The names of the clients allowed to call the component, matched
+	// against the common name and the DNS names of their certificates.
+	// Empty means every client of the gateway.
+	Clients []string `toml:"clients"`
+}
+
+// ImportConfig holds the connection settings of a component of another
+// deployment, exported by its federation gateway (see FederationConfig). It
+// is specified in the config in a section of the form:
+//
+//	[serviceweaver.imports."github.com/orders/Orders"]
+//	address = "orders.example.com:9000"
+//	ca_file = "/etc/payments/orders-ca.pem"
+//	cert_file = "/etc/payments/client.pem"
+//	key_file = "/etc/payments/client.key"
+//
+// The importing binary links the package of the component, for its
+// interface and generated client stub, but never starts the component.
+type ImportConfig struct {
+	// The "host:port" address of the federation gateway.
+	Address string `toml:"address"`
+
+	// The PEM encoded certificates of the authorities that sign the
+	// certificate of the gateway.
+	CAFile string `toml:"ca_file"`
+
+	// The PEM encoded certificate and key files the client presents to the
+	// gateway.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+
+	// The name expected in the certificate of the gateway. Empty means the
+	// host of Address.
+	ServerName string `toml:"server_name"`
+}
+
+// OTLPConfig configures the export of traces and metrics using the
+// OpenTelemetry protocol (OTLP), e.g., to an OpenTelemetry Collector. It is
+// specified in the config in a section of the form:
+//
+//	[serviceweaver.otlp]
+//	endpoint = "localhost:4317"
+//	insecure = true
+//	headers = {"x-api-key" = "secret"}
+//	sampling_rate = 0.1
+type OTLPConfig struct {
+	// The transport: "grpc" or "http". Empty means "grpc".
+	Protocol string `toml:"protocol"`
+
+	// The address, of the form "host:port", of the collector or backend
+	// that receives the traces and metrics. Empty disables the export.
+	Endpoint string `toml:"endpoint"`
+
+	// If true, the traces and metrics are sent without TLS.
+	Insecure bool `toml:"insecure"`
+
+	// Headers sent with every export, e.g., to authenticate with a backend.
+	Headers map[string]string `toml:"headers"`
+
+	// The fraction of traces that are sampled, in (0, 1]. Zero means 1. The
+	// rate applies to the traces sent to the deployer as well, so that the
+	// two agree on which traces exist. It is a shorthand for a
+	// "parent_based" TracingConfig with the same ratio, and can't be used
+	// along with a TracingConfig.
+	SamplingRate float64 `toml:"sampling_rate"`
+
+	// How often metrics are exported. Zero means
+	// DefaultOTLPMetricsInterval.
+	MetricsInterval time.Duration `toml:"metrics_interval"`
+}
+
+// DefaultOTLPMetricsInterval is the default value of
+// OTLPConfig.MetricsInterval.
+const DefaultOTLPMetricsInterval = time.Minute
+
+// TracingConfig configures the sampling of traces. It is specified in the
+// config in a section of the form:
+//
+//	[serviceweaver.tracing]
+//	sampler = "rate_limited"
+//	rate = 10
+//
+//	[serviceweaver.tracing.components."github.com/my/project/cart/T"]
+//	sampler = "ratio"
+//	ratio = 0.01
+//
+// It also holds the attributes of the OpenTelemetry resource of every
+// process:
+//
+//	[serviceweaver.tracing]
+//	resource = {"service.version" = "1.2.0", team = "payments"}
+type TracingConfig struct {
+	// The sampler of the application's spans, except for the spans of calls
+	// to the components in Components.
+	SamplingConfig
+
+	// Per-component samplers, keyed by full component name. The sampler of a
+	// component decides whether the spans of calls to the component's
+	// methods are sampled. Spans in the same trace may be sampled by
+	// different samplers, so a component with a sampler that ignores the
+	// parent span, like "ratio", can drop the spans of a sampled trace, or
+	// sample the spans of a dropped one.
+	Components map[string]SamplingConfig `toml:"components"`
+
+	// Attributes added to the OpenTelemetry resource of every process, in
+	// addition to the ones registered with weaver.AddResourceAttributes.
+	// They label every span and every metric exported over OTLP.
+	Resource map[string]string `toml:"resource"`
+}
+
+// SamplingConfig configures a trace sampler.
+type SamplingConfig struct {
+	// The sampler. Empty means "parent_based".
+	//
+	//   - "always" samples every span.
+	//   - "never" samples no span.
+	//   - "ratio" samples a Ratio of traces, picked by trace id, regardless
+	//     of whether the parent span is sampled.
+	//   - "parent_based" samples a span if its parent span is sampled, and a
+	//     Ratio of new traces.
+	//   - "rate_limited" samples a span if its parent span is sampled, and at
+	//     most Rate new traces per second in every process.
+	Sampler string `toml:"sampler"`
+
+	// The fraction of traces sampled by "ratio" and "parent_based"
+	// samplers, in (0, 1]. Zero means 1.
+	Ratio float64 `toml:"ratio"`
+
+	// The number of new traces sampled per second, in every process, by
+	// "rate_limited" samplers. Required by "rate_limited" samplers.
+	Rate float64 `toml:"rate"`
+}
+
+// ChaosConfig holds the schedule of the faults injected into a deployment
+// for chaos testing. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.chaos]
+//	interval = "1m"
+//	duration = "20s"
+//	faults = ["kill", "partition"]
+//	latency = "250ms"
+//	seed = 42
+//
+// A "kill" fault kills a random weavelet, which the deployer replaces. A
+// "partition" fault makes the components of two random co-location groups
+// unreachable from each other. A "latency" fault delays every call to the
+// components of a random co-location group by Latency. Partitions and
+// latency last for Duration.
+type ChaosConfig struct {
+	// The mean time between two faults. Zero means DefaultChaosInterval.
+	Interval time.Duration `toml:"interval"`
+
+	// How long partitions and latency last. Zero means DefaultChaosDuration.
+	Duration time.Duration `toml:"duration"`
+
+	// The kinds of faults injected: "kill", "partition", or "latency".
+	// Empty means all of them.
+	Faults []string `toml:"faults"`
+
+	// The latency added to calls by a "latency" fault. Zero means
+	// DefaultChaosLatency.
+	Latency time.Duration `toml:"latency"`
+
+	// If not zero, the seed of the random schedule, which makes the
+	// schedule reproducible.
+	Seed int64 `toml:"seed"`
+}
+
+const (
+	// DefaultChaosInterval is the default value of ChaosConfig.Interval.
+	DefaultChaosInterval = 30 * time.Second
+
+	// DefaultChaosDuration is the default value of ChaosConfig.Duration.
+	DefaultChaosDuration = 10 * time.Second
+
+	// DefaultChaosLatency is the default value of ChaosConfig.Latency.
+	DefaultChaosLatency = 100 * time.Millisecond
+)
+
+// AlertsConfig configures the alerts sent by deployers that record the
+// events of deployments, like "weaver multi deploy". It is specified in the
+// config in sections of the form:
+//
+//	[serviceweaver.alerts]
+//	on = ["crash_loop", "rollout_stalled"]
+//	crash_loop_restarts = 5
+//	crash_loop_window = "5m"
+//	rollout_stall_timeout = "1h"
+//
+//	[serviceweaver.alerts.slack]
+//	url = "https://hooks.slack.com/services/${secret:env:SLACK_WEBHOOK}"
+//
+//	[serviceweaver.alerts.pagerduty]
+//	routing_key = "${secret:env:PAGERDUTY_ROUTING_KEY}"
+//
+// A "crash_loop" alert fires when the replicas of a co-location group crash,
+// or fail to start, CrashLoopRestarts times within CrashLoopWindow. An
+// "unhealthy" alert fires when a replica fails its health checks, and
+// resolves once it passes them again. A "rollout_stalled" alert fires when
+// the traffic of a rollout hasn't changed for RolloutStallTimeout, and
+// resolves once it changes, or the rollout is done. No alerts are sent unless
+// a destination is configured.
+type AlertsConfig struct {
+	// The alerts sent: "crash_loop", "unhealthy", or "rollout_stalled".
+	// Empty means all of them.
+	On []string `toml:"on"`
+
+	// The number of crashes, within CrashLoopWindow, that make a crash
+	// loop. Zero means DefaultCrashLoopRestarts.
+	CrashLoopRestarts int `toml:"crash_loop_restarts"`
+
+	// Zero means DefaultCrashLoopWindow.
+	CrashLoopWindow time.Duration `toml:"crash_loop_window"`
+
+	// Zero means DefaultRolloutStallTimeout.
+	RolloutStallTimeout time.Duration `toml:"rollout_stall_timeout"`
+
+	// The destinations of the alerts.
+	Slack     *SlackAlertConfig     `toml:"slack"`
+	PagerDuty *PagerDutyAlertConfig `toml:"pagerduty"`
+	Webhook   *WebhookAlertConfig   `toml:"webhook"`
+}
 
+// SlackAlertConfig configures the posting of alerts to a Slack channel,
+// through an incoming webhook.
+type SlackAlertConfig struct {
+	// The URL of the incoming webhook.
+	URL string `toml:"url"`
+}
+
+// PagerDutyAlertConfig configures the sending of alerts to PagerDuty, with
+// the Events API v2. A firing alert triggers an incident, and resolving the
+// alert resolves the incident.
+type PagerDutyAlertConfig struct {
+	// The integration key of the PagerDuty service.
+	RoutingKey string `toml:"routing_key"`
+
+	// The URL of the Events API. Empty means DefaultPagerDutyURL.
+	URL string `toml:"url"`
+}
+
+// WebhookAlertConfig configures the posting of alerts, JSON encoded, to an
+// HTTP endpoint.
+type WebhookAlertConfig struct {
+	// The URL the alerts are posted to.
+	URL string `toml:"url"`
+
+	// Headers sent with every alert, e.g., to authenticate.
+	Headers map[string]string `toml:"headers"`
+}
+
+const (
+	// DefaultCrashLoopRestarts is the default value of
+	// AlertsConfig.CrashLoopRestarts.
+	DefaultCrashLoopRestarts = 3
+
+	// DefaultCrashLoopWindow is the default value of
+	// AlertsConfig.CrashLoopWindow.
+	DefaultCrashLoopWindow = 10 * time.Minute
+
+	// DefaultRolloutStallTimeout is the default value of
+	// AlertsConfig.RolloutStallTimeout.
+	DefaultRolloutStallTimeout = 30 * time.Minute
+
+	// DefaultPagerDutyURL is the default value of PagerDutyAlertConfig.URL.
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// RecordingConfig configures the recording of the remote calls to
+// components. It is specified in the config in a section of the form:
+//
+//	[serviceweaver.recording]
+//	dir = "/var/log/boutique/calls"
+//	components = ["github.com/my/project/cart/T"]
+//
+// Every weavelet appends the calls it serves to a file in Dir named after
+// the weavelet's id, one JSON object per line, holding the component and
+// method called, the serialized arguments and results, and the call's start
+// time and duration. Calls to streaming methods and calls between colocated
+// components, which are regular function calls, are not recorded.
+type RecordingConfig struct {
+	// The directory the calls are recorded in. Empty disables recording.
+	Dir string `toml:"dir"`
+
+	// The full names of the components whose calls are recorded. Empty
+	// means all components.
+	Components []string `toml:"components"`
+}
+
+// LoggingConfig configures the export of log entries to logging backends. It
+// is specified in the config in sections of the form:
+//
+//	[serviceweaver.logging.loki]
+//	url = "http://loki:3100/loki/api/v1/push"
+//	labels = {env = "prod"}
+//
+//	[serviceweaver.logging.cloud_logging]
+//	project = "my-project"
+//
+//	[serviceweaver.logging.syslog]
+//	network = "udp"
+//	address = "logs.example.com:514"
+//
+//	[serviceweaver.logging.file]
+//	dir = "/var/log/boutique"
+//
+// Every section enables an exporter, and every weavelet exports the log
+// entries of its components, with their attributes, to all the enabled
+// exporters. Log entries are exported in batches, in the background, and are
+// dropped if an exporter falls behind.
+type LoggingConfig struct {
+	Loki         *LokiConfig         `toml:"loki"`
+	CloudLogging *CloudLoggingConfig `toml:"cloud_logging"`
+	Syslog       *SyslogConfig       `toml:"syslog"`
+	File         *LogFileConfig      `toml:"file"`
+}
+
+// LokiConfig configures the export of log entries to Grafana Loki. Every log
+// entry is pushed as a JSON object holding its message, attributes, and
+// metadata, in a stream labeled with its application, component, and level.
+type LokiConfig struct {
+	// The URL of Loki's push API, e.g.,
+	// "http://loki:3100/loki/api/v1/push".
+	URL string `toml:"url"`
+
+	// Headers sent with every push, e.g., to authenticate with Loki, or to
+	// pick a tenant with X-Scope-OrgID.
+	Headers map[string]string `toml:"headers"`
+
+	// Labels added to every stream.
+	Labels map[string]string `toml:"labels"`
+}
+
+// CloudLoggingConfig configures the export of log entries to Google Cloud
+// Logging, using the credentials of the default service account of the
+// machine, which are fetched from the metadata server. Every log entry is
+// written with its attributes in its JSON payload, and its metadata in its
+// labels.
+type CloudLoggingConfig struct {
+	// The id of the Google Cloud project the log entries are written to.
+	Project string `toml:"project"`
+
+	// The name of the log the entries are written to. Empty means
+	// "serviceweaver".
+	LogName string `toml:"log_name"`
+}
+
+// SyslogConfig configures the export of log entries to a syslog daemon. Every
+// log entry is sent with a priority matching its level, and its attributes
+// appended to its message as key=value pairs.
+type SyslogConfig struct {
+	// The network and address of the daemon, e.g., "udp" and
+	// "logs.example.com:514". An empty network means the local daemon.
+	Network string `toml:"network"`
+	Address string `toml:"address"`
+
+	// The tag of the log entries. Empty means the application name.
+	Tag string `toml:"tag"`
+}
+
+// LogFileConfig configures the export of log entries to files. Every
+// weavelet appends its log entries to a file in Dir named after the
+// weavelet's id, one JSON object per line. Once the file reaches MaxBytes, it
+// is rotated: it is renamed with a ".1" suffix, the ".1" file is renamed with
+// a ".2" suffix, and so on, keeping at most MaxFiles rotated files.
+type LogFileConfig struct {
+	// The directory of the files.
+	Dir string `toml:"dir"`
+
+	// The size, in bytes, at which a file is rotated. Zero means
+	// DefaultLogFileMaxBytes.
+	MaxBytes int64 `toml:"max_bytes"`
+
+	// The number of rotated files kept. Zero means DefaultLogFileMaxFiles.
+	MaxFiles int `toml:"max_files"`
+}
+
+const (
+	// DefaultLogFileMaxBytes is the default value of LogFileConfig.MaxBytes.
+	DefaultLogFileMaxBytes = 100 << 20
+
+	// DefaultLogFileMaxFiles is the default value of LogFileConfig.MaxFiles.
+	DefaultLogFileMaxFiles = 5
+)
+
+// ProfilingConfig configures the continuous profiling of weavelets. It is
+// specified in the config in sections of the form:
+//
+//	[serviceweaver.profiling]
+//	interval = "1m"
+//	cpu_duration = "10s"
+//	types = ["cpu", "heap"]
+//
+//	[serviceweaver.profiling.pyroscope]
+//	url = "http://pyroscope:4040"
+//
+//	[serviceweaver.profiling.cloud_profiler]
+//	project = "my-project"
+//
+// Every weavelet collects a profile of every type once per Interval, and
+// sends it to its deployer, which keeps the recent profiles of every
+// component for the dashboard, and to the enabled backends. A "cpu" profile
+// samples the process for CPUDuration; a "heap" profile is a snapshot of the
+// sampled heap allocations; a "mutex" profile samples the mutex contention
+// of the process for CPUDuration. The sampling overhead of the CPU profiler
+// is a few percent of the CPU usage of the process while it runs, so the
+// overhead over time is a fraction CPUDuration/Interval of that.
+type ProfilingConfig struct {
+	// How often every weavelet is profiled. Zero disables continuous
+	// profiling.
+	Interval time.Duration `toml:"interval"`
+
+	// The duration of "cpu" and "mutex" profiles. Zero means
+	// DefaultProfilingCPUDuration.
+	CPUDuration time.Duration `toml:"cpu_duration"`
+
+	// The types of profiles collected: "cpu", "heap", or "mutex". Empty
+	// means "cpu" and "heap".
+	Types []string `toml:"types"`
+
+	Pyroscope     *PyroscopeConfig     `toml:"pyroscope"`
+	CloudProfiler *CloudProfilerConfig `toml:"cloud_profiler"`
+}
+
+// PyroscopeConfig configures the export of profiles to Grafana Pyroscope.
+// Every profile is ingested under the application name, labeled with its
+// deployment and weavelet.
+type PyroscopeConfig struct {
+	// The URL of the Pyroscope server, e.g., "http://pyroscope:4040".
+	URL string `toml:"url"`
+
+	// Headers sent with every profile, e.g., to authenticate with
+	// Pyroscope, or to pick a tenant with X-Scope-OrgID.
+	Headers map[string]string `toml:"headers"`
+
+	// Labels added to every profile.
+	Labels map[string]string `toml:"labels"`
+}
+
+// CloudProfilerConfig configures the export of profiles to Google Cloud
+// Profiler, using the credentials of the default service account of the
+// machine, which are fetched from the metadata server. Every profile is
+// uploaded with the deployment id as its version.
+type CloudProfilerConfig struct {
+	// The id of the Google Cloud project the profiles are uploaded to.
+	Project string `toml:"project"`
+
+	// The service name of the profiles. Empty means the application name.
+	Service string `toml:"service"`
+}
+
+// DefaultProfilingCPUDuration is the default value of
+// ProfilingConfig.CPUDuration.
+const DefaultProfilingCPUDuration = 10 * time.Second
+
+// RetentionConfig configures how long deployers keep the logs and traces of
+// the deployments of an application. It is specified in the config in
+// sections of the form:
+//
+//	[serviceweaver.retention]
+//	interval = "10m"
+//
+//	[serviceweaver.retention.logs]
+//	max_age = "168h"
+//	max_bytes = 1073741824
+//
+//	[serviceweaver.retention.traces]
+//	max_age = "72h"
+//
+// Every Interval, which defaults to DefaultRetentionInterval, deployers that
+// store data on the local machine, like the single process, multiprocess,
+// and SSH deployers, delete the logs and traces of the deployments of the
+// application that the policies expire. Running deployments are never
+// expired. Deployers serve the metrics of running deployments, but don't
+// store them, so there are no metrics to expire.
+type RetentionConfig struct {
+	// How often expired data is deleted.
+	Interval time.Duration `toml:"interval"`
+
+	// The retention policy of the log files, and of the database that
+	// indexes them.
+	Logs RetentionPolicy `toml:"logs"`
+
+	// The retention policy of the traces.
+	Traces RetentionPolicy `toml:"traces"`
+}
+
+// RetentionPolicy is the retention policy of one kind of data. The data of
+// a deployment expires once it hasn't been written to for MaxAge. In
+// addition, if the data of all the deployments of the application takes more
+// than MaxBytes, the data of the least recently written deployments expires
+// until the rest takes at most MaxBytes. Zero means no limit.
+type RetentionPolicy struct {
+	MaxAge   time.Duration `toml:"max_age"`
+	MaxBytes int64         `toml:"max_bytes"`
+}
+
+// Enabled returns whether the policy expires any data.
+func (r RetentionPolicy) Enabled() bool {
+	return r.MaxAge > 0 || r.MaxBytes > 0
+}
+
+// Enabled returns whether any of the policies expires any data.
+func (r RetentionConfig) Enabled() bool {
+	return r.Logs.Enabled() || r.Traces.Enabled()
+}
+
+// DefaultRetentionInterval is the default value of RetentionConfig.Interval.
+const DefaultRetentionInterval = 10 * time.Minute
+
+// AuthConfig configures the authentication and authorization of the HTTP
+// requests received by a listener. It is specified in the config in a
+// section of the form:
+//
+//	[serviceweaver.auth.frontend]
+//	issuer = "https://accounts.google.com"
+//	audience = "my-client-id"
+//	api_keys_file = "/etc/boutique/api_keys"
+//	routes = [
+//	  {path = "/static/", public = true},
+//	  {path = "/admin/", claims = {role = "admin"}},
+//	  {path = "/orders/", methods = ["POST"], principals = ["billing"]},
+//	]
+//
+// A request is authenticated either with a JSON Web Token (JWT), e.g., an
+// OpenID Connect ID token, sent as a bearer token in the Authorization
+// header, or with an API key sent in the APIKeyHeader header. At least one
+// of the two must be configured.
+type AuthConfig struct {
+	// If Issuer or JWKSURL is not empty, requests may be authenticated with
+	// a JWT signed by one of the keys published at JWKSURL, which defaults
+	// to the "jwks_uri" of the OpenID Connect discovery document of Issuer.
+	// If not empty, Issuer and Audience must match the "iss" and "aud"
+	// claims of the token.
+	Issuer   string `toml:"issuer"`
+	Audience string `toml:"audience"`
+	JWKSURL  string `toml:"jwks_url"`
+
+	// If APIKeysFile is not empty, requests may be authenticated with one of
+	// the API keys listed in the file, sent in the APIKeyHeader header,
+	// which defaults to DefaultAPIKeyHeader. Every line of the file holds
+	// the name of a principal and its API key, separated by whitespace.
+	// Empty lines and lines starting with '#' are ignored.
+	APIKeysFile  string `toml:"api_keys_file"`
+	APIKeyHeader string `toml:"api_key_header"`
+
+	// The authorization policies of routes. A request is subject to the
+	// policy of the route with the longest path that is a prefix of the
+	// request's path and that lists the request's method, if any. Requests
+	// that match no route only need to be authenticated.
+	Routes []AuthRoute `toml:"routes"`
+}
+
+// AuthRoute holds the authorization policy of the requests to a route. See
+// AuthConfig.
+type AuthRoute struct {
+	// The prefix of the paths of the route's requests, e.g., "/admin/".
+	Path string `toml:"path"`
+
+	// The HTTP methods of the route's requests. Empty means all methods.
+	Methods []string `toml:"methods"`
+
+	// If true, the route's requests are not authenticated.
+	Public bool `toml:"public"`
+
+	// If not empty, only the listed principals may send the route's
+	// requests. A principal authenticated with a JWT is named after the
+	// token's "sub" claim.
+	Principals []string `toml:"principals"`
+
+	// Claims that the JWT of the route's requests must have. A claim
+	// matches if it is equal to the given string or is a list that contains
+	// it. Requests authenticated with an API key have no claims.
+	Claims map[string]string `toml:"claims"`
+}
+
+// DefaultAPIKeyHeader is the default value of AuthConfig.APIKeyHeader.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// ListenerConfig configures the connections accepted by a listener. It is
+// specified in the config in a section of the form:
+//
+//	[serviceweaver.listeners.frontend]
+//	max_connections = 1000
+//	idle_timeout = "2m"
+//	session_affinity = "cookie"
+//
+// When a weavelet drains, its listeners stop accepting connections once the
+// drain grace period is over, and the weavelet waits for the open
+// connections to be closed, e.g., by http.Server.Shutdown called from a
+// weaver.OnDrain hook, within the max drain time. The connections still
+// open after the max drain time are closed. See WeaveletConfig.
+type ListenerConfig struct {
+	// If positive, the listener has at most MaxConnections open
+	// connections. Further connections wait to be accepted until an open
+	// connection is closed.
+	MaxConnections int `toml:"max_connections"`
+
+	// If positive, a connection on which no data is read or written for
+	// IdleTimeout is closed, e.g., an HTTP keep-alive connection that sends
+	// no new request. Note that a request whose handler neither reads nor
+	// writes for IdleTimeout is cut short.
+	IdleTimeout time.Duration `toml:"idle_timeout"`
+
+	// If "cookie" or "header", the deployer's proxy of the listener forwards
+	// the requests of a session to the same replica of the listener, as long
+	// as the replica receives traffic, so that the replica can keep
+	// per-session state in memory. Sessions are identified by the value of
+	// the SessionKey cookie or header. With cookie affinity, the proxy sets
+	// the cookie on requests that don't have it, and on their responses.
+	// Empty means that requests are spread across replicas regardless of
+	// their session.
+	SessionAffinity string `toml:"session_affinity"`
+
+	// The name of the cookie or header that identifies sessions. Defaults to
+	// DefaultSessionCookie with cookie affinity, and is required with header
+	// affinity.
+	SessionKey string `toml:"session_key"`
+}
+
+// DefaultSessionCookie is the default value of ListenerConfig.SessionKey with
+// cookie session affinity.
+const DefaultSessionCookie = "serviceweaver_session"
+
+// MethodConfig holds the configuration of a single component method. It is
+// specified in the config in a section of the form:
+//
+//	[serviceweaver.methods."github.com/my/project/package/ComponentName.MethodName"]
+//	cache_ttl = "30s"
+type MethodConfig struct {
+	// The policy of calls to the method.
+	CallConfig
+
+	// If positive, successful results of remote calls to the method are cached
+	// by the caller for CacheTTL, keyed by the method arguments. Caching
+	// should only be enabled for idempotent methods.
+	CacheTTL time.Duration `toml:"cache_ttl"`
+
+	// If positive, results of remote calls to the method that return an
+	// application error are cached for NegativeCacheTTL.
+	NegativeCacheTTL time.Duration `toml:"negative_cache_ttl"`
+
+	// If positive, the caller caches the results of at most CacheMaxEntries
+	// calls to the method, evicting the least recently used results first.
+	// The results of all cached methods are also bounded in size by
+	// cache_max_bytes.
+	CacheMaxEntries int `toml:"cache_max_entries"`
+
+	// The default priority class of calls to the method: "low", "normal", or
+	// "high". Empty means "normal". The priority of an individual call can be
+	// overridden by the caller.
+	Priority string `toml:"priority"`
+
+	// If positive, remote calls to the method made with an idempotency key
+	// are deduplicated by the callee: a call with the same key as a call that
+	// is running, or that succeeded within the last IdempotencyWindow, is not
+	// run again, and returns the results of the earlier call instead.
+	IdempotencyWindow time.Duration `toml:"idempotency_window"`
+}
+
+// ParseWeaveletConfig returns the weavelet configuration specified in the
+// provided config sections.
+func ParseWeaveletConfig(sections map[string]string) (*WeaveletConfig, error) {
+	parsed := &appConfig{}
+	if err := ParseConfigSection(appKey, shortAppKey, sections, parsed); err != nil {
+		return nil, err
+	}
+	maxDrainTime := parsed.MaxDrainTime
+	if maxDrainTime == 0 {
+		maxDrainTime = DefaultMaxDrainTime
+	}
+	shutdownTimeout := parsed.ShutdownTimeout
+	if shutdownTimeout == 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	startupTimeout := parsed.StartupTimeout
+	if startupTimeout == 0 {
+		startupTimeout = DefaultStartupTimeout
+	}
+	healthCheckInterval := parsed.HealthCheckInterval
+	if healthCheckInterval == 0 {
+		healthCheckInterval = DefaultHealthCheckInterval
+	}
+	healthCheckTimeout := parsed.HealthCheckTimeout
+	if healthCheckTimeout == 0 {
+		healthCheckTimeout = DefaultHealthCheckTimeout
+	}
+	streamIdleTimeout := parsed.StreamIdleTimeout
+	if streamIdleTimeout == 0 {
+		streamIdleTimeout = DefaultStreamIdleTimeout
+	}
+	compressionThreshold := parsed.CompressionThreshold
+	if compressionThreshold == 0 {
+		compressionThreshold = DefaultCompressionThreshold
+	}
+	chaos := parsed.Chaos
+	if chaos.Interval == 0 {
+		chaos.Interval = DefaultChaosInterval
+	}
+	if chaos.Duration == 0 {
+		chaos.Duration = DefaultChaosDuration
+	}
+	if len(chaos.Faults) == 0 {
+		chaos.Faults = []string{"kill", "partition", "latency"}
+	}
+	if chaos.Latency == 0 {
+		chaos.Latency = DefaultChaosLatency
+	}
+	alerts := parsed.Alerts
+	if len(alerts.On) == 0 {
+		alerts.On = []string{"crash_loop", "unhealthy", "rollout_stalled"}
+	}
+	if alerts.CrashLoopRestarts == 0 {
+		alerts.CrashLoopRestarts = DefaultCrashLoopRestarts
+	}
+	if alerts.CrashLoopWindow == 0 {
+		alerts.CrashLoopWindow = DefaultCrashLoopWindow
+	}
+	if alerts.RolloutStallTimeout == 0 {
+		alerts.RolloutStallTimeout = DefaultRolloutStallTimeout
+	}
+	if alerts.PagerDuty != nil && alerts.PagerDuty.URL == "" {
+		pagerDuty := *alerts.PagerDuty
+		pagerDuty.URL = DefaultPagerDutyURL
+		alerts.PagerDuty = &pagerDuty
+	}
+	profiling := parsed.Profiling
+	if profiling.CPUDuration == 0 {
+		profiling.CPUDuration = DefaultProfilingCPUDuration
+	}
+	if len(profiling.Types) == 0 {
+		profiling.Types = []string{"cpu", "heap"}
+	}
+	retention := parsed.Retention
+	if retention.Interval == 0 {
+		retention.Interval = DefaultRetentionInterval
+	}
+	var autoscaling map[string]AutoscalingConfig
+	if len(parsed.Autoscaling) > 0 {
+		autoscaling = map[string]AutoscalingConfig{}
+		for name, a := range parsed.Autoscaling {
+			if a.MinReplicas == 0 {
+				a.MinReplicas = 1
+			}
+			if a.ScaleDownDelay == 0 {
+				a.ScaleDownDelay = DefaultScaleDownDelay
+			}
+			autoscaling[name] = a
+		}
+	}
+	var breakers map[string]BreakerConfig
+	if len(parsed.Breakers) > 0 {
+		breakers = map[string]BreakerConfig{}
+		for name, b := range parsed.Breakers {
+			if b.Window == 0 {
+				b.Window = DefaultBreakerWindow
+			}
+			if b.MinCalls == 0 {
+				b.MinCalls = DefaultBreakerMinCalls
+			}
+			if b.Cooldown == 0 {
+				b.Cooldown = DefaultBreakerCooldown
+			}
+			breakers[name] = b
+		}
+	}
+	var admission map[string]AdmissionConfig
+	if len(parsed.Admission) > 0 {
+		admission = map[string]AdmissionConfig{}
+		for name, a := range parsed.Admission {
+			if a.Rate > 0 && a.Burst == 0 {
+				a.Burst = int(math.Ceil(a.Rate))
+			}
+			if a.TargetQueueDelay > 0 && a.QueueDelayInterval == 0 {
+				a.QueueDelayInterval = DefaultQueueDelayInterval
+			}
+			admission[name] = a
+		}
+	}
+	var auth map[string]AuthConfig
+	if len(parsed.Auth) > 0 {
+		auth = map[string]AuthConfig{}
+		for name, a := range parsed.Auth {
+			if a.APIKeyHeader == "" {
+				a.APIKeyHeader = DefaultAPIKeyHeader
+			}
+			auth[name] = a
+		}
+	}
+	var listeners map[string]ListenerConfig
+	if len(parsed.Listeners) > 0 {
+		listeners = map[string]ListenerConfig{}
+		for name, l := range parsed.Listeners {
+			if l.SessionAffinity == "cookie" && l.SessionKey == "" {
+				l.SessionKey = DefaultSessionCookie
+			}
+			listeners[name] = l
+		}
+	}
+	return &WeaveletConfig{
+		CacheMaxBytes:          parsed.CacheMaxBytes,
+		MaxConcurrentCalls:     parsed.MaxConcurrentCalls,
+		MaxQueuedCalls:         parsed.MaxQueuedCalls,
+		MaxInFlightCalls:       parsed.MaxInFlightCalls,
+		MaxPendingCalls:        parsed.MaxPendingCalls,
+		DrainGracePeriod:       parsed.DrainGracePeriod,
+		MaxDrainTime:           maxDrainTime,
+		ShutdownTimeout:        shutdownTimeout,
+		LoadBalancing:          parsed.LoadBalancing,
+		Compression:            parsed.Compression,
+		CompressionThreshold:   compressionThreshold,
+		WarmPoolSize:           parsed.WarmPoolSize,
+		StartupTimeout:         startupTimeout,
+		HealthCheckInterval:    healthCheckInterval,
+		HealthCheckTimeout:     healthCheckTimeout,
+		StreamIdleTimeout:      streamIdleTimeout,
+		MetricsAddress:         parsed.MetricsAddress,
+		DeployerMetricsAddress: parsed.DeployerMetricsAddress,
+		EventsWebhook:          parsed.EventsWebhook,
+		Alerts:                 alerts,
+		MTLS:                   parsed.MTLS,
+		Transport:              parsed.Transport,
+		OTLP:                   parsed.OTLP,
+		Tracing:                parsed.Tracing,
+		Chaos:                  chaos,
+		Recording:              parsed.Recording,
+		Logging:                parsed.Logging,
+		Profiling:              profiling,
+		Retention:              retention,
+		Components:             parsed.Components,
+		Routing:                parsed.Routing,
+		Startup:                parsed.Startup,
+		Resources:              parsed.Resources,
+		Separate:               parsed.Separate,
+		Processes:              parsed.Process,
+		Autoscaling:            autoscaling,
+		Breakers:               breakers,
+		Admission:              admission,
+		Stores:                 parsed.Stores,
+		External:               parsed.External,
+		Metrics:                parsed.Metrics,
+		Federation:             parsed.Federation,
+		Imports:                parsed.Imports,
+		Auth:                   auth,
+		Listeners:              listeners,
+		Access:                 parsed.Access,
+		Priorities:             parsed.Priorities,
+		Codecs:                 parsed.Codecs,
+		Methods:                parsed.Methods,
+	}, nil
+}
+
+// GroupProcessConfigs returns the process configs of the provided weavelet
+// config, keyed by the name of the co-location group they apply to: the first
+// component of the group in app.Colocate, or the component itself if it isn't
+// co-located with other components.
+func GroupProcessConfigs(app *protos.AppConfig, config *WeaveletConfig) map[string]ProcessConfig {
+	groups := map[string]string{}
+	for _, group := range app.Colocate {
+		for _, c := range group.Components {
+			groups[c] = group.Components[0]
+		}
+	}
+	processes := map[string]ProcessConfig{}
+	for component, p := range config.Processes {
+		group, ok := groups[component]
+		if !ok {
+			group = component
+		}
+		processes[group] = p
+	}
+	return processes
+}
+
+// ApplyProcessConfig returns a copy of app with the provided process settings
+// applied: p.Env and p.Args are appended to the env and args of app, and
+// p.Dir, if not empty, replaces its working directory. app itself is not
+// modified.
+func ApplyProcessConfig(app *protos.AppConfig, p ProcessConfig) *protos.AppConfig {
+	applied := proto.Clone(app).(*protos.AppConfig)
+	applied.Env = append(applied.Env, p.Env...)
+	applied.Args = append(applied.Args, p.Args...)
+	if p.Dir != "" {
+		applied.Dir = p.Dir
+	}
+	return applied
+}
+
+// Validate validates the appConfig.
+func (a *appConfig) Validate() error {
+	if a.CacheMaxBytes < 0 {
+		return fmt.Errorf("negative cache_max_bytes %d", a.CacheMaxBytes)
+	}
+	if a.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("negative max_concurrent_calls %d", a.MaxConcurrentCalls)
+	}
+	if a.MaxQueuedCalls < 0 {
+		return fmt.Errorf("negative max_queued_calls %d", a.MaxQueuedCalls)
+	}
+	if a.MaxInFlightCalls < 0 {
+		return fmt.Errorf("negative max_in_flight_calls %d", a.MaxInFlightCalls)
+	}
+	if a.MaxPendingCalls < 0 {
+		return fmt.Errorf("negative max_pending_calls %d", a.MaxPendingCalls)
+	}
+	if a.MaxPendingCalls > 0 && a.MaxInFlightCalls == 0 {
+		return fmt.Errorf("max_pending_calls without max_in_flight_calls")
+	}
+	if a.DrainGracePeriod < 0 {
+		return fmt.Errorf("negative drain_grace_period %v", a.DrainGracePeriod)
+	}
+	if a.MaxDrainTime < 0 {
+		return fmt.Errorf("negative max_drain_time %v", a.MaxDrainTime)
+	}
+	if a.ShutdownTimeout < 0 {
+		return fmt.Errorf("negative shutdown_timeout %v", a.ShutdownTimeout)
+	}
+	if a.WarmPoolSize < 0 {
+		return fmt.Errorf("negative warm_pool_size %d", a.WarmPoolSize)
+	}
+	if a.StartupTimeout < 0 {
+		return fmt.Errorf("negative startup_timeout %v", a.StartupTimeout)
+	}
+	if a.HealthCheckInterval < 0 {
+		return fmt.Errorf("negative health_check_interval %v", a.HealthCheckInterval)
+	}
+	if a.HealthCheckTimeout < 0 {
+		return fmt.Errorf("negative health_check_timeout %v", a.HealthCheckTimeout)
+	}
+	if a.StreamIdleTimeout < 0 {
+		return fmt.Errorf("negative stream_idle_timeout %v", a.StreamIdleTimeout)
+	}
+	if a.MetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(a.MetricsAddress); err != nil {
+			return fmt.Errorf("invalid metrics_address %q: %w", a.MetricsAddress, err)
+		}
+	}
+	if a.DeployerMetricsAddress != "" {
+		if _, _, err := net.SplitHostPort(a.DeployerMetricsAddress); err != nil {
+			return fmt.Errorf("invalid deployer_metrics_address %q: %w", a.DeployerMetricsAddress, err)
+		}
+	}
+	if a.EventsWebhook != "" {
+		u, err := url.Parse(a.EventsWebhook)
+		if err != nil {
+			return fmt.Errorf("invalid events_webhook %q: %w", a.EventsWebhook, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid events_webhook %q: want an http or https URL", a.EventsWebhook)
+		}
+	}
+	if err := a.Alerts.validate(); err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	switch a.LoadBalancing {
+	case "", "round_robin", "least_outstanding", "latency_weighted":
+	default:
+		return fmt.Errorf("unknown load_balancing %q; want \"round_robin\", \"least_outstanding\", or \"latency_weighted\"", a.LoadBalancing)
+	}
+	switch a.Compression {
+	case "", "none", "snappy", "zstd":
+	default:
+		return fmt.Errorf("unknown compression %q; want \"none\", \"snappy\", or \"zstd\"", a.Compression)
+	}
+	if a.CompressionThreshold < 0 {
+		return fmt.Errorf("negative compression_threshold %d", a.CompressionThreshold)
+	}
+	switch a.Transport {
+	case "", "tcp", "quic":
+	default:
+		return fmt.Errorf("unknown transport %q; want \"tcp\" or \"quic\"", a.Transport)
+	}
+	if err := a.OTLP.validate(); err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	if err := a.Tracing.validate(); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	if a.OTLP.SamplingRate != 0 && a.Tracing.SamplingConfig != (SamplingConfig{}) {
+		return fmt.Errorf("otlp sampling_rate along with a tracing sampler")
+	}
+	if err := a.Chaos.validate(); err != nil {
+		return fmt.Errorf("chaos: %w", err)
+	}
+	if err := a.Recording.validate(); err != nil {
+		return fmt.Errorf("recording: %w", err)
+	}
+	if err := a.Logging.validate(); err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+	if err := a.Profiling.validate(); err != nil {
+		return fmt.Errorf("profiling: %w", err)
+	}
+	if err := a.Retention.validate(); err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+	for name, c := range a.Components {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("component %q: %w", name, err)
+		}
+	}
+	for name, r := range a.Routing {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("routing %q: %w", name, err)
+		}
+	}
+	if a.Startup["main"].Lazy {
+		return fmt.Errorf("startup %q: the main component cannot be lazy", "main")
+	}
+	if err := validateStartupOrder(a.Startup); err != nil {
+		return err
+	}
+	groups := map[string]string{}
+	for _, colocate := range a.Colocate {
+		for _, component := range colocate {
+			groups[component] = colocate[0]
+		}
+	}
+	for _, separate := range a.Separate {
+		if len(separate) < 2 {
+			return fmt.Errorf("separate %q: fewer than two components", separate)
+		}
+		seen := map[string]string{}
+		for _, component := range separate {
+			group, ok := groups[component]
+			if !ok {
+				group = component
+			}
+			if other, ok := seen[group]; ok {
+				return fmt.Errorf("separate %q and %q: components are co-located", other, component)
+			}
+			seen[group] = component
+		}
+	}
+	limited := map[string]string{}
+	for name, r := range a.Resources {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("resources %q: %w", name, err)
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = name
+		}
+		if other, ok := limited[group]; ok {
+			return fmt.Errorf("resources %q and %q: components are co-located", other, name)
+		}
+		limited[group] = name
+	}
+	autoscaled := map[string]string{}
+	for name, as := range a.Autoscaling {
+		if err := as.validate(); err != nil {
+			return fmt.Errorf("autoscaling %q: %w", name, err)
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = name
+		}
+		if other, ok := autoscaled[group]; ok {
+			return fmt.Errorf("autoscaling %q and %q: components are co-located", other, name)
+		}
+		autoscaled[group] = name
+	}
+	processes := map[string]string{}
+	for name, p := range a.Process {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("process %q: %w", name, err)
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = name
+		}
+		if other, ok := processes[group]; ok {
+			return fmt.Errorf("process %q and %q: components are co-located", other, name)
+		}
+		processes[group] = name
+	}
+	for name, b := range a.Breakers {
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("breaker %q: %w", name, err)
+		}
+	}
+	for name, adm := range a.Admission {
+		if err := adm.validate(); err != nil {
+			return fmt.Errorf("admission %q: %w", name, err)
+		}
+	}
+	for name, s := range a.Stores {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("store %q: %w", name, err)
+		}
+	}
+	for name, e := range a.External {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("external %q: %w", name, err)
+		}
+	}
+	for name, m := range a.Metrics {
+		if err := m.validate(name); err != nil {
+			return fmt.Errorf("metrics %q: %w", name, err)
+		}
+	}
+	if err := a.Federation.validate(); err != nil {
+		return fmt.Errorf("federation: %w", err)
+	}
+	for name, i := range a.Imports {
+		if name == "main" {
+			return fmt.Errorf("import %q: the main component cannot be imported", name)
+		}
+		if err := i.validate(); err != nil {
+			return fmt.Errorf("import %q: %w", name, err)
+		}
+	}
+	for name, auth := range a.Auth {
+		if err := auth.validate(); err != nil {
+			return fmt.Errorf("auth %q: %w", name, err)
+		}
+	}
+	for name, l := range a.Listeners {
+		if l.MaxConnections < 0 {
+			return fmt.Errorf("listener %q: negative max_connections %d", name, l.MaxConnections)
+		}
+		if l.IdleTimeout < 0 {
+			return fmt.Errorf("listener %q: negative idle_timeout %v", name, l.IdleTimeout)
+		}
+		switch l.SessionAffinity {
+		case "", "cookie":
+		case "header":
+			if l.SessionKey == "" {
+				return fmt.Errorf("listener %q: header session affinity without a session_key", name)
+			}
+		default:
+			return fmt.Errorf("listener %q: unknown session_affinity %q; want \"cookie\" or \"header\"", name, l.SessionAffinity)
+		}
+		if l.SessionKey != "" && l.SessionAffinity == "" {
+			return fmt.Errorf("listener %q: session_key without session_affinity", name)
+		}
+	}
+	for name, acc := range a.Access {
+		if err := acc.validate(); err != nil {
+			return fmt.Errorf("access %q: %w", name, err)
+		}
+	}
+	for name, p := range a.Priorities {
+		switch p {
+		case "low", "normal", "high":
+		default:
+			return fmt.Errorf("priorities %q: unknown priority %q; want \"low\", \"normal\", or \"high\"", name, p)
+		}
+	}
+	for name, codec := range a.Codecs {
+		if codec == "" {
+			return fmt.Errorf("codec %q: empty codec name", name)
+		}
+	}
+	for name, m := range a.Methods {
+		if m.CacheTTL < 0 || m.NegativeCacheTTL < 0 {
+			return fmt.Errorf("method %q: negative cache TTL", name)
+		}
+		if m.CacheMaxEntries < 0 {
+			return fmt.Errorf("method %q: negative cache_max_entries %d", name, m.CacheMaxEntries)
+		}
+		if m.IdempotencyWindow < 0 {
+			return fmt.Errorf("method %q: negative idempotency_window %v", name, m.IdempotencyWindow)
+		}
+		if err := m.CallConfig.validate(); err != nil {
+			return fmt.Errorf("method %q: %w", name, err)
+		}
+		switch m.Priority {
+		case "", "low", "normal", "high":
+		default:
+			return fmt.Errorf("method %q: unknown priority %q; want \"low\", \"normal\", or \"high\"", name, m.Priority)
+		}
+	}
+	return nil
+}
+
+// validate validates the CallConfig.
+func (c CallConfig) validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("negative timeout %v", c.Timeout)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("negative max_retries %d", c.MaxRetries)
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("negative retry_backoff %v", c.RetryBackoff)
+	}
+	if c.HedgeDelay < 0 {
+		return fmt.Errorf("negative hedge_delay %v", c.HedgeDelay)
+	}
+	if c.MaxRequestBytes < 0 {
+		return fmt.Errorf("negative max_request_bytes %d", c.MaxRequestBytes)
+	}
+	if c.MaxResponseBytes < 0 {
+		return fmt.Errorf("negative max_response_bytes %d", c.MaxResponseBytes)
+	}
+	return nil
+}
+
+// validate validates the RoutingConfig.
+func (r RoutingConfig) validate() error {
+	switch r.Strategy {
+	case "", "equal_slices", "consistent_hash":
+	default:
+		return fmt.Errorf("unknown strategy %q; want \"equal_slices\" or \"consistent_hash\"", r.Strategy)
+	}
+	if r.Replication < 0 {
+		return fmt.Errorf("negative replication %d", r.Replication)
+	}
+	if r.LoadFactor != 0 && r.LoadFactor < 1 {
+		return fmt.Errorf("load_factor %v less than 1", r.LoadFactor)
+	}
+	if r.Strategy != "consistent_hash" && (r.Replication != 0 || r.LoadFactor != 0) {
+		return fmt.Errorf("replication and load_factor require strategy \"consistent_hash\"")
+	}
+	return nil
+}
+
+// validate validates the ResourceLimits.
+func (r ResourceLimits) validate() error {
+	if r.CPU < 0 {
+		return fmt.Errorf("negative cpu %v", r.CPU)
+	}
+	if r.MemoryBytes < 0 {
+		return fmt.Errorf("negative memory_bytes %d", r.MemoryBytes)
+	}
+	return nil
+}
+
+// validate validates the ProcessConfig.
+func (p ProcessConfig) validate() error {
+	if _, err := env.Parse(p.Env); err != nil {
+		return fmt.Errorf("invalid env: %w", err)
+	}
+	return nil
+}
+
+// validate validates the AutoscalingConfig.
+func (a AutoscalingConfig) validate() error {
+	if a.MinReplicas < 0 {
+		return fmt.Errorf("negative min_replicas %d", a.MinReplicas)
+	}
+	if a.MaxReplicas <= 0 {
+		return fmt.Errorf("max_replicas %d not positive", a.MaxReplicas)
+	}
+	if a.MaxReplicas < a.MinReplicas {
+		return fmt.Errorf("max_replicas %d less than min_replicas %d", a.MaxReplicas, a.MinReplicas)
+	}
+	if a.TargetRequestRate < 0 {
+		return fmt.Errorf("negative target_request_rate %v", a.TargetRequestRate)
+	}
+	if a.TargetP99Latency < 0 {
+		return fmt.Errorf("negative target_p99_latency %v", a.TargetP99Latency)
+	}
+	if a.TargetCPU < 0 {
+		return fmt.Errorf("negative target_cpu %v", a.TargetCPU)
+	}
+	if a.TargetRequestRate == 0 && a.TargetP99Latency == 0 && a.TargetCPU == 0 {
+		return fmt.Errorf("no target_request_rate, target_p99_latency, or target_cpu")
+	}
+	if a.ScaleDownDelay < 0 {
+		return fmt.Errorf("negative scale_down_delay %v", a.ScaleDownDelay)
+	}
+	return nil
+}
+
+// validate validates the BreakerConfig.
+func (b BreakerConfig) validate() error {
+	if b.ConsecutiveFailures < 0 {
+		return fmt.Errorf("negative consecutive_failures %d", b.ConsecutiveFailures)
+	}
+	if b.ErrorRate < 0 || b.ErrorRate > 1 {
+		return fmt.Errorf("error_rate %v not in [0, 1]", b.ErrorRate)
+	}
+	if b.ConsecutiveFailures == 0 && b.ErrorRate == 0 {
+		return fmt.Errorf("no consecutive_failures or error_rate")
+	}
+	if b.Window < 0 {
+		return fmt.Errorf("negative window %v", b.Window)
+	}
+	if b.MinCalls < 0 {
+		return fmt.Errorf("negative min_calls %d", b.MinCalls)
+	}
+	if b.Cooldown < 0 {
+		return fmt.Errorf("negative cooldown %v", b.Cooldown)
+	}
+	return nil
+}
+
+// validate validates the AdmissionConfig.
+func (a AdmissionConfig) validate() error {
+	if a.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("negative max_concurrent_calls %d", a.MaxConcurrentCalls)
+	}
+	if a.MaxQueuedCalls < 0 {
+		return fmt.Errorf("negative max_queued_calls %d", a.MaxQueuedCalls)
+	}
+	if a.Rate < 0 {
+		return fmt.Errorf("negative rate %v", a.Rate)
+	}
+	if a.Burst < 0 {
+		return fmt.Errorf("negative burst %d", a.Burst)
+	}
+	if a.TargetQueueDelay < 0 {
+		return fmt.Errorf("negative target_queue_delay %v", a.TargetQueueDelay)
+	}
+	if a.QueueDelayInterval < 0 {
+		return fmt.Errorf("negative queue_delay_interval %v", a.QueueDelayInterval)
+	}
+	if a.MaxConcurrentCalls == 0 && a.Rate == 0 {
+		return fmt.Errorf("no max_concurrent_calls or rate")
+	}
+	if a.MaxConcurrentCalls == 0 && (a.MaxQueuedCalls > 0 || a.TargetQueueDelay > 0) {
+		return fmt.Errorf("max_queued_calls or target_queue_delay without max_concurrent_calls")
+	}
+	if a.TargetQueueDelay > 0 && a.MaxQueuedCalls == 0 {
+		return fmt.Errorf("target_queue_delay without max_queued_calls")
+	}
+	return nil
+}
+
+// validate validates the StoreConfig.
+func (s StoreConfig) validate() error {
+	switch s.Backend {
+	case "", "memory":
+		if s.Path != "" || s.Address != "" {
+			return fmt.Errorf("path and address require backend \"file\" or \"redis\"")
+		}
+	case "file":
+		if s.Path == "" {
+			return fmt.Errorf("backend \"file\" without a path")
+		}
+	case "redis":
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			return fmt.Errorf("invalid address %q: %w", s.Address, err)
+		}
+		if s.DB < 0 {
+			return fmt.Errorf("negative db %d", s.DB)
+		}
+	default:
+		return fmt.Errorf("unknown backend %q; want \"memory\", \"file\", or \"redis\"", s.Backend)
+	}
+	return nil
+}
+
+// validate validates the ExternalConfig.
+func (e ExternalConfig) validate() error {
+	switch e.Kind {
+	case "":
+		return fmt.Errorf("missing kind")
+	case "sql":
+		if e.Driver == "" || e.DSN == "" {
+			return fmt.Errorf("kind \"sql\" without a driver and a dsn")
+		}
+	}
+	if e.MaxConnections < 0 {
+		return fmt.Errorf("negative max_connections %d", e.MaxConnections)
+	}
+	if e.MaxIdleConnections < 0 {
+		return fmt.Errorf("negative max_idle_connections %d", e.MaxIdleConnections)
+	}
+	if e.ConnectionLifetime < 0 {
+		return fmt.Errorf("negative connection_lifetime %v", e.ConnectionLifetime)
+	}
+	return nil
+}
+
+// validate validates the MetricsConfig of the section with the provided name.
+func (m MetricsConfig) validate(name string) error {
+	if m.KindOrName(name) == "statsd" && m.Address == "" {
+		return fmt.Errorf("kind \"statsd\" without an address")
+	}
+	if m.Interval < 0 {
+		return fmt.Errorf("negative interval %v", m.Interval)
+	}
+	return nil
+}
+
+// validate validates the FederationConfig.
+func (f FederationConfig) validate() error {
+	if f.Address == "" {
+		if len(f.Exports) > 0 {
+			return fmt.Errorf("exports without an address")
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(f.Address); err != nil {
+		return fmt.Errorf("invalid address %q: %w", f.Address, err)
+	}
+	if f.CertFile == "" || f.KeyFile == "" {
+		return fmt.Errorf("address without a cert_file and a key_file")
+	}
+	if f.ClientCAFile == "" {
+		return fmt.Errorf("address without a client_ca_file")
+	}
+	if len(f.Exports) == 0 {
+		return fmt.Errorf("address without exports")
+	}
+	return nil
+}
+
+// validate validates the ImportConfig.
+func (i ImportConfig) validate() error {
+	if i.Address == "" {
+		return fmt.Errorf("missing address")
+	}
+	if _, _, err := net.SplitHostPort(i.Address); err != nil {
+		return fmt.Errorf("invalid address %q: %w", i.Address, err)
+	}
+	if i.CAFile == "" {
+		return fmt.Errorf("missing ca_file")
+	}
+	if i.CertFile == "" || i.KeyFile == "" {
+		return fmt.Errorf("missing cert_file and key_file")
+	}
+	return nil
+}
+
+// validate validates the OTLPConfig.
+func (o OTLPConfig) validate() error {
+	switch o.Protocol {
+	case "", "grpc", "http":
+	default:
+		return fmt.Errorf("unknown protocol %q; want \"grpc\" or \"http\"", o.Protocol)
+	}
+	if o.Endpoint != "" {
+		if _, _, err := net.SplitHostPort(o.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint %q: %w", o.Endpoint, err)
+		}
+	}
+	if o.SamplingRate < 0 || o.SamplingRate > 1 {
+		return fmt.Errorf("sampling_rate %v not in (0, 1]", o.SamplingRate)
+	}
+	if o.MetricsInterval < 0 {
+		return fmt.Errorf("negative metrics_interval %v", o.MetricsInterval)
+	}
+	return nil
+}
+
+// validate validates the TracingConfig.
+func (t TracingConfig) validate() error {
+	if err := t.SamplingConfig.validate(); err != nil {
+		return err
+	}
+	for name, c := range t.Components {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("component %q: %w", name, err)
+		}
+	}
+	for k := range t.Resource {
+		if k == "" {
+			return fmt.Errorf("empty resource attribute key")
+		}
+	}
+	return nil
+}
+
+// validate validates the SamplingConfig.
+func (s SamplingConfig) validate() error {
+	switch s.Sampler {
+	case "", "always", "never", "ratio", "parent_based", "rate_limited":
+	default:
+		return fmt.Errorf("unknown sampler %q; want \"always\", \"never\", \"ratio\", \"parent_based\", or \"rate_limited\"", s.Sampler)
+	}
+	if s.Ratio < 0 || s.Ratio > 1 {
+		return fmt.Errorf("ratio %v not in (0, 1]", s.Ratio)
+	}
+	if s.Rate < 0 {
+		return fmt.Errorf("negative rate %v", s.Rate)
+	}
+	switch s.Sampler {
+	case "always", "never", "rate_limited":
+		if s.Ratio != 0 {
+			return fmt.Errorf("ratio with a %q sampler", s.Sampler)
+		}
+	}
+	if s.Sampler == "rate_limited" {
+		if s.Rate == 0 {
+			return fmt.Errorf("\"rate_limited\" sampler without a rate")
+		}
+	} else if s.Rate != 0 {
+		return fmt.Errorf("rate without a \"rate_limited\" sampler")
+	}
+	return nil
+}
+
+// validate validates the ChaosConfig.
+func (c ChaosConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("negative interval %v", c.Interval)
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("negative duration %v", c.Duration)
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("negative latency %v", c.Latency)
+	}
+	for _, f := range c.Faults {
+		switch f {
+		case "kill", "partition", "latency":
+		default:
+			return fmt.Errorf("unknown fault %q; want \"kill\", \"partition\", or \"latency\"", f)
+		}
+	}
+	return nil
+}
+
+// validate validates the AlertsConfig.
+func (a AlertsConfig) validate() error {
+	for _, on := range a.On {
+		switch on {
+		case "crash_loop", "unhealthy", "rollout_stalled":
+		default:
+			return fmt.Errorf("unknown alert %q; want \"crash_loop\", \"unhealthy\", or \"rollout_stalled\"", on)
+		}
+	}
+	if a.CrashLoopRestarts < 0 {
+		return fmt.Errorf("negative crash_loop_restarts %d", a.CrashLoopRestarts)
+	}
+	if a.CrashLoopWindow < 0 {
+		return fmt.Errorf("negative crash_loop_window %v", a.CrashLoopWindow)
+	}
+	if a.RolloutStallTimeout < 0 {
+		return fmt.Errorf("negative rollout_stall_timeout %v", a.RolloutStallTimeout)
+	}
+	validURL := func(dest, rawURL string) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("%s: invalid url %q: %w", dest, rawURL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("%s: url %q is not an http or https URL", dest, rawURL)
+		}
+		return nil
+	}
+	if a.Slack != nil {
+		if err := validURL("slack", a.Slack.URL); err != nil {
+			return err
+		}
+	}
+	if a.PagerDuty != nil {
+		if a.PagerDuty.RoutingKey == "" {
+			return fmt.Errorf("pagerduty: missing routing_key")
+		}
+		if a.PagerDuty.URL != "" {
+			if err := validURL("pagerduty", a.PagerDuty.URL); err != nil {
+				return err
+			}
+		}
+	}
+	if a.Webhook != nil {
+		if err := validURL("webhook", a.Webhook.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate validates the RecordingConfig.
+func (r RecordingConfig) validate() error {
+	if r.Dir == "" && len(r.Components) > 0 {
+		return fmt.Errorf("components without a dir")
+	}
+	for _, c := range r.Components {
+		if c == "" {
+			return fmt.Errorf("empty component name")
+		}
+	}
+	return nil
+}
+
+// validate validates the LoggingConfig.
+func (l LoggingConfig) validate() error {
+	if l.Loki != nil {
+		u, err := url.Parse(l.Loki.URL)
+		if err != nil {
+			return fmt.Errorf("loki: invalid url %q: %w", l.Loki.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("loki: url %q is not an http or https URL", l.Loki.URL)
+		}
+		for name := range l.Loki.Labels {
+			if !isLokiLabel(name) {
+				return fmt.Errorf("loki: invalid label name %q", name)
+			}
+		}
+	}
+	if l.CloudLogging != nil && l.CloudLogging.Project == "" {
+		return fmt.Errorf("cloud_logging: no project")
+	}
+	if l.Syslog != nil {
+		switch l.Syslog.Network {
+		case "":
+			if l.Syslog.Address != "" {
+				return fmt.Errorf("syslog: address without a network")
+			}
+		case "tcp", "udp", "unix", "unixgram":
+			if l.Syslog.Address == "" {
+				return fmt.Errorf("syslog: no address")
+			}
+		default:
+			return fmt.Errorf("syslog: unknown network %q; want \"tcp\", \"udp\", \"unix\", or \"unixgram\"", l.Syslog.Network)
+		}
+	}
+	if l.File != nil {
+		if l.File.Dir == "" {
+			return fmt.Errorf("file: no dir")
+		}
+		if l.File.MaxBytes < 0 {
+			return fmt.Errorf("file: negative max_bytes %d", l.File.MaxBytes)
+		}
+		if l.File.MaxFiles < 0 {
+			return fmt.Errorf("file: negative max_files %d", l.File.MaxFiles)
+		}
+	}
+	return nil
+}
+
+// validate validates the ProfilingConfig.
+func (p ProfilingConfig) validate() error {
+	if p.Interval < 0 {
+		return fmt.Errorf("negative interval %v", p.Interval)
+	}
+	if p.CPUDuration < 0 {
+		return fmt.Errorf("negative cpu_duration %v", p.CPUDuration)
+	}
+	cpuDuration := p.CPUDuration
+	if cpuDuration == 0 {
+		cpuDuration = DefaultProfilingCPUDuration
+	}
+	if p.Interval > 0 && cpuDuration >= p.Interval {
+		return fmt.Errorf("cpu_duration %v not shorter than interval %v", cpuDuration, p.Interval)
+	}
+	seen := map[string]bool{}
+	for _, t := range p.Types {
+		switch t {
+		case "cpu", "heap", "mutex":
+		default:
+			return fmt.Errorf("unknown type %q; want \"cpu\", \"heap\", or \"mutex\"", t)
+		}
+		if seen[t] {
+			return fmt.Errorf("duplicate type %q", t)
+		}
+		seen[t] = true
+	}
+	if p.Interval == 0 && (p.Pyroscope != nil || p.CloudProfiler != nil) {
+		return fmt.Errorf("backends without an interval")
+	}
+	if p.Pyroscope != nil {
+		u, err := url.Parse(p.Pyroscope.URL)
+		if err != nil {
+			return fmt.Errorf("pyroscope: invalid url %q: %w", p.Pyroscope.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("pyroscope: url %q is not an http or https URL", p.Pyroscope.URL)
+		}
+	}
+	if p.CloudProfiler != nil && p.CloudProfiler.Project == "" {
+		return fmt.Errorf("cloud_profiler: no project")
+	}
+	return nil
+}
+
+// validate validates the RetentionConfig.
+func (r RetentionConfig) validate() error {
+	if r.Interval < 0 {
+		return fmt.Errorf("negative interval %v", r.Interval)
+	}
+	if err := r.Logs.validate(); err != nil {
+		return fmt.Errorf("logs: %w", err)
+	}
+	if err := r.Traces.validate(); err != nil {
+		return fmt.Errorf("traces: %w", err)
+	}
+	return nil
+}
+
+// validate validates the RetentionPolicy.
+func (r RetentionPolicy) validate() error {
+	if r.MaxAge < 0 {
+		return fmt.Errorf("negative max_age %v", r.MaxAge)
+	}
+	if r.MaxBytes < 0 {
+		return fmt.Errorf("negative max_bytes %d", r.MaxBytes)
+	}
+	return nil
+}
+
+// isLokiLabel returns whether the provided string is a valid Loki label
+// name, i.e., whether it matches [a-zA-Z_][a-zA-Z0-9_]*.
+func isLokiLabel(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validate validates the AuthConfig.
+func (a AuthConfig) validate() error {
+	if a.Issuer == "" && a.JWKSURL == "" && a.APIKeysFile == "" {
+		return fmt.Errorf("no issuer, jwks_url, or api_keys_file")
+	}
+	for _, u := range []string{a.Issuer, a.JWKSURL} {
+		if u == "" {
+			continue
+		}
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q: %w", u, err)
+		}
+		if parsed.Scheme != "https" && parsed.Scheme != "http" {
+			return fmt.Errorf("invalid URL %q: scheme not \"https\" or \"http\"", u)
+		}
+	}
+	for _, r := range a.Routes {
+		if !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("route path %q does not start with '/'", r.Path)
+		}
+		if r.Public && (len(r.Principals) > 0 || len(r.Claims) > 0) {
+			return fmt.Errorf("route %q: public route with principals or claims", r.Path)
+		}
+		if len(r.Claims) > 0 && a.Issuer == "" && a.JWKSURL == "" {
+			return fmt.Errorf("route %q: claims require an issuer or jwks_url", r.Path)
+		}
+	}
+	return nil
+}
+
+func extractApp(file string, config *protos.AppConfig) error {
 	parsed := &appConfig{}
 	if err := ParseConfigSection(appKey, shortAppKey, config.Sections, parsed); err != nil {
 		return err
@@ -121,6 +2792,7 @@ func extractApp(file string, config *protos.AppConfig) error {
 	config.Args = parsed.Args
 	config.Env = parsed.Env
 	config.RolloutNanos = int64(parsed.Rollout)
+	config.DependencyGraph = parsed.DependencyGraph
 	for _, colocate := range parsed.Colocate {
 		group := &protos.ComponentGroup{Components: colocate}
 		config.Colocate = append(config.Colocate, group)
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
@@ -102,6 +103,381 @@ func TestParseConfigSection(t *testing.T) {
 	}
 }
 
+func TestParseWeaveletConfig(t *testing.T) {
+	const cfg = `
+[serviceweaver]
+cache_max_bytes = 1024
+max_concurrent_calls = 100
+max_queued_calls = 10
+max_in_flight_calls = 50
+max_pending_calls = 5
+drain_grace_period = "5s"
+shutdown_timeout = "3s"
+load_balancing = "least_outstanding"
+compression = "zstd"
+warm_pool_size = 1
+health_check_interval = "1s"
+stream_idle_timeout = "30s"
+metrics_address = ":0"
+deployer_metrics_address = "localhost:9090"
+events_webhook = "https://alerts.example.com/weaver"
+mtls = true
+transport = "quic"
+separate = [["a/b", "a/c"]]
+
+[serviceweaver.methods."a/b.C"]
+cache_ttl = "30s"
+negative_cache_ttl = "1s"
+cache_max_entries = 100
+
+[serviceweaver.methods."a/b.D"]
+priority = "low"
+max_retries = 1
+idempotency_window = "10m"
+max_response_bytes = 1048576
+
+[serviceweaver.components."a/b"]
+timeout = "2s"
+max_retries = 3
+retry_backoff = "50ms"
+hedge_delay = "20ms"
+
+[serviceweaver.routing."a/b"]
+strategy = "consistent_hash"
+replication = 2
+load_factor = 1.5
+
+[serviceweaver.resources."a/b"]
+cpu = 1.5
+memory_bytes = 1048576
+
+[serviceweaver.process."a/b"]
+env = ["PLATFORM=gcp"]
+args = ["--verbose"]
+dir = "/var/lib/b"
+
+[serviceweaver.autoscaling."a/b"]
+max_replicas = 5
+target_request_rate = 100
+target_p99_latency = "50ms"
+
+[serviceweaver.breakers."a/b"]
+consecutive_failures = 5
+error_rate = 0.5
+cooldown = "10s"
+
+[serviceweaver.admission."a/b"]
+max_concurrent_calls = 100
+max_queued_calls = 1000
+rate = 12.5
+target_queue_delay = "20ms"
+
+[serviceweaver.startup."a/b"]
+lazy = true
+order = 2
+after = ["a/c"]
+
+[serviceweaver.stores.carts]
+backend = "file"
+path = "/tmp/carts.db"
+
+[serviceweaver.external.orders]
+kind = "sql"
+driver = "postgres"
+dsn = "postgres://orders@db/orders"
+max_connections = 20
+connection_lifetime = "5m"
+options = {schema = "public"}
+
+[serviceweaver.metrics.statsd]
+address = "statsd:8125"
+interval = "10s"
+
+[serviceweaver.metrics.ops]
+kind = "cloudwatch"
+options = {namespace = "Boutique"}
+
+[serviceweaver.federation]
+address = ":9000"
+cert_file = "/etc/gateway.pem"
+key_file = "/etc/gateway.key"
+client_ca_file = "/etc/partners.pem"
+
+[serviceweaver.federation.exports."a/b"]
+clients = ["payments"]
+
+[serviceweaver.imports."x/orders"]
+address = "orders.example.com:9000"
+ca_file = "/etc/orders-ca.pem"
+cert_file = "/etc/client.pem"
+key_file = "/etc/client.key"
+
+[serviceweaver.auth.frontend]
+issuer = "https://accounts.example.com"
+audience = "my-client-id"
+api_keys_file = "/etc/api_keys"
+routes = [
+  {path = "/healthz", public = true},
+  {path = "/admin/", methods = ["POST"], principals = ["alice"], claims = {role = "admin"}},
+]
+
+[serviceweaver.listeners.frontend]
+max_connections = 1000
+idle_timeout = "2m"
+session_affinity = "cookie"
+
+[serviceweaver.access."a/c".allow]
+"a/b" = ["C", "D"]
+"a/d" = ["*"]
+
+[serviceweaver.codecs]
+"a/b" = "msgpack"
+
+[serviceweaver.priorities]
+"a/d" = "low"
+
+[serviceweaver.otlp]
+protocol = "http"
+endpoint = "localhost:4318"
+headers = {"x-api-key" = "secret"}
+sampling_rate = 0.5
+
+[serviceweaver.tracing]
+resource = {"service.version" = "1.2.0", team = "payments"}
+
+[serviceweaver.tracing.components."a/b"]
+sampler = "rate_limited"
+rate = 10
+
+[serviceweaver.chaos]
+interval = "1m"
+faults = ["kill", "latency"]
+latency = "250ms"
+seed = 42
+
+[serviceweaver.alerts]
+on = ["crash_loop"]
+crash_loop_window = "5m"
+
+[serviceweaver.alerts.pagerduty]
+routing_key = "key"
+
+[serviceweaver.alerts.webhook]
+url = "https://alerts.example.com/alerts"
+headers = {Authorization = "Bearer token"}
+
+[serviceweaver.recording]
+dir = "/tmp/calls"
+components = ["a/b"]
+
+[serviceweaver.logging.loki]
+url = "http://loki:3100/loki/api/v1/push"
+labels = {env = "prod"}
+
+[serviceweaver.logging.file]
+dir = "/var/log/b"
+max_files = 3
+
+[serviceweaver.profiling]
+interval = "1m"
+types = ["cpu", "mutex"]
+
+[serviceweaver.profiling.pyroscope]
+url = "http://pyroscope:4040"
+
+[serviceweaver.retention.logs]
+max_age = "168h"
+max_bytes = 1073741824
+`
+	config, err := runtime.ParseConfig("weaver.toml", cfg, codegen.ComponentConfigValidator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := runtime.ParseWeaveletConfig(config.Sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &runtime.WeaveletConfig{
+		CacheMaxBytes:          1024,
+		MaxConcurrentCalls:     100,
+		MaxQueuedCalls:         10,
+		MaxInFlightCalls:       50,
+		MaxPendingCalls:        5,
+		DrainGracePeriod:       5 * time.Second,
+		MaxDrainTime:           runtime.DefaultMaxDrainTime,
+		ShutdownTimeout:        3 * time.Second,
+		LoadBalancing:          "least_outstanding",
+		Compression:            "zstd",
+		CompressionThreshold:   runtime.DefaultCompressionThreshold,
+		WarmPoolSize:           1,
+		StartupTimeout:         runtime.DefaultStartupTimeout,
+		HealthCheckInterval:    time.Second,
+		HealthCheckTimeout:     runtime.DefaultHealthCheckTimeout,
+		StreamIdleTimeout:      30 * time.Second,
+		MetricsAddress:         ":0",
+		DeployerMetricsAddress: "localhost:9090",
+		EventsWebhook:          "https://alerts.example.com/weaver",
+		Alerts: runtime.AlertsConfig{
+			On:                  []string{"crash_loop"},
+			CrashLoopRestarts:   runtime.DefaultCrashLoopRestarts,
+			CrashLoopWindow:     5 * time.Minute,
+			RolloutStallTimeout: runtime.DefaultRolloutStallTimeout,
+			PagerDuty:           &runtime.PagerDutyAlertConfig{RoutingKey: "key", URL: runtime.DefaultPagerDutyURL},
+			Webhook: &runtime.WebhookAlertConfig{
+				URL:     "https://alerts.example.com/alerts",
+				Headers: map[string]string{"Authorization": "Bearer token"},
+			},
+		},
+		MTLS:                   true,
+		Transport:              "quic",
+		OTLP: runtime.OTLPConfig{
+			Protocol:     "http",
+			Endpoint:     "localhost:4318",
+			Headers:      map[string]string{"x-api-key": "secret"},
+			SamplingRate: 0.5,
+		},
+		Tracing: runtime.TracingConfig{
+			Components: map[string]runtime.SamplingConfig{
+				"a/b": {Sampler: "rate_limited", Rate: 10},
+			},
+			Resource: map[string]string{"service.version": "1.2.0", "team": "payments"},
+		},
+		Chaos: runtime.ChaosConfig{
+			Interval: time.Minute,
+			Duration: runtime.DefaultChaosDuration,
+			Faults:   []string{"kill", "latency"},
+			Latency:  250 * time.Millisecond,
+			Seed:     42,
+		},
+		Recording: runtime.RecordingConfig{
+			Dir:        "/tmp/calls",
+			Components: []string{"a/b"},
+		},
+		Logging: runtime.LoggingConfig{
+			Loki: &runtime.LokiConfig{
+				URL:    "http://loki:3100/loki/api/v1/push",
+				Labels: map[string]string{"env": "prod"},
+			},
+			File: &runtime.LogFileConfig{Dir: "/var/log/b", MaxFiles: 3},
+		},
+		Profiling: runtime.ProfilingConfig{
+			Interval:    time.Minute,
+			CPUDuration: runtime.DefaultProfilingCPUDuration,
+			Types:       []string{"cpu", "mutex"},
+			Pyroscope:   &runtime.PyroscopeConfig{URL: "http://pyroscope:4040"},
+		},
+		Retention: runtime.RetentionConfig{
+			Interval: runtime.DefaultRetentionInterval,
+			Logs:     runtime.RetentionPolicy{MaxAge: 168 * time.Hour, MaxBytes: 1 << 30},
+		},
+		Components: map[string]runtime.CallConfig{
+			"a/b": {Timeout: 2 * time.Second, MaxRetries: 3, RetryBackoff: 50 * time.Millisecond, HedgeDelay: 20 * time.Millisecond},
+		},
+		Routing: map[string]runtime.RoutingConfig{
+			"a/b": {Strategy: "consistent_hash", Replication: 2, LoadFactor: 1.5},
+		},
+		Startup: map[string]runtime.StartupConfig{
+			"a/b": {Lazy: true, Order: 2, After: []string{"a/c"}},
+		},
+		Resources: map[string]runtime.ResourceLimits{
+			"a/b": {CPU: 1.5, MemoryBytes: 1 << 20},
+		},
+		Separate: [][]string{{"a/b", "a/c"}},
+		Processes: map[string]runtime.ProcessConfig{
+			"a/b": {Env: []string{"PLATFORM=gcp"}, Args: []string{"--verbose"}, Dir: "/var/lib/b"},
+		},
+		Autoscaling: map[string]runtime.AutoscalingConfig{
+			"a/b": {
+				MinReplicas:       1,
+				MaxReplicas:       5,
+				TargetRequestRate: 100,
+				TargetP99Latency:  50 * time.Millisecond,
+				ScaleDownDelay:    runtime.DefaultScaleDownDelay,
+			},
+		},
+		Breakers: map[string]runtime.BreakerConfig{
+			"a/b": {
+				ConsecutiveFailures: 5,
+				ErrorRate:           0.5,
+				Window:              runtime.DefaultBreakerWindow,
+				MinCalls:            runtime.DefaultBreakerMinCalls,
+				Cooldown:            10 * time.Second,
+			},
+		},
+		Admission: map[string]runtime.AdmissionConfig{
+			"a/b": {
+				MaxConcurrentCalls: 100,
+				MaxQueuedCalls:     1000,
+				Rate:               12.5,
+				Burst:              13,
+				TargetQueueDelay:   20 * time.Millisecond,
+				QueueDelayInterval: runtime.DefaultQueueDelayInterval,
+			},
+		},
+		Stores: map[string]runtime.StoreConfig{
+			"carts": {Backend: "file", Path: "/tmp/carts.db"},
+		},
+		External: map[string]runtime.ExternalConfig{
+			"orders": {
+				Kind:               "sql",
+				Driver:             "postgres",
+				DSN:                "postgres://orders@db/orders",
+				MaxConnections:     20,
+				ConnectionLifetime: 5 * time.Minute,
+				Options:            map[string]string{"schema": "public"},
+			},
+		},
+		Metrics: map[string]runtime.MetricsConfig{
+			"statsd": {Address: "statsd:8125", Interval: 10 * time.Second},
+			"ops":    {Kind: "cloudwatch", Options: map[string]string{"namespace": "Boutique"}},
+		},
+		Federation: runtime.FederationConfig{
+			Address:      ":9000",
+			CertFile:     "/etc/gateway.pem",
+			KeyFile:      "/etc/gateway.key",
+			ClientCAFile: "/etc/partners.pem",
+			Exports: map[string]runtime.ExportConfig{
+				"a/b": {Clients: []string{"payments"}},
+			},
+		},
+		Imports: map[string]runtime.ImportConfig{
+			"x/orders": {
+				Address:  "orders.example.com:9000",
+				CAFile:   "/etc/orders-ca.pem",
+				CertFile: "/etc/client.pem",
+				KeyFile:  "/etc/client.key",
+			},
+		},
+		Auth: map[string]runtime.AuthConfig{
+			"frontend": {
+				Issuer:       "https://accounts.example.com",
+				Audience:     "my-client-id",
+				APIKeysFile:  "/etc/api_keys",
+				APIKeyHeader: runtime.DefaultAPIKeyHeader,
+				Routes: []runtime.AuthRoute{
+					{Path: "/healthz", Public: true},
+					{Path: "/admin/", Methods: []string{"POST"}, Principals: []string{"alice"}, Claims: map[string]string{"role": "admin"}},
+				},
+			},
+		},
+		Listeners: map[string]runtime.ListenerConfig{
+			"frontend": {MaxConnections: 1000, IdleTimeout: 2 * time.Minute, SessionAffinity: "cookie", SessionKey: runtime.DefaultSessionCookie},
+		},
+		Access: map[string]runtime.AccessConfig{
+			"a/c": {Allow: map[string][]string{"a/b": {"C", "D"}, "a/d": {"*"}}},
+		},
+		Codecs:     map[string]string{"a/b": "msgpack"},
+		Priorities: map[string]string{"a/d": "low"},
+		Methods: map[string]runtime.MethodConfig{
+			"a/b.C": {CacheTTL: 30 * time.Second, NegativeCacheTTL: time.Second, CacheMaxEntries: 100},
+			"a/b.D": {Priority: "low", CallConfig: runtime.CallConfig{MaxRetries: 1, MaxResponseBytes: 1 << 20}, IdempotencyWindow: 10 * time.Minute},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("ParseWeaveletConfig: (-want +got):\n%s", diff)
+	}
+}
+
 func TestConfigErrors(t *testing.T) {
 	type testCase struct {
 		name          string
@@ -152,6 +528,719 @@ rollout = "hello"
 `,
 			expectedError: "invalid duration",
 		},
+		{
+			name: "negative cache ttl",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+cache_ttl = "-1s"
+`,
+			expectedError: "negative cache TTL",
+		},
+		{
+			name: "negative cache max entries",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+cache_max_entries = -1
+`,
+			expectedError: "negative cache_max_entries",
+		},
+		{
+			name: "empty access method",
+			cfg: `
+[serviceweaver.access."a/c".allow]
+"a/b" = ["C", ""]
+`,
+			expectedError: "empty method name",
+		},
+		{
+			name: "empty codec",
+			cfg: `
+[serviceweaver.codecs]
+"a/b" = ""
+`,
+			expectedError: "empty codec name",
+		},
+		{
+			name: "negative idempotency window",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+idempotency_window = "-1s"
+`,
+			expectedError: "negative idempotency_window",
+		},
+		{
+			name: "negative component timeout",
+			cfg: `
+[serviceweaver.components."a/b"]
+timeout = "-1s"
+`,
+			expectedError: "negative timeout",
+		},
+		{
+			name: "negative method max retries",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+max_retries = -1
+`,
+			expectedError: "negative max_retries",
+		},
+		{
+			name: "negative hedge delay",
+			cfg: `
+[serviceweaver.components."a/b"]
+hedge_delay = "-1s"
+`,
+			expectedError: "negative hedge_delay",
+		},
+		{
+			name: "negative max request bytes",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+max_request_bytes = -1
+`,
+			expectedError: "negative max_request_bytes",
+		},
+		{
+			name: "negative max concurrent calls",
+			cfg: `
+[serviceweaver]
+max_concurrent_calls = -1
+`,
+			expectedError: "negative max_concurrent_calls",
+		},
+		{
+			name: "negative max in flight calls",
+			cfg: `
+[serviceweaver]
+max_in_flight_calls = -1
+`,
+			expectedError: "negative max_in_flight_calls",
+		},
+		{
+			name: "pending calls without in flight calls",
+			cfg: `
+[serviceweaver]
+max_pending_calls = 10
+`,
+			expectedError: "max_pending_calls without max_in_flight_calls",
+		},
+		{
+			name: "negative max drain time",
+			cfg: `
+[serviceweaver]
+max_drain_time = "-1s"
+`,
+			expectedError: "negative max_drain_time",
+		},
+		{
+			name: "negative shutdown timeout",
+			cfg: `
+[serviceweaver]
+shutdown_timeout = "-1s"
+`,
+			expectedError: "negative shutdown_timeout",
+		},
+		{
+			name: "bad otlp protocol",
+			cfg: `
+[serviceweaver.otlp]
+protocol = "udp"
+endpoint = "localhost:4317"
+`,
+			expectedError: "unknown protocol",
+		},
+		{
+			name: "bad otlp sampling rate",
+			cfg: `
+[serviceweaver.otlp]
+sampling_rate = 2.0
+`,
+			expectedError: "sampling_rate",
+		},
+		{
+			name: "bad loki url",
+			cfg: `
+[serviceweaver.logging.loki]
+url = "loki:3100"
+`,
+			expectedError: "not an http or https URL",
+		},
+		{
+			name: "bad loki label",
+			cfg: `
+[serviceweaver.logging.loki]
+url = "http://loki:3100/loki/api/v1/push"
+labels = {"my-env" = "prod"}
+`,
+			expectedError: "invalid label name",
+		},
+		{
+			name: "cloud logging without project",
+			cfg: `
+[serviceweaver.logging.cloud_logging]
+log_name = "boutique"
+`,
+			expectedError: "no project",
+		},
+		{
+			name: "syslog address without network",
+			cfg: `
+[serviceweaver.logging.syslog]
+address = "localhost:514"
+`,
+			expectedError: "address without a network",
+		},
+		{
+			name: "log file without dir",
+			cfg: `
+[serviceweaver.logging.file]
+max_bytes = 1024
+`,
+			expectedError: "no dir",
+		},
+		{
+			name: "cpu profiles longer than interval",
+			cfg: `
+[serviceweaver.profiling]
+interval = "5s"
+`,
+			expectedError: "not shorter than interval",
+		},
+		{
+			name: "unknown profile type",
+			cfg: `
+[serviceweaver.profiling]
+interval = "1m"
+types = ["goroutine"]
+`,
+			expectedError: "unknown type",
+		},
+		{
+			name: "profiling backend without interval",
+			cfg: `
+[serviceweaver.profiling.cloud_profiler]
+project = "my-project"
+`,
+			expectedError: "backends without an interval",
+		},
+		{
+			name: "negative retention max age",
+			cfg: `
+[serviceweaver.retention.traces]
+max_age = "-1h"
+`,
+			expectedError: "negative max_age",
+		},
+		{
+			name: "unknown sampler",
+			cfg: `
+[serviceweaver.tracing]
+sampler = "sometimes"
+`,
+			expectedError: "unknown sampler",
+		},
+		{
+			name: "bad sampling ratio",
+			cfg: `
+[serviceweaver.tracing]
+sampler = "ratio"
+ratio = 1.5
+`,
+			expectedError: "ratio 1.5 not in (0, 1]",
+		},
+		{
+			name: "rate limited sampler without rate",
+			cfg: `
+[serviceweaver.tracing.components."a/b"]
+sampler = "rate_limited"
+`,
+			expectedError: "without a rate",
+		},
+		{
+			name: "sampling rate with tracing sampler",
+			cfg: `
+[serviceweaver.otlp]
+sampling_rate = 0.5
+
+[serviceweaver.tracing]
+sampler = "always"
+`,
+			expectedError: "sampling_rate along with a tracing sampler",
+		},
+		{
+			name: "empty resource attribute key",
+			cfg: `
+[serviceweaver.tracing]
+resource = {"" = "x"}
+`,
+			expectedError: "empty resource attribute key",
+		},
+		{
+			name: "unknown chaos fault",
+			cfg: `
+[serviceweaver.chaos]
+faults = ["kill", "flood"]
+`,
+			expectedError: "unknown fault",
+		},
+		{
+			name: "negative chaos interval",
+			cfg: `
+[serviceweaver.chaos]
+interval = "-1s"
+`,
+			expectedError: "negative interval",
+		},
+		{
+			name: "federation without a client CA",
+			cfg: `
+[serviceweaver.federation]
+address = ":9000"
+cert_file = "/etc/gateway.pem"
+key_file = "/etc/gateway.key"
+
+[serviceweaver.federation.exports."a/b"]
+`,
+			expectedError: "without a client_ca_file",
+		},
+		{
+			name: "federation exports without an address",
+			cfg: `
+[serviceweaver.federation.exports."a/b"]
+clients = ["payments"]
+`,
+			expectedError: "exports without an address",
+		},
+		{
+			name: "import without credentials",
+			cfg: `
+[serviceweaver.imports."x/orders"]
+address = "orders.example.com:9000"
+ca_file = "/etc/orders-ca.pem"
+`,
+			expectedError: "missing cert_file and key_file",
+		},
+		{
+			name: "recording components without dir",
+			cfg: `
+[serviceweaver.recording]
+components = ["a/b"]
+`,
+			expectedError: "components without a dir",
+		},
+		{
+			name: "bad metrics address",
+			cfg: `
+[serviceweaver]
+metrics_address = "9090"
+`,
+			expectedError: "invalid metrics_address",
+		},
+		{
+			name: "unknown load balancing",
+			cfg: `
+[serviceweaver]
+load_balancing = "random"
+`,
+			expectedError: "unknown load_balancing",
+		},
+		{
+			name: "unknown compression",
+			cfg: `
+[serviceweaver]
+compression = "gzip"
+`,
+			expectedError: "unknown compression",
+		},
+		{
+			name: "unknown transport",
+			cfg: `
+[serviceweaver]
+transport = "udp"
+`,
+			expectedError: "unknown transport",
+		},
+		{
+			name: "invalid events webhook",
+			cfg: `
+[serviceweaver]
+events_webhook = "alerts.example.com"
+`,
+			expectedError: "invalid events_webhook",
+		},
+		{
+			name: "unknown alert",
+			cfg: `
+[serviceweaver.alerts]
+on = ["crash_loop", "oom"]
+`,
+			expectedError: "unknown alert",
+		},
+		{
+			name: "pagerduty alerts without routing key",
+			cfg: `
+[serviceweaver.alerts.pagerduty]
+url = "https://events.pagerduty.com/v2/enqueue"
+`,
+			expectedError: "missing routing_key",
+		},
+		{
+			name: "invalid slack alerts url",
+			cfg: `
+[serviceweaver.alerts.slack]
+url = "hooks.slack.com/services/x"
+`,
+			expectedError: "not an http or https URL",
+		},
+		{
+			name: "negative compression threshold",
+			cfg: `
+[serviceweaver]
+compression = "snappy"
+compression_threshold = -1
+`,
+			expectedError: "negative compression_threshold",
+		},
+		{
+			name: "negative health check timeout",
+			cfg: `
+[serviceweaver]
+health_check_timeout = "-1s"
+`,
+			expectedError: "negative health_check_timeout",
+		},
+		{
+			name: "negative stream idle timeout",
+			cfg: `
+[serviceweaver]
+stream_idle_timeout = "-1s"
+`,
+			expectedError: "negative stream_idle_timeout",
+		},
+		{
+			name: "negative warm pool size",
+			cfg: `
+[serviceweaver]
+warm_pool_size = -1
+`,
+			expectedError: "negative warm_pool_size",
+		},
+		{
+			name: "unknown routing strategy",
+			cfg: `
+[serviceweaver.routing."a/b"]
+strategy = "random"
+`,
+			expectedError: "unknown strategy",
+		},
+		{
+			name: "small load factor",
+			cfg: `
+[serviceweaver.routing."a/b"]
+strategy = "consistent_hash"
+load_factor = 0.5
+`,
+			expectedError: "load_factor",
+		},
+		{
+			name: "replication without consistent hashing",
+			cfg: `
+[serviceweaver.routing."a/b"]
+replication = 2
+`,
+			expectedError: "require strategy",
+		},
+		{
+			name: "negative cpu limit",
+			cfg: `
+[serviceweaver.resources."a/b"]
+cpu = -1.0
+`,
+			expectedError: "negative cpu",
+		},
+		{
+			name: "co-located resource limits",
+			cfg: `
+[serviceweaver]
+colocate = [["a/b", "a/c"]]
+
+[serviceweaver.resources."a/b"]
+cpu = 1.0
+
+[serviceweaver.resources."a/c"]
+memory_bytes = 1024
+`,
+			expectedError: "co-located",
+		},
+		{
+			name: "separate single component",
+			cfg: `
+[serviceweaver]
+separate = [["a/b"]]
+`,
+			expectedError: "fewer than two components",
+		},
+		{
+			name: "separate co-located components",
+			cfg: `
+[serviceweaver]
+colocate = [["a/b", "a/c"]]
+separate = [["a/b", "a/c"]]
+`,
+			expectedError: "components are co-located",
+		},
+		{
+			name: "invalid process env",
+			cfg: `
+[serviceweaver.process."a/b"]
+env = ["PLATFORM"]
+`,
+			expectedError: "invalid env",
+		},
+		{
+			name: "co-located process configs",
+			cfg: `
+[serviceweaver]
+colocate = [["a/b", "a/c"]]
+
+[serviceweaver.process."a/b"]
+dir = "/tmp"
+
+[serviceweaver.process."a/c"]
+args = ["--verbose"]
+`,
+			expectedError: "co-located",
+		},
+		{
+			name: "autoscaling without target",
+			cfg: `
+[serviceweaver.autoscaling."a/b"]
+max_replicas = 3
+`,
+			expectedError: "no target_request_rate",
+		},
+		{
+			name: "autoscaling max below min",
+			cfg: `
+[serviceweaver.autoscaling."a/b"]
+min_replicas = 4
+max_replicas = 3
+target_cpu = 0.5
+`,
+			expectedError: "less than min_replicas",
+		},
+		{
+			name: "breaker without trigger",
+			cfg: `
+[serviceweaver.breakers."a/b"]
+cooldown = "10s"
+`,
+			expectedError: "no consecutive_failures or error_rate",
+		},
+		{
+			name: "breaker error rate above one",
+			cfg: `
+[serviceweaver.breakers."a/b"]
+error_rate = 1.5
+`,
+			expectedError: "not in [0, 1]",
+		},
+		{
+			name: "admission without limit",
+			cfg: `
+[serviceweaver.admission."a/b"]
+burst = 10
+`,
+			expectedError: "no max_concurrent_calls or rate",
+		},
+		{
+			name: "admission queue delay without queue",
+			cfg: `
+[serviceweaver.admission."a/b"]
+max_concurrent_calls = 10
+target_queue_delay = "5ms"
+`,
+			expectedError: "target_queue_delay without max_queued_calls",
+		},
+		{
+			name: "lazy main",
+			cfg: `
+[serviceweaver.startup.main]
+lazy = true
+`,
+			expectedError: "main component cannot be lazy",
+		},
+		{
+			name: "startup cycle",
+			cfg: `
+[serviceweaver.startup."a/b"]
+after = ["a/c"]
+
+[serviceweaver.startup."a/c"]
+after = ["a/d"]
+
+[serviceweaver.startup."a/d"]
+after = ["a/b"]
+`,
+			expectedError: "a/b -> a/c -> a/d -> a/b",
+		},
+		{
+			name: "startup self",
+			cfg: `
+[serviceweaver.startup.main]
+after = ["main"]
+`,
+			expectedError: "components wait for each other",
+		},
+		{
+			name: "file store without path",
+			cfg: `
+[serviceweaver.stores.carts]
+backend = "file"
+`,
+			expectedError: "without a path",
+		},
+		{
+			name: "unknown store backend",
+			cfg: `
+[serviceweaver.stores.carts]
+backend = "spanner"
+`,
+			expectedError: "unknown backend",
+		},
+		{
+			name: "external without kind",
+			cfg: `
+[serviceweaver.external.orders]
+address = "orders.internal:443"
+`,
+			expectedError: "missing kind",
+		},
+		{
+			name: "sql external without dsn",
+			cfg: `
+[serviceweaver.external.orders]
+kind = "sql"
+driver = "postgres"
+`,
+			expectedError: "without a driver and a dsn",
+		},
+		{
+			name: "statsd metrics without address",
+			cfg: `
+[serviceweaver.metrics.statsd]
+interval = "10s"
+`,
+			expectedError: "without an address",
+		},
+		{
+			name: "negative metrics interval",
+			cfg: `
+[serviceweaver.metrics.ops]
+kind = "cloudwatch"
+interval = "-1s"
+`,
+			expectedError: "negative interval",
+		},
+		{
+			name: "auth without credentials",
+			cfg: `
+[serviceweaver.auth.frontend]
+audience = "my-client-id"
+`,
+			expectedError: "no issuer, jwks_url, or api_keys_file",
+		},
+		{
+			name: "auth with invalid issuer",
+			cfg: `
+[serviceweaver.auth.frontend]
+issuer = "accounts.example.com"
+`,
+			expectedError: "scheme not",
+		},
+		{
+			name: "auth route without leading slash",
+			cfg: `
+[serviceweaver.auth.frontend]
+api_keys_file = "/etc/api_keys"
+routes = [{path = "admin"}]
+`,
+			expectedError: "does not start with '/'",
+		},
+		{
+			name: "public auth route with principals",
+			cfg: `
+[serviceweaver.auth.frontend]
+api_keys_file = "/etc/api_keys"
+routes = [{path = "/", public = true, principals = ["alice"]}]
+`,
+			expectedError: "public route with principals or claims",
+		},
+		{
+			name: "auth route claims without tokens",
+			cfg: `
+[serviceweaver.auth.frontend]
+api_keys_file = "/etc/api_keys"
+routes = [{path = "/admin/", claims = {role = "admin"}}]
+`,
+			expectedError: "claims require an issuer or jwks_url",
+		},
+		{
+			name: "negative listener max connections",
+			cfg: `
+[serviceweaver.listeners.frontend]
+max_connections = -1
+`,
+			expectedError: "negative max_connections",
+		},
+		{
+			name: "unknown listener session affinity",
+			cfg: `
+[serviceweaver.listeners.frontend]
+session_affinity = "ip"
+`,
+			expectedError: "unknown session_affinity",
+		},
+		{
+			name: "header session affinity without key",
+			cfg: `
+[serviceweaver.listeners.frontend]
+session_affinity = "header"
+`,
+			expectedError: "header session affinity without a session_key",
+		},
+		{
+			name: "negative listener idle timeout",
+			cfg: `
+[serviceweaver.listeners.frontend]
+idle_timeout = "-1s"
+`,
+			expectedError: "negative idle_timeout",
+		},
+		{
+			name: "unknown priority",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+priority = "urgent"
+`,
+			expectedError: "unknown priority",
+		},
+		{
+			name: "unknown caller priority",
+			cfg: `
+[serviceweaver.priorities]
+"a/b" = "urgent"
+`,
+			expectedError: "unknown priority",
+		},
+		{
+			name: "unknown method key",
+			cfg: `
+[serviceweaver.methods."a/b.C"]
+cachettl = "1s"
+`,
+			expectedError: "unknown",
+		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
 			_, err := runtime.ParseConfig("weaver.toml", c.cfg, codegen.ComponentConfigValidator)
@@ -164,3 +1253,58 @@ rollout = "hello"
 		})
 	}
 }
+
+func TestProcessConfigs(t *testing.T) {
+	const cfg = `
+[serviceweaver]
+binary = "/bin/app"
+args = ["--port=8000"]
+env = ["PLATFORM=local", "DEBUG=1"]
+colocate = [["a/b", "a/c"]]
+
+[serviceweaver.process."a/c"]
+env = ["PLATFORM=gcp"]
+args = ["--verbose"]
+dir = "/var/lib/c"
+
+[serviceweaver.process."a/d"]
+env = ["CACHE_SIZE=1024"]
+`
+	app, err := runtime.ParseConfig("weaver.toml", cfg, codegen.ComponentConfigValidator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := runtime.ParseWeaveletConfig(app.Sections)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Process configs apply to the co-location group of their component.
+	processes := runtime.GroupProcessConfigs(app, config)
+	if got, want := len(processes), 2; got != want {
+		t.Fatalf("GroupProcessConfigs: got %d configs, want %d", got, want)
+	}
+	p, ok := processes["a/b"]
+	if !ok {
+		t.Fatalf("GroupProcessConfigs: no config for group a/b in %v", processes)
+	}
+
+	applied := runtime.ApplyProcessConfig(app, p)
+	if diff := cmp.Diff([]string{"--port=8000", "--verbose"}, applied.Args); diff != "" {
+		t.Errorf("args (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PLATFORM=local", "DEBUG=1", "PLATFORM=gcp"}, applied.Env); diff != "" {
+		t.Errorf("env (-want +got):\n%s", diff)
+	}
+	if got, want := applied.Dir, "/var/lib/c"; got != want {
+		t.Errorf("dir: got %q, want %q", got, want)
+	}
+
+	// The original config is not modified.
+	if got, want := len(app.Args), 1; got != want {
+		t.Errorf("original args: got %d, want %d", got, want)
+	}
+	if app.Dir != "" {
+		t.Errorf("original dir: got %q, want empty", app.Dir)
+	}
+}
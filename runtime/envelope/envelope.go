@@ -48,7 +48,9 @@ type EnvelopeHandler interface {
 
 	// ExportListener exports the provided listener. Exporting a listener
 	// typically, but not always, involves running a proxy that forwards
-	// traffic to the provided address.
+	// traffic to the provided address. If clients reach the listener through
+	// some other address, like a load balancer, the reply should include it
+	// in advertised_address, along with whether clients connect using TLS.
 	ExportListener(context.Context, *protos.ExportListenerRequest) (*protos.ExportListenerReply, error)
 
 	// HandleLogEntry handles a log entry.
@@ -56,6 +58,60 @@ type EnvelopeHandler interface {
 
 	// HandleTraceSpans handles a set of trace spans.
 	HandleTraceSpans(context.Context, []trace.ReadOnlySpan) error
+
+	// HandleTopicEvent handles an event published by the weavelet. A
+	// deployer should forward the event to every other weavelet in the
+	// deployment using Envelope.SendTopicEvent; the publishing weavelet
+	// delivers the event to its own subscribers. Dropping the event is also
+	// acceptable, since delivery is best effort.
+	HandleTopicEvent(context.Context, *protos.TopicEvent) error
+
+	// PublishMessage stores a message published by the weavelet on a
+	// weaver.Topic, for delivery to every subscription to the topic. The
+	// message must be stored before PublishMessage returns, and delivered
+	// using Envelope.DeliverMessage until a delivery succeeds. See
+	// internal/pubsub for an implementation. Deployers that don't support
+	// topics should return an error.
+	PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error)
+
+	// Subscribe subscribes the weavelet to a subscription to a topic. Every
+	// message of the subscription should be delivered to one of the
+	// weavelets subscribed to it, using Envelope.DeliverMessage.
+	Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error)
+
+	// ScheduleJob schedules a job registered by the weavelet with
+	// weaver.Cron. Every run of the job should be started on exactly one of
+	// the weavelets that scheduled it, using Envelope.RunJob. See
+	// internal/cron for an implementation. Deployers that don't support cron
+	// jobs should return an error.
+	ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error)
+
+	// GetCertificate mints a new certificate that authenticates the weavelet
+	// to the other weavelets in the deployment. It is called by weavelets of
+	// applications configured with mtls = true. See internal/mtls for an
+	// implementation. Deployers that don't support mutual TLS should return
+	// an error.
+	GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error)
+
+	// Lease acquires, renews, or releases the lease of a leader election
+	// created by the weavelet with weaver.NewLeaderElection. The lease of an
+	// election should be held by at most one weavelet at a time. See
+	// internal/lease for an implementation. Deployers that don't support
+	// leader elections should return an error.
+	Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error)
+
+	// HandleProfile handles a profile collected by the continuous profiler
+	// of the weavelet. A deployer should keep the recent profiles, e.g., to
+	// show them in its dashboard; see status.ProfileStore. Deployers that
+	// don't keep profiles can drop them.
+	HandleProfile(context.Context, *protos.Profile) error
+
+	// HandleCrashReport handles the report of a panic in a component method,
+	// sent by the weavelet right before it crashes. A deployer should keep
+	// the recent reports, e.g., to show them in its dashboard; see
+	// status.CrashStore. Deployers that don't keep crash reports can drop
+	// them.
+	HandleCrashReport(context.Context, *protos.CrashReport) error
 }
 
 // Ensure that EnvelopeHandler remains in-sync with conn.EnvelopeHandler.
@@ -100,6 +156,7 @@ func NewEnvelope(ctx context.Context, wlet *protos.EnvelopeInfo, config *protos.
 
 	// Form the weavelet command.
 	cmd := pipe.CommandContext(e.ctx, e.config.Binary, e.config.Args...)
+	cmd.Dir = e.config.Dir
 
 	// Create the pipes first, so we can fill cmd.Env and detect any errors early.
 	//
@@ -224,11 +281,36 @@ func (e *Envelope) WeaveletInfo() *protos.WeaveletInfo {
 
 // GetHealth returns the health status of the weavelet.
 func (e *Envelope) GetHealth() protos.HealthStatus {
-	status, err := e.conn.GetHealthRPC()
+	return e.CheckHealth().Status
+}
+
+// CheckHealth returns the health status of the weavelet and, if the weavelet
+// is unhealthy, the reason why, e.g., the error returned by the HealthCheck
+// method of one of its components.
+func (e *Envelope) CheckHealth() *protos.GetHealthReply {
+	reply, err := e.conn.GetHealthRPC()
 	if err != nil {
-		return protos.HealthStatus_UNHEALTHY
+		return &protos.GetHealthReply{Status: protos.HealthStatus_UNHEALTHY, Reason: err.Error()}
+	}
+	return reply
+}
+
+// Drain asks the weavelet to drain, and waits until it is drained or ctx is
+// done. A deployer should drain a weavelet before stopping it, e.g., during a
+// rolling update or when the application is shut down. To shift traffic away
+// from the weavelet while it drains, the deployer should also stop routing
+// calls to its address and stop forwarding traffic to its listeners before
+// calling Drain. The weavelet bounds the time it spends draining by the
+// max_drain_time specified in the config; see runtime.WeaveletConfig.
+func (e *Envelope) Drain(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() { errs <- e.conn.DrainRPC() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return status
 }
 
 // GetProfile gets a profile from the weavelet.
@@ -240,6 +322,30 @@ func (e *Envelope) GetProfile(req *protos.GetProfileRequest) ([]byte, error) {
 	return e.conn.GetProfileRPC(req)
 }
 
+// SetLogLevel changes the minimum level of the log entries produced by a
+// component in the weavelet. See protos.SetLogLevelRequest for details.
+func (e *Envelope) SetLogLevel(req *protos.SetLogLevelRequest) error {
+	return e.conn.SetLogLevelRPC(req)
+}
+
+// InjectFaults asks the weavelet to inject faults into its calls to remote
+// components. See protos.InjectFaultsRequest for details.
+func (e *Envelope) InjectFaults(req *protos.InjectFaultsRequest) error {
+	return e.conn.InjectFaultsRPC(req)
+}
+
+// SetFlags replaces the feature flags of the weavelet's deployment. See
+// protos.SetFlagsRequest for details.
+func (e *Envelope) SetFlags(req *protos.SetFlagsRequest) error {
+	return e.conn.SetFlagsRPC(req)
+}
+
+// SetConfig replaces the component config sections of the weavelet's
+// deployment. See protos.SetConfigRequest for details.
+func (e *Envelope) SetConfig(req *protos.SetConfigRequest) error {
+	return e.conn.SetConfigRPC(req)
+}
+
 // GetMetrics returns a weavelet's metrics.
 func (e *Envelope) GetMetrics() ([]*metrics.MetricSnapshot, error) {
 	return e.conn.GetMetricsRPC()
@@ -262,6 +368,25 @@ func (e *Envelope) UpdateRoutingInfo(routing *protos.RoutingInfo) error {
 	return e.conn.UpdateRoutingInfoRPC(routing)
 }
 
+// SendTopicEvent delivers an event published by another weavelet in the
+// deployment to the weavelet's subscribers.
+func (e *Envelope) SendTopicEvent(event *protos.TopicEvent) error {
+	return e.conn.SendTopicEvent(event)
+}
+
+// DeliverMessage delivers a message published on a topic to the weavelet, and
+// blocks until the weavelet has handled it. A nil error acknowledges the
+// message.
+func (e *Envelope) DeliverMessage(req *protos.DeliverMessageRequest) error {
+	return e.conn.DeliverMessageRPC(req)
+}
+
+// RunJob runs a job registered with weaver.Cron in the weavelet, and blocks
+// until the run has finished.
+func (e *Envelope) RunJob(req *protos.RunJobRequest) error {
+	return e.conn.RunJobRPC(req)
+}
+
 func (e *Envelope) logLines(component string, src io.Reader, h EnvelopeHandler) error {
 	// Fill partial log entry.
 	entry := &protos.LogEntry{
@@ -290,9 +415,14 @@ func (e *Envelope) logLines(component string, src io.Reader, h EnvelopeHandler)
 	}
 }
 
+// dropNewline drops the trailing newline, "\n" or "\r\n" as written by some
+// Windows programs, of the provided line, if any.
 func dropNewline(line []byte) []byte {
 	if len(line) > 0 && line[len(line)-1] == '\n' {
 		line = line[:len(line)-1]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
 	}
 	return line
 }
@@ -176,6 +176,38 @@ func (*handlerForTest) ExportListener(context.Context, *protos.ExportListenerReq
 	return nil, nil
 }
 
+func (*handlerForTest) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	return nil
+}
+
+func (*handlerForTest) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return nil, nil
+}
+
+func (*handlerForTest) HandleProfile(context.Context, *protos.Profile) error {
+	return nil
+}
+
+func (*handlerForTest) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	return nil
+}
+
 func TestStartStop(t *testing.T) {
 	filename := filepath.Join(t.TempDir(), "file.txt")
 	if _, err := os.Create(filename); err != nil {
@@ -320,7 +352,17 @@ func createWeaveletConn() (*conn.WeaveletConn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable make weavelet<->envelope pipes: %w", err)
 	}
-	return conn.NewWeaveletConn(toWeavelet, toEnvelope, nil /*handler*/)
+	return conn.NewWeaveletConn(toWeavelet, toEnvelope, healthyHandler{})
+}
+
+// healthyHandler is a conn.WeaveletHandler that always reports itself as
+// healthy. Its other methods must not be called.
+type healthyHandler struct {
+	conn.WeaveletHandler
+}
+
+func (healthyHandler) Health() *protos.GetHealthReply {
+	return &protos.GetHealthReply{Status: protos.HealthStatus_HEALTHY}
 }
 
 func writeTraces(conn *conn.WeaveletConn) error {
@@ -451,15 +493,15 @@ func startEnvelope(ctx context.Context, t *testing.T) (*Envelope, chan error) {
 }
 
 func TestSingleProfile(t *testing.T) {
-	// Test Plan: Start an envelope and send it a heap and a cpu profile
-	// requests, one after another. Verify that both succeed and return
+	// Test Plan: Start an envelope and send it heap, cpu, and mutex profile
+	// requests, one after another. Verify that all succeed and return
 	// non-empty profile data.
 	ctx, cancel := context.WithCancel(context.Background())
 	e, done := startEnvelope(ctx, t)
 	defer func() { <-done }()
 	defer cancel()
 
-	for _, typ := range []protos.ProfileType{protos.ProfileType_Heap, protos.ProfileType_CPU} {
+	for _, typ := range []protos.ProfileType{protos.ProfileType_Heap, protos.ProfileType_CPU, protos.ProfileType_Mutex} {
 		typ := typ
 		t.Run(typ.String(), func(t *testing.T) {
 			// Send a profiling request and wait for a reply.
@@ -523,3 +565,18 @@ func TestConcurrentProfiles(t *testing.T) {
 		t.Fatalf("unexpected profiler error, want %s got %v", expect, profErr)
 	}
 }
+
+func TestDropNewline(t *testing.T) {
+	for _, test := range []struct{ line, want string }{
+		{"", ""},
+		{"hello", "hello"},
+		{"hello\n", "hello"},
+		{"hello\r\n", "hello"},
+		{"hello\r", "hello\r"},
+		{"\n", ""},
+	} {
+		if got := string(dropNewline([]byte(test.line))); got != test.want {
+			t.Errorf("dropNewline(%q): got %q, want %q", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logdb contains an indexed store of log entries, backed by a SQLite
+// database, that can be queried by component, level, time range, attributes,
+// and message text, or with arbitrary SQL.
+//
+// Unlike the files written by a logging.FileStore, which have to be scanned
+// in full to answer a query, the database indexes every log entry, so queries
+// over the logs of many weavelets stay fast as the logs grow.
+package logdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// How often buffered log entries are written to the database.
+	flushInterval = 100 * time.Millisecond
+
+	// The maximum number of buffered log entries. Entries added while the
+	// buffer is full are dropped.
+	maxPending = 100000
+
+	// The attribute of system log entries.
+	systemAttr = "serviceweaver/system"
+)
+
+// schema is the schema of the database. Users can refer to the tables and
+// columns in the Where clause of a Query.
+const schema = `
+-- Log entries.
+CREATE TABLE IF NOT EXISTS logs (
+	id INTEGER PRIMARY KEY,
+	app TEXT NOT NULL,
+	version TEXT NOT NULL,         -- deployment id
+	component TEXT NOT NULL,       -- full component name
+	short_component TEXT NOT NULL, -- shortened component name, e.g., pkg.T
+	node TEXT NOT NULL,            -- weavelet id
+	time INTEGER NOT NULL,         -- microseconds since the Unix epoch
+	level TEXT NOT NULL,
+	severity INTEGER NOT NULL,     -- numeric level: -4 (debug) to 8 (error)
+	file TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	msg TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS logs_by_time ON logs(time);
+CREATE INDEX IF NOT EXISTS logs_by_component ON logs(component, time);
+CREATE INDEX IF NOT EXISTS logs_by_short_component ON logs(short_component, time);
+CREATE INDEX IF NOT EXISTS logs_by_severity ON logs(severity, time);
+
+-- Log entry attributes.
+CREATE TABLE IF NOT EXISTS attrs (
+	log INTEGER NOT NULL, -- id of the log entry
+	key TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS attrs_by_key ON attrs(key, value);
+CREATE INDEX IF NOT EXISTS attrs_by_log ON attrs(log);
+
+-- Full text index of log entry messages.
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_text USING fts5(
+	msg, content='logs', content_rowid='id'
+);
+`
+
+// DB is a database of log entries stored on the local file system.
+//
+// Log entries added to a DB are buffered and written to the database in
+// batches. A DB may be shared by multiple processes.
+type DB struct {
+	fname string
+	db    *sql.DB
+
+	start   sync.Once
+	done    chan struct{} // closed when Close is called
+	stopped chan struct{} // closed when the flushing goroutine exits
+
+	mu      sync.Mutex
+	pending []*protos.LogEntry // entries not yet written to the database
+}
+
+// Query is a filter for the log entries in a DB. Every non-zero field of a
+// Query must match for an entry to be returned.
+type Query struct {
+	App       string            // application name
+	Version   string            // prefix of the deployment id
+	Component string            // full or shortened component name
+	Node      string            // prefix of the weavelet id
+	MinLevel  string            // least severe level, e.g., "warn"
+	Since     time.Time         // earliest time, inclusive
+	Until     time.Time         // latest time, exclusive
+	Attrs     map[string]string // attributes and their values
+	Text      string            // full text query over messages, in FTS5 syntax
+	Where     string            // SQL boolean expression over the logs table
+	System    bool              // if true, system log entries are returned
+	Limit     int               // if positive, only the latest Limit entries are returned
+}
+
+// Open opens the log database stored in the provided file, creating it if
+// needed.
+func Open(ctx context.Context, fname string) (*DB, error) {
+	// The DB may be opened by multiple processes. Write-ahead logging lets
+	// readers run concurrently with the writer, and the busy timeout makes
+	// writers wait for each other. See:
+	//   https://www.sqlite.org/wal.html
+	//   https://www.sqlite.org/pragma.html#pragma_busy_timeout
+	const params = "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", fname+params)
+	if err != nil {
+		return nil, fmt.Errorf("open log db %q: %w", fname, err)
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open log db %q: %w", fname, err)
+	}
+	return &DB{
+		fname:   fname,
+		db:      db,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}, nil
+}
+
+// Close writes the buffered log entries to the database and closes it.
+func (d *DB) Close() error {
+	started := true
+	d.start.Do(func() { started = false })
+	if started {
+		close(d.done)
+		<-d.stopped
+	}
+	d.flush()
+	return d.db.Close()
+}
+
+// Add adds the provided log entry to the database, assigning a timestamp to it
+// if necessary. The entry is written asynchronously.
+func (d *DB) Add(entry *protos.LogEntry) {
+	if entry.TimeMicros == 0 {
+		entry.TimeMicros = time.Now().UnixMicro()
+	}
+	d.start.Do(func() { go d.flushPeriodically() })
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.pending) < maxPending {
+		d.pending = append(d.pending, entry)
+	}
+}
+
+// flushPeriodically writes the buffered log entries to the database every
+// flushInterval, until the DB is closed.
+func (d *DB) flushPeriodically() {
+	defer close(d.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			d.flush()
+		}
+	}
+}
+
+// flush writes the buffered log entries to the database.
+func (d *DB) flush() {
+	d.mu.Lock()
+	entries := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+	if err := d.store(context.Background(), entries); err != nil {
+		// Like a logging.FileStore, fall back to stderr.
+		fmt.Fprintf(os.Stderr, "store %d log entries in %q: %v\n", len(entries), d.fname, err)
+	}
+}
+
+// store writes the provided log entries to the database, in a single
+// transaction.
+func (d *DB) store(ctx context.Context, entries []*protos.LogEntry) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	insertLog, err := tx.PrepareContext(ctx, `
+		INSERT INTO logs(app, version, component, short_component, node, time, level, severity, file, line, msg)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?);
+	`)
+	if err != nil {
+		return err
+	}
+	insertText, err := tx.PrepareContext(ctx, `INSERT INTO logs_text(rowid, msg) VALUES (?,?);`)
+	if err != nil {
+		return err
+	}
+	insertAttr, err := tx.PrepareContext(ctx, `INSERT INTO attrs(log, key, value) VALUES (?,?,?);`)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		res, err := insertLog.ExecContext(ctx, e.App, e.Version, e.Component,
+			logging.ShortenComponent(e.Component), e.Node, e.TimeMicros, e.Level,
+			int(severity(e.Level)), e.File, e.Line, e.Msg)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := insertText.ExecContext(ctx, id, e.Msg); err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(e.Attrs); i += 2 {
+			if _, err := insertAttr.ExecContext(ctx, id, e.Attrs[i], e.Attrs[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// severity returns the numeric severity of the provided level. Unknown levels
+// are as severe as info.
+func severity(level string) slog.Level {
+	l, err := logging.ParseLevel(level)
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// Query returns the log entries that match the provided query, ordered by
+// time. The query is run on a read-only connection, so the Where clause of the
+// query cannot modify the database.
+func (d *DB) Query(ctx context.Context, q Query) ([]*protos.LogEntry, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, vals ...any) {
+		conds = append(conds, cond)
+		args = append(args, vals...)
+	}
+	if q.App != "" {
+		add("app = ?", q.App)
+	}
+	if q.Version != "" {
+		add("substr(version, 1, ?) = ?", len(q.Version), q.Version)
+	}
+	if q.Component != "" {
+		add("(component = ? OR short_component = ?)", q.Component, q.Component)
+	}
+	if q.Node != "" {
+		add("substr(node, 1, ?) = ?", len(q.Node), q.Node)
+	}
+	if q.MinLevel != "" {
+		level, err := logging.ParseLevel(q.MinLevel)
+		if err != nil {
+			return nil, err
+		}
+		add("severity >= ?", int(level))
+	}
+	if !q.Since.IsZero() {
+		add("time >= ?", q.Since.UnixMicro())
+	}
+	if !q.Until.IsZero() {
+		add("time < ?", q.Until.UnixMicro())
+	}
+	keys := make([]string, 0, len(q.Attrs))
+	for key := range q.Attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add("id IN (SELECT log FROM attrs WHERE key = ? AND value = ?)", key, q.Attrs[key])
+	}
+	if q.Text != "" {
+		add("id IN (SELECT rowid FROM logs_text WHERE logs_text MATCH ?)", q.Text)
+	}
+	if !q.System {
+		add("id NOT IN (SELECT log FROM attrs WHERE key = ?)", systemAttr)
+	}
+	if q.Where != "" {
+		add("(" + q.Where + ")")
+	}
+	where := "TRUE"
+	if len(conds) > 0 {
+		where = strings.Join(conds, " AND ")
+	}
+	limit := -1 // no limit
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+	args = append(args, limit)
+
+	// Pick the latest entries that match, and return them oldest first.
+	stmt := fmt.Sprintf(`
+		SELECT app, version, component, node, time, level, file, line, msg, attrs FROM (
+			SELECT id, app, version, component, node, time, level, file, line, msg,
+				(SELECT json_group_array(json_array(key, value)) FROM attrs WHERE log = logs.id) AS attrs
+			FROM logs
+			WHERE %s
+			ORDER BY time DESC, id DESC
+			LIMIT ?
+		)
+		ORDER BY time, id;
+	`, where)
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON;"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = OFF;") //nolint:errcheck // best effort
+
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query logs: %w", err)
+	}
+	defer rows.Close()
+	var entries []*protos.LogEntry
+	for rows.Next() {
+		e := &protos.LogEntry{}
+		var attrs string
+		if err := rows.Scan(&e.App, &e.Version, &e.Component, &e.Node, &e.TimeMicros, &e.Level, &e.File, &e.Line, &e.Msg, &attrs); err != nil {
+			return nil, fmt.Errorf("query logs: %w", err)
+		}
+		var kvs [][2]string
+		if err := json.Unmarshal([]byte(attrs), &kvs); err != nil {
+			return nil, fmt.Errorf("query logs: attributes: %w", err)
+		}
+		for _, kv := range kvs {
+			e.Attrs = append(e.Attrs, kv[0], kv[1])
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query logs: %w", err)
+	}
+	return entries, nil
+}
+
+// Deployment summarizes the log entries of a deployment stored in a DB.
+type Deployment struct {
+	App     string
+	Version string    // deployment id
+	Latest  time.Time // time of the latest log entry
+	Bytes   int64     // approximate size of the log entries
+}
+
+// Deployments returns a summary of the log entries of every deployment
+// stored in the database.
+func (d *DB) Deployments(ctx context.Context) ([]Deployment, error) {
+	// The size of an entry is approximated by the size of its text columns.
+	const stmt = `
+		SELECT app, version, MAX(time),
+			SUM(LENGTH(component) + LENGTH(node) + LENGTH(file) + LENGTH(msg))
+		FROM logs
+		GROUP BY app, version
+		ORDER BY app, version;
+	`
+	rows, err := d.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	defer rows.Close()
+	var deps []Deployment
+	for rows.Next() {
+		var dep Deployment
+		var latest int64
+		if err := rows.Scan(&dep.App, &dep.Version, &latest, &dep.Bytes); err != nil {
+			return nil, fmt.Errorf("list deployments: %w", err)
+		}
+		dep.Latest = time.UnixMicro(latest)
+		deps = append(deps, dep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	return deps, nil
+}
+
+// DeleteDeployment deletes the log entries of the provided deployment, and
+// returns the freed space to the file system.
+func (d *DB) DeleteDeployment(ctx context.Context, app, version string) error {
+	// Write the buffered entries first, so none of them outlives the delete.
+	d.flush()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op once committed
+
+	// Entries are removed from the full text index with the special
+	// 'delete' command of external content tables. See:
+	//   https://www.sqlite.org/fts5.html#the_delete_command
+	for _, stmt := range []string{
+		`DELETE FROM attrs WHERE log IN (SELECT id FROM logs WHERE app = ? AND version = ?);`,
+		`INSERT INTO logs_text(logs_text, rowid, msg) SELECT 'delete', id, msg FROM logs WHERE app = ? AND version = ?;`,
+		`DELETE FROM logs WHERE app = ? AND version = ?;`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, app, version); err != nil {
+			return fmt.Errorf("delete deployment %s: %w", version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete deployment %s: %w", version, err)
+	}
+	if _, err := d.db.ExecContext(ctx, "VACUUM;"); err != nil {
+		return fmt.Errorf("delete deployment %s: vacuum: %w", version, err)
+	}
+	return nil
+}
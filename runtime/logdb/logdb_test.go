@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logdb
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	fname := filepath.Join(t.TempDir(), "logs.db")
+	db, err := Open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(secs int) int64 { return start.Add(time.Duration(secs) * time.Second).UnixMicro() }
+	entries := []*protos.LogEntry{
+		{App: "todo", Version: "v1234567", Component: "github.com/a/store/Store", Node: "n1", TimeMicros: at(0), Level: "debug", Msg: "connecting to database"},
+		{App: "todo", Version: "v1234567", Component: "github.com/a/store/Store", Node: "n1", TimeMicros: at(1), Level: "error", Msg: "database timeout", Attrs: []string{"table", "users", "retries", "3"}},
+		{App: "todo", Version: "v1234567", Component: "github.com/a/cache/Cache", Node: "n2", TimeMicros: at(2), Level: "info", Msg: "cache miss", Attrs: []string{"table", "users"}},
+		{App: "todo", Version: "v7654321", Component: "github.com/a/store/Store", Node: "n3", TimeMicros: at(3), Level: "warn", Msg: "slow query"},
+		{App: "chat", Version: "v0000000", Component: "main", Node: "n4", TimeMicros: at(4), Level: "info", Msg: "weavelet started", Attrs: []string{"serviceweaver/system", ""}},
+	}
+	for _, e := range entries {
+		db.Add(e)
+	}
+	// Close the database to flush the entries, and reopen it.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, test := range []struct {
+		name  string
+		query Query
+		want  []int // indices of the expected entries
+	}{
+		{"All", Query{}, []int{0, 1, 2, 3}},
+		{"System", Query{System: true}, []int{0, 1, 2, 3, 4}},
+		{"App", Query{App: "todo"}, []int{0, 1, 2, 3}},
+		{"Version", Query{Version: "v1"}, []int{0, 1, 2}},
+		{"FullComponent", Query{Component: "github.com/a/cache/Cache"}, []int{2}},
+		{"ShortComponent", Query{Component: "store.Store"}, []int{0, 1, 3}},
+		{"Node", Query{Node: "n2"}, []int{2}},
+		{"MinLevel", Query{MinLevel: "warn"}, []int{1, 3}},
+		{"TimeRange", Query{Since: start.Add(time.Second), Until: start.Add(3 * time.Second)}, []int{1, 2}},
+		{"Attr", Query{Attrs: map[string]string{"table": "users"}}, []int{1, 2}},
+		{"Attrs", Query{Attrs: map[string]string{"table": "users", "retries": "3"}}, []int{1}},
+		{"Text", Query{Text: "database"}, []int{0, 1}},
+		{"TextPrefix", Query{Text: "conn*"}, []int{0}},
+		{"Where", Query{Where: "msg LIKE '%query%' OR node = 'n2'"}, []int{2, 3}},
+		{"Limit", Query{Limit: 2}, []int{2, 3}},
+		{"Combined", Query{Component: "store.Store", MinLevel: "info", Text: "timeout"}, []int{1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := db.Query(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []*protos.LogEntry
+			for _, i := range test.want {
+				want = append(want, entries[i])
+			}
+			if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+				t.Fatalf("Query(%+v) (-want +got):\n%s", test.query, diff)
+			}
+		})
+	}
+}
+
+func TestQueryIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Add(&protos.LogEntry{App: "todo", Msg: "hello"})
+	db.flush()
+
+	q := Query{Where: "TRUE; DELETE FROM logs"}
+	if _, err := db.Query(ctx, q); err == nil {
+		t.Fatalf("Query(%q): unexpected success", q.Where)
+	}
+	got, err := db.Query(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Query: got %d entries, want 1", len(got))
+	}
+}
+
+func TestDeleteDeployment(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	db.Add(&protos.LogEntry{App: "todo", Version: "v1", TimeMicros: start.UnixMicro(), Msg: "database timeout", Attrs: []string{"table", "users"}})
+	db.Add(&protos.LogEntry{App: "todo", Version: "v2", TimeMicros: start.Add(time.Hour).UnixMicro(), Msg: "database timeout", Attrs: []string{"table", "users"}})
+	db.flush()
+
+	deps, err := db.Deployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Deployment{
+		{App: "todo", Version: "v1", Latest: start, Bytes: int64(len("database timeout"))},
+		{App: "todo", Version: "v2", Latest: start.Add(time.Hour), Bytes: int64(len("database timeout"))},
+	}
+	if diff := cmp.Diff(want, deps, cmp.Comparer(time.Time.Equal)); diff != "" {
+		t.Fatalf("Deployments (-want +got):\n%s", diff)
+	}
+
+	if err := db.DeleteDeployment(ctx, "todo", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	// The entry must be gone from the logs, the attributes, and the full
+	// text index.
+	for _, q := range []Query{{}, {Attrs: map[string]string{"table": "users"}}, {Text: "timeout"}} {
+		got, err := db.Query(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Version != "v2" {
+			t.Fatalf("Query(%+v): got %v, want the entry of v2", q, got)
+		}
+	}
+	var attrs int
+	if err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM attrs").Scan(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	if attrs != 1 {
+		t.Fatalf("got %d attributes, want 1", attrs)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"context"
+
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// An Exporter exports log entries to a logging backend, e.g., Loki or a
+// file.
+type Exporter interface {
+	// Export exports a batch of log entries, in the order they were logged.
+	// Export is not called concurrently, and must not modify the entries.
+	Export(ctx context.Context, entries []*protos.LogEntry) error
+}
@@ -16,10 +16,13 @@ package logging
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -27,6 +30,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/ServiceWeaver/weaver/internal/cond"
 	"github.com/ServiceWeaver/weaver/internal/heap"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
@@ -34,6 +38,7 @@ import (
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/fsnotify/fsnotify"
 	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/proto"
 )
 
 // This file contains code to read and write log entries to and from files.
@@ -44,6 +49,7 @@ var DefaultLogDir = filepath.Join(os.TempDir(), "serviceweaver", "logs")
 // FileStore stores log entries in files.
 type FileStore struct {
 	dir string
+	key *atrest.Key // if not nil, encrypts the log entries
 	mu  sync.Mutex
 	pp  *PrettyPrinter
 
@@ -53,11 +59,19 @@ type FileStore struct {
 
 // NewFileStore returns a LogStore that writes files to the specified directory.
 func NewFileStore(dir string) (*FileStore, error) {
+	return NewEncryptedFileStore(dir, nil)
+}
+
+// NewEncryptedFileStore returns a LogStore that writes files to the specified
+// directory, encrypted with the provided key. If key is nil, the files are
+// not encrypted. Encrypted files are decrypted transparently by FileSource.
+func NewEncryptedFileStore(dir string, key *atrest.Key) (*FileStore, error) {
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return nil, err
 	}
 	return &FileStore{
 		dir:   dir,
+		key:   key,
 		pp:    NewPrettyPrinter(colors.Enabled()),
 		files: map[string]*os.File{},
 	}, nil
@@ -96,7 +110,7 @@ func (fs *FileStore) Add(e *protos.LogEntry) {
 	f, ok := fs.files[fname]
 	if !ok {
 		var err error
-		f, err = os.Create(filepath.Join(fs.dir, fname))
+		f, err = fs.create(fname)
 		if err != nil {
 			// Since we can't open the log file, fall back to stderr.
 			fmt.Fprintf(os.Stderr, "create log file: %v\n", err)
@@ -107,7 +121,7 @@ func (fs *FileStore) Add(e *protos.LogEntry) {
 
 	// Write to log file if available.
 	if f != nil {
-		err := protomsg.Write(f, e)
+		err := fs.write(f, e)
 		if err == nil {
 			return
 		}
@@ -120,6 +134,37 @@ func (fs *FileStore) Add(e *protos.LogEntry) {
 	fmt.Fprintln(os.Stderr, fs.pp.Format(e))
 }
 
+// create creates the named log file. An encrypted log file starts with the
+// header of the key that encrypts its entries.
+func (fs *FileStore) create(fname string) (*os.File, error) {
+	f, err := os.Create(filepath.Join(fs.dir, fname))
+	if err != nil || fs.key == nil {
+		return f, err
+	}
+	if err := atrest.WriteHeader(f, fs.key); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// write writes a log entry to a log file, as a length-prefixed, possibly
+// encrypted, protobuf.
+func (fs *FileStore) write(f *os.File, e *protos.LogEntry) error {
+	if fs.key == nil {
+		return protomsg.Write(f, e)
+	}
+	enc, err := proto.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sealed := fs.key.Seal(enc)
+	data := make([]byte, 4, 4+len(sealed))
+	binary.LittleEndian.PutUint32(data, uint32(len(sealed)))
+	_, err = f.Write(append(data, sealed...))
+	return err
+}
+
 // filename returns the log file for the specified (app, deployment, weavelet,
 // level) tuple.
 //
@@ -177,6 +222,16 @@ func parseLogfile(filename string) (logfile, error) {
 	}, nil
 }
 
+// ParseFileName returns the application name and deployment id of the log
+// file with the provided base name, as written by a FileStore.
+func ParseFileName(name string) (app, deployment string, err error) {
+	l, err := parseLogfile(name)
+	if err != nil {
+		return "", "", err
+	}
+	return l.app, l.deployment, nil
+}
+
 // matches returns whether the provided compiled query may match some log
 // entries in this logfile.
 func (l *logfile) matches(prog cel.Program) (bool, error) {
@@ -457,7 +512,7 @@ type fileScanner struct {
 	entry   *protos.LogEntry      // buffered entry scanned from scanner
 	buf     chan *protos.LogEntry // buffer of entries scanned from scanner
 	blocked bool                  // is tailReader blocked?
-	reader  *tailReader           // reads the file
+	reader  *entryReader          // reads the file
 	ready   *cond.Cond            // signals reader that more bytes are ready
 }
 
@@ -654,7 +709,7 @@ func (ff *fileFollower) created(filename string) error {
 		ready:   cond.NewCond(&ff.mu),
 	}
 	reader := newTailReader(file, func() error { return ff.waitForChanges(fs) })
-	fs.reader = reader
+	fs.reader = &entryReader{src: reader}
 	ff.scanners[filename] = fs
 
 	// Launch a goroutine that scans the file.
@@ -701,7 +756,7 @@ func (ff *fileFollower) scan(fs *fileScanner) error {
 		//
 		// Note that fs.reader is cancelled when ff.ctx is cancelled. This will
 		// also cause scanner.Scan to be cancelled.
-		err := protomsg.Read(fs.reader, entry)
+		err := fs.reader.read(ff.ctx, entry)
 		if err != nil {
 			return err
 		}
@@ -849,11 +904,58 @@ func ls(dir string, prog cel.Program) ([]string, error) {
 	return filenames, nil
 }
 
+// maxEntrySize is the maximum size of a log entry read from a log file, as
+// written by protomsg.Write.
+const maxEntrySize = math.MaxInt32
+
+// An entryReader reads the log entries of a log file written by a FileStore.
+// If the file starts with an encryption header, the entries are decrypted
+// with the key it references.
+type entryReader struct {
+	src     io.Reader   // source of log entries
+	started bool        // has the first entry or header been read?
+	key     *atrest.Key // decrypts the entries, if not nil
+}
+
+// read reads the next log entry into entry.
+func (r *entryReader) read(ctx context.Context, entry *protos.LogEntry) error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r.src, hdr[:]); err != nil {
+		return fmt.Errorf("read log entry length: %w", err)
+	}
+	if !r.started {
+		r.started = true
+		if atrest.HasHeader(hdr[:]) {
+			key, err := atrest.ReadHeader(ctx, io.MultiReader(bytes.NewReader(hdr[:]), r.src))
+			if err != nil {
+				return err
+			}
+			r.key = key
+			return r.read(ctx, entry)
+		}
+	}
+	n := binary.LittleEndian.Uint32(hdr[:])
+	if n > maxEntrySize {
+		return fmt.Errorf("read log entry: size %d is too large", n)
+	}
+	data := make([]byte, int(n))
+	if _, err := io.ReadFull(r.src, data); err != nil {
+		return fmt.Errorf("read log entry data %d: %w", n, err)
+	}
+	if r.key != nil {
+		var err error
+		if data, err = r.key.Open(data); err != nil {
+			return err
+		}
+	}
+	return proto.Unmarshal(data, entry)
+}
+
 // buffered is an entryScanner with a buffered *Entry scanned from it.
 type buffered struct {
 	filename string           // absolute filename of the file being scanned
 	entry    *protos.LogEntry // entry scanned from scanner
-	src      *bufio.Reader    // source of log entries
+	src      *entryReader     // source of log entries
 }
 
 // newBuffered returns a new buffered.
@@ -861,7 +963,7 @@ func newBuffered(filename string, src io.Reader) *buffered {
 	return &buffered{
 		filename: filename,
 		entry:    nil,
-		src:      bufio.NewReader(src),
+		src:      &entryReader{src: bufio.NewReader(src)},
 	}
 }
 
@@ -874,7 +976,7 @@ func (b *buffered) buffer() error {
 	}
 
 	entry := &protos.LogEntry{}
-	err := protomsg.Read(b.src, entry)
+	err := b.src.read(context.Background(), entry)
 	if err != nil && !errors.Is(err, io.EOF) {
 		return err
 	} else if errors.Is(err, io.EOF) {
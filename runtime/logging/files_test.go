@@ -15,7 +15,9 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -25,10 +27,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/ServiceWeaver/weaver/runtime/protomsg"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 )
 
@@ -367,6 +371,49 @@ func TestFileFollowerNoMatches(t *testing.T) {
 	}
 }
 
+func TestEncryptedFileStore(t *testing.T) {
+	os.RemoveAll(logdir)
+	ctx := ctx(t)
+
+	t.Setenv("LOGGING_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	key, err := atrest.Resolve(ctx, "${secret:env:LOGGING_TEST_KEY}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewEncryptedFileStore(logdir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	// Write encrypted entries, next to plaintext ones.
+	if err := (&fileTestLogger{app: "test", dep: "v1", component: "a", weavelet: "1", n: 1}).Log(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	r := follow(t, ctx, `version == "v2"`)
+	want := []*protos.LogEntry{}
+	for i := 0; i < 10; i++ {
+		e := &protos.LogEntry{App: "test", Version: "v2", Node: "2", Level: "info", Line: -1, Msg: fmt.Sprintf("classified %d", i)}
+		want = append(want, e)
+		fs.Add(proto.Clone(e).(*protos.LogEntry))
+	}
+
+	data, err := os.ReadFile(filepath.Join(logdir, filename("test", "v2", "2", "info")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("classified")) {
+		t.Fatal("plaintext found in encrypted log file")
+	}
+	if got := take(t, ctx, r, len(want)); !cmp.Equal(want, got, opts()...) {
+		t.Errorf("bad follow (-want +got):\n%s", cmp.Diff(want, got, opts()...))
+	}
+	got := drain(t, ctx, cat(t, ctx, `app == "test"`))
+	if len(got) != len(want)+1 {
+		t.Errorf("bad cat: got %d entries, want %d", len(got), len(want)+1)
+	}
+}
+
 // drain reads and returns every entry from r.
 func drain(t *testing.T, ctx context.Context, r Reader) []*protos.LogEntry {
 	t.Helper()
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -46,6 +47,10 @@ type Options struct {
 type LogHandler struct {
 	Opts  Options                      // configures the log entries
 	Write func(entry *protos.LogEntry) // called on every log entry
+
+	// If not nil, log entries below Level are dropped. The level may change
+	// over time, e.g., if Level is a *slog.LevelVar.
+	Level slog.Leveler
 }
 
 var _ slog.Handler = &LogHandler{}
@@ -57,9 +62,12 @@ func (h *LogHandler) Handle(rec slog.Record) error {
 }
 
 // Enabled implements the slog.Handler interface.
-func (h *LogHandler) Enabled(context.Context, slog.Level) bool {
-	// Support all logging levels.
-	return true
+func (h *LogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.Level == nil {
+		// Support all logging levels.
+		return true
+	}
+	return level >= h.Level.Level()
 }
 
 // WithAttrs implements the slog.Handler interface.
@@ -72,6 +80,7 @@ func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	rh := &LogHandler{
 		Opts:  h.Opts,
 		Write: h.Write,
+		Level: h.Level,
 	}
 	rh.Opts.Attrs = appendAttrs(rh.Opts.Attrs, attrs)
 	return rh
@@ -118,6 +127,22 @@ func (h *LogHandler) makeEntry(rec slog.Record) *protos.LogEntry {
 	return &entry
 }
 
+// ParseLevel parses a log level: "debug", "info", "warn", or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q; want %q, %q, %q, or %q", s, "debug", "info", "warn", "error")
+	}
+}
+
 // StderrLogger returns a logger that pretty prints log entries to stderr.
 func StderrLogger(opts Options) *slog.Logger {
 	pp := NewPrettyPrinter(colors.Enabled())
@@ -141,3 +141,45 @@ func newAttrLogger(app, version, component, weavelet string, saver func(*protos.
 		Write: saver,
 	})
 }
+
+func TestLevel(t *testing.T) {
+	var got []string
+	var level slog.LevelVar
+	logger := slog.New(&LogHandler{
+		Write: func(e *protos.LogEntry) { got = append(got, e.Msg) },
+		Level: &level,
+	}).With("foo", "bar")
+
+	level.Set(slog.LevelWarn)
+	logger.Debug("1")
+	logger.Info("2")
+	logger.Warn("3")
+	level.Set(slog.LevelDebug)
+	logger.Debug("4")
+	if diff := cmp.Diff([]string{"3", "4"}, got); diff != "" {
+		t.Fatalf("unexpected log entries (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	} {
+		got, err := ParseLevel(test.s)
+		if err != nil {
+			t.Fatalf("ParseLevel(%q): %v", test.s, err)
+		}
+		if got != test.want {
+			t.Errorf("ParseLevel(%q): got %v, want %v", test.s, got, test.want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel(%q): unexpected success", "verbose")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// A Backend exports snapshots of the metrics of a process to a metrics
+// backend, e.g., StatsD, InfluxDB, or CloudWatch.
+type Backend interface {
+	// Export exports a batch of metric snapshots. Export is not called
+	// concurrently, and must not modify the batch.
+	Export(ctx context.Context, batch *Batch) error
+}
+
+// A Batch is a set of metric snapshots taken at the same time.
+type Batch struct {
+	// When the snapshots were taken.
+	Time time.Time
+
+	// When the metrics started being recorded. The values of counters and
+	// histograms are cumulative since Start. Backends that expect deltas,
+	// like StatsD, subtract the values of the previous batch.
+	Start time.Time
+
+	// The snapshots, one per metric and set of labels. Besides the labels of
+	// the metric, every snapshot is labeled with the app, deployment, and
+	// weavelet it belongs to.
+	Metrics []*MetricSnapshot
+}
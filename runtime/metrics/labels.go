@@ -17,10 +17,15 @@ package metrics
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
+// OtherLabelValue is the value recorded for a label whose value is not one of
+// the values declared in the label's "values" struct tag.
+const OtherLabelValue = "other"
+
 // unexport returns a copy of s with the first letter lowercased.
 func unexport(s string) string {
 	// NOTE(mwhittaker): Handling unicode complicates the implementation of
@@ -75,6 +80,16 @@ func typecheckLabels[L comparable]() error {
 			return fmt.Errorf("metric labels: type %T has duplicate field %q", x, fi.Name)
 		}
 		names[name] = struct{}{}
+
+		// Check the declared values, if any.
+		if values, ok := fi.Tag.Lookup("values"); ok {
+			if fi.Type.Kind() != reflect.String {
+				return fmt.Errorf("metric labels: field %q of type %T declares values but is not a string", fi.Name, x)
+			}
+			if values == "" {
+				return fmt.Errorf("metric labels: field %q of type %T declares no values", fi.Name, x)
+			}
+		}
 	}
 
 	return nil
@@ -82,12 +97,14 @@ func typecheckLabels[L comparable]() error {
 
 // labelExtractor extracts labels from a label struct of type L.
 type labelExtractor[L comparable] struct {
-	fields []field
+	fields  []field
+	bounded bool // does any field declare values?
 }
 
 type field struct {
-	f    reflect.StructField // struct field
-	name string              // field name, or alias if present
+	f      reflect.StructField // struct field
+	name   string              // field name, or alias if present
+	values map[string]bool     // declared values, or nil if unbounded
 }
 
 // newLabelExtractor returns a new labelExtractor that can extract the labels
@@ -96,15 +113,42 @@ func newLabelExtractor[L comparable]() *labelExtractor[L] {
 	var x L
 	t := reflect.TypeOf(x)
 	fields := make([]field, t.NumField())
+	bounded := false
 	for i := 0; i < t.NumField(); i++ {
 		fi := t.Field(i)
+		fields[i] = field{f: fi, name: unexport(fi.Name)}
 		if alias, ok := fi.Tag.Lookup("weaver"); ok {
-			fields[i] = field{fi, alias}
-		} else {
-			fields[i] = field{fi, unexport(fi.Name)}
+			fields[i].name = alias
+		}
+		if values, ok := fi.Tag.Lookup("values"); ok {
+			fields[i].values = map[string]bool{}
+			for _, value := range strings.Split(values, ",") {
+				fields[i].values[strings.TrimSpace(value)] = true
+			}
+			bounded = true
+		}
+	}
+	return &labelExtractor[L]{fields: fields, bounded: bounded}
+}
+
+// Normalize returns a copy of the provided labels with every label value that
+// is not declared in the label's "values" struct tag replaced by
+// OtherLabelValue.
+func (l *labelExtractor[L]) Normalize(labels L) L {
+	if !l.bounded {
+		return labels
+	}
+	v := reflect.ValueOf(&labels).Elem()
+	for _, field := range l.fields {
+		if field.values == nil {
+			continue
+		}
+		fv := v.FieldByIndex(field.f.Index)
+		if !field.values[fv.String()] {
+			fv.SetString(OtherLabelValue)
 		}
 	}
-	return &labelExtractor[L]{fields}
+	return labels
 }
 
 // Extract extracts the labels from a label struct. The provided labels must be
@@ -118,6 +118,12 @@ func TestInvalidLabels(t *testing.T) {
 	type t6 = struct{ X Bool }               // unsupported type
 	type t7 = struct{ X float32 }            // unsupported type
 	type t8 = struct{ X struct{ Y string } } // unsupported type
+	type t9 = struct {                       // values on a non-string
+		X int `values:"1,2"`
+	}
+	type t10 = struct { // no values
+		X string `values:""`
+	}
 
 	type u1 t1
 	type u2 t2
@@ -127,6 +133,8 @@ func TestInvalidLabels(t *testing.T) {
 	type u6 t6
 	type u7 t7
 	type u8 t8
+	type u9 t9
+	type u10 t10
 
 	mistyped[t1](t, "not a struct")
 	mistyped[t2](t, "unexported")
@@ -136,6 +144,8 @@ func TestInvalidLabels(t *testing.T) {
 	mistyped[t6](t, "unsupported type")
 	mistyped[t7](t, "unsupported type")
 	mistyped[t8](t, "unsupported type")
+	mistyped[t9](t, "not a string")
+	mistyped[t10](t, "no values")
 
 	mistyped[u1](t, "not a struct")
 	mistyped[u2](t, "unexported")
@@ -145,6 +155,8 @@ func TestInvalidLabels(t *testing.T) {
 	mistyped[u6](t, "unsupported type")
 	mistyped[u7](t, "unsupported type")
 	mistyped[u8](t, "unsupported type")
+	mistyped[u9](t, "not a string")
+	mistyped[u10](t, "no values")
 }
 
 func TestLabelExtractor(t *testing.T) {
@@ -162,3 +174,22 @@ func TestLabelExtractor(t *testing.T) {
 		t.Fatalf("bad label extraction (-want +got)\n%s", diff)
 	}
 }
+
+func TestLabelNormalize(t *testing.T) {
+	type labels struct {
+		Status   string `values:"ok, error"`
+		Currency string
+	}
+	welltyped[labels](t)
+	extractor := newLabelExtractor[labels]()
+	for _, test := range []struct{ in, want labels }{
+		{labels{"ok", "USD"}, labels{"ok", "USD"}},
+		{labels{"error", "EUR"}, labels{"error", "EUR"}},
+		{labels{"teapot", "JPY"}, labels{OtherLabelValue, "JPY"}},
+		{labels{"", "JPY"}, labels{OtherLabelValue, "JPY"}},
+	} {
+		if got := extractor.Normalize(test.in); got != test.want {
+			t.Errorf("Normalize(%v): got %v, want %v", test.in, got, test.want)
+		}
+	}
+}
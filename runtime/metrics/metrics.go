@@ -31,9 +31,11 @@ import (
 )
 
 var (
-	// metricNames stores the name of every metric (labeled or not).
+	// metricNames stores the name of every metric (labeled or not), and
+	// metricDefs its definition.
 	metricNamesMu sync.RWMutex
 	metricNames   = map[string]bool{}
+	metricDefs    = []Def{}
 
 	// metrics stores every metric.
 	metricsMu sync.RWMutex
@@ -321,9 +323,15 @@ func RegisterMap[L comparable](typ protos.MetricType, name string, help string,
 		panic(fmt.Errorf("metric %q already exists", name))
 	}
 	metricNames[name] = true
+	extractor := newLabelExtractor[L]()
+	def := Def{Type: typ, Name: name, Help: help, Bounds: bounds}
+	for _, field := range extractor.fields {
+		def.Labels = append(def.Labels, field.name)
+	}
+	metricDefs = append(metricDefs, def)
 	return &MetricMap[L]{
 		config:    config{Type: typ, Name: name, Help: help, Bounds: bounds},
-		extractor: newLabelExtractor[L](),
+		extractor: extractor,
 		metrics:   map[L]*Metric{},
 	}
 }
@@ -335,8 +343,10 @@ func (mm *MetricMap[L]) Name() string {
 
 // Get returns the metric with the provided labels, constructing it if it
 // doesn't already exist. Multiple calls to Get with the same labels will
-// return the same metric.
+// return the same metric. Label values that are not declared in the label's
+// "values" struct tag are recorded as OtherLabelValue.
 func (mm *MetricMap[L]) Get(labels L) *Metric {
+	labels = mm.extractor.Normalize(labels)
 	mm.mu.Lock()
 	defer mm.mu.Unlock()
 	if metric, ok := mm.metrics[labels]; ok {
@@ -351,6 +361,26 @@ func (mm *MetricMap[L]) Get(labels L) *Metric {
 	return metric
 }
 
+// Def is the definition of a registered metric, labeled or not. Unlike
+// Snapshot, which only returns the metrics of the labels used so far, Defs
+// returns the definitions of every registered metric.
+type Def struct {
+	Type   protos.MetricType
+	Name   string
+	Help   string
+	Bounds []float64 // histogram bounds
+	Labels []string  // label names, in declaration order
+}
+
+// Defs returns the definitions of all registered metrics, sorted by name.
+func Defs() []Def {
+	metricNamesMu.RLock()
+	defer metricNamesMu.RUnlock()
+	defs := slices.Clone(metricDefs)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
 // Snapshot returns a snapshot of all currently registered metrics. The
 // snapshot is not guaranteed to be atomic.
 func Snapshot() []*MetricSnapshot {
@@ -37,6 +37,7 @@ const (
 // clear clears all registered metrics.
 func clear() {
 	metricNames = map[string]bool{}
+	metricDefs = []Def{}
 	metrics = []*Metric{}
 }
 
@@ -110,6 +111,28 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestDefs(t *testing.T) {
+	clear()
+	type labels struct {
+		Method string
+		Caller string `weaver:"caller_component"`
+	}
+	Register(gaugeType, "TestDefs/gauge", "A gauge", nil)
+	bounds := []float64{1, 10}
+	RegisterMap[labels](histogramType, "TestDefs/histogram", "A histogram", bounds)
+	RegisterMap[labels](counterType, "TestDefs/counter", "A counter", nil)
+
+	// Labeled metrics are defined before they are used.
+	want := []Def{
+		{Type: counterType, Name: "TestDefs/counter", Help: "A counter", Labels: []string{"method", "caller_component"}},
+		{Type: gaugeType, Name: "TestDefs/gauge", Help: "A gauge"},
+		{Type: histogramType, Name: "TestDefs/histogram", Help: "A histogram", Bounds: bounds, Labels: []string{"method", "caller_component"}},
+	}
+	if diff := cmp.Diff(want, Defs()); diff != "" {
+		t.Fatalf("Defs (-want +got):\n%s", diff)
+	}
+}
+
 func TestSnapshot(t *testing.T) {
 	clear()
 
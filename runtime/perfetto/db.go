@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -30,9 +31,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/ServiceWeaver/weaver/internal/files"
 	"github.com/ServiceWeaver/weaver/internal/traceio"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
@@ -83,14 +86,17 @@ type replicaCacheKey struct {
 // [1] https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU/preview#
 // [2] https://ui.perfetto.dev/
 type DB struct {
-	// Trace data is stored in a sqlite DB spread across three tables:
+	// Trace data is stored in a sqlite DB spread across four tables:
 	// (1) traces:           trace data in a Perfetto-UI-compattible JSON format
-	// (2) replica_num:      map from colocation group replica id to a replica
+	// (2) spans:            one row per span, used to search traces (see
+	//                       QueryTraces)
+	// (3) replica_num:      map from colocation group replica id to a replica
 	//                       number
-	// (3) next_replica_num: the next replica number to use for a given
+	// (4) next_replica_num: the next replica number to use for a given
 	//                       colocation group
 	fname string
 	db    *sql.DB
+	key   *atrest.Key // if not nil, encrypts the stored trace events
 
 	// Cache of replica numbers.
 	replicaNumCache *lru.Cache[replicaCacheKey, int]
@@ -98,13 +104,28 @@ type DB struct {
 
 // Open opens the default trace database on the local machine.
 func Open(ctx context.Context) (*DB, error) {
+	return OpenEncrypted(ctx, nil)
+}
+
+// OpenEncrypted opens the default trace database on the local machine. The
+// trace events stored through the returned DB are encrypted with the
+// provided key, and the spans indexed to search traces omit their status
+// descriptions, which may hold application data. If key is nil, nothing is
+// encrypted. Encrypted events are decrypted transparently when they are
+// fetched.
+func OpenEncrypted(ctx context.Context, key *atrest.Key) (*DB, error) {
 	dataDir, err := files.DefaultDataDir()
 	if err != nil {
 		return nil, err
 	}
 
 	fname := filepath.Join(dataDir, "perfetto.db")
-	return open(ctx, fname)
+	db, err := open(ctx, fname)
+	if err != nil {
+		return nil, err
+	}
+	db.key = key
+	return db, nil
 }
 
 func open(ctx context.Context, fname string) (*DB, error) {
@@ -140,6 +161,28 @@ CREATE TABLE IF NOT EXISTS traces (
 	events TEXT NOT NULL
 );
 
+-- Spans, used to search traces. component and method are the two halves of
+-- the span name, split at its last dot, e.g., "cart.T" and "AddItem".
+CREATE TABLE IF NOT EXISTS spans (
+	app TEXT NOT NULL,
+	version TEXT NOT NULL,
+	trace_id TEXT NOT NULL,
+	span_id TEXT NOT NULL,
+	parent_span_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	component TEXT NOT NULL,
+	method TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	cgroup TEXT NOT NULL,
+	replica INTEGER NOT NULL,
+	start_micros INTEGER NOT NULL,
+	duration_micros INTEGER NOT NULL,
+	error INTEGER NOT NULL,
+	status TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS spans_by_trace ON spans(app, version, trace_id);
+CREATE INDEX IF NOT EXISTS spans_by_duration ON spans(app, version, duration_micros);
+
 -- Map from a group replica id to a replica number.
 CREATE TABLE IF NOT EXISTS replica_num (
 	app TEXT NOT NULL,
@@ -177,16 +220,69 @@ func (d *DB) Store(ctx context.Context, app, version string, spans []sdktrace.Re
 	if err != nil {
 		return err
 	}
-	return d.storeEncoded(ctx, app, version, encoded)
+	rows := make([]Span, len(spans))
+	for i, span := range spans {
+		if rows[i], err = d.makeSpan(ctx, app, version, span); err != nil {
+			return err
+		}
+	}
+	// Keep retrying as long as we are getting the "locked" error.
+	for r := retry.Begin(); r.Continue(ctx); {
+		err := d.store(ctx, app, version, encoded, rows)
+		if isLocked(err) {
+			continue
+		}
+		return err
+	}
+	return ctx.Err()
 }
 
-func (d *DB) storeEncoded(ctx context.Context, app, version string, encoded []byte) error {
-	const stmt = `
+// store stores the provided encoded events and spans in a single
+// transaction.
+func (d *DB) store(ctx context.Context, app, version string, encoded []byte, spans []Span) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // supplanted by errors below
+
+	const insertEvents = `
 		INSERT INTO traces(app, version, events)
 		VALUES (?,?,?);
 	`
-	_, err := d.execDB(ctx, stmt, app, version, string(encoded))
-	return err
+	events := string(encoded)
+	if d.key != nil {
+		events = encryptedEventsPrefix + base64.StdEncoding.EncodeToString(atrest.Encrypt(d.key, encoded))
+	}
+	if _, err := tx.ExecContext(ctx, insertEvents, app, version, events); err != nil {
+		return err
+	}
+
+	const insertSpan = `
+		INSERT INTO spans(app, version, trace_id, span_id, parent_span_id, name,
+			component, method, kind, cgroup, replica, start_micros,
+			duration_micros, error, status)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);
+	`
+	stmt, err := tx.PrepareContext(ctx, insertSpan)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, s := range spans {
+		component, method := splitSpanName(s.Name)
+		status := s.Status
+		if d.key != nil {
+			status = ""
+		}
+		if _, err := stmt.ExecContext(ctx, app, version, s.TraceID, s.SpanID,
+			s.ParentSpanID, s.Name, component, method, s.Kind, s.Group,
+			s.Replica, s.Start.UnixMicro(), s.Duration.Microseconds(), s.Error,
+			status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (d *DB) encodeSpans(ctx context.Context, app, version string, spans []sdktrace.ReadOnlySpan) ([]byte, error) {
@@ -213,19 +309,7 @@ func (d *DB) encodeSpan(ctx context.Context, app, version string, span sdktrace.
 	}
 
 	// Extract information from the span attributes.
-	var pid int
-	var colocGroup string
-	var colocGroupReplicaID string
-	for _, a := range span.Resource().Attributes() {
-		switch a.Key {
-		case semconv.ProcessPIDKey:
-			pid = int(a.Value.AsInt64())
-		case traceio.ColocationGroupNameTraceKey:
-			colocGroup = a.Value.AsString()
-		case traceio.GroupReplicaIDTraceKey:
-			colocGroupReplicaID = a.Value.AsString()
-		}
-	}
+	pid, colocGroup, colocGroupReplicaID := spanProcess(span)
 
 	// Get replica number that corresponds to the colocation group replica
 	// that generated the span. We do this to avoid displaying long random
@@ -326,6 +410,22 @@ func (d *DB) encodeSpan(ctx context.Context, app, version string, span sdktrace.
 	return nil
 }
 
+// spanProcess returns the pid, colocation group, and colocation group replica
+// id of the process that generated the provided span.
+func spanProcess(span sdktrace.ReadOnlySpan) (pid int, colocGroup, colocGroupReplicaID string) {
+	for _, a := range span.Resource().Attributes() {
+		switch a.Key {
+		case semconv.ProcessPIDKey:
+			pid = int(a.Value.AsInt64())
+		case traceio.ColocationGroupNameTraceKey:
+			colocGroup = a.Value.AsString()
+		case traceio.GroupReplicaIDTraceKey:
+			colocGroupReplicaID = a.Value.AsString()
+		}
+	}
+	return pid, colocGroup, colocGroupReplicaID
+}
+
 // getReplicaNumber returns a replica number associated with the given
 // colocation group replica. If no such number exists, a new number will be
 // associated. The returned replica number is guaranteed to come from a dense
@@ -430,10 +530,16 @@ func (d *DB) getNextReplicaNumber(ctx context.Context, tx *sql.Tx, app, version,
 	return replicaNum, nil
 }
 
-// fetch returns all trace events for the given application version.
+// encryptedEventsPrefix prefixes the base64 encoding of encrypted trace
+// events in the traces table. Plaintext events are JSON, and never start
+// with it.
+const encryptedEventsPrefix = "encrypted:"
+
+// fetch returns all trace events for the given application version,
+// decrypting the encrypted ones.
 func (d *DB) fetch(ctx context.Context, app, version string) ([]byte, error) {
 	const query = `
-		SELECT GROUP_CONCAT(events, ',')
+		SELECT events
 		FROM traces
 		WHERE
 		(app=? OR ?="") AND (version=? OR ?="");
@@ -444,12 +550,102 @@ func (d *DB) fetch(ctx context.Context, app, version string) ([]byte, error) {
 	}
 	defer rows.Close()
 	var traces []byte
-	if rows.Next() {
-		if err := rows.Scan(&traces); err != nil {
+	for rows.Next() {
+		var events string
+		if err := rows.Scan(&events); err != nil {
 			return nil, err
 		}
+		decrypted, err := decryptEvents(ctx, events)
+		if err != nil {
+			return nil, err
+		}
+		if len(traces) > 0 {
+			traces = append(traces, ',')
+		}
+		traces = append(traces, decrypted...)
+	}
+	return traces, rows.Err()
+}
+
+// Deployment summarizes the traces of a deployment stored in a DB.
+type Deployment struct {
+	App     string
+	Version string    // deployment id
+	Latest  time.Time // end time of the latest span
+	Bytes   int64     // approximate size of the traces
+}
+
+// Deployments returns a summary of the traces of every deployment stored in
+// the database.
+func (d *DB) Deployments(ctx context.Context) ([]Deployment, error) {
+	// The size of the traces of a deployment is approximated by the size of
+	// their encoded events, which dwarfs the size of the rows that index
+	// them.
+	const query = `
+		SELECT t.app, t.version, t.bytes, IFNULL(s.latest, 0)
+		FROM (
+			SELECT app, version, SUM(LENGTH(events)) AS bytes
+			FROM traces
+			GROUP BY app, version
+		) AS t LEFT JOIN (
+			SELECT app, version, MAX(start_micros + duration_micros) AS latest
+			FROM spans
+			GROUP BY app, version
+		) AS s ON t.app = s.app AND t.version = s.version
+		ORDER BY t.app, t.version;
+	`
+	rows, err := d.queryDB(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	defer rows.Close()
+	var deps []Deployment
+	for rows.Next() {
+		var dep Deployment
+		var latest int64
+		if err := rows.Scan(&dep.App, &dep.Version, &dep.Bytes, &latest); err != nil {
+			return nil, fmt.Errorf("list deployments: %w", err)
+		}
+		dep.Latest = time.UnixMicro(latest)
+		deps = append(deps, dep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list deployments: %w", err)
+	}
+	return deps, nil
+}
+
+// DeleteDeployment deletes the traces of the provided deployment, and
+// returns the freed space to the file system.
+func (d *DB) DeleteDeployment(ctx context.Context, app, version string) error {
+	for _, table := range []string{"traces", "spans", "replica_num", "next_replica_num"} {
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE app = ? AND version = ?;", table)
+		if _, err := d.execDB(ctx, stmt, app, version); err != nil {
+			return fmt.Errorf("delete deployment %s: %w", version, err)
+		}
+	}
+	for _, key := range d.replicaNumCache.Keys() {
+		if key.app == app && key.version == version {
+			d.replicaNumCache.Remove(key)
+		}
+	}
+	if _, err := d.execDB(ctx, "VACUUM;"); err != nil {
+		return fmt.Errorf("delete deployment %s: vacuum: %w", version, err)
+	}
+	return nil
+}
+
+// decryptEvents decrypts trace events stored in the traces table, if they
+// are encrypted.
+func decryptEvents(ctx context.Context, events string) ([]byte, error) {
+	if !strings.HasPrefix(events, encryptedEventsPrefix) {
+		return []byte(events), nil
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(events, encryptedEventsPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("decode encrypted trace events: %w", err)
 	}
-	return traces, nil
+	return atrest.Decrypt(ctx, encrypted)
 }
 
 func (d *DB) queryDB(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
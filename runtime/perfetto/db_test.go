@@ -15,11 +15,15 @@
 package perfetto
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/atrest"
 	"github.com/google/go-cmp/cmp"
 	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -123,6 +127,103 @@ func TestStoreFetch(t *testing.T) {
 	}
 }
 
+func TestDeleteDeployment(t *testing.T) {
+	// Test Plan: insert the spans of a few application versions into the
+	// database, and delete one of the versions. Validate that the deleted
+	// version's traces are gone, and that the other versions' traces remain.
+	ctx := context.Background()
+	fname := filepath.Join(t.TempDir(), "tracedb.db_test.db")
+	db, err := open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	storeSpans(ctx, t, db, "app1", "v1", makeSpan("s1", time.Minute, 1, "cg1"))
+	storeSpans(ctx, t, db, "app1", "v2", makeSpan("s2", time.Second, 2, "cg1"))
+
+	deps, err := db.Deployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("Deployments: got %v, want two deployments", deps)
+	}
+	for _, dep := range deps {
+		if dep.Bytes <= 0 {
+			t.Errorf("Deployments: %s has %d bytes, want more", dep.Version, dep.Bytes)
+		}
+	}
+	if got, want := deps[0].Latest.Truncate(time.Microsecond), now.Add(time.Minute).Truncate(time.Microsecond); !got.Equal(want) {
+		t.Errorf("Deployments: latest span of v1 ended at %v, want %v", got, want)
+	}
+
+	if err := db.DeleteDeployment(ctx, "app1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	deps, err = db.Deployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || deps[0].Version != "v2" {
+		t.Fatalf("Deployments after delete: got %v, want v2", deps)
+	}
+	if got, err := db.fetch(ctx, "app1", "v1"); err != nil || len(got) != 0 {
+		t.Fatalf("fetch(v1): got %q, %v, want no traces", got, err)
+	}
+	var spans int
+	if err := db.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM spans WHERE version = 'v1'").Scan(&spans); err != nil {
+		t.Fatal(err)
+	}
+	if spans != 0 {
+		t.Fatalf("got %d spans of v1, want 0", spans)
+	}
+}
+
+func TestStoreFetchEncrypted(t *testing.T) {
+	// Test Plan: insert encrypted and plaintext spans into the database.
+	// Validate that the encrypted events are not stored in plaintext, and
+	// that fetching them decrypts them.
+	ctx := context.Background()
+	t.Setenv("PERFETTO_TEST_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32)))
+	key, err := atrest.Resolve(ctx, "${secret:env:PERFETTO_TEST_KEY}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fname := filepath.Join(t.TempDir(), "tracedb.db_test.db")
+	db, err := open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s1 := makeSpan("s1", time.Minute, 1, "cg1")
+	s2 := makeSpan("s2", time.Second, 2, "cg1")
+	storeSpans(ctx, t, db, "app", "v1", s1)
+	db.key = key
+	storeSpans(ctx, t, db, "app", "v1", s2)
+
+	var events string
+	if err := db.db.QueryRowContext(ctx, `SELECT events FROM traces WHERE rowid=2`).Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(events, encryptedEventsPrefix) {
+		t.Fatalf("events stored in plaintext: %s", events)
+	}
+
+	want, err := db.encodeSpans(ctx, "app", "v1", []sdktrace.ReadOnlySpan{s1, s2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.fetch(ctx, "app", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+}
+
 func TestReplicaNum(t *testing.T) {
 	// Test Plan: Retrieve replica numbers for a number of different
 	// application versions and their colocation groups. Ensure that different
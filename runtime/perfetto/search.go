@@ -0,0 +1,344 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfetto
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultTraceLimit is the default value of TraceQuery.Limit.
+const DefaultTraceLimit = 100
+
+// LatencyBounds are the upper bounds of the buckets of the histograms of
+// MethodLatency. The last bucket, of spans slower than the last bound, is
+// unbounded.
+var LatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// A Span is a span stored in the database.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string // all zeroes for the root span of a trace
+	Name         string // e.g., "cart.T.AddItem"
+	Kind         string // e.g., "server", "client", or "internal"
+	Group        string // colocation group of the process that ended the span
+	Replica      int    // replica number of the process in its group
+	Start        time.Time
+	Duration     time.Duration
+	Error        bool   // did the span end with an error status?
+	Status       string // description of the span's status, if any
+}
+
+// A TraceQuery filters the traces returned by DB.QueryTraces. A trace
+// matches if any of its spans matches every filter.
+type TraceQuery struct {
+	// Application and version of the traces. An empty version matches every
+	// version of the application, and an empty application every
+	// application.
+	App     string
+	Version string
+
+	// If not empty, case-insensitive substrings of the component and the
+	// method of a matching span. The name of the span of a component method
+	// call is the shortened component name, followed by a dot and the method
+	// name, e.g., "cart.T.AddItem". The component of other spans is the part
+	// of their name before the last dot, if any, and their method the rest.
+	Component string
+	Method    string
+
+	// If positive, matching spans last at least MinDuration.
+	MinDuration time.Duration
+
+	// If true, matching spans ended with an error.
+	Errors bool
+
+	// The maximum number of traces to return. Defaults to DefaultTraceLimit.
+	Limit int
+}
+
+// A TraceSummary summarizes a trace returned by DB.QueryTraces.
+type TraceSummary struct {
+	TraceID  string
+	Root     Span          // the root span, or the earliest span if unknown
+	Match    Span          // the slowest matching span
+	Duration time.Duration // from the start of the first span to the end of the last
+	Spans    int           // number of spans
+	Errors   int           // number of spans that ended with an error
+}
+
+// A MethodLatency is a histogram of the latencies of a method, as measured
+// by the spans of its calls. Client spans are not counted, so that a remote
+// call is only counted once, by its server span.
+type MethodLatency struct {
+	Component string
+	Method    string
+	Calls     int
+	Errors    int
+	Counts    []int // number of calls in every bucket; see LatencyBounds
+	P50       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// makeSpan returns the row of the provided span, generated by the provided
+// application version.
+func (d *DB) makeSpan(ctx context.Context, app, version string, span sdktrace.ReadOnlySpan) (Span, error) {
+	_, colocGroup, colocGroupReplicaID := spanProcess(span)
+	replicaNum, err := d.getReplicaNumber(ctx, app, version, colocGroup, colocGroupReplicaID)
+	if err != nil {
+		return Span{}, err
+	}
+	return Span{
+		TraceID:      span.SpanContext().TraceID().String(),
+		SpanID:       span.SpanContext().SpanID().String(),
+		ParentSpanID: span.Parent().SpanID().String(),
+		Name:         span.Name(),
+		Kind:         span.SpanKind().String(),
+		Group:        colocGroup,
+		Replica:      replicaNum,
+		Start:        span.StartTime(),
+		Duration:     span.EndTime().Sub(span.StartTime()),
+		Error:        span.Status().Code == codes.Error,
+		Status:       span.Status().Description,
+	}, nil
+}
+
+// splitSpanName splits a span name into a component and a method, at its last
+// dot. See TraceQuery.
+func splitSpanName(name string) (component, method string) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// likeSubstring returns a LIKE pattern, with escape character '\', that
+// matches the strings that contain s.
+func likeSubstring(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
+
+// QueryTraces returns the traces that match the provided query, slowest
+// matching span first.
+func (d *DB) QueryTraces(ctx context.Context, q TraceQuery) ([]TraceSummary, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultTraceLimit
+	}
+	const query = `
+		SELECT trace_id, MAX(duration_micros) AS slowest
+		FROM spans
+		WHERE
+			(app=? OR ?='') AND (version=? OR ?='') AND
+			component LIKE ? ESCAPE '\' AND method LIKE ? ESCAPE '\' AND
+			duration_micros >= ? AND (error=1 OR ?=0)
+		GROUP BY trace_id
+		ORDER BY slowest DESC
+		LIMIT ?;
+	`
+	rows, err := d.queryDB(ctx, query, q.App, q.App, q.Version, q.Version,
+		likeSubstring(q.Component), likeSubstring(q.Method),
+		q.MinDuration.Microseconds(), q.Errors, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		var slowest int64
+		if err := rows.Scan(&id, &slowest); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]TraceSummary, 0, len(ids))
+	for _, id := range ids {
+		spans, err := d.TraceSpans(ctx, q.App, q.Version, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		summaries = append(summaries, summarize(id, spans, q))
+	}
+	return summaries, nil
+}
+
+// summarize returns the summary of the trace with the provided id and spans,
+// ordered by start time, that matches the provided query.
+func summarize(id string, spans []Span, q TraceQuery) TraceSummary {
+	summary := TraceSummary{TraceID: id, Root: spans[0], Spans: len(spans)}
+	ids := map[string]bool{}
+	for _, s := range spans {
+		ids[s.SpanID] = true
+	}
+	start, end := spans[0].Start, spans[0].Start
+	matched := false
+	for _, s := range spans {
+		if !ids[s.ParentSpanID] && ids[summary.Root.ParentSpanID] {
+			// Prefer the first span without a parent in the trace.
+			summary.Root = s
+		}
+		if s.Error {
+			summary.Errors++
+		}
+		if e := s.Start.Add(s.Duration); e.After(end) {
+			end = e
+		}
+		if matches(s, q) && (!matched || s.Duration > summary.Match.Duration) {
+			summary.Match = s
+			matched = true
+		}
+	}
+	summary.Duration = end.Sub(start)
+	return summary
+}
+
+// matches returns whether the provided span matches the provided query.
+func matches(s Span, q TraceQuery) bool {
+	component, method := splitSpanName(s.Name)
+	contains := func(s, substr string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+	}
+	return contains(component, q.Component) &&
+		contains(method, q.Method) &&
+		s.Duration >= q.MinDuration &&
+		(s.Error || !q.Errors)
+}
+
+// TraceSpans returns the spans of the trace with the provided id, generated
+// by the provided application version, ordered by start time. An empty
+// version or application matches every version or application.
+func (d *DB) TraceSpans(ctx context.Context, app, version, traceID string) ([]Span, error) {
+	const query = `
+		SELECT trace_id, span_id, parent_span_id, name, kind, cgroup, replica,
+			start_micros, duration_micros, error, status
+		FROM spans
+		WHERE (app=? OR ?='') AND (version=? OR ?='') AND trace_id=?
+		ORDER BY start_micros, span_id;
+	`
+	rows, err := d.queryDB(ctx, query, app, app, version, version, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var spans []Span
+	for rows.Next() {
+		var s Span
+		var start, duration int64
+		if err := rows.Scan(&s.TraceID, &s.SpanID, &s.ParentSpanID, &s.Name,
+			&s.Kind, &s.Group, &s.Replica, &start, &duration, &s.Error,
+			&s.Status); err != nil {
+			return nil, err
+		}
+		s.Start = time.UnixMicro(start)
+		s.Duration = time.Duration(duration) * time.Microsecond
+		spans = append(spans, s)
+	}
+	return spans, rows.Err()
+}
+
+// MethodLatencies returns the latency histograms of the methods called by the
+// provided application version, ordered by component and method. An empty
+// version or application matches every version or application.
+func (d *DB) MethodLatencies(ctx context.Context, app, version string) ([]MethodLatency, error) {
+	const query = `
+		SELECT component, method, duration_micros, error
+		FROM spans
+		WHERE (app=? OR ?='') AND (version=? OR ?='') AND kind!=?
+		ORDER BY component, method, duration_micros;
+	`
+	rows, err := d.queryDB(ctx, query, app, app, version, version, trace.SpanKindClient.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var latencies []MethodLatency
+	var durations []time.Duration // durations of the last method, in order
+	finish := func() {
+		if len(latencies) == 0 {
+			return
+		}
+		l := &latencies[len(latencies)-1]
+		l.P50 = durations[(len(durations)-1)/2]
+		l.P99 = durations[(len(durations)-1)*99/100]
+		l.Max = durations[len(durations)-1]
+	}
+	for rows.Next() {
+		var component, method string
+		var micros int64
+		var failed bool
+		if err := rows.Scan(&component, &method, &micros, &failed); err != nil {
+			return nil, err
+		}
+		n := len(latencies)
+		if n == 0 || latencies[n-1].Component != component || latencies[n-1].Method != method {
+			finish()
+			latencies = append(latencies, MethodLatency{
+				Component: component,
+				Method:    method,
+				Counts:    make([]int, len(LatencyBounds)+1),
+			})
+			durations = durations[:0]
+		}
+		l := &latencies[len(latencies)-1]
+		duration := time.Duration(micros) * time.Microsecond
+		l.Calls++
+		if failed {
+			l.Errors++
+		}
+		l.Counts[sort.Search(len(LatencyBounds), func(i int) bool { return duration <= LatencyBounds[i] })]++
+		durations = append(durations, duration)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return latencies, nil
+}
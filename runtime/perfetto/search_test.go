@@ -0,0 +1,222 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfetto
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// makeTraceSpan creates a test span of the provided trace, with the provided
+// parent, that starts at the provided offset from now.
+func makeTraceSpan(traceID, spanID, parentID byte, name string, kind trace.SpanKind, offset, dur time.Duration, failed bool) sdktrace.ReadOnlySpan {
+	spanContext := func(id byte) trace.SpanContext {
+		if id == 0 {
+			return trace.SpanContext{}
+		}
+		return trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{traceID},
+			SpanID:  trace.SpanID{id},
+		})
+	}
+	stub := tracetest.SpanStub{
+		Name:        name,
+		SpanContext: spanContext(spanID),
+		Parent:      spanContext(parentID),
+		SpanKind:    kind,
+		StartTime:   now.Add(offset),
+		EndTime:     now.Add(offset + dur),
+	}
+	if failed {
+		stub.Status = sdktrace.Status{Code: codes.Error, Description: "boom"}
+	}
+	return stub.Snapshot()
+}
+
+func TestQueryTraces(t *testing.T) {
+	// Test Plan: store a few traces of placeOrder calls, one of them slow and
+	// one failed, and check that queries find the right traces, slowest
+	// first.
+	ctx := context.Background()
+	fname := filepath.Join(t.TempDir(), "tracedb.search_test.db")
+	db, err := open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const server, client = trace.SpanKindServer, trace.SpanKindClient
+	storeSpans(ctx, t, db, "app", "v1",
+		// Trace 1: a fast call.
+		makeTraceSpan(1, 1, 0, "main.Main.Run", server, 0, 10*time.Millisecond, false),
+		makeTraceSpan(1, 2, 1, "shop.Checkout.PlaceOrder", client, time.Millisecond, 5*time.Millisecond, false),
+		makeTraceSpan(1, 3, 2, "shop.Checkout.PlaceOrder", server, 2*time.Millisecond, 3*time.Millisecond, false),
+		// Trace 2: a slow call.
+		makeTraceSpan(2, 1, 0, "main.Main.Run", server, 0, 2*time.Second, false),
+		makeTraceSpan(2, 2, 1, "shop.Checkout.PlaceOrder", client, time.Millisecond, 1500*time.Millisecond, false),
+		makeTraceSpan(2, 3, 2, "shop.Checkout.PlaceOrder", server, 2*time.Millisecond, 1400*time.Millisecond, false),
+		// Trace 3: a failed call.
+		makeTraceSpan(3, 1, 0, "main.Main.Run", server, 0, 20*time.Millisecond, false),
+		makeTraceSpan(3, 2, 1, "shop.Payment.Charge", server, time.Millisecond, 4*time.Millisecond, true),
+	)
+	// Another version.
+	storeSpans(ctx, t, db, "app", "v2",
+		makeTraceSpan(4, 1, 0, "shop.Checkout.PlaceOrder", server, 0, time.Hour, false))
+
+	traceID := func(id byte) string { return trace.TraceID{id}.String() }
+	for _, tc := range []struct {
+		help  string
+		query TraceQuery
+		want  []string
+	}{
+		{"all", TraceQuery{App: "app", Version: "v1"}, []string{traceID(2), traceID(3), traceID(1)}},
+		{"method", TraceQuery{App: "app", Version: "v1", Method: "placeorder"}, []string{traceID(2), traceID(1)}},
+		{"component", TraceQuery{App: "app", Version: "v1", Component: "Payment"}, []string{traceID(3)}},
+		{"min duration", TraceQuery{App: "app", Version: "v1", Method: "PlaceOrder", MinDuration: time.Second}, []string{traceID(2)}},
+		{"errors", TraceQuery{App: "app", Version: "v1", Errors: true}, []string{traceID(3)}},
+		{"limit", TraceQuery{App: "app", Version: "v1", Limit: 1}, []string{traceID(2)}},
+		{"escaped", TraceQuery{App: "app", Version: "v1", Method: "%"}, nil},
+		{"any version", TraceQuery{App: "app", Method: "PlaceOrder"}, []string{traceID(4), traceID(2), traceID(1)}},
+	} {
+		t.Run(tc.help, func(t *testing.T) {
+			summaries, err := db.QueryTraces(ctx, tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range summaries {
+				got = append(got, s.TraceID)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("QueryTraces (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Check the summary of the slow trace.
+	summaries, err := db.QueryTraces(ctx, TraceQuery{App: "app", Version: "v1", Method: "PlaceOrder", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := summaries[0]
+	if s.Root.Name != "main.Main.Run" || s.Match.Kind != "client" || s.Match.Duration != 1500*time.Millisecond || s.Spans != 3 || s.Duration != 2*time.Second {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestTraceSpans(t *testing.T) {
+	ctx := context.Background()
+	fname := filepath.Join(t.TempDir(), "tracedb.search_test.db")
+	db, err := open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	storeSpans(ctx, t, db, "app", "v1",
+		makeTraceSpan(1, 2, 1, "shop.Payment.Charge", trace.SpanKindServer, time.Millisecond, time.Millisecond, true),
+		makeTraceSpan(1, 1, 0, "main.Main.Run", trace.SpanKindServer, 0, 3*time.Millisecond, false),
+		makeTraceSpan(2, 1, 0, "main.Main.Run", trace.SpanKindServer, 0, time.Millisecond, false),
+	)
+	spans, err := db.TraceSpans(ctx, "app", "v1", trace.TraceID{1}.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.UnixMicro(now.UnixMicro())
+	want := []Span{
+		{
+			TraceID:      trace.TraceID{1}.String(),
+			SpanID:       trace.SpanID{1}.String(),
+			ParentSpanID: trace.SpanID{}.String(),
+			Name:         "main.Main.Run",
+			Kind:         "server",
+			Start:        start,
+			Duration:     3 * time.Millisecond,
+		},
+		{
+			TraceID:      trace.TraceID{1}.String(),
+			SpanID:       trace.SpanID{2}.String(),
+			ParentSpanID: trace.SpanID{1}.String(),
+			Name:         "shop.Payment.Charge",
+			Kind:         "server",
+			Start:        start.Add(time.Millisecond),
+			Duration:     time.Millisecond,
+			Error:        true,
+			Status:       "boom",
+		},
+	}
+	if diff := cmp.Diff(want, spans); diff != "" {
+		t.Fatalf("TraceSpans (-want +got):\n%s", diff)
+	}
+}
+
+func TestMethodLatencies(t *testing.T) {
+	ctx := context.Background()
+	fname := filepath.Join(t.TempDir(), "tracedb.search_test.db")
+	db, err := open(ctx, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var spans []sdktrace.ReadOnlySpan
+	for i := 1; i <= 100; i++ {
+		dur := time.Duration(i) * time.Millisecond
+		spans = append(spans,
+			makeTraceSpan(byte(i), 1, 0, "shop.Checkout.PlaceOrder", trace.SpanKindServer, 0, dur, i == 100),
+			// Client spans aren't counted.
+			makeTraceSpan(byte(i), 2, 1, "shop.Payment.Charge", trace.SpanKindClient, 0, dur, false),
+		)
+	}
+	storeSpans(ctx, t, db, "app", "v1", spans...)
+
+	latencies, err := db.MethodLatencies(ctx, "app", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latencies) != 1 {
+		t.Fatalf("MethodLatencies: got %d methods, want 1: %+v", len(latencies), latencies)
+	}
+	l := latencies[0]
+	counts := make([]int, len(LatencyBounds)+1)
+	counts[3] = 1  // ≤ 1ms
+	counts[4] = 1  // ≤ 2.5ms
+	counts[5] = 3  // ≤ 5ms
+	counts[6] = 5  // ≤ 10ms
+	counts[7] = 15 // ≤ 25ms
+	counts[8] = 25 // ≤ 50ms
+	counts[9] = 50 // ≤ 100ms
+	want := MethodLatency{
+		Component: "shop.Checkout",
+		Method:    "PlaceOrder",
+		Calls:     100,
+		Errors:    1,
+		Counts:    counts,
+		P50:       50 * time.Millisecond,
+		P99:       99 * time.Millisecond,
+		Max:       100 * time.Millisecond,
+	}
+	if diff := cmp.Diff(want, l); diff != "" {
+		t.Fatalf("MethodLatencies (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention deletes the logs and traces that deployers store on the
+// local machine for the deployments they run.
+//
+// The data of every kind is held by a Store, which lists the deployments it
+// holds data for and deletes the data of a deployment. A Janitor applies the
+// retention policies of an application's config (see
+// runtime.RetentionConfig) to a set of stores periodically, and Select picks
+// the data that "weaver purge" deletes when given filters.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/logdb"
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/perfetto"
+	"golang.org/x/exp/slog"
+)
+
+// The kinds of data held by stores.
+const (
+	Logs   = "logs"
+	Traces = "traces"
+)
+
+// Deployment is the data a Store holds for one deployment.
+type Deployment struct {
+	App      string
+	Version  string    // deployment id
+	Modified time.Time // when the data was last written
+	Bytes    int64     // approximate size of the data
+}
+
+// A Store holds data of one kind for many deployments.
+type Store interface {
+	// Kind returns the kind of the data, Logs or Traces.
+	Kind() string
+
+	// Name returns a short description of the store, e.g., "log files in
+	// /tmp/serviceweaver/logs/weaver-multi".
+	Name() string
+
+	// Deployments returns the deployments the store holds data for.
+	Deployments(ctx context.Context) ([]Deployment, error)
+
+	// Delete deletes the data of the provided deployment.
+	Delete(ctx context.Context, app, version string) error
+}
+
+// Expired returns the deployments whose data the provided policy expires at
+// time now, least recently modified first: the deployments not modified for
+// policy.MaxAge, and then the least recently modified deployments until the
+// rest take at most policy.MaxBytes. Running deployments, keyed by
+// deployment id, never expire, but their data counts towards MaxBytes.
+func Expired(deps []Deployment, policy runtime.RetentionPolicy, now time.Time, running map[string]bool) []Deployment {
+	sorted := make([]Deployment, len(deps))
+	copy(sorted, deps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Modified.Before(sorted[j].Modified)
+	})
+	var total int64
+	for _, dep := range sorted {
+		total += dep.Bytes
+	}
+
+	var expired []Deployment
+	for _, dep := range sorted {
+		if running[dep.Version] {
+			continue
+		}
+		old := policy.MaxAge > 0 && now.Sub(dep.Modified) > policy.MaxAge
+		large := policy.MaxBytes > 0 && total > policy.MaxBytes
+		if !old && !large {
+			continue
+		}
+		expired = append(expired, dep)
+		total -= dep.Bytes
+	}
+	return expired
+}
+
+// Filter picks the deployments whose data "weaver purge" deletes. Every
+// non-zero field of a Filter must match for a deployment to be picked.
+type Filter struct {
+	App       string        // application name
+	Version   string        // prefix of the deployment id
+	OlderThan time.Duration // minimum time since the data was modified
+}
+
+// IsZero returns whether the filter matches every deployment.
+func (f Filter) IsZero() bool {
+	return f == Filter{}
+}
+
+// Matches returns whether the filter matches the provided deployment at time
+// now.
+func (f Filter) Matches(dep Deployment, now time.Time) bool {
+	if f.App != "" && dep.App != f.App {
+		return false
+	}
+	if f.Version != "" && !strings.HasPrefix(dep.Version, f.Version) {
+		return false
+	}
+	if f.OlderThan > 0 && now.Sub(dep.Modified) < f.OlderThan {
+		return false
+	}
+	return true
+}
+
+// Match is the data of a deployment in a store.
+type Match struct {
+	Store      Store
+	Deployment Deployment
+}
+
+// Select returns the data in the provided stores of the deployments that
+// match the provided filter and are not running at time now.
+func Select(ctx context.Context, stores []Store, filter Filter, now time.Time, running map[string]bool) ([]Match, error) {
+	var matches []Match
+	for _, store := range stores {
+		deps, err := store.Deployments(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", store.Name(), err)
+		}
+		for _, dep := range deps {
+			if !running[dep.Version] && filter.Matches(dep, now) {
+				matches = append(matches, Match{store, dep})
+			}
+		}
+	}
+	return matches, nil
+}
+
+// A Janitor deletes the data of the deployments of an application that the
+// retention policies of the application expire.
+type Janitor struct {
+	App     string                  // the application
+	Version string                  // the deployment running the janitor
+	Config  runtime.RetentionConfig // the retention policies
+	Stores  []Store                 // the stores holding the data
+	Logger  *slog.Logger            // logs the deleted data, and errors
+
+	// Running returns the ids of the running deployments. The data of the
+	// running deployments, and of Version, is never deleted.
+	Running func(context.Context) (map[string]bool, error)
+}
+
+// Run deletes expired data every j.Config.Interval, until ctx is done.
+func (j *Janitor) Run(ctx context.Context) {
+	if !j.Config.Enabled() {
+		return
+	}
+	interval := j.Config.Interval
+	if interval <= 0 {
+		interval = runtime.DefaultRetentionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := j.Clean(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			j.Logger.Error("retention", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes the data that is expired at time now. It keeps going past
+// the stores that fail, and returns the first error.
+func (j *Janitor) Clean(ctx context.Context, now time.Time) error {
+	running, err := j.Running(ctx)
+	if err != nil {
+		return fmt.Errorf("list running deployments: %w", err)
+	}
+	if running == nil {
+		running = map[string]bool{}
+	}
+	running[j.Version] = true
+	var errs []error
+	for _, store := range j.Stores {
+		policy := j.Config.Logs
+		if store.Kind() == Traces {
+			policy = j.Config.Traces
+		}
+		if !policy.Enabled() {
+			continue
+		}
+		deps, err := store.Deployments(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", store.Name(), err))
+			continue
+		}
+		var own []Deployment
+		for _, dep := range deps {
+			if dep.App == j.App {
+				own = append(own, dep)
+			}
+		}
+		for _, dep := range Expired(own, policy, now, running) {
+			if err := store.Delete(ctx, dep.App, dep.Version); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", store.Name(), err))
+				continue
+			}
+			j.Logger.Info("Deleted expired data", "kind", store.Kind(), "store", store.Name(), "version", logging.Shorten(dep.Version), "bytes", dep.Bytes)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// LogFiles returns a store of the log files written to the provided
+// directory by a logging.FileStore.
+func LogFiles(dir string) Store {
+	return logFiles{dir}
+}
+
+type logFiles struct{ dir string }
+
+func (l logFiles) Kind() string { return Logs }
+func (l logFiles) Name() string { return "log files in " + l.dir }
+
+func (l logFiles) Deployments(context.Context) ([]Deployment, error) {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ app, version string }
+	deps := map[key]*Deployment{}
+	var order []key
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		app, version, err := logging.ParseFileName(entry.Name())
+		if err != nil {
+			continue // not a log file
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue // deleted concurrently
+		}
+		if err != nil {
+			return nil, err
+		}
+		k := key{app, version}
+		dep, ok := deps[k]
+		if !ok {
+			dep = &Deployment{App: app, Version: version}
+			deps[k] = dep
+			order = append(order, k)
+		}
+		if info.ModTime().After(dep.Modified) {
+			dep.Modified = info.ModTime()
+		}
+		dep.Bytes += info.Size()
+	}
+	result := make([]Deployment, len(order))
+	for i, k := range order {
+		result[i] = *deps[k]
+	}
+	return result, nil
+}
+
+func (l logFiles) Delete(_ context.Context, app, version string) error {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		a, v, err := logging.ParseFileName(entry.Name())
+		if err != nil || a != app || v != version {
+			continue
+		}
+		if err := os.Remove(filepath.Join(l.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogDB returns a store of the log entries indexed in the log database
+// stored in the provided file (see logdb.DB).
+func LogDB(fname string) Store {
+	return logDB{fname}
+}
+
+type logDB struct{ fname string }
+
+func (l logDB) Kind() string { return Logs }
+func (l logDB) Name() string { return "log database " + l.fname }
+
+func (l logDB) Deployments(ctx context.Context) ([]Deployment, error) {
+	if _, err := os.Stat(l.fname); os.IsNotExist(err) {
+		return nil, nil
+	}
+	db, err := logdb.Open(ctx, l.fname)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	deps, err := db.Deployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Deployment, len(deps))
+	for i, dep := range deps {
+		result[i] = Deployment{App: dep.App, Version: dep.Version, Modified: dep.Latest, Bytes: dep.Bytes}
+	}
+	return result, nil
+}
+
+func (l logDB) Delete(ctx context.Context, app, version string) error {
+	db, err := logdb.Open(ctx, l.fname)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.DeleteDeployment(ctx, app, version)
+}
+
+// TraceDB returns a store of the traces in the trace database opened by the
+// provided function (see perfetto.Open).
+func TraceDB(open func(context.Context) (*perfetto.DB, error)) Store {
+	return traceDB{open}
+}
+
+type traceDB struct {
+	open func(context.Context) (*perfetto.DB, error)
+}
+
+func (t traceDB) Kind() string { return Traces }
+func (t traceDB) Name() string { return "trace database" }
+
+func (t traceDB) Deployments(ctx context.Context) ([]Deployment, error) {
+	db, err := t.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	deps, err := db.Deployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Deployment, len(deps))
+	for i, dep := range deps {
+		result[i] = Deployment{App: dep.App, Version: dep.Version, Modified: dep.Latest, Bytes: dep.Bytes}
+	}
+	return result, nil
+}
+
+func (t traceDB) Delete(ctx context.Context, app, version string) error {
+	db, err := t.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.DeleteDeployment(ctx, app, version)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slog"
+)
+
+var now = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// dep returns a deployment of the todo app last modified the provided number
+// of hours ago.
+func dep(version string, hours int, bytes int64) Deployment {
+	return Deployment{App: "todo", Version: version, Modified: now.Add(-time.Duration(hours) * time.Hour), Bytes: bytes}
+}
+
+func versions(deps []Deployment) []string {
+	var versions []string
+	for _, d := range deps {
+		versions = append(versions, d.Version)
+	}
+	return versions
+}
+
+func TestExpired(t *testing.T) {
+	deps := []Deployment{
+		dep("v3", 1, 100),
+		dep("v1", 72, 100),
+		dep("v4", 0, 100),
+		dep("v2", 48, 100),
+	}
+	for _, test := range []struct {
+		name    string
+		policy  runtime.RetentionPolicy
+		running map[string]bool
+		want    []string
+	}{
+		{"no policy", runtime.RetentionPolicy{}, nil, nil},
+		{"max age", runtime.RetentionPolicy{MaxAge: 24 * time.Hour}, nil, []string{"v1", "v2"}},
+		{"max bytes", runtime.RetentionPolicy{MaxBytes: 250}, nil, []string{"v1", "v2"}},
+		{"max age and bytes", runtime.RetentionPolicy{MaxAge: 60 * time.Hour, MaxBytes: 300}, nil, []string{"v1"}},
+		{"both limits apply", runtime.RetentionPolicy{MaxAge: 60 * time.Hour, MaxBytes: 100}, nil, []string{"v1", "v2", "v3"}},
+		{"running", runtime.RetentionPolicy{MaxBytes: 250}, map[string]bool{"v1": true}, []string{"v2", "v3"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := versions(Expired(deps, test.policy, now, test.running))
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("Expired (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	d := Deployment{App: "todo", Version: "12345678-abcd", Modified: now.Add(-2 * time.Hour)}
+	for _, test := range []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{App: "todo"}, true},
+		{Filter{App: "chat"}, false},
+		{Filter{Version: "1234"}, true},
+		{Filter{Version: "abcd"}, false},
+		{Filter{OlderThan: time.Hour}, true},
+		{Filter{OlderThan: 3 * time.Hour}, false},
+		{Filter{App: "todo", Version: "1234", OlderThan: time.Hour}, true},
+	} {
+		if got := test.filter.Matches(d, now); got != test.want {
+			t.Errorf("%+v.Matches: got %t, want %t", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestLogFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name string, size int, modified time.Time) {
+		t.Helper()
+		fname := filepath.Join(dir, name)
+		if err := os.WriteFile(fname, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fname, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("todo.v1.w1.info.log", 10, now.Add(-3*time.Hour))
+	write("todo.v1.w2.error.log", 20, now.Add(-2*time.Hour))
+	write("todo.v2.w3.info.log", 30, now.Add(-time.Hour))
+	write("chat.v3.w4.info.log", 40, now)
+	write("README", 50, now)
+
+	store := LogFiles(dir)
+	got, err := store.Deployments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Version < got[j].Version })
+	want := []Deployment{
+		{App: "todo", Version: "v1", Modified: now.Add(-2 * time.Hour), Bytes: 30},
+		{App: "todo", Version: "v2", Modified: now.Add(-time.Hour), Bytes: 30},
+		{App: "chat", Version: "v3", Modified: now, Bytes: 40},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(time.Time.Equal)); diff != "" {
+		t.Fatalf("Deployments (-want +got):\n%s", diff)
+	}
+
+	if err := store.Delete(ctx, "todo", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if diff := cmp.Diff([]string{"README", "chat.v3.w4.info.log", "todo.v2.w3.info.log"}, names); diff != "" {
+		t.Fatalf("files after Delete (-want +got):\n%s", diff)
+	}
+}
+
+// fakeStore is an in-memory Store.
+type fakeStore struct {
+	kind string
+	deps []Deployment
+}
+
+func (f *fakeStore) Kind() string { return f.kind }
+func (f *fakeStore) Name() string { return "fake " + f.kind }
+
+func (f *fakeStore) Deployments(context.Context) ([]Deployment, error) {
+	return f.deps, nil
+}
+
+func (f *fakeStore) Delete(_ context.Context, app, version string) error {
+	var kept []Deployment
+	for _, d := range f.deps {
+		if d.App != app || d.Version != version {
+			kept = append(kept, d)
+		}
+	}
+	f.deps = kept
+	return nil
+}
+
+func TestJanitorClean(t *testing.T) {
+	// Test plan: clean stores holding the logs and traces of old
+	// deployments of two apps, and check that only the expired data of the
+	// janitor's app is deleted, with the policy of every kind of data.
+	chat := Deployment{App: "chat", Version: "v9", Modified: now.Add(-100 * time.Hour), Bytes: 100}
+	logs := &fakeStore{kind: Logs, deps: []Deployment{dep("v1", 72, 100), dep("v2", 48, 100), dep("v3", 0, 100), chat}}
+	traces := &fakeStore{kind: Traces, deps: []Deployment{dep("v1", 72, 100), dep("v2", 48, 100), dep("v3", 0, 100), chat}}
+	j := &Janitor{
+		App:     "todo",
+		Version: "v3",
+		Config: runtime.RetentionConfig{
+			Logs:   runtime.RetentionPolicy{MaxAge: 24 * time.Hour},
+			Traces: runtime.RetentionPolicy{MaxBytes: 50},
+		},
+		Stores: []Store{logs, traces},
+		Logger: slog.New(slog.NewTextHandler(os.Stderr)),
+		Running: func(context.Context) (map[string]bool, error) {
+			return map[string]bool{"v1": true}, nil
+		},
+	}
+	if err := j.Clean(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	// v1 is running, and v3 is the janitor's own deployment.
+	if diff := cmp.Diff([]string{"v1", "v3", "v9"}, versions(logs.deps)); diff != "" {
+		t.Errorf("logs (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"v1", "v3", "v9"}, versions(traces.deps)); diff != "" {
+		t.Errorf("traces (-want +got):\n%s", diff)
+	}
+}
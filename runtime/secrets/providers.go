@@ -0,0 +1,201 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// httpClient is used by the providers that fetch secrets over HTTP.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Endpoints of the Google Cloud APIs used by the gcp provider. Overridden by
+// tests.
+var (
+	gcpTokenURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpSecretsURL = "https://secretmanager.googleapis.com/v1"
+)
+
+// fromEnv returns the value of the environment variable name.
+func fromEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", name)
+	}
+	return value, nil
+}
+
+// fromFile returns the contents of the file at path, without trailing
+// newlines.
+func fromFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// fromEnvFile returns the value of a variable in an env file, referenced as
+// "path#NAME". Every line of the file has the form NAME=value, optionally
+// prefixed with "export", with the value optionally quoted. Empty lines and
+// lines starting with '#' are ignored.
+func fromEnvFile(_ context.Context, ref string) (string, error) {
+	path, name, ok := strings.Cut(ref, "#")
+	if !ok || name == "" {
+		return "", fmt.Errorf("missing variable name; want path#NAME")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(k) != name {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		return v, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("variable %s not found in %s", name, path)
+}
+
+// fromVault returns a field of a HashiCorp Vault secret, referenced as
+// "path#field". Both version 1 and version 2 key/value secrets engines are
+// supported; the path of a version 2 secret includes "data/", e.g.,
+// "secret/data/payments".
+func fromVault(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("missing field; want path#field")
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("VAULT_TOKEN not set: %w", err)
+		}
+		data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+		if err != nil {
+			return "", fmt.Errorf("VAULT_TOKEN not set: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	var reply struct {
+		Data map[string]any `json:"data"`
+	}
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	if err := getJSON(ctx, url, map[string]string{"X-Vault-Token": token}, &reply); err != nil {
+		return "", err
+	}
+	data := reply.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			// A version 2 secret, whose fields are nested.
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found", field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string", field)
+	}
+	return s, nil
+}
+
+// fromGCP returns a version of a Google Cloud Secret Manager secret,
+// referenced as "projects/p/secrets/s" or "projects/p/secrets/s/versions/v".
+// The secret is read with an access token obtained from the metadata server.
+func fromGCP(ctx context.Context, name string) (string, error) {
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 4 && parts[0] == "projects" && parts[2] == "secrets":
+		name += "/versions/latest"
+	case len(parts) == 6 && parts[0] == "projects" && parts[2] == "secrets" && parts[4] == "versions":
+	default:
+		return "", fmt.Errorf("invalid secret name; want projects/p/secrets/s[/versions/v]")
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := getJSON(ctx, gcpTokenURL, map[string]string{"Metadata-Flavor": "Google"}, &token); err != nil {
+		return "", fmt.Errorf("get access token: %w", err)
+	}
+	var reply struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	url := gcpSecretsURL + "/" + name + ":access"
+	if err := getJSON(ctx, url, map[string]string{"Authorization": "Bearer " + token.AccessToken}, &reply); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(reply.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decode payload: %w", err)
+	}
+	return string(data), nil
+}
+
+// getJSON fetches the JSON document at url, with the provided headers, and
+// decodes it into dst.
+func getJSON(ctx context.Context, url string, headers map[string]string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves references to secrets in application configs.
+//
+// The string values of a config may reference secrets kept outside of the
+// config, in the form "${secret:<provider>:<reference>}", e.g.:
+//
+//	[github.com/my/project/checkout/PaymentService]
+//	api_key = "${secret:vault:secret/data/payments#api_key}"
+//
+// Deployers resolve the references with Resolve just before they pass the
+// config to the weavelets they start, so that the secrets are never written
+// to the config that is rolled out. The supported providers are:
+//
+//   - env: "${secret:env:NAME}" is the value of the environment variable NAME.
+//   - file: "${secret:file:/path}" is the contents of the file at /path,
+//     without trailing newlines.
+//   - envfile: "${secret:envfile:/path#NAME}" is the value of NAME in the file
+//     at /path, which holds lines of the form NAME=value.
+//   - vault: "${secret:vault:path#field}" is the value of field in the
+//     HashiCorp Vault secret at path, read from the server at $VAULT_ADDR with
+//     the token in $VAULT_TOKEN, or in ~/.vault-token.
+//   - gcp: "${secret:gcp:projects/p/secrets/s}" is the latest version of the
+//     Google Cloud Secret Manager secret s of project p, read with the
+//     credentials of the machine's service account. A specific version is
+//     referenced as "projects/p/secrets/s/versions/v".
+//
+// Secrets are resolved in the environment of the deployer process that
+// resolves them, e.g., on the machines of the ssh deployer.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"google.golang.org/protobuf/proto"
+)
+
+// reference matches references to secrets.
+var reference = regexp.MustCompile(`\$\{secret:([a-z]+):([^}]+)\}`)
+
+// A provider returns the secret with the provided reference.
+type provider func(ctx context.Context, ref string) (string, error)
+
+// providers are the supported providers, by name.
+var providers = map[string]provider{
+	"env":     fromEnv,
+	"file":    fromFile,
+	"envfile": fromEnvFile,
+	"vault":   fromVault,
+	"gcp":     fromGCP,
+}
+
+// Resolve returns a copy of config in which the references to secrets in the
+// string values of the config sections, and in config.Env, are replaced with
+// the secrets they reference. config itself is not modified.
+func Resolve(ctx context.Context, config *protos.AppConfig) (*protos.AppConfig, error) {
+	resolved := proto.Clone(config).(*protos.AppConfig)
+	r := resolver{ctx: ctx, secrets: map[string]string{}}
+	for key, section := range resolved.Sections {
+		s, err := r.section(section)
+		if err != nil {
+			return nil, fmt.Errorf("section %q: %w", key, err)
+		}
+		resolved.Sections[key] = s
+	}
+	for i, env := range resolved.Env {
+		s, err := r.replace(env)
+		if err != nil {
+			return nil, fmt.Errorf("env: %w", err)
+		}
+		resolved.Env[i] = s
+	}
+	return resolved, nil
+}
+
+// IsReference returns whether s consists of exactly one reference to a
+// secret, e.g., "${secret:file:/etc/weaver/data.key}".
+func IsReference(s string) bool {
+	loc := reference.FindStringIndex(s)
+	return loc != nil && loc[0] == 0 && loc[1] == len(s)
+}
+
+// ResolveReference returns the secret referenced by ref, which must consist
+// of exactly one reference to a secret.
+func ResolveReference(ctx context.Context, ref string) (string, error) {
+	if !IsReference(ref) {
+		return "", fmt.Errorf("%q is not a reference to a secret", ref)
+	}
+	r := resolver{ctx: ctx, secrets: map[string]string{}}
+	return r.replace(ref)
+}
+
+// resolver resolves the references in a config.
+type resolver struct {
+	ctx     context.Context
+	secrets map[string]string // secrets resolved so far, by reference
+}
+
+// section resolves the references in a config section.
+func (r *resolver) section(section string) (string, error) {
+	if !reference.MatchString(section) {
+		return section, nil
+	}
+
+	// Rather than substitute the secrets in the TOML text, where they would
+	// have to be escaped, substitute them in the decoded values.
+	var values map[string]any
+	if _, err := toml.Decode(section, &values); err != nil {
+		return "", err
+	}
+	resolved, err := r.value(values)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(resolved); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// value resolves the references in a decoded TOML value.
+func (r *resolver) value(v any) (any, error) {
+	switch x := v.(type) {
+	case string:
+		return r.replace(x)
+	case map[string]any:
+		for k, e := range x {
+			resolved, err := r.value(e)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = resolved
+		}
+		return x, nil
+	case []any:
+		for i, e := range x {
+			resolved, err := r.value(e)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = resolved
+		}
+		return x, nil
+	case []map[string]any:
+		for _, e := range x {
+			if _, err := r.value(e); err != nil {
+				return nil, err
+			}
+		}
+		return x, nil
+	default:
+		return v, nil
+	}
+}
+
+// replace replaces the references in s with the secrets they reference.
+func (r *resolver) replace(s string) (string, error) {
+	var err error
+	replaced := reference.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return ""
+		}
+		if secret, ok := r.secrets[match]; ok {
+			return secret
+		}
+		m := reference.FindStringSubmatch(match)
+		name, ref := m[1], m[2]
+		p, ok := providers[name]
+		if !ok {
+			err = fmt.Errorf("%s: unknown provider %q", match, name)
+			return ""
+		}
+		var secret string
+		secret, err = p(r.ctx, ref)
+		if err != nil {
+			// Errors never include the secrets themselves.
+			err = fmt.Errorf("%s: %w", match, err)
+			return ""
+		}
+		r.secrets[match] = secret
+		return secret
+	})
+	if err != nil {
+		return "", err
+	}
+	return replaced, nil
+}
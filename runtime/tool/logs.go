@@ -26,7 +26,9 @@ import (
 	"time"
 
 	"github.com/ServiceWeaver/weaver/runtime/colors"
+	"github.com/ServiceWeaver/weaver/runtime/logdb"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
 )
 
 // LogSpec configures the command returned by LogsCmd.
@@ -35,6 +37,7 @@ type LogsSpec struct {
 	Flags   *flag.FlagSet                                 // optional additional flags
 	Rewrite func(logging.Query) (logging.Query, error)    // optional query preprocessing
 	Source  func(context.Context) (logging.Source, error) // returns log source
+	DB      func(context.Context) (*logdb.DB, error)      // optional log database, enables "logs query"
 
 	// Flags.
 	follow bool
@@ -212,10 +215,50 @@ Query Reference:
   exception. An attribute expression like attrs["foo"] has an implicit
   membership test "foo" in attrs.
 
-  [1]: https://opensource.google/projects/cel`
+  [1]: https://opensource.google/projects/cel{{if .Query}}
+
+Indexed Queries:
+  {{.Tool}} logs query [flags] [where]
+
+  Queries the database that indexes every log entry. Indexed queries are much
+  faster than CEL queries over many logs, but they cannot follow the logs. The
+  latest matching entries are shown, oldest first.
+
+  Flags:
+{{.QueryFlags}}
+
+  The optional where argument is a SQL boolean expression over the columns of
+  the logs table, which can also refer to the attrs table:
+
+      logs(id, app, version, component, short_component, node, time, level,
+           severity, file, line, msg)
+      attrs(log, key, value)
+
+  The time column holds microseconds since the Unix epoch, and the severity
+  column holds the numeric level: -4 for debug, 0 for info, 4 for warn, and 8
+  for error. The where argument cannot modify the database.
+
+  Examples:
+    # Display the warnings and errors of the store.Store component.
+    {{.Tool}} logs query --component=store.Store --level=warn
+
+    # Display the logs of the last 10 minutes that mention "timeout".
+    {{.Tool}} logs query --since=10m --text=timeout
+
+    # Display the logs with attribute "user" set to "alice".
+    {{.Tool}} logs query --attr=user=alice
+
+    # Display the logs written by lines 100 to 200 of store.go.
+    {{.Tool}} logs query "file LIKE '%/store.go' AND line BETWEEN 100 AND 200"{{end}}`
 	var b strings.Builder
 	t := template.Must(template.New(spec.Tool).Parse(help))
-	content := struct{ Tool, Flags string }{spec.Tool, FlagsHelp(spec.Flags)}
+	content := struct {
+		Tool, Flags, QueryFlags string
+		Query                   bool
+	}{spec.Tool, FlagsHelp(spec.Flags), "", spec.DB != nil}
+	if content.Query {
+		content.QueryFlags = "  " + strings.ReplaceAll(FlagsHelp(newQueryFlags().FlagSet), "\n", "\n  ")
+	}
 	if err := t.Execute(&b, content); err != nil {
 		panic(err)
 	}
@@ -230,6 +273,10 @@ Query Reference:
 }
 
 func (s *LogsSpec) logFn(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "query" && s.DB != nil {
+		return s.queryFn(ctx, args[1:])
+	}
+
 	// Parse command line arguments.
 	if len(args) > 1 {
 		return fmt.Errorf("too many arguments")
@@ -280,30 +327,160 @@ func (s *LogsSpec) logFn(ctx context.Context, args []string) error {
 		} else if err != nil {
 			return err
 		}
-		switch s.format {
-		case "pretty":
-			fmt.Println(pp.Format(entry))
-		case "json":
-			bytes, err := json.MarshalIndent(fullEntry{
-				App:           entry.App,
-				Version:       logging.Shorten(entry.Version),
-				FullVersion:   entry.Version,
-				Component:     logging.ShortenComponent(entry.Component),
-				FullComponent: entry.Component,
-				Node:          logging.Shorten(entry.Node),
-				FullNode:      entry.Node,
-				Time:          time.UnixMicro(entry.TimeMicros).Format(time.RFC3339Nano),
-				Level:         entry.Level,
-				File:          entry.File,
-				Line:          entry.Line,
-				Msg:           entry.Msg,
-			}, "", "    ")
-			if err != nil {
-				return err
-			}
-			fmt.Println(string(bytes))
-		default:
-			panic(fmt.Sprintf("unexpected format %q", s.format))
+		if err := printEntry(pp, s.format, entry); err != nil {
+			return err
+		}
+	}
+}
+
+// queryFlags holds the flags of the "logs query" command.
+type queryFlags struct {
+	*flag.FlagSet
+	app, version, component, node string
+	level, since, until, text     string
+	attrs                         attrsFlag
+	limit                         int
+	format                        string
+	system                        bool
+}
+
+// newQueryFlags returns the flags of the "logs query" command.
+func newQueryFlags() *queryFlags {
+	f := &queryFlags{FlagSet: flag.NewFlagSet("query", flag.ContinueOnError)}
+	f.StringVar(&f.app, "app", "", "Only show the logs of this application")
+	f.StringVar(&f.version, "version", "", "Only show the logs of this (abbreviated) version")
+	f.StringVar(&f.component, "component", "", "Only show the logs of this (abbreviated) component")
+	f.StringVar(&f.node, "node", "", "Only show the logs of this (abbreviated) node")
+	f.StringVar(&f.level, "level", "", "Only show the logs at this level or more severe")
+	f.StringVar(&f.since, "since", "", "Only show the logs since this RFC 3339 time, or duration ago")
+	f.StringVar(&f.until, "until", "", "Only show the logs before this RFC 3339 time, or duration ago")
+	f.Var(&f.attrs, "attr", "Only show the logs with this key=value attribute (repeatable)")
+	f.StringVar(&f.text, "text", "", "Only show the logs whose message matches this full text query")
+	f.IntVar(&f.limit, "limit", 1000, "Show at most this many log entries")
+	f.StringVar(&f.format, "format", "pretty", "Output format (pretty or json)")
+	f.BoolVar(&f.system, "system", false, "Show system internal logs")
+	f.Usage = func() {}
+	return f
+}
+
+// queryFn implements the "logs query" command.
+func (s *LogsSpec) queryFn(ctx context.Context, args []string) error {
+	f := newQueryFlags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("logs query: %w", err)
+	}
+	if f.NArg() > 1 {
+		return fmt.Errorf("logs query: too many arguments")
+	}
+	if f.format != "pretty" && f.format != "json" {
+		return fmt.Errorf("invalid format %q; must be %q or %q", f.format, "pretty", "json")
+	}
+	q := logdb.Query{
+		App:       f.app,
+		Version:   f.version,
+		Component: f.component,
+		Node:      f.node,
+		MinLevel:  f.level,
+		Attrs:     f.attrs.attrs,
+		Text:      f.text,
+		Where:     f.Arg(0),
+		System:    f.system,
+		Limit:     f.limit,
+	}
+	now := time.Now()
+	var err error
+	if q.Since, err = parseTime(now, f.since); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if q.Until, err = parseTime(now, f.until); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	db, err := s.DB(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	entries, err := db.Query(ctx, q)
+	if err != nil {
+		return err
+	}
+	pp := logging.NewPrettyPrinter(colors.Enabled())
+	for _, entry := range entries {
+		if err := printEntry(pp, f.format, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attrsFlag is a repeatable flag of key=value attributes.
+type attrsFlag struct {
+	attrs map[string]string
+}
+
+var _ flag.Value = &attrsFlag{}
+
+// String implements the flag.Value interface.
+func (a *attrsFlag) String() string {
+	var kvs []string
+	for k, v := range a.attrs {
+		kvs = append(kvs, k+"="+v)
+	}
+	return strings.Join(kvs, ",")
+}
+
+// Set implements the flag.Value interface.
+func (a *attrsFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("attribute %q is not of the form key=value", s)
+	}
+	if a.attrs == nil {
+		a.attrs = map[string]string{}
+	}
+	a.attrs[k] = v
+	return nil
+}
+
+// parseTime parses an RFC 3339 time, or a duration before now. It returns the
+// zero time for an empty string.
+func parseTime(now time.Time, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// printEntry prints a log entry in the provided format.
+func printEntry(pp *logging.PrettyPrinter, format string, entry *protos.LogEntry) error {
+	switch format {
+	case "pretty":
+		fmt.Println(pp.Format(entry))
+	case "json":
+		bytes, err := json.MarshalIndent(fullEntry{
+			App:           entry.App,
+			Version:       logging.Shorten(entry.Version),
+			FullVersion:   entry.Version,
+			Component:     logging.ShortenComponent(entry.Component),
+			FullComponent: entry.Component,
+			Node:          logging.Shorten(entry.Node),
+			FullNode:      entry.Node,
+			Time:          time.UnixMicro(entry.TimeMicros).Format(time.RFC3339Nano),
+			Level:         entry.Level,
+			File:          entry.File,
+			Line:          entry.Line,
+			Msg:           entry.Msg,
+		}, "", "    ")
+		if err != nil {
+			return err
 		}
+		fmt.Println(string(bytes))
+	default:
+		panic(fmt.Sprintf("unexpected format %q", format))
 	}
+	return nil
 }
@@ -24,6 +24,10 @@ import (
 	"os/exec"
 	"strings"
 	"text/template"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 )
 
 // PurgeSpec configures the command returned by PurgeCmd.
@@ -32,7 +36,16 @@ type PurgeSpec struct {
 	Kill  string   // regex of processes to kill, or empty
 	Paths []string // paths to delete
 
-	force bool // the --force flag
+	// The stores of the logs and traces of deployments, and a function that
+	// returns the ids of the running deployments. If Data is not empty, the
+	// command accepts the --app, --version, and --older_than flags, which
+	// delete the data of the matching deployments that aren't running,
+	// rather than killing processes and deleting Paths.
+	Data    []retention.Store
+	Running func(context.Context) (map[string]bool, error)
+
+	force  bool             // the --force flag
+	filter retention.Filter // the --app, --version, and --older_than flags
 }
 
 // PurgeCmd returns a command to delete a set of paths.
@@ -40,8 +53,13 @@ func PurgeCmd(spec *PurgeSpec) *Command {
 	// Create flags and help.
 	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
 	flags.BoolVar(&spec.force, "force", false, "Purge without prompt")
+	if len(spec.Data) > 0 {
+		flags.StringVar(&spec.filter.App, "app", "", "Only purge the data of this application")
+		flags.StringVar(&spec.filter.Version, "version", "", "Only purge the data of deployments with this id prefix")
+		flags.DurationVar(&spec.filter.OlderThan, "older_than", 0, "Only purge data not written to for this long (e.g., 168h)")
+	}
 	const help = `Usage:
-  {{.Tool}} purge [--force]
+  {{.Tool}} purge [--force]{{if .Data}} [--app=<app>] [--version=<id>] [--older_than=<duration>]{{end}}
 
 Flags:
   -h, --help	Print this help message.
@@ -49,10 +67,24 @@ Flags:
 
 Description:
   "{{.Tool}} purge" kills all "{{.Tool}}"-related processes and deletes any logs
-  and data produced by "{{.Tool}}".`
+  and data produced by "{{.Tool}}".{{if .Data}}
+
+  If any of --app, --version, or --older_than is given, "{{.Tool}} purge"
+  instead deletes the logs and traces of the matching deployments that aren't
+  running, and leaves processes and other data alone.
+
+Examples:
+  # Delete the logs and traces of deployments that ended over a week ago.
+  {{.Tool}} purge --older_than=168h
+
+  # Delete the logs and traces of all the old deployments of an app.
+  {{.Tool}} purge --app=collatz{{end}}`
 	var b strings.Builder
 	t := template.Must(template.New(spec.Tool).Parse(help))
-	content := struct{ Tool, Flags string }{spec.Tool, FlagsHelp(flags)}
+	content := struct {
+		Tool, Flags string
+		Data        bool
+	}{spec.Tool, FlagsHelp(flags), len(spec.Data) > 0}
 	if err := t.Execute(&b, content); err != nil {
 		panic(err)
 	}
@@ -66,7 +98,10 @@ Description:
 	}
 }
 
-func (spec *PurgeSpec) purge(context.Context, []string) error {
+func (spec *PurgeSpec) purge(ctx context.Context, _ []string) error {
+	if !spec.filter.IsZero() {
+		return spec.purgeData(ctx)
+	}
 	if !spec.force {
 		// Gather the set of processes to kill.
 		tokill := ""
@@ -105,19 +140,9 @@ immediately and irrevocably. Are you sure you want to proceed?"
 Enter (y)es to continue: `, spec.Kill, indent(tokill, 4), spec.Tool, paths.String())
 
 		// Get confirmation from the user.
-		reader := bufio.NewReader(os.Stdin)
-		text, err := reader.ReadString('\n')
-		if err != nil {
+		if ok, err := confirm(); !ok || err != nil {
 			return err
 		}
-		text = text[:len(text)-1] // strip the trailing "\n"
-		text = strings.ToLower(text)
-		if !(text == "y" || text == "yes") {
-			fmt.Println("")
-			fmt.Println("Purge aborted.")
-			return nil
-		}
-		fmt.Println("")
 	}
 
 	// Kill the processes.
@@ -141,6 +166,76 @@ Enter (y)es to continue: `, spec.Kill, indent(tokill, 4), spec.Tool, paths.Strin
 	return nil
 }
 
+// purgeData deletes the data of the deployments that match spec.filter and
+// aren't running.
+func (spec *PurgeSpec) purgeData(ctx context.Context) error {
+	if spec.Running == nil {
+		return fmt.Errorf("%s purge: filters not supported", spec.Tool)
+	}
+	running, err := spec.Running(ctx)
+	if err != nil {
+		return fmt.Errorf("list running deployments: %w", err)
+	}
+	now := time.Now()
+	matches, err := retention.Select(ctx, spec.Data, spec.filter, now, running)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Println("No matching data found.")
+		return nil
+	}
+
+	if !spec.force {
+		// Warn the user they're about to delete stuff.
+		var data strings.Builder
+		for _, m := range matches {
+			d := m.Deployment
+			fmt.Fprintf(&data, "    - %s of %s version %s (%d bytes, written %v ago)\n",
+				m.Store.Name(), d.App, logging.Shorten(d.Version), d.Bytes, now.Sub(d.Modified).Round(time.Second))
+		}
+		fmt.Printf(`WARNING: You are about to delete the following data of %q Service Weaver
+applications. This data will be deleted immediately and irrevocably. Are you
+sure you want to proceed?
+
+%s
+Enter (y)es to continue: `, spec.Tool, data.String())
+		if ok, err := confirm(); !ok || err != nil {
+			return err
+		}
+	}
+
+	for _, m := range matches {
+		d := m.Deployment
+		fmt.Printf("Deleting %s of %s version %s... ", m.Store.Name(), d.App, logging.Shorten(d.Version))
+		if err := m.Store.Delete(ctx, d.App, d.Version); err != nil {
+			fmt.Println("❌")
+			return err
+		}
+		fmt.Println("✅")
+	}
+	return nil
+}
+
+// confirm reads the user's answer to a yes/no prompt, and returns whether
+// the answer is yes.
+func confirm() (bool, error) {
+	reader := bufio.NewReader(os.Stdin)
+	text, err := reader.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	text = text[:len(text)-1] // strip the trailing "\n"
+	text = strings.ToLower(text)
+	if !(text == "y" || text == "yes") {
+		fmt.Println("")
+		fmt.Println("Purge aborted.")
+		return false, nil
+	}
+	fmt.Println("")
+	return true, nil
+}
+
 // pgrep returns the output of 'pgrep -a -f <regex>'.
 func pgrep(regex string) (string, error) {
 	// "-a" causes pgrep to output the full command line of matched processes.
@@ -0,0 +1,109 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newSampler returns the trace sampler described by the provided config.
+func newSampler(config runtime.SamplingConfig) sdktrace.Sampler {
+	ratio := config.Ratio
+	if ratio == 0 {
+		ratio = 1
+	}
+	switch config.Sampler {
+	case "always":
+		return sdktrace.AlwaysSample()
+	case "never":
+		return sdktrace.NeverSample()
+	case "ratio":
+		return sdktrace.TraceIDRatioBased(ratio)
+	case "rate_limited":
+		return sdktrace.ParentBased(newRateLimitedSampler(config.Rate, time.Now))
+	default:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	}
+}
+
+// componentTracer returns the tracer of the spans of calls to the provided
+// component.
+func (w *weavelet) componentTracer(component string) trace.Tracer {
+	if tracer, ok := w.tracers[component]; ok {
+		return tracer
+	}
+	return w.tracer
+}
+
+// rateLimitedSampler is a sampler that samples at most rate spans per second,
+// using a token bucket that holds up to max(rate, 1) tokens, so that it samples
+// some spans even if rate is less than one.
+type rateLimitedSampler struct {
+	rate float64          // spans sampled per second
+	max  float64          // capacity of the token bucket
+	now  func() time.Time // returns the current time
+
+	mu     sync.Mutex
+	tokens float64   // tokens in the bucket
+	last   time.Time // last time tokens were added to the bucket
+}
+
+var _ sdktrace.Sampler = &rateLimitedSampler{}
+
+// newRateLimitedSampler returns a new rateLimitedSampler with a full bucket.
+func newRateLimitedSampler(rate float64, now func() time.Time) *rateLimitedSampler {
+	max := math.Max(rate, 1)
+	return &rateLimitedSampler{rate: rate, max: max, now: now, tokens: max, last: now()}
+}
+
+// ShouldSample implements the sdktrace.Sampler interface.
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if s.take() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+// Description implements the sdktrace.Sampler interface.
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimited{%g}", s.rate)
+}
+
+// take takes a token from the bucket, if there is one.
+func (s *rateLimitedSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = math.Min(s.max, s.tokens+elapsed.Seconds()*s.rate)
+		s.last = now
+	}
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampled returns whether the provided sampler samples a span with the
+// provided trace id, and with a parent span if parent is not nil.
+func sampled(s sdktrace.Sampler, id trace.TraceID, parent *bool) bool {
+	ctx := context.Background()
+	if parent != nil {
+		flags := trace.TraceFlags(0)
+		if *parent {
+			flags = trace.FlagsSampled
+		}
+		ctx = trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    id,
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+		}))
+	}
+	result := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: id})
+	return result.Decision == sdktrace.RecordAndSample
+}
+
+func TestSamplers(t *testing.T) {
+	yes, no := true, false
+	// A trace id that a ratio of 0.9 samples, and a ratio of 0.1 doesn't.
+	id := trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0x60}
+	for _, test := range []struct {
+		config runtime.SamplingConfig
+		parent *bool
+		want   bool
+	}{
+		{runtime.SamplingConfig{}, nil, true},
+		{runtime.SamplingConfig{}, &no, false},
+		{runtime.SamplingConfig{Sampler: "always"}, &no, true},
+		{runtime.SamplingConfig{Sampler: "never"}, &yes, false},
+		{runtime.SamplingConfig{Sampler: "ratio", Ratio: 0.9}, &no, true},
+		{runtime.SamplingConfig{Sampler: "ratio", Ratio: 0.1}, &yes, false},
+		{runtime.SamplingConfig{Sampler: "parent_based", Ratio: 0.1}, &yes, true},
+		{runtime.SamplingConfig{Sampler: "parent_based", Ratio: 0.1}, nil, false},
+		{runtime.SamplingConfig{Sampler: "parent_based", Ratio: 0.9}, nil, true},
+		{runtime.SamplingConfig{Sampler: "rate_limited", Rate: 1}, nil, true},
+		{runtime.SamplingConfig{Sampler: "rate_limited", Rate: 1}, &no, false},
+	} {
+		if got := sampled(newSampler(test.config), id, test.parent); got != test.want {
+			t.Errorf("%+v, parent %v: got %v, want %v", test.config, test.parent, got, test.want)
+		}
+	}
+}
+
+func TestRateLimitedSampler(t *testing.T) {
+	now := time.Now()
+	s := newRateLimitedSampler(2, func() time.Time { return now })
+	count := func() int {
+		n := 0
+		for i := 0; i < 10; i++ {
+			if sampled(s, trace.TraceID{1}, nil) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The bucket starts full.
+	if got, want := count(), 2; got != want {
+		t.Fatalf("sampled %d spans, want %d", got, want)
+	}
+	if got := count(); got != 0 {
+		t.Fatalf("sampled %d spans with an empty bucket, want 0", got)
+	}
+
+	// Half a second adds a token, and ten seconds fill the bucket.
+	now = now.Add(time.Second / 2)
+	if got, want := count(), 1; got != want {
+		t.Fatalf("sampled %d spans after 500ms, want %d", got, want)
+	}
+	now = now.Add(10 * time.Second)
+	if got, want := count(), 2; got != want {
+		t.Fatalf("sampled %d spans after 10s, want %d", got, want)
+	}
+}
@@ -24,13 +24,18 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/ServiceWeaver/weaver/internal/cron"
 	"github.com/ServiceWeaver/weaver/internal/envelope/conn"
 	"github.com/ServiceWeaver/weaver/internal/files"
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/internal/listeners"
 	imetrics "github.com/ServiceWeaver/weaver/internal/metrics"
+	"github.com/ServiceWeaver/weaver/internal/pubsub"
 	"github.com/ServiceWeaver/weaver/internal/status"
 	"github.com/ServiceWeaver/weaver/internal/traceio"
 	"github.com/ServiceWeaver/weaver/runtime"
@@ -40,9 +45,13 @@ import (
 	"github.com/ServiceWeaver/weaver/runtime/metrics"
 	"github.com/ServiceWeaver/weaver/runtime/perfetto"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/retention"
 	"github.com/ServiceWeaver/weaver/runtime/retry"
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
 	"github.com/google/uuid"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
@@ -56,15 +65,70 @@ type singleprocessEnv struct {
 	submissionTime time.Time
 	statsProcessor *imetrics.StatsProcessor // tracks and computes stats to be rendered on the /statusz page.
 	traceSaver     func(spans *protos.TraceSpans) error
+	handler        conn.WeaveletHandler // handles messages delivered by broker and jobs run by scheduler
+	broker         *pubsub.Broker       // stores messages published on topics
+	scheduler      *cron.Scheduler      // runs jobs registered with Cron
+	leases         *lease.Table         // leases of leader elections
+	clock          Clock                // times jobs and leases
+	profiles       *status.ProfileStore // profiles of the continuous profiler
+	retention      runtime.RetentionConfig
+
+	// If not empty, the file in which listener addresses are recorded and
+	// from which they are reused. See the listeners package.
+	listenersFile string
 
 	mu         sync.Mutex
 	listeners  map[string][]string // listener addresses, keyed by name
 	components []string            // list of active components
+	flags      map[string]string   // feature flags set with Flags, by name
 }
 
 var _ env = &singleprocessEnv{}
 
-func newSingleprocessEnv(bootstrap runtime.Bootstrap) (*singleprocessEnv, error) {
+// applyProcessConfigs applies the provided process configs, keyed by
+// component name, to this process, which hosts every component: it sets the
+// env variables of the configs, and changes to their working directory. The
+// configs may not set a variable to different values, or set different
+// working directories. Args can't be applied to a running process, and are
+// ignored.
+func applyProcessConfigs(processes map[string]runtime.ProcessConfig) error {
+	names := maps.Keys(processes)
+	slices.Sort(names)
+	vars := map[string]string{}
+	var dir, dirName string
+	for _, name := range names {
+		p := processes[name]
+		env := map[string]string{}
+		for _, kv := range p.Env {
+			k, v, _ := strings.Cut(kv, "=")
+			env[k] = v
+		}
+		for k, v := range env {
+			if old, ok := vars[k]; ok && old != v {
+				return fmt.Errorf("process %q: env variable %s set to both %q and %q", name, k, old, v)
+			}
+			vars[k] = v
+		}
+		if p.Dir == "" {
+			continue
+		}
+		if dir != "" && dir != p.Dir {
+			return fmt.Errorf("process %q and %q: different working directories", dirName, name)
+		}
+		dir, dirName = p.Dir, name
+	}
+	for k, v := range vars {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	if dir != "" {
+		return os.Chdir(dir)
+	}
+	return nil
+}
+
+func newSingleprocessEnv(bootstrap runtime.Bootstrap, handler conn.WeaveletHandler) (*singleprocessEnv, error) {
 	ctx := context.Background()
 
 	// Get the config to use.
@@ -96,11 +160,22 @@ func newSingleprocessEnv(bootstrap runtime.Bootstrap) (*singleprocessEnv, error)
 	appConfig.Binary = os.Args[0]
 	appConfig.Args = os.Args[1:]
 
+	resolved, err := secrets.Resolve(ctx, appConfig)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	wletConfig, err := runtime.ParseWeaveletConfig(resolved.Sections)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	if err := applyProcessConfigs(wletConfig.Processes); err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
 	wlet := &protos.EnvelopeInfo{
 		App:           appConfig.Name,
 		DeploymentId:  uuid.New().String(),
 		Id:            uuid.New().String(),
-		Sections:      appConfig.Sections,
+		Sections:      resolved.Sections,
 		SingleProcess: true,
 		SingleMachine: true,
 		RunMain:       true,
@@ -121,15 +196,28 @@ func newSingleprocessEnv(bootstrap runtime.Bootstrap) (*singleprocessEnv, error)
 		return traceDB.Store(ctx, appConfig.Name, wlet.DeploymentId, traces)
 	}
 
+	clock, err := bootstrapClock(bootstrap)
+	if err != nil {
+		return nil, err
+	}
+
 	env := &singleprocessEnv{
 		ctx:            ctx,
 		info:           wlet,
 		config:         appConfig,
 		submissionTime: time.Now(),
+		listenersFile:  os.Getenv(listeners.FileKey),
 		listeners:      map[string][]string{},
 		statsProcessor: imetrics.NewStatsProcessor(),
 		traceSaver:     traceSaver,
+		handler:        handler,
+		retention:      wletConfig.Retention,
+		clock:          clock,
 	}
+	env.broker = pubsub.NewBroker(ctx, pubsub.NewMemoryStore(), env.SystemLogger())
+	env.scheduler = cron.NewScheduler(ctx, env.SystemLogger(), cronClock{env.clock})
+	env.leases = lease.NewTable()
+	env.profiles = status.NewProfileStore()
 	go func() {
 		err := env.statsProcessor.CollectMetrics(ctx, metrics.Snapshot)
 		if err != nil {
@@ -151,6 +239,11 @@ func (e *singleprocessEnv) ActivateComponent(_ context.Context, component string
 }
 
 func (e *singleprocessEnv) GetListenerAddress(_ context.Context, listener string, opts ListenerOptions) (*protos.GetListenerAddressReply, error) {
+	if e.listenersFile != "" {
+		// Reuse the address of the listener in the previous run of the
+		// application, if any.
+		return &protos.GetListenerAddressReply{Address: listeners.Address(e.listenersFile, listener, opts.LocalAddress)}, nil
+	}
 	return &protos.GetListenerAddressReply{Address: opts.LocalAddress}, nil
 }
 
@@ -158,9 +251,69 @@ func (e *singleprocessEnv) ExportListener(_ context.Context, listener, addr stri
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.listeners[listener] = append(e.listeners[listener], addr)
+	if e.listenersFile != "" {
+		if err := listeners.Record(e.listenersFile, listener, addr); err != nil {
+			e.SystemLogger().Error("record listener address", err, "listener", listener, "address", addr)
+		}
+	}
 	return &protos.ExportListenerReply{}, nil
 }
 
+func (e *singleprocessEnv) PublishTopicEvent(*protos.TopicEvent) error {
+	// There are no other weavelets.
+	return nil
+}
+
+// PublishMessage implements the env interface.
+func (e *singleprocessEnv) PublishMessage(_ context.Context, msg *protos.TopicMessage) error {
+	return e.broker.Publish(msg.Topic, msg.Payload)
+}
+
+// Subscribe implements the env interface.
+func (e *singleprocessEnv) Subscribe(_ context.Context, topic, subscription string) error {
+	sub := pubsub.Subscription{Topic: topic, Name: subscription}
+	deliver := func(_ context.Context, req *protos.DeliverMessageRequest) error {
+		_, err := e.handler.DeliverMessage(req)
+		return err
+	}
+	_, err := e.broker.Subscribe(sub, deliver)
+	return err
+}
+
+// ScheduleJob implements the env interface.
+func (e *singleprocessEnv) ScheduleJob(_ context.Context, req *protos.ScheduleJobRequest) error {
+	run := func(_ context.Context, req *protos.RunJobRequest) error {
+		_, err := e.handler.RunJob(req)
+		return err
+	}
+	_, err := e.scheduler.Schedule(req, run)
+	return err
+}
+
+// Lease implements the env interface.
+func (e *singleprocessEnv) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return e.leases.Handle(e.clock.Now(), req), nil
+}
+
+// SendProfile implements the env interface.
+func (e *singleprocessEnv) SendProfile(profile *protos.Profile) error {
+	// Every active component runs in the process, which is its own "main"
+	// group.
+	e.mu.Lock()
+	profile.Components = slices.Clone(e.components)
+	e.mu.Unlock()
+	slices.Sort(profile.Components)
+	e.profiles.Add("main", e.info.Id, profile)
+	return nil
+}
+
+// SendCrashReport implements the env interface.
+func (e *singleprocessEnv) SendCrashReport(*protos.CrashReport) error {
+	// Every component is local, so there are no remote calls whose panics
+	// could be reported.
+	return nil
+}
+
 // serveStatus runs and registers the weaver-single status server.
 func (e *singleprocessEnv) serveStatus(ctx context.Context) error {
 	mux := http.NewServeMux()
@@ -205,6 +358,18 @@ func (e *singleprocessEnv) serveStatus(ctx context.Context) error {
 		return err
 	}
 
+	// Delete the traces of old deployments of the application, if the config
+	// has retention policies.
+	janitor := &retention.Janitor{
+		App:     e.info.App,
+		Version: e.info.DeploymentId,
+		Config:  e.retention,
+		Stores:  []retention.Store{retention.TraceDB(perfetto.Open)},
+		Logger:  e.SystemLogger(),
+		Running: status.RunningDeployments,
+	}
+	go janitor.Run(ctx)
+
 	// Unregister the deployment if this application is killed.
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM)
@@ -228,7 +393,7 @@ func (e *singleprocessEnv) Status(ctx context.Context) (*status.Status, error) {
 	// any other process?
 	pid := int64(os.Getpid())
 	stats := e.statsProcessor.GetStatsStatusz()
-	components := []*status.Component{{Name: "main", Pids: []int64{pid}}}
+	components := []*status.Component{{Name: "main", Group: "main", Pids: []int64{pid}}}
 	for _, component := range e.components {
 		c := &status.Component{
 			Name:  component,
@@ -288,6 +453,7 @@ func (e *singleprocessEnv) Status(ctx context.Context) (*status.Status, error) {
 
 // Metrics implements the status.Server interface.
 func (e *singleprocessEnv) Metrics(context.Context) (*status.Metrics, error) {
+	conn.UpdateRuntimeMetrics()
 	m := &status.Metrics{}
 	for _, snap := range metrics.Snapshot() {
 		proto := snap.ToProto()
@@ -297,6 +463,10 @@ func (e *singleprocessEnv) Metrics(context.Context) (*status.Metrics, error) {
 		proto.Labels["serviceweaver_app"] = e.info.App
 		proto.Labels["serviceweaver_version"] = e.info.DeploymentId
 		proto.Labels["serviceweaver_node"] = e.info.Id
+		// Every component runs in this process, which is its own "main"
+		// group, so that the dashboard attributes the resources of the
+		// process to the components, like for weaver multi.
+		proto.Labels["serviceweaver_group"] = "main"
 		m.Metrics = append(m.Metrics, proto)
 	}
 	return m, nil
@@ -304,10 +474,62 @@ func (e *singleprocessEnv) Metrics(context.Context) (*status.Metrics, error) {
 
 // Profile implements the status.Server interface.
 func (e *singleprocessEnv) Profile(_ context.Context, req *protos.GetProfileRequest) (*protos.GetProfileReply, error) {
+	if req.Component != "" && req.Component != "main" {
+		// Every component runs in this process.
+		e.mu.Lock()
+		found := slices.Contains(e.components, req.Component)
+		e.mu.Unlock()
+		if !found {
+			return nil, fmt.Errorf("component %q is not running", req.Component)
+		}
+	}
 	data, err := conn.Profile(req)
 	return &protos.GetProfileReply{Data: data}, err
 }
 
+// Profiles implements the status.Server interface.
+func (e *singleprocessEnv) Profiles(_ context.Context, req *status.ProfilesRequest) (*status.ProfilesReply, error) {
+	return e.profiles.Profiles(req)
+}
+
+// SetLogLevel implements the status.Server interface.
+func (e *singleprocessEnv) SetLogLevel(_ context.Context, req *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	// Every component runs in this process.
+	return e.handler.SetLogLevel(req)
+}
+
+// Flags implements the status.Server interface.
+func (e *singleprocessEnv) Flags(_ context.Context, req *status.FlagsRequest) (*status.FlagsReply, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.flags == nil {
+		e.flags = map[string]string{}
+	}
+	if err := status.UpdateFlags(e.flags, req); err != nil {
+		return nil, err
+	}
+	flags := maps.Clone(e.flags)
+	if _, err := e.handler.SetFlags(&protos.SetFlagsRequest{Flags: flags}); err != nil {
+		return nil, err
+	}
+	return &status.FlagsReply{Flags: flags}, nil
+}
+
+// ApplyConfig implements the status.Server interface.
+func (e *singleprocessEnv) ApplyConfig(context.Context, *status.ApplyConfigRequest) (*status.ApplyConfigReply, error) {
+	return nil, fmt.Errorf("applying configs is not supported by single process deployments")
+}
+
+// SplitTraffic implements the status.Server interface.
+func (e *singleprocessEnv) SplitTraffic(context.Context, *status.SplitTrafficRequest) (*status.SplitTrafficReply, error) {
+	return nil, fmt.Errorf("rollouts are not supported by single process deployments")
+}
+
+// Rollout implements the status.Server interface.
+func (e *singleprocessEnv) Rollout(context.Context, *status.RolloutRequest) (*status.RolloutReply, error) {
+	return nil, fmt.Errorf("rollouts are not supported by single process deployments")
+}
+
 func (e *singleprocessEnv) CreateLogSaver() func(entry *protos.LogEntry) {
 	pp := logging.NewPrettyPrinter(colors.Enabled())
 	return func(entry *protos.LogEntry) {
@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+)
+
+func TestApplyProcessConfigs(t *testing.T) {
+	// Test plan: Apply the process configs of two groups to this process, and
+	// check its env and working directory. Then, check that conflicting
+	// configs are rejected.
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) }) //nolint:errcheck // best effort
+	t.Setenv("WEAVER_TEST_PLATFORM", "")
+	t.Setenv("WEAVER_TEST_CACHE", "")
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyProcessConfigs(map[string]runtime.ProcessConfig{
+		"a/T": {Env: []string{"WEAVER_TEST_PLATFORM=gcp"}, Args: []string{"--ignored"}, Dir: dir},
+		"b/T": {Env: []string{"WEAVER_TEST_PLATFORM=gcp", "WEAVER_TEST_CACHE=1024"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"WEAVER_TEST_PLATFORM": "gcp", "WEAVER_TEST_CACHE": "1024"} {
+		if got := os.Getenv(k); got != want {
+			t.Errorf("%s: got %q, want %q", k, got, want)
+		}
+	}
+	if got, err := os.Getwd(); err != nil || got != dir {
+		t.Errorf("working directory: got %q, %v, want %q", got, err, dir)
+	}
+
+	for _, test := range []struct {
+		name      string
+		processes map[string]runtime.ProcessConfig
+		want      string
+	}{
+		{
+			name: "env",
+			processes: map[string]runtime.ProcessConfig{
+				"a/T": {Env: []string{"WEAVER_TEST_PLATFORM=gcp"}},
+				"b/T": {Env: []string{"WEAVER_TEST_PLATFORM=aws"}},
+			},
+			want: "set to both",
+		},
+		{
+			name: "dir",
+			processes: map[string]runtime.ProcessConfig{
+				"a/T": {Dir: dir},
+				"b/T": {Dir: cwd},
+			},
+			want: "different working directories",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := applyProcessConfigs(test.processes)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("applyProcessConfigs: got %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/internal/kv"
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+)
+
+// StoreKey is the set of types of the keys of a Store or a Cache.
+type StoreKey interface {
+	~string | ~int | ~int32 | ~int64 | ~uint | ~uint32 | ~uint64
+}
+
+// A Store is a named, persistent key-value store that maps keys of type K to
+// values of type V. For example, a cart service can keep the carts of its
+// users in a store:
+//
+//	type Cart struct {
+//	    weaver.AutoMarshal
+//	    Items []Item
+//	}
+//
+//	var carts = weaver.NewStore[string, Cart]("carts")
+//
+//	func (c *cartService) AddItem(ctx context.Context, user string, item Item) error {
+//	    cart, _, err := carts.Get(ctx, c, user)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    cart.Items = append(cart.Items, item)
+//	    return carts.Put(ctx, c, user, cart)
+//	}
+//
+// Where a store keeps its values is configured in the config, so that the
+// same code runs unchanged on every deployer (see runtime.StoreConfig):
+//
+//	[serviceweaver.stores.carts]
+//	backend = "redis"
+//	address = "redis.internal:6379"
+//
+// A store without a config is kept in the memory of every process. A value of
+// type V is serialized like a component method argument, so V must be a
+// struct that embeds weaver.AutoMarshal. Store operations are not
+// transactional: concurrent updates to the same key, like AddItem above, may
+// overwrite each other, so route them to the same replica (see
+// weaver.WithRouter) or serialize them otherwise.
+type Store[K StoreKey, V any] struct {
+	name string
+}
+
+// NewStore returns the store with the provided name. Stores are typically
+// declared at package scope. NewStore panics if V is not a struct that embeds
+// weaver.AutoMarshal.
+func NewStore[K StoreKey, V any](name string) Store[K, V] {
+	if _, ok := any(new(V)).(codegen.AutoMarshal); !ok {
+		var zero V
+		panic(fmt.Errorf("NewStore(%q): value type %T does not embed weaver.AutoMarshal", name, zero))
+	}
+	return Store[K, V]{name: name}
+}
+
+// Name returns the name of the store.
+func (s Store[K, V]) Name() string {
+	return s.name
+}
+
+// Get returns the value of the provided key, and whether the key is present.
+// If the key is absent, Get returns the zero value of V.
+func (s Store[K, V]) Get(ctx context.Context, requester Instance, key K) (V, bool, error) {
+	var value V
+	backend, err := requester.rep().wlet.stores.backend(ctx, s.name)
+	if err != nil {
+		return value, false, err
+	}
+	data, ok, err := backend.Get(ctx, fmt.Sprint(key))
+	if err != nil || !ok {
+		return value, false, err
+	}
+	if err := decodeMessage(data, any(&value).(codegen.AutoMarshal)); err != nil {
+		return value, false, fmt.Errorf("store %q: decode value of %v: %w", s.name, key, err)
+	}
+	return value, true, nil
+}
+
+// Put sets the value of the provided key.
+func (s Store[K, V]) Put(ctx context.Context, requester Instance, key K, value V) error {
+	backend, err := requester.rep().wlet.stores.backend(ctx, s.name)
+	if err != nil {
+		return err
+	}
+	enc := codegen.NewEncoder()
+	any(&value).(codegen.AutoMarshal).WeaverMarshal(enc)
+	return backend.Put(ctx, fmt.Sprint(key), enc.Data())
+}
+
+// Delete removes the provided key from the store, if present.
+func (s Store[K, V]) Delete(ctx context.Context, requester Instance, key K) error {
+	backend, err := requester.rep().wlet.stores.backend(ctx, s.name)
+	if err != nil {
+		return err
+	}
+	return backend.Delete(ctx, fmt.Sprint(key))
+}
+
+// stores holds the backends of the stores used in a weavelet. A backend is
+// opened the first time its store is used.
+type stores struct {
+	configs map[string]runtime.StoreConfig // by store name
+
+	mu       sync.Mutex
+	backends map[string]kv.Backend // by store name
+}
+
+func newStores(configs map[string]runtime.StoreConfig) *stores {
+	return &stores{configs: configs, backends: map[string]kv.Backend{}}
+}
+
+// backend returns the backend of the named store, opening it if needed.
+func (s *stores) backend(ctx context.Context, name string) (kv.Backend, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.backends[name]; ok {
+		return b, nil
+	}
+	b, err := kv.Open(ctx, name, s.configs[name])
+	if err != nil {
+		return nil, err
+	}
+	s.backends[name] = b
+	return b, nil
+}
+
+// close closes the backends of the stores, and returns the first error
+// encountered, if any.
+func (s *stores) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first error
+	for name, b := range s.backends {
+		if err := b.Close(); err != nil && first == nil {
+			first = fmt.Errorf("store %q: %w", name, err)
+		}
+	}
+	s.backends = map[string]kv.Backend{}
+	return first
+}
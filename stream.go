@@ -0,0 +1,136 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// errStreamClosed is returned by the Recv method of a stream created by
+// NewStream once the stream has been closed.
+var errStreamClosed = errors.New("stream closed")
+
+// Stream is a stream of values of type T. A component method can return a
+// stream, rather than a slice, to produce its results incrementally:
+//
+//	type Recommender interface {
+//	    Recommend(ctx context.Context, userID string) (weaver.Stream[Product], error)
+//	}
+//
+// A method that returns a stream can also take a stream as its last
+// argument, to consume values sent by the caller while producing its own:
+//
+//	type Translator interface {
+//	    Translate(ctx context.Context, lang string, lines weaver.Stream[string]) (weaver.Stream[string], error)
+//	}
+//
+// When a streaming method is called remotely, values are sent as they are
+// produced, and the producer is blocked, rather than values being buffered,
+// when the consumer falls behind. The stream is bound to the context passed
+// to the method: cancelling the context ends the stream. An error returned by
+// a remote streaming method may be returned by the first call to Recv,
+// rather than by the method itself.
+//
+// The consumer of a stream must call Close when it is done with it.
+// Streams are typically created with NewStream.
+type Stream[T any] interface {
+	// Recv returns the next value in the stream. Recv returns io.EOF once
+	// all of the values in the stream have been received, or the error that
+	// ended the stream.
+	Recv() (T, error)
+
+	// Close informs the producer of the stream that no more values will be
+	// received. Close may be called concurrently with Recv, and may be
+	// called more than once.
+	Close()
+}
+
+// NewStream returns a stream of the values sent by produce, which is run in
+// its own goroutine. send blocks until the sent value is received, and fails
+// once the stream has been closed. The stream ends with the error returned by
+// produce, or io.EOF if produce returns nil. For example:
+//
+//	func (r *recommender) Recommend(ctx context.Context, userID string) (weaver.Stream[Product], error) {
+//	    return weaver.NewStream(ctx, func(ctx context.Context, send func(Product) error) error {
+//	        for _, p := range r.candidates(userID) {
+//	            if err := send(p); err != nil {
+//	                return err
+//	            }
+//	        }
+//	        return nil
+//	    }), nil
+//	}
+//
+// The context passed to produce is derived from ctx, and is cancelled when
+// the stream is closed.
+func NewStream[T any](ctx context.Context, produce func(ctx context.Context, send func(T) error) error) Stream[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &stream[T]{
+		values: make(chan T),
+		done:   make(chan struct{}),
+		closed: make(chan struct{}),
+		cancel: cancel,
+	}
+	go func() {
+		defer cancel()
+		s.err = produce(ctx, func(v T) error {
+			select {
+			case s.values <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(s.done)
+	}()
+	return s
+}
+
+// stream is the Stream returned by NewStream.
+type stream[T any] struct {
+	values chan T        // values sent by the producer
+	done   chan struct{} // closed when the producer returns
+	err    error         // the producer's error; read once done is closed
+	closed chan struct{} // closed by Close
+	cancel func()        // cancels the producer
+	once   sync.Once     // used to close closed
+}
+
+// Recv implements the Stream interface.
+func (s *stream[T]) Recv() (T, error) {
+	var zero T
+	select {
+	case v := <-s.values:
+		return v, nil
+	case <-s.done:
+		if s.err != nil {
+			return zero, s.err
+		}
+		return zero, io.EOF
+	case <-s.closed:
+		return zero, errStreamClosed
+	}
+}
+
+// Close implements the Stream interface.
+func (s *stream[T]) Close() {
+	s.once.Do(func() {
+		close(s.closed)
+		s.cancel()
+	})
+}
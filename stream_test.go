@@ -0,0 +1,81 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestNewStream(t *testing.T) {
+	s := NewStream(context.Background(), func(_ context.Context, send func(int) error) error {
+		for i := 0; i < 3; i++ {
+			if err := send(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	defer s.Close()
+	for want := 0; want < 3; want++ {
+		if got, err := s.Recv(); err != nil || got != want {
+			t.Fatalf("Recv: got (%d, %v), want (%d, nil)", got, err, want)
+		}
+	}
+	if _, err := s.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv: got %v, want io.EOF", err)
+	}
+}
+
+func TestNewStreamError(t *testing.T) {
+	want := errors.New("producer failed")
+	s := NewStream(context.Background(), func(context.Context, func(int) error) error {
+		return want
+	})
+	defer s.Close()
+	if _, err := s.Recv(); !errors.Is(err, want) {
+		t.Fatalf("Recv: got %v, want %v", err, want)
+	}
+}
+
+func TestNewStreamClose(t *testing.T) {
+	// Test plan: Close a stream whose producer is blocked sending a value,
+	// and check that the producer is cancelled.
+	done := make(chan error)
+	s := NewStream(context.Background(), func(ctx context.Context, send func(int) error) error {
+		err := send(0)
+		if ctx.Err() == nil {
+			err = errors.New("context not cancelled")
+		}
+		done <- err
+		return err
+	})
+	s.Close()
+	s.Close() // Close can be called more than once.
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("send: got %v, want context.Canceled", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("producer not cancelled")
+	}
+	if _, err := s.Recv(); err == nil {
+		t.Fatal("Recv: unexpected success")
+	}
+}
@@ -50,12 +50,148 @@ func (r retriable) Unwrap() error {
 	return r.err
 }
 
+// versionMismatch is an error caused by a caller and a callee disagreeing on
+// the version of a method. If err is a versionMismatch, then errors.Is(err,
+// ErrVersionMismatch) is true.
+type versionMismatch struct {
+	err error
+}
+
+// Error implements the error interface.
+func (v versionMismatch) Error() string {
+	return v.err.Error()
+}
+
+// Is makes versionMismatch compatible with errors.Is.
+func (v versionMismatch) Is(err error) bool {
+	return err == ErrVersionMismatch
+}
+
+// Unwrap makes versionMismatch compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (v versionMismatch) Unwrap() error {
+	return v.err
+}
+
+// shedLoad is an error caused by a call being rejected by an overloaded
+// server. If err is a shedLoad, then errors.Is(err, ErrShedLoad) is true.
+type shedLoad struct {
+	err error
+}
+
+// Error implements the error interface.
+func (s shedLoad) Error() string {
+	return s.err.Error()
+}
+
+// Is makes shedLoad compatible with errors.Is.
+func (s shedLoad) Is(err error) bool {
+	return err == ErrShedLoad
+}
+
+// Unwrap makes shedLoad compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (s shedLoad) Unwrap() error {
+	return s.err
+}
+
+// overloaded is an error caused by a call being rejected because too many
+// calls were in flight on its connection. If err is an overloaded, then
+// errors.Is(err, ErrOverloaded) is true.
+type overloaded struct {
+	err error
+}
+
+// Error implements the error interface.
+func (o overloaded) Error() string {
+	return o.err.Error()
+}
+
+// Is makes overloaded compatible with errors.Is.
+func (o overloaded) Is(err error) bool {
+	return err == ErrOverloaded
+}
+
+// Unwrap makes overloaded compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (o overloaded) Unwrap() error {
+	return o.err
+}
+
+// permissionDenied is an error caused by a call being rejected because the
+// caller isn't allowed to make it. If err is a permissionDenied, then
+// errors.Is(err, ErrPermissionDenied) is true.
+type permissionDenied struct {
+	err error
+}
+
+// Error implements the error interface.
+func (p permissionDenied) Error() string {
+	return p.err.Error()
+}
+
+// Is makes permissionDenied compatible with errors.Is.
+func (p permissionDenied) Is(err error) bool {
+	return err == ErrPermissionDenied
+}
+
+// Unwrap makes permissionDenied compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (p permissionDenied) Unwrap() error {
+	return p.err
+}
+
+// payloadTooLarge is an error caused by the arguments or results of a call
+// exceeding the size limit of the called method. If err is a
+// payloadTooLarge, then errors.Is(err, ErrPayloadTooLarge) is true.
+type payloadTooLarge struct {
+	err error
+}
+
+// Error implements the error interface.
+func (p payloadTooLarge) Error() string {
+	return p.err.Error()
+}
+
+// Is makes payloadTooLarge compatible with errors.Is.
+func (p payloadTooLarge) Is(err error) bool {
+	return err == ErrPayloadTooLarge
+}
+
+// Unwrap makes payloadTooLarge compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (p payloadTooLarge) Unwrap() error {
+	return p.err
+}
+
 // stub holds information about a client stub to the remote component.
 type stub struct {
-	client   call.Connection  // client to talk to the remote component, created lazily.
-	methods  []call.MethodKey // Keys for the remote component methods.
-	balancer call.Balancer    // if not nil, component load balancer
-	tracer   trace.Tracer     // component tracer
+	component string           // name of the remote component
+	client    call.Connection  // client to talk to the remote component, created lazily.
+	methods   []call.MethodKey // Keys for the remote component methods.
+	versions  []uint64         // if not nil, versions of the remote component methods
+	priority  []Priority       // if not nil, default priorities of the remote component methods
+	tracer    trace.Tracer     // component tracer
+
+	// If not nil, policies[i] describes how the results of the i-th method
+	// are cached in cache. A nil policy disables caching for a method.
+	policies []*cachePolicy
+	cache    *methodCache
+	clock    Clock // clock used to expire cached results
+
+	// If not nil, calls[i] describes how calls to the i-th method are
+	// bounded, retried, and hedged. A nil policy runs every call once, unbounded.
+	calls []*callPolicy
+
+	// If not nil, limits[i] bounds the size of the arguments of the calls to
+	// the i-th method.
+	limits []*payloadLimits
+
+	// If not nil, every attempt of every call goes through breaker.
+	breaker *circuitBreaker
+
+	// If not nil, faults injected into every attempt of every call.
+	chaos *chaos
 }
 
 var _ codegen.Stub = &stub{}
@@ -67,11 +203,123 @@ func (s *stub) Tracer() trace.Tracer {
 
 // Run implements the codegen.Stub interface.
 func (s *stub) Run(ctx context.Context, method int, args []byte, shardKey uint64) ([]byte, error) {
+	if s.limits != nil {
+		if err := s.limits[method].checkRequest(len(args)); err != nil {
+			return nil, err
+		}
+	}
+	opts := s.callOptions(ctx, method, shardKey)
+	if s.policies == nil || s.policies[method] == nil {
+		return s.call(ctx, method, args, opts)
+	}
+
+	// Serve the call from the cache, if possible.
+	policy := s.policies[method]
+	key := cacheKey{method: s.methods[method], args: string(args), scope: cacheScope(ctx)}
+	if invalidated(ctx) {
+		s.cache.remove(key)
+	} else if results, ok := s.cache.get(key, s.clock.Now()); ok {
+		policy.hits.Add(1)
+		return results, nil
+	}
+	policy.misses.Add(1)
+
+	results, err := s.call(ctx, method, args, opts)
+	if err != nil {
+		// Never cache system errors.
+		return nil, err
+	}
+	ttl := policy.ttl
+	if !codegen.EndsWithNilError(results) {
+		ttl = policy.negativeTTL
+	}
+	if ttl > 0 {
+		s.cache.put(key, results, s.clock.Now().Add(ttl), policy.maxEntries)
+	}
+	return results, nil
+}
+
+// call makes a remote call to the provided method, subject to the method's
+// call policy and the component's circuit breaker.
+func (s *stub) call(ctx context.Context, method int, args []byte, opts call.CallOptions) ([]byte, error) {
+	attempt := func(ctx context.Context, opts call.CallOptions) ([]byte, error) {
+		if s.chaos != nil {
+			if err := s.chaos.inject(ctx, s.component); err != nil {
+				return nil, err
+			}
+		}
+		return s.client.Call(ctx, s.methods[method], args, opts)
+	}
+	if s.breaker != nil {
+		send := attempt
+		attempt = func(ctx context.Context, opts call.CallOptions) ([]byte, error) {
+			return s.breaker.run(ctx, func(ctx context.Context) ([]byte, error) {
+				return send(ctx, opts)
+			})
+		}
+	}
+	if s.calls == nil || s.calls[method] == nil {
+		return attempt(ctx, opts)
+	}
+	return s.calls[method].run(ctx, opts, attempt)
+}
+
+// Stream implements the codegen.Stub interface. Streaming calls are never
+// cached, bounded, or retried.
+func (s *stub) Stream(ctx context.Context, method int, args []byte, shardKey uint64) (codegen.ClientStream, error) {
+	if s.limits != nil {
+		if err := s.limits[method].checkRequest(len(args)); err != nil {
+			return nil, err
+		}
+	}
+	if s.chaos != nil {
+		if err := s.chaos.inject(ctx, s.component); err != nil {
+			return nil, err
+		}
+	}
+	stream, err := s.client.Stream(ctx, s.methods[method], args, s.callOptions(ctx, method, shardKey))
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// callOptions returns the options of a call to the provided method.
+func (s *stub) callOptions(ctx context.Context, method int, shardKey uint64) call.CallOptions {
 	opts := call.CallOptions{
 		ShardKey: shardKey,
-		Balancer: s.balancer,
 	}
-	return s.client.Call(ctx, s.methods[method], args, opts)
+	if s.versions != nil {
+		opts.Version = s.versions[method]
+	}
+	if p, ok := priorityFromContext(ctx); ok {
+		opts.Priority = call.Priority(p)
+	} else if s.priority != nil {
+		opts.Priority = call.Priority(s.priority[method])
+	}
+	md := metadataFromContext(ctx)
+	if md != nil {
+		// Record the metadata in the call's span, and send it to the callee.
+		recordMetadata(ctx, md)
+		opts.Metadata = md.encoded
+	}
+	key, hasKey := idempotencyKeyFromContext(ctx)
+	caller := callerFromContext(ctx)
+	if hasKey || caller != "" {
+		// Send the idempotency key and the caller along with the metadata.
+		sent := &callMetadata{IdempotencyKey: key, Caller: caller}
+		if md != nil {
+			sent.Principal = md.Principal
+			sent.Values = md.Values
+		}
+		opts.Metadata = encodeMetadata(sent)
+	}
+	if hasKey {
+		// Send the retries of the call to the same replica, which
+		// deduplicates them.
+		opts.Affinity = affinity(key)
+	}
+	return opts
 }
 
 // WrapError implements the codegen.Stub interface.
@@ -79,5 +327,23 @@ func (s *stub) WrapError(err error) error {
 	if errors.Is(err, call.CommunicationError) || errors.Is(err, call.Unreachable) {
 		return retriable{err}
 	}
+	if errors.Is(err, call.VersionMismatch) {
+		return versionMismatch{err}
+	}
+	if errors.Is(err, call.ShedLoad) {
+		return shedLoad{err}
+	}
+	if errors.Is(err, call.Overloaded) {
+		return overloaded{err}
+	}
+	if errors.Is(err, call.PermissionDenied) {
+		return permissionDenied{err}
+	}
+	if errors.Is(err, call.PayloadTooLarge) {
+		return payloadTooLarge{err}
+	}
+	if codegen.IsDecodedError(err) {
+		return &RemoteError{Component: s.component, Message: err.Error(), err: err}
+	}
 	return err
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -37,6 +38,10 @@ func (c *localClient) Call(ctx context.Context, _ call.MethodKey, args []byte, _
 	return handleCall(ctx, reflect.ValueOf(c.fn), args)
 }
 
+func (c *localClient) Stream(context.Context, call.MethodKey, []byte, call.CallOptions) (call.ClientStream, error) {
+	return nil, fmt.Errorf("streaming calls not supported")
+}
+
 func (c *localClient) Close() {}
 
 func TestCall(t *testing.T) {
@@ -272,6 +277,46 @@ func TestWrapErrors(t *testing.T) {
 	}
 }
 
+func TestWrapOverloaded(t *testing.T) {
+	err := (&stub{}).WrapError(fmt.Errorf("%w: 1 calls in flight and 0 pending on the connection", call.Overloaded))
+	if !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("errors.Is(%v, ErrOverloaded) = false, want true", err)
+	}
+	if errors.Is(err, ErrShedLoad) || errors.Is(err, ErrRetriable) {
+		t.Fatalf("errors.Is(%v, ErrShedLoad or ErrRetriable) = true, want false", err)
+	}
+}
+
+func TestWrapRemoteError(t *testing.T) {
+	// Test plan: Encode and decode an application error, as a client stub
+	// does, and check that WrapError turns it into a RemoteError that still
+	// matches the original error with errors.Is.
+	enc := codegen.NewEncoder()
+	enc.Error(fmt.Errorf("no quote: %w", os.ErrNotExist))
+	decoded := codegen.NewDecoder(enc.Data()).Error()
+
+	s := stub{component: "shipping.T"}
+	err := s.WrapError(decoded)
+	var remote *RemoteError
+	if !errors.As(err, &remote) {
+		t.Fatalf("errors.As(%v, *RemoteError) = false, want true", err)
+	}
+	if got, want := remote.Component, "shipping.T"; got != want {
+		t.Errorf("Component: got %q, want %q", got, want)
+	}
+	if got, want := err.Error(), "no quote: file does not exist"; got != want {
+		t.Errorf("Error: got %q, want %q", got, want)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("errors.Is(%v, os.ErrNotExist) = false, want true", err)
+	}
+
+	// Local errors are not wrapped.
+	if err := s.WrapError(os.ErrNotExist); errors.As(err, &remote) {
+		t.Errorf("errors.As(%v, *RemoteError) = true, want false", err)
+	}
+}
+
 // convertCallPanicToError catches and returns errors detected during fn's execution.
 func convertCallPanicToError(fn func() error) (err error) {
 	defer func() {
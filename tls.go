@@ -0,0 +1,108 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloadInterval is the minimum time between two checks for a new
+// certificate in a listener's certificate and key files.
+const certReloadInterval = 10 * time.Second
+
+// tlsConfig returns the TLS config of a listener with the provided options,
+// or nil if the listener doesn't serve TLS.
+func tlsConfig(opts ListenerOptions) (*tls.Config, error) {
+	files := opts.CertFile != "" || opts.KeyFile != ""
+	switch {
+	case files && opts.TLSConfig != nil:
+		return nil, fmt.Errorf("both CertFile/KeyFile and TLSConfig specified")
+	case opts.TLSConfig != nil:
+		return opts.TLSConfig, nil
+	case !files:
+		return nil, nil
+	case opts.CertFile == "" || opts.KeyFile == "":
+		return nil, fmt.Errorf("CertFile and KeyFile must be specified together")
+	}
+	r := &certReloader{certFile: opts.CertFile, keyFile: opts.KeyFile}
+	if err := r.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return &tls.Config{GetCertificate: r.getCertificate}, nil
+}
+
+// certReloader serves the certificate in a pair of certificate and key files,
+// reloading it when the files change.
+type certReloader struct {
+	certFile string // PEM encoded certificate file
+	keyFile  string // PEM encoded key file
+
+	mu      sync.Mutex
+	cert    *tls.Certificate // last loaded certificate
+	certMod time.Time        // modification time of certFile when cert was loaded
+	keyMod  time.Time        // modification time of keyFile when cert was loaded
+	checked time.Time        // last time the files were checked
+}
+
+// getCertificate implements the tls.Config.GetCertificate function.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := time.Now(); now.Sub(r.checked) >= certReloadInterval {
+		// If the new files are invalid, e.g., because they are being
+		// replaced right now, keep serving the old certificate and retry
+		// later.
+		r.reloadLocked(now) //nolint:errcheck // see above
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate, if the files have changed since the last
+// time it was loaded.
+func (r *certReloader) reload(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked(now)
+}
+
+// reloadLocked is like reload.
+//
+// REQUIRES: r.mu is held.
+func (r *certReloader) reloadLocked(now time.Time) error {
+	r.checked = now
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	return nil
+}
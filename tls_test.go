@@ -0,0 +1,139 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a new self-signed certificate for the provided common name
+// and its key to certFile and keyFile.
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commonName returns the common name of the certificate returned by
+// getCertificate.
+func commonName(t *testing.T, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) string {
+	t.Helper()
+	cert, err := getCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	// Test plan: Serve a certificate from files, replace the files, and
+	// check that the new certificate is served once the files are checked
+	// again.
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "old")
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commonName(t, r.getCertificate), "old"; got != want {
+		t.Fatalf("certificate: got %q, want %q", got, want)
+	}
+
+	writeCert(t, certFile, keyFile, "new")
+	later := time.Now().Add(time.Hour)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := commonName(t, r.getCertificate), "old"; got != want {
+		t.Fatalf("certificate before check: got %q, want %q", got, want)
+	}
+	r.checked = time.Time{} // check the files on the next handshake
+	if got, want := commonName(t, r.getCertificate), "new"; got != want {
+		t.Fatalf("certificate after check: got %q, want %q", got, want)
+	}
+
+	// Invalid files are ignored.
+	if err := os.WriteFile(certFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r.checked = time.Time{}
+	if got, want := commonName(t, r.getCertificate), "new"; got != want {
+		t.Fatalf("certificate after invalid files: got %q, want %q", got, want)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "test")
+	for _, test := range []struct {
+		name string
+		opts ListenerOptions
+	}{
+		{"MissingKey", ListenerOptions{CertFile: certFile}},
+		{"FilesAndConfig", ListenerOptions{CertFile: certFile, KeyFile: keyFile, TLSConfig: &tls.Config{}}},
+		{"NoSuchFile", ListenerOptions{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := tlsConfig(test.opts); err == nil {
+				t.Fatal("tlsConfig: unexpected success")
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ServiceWeaver/weaver/internal/pubsub"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+type subscriptionLabels struct {
+	Topic        string // the topic name
+	Subscription string // the subscription name
+}
+
+var (
+	publishedMessages = metrics.NewCounterMap[topicLabels](
+		"serviceweaver_topic_published_message_count",
+		"Count of messages published on a Service Weaver topic",
+	)
+	failedDeliveries = metrics.NewCounterMap[subscriptionLabels](
+		"serviceweaver_topic_failed_delivery_count",
+		"Count of failed deliveries of Service Weaver topic messages, which are retried",
+	)
+)
+
+// A Topic is a named topic on which messages of type T are published. Unlike
+// the events published with Publish, which are delivered best effort, every
+// message published on a Topic is delivered at least once to every
+// subscription to the topic. For example, a checkout service can announce
+// placed orders to the services that act on them:
+//
+//	type OrderPlaced struct {
+//	    weaver.AutoMarshal
+//	    OrderID string
+//	    Email   string
+//	}
+//
+//	var orders = weaver.NewTopic[OrderPlaced]("orders")
+//
+//	func (s *checkout) PlaceOrder(ctx context.Context, req Order) error {
+//	    ...
+//	    return orders.Publish(ctx, s, OrderPlaced{OrderID: id, Email: req.Email})
+//	}
+//
+// Messages are stored by the deployer until they are delivered. The multi
+// deployer stores them on disk, so that messages survive the failure of any
+// process; the single process deployer and weavertest keep them in memory. A
+// message of type T is serialized like a component method argument, so T
+// must be a struct that embeds weaver.AutoMarshal.
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic returns the topic with the provided name. Topics are typically
+// declared at package scope. NewTopic panics if T is not a struct that embeds
+// weaver.AutoMarshal.
+func NewTopic[T any](name string) Topic[T] {
+	if _, ok := any(new(T)).(codegen.AutoMarshal); !ok {
+		var zero T
+		panic(fmt.Errorf("NewTopic(%q): message type %T does not embed weaver.AutoMarshal", name, zero))
+	}
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish publishes a message on the topic. Publish returns once the message
+// has been stored by the deployer, after which it is delivered to every
+// subscription to the topic, even if the publisher fails. If Publish returns
+// an error, the message may or may not have been published.
+func (t Topic[T]) Publish(ctx context.Context, requester Instance, msg T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	enc := codegen.NewEncoder()
+	any(&msg).(codegen.AutoMarshal).WeaverMarshal(enc)
+	publishedMessages.Get(topicLabels{Topic: t.name}).Add(1)
+	return requester.rep().wlet.env.PublishMessage(ctx, &protos.TopicMessage{
+		Topic:   t.name,
+		Payload: enc.Data(),
+	})
+}
+
+// Subscribe delivers the messages of the named subscription to the topic to
+// handler. A subscription is created the first time any process subscribes
+// to it, and from then on receives every message published on the topic.
+// Subscriptions with different names are independent, and each receive every
+// message. The handler is typically a method of a component client, so that
+// messages are handled by the component's replicas:
+//
+//	email := weaver.MustGet[EmailService](root)
+//	err := orders.Subscribe(root, "email", email.SendConfirmation)
+//
+// Many processes, e.g., the replicas of a component, may subscribe to the
+// same subscription; every message of the subscription is then delivered to
+// one of them. A process may subscribe to a subscription only once.
+//
+// The messages of a subscription are delivered one at a time, in the order
+// they were published. A message is acknowledged once handler returns nil. If
+// handler returns an error, or its process fails, the message is redelivered
+// later, with exponential backoff, and later messages wait until it is
+// acknowledged. A message may be delivered more than once, so handler should
+// be idempotent. A subscription only receives the messages published after
+// it is created.
+func (t Topic[T]) Subscribe(requester Instance, subscription string, handler func(context.Context, T) error) error {
+	w := requester.rep().wlet
+	sub := pubsub.Subscription{Topic: t.name, Name: subscription}
+	decode := func(ctx context.Context, payload []byte) error {
+		var msg T
+		if err := decodeMessage(payload, any(&msg).(codegen.AutoMarshal)); err != nil {
+			return fmt.Errorf("decode message: %w", err)
+		}
+		return handler(ctx, msg)
+	}
+	if err := w.messageHandlers.add(sub, decode); err != nil {
+		return err
+	}
+	if err := w.env.Subscribe(w.ctx, t.name, subscription); err != nil {
+		w.messageHandlers.remove(sub)
+		return err
+	}
+	return nil
+}
+
+// decodeMessage decodes a message encoded by Publish into msg.
+func decodeMessage(payload []byte, msg codegen.AutoMarshal) (err error) {
+	defer func() { err = codegen.CatchPanics(recover()) }()
+	msg.WeaverUnmarshal(codegen.NewDecoder(payload))
+	return nil
+}
+
+// messageHandlers holds the handlers of the Topic subscriptions in a
+// weavelet.
+type messageHandlers struct {
+	mu       sync.Mutex
+	handlers map[pubsub.Subscription]func(context.Context, []byte) error
+}
+
+func newMessageHandlers() *messageHandlers {
+	return &messageHandlers{handlers: map[pubsub.Subscription]func(context.Context, []byte) error{}}
+}
+
+// add adds the handler of the provided subscription. It returns an error if
+// the subscription already has a handler.
+func (m *messageHandlers) add(sub pubsub.Subscription, handler func(context.Context, []byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.handlers[sub]; ok {
+		return fmt.Errorf("already subscribed to subscription %q to topic %q", sub.Name, sub.Topic)
+	}
+	m.handlers[sub] = handler
+	return nil
+}
+
+// remove removes the handler of the provided subscription.
+func (m *messageHandlers) remove(sub pubsub.Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.handlers, sub)
+}
+
+// deliver delivers a message to the handler of the provided subscription.
+func (m *messageHandlers) deliver(ctx context.Context, sub pubsub.Subscription, payload []byte) error {
+	m.mu.Lock()
+	handler, ok := m.handlers[sub]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no handler for subscription %q to topic %q", sub.Name, sub.Topic)
+	}
+	if err := handler(ctx, payload); err != nil {
+		failedDeliveries.Get(subscriptionLabels{Topic: sub.Topic, Subscription: sub.Name}).Add(1)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// tracePropagator propagates W3C trace context (the traceparent and
+// tracestate headers) and W3C baggage (the baggage header).
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// ExtractTraceContext returns a copy of ctx that carries the W3C trace
+// context and baggage found in the provided carrier, if any. Spans started
+// with the returned context, including the spans of component method calls,
+// are children of the caller's span, so the traces of external callers, like
+// an edge proxy, connect to the traces of the application.
+//
+// Use ExtractTraceContext for requests arriving at a Listener over protocols
+// other than HTTP, for example with the key-value metadata of a request:
+//
+//	lis, err := root.Listener("orders", weaver.ListenerOptions{})
+//	...
+//	for {
+//	    req, err := readRequest(conn)
+//	    ...
+//	    ctx := weaver.ExtractTraceContext(ctx, propagation.MapCarrier(req.Metadata))
+//	    reply, err := orders.Place(ctx, req.Order)
+//	    ...
+//	}
+//
+// For HTTP, use TraceHandler.
+func ExtractTraceContext(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return tracePropagator.Extract(ctx, carrier)
+}
+
+// TraceHandler returns an HTTP handler that traces the requests served by the
+// provided handler. Every request gets a server span with the provided name.
+// If the request has W3C trace context or baggage headers (traceparent,
+// tracestate, and baggage), the span is a child of the caller's span and
+// carries its baggage, so the traces of external callers, like an edge proxy,
+// connect to the traces of the application. For example:
+//
+//	lis, err := root.Listener("frontend", weaver.ListenerOptions{})
+//	...
+//	http.Serve(lis, weaver.TraceHandler("frontend", mux))
+//
+// TraceHandler can be used in place of otelhttp.NewHandler, which uses the
+// global propagator, and thus only honors W3C trace context and baggage if
+// the application hasn't replaced it.
+func TraceHandler(name string, handler http.Handler) http.Handler {
+	return otelhttp.NewHandler(handler, name, otelhttp.WithPropagators(tracePropagator))
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+func TestExtractTraceContext(t *testing.T) {
+	ctx := ExtractTraceContext(context.Background(), propagation.MapCarrier{
+		"traceparent": testTraceParent,
+		"baggage":     "tenant=acme",
+	})
+	sc := trace.SpanContextFromContext(ctx)
+	if got := sc.TraceID().String(); got != testTraceID {
+		t.Errorf("trace id: got %q, want %q", got, testTraceID)
+	}
+	if !sc.IsRemote() {
+		t.Error("span context: got local, want remote")
+	}
+	if got, want := baggage.FromContext(ctx).Member("tenant").Value(), "acme"; got != want {
+		t.Errorf("baggage: got %q, want %q", got, want)
+	}
+}
+
+func TestTraceHandler(t *testing.T) {
+	var traceID, tenant string
+	handler := TraceHandler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = trace.SpanContextFromContext(r.Context()).TraceID().String()
+		tenant = baggage.FromContext(r.Context()).Member("tenant").Value()
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("traceparent", testTraceParent)
+	r.Header.Set("baggage", "tenant=acme")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if traceID != testTraceID {
+		t.Errorf("trace id: got %q, want %q", traceID, testTraceID)
+	}
+	if tenant != "acme" {
+		t.Errorf("baggage: got %q, want %q", tenant, "acme")
+	}
+}
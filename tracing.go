@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A SpanEnricher is called when a span is started, with the context the span
+// is started in and the new span, e.g., to add attributes to the span:
+//
+//	func addTenant(ctx context.Context, span trace.Span) {
+//	    if tenant, ok := weaver.MetadataFromContext(ctx)["tenant"]; ok {
+//	        span.SetAttributes(attribute.String("tenant", tenant))
+//	    }
+//	}
+//
+// The context is the parent of the span's context, so it carries the
+// caller's metadata, baggage, and principal, but not the span itself.
+// Enrichers run synchronously, on the path of every sampled span, and must
+// be fast.
+type SpanEnricher func(ctx context.Context, span trace.Span)
+
+var tracingHooks struct {
+	mu        sync.Mutex
+	attrs     []attribute.KeyValue
+	enrichers []SpanEnricher
+}
+
+// AddResourceAttributes registers attributes of the OpenTelemetry resource
+// of the process, e.g., service.version, deployment.environment, or the team
+// that owns the application. Resource attributes label every span and, when
+// exported over OTLP, every metric of the process. They are merged with the
+// attributes of the resource section of the [serviceweaver.tracing] config,
+// which take precedence, and can't override the attributes that Service
+// Weaver sets, like service.name.
+//
+// Like RegisterError, AddResourceAttributes must be called in every process,
+// before Init, typically in main or an init function.
+func AddResourceAttributes(attrs ...attribute.KeyValue) {
+	tracingHooks.mu.Lock()
+	defer tracingHooks.mu.Unlock()
+	tracingHooks.attrs = append(tracingHooks.attrs, attrs...)
+}
+
+// EnrichSpans registers an enricher that is called on every span started in
+// the process, including the spans that Service Weaver starts automatically
+// for component method calls and the spans of instrumented HTTP handlers.
+// Enrichers run in the order they are registered.
+//
+// Like RegisterError, EnrichSpans must be called in every process, before
+// Init, typically in main or an init function.
+func EnrichSpans(e SpanEnricher) {
+	tracingHooks.mu.Lock()
+	defer tracingHooks.mu.Unlock()
+	tracingHooks.enrichers = append(tracingHooks.enrichers, e)
+}
+
+// resourceAttributes returns the attributes registered with
+// AddResourceAttributes, followed by the provided config attributes, sorted
+// by key, so that the config attributes take precedence.
+func resourceAttributes(config map[string]string) []attribute.KeyValue {
+	tracingHooks.mu.Lock()
+	attrs := append([]attribute.KeyValue(nil), tracingHooks.attrs...)
+	tracingHooks.mu.Unlock()
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, attribute.String(k, config[k]))
+	}
+	return attrs
+}
+
+// newEnrichingProcessor returns a span processor that calls the enrichers
+// registered with EnrichSpans on every started span, or nil if there are no
+// enrichers.
+func newEnrichingProcessor() sdktrace.SpanProcessor {
+	tracingHooks.mu.Lock()
+	defer tracingHooks.mu.Unlock()
+	if len(tracingHooks.enrichers) == 0 {
+		return nil
+	}
+	return enrichingProcessor{append([]SpanEnricher(nil), tracingHooks.enrichers...)}
+}
+
+// enrichingProcessor is a span processor that calls a set of enrichers on
+// every started span.
+type enrichingProcessor struct {
+	enrichers []SpanEnricher
+}
+
+var _ sdktrace.SpanProcessor = enrichingProcessor{}
+
+// OnStart implements the sdktrace.SpanProcessor interface.
+func (p enrichingProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	for _, e := range p.enrichers {
+		e(ctx, span)
+	}
+}
+
+// OnEnd implements the sdktrace.SpanProcessor interface.
+func (enrichingProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown implements the sdktrace.SpanProcessor interface.
+func (enrichingProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush implements the sdktrace.SpanProcessor interface.
+func (enrichingProcessor) ForceFlush(context.Context) error { return nil }
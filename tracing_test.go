@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestResourceAttributes(t *testing.T) {
+	got := resourceAttributes(map[string]string{"team": "payments", "env": "prod"})
+	want := append(append([]attribute.KeyValue(nil), tracingHooks.attrs...),
+		attribute.String("env", "prod"),
+		attribute.String("team", "payments"),
+	)
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Errorf("resourceAttributes (-want +got):\n%s", diff)
+	}
+}
+
+func TestEnrichingProcessor(t *testing.T) {
+	// Add the tenant of the caller's metadata to every span.
+	recorder := tracetest.NewSpanRecorder()
+	processor := enrichingProcessor{[]SpanEnricher{
+		func(ctx context.Context, span trace.Span) {
+			if tenant, ok := MetadataFromContext(ctx)["tenant"]; ok {
+				span.SetAttributes(attribute.String("tenant", tenant))
+			}
+		},
+	}}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	ctx := WithMetadata(context.Background(), "tenant", "acme")
+	_, span := provider.Tracer("test").Start(ctx, "span")
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	want := attribute.String("tenant", "acme")
+	for _, attr := range spans[0].Attributes() {
+		if attr == want {
+			return
+		}
+	}
+	t.Errorf("attributes: got %v, want %v", spans[0].Attributes(), want)
+}
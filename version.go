@@ -0,0 +1,126 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+)
+
+var (
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	autoMarshalType     = reflect.TypeOf((*codegen.AutoMarshal)(nil)).Elem()
+)
+
+// methodVersion returns the version of the provided component method. The
+// version is a non-zero fingerprint of the method's signature. See
+// ErrVersionMismatch for a description of when two versions of a method are
+// compatible.
+func methodVersion(m reflect.Method) uint64 {
+	var b strings.Builder
+	b.WriteString(m.Name)
+	writeSignature(&b, m.Type, map[reflect.Type]bool{})
+	sum := sha256.Sum256([]byte(b.String()))
+	v := binary.LittleEndian.Uint64(sum[:])
+	if v == 0 {
+		// Zero means "unversioned" on the wire.
+		v = 1
+	}
+	return v
+}
+
+// writeSignature writes a canonical description of the layout of t to b.
+// seen holds the named types that have already been described, and is used
+// to terminate the description of recursive types.
+func writeSignature(b *strings.Builder, t reflect.Type, seen map[reflect.Type]bool) {
+	if t.PkgPath() != "" && t.Name() != "" {
+		// A named type. Describe the type by name and, the first time we
+		// encounter it, by its layout.
+		fmt.Fprintf(b, "%s.%s", t.PkgPath(), t.Name())
+		if seen[t] || selfSerializing(t) {
+			return
+		}
+		seen[t] = true
+		b.WriteString("=")
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		b.WriteString("*")
+		writeSignature(b, t.Elem(), seen)
+	case reflect.Slice:
+		b.WriteString("[]")
+		writeSignature(b, t.Elem(), seen)
+	case reflect.Array:
+		fmt.Fprintf(b, "[%d]", t.Len())
+		writeSignature(b, t.Elem(), seen)
+	case reflect.Map:
+		b.WriteString("map[")
+		writeSignature(b, t.Key(), seen)
+		b.WriteString("]")
+		writeSignature(b, t.Elem(), seen)
+	case reflect.Struct:
+		b.WriteString("struct{")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			b.WriteString(f.Name)
+			b.WriteString(" ")
+			writeSignature(b, f.Type, seen)
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	case reflect.Func:
+		b.WriteString("func(")
+		for i := 0; i < t.NumIn(); i++ {
+			writeSignature(b, t.In(i), seen)
+			b.WriteString(",")
+		}
+		b.WriteString(")(")
+		for i := 0; i < t.NumOut(); i++ {
+			writeSignature(b, t.Out(i), seen)
+			b.WriteString(",")
+		}
+		b.WriteString(")")
+	case reflect.Interface:
+		// Interfaces (e.g., context.Context, error) are not serialized
+		// structurally, so their name is enough.
+		if t.Name() == "" {
+			b.WriteString("interface")
+		}
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
+
+// selfSerializing returns whether the provided type provides its own
+// serialization, in which case its layout is not part of its version.
+//
+// AutoMarshal structs are self-serializing too: their generated encoding
+// tolerates added fields, so adding a field must not change the version of
+// the methods that use them. Other changes to their layout are caught by
+// the compatibility checks performed before a rollout (see internal/compat).
+func selfSerializing(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	if _, ok := pt.MethodByName("ProtoReflect"); ok {
+		return true
+	}
+	return pt.Implements(binaryMarshalerType) || pt.Implements(autoMarshalType)
+}
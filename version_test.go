@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+)
+
+type versionedV1 interface {
+	Foo(context.Context, versionArgsV1) error
+	Bar(context.Context, int) (string, error)
+}
+
+type versionArgsV1 struct {
+	A int
+	B string
+}
+
+type versionedV2 interface {
+	Foo(context.Context, versionArgsV2) error
+	Bar(ctx context.Context, renamed int) (string, error)
+	Baz(context.Context) error
+}
+
+type versionArgsV2 struct {
+	A int
+	B string
+	C bool // added field
+}
+
+type evolvingArgs struct {
+	AutoMarshal
+	A int
+}
+
+type versionedRecursive interface {
+	Foo(context.Context, *versionList) (time.Time, error)
+}
+
+type versionList struct {
+	Val  int
+	Next *versionList
+}
+
+func version(t *testing.T, iface reflect.Type, name string) uint64 {
+	t.Helper()
+	m, ok := iface.MethodByName(name)
+	if !ok {
+		t.Fatalf("method %s not found", name)
+	}
+	return methodVersion(m)
+}
+
+func TestMethodVersion(t *testing.T) {
+	v1 := reflect.TypeOf((*versionedV1)(nil)).Elem()
+	v2 := reflect.TypeOf((*versionedV2)(nil)).Elem()
+
+	// Changing the layout of an argument changes the version.
+	if version(t, v1, "Foo") == version(t, v2, "Foo") {
+		t.Error("Foo: unexpected matching versions")
+	}
+
+	// Renaming arguments and adding methods doesn't change the version.
+	if version(t, v1, "Bar") != version(t, v2, "Bar") {
+		t.Error("Bar: unexpected mismatched versions")
+	}
+
+	// AutoMarshal structs are described by name, so that adding a field
+	// doesn't change the version.
+	var b strings.Builder
+	writeSignature(&b, reflect.TypeOf(evolvingArgs{}), map[reflect.Type]bool{})
+	if got, want := b.String(), "github.com/ServiceWeaver/weaver.evolvingArgs"; got != want {
+		t.Errorf("evolvingArgs: got signature %q, want %q", got, want)
+	}
+
+	// Versions of recursive types are computed.
+	rec := reflect.TypeOf((*versionedRecursive)(nil)).Elem()
+	if version(t, rec, "Foo") == 0 {
+		t.Error("Foo: unexpected zero version")
+	}
+}
+
+func TestWrapVersionMismatch(t *testing.T) {
+	err := fmt.Errorf("%w: method foo", call.VersionMismatch)
+	s := stub{}
+	if got := s.WrapError(err); !errors.Is(got, ErrVersionMismatch) {
+		t.Fatalf("errors.Is(%v, ErrVersionMismatch) = false, want true", got)
+	}
+	if got := s.WrapError(errors.New("other")); errors.Is(got, ErrVersionMismatch) {
+		t.Fatalf("errors.Is(%v, ErrVersionMismatch) = true, want false", got)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/runtime/retry"
+)
+
+// warmer is implemented by component implementations that have a Warmup
+// method. For example:
+//
+//	func (c *currency) Warmup(ctx context.Context) error {
+//	    return c.loadRates(ctx)
+//	}
+//
+// A weavelet calls the Warmup method of a local component in the background,
+// once its Init method returns, and reports itself as STARTING until Warmup
+// returns. The component is ready once Warmup returns nil. See
+// runtime.StartupConfig.After.
+type warmer interface {
+	Warmup(context.Context) error
+}
+
+// readiness records whether a component is ready. The zero value is not ready.
+type readiness struct {
+	mu    sync.Mutex
+	done  chan struct{} // closed once ready or failed; allocated lazily
+	err   error         // the reason the component failed to become ready
+	ready bool          // is the component ready?
+}
+
+// wait returns the channel that is closed once the component is ready or has
+// failed to become ready.
+func (r *readiness) wait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done == nil {
+		r.done = make(chan struct{})
+	}
+	return r.done
+}
+
+// set records that the component is ready, if err is nil, or that it failed
+// to become ready otherwise.
+func (r *readiness) set(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done == nil {
+		r.done = make(chan struct{})
+	}
+	r.err = err
+	r.ready = err == nil
+	close(r.done)
+}
+
+// get returns whether the component is ready, and the reason it failed to
+// become ready, if it did.
+func (r *readiness) get() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready, r.err
+}
+
+// warmup runs the Warmup method of the provided local component, if any, in
+// the background, and records when the component is ready.
+func (w *weavelet) warmup(c *component, obj any) {
+	wm, ok := obj.(warmer)
+	if !ok {
+		c.ready.set(nil)
+		return
+	}
+	w.warming.Add(1)
+	go func() {
+		defer w.warming.Add(-1)
+		w.env.SystemLogger().Debug("Warming up component", "component", c.info.Name)
+		start := time.Now()
+		if err := wm.Warmup(w.ctx); err != nil {
+			err = fmt.Errorf("component %q warmup failed: %w", c.info.Name, err)
+			w.env.SystemLogger().Error("Warming up component failed", err, "component", c.info.Name)
+			w.initFailed.Store(true)
+			c.ready.set(err)
+			return
+		}
+		w.env.SystemLogger().Debug("Warming up component succeeded", "component", c.info.Name, "latency", time.Since(start))
+		c.ready.set(nil)
+	}()
+}
+
+// awaitDependencies waits until the components that the provided component
+// must start after, per its runtime.StartupConfig, are ready, in this process
+// or another one.
+func (w *weavelet) awaitDependencies(c *component) error {
+	for _, name := range w.startupConfigs[c.info.Name].After {
+		dep, err := w.getComponent(name)
+		if err != nil {
+			return fmt.Errorf("component %q: start after %q: %w", c.info.Name, name, err)
+		}
+		w.env.SystemLogger().Debug("Waiting for component to be ready", "component", c.info.Name, "after", name)
+		if err := w.awaitReady(dep); err != nil {
+			return fmt.Errorf("component %q: start after %q: %w", c.info.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// awaitReady starts the provided component, if needed, and waits until it is
+// ready.
+func (w *weavelet) awaitReady(c *component) error {
+	if _, ok := w.imports[c.info.Name]; ok {
+		// The readiness of imported components is up to the deployment that
+		// exports them.
+		return nil
+	}
+	if err := w.register(c); err != nil {
+		return err
+	}
+	if c.local.Read() {
+		if _, err := w.getImpl(c); err != nil {
+			return err
+		}
+		select {
+		case <-c.ready.wait():
+			_, err := c.ready.get()
+			return err
+		case <-w.ctx.Done():
+			return w.ctx.Err()
+		}
+	}
+
+	stub, err := w.getStub(c)
+	if err != nil {
+		return err
+	}
+	for r := retry.Begin(); r.Continue(w.ctx); {
+		_, err := stub.stub.client.Call(w.ctx, readyMethodKey, []byte(c.info.Name), call.CallOptions{})
+		if err == nil {
+			return nil
+		}
+	}
+	return w.ctx.Err()
+}
+
+// componentReady returns nil if the named component is ready in this
+// weavelet, or an error explaining why it isn't. It serves the calls to the
+// "ready" method that name a component.
+func (w *weavelet) componentReady(name string) error {
+	c, ok := w.componentsByName[name]
+	if !ok {
+		return fmt.Errorf("component %q not found", name)
+	}
+	ready, err := c.ready.get()
+	switch {
+	case err != nil:
+		return err
+	case !ready:
+		return fmt.Errorf("component %q is not ready", name)
+	}
+	return nil
+}
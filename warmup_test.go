@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+// warmupGate is a component implementation whose Warmup method returns the
+// error sent on its channel.
+type warmupGate chan error
+
+func (g warmupGate) Warmup(ctx context.Context) error {
+	select {
+	case err := <-g:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// testWarmupWeavelet returns a weavelet hosting the provided local
+// components, which are already registered and constructed.
+func testWarmupWeavelet(t *testing.T, startup map[string]runtime.StartupConfig, names ...string) *weavelet {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w := &weavelet{
+		ctx:              ctx,
+		env:              leaseEnv{t: t},
+		info:             &protos.EnvelopeInfo{Id: "a"},
+		startupConfigs:   startup,
+		componentsByName: map[string]*component{},
+	}
+	for _, name := range names {
+		c := &component{wlet: w, info: &codegen.Registration{Name: name}}
+		c.registerInit.Do(func() {})
+		c.implInit.Do(func() {})
+		c.local.TryWrite(true)
+		w.componentsByName[name] = c
+	}
+	return w
+}
+
+func TestWarmup(t *testing.T) {
+	// Test plan: Warm up a component, and check that the weavelet is
+	// STARTING, and the component isn't ready, until Warmup returns.
+	w := testWarmupWeavelet(t, nil, "a/T")
+	c := w.componentsByName["a/T"]
+	gate := make(warmupGate)
+	w.warmup(c, gate)
+
+	if got := w.Health().Status; got != protos.HealthStatus_STARTING {
+		t.Fatalf("Health while warming up: got %v, want STARTING", got)
+	}
+	if err := w.componentReady("a/T"); err == nil {
+		t.Fatal("componentReady while warming up: unexpected success")
+	}
+
+	gate <- nil
+	<-c.ready.wait()
+	if err := w.componentReady("a/T"); err != nil {
+		t.Fatalf("componentReady: %v", err)
+	}
+	for w.warming.Load() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := w.Health().Status; got != protos.HealthStatus_HEALTHY {
+		t.Fatalf("Health: got %v, want HEALTHY", got)
+	}
+}
+
+func TestWarmupFails(t *testing.T) {
+	// Test plan: Fail the warmup of a component, and check that the
+	// component never becomes ready and the weavelet is UNHEALTHY.
+	w := testWarmupWeavelet(t, nil, "a/T")
+	c := w.componentsByName["a/T"]
+	gate := make(warmupGate, 1)
+	gate <- errors.New("no rates")
+	w.warmup(c, gate)
+	<-c.ready.wait()
+	if err := w.componentReady("a/T"); err == nil {
+		t.Fatal("componentReady: unexpected success")
+	}
+	if got := w.Health().Status; got != protos.HealthStatus_UNHEALTHY {
+		t.Fatalf("Health: got %v, want UNHEALTHY", got)
+	}
+}
+
+func TestWarmupNone(t *testing.T) {
+	// A component without a Warmup method is ready right away.
+	w := testWarmupWeavelet(t, nil, "a/T")
+	w.warmup(w.componentsByName["a/T"], struct{}{})
+	if err := w.componentReady("a/T"); err != nil {
+		t.Fatalf("componentReady: %v", err)
+	}
+}
+
+func TestAwaitDependencies(t *testing.T) {
+	// Test plan: Declare that a component starts after another one, and
+	// check that awaitDependencies waits until the other one is ready.
+	startup := map[string]runtime.StartupConfig{
+		"a/Frontend": {After: []string{"a/Currency"}},
+	}
+	w := testWarmupWeavelet(t, startup, "a/Frontend", "a/Currency")
+	currency := w.componentsByName["a/Currency"]
+	gate := make(warmupGate)
+	w.warmup(currency, gate)
+
+	done := make(chan error, 1)
+	go func() { done <- w.awaitDependencies(w.componentsByName["a/Frontend"]) }()
+	select {
+	case err := <-done:
+		t.Fatalf("awaitDependencies returned %v before the dependency was ready", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	gate <- nil
+	if err := <-done; err != nil {
+		t.Fatalf("awaitDependencies: %v", err)
+	}
+
+	// Components without dependencies don't wait.
+	if err := w.awaitDependencies(currency); err != nil {
+		t.Fatalf("awaitDependencies: %v", err)
+	}
+}
+
+func TestAwaitDependenciesUnknown(t *testing.T) {
+	startup := map[string]runtime.StartupConfig{
+		"a/Frontend": {After: []string{"a/Missing"}},
+	}
+	w := testWarmupWeavelet(t, startup, "a/Frontend")
+	if err := w.awaitDependencies(w.componentsByName["a/Frontend"]); err == nil {
+		t.Fatal("awaitDependencies: unexpected success")
+	}
+}
@@ -16,6 +16,7 @@ package weaver
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -23,18 +24,25 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ServiceWeaver/weaver/internal/envelope/conn"
+	"github.com/ServiceWeaver/weaver/internal/listeners"
+	"github.com/ServiceWeaver/weaver/internal/logexport"
+	"github.com/ServiceWeaver/weaver/internal/mtls"
 	"github.com/ServiceWeaver/weaver/internal/net/call"
+	"github.com/ServiceWeaver/weaver/internal/otlp"
+	"github.com/ServiceWeaver/weaver/internal/profexport"
+	"github.com/ServiceWeaver/weaver/internal/pubsub"
 	"github.com/ServiceWeaver/weaver/internal/traceio"
+	"github.com/ServiceWeaver/weaver/metrics"
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"github.com/ServiceWeaver/weaver/runtime/logging"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
 	"github.com/ServiceWeaver/weaver/runtime/retry"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
@@ -46,20 +54,125 @@ import (
 // readyMethodKey holds the key for a method used to check if a backend is ready.
 var readyMethodKey = call.MakeMethodKey("", "ready")
 
+type initLabels struct {
+	Component string // the initialized component
+}
+
+type servingLabels struct {
+	Component string // full component name
+}
+
+var (
+	componentInitLatencyMicros = metrics.NewHistogramMap[initLabels](
+		"serviceweaver_component_init_latency_micros",
+		"Duration, in microseconds, of constructing and initializing a Service Weaver component",
+		metrics.NonNegativeBuckets,
+	)
+	coldStartLatencyMicros = metrics.NewHistogram(
+		"serviceweaver_weavelet_cold_start_latency_micros",
+		"Duration, in microseconds, from the start of a Service Weaver process until its components are initialized",
+		metrics.NonNegativeBuckets,
+	)
+	componentServingCalls = metrics.NewGaugeMap[servingLabels](
+		"serviceweaver_component_serving_calls",
+		"Number of Service Weaver component method calls being served by the weavelet, including the calls waiting to be admitted",
+	)
+)
+
 // A weavelet runs and manages components. As the name suggests, a weavelet is
 // analogous to a kubelet.
 type weavelet struct {
 	ctx       context.Context
-	env       env                  // Manages interactions with execution environment
-	info      *protos.EnvelopeInfo // Setup info sent by the deployer.
-	transport *transport           // Transport for cross-weavelet communication
-	dialAddr  string               // Address this weavelet is reachable at
-	tracer    trace.Tracer         // Tracer for this weavelet
+	env       env                     // Manages interactions with execution environment
+	info      *protos.EnvelopeInfo    // Setup info sent by the deployer.
+	transport *transport              // Transport for cross-weavelet communication
+	dialAddr  string                  // Address this weavelet is reachable at
+	metrics   string                  // URL of the Prometheus metrics endpoint, if any
+	tracer    trace.Tracer            // Tracer for this weavelet
+	tracers   map[string]trace.Tracer // per-component tracers, by full component name
+
+	methodConfigs    map[string]runtime.MethodConfig    // per-method config, by full method name
+	componentConfigs map[string]runtime.CallConfig      // per-component call config, by full component name
+	breakerConfigs   map[string]runtime.BreakerConfig   // per-component circuit breakers, by full component name
+	admissionConfigs map[string]runtime.AdmissionConfig // per-component admission control, by full component name
+	startupConfigs   map[string]runtime.StartupConfig   // per-component startup policies, by full component name
+	authConfigs      map[string]runtime.AuthConfig      // per-listener auth config, by listener name
+	listenerConfigs  map[string]runtime.ListenerConfig  // per-listener connection limits, by listener name
+	accessConfigs    map[string]runtime.AccessConfig    // per-component access policies, by full caller name
+	callerPriority   map[string]Priority                // priorities of the calls made by components, by full caller name
+	codecs           map[string]codegen.Codec           // per-component codecs, by full component name
+	fakes            map[reflect.Type]any               // fake component implementations, by interface type
+	loadBalancing    string                             // see runtime.WeaveletConfig
+	metricsAddr      string                             // see runtime.WeaveletConfig
+	otlp             runtime.OTLPConfig                 // see runtime.WeaveletConfig
+	metricBackends   map[string]runtime.MetricsConfig   // see runtime.WeaveletConfig.Metrics
+	federation       runtime.FederationConfig           // see runtime.WeaveletConfig
+	imports          map[string]runtime.ImportConfig    // see runtime.WeaveletConfig
+	resource         *resource.Resource                 // describes the weavelet in traces and metrics
+	mutualTLS        bool                               // see runtime.WeaveletConfig
+	quic             *call.QUICTransport                // dials other weavelets, if the transport is QUIC
+	cache            *methodCache                       // cache of method results
+	chaos            chaos                              // faults injected by the deployer
+	recorder         *callRecorder                      // records remote calls, or nil
+	logs             *logShipper                        // exports log entries, or nil
+	profiler         *profiler                          // continuous profiler, or nil
+	crashes          *crashReporter                     // reports panics serving remote calls
+
+	// Fault injectors, by component interface type. See runtime.Bootstrap.
+	faults map[reflect.Type]func(ctx context.Context, method string) error
+
+	credsOnce sync.Once         // used to get creds
+	creds     *mtls.Credentials // credentials for mutual TLS, if mutualTLS
+	credsErr  error             // error getting creds
 
 	root             *component                  // The automatically created "root" component
 	componentsByName map[string]*component       // component name -> component
 	componentsByType map[reflect.Type]*component // component type -> component
 
+	startOnce  sync.Once                     // used to run startHooks
+	startMu    sync.Mutex                    // guards started and startHooks
+	started    bool                          // have the start hooks been run?
+	startHooks []func(context.Context) error // hooks registered with OnStart
+
+	topics          *topics          // subscriptions created with Subscribe
+	messageHandlers *messageHandlers // handlers registered with Topic.Subscribe
+	jobs            *jobs            // jobs registered with Cron
+	elections       *elections       // elections created with NewLeaderElection
+	actors          *actorNames      // actors created with NewActors
+	workflows       *workflowNames   // workflows created with NewWorkflow
+	queues          *queueProcessors // queues processed with Queue.Process
+	stores          *stores          // backends of the stores created with NewStore
+	externals       externals        // clients of the external services
+	caches          *caches          // entries of the caches created with NewCache
+	callbacks       *callbacks       // handlers registered with Callback.Register
+	flags           *Flags           // feature flags, set by the deployer
+	clock           Clock            // see ClockOf
+
+	configMu sync.Mutex        // guards sections, and the config of components
+	sections map[string]string // config sections; see SetConfig
+
+	drainGracePeriod time.Duration                 // see runtime.WeaveletConfig
+	maxDrainTime     time.Duration                 // see runtime.WeaveletConfig
+	calls            callTracker                   // component method calls being served
+	drainOnce        sync.Once                     // used to drain the weavelet
+	draining         atomic.Bool                   // has draining started?
+	drainMu          sync.Mutex                    // guards drainHooks and listeners
+	drainHooks       []func(context.Context) error // hooks registered with OnDrain
+	listeners        []namedListener               // listeners created with Listener
+	shutdownTimeout  time.Duration                 // see runtime.WeaveletConfig
+	shutdownMu       sync.Mutex                    // guards shutdowns
+	shutdowns        []*component                  // local components with a Shutdown method, in creation order
+
+	health              healthChecks  // health checks of the local components
+	healthCheckInterval time.Duration // see runtime.WeaveletConfig
+	healthCheckTimeout  time.Duration // see runtime.WeaveletConfig
+
+	created       time.Time    // when the weavelet was created
+	initializing  atomic.Int32 // number of UpdateComponents being initialized
+	initFailed    atomic.Bool  // did a component fail to initialize?
+	warming       atomic.Int32 // number of components warming up
+	coldStartOnce sync.Once    // used to record the cold start latency
+
 	// TODO(mwhittaker): We have one client for every component. Every client
 	// independently maintains network connections to every weavelet hosting
 	// the component. Thus, there may be many redundant network connections to
@@ -91,8 +204,20 @@ func newWeavelet(ctx context.Context, componentInfos []*codegen.Registration) (*
 	byType := make(map[reflect.Type]*component, len(componentInfos))
 	w := &weavelet{
 		ctx:              ctx,
+		created:          time.Now(),
 		componentsByName: byName,
 		componentsByType: byType,
+		topics:           newTopics(),
+		messageHandlers:  newMessageHandlers(),
+		jobs:             newJobs(),
+		elections:        newElections(),
+		actors:           newActorNames(),
+		workflows:        newWorkflowNames(),
+		queues:           newQueueProcessors(),
+		caches:           newCaches(),
+		callbacks:        newCallbacks(),
+		flags:            newFlags(ctx),
+		clock:            systemClock{},
 		tcpClients:       map[string]*client{},
 	}
 
@@ -109,6 +234,67 @@ func newWeavelet(ctx context.Context, componentInfos []*codegen.Registration) (*
 		return nil, fmt.Errorf("unable to get weavelet information")
 	}
 	w.info = info
+	w.configMu.Lock()
+	w.sections = info.Sections
+	w.configMu.Unlock()
+
+	config, err := runtime.ParseWeaveletConfig(info.Sections)
+	if err != nil {
+		return nil, err
+	}
+	cacheMaxBytes := config.CacheMaxBytes
+	if cacheMaxBytes == 0 {
+		cacheMaxBytes = defaultCacheMaxBytes
+	}
+	w.methodConfigs = config.Methods
+	w.componentConfigs = config.Components
+	w.breakerConfigs = config.Breakers
+	w.admissionConfigs = config.Admission
+	w.startupConfigs = config.Startup
+	w.authConfigs = config.Auth
+	w.listenerConfigs = config.Listeners
+	w.accessConfigs = config.Access
+	w.callerPriority = map[string]Priority{}
+	for caller, p := range config.Priorities {
+		w.callerPriority[caller] = parsePriority(p)
+	}
+	w.codecs = map[string]codegen.Codec{}
+	for component, name := range config.Codecs {
+		codec, err := codegen.GetCodec(name)
+		if err != nil {
+			return nil, fmt.Errorf("component %q: %w", component, err)
+		}
+		w.codecs[component] = codec
+	}
+	w.loadBalancing = config.LoadBalancing
+	w.metricsAddr = config.MetricsAddress
+	w.otlp = config.OTLP
+	w.metricBackends = config.Metrics
+	w.federation = config.Federation
+	w.imports = config.Imports
+	w.mutualTLS = config.MTLS
+	if config.Transport == "quic" {
+		if !call.QUICEnabled {
+			return nil, fmt.Errorf(`transport "quic" requires building the application with "-tags quic"`)
+		}
+		w.quic = call.NewQUICTransport(w.quicConfig)
+	}
+	w.cache = newMethodCache(cacheMaxBytes)
+	w.recorder = newCallRecorder(config.Recording, info.Id, env.SystemLogger())
+	exporters, closeExporters, err := logexport.New(config.Logging, info.App, info.Id)
+	if err != nil {
+		return nil, fmt.Errorf("log exporters: %w", err)
+	}
+	w.logs = newLogShipper(append(exporters, registeredLogExporters()...), closeExporters, env.SystemLogger())
+	w.profiler = newProfiler(config.Profiling, env.SendProfile, profexport.New(config.Profiling, info.App, info.DeploymentId, info.Id), env.SystemLogger())
+	w.crashes = &crashReporter{send: env.SendCrashReport, logger: env.SystemLogger()}
+	w.stores = newStores(config.Stores)
+	w.externals.configs = config.External
+	w.drainGracePeriod = config.DrainGracePeriod
+	w.maxDrainTime = config.MaxDrainTime
+	w.shutdownTimeout = config.ShutdownTimeout
+	w.healthCheckInterval = config.HealthCheckInterval
+	w.healthCheckTimeout = config.HealthCheckTimeout
 
 	for _, info := range componentInfos {
 		c := &component{
@@ -118,6 +304,7 @@ func newWeavelet(ctx context.Context, componentInfos []*codegen.Registration) (*
 			// Discard all log entries.
 			logger: slog.New(slog.HandlerOptions{Level: slog.LevelError + 1}.NewTextHandler(os.Stdout)),
 		}
+		c.logLevel.Set(slog.LevelDebug)
 		byName[info.Name] = c
 		byType[info.Iface] = c
 	}
@@ -125,42 +312,110 @@ func newWeavelet(ctx context.Context, componentInfos []*codegen.Registration) (*
 	if !ok {
 		return nil, fmt.Errorf("internal error: no main component registered")
 	}
+
+	bootstrap, err := runtime.GetBootstrap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for t := range bootstrap.TestFakes {
+		if c, ok := byType[t]; !ok || c == main {
+			return nil, fmt.Errorf("fake for %v: not a component", t)
+		}
+	}
+	w.fakes = bootstrap.TestFakes
+	for t := range bootstrap.TestFaults {
+		if c, ok := byType[t]; !ok || c == main {
+			return nil, fmt.Errorf("faults for %v: not a component", t)
+		}
+	}
+	w.faults = bootstrap.TestFaults
+	if w.clock, err = bootstrapClock(bootstrap); err != nil {
+		return nil, err
+	}
 	main.impl = &componentImpl{component: main}
 
 	const instrumentationLibrary = "github.com/ServiceWeaver/weaver/serviceweaver"
 	const instrumentationVersion = "0.0.1"
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(env.CreateTraceExporter()),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(fmt.Sprintf("serviceweaver/%s", info.Id)),
-			semconv.ProcessPIDKey.Int(os.Getpid()),
-			traceio.AppNameTraceKey.String(info.App),
-			traceio.VersionTraceKey.String(info.DeploymentId),
-		)),
-		// TODO(spetrovic): Allow the user to create new TracerProviders where
-		// they can control trace sampling and other options.
-		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())))
+	// The attributes set by Service Weaver come last, so that they take
+	// precedence over the application's.
+	attrs := resourceAttributes(config.Tracing.Resource)
+	attrs = append(attrs,
+		semconv.ServiceNameKey.String(fmt.Sprintf("serviceweaver/%s", info.Id)),
+		semconv.ProcessPIDKey.Int(os.Getpid()),
+		traceio.AppNameTraceKey.String(info.App),
+		traceio.VersionTraceKey.String(info.DeploymentId),
+	)
+	if info.Region != "" {
+		attrs = append(attrs, traceio.RegionTraceKey.String(info.Region))
+	}
+	w.resource = resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	// Every sampler gets its own TracerProvider. The providers share their
+	// span processors, so that all spans are exported together.
+	var processors []sdktrace.SpanProcessor
+	if p := newEnrichingProcessor(); p != nil {
+		processors = append(processors, p)
+	}
+	processors = append(processors, sdktrace.NewBatchSpanProcessor(env.CreateTraceExporter()))
+	if config.OTLP.Endpoint != "" {
+		exporter, err := otlp.NewTraceExporter(ctx, config.OTLP)
+		if err != nil {
+			return nil, fmt.Errorf("OTLP trace exporter: %w", err)
+		}
+		processors = append(processors, sdktrace.NewBatchSpanProcessor(exporter))
+	}
+	newTracerProvider := func(sampling runtime.SamplingConfig) *sdktrace.TracerProvider {
+		opts := []sdktrace.TracerProviderOption{
+			sdktrace.WithResource(w.resource),
+			sdktrace.WithSampler(newSampler(sampling)),
+		}
+		for _, p := range processors {
+			opts = append(opts, sdktrace.WithSpanProcessor(p))
+		}
+		return sdktrace.NewTracerProvider(opts...)
+	}
+	sampling := config.Tracing.SamplingConfig
+	if sampling == (runtime.SamplingConfig{}) {
+		sampling.Ratio = config.OTLP.SamplingRate
+	}
+	tracerProvider := newTracerProvider(sampling)
 	tracer := tracerProvider.Tracer(instrumentationLibrary, trace.WithInstrumentationVersion(instrumentationVersion))
+	w.tracers = map[string]trace.Tracer{}
+	for name, sampling := range config.Tracing.Components {
+		w.tracers[name] = newTracerProvider(sampling).Tracer(instrumentationLibrary, trace.WithInstrumentationVersion(instrumentationVersion))
+	}
 
 	// Set global tracing defaults.
 	otel.SetTracerProvider(tracerProvider)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTextMapPropagator(tracePropagator)
 
+	codec, err := call.ParseCodec(config.Compression)
+	if err != nil {
+		return nil, err
+	}
 	w.transport = &transport{
 		clientOpts: call.ClientOptions{
 			Logger:            env.SystemLogger(),
 			WriteFlattenLimit: 4 << 10,
+			Compression:       call.Compression{Codec: codec, Threshold: config.CompressionThreshold},
+			MaxInFlightCalls:  config.MaxInFlightCalls,
+			MaxPendingCalls:   config.MaxPendingCalls,
 		},
 		serverOpts: call.ServerOptions{
 			Logger:                env.SystemLogger(),
 			Tracer:                tracer,
 			InlineHandlerDuration: 20 * time.Microsecond,
 			WriteFlattenLimit:     4 << 10,
+			MaxConcurrentCalls:    config.MaxConcurrentCalls,
+			MaxQueuedCalls:        config.MaxQueuedCalls,
+			MaxInFlightCalls:      config.MaxInFlightCalls,
+			MaxPendingCalls:       config.MaxPendingCalls,
+			OnShed: func(p call.Priority) {
+				shedCalls.Get(shedLabels{Priority: p.String()}).Add(1)
+			},
 		},
 	}
 	w.tracer = tracer
-	main.tracer = tracer
+	main.tracer = w.componentTracer(main.info.Name)
 	w.root = main
 
 	return w, nil
@@ -192,11 +447,18 @@ func (w *weavelet) start() (Instance, error) {
 	for _, c := range w.componentsByName {
 		w.addHandlers(handlers, c)
 	}
-	// Add a dummy "ready" handler. Clients will repeatedly call this RPC until
-	// it responds successfully, ensuring the server is ready.
-	handlers.Set("", "ready", func(context.Context, []byte) ([]byte, error) {
-		return nil, nil
+	// Add a "ready" handler. Clients will repeatedly call this RPC until it
+	// responds successfully, ensuring the server is ready. Calls that name a
+	// component also wait for the component to be ready; see
+	// awaitDependencies.
+	handlers.Set("", "ready", func(_ context.Context, args []byte) ([]byte, error) {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return nil, w.componentReady(string(args))
 	})
+	// Add a handler for the callbacks registered in this weavelet.
+	handlers.Set(callbackComponent, "invoke", w.serveCallback)
 
 	if w.info.RunMain {
 		// Set appropriate logger and tracer for main.
@@ -207,7 +469,8 @@ func (w *weavelet) start() (Instance, error) {
 				Component:  w.root.info.Name,
 				Weavelet:   w.info.Id,
 			},
-			Write: w.env.CreateLogSaver(),
+			Write: w.createLogSaver(),
+			Level: &w.root.logLevel,
 		})
 	}
 
@@ -224,8 +487,13 @@ func (w *weavelet) start() (Instance, error) {
 	if remote, ok := w.env.(*remoteEnv); ok {
 		startWork(w.ctx, "serve weavelet conn", remote.conn.Serve)
 
-		lis := remote.conn.Listener()
-		addr := fmt.Sprintf("tcp://%s", lis.Addr().String())
+		var addr string
+		if w.quic != nil {
+			addr = fmt.Sprintf("quic://%s", remote.conn.PacketConn().LocalAddr().String())
+		} else {
+			lisAddr := remote.conn.Listener().Addr()
+			addr = fmt.Sprintf("%s://%s", lisAddr.Network(), lisAddr.String())
+		}
 		w.dialAddr = addr
 		for _, c := range w.componentsByName {
 			if c.info.Routed {
@@ -235,10 +503,53 @@ func (w *weavelet) start() (Instance, error) {
 		}
 
 		startWork(w.ctx, "handle calls", func() error {
-			return call.Serve(w.ctx, lis, handlers, w.transport.serverOpts)
+			opts := w.transport.serverOpts
+			var config *tls.Config
+			if w.mutualTLS {
+				creds, err := w.credentials()
+				if err != nil {
+					return err
+				}
+				config = creds.ServerConfig()
+			}
+			if w.quic != nil {
+				// QUIC performs the TLS handshake itself.
+				lis, err := call.ListenQUIC(remote.conn.PacketConn(), config)
+				if err != nil {
+					return err
+				}
+				return call.Serve(w.ctx, lis, handlers, opts)
+			}
+			opts.TLSConfig = config
+			return call.Serve(w.ctx, remote.conn.Listener(), handlers, opts)
 		})
 	}
 
+	if w.metricsAddr != "" {
+		url, err := w.serveMetrics(w.metricsAddr)
+		if err != nil {
+			return nil, err
+		}
+		w.metrics = url
+	}
+
+	if w.otlp.Endpoint != "" {
+		if err := w.exportMetrics(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.exportMetricsToBackends(); err != nil {
+		return nil, err
+	}
+
+	if w.info.RunMain && w.federation.Address != "" {
+		if err := w.serveFederation(); err != nil {
+			return nil, err
+		}
+	}
+
+	go w.profiler.run(w.ctx)
+
 	w.logRolodexCard()
 
 	// Every Service Weaver process launches a watchComponentsToStart goroutine that
@@ -289,6 +600,9 @@ func (w *weavelet) logRolodexCard() {
 		fmt.Sprintf("   address    : %s", w.dialAddr),
 		fmt.Sprintf("   pid        : %v ", os.Getpid()),
 	}
+	if w.metrics != "" {
+		lines = append(lines, fmt.Sprintf("   metrics    : %s ", w.metrics))
+	}
 
 	width := len(header)
 	for _, line := range lines {
@@ -308,9 +622,51 @@ func (w *weavelet) logRolodexCard() {
 
 // getInstance returns an instance of the provided component. If the component
 // is local, the returned instance is local. Otherwise, it's a network client.
-// requester is the name of the requesting component.
+// If the component is lazy, it is started on the first call to the returned
+// instance. requester is the name of the requesting component.
 func (w *weavelet) getInstance(c *component, requester string) (interface{}, error) {
-	// Register the component.
+	// Fake components are never started; every process uses the fake.
+	if fake, ok := w.fakes[c.info.Iface]; ok {
+		return fake, nil
+	}
+
+	// Imported components are never started; every process calls the
+	// deployment that exports them.
+	if _, ok := w.imports[c.info.Name]; ok {
+		stub, err := w.clientStub(c)
+		if err != nil {
+			return nil, err
+		}
+		return c.info.ClientStubFn(w.callerStub(stub, requester), requester, w.codecs[c.info.Name]), nil
+	}
+
+	if w.startupConfigs[c.info.Name].Lazy {
+		// Don't start the component until it is first called.
+		return c.info.ClientStubFn(w.callerStub(newLazyStub(c.info.Name, w.componentTracer(c.info.Name), func() (codegen.Stub, error) {
+			return w.clientStub(c)
+		}), requester), requester, w.codecs[c.info.Name]), nil
+	}
+
+	if err := w.register(c); err != nil {
+		return nil, err
+	}
+	if _, faulty := w.faults[c.info.Iface]; c.local.Read() && !faulty {
+		impl, err := w.getImpl(c)
+		if err != nil {
+			return nil, err
+		}
+		return c.info.LocalStubFn(impl.impl, impl.component.tracer), nil
+	}
+	stub, err := w.clientStub(c)
+	if err != nil {
+		return nil, err
+	}
+	return c.info.ClientStubFn(w.callerStub(stub, requester), requester, w.codecs[c.info.Name]), nil
+}
+
+// register asks the deployer to start the provided component, if it hasn't
+// been asked already.
+func (w *weavelet) register(c *component) error {
 	c.registerInit.Do(func() {
 		w.env.SystemLogger().Debug("Registering component...", "component", c.info.Name)
 		errMsg := fmt.Sprintf("cannot register component %q to start", c.info.Name)
@@ -323,23 +679,69 @@ func (w *weavelet) getInstance(c *component, requester string) (interface{}, err
 			w.env.SystemLogger().Debug("Registering component succeeded", "component", c.info.Name)
 		}
 	})
-	if c.registerErr != nil {
-		return nil, c.registerErr
+	return c.registerErr
+}
+
+// clientStub starts the provided component, if needed, and returns a
+// codegen.Stub that calls it. Calls to a local component go through its
+// server stub, as if it were remote.
+func (w *weavelet) clientStub(c *component) (codegen.Stub, error) {
+	if _, ok := w.imports[c.info.Name]; ok {
+		imported, err := w.getStub(c)
+		if err != nil {
+			return nil, err
+		}
+		return imported.stub, nil
+	}
+	if err := w.register(c); err != nil {
+		return nil, err
 	}
 
+	var stub codegen.Stub
 	if c.local.Read() {
 		impl, err := w.getImpl(c)
 		if err != nil {
 			return nil, err
 		}
-		return c.info.LocalStubFn(impl.impl, impl.component.tracer), nil
+		stub = &loopbackStub{
+			component: c.info.Name,
+			server:    impl.serverStub,
+			methods:   methodNames(c.info.Iface),
+			tracer:    impl.component.tracer,
+		}
+	} else {
+		remote, err := w.getStub(c)
+		if err != nil {
+			return nil, err
+		}
+		stub = remote.stub
+	}
+
+	if inject, faulty := w.faults[c.info.Iface]; faulty {
+		// Inject faults into the calls to the component.
+		stub = &faultyStub{stub: stub, methods: methodNames(c.info.Iface), inject: inject}
+	}
+	return stub, nil
+}
+
+// methodNames returns the names of the methods of the provided component
+// interface, by method index.
+func methodNames(iface reflect.Type) []string {
+	names := make([]string, iface.NumMethod())
+	for i := range names {
+		names[i] = iface.Method(i).Name
 	}
+	return names
+}
 
-	stub, err := w.getStub(c)
+// getInstanceByType returns an instance of the component with the provided
+// type. requester is the name of the requesting component.
+func (w *weavelet) getInstanceByType(t reflect.Type, requester string) (interface{}, error) {
+	c, err := w.getComponentByType(t)
 	if err != nil {
 		return nil, err
 	}
-	return c.info.ClientStubFn(stub.stub, requester), nil
+	return w.getInstance(c, requester)
 }
 
 // getListener returns a network listener with the given name.
@@ -355,10 +757,23 @@ func (w *weavelet) getListener(name string, opts ListenerOptions) (*Listener, er
 	}
 
 	// Listen on the address.
-	l, err := net.Listen("tcp", addr.Address)
+	config, err := tlsConfig(opts)
 	if err != nil {
 		return nil, fmt.Errorf("getListener(%q): %w", name, err)
 	}
+	raw, err := listeners.Listen(addr.Address)
+	if err != nil {
+		return nil, fmt.Errorf("getListener(%q): %w", name, err)
+	}
+	limits := w.listenerConfigs[name]
+	limited := listeners.Limit(raw, limits.MaxConnections, limits.IdleTimeout)
+	w.drainMu.Lock()
+	w.listeners = append(w.listeners, namedListener{name, limited})
+	w.drainMu.Unlock()
+	var l net.Listener = limited
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
 
 	// Export the listener.
 	errMsg := fmt.Sprintf("getListener(%q): error exporting listener %v", name, l.Addr())
@@ -373,7 +788,260 @@ func (w *weavelet) getListener(name string, opts ListenerOptions) (*Listener, er
 	if reply.Error != "" {
 		return nil, fmt.Errorf("getListener(%q): %s", name, reply.Error)
 	}
-	return &Listener{Listener: l, proxyAddr: reply.ProxyAddress}, nil
+
+	// Clients use TLS if the address they connect to terminates TLS. Proxies
+	// terminate TLS, if at all, on their own.
+	useTLS := reply.Tls
+	if reply.AdvertisedAddress == "" && reply.ProxyAddress == "" {
+		useTLS = config != nil
+	}
+	return &Listener{
+		Listener:       l,
+		proxyAddr:      reply.ProxyAddress,
+		advertisedAddr: reply.AdvertisedAddress,
+		tls:            useTLS,
+		start:          w.runStartHooks,
+	}, nil
+}
+
+// onStart registers a start hook. If the start hooks have already been run,
+// the hook is run immediately.
+func (w *weavelet) onStart(hook func(context.Context) error) {
+	w.startMu.Lock()
+	if !w.started {
+		w.startHooks = append(w.startHooks, hook)
+		w.startMu.Unlock()
+		return
+	}
+	w.startMu.Unlock()
+	w.runStartHook(hook)
+}
+
+// runStartHooks runs the hooks registered with OnStart, in registration
+// order. The hooks are run only once; later calls block until the first call
+// returns.
+func (w *weavelet) runStartHooks() {
+	w.startOnce.Do(func() {
+		w.startMu.Lock()
+		hooks := w.startHooks
+		w.startHooks = nil
+		w.started = true
+		w.startMu.Unlock()
+
+		for _, hook := range hooks {
+			w.runStartHook(hook)
+		}
+	})
+}
+
+// runStartHook runs a start hook. A hook failure is fatal.
+func (w *weavelet) runStartHook(hook func(context.Context) error) {
+	if err := hook(w.ctx); err != nil {
+		w.env.SystemLogger().Error("fatal: start hook failed", err)
+		os.Exit(1)
+	}
+}
+
+// SetLogLevel implements the conn.WeaveletHandler interface.
+func (w *weavelet) SetLogLevel(req *protos.SetLogLevelRequest) (*protos.SetLogLevelReply, error) {
+	c, ok := w.componentsByName[req.Component]
+	if !ok {
+		return nil, fmt.Errorf("component %q not found", req.Component)
+	}
+	level, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		return nil, err
+	}
+	c.logLevel.Set(level)
+	return &protos.SetLogLevelReply{}, nil
+}
+
+// InjectFaults implements the conn.WeaveletHandler interface.
+func (w *weavelet) InjectFaults(req *protos.InjectFaultsRequest) (*protos.InjectFaultsReply, error) {
+	for _, component := range req.Unreachable {
+		w.env.SystemLogger().Warn("chaos: partitioned from component", "component", component)
+	}
+	for component, micros := range req.LatencyMicros {
+		latency := time.Duration(micros) * time.Microsecond
+		w.env.SystemLogger().Warn("chaos: slowing calls to component", "component", component, "latency", latency)
+	}
+	w.chaos.set(req)
+	return &protos.InjectFaultsReply{}, nil
+}
+
+// namedListener is a listener created with Listener, and its name.
+type namedListener struct {
+	name string
+	*listeners.Limited
+}
+
+// onDrain registers a drain hook.
+func (w *weavelet) onDrain(hook func(context.Context) error) {
+	w.drainMu.Lock()
+	defer w.drainMu.Unlock()
+	w.drainHooks = append(w.drainHooks, hook)
+}
+
+// SetFlags implements the conn.WeaveletHandler interface.
+func (w *weavelet) SetFlags(req *protos.SetFlagsRequest) (*protos.SetFlagsReply, error) {
+	w.flags.set(req.Flags)
+	return &protos.SetFlagsReply{}, nil
+}
+
+// SetConfig implements the conn.WeaveletHandler interface. The new component
+// config sections are checked against the config structs of the components
+// before any component is reconfigured, so that either every component of
+// the weavelet is reconfigured, or none is.
+func (w *weavelet) SetConfig(req *protos.SetConfigRequest) (*protos.SetConfigReply, error) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+
+	// Replace the component sections.
+	sections := map[string]string{}
+	for key, section := range w.sections {
+		if !runtime.IsComponentSection(key) {
+			sections[key] = section
+		}
+	}
+	for key, section := range req.Sections {
+		if !runtime.IsComponentSection(key) {
+			return nil, fmt.Errorf("section %q doesn't configure a component", key)
+		}
+		sections[key] = section
+	}
+
+	// Check the sections that changed.
+	var changed []*component
+	for name, c := range w.componentsByName {
+		section, ok := sections[name]
+		if old, wasSet := w.sections[name]; ok == wasSet && section == old {
+			continue
+		}
+		if c.info.ConfigFn == nil {
+			if ok {
+				return nil, fmt.Errorf("component %q does not support configuration (it has no weaver.WithConfig field)", name)
+			}
+			continue
+		}
+		if err := runtime.ParseConfigSection(name, "", sections, c.info.ConfigFn(c.info.New())); err != nil {
+			return nil, fmt.Errorf("component %q: %w", name, err)
+		}
+		changed = append(changed, c)
+	}
+
+	w.sections = sections
+	for _, c := range changed {
+		if c.config == nil {
+			// The component isn't hosted by this weavelet, or isn't created
+			// yet, in which case it is created with the new config.
+			continue
+		}
+		if err := c.config.reconfigure(w.ctx, c.info.Name, sections); err != nil {
+			return nil, fmt.Errorf("component %q: %w", c.info.Name, err)
+		}
+		w.env.SystemLogger().Info("Reconfigured component", "component", c.info.Name)
+	}
+	return &protos.SetConfigReply{}, nil
+}
+
+// Drain implements the conn.WeaveletHandler interface.
+func (w *weavelet) Drain(*protos.DrainRequest) (*protos.DrainReply, error) {
+	w.drain()
+	return &protos.DrainReply{}, nil
+}
+
+// drain drains the weavelet: it reports the weavelet as draining, waits for
+// the drain grace period, stops accepting listener connections, runs the
+// drain hooks, and waits for the component method calls being served to
+// finish and the listener connections to be closed, all within the max drain
+// time. It then
+// shuts down the local components, within the shutdown timeout. Only the
+// first call drains the weavelet; later calls block until it is drained.
+func (w *weavelet) drain() {
+	w.drainOnce.Do(func() {
+		logger := w.env.SystemLogger()
+		logger.Info("Draining", "grace period", w.drainGracePeriod, "max drain time", w.maxDrainTime)
+		w.draining.Store(true)
+		processDraining.Store(true)
+
+		ctx, cancel := context.WithTimeout(w.ctx, w.maxDrainTime)
+		defer cancel()
+
+		// Keep serving while traffic shifts away from the weavelet.
+		select {
+		case <-time.After(w.drainGracePeriod):
+		case <-ctx.Done():
+		}
+
+		// Stop accepting listener connections. The open connections are
+		// still served.
+		w.drainMu.Lock()
+		hooks := w.drainHooks
+		lis := w.listeners
+		w.drainMu.Unlock()
+		for _, l := range lis {
+			l.Stop()
+		}
+
+		for _, hook := range hooks {
+			if err := hook(ctx); err != nil {
+				logger.Error("drain hook failed", err)
+			}
+		}
+
+		if err := w.calls.wait(ctx); err != nil {
+			logger.Error("drain incomplete", err, "outstanding calls", w.calls.count())
+		}
+		for _, l := range lis {
+			if err := l.Wait(ctx); err != nil {
+				logger.Error("listener drain incomplete", err, "listener", l.name)
+			}
+		}
+
+		// Shut down the local components, now that they serve no calls.
+		shutdownCtx, shutdownCancel := context.WithTimeout(w.ctx, w.shutdownTimeout)
+		defer shutdownCancel()
+		w.shutdown(shutdownCtx)
+		logger.Info("Drained")
+	})
+}
+
+// shutdowner is implemented by component implementations that have a
+// Shutdown method.
+type shutdowner interface {
+	Shutdown(context.Context) error
+}
+
+// shutdown calls the Shutdown method of every local component that has one,
+// in the reverse of the order in which the components were created. A
+// component's dependencies are created before it, so a component is shut down
+// before the components it calls. shutdown then closes the backends of the
+// stores created with NewStore and the clients of the external services,
+// stops recording calls, and exports the buffered log entries.
+func (w *weavelet) shutdown(ctx context.Context) {
+	w.shutdownMu.Lock()
+	components := w.shutdowns
+	w.shutdowns = nil
+	w.shutdownMu.Unlock()
+
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if err := c.impl.impl.(shutdowner).Shutdown(ctx); err != nil {
+			w.env.SystemLogger().Error("component shutdown failed", err, "component", c.info.Name)
+		}
+	}
+	if err := w.stores.close(); err != nil {
+		w.env.SystemLogger().Error("close stores", err)
+	}
+	if err := w.externals.close(); err != nil {
+		w.env.SystemLogger().Error("close external services", err)
+	}
+	if err := w.recorder.close(); err != nil {
+		w.env.SystemLogger().Error("close call recording", err)
+	}
+	if err := w.logs.close(ctx); err != nil {
+		w.env.SystemLogger().Error("close log exporters", err)
+	}
 }
 
 // addHandlers registers a component's methods as handlers in stub.HandlerMap.
@@ -381,11 +1049,19 @@ func (w *weavelet) getListener(name string, opts ListenerOptions) (*Listener, er
 // that (1) creates the local component if it hasn't been created yet and (2)
 // calls m.
 func (w *weavelet) addHandlers(handlers *call.HandlerMap, c *component) {
+	admission := w.admissionController(c.info.Name)
+	recorded := w.recorder.records(c.info.Name)
 	for i, n := 0, c.info.Iface.NumMethod(); i < n; i++ {
-		mname := c.info.Iface.Method(i).Name
-		handler := func(ctx context.Context, args []byte) (res []byte, err error) {
-			// This handler is supposed to invoke the method named mname on the
-			// local component. However, it is possible that the component has not
+		m := c.info.Iface.Method(i)
+		mname := m.Name
+		idempotency := w.idempotencyTable(c.info.Name, mname)
+		access := w.accessPolicy(c.info.Name, mname)
+		limits := w.payloadLimits(c.info.Name, mname)
+		requestBytes := methodPayloadBytes.Get(payloadLabels{Component: c.info.Name, Method: mname, Direction: "request"})
+		responseBytes := methodPayloadBytes.Get(payloadLabels{Component: c.info.Name, Method: mname, Direction: "response"})
+		invoke := func(ctx context.Context, args []byte) (res []byte, err error) {
+			// invoke invokes the method named mname on the local component.
+			// However, it is possible that the component has not
 			// yet been started (e.g., the start command was issued but hasn't
 			// yet taken effect). d.getImpl(c) will start the component if it
 			// hasn't already been started, or it will be a noop if the component
@@ -395,9 +1071,90 @@ func (w *weavelet) addHandlers(handlers *call.HandlerMap, c *component) {
 				return nil, err
 			}
 			fn := impl.serverStub.GetStubFn(mname)
-			return fn(ctx, args)
+			if fn == nil {
+				return nil, fmt.Errorf("component %q: method %s is a streaming method", c.info.Name, mname)
+			}
+			if !recorded {
+				return fn(ctx, args)
+			}
+			start := time.Now()
+			res, err = fn(ctx, args)
+			rec := RecordedCall{
+				Component: c.info.Name,
+				Method:    mname,
+				Start:     start,
+				Duration:  time.Since(start),
+				Args:      args,
+				Results:   res,
+			}
+			if err != nil {
+				rec.Error = err.Error()
+			}
+			w.recorder.record(rec)
+			return res, err
+		}
+		handler := func(ctx context.Context, args []byte) ([]byte, error) {
+			defer w.crashes.catch(ctx, c.info.Name, mname, args)
+			w.calls.start()
+			defer w.calls.end()
+			serving := componentServingCalls.Get(servingLabels{Component: c.info.Name})
+			serving.Add(1)
+			defer serving.Sub(1)
+			ctx, key, caller := receiveMetadata(ctx)
+			if err := access.check(ctx, caller); err != nil {
+				return nil, err
+			}
+			if err := admission.acquire(ctx, call.CallPriority(ctx)); err != nil {
+				return nil, err
+			}
+			defer admission.release()
+			requestBytes.Put(float64(len(args)))
+			if err := limits.checkRequest(len(args)); err != nil {
+				return nil, err
+			}
+			var res []byte
+			var err error
+			if key != "" && idempotency != nil {
+				res, err = idempotency.run(ctx, key, args, invoke)
+			} else {
+				res, err = invoke(ctx, args)
+			}
+			if err != nil {
+				return nil, err
+			}
+			responseBytes.Put(float64(len(res)))
+			if err := limits.checkResponse(len(res)); err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+		streamHandler := func(ctx context.Context, args []byte, s call.ServerStream) error {
+			defer w.crashes.catch(ctx, c.info.Name, mname, args)
+			w.calls.start()
+			defer w.calls.end()
+			serving := componentServingCalls.Get(servingLabels{Component: c.info.Name})
+			serving.Add(1)
+			defer serving.Sub(1)
+			ctx, _, caller := receiveMetadata(ctx)
+			if err := access.check(ctx, caller); err != nil {
+				return err
+			}
+			if err := admission.acquire(ctx, call.CallPriority(ctx)); err != nil {
+				return err
+			}
+			defer admission.release()
+			impl, err := w.getImpl(c)
+			if err != nil {
+				return err
+			}
+			fn := impl.serverStub.GetStreamFn(mname)
+			if fn == nil {
+				return fmt.Errorf("component %q: method %s is not a streaming method", c.info.Name, mname)
+			}
+			return fn(ctx, args, s)
 		}
-		handlers.Set(c.info.Name, mname, handler)
+		handlers.SetVersioned(c.info.Name, mname, methodVersion(m), handler)
+		handlers.SetStream(c.info.Name, mname, methodVersion(m), streamHandler)
 	}
 }
 
@@ -433,26 +1190,92 @@ func (w *weavelet) UpdateComponents(req *protos.UpdateComponentsRequest) (*proto
 	//
 	// TODO(mwhittaker): Document that handlers shouldn't retain access to the
 	// arguments passed to them.
+	//
+	// The weavelet reports itself as STARTING until the components are
+	// initialized; see Health.
 	components := slices.Clone(req.Components)
+	slices.SortStableFunc(components, func(a, b string) bool {
+		return w.startupConfigs[a].Order < w.startupConfigs[b].Order
+	})
 	w.env.SystemLogger().Debug("UpdateComponents", "components", components)
+	w.profiler.host(components)
+	w.initializing.Add(1)
 	go func() {
+		defer w.initializing.Add(-1)
 		for _, component := range components {
 			c, err := w.getComponent(component)
 			if err != nil {
 				// TODO(mwhittaker): Propagate errors.
 				w.env.SystemLogger().Error("getComponent", err, "component", component)
+				w.initFailed.Store(true)
 				return
 			}
 			if _, err = w.getImpl(c); err != nil {
 				// TODO(mwhittaker): Propagate errors.
 				w.env.SystemLogger().Error("getImpl", err, "component", component)
+				w.initFailed.Store(true)
 				return
 			}
 		}
+		w.coldStartOnce.Do(func() {
+			coldStartLatencyMicros.Put(float64(time.Since(w.created).Microseconds()))
+		})
+		if !w.info.RunMain {
+			// The components hosted by this process have been initialized.
+			// The main process instead runs its start hooks when it starts
+			// serving on a listener, after main has fetched its components.
+			w.runStartHooks()
+		}
 	}()
 	return &protos.UpdateComponentsReply{}, nil
 }
 
+// Health implements the conn.WeaveletHandler interface.
+func (w *weavelet) Health() *protos.GetHealthReply {
+	var reply *protos.GetHealthReply
+	switch {
+	case w.initFailed.Load():
+		reply = &protos.GetHealthReply{
+			Status: protos.HealthStatus_UNHEALTHY,
+			Reason: "component initialization failed",
+		}
+	case w.initializing.Load() > 0 || w.warming.Load() > 0:
+		reply = &protos.GetHealthReply{Status: protos.HealthStatus_STARTING}
+	default:
+		reply = w.health.status()
+	}
+	reply.Externals = w.externals.health()
+	return reply
+}
+
+// HandleTopicEvent implements the conn.WeaverHandler interface.
+func (w *weavelet) HandleTopicEvent(event *protos.TopicEvent) {
+	w.caches.invalidate(event.Topic, event.Payload)
+	w.deliverCallback(event.Topic, event.Payload)
+	w.topics.deliver(event.Topic, event.Payload)
+}
+
+// DeliverMessage implements the conn.WeaverHandler interface.
+func (w *weavelet) DeliverMessage(req *protos.DeliverMessageRequest) (*protos.DeliverMessageReply, error) {
+	w.calls.start()
+	defer w.calls.end()
+	sub := pubsub.Subscription{Topic: req.Message.Topic, Name: req.Subscription}
+	if err := w.messageHandlers.deliver(w.ctx, sub, req.Message.Payload); err != nil {
+		return nil, err
+	}
+	return &protos.DeliverMessageReply{}, nil
+}
+
+// RunJob implements the conn.WeaverHandler interface.
+func (w *weavelet) RunJob(req *protos.RunJobRequest) (*protos.RunJobReply, error) {
+	w.calls.start()
+	defer w.calls.end()
+	if err := w.jobs.run(w.ctx, req.Name); err != nil {
+		return nil, err
+	}
+	return &protos.RunJobReply{}, nil
+}
+
 // UpdateRoutingInfo implements the conn.WeaverHandler interface.
 func (w *weavelet) UpdateRoutingInfo(req *protos.UpdateRoutingInfoRequest) (reply *protos.UpdateRoutingInfoReply, err error) {
 	// TODO(rgrandl): After we switch to slog, call With here to avoid
@@ -480,10 +1303,11 @@ func (w *weavelet) UpdateRoutingInfo(req *protos.UpdateRoutingInfoRequest) (repl
 
 	// Update resolver and balancer.
 	client := w.getTCPClient(req.RoutingInfo.Component)
-	endpoints, err := parseEndpoints(req.RoutingInfo.Replicas)
+	endpoints, err := parseEndpoints(req.RoutingInfo.Replicas, w.quic)
 	if err != nil {
 		return nil, err
 	}
+	client.balancer.updateRegions(req.RoutingInfo.Regions)
 	client.resolver.update(endpoints)
 	client.balancer.update(req.RoutingInfo.Assignment)
 
@@ -535,16 +1359,25 @@ func (w *weavelet) getImpl(c *component) (*componentImpl, error) {
 				Component:  c.info.Name,
 				Weavelet:   w.info.Id,
 			},
-			Write: w.env.CreateLogSaver(),
+			Write: w.createLogSaver(),
+			Level: &c.logLevel,
 		})
-		c.tracer = w.tracer
+		c.tracer = w.componentTracer(c.info.Name)
+
+		if err := w.awaitDependencies(c); err != nil {
+			w.env.SystemLogger().Error("Waiting for dependencies failed", err, "component", c.info.Name)
+			return err
+		}
 
 		w.env.SystemLogger().Debug("Constructing component", "component", c.info.Name)
+		start := time.Now()
 		if err := createComponent(w.ctx, c); err != nil {
 			w.env.SystemLogger().Error("Constructing component failed", err, "component", c.info.Name)
 			return err
 		}
-		w.env.SystemLogger().Debug("Constructing component succeeded", "component", c.info.Name)
+		latency := time.Since(start)
+		componentInitLatencyMicros.Get(initLabels{Component: c.info.Name}).Put(float64(latency.Microseconds()))
+		w.env.SystemLogger().Debug("Constructing component succeeded", "component", c.info.Name, "latency", latency)
 
 		c.impl.serverStub = c.info.ServerStubFn(c.impl.impl, func(key uint64, v float64) {
 			if c.info.Routed {
@@ -552,7 +1385,7 @@ func (w *weavelet) getImpl(c *component) (*componentImpl, error) {
 					c.logger.Error("add load", err, "component", c.info.Name, "key", key)
 				}
 			}
-		})
+		}, w.codecs[c.info.Name])
 		return nil
 	}
 	c.implInit.Do(func() { c.implErr = init(c) })
@@ -564,8 +1397,16 @@ func createComponent(ctx context.Context, c *component) error {
 	obj := c.info.New()
 
 	if c.info.ConfigFn != nil {
+		// Parse the config, and remember the implementation, while holding
+		// configMu, so that no config applied by SetConfig is missed.
 		cfg := c.info.ConfigFn(obj)
-		if err := runtime.ParseConfigSection(c.info.Name, "", c.wlet.info.Sections, cfg); err != nil {
+		c.wlet.configMu.Lock()
+		err := runtime.ParseConfigSection(c.info.Name, "", c.wlet.sections, cfg)
+		if r, ok := obj.(reconfigurer); ok && err == nil {
+			c.config = r
+		}
+		c.wlet.configMu.Unlock()
+		if err != nil {
 			return err
 		}
 	}
@@ -577,6 +1418,20 @@ func createComponent(ctx context.Context, c *component) error {
 		i.setInstance(c.impl)
 	}
 
+	// Fill the Ref fields of obj.
+	if err := fillRefs(obj, func(t reflect.Type) (any, error) {
+		return c.wlet.getInstanceByType(t, c.info.Name)
+	}); err != nil {
+		return fmt.Errorf("component %q: %w", c.info.Name, err)
+	}
+
+	// Fill the External fields of obj.
+	if err := fillExternals(obj, func(name string, t reflect.Type) (any, error) {
+		return c.wlet.getExternal(ctx, name, t)
+	}); err != nil {
+		return fmt.Errorf("component %q: %w", c.info.Name, err)
+	}
+
 	// Call Init if available.
 	if i, ok := obj.(interface{ Init(context.Context) error }); ok {
 		if err := i.Init(ctx); err != nil {
@@ -584,9 +1439,54 @@ func createComponent(ctx context.Context, c *component) error {
 		}
 	}
 	c.impl.impl = obj
+
+	// Remember to call Shutdown if available.
+	if _, ok := obj.(shutdowner); ok {
+		c.wlet.shutdownMu.Lock()
+		c.wlet.shutdowns = append(c.wlet.shutdowns, c)
+		c.wlet.shutdownMu.Unlock()
+	}
+
+	// Check the component's health if it has a HealthCheck method.
+	if _, ok := obj.(healthChecker); ok {
+		c.wlet.addHealthCheck(c)
+	}
+
+	// Warm the component up if it has a Warmup method.
+	c.wlet.warmup(c, obj)
 	return nil
 }
 
+// credentials returns the credentials the weavelet uses to authenticate itself
+// to, and to authenticate, the other weavelets in the deployment. The
+// certificate is fetched from the deployer on the first call, and not in
+// start, because the deployer may only start serving the weavelet once start
+// returns.
+func (w *weavelet) credentials() (*mtls.Credentials, error) {
+	w.credsOnce.Do(func() {
+		remote, ok := w.env.(*remoteEnv)
+		if !ok {
+			w.credsErr = fmt.Errorf("mtls is not supported in a single process")
+			return
+		}
+		w.creds, w.credsErr = mtls.NewCredentials(w.ctx, w.env.SystemLogger(), remote.conn.GetCertificateRPC)
+	})
+	return w.creds, w.credsErr
+}
+
+// quicConfig returns the TLS config of the weavelet's QUIC clients: the
+// mutual TLS config if mutualTLS, or nil otherwise.
+func (w *weavelet) quicConfig() (*tls.Config, error) {
+	if !w.mutualTLS {
+		return nil, nil
+	}
+	creds, err := w.credentials()
+	if err != nil {
+		return nil, err
+	}
+	return creds.ClientConfig(), nil
+}
+
 func (w *weavelet) repeatedly(errMsg string, f func() error) error {
 	for r := retry.Begin(); r.Continue(w.ctx); {
 		if err := f(); err != nil {
@@ -601,33 +1501,62 @@ func (w *weavelet) repeatedly(errMsg string, f func() error) error {
 // getStub returns a component's componentStub, initializing it if necessary.
 func (w *weavelet) getStub(c *component) (*componentStub, error) {
 	init := func(c *component) error {
+		if config, ok := w.imports[c.info.Name]; ok {
+			return w.importStub(c, config)
+		}
+
 		// Initialize the client.
 		w.env.SystemLogger().Debug("Getting TCP client to component...", "component", c.info.Name)
 		client := w.getTCPClient(c.info.Name)
-		if err := client.init(w.ctx, w.transport.clientOpts); err != nil {
+		opts := w.transport.clientOpts
+		opts.Balancer = client.balancer
+		if w.mutualTLS && w.quic == nil {
+			creds, err := w.credentials()
+			if err != nil {
+				return err
+			}
+			opts.TLSConfig = creds.ClientConfig()
+		}
+		if err := client.init(w.ctx, opts); err != nil {
 			w.env.SystemLogger().Error("Getting TCP client to component failed", err, "component", c.info.Name)
 			return err
 		}
 		w.env.SystemLogger().Debug("Getting TCP client to component succeeded", "component", c.info.Name)
 
-		// Construct the keys for the methods.
+		// Construct the keys, versions, priorities, cache policies, call
+		// policies, and payload limits for the methods.
 		n := c.info.Iface.NumMethod()
 		methods := make([]call.MethodKey, n)
+		versions := make([]uint64, n)
+		priority := make([]Priority, n)
+		policies := make([]*cachePolicy, n)
+		calls := make([]*callPolicy, n)
+		limits := make([]*payloadLimits, n)
 		for i := 0; i < n; i++ {
-			mname := c.info.Iface.Method(i).Name
-			methods[i] = call.MakeMethodKey(c.info.Name, mname)
+			m := c.info.Iface.Method(i)
+			methods[i] = call.MakeMethodKey(c.info.Name, m.Name)
+			versions[i] = methodVersion(m)
+			priority[i] = parsePriority(w.methodConfigs[c.info.Name+"."+m.Name].Priority)
+			policies[i] = w.cachePolicy(c.info.Name, m.Name)
+			calls[i] = w.callPolicy(c.info.Name, m.Name)
+			limits[i] = w.payloadLimits(c.info.Name, m.Name)
 		}
 
-		var balancer call.Balancer
-		if c.info.Routed {
-			balancer = client.balancer
-		}
 		c.stub = &componentStub{
 			stub: &stub{
-				client:   client.client,
-				methods:  methods,
-				balancer: balancer,
-				tracer:   w.tracer,
+				component: c.info.Name,
+				client:    client.client,
+				methods:   methods,
+				versions:  versions,
+				priority:  priority,
+				tracer:    w.componentTracer(c.info.Name),
+				policies:  policies,
+				cache:     w.cache,
+				clock:     w.clock,
+				calls:     calls,
+				limits:    limits,
+				breaker:   w.circuitBreaker(c.info.Name),
+				chaos:     &w.chaos,
 			},
 		}
 		return nil
@@ -636,6 +1565,23 @@ func (w *weavelet) getStub(c *component) (*componentStub, error) {
 	return c.stub, c.stubErr
 }
 
+// cachePolicy returns the cache policy of the provided method, or nil if the
+// results of the method should not be cached.
+func (w *weavelet) cachePolicy(component, method string) *cachePolicy {
+	config, ok := w.methodConfigs[component+"."+method]
+	if !ok || (config.CacheTTL <= 0 && config.NegativeCacheTTL <= 0) {
+		return nil
+	}
+	labels := cacheLabels{Component: component, Method: method}
+	return &cachePolicy{
+		ttl:         config.CacheTTL,
+		negativeTTL: config.NegativeCacheTTL,
+		maxEntries:  config.CacheMaxEntries,
+		hits:        methodCacheHits.Get(labels),
+		misses:      methodCacheMisses.Get(labels),
+	}
+}
+
 func waitUntilReady(ctx context.Context, client call.Connection) error {
 	for r := retry.Begin(); r.Continue(ctx); {
 		_, err := client.Call(ctx, readyMethodKey, nil, call.CallOptions{})
@@ -653,9 +1599,17 @@ func (w *weavelet) getTCPClient(component string) *client {
 	defer w.clientsLock.Unlock()
 	c, ok := w.tcpClients[component]
 	if !ok {
+		balancer := newRoutingBalancer(component, w.loadBalancing, w.info.Region)
+		balancer.quic = w.quic
+		if comp, ok := w.componentsByName[component]; ok {
+			if custom := registeredBalancer(comp.info.Iface); custom != nil {
+				balancer.balancer = newCustomBalancer(custom)
+				balancer.locality = nil
+			}
+		}
 		c = &client{
 			resolver: newRoutingResolver(),
-			balancer: newRoutingBalancer(),
+			balancer: balancer,
 		}
 		w.tcpClients[component] = c
 	}
@@ -682,3 +1636,56 @@ func startWork(ctx context.Context, msg string, fn func() error) {
 		}
 	}()
 }
+
+// callTracker tracks the number of calls in progress.
+type callTracker struct {
+	mu   sync.Mutex
+	n    int           // number of calls in progress
+	idle chan struct{} // if not nil, closed when n drops to zero
+}
+
+// start records the start of a call.
+func (c *callTracker) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+}
+
+// end records the end of a call.
+func (c *callTracker) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n--
+	if c.n == 0 && c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// count returns the number of calls in progress.
+func (c *callTracker) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// wait waits until no calls are in progress, or until ctx is done.
+func (c *callTracker) wait(ctx context.Context) error {
+	c.mu.Lock()
+	if c.n == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"golang.org/x/exp/slog"
+)
+
+func TestCallTracker(t *testing.T) {
+	// Test plan: Start two calls, and check that wait returns only once both
+	// have ended.
+	var c callTracker
+	if err := c.wait(context.Background()); err != nil {
+		t.Fatalf("wait with no calls: %v", err)
+	}
+
+	c.start()
+	c.start()
+	waited := make(chan error, 1)
+	go func() { waited <- c.wait(context.Background()) }()
+
+	c.end()
+	select {
+	case err := <-waited:
+		t.Fatalf("wait returned %v with a call in progress", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.end()
+	if err := <-waited; err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+}
+
+func TestCallTrackerTimeout(t *testing.T) {
+	var c callTracker
+	c.start()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := c.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got, want := c.count(), 1; got != want {
+		t.Fatalf("count: got %d, want %d", got, want)
+	}
+}
+
+func TestWeaveletHealth(t *testing.T) {
+	// Test plan: Move a weavelet through the states of initializing its
+	// components, and check the health it reports in each.
+	var w weavelet
+	check := func(want protos.HealthStatus) {
+		t.Helper()
+		if got := w.Health().Status; got != want {
+			t.Fatalf("Health: got %v, want %v", got, want)
+		}
+	}
+	check(protos.HealthStatus_HEALTHY)
+	w.initializing.Add(1)
+	check(protos.HealthStatus_STARTING)
+	w.initializing.Add(-1)
+	check(protos.HealthStatus_HEALTHY)
+	w.warming.Add(1)
+	check(protos.HealthStatus_STARTING)
+	w.warming.Add(-1)
+	check(protos.HealthStatus_HEALTHY)
+	w.initFailed.Store(true)
+	check(protos.HealthStatus_UNHEALTHY)
+}
+
+// healthCheck is a component implementation whose HealthCheck method returns
+// *err.
+type healthCheck struct {
+	err *error
+}
+
+func (h healthCheck) HealthCheck(context.Context) error {
+	return *h.err
+}
+
+func TestWeaveletHealthChecks(t *testing.T) {
+	// Test plan: Fail and pass the health check of a component, and check
+	// that the weavelet is STARTING until the check first passes, and
+	// UNHEALTHY when it fails afterwards.
+	var w weavelet
+	var err error
+	c := &component{
+		info: &codegen.Registration{Name: "a"},
+		impl: &componentImpl{impl: healthCheck{err: &err}},
+	}
+	w.health.components = []*component{c}
+	w.health.results = map[*component]*healthResult{c: {}}
+	logger := slog.New(slog.HandlerOptions{Level: slog.LevelError + 1}.NewTextHandler(io.Discard))
+	check := func(want protos.HealthStatus) {
+		t.Helper()
+		w.health.run(context.Background(), time.Second, logger)
+		if got := w.Health().Status; got != want {
+			t.Fatalf("Health: got %v, want %v", got, want)
+		}
+	}
+
+	err = errors.New("not ready")
+	check(protos.HealthStatus_STARTING)
+	err = nil
+	check(protos.HealthStatus_HEALTHY)
+	err = errors.New("wedged")
+	check(protos.HealthStatus_UNHEALTHY)
+	if got, want := w.Health().Reason, "component a: wedged"; got != want {
+		t.Fatalf("Health reason: got %q, want %q", got, want)
+	}
+	err = nil
+	check(protos.HealthStatus_HEALTHY)
+}
+
+type shutdownRecorder struct {
+	name     string
+	shutdown *[]string
+}
+
+func (s shutdownRecorder) Shutdown(context.Context) error {
+	*s.shutdown = append(*s.shutdown, s.name)
+	return nil
+}
+
+func TestWeaveletShutdown(t *testing.T) {
+	// Test plan: Register two components with a Shutdown method, and check
+	// that shutdown calls them in the reverse of the order they were created.
+	w := weavelet{stores: newStores(nil)}
+	var got []string
+	for _, name := range []string{"a", "b"} {
+		w.shutdowns = append(w.shutdowns, &component{
+			info: &codegen.Registration{Name: name},
+			impl: &componentImpl{impl: shutdownRecorder{name: name, shutdown: &got}},
+		})
+	}
+	w.shutdown(context.Background())
+	if want := []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("shutdown order: got %v, want %v", got, want)
+	}
+
+	// Components are shut down only once.
+	w.shutdown(context.Background())
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("shutdowns: got %d, want %d", got, want)
+	}
+}
@@ -31,7 +31,9 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sync/atomic"
 
+	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -58,6 +60,159 @@ import (
 //	}
 var ErrRetriable = errors.New("retriable")
 
+// ErrVersionMismatch indicates a component method call failed because the
+// caller and the callee were built with incompatible versions of the method.
+// This typically happens during a rolling upgrade, when an old and a new
+// version of an application briefly run side by side. You can use
+// ErrVersionMismatch in conjunction with errors.Is to detect such failures:
+//
+//	if errors.Is(err, weaver.ErrVersionMismatch) {
+//	    // The callee runs a different version of foo.Foo.
+//	}
+//
+// Every component method has a version, derived from its signature. Two
+// versions of a method are compatible if and only if they have the same
+// signature, where the signature of a method includes its name, the types
+// of its arguments and results, and, recursively, the layout of those types.
+// Renaming a method argument or changing the method's implementation does
+// not change its version. Methods are versioned independently, so adding a
+// new method to a component does not affect calls to its existing methods.
+//
+// Types that provide their own serialization (i.e. types that implement
+// proto.Message or encoding.BinaryMarshaler) are versioned by name only; it
+// is up to them to remain compatible across versions. So are structs that
+// embed AutoMarshal: their encoding records their number of fields, so a
+// field can be appended to such a struct without breaking calls between
+// versions. A callee that receives an old value leaves the new field unset,
+// and a callee that receives a new value skips it. Removing, reordering, or
+// changing the type of a field is not compatible; "weaver multi deploy
+// --rollout" and "weaver ssh deploy --rollout" refuse to roll out such
+// changes.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// ErrShedLoad indicates a component method call was rejected, without being
+// run, because the process hosting the component was overloaded. Calls of
+// lower priority are shed before calls of higher priority; see Priority. You
+// can use ErrShedLoad in conjunction with errors.Is to degrade gracefully:
+//
+//	ads, err := adService.GetAds(ctx, keywords)
+//	if errors.Is(err, weaver.ErrShedLoad) {
+//	    ads = nil // Render the page without ads.
+//	}
+var ErrShedLoad = errors.New("load shed")
+
+// ErrOverloaded indicates a component method call was rejected, without being
+// run, because too many calls were already in flight, or waiting to be in
+// flight, between the caller and the process hosting the component. The
+// limits are set by the max_in_flight_calls and max_pending_calls fields of
+// the config. You can use ErrOverloaded in conjunction with errors.Is to back
+// off, rather than piling up more calls on a slow component:
+//
+//	if errors.Is(err, weaver.ErrOverloaded) {
+//	    http.Error(w, "busy, try again later", http.StatusServiceUnavailable)
+//	    return
+//	}
+var ErrOverloaded = errors.New("overloaded")
+
+// ErrCircuitOpen indicates a component method call failed fast, without being
+// sent, because too many recent calls to the component had failed. Circuit
+// breakers are configured per component in the config; see
+// runtime.BreakerConfig. You can use ErrCircuitOpen in conjunction with
+// errors.Is to degrade gracefully:
+//
+//	quote, err := shipping.GetQuote(ctx, address, items)
+//	if errors.Is(err, weaver.ErrCircuitOpen) {
+//	    // Shipping is struggling. Show the cart without a quote.
+//	}
+var ErrCircuitOpen = errors.New("circuit open")
+
+// ErrPermissionDenied indicates a component method call was rejected, without
+// being run, because the authorization policy of the calling component doesn't
+// allow it. Policies are set in the config; see runtime.AccessConfig. You can
+// use ErrPermissionDenied in conjunction with errors.Is to tell rejected calls
+// apart from failed ones:
+//
+//	err := checkout.PlaceOrder(ctx, order)
+//	if errors.Is(err, weaver.ErrPermissionDenied) {
+//	    // The config doesn't allow this component to place orders.
+//	}
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrPayloadTooLarge indicates a component method call failed because its
+// serialized arguments or results were larger than the limit of the method.
+// Limits are set per component and per method by the max_request_bytes and
+// max_response_bytes fields of the config. Oversized arguments are never
+// sent, and oversized results are never sent back. You can use
+// ErrPayloadTooLarge in conjunction with errors.Is to fall back to a smaller
+// request, e.g., a page of the results rather than all of them:
+//
+//	products, err := catalog.ListProducts(ctx)
+//	if errors.Is(err, weaver.ErrPayloadTooLarge) {
+//	    products, err = catalog.ListProductsPage(ctx, 0, pageSize)
+//	}
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// RegisterError registers the concrete type of err, so that errors of this
+// type returned by a remote component method keep their type and fields. The
+// caller can then use errors.As to retrieve the original error:
+//
+//	type InvalidAddressError struct {
+//	    Field string // the invalid field, e.g., "ZipCode"
+//	}
+//
+//	func (e *InvalidAddressError) Error() string { ... }
+//
+//	func init() {
+//	    weaver.RegisterError(&InvalidAddressError{})
+//	}
+//
+//	// In the caller:
+//	_, err := shipping.GetQuote(ctx, addr, items)
+//	var invalid *shipping.InvalidAddressError
+//	if errors.As(err, &invalid) {
+//	    // Ask the user to fix invalid.Field.
+//	}
+//
+// RegisterError is analogous to gob.Register, and registered errors are
+// serialized using encoding/gob, so only their exported fields are preserved.
+// A type must be registered in every process, typically in an init function
+// of the package that defines it. Registering two different types with the
+// same name panics.
+//
+// Errors that are not registered, or that cannot be gob encoded, are returned
+// to the caller as a *RemoteError.
+func RegisterError(err error) {
+	codegen.RegisterError(err)
+}
+
+// RemoteError is an error returned by a remote component method whose type
+// was not registered with RegisterError. It preserves the message of the
+// original error, and errors.Is still reports whether the original error
+// wrapped a particular (comparable) error value:
+//
+//	var remote *weaver.RemoteError
+//	if errors.As(err, &remote) {
+//	    logger.Error("call failed", err, "component", remote.Component)
+//	}
+type RemoteError struct {
+	Component string // the component that returned the error
+	Message   string // the message of the original error
+
+	err error // the decoded error
+}
+
+// Error implements the error interface. It returns the message of the
+// original error.
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// Unwrap makes RemoteError compatible with errors.Is, errors.As, and
+// errors.Unwrap.
+func (e *RemoteError) Unwrap() error {
+	return e.err
+}
+
 // mainIface is an empty interface "implemented" by the user main function,
 // allowing us to treat the user main as a regular Service Weaver component in the
 // implementation.
@@ -75,12 +230,18 @@ func init() {
 		Iface:        reflect.TypeOf((*mainIface)(nil)).Elem(),
 		New:          func() any { return &mainImpl{} },
 		LocalStubFn:  func(any, trace.Tracer) any { return nil },
-		ClientStubFn: func(codegen.Stub, string) any { return nil },
-		ServerStubFn: func(any, func(uint64, float64)) codegen.Server { return nil },
+		ClientStubFn: func(codegen.Stub, string, codegen.Codec) any { return nil },
+		ServerStubFn: func(any, func(uint64, float64), codegen.Codec) codegen.Server { return nil },
 	})
 
-	// Add a trivial /healthz handler to the default mux.
+	// Add a trivial /healthz handler to the default mux. The handler reports
+	// the process as unavailable once it starts draining.
 	http.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if processDraining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("draining")) //nolint:errcheck // response write error
+			return
+		}
 		w.Write([]byte("ok")) //nolint:errcheck // response write error
 	})
 }
@@ -104,6 +265,22 @@ func Init(ctx context.Context) Instance {
 }
 
 func initInternal(ctx context.Context) (Instance, error) {
+	if report := os.Getenv(runtime.CheckConfigKey); report != "" {
+		// Run by "weaver config check".
+		checkConfigAndExit(report, codegen.Registered())
+	}
+	if file := os.Getenv(runtime.ListComponentsKey); file != "" {
+		// Run by a deployer previewing a deployment.
+		listComponentsAndExit(file, codegen.Registered())
+	}
+	if file := os.Getenv(runtime.DescribeComponentsKey); file != "" {
+		// Run by a deployer before rolling the binary out.
+		describeComponentsAndExit(file, codegen.Registered())
+	}
+	if file := os.Getenv(runtime.DescribeMetricsKey); file != "" {
+		// Run by "weaver metrics".
+		describeMetricsAndExit(file, codegen.Registered())
+	}
 	wlet, err := newWeavelet(ctx, codegen.Registered())
 	if err != nil {
 		return nil, fmt.Errorf("internal error creating weavelet: %w", err)
@@ -133,13 +310,161 @@ func Get[T any](requester Instance) (T, error) {
 	var zero T
 	iface := reflect.TypeOf(&zero).Elem()
 	rep := requester.rep()
-	component, err := rep.wlet.getComponentByType(iface)
+	result, err := rep.wlet.getInstanceByType(iface, rep.info.Name)
 	if err != nil {
 		return zero, err
 	}
-	result, err := rep.wlet.getInstance(component, rep.info.Name)
+	return result.(T), nil
+}
+
+// Fill fills every [weaver.Ref] field of the struct pointed to by v with a
+// handle to the corresponding component, as if by calling [weaver.Get] with
+// the provided requester. Component implementations do not need to call Fill;
+// their Ref fields are filled automatically. Fill is intended for structs that
+// are not components, e.g.:
+//
+//	type server struct {
+//	    cart    weaver.Ref[Cart]
+//	    catalog weaver.Ref[Catalog]
+//	}
+//
+//	func main() {
+//	    root := weaver.Init(context.Background())
+//	    var s server
+//	    if err := weaver.Fill(root, &s); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    // ...
+//	}
+//
+// Fill also fills the [weaver.External] fields of v with the clients of their
+// external services.
+func Fill(requester Instance, v any) error {
+	rep := requester.rep()
+	if err := fillRefs(v, func(t reflect.Type) (any, error) {
+		return rep.wlet.getInstanceByType(t, rep.info.Name)
+	}); err != nil {
+		return err
+	}
+	return fillExternals(v, func(name string, t reflect.Type) (any, error) {
+		return rep.wlet.getExternal(rep.wlet.ctx, name, t)
+	})
+}
+
+// MustGet is like Get, but it treats a failure to get the component as fatal.
+// If Get fails, MustGet logs the error using requester's logger, reports it to
+// the deployer, and exits the process with a non-zero exit code once the logs
+// are flushed. For example:
+//
+//	func main() {
+//	    root := weaver.Init(context.Background())
+//	    foo := weaver.MustGet[Foo](root) // Get the Foo component or die.
+//	    // ...
+//	}
+//
+// MustGet is intended for startup code where there is no meaningful way to
+// recover from a missing dependency. Use Get in code that can degrade
+// gracefully.
+func MustGet[T any](requester Instance) T {
+	result, err := Get[T](requester)
 	if err != nil {
-		return zero, err
+		var zero T
+		name := reflect.TypeOf(&zero).Elem().String()
+		requester.Logger().Error("fatal: cannot get component", err, "component", name)
+		rep := requester.rep()
+		rep.wlet.fatal(rep.info.Name, "weaver.MustGet", fmt.Errorf("cannot get component %s: %w", name, err))
 	}
-	return result.(T), nil
+	return result
+}
+
+// OnStart registers a hook that the Service Weaver runtime runs once the
+// process has started: after the components it hosts have been initialized
+// (i.e., their Init methods have returned), and before any of its listeners
+// accepts a connection. Hooks are run once, in registration order. A hook is
+// a natural place for startup work that needs other components, like warming
+// up a cache:
+//
+//	func main() {
+//	    root := weaver.Init(context.Background())
+//	    currency := weaver.MustGet[CurrencyService](root)
+//	    weaver.OnStart(root, func(ctx context.Context) error {
+//	        _, err := currency.GetSupportedCurrencies(ctx)
+//	        return err
+//	    })
+//	    lis, err := root.Listener("frontend", weaver.ListenerOptions{})
+//	    // ...
+//	}
+//
+// In the process hosting main, hooks run when the process first accepts a
+// connection on a Listener; processes that don't host main run their hooks
+// once the components assigned to them have been initialized. A hook that is
+// registered after the hooks have run is run immediately.
+//
+// If a hook returns an error, the error is logged and the process exits with
+// a non-zero exit code, which the deployer reports as a failure of the
+// application.
+func OnStart(requester Instance, hook func(context.Context) error) {
+	requester.rep().wlet.onStart(hook)
+}
+
+// processDraining is true once a weavelet in this process has started
+// draining. A process is never reused after it is drained.
+var processDraining atomic.Bool
+
+// OnDrain registers a hook that the Service Weaver runtime runs when the
+// deployer drains the process before stopping it, e.g., during a rolling
+// update. Draining proceeds as follows:
+//
+//  1. The process reports itself as draining: Draining returns true, and the
+//     /healthz handler on the default mux starts failing. The deployer stops
+//     routing new component method calls and listener traffic to the
+//     process.
+//  2. The process keeps serving as usual for the drain_grace_period in the
+//     config, giving load balancers and callers time to shift traffic away.
+//  3. The listeners of the process stop accepting connections: new
+//     connections are refused, but Accept blocks rather than fails, so
+//     that servers keep serving the open connections.
+//  4. The drain hooks are run, in registration order.
+//  5. The runtime waits for the component method calls being served by the
+//     process to finish, and for the open listener connections to be
+//     closed. Connections still open at the end of step 5 are closed.
+//  6. The runtime calls the Shutdown(context.Context) error method of every
+//     component hosted by the process that has one, giving the components a
+//     chance to flush buffered writes or release resources. Components are
+//     shut down in the reverse of the order in which they were created.
+//
+// Steps 1 to 5 take at most max_drain_time (30s by default); the context
+// passed to the hooks expires at that point. Step 6 takes at most
+// shutdown_timeout (10s by default), after which the process is stopped
+// regardless. All three durations are set in the config:
+//
+//	[serviceweaver]
+//	drain_grace_period = "5s"
+//	max_drain_time = "1m"
+//	shutdown_timeout = "20s"
+//
+// A hook is a natural place to shut down an HTTP server gracefully, letting
+// in-flight requests finish and closing idle keep-alive connections, which
+// would otherwise stay open until step 5 times out:
+//
+//	srv := &http.Server{Handler: mux}
+//	drained := make(chan struct{})
+//	weaver.OnDrain(root, func(ctx context.Context) error {
+//	    defer close(drained)
+//	    return srv.Shutdown(ctx)
+//	})
+//	if err := srv.Serve(lis); err != http.ErrServerClosed {
+//	    return err
+//	}
+//	<-drained
+//
+// Errors returned by hooks are logged.
+func OnDrain(requester Instance, hook func(context.Context) error) {
+	requester.rep().wlet.onDrain(hook)
+}
+
+// Draining returns whether the process hosting requester is draining; see
+// OnDrain. Readiness checks should fail once a process is draining.
+func Draining(requester Instance) bool {
+	return requester.rep().wlet.draining.Load()
 }
@@ -0,0 +1,141 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/envelope"
+	"github.com/ServiceWeaver/weaver/runtime/metrics"
+)
+
+// Benchmark benchmarks an operation on Service Weaver components. It runs
+// two sub-benchmarks, each of which calls f with a new application:
+//
+//   - "local" runs every component in a single process, like a
+//     SingleProcess test, so that component method calls are regular
+//     function calls. It measures the cost of the operation itself.
+//   - "rpc" runs every component in its own process, like a multiprocess
+//     test, so that component method calls are remote calls. In addition to
+//     the time per operation, it reports the number of remote calls made
+//     per operation, by any process, as "rpcs/op", and the number of bytes of
+//     serialized arguments and results they exchanged as "rpc-B/op".
+//
+// Both sub-benchmarks report allocations per operation. They only count the
+// allocations of the benchmark's process, so in the "rpc" sub-benchmark
+// they exclude the allocations of the components that run in other
+// processes. f should run the operation b.N times:
+//
+//	func BenchmarkHomePage(b *testing.B) {
+//	    ctx := context.Background()
+//	    weavertest.Benchmark(b, weavertest.Options{}, func(b *testing.B, root weaver.Instance) {
+//	        frontend, err := weaver.Get[frontend.Server](root)
+//	        if err != nil {
+//	            b.Fatal(err)
+//	        }
+//	        for i := 0; i < b.N; i++ {
+//	            if _, err := frontend.HomePage(ctx, "alice"); err != nil {
+//	                b.Fatal(err)
+//	            }
+//	        }
+//	    })
+//	}
+//
+// The timer is reset before f is called. The remote calls made by f outside
+// its loop, e.g., to warm up caches, are counted too, but, with a large
+// enough b.N, their cost is amortized over all the operations. Tracking
+// "rpcs/op" in CI benchmarks catches regressions where an operation starts
+// making more calls than it should, e.g., a lookup per item of a page rather
+// than one lookup for the whole page. Options.SingleProcess is ignored, and
+// Options.Simulator is not supported.
+func Benchmark(b *testing.B, opts Options, f func(b *testing.B, root weaver.Instance)) {
+	b.Helper()
+	if opts.Simulator != nil {
+		b.Fatal("weavertest.Benchmark: the Simulator option is not supported")
+	}
+	ctx := context.Background()
+	fakes, faults := opts.testDoubles()
+
+	b.Run("local", func(b *testing.B) {
+		root := initSingleProcess(ctx, b, opts.Config, fakes, faults, opts.Clock)
+		b.ReportAllocs()
+		b.ResetTimer()
+		f(b, root)
+	})
+
+	b.Run("rpc", func(b *testing.B) {
+		root, d := initMultiProcess(ctx, b, opts.Config, opts.Placements, fakes, faults, opts.Clock)
+		before := d.rpcStats(b)
+		b.ReportAllocs()
+		b.ResetTimer()
+		f(b, root)
+		b.StopTimer()
+		after := d.rpcStats(b)
+		b.ReportMetric((after.calls-before.calls)/float64(b.N), "rpcs/op")
+		b.ReportMetric((after.bytes-before.bytes)/float64(b.N), "rpc-B/op")
+	})
+}
+
+// rpcStats counts the remote calls made by the processes of an application.
+type rpcStats struct {
+	calls float64 // number of remote calls
+	bytes float64 // bytes of serialized arguments and results
+}
+
+// rpcStats returns the number of remote calls made so far by the processes
+// of the application, including the benchmark's process, along with the
+// bytes they exchanged.
+func (d *deployer) rpcStats(b *testing.B) rpcStats {
+	b.Helper()
+	// Fetch the metrics of the weavelets without holding d.mu, which the
+	// envelopes' handlers may need to make progress.
+	d.mu.Lock()
+	var envelopes []*envelope.Envelope
+	for _, g := range d.groups {
+		for _, c := range g.conns {
+			// The main weavelet runs in this process. Its metrics are read
+			// directly below.
+			if c.envelope != nil {
+				envelopes = append(envelopes, c.envelope)
+			}
+		}
+	}
+	d.mu.Unlock()
+
+	var stats rpcStats
+	count := func(snapshots []*metrics.MetricSnapshot) {
+		for _, m := range snapshots {
+			switch m.Name {
+			case codegen.MethodCounts.Name():
+				stats.calls += m.Value
+			case codegen.MethodBytesRequest.Name(), codegen.MethodBytesReply.Name():
+				// The value of a histogram is the sum of its observations.
+				stats.bytes += m.Value
+			}
+		}
+	}
+	count(metrics.Snapshot())
+	for _, e := range envelopes {
+		snapshots, err := e.GetMetrics()
+		if err != nil {
+			b.Fatalf("error fetching metrics: %v", err)
+		}
+		count(snapshots)
+	}
+	return stats
+}
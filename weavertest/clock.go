@@ -0,0 +1,289 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+// A FakeClock is a weaver.Clock that only moves when the test advances it.
+// Passed in Options.Clock, it replaces the clock returned by weaver.ClockOf,
+// which lets a test expire TTLs, sessions, and the like, and run periodic
+// work, deterministically and without sleeping. For example:
+//
+//	func TestSessionExpiry(t *testing.T) {
+//	    clock := weavertest.NewFakeClock(time.Time{})
+//	    root := weavertest.Init(ctx, t, weavertest.Options{
+//	        SingleProcess: true,
+//	        Clock:         clock,
+//	    })
+//	    sessions, err := weaver.Get[sessions.T](root)
+//	    // ...
+//	    clock.Advance(2 * time.Hour)
+//	    if _, err := sessions.Get(ctx, id); !errors.Is(err, sessions.ErrExpired) {
+//	        t.Fatalf("session not expired: %v", err)
+//	    }
+//	}
+//
+// Advance fires the timers and tickers that are due in order of their
+// deadlines, moving the clock to every deadline in turn. Timers and tickers
+// send on their channels without blocking, so a tick is dropped if the
+// previous one has not been received, like with a time.Ticker. Code that
+// creates a timer in another goroutine can be synchronized with using
+// WaitForTimers before advancing the clock.
+//
+// In a multiprocess test, every process runs the test and gets its own fake
+// clock, so advancing the clock of the test only affects the components that
+// run in the test's process. Use SingleProcess to control the time of every
+// component.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    sync.Cond   // signalled when waiters change
+	now     time.Time   // current time
+	seq     int         // sequence number of the last waiter added
+	waiters []*fakeWait // active timers, tickers, and sleeps
+}
+
+var _ weaver.Clock = &FakeClock{}
+
+// fakeWait is a timer, ticker, or sleep of a FakeClock.
+type fakeWait struct {
+	clock    *FakeClock
+	seq      int            // breaks ties between equal deadlines
+	deadline time.Time      // when the wait fires next
+	period   time.Duration  // period of a ticker, or zero
+	c        chan time.Time // channel of a timer or ticker
+	done     chan struct{}  // closed when a sleep fires
+}
+
+// NewFakeClock returns a fake clock set to the provided time, or to
+// 2000-01-01 00:00:00 UTC if the time is zero.
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = simEpoch
+	}
+	c := &FakeClock{now: start}
+	c.cond.L = &c.mu
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the time elapsed since t, according to the clock.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// NewTimer returns a timer that fires once the clock is advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) weaver.Timer {
+	w := &fakeWait{c: make(chan time.Time, 1)}
+	c.add(w, d)
+	return fakeTimer{w}
+}
+
+// NewTicker returns a ticker that ticks every time the clock is advanced by
+// another d.
+func (c *FakeClock) NewTicker(d time.Duration) weaver.Ticker {
+	if d <= 0 {
+		panic(fmt.Errorf("FakeClock.NewTicker: non-positive interval %v", d))
+	}
+	w := &fakeWait{c: make(chan time.Time, 1), period: d}
+	c.add(w, d)
+	return fakeTicker{w}
+}
+
+// Sleep blocks until the clock is advanced by d, or until ctx is done.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	w := &fakeWait{done: make(chan struct{})}
+	c.add(w, d)
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.remove(w)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Advance moves the clock forward by d, firing the timers, tickers, and
+// sleeps that are due along the way, in order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	if d < 0 {
+		panic(fmt.Errorf("FakeClock.Advance: negative duration %v", d))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		w := c.next()
+		if w == nil || w.deadline.After(end) {
+			break
+		}
+		c.now = w.deadline
+		c.fire(w)
+	}
+	c.now = end
+}
+
+// WaitForTimers blocks until at least n timers, tickers, and sleeps are
+// pending on the clock. It lets a test wait for a goroutine to create its
+// timer before advancing the clock past it.
+func (c *FakeClock) WaitForTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// Timers returns the number of timers, tickers, and sleeps pending on the
+// clock.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// add schedules w to fire after d.
+func (c *FakeClock) add(w *fakeWait, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.clock = c
+	c.schedule(w, d)
+}
+
+// schedule schedules w to fire after d.
+//
+// REQUIRES: c.mu is held.
+func (c *FakeClock) schedule(w *fakeWait, d time.Duration) {
+	c.seq++
+	w.seq = c.seq
+	w.deadline = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	if d <= 0 {
+		// Like a time.Timer with a non-positive duration, fire right away.
+		c.fire(w)
+	}
+}
+
+// remove removes w from the pending waiters, returning whether it was
+// pending.
+//
+// REQUIRES: c.mu is held.
+func (c *FakeClock) remove(w *fakeWait) bool {
+	for i, x := range c.waiters {
+		if x == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the pending waiter with the earliest deadline, or nil.
+//
+// REQUIRES: c.mu is held.
+func (c *FakeClock) next() *fakeWait {
+	var next *fakeWait
+	for _, w := range c.waiters {
+		if next == nil || w.deadline.Before(next.deadline) ||
+			(w.deadline.Equal(next.deadline) && w.seq < next.seq) {
+			next = w
+		}
+	}
+	return next
+}
+
+// fire fires w at the current time, rescheduling it if it is a ticker.
+//
+// REQUIRES: c.mu is held.
+func (c *FakeClock) fire(w *fakeWait) {
+	c.remove(w)
+	if w.done != nil {
+		close(w.done)
+		return
+	}
+	select {
+	case w.c <- c.now:
+	default:
+		// Drop the tick, like a time.Ticker does for slow receivers.
+	}
+	if w.period > 0 {
+		c.schedule(w, w.period)
+	}
+}
+
+// fakeTimer is a weaver.Timer of a FakeClock.
+type fakeTimer struct{ w *fakeWait }
+
+func (t fakeTimer) C() <-chan time.Time { return t.w.c }
+
+func (t fakeTimer) Stop() bool {
+	t.w.clock.mu.Lock()
+	defer t.w.clock.mu.Unlock()
+	return t.w.clock.remove(t.w)
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool {
+	c := t.w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.remove(t.w)
+	c.schedule(t.w, d)
+	return active
+}
+
+// fakeTicker is a weaver.Ticker of a FakeClock.
+type fakeTicker struct{ w *fakeWait }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.c }
+
+func (t fakeTicker) Stop() {
+	t.w.clock.mu.Lock()
+	defer t.w.clock.mu.Unlock()
+	t.w.clock.remove(t.w)
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic(fmt.Errorf("FakeClock: non-positive ticker interval %v", d))
+	}
+	c := t.w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(t.w)
+	t.w.period = d
+	c.schedule(t.w, d)
+}
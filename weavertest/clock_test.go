@@ -0,0 +1,229 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fired returns the time received on c, or false if c is empty.
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeClockNow(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Fatalf("Now: got %v, want %v", got, start)
+	}
+	clock.Advance(90 * time.Minute)
+	if got, want := clock.Now(), start.Add(90*time.Minute); !got.Equal(want) {
+		t.Fatalf("Now: got %v, want %v", got, want)
+	}
+	if got, want := clock.Since(start), 90*time.Minute; got != want {
+		t.Fatalf("Since: got %v, want %v", got, want)
+	}
+	if got := NewFakeClock(time.Time{}).Now(); !got.Equal(simEpoch) {
+		t.Fatalf("Now of zero clock: got %v, want %v", got, simEpoch)
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	start := clock.Now()
+	timer := clock.NewTimer(time.Minute)
+	clock.Advance(59 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired early")
+	}
+	clock.Advance(time.Second)
+	got, ok := fired(timer.C())
+	if !ok {
+		t.Fatal("timer did not fire")
+	}
+	if want := start.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("timer fired at %v, want %v", got, want)
+	}
+	if timer.Stop() {
+		t.Fatal("Stop of a fired timer returned true")
+	}
+	if n := clock.Timers(); n != 0 {
+		t.Fatalf("Timers: got %d, want 0", n)
+	}
+}
+
+func TestFakeClockTimerStopReset(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	timer := clock.NewTimer(time.Minute)
+	if !timer.Stop() {
+		t.Fatal("Stop of a pending timer returned false")
+	}
+	clock.Advance(time.Hour)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	start := clock.Now()
+	if timer.Reset(time.Minute) {
+		t.Fatal("Reset of a stopped timer returned true")
+	}
+	if !timer.Reset(2 * time.Minute) {
+		t.Fatal("Reset of a pending timer returned false")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("reset timer fired at its old deadline")
+	}
+	clock.Advance(time.Minute)
+	if got, ok := fired(timer.C()); !ok || !got.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("reset timer: got (%v, %t), want (%v, true)", got, ok, start.Add(2*time.Minute))
+	}
+}
+
+func TestFakeClockAdvanceFiresInOrder(t *testing.T) {
+	// Test plan: Create timers out of order, and advance the clock past all
+	// of them at once. Every timer fires at its own deadline, and the clock
+	// ends up at the end of the advance.
+	clock := NewFakeClock(time.Time{})
+	start := clock.Now()
+	durations := []time.Duration{3 * time.Second, time.Second, 2 * time.Second}
+	var timers []<-chan time.Time
+	for _, d := range durations {
+		timers = append(timers, clock.NewTimer(d).C())
+	}
+	clock.Advance(time.Minute)
+	for i, c := range timers {
+		got, ok := fired(c)
+		if want := start.Add(durations[i]); !ok || !got.Equal(want) {
+			t.Errorf("timer %d: got (%v, %t), want (%v, true)", i, got, ok, want)
+		}
+	}
+	if got, want := clock.Now(), start.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("Now: got %v, want %v", got, want)
+	}
+}
+
+func TestFakeClockNonPositiveTimer(t *testing.T) {
+	// Like a time.Timer, a timer with a non-positive duration fires right
+	// away.
+	clock := NewFakeClock(time.Time{})
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, ok := fired(clock.NewTimer(d).C()); !ok {
+			t.Errorf("NewTimer(%v) did not fire right away", d)
+		}
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	start := clock.Now()
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		got, ok := fired(ticker.C())
+		if want := start.Add(time.Duration(i) * time.Second); !ok || !got.Equal(want) {
+			t.Fatalf("tick %d: got (%v, %t), want (%v, true)", i, got, ok, want)
+		}
+	}
+
+	// Ticks that aren't received are dropped, like with a time.Ticker.
+	clock.Advance(10 * time.Second)
+	if got, ok := fired(ticker.C()); !ok || !got.Equal(start.Add(4*time.Second)) {
+		t.Fatalf("first dropped tick: got (%v, %t), want (%v, true)", got, ok, start.Add(4*time.Second))
+	}
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("ticker buffered more than one tick")
+	}
+
+	// Reset changes the period.
+	ticker.Reset(time.Minute)
+	clock.Advance(59 * time.Second)
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("reset ticker ticked early")
+	}
+	clock.Advance(time.Second)
+	if _, ok := fired(ticker.C()); !ok {
+		t.Fatal("reset ticker did not tick")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	if _, ok := fired(ticker.C()); ok {
+		t.Fatal("stopped ticker ticked")
+	}
+	if n := clock.Timers(); n != 0 {
+		t.Fatalf("Timers: got %d, want 0", n)
+	}
+}
+
+func TestFakeClockTickerPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewTicker(0) did not panic")
+		}
+	}()
+	NewFakeClock(time.Time{}).NewTicker(0)
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	errs := make(chan error, 1)
+	go func() { errs <- clock.Sleep(context.Background(), time.Minute) }()
+	clock.WaitForTimers(1)
+	clock.Advance(59 * time.Second)
+	select {
+	case err := <-errs:
+		t.Fatalf("Sleep returned early: %v", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	if err := <-errs; err != nil {
+		t.Fatalf("Sleep: %v", err)
+	}
+}
+
+func TestFakeClockSleepCanceled(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- clock.Sleep(ctx, time.Minute) }()
+	clock.WaitForTimers(1)
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sleep: got %v, want %v", err, context.Canceled)
+	}
+	if n := clock.Timers(); n != 0 {
+		t.Fatalf("Timers after canceled Sleep: got %d, want 0", n)
+	}
+
+	// A sleep with a done context, or a non-positive duration, returns
+	// right away.
+	if err := clock.Sleep(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sleep with done context: got %v, want %v", err, context.Canceled)
+	}
+	if err := clock.Sleep(context.Background(), 0); err != nil {
+		t.Fatalf("Sleep(0): %v", err)
+	}
+}
@@ -19,11 +19,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/internal/cron"
 	"github.com/ServiceWeaver/weaver/internal/envelope/conn"
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/internal/mtls"
+	"github.com/ServiceWeaver/weaver/internal/pubsub"
+	"github.com/ServiceWeaver/weaver/internal/routing"
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/colors"
 	"github.com/ServiceWeaver/weaver/runtime/envelope"
@@ -36,9 +43,7 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-// The default number of times a component is replicated.
-//
-// TODO(mwhittaker): Include this in the Options struct?
+// The default number of times a component is replicated. See Remote.
 const DefaultReplication = 2
 
 // deployer is the weavertest multiprocess deployer. Every multiprocess
@@ -49,7 +54,7 @@ const DefaultReplication = 2
 // This deployer differs from 'weaver multi' in two key ways.
 //
 //  1. This deployer doesn't implement unneeded features (e.g., traces,
-//     metrics, routing, health checking). This greatly simplifies the
+//     metrics, health checking). This greatly simplifies the
 //     implementation.
 //  2. This deployer handles the fact that the main component is run in the
 //     same process as the deployer. This is special to weavertests and
@@ -57,11 +62,18 @@ const DefaultReplication = 2
 type deployer struct {
 	ctx        context.Context
 	ctxCancel  context.CancelFunc
-	t          testing.TB           // the unit test
-	wlet       *protos.EnvelopeInfo // info for subprocesses
-	config     *protos.AppConfig    // application config
-	logger     *slog.Logger         // logger
-	colocation map[string]string    // maps component to group
+	t          testing.TB                       // the unit test
+	wlet       *protos.EnvelopeInfo             // info for subprocesses
+	config     *protos.AppConfig                // application config
+	logger     *slog.Logger                     // logger
+	broker     *pubsub.Broker                   // topic message broker
+	scheduler  *cron.Scheduler                  // cron job scheduler
+	leases     *lease.Table                     // leader election leases
+	clock      weaver.Clock                     // if not nil, replaces the system clock
+	ca         *mtls.CA                         // mints weavelet certificates
+	colocation map[string]string                // maps component to group
+	replicas   map[string]int                   // number of processes, by group, if not DefaultReplication
+	routing    map[string]runtime.RoutingConfig // routing policies, by component
 	running    errgroup.Group
 
 	logMu sync.Mutex // guards log
@@ -74,17 +86,19 @@ type deployer struct {
 
 // A group contains information about a co-location group.
 type group struct {
-	name        string                  // group name
-	conns       []connection            // connections to the weavelet
-	components  map[string]bool         // started components
-	addresses   map[string]bool         // weavelet addresses
-	subscribers map[string][]connection // routing info subscribers, by component
+	name        string                        // group name
+	conns       []connection                  // connections to the weavelet
+	components  map[string]bool               // started components
+	addresses   map[string]bool               // weavelet addresses
+	subscribers map[string][]connection       // routing info subscribers, by component
+	assignments map[string]*protos.Assignment // assignments of routed components
 }
 
 // handler handles a connection to a weavelet.
 type handler struct {
 	*deployer
 	group      *group
+	id         string // weavelet id
 	conn       connection
 	subscribed map[string]bool // routing info subscriptions, by component
 }
@@ -108,14 +122,24 @@ type connection struct {
 
 var _ envelope.EnvelopeHandler = &handler{}
 
-// newDeployer returns a new weavertest multiprocess deployer.
-func newDeployer(ctx context.Context, t testing.TB, wlet *protos.EnvelopeInfo, config *protos.AppConfig) *deployer {
+// newDeployer returns a new weavertest multiprocess deployer that places
+// components as specified by the config and the provided placements. clock,
+// if not nil, replaces the system clock.
+func newDeployer(ctx context.Context, t testing.TB, wlet *protos.EnvelopeInfo, config *protos.AppConfig, placements []Placement, clock weaver.Clock) *deployer {
 	colocation := map[string]string{}
 	for _, group := range config.Colocate {
 		for _, c := range group.Components {
 			colocation[c] = group.Components[0]
 		}
 	}
+	replicas, err := placeComponents(placements, colocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wletConfig, err := runtime.ParseWeaveletConfig(config.Sections)
+	if err != nil {
+		t.Fatal(err)
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	d := &deployer{
 		ctx:        ctx,
@@ -123,7 +147,10 @@ func newDeployer(ctx context.Context, t testing.TB, wlet *protos.EnvelopeInfo, c
 		t:          t,
 		wlet:       wlet,
 		config:     config,
+		clock:      clock,
 		colocation: colocation,
+		replicas:   replicas,
+		routing:    wletConfig.Routing,
 		groups:     map[string]*group{},
 		log:        true,
 	}
@@ -138,6 +165,18 @@ func newDeployer(ctx context.Context, t testing.TB, wlet *protos.EnvelopeInfo, c
 			d.HandleLogEntry(d.ctx, e) //nolint:errcheck // TODO(mwhittaker): Propagate error.
 		},
 	})
+	d.broker = pubsub.NewBroker(ctx, pubsub.NewMemoryStore(), d.logger)
+	var schedulerClock cron.Clock
+	if clock != nil {
+		schedulerClock = cronClock{clock}
+	}
+	d.scheduler = cron.NewScheduler(ctx, d.logger, schedulerClock)
+	d.leases = lease.NewTable()
+	ca, err := mtls.NewCA(wlet.DeploymentId, mtls.DefaultCertLifetime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ca = ca
 
 	t.Cleanup(func() {
 		if err := d.cleanup(); err != nil {
@@ -155,7 +194,7 @@ func newDeployer(ctx context.Context, t testing.TB, wlet *protos.EnvelopeInfo, c
 }
 
 // Init acts like weaver.Init when called from the main component.
-func (d *deployer) Init(config string) weaver.Instance {
+func (d *deployer) Init(config string, fakes map[reflect.Type]any, faults map[reflect.Type]func(context.Context, string) error) weaver.Instance {
 	// Set up the pipes between the envelope and the main weavelet. The
 	// pipes will be closed by the envelope and weavelet conns.
 	//
@@ -199,6 +238,9 @@ func (d *deployer) Init(config string) weaver.Instance {
 		ToWeaveletFile: toWeaveletReader,
 		ToEnvelopeFile: fromWeaveletWriter,
 		TestConfig:     config,
+		TestFakes:      fakes,
+		TestFaults:     faults,
+		TestClock:      d.clock,
 	}
 	ctx := context.WithValue(d.ctx, runtime.BootstrapKey{}, bootstrap)
 	instance := weaver.Init(ctx)
@@ -209,6 +251,7 @@ func (d *deployer) Init(config string) weaver.Instance {
 	handler := &handler{
 		deployer:   d,
 		group:      g,
+		id:         wlet.Id,
 		subscribed: map[string]bool{},
 		conn:       connection{conn: e},
 	}
@@ -276,12 +319,15 @@ func (d *deployer) ExportListener(_ context.Context, req *protos.ExportListenerR
 // registerReplica registers the information about a colocation group replica
 // (i.e., a weavelet).
 func (d *deployer) registerReplica(g *group, info *protos.WeaveletInfo) error {
-	// Update addresses.
+	// Update addresses and assignments.
 	if g.addresses[info.DialAddr] {
 		// Replica already registered.
 		return nil
 	}
 	g.addresses[info.DialAddr] = true
+	for component, assignment := range g.assignments {
+		g.assignments[component] = d.assign(component, assignment, maps.Keys(g.addresses))
+	}
 
 	// Notify subscribers.
 	for component := range g.components {
@@ -304,6 +350,9 @@ func (h *handler) ActivateComponent(_ context.Context, req *protos.ActivateCompo
 	target := h.deployer.group(req.Component)
 	if !target.components[req.Component] {
 		target.components[req.Component] = true
+		if req.Routed {
+			target.assignments[req.Component] = h.deployer.assign(req.Component, &protos.Assignment{}, maps.Keys(target.addresses))
+		}
 
 		// Notify the weavelets.
 		components := maps.Keys(target.components)
@@ -345,6 +394,100 @@ func (h *handler) ActivateComponent(_ context.Context, req *protos.ActivateCompo
 	return &protos.ActivateComponentReply{}, h.deployer.startGroup(target)
 }
 
+// HandleTopicEvent implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleTopicEvent(_ context.Context, event *protos.TopicEvent) error {
+	// Forward the event to every other weavelet. We send the events without
+	// holding the lock, as sending may block.
+	h.mu.Lock()
+	var targets []connection
+	for _, g := range h.groups {
+		for _, c := range g.conns {
+			if c != h.conn {
+				targets = append(targets, c)
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range targets {
+		if err := c.SendTopicEvent(event); err != nil {
+			h.logger.Error("cannot forward topic event", err, "topic", event.Topic)
+		}
+	}
+	return nil
+}
+
+// PublishMessage implements the envelope.EnvelopeHandler interface.
+func (h *handler) PublishMessage(_ context.Context, req *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	if err := h.broker.Publish(req.Message.Topic, req.Message.Payload); err != nil {
+		return nil, err
+	}
+	return &protos.PublishMessageReply{}, nil
+}
+
+// Subscribe implements the envelope.EnvelopeHandler interface.
+func (h *handler) Subscribe(_ context.Context, req *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	// Subscriptions last until the deployer's context is cancelled, which
+	// stops the broker.
+	sub := pubsub.Subscription{Topic: req.Topic, Name: req.Subscription}
+	deliver := func(_ context.Context, req *protos.DeliverMessageRequest) error {
+		return h.conn.DeliverMessage(req)
+	}
+	if _, err := h.broker.Subscribe(sub, deliver); err != nil {
+		return nil, err
+	}
+	return &protos.SubscribeReply{}, nil
+}
+
+// ScheduleJob implements the envelope.EnvelopeHandler interface.
+func (h *handler) ScheduleJob(_ context.Context, req *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	// Like subscriptions, jobs last until the deployer's context is
+	// cancelled, which stops the scheduler.
+	run := func(_ context.Context, req *protos.RunJobRequest) error {
+		return h.conn.RunJob(req)
+	}
+	if _, err := h.scheduler.Schedule(req, run); err != nil {
+		return nil, err
+	}
+	return &protos.ScheduleJobReply{}, nil
+}
+
+// now returns the current time of the deployer's clock.
+func (d *deployer) now() time.Time {
+	if d.clock == nil {
+		return time.Now()
+	}
+	return d.clock.Now()
+}
+
+// cronClock adapts a weaver.Clock to the cron.Clock interface.
+type cronClock struct{ weaver.Clock }
+
+func (c cronClock) NewTimer(d time.Duration) cron.Timer { return c.Clock.NewTimer(d) }
+
+// Lease implements the envelope.EnvelopeHandler interface.
+func (h *handler) Lease(_ context.Context, req *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	return h.leases.Handle(h.now(), req), nil
+}
+
+// HandleProfile implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleProfile(context.Context, *protos.Profile) error {
+	// Tests don't keep profiles.
+	return nil
+}
+
+// HandleCrashReport implements the envelope.EnvelopeHandler interface.
+func (h *handler) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	// Tests don't keep crash reports. The panic is printed by the crashing
+	// weavelet.
+	return nil
+}
+
+// GetCertificate implements the envelope.EnvelopeHandler interface.
+func (h *handler) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	return h.ca.Mint(h.id)
+}
+
 // startGroup starts the provided co-location group in a subprocess, if it
 // hasn't already been started.
 //
@@ -356,7 +499,11 @@ func (d *deployer) startGroup(g *group) error {
 	}
 
 	components := maps.Keys(g.components)
-	for r := 0; r < DefaultReplication; r++ {
+	replicas := DefaultReplication
+	if n, ok := d.replicas[g.name]; ok {
+		replicas = n
+	}
+	for r := 0; r < replicas; r++ {
 		// Start the weavelet.
 		wlet := &protos.EnvelopeInfo{
 			App:           d.wlet.App,
@@ -369,6 +516,7 @@ func (d *deployer) startGroup(g *group) error {
 		handler := &handler{
 			deployer:   d,
 			group:      g,
+			id:         wlet.Id,
 			subscribed: map[string]bool{},
 		}
 		e, err := envelope.NewEnvelope(d.ctx, wlet, d.config)
@@ -409,6 +557,7 @@ func (d *deployer) group(component string) *group {
 			components:  map[string]bool{},
 			addresses:   map[string]bool{},
 			subscribers: map[string][]connection{},
+			assignments: map[string]*protos.Assignment{},
 		}
 		d.groups[name] = g
 	}
@@ -420,9 +569,25 @@ func (d *deployer) group(component string) *group {
 // REQUIRES: d.mu is held.
 func (g *group) routing(component string) *protos.RoutingInfo {
 	return &protos.RoutingInfo{
-		Component: component,
-		Replicas:  maps.Keys(g.addresses),
+		Component:  component,
+		Replicas:   maps.Keys(g.addresses),
+		Assignment: g.assignments[component],
+	}
+}
+
+// assign returns the assignment of the provided routed component to the
+// provided replicas, which replaces the provided assignment.
+func (d *deployer) assign(component string, prev *protos.Assignment, replicas []string) *protos.Assignment {
+	config := d.routing[component]
+	var assignment *protos.Assignment
+	switch config.Strategy {
+	case "consistent_hash":
+		assignment = routing.ConsistentHash(replicas, config.Replication, config.LoadFactor)
+	default:
+		assignment = routing.EqualSlices(replicas)
 	}
+	assignment.Version = prev.Version + 1
+	return assignment
 }
 
 // UpdateRoutingInfo is equivalent to Envelope.UpdateRoutingInfo.
@@ -446,3 +611,36 @@ func (c connection) UpdateComponents(components []string) error {
 	}
 	panic(fmt.Errorf("nil connection"))
 }
+
+// SendTopicEvent is equivalent to Envelope.SendTopicEvent.
+func (c connection) SendTopicEvent(event *protos.TopicEvent) error {
+	if c.envelope != nil {
+		return c.envelope.SendTopicEvent(event)
+	}
+	if c.conn != nil {
+		return c.conn.SendTopicEvent(event)
+	}
+	panic(fmt.Errorf("nil connection"))
+}
+
+// DeliverMessage is equivalent to Envelope.DeliverMessage.
+func (c connection) DeliverMessage(req *protos.DeliverMessageRequest) error {
+	if c.envelope != nil {
+		return c.envelope.DeliverMessage(req)
+	}
+	if c.conn != nil {
+		return c.conn.DeliverMessageRPC(req)
+	}
+	panic(fmt.Errorf("nil connection"))
+}
+
+// RunJob runs a cron job over the connection.
+func (c connection) RunJob(req *protos.RunJobRequest) error {
+	if c.envelope != nil {
+		return c.envelope.RunJob(req)
+	}
+	if c.conn != nil {
+		return c.conn.RunJobRPC(req)
+	}
+	panic(fmt.Errorf("nil connection"))
+}
@@ -48,4 +48,52 @@
 //	    reverser, err := weaver.Get[Reverser](root)
 //	    // ...
 //	}
+//
+// To test a component without starting the components it depends on, replace
+// them with fakes using the Fakes option. See [Fake].
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{
+//	    Fakes: []weavertest.FakeComponent{weavertest.Fake[Dictionary](fakeDictionary{})},
+//	})
+//
+// To test how components cope with an unhealthy dependency, inject faults into
+// the calls to the dependency using the Faults option. See [InjectFaults].
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{
+//	    Faults: []weavertest.ComponentFaults{
+//	        weavertest.InjectFaults[Dictionary](weavertest.Fault{DropRate: 0.5}),
+//	    },
+//	})
+//
+// To reproduce a concurrency bug, run the test as a deterministic simulation
+// using the Simulator option. Calls made concurrently by the tasks started
+// with the simulator are scheduled in an order determined by a seed. See
+// [Simulator].
+//
+//	sim := weavertest.NewSimulator(t, seed)
+//	root := weavertest.Init(ctx, t, weavertest.Options{Simulator: sim})
+//
+// To test components that expire entries, sessions, and the like, or that do
+// periodic work, without sleeping, replace the clock returned by
+// [weaver.ClockOf] with a fake clock using the Clock option, and advance it
+// from the test. See [FakeClock].
+//
+//	clock := weavertest.NewFakeClock(time.Time{})
+//	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true, Clock: clock})
+//	// ...
+//	clock.Advance(time.Hour)
+//
+// To benchmark components, and count the remote calls and bytes an operation
+// costs, use [Benchmark].
+//
+//	weavertest.Benchmark(b, weavertest.Options{}, func(b *testing.B, root weaver.Instance) {
+//	    // Run the operation b.N times...
+//	})
+//
+// To reproduce a bug triggered by the calls a deployment served, record the
+// calls with the recording section of the config, and replay them using
+// [Replay].
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{})
+//	diverged := weavertest.Replay(ctx, t, root, "testdata/calls")
 package weavertest
@@ -16,9 +16,14 @@ package weavertest
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
 )
 
 // Options configure weavertest.Init.
@@ -31,6 +36,176 @@ type Options struct {
 	// Service Weaver config file. It can contain application level as well as component
 	// level configuration. Config is allowed to be empty.
 	Config string
+
+	// Fakes replace the implementations of components with test doubles.
+	// See Fake.
+	Fakes []FakeComponent
+
+	// Faults are injected into the calls to components. See InjectFaults.
+	Faults []ComponentFaults
+
+	// Placements place components in a multiprocess test. By default, every
+	// component other than main runs in its own co-location group, unless
+	// colocated by the config, in DefaultReplication processes. See Local,
+	// Remote, and ColocatedWith. Placements are ignored by single process and
+	// simulated tests.
+	Placements []Placement
+
+	// Simulator, if not nil, runs the test as a deterministic simulation,
+	// with every component in a single process, regardless of
+	// SingleProcess. See Simulator.
+	Simulator *Simulator
+
+	// Clock, if not nil, replaces the system clock returned by
+	// weaver.ClockOf. See FakeClock. A simulated test uses the clock of its
+	// Simulator, so Clock must be nil or the clock returned by
+	// Simulator.Clock.
+	Clock weaver.Clock
+}
+
+// A FakeComponent is a fake implementation of a component. See Fake.
+type FakeComponent struct {
+	intf reflect.Type // component interface type
+	impl any          // fake implementation of intf
+}
+
+// Fake returns a fake implementation of the component with interface type T.
+// Passed in Options.Fakes, it replaces the real implementation of the
+// component for the duration of a test, so that a component can be tested
+// without starting its dependencies. For example:
+//
+//	type fakeCart struct{ items []cartservice.CartItem }
+//
+//	func (f *fakeCart) GetCart(context.Context, string) ([]cartservice.CartItem, error) {
+//	    return f.items, nil
+//	}
+//	...
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{
+//	    Fakes: []weavertest.FakeComponent{
+//	        weavertest.Fake[cartservice.T](&fakeCart{}),
+//	    },
+//	})
+//
+// A faked component is never started, and calls to it are regular method
+// calls on impl, made in the process of the caller. In a multiprocess test,
+// every process runs the test function to construct its own fakes, so the
+// fakes in other processes do not share state with impl; calls made from the
+// test itself always reach impl. A fake is typically a struct that implements
+// T, but it need not embed weaver.Implements.
+func Fake[T any](impl T) FakeComponent {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("Fake: type %v is not an interface", t))
+	}
+	return FakeComponent{intf: t, impl: impl}
+}
+
+// A Fault is a fault injected into the calls to the methods of a component.
+// See InjectFaults.
+type Fault struct {
+	// The name of the method whose calls are affected, e.g., "GetAds". Empty
+	// means every method of the component.
+	Method string
+
+	// Every affected call is delayed by Delay before being made.
+	Delay time.Duration
+
+	// The fraction, in [0, 1], of affected calls that are dropped. A dropped
+	// call does not reach the component, and fails with an error err such
+	// that errors.Is(err, weaver.ErrRetriable), as if the component were
+	// unreachable.
+	DropRate float64
+
+	// If not nil, every affected call that is not dropped fails with Err,
+	// without reaching the component.
+	Err error
+}
+
+// ComponentFaults holds the faults injected into the calls to a component.
+// See InjectFaults.
+type ComponentFaults struct {
+	intf   reflect.Type // component interface type
+	faults []Fault      // faults injected into calls to intf
+}
+
+// InjectFaults returns the faults to inject into the calls to the component
+// with interface type T. Passed in Options.Faults, the faults are injected
+// into every call to the component made during a test, by the test or by
+// other components, so that a test can check how an application copes with
+// an unhealthy component. For example:
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{
+//	    Faults: []weavertest.ComponentFaults{
+//	        // Drop half the calls to adservice.T.GetAds.
+//	        weavertest.InjectFaults[adservice.T](weavertest.Fault{
+//	            Method:   "GetAds",
+//	            DropRate: 0.5,
+//	        }),
+//	        // Slow down and fail every call to recommendationservice.T.
+//	        weavertest.InjectFaults[recommendationservice.T](weavertest.Fault{
+//	            Delay: 100 * time.Millisecond,
+//	            Err:   errors.New("unavailable"),
+//	        }),
+//	    },
+//	})
+//
+// The faults are applied in order; a call that is dropped or failed by one
+// fault is not affected by later faults. Calls to local components are made
+// through the components' server stubs when faults are injected into them, so
+// their arguments and results are serialized, as if the components were
+// remote.
+func InjectFaults[T any](faults ...Fault) ComponentFaults {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("InjectFaults: type %v is not an interface", t))
+	}
+	for _, f := range faults {
+		if f.Method == "" {
+			continue
+		}
+		if _, ok := t.MethodByName(f.Method); !ok {
+			panic(fmt.Errorf("InjectFaults: %v has no method %q", t, f.Method))
+		}
+	}
+	return ComponentFaults{intf: t, faults: faults}
+}
+
+// inject injects the faults into a call to the provided method. It returns
+// the error the call fails with, or nil if the call should be made. If sim is
+// not nil, delays and drops use its clock and random number generator.
+func (c ComponentFaults) inject(ctx context.Context, method string, sim *Simulator) error {
+	for _, f := range c.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		if f.Delay > 0 {
+			if sim != nil {
+				if err := sim.Sleep(ctx, f.Delay); err != nil {
+					return err
+				}
+			} else {
+				select {
+				case <-time.After(f.Delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if f.DropRate > 0 {
+			r := rand.Float64
+			if sim != nil {
+				r = sim.float64
+			}
+			if r() < f.DropRate {
+				return fmt.Errorf("%w: weavertest dropped call to %v.%s", weaver.ErrRetriable, c.intf, method)
+			}
+		}
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+	return nil
 }
 
 // Init is a testing version of weaver.Init. Calling Init will create a brand
@@ -45,8 +220,49 @@ type Options struct {
 //	    // Test the Foo component...
 //	}
 func Init(ctx context.Context, t testing.TB, opts Options) weaver.Instance {
+	fakes, faults := opts.testDoubles()
+	if sim := opts.Simulator; sim != nil {
+		if opts.Clock != nil && opts.Clock != weaver.Clock(sim.clock) {
+			t.Fatal("weavertest.Init: a simulated test must use the clock of its Simulator; see Simulator.Clock")
+		}
+		// Yield to the simulator before every call to a component, and
+		// before injecting faults into the call.
+		byType := map[reflect.Type]ComponentFaults{}
+		for _, f := range opts.Faults {
+			byType[f.intf] = f
+		}
+		for _, reg := range codegen.Registered() {
+			if reg.Name == "main" {
+				continue
+			}
+			reg, f := reg, byType[reg.Iface]
+			faults[reg.Iface] = func(ctx context.Context, method string) error {
+				sim.yield(ctx, reg.Name, method)
+				return f.inject(ctx, method, sim)
+			}
+		}
+		return initSingleProcess(ctx, t, opts.Config, fakes, faults, sim.clock)
+	}
 	if opts.SingleProcess {
-		return initSingleProcess(ctx, t, opts.Config)
+		return initSingleProcess(ctx, t, opts.Config, fakes, faults, opts.Clock)
+	}
+	root, _ := initMultiProcess(ctx, t, opts.Config, opts.Placements, fakes, faults, opts.Clock)
+	return root
+}
+
+// testDoubles returns the fake component implementations and the fault
+// injectors specified by opts, by component interface type.
+func (opts Options) testDoubles() (map[reflect.Type]any, map[reflect.Type]func(context.Context, string) error) {
+	fakes := map[reflect.Type]any{}
+	for _, fake := range opts.Fakes {
+		fakes[fake.intf] = fake.impl
+	}
+	faults := map[reflect.Type]func(context.Context, string) error{}
+	for _, f := range opts.Faults {
+		f := f
+		faults[f.intf] = func(ctx context.Context, method string) error {
+			return f.inject(ctx, method, nil)
+		}
 	}
-	return initMultiProcess(ctx, t, opts.Config)
+	return fakes, faults
 }
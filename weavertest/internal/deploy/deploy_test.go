@@ -46,6 +46,26 @@ func TestReplicated(t *testing.T) {
 	}
 }
 
+// TestPlacedReplicas tests that the weavertest deployer replicates a component
+// as many times as specified by its placement.
+func TestPlacedReplicas(t *testing.T) {
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{
+		Placements: []weavertest.Placement{weavertest.Remote[deploy.Started](3)},
+	})
+	dir := t.TempDir()
+	w, err := weaver.Get[deploy.Widget](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Use(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := numDeployed(t, dir), 3; got != want {
+		t.Fatalf("wrong number of deployed processes: want %d, got %d", want, got)
+	}
+}
+
 // numDeployed returns the number of Started or ReplicatedStarted components that
 // have been successfully deployed.
 func numDeployed(t *testing.T, dir string) int {
@@ -16,25 +16,27 @@ func init() {
 		Iface: reflect.TypeOf((*Started)(nil)).Elem(),
 		New:   func() any { return &started{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return started_local_stub{impl: impl.(Started), tracer: tracer}
+			return started_local_stub{impl: impl.(Started), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return started_client_stub{stub: stub, markStartedMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Started", Method: "MarkStarted"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return started_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), markStartedMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Started", Method: "MarkStarted"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return started_server_stub{impl: impl.(Started), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return started_server_stub{impl: impl.(Started), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget",
-		Iface:       reflect.TypeOf((*Widget)(nil)).Elem(),
-		New:         func() any { return &widget{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return widget_local_stub{impl: impl.(Widget), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return widget_client_stub{stub: stub, useMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget", Method: "Use"})}
+		Name:  "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget",
+		Iface: reflect.TypeOf((*Widget)(nil)).Elem(),
+		New:   func() any { return &widget{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return widget_local_stub{impl: impl.(Widget), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return widget_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), useMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget", Method: "Use"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return widget_server_stub{impl: impl.(Widget), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return widget_server_stub{impl: impl.(Widget), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -42,8 +44,9 @@ func init() {
 // Local stub implementations.
 
 type started_local_stub struct {
-	impl   Started
-	tracer trace.Tracer
+	impl        Started
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s started_local_stub) MarkStarted(ctx context.Context, a0 string) (err error) {
@@ -60,12 +63,19 @@ func (s started_local_stub) MarkStarted(ctx context.Context, a0 string) (err err
 		}()
 	}
 
-	return s.impl.MarkStarted(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.MarkStarted(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Started", Method: "MarkStarted", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.MarkStarted(ctx, a0)
+	})
+	return
 }
 
 type widget_local_stub struct {
-	impl   Widget
-	tracer trace.Tracer
+	impl        Widget
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s widget_local_stub) Use(ctx context.Context, a0 string) (err error) {
@@ -82,17 +92,35 @@ func (s widget_local_stub) Use(ctx context.Context, a0 string) (err error) {
 		}()
 	}
 
-	return s.impl.Use(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Use(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget", Method: "Use", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.Use(ctx, a0)
+	})
+	return
 }
 
 // Client stub implementations.
 
 type started_client_stub struct {
 	stub               codegen.Stub
+	codec              codegen.Codec
+	interceptor        codegen.Interceptor
 	markStartedMetrics *codegen.MethodMetrics
 }
 
 func (s started_client_stub) MarkStarted(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callMarkStarted(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Started", Method: "MarkStarted", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callMarkStarted(ctx, a0)
+	})
+	return
+}
+
+func (s started_client_stub) callMarkStarted(ctx context.Context, a0 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.markStartedMetrics.Count.Add(1)
@@ -120,6 +148,22 @@ func (s started_client_stub) MarkStarted(ctx context.Context, a0 string) (err er
 		s.markStartedMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.markStartedMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.markStartedMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -128,7 +172,6 @@ func (s started_client_stub) MarkStarted(ctx context.Context, a0 string) (err er
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.markStartedMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -146,11 +189,23 @@ func (s started_client_stub) MarkStarted(ctx context.Context, a0 string) (err er
 }
 
 type widget_client_stub struct {
-	stub       codegen.Stub
-	useMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	useMetrics  *codegen.MethodMetrics
 }
 
 func (s widget_client_stub) Use(ctx context.Context, a0 string) (err error) {
+	if s.interceptor == nil {
+		return s.callUse(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget", Method: "Use", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callUse(ctx, a0)
+	})
+	return
+}
+
+func (s widget_client_stub) callUse(ctx context.Context, a0 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.useMetrics.Count.Add(1)
@@ -178,6 +233,22 @@ func (s widget_client_stub) Use(ctx context.Context, a0 string) (err error) {
 		s.useMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.useMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.useMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -186,7 +257,6 @@ func (s widget_client_stub) Use(ctx context.Context, a0 string) (err error) {
 
 	// Encode arguments.
 	enc.String(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.useMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -206,8 +276,10 @@ func (s widget_client_stub) Use(ctx context.Context, a0 string) (err error) {
 // Server stub implementations.
 
 type started_server_stub struct {
-	impl    Started
-	addLoad func(key uint64, load float64)
+	impl        Started
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -220,6 +292,11 @@ func (s started_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s started_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s started_server_stub) markStarted(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -229,24 +306,40 @@ func (s started_server_stub) markStarted(ctx context.Context, args []byte) (res
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.MarkStarted(ctx, a0)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.MarkStarted(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Started", Method: "MarkStarted", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.MarkStarted(ctx, a0)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type widget_server_stub struct {
-	impl    Widget
-	addLoad func(key uint64, load float64)
+	impl        Widget
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -259,6 +352,11 @@ func (s widget_server_stub) GetStubFn(method string) func(ctx context.Context, a
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s widget_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s widget_server_stub) use(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -268,16 +366,30 @@ func (s widget_server_stub) use(ctx context.Context, args []byte) (res []byte, e
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Use(ctx, a0)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Use(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/deploy/Widget", Method: "Use", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.Use(ctx, a0)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -13,27 +13,31 @@ import (
 
 func init() {
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer",
-		Iface:       reflect.TypeOf((*Errer)(nil)).Elem(),
-		New:         func() any { return &errer{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return errer_local_stub{impl: impl.(Errer), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return errer_client_stub{stub: stub, errMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer", Method: "Err"})}
+		Name:  "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer",
+		Iface: reflect.TypeOf((*Errer)(nil)).Elem(),
+		New:   func() any { return &errer{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return errer_local_stub{impl: impl.(Errer), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return errer_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), errMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer", Method: "Err"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return errer_server_stub{impl: impl.(Errer), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return errer_server_stub{impl: impl.(Errer), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer",
-		Iface:       reflect.TypeOf((*Failer)(nil)).Elem(),
-		New:         func() any { return &failer{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return failer_local_stub{impl: impl.(Failer), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return failer_client_stub{stub: stub, imJustHereSoWeaverGenerateDoesntComplainMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer", Method: "ImJustHereSoWeaverGenerateDoesntComplain"})}
+		Name:  "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer",
+		Iface: reflect.TypeOf((*Failer)(nil)).Elem(),
+		New:   func() any { return &failer{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return failer_local_stub{impl: impl.(Failer), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return failer_server_stub{impl: impl.(Failer), addLoad: addLoad}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return failer_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), imJustHereSoWeaverGenerateDoesntComplainMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer", Method: "ImJustHereSoWeaverGenerateDoesntComplain"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return failer_server_stub{impl: impl.(Failer), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
@@ -41,13 +45,13 @@ func init() {
 		Iface: reflect.TypeOf((*Pointer)(nil)).Elem(),
 		New:   func() any { return &pointer{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return pointer_local_stub{impl: impl.(Pointer), tracer: tracer}
+			return pointer_local_stub{impl: impl.(Pointer), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return pointer_client_stub{stub: stub, getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Pointer", Method: "Get"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return pointer_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Pointer", Method: "Get"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return pointer_server_stub{impl: impl.(Pointer), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return pointer_server_stub{impl: impl.(Pointer), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -55,8 +59,9 @@ func init() {
 // Local stub implementations.
 
 type errer_local_stub struct {
-	impl   Errer
-	tracer trace.Tracer
+	impl        Errer
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s errer_local_stub) Err(ctx context.Context, a0 int) (err error) {
@@ -73,12 +78,19 @@ func (s errer_local_stub) Err(ctx context.Context, a0 int) (err error) {
 		}()
 	}
 
-	return s.impl.Err(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Err(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer", Method: "Err", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.Err(ctx, a0)
+	})
+	return
 }
 
 type failer_local_stub struct {
-	impl   Failer
-	tracer trace.Tracer
+	impl        Failer
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s failer_local_stub) ImJustHereSoWeaverGenerateDoesntComplain(ctx context.Context) (err error) {
@@ -95,12 +107,19 @@ func (s failer_local_stub) ImJustHereSoWeaverGenerateDoesntComplain(ctx context.
 		}()
 	}
 
-	return s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	if s.interceptor == nil {
+		return s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer", Method: "ImJustHereSoWeaverGenerateDoesntComplain"}, func(ctx context.Context) error {
+		return s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	})
+	return
 }
 
 type pointer_local_stub struct {
-	impl   Pointer
-	tracer trace.Tracer
+	impl        Pointer
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s pointer_local_stub) Get(ctx context.Context) (r0 Pair, err error) {
@@ -117,17 +136,36 @@ func (s pointer_local_stub) Get(ctx context.Context) (r0 Pair, err error) {
 		}()
 	}
 
-	return s.impl.Get(ctx)
+	if s.interceptor == nil {
+		return s.impl.Get(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Pointer", Method: "Get"}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type errer_client_stub struct {
-	stub       codegen.Stub
-	errMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	errMetrics  *codegen.MethodMetrics
 }
 
 func (s errer_client_stub) Err(ctx context.Context, a0 int) (err error) {
+	if s.interceptor == nil {
+		return s.callErr(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer", Method: "Err", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callErr(ctx, a0)
+	})
+	return
+}
+
+func (s errer_client_stub) callErr(ctx context.Context, a0 int) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.errMetrics.Count.Add(1)
@@ -155,6 +193,22 @@ func (s errer_client_stub) Err(ctx context.Context, a0 int) (err error) {
 		s.errMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.errMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.errMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += 8
@@ -163,7 +217,6 @@ func (s errer_client_stub) Err(ctx context.Context, a0 int) (err error) {
 
 	// Encode arguments.
 	enc.Int(a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.errMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -182,10 +235,22 @@ func (s errer_client_stub) Err(ctx context.Context, a0 int) (err error) {
 
 type failer_client_stub struct {
 	stub                                            codegen.Stub
+	codec                                           codegen.Codec
+	interceptor                                     codegen.Interceptor
 	imJustHereSoWeaverGenerateDoesntComplainMetrics *codegen.MethodMetrics
 }
 
 func (s failer_client_stub) ImJustHereSoWeaverGenerateDoesntComplain(ctx context.Context) (err error) {
+	if s.interceptor == nil {
+		return s.callImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer", Method: "ImJustHereSoWeaverGenerateDoesntComplain"}, func(ctx context.Context) error {
+		return s.callImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	})
+	return
+}
+
+func (s failer_client_stub) callImJustHereSoWeaverGenerateDoesntComplain(ctx context.Context) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.imJustHereSoWeaverGenerateDoesntComplainMetrics.Count.Add(1)
@@ -215,6 +280,20 @@ func (s failer_client_stub) ImJustHereSoWeaverGenerateDoesntComplain(ctx context
 
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.imJustHereSoWeaverGenerateDoesntComplainMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.imJustHereSoWeaverGenerateDoesntComplainMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Call the remote method.
 	s.imJustHereSoWeaverGenerateDoesntComplainMetrics.BytesRequest.Put(0)
 	var results []byte
@@ -231,11 +310,24 @@ func (s failer_client_stub) ImJustHereSoWeaverGenerateDoesntComplain(ctx context
 }
 
 type pointer_client_stub struct {
-	stub       codegen.Stub
-	getMetrics *codegen.MethodMetrics
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	getMetrics  *codegen.MethodMetrics
 }
 
 func (s pointer_client_stub) Get(ctx context.Context) (r0 Pair, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Pointer", Method: "Get"}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx)
+		return
+	})
+	return
+}
+
+func (s pointer_client_stub) callGet(ctx context.Context) (r0 Pair, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getMetrics.Count.Add(1)
@@ -265,6 +357,20 @@ func (s pointer_client_stub) Get(ctx context.Context) (r0 Pair, err error) {
 
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Call the remote method.
 	s.getMetrics.BytesRequest.Put(0)
 	var results []byte
@@ -284,8 +390,10 @@ func (s pointer_client_stub) Get(ctx context.Context) (r0 Pair, err error) {
 // Server stub implementations.
 
 type errer_server_stub struct {
-	impl    Errer
-	addLoad func(key uint64, load float64)
+	impl        Errer
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -298,6 +406,11 @@ func (s errer_server_stub) GetStubFn(method string) func(ctx context.Context, ar
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s errer_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s errer_server_stub) err(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -307,24 +420,40 @@ func (s errer_server_stub) err(ctx context.Context, args []byte) (res []byte, er
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 int
-	a0 = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Err(ctx, a0)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Err(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Errer", Method: "Err", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.Err(ctx, a0)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type failer_server_stub struct {
-	impl    Failer
-	addLoad func(key uint64, load float64)
+	impl        Failer
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -337,6 +466,11 @@ func (s failer_server_stub) GetStubFn(method string) func(ctx context.Context, a
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s failer_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s failer_server_stub) imJustHereSoWeaverGenerateDoesntComplain(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -348,17 +482,29 @@ func (s failer_server_stub) imJustHereSoWeaverGenerateDoesntComplain(ctx context
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Failer", Method: "ImJustHereSoWeaverGenerateDoesntComplain"}, func(ctx context.Context) error {
+			return s.impl.ImJustHereSoWeaverGenerateDoesntComplain(ctx)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type pointer_server_stub struct {
-	impl    Pointer
-	addLoad func(key uint64, load float64)
+	impl        Pointer
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -371,6 +517,11 @@ func (s pointer_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s pointer_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s pointer_server_stub) get(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -382,9 +533,21 @@ func (s pointer_server_stub) get(ctx context.Context, args []byte) (res []byte,
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Get(ctx)
+	var r0 Pair
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/diverge/Pointer", Method: "Get"}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	(r0).WeaverMarshal(enc)
 	enc.Error(appErr)
@@ -399,16 +562,24 @@ func (x *Pair) WeaverMarshal(enc *codegen.Encoder) {
 	if x == nil {
 		panic(fmt.Errorf("Pair.WeaverMarshal: nil receiver"))
 	}
+	start := enc.BeginStruct(2)
 	serviceweaver_enc_ptr_int_98a2a745(enc, x.X)
 	serviceweaver_enc_ptr_int_98a2a745(enc, x.Y)
+	enc.EndStruct(start)
 }
 
 func (x *Pair) WeaverUnmarshal(dec *codegen.Decoder) {
 	if x == nil {
 		panic(fmt.Errorf("Pair.WeaverUnmarshal: nil receiver"))
 	}
-	x.X = serviceweaver_dec_ptr_int_98a2a745(dec)
-	x.Y = serviceweaver_dec_ptr_int_98a2a745(dec)
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.X = serviceweaver_dec_ptr_int_98a2a745(dec)
+	}
+	if n > 1 {
+		x.Y = serviceweaver_dec_ptr_int_98a2a745(dec)
+	}
+	dec.EndStruct(end)
 }
 
 func serviceweaver_enc_ptr_int_98a2a745(enc *codegen.Encoder, arg *int) {
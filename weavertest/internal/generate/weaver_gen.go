@@ -16,13 +16,13 @@ func init() {
 		Iface: reflect.TypeOf((*testApp)(nil)).Elem(),
 		New:   func() any { return &impl{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return testApp_local_stub{impl: impl.(testApp), tracer: tracer}
+			return testApp_local_stub{impl: impl.(testApp), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return testApp_client_stub{stub: stub, getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "Get"}), incPointerMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "IncPointer"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return testApp_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "Get"}), incPointerMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "IncPointer"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return testApp_server_stub{impl: impl.(testApp), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return testApp_server_stub{impl: impl.(testApp), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -30,8 +30,9 @@ func init() {
 // Local stub implementations.
 
 type testApp_local_stub struct {
-	impl   testApp
-	tracer trace.Tracer
+	impl        testApp
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s testApp_local_stub) Get(ctx context.Context, a0 string, a1 behaviorType) (r0 int, err error) {
@@ -48,7 +49,14 @@ func (s testApp_local_stub) Get(ctx context.Context, a0 string, a1 behaviorType)
 		}()
 	}
 
-	return s.impl.Get(ctx, a0, a1)
+	if s.interceptor == nil {
+		return s.impl.Get(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "Get", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx, a0, a1)
+		return
+	})
+	return
 }
 
 func (s testApp_local_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int, err error) {
@@ -65,18 +73,38 @@ func (s testApp_local_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int, e
 		}()
 	}
 
-	return s.impl.IncPointer(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.IncPointer(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "IncPointer", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.IncPointer(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type testApp_client_stub struct {
 	stub              codegen.Stub
+	codec             codegen.Codec
+	interceptor       codegen.Interceptor
 	getMetrics        *codegen.MethodMetrics
 	incPointerMetrics *codegen.MethodMetrics
 }
 
 func (s testApp_client_stub) Get(ctx context.Context, a0 string, a1 behaviorType) (r0 int, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "Get", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s testApp_client_stub) callGet(ctx context.Context, a0 string, a1 behaviorType) (r0 int, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.getMetrics.Count.Add(1)
@@ -104,6 +132,22 @@ func (s testApp_client_stub) Get(ctx context.Context, a0 string, a1 behaviorType
 		s.getMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -114,7 +158,6 @@ func (s testApp_client_stub) Get(ctx context.Context, a0 string, a1 behaviorType
 	// Encode arguments.
 	enc.String(a0)
 	enc.Int((int)(a1))
-	var shardKey uint64
 
 	// Call the remote method.
 	s.getMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -133,6 +176,17 @@ func (s testApp_client_stub) Get(ctx context.Context, a0 string, a1 behaviorType
 }
 
 func (s testApp_client_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int, err error) {
+	if s.interceptor == nil {
+		return s.callIncPointer(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "IncPointer", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callIncPointer(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s testApp_client_stub) callIncPointer(ctx context.Context, a0 *int) (r0 *int, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.incPointerMetrics.Count.Add(1)
@@ -160,6 +214,22 @@ func (s testApp_client_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int,
 		s.incPointerMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.incPointerMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.incPointerMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += serviceweaver_size_ptr_int_98a2a745(a0)
@@ -168,7 +238,6 @@ func (s testApp_client_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int,
 
 	// Encode arguments.
 	serviceweaver_enc_ptr_int_98a2a745(enc, a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.incPointerMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -189,8 +258,10 @@ func (s testApp_client_stub) IncPointer(ctx context.Context, a0 *int) (r0 *int,
 // Server stub implementations.
 
 type testApp_server_stub struct {
-	impl    testApp
-	addLoad func(key uint64, load float64)
+	impl        testApp
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -205,6 +276,11 @@ func (s testApp_server_stub) GetStubFn(method string) func(ctx context.Context,
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s testApp_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s testApp_server_stub) get(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -214,18 +290,34 @@ func (s testApp_server_stub) get(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 behaviorType
-	*(*int)(&a1) = dec.Int()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		*(*int)(&a1) = dec.Int()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Get(ctx, a0, a1)
+	var r0 int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "Get", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx, a0, a1)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Int(r0)
 	enc.Error(appErr)
@@ -241,16 +333,32 @@ func (s testApp_server_stub) incPointer(ctx context.Context, args []byte) (res [
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 *int
-	a0 = serviceweaver_dec_ptr_int_98a2a745(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = serviceweaver_dec_ptr_int_98a2a745(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.IncPointer(ctx, a0)
+	var r0 *int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.IncPointer(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/generate/testApp", Method: "IncPointer", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.IncPointer(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_ptr_int_98a2a745(enc, r0)
 	enc.Error(appErr)
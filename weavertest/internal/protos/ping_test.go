@@ -15,11 +15,14 @@
 package protos
 
 import (
+	"bytes"
 	"context"
 	"testing"
 
 	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"github.com/ServiceWeaver/weaver/weavertest"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestPingPong(t *testing.T) {
@@ -37,3 +40,33 @@ func TestPingPong(t *testing.T) {
 		t.Fatalf("bad pong: got %v, want %v", pong.Id, 42)
 	}
 }
+
+func TestProtoArgsEncodedWithProtoMarshal(t *testing.T) {
+	// A protocol buffer argument is encoded with proto.Marshal, after a bit
+	// that records whether the pointer is nil, rather than field by field
+	// like other structs.
+	ping := &Ping{Id: 42}
+	want, err := proto.Marshal(ping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := codegen.NewEncoder()
+	serviceweaver_enc_ptr_Ping_53efca65(enc, ping)
+	dec := codegen.NewDecoder(enc.Data())
+	if !dec.Bool() {
+		t.Fatal("non-nil *Ping encoded as nil")
+	}
+	if got := dec.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("encoded *Ping: got %x, want proto.Marshal's %x", got, want)
+	}
+
+	// The encoding round trips, including nil.
+	for _, ping := range []*Ping{{Id: 42}, nil} {
+		enc := codegen.NewEncoder()
+		serviceweaver_enc_ptr_Ping_53efca65(enc, ping)
+		got := serviceweaver_dec_ptr_Ping_53efca65(codegen.NewDecoder(enc.Data()))
+		if !proto.Equal(got, ping) {
+			t.Fatalf("round trip of %v: got %v", ping, got)
+		}
+	}
+}
@@ -16,13 +16,13 @@ func init() {
 		Iface: reflect.TypeOf((*PingPonger)(nil)).Elem(),
 		New:   func() any { return &impl{} },
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return pingPonger_local_stub{impl: impl.(PingPonger), tracer: tracer}
+			return pingPonger_local_stub{impl: impl.(PingPonger), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return pingPonger_client_stub{stub: stub, pingMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/protos/PingPonger", Method: "Ping"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return pingPonger_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), pingMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/protos/PingPonger", Method: "Ping"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return pingPonger_server_stub{impl: impl.(PingPonger), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return pingPonger_server_stub{impl: impl.(PingPonger), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
@@ -30,8 +30,9 @@ func init() {
 // Local stub implementations.
 
 type pingPonger_local_stub struct {
-	impl   PingPonger
-	tracer trace.Tracer
+	impl        PingPonger
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s pingPonger_local_stub) Ping(ctx context.Context, a0 *Ping) (r0 *Pong, err error) {
@@ -48,17 +49,37 @@ func (s pingPonger_local_stub) Ping(ctx context.Context, a0 *Ping) (r0 *Pong, er
 		}()
 	}
 
-	return s.impl.Ping(ctx, a0)
+	if s.interceptor == nil {
+		return s.impl.Ping(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/protos/PingPonger", Method: "Ping", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Ping(ctx, a0)
+		return
+	})
+	return
 }
 
 // Client stub implementations.
 
 type pingPonger_client_stub struct {
 	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 	pingMetrics *codegen.MethodMetrics
 }
 
 func (s pingPonger_client_stub) Ping(ctx context.Context, a0 *Ping) (r0 *Pong, err error) {
+	if s.interceptor == nil {
+		return s.callPing(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/protos/PingPonger", Method: "Ping", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callPing(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s pingPonger_client_stub) callPing(ctx context.Context, a0 *Ping) (r0 *Pong, err error) {
 	// Update metrics.
 	start := time.Now()
 	s.pingMetrics.Count.Add(1)
@@ -86,10 +107,25 @@ func (s pingPonger_client_stub) Ping(ctx context.Context, a0 *Ping) (r0 *Pong, e
 		s.pingMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.pingMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.pingMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Encode arguments.
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_ptr_Ping_53efca65(enc, a0)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.pingMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -110,8 +146,10 @@ func (s pingPonger_client_stub) Ping(ctx context.Context, a0 *Ping) (r0 *Pong, e
 // Server stub implementations.
 
 type pingPonger_server_stub struct {
-	impl    PingPonger
-	addLoad func(key uint64, load float64)
+	impl        PingPonger
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -124,6 +162,11 @@ func (s pingPonger_server_stub) GetStubFn(method string) func(ctx context.Contex
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s pingPonger_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s pingPonger_server_stub) ping(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -133,16 +176,32 @@ func (s pingPonger_server_stub) ping(ctx context.Context, args []byte) (res []by
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 *Ping
-	a0 = serviceweaver_dec_ptr_Ping_53efca65(dec)
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = serviceweaver_dec_ptr_Ping_53efca65(dec)
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Ping(ctx, a0)
+	var r0 *Pong
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Ping(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/protos/PingPonger", Method: "Ping", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Ping(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_ptr_Pong_10ae1a4e(enc, r0)
 	enc.Error(appErr)
@@ -20,6 +20,9 @@ package simple
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -35,18 +38,16 @@ type Source interface {
 
 type source struct {
 	weaver.Implements[Source]
-	dst Destination
+	dst weaver.Ref[Destination]
 }
 
 func (s *source) Init(_ context.Context) error {
 	s.Logger().Debug("simple.Init")
-	dst, err := weaver.Get[Destination](s)
-	s.dst = dst
-	return err
+	return nil
 }
 
 func (s *source) Emit(ctx context.Context, file, msg string) error {
-	return s.dst.Record(ctx, file, msg)
+	return s.dst.Get().Record(ctx, file, msg)
 }
 
 type Destination interface {
@@ -54,8 +55,25 @@ type Destination interface {
 	Record(_ context.Context, file, msg string) error
 	GetAll(_ context.Context, file string) ([]string, error)
 	RoutedRecord(_ context.Context, file, msg string) error
+	Publish(_ context.Context, topic, msg string) error
+	PublishNote(_ context.Context, note Note) error
+	RecordNote(_ context.Context, note Note) error
+	Count(_ context.Context, n int) (weaver.Stream[int], error)
+	Echo(_ context.Context, prefix string, msgs weaver.Stream[string]) (weaver.Stream[string], error)
+	Whoami(_ context.Context) (string, error)
+	GetMetadata(_ context.Context, key string) (string, error)
+}
+
+// A Note is a message, published on the Notes topic, to be recorded in a file.
+type Note struct {
+	weaver.AutoMarshal
+	File string
+	Msg  string
 }
 
+// Notes is a topic of notes.
+var Notes = weaver.NewTopic[Note]("notes")
+
 type destRouter struct{}
 
 func (r destRouter) RoutedRecord(_ context.Context, file, msg string) string {
@@ -90,6 +108,35 @@ func (d *destination) RoutedRecord(ctx context.Context, file, msg string) error
 	return d.Record(ctx, file, "routed: "+msg)
 }
 
+// Publish publishes a message on a topic.
+func (d *destination) Publish(ctx context.Context, topic, msg string) error {
+	return weaver.Publish(ctx, d, topic, []byte(msg))
+}
+
+// PublishNote publishes a note on the Notes topic.
+func (d *destination) PublishNote(ctx context.Context, note Note) error {
+	return Notes.Publish(ctx, d, note)
+}
+
+// RecordNote records a note in its file.
+func (d *destination) RecordNote(ctx context.Context, note Note) error {
+	return d.Record(ctx, note.File, note.Msg)
+}
+
+// Whoami returns the name of the principal that made the call, if any.
+func (d *destination) Whoami(ctx context.Context) (string, error) {
+	p, ok := weaver.PrincipalFromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("no principal")
+	}
+	return p.Name, nil
+}
+
+// GetMetadata returns the value of the provided call metadata key.
+func (d *destination) GetMetadata(ctx context.Context, key string) (string, error) {
+	return weaver.MetadataFromContext(ctx)[key], nil
+}
+
 // GetAll returns all added messages.
 func (d *destination) GetAll(_ context.Context, file string) ([]string, error) {
 	d.mu.Lock()
@@ -102,3 +149,67 @@ func (d *destination) GetAll(_ context.Context, file string) ([]string, error) {
 	str := strings.TrimSpace(string(data))
 	return strings.Split(str, "\n"), nil
 }
+
+// Count streams the integers from 0 to n-1.
+func (d *destination) Count(ctx context.Context, n int) (weaver.Stream[int], error) {
+	if n < 0 {
+		return nil, fmt.Errorf("negative count %d", n)
+	}
+	return weaver.NewStream(ctx, func(_ context.Context, send func(int) error) error {
+		for i := 0; i < n; i++ {
+			if err := send(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// Echo streams every message in msgs, prefixed by prefix.
+func (d *destination) Echo(ctx context.Context, prefix string, msgs weaver.Stream[string]) (weaver.Stream[string], error) {
+	return weaver.NewStream(ctx, func(_ context.Context, send func(string) error) error {
+		defer msgs.Close()
+		for {
+			msg, err := msgs.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := send(prefix + msg); err != nil {
+				return err
+			}
+		}
+	}), nil
+}
+
+// Cache is a generic component. Every instantiation of cache declared below
+// is a separate component.
+type Cache[K comparable, V any] interface {
+	Get(ctx context.Context, key K) (V, error)
+	Put(ctx context.Context, key K, val V) error
+}
+
+type cache[K comparable, V any] struct {
+	weaver.Implements[Cache[K, V]]
+	mu sync.Mutex
+	m  map[K]V
+}
+
+type stringIntCache = cache[string, int]
+
+func (c *cache[K, V]) Get(_ context.Context, key K) (V, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key], nil
+}
+
+func (c *cache[K, V]) Put(_ context.Context, key K, val V) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = map[K]V{}
+	}
+	c.m[key] = val
+	return nil
+}
@@ -16,20 +16,26 @@ package simple_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ServiceWeaver/weaver"
+	"github.com/ServiceWeaver/weaver/internal/net/call"
 	"github.com/ServiceWeaver/weaver/weavertest"
 	"github.com/ServiceWeaver/weaver/weavertest/internal/simple"
 	"github.com/google/uuid"
+	"golang.org/x/exp/maps"
 )
 
 func TestOneComponent(t *testing.T) {
@@ -70,6 +76,10 @@ func TestTwoComponents(t *testing.T) {
 	for _, c := range []testCase{
 		{"single", true, ""},
 		{"multi", false, ""},
+		{"tcp", false, `
+			[serviceweaver]
+			transport = "tcp"
+		`},
 		{"colocate", false, `
 			[serviceweaver]
 			colocate = [
@@ -78,8 +88,24 @@ func TestTwoComponents(t *testing.T) {
 			    "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination",
 			  ]
 		]`},
+		{"mtls", false, `
+			[serviceweaver]
+			mtls = true
+		`},
+		{"quic", false, `
+			[serviceweaver]
+			transport = "quic"
+		`},
+		{"quic_mtls", false, `
+			[serviceweaver]
+			transport = "quic"
+			mtls = true
+		`},
 	} {
 		t.Run(c.name, func(t *testing.T) {
+			if strings.Contains(c.config, "quic") && !call.QUICEnabled {
+				t.Skip(`QUIC transport not compiled in; test with "-tags quic"`)
+			}
 			file := filepath.Join(t.TempDir(), fmt.Sprintf("simple_%s", uuid.New().String()))
 
 			root := weavertest.Init(ctx, t, weavertest.Options{
@@ -163,22 +189,565 @@ func TestListener(t *testing.T) {
 	}
 }
 
+func TestAuthenticate(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "api_keys")
+	if err := os.WriteFile(keys, []byte("billing b-secret\nreports r-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`
+		[serviceweaver.auth.hello]
+		api_keys_file = %q
+		routes = [
+		  {path = "/public/", public = true},
+		  {path = "/billing/", principals = ["billing"]},
+		]
+	`, keys)
+
+	for _, single := range []bool{true, false} {
+		// Serve a handler that returns the principal seen by a component
+		// called with the request's context, and check that requests are
+		// authenticated and authorized.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single, Config: config})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lis, err := root.Listener("hello", weaver.ListenerOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			authenticate, err := weaver.Authenticate(root, "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := &http.Server{
+				Handler: authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					who, err := dst.Whoami(r.Context())
+					if err != nil {
+						who = "nobody"
+					}
+					fmt.Fprint(w, who)
+				})),
+			}
+			go srv.Serve(lis)
+			defer srv.Shutdown(ctx)
+
+			for _, test := range []struct {
+				path   string
+				key    string
+				status int
+				want   string
+			}{
+				{"/public/", "", http.StatusOK, "nobody"},
+				{"/", "", http.StatusUnauthorized, ""},
+				{"/", "x-secret", http.StatusUnauthorized, ""},
+				{"/", "r-secret", http.StatusOK, "reports"},
+				{"/billing/", "b-secret", http.StatusOK, "billing"},
+				{"/billing/", "r-secret", http.StatusForbidden, ""},
+			} {
+				req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s%s", lis, test.path), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.key != "" {
+					req.Header.Set("X-API-Key", test.key)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != test.status {
+					t.Fatalf("GET %s with key %q: got status %d, want %d", test.path, test.key, resp.StatusCode, test.status)
+				}
+				if test.status == http.StatusOK && string(data) != test.want {
+					t.Fatalf("GET %s with key %q: got %q, want %q", test.path, test.key, string(data), test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Attach metadata to a context, and check that it is propagated to
+		// component method calls.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx = weaver.WithMetadata(ctx, "tenant", "acme")
+			for key, want := range map[string]string{"tenant": "acme", "session": ""} {
+				got, err := dst.GetMetadata(ctx, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("GetMetadata(%q): got %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMustGetExits(t *testing.T) {
+	// Test plan: Call MustGet for a type that isn't a component, in a
+	// subprocess. Check that the subprocess exits with a non-zero exit code,
+	// and prints the error rather than a stack trace.
+	if os.Getenv("MUST_GET_EXITS") != "" {
+		root := weavertest.Init(context.Background(), t, weavertest.Options{SingleProcess: true})
+		weaver.MustGet[io.Reader](root)
+		t.Fatal("MustGet returned")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMustGetExits$")
+	cmd.Env = append(os.Environ(), "MUST_GET_EXITS=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() == 0 {
+		t.Fatalf("MustGet: got %v, want a non-zero exit code\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "weaver.MustGet: cannot get component io.Reader") {
+		t.Errorf("MustGet: output doesn't report the error:\n%s", out)
+	}
+	if strings.Contains(string(out), "goroutine ") {
+		t.Errorf("MustGet: output has a stack trace:\n%s", out)
+	}
+}
+
+func TestAccessPolicy(t *testing.T) {
+	// Allow main to call Source, and to read from Destination, but not to
+	// write to it.
+	const config = `
+		[serviceweaver.access.main.allow]
+		"github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source" = ["*"]
+		"github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination" = ["GetAll"]
+	`
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{Config: config})
+	src, err := weaver.Get[simple.Source](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := weaver.Get[simple.Destination](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "simple")
+	if err := dst.Record(ctx, file, "denied"); !errors.Is(err, weaver.ErrPermissionDenied) {
+		t.Fatalf("Record: got %v, want ErrPermissionDenied", err)
+	}
+
+	// Source has no policy, so it can write to Destination.
+	if err := src.Emit(ctx, file, "allowed"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.GetAll(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"allowed"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("GetAll() = %v; expecting %v", got, want)
+	}
+}
+
+func TestPayloadTooLarge(t *testing.T) {
+	// Limit the arguments of Record, and the results of GetAll, to 1 KiB.
+	const config = `
+		[serviceweaver.methods."github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination.Record"]
+		max_request_bytes = 1024
+
+		[serviceweaver.methods."github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination.GetAll"]
+		max_response_bytes = 1024
+	`
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{Config: config})
+	dst, err := weaver.Get[simple.Destination](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "simple")
+	long := strings.Repeat("x", 2048)
+	if err := dst.Record(ctx, file, long); !errors.Is(err, weaver.ErrPayloadTooLarge) {
+		t.Fatalf("Record(long): got %v, want ErrPayloadTooLarge", err)
+	}
+
+	// Record messages that fit, until GetAll returns more than the limit.
+	short := strings.Repeat("x", 512)
+	for i := 0; i < 3; i++ {
+		if err := dst.Record(ctx, file, short); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dst.GetAll(ctx, file); !errors.Is(err, weaver.ErrPayloadTooLarge) {
+		t.Fatalf("GetAll: got %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+// jsonCodec is a weaver.Codec that encodes values as JSON, and counts the
+// values it encodes in the process.
+type jsonCodec struct {
+	encoded *atomic.Int64
+}
+
+func (c jsonCodec) Marshal(values []any) ([]byte, error) {
+	c.encoded.Add(int64(len(values)))
+	return json.Marshal(values)
+}
+
+func (c jsonCodec) Unmarshal(data []byte, ptrs []any) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != len(ptrs) {
+		return fmt.Errorf("got %d values, want %d", len(raw), len(ptrs))
+	}
+	for i, ptr := range ptrs {
+		if err := json.Unmarshal(raw[i], ptr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var jsonEncoded atomic.Int64
+
+func init() {
+	weaver.RegisterCodec("json", jsonCodec{&jsonEncoded})
+}
+
+func TestCodec(t *testing.T) {
+	// Encode the calls to Destination as JSON.
+	const config = `
+		[serviceweaver.codecs]
+		"github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination" = "json"
+	`
+	ctx := context.Background()
+	root := weavertest.Init(ctx, t, weavertest.Options{Config: config})
+	src, err := weaver.Get[simple.Source](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := weaver.Get[simple.Destination](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := jsonEncoded.Load()
+	file := filepath.Join(t.TempDir(), "simple")
+	if err := src.Emit(ctx, file, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.GetAll(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hello"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("GetAll() = %v; expecting %v", got, want)
+	}
+	// The components run in other processes, so the test process only
+	// encodes the argument of GetAll.
+	if encoded := jsonEncoded.Load() - before; encoded != 1 {
+		t.Fatalf("codec encoded %d values, want 1", encoded)
+	}
+
+	// Application errors are still encoded by Service Weaver.
+	if _, err := dst.Whoami(ctx); err == nil || !strings.Contains(err.Error(), "no principal") {
+		t.Fatalf("Whoami: got %v, want a no principal error", err)
+	}
+}
+
+func TestOnStart(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Register a start hook that calls a component, and check that it
+		// runs before the listener serves a request.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var started atomic.Bool
+			weaver.OnStart(root, func(ctx context.Context) error {
+				if _, err := dst.Getpid(ctx); err != nil {
+					return err
+				}
+				started.Store(true)
+				return nil
+			})
+
+			lis, err := root.Listener("onstart", weaver.ListenerOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, started.Load())
+				}),
+			}
+			go srv.Serve(lis)
+			defer srv.Shutdown(ctx)
+
+			resp, err := http.Get(fmt.Sprintf("http://%s/", lis.String()))
+			if err != nil {
+				t.Fatalf("Calling listener: %v", err)
+			}
+			defer resp.Body.Close()
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Reading listener response: %v", err)
+			}
+			if got, want := string(data), "true"; got != want {
+				t.Fatalf("start hook run before serving: got %s, want %s", got, want)
+			}
+
+			// Hooks registered after start are run immediately.
+			late := false
+			weaver.OnStart(root, func(context.Context) error {
+				late = true
+				return nil
+			})
+			if !late {
+				t.Fatal("late start hook not run")
+			}
+		})
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Subscribe to a topic in main, publish events to it from the dst
+		// component, and check that they are received in order.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sub := weaver.Subscribe(root, "topic", weaver.SubscribeOptions{})
+			defer sub.Close()
+			for _, msg := range []string{"a", "b", "c"} {
+				if err := dst.Publish(ctx, "topic", msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, want := range []string{"a", "b", "c"} {
+				select {
+				case got := <-sub.C:
+					if string(got) != want {
+						t.Fatalf("event: got %q, want %q", got, want)
+					}
+				case <-ctx.Done():
+					t.Fatalf("timed out waiting for event %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Subscribe the dst component to the Notes topic, publish notes from
+		// the dst component, and check that every note is recorded in order.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := simple.Notes.Subscribe(root, "record", dst.RecordNote); err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(t.TempDir(), "notes")
+			want := []string{"a", "b", "c"}
+			for _, msg := range want {
+				if err := dst.PublishNote(ctx, simple.Note{File: file, Msg: msg}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for {
+				got, err := dst.GetAll(ctx, file)
+				if err == nil && len(got) == len(want) {
+					if !reflect.DeepEqual(want, got) {
+						t.Fatalf("notes: got %v, want %v", got, want)
+					}
+					return
+				}
+				select {
+				case <-ctx.Done():
+					t.Fatalf("timed out waiting for notes: got %v, want %v", got, want)
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		single bool
+		config string
+	}{
+		{"memory", true, ""},
+		{"file", false, `
+			[serviceweaver.stores.notes]
+			backend = "file"
+			path = "DB_PATH"
+		`},
+		{"secret", false, `
+			[serviceweaver.stores.notes]
+			backend = "file"
+			path = "${secret:env:SIMPLE_NOTES_DB}"
+		`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			db := filepath.Join(t.TempDir(), "notes.db")
+			t.Setenv("SIMPLE_NOTES_DB", db)
+			config := strings.ReplaceAll(test.config, "DB_PATH", db)
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: test.single, Config: config})
+			notes := weaver.NewStore[int, simple.Note]("notes")
+
+			if _, ok, err := notes.Get(ctx, root, 1); err != nil || ok {
+				t.Fatalf("Get(1): got (%v, %v), want (false, nil)", ok, err)
+			}
+			want := simple.Note{File: "f", Msg: "hello"}
+			if err := notes.Put(ctx, root, 1, want); err != nil {
+				t.Fatal(err)
+			}
+			if test.config != "" {
+				// The store is kept in the configured file.
+				if _, err := os.Stat(db); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, ok, err := notes.Get(ctx, root, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok || got != want {
+				t.Fatalf("Get(1): got (%+v, %v), want (%+v, true)", got, ok, want)
+			}
+			if err := notes.Delete(ctx, root, 1); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, err := notes.Get(ctx, root, 1); err != nil || ok {
+				t.Fatalf("Get(1) after Delete: got (%v, %v), want (false, nil)", ok, err)
+			}
+		})
+	}
+}
+
+func TestNewStorePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewStore[string, string] did not panic")
+		}
+	}()
+	weaver.NewStore[string, string]("strings")
+}
+
+func TestNewTopicPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewTopic[string] did not panic")
+		}
+	}()
+	weaver.NewTopic[string]("strings")
+}
+
+// fakeDestination is a fake Destination that records messages with a "fake: "
+// prefix. Its other methods are unimplemented.
+type fakeDestination struct {
+	simple.Destination
+}
+
+func (fakeDestination) Record(_ context.Context, file, msg string) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString("fake: " + msg + "\n")
+	return err
+}
+
+func TestFake(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Replace dst with a fake, and check that calls from src reach the
+		// fake rather than the real dst.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx := context.Background()
+			root := weavertest.Init(ctx, t, weavertest.Options{
+				SingleProcess: single,
+				Fakes: []weavertest.FakeComponent{
+					weavertest.Fake[simple.Destination](fakeDestination{}),
+				},
+			})
+			src, err := weaver.Get[simple.Source](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(t.TempDir(), "fake")
+			if err := src.Emit(ctx, file, "hello"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "fake: hello\n"; string(got) != want {
+				t.Fatalf("file: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
 func TestRoutedCall(t *testing.T) {
 	// Make a call to a routed method.
 	type testCase struct {
-		name   string
-		single bool
+		name       string
+		single     bool
+		placements []weavertest.Placement
 	}
 	ctx := context.Background()
 	for _, c := range []testCase{
-		{"single", true},
-		{"multi", false},
+		{"single", true, nil},
+		{"multi", false, nil},
+		{"local", false, []weavertest.Placement{weavertest.Local[simple.Destination]()}},
+		{"replicated", false, []weavertest.Placement{weavertest.Remote[simple.Destination](3)}},
 	} {
 		t.Run(c.name, func(t *testing.T) {
 			file := filepath.Join(t.TempDir(), fmt.Sprintf("simple_%s", uuid.New().String()))
 
 			root := weavertest.Init(ctx, t, weavertest.Options{
 				SingleProcess: c.single,
+				Placements:    c.placements,
 			})
 			dst, err := weaver.Get[simple.Destination](root)
 			if err != nil {
@@ -200,3 +769,737 @@ func TestRoutedCall(t *testing.T) {
 		})
 	}
 }
+
+// recvAll receives the values in s until it ends.
+func recvAll[T any](s weaver.Stream[T]) ([]T, error) {
+	defer s.Close()
+	var values []T
+	for {
+		v, err := s.Recv()
+		if err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Stream more values than fit in a stream's window.
+			const n = 1000
+			s, err := dst.Count(ctx, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := recvAll(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := make([]int, n)
+			for i := range want {
+				want[i] = i
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Count: got %v, want %v", got, want)
+			}
+
+			// The error of a remote streaming method may only be returned
+			// by Recv.
+			s, err = dst.Count(ctx, -1)
+			if err == nil {
+				_, err = recvAll(s)
+			}
+			if err == nil || !strings.Contains(err.Error(), "negative count") {
+				t.Fatalf("Count: got %v, want negative count error", err)
+			}
+
+			// Echo a stream of messages.
+			msgs := weaver.NewStream(ctx, func(_ context.Context, send func(string) error) error {
+				for _, msg := range []string{"a", "b", "c"} {
+					if err := send(msg); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			s2, err := dst.Echo(ctx, "> ", msgs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			echoes, err := recvAll(s2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"> a", "> b", "> c"}; !reflect.DeepEqual(echoes, want) {
+				t.Fatalf("Echo: got %v, want %v", echoes, want)
+			}
+		})
+	}
+}
+
+func TestGenericComponent(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx := context.Background()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			c, err := weaver.Get[simple.Cache[string, int]](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// In multiprocess mode, the cache is replicated, and a Put and a
+			// subsequent Get may be routed to different replicas. Put the
+			// value repeatedly, so that it reaches every replica.
+			for i := 0; i < 10; i++ {
+				if err := c.Put(ctx, "a", 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := c.Get(ctx, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 1; got != want {
+				t.Fatalf("Get(a): got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestCron(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Register a job that records a message in the dst component, and
+		// check that it runs.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file := filepath.Join(t.TempDir(), "ticks")
+			job := func(ctx context.Context) error {
+				return dst.Record(ctx, file, "tick")
+			}
+			if err := weaver.Cron(root, "tick", "@every 10ms", weaver.CronOptions{}, job); err != nil {
+				t.Fatal(err)
+			}
+			if err := weaver.Cron(root, "tick", "@every 10ms", weaver.CronOptions{}, job); err == nil {
+				t.Fatal("registering a job twice: unexpected success")
+			}
+			for {
+				got, err := dst.GetAll(ctx, file)
+				if err == nil && len(got) >= 3 {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					t.Fatalf("timed out waiting for ticks: got %v", got)
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		})
+	}
+}
+
+// interceptedCalls counts the calls to Destination.Record with a message of
+// "intercepted" seen by the client interceptor registered in init.
+var interceptedCalls atomic.Int64
+
+func init() {
+	// Interceptors must be registered in every process, before weaver.Init.
+	weaver.InterceptClientCalls(func(ctx context.Context, info weaver.CallInfo, invoke func(context.Context) error) error {
+		if info.Method == "Record" && info.Args[1] == "intercepted" {
+			interceptedCalls.Add(1)
+		}
+		return invoke(ctx)
+	})
+	weaver.InterceptServerCalls(func(ctx context.Context, info weaver.CallInfo, invoke func(context.Context) error) error {
+		if info.Method == "Record" && info.Args[1] == "rejected" {
+			return fmt.Errorf("%s.%s: message rejected", info.Component, info.Method)
+		}
+		return invoke(ctx)
+	})
+}
+
+func TestInterceptors(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx := context.Background()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			interceptedCalls.Store(0)
+			file := filepath.Join(t.TempDir(), "intercepted")
+			if err := dst.Record(ctx, file, "intercepted"); err != nil {
+				t.Fatal(err)
+			}
+			err = dst.Record(ctx, file, "rejected")
+			if err == nil || !strings.Contains(err.Error(), "message rejected") {
+				t.Fatalf("Record(rejected): got %v, want message rejected error", err)
+			}
+			if got, want := interceptedCalls.Load(), int64(1); got != want {
+				t.Fatalf("intercepted calls: got %d, want %d", got, want)
+			}
+
+			got, err := dst.GetAll(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"intercepted"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("GetAll() = %v; expecting %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			errInjected := errors.New("injected")
+			root := weavertest.Init(ctx, t, weavertest.Options{
+				SingleProcess: single,
+				Faults: []weavertest.ComponentFaults{
+					weavertest.InjectFaults[simple.Destination](
+						weavertest.Fault{Method: "Record", Err: errInjected},
+						weavertest.Fault{Method: "GetAll", DropRate: 1},
+						weavertest.Fault{Method: "Count", Delay: 10 * time.Millisecond},
+					),
+				},
+			})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file := filepath.Join(t.TempDir(), "faults")
+			if err := dst.Record(ctx, file, "hello"); err == nil || !strings.Contains(err.Error(), "injected") {
+				t.Fatalf("Record: got %v, want injected error", err)
+			}
+			if _, err := dst.GetAll(ctx, file); !errors.Is(err, weaver.ErrRetriable) {
+				t.Fatalf("GetAll: got %v, want ErrRetriable", err)
+			}
+
+			// Methods without faults, and methods that are only delayed,
+			// still succeed.
+			if _, err := dst.Getpid(ctx); err != nil {
+				t.Fatal(err)
+			}
+			s, err := dst.Count(ctx, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := recvAll(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("Count: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLazyComponent(t *testing.T) {
+	const lazy = `
+		[serviceweaver.startup."github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination"]
+		lazy = true
+	`
+	ctx := context.Background()
+	for _, c := range []struct {
+		name   string
+		single bool
+		config string
+	}{
+		{"single", true, lazy},
+		{"multi", false, lazy},
+		{"colocate", false, lazy + `
+			[serviceweaver]
+			colocate = [
+			  [
+			    "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source",
+			    "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination",
+			  ]
+			]
+		`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			root := weavertest.Init(ctx, t, weavertest.Options{
+				SingleProcess: c.single,
+				Config:        c.config,
+			})
+			src, err := weaver.Get[simple.Source](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Calls to the lazy component, direct or through another
+			// component, start it.
+			file := filepath.Join(t.TempDir(), "lazy")
+			if err := src.Emit(ctx, file, "a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := dst.Record(ctx, file, "b"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := dst.GetAll(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("GetAll: got %v, want %v", got, want)
+			}
+			s, err := dst.Count(ctx, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts, err := recvAll(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []int{0, 1, 2}; !reflect.DeepEqual(counts, want) {
+				t.Fatalf("Count: got %v, want %v", counts, want)
+			}
+		})
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			opts := weaver.LeaderElectionOptions{LeaseDuration: 300 * time.Millisecond}
+			election, err := weaver.NewLeaderElection(root, "leader", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := weaver.NewLeaderElection(root, "leader", opts); err == nil {
+				t.Fatal("creating an election twice: unexpected success")
+			}
+
+			// Win the election, and keep the lease across a few renewals.
+			leaderCtx, err := election.Campaign(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := election.Campaign(ctx); err == nil {
+				t.Fatal("campaigning while the leader: unexpected success")
+			}
+			select {
+			case <-leaderCtx.Done():
+				t.Fatal("leadership lost")
+			case <-time.After(time.Second):
+			}
+			if !election.IsLeader() {
+				t.Fatal("IsLeader: got false, want true")
+			}
+
+			// Resign, and win the election again.
+			if err := election.Resign(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if leaderCtx.Err() == nil || election.IsLeader() {
+				t.Fatal("still the leader after resigning")
+			}
+			if _, err := election.Campaign(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestActors(t *testing.T) {
+	type counter struct{ n int }
+	for _, single := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			opts := weaver.ActorOptions{IdleTimeout: 300 * time.Millisecond, LeaseDuration: 300 * time.Millisecond}
+			counters, err := weaver.NewActors[counter](root, "counters", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := weaver.NewActors[counter](root, "counters", opts); err == nil {
+				t.Fatal("creating actors twice: unexpected success")
+			}
+
+			increment := func(id string) int {
+				var n int
+				if err := counters.Do(ctx, id, func(_ context.Context, c *counter) error {
+					c.n++
+					n = c.n
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+				return n
+			}
+
+			// Every id has its own state, which is kept across a few lease
+			// renewals while the id is in use.
+			for i := 1; i <= 10; i++ {
+				if got := increment("a"); got != i {
+					t.Fatalf("increment(a): got %d, want %d", got, i)
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			if got := increment("b"); got != 1 {
+				t.Fatalf("increment(b): got %d, want 1", got)
+			}
+
+			// Once idle, an id is deactivated, and its state dropped.
+			time.Sleep(time.Second)
+			if got := increment("a"); got != 1 {
+				t.Fatalf("increment(a) once idle: got %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestWorkflow(t *testing.T) {
+	for _, single := range []bool{true, false} {
+		// Run a workflow that records a note in a file and then checks it,
+		// and check that the record of a note that fails the check is undone.
+		t.Run(fmt.Sprintf("Single=%t", single), func(t *testing.T) {
+			ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancelFunc()
+			root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: single})
+			dst, err := weaver.Get[simple.Destination](root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			notes, err := weaver.NewWorkflow(root, "notes", weaver.WorkflowOptions{},
+				weaver.WorkflowStep[simple.Note]{
+					Name: "record",
+					Do: func(ctx context.Context, id string, note *simple.Note) error {
+						return dst.Record(ctx, note.File, "record "+id)
+					},
+					Compensate: func(ctx context.Context, id string, note *simple.Note) error {
+						return dst.Record(ctx, note.File, "undo "+id)
+					},
+				},
+				weaver.WorkflowStep[simple.Note]{
+					Name: "check",
+					Do: func(_ context.Context, _ string, note *simple.Note) error {
+						if note.Msg == "bad" {
+							return errors.New("bad note")
+						}
+						return nil
+					},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			file := filepath.Join(t.TempDir(), "notes")
+			if _, err := notes.Run(ctx, "a", simple.Note{File: file, Msg: "good"}); err != nil {
+				t.Fatal(err)
+			}
+			var werr *weaver.WorkflowError
+			if _, err := notes.Run(ctx, "b", simple.Note{File: file, Msg: "bad"}); !errors.As(err, &werr) || werr.Step != "check" {
+				t.Fatalf("Run(b): got %v, want *WorkflowError for step check", err)
+			}
+			got, err := dst.GetAll(ctx, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"record a", "record b", "undo b"}; !reflect.DeepEqual(want, got) {
+				t.Fatalf("records: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// simulateIncrements runs three tasks that increment a counter in a cache,
+// racing between reading and writing it, in a simulation with the provided
+// seed. It returns the final value of the counter, and the history of the
+// simulation.
+func simulateIncrements(t *testing.T, seed int64) (int, []string) {
+	ctx := context.Background()
+	sim := weavertest.NewSimulator(t, seed)
+	root := weavertest.Init(ctx, t, weavertest.Options{Simulator: sim})
+	c, err := weaver.Get[simple.Cache[string, int]](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		sim.Go(ctx, func(ctx context.Context) {
+			n, err := c.Get(ctx, "counter")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.Put(ctx, "counter", n+1); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	sim.Wait()
+	n, err := c.Get(ctx, "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, sim.History()
+}
+
+func TestSimulation(t *testing.T) {
+	// Every seed replays the same interleaving, and different seeds explore
+	// different interleavings, some of which lose an increment.
+	results := map[int]bool{}
+	for seed := int64(1); seed <= 20; seed++ {
+		n1, history1 := simulateIncrements(t, seed)
+		n2, history2 := simulateIncrements(t, seed)
+		if n1 != n2 || !reflect.DeepEqual(history1, history2) {
+			t.Fatalf("seed %d: got %d after %v, then %d after %v", seed, n1, history1, n2, history2)
+		}
+		results[n1] = true
+	}
+	if !results[3] || len(results) < 2 {
+		t.Fatalf("got counters %v, want 3 and lost increments", maps.Keys(results))
+	}
+}
+
+func TestSimulatedClock(t *testing.T) {
+	ctx := context.Background()
+	sim := weavertest.NewSimulator(t, 1)
+	root := weavertest.Init(ctx, t, weavertest.Options{
+		Simulator: sim,
+		Faults: []weavertest.ComponentFaults{
+			weavertest.InjectFaults[simple.Cache[string, int]](weavertest.Fault{Method: "Put", Delay: time.Hour}),
+		},
+	})
+	c, err := weaver.Get[simple.Cache[string, int]](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The task that sleeps the longest puts its value last, after three
+	// hours of simulated time.
+	start := sim.Now()
+	for i := 1; i <= 2; i++ {
+		i := i
+		sim.Go(ctx, func(ctx context.Context) {
+			if err := sim.Sleep(ctx, time.Duration(i)*time.Hour); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.Put(ctx, "a", i); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	sim.Wait()
+	if got, want := sim.Now().Sub(start), 3*time.Hour; got != want {
+		t.Fatalf("simulated time: got %v, want %v", got, want)
+	}
+	// Components share the simulated clock.
+	if got, want := weaver.ClockOf(root).Now(), sim.Now(); !got.Equal(want) {
+		t.Fatalf("ClockOf(root).Now(): got %v, want %v", got, want)
+	}
+	got, err := c.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2; got != want {
+		t.Fatalf("Get(a): got %d, want %d", got, want)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	// Test plan: Record the calls to Destination made by a multiprocess
+	// application, replay them against a new application, and check that the
+	// new application ends up in the same state.
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "messages")
+	config := fmt.Sprintf(`
+[serviceweaver.recording]
+dir = %q
+components = ["github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination"]
+`, dir)
+	t.Run("Record", func(t *testing.T) {
+		root := weavertest.Init(ctx, t, weavertest.Options{Config: config})
+		src, err := weaver.Get[simple.Source](root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := weaver.Get[simple.Destination](root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range []string{"a", "b"} {
+			if err := src.Emit(ctx, file, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := dst.GetAll(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	calls, err := weaver.ReadRecording(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, call := range calls {
+		methods = append(methods, call.Method)
+	}
+	if got, want := strings.Join(methods, ","), "Record,Record,GetAll"; got != want {
+		t.Fatalf("recorded methods: got %s, want %s", got, want)
+	}
+
+	os.Remove(file)
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true})
+	if diverged := weavertest.Replay(ctx, t, root, dir); len(diverged) != 0 {
+		t.Fatalf("first replay: got %d diverged calls, want 0", len(diverged))
+	}
+	dst, err := weaver.Get[simple.Destination](root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.GetAll(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetAll after replay: got %v, want %v", got, want)
+	}
+
+	// Replaying the calls again appends the messages again, so GetAll
+	// returns more messages than it did when recorded.
+	diverged := weavertest.Replay(ctx, t, root, dir)
+	if len(diverged) != 1 || diverged[0].Method != "GetAll" {
+		t.Fatalf("second replay: got diverged calls %v, want GetAll", diverged)
+	}
+}
+
+func BenchmarkEmit(b *testing.B) {
+	// Every Emit makes two remote calls: one from the benchmark to Source,
+	// and one from Source to Destination.
+	ctx := context.Background()
+	weavertest.Benchmark(b, weavertest.Options{}, func(b *testing.B, root weaver.Instance) {
+		src, err := weaver.Get[simple.Source](root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		file := filepath.Join(b.TempDir(), "emit")
+		for i := 0; i < b.N; i++ {
+			if err := src.Emit(ctx, file, "x"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestFakeClock(t *testing.T) {
+	// Test plan: Run a ticker and a timer on a fake clock passed to
+	// weavertest. Advance the clock, and check that the ticker and timer
+	// fire when they are due, and that a named cache expires its entries
+	// according to the fake clock.
+	ctx := context.Background()
+	clock := weavertest.NewFakeClock(time.Time{})
+	root := weavertest.Init(ctx, t, weavertest.Options{SingleProcess: true, Clock: clock})
+	c := weaver.ClockOf(root)
+	start := c.Now()
+	if !start.Equal(clock.Now()) {
+		t.Fatalf("ClockOf(root).Now(): got %v, want %v", start, clock.Now())
+	}
+
+	ticker := c.NewTicker(time.Minute)
+	defer ticker.Stop()
+	timer := c.NewTimer(90 * time.Second)
+	cache := weaver.NewCache[string, int]("TestFakeClock", weaver.CacheOptions{TTL: 2 * time.Minute})
+	cache.Put(root, "answer", 42)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(31 * time.Second)
+	if got, want := <-ticker.C(), start.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("tick: got %v, want %v", got, want)
+	}
+	if got, want := <-timer.C(), start.Add(90*time.Second); !got.Equal(want) {
+		t.Errorf("timer: got %v, want %v", got, want)
+	}
+	if timer.Stop() {
+		t.Error("timer.Stop(): fired timer reported active")
+	}
+	if got, ok := cache.Get(root, "answer"); !ok || got != 42 {
+		t.Errorf("cache.Get before TTL: got %d, %t, want 42, true", got, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := cache.Get(root, "answer"); ok {
+		t.Error("cache.Get after TTL: unexpected hit")
+	}
+	if got, want := c.Since(start), 150*time.Second; got != want {
+		t.Errorf("Since(start): got %v, want %v", got, want)
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	// Test plan: Sleep in a goroutine. Wait for the sleep to be pending,
+	// advance the clock past it, and check that the goroutine wakes up.
+	// Check that cancelling the context interrupts a sleep.
+	clock := weavertest.NewFakeClock(time.Time{})
+	woke := make(chan error)
+	go func() {
+		woke <- clock.Sleep(context.Background(), time.Hour)
+	}()
+	clock.WaitForTimers(1)
+	clock.Advance(time.Hour - time.Nanosecond)
+	select {
+	case <-woke:
+		t.Fatal("Sleep returned early")
+	default:
+	}
+	clock.Advance(time.Nanosecond)
+	if err := <-woke; err != nil {
+		t.Fatalf("Sleep: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		woke <- clock.Sleep(ctx, time.Hour)
+	}()
+	clock.WaitForTimers(1)
+	cancel()
+	if err := <-woke; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sleep: got %v, want %v", err, context.Canceled)
+	}
+	if n := clock.Timers(); n != 0 {
+		t.Errorf("Timers: got %d, want 0", n)
+	}
+}
@@ -3,6 +3,8 @@ package simple
 // Code generated by "weaver generate". DO NOT EDIT.
 import (
 	"context"
+	"fmt"
+	"github.com/ServiceWeaver/weaver"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -11,40 +13,110 @@ import (
 )
 
 func init() {
+	codegen.Register(codegen.Registration{
+		Name:  "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]",
+		Iface: reflect.TypeOf((*Cache[string, int])(nil)).Elem(),
+		New:   func() any { return &stringIntCache{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return cache_string_int_94168a7d_local_stub{impl: impl.(Cache[string, int]), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return cache_string_int_94168a7d_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Get"}), putMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Put"})}
+		},
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return cache_string_int_94168a7d_server_stub{impl: impl.(Cache[string, int]), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
+		},
+	})
 	codegen.Register(codegen.Registration{
 		Name:   "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination",
 		Iface:  reflect.TypeOf((*Destination)(nil)).Elem(),
 		New:    func() any { return &destination{} },
 		Routed: true,
 		LocalStubFn: func(impl any, tracer trace.Tracer) any {
-			return destination_local_stub{impl: impl.(Destination), tracer: tracer}
+			return destination_local_stub{impl: impl.(Destination), tracer: tracer, interceptor: codegen.LocalInterceptor()}
 		},
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return destination_client_stub{stub: stub, getpidMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Getpid"}), recordMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Record"}), getAllMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetAll"}), routedRecordMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RoutedRecord"})}
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return destination_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), getpidMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Getpid"}), recordMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Record"}), getAllMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetAll"}), routedRecordMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RoutedRecord"}), publishMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Publish"}), publishNoteMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "PublishNote"}), recordNoteMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RecordNote"}), countMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Count"}), echoMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Echo"}), whoamiMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Whoami"}), getMetadataMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetMetadata"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return destination_server_stub{impl: impl.(Destination), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return destination_server_stub{impl: impl.(Destination), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 	codegen.Register(codegen.Registration{
-		Name:        "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source",
-		Iface:       reflect.TypeOf((*Source)(nil)).Elem(),
-		New:         func() any { return &source{} },
-		LocalStubFn: func(impl any, tracer trace.Tracer) any { return source_local_stub{impl: impl.(Source), tracer: tracer} },
-		ClientStubFn: func(stub codegen.Stub, caller string) any {
-			return source_client_stub{stub: stub, emitMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source", Method: "Emit"})}
+		Name:  "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source",
+		Iface: reflect.TypeOf((*Source)(nil)).Elem(),
+		New:   func() any { return &source{} },
+		LocalStubFn: func(impl any, tracer trace.Tracer) any {
+			return source_local_stub{impl: impl.(Source), tracer: tracer, interceptor: codegen.LocalInterceptor()}
+		},
+		ClientStubFn: func(stub codegen.Stub, caller string, codec codegen.Codec) any {
+			return source_client_stub{stub: stub, codec: codec, interceptor: codegen.ClientInterceptor(), emitMetrics: codegen.MethodMetricsFor(codegen.MethodLabels{Caller: caller, Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source", Method: "Emit"})}
 		},
-		ServerStubFn: func(impl any, addLoad func(uint64, float64)) codegen.Server {
-			return source_server_stub{impl: impl.(Source), addLoad: addLoad}
+		ServerStubFn: func(impl any, addLoad func(uint64, float64), codec codegen.Codec) codegen.Server {
+			return source_server_stub{impl: impl.(Source), addLoad: addLoad, codec: codec, interceptor: codegen.ServerInterceptor()}
 		},
 	})
 }
 
 // Local stub implementations.
 
+type cache_string_int_94168a7d_local_stub struct {
+	impl        Cache[string, int]
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s cache_string_int_94168a7d_local_stub) Get(ctx context.Context, a0 string) (r0 int, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Cache[string, int].Get", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Get(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Get(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s cache_string_int_94168a7d_local_stub) Put(ctx context.Context, a0 string, a1 int) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Cache[string, int].Put", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Put(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Put(ctx, a0, a1)
+	})
+	return
+}
+
 type destination_local_stub struct {
-	impl   Destination
-	tracer trace.Tracer
+	impl        Destination
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
 }
 
 func (s destination_local_stub) Getpid(ctx context.Context) (r0 int, err error) {
@@ -61,101 +133,957 @@ func (s destination_local_stub) Getpid(ctx context.Context) (r0 int, err error)
 		}()
 	}
 
-	return s.impl.Getpid(ctx)
+	if s.interceptor == nil {
+		return s.impl.Getpid(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Getpid"}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Getpid(ctx)
+		return
+	})
+	return
+}
+
+func (s destination_local_stub) Record(ctx context.Context, a0 string, a1 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.Record", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Record(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Record", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Record(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_local_stub) GetAll(ctx context.Context, a0 string) (r0 []string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.GetAll", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.GetAll(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetAll", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetAll(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s destination_local_stub) RoutedRecord(ctx context.Context, a0 string, a1 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.RoutedRecord", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.RoutedRecord(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RoutedRecord", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.RoutedRecord(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_local_stub) Publish(ctx context.Context, a0 string, a1 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.Publish", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Publish(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Publish", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Publish(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_local_stub) PublishNote(ctx context.Context, a0 Note) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.PublishNote", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.PublishNote(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "PublishNote", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.PublishNote(ctx, a0)
+	})
+	return
+}
+
+func (s destination_local_stub) RecordNote(ctx context.Context, a0 Note) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.RecordNote", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.RecordNote(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RecordNote", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.impl.RecordNote(ctx, a0)
+	})
+	return
+}
+
+func (s destination_local_stub) Count(ctx context.Context, a0 int) (r0 weaver.Stream[int], err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.Count", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Count(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Count", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Count(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s destination_local_stub) Echo(ctx context.Context, a0 string, a1 weaver.Stream[string]) (r0 weaver.Stream[string], err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.Echo", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Echo(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Echo", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Echo(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s destination_local_stub) Whoami(ctx context.Context) (r0 string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.Whoami", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Whoami(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Whoami"}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.Whoami(ctx)
+		return
+	})
+	return
+}
+
+func (s destination_local_stub) GetMetadata(ctx context.Context, a0 string) (r0 string, err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Destination.GetMetadata", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.GetMetadata(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetMetadata", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.impl.GetMetadata(ctx, a0)
+		return
+	})
+	return
+}
+
+type source_local_stub struct {
+	impl        Source
+	tracer      trace.Tracer
+	interceptor codegen.Interceptor
+}
+
+func (s source_local_stub) Emit(ctx context.Context, a0 string, a1 string) (err error) {
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.tracer.Start(ctx, "simple.Source.Emit", trace.WithSpanKind(trace.SpanKindInternal))
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}()
+	}
+
+	if s.interceptor == nil {
+		return s.impl.Emit(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source", Method: "Emit", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.impl.Emit(ctx, a0, a1)
+	})
+	return
+}
+
+// Client stub implementations.
+
+type cache_string_int_94168a7d_client_stub struct {
+	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+	getMetrics  *codegen.MethodMetrics
+	putMetrics  *codegen.MethodMetrics
+}
+
+func (s cache_string_int_94168a7d_client_stub) Get(ctx context.Context, a0 string) (r0 int, err error) {
+	if s.interceptor == nil {
+		return s.callGet(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGet(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s cache_string_int_94168a7d_client_stub) callGet(ctx context.Context, a0 string) (r0 int, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.getMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Cache[string, int].Get", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.getMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.getMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.getMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.getMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = dec.Int()
+	err = dec.Error()
+	return
+}
+
+func (s cache_string_int_94168a7d_client_stub) Put(ctx context.Context, a0 string, a1 int) (err error) {
+	if s.interceptor == nil {
+		return s.callPut(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callPut(ctx, a0, a1)
+	})
+	return
+}
+
+func (s cache_string_int_94168a7d_client_stub) callPut(ctx context.Context, a0 string, a1 int) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.putMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Cache[string, int].Put", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.putMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.putMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.putMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 1, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.putMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += 8
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.Int(a1)
+
+	// Call the remote method.
+	s.putMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 1, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.putMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+type destination_client_stub struct {
+	stub                codegen.Stub
+	codec               codegen.Codec
+	interceptor         codegen.Interceptor
+	getpidMetrics       *codegen.MethodMetrics
+	recordMetrics       *codegen.MethodMetrics
+	getAllMetrics       *codegen.MethodMetrics
+	routedRecordMetrics *codegen.MethodMetrics
+	publishMetrics      *codegen.MethodMetrics
+	publishNoteMetrics  *codegen.MethodMetrics
+	recordNoteMetrics   *codegen.MethodMetrics
+	countMetrics        *codegen.MethodMetrics
+	echoMetrics         *codegen.MethodMetrics
+	whoamiMetrics       *codegen.MethodMetrics
+	getMetadataMetrics  *codegen.MethodMetrics
+}
+
+func (s destination_client_stub) Getpid(ctx context.Context) (r0 int, err error) {
+	if s.interceptor == nil {
+		return s.callGetpid(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Getpid"}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetpid(ctx)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callGetpid(ctx context.Context) (r0 int, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.getpidMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Getpid", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.getpidMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.getpidMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.getpidMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 4, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getpidMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Call the remote method.
+	s.getpidMetrics.BytesRequest.Put(0)
+	var results []byte
+	results, err = s.stub.Run(ctx, 4, nil, shardKey)
+	if err != nil {
+		return
+	}
+	s.getpidMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = dec.Int()
+	err = dec.Error()
+	return
+}
+
+func (s destination_client_stub) Record(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callRecord(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Record", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callRecord(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_client_stub) callRecord(ctx context.Context, a0 string, a1 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.recordMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Record", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.recordMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.recordMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.recordMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 7, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.recordMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += (4 + len(a1))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.String(a1)
+
+	// Call the remote method.
+	s.recordMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 7, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.recordMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+func (s destination_client_stub) GetAll(ctx context.Context, a0 string) (r0 []string, err error) {
+	if s.interceptor == nil {
+		return s.callGetAll(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetAll", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetAll(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callGetAll(ctx context.Context, a0 string) (r0 []string, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.getAllMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.GetAll", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.getAllMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.getAllMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getAllMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 2, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getAllMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+
+	// Call the remote method.
+	s.getAllMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 2, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.getAllMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	r0 = serviceweaver_dec_slice_string_4af10117(dec)
+	err = dec.Error()
+	return
+}
+
+func (s destination_client_stub) RoutedRecord(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callRoutedRecord(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RoutedRecord", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callRoutedRecord(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_client_stub) callRoutedRecord(ctx context.Context, a0 string, a1 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.routedRecordMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.RoutedRecord", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.routedRecordMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.routedRecordMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	// Set the shardKey.
+	var r destRouter
+	shardKey := _hashDestination(r.RoutedRecord(ctx, a0, a1))
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.routedRecordMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 9, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.routedRecordMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += (4 + len(a1))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.String(a1)
+
+	// Call the remote method.
+	s.routedRecordMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 9, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.routedRecordMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
+}
+
+func (s destination_client_stub) Publish(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callPublish(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Publish", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callPublish(ctx, a0, a1)
+	})
+	return
+}
+
+func (s destination_client_stub) callPublish(ctx context.Context, a0 string, a1 string) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.publishMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Publish", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.publishMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.publishMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.publishMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 5, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.publishMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += (4 + len(a0))
+	size += (4 + len(a1))
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	enc.String(a0)
+	enc.String(a1)
+
+	// Call the remote method.
+	s.publishMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 5, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+	s.publishMetrics.BytesReply.Put(float64(len(results)))
+
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
 }
 
-func (s destination_local_stub) Record(ctx context.Context, a0 string, a1 string) (err error) {
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		// Create a child span for this method.
-		ctx, span = s.tracer.Start(ctx, "simple.Destination.Record", trace.WithSpanKind(trace.SpanKindInternal))
-		defer func() {
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-			}
-			span.End()
-		}()
+func (s destination_client_stub) PublishNote(ctx context.Context, a0 Note) (err error) {
+	if s.interceptor == nil {
+		return s.callPublishNote(ctx, a0)
 	}
-
-	return s.impl.Record(ctx, a0, a1)
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "PublishNote", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callPublishNote(ctx, a0)
+	})
+	return
 }
 
-func (s destination_local_stub) GetAll(ctx context.Context, a0 string) (r0 []string, err error) {
+func (s destination_client_stub) callPublishNote(ctx context.Context, a0 Note) (err error) {
+	// Update metrics.
+	start := time.Now()
+	s.publishNoteMetrics.Count.Add(1)
+
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
 		// Create a child span for this method.
-		ctx, span = s.tracer.Start(ctx, "simple.Destination.GetAll", trace.WithSpanKind(trace.SpanKindInternal))
-		defer func() {
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-			}
-			span.End()
-		}()
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.PublishNote", trace.WithSpanKind(trace.SpanKindClient))
 	}
 
-	return s.impl.GetAll(ctx, a0)
-}
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
 
-func (s destination_local_stub) RoutedRecord(ctx context.Context, a0 string, a1 string) (err error) {
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		// Create a child span for this method.
-		ctx, span = s.tracer.Start(ctx, "simple.Destination.RoutedRecord", trace.WithSpanKind(trace.SpanKindInternal))
-		defer func() {
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-			}
-			span.End()
-		}()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.publishNoteMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.publishNoteMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.publishNoteMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 6, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.publishNoteMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
 	}
 
-	return s.impl.RoutedRecord(ctx, a0, a1)
-}
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += serviceweaver_size_Note_80c68000(&a0)
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
 
-type source_local_stub struct {
-	impl   Source
-	tracer trace.Tracer
-}
+	// Encode arguments.
+	(a0).WeaverMarshal(enc)
 
-func (s source_local_stub) Emit(ctx context.Context, a0 string, a1 string) (err error) {
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		// Create a child span for this method.
-		ctx, span = s.tracer.Start(ctx, "simple.Source.Emit", trace.WithSpanKind(trace.SpanKindInternal))
-		defer func() {
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-			}
-			span.End()
-		}()
+	// Call the remote method.
+	s.publishNoteMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var results []byte
+	results, err = s.stub.Run(ctx, 6, enc.Data(), shardKey)
+	if err != nil {
+		return
 	}
+	s.publishNoteMetrics.BytesReply.Put(float64(len(results)))
 
-	return s.impl.Emit(ctx, a0, a1)
+	// Decode the results.
+	dec := codegen.NewDecoder(results)
+	err = dec.Error()
+	return
 }
 
-// Client stub implementations.
-
-type destination_client_stub struct {
-	stub                codegen.Stub
-	getpidMetrics       *codegen.MethodMetrics
-	recordMetrics       *codegen.MethodMetrics
-	getAllMetrics       *codegen.MethodMetrics
-	routedRecordMetrics *codegen.MethodMetrics
+func (s destination_client_stub) RecordNote(ctx context.Context, a0 Note) (err error) {
+	if s.interceptor == nil {
+		return s.callRecordNote(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RecordNote", Args: []any{a0}}, func(ctx context.Context) error {
+		return s.callRecordNote(ctx, a0)
+	})
+	return
 }
 
-func (s destination_client_stub) Getpid(ctx context.Context) (r0 int, err error) {
+func (s destination_client_stub) callRecordNote(ctx context.Context, a0 Note) (err error) {
 	// Update metrics.
 	start := time.Now()
-	s.getpidMetrics.Count.Add(1)
+	s.recordNoteMetrics.Count.Add(1)
 
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
 		// Create a child span for this method.
-		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Getpid", trace.WithSpanKind(trace.SpanKindClient))
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.RecordNote", trace.WithSpanKind(trace.SpanKindClient))
 	}
 
 	defer func() {
@@ -168,40 +1096,73 @@ func (s destination_client_stub) Getpid(ctx context.Context) (r0 int, err error)
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
-			s.getpidMetrics.ErrorCount.Add(1)
+			s.recordNoteMetrics.ErrorCount.Add(1)
 		}
 		span.End()
 
-		s.getpidMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+		s.recordNoteMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.recordNoteMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 8, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.recordNoteMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
+	// Preallocate a buffer of the right size.
+	size := 0
+	size += serviceweaver_size_Note_80c68000(&a0)
+	enc := codegen.NewEncoder()
+	enc.Reset(size)
+
+	// Encode arguments.
+	(a0).WeaverMarshal(enc)
+
 	// Call the remote method.
-	s.getpidMetrics.BytesRequest.Put(0)
+	s.recordNoteMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
-	results, err = s.stub.Run(ctx, 1, nil, shardKey)
+	results, err = s.stub.Run(ctx, 8, enc.Data(), shardKey)
 	if err != nil {
 		return
 	}
-	s.getpidMetrics.BytesReply.Put(float64(len(results)))
+	s.recordNoteMetrics.BytesReply.Put(float64(len(results)))
 
 	// Decode the results.
 	dec := codegen.NewDecoder(results)
-	r0 = dec.Int()
 	err = dec.Error()
 	return
 }
 
-func (s destination_client_stub) Record(ctx context.Context, a0 string, a1 string) (err error) {
+func (s destination_client_stub) Count(ctx context.Context, a0 int) (r0 weaver.Stream[int], err error) {
+	if s.interceptor == nil {
+		return s.callCount(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Count", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callCount(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callCount(ctx context.Context, a0 int) (r0 weaver.Stream[int], err error) {
 	// Update metrics.
 	start := time.Now()
-	s.recordMetrics.Count.Add(1)
+	s.countMetrics.Count.Add(1)
 
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
 		// Create a child span for this method.
-		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Record", trace.WithSpanKind(trace.SpanKindClient))
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Count", trace.WithSpanKind(trace.SpanKindClient))
 	}
 
 	defer func() {
@@ -214,49 +1175,60 @@ func (s destination_client_stub) Record(ctx context.Context, a0 string, a1 strin
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
-			s.recordMetrics.ErrorCount.Add(1)
+			s.countMetrics.ErrorCount.Add(1)
 		}
 		span.End()
 
-		s.recordMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+		s.countMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
 	// Preallocate a buffer of the right size.
 	size := 0
-	size += (4 + len(a0))
-	size += (4 + len(a1))
+	size += 8
 	enc := codegen.NewEncoder()
 	enc.Reset(size)
 
 	// Encode arguments.
-	enc.String(a0)
-	enc.String(a1)
-	var shardKey uint64
+	enc.Int(a0)
 
 	// Call the remote method.
-	s.recordMetrics.BytesRequest.Put(float64(len(enc.Data())))
-	var results []byte
-	results, err = s.stub.Run(ctx, 2, enc.Data(), shardKey)
+	s.countMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var stream codegen.ClientStream
+	stream, err = s.stub.Stream(ctx, 0, enc.Data(), shardKey)
 	if err != nil {
 		return
 	}
-	s.recordMetrics.BytesReply.Put(float64(len(results)))
 
-	// Decode the results.
-	dec := codegen.NewDecoder(results)
-	err = dec.Error()
+	// Decode the streamed results.
+	r0 = codegen.ClientValues[int](stream, func(dec *codegen.Decoder) (v int) {
+		v = dec.Int()
+		return
+	}, s.stub.WrapError)
 	return
 }
 
-func (s destination_client_stub) GetAll(ctx context.Context, a0 string) (r0 []string, err error) {
+func (s destination_client_stub) Echo(ctx context.Context, a0 string, a1 weaver.Stream[string]) (r0 weaver.Stream[string], err error) {
+	if s.interceptor == nil {
+		return s.callEcho(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Echo", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+		r0, err = s.callEcho(ctx, a0, a1)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callEcho(ctx context.Context, a0 string, a1 weaver.Stream[string]) (r0 weaver.Stream[string], err error) {
 	// Update metrics.
 	start := time.Now()
-	s.getAllMetrics.Count.Add(1)
+	s.echoMetrics.Count.Add(1)
 
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
 		// Create a child span for this method.
-		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.GetAll", trace.WithSpanKind(trace.SpanKindClient))
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Echo", trace.WithSpanKind(trace.SpanKindClient))
 	}
 
 	defer func() {
@@ -269,13 +1241,15 @@ func (s destination_client_stub) GetAll(ctx context.Context, a0 string) (r0 []st
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
-			s.getAllMetrics.ErrorCount.Add(1)
+			s.echoMetrics.ErrorCount.Add(1)
 		}
 		span.End()
 
-		s.getAllMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+		s.echoMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -284,33 +1258,116 @@ func (s destination_client_stub) GetAll(ctx context.Context, a0 string) (r0 []st
 
 	// Encode arguments.
 	enc.String(a0)
+
+	// Call the remote method.
+	s.echoMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	var stream codegen.ClientStream
+	stream, err = s.stub.Stream(ctx, 1, enc.Data(), shardKey)
+	if err != nil {
+		return
+	}
+
+	// Decode the streamed results.
+	r0 = codegen.BidiClientValues[string, string](stream, a1, func(enc *codegen.Encoder, v string) {
+		enc.String(v)
+	}, func(dec *codegen.Decoder) (v string) {
+		v = dec.String()
+		return
+	}, s.stub.WrapError)
+	return
+}
+
+func (s destination_client_stub) Whoami(ctx context.Context) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callWhoami(ctx)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Whoami"}, func(ctx context.Context) (err error) {
+		r0, err = s.callWhoami(ctx)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callWhoami(ctx context.Context) (r0 string, err error) {
+	// Update metrics.
+	start := time.Now()
+	s.whoamiMetrics.Count.Add(1)
+
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		// Create a child span for this method.
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.Whoami", trace.WithSpanKind(trace.SpanKindClient))
+	}
+
+	defer func() {
+		// Catch and return any panics detected during encoding/decoding/rpc.
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+		err = s.stub.WrapError(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			s.whoamiMetrics.ErrorCount.Add(1)
+		}
+		span.End()
+
+		s.whoamiMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+	}()
+
 	var shardKey uint64
 
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec)
+		s.whoamiMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 10, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.whoamiMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Call the remote method.
-	s.getAllMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	s.whoamiMetrics.BytesRequest.Put(0)
 	var results []byte
-	results, err = s.stub.Run(ctx, 0, enc.Data(), shardKey)
+	results, err = s.stub.Run(ctx, 10, nil, shardKey)
 	if err != nil {
 		return
 	}
-	s.getAllMetrics.BytesReply.Put(float64(len(results)))
+	s.whoamiMetrics.BytesReply.Put(float64(len(results)))
 
 	// Decode the results.
 	dec := codegen.NewDecoder(results)
-	r0 = serviceweaver_dec_slice_string_4af10117(dec)
+	r0 = dec.String()
 	err = dec.Error()
 	return
 }
 
-func (s destination_client_stub) RoutedRecord(ctx context.Context, a0 string, a1 string) (err error) {
+func (s destination_client_stub) GetMetadata(ctx context.Context, a0 string) (r0 string, err error) {
+	if s.interceptor == nil {
+		return s.callGetMetadata(ctx, a0)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetMetadata", Args: []any{a0}}, func(ctx context.Context) (err error) {
+		r0, err = s.callGetMetadata(ctx, a0)
+		return
+	})
+	return
+}
+
+func (s destination_client_stub) callGetMetadata(ctx context.Context, a0 string) (r0 string, err error) {
 	// Update metrics.
 	start := time.Now()
-	s.routedRecordMetrics.Count.Add(1)
+	s.getMetadataMetrics.Count.Add(1)
 
 	span := trace.SpanFromContext(ctx)
 	if span.SpanContext().IsValid() {
 		// Create a child span for this method.
-		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.RoutedRecord", trace.WithSpanKind(trace.SpanKindClient))
+		ctx, span = s.stub.Tracer().Start(ctx, "simple.Destination.GetMetadata", trace.WithSpanKind(trace.SpanKindClient))
 	}
 
 	defer func() {
@@ -323,49 +1380,72 @@ func (s destination_client_stub) RoutedRecord(ctx context.Context, a0 string, a1
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
-			s.routedRecordMetrics.ErrorCount.Add(1)
+			s.getMetadataMetrics.ErrorCount.Add(1)
 		}
 		span.End()
 
-		s.routedRecordMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
+		s.getMetadataMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0)
+		s.getMetadataMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 3, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.getMetadataMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results, &r0)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
-	size += (4 + len(a1))
 	enc := codegen.NewEncoder()
 	enc.Reset(size)
 
 	// Encode arguments.
 	enc.String(a0)
-	enc.String(a1)
-
-	// Set the shardKey.
-	var r destRouter
-	shardKey := _hashDestination(r.RoutedRecord(ctx, a0, a1))
 
 	// Call the remote method.
-	s.routedRecordMetrics.BytesRequest.Put(float64(len(enc.Data())))
+	s.getMetadataMetrics.BytesRequest.Put(float64(len(enc.Data())))
 	var results []byte
 	results, err = s.stub.Run(ctx, 3, enc.Data(), shardKey)
 	if err != nil {
 		return
 	}
-	s.routedRecordMetrics.BytesReply.Put(float64(len(results)))
+	s.getMetadataMetrics.BytesReply.Put(float64(len(results)))
 
 	// Decode the results.
 	dec := codegen.NewDecoder(results)
+	r0 = dec.String()
 	err = dec.Error()
 	return
 }
 
 type source_client_stub struct {
 	stub        codegen.Stub
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 	emitMetrics *codegen.MethodMetrics
 }
 
 func (s source_client_stub) Emit(ctx context.Context, a0 string, a1 string) (err error) {
+	if s.interceptor == nil {
+		return s.callEmit(ctx, a0, a1)
+	}
+	err = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source", Method: "Emit", Args: []any{a0, a1}}, func(ctx context.Context) error {
+		return s.callEmit(ctx, a0, a1)
+	})
+	return
+}
+
+func (s source_client_stub) callEmit(ctx context.Context, a0 string, a1 string) (err error) {
 	// Update metrics.
 	start := time.Now()
 	s.emitMetrics.Count.Add(1)
@@ -393,6 +1473,22 @@ func (s source_client_stub) Emit(ctx context.Context, a0 string, a1 string) (err
 		s.emitMetrics.Latency.Put(float64(time.Since(start).Microseconds()))
 	}()
 
+	var shardKey uint64
+
+	if s.codec != nil {
+		// Call the remote method, with the component's codec.
+		args := codegen.EncodeArgs(s.codec, a0, a1)
+		s.emitMetrics.BytesRequest.Put(float64(len(args)))
+		var results []byte
+		results, err = s.stub.Run(ctx, 0, args, shardKey)
+		if err != nil {
+			return
+		}
+		s.emitMetrics.BytesReply.Put(float64(len(results)))
+		err = codegen.DecodeResults(s.codec, results)
+		return
+	}
+
 	// Preallocate a buffer of the right size.
 	size := 0
 	size += (4 + len(a0))
@@ -403,7 +1499,6 @@ func (s source_client_stub) Emit(ctx context.Context, a0 string, a1 string) (err
 	// Encode arguments.
 	enc.String(a0)
 	enc.String(a1)
-	var shardKey uint64
 
 	// Call the remote method.
 	s.emitMetrics.BytesRequest.Put(float64(len(enc.Data())))
@@ -422,9 +1517,116 @@ func (s source_client_stub) Emit(ctx context.Context, a0 string, a1 string) (err
 
 // Server stub implementations.
 
+type cache_string_int_94168a7d_server_stub struct {
+	impl        Cache[string, int]
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
+}
+
+// GetStubFn implements the stub.Server interface.
+func (s cache_string_int_94168a7d_server_stub) GetStubFn(method string) func(ctx context.Context, args []byte) ([]byte, error) {
+	switch method {
+	case "Get":
+		return s.get
+	case "Put":
+		return s.put
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s cache_string_int_94168a7d_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
+func (s cache_string_int_94168a7d_server_stub) get(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Get(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Get", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Get(ctx, a0)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Int(r0)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s cache_string_int_94168a7d_server_stub) put(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	var a1 int
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.Int()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Put(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Cache[string, int]", Method: "Put", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Put(ctx, a0, a1)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
 type destination_server_stub struct {
-	impl    Destination
-	addLoad func(key uint64, load float64)
+	impl        Destination
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -438,6 +1640,28 @@ func (s destination_server_stub) GetStubFn(method string) func(ctx context.Conte
 		return s.getAll
 	case "RoutedRecord":
 		return s.routedRecord
+	case "Publish":
+		return s.publish
+	case "PublishNote":
+		return s.publishNote
+	case "RecordNote":
+		return s.recordNote
+	case "Whoami":
+		return s.whoami
+	case "GetMetadata":
+		return s.getMetadata
+	default:
+		return nil
+	}
+}
+
+// GetStreamFn implements the stub.Server interface.
+func (s destination_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	switch method {
+	case "Count":
+		return s.count
+	case "Echo":
+		return s.echo
 	default:
 		return nil
 	}
@@ -454,9 +1678,21 @@ func (s destination_server_stub) getpid(ctx context.Context, args []byte) (res [
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.Getpid(ctx)
+	var r0 int
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Getpid(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Getpid"}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Getpid(ctx)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Int(r0)
 	enc.Error(appErr)
@@ -472,18 +1708,32 @@ func (s destination_server_stub) record(ctx context.Context, args []byte) (res [
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Record(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Record(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Record", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Record(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
@@ -498,16 +1748,32 @@ func (s destination_server_stub) getAll(ctx context.Context, args []byte) (res [
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	r0, appErr := s.impl.GetAll(ctx, a0)
+	var r0 []string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetAll(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetAll", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetAll(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
 	serviceweaver_enc_slice_string_4af10117(enc, r0)
 	enc.Error(appErr)
@@ -523,28 +1789,303 @@ func (s destination_server_stub) routedRecord(ctx context.Context, args []byte)
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
 	var r destRouter
 	s.addLoad(_hashDestination(r.RoutedRecord(ctx, a0, a1)), 1.0)
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.RoutedRecord(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.RoutedRecord(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RoutedRecord", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.RoutedRecord(ctx, a0, a1)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s destination_server_stub) publish(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	var a1 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Publish(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Publish", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Publish(ctx, a0, a1)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s destination_server_stub) publishNote(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 Note
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.PublishNote(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "PublishNote", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.PublishNote(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s destination_server_stub) recordNote(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 Note
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		(&a0).WeaverUnmarshal(dec)
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.RecordNote(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "RecordNote", Args: []any{a0}}, func(ctx context.Context) error {
+			return s.impl.RecordNote(ctx, a0)
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s destination_server_stub) count(ctx context.Context, args []byte, stream codegen.ServerStream) (err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	dec := codegen.NewDecoder(args)
+	var a0 int
+	a0 = dec.Int()
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 weaver.Stream[int]
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Count(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Count", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Count(ctx, a0)
+			return
+		})
+	}
+
+	// Stream the results.
+	return codegen.SendServerValues[int](stream, r0, appErr, func(enc *codegen.Encoder, v int) {
+		enc.Int(v)
+	})
+}
+
+func (s destination_server_stub) echo(ctx context.Context, args []byte, stream codegen.ServerStream) (err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	dec := codegen.NewDecoder(args)
+	var a0 string
+	a0 = dec.String()
+	a1 := codegen.ServerValues[string](stream, func(dec *codegen.Decoder) (v string) {
+		v = dec.String()
+		return
+	})
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 weaver.Stream[string]
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Echo(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Echo", Args: []any{a0, a1}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Echo(ctx, a0, a1)
+			return
+		})
+	}
+
+	// Stream the results.
+	return codegen.SendServerValues[string](stream, r0, appErr, func(enc *codegen.Encoder, v string) {
+		enc.String(v)
+	})
+}
+
+func (s destination_server_stub) whoami(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.Whoami(ctx)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "Whoami"}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.Whoami(ctx)
+			return
+		})
+	}
+
+	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
+	enc := codegen.NewEncoder()
+	enc.String(r0)
+	enc.Error(appErr)
+	return enc.Data(), nil
+}
+
+func (s destination_server_stub) getMetadata(ctx context.Context, args []byte) (res []byte, err error) {
+	// Catch and return any panics detected during encoding/decoding/rpc.
+	defer func() {
+		if err == nil {
+			err = codegen.CatchPanics(recover())
+		}
+	}()
+
+	// Decode arguments.
+	var a0 string
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+	}
+
+	// TODO(rgrandl): The deferred function above will recover from panics in the
+	// user code: fix this.
+	// Call the local method.
+	var r0 string
+	var appErr error
+	if s.interceptor == nil {
+		r0, appErr = s.impl.GetMetadata(ctx, a0)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Destination", Method: "GetMetadata", Args: []any{a0}}, func(ctx context.Context) (err error) {
+			r0, err = s.impl.GetMetadata(ctx, a0)
+			return
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr, r0), nil
+	}
 	enc := codegen.NewEncoder()
+	enc.String(r0)
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
 type source_server_stub struct {
-	impl    Source
-	addLoad func(key uint64, load float64)
+	impl        Source
+	addLoad     func(key uint64, load float64)
+	codec       codegen.Codec
+	interceptor codegen.Interceptor
 }
 
 // GetStubFn implements the stub.Server interface.
@@ -557,6 +2098,11 @@ func (s source_server_stub) GetStubFn(method string) func(ctx context.Context, a
 	}
 }
 
+// GetStreamFn implements the stub.Server interface.
+func (s source_server_stub) GetStreamFn(method string) func(ctx context.Context, args []byte, stream codegen.ServerStream) error {
+	return nil
+}
+
 func (s source_server_stub) emit(ctx context.Context, args []byte) (res []byte, err error) {
 	// Catch and return any panics detected during encoding/decoding/rpc.
 	defer func() {
@@ -566,23 +2112,65 @@ func (s source_server_stub) emit(ctx context.Context, args []byte) (res []byte,
 	}()
 
 	// Decode arguments.
-	dec := codegen.NewDecoder(args)
 	var a0 string
-	a0 = dec.String()
 	var a1 string
-	a1 = dec.String()
+	if s.codec != nil {
+		codegen.DecodeArgs(s.codec, args, &a0, &a1)
+	} else {
+		dec := codegen.NewDecoder(args)
+		a0 = dec.String()
+		a1 = dec.String()
+	}
 
 	// TODO(rgrandl): The deferred function above will recover from panics in the
 	// user code: fix this.
 	// Call the local method.
-	appErr := s.impl.Emit(ctx, a0, a1)
+	var appErr error
+	if s.interceptor == nil {
+		appErr = s.impl.Emit(ctx, a0, a1)
+	} else {
+		appErr = s.interceptor(ctx, codegen.CallInfo{Component: "github.com/ServiceWeaver/weaver/weavertest/internal/simple/Source", Method: "Emit", Args: []any{a0, a1}}, func(ctx context.Context) error {
+			return s.impl.Emit(ctx, a0, a1)
+		})
+	}
 
 	// Encode the results.
+	if s.codec != nil {
+		return codegen.EncodeResults(s.codec, appErr), nil
+	}
 	enc := codegen.NewEncoder()
 	enc.Error(appErr)
 	return enc.Data(), nil
 }
 
+// AutoMarshal implementations.
+
+var _ codegen.AutoMarshal = &Note{}
+
+func (x *Note) WeaverMarshal(enc *codegen.Encoder) {
+	if x == nil {
+		panic(fmt.Errorf("Note.WeaverMarshal: nil receiver"))
+	}
+	start := enc.BeginStruct(2)
+	enc.String(x.File)
+	enc.String(x.Msg)
+	enc.EndStruct(start)
+}
+
+func (x *Note) WeaverUnmarshal(dec *codegen.Decoder) {
+	if x == nil {
+		panic(fmt.Errorf("Note.WeaverUnmarshal: nil receiver"))
+	}
+	n, end := dec.BeginStruct()
+	if n > 0 {
+		x.File = dec.String()
+	}
+	if n > 1 {
+		x.Msg = dec.String()
+	}
+	dec.EndStruct(end)
+}
+
 // Router methods.
 
 // _hashDestination returns a 64 bit hash of the provided value.
@@ -623,3 +2211,15 @@ func serviceweaver_dec_slice_string_4af10117(dec *codegen.Decoder) []string {
 	}
 	return res
 }
+
+// Size implementations.
+
+// serviceweaver_size_Note_80c68000 returns the size (in bytes) of the serialization
+// of the provided type.
+func serviceweaver_size_Note_80c68000(x *Note) int {
+	size := 0
+	size += 0
+	size += (4 + len(x.File))
+	size += (4 + len(x.Msg))
+	return size
+}
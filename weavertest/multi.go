@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -26,19 +27,21 @@ import (
 	"github.com/ServiceWeaver/weaver/runtime"
 	"github.com/ServiceWeaver/weaver/runtime/codegen"
 	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/ServiceWeaver/weaver/runtime/secrets"
 	"github.com/google/uuid"
 )
 
 // initMultiProcess initializes a brand new multi-process execution environment
-// that places every component in its own collocation group and returns the root
-// component for the new application.
+// that places every component in its own collocation group, unless placed
+// otherwise by the config or the provided placements, and returns the root
+// component for the new application, along with the deployer that runs it.
 //
 // config contains configuration identical to what might be found in a file passed
 // when deploying an application. It can contain application level as well as
-// component level configs. config is allowed to be empty.
-//
-// Future extension: allow options so the user can control collocation/replication/etc.
-func initMultiProcess(ctx context.Context, t testing.TB, config string) weaver.Instance {
+// component level configs. config is allowed to be empty. fakes holds the fake
+// component implementations, and faults the fault injectors, by component
+// interface type. clock, if not nil, replaces the system clock.
+func initMultiProcess(ctx context.Context, t testing.TB, config string, placements []Placement, fakes map[reflect.Type]any, faults map[reflect.Type]func(context.Context, string) error, clock weaver.Clock) (weaver.Instance, *deployer) {
 	t.Helper()
 	bootstrap, err := runtime.GetBootstrap(ctx)
 	if err != nil {
@@ -54,8 +57,11 @@ func initMultiProcess(ctx context.Context, t testing.TB, config string) weaver.I
 			}
 			os.Exit(1)
 		}()
-		weaver.Init(context.Background())
-		return nil
+		bootstrap.TestFakes = fakes
+		bootstrap.TestFaults = faults
+		bootstrap.TestClock = clock
+		weaver.Init(context.WithValue(context.Background(), runtime.BootstrapKey{}, bootstrap))
+		return nil, nil
 	}
 
 	// Construct AppConfig and EnvelopeInfo.
@@ -74,7 +80,20 @@ func initMultiProcess(ctx context.Context, t testing.TB, config string) weaver.I
 	appConfig.Name = strings.ReplaceAll(t.Name(), "/", "_")
 	appConfig.Binary = exe
 	appConfig.Args = []string{"-test.run", regexp.QuoteMeta(t.Name())}
+	if _, ok := t.(*testing.B); ok {
+		// Benchmarks aren't selected by -test.run. Select only this one, with
+		// every level of its name anchored, e.g., "^BenchmarkFoo$/^rpc$".
+		levels := strings.Split(t.Name(), "/")
+		for i, level := range levels {
+			levels[i] = "^" + regexp.QuoteMeta(level) + "$"
+		}
+		appConfig.Args = []string{"-test.run", "^$", "-test.bench", strings.Join(levels, "/")}
+	}
 
+	appConfig, err = secrets.Resolve(ctx, appConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
 	wlet := &protos.EnvelopeInfo{
 		App:           appConfig.Name,
 		DeploymentId:  uuid.New().String(),
@@ -84,6 +103,6 @@ func initMultiProcess(ctx context.Context, t testing.TB, config string) weaver.I
 	}
 
 	// Launch the deployer.
-	d := newDeployer(ctx, t, wlet, appConfig)
-	return d.Init(config)
+	d := newDeployer(ctx, t, wlet, appConfig, placements, clock)
+	return d.Init(config, fakes, faults), d
 }
@@ -0,0 +1,142 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+)
+
+// A Placement places a component in a multiprocess test. See Local, Remote,
+// and ColocatedWith.
+type Placement struct {
+	intf     reflect.Type // component interface type
+	local    bool         // run in the test's process?
+	replicas int          // if positive, number of processes
+	with     reflect.Type // if not nil, component to colocate with
+}
+
+// Local places the component with interface type T in the process of the
+// test, which runs main. Calls from main to the component are regular method
+// calls.
+func Local[T any]() Placement {
+	return Placement{intf: componentType[T]("Local"), local: true}
+}
+
+// Remote places the component with interface type T in a co-location group
+// run by the provided number of processes. The group holds T alone, unless
+// the colocate option of the config or ColocatedWith adds other components to
+// it. For example, the following test runs the cart service in three
+// processes, so that calls to it are load balanced, or routed, across three
+// replicas:
+//
+//	root := weavertest.Init(ctx, t, weavertest.Options{
+//	    Placements: []weavertest.Placement{
+//	        weavertest.Remote[cartservice.T](3),
+//	    },
+//	})
+func Remote[T any](replicas int) Placement {
+	if replicas <= 0 {
+		panic(fmt.Errorf("Remote: %d replicas; want at least one", replicas))
+	}
+	return Placement{intf: componentType[T]("Remote"), replicas: replicas}
+}
+
+// ColocatedWith places the component with interface type T in the
+// co-location group of the component with interface type U, which must not
+// itself be placed with ColocatedWith. U may be placed with Local or Remote.
+func ColocatedWith[T, U any]() Placement {
+	return Placement{intf: componentType[T]("ColocatedWith"), with: componentType[U]("ColocatedWith")}
+}
+
+// componentType returns the interface type T, panicking if it isn't an
+// interface.
+func componentType[T any](caller string) reflect.Type {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("%s: type %v is not an interface", caller, t))
+	}
+	return t
+}
+
+// placeComponents applies the provided placements to colocation, which maps
+// components to the names of their co-location groups, and returns the number
+// of processes of the groups placed with Remote, by group name.
+func placeComponents(placements []Placement, colocation map[string]string) (map[string]int, error) {
+	names := map[reflect.Type]string{}
+	for _, reg := range codegen.Registered() {
+		names[reg.Iface] = reg.Name
+	}
+	name := func(t reflect.Type) (string, error) {
+		if name, ok := names[t]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("placement of %v: not a component", t)
+	}
+	groupOf := func(component string) string {
+		if group, ok := colocation[component]; ok {
+			return group
+		}
+		return component
+	}
+
+	// Place the components with Local and Remote before the components
+	// colocated with them.
+	placed := map[string]bool{}
+	replicas := map[string]int{}
+	var with []Placement
+	for _, p := range placements {
+		component, err := name(p.intf)
+		if err != nil {
+			return nil, err
+		}
+		if component == "main" {
+			return nil, fmt.Errorf("placement of %v: main can't be placed", p.intf)
+		}
+		if placed[component] {
+			return nil, fmt.Errorf("placement of %v: placed more than once", p.intf)
+		}
+		placed[component] = true
+		switch {
+		case p.local:
+			colocation[component] = "main"
+		case p.replicas > 0:
+			group := groupOf(component)
+			if group == "main" {
+				return nil, fmt.Errorf("placement of %v: colocated with main, which runs in a single process", p.intf)
+			}
+			replicas[group] = p.replicas
+		default:
+			with = append(with, p)
+		}
+	}
+	colocatedWith := map[string]bool{}
+	for _, p := range with {
+		colocatedWith[names[p.intf]] = true
+	}
+	for _, p := range with {
+		target, err := name(p.with)
+		if err != nil {
+			return nil, err
+		}
+		if colocatedWith[target] {
+			return nil, fmt.Errorf("placement of %v: %v is itself placed with ColocatedWith", p.intf, p.with)
+		}
+		colocation[names[p.intf]] = groupOf(target)
+	}
+	return replicas, nil
+}
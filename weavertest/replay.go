@@ -0,0 +1,66 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ServiceWeaver/weaver"
+)
+
+// Replay replays the component method calls recorded in dir, e.g., by a
+// deployment with call recording enabled, against the components of root's
+// application. The calls are made one at a time, in the order in which they
+// started when recorded, without waiting between them. For example, a test
+// can reproduce the calls that led a component to a bad state:
+//
+//	func TestCartCorruption(t *testing.T) {
+//	    ctx := context.Background()
+//	    root := weavertest.Init(ctx, t, weavertest.Options{})
+//	    weavertest.Replay(ctx, t, root, "testdata/corrupted_cart")
+//	    cart, err := weaver.Get[cartservice.T](root)
+//	    ...
+//	}
+//
+// Replay fails the test if the recorded calls can't be read. It logs, and
+// returns, the calls whose outcome differs from the recorded one: calls whose
+// results differ from the recorded results, and calls that fail, e.g.,
+// because the method no longer exists, although the recorded call didn't, or
+// vice versa. See weaver.RecordedCall and weaver.Replay for details.
+func Replay(ctx context.Context, t testing.TB, root weaver.Instance, dir string) []weaver.RecordedCall {
+	t.Helper()
+	calls, err := weaver.ReadRecording(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diverged []weaver.RecordedCall
+	for i, call := range calls {
+		results, err := weaver.Replay(ctx, root, call)
+		switch {
+		case err != nil && call.Error == "":
+			t.Logf("call %d to %s.%s: replay failed with %v; recorded call succeeded", i, call.Component, call.Method, err)
+		case err == nil && call.Error != "":
+			t.Logf("call %d to %s.%s: replay succeeded; recorded call failed with %s", i, call.Component, call.Method, call.Error)
+		case err == nil && !bytes.Equal(results, call.Results):
+			t.Logf("call %d to %s.%s: replay results differ from recorded results", i, call.Component, call.Method)
+		default:
+			continue
+		}
+		diverged = append(diverged, call)
+	}
+	return diverged
+}
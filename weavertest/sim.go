@@ -0,0 +1,286 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weavertest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/runtime/logging"
+)
+
+// blockTimeout is the real time after which a simulated task that neither
+// yields nor ends is considered blocked, e.g., on a mutex held by another
+// task, and another task is scheduled.
+const blockTimeout = 100 * time.Millisecond
+
+// simEpoch is the initial time of the simulated clock.
+var simEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A Simulator runs a test as a deterministic simulation. Passed in
+// Options.Simulator, it runs every component in the test process, and
+// schedules the concurrent calls to the components made by the tasks started
+// with Go, one at a time, in an order picked by a seeded pseudo-random number
+// generator. Running a test twice with the same seed replays the same
+// interleaving of calls, so that a flaky concurrency bug can be reproduced.
+// For example:
+//
+//	func TestConcurrentAddItem(t *testing.T) {
+//	    sim := weavertest.NewSimulator(t, 0)
+//	    root := weavertest.Init(ctx, t, weavertest.Options{Simulator: sim})
+//	    cart, err := weaver.Get[cartservice.T](root)
+//	    ...
+//	    for i := 0; i < 3; i++ {
+//	        sim.Go(ctx, func(ctx context.Context) {
+//	            cart.AddItem(ctx, "alice", item)
+//	        })
+//	    }
+//	    sim.Wait()
+//	    // Check the cart...
+//	}
+//
+// A task runs until it calls a component method, or sleeps with Sleep. The
+// simulator then picks the next task to run among the tasks ready to run.
+// Once every task sleeps, the simulated clock jumps to the time the first of
+// them wakes up. The simulated clock is a FakeClock, returned by Clock, that
+// also replaces the clock returned by weaver.ClockOf, so the timers of the
+// components fire as the simulated clock jumps. Delays injected with
+// Options.Faults, and drops, use the simulated clock and the seeded generator
+// too.
+//
+// Only the calls made by tasks, directly or through other components, are
+// scheduled. Calls made by the goroutines started by components, or by the
+// test outside of a task, are not. The interleaving is reproducible as long
+// as tasks only communicate through components, and do not depend on real
+// time. A task that blocks for long without calling a component, e.g., on a
+// mutex held by another task, lets other tasks run meanwhile, which may make
+// the interleaving differ between runs.
+type Simulator struct {
+	seed int64
+
+	mu       sync.Mutex
+	cond     sync.Cond  // signaled when every task has ended
+	rand     *rand.Rand // picks the next task
+	clock    *FakeClock // simulated clock
+	started  bool       // has Wait been called?
+	nextId   int        // id of the next task
+	tasks    int        // number of tasks that have not ended
+	running  *simTask   // the task running, or nil
+	slot     int        // incremented every time a task is scheduled
+	ready    []*simTask // tasks ready to run, in the order they became ready
+	sleeping []*simTask // sleeping tasks
+	history  []string   // scheduling decisions
+}
+
+// A simTask is a goroutine started by Simulator.Go.
+type simTask struct {
+	id    int
+	wake  chan struct{} // receives when the task is scheduled
+	until time.Time     // when a sleeping task wakes up
+	label string        // what the task is about to do
+}
+
+// simTaskKey is the context key that holds the simTask making a call.
+type simTaskKey struct{}
+
+// NewSimulator returns a new simulator that schedules tasks with the provided
+// seed. If seed is 0, a random seed is picked. The seed is logged if the test
+// fails, so that the failure can be replayed.
+func NewSimulator(t testing.TB, seed int64) *Simulator {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	s := &Simulator{
+		seed:  seed,
+		rand:  rand.New(rand.NewSource(seed)),
+		clock: NewFakeClock(simEpoch),
+	}
+	s.cond.L = &s.mu
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("weavertest: simulation seed %d; pass it to NewSimulator to replay the test", seed)
+		}
+	})
+	return s
+}
+
+// Seed returns the seed of the simulator.
+func (s *Simulator) Seed() int64 {
+	return s.seed
+}
+
+// Go starts a task that runs fn. Tasks don't run until Wait is called.
+func (s *Simulator) Go(ctx context.Context, fn func(ctx context.Context)) {
+	s.mu.Lock()
+	t := &simTask{id: s.nextId, wake: make(chan struct{}, 1), label: "start"}
+	s.nextId++
+	s.tasks++
+	s.ready = append(s.ready, t)
+	s.schedule()
+	s.mu.Unlock()
+
+	go func() {
+		<-t.wake
+		defer s.end(t)
+		fn(context.WithValue(ctx, simTaskKey{}, t))
+	}()
+}
+
+// Wait runs the tasks started with Go, and waits for all of them to end.
+func (s *Simulator) Wait() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	s.schedule()
+	for s.tasks > 0 {
+		s.cond.Wait()
+	}
+}
+
+// Clock returns the simulated clock. In a simulated test, it is also the
+// clock returned by weaver.ClockOf.
+func (s *Simulator) Clock() *FakeClock {
+	return s.clock
+}
+
+// Now returns the current time of the simulated clock.
+func (s *Simulator) Now() time.Time {
+	return s.clock.Now()
+}
+
+// Sleep pauses the task making the call for the provided duration of
+// simulated time. Outside of a task, Sleep sleeps for the provided duration
+// of real time. Fakes can call Sleep to simulate slow components.
+func (s *Simulator) Sleep(ctx context.Context, d time.Duration) error {
+	t, ok := ctx.Value(simTaskKey{}).(*simTask)
+	if !ok {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	t.until = s.clock.Now().Add(d)
+	t.label = fmt.Sprintf("wake up after %v", d)
+	s.sleeping = append(s.sleeping, t)
+	s.release(t)
+	s.mu.Unlock()
+	<-t.wake
+	return ctx.Err()
+}
+
+// History returns the scheduling decisions made so far, e.g., "task 1:
+// call example.com/cart/T.AddItem", in order.
+func (s *Simulator) History() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.history...)
+}
+
+// yield is called by a task before it calls the provided method of the
+// provided component. It lets the simulator run another task.
+func (s *Simulator) yield(ctx context.Context, component, method string) {
+	t, ok := ctx.Value(simTaskKey{}).(*simTask)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	t.label = fmt.Sprintf("call %s.%s", logging.ShortenComponent(component), method)
+	s.ready = append(s.ready, t)
+	s.release(t)
+	s.mu.Unlock()
+	<-t.wake
+}
+
+// float64 returns a pseudo-random number in [0, 1).
+func (s *Simulator) float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rand.Float64()
+}
+
+// end is called when a task ends.
+func (s *Simulator) end(t *simTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks--
+	s.release(t)
+	if s.tasks == 0 {
+		s.cond.Broadcast()
+	}
+}
+
+// release is called when the provided task stops running. The task may have
+// been considered blocked, and not be the running task anymore.
+//
+// REQUIRES: s.mu is held.
+func (s *Simulator) release(t *simTask) {
+	if s.running == t {
+		s.running = nil
+	}
+	s.schedule()
+}
+
+// schedule runs the next task, if no task is running.
+//
+// REQUIRES: s.mu is held.
+func (s *Simulator) schedule() {
+	if !s.started || s.running != nil {
+		return
+	}
+	if len(s.ready) == 0 && len(s.sleeping) > 0 {
+		// Every task sleeps. Advance the clock to wake the first of them,
+		// unless the test already advanced it past the first wake up.
+		sort.SliceStable(s.sleeping, func(i, j int) bool {
+			return s.sleeping[i].until.Before(s.sleeping[j].until)
+		})
+		if d := s.sleeping[0].until.Sub(s.clock.Now()); d > 0 {
+			s.clock.Advance(d)
+		}
+		now := s.clock.Now()
+		for len(s.sleeping) > 0 && !s.sleeping[0].until.After(now) {
+			s.ready = append(s.ready, s.sleeping[0])
+			s.sleeping = s.sleeping[1:]
+		}
+	}
+	if len(s.ready) == 0 {
+		return
+	}
+	i := s.rand.Intn(len(s.ready))
+	t := s.ready[i]
+	s.ready = append(s.ready[:i], s.ready[i+1:]...)
+	s.running = t
+	s.slot++
+	slot := s.slot
+	s.history = append(s.history, fmt.Sprintf("task %d: %s", t.id, t.label))
+	t.wake <- struct{}{}
+
+	// Schedule another task if t blocks.
+	time.AfterFunc(blockTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.running == t && s.slot == slot {
+			s.history = append(s.history, fmt.Sprintf("task %d: blocked", t.id))
+			s.release(t)
+		}
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	rt "runtime"
 	"testing"
 	"time"
@@ -31,8 +32,10 @@ import (
 //
 // config contains configuration identical to what might be found in a file passed
 // when deploying an application. It can contain application level as well as
-// component level configs. config is allowed to be empty.
-func initSingleProcess(ctx context.Context, t testing.TB, config string) weaver.Instance {
+// component level configs. config is allowed to be empty. fakes holds the fake
+// component implementations, and faults the fault injectors, by component
+// interface type. clock, if not nil, replaces the system clock.
+func initSingleProcess(ctx context.Context, t testing.TB, config string, fakes map[reflect.Type]any, faults map[reflect.Type]func(context.Context, string) error, clock weaver.Clock) weaver.Instance {
 	t.Helper()
 	ctx, cancelFunc := context.WithCancel(ctx)
 	t.Cleanup(func() {
@@ -41,6 +44,9 @@ func initSingleProcess(ctx context.Context, t testing.TB, config string) weaver.
 	})
 	ctx = context.WithValue(ctx, runtime.BootstrapKey{}, runtime.Bootstrap{
 		TestConfig: config,
+		TestFakes:  fakes,
+		TestFaults: faults,
+		TestClock:  clock,
 	})
 	return weaver.Init(ctx)
 }
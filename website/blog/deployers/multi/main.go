@@ -150,3 +150,44 @@ func (h *handler) HandleTraceSpans(context.Context, []trace.ReadOnlySpan) error
 	// This simplified deployer drops traces on the floor.
 	return nil
 }
+
+func (h *handler) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	// This simplified deployer doesn't forward topic events to other
+	// weavelets.
+	return nil
+}
+
+func (h *handler) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	// This simplified deployer doesn't support topics.
+	return nil, fmt.Errorf("topics not supported")
+}
+
+func (h *handler) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	// This simplified deployer doesn't support topics.
+	return nil, fmt.Errorf("topics not supported")
+}
+
+func (h *handler) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	// This simplified deployer doesn't support cron jobs.
+	return nil, fmt.Errorf("cron jobs not supported")
+}
+
+func (h *handler) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	// This simplified deployer doesn't support mutual TLS.
+	return nil, fmt.Errorf("mTLS not supported")
+}
+
+func (h *handler) Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	// This simplified deployer doesn't support leader elections.
+	return nil, fmt.Errorf("leader elections not supported")
+}
+
+func (h *handler) HandleProfile(context.Context, *protos.Profile) error {
+	// This simplified deployer drops profiles.
+	return nil
+}
+
+func (h *handler) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	// This simplified deployer drops crash reports.
+	return nil
+}
@@ -115,3 +115,43 @@ func (d *deployer) HandleTraceSpans(context.Context, []trace.ReadOnlySpan) error
 	// This simplified deployer drops traces on the floor.
 	return nil
 }
+
+func (d *deployer) HandleTopicEvent(context.Context, *protos.TopicEvent) error {
+	// There is only one weavelet, so there is nobody to forward events to.
+	return nil
+}
+
+func (d *deployer) PublishMessage(context.Context, *protos.PublishMessageRequest) (*protos.PublishMessageReply, error) {
+	// This simplified deployer doesn't support topics.
+	return nil, fmt.Errorf("topics not supported")
+}
+
+func (d *deployer) Subscribe(context.Context, *protos.SubscribeRequest) (*protos.SubscribeReply, error) {
+	// This simplified deployer doesn't support topics.
+	return nil, fmt.Errorf("topics not supported")
+}
+
+func (d *deployer) ScheduleJob(context.Context, *protos.ScheduleJobRequest) (*protos.ScheduleJobReply, error) {
+	// This simplified deployer doesn't support cron jobs.
+	return nil, fmt.Errorf("cron jobs not supported")
+}
+
+func (d *deployer) GetCertificate(context.Context, *protos.GetCertificateRequest) (*protos.GetCertificateReply, error) {
+	// This simplified deployer doesn't support mutual TLS.
+	return nil, fmt.Errorf("mTLS not supported")
+}
+
+func (d *deployer) Lease(context.Context, *protos.LeaseRequest) (*protos.LeaseReply, error) {
+	// This simplified deployer doesn't support leader elections.
+	return nil, fmt.Errorf("leader elections not supported")
+}
+
+func (d *deployer) HandleProfile(context.Context, *protos.Profile) error {
+	// This simplified deployer drops profiles.
+	return nil
+}
+
+func (d *deployer) HandleCrashReport(context.Context, *protos.CrashReport) error {
+	// This simplified deployer drops crash reports.
+	return nil
+}
@@ -0,0 +1,795 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/kv"
+	"github.com/ServiceWeaver/weaver/metrics"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+)
+
+const (
+	// DefaultWorkflowMaxAttempts is the default WorkflowOptions.MaxAttempts.
+	DefaultWorkflowMaxAttempts = 5
+
+	// DefaultWorkflowRetryBackoff is the default WorkflowOptions.RetryBackoff.
+	DefaultWorkflowRetryBackoff = time.Second
+
+	// DefaultWorkflowRecoveryInterval is the default
+	// WorkflowOptions.RecoveryInterval.
+	DefaultWorkflowRecoveryInterval = 30 * time.Second
+
+	// DefaultWorkflowRetention is the default WorkflowOptions.Retention.
+	DefaultWorkflowRetention = 7 * 24 * time.Hour
+)
+
+// maxWorkflowRetryBackoff bounds the backoff between two attempts of a step or
+// of a compensation handler.
+const maxWorkflowRetryBackoff = time.Minute
+
+// workflowPollInterval is how often Wait checks whether an instance run by
+// another process has finished.
+const workflowPollInterval = time.Second
+
+type workflowLabels struct {
+	Workflow string // the workflow name
+	Outcome  string // "succeeded" or "compensated"
+}
+
+type workflowStepLabels struct {
+	Workflow string // the workflow name
+	Step     string // the step name
+}
+
+var (
+	workflowsFinished = metrics.NewCounterMap[workflowLabels](
+		"serviceweaver_workflow_finished_count",
+		"Count of Service Weaver workflow instances finished by the process",
+	)
+	workflowStepRetries = metrics.NewCounterMap[workflowStepLabels](
+		"serviceweaver_workflow_step_retry_count",
+		"Count of retries of Service Weaver workflow steps and compensation handlers",
+	)
+)
+
+// WorkflowOptions configure a Workflow.
+type WorkflowOptions struct {
+	// MaxAttempts is how many times a step is attempted when it fails with a
+	// retriable error (see ErrRetriable), before the instance is compensated.
+	// If zero, DefaultWorkflowMaxAttempts is used.
+	MaxAttempts int
+
+	// RetryBackoff is how long to wait before attempting a step or a
+	// compensation handler again. It doubles after every failed attempt, up
+	// to a minute. If zero, DefaultWorkflowRetryBackoff is used.
+	RetryBackoff time.Duration
+
+	// RecoveryInterval is how often every process that created the workflow
+	// looks for unfinished instances that no process runs, e.g., because
+	// their process failed, and resumes them. If zero,
+	// DefaultWorkflowRecoveryInterval is used.
+	RecoveryInterval time.Duration
+
+	// Retention is how long a finished instance is kept, during which Wait
+	// returns its outcome and Start with its ID does nothing. If zero,
+	// DefaultWorkflowRetention is used.
+	Retention time.Duration
+
+	// LeaseDuration is how long a process remains the only one running an
+	// instance after it last renewed the instance's lease, which it does
+	// every third of LeaseDuration. If zero, DefaultLeaseDuration is used.
+	LeaseDuration time.Duration
+}
+
+// A WorkflowStep is a step of a Workflow with state of type S.
+type WorkflowStep[S any] struct {
+	// Name is the name of the step, unique within its workflow.
+	Name string
+
+	// Do runs the step of the instance with the provided ID. It may update
+	// the state of the instance, which is persisted once Do returns.
+	Do func(ctx context.Context, id string, state *S) error
+
+	// Compensate, if not nil, undoes the effects of a step that succeeded,
+	// when a later step fails.
+	Compensate func(ctx context.Context, id string, state *S) error
+}
+
+// A Workflow is a durable sequence of steps, run on a state of type S. Every
+// run of the workflow, or instance, has an ID, and its state and progress are
+// persisted in a store after every step, so that an instance whose process
+// fails is resumed by another process where it left off. A step that fails
+// is retried; if it keeps failing, the steps that succeeded are undone by
+// their compensation handlers, in reverse order. For example, a checkout
+// service can place orders with:
+//
+//	type order struct {
+//	    weaver.AutoMarshal
+//	    Req       PlaceOrderRequest
+//	    PaymentID string
+//	}
+//
+//	func (c *checkout) Init(context.Context) error {
+//	    payment := weaver.MustGet[PaymentService](c)
+//	    shipping := weaver.MustGet[ShippingService](c)
+//	    var err error
+//	    c.orders, err = weaver.NewWorkflow(c, "orders", weaver.WorkflowOptions{},
+//	        weaver.WorkflowStep[order]{
+//	            Name: "charge",
+//	            Do: func(ctx context.Context, id string, o *order) error {
+//	                var err error
+//	                ctx = weaver.WithIdempotencyKey(ctx, id)
+//	                o.PaymentID, err = payment.Charge(ctx, o.Req.Card, o.Req.Total)
+//	                return err
+//	            },
+//	            Compensate: func(ctx context.Context, id string, o *order) error {
+//	                return payment.Refund(ctx, o.PaymentID)
+//	            },
+//	        },
+//	        weaver.WorkflowStep[order]{
+//	            Name: "ship",
+//	            Do: func(ctx context.Context, id string, o *order) error {
+//	                return shipping.Ship(weaver.WithIdempotencyKey(ctx, id), o.Req.Address)
+//	            },
+//	        },
+//	    )
+//	    return err
+//	}
+//
+//	func (c *checkout) PlaceOrder(ctx context.Context, req PlaceOrderRequest) error {
+//	    _, err := c.orders.Run(ctx, req.OrderID, order{Req: req})
+//	    return err
+//	}
+//
+// A step that fails with a retriable error is attempted up to
+// WorkflowOptions.MaxAttempts times. Any other error fails the step right
+// away. A step that fails is not compensated, so it must undo its own partial
+// effects. Compensation handlers are retried until they succeed.
+//
+// A step runs at least once, but may run more than once, e.g., if its
+// process fails before the step's outcome is persisted, so steps and
+// compensation handlers must be idempotent. Pass the ID of the instance as
+// the idempotency key of their calls (see WithIdempotencyKey), or check the
+// state for the results of an earlier run.
+//
+// Like an actor, an instance runs in at most one process at any time, which
+// holds a lease granted by the deployer. When its process drains, an instance
+// stops after its current step, and is resumed by another process. The state
+// is kept in the store named "workflows/<name>", which is kept in memory
+// unless configured otherwise (see Store), e.g.:
+//
+//	[serviceweaver.stores."workflows/orders"]
+//	backend = "redis"
+//	address = "redis.internal:6379"
+//
+// The state of type S is serialized like a component method argument, so S
+// must be a struct that embeds weaver.AutoMarshal.
+type Workflow[S any] struct {
+	w      *weavelet
+	name   string
+	opts   WorkflowOptions
+	steps  []WorkflowStep[S]
+	ctx    context.Context    // done once the process drains
+	cancel context.CancelFunc // cancels ctx
+
+	mu      sync.Mutex
+	running map[string]*workflowRun // instances run by the process, by ID
+}
+
+// workflowRun is an instance run by the process.
+type workflowRun struct {
+	done chan struct{} // closed once the process stops running the instance
+}
+
+// workflowRecord is the persisted state and progress of an instance.
+type workflowRecord struct {
+	State []byte `json:"state"` // the encoded state of the instance
+
+	// While the instance runs forward, Next is the number of steps that
+	// succeeded. While it is compensated, Next is the number of steps that
+	// remain to be compensated.
+	Next         int       `json:"next"`
+	Compensating bool      `json:"compensating,omitempty"` // is the instance compensated?
+	Step         string    `json:"step,omitempty"`         // the name of the step that failed, if any
+	Error        string    `json:"error,omitempty"`        // the error of the step that failed, if any
+	Done         bool      `json:"done,omitempty"`         // has the instance finished?
+	Finished     time.Time `json:"finished,omitempty"`     // when the instance finished
+}
+
+// WorkflowError is the error of an instance that was compensated after one of
+// its steps failed.
+type WorkflowError struct {
+	Workflow string // the workflow name
+	ID       string // the ID of the instance
+	Step     string // the name of the step that failed
+	Message  string // the error of the step
+}
+
+// Error implements the error interface.
+func (e *WorkflowError) Error() string {
+	return fmt.Sprintf("workflow %q: instance %q: step %q failed: %s", e.Workflow, e.ID, e.Step, e.Message)
+}
+
+// NewWorkflow returns the workflow with the provided name and steps. Every
+// process that creates a workflow with the same name must create it with the
+// same type, options, and steps, typically in the Init method of a
+// component. A process may create a workflow with a given name only once.
+// Unfinished instances are resumed by the processes that create the
+// workflow.
+func NewWorkflow[S any](requester Instance, name string, opts WorkflowOptions, steps ...WorkflowStep[S]) (*Workflow[S], error) {
+	w := requester.rep().wlet
+	wf, err := newWorkflow(w, name, opts, steps)
+	if err != nil {
+		return nil, err
+	}
+	w.onDrain(wf.drain)
+	go wf.recoverPeriodically()
+	return wf, nil
+}
+
+// newWorkflow returns a new workflow run by the provided weavelet.
+func newWorkflow[S any](w *weavelet, name string, opts WorkflowOptions, steps []WorkflowStep[S]) (*Workflow[S], error) {
+	if _, ok := any(new(S)).(codegen.AutoMarshal); !ok {
+		var zero S
+		return nil, fmt.Errorf("NewWorkflow(%q): state type %T does not embed weaver.AutoMarshal", name, zero)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("NewWorkflow(%q): no steps", name)
+	}
+	names := map[string]bool{}
+	for i, step := range steps {
+		switch {
+		case step.Name == "":
+			return nil, fmt.Errorf("NewWorkflow(%q): step %d has no name", name, i)
+		case names[step.Name]:
+			return nil, fmt.Errorf("NewWorkflow(%q): duplicate step %q", name, step.Name)
+		case step.Do == nil:
+			return nil, fmt.Errorf("NewWorkflow(%q): step %q has no Do function", name, step.Name)
+		}
+		names[step.Name] = true
+	}
+	switch {
+	case opts.MaxAttempts < 0:
+		return nil, fmt.Errorf("NewWorkflow(%q): negative max attempts %d", name, opts.MaxAttempts)
+	case opts.RetryBackoff < 0:
+		return nil, fmt.Errorf("NewWorkflow(%q): negative retry backoff %v", name, opts.RetryBackoff)
+	case opts.RecoveryInterval < 0:
+		return nil, fmt.Errorf("NewWorkflow(%q): negative recovery interval %v", name, opts.RecoveryInterval)
+	case opts.Retention < 0:
+		return nil, fmt.Errorf("NewWorkflow(%q): negative retention %v", name, opts.Retention)
+	case opts.LeaseDuration < 0:
+		return nil, fmt.Errorf("NewWorkflow(%q): negative lease duration %v", name, opts.LeaseDuration)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = DefaultWorkflowMaxAttempts
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = DefaultWorkflowRetryBackoff
+	}
+	if opts.RecoveryInterval == 0 {
+		opts.RecoveryInterval = DefaultWorkflowRecoveryInterval
+	}
+	if opts.Retention == 0 {
+		opts.Retention = DefaultWorkflowRetention
+	}
+	if opts.LeaseDuration == 0 {
+		opts.LeaseDuration = DefaultLeaseDuration
+	}
+	if err := w.workflows.add(name); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workflow[S]{
+		w:       w,
+		name:    name,
+		opts:    opts,
+		steps:   steps,
+		ctx:     ctx,
+		cancel:  cancel,
+		running: map[string]*workflowRun{},
+	}, nil
+}
+
+// Start starts an instance with the provided ID and initial state, and
+// returns once the instance is persisted. The instance runs in the
+// background. If an instance with the same ID was already started, Start
+// does nothing.
+func (wf *Workflow[S]) Start(ctx context.Context, id string, state S) error {
+	backend, err := wf.backend(ctx)
+	if err != nil {
+		return err
+	}
+	initial := &workflowRecord{State: encodeWorkflowState(&state)}
+	retry := wf.opts.LeaseDuration / 3
+	if retry > time.Second {
+		retry = time.Second
+	}
+	for {
+		if _, ok, err := wf.get(ctx, backend, id); err != nil || ok {
+			return err
+		}
+
+		// Create the instance while holding its lease, so that no other
+		// process creates it concurrently.
+		expires, acquired, err := wf.acquire(ctx, id)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			_, ok, err := wf.get(ctx, backend, id)
+			if err == nil && !ok {
+				err = wf.put(ctx, backend, id, initial)
+			}
+			if err != nil {
+				wf.release(id)
+				return err
+			}
+			wf.run(id, expires)
+			return nil
+		}
+
+		// Another process is creating or running the instance.
+		if err := wf.w.clock.Sleep(ctx, retry); err != nil {
+			return err
+		}
+	}
+}
+
+// Wait waits for the instance with the provided ID to finish, and returns
+// its final state. If the instance was compensated, Wait returns a
+// *WorkflowError, along with the state after compensation.
+func (wf *Workflow[S]) Wait(ctx context.Context, id string) (S, error) {
+	var state S
+	backend, err := wf.backend(ctx)
+	if err != nil {
+		return state, err
+	}
+	for {
+		rec, ok, err := wf.get(ctx, backend, id)
+		if err != nil {
+			return state, err
+		}
+		if !ok {
+			return state, fmt.Errorf("workflow %q: no instance %q", wf.name, id)
+		}
+		if rec.Done {
+			if err := decodeMessage(rec.State, any(&state).(codegen.AutoMarshal)); err != nil {
+				return state, fmt.Errorf("workflow %q: decode state of %q: %w", wf.name, id, err)
+			}
+			if rec.Error != "" {
+				return state, &WorkflowError{Workflow: wf.name, ID: id, Step: rec.Step, Message: rec.Error}
+			}
+			return state, nil
+		}
+
+		var done <-chan struct{} // nil if another process runs the instance
+		wf.mu.Lock()
+		if r, ok := wf.running[id]; ok {
+			done = r.done
+		}
+		wf.mu.Unlock()
+		timer := wf.w.clock.NewTimer(workflowPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return state, ctx.Err()
+		case <-done:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// Run starts an instance with the provided ID and initial state, like Start,
+// and waits for it to finish, like Wait.
+func (wf *Workflow[S]) Run(ctx context.Context, id string, state S) (S, error) {
+	if err := wf.Start(ctx, id, state); err != nil {
+		return state, err
+	}
+	return wf.Wait(ctx, id)
+}
+
+// run runs the instance with the provided ID in the background, until it
+// finishes, its lease is lost, or the process drains. The process holds the
+// instance's lease until the provided time.
+func (wf *Workflow[S]) run(id string, expires time.Time) {
+	wf.mu.Lock()
+	if _, ok := wf.running[id]; ok {
+		// The process already runs the instance, and holds its lease.
+		wf.mu.Unlock()
+		return
+	}
+	if wf.ctx.Err() != nil {
+		wf.mu.Unlock()
+		wf.release(id)
+		return
+	}
+	r := &workflowRun{done: make(chan struct{})}
+	wf.running[id] = r
+	wf.mu.Unlock()
+
+	// Steps are not cancelled when the process drains, so that they don't
+	// stop halfway, but they are cancelled when the lease is lost.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		renewed := make(chan struct{})
+		go func() {
+			defer close(renewed)
+			wf.renew(ctx, cancel, id, expires)
+		}()
+		if err := wf.execute(ctx, id); err != nil && ctx.Err() == nil && wf.ctx.Err() == nil {
+			// The instance is resumed by the next recovery.
+			wf.w.env.SystemLogger().Error("run workflow instance", err, "workflow", wf.name, "id", id)
+		}
+		cancel()
+		<-renewed
+
+		wf.mu.Lock()
+		delete(wf.running, id)
+		wf.mu.Unlock()
+		wf.release(id)
+		close(r.done)
+	}()
+}
+
+// execute runs the steps, or the compensation handlers, of the instance with
+// the provided ID, from where the instance left off, until the instance
+// finishes. It returns early if ctx is done, or the process drains.
+func (wf *Workflow[S]) execute(ctx context.Context, id string) error {
+	backend, err := wf.backend(ctx)
+	if err != nil {
+		return err
+	}
+	rec, ok, err := wf.get(ctx, backend, id)
+	if err != nil || !ok || rec.Done {
+		return err
+	}
+	if rec.Next > len(wf.steps) {
+		return fmt.Errorf("workflow %q: instance %q has %d steps, want at most %d", wf.name, id, rec.Next, len(wf.steps))
+	}
+	state := new(S)
+	if err := decodeMessage(rec.State, any(state).(codegen.AutoMarshal)); err != nil {
+		return fmt.Errorf("workflow %q: decode state of %q: %w", wf.name, id, err)
+	}
+
+	for !rec.Done {
+		if err := wf.stopped(ctx); err != nil {
+			return err
+		}
+		if !rec.Compensating && rec.Next < len(wf.steps) {
+			step := wf.steps[rec.Next]
+			stepErr := wf.do(ctx, id, step, state)
+			if ctx.Err() != nil || (stepErr != nil && wf.ctx.Err() != nil) {
+				// The lease was lost, or the step may have failed because
+				// the process drains. The step will run again once the
+				// instance is resumed.
+				return wf.stopped(ctx)
+			}
+			if stepErr != nil {
+				rec.Compensating, rec.Step, rec.Error = true, step.Name, stepErr.Error()
+			} else {
+				rec.Next++
+			}
+		} else if rec.Compensating && rec.Next > 0 {
+			step := wf.steps[rec.Next-1]
+			if err := wf.compensate(ctx, id, step, state); err != nil {
+				return err
+			}
+			rec.Next--
+		}
+
+		outcome := "succeeded"
+		if rec.Compensating {
+			outcome = "compensated"
+		}
+		if (rec.Compensating && rec.Next == 0) || (!rec.Compensating && rec.Next == len(wf.steps)) {
+			rec.Done = true
+			rec.Finished = wf.w.clock.Now()
+		}
+		rec.State = encodeWorkflowState(state)
+		if err := wf.put(ctx, backend, id, rec); err != nil {
+			return err
+		}
+		if rec.Done {
+			workflowsFinished.Get(workflowLabels{Workflow: wf.name, Outcome: outcome}).Add(1)
+		}
+	}
+	return nil
+}
+
+// do runs a step, attempting it again if it fails with a retriable error, up
+// to the maximum number of attempts. It returns the error of the last
+// attempt.
+func (wf *Workflow[S]) do(ctx context.Context, id string, step WorkflowStep[S], state *S) error {
+	backoff := wf.opts.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := step.Do(ctx, id, state)
+		if err == nil || !errors.Is(err, ErrRetriable) || attempt >= wf.opts.MaxAttempts {
+			return err
+		}
+		wf.w.env.SystemLogger().Error("workflow step failed", err, "workflow", wf.name, "id", id, "step", step.Name, "attempt", attempt)
+		workflowStepRetries.Get(workflowStepLabels{Workflow: wf.name, Step: step.Name}).Add(1)
+		if err := wf.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = nextWorkflowBackoff(backoff)
+	}
+}
+
+// compensate runs the compensation handler of a step, if any, until it
+// succeeds. It returns an error only if ctx is done, or the process drains.
+func (wf *Workflow[S]) compensate(ctx context.Context, id string, step WorkflowStep[S], state *S) error {
+	if step.Compensate == nil {
+		return nil
+	}
+	backoff := wf.opts.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := step.Compensate(ctx, id, state)
+		if err == nil {
+			return nil
+		}
+		if err := wf.stopped(ctx); err != nil {
+			return err
+		}
+		wf.w.env.SystemLogger().Error("workflow compensation failed", err, "workflow", wf.name, "id", id, "step", step.Name, "attempt", attempt)
+		workflowStepRetries.Get(workflowStepLabels{Workflow: wf.name, Step: step.Name}).Add(1)
+		if err := wf.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = nextWorkflowBackoff(backoff)
+	}
+}
+
+// nextWorkflowBackoff returns the backoff that follows the provided one.
+func nextWorkflowBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > maxWorkflowRetryBackoff {
+		return maxWorkflowRetryBackoff
+	}
+	return backoff
+}
+
+// stopped returns a non-nil error if ctx is done, or the process drains.
+func (wf *Workflow[S]) stopped(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return wf.ctx.Err()
+}
+
+// sleep waits for the provided duration. It returns early, with an error, if
+// ctx is done, or the process drains.
+func (wf *Workflow[S]) sleep(ctx context.Context, d time.Duration) error {
+	timer := wf.w.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wf.ctx.Done():
+		return wf.ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// renew renews the lease of the instance with the provided ID until ctx is
+// done, and calls cancel if the lease is lost. The lease expires at the
+// provided time unless renewed.
+func (wf *Workflow[S]) renew(ctx context.Context, cancel context.CancelFunc, id string, expires time.Time) {
+	// The lease is lost when it expires, even if a renewal is stuck.
+	clock := wf.w.clock
+	expiry := afterFunc(clock, expires.Sub(clock.Now()), cancel)
+	defer expiry.Stop()
+	ticker := clock.NewTicker(wf.opts.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		expires, acquired, err := wf.acquire(ctx, id)
+		switch {
+		case err != nil:
+			// Try again, unless the lease expires first.
+			wf.w.env.SystemLogger().Error("renew workflow lease", err, "workflow", wf.name, "id", id)
+		case !acquired:
+			// Another process holds the lease.
+			cancel()
+			return
+		default:
+			expiry.Reset(expires.Sub(clock.Now()))
+		}
+	}
+}
+
+// recoverPeriodically resumes abandoned instances, and deletes expired ones,
+// every recovery interval, until the process drains.
+func (wf *Workflow[S]) recoverPeriodically() {
+	ticker := wf.w.clock.NewTicker(wf.opts.RecoveryInterval)
+	defer ticker.Stop()
+	for {
+		if err := wf.recover(wf.ctx); err != nil && wf.ctx.Err() == nil {
+			wf.w.env.SystemLogger().Error("recover workflow instances", err, "workflow", wf.name)
+		}
+		select {
+		case <-wf.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// recover resumes the unfinished instances that no process runs, and deletes
+// the instances that finished more than the retention period ago.
+func (wf *Workflow[S]) recover(ctx context.Context) error {
+	backend, err := wf.backend(ctx)
+	if err != nil {
+		return err
+	}
+	ids, err := backend.Keys(ctx)
+	if err != nil {
+		return fmt.Errorf("workflow %q: list instances: %w", wf.name, err)
+	}
+	for _, id := range ids {
+		wf.mu.Lock()
+		_, running := wf.running[id]
+		wf.mu.Unlock()
+		if running {
+			continue
+		}
+		rec, ok, err := wf.get(ctx, backend, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if rec.Done {
+			if wf.w.clock.Since(rec.Finished) > wf.opts.Retention {
+				if err := backend.Delete(ctx, id); err != nil {
+					return fmt.Errorf("workflow %q: delete instance %q: %w", wf.name, id, err)
+				}
+			}
+			continue
+		}
+		expires, acquired, err := wf.acquire(ctx, id)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			wf.run(id, expires)
+		}
+	}
+	return nil
+}
+
+// drain stops the recovery of instances, and waits for the instances run by
+// the process to stop after their current step. It is called when the
+// process drains.
+func (wf *Workflow[S]) drain(ctx context.Context) error {
+	wf.mu.Lock()
+	wf.cancel()
+	runs := make([]*workflowRun, 0, len(wf.running))
+	for _, r := range wf.running {
+		runs = append(runs, r)
+	}
+	wf.mu.Unlock()
+
+	for _, r := range runs {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// backend returns the backend of the store of the workflow.
+func (wf *Workflow[S]) backend(ctx context.Context) (kv.Backend, error) {
+	return wf.w.stores.backend(ctx, "workflows/"+wf.name)
+}
+
+// get returns the record of the instance with the provided ID, and whether
+// the instance exists.
+func (wf *Workflow[S]) get(ctx context.Context, backend kv.Backend, id string) (*workflowRecord, bool, error) {
+	data, ok, err := backend.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var rec workflowRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false, fmt.Errorf("workflow %q: decode instance %q: %w", wf.name, id, err)
+	}
+	return &rec, true, nil
+}
+
+// put persists the record of the instance with the provided ID.
+func (wf *Workflow[S]) put(ctx context.Context, backend kv.Backend, id string, rec *workflowRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, id, data)
+}
+
+// acquire acquires, or renews, the lease of the instance with the provided ID,
+// and returns when the lease expires unless renewed.
+func (wf *Workflow[S]) acquire(ctx context.Context, id string) (time.Time, bool, error) {
+	start := wf.w.clock.Now()
+	acquired, err := wf.lease(ctx, id, false)
+	return start.Add(wf.opts.LeaseDuration), acquired, err
+}
+
+// release releases the lease of the instance with the provided ID, so that
+// another process can resume the instance without waiting for the lease to
+// expire.
+func (wf *Workflow[S]) release(id string) {
+	if _, err := wf.lease(context.Background(), id, true); err != nil {
+		wf.w.env.SystemLogger().Error("release workflow lease", err, "workflow", wf.name, "id", id)
+	}
+}
+
+// lease acquires, renews, or releases the lease of the provided ID.
+func (wf *Workflow[S]) lease(ctx context.Context, id string, release bool) (bool, error) {
+	reply, err := wf.w.env.Lease(ctx, &protos.LeaseRequest{
+		Election:       fmt.Sprintf("serviceweaver/workflows/%s/%s", wf.name, id),
+		Holder:         wf.w.info.Id,
+		DurationMicros: wf.opts.LeaseDuration.Microseconds(),
+		Release:        release,
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply.Acquired, nil
+}
+
+// encodeWorkflowState encodes the state of an instance.
+func encodeWorkflowState[S any](state *S) []byte {
+	enc := codegen.NewEncoder()
+	any(state).(codegen.AutoMarshal).WeaverMarshal(enc)
+	return enc.Data()
+}
+
+// workflowNames holds the names of the workflows created with NewWorkflow in
+// a weavelet.
+type workflowNames struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newWorkflowNames() *workflowNames {
+	return &workflowNames{names: map[string]bool{}}
+}
+
+// add adds the provided workflow. It returns an error if a workflow with the
+// same name was already added.
+func (n *workflowNames) add(name string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.names[name] {
+		return fmt.Errorf("workflow %q already created", name)
+	}
+	n.names[name] = true
+	return nil
+}
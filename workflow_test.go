@@ -0,0 +1,260 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package weaver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ServiceWeaver/weaver/internal/lease"
+	"github.com/ServiceWeaver/weaver/runtime/codegen"
+	"github.com/ServiceWeaver/weaver/runtime/protos"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testOrder is the state of a test workflow.
+type testOrder struct {
+	Log []string // the steps and compensation handlers run, in order
+}
+
+func (o *testOrder) WeaverMarshal(enc *codegen.Encoder) {
+	enc.Len(len(o.Log))
+	for _, entry := range o.Log {
+		enc.String(entry)
+	}
+}
+
+func (o *testOrder) WeaverUnmarshal(dec *codegen.Decoder) {
+	o.Log = nil
+	for n := dec.Len(); n > 0; n-- {
+		o.Log = append(o.Log, dec.String())
+	}
+}
+
+// testStep returns a step that logs its runs in the state, and fails with
+// the errors returned by fail, if not nil.
+func testStep(name string, fail func() error) WorkflowStep[testOrder] {
+	return WorkflowStep[testOrder]{
+		Name: name,
+		Do: func(_ context.Context, _ string, o *testOrder) error {
+			o.Log = append(o.Log, "do "+name)
+			if fail != nil {
+				return fail()
+			}
+			return nil
+		},
+		Compensate: func(_ context.Context, _ string, o *testOrder) error {
+			o.Log = append(o.Log, "undo "+name)
+			return nil
+		},
+	}
+}
+
+// testWorkflow returns a workflow run by a weavelet with the provided id,
+// whose leases are granted by the provided table, and whose instances are
+// kept in the provided stores.
+func testWorkflow(t *testing.T, table *lease.Table, s *stores, id string, opts WorkflowOptions, steps ...WorkflowStep[testOrder]) *Workflow[testOrder] {
+	t.Helper()
+	w := &weavelet{
+		env:       leaseEnv{t: t, table: table},
+		info:      &protos.EnvelopeInfo{Id: id},
+		workflows: newWorkflowNames(),
+		stores:    s,
+		clock:     systemClock{},
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Millisecond
+	}
+	wf, err := newWorkflow(w, "orders", opts, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newWorkflow(w, "orders", opts, steps); err == nil {
+		t.Fatal("newWorkflow with the same name: unexpected success")
+	}
+	t.Cleanup(func() { wf.drain(context.Background()) })
+	return wf
+}
+
+func TestWorkflowSucceeds(t *testing.T) {
+	ctx := context.Background()
+	wf := testWorkflow(t, lease.NewTable(), newStores(nil), "w1", WorkflowOptions{},
+		testStep("charge", nil), testStep("ship", nil), testStep("email", nil))
+
+	got, err := wf.Run(ctx, "order-1", testOrder{Log: []string{"start"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start", "do charge", "do ship", "do email"}
+	if diff := cmp.Diff(want, got.Log); diff != "" {
+		t.Fatalf("Run (-want +got):\n%s", diff)
+	}
+
+	// Starting the instance again does nothing.
+	got, err = wf.Run(ctx, "order-1", testOrder{Log: []string{"again"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got.Log); diff != "" {
+		t.Fatalf("Run again (-want +got):\n%s", diff)
+	}
+}
+
+func TestWorkflowCompensates(t *testing.T) {
+	// Test plan: Fail the second of three steps, and check that only the
+	// first step is compensated, and that the third step never runs.
+	ctx := context.Background()
+	declined := errors.New("card declined")
+	wf := testWorkflow(t, lease.NewTable(), newStores(nil), "w1", WorkflowOptions{},
+		testStep("reserve", nil),
+		testStep("charge", func() error { return declined }),
+		testStep("ship", nil))
+
+	got, err := wf.Run(ctx, "order-1", testOrder{})
+	var werr *WorkflowError
+	if !errors.As(err, &werr) {
+		t.Fatalf("Run: got %v, want *WorkflowError", err)
+	}
+	wantErr := &WorkflowError{Workflow: "orders", ID: "order-1", Step: "charge", Message: "card declined"}
+	if diff := cmp.Diff(wantErr, werr); diff != "" {
+		t.Fatalf("Run: error (-want +got):\n%s", diff)
+	}
+	want := []string{"do reserve", "do charge", "undo reserve"}
+	if diff := cmp.Diff(want, got.Log); diff != "" {
+		t.Fatalf("Run (-want +got):\n%s", diff)
+	}
+}
+
+func TestWorkflowRetries(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name     string
+		failures int32 // number of retriable failures of the step
+		want     []string
+	}{
+		{"Recovers", 2, []string{"do charge", "do charge", "do charge", "do ship"}},
+		{"GivesUp", 3, []string{"do charge", "do charge", "do charge"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var failures atomic.Int32
+			fail := func() error {
+				if failures.Add(1) <= test.failures {
+					return retriable{errors.New("payment service unavailable")}
+				}
+				return nil
+			}
+			wf := testWorkflow(t, lease.NewTable(), newStores(nil), "w1", WorkflowOptions{MaxAttempts: 3},
+				testStep("charge", fail), testStep("ship", nil))
+			got, _ := wf.Run(ctx, "order-1", testOrder{})
+			if diff := cmp.Diff(test.want, got.Log); diff != "" {
+				t.Fatalf("Run (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorkflowResumes(t *testing.T) {
+	// Test plan: Drain a process while it runs the second of three steps, and
+	// check that it finishes the step, and that another process resumes the
+	// instance at the third step.
+	ctx := context.Background()
+	table := lease.NewTable()
+	s := newStores(nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	block := func() error {
+		close(started)
+		<-release
+		return nil
+	}
+	wf1 := testWorkflow(t, table, s, "w1", WorkflowOptions{},
+		testStep("charge", nil), testStep("ship", block), testStep("email", nil))
+	if err := wf1.Start(ctx, "order-1", testOrder{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	drained := make(chan error)
+	go func() { drained <- wf1.drain(ctx) }()
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatal(err)
+	}
+
+	// The step that was running when w1 drained isn't run again.
+	once := func() error {
+		t.Error("ship ran twice")
+		return nil
+	}
+	wf2 := testWorkflow(t, table, s, "w2", WorkflowOptions{},
+		testStep("charge", nil), testStep("ship", once), testStep("email", nil))
+	if err := wf2.recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := wf2.Wait(ctx, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"do charge", "do ship", "do email"}
+	if diff := cmp.Diff(want, got.Log); diff != "" {
+		t.Fatalf("Wait (-want +got):\n%s", diff)
+	}
+}
+
+func TestWorkflowRetention(t *testing.T) {
+	ctx := context.Background()
+	wf := testWorkflow(t, lease.NewTable(), newStores(nil), "w1", WorkflowOptions{Retention: time.Millisecond},
+		testStep("charge", nil))
+	if _, err := wf.Run(ctx, "order-1", testOrder{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := wf.recover(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wf.Wait(ctx, "order-1"); err == nil {
+		t.Fatal("Wait on an expired instance: unexpected success")
+	}
+}
+
+func TestNewWorkflowErrors(t *testing.T) {
+	w := &weavelet{workflows: newWorkflowNames()}
+	step := testStep("charge", nil)
+	for _, test := range []struct {
+		name  string
+		opts  WorkflowOptions
+		steps []WorkflowStep[testOrder]
+	}{
+		{"NoSteps", WorkflowOptions{}, nil},
+		{"NoName", WorkflowOptions{}, []WorkflowStep[testOrder]{{Do: step.Do}}},
+		{"NoDo", WorkflowOptions{}, []WorkflowStep[testOrder]{{Name: "charge"}}},
+		{"DuplicateSteps", WorkflowOptions{}, []WorkflowStep[testOrder]{step, step}},
+		{"NegativeMaxAttempts", WorkflowOptions{MaxAttempts: -1}, []WorkflowStep[testOrder]{step}},
+		{"NegativeRetention", WorkflowOptions{Retention: -1}, []WorkflowStep[testOrder]{step}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newWorkflow(w, test.name, test.opts, test.steps); err == nil {
+				t.Fatal("newWorkflow: unexpected success")
+			}
+		})
+	}
+
+	// The state must embed weaver.AutoMarshal.
+	if _, err := newWorkflow(w, "plain", WorkflowOptions{}, []WorkflowStep[counter]{{Name: "a", Do: func(context.Context, string, *counter) error { return nil }}}); err == nil {
+		t.Fatal("newWorkflow with a plain state: unexpected success")
+	}
+}